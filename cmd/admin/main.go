@@ -0,0 +1,711 @@
+// Command zentalk-admin is the operator CLI for ZenTalk relay and storage nodes
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/blockchain"
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/meshstorage"
+	"github.com/ZentaChain/zentalk-node/pkg/meshstorage/api"
+	"github.com/ZentaChain/zentalk-node/pkg/release"
+	"github.com/ZentaChain/zentalk-node/pkg/storage"
+)
+
+var (
+	apiURL     = flag.String("api", "http://localhost:8080", "Mesh API base URL")
+	adminToken = flag.String("token", os.Getenv("ZENTALK_ADMIN_TOKEN"), "Admin token configured on the node (-admin-token)")
+	timeout    = flag.Duration("timeout", 30*time.Second, "HTTP request timeout")
+)
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, args := flag.Arg(0), flag.Args()[1:]
+	var err error
+	switch cmd {
+	case "stats":
+		err = cmdStats()
+	case "peers":
+		err = cmdPeers()
+	case "status":
+		err = cmdStatus()
+	case "drain":
+		err = cmdDrain(true)
+	case "resume":
+		err = cmdDrain(false)
+	case "repair":
+		err = cmdRepair(args)
+	case "blocklist":
+		err = cmdBlocklist(args)
+	case "integrity":
+		err = cmdIntegrity()
+	case "chaos":
+		err = cmdChaos(args)
+	case "usage":
+		err = cmdUsage(args)
+	case "logs":
+		err = cmdLogs(args)
+	case "rotate-key":
+		err = cmdRotateKey(args)
+	case "bot-key":
+		err = cmdBotKey(args)
+	case "verify-proofs":
+		err = cmdVerifyProofs(args)
+	case "release-key":
+		err = cmdReleaseKey(args)
+	case "sign-release":
+		err = cmdSignRelease(args)
+	case "claim-rewards":
+		err = cmdClaimRewards(args)
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `zentalk-admin - operate ZenTalk relay and storage nodes
+
+Usage: zentalk-admin [flags] <command> [args]
+
+Storage node commands (mesh API):
+  stats                          Storage and traffic statistics
+  peers                          Connected mesh peers
+  status                         Drain, repair and blocklist state (admin)
+  drain                          Stop accepting uploads; running uploads finish (admin)
+  resume                         Accept uploads again (admin)
+  repair [-wait]                 Health-check and repair every tracked chunk (admin)
+  blocklist list                 Show banned peers (admin)
+  blocklist add <peerID> [why]   Ban a peer and drop its connections (admin)
+  blocklist remove <peerID>      Lift a ban (admin)
+  integrity                      Shard transfers per peer that failed their checksum (admin)
+  chaos [spec|off]               Show or set fault injection; chaos builds only (admin)
+  usage [-csv|-json] [epoch]     List metered epochs, or export one epoch's usage report (admin)
+  logs [-n lines]                Latest log lines, after repeat suppression (admin)
+
+Relay commands (local):
+  rotate-key [-key path]         Replace the relay identity key, keeping a backup
+  bot-key [-key path] [-label l] Print a bot's API key for the relay's -bots file
+  verify-proofs <file>           Check a relay's delivery proof export (-export-proofs)
+  claim-rewards -contract addr   Claim the operator's relay rewards from the registry contract
+                 [-key path] [-rpc url]
+
+Release commands (local):
+  release-key [-key path]        Create a release signing key; prints the relays' -update-key
+  sign-release [-key path] <f>   Sign a release manifest (JSON) for -update-manifest, to stdout
+
+Admin commands need the node's -admin-token, via -token or ZENTALK_ADMIN_TOKEN.
+
+Flags:
+`)
+	flag.PrintDefaults()
+}
+
+// call sends a request to the mesh API and decodes the JSON response into out
+func call(method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, strings.TrimRight(*apiURL, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if *adminToken != "" {
+		req.Header.Set("Authorization", "Bearer "+*adminToken)
+	}
+
+	client := &http.Client{Timeout: *timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		var apiErr api.ErrorResponse
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != "" {
+			if apiErr.Message != "" {
+				return fmt.Errorf("%s (HTTP %d): %s", apiErr.Error, resp.StatusCode, apiErr.Message)
+			}
+			return fmt.Errorf("%s (HTTP %d)", apiErr.Error, resp.StatusCode)
+		}
+		if resp.StatusCode == http.StatusNotFound && strings.HasPrefix(path, "/api/v1/admin") {
+			return fmt.Errorf("admin API not enabled on this node (start mesh-api with -admin-token)")
+		}
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+func cmdStats() error {
+	var info api.NodeInfoResponse
+	if err := call(http.MethodGet, "/api/v1/node/info", nil, &info); err != nil {
+		return err
+	}
+	var stats api.NodeStatsResponse
+	if err := call(http.MethodGet, "/api/v1/node/stats", nil, &stats); err != nil {
+		return err
+	}
+
+	st := stats.Stats
+	fmt.Printf("Node:            %s\n", info.NodeID)
+	fmt.Printf("Bootstrap:       %v (bootstrapped: %v)\n", info.IsBootstrap, info.Bootstrapped)
+	fmt.Printf("Connected peers: %d\n", info.ConnectedAt)
+	fmt.Printf("Started:         %s\n", info.StartedAt.Format(time.RFC3339))
+	var enabled []string
+	for _, f := range info.Features {
+		if f.Enabled {
+			enabled = append(enabled, fmt.Sprintf("%s/%d", f.Name, f.Version))
+		}
+	}
+	if len(enabled) == 0 {
+		enabled = append(enabled, "none")
+	}
+	fmt.Printf("Features:        %s\n", strings.Join(enabled, ", "))
+	fmt.Println()
+	fmt.Printf("Chunks:          %d (%d users)\n", st.TotalChunks, st.UniqueUsers)
+	fmt.Printf("Stored:          %.2f GB (avg chunk %d bytes)\n", st.TotalSizeGB, st.AverageChunkSize)
+	fmt.Printf("Uploads:         %d\n", st.UploadCount)
+	fmt.Printf("Downloads:       %d\n", st.DownloadCount)
+	fmt.Printf("Success rate:    %.1f%%\n", st.SuccessRate)
+	fmt.Printf("Cache:           %d bytes (hit rate %.1f%%)\n", st.CacheBytes, st.CacheHitRate)
+	return nil
+}
+
+func cmdPeers() error {
+	var peers api.PeersResponse
+	if err := call(http.MethodGet, "/api/v1/network/peers", nil, &peers); err != nil {
+		return err
+	}
+
+	fmt.Printf("%d peers\n", peers.Count)
+	for _, p := range peers.Peers {
+		state := "disconnected"
+		if p.Connected {
+			state = "connected"
+		}
+		fmt.Printf("  %s  %-12s  %s\n", p.PeerID, state, strings.Join(p.Addresses, ", "))
+	}
+	return nil
+}
+
+func cmdStatus() error {
+	var status api.AdminStatusResponse
+	if err := call(http.MethodGet, "/api/v1/admin/status", nil, &status); err != nil {
+		return err
+	}
+
+	fmt.Printf("Node:              %s\n", status.NodeID)
+	fmt.Printf("Draining:          %v\n", status.Draining)
+	fmt.Printf("Shutting down:     %v\n", status.ShuttingDown)
+	fmt.Printf("Uploads in flight: %d\n", status.UploadsInFlight)
+	fmt.Printf("Tracked chunks:    %d\n", status.TrackedChunks)
+	fmt.Printf("Connected peers:   %d\n", status.ConnectedPeers)
+	fmt.Printf("Blocked peers:     %d\n", status.BlockedPeers)
+	if len(status.MaintenancePeers) > 0 {
+		fmt.Printf("In maintenance:    %s\n", strings.Join(status.MaintenancePeers, ", "))
+	}
+	return nil
+}
+
+func cmdDrain(drain bool) error {
+	path := "/api/v1/admin/resume"
+	if drain {
+		path = "/api/v1/admin/drain"
+	}
+	var resp api.SuccessResponse
+	if err := call(http.MethodPost, path, nil, &resp); err != nil {
+		return err
+	}
+	fmt.Printf("✅ %s\n", resp.Message)
+	return nil
+}
+
+func cmdRepair(args []string) error {
+	fs := flag.NewFlagSet("repair", flag.ExitOnError)
+	wait := fs.Bool("wait", false, "Wait for the pass to finish and print its report")
+	fs.Parse(args)
+
+	var started api.SuccessResponse
+	if err := call(http.MethodPost, "/api/v1/admin/repair", nil, &started); err != nil {
+		return err
+	}
+	fmt.Printf("🔧 %s\n", started.Message)
+	if !*wait {
+		return nil
+	}
+
+	for {
+		time.Sleep(2 * time.Second)
+		var status api.RepairStatusResponse
+		if err := call(http.MethodGet, "/api/v1/admin/repair", nil, &status); err != nil {
+			return err
+		}
+		if status.Running {
+			continue
+		}
+
+		r := status.Report
+		fmt.Printf("Finished in %s\n", status.FinishedAt.Sub(status.StartedAt).Round(time.Second))
+		fmt.Printf("  checked:       %d\n", r.Checked)
+		fmt.Printf("  healthy:       %d\n", r.Healthy)
+		fmt.Printf("  repaired:      %d\n", r.Repaired)
+		fmt.Printf("  repair failed: %d\n", r.RepairFailed)
+		fmt.Printf("  unrecoverable: %d\n", r.Unrecoverable)
+		fmt.Printf("  check failed:  %d\n", r.CheckFailed)
+		return nil
+	}
+}
+
+func cmdBlocklist(args []string) error {
+	action := "list"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case "list":
+		var list api.BlocklistResponse
+		if err := call(http.MethodGet, "/api/v1/admin/blocklist", nil, &list); err != nil {
+			return err
+		}
+		fmt.Printf("%d blocked peers\n", list.Count)
+		for _, p := range list.Peers {
+			fmt.Printf("  %s  since %s  %s\n", p.ID, time.Unix(p.BlockedAt, 0).Format(time.RFC3339), p.Reason)
+		}
+		return nil
+
+	case "add":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: blocklist add <peerID> [reason]")
+		}
+		req := api.BlockPeerRequest{PeerID: args[1], Reason: strings.Join(args[2:], " ")}
+		var resp api.SuccessResponse
+		if err := call(http.MethodPost, "/api/v1/admin/blocklist", req, &resp); err != nil {
+			return err
+		}
+		fmt.Printf("⛔ %s\n", resp.Message)
+		return nil
+
+	case "remove":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: blocklist remove <peerID>")
+		}
+		var resp api.SuccessResponse
+		if err := call(http.MethodDelete, "/api/v1/admin/blocklist/"+url.PathEscape(args[1]), nil, &resp); err != nil {
+			return err
+		}
+		fmt.Printf("✅ %s\n", resp.Message)
+		return nil
+	}
+
+	return fmt.Errorf("unknown blocklist action %q (list, add, remove)", action)
+}
+
+func cmdIntegrity() error {
+	var resp api.IntegrityResponse
+	if err := call(http.MethodGet, "/api/v1/admin/integrity", nil, &resp); err != nil {
+		return err
+	}
+
+	fmt.Printf("%d peers\n", resp.Count)
+	for _, p := range resp.Peers {
+		excluded := ""
+		if p.Excluded {
+			excluded = "  excluded from placement"
+		}
+		fmt.Printf("  %s  %d/%d corrupted (%.1f%%)  score %.2f%s\n",
+			p.PeerID, p.Corrupted, p.Transfers, p.CorruptionRate*100, p.Score, excluded)
+	}
+	return nil
+}
+
+func cmdChaos(args []string) error {
+	var resp api.ChaosResponse
+	switch {
+	case len(args) == 0:
+		if err := call(http.MethodGet, "/api/v1/admin/chaos", nil, &resp); err != nil {
+			return err
+		}
+	case len(args) == 1:
+		spec := args[0]
+		if spec == "off" {
+			spec = ""
+		}
+		if err := call(http.MethodPut, "/api/v1/admin/chaos", api.ChaosRequest{Spec: spec}, &resp); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("usage: chaos [drop=0.05,delay=0.1,max-delay=2s,corrupt=0.01,disconnect=0.01 | off]")
+	}
+
+	fmt.Printf("🌀 %s\n", resp.Spec)
+	for point, n := range resp.Stats {
+		fmt.Printf("  %-32s %d\n", point, n)
+	}
+	return nil
+}
+
+// cmdUsage lists the node's usage epochs or prints one epoch's report
+func cmdUsage(args []string) error {
+	fs := flag.NewFlagSet("usage", flag.ExitOnError)
+	asCSV := fs.Bool("csv", false, "Print the report as CSV")
+	asJSON := fs.Bool("json", false, "Print the report as JSON")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		var list api.UsageEpochsResponse
+		if err := call(http.MethodGet, "/api/v1/admin/usage", nil, &list); err != nil {
+			return err
+		}
+		fmt.Printf("%d epochs\n", len(list.Epochs))
+		for _, start := range list.Epochs {
+			fmt.Printf("  %d  %s\n", start, time.Unix(start, 0).UTC().Format(time.RFC3339))
+		}
+		return nil
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: usage [-csv|-json] [epoch]")
+	}
+	if _, err := strconv.ParseInt(fs.Arg(0), 10, 64); err != nil {
+		return fmt.Errorf("epoch must be its start in Unix seconds, as listed by usage")
+	}
+
+	var report meshstorage.UsageReport
+	if err := call(http.MethodGet, "/api/v1/admin/usage/"+fs.Arg(0), nil, &report); err != nil {
+		return err
+	}
+
+	switch {
+	case *asCSV:
+		return report.WriteCSV(os.Stdout)
+	case *asJSON:
+		out, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+
+	fmt.Printf("Node:      %s\n", report.NodeID)
+	fmt.Printf("Epoch:     %s to %s (closed: %v)\n",
+		time.Unix(report.EpochStart, 0).UTC().Format(time.RFC3339),
+		time.Unix(report.EpochEnd, 0).UTC().Format(time.RFC3339),
+		report.Closed)
+	fmt.Printf("Digest:    %s\n", report.Digest)
+	if report.CommittedAt != 0 {
+		fmt.Printf("Committed: %s\n", time.Unix(report.CommittedAt, 0).UTC().Format(time.RFC3339))
+	}
+	fmt.Printf("%d users\n", len(report.Users))
+	for _, u := range report.Users {
+		fmt.Printf("  %s  %12.6f GB-h  %12d bytes served\n", u.UserAddr, float64(u.ByteSeconds)/(1e9*3600), u.BytesServed)
+	}
+	return nil
+}
+
+// cmdLogs prints the node's latest log lines
+func cmdLogs(args []string) error {
+	fs := flag.NewFlagSet("logs", flag.ExitOnError)
+	lines := fs.Int("n", 100, "Number of lines")
+	fs.Parse(args)
+
+	var logs api.LogsResponse
+	if err := call(http.MethodGet, fmt.Sprintf("/api/v1/admin/logs?limit=%d", *lines), nil, &logs); err != nil {
+		return err
+	}
+	for _, e := range logs.Entries {
+		fmt.Printf("%s %s\n", e.Time.Format("2006/01/02 15:04:05"), e.Line)
+	}
+	return nil
+}
+
+// cmdRotateKey replaces a relay's RSA identity key in place
+// The old key pair is kept next to the new one so a rotation can be rolled back
+func cmdRotateKey(args []string) error {
+	fs := flag.NewFlagSet("rotate-key", flag.ExitOnError)
+	keyPath := fs.String("key", "./keys/relay.pem", "Relay private key file")
+	fs.Parse(args)
+
+	oldPEM, err := crypto.LoadKeyFromFile(*keyPath)
+	if err != nil {
+		return fmt.Errorf("failed to read current key: %w", err)
+	}
+	oldKey, err := crypto.ImportPrivateKeyPEM(oldPEM)
+	if err != nil {
+		return fmt.Errorf("current key is not a valid RSA private key: %w", err)
+	}
+
+	suffix := "." + time.Now().Format("20060102-150405") + ".bak"
+	if err := crypto.SaveKeyToFile(*keyPath+suffix, oldPEM); err != nil {
+		return fmt.Errorf("failed to back up current key: %w", err)
+	}
+	if pubPEM, err := crypto.LoadKeyFromFile(*keyPath + ".pub"); err == nil {
+		if err := crypto.SaveKeyToFile(*keyPath+".pub"+suffix, pubPEM); err != nil {
+			return fmt.Errorf("failed to back up current public key: %w", err)
+		}
+	}
+
+	fmt.Println("Generating new RSA-4096 key pair...")
+	newKey, err := crypto.GenerateRSAKeyPair()
+	if err != nil {
+		return err
+	}
+	newPEM, err := crypto.ExportPrivateKeyPEM(newKey)
+	if err != nil {
+		return err
+	}
+	newPubPEM, err := crypto.ExportPublicKeyPEM(&newKey.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	if err := crypto.SaveKeyToFile(*keyPath, newPEM); err != nil {
+		return fmt.Errorf("failed to write new key: %w", err)
+	}
+	if err := crypto.SaveKeyToFile(*keyPath+".pub", newPubPEM); err != nil {
+		return fmt.Errorf("failed to write new public key: %w", err)
+	}
+
+	fmt.Printf("✅ Key rotated: %s\n", *keyPath)
+	fmt.Printf("   old: %s (backup %s)\n", fingerprint(&oldKey.PublicKey), *keyPath+suffix)
+	fmt.Printf("   new: %s\n", fingerprint(&newKey.PublicKey))
+	fmt.Println("Restart the relay to load the new key.")
+	return nil
+}
+
+// cmdBotKey prints the API key a bot derives from its identity key
+// The bot runs with the same label; the operator adds the key to the relay's -bots file.
+func cmdBotKey(args []string) error {
+	fs := flag.NewFlagSet("bot-key", flag.ExitOnError)
+	keyPath := fs.String("key", "./keys/bot.pem", "Bot private key file")
+	label := fs.String("label", "", "Key label; change it to revoke a leaked key")
+	fs.Parse(args)
+
+	keyPEM, err := crypto.LoadKeyFromFile(*keyPath)
+	if err != nil {
+		return fmt.Errorf("failed to read bot key: %w", err)
+	}
+	key, err := crypto.ImportPrivateKeyPEM(keyPEM)
+	if err != nil {
+		return fmt.Errorf("bot key is not a valid RSA private key: %w", err)
+	}
+
+	apiKey, err := crypto.DeriveBotAPIKey(key, *label)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Bot key %s (label %q)\n", fingerprint(&key.PublicKey), *label)
+	fmt.Println(hex.EncodeToString(apiKey))
+	return nil
+}
+
+// cmdVerifyProofs checks the signatures in a relay's delivery proof export
+// The key fingerprint printed must match the relay's registered key for the proofs to count.
+func cmdVerifyProofs(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: verify-proofs <file>")
+	}
+
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to read proof export: %w", err)
+	}
+
+	var export storage.DeliveryProofExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return fmt.Errorf("failed to parse proof export: %w", err)
+	}
+
+	count, err := export.Verify()
+	if err != nil {
+		return err
+	}
+
+	pubKey, _ := crypto.ImportPublicKeyPEM(export.PublicKey)
+	fmt.Printf("✓ %d delivery proofs signed by relay %s (key %s)\n", count, export.Relay, fingerprint(pubKey))
+	if count > 0 {
+		first := time.UnixMilli(export.Proofs[0].Timestamp).UTC()
+		last := time.UnixMilli(export.Proofs[count-1].Timestamp).UTC()
+		fmt.Printf("  %s to %s\n", first.Format(time.RFC3339), last.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// cmdReleaseKey creates the Ed25519 key release manifests are signed with
+func cmdReleaseKey(args []string) error {
+	fs := flag.NewFlagSet("release-key", flag.ExitOnError)
+	keyPath := fs.String("key", "./keys/release.key", "Release signing key file to create")
+	fs.Parse(args)
+
+	if _, err := os.Stat(*keyPath); err == nil {
+		return fmt.Errorf("%s already exists", *keyPath)
+	}
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(*keyPath), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(*keyPath, []byte(hex.EncodeToString(priv.Seed())+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write release key: %w", err)
+	}
+
+	fmt.Printf("✅ Release key saved to %s\n", *keyPath)
+	fmt.Println("Relays verify manifests with -update-key:")
+	fmt.Println(hex.EncodeToString(pub))
+	return nil
+}
+
+// cmdSignRelease signs a release manifest and prints the document relays fetch
+func cmdSignRelease(args []string) error {
+	fs := flag.NewFlagSet("sign-release", flag.ExitOnError)
+	keyPath := fs.String("key", "./keys/release.key", "Release signing key file")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: sign-release [-key path] <manifest.json>")
+	}
+
+	keyHex, err := os.ReadFile(*keyPath)
+	if err != nil {
+		return fmt.Errorf("failed to read release key: %w", err)
+	}
+	seed, err := hex.DecodeString(strings.TrimSpace(string(keyHex)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return fmt.Errorf("%s is not a release key", *keyPath)
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var manifest release.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return fmt.Errorf("failed to parse manifest: %w", err)
+	}
+
+	signed, err := release.Sign(&manifest, ed25519.NewKeyFromSeed(seed))
+	if err != nil {
+		return err
+	}
+
+	out, err := json.MarshalIndent(signed, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+// fingerprint returns a short SHA-256 fingerprint of a public key
+func fingerprint(pub interface{}) string {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "unknown"
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:16])
+}
+
+func cmdClaimRewards(args []string) error {
+	fs := flag.NewFlagSet("claim-rewards", flag.ExitOnError)
+	keyPath := fs.String("key", "./keys/operator.key", "Hex private key file of the operator account (the relay's -eth-key)")
+	contract := fs.String("contract", "", "Registry contract address")
+	rpc := fs.String("rpc", "https://rpc.sepolia.org", "RPC URL")
+	fs.Parse(args)
+
+	if *contract == "" {
+		return fmt.Errorf("usage: claim-rewards -contract addr [-key path] [-rpc url]")
+	}
+
+	key, err := blockchain.LoadKey(*keyPath)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), blockchain.DefaultTxTimeout)
+	defer cancel()
+
+	registry, err := blockchain.Dial(ctx, blockchain.Config{RPCURL: *rpc, Contract: *contract, Key: key})
+	if err != nil {
+		return err
+	}
+	defer registry.Close()
+
+	pending, err := registry.PendingRewards(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Operator:        %s\n", registry.Operator().Hex())
+	fmt.Printf("Pending rewards: %s CHAIN\n", formatCHAIN(pending))
+	if pending.Sign() == 0 {
+		fmt.Println("Nothing to claim")
+		return nil
+	}
+
+	if err := registry.ClaimRewards(ctx); err != nil {
+		return err
+	}
+	fmt.Println("✅ Rewards claimed")
+	return nil
+}
+
+// formatCHAIN formats an amount in wei as CHAIN (18 decimals)
+func formatCHAIN(wei *big.Int) string {
+	return new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(1e18)).Text('f', 6)
+}
@@ -0,0 +1,246 @@
+// Command zentalk-dissect decodes captured ZenTalk relay traffic for protocol debugging
+//
+// It reads a libpcap capture (tcpdump -w) or a raw dump of one direction of a
+// connection and prints the message flow: which side sent what type of frame, when,
+// with which flags and message ID. Payloads are never decrypted.
+//
+//	tcpdump -i any -w relay.pcap tcp port 8080
+//	zentalk-dissect -port 8080 relay.pcap
+//
+// With -proxy it instead sits between clients and a relay, logging frames live with
+// reply latencies and retransmissions, and can record the session with -record.
+// With -replay it resends the client side of a captured session to a test relay:
+//
+//	zentalk-dissect -proxy :9080 -upstream localhost:8080 -record session.pcap
+//	zentalk-dissect -replay localhost:8081 session.pcap
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/dissect"
+)
+
+var (
+	port      = flag.Uint("port", 0, "Only decode TCP traffic to or from this port (pcap input; 0 = all)")
+	raw       = flag.Bool("raw", false, "Treat input as a raw stream dump even if it looks like a pcap")
+	typeList  = flag.String("type", "", "Only show these message types (e.g. RelayForward,RelayAck or 0x0100)")
+	jsonOut   = flag.Bool("json", false, "Print frames as JSON lines instead of a timeline")
+	summary   = flag.Bool("summary", false, "Print only per-type counts and anomalies")
+	noSummary = flag.Bool("no-summary", false, "Omit the summary after the timeline")
+
+	proxyAddr = flag.String("proxy", "", "Run as a debug proxy listening on this address")
+	upstream  = flag.String("upstream", "", "Relay the proxy forwards to (host:port)")
+	record    = flag.String("record", "", "Record proxied traffic to this pcap file")
+
+	replayTo    = flag.String("replay", "", "Resend the client side of the capture to this relay (host:port)")
+	replayConn  = flag.Int("conn", 1, "Connection in the capture to replay, as numbered in the timeline")
+	replaySpeed = flag.Float64("speed", 1, "Replay timing: 1 = as captured, 2 = twice as fast, 0 = no delays")
+	linger      = flag.Duration("linger", 2*time.Second, "How long to wait for replies after replaying the last frame")
+)
+
+// jsonFrame is the -json representation of a frame
+type jsonFrame struct {
+	Time        *time.Time `json:"time,omitempty"`
+	Flow        string     `json:"flow,omitempty"`
+	Offset      int64      `json:"offset"`
+	Type        string     `json:"type"`
+	TypeCode    uint16     `json:"typeCode"`
+	Version     uint16     `json:"version"`
+	Flags       string     `json:"flags"`
+	Length      uint32     `json:"length"`
+	PayloadSeen uint32     `json:"payloadSeen"`
+	MessageID   string     `json:"messageId"`
+	SentAt      *time.Time `json:"sentAt,omitempty"` // Timestamp embedded in the message ID
+	Skipped     int64      `json:"skipped,omitempty"`
+	AfterGap    bool       `json:"afterGap,omitempty"`
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: zentalk-dissect [flags] <capture.pcap|stream.bin|->\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "       zentalk-dissect -proxy <listen> -upstream <relay> [-record file.pcap]\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "       zentalk-dissect -replay <relay> [-conn n] [-speed x] <capture>\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *proxyAddr != "" {
+		runProxy()
+		return
+	}
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *port > 0xFFFF {
+		log.Fatalf("Error: invalid port %d", *port)
+	}
+
+	var in io.Reader = os.Stdin
+	if name := flag.Arg(0); name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	var frames []dissect.Frame
+	var err error
+	if *raw {
+		frames, err = dissect.ReadStream(in)
+	} else {
+		frames, err = dissect.Read(in, uint16(*port))
+	}
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	if *replayTo != "" {
+		result, err := dissect.Replay(frames, dissect.ReplayConfig{
+			Target:     *replayTo,
+			Connection: *replayConn,
+			Speed:      *replaySpeed,
+			Linger:     *linger,
+		})
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		log.Printf("✅ Replay done: %d frames sent, %d skipped, %d received", result.Sent, result.Skipped, result.Received)
+		return
+	}
+
+	if *typeList != "" {
+		frames, err = filterTypes(frames, *typeList)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	}
+
+	switch {
+	case *jsonOut:
+		err = writeJSON(os.Stdout, frames)
+	case *summary:
+		err = dissect.WriteSummary(os.Stdout, dissect.Summarize(frames))
+	default:
+		err = dissect.WriteTimeline(os.Stdout, frames)
+		if err == nil && !*noSummary {
+			fmt.Println()
+			err = dissect.WriteSummary(os.Stdout, dissect.Summarize(frames))
+		}
+	}
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+}
+
+// runProxy runs the debug proxy until interrupted
+func runProxy() {
+	if *upstream == "" {
+		log.Fatal("Error: -proxy needs -upstream")
+	}
+
+	var out io.Writer
+	if *record != "" {
+		f, err := os.Create(*record)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	proxy, err := dissect.NewProxy(*proxyAddr, *upstream, out)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		log.Println("🛑 Shutting down...")
+		proxy.Close()
+	}()
+
+	if err := proxy.Serve(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	// Wait for open sessions to finish logging before the recording is closed
+	proxy.Close()
+	if *record != "" {
+		log.Printf("💾 Session recorded to %s", *record)
+	}
+}
+
+// filterTypes keeps frames whose type is named in list
+func filterTypes(frames []dissect.Frame, list string) ([]dissect.Frame, error) {
+	want := make(map[string]bool)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		var code uint16
+		if _, err := fmt.Sscanf(name, "0x%x", &code); err == nil {
+			name = dissect.TypeName(code)
+		}
+		want[strings.ToLower(name)] = true
+	}
+	if len(want) == 0 {
+		return nil, fmt.Errorf("no message types given to -type")
+	}
+
+	kept := frames[:0]
+	for _, f := range frames {
+		if want[strings.ToLower(dissect.TypeName(f.Header.Type))] {
+			kept = append(kept, f)
+		}
+	}
+	return kept, nil
+}
+
+// writeJSON prints one JSON object per frame
+func writeJSON(w io.Writer, frames []dissect.Frame) error {
+	enc := json.NewEncoder(w)
+	for i := range frames {
+		f := &frames[i]
+		out := jsonFrame{
+			Offset:      f.Offset,
+			Type:        dissect.TypeName(f.Header.Type),
+			TypeCode:    f.Header.Type,
+			Version:     f.Header.Version,
+			Flags:       dissect.FlagNames(f.Header.Flags),
+			Length:      f.Header.Length,
+			PayloadSeen: f.PayloadSeen,
+			MessageID:   hex.EncodeToString(f.Header.MessageID[:]),
+			Skipped:     f.Skipped,
+			AfterGap:    f.AfterGap,
+		}
+		if !f.Time.IsZero() {
+			t := f.Time
+			out.Time = &t
+			out.Flow = f.Flow.String()
+		}
+		if sent := dissect.MessageTime(f.Header.MessageID); !sent.IsZero() {
+			out.SentAt = &sent
+		}
+		if err := enc.Encode(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
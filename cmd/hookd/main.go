@@ -0,0 +1,143 @@
+// Command hookd runs a headless ZenTalk client that forwards incoming messages to webhooks
+//
+//	hookd -relay relay.example.org:8080 -key ./keys/hookd.pem -address <hex> -hooks hooks.json
+//
+// hooks.json lists the endpoints (see webhook.LoadEndpoints):
+//
+//	[{"url": "https://ci.example.org/zentalk", "secret": "...", "events": ["message"]}]
+//
+// Messages are decrypted here and posted as JSON, signed with each endpoint's
+// secret (see package webhook for the headers). Without -db every sender is
+// treated as a contact; with it, first messages from unknown senders arrive as
+// message_request events and are kept until accepted.
+//
+// -plugins loads message-handling plugins (auto-replies, filters, archives)
+// from a directory of manifests; see package plugins. Plugins run alongside
+// the webhooks; pass -hooks "" to run plugins only.
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/network"
+	"github.com/ZentaChain/zentalk-node/pkg/plugins"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+	"github.com/ZentaChain/zentalk-node/pkg/storage"
+	"github.com/ZentaChain/zentalk-node/pkg/webhook"
+)
+
+var (
+	relayAddr  = flag.String("relay", "localhost:8080", "Relay to connect to (host:port)")
+	keyPath    = flag.String("key", "./keys/hookd.pem", "Private key file")
+	address    = flag.String("address", "", "Our address (hex, required)")
+	hooksPath  = flag.String("hooks", "hooks.json", "Webhook endpoints file (\"\" with -plugins for none)")
+	pluginDir  = flag.String("plugins", "", "Directory of plugin manifests (optional)")
+	pluginData = flag.String("plugin-data", "./plugin-data", "Directory for plugin archives")
+	dbPath     = flag.String("db", "", "Message database; enables contacts and message requests (optional)")
+	dbPass     = flag.String("db-password", os.Getenv("ZENTALK_DB_PASSWORD"), "Message database password (or set ZENTALK_DB_PASSWORD)")
+	botLabel   = flag.String("bot-label", "", "Label the bot API key was derived with (zentalk-admin bot-key -label)")
+	asBot      = flag.Bool("bot", false, "Run as a bot account (see zentalk-admin bot-key)")
+	maxRetries = flag.Int("max-attempts", webhook.DefaultMaxAttempts, "Delivery attempts per event and endpoint")
+)
+
+func main() {
+	flag.Parse()
+
+	if *address == "" {
+		log.Fatal("Error: -address is required")
+	}
+	addrBytes, err := hex.DecodeString(strings.TrimPrefix(*address, "0x"))
+	if err != nil || len(addrBytes) != len(protocol.Address{}) {
+		log.Fatalf("Invalid -address %q: want 20 bytes of hex", *address)
+	}
+
+	keyPEM, err := crypto.LoadKeyFromFile(*keyPath)
+	if err != nil {
+		log.Fatalf("Failed to read key: %v", err)
+	}
+	key, err := crypto.ImportPrivateKeyPEM(keyPEM)
+	if err != nil {
+		log.Fatalf("Invalid key: %v", err)
+	}
+
+	var endpoints []webhook.Endpoint
+	if *pluginDir == "" || *hooksPath != "" {
+		if endpoints, err = webhook.LoadEndpoints(*hooksPath); err != nil {
+			log.Fatalf("Invalid -hooks: %v", err)
+		}
+		if len(endpoints) == 0 && *pluginDir == "" {
+			log.Fatal("Error: no endpoints in -hooks")
+		}
+	}
+
+	client := network.NewClient(key)
+	copy(client.Address[:], addrBytes)
+
+	if *asBot {
+		if err := client.EnableBotMode(*botLabel); err != nil {
+			log.Fatalf("Failed to enable bot mode: %v", err)
+		}
+	}
+
+	var db *storage.MessageDB
+	if *dbPath != "" {
+		if db, err = storage.NewMessageDB(*dbPath, *dbPass); err != nil {
+			log.Fatalf("Failed to open message database: %v", err)
+		}
+		defer db.Close()
+		client.AttachDatabase(db)
+	}
+
+	// Plugins reply to contacts, so replies need the message database
+	var replies plugins.Sender
+	if db != nil {
+		replies = plugins.NewContactSender(client, db)
+	}
+	pluginManager := plugins.NewManager(replies, *pluginData)
+	if *pluginDir != "" {
+		if err := pluginManager.LoadDir(*pluginDir); err != nil {
+			log.Fatalf("Invalid -plugins: %v", err)
+		}
+		client.AddMessageFilter(pluginManager)
+	}
+
+	dispatcher := webhook.NewDispatcher(endpoints)
+	dispatcher.SetRetryPolicy(*maxRetries, 0)
+
+	client.OnMessageReceived = func(msg *protocol.DirectMessage) {
+		dispatcher.Deliver(webhook.NewMessageEvent(webhook.EventMessage, msg))
+		pluginManager.HandleDirectMessage(msg)
+	}
+	client.OnMessageRequest = func(msg *protocol.DirectMessage) {
+		dispatcher.Deliver(webhook.NewMessageEvent(webhook.EventMessageRequest, msg))
+	}
+	client.OnGroupMessageReceived = func(msg *protocol.GroupMessage) {
+		dispatcher.Deliver(webhook.NewGroupMessageEvent(msg))
+	}
+
+	if err := dispatcher.Start(); err != nil {
+		log.Fatalf("Failed to start webhook delivery: %v", err)
+	}
+	pluginManager.Start()
+
+	if err := client.ConnectToRelay(*relayAddr); err != nil {
+		log.Fatalf("Failed to connect to relay: %v", err)
+	}
+	log.Printf("✓ Connected to %s as %x", *relayAddr, client.Address[:8])
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	<-sigChan
+
+	log.Println("Shutting down...")
+	client.Disconnect()
+	dispatcher.Stop()
+	pluginManager.Stop()
+}
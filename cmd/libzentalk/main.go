@@ -0,0 +1,174 @@
+// Command libzentalk builds the ZenTalk core as a C library
+//
+//	go build -buildmode=c-shared -o libzentalk.so ./cmd/libzentalk
+//	go build -buildmode=c-archive -o libzentalk.a ./cmd/libzentalk
+//
+// zentalk.h in this directory is the stable interface; link against it rather
+// than the header cgo generates next to the library. The functions are thin
+// wrappers over pkg/capi, so Rust, Swift and other clients share the exact
+// encoders, X3DH and ratchet code the Go node uses.
+package main
+
+/*
+#include <stdlib.h>
+#include "zentalk.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"math"
+	"unsafe"
+
+	"github.com/ZentaChain/zentalk-node/pkg/capi"
+)
+
+func main() {}
+
+//export zt_abi_version
+func zt_abi_version() C.int {
+	return C.int(capi.ABIVersion)
+}
+
+//export zt_free
+func zt_free(ptr unsafe.Pointer) {
+	C.free(ptr)
+}
+
+//export zt_encode
+func zt_encode(kind C.uint32_t, json *C.uint8_t, jsonLen C.size_t, out **C.uint8_t, outLen *C.size_t, errOut **C.char) C.int {
+	var in inputs
+	jsonData := in.bytes(json, jsonLen)
+	if in.err != nil {
+		return result(in.err, errOut)
+	}
+	data, err := capi.Encode(uint32(kind), jsonData)
+	return result(err, errOut, output{out, outLen, data})
+}
+
+//export zt_decode
+func zt_decode(kind C.uint32_t, data *C.uint8_t, dataLen C.size_t, out **C.uint8_t, outLen *C.size_t, errOut **C.char) C.int {
+	var in inputs
+	encoded := in.bytes(data, dataLen)
+	if in.err != nil {
+		return result(in.err, errOut)
+	}
+	json, err := capi.Decode(uint32(kind), encoded)
+	return result(err, errOut, output{out, outLen, json})
+}
+
+//export zt_x3dh_generate
+func zt_x3dh_generate(registrationID, signedPreKeyID, oneTimePreKeys C.uint32_t, state **C.uint8_t, stateLen *C.size_t, errOut **C.char) C.int {
+	s, err := capi.GenerateX3DHState(uint32(registrationID), uint32(signedPreKeyID), int(oneTimePreKeys))
+	return result(err, errOut, output{state, stateLen, s})
+}
+
+//export zt_x3dh_key_bundle
+func zt_x3dh_key_bundle(state *C.uint8_t, stateLen C.size_t, address *C.uint8_t, bundle **C.uint8_t, bundleLen *C.size_t, errOut **C.char) C.int {
+	var in inputs
+	st, addr := in.bytes(state, stateLen), in.bytes(address, 20)
+	if in.err != nil {
+		return result(in.err, errOut)
+	}
+	b, err := capi.KeyBundle(st, addr)
+	return result(err, errOut, output{bundle, bundleLen, b})
+}
+
+//export zt_x3dh_initiate
+func zt_x3dh_initiate(state *C.uint8_t, stateLen C.size_t, address *C.uint8_t, bundle *C.uint8_t, bundleLen C.size_t,
+	session **C.uint8_t, sessionLen *C.size_t, initialMessage **C.uint8_t, initialMessageLen *C.size_t, errOut **C.char) C.int {
+	var in inputs
+	st, addr, b := in.bytes(state, stateLen), in.bytes(address, 20), in.bytes(bundle, bundleLen)
+	if in.err != nil {
+		return result(in.err, errOut)
+	}
+	s, im, err := capi.X3DHInitiate(st, addr, b)
+	return result(err, errOut, output{session, sessionLen, s}, output{initialMessage, initialMessageLen, im})
+}
+
+//export zt_x3dh_respond
+func zt_x3dh_respond(state *C.uint8_t, stateLen C.size_t, address *C.uint8_t, initialMessage *C.uint8_t, initialMessageLen C.size_t,
+	session **C.uint8_t, sessionLen *C.size_t, newState **C.uint8_t, newStateLen *C.size_t, errOut **C.char) C.int {
+	var in inputs
+	st, addr, im := in.bytes(state, stateLen), in.bytes(address, 20), in.bytes(initialMessage, initialMessageLen)
+	if in.err != nil {
+		return result(in.err, errOut)
+	}
+	s, ns, err := capi.X3DHRespond(st, addr, im)
+	return result(err, errOut, output{session, sessionLen, s}, output{newState, newStateLen, ns})
+}
+
+//export zt_ratchet_encrypt
+func zt_ratchet_encrypt(session *C.uint8_t, sessionLen C.size_t, plaintext *C.uint8_t, plaintextLen C.size_t,
+	newSession **C.uint8_t, newSessionLen *C.size_t, payload **C.uint8_t, payloadLen *C.size_t, errOut **C.char) C.int {
+	var in inputs
+	sess, pt := in.bytes(session, sessionLen), in.bytes(plaintext, plaintextLen)
+	if in.err != nil {
+		return result(in.err, errOut)
+	}
+	s, p, err := capi.RatchetEncrypt(sess, pt)
+	return result(err, errOut, output{newSession, newSessionLen, s}, output{payload, payloadLen, p})
+}
+
+//export zt_ratchet_decrypt
+func zt_ratchet_decrypt(session *C.uint8_t, sessionLen C.size_t, payload *C.uint8_t, payloadLen C.size_t,
+	newSession **C.uint8_t, newSessionLen *C.size_t, plaintext **C.uint8_t, plaintextLen *C.size_t, errOut **C.char) C.int {
+	var in inputs
+	sess, ct := in.bytes(session, sessionLen), in.bytes(payload, payloadLen)
+	if in.err != nil {
+		return result(in.err, errOut)
+	}
+	s, p, err := capi.RatchetDecrypt(sess, ct)
+	return result(err, errOut, output{newSession, newSessionLen, s}, output{plaintext, plaintextLen, p})
+}
+
+// output is a caller's out-pointer pair and the value to store in it
+type output struct {
+	ptr  **C.uint8_t
+	len  *C.size_t
+	data []byte
+}
+
+// inputs copies a call's buffers into Go memory, keeping the first error
+// Copying means nothing the library keeps can alias memory the caller frees.
+type inputs struct {
+	err error
+}
+
+// bytes copies one buffer, refusing lengths C.GoBytes' int length would truncate
+func (in *inputs) bytes(ptr *C.uint8_t, n C.size_t) []byte {
+	if ptr == nil || n == 0 {
+		return nil
+	}
+	if uint64(n) > math.MaxInt32 {
+		if in.err == nil {
+			in.err = fmt.Errorf("input of %d bytes is larger than the %d byte limit", uint64(n), math.MaxInt32)
+		}
+		return nil
+	}
+	return C.GoBytes(unsafe.Pointer(ptr), C.int(n))
+}
+
+// result stores outputs (or the error message) and returns the status code
+// Out-pointers may be NULL when the caller doesn't want that value.
+func result(err error, errOut **C.char, outputs ...output) C.int {
+	if err != nil {
+		if errOut != nil {
+			*errOut = C.CString(err.Error())
+		}
+		return C.ZT_ERROR
+	}
+	if errOut != nil {
+		*errOut = nil
+	}
+
+	for _, o := range outputs {
+		if o.ptr != nil {
+			*o.ptr = (*C.uint8_t)(C.CBytes(o.data))
+		}
+		if o.len != nil {
+			*o.len = C.size_t(len(o.data))
+		}
+	}
+	return C.ZT_OK
+}
@@ -0,0 +1,95 @@
+/*
+ * libzentalk - ZenTalk protocol encoding, X3DH and Double Ratchet
+ *
+ * Build:
+ *   go build -buildmode=c-shared -o libzentalk.so ./cmd/libzentalk
+ *   go build -buildmode=c-archive -o libzentalk.a ./cmd/libzentalk
+ *
+ * Conventions:
+ *   - Functions return ZT_OK (0) or ZT_ERROR (-1). On error *err points to a
+ *     NUL-terminated message; otherwise *err is NULL.
+ *   - Every output buffer and error message is allocated by the library and
+ *     must be released with zt_free().
+ *   - Inputs are never modified or retained. Inputs longer than INT32_MAX
+ *     bytes are refused with ZT_ERROR.
+ *   - Addresses are 20 raw bytes. Key material (X3DH state) and sessions are
+ *     JSON documents the caller stores and passes back; the library keeps no
+ *     state between calls and is safe to call from any thread.
+ *   - An X3DH state may list "cipher_suites" (suite IDs, preferred first);
+ *     sessions then use only those, and initial messages on others are refused.
+ *   - Decoded messages are JSON using the Go field names of pkg/protocol,
+ *     with byte fields as hex strings (key bundles keep their DHT layout).
+ */
+#ifndef ZENTALK_H
+#define ZENTALK_H
+
+#include <stddef.h>
+#include <stdint.h>
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+#define ZT_OK 0
+#define ZT_ERROR (-1)
+
+/* Bumped whenever a signature, kind or JSON layout changes incompatibly */
+#define ZT_ABI_VERSION 2
+
+/* Kinds for zt_encode/zt_decode. Message payloads use their message type (e.g. 0x0200 for a direct message). */
+#define ZT_KIND_HEADER 0x10000u
+#define ZT_KIND_KEY_BUNDLE 0x10001u
+#define ZT_KIND_INITIAL_MESSAGE 0x10002u
+#define ZT_KIND_RATCHET_HEADER 0x10003u
+
+/* Returns the ABI version the library was built with; compare with ZT_ABI_VERSION */
+int zt_abi_version(void);
+
+/* Releases a buffer or error message returned by the library */
+void zt_free(void *ptr);
+
+/* Encodes a JSON value of the given kind to its wire format */
+int zt_encode(uint32_t kind, uint8_t *json, size_t json_len,
+              uint8_t **out, size_t *out_len, char **err);
+
+/* Decodes wire bytes of the given kind to JSON */
+int zt_decode(uint32_t kind, uint8_t *data, size_t data_len,
+              uint8_t **out, size_t *out_len, char **err);
+
+/* Generates X3DH key material: identity, signed prekey and one-time prekeys */
+int zt_x3dh_generate(uint32_t registration_id, uint32_t signed_prekey_id, uint32_t one_time_prekeys,
+                     uint8_t **state, size_t *state_len, char **err);
+
+/* Encodes the public key bundle to publish for address */
+int zt_x3dh_key_bundle(uint8_t *state, size_t state_len, uint8_t *address,
+                       uint8_t **bundle, size_t *bundle_len, char **err);
+
+/* Starts a session with the owner of bundle; send initial_message before the first ratchet message */
+int zt_x3dh_initiate(uint8_t *state, size_t state_len, uint8_t *address,
+                     uint8_t *bundle, size_t bundle_len,
+                     uint8_t **session, size_t *session_len,
+                     uint8_t **initial_message, size_t *initial_message_len, char **err);
+
+/* Accepts an initial message; new_state (one-time prekey consumed) replaces state */
+int zt_x3dh_respond(uint8_t *state, size_t state_len, uint8_t *address,
+                    uint8_t *initial_message, size_t initial_message_len,
+                    uint8_t **session, size_t *session_len,
+                    uint8_t **new_state, size_t *new_state_len, char **err);
+
+/* Encrypts plaintext; new_session replaces session */
+int zt_ratchet_encrypt(uint8_t *session, size_t session_len,
+                       uint8_t *plaintext, size_t plaintext_len,
+                       uint8_t **new_session, size_t *new_session_len,
+                       uint8_t **payload, size_t *payload_len, char **err);
+
+/* Decrypts a payload; new_session replaces session. On error session is still valid. */
+int zt_ratchet_decrypt(uint8_t *session, size_t session_len,
+                       uint8_t *payload, size_t payload_len,
+                       uint8_t **new_session, size_t *new_session_len,
+                       uint8_t **plaintext, size_t *plaintext_len, char **err);
+
+#ifdef __cplusplus
+}
+#endif
+
+#endif /* ZENTALK_H */
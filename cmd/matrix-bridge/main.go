@@ -0,0 +1,205 @@
+// Command matrix-bridge bridges Matrix rooms with ZenTalk groups
+//
+// It connects to a relay as a ZenTalk bot account and to a homeserver as a
+// Matrix user that has joined every bridged room:
+//
+//	zentalk-admin bot-key -key ./keys/bridge.pem   # register the key with the relay's -bots
+//	MATRIX_ACCESS_TOKEN=... matrix-bridge -relay relay.example.org:8080 \
+//	  -key ./keys/bridge.pem -address <hex> \
+//	  -homeserver https://matrix.example.org -matrix-user @zentalk:example.org \
+//	  -mapping mapping.json -groups groups.json
+//
+// The mapping file pairs rooms with groups (see bridge.LoadMapping). The
+// groups file lists each bridged group's members, since groups are managed by
+// their members rather than by relays:
+//
+//	[{"group": "<hex group ID>", "name": "General",
+//	  "members": [{"address": "<hex>", "public_key": "keys/alice.pub"}]}]
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/ZentaChain/zentalk-node/pkg/bridge"
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/network"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+var (
+	relayAddr   = flag.String("relay", "localhost:8080", "Relay to connect to (host:port)")
+	relayID     = flag.String("relay-address", "", "Relay address (hex); with -relay-key, messages are routed through the connected relay only")
+	relayKey    = flag.String("relay-key", "", "Relay public key file (PEM)")
+	keyPath     = flag.String("key", "./keys/bridge.pem", "Bridge bot private key file")
+	address     = flag.String("address", "", "Bridge bot address (hex, required)")
+	botLabel    = flag.String("bot-label", "", "Label the bot API key was derived with (zentalk-admin bot-key -label)")
+	homeserver  = flag.String("homeserver", "", "Matrix homeserver URL (required)")
+	matrixUser  = flag.String("matrix-user", "", "Matrix user ID of the bridge account (required)")
+	matrixToken = flag.String("matrix-token", os.Getenv("MATRIX_ACCESS_TOKEN"), "Matrix access token (or set MATRIX_ACCESS_TOKEN)")
+	mappingPath = flag.String("mapping", "mapping.json", "Room/group and user mapping file")
+	groupsPath  = flag.String("groups", "groups.json", "Members of the bridged groups")
+)
+
+// groupFileEntry is one group in the groups file
+type groupFileEntry struct {
+	Group   string `json:"group"` // Hex group ID
+	Name    string `json:"name"`
+	Members []struct {
+		Address   string `json:"address"`    // Hex address
+		PublicKey string `json:"public_key"` // PEM file
+	} `json:"members"`
+}
+
+func main() {
+	flag.Parse()
+
+	if *address == "" || *homeserver == "" || *matrixUser == "" || *matrixToken == "" {
+		log.Fatal("Error: -address, -homeserver, -matrix-user and -matrix-token are required")
+	}
+
+	var self protocol.Address
+	if err := decodeHex(*address, self[:]); err != nil {
+		log.Fatalf("Invalid -address: %v", err)
+	}
+
+	keyPEM, err := crypto.LoadKeyFromFile(*keyPath)
+	if err != nil {
+		log.Fatalf("Failed to read bot key: %v", err)
+	}
+	key, err := crypto.ImportPrivateKeyPEM(keyPEM)
+	if err != nil {
+		log.Fatalf("Invalid bot key: %v", err)
+	}
+
+	mapping, err := bridge.LoadMapping(*mappingPath)
+	if err != nil {
+		log.Fatalf("Invalid -mapping: %v", err)
+	}
+	groups, err := loadGroups(*groupsPath)
+	if err != nil {
+		log.Fatalf("Invalid -groups: %v", err)
+	}
+
+	client := network.NewClient(key)
+	client.Address = self
+	if err := client.EnableBotMode(*botLabel); err != nil {
+		log.Fatalf("Failed to enable bot mode: %v", err)
+	}
+
+	local := bridge.NewClientLocal(client)
+	for _, group := range groups {
+		if _, ok := mapping.RoomFor(group.ID); !ok {
+			log.Printf("⚠️  Group %x has no Matrix room in the mapping", group.ID[:8])
+		}
+		local.AddGroup(group)
+	}
+	if *relayID != "" || *relayKey != "" {
+		hop, err := loadRelayInfo(*relayID, *relayKey)
+		if err != nil {
+			log.Fatalf("Invalid -relay-address/-relay-key: %v", err)
+		}
+		local.SetRelayPath([]*crypto.RelayInfo{hop})
+	}
+
+	remote := bridge.NewMatrixRemote(bridge.MatrixConfig{
+		Homeserver:  *homeserver,
+		UserID:      *matrixUser,
+		AccessToken: *matrixToken,
+	})
+	b := bridge.New(remote, local, mapping, self)
+	client.OnGroupMessageReceived = b.HandleGroupMessage
+	client.OnProfileUpdate = b.HandleProfileUpdate
+
+	if err := client.ConnectToRelay(*relayAddr); err != nil {
+		log.Fatalf("Failed to connect to relay: %v", err)
+	}
+	log.Printf("✓ Connected to %s as bot %x", *relayAddr, self[:8])
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := b.Run(ctx); err != nil && ctx.Err() == nil {
+		log.Printf("❌ Bridge stopped: %v", err)
+	}
+
+	log.Println("Shutting down...")
+	client.Disconnect()
+}
+
+// loadGroups reads the members of the bridged groups
+func loadGroups(path string) ([]*network.Group, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []groupFileEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+
+	groups := make([]*network.Group, 0, len(entries))
+	for _, entry := range entries {
+		group := &network.Group{Name: entry.Name}
+		if err := decodeHex(entry.Group, group.ID[:]); err != nil {
+			return nil, fmt.Errorf("group %q: %w", entry.Group, err)
+		}
+
+		for _, m := range entry.Members {
+			member := &network.GroupMember{}
+			if err := decodeHex(m.Address, member.Address[:]); err != nil {
+				return nil, fmt.Errorf("group %s member %q: %w", entry.Group, m.Address, err)
+			}
+			pemData, err := crypto.LoadKeyFromFile(m.PublicKey)
+			if err != nil {
+				return nil, fmt.Errorf("group %s member %s: %w", entry.Group, m.Address, err)
+			}
+			if member.PublicKey, err = crypto.ImportPublicKeyPEM(pemData); err != nil {
+				return nil, fmt.Errorf("group %s member %s: %w", entry.Group, m.Address, err)
+			}
+			group.Members = append(group.Members, member)
+		}
+		groups = append(groups, group)
+	}
+
+	return groups, nil
+}
+
+// loadRelayInfo builds the one-hop path through the connected relay
+func loadRelayInfo(addrHex, keyFile string) (*crypto.RelayInfo, error) {
+	info := &crypto.RelayInfo{}
+	if err := decodeHex(addrHex, info.Address[:]); err != nil {
+		return nil, err
+	}
+
+	pemData, err := crypto.LoadKeyFromFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if info.PublicKey, err = crypto.ImportPublicKeyPEM(pemData); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// decodeHex decodes a hex string (optional 0x prefix) that must fill dst exactly
+func decodeHex(s string, dst []byte) error {
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		return err
+	}
+	if len(b) != len(dst) {
+		return fmt.Errorf("want %d bytes of hex, got %d", len(dst), len(b))
+	}
+	copy(dst, b)
+	return nil
+}
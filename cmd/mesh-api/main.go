@@ -8,10 +8,15 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/ZentaChain/zentalk-node/pkg/blockchain"
+	"github.com/ZentaChain/zentalk-node/pkg/logging"
 	"github.com/ZentaChain/zentalk-node/pkg/meshstorage"
 	"github.com/ZentaChain/zentalk-node/pkg/meshstorage/api"
+	"github.com/gin-gonic/gin"
 )
 
 func main() {
@@ -19,13 +24,71 @@ func main() {
 	port := flag.Int("port", 9000, "DHT node port")
 	apiPort := flag.Int("api-port", 8080, "HTTP API port")
 	dataDir := flag.String("data", "./mesh-data", "Data directory for storage")
+	dbDSN := flag.String("db", "", "Chunk database DSN (postgres://...); default is SQLite in the data directory")
 	bootstrap := flag.String("bootstrap", "", "Bootstrap node address")
 	enableCORS := flag.Bool("cors", true, "Enable CORS headers")
 	rateLimit := flag.Int("rate-limit", 100, "Rate limit (requests per minute)")
 	maxUploadMB := flag.Int("max-upload", 100, "Maximum upload size in MB")
+	drainTimeout := flag.Duration("drain-timeout", api.DefaultDrainTimeout, "How long shutdown waits for in-flight uploads")
+	maintenance := flag.Duration("maintenance", 0, "Announce planned downtime of this length to peers on shutdown (e.g. 30m)")
+	cacheMB := flag.Int("cache-mb", 64, "Memory budget for hot chunk cache in MB (0 disables)")
+	linkSecret := flag.String("link-secret", os.Getenv("ZENTALK_LINK_SECRET"), "HMAC secret for shared download links; use the same value on every API node")
+	adminToken := flag.String("admin-token", os.Getenv("ZENTALK_ADMIN_TOKEN"), "Bearer token for /api/v1/admin operator endpoints (disabled when empty)")
+	bootstrapOnly := flag.Bool("bootstrap-only", false, "Run as a dedicated bootstrap node: no storage, high connection limits, serves a signed seed list")
+	maxConns := flag.Int("max-conns", meshstorage.DefaultBootstrapConnections, "Connection limit in -bootstrap-only mode")
+	listenHosts := flag.String("listen", "", "Comma-separated IP addresses for the DHT node to listen on (default: all interfaces)")
+	addrFamily := flag.String("address-family", meshstorage.AddressFamilyDual, "IP versions for the DHT node: dual, ipv4, ipv6")
+	leaseGC := flag.Duration("lease-gc", meshstorage.DefaultLeaseGCInterval, "How often chunks with expired storage leases are deleted (0 disables)")
+	usageEpoch := flag.Duration("usage-epoch", meshstorage.DefaultUsageEpoch, "Length of a usage metering epoch (0 disables metering)")
+	dataShards := flag.Int("data-shards", meshstorage.DataShards, "Erasure coding data shards per uploaded chunk (any this many shards rebuild it)")
+	parityShards := flag.Int("parity-shards", meshstorage.ParityShards, "Erasure coding parity shards per uploaded chunk (how many shards may be lost)")
+	minRecovery := flag.Int("min-recovery", 0, "Shards that must be stored for an upload to succeed (default -data-shards)")
+	rebalance := flag.Duration("rebalance", meshstorage.DefaultRebalanceInterval, "How often shards are migrated toward the nodes now closest to their chunk (0 disables)")
+	rebalanceRate := flag.Int("rebalance-rate", meshstorage.DefaultRebalanceRate>>10, "Bandwidth shard migration may use in KB/s (0 for no limit)")
+	rpcMaxMessage := flag.Int("rpc-max-message", int(meshstorage.DefaultRPCLimits().MaxMessageSize>>20), "Largest storage RPC request accepted from a peer in MB (0 for no limit)")
+	rpcTimeout := flag.Duration("rpc-timeout", meshstorage.DefaultRPCLimits().ReadTimeout, "Time a peer has to send a storage RPC request, and to read the response (0 for no limit)")
+	rpcStreams := flag.Int("rpc-streams", meshstorage.DefaultRPCLimits().MaxStreamsPerPeer, "Storage RPC requests one peer may have in progress (0 for no limit)")
+	quotaMB := flag.Int64("quota-mb", 0, "Chunk data this node stores in MB, for all users together (0 for no limit)")
+	quotaChunks := flag.Int64("quota-chunks", 0, "Chunks and shards this node stores, for all users together (0 for no limit)")
+	userQuotaMB := flag.Int64("user-quota-mb", 0, "Chunk data this node stores for one user address in MB (0 for no limit)")
+	userQuotaChunks := flag.Int64("user-quota-chunks", 0, "Chunks and shards this node stores for one user address (0 for no limit)")
+	rpcURL := flag.String("rpc", "https://rpc.sepolia.org", "RPC URL for committing usage digests")
+	contractAddr := flag.String("contract", "", "Registry contract address that usage digests are committed to")
+	ethKeyPath := flag.String("eth-key", "", "Hex private key file of the operator account; enables committing a digest of each closed usage epoch on-chain")
+	logFile := flag.String("log-file", "", "Write logs to this file, rotated at -log-max-size (default: stdout only)")
+	logMaxSize := flag.Int("log-max-size", int(logging.DefaultConfig().MaxSize>>20), "Rotate -log-file at this size in MB (0 to never rotate)")
+	logMaxAge := flag.Duration("log-max-age", logging.DefaultConfig().MaxAge, "Delete rotated log files older than this (0 to keep -log-backups files)")
+	logBackups := flag.Int("log-backups", logging.DefaultConfig().MaxBackups, "Rotated log files to keep (0 to keep by -log-max-age only)")
+	logConsole := flag.Bool("log-console", false, "Also print logs to stdout with -log-file")
+	logRepeats := flag.Int("log-repeats", logging.DefaultConfig().RepeatBurst, "Similar log lines printed per minute before the rest are suppressed (0 to disable)")
 
 	flag.Parse()
 
+	// Chunks already stored keep the coding they were uploaded with
+	erasure := meshstorage.ErasureConfig{DataShards: *dataShards, ParityShards: *parityShards, MinRecovery: *minRecovery}
+	if err := erasure.Validate(); err != nil {
+		log.Fatalf("Invalid erasure coding: %v", err)
+	}
+
+	// Every line printed from here on is rotated, kept for /admin/logs and rate-limited
+	logCfg := logging.DefaultConfig()
+	logCfg.Path = *logFile
+	logCfg.MaxSize = int64(*logMaxSize) << 20
+	logCfg.MaxAge = *logMaxAge
+	logCfg.MaxBackups = *logBackups
+	logCfg.Console = *logConsole
+	logCfg.RepeatBurst = *logRepeats
+	logs, err := logging.New(logCfg)
+	if err != nil {
+		log.Fatalf("Failed to set up logging: %v", err)
+	}
+	logs.Install()
+	if err := logs.CaptureStdout(); err != nil {
+		log.Fatalf("Failed to set up logging: %v", err)
+	}
+	gin.DefaultWriter = os.Stdout
+	defer logs.Close()
+
 	fmt.Println("🚀 ZenTalk Mesh Storage API Server")
 	fmt.Println("===================================")
 	fmt.Println()
@@ -36,8 +99,31 @@ func main() {
 	// Create DHT node
 	fmt.Printf("📡 Starting DHT node on port %d...\n", *port)
 	nodeConfig := &meshstorage.NodeConfig{
-		Port:    *port,
-		DataDir: *dataDir,
+		Port:            *port,
+		DataDir:         *dataDir,
+		DatabaseDSN:     *dbDSN,
+		CacheBytes:      int64(*cacheMB) * 1024 * 1024,
+		ListenHosts:     splitList(*listenHosts),
+		AddressFamily:   *addrFamily,
+		BootstrapOnly:   *bootstrapOnly,
+		MaxConnections:  *maxConns,
+		LeaseGCInterval: *leaseGC,
+		UsageEpoch:      *usageEpoch,
+		Quota: meshstorage.QuotaConfig{
+			MaxBytes:      *quotaMB << 20,
+			MaxChunks:     *quotaChunks,
+			UserMaxBytes:  *userQuotaMB << 20,
+			UserMaxChunks: *userQuotaChunks,
+		},
+	}
+	if *cacheMB <= 0 {
+		nodeConfig.CacheBytes = -1
+	}
+	if *leaseGC <= 0 {
+		nodeConfig.LeaseGCInterval = -1
+	}
+	if *usageEpoch <= 0 {
+		nodeConfig.UsageEpoch = -1
 	}
 
 	node, err := meshstorage.NewDHTNode(ctx, nodeConfig)
@@ -45,6 +131,25 @@ func main() {
 		log.Fatalf("Failed to create DHT node: %v", err)
 	}
 
+	// Usage digests are committed on-chain once an epoch closes
+	if *ethKeyPath != "" && node.UsageMeter() != nil {
+		key, err := blockchain.LoadKey(*ethKeyPath)
+		if err != nil {
+			log.Fatalf("Failed to load operator key: %v", err)
+		}
+		registry, err := blockchain.Dial(ctx, blockchain.Config{
+			RPCURL:   *rpcURL,
+			Contract: *contractAddr,
+			Key:      key,
+		})
+		if err != nil {
+			log.Fatalf("Failed to connect to the registry contract: %v", err)
+		}
+		defer registry.Close()
+		node.UsageMeter().SetCommitter(registry)
+		fmt.Printf("🧾 Committing usage digests as %s\n", registry.Operator().Hex())
+	}
+
 	// Bootstrap if address provided
 	if *bootstrap != "" {
 		fmt.Printf("🔗 Connecting to bootstrap node: %s\n", *bootstrap)
@@ -54,9 +159,24 @@ func main() {
 		fmt.Println("✅ Connected to bootstrap node")
 	}
 
-	// Set up RPC handler
-	rpcHandler := meshstorage.NewRPCHandler(node)
-	rpcHandler.SetupStreamHandler()
+	// Set up RPC handler (bootstrap-only nodes hold no shards to serve)
+	if !*bootstrapOnly {
+		rpcHandler := meshstorage.NewRPCHandler(node)
+		rpcHandler.SetLimits(meshstorage.RPCLimits{
+			MaxMessageSize:    int64(*rpcMaxMessage) << 20,
+			ReadTimeout:       *rpcTimeout,
+			WriteTimeout:      *rpcTimeout,
+			MaxStreamsPerPeer: *rpcStreams,
+		})
+		rpcHandler.SetupStreamHandler()
+	}
+
+	// Tell peers we're back so they re-verify our shards after planned downtime
+	if *bootstrap != "" && !*bootstrapOnly {
+		announceCtx, announceCancel := context.WithTimeout(ctx, 10*time.Second)
+		node.AnnounceReturn(announceCtx)
+		announceCancel()
+	}
 
 	// Display node info
 	fmt.Println()
@@ -66,7 +186,15 @@ func main() {
 	for _, addr := range node.Addresses() {
 		fmt.Printf("    %s\n", addr)
 	}
-	fmt.Printf("  Storage: %s/chunks.db\n", *dataDir)
+	if *bootstrapOnly {
+		fmt.Printf("  Mode: bootstrap-only (max %d connections)\n", *maxConns)
+	} else {
+		fmt.Printf("  Storage: %s/chunks.db\n", *dataDir)
+		fmt.Printf("  Erasure coding: %s (%.2fx overhead)\n", erasure, erasure.Redundancy())
+		if node.UsageMeter() != nil {
+			fmt.Printf("  Usage epoch: %v\n", *usageEpoch)
+		}
+	}
 	fmt.Printf("  Peers: %d\n", node.PeerCount())
 	fmt.Println()
 
@@ -78,6 +206,12 @@ func main() {
 		EnableCORS:      *enableCORS,
 		RateLimit:       *rateLimit,
 		MaxUploadSizeMB: *maxUploadMB,
+		DrainTimeout:    *drainTimeout,
+		LinkSecret:      *linkSecret,
+		AdminToken:      *adminToken,
+		Logs:            logs,
+		Erasure:         erasure,
+		Rebalance:       meshstorage.RebalanceConfig{Interval: *rebalance, BytesPerSecond: int64(*rebalanceRate) << 10},
 	}
 
 	apiServer, err := api.NewServer(node, apiConfig)
@@ -100,15 +234,33 @@ func main() {
 	fmt.Println("✅ Server is ready!")
 	fmt.Println()
 	fmt.Println("API Endpoints:")
-	fmt.Printf("  POST   http://localhost:%d/api/v1/storage/upload\n", *apiPort)
-	fmt.Printf("  GET    http://localhost:%d/api/v1/storage/download/:userAddr/:chunkID\n", *apiPort)
-	fmt.Printf("  GET    http://localhost:%d/api/v1/storage/status/:userAddr/:chunkID\n", *apiPort)
-	fmt.Printf("  DELETE http://localhost:%d/api/v1/storage/delete/:userAddr/:chunkID\n", *apiPort)
+	if !*bootstrapOnly {
+		fmt.Printf("  POST   http://localhost:%d/api/v1/storage/upload\n", *apiPort)
+		fmt.Printf("  GET    http://localhost:%d/api/v1/storage/download/:userAddr/:chunkID\n", *apiPort)
+		fmt.Printf("  GET    http://localhost:%d/api/v1/storage/status/:userAddr/:chunkID\n", *apiPort)
+		fmt.Printf("  DELETE http://localhost:%d/api/v1/storage/delete/:userAddr/:chunkID\n", *apiPort)
+		fmt.Printf("  POST   http://localhost:%d/api/v1/storage/sessions\n", *apiPort)
+		fmt.Printf("  PUT    http://localhost:%d/api/v1/storage/sessions/:sessionID/parts/:part\n", *apiPort)
+		fmt.Printf("  GET    http://localhost:%d/api/v1/storage/sessions/:sessionID\n", *apiPort)
+		fmt.Printf("  POST   http://localhost:%d/api/v1/storage/sessions/:sessionID/complete\n", *apiPort)
+		fmt.Printf("  DELETE http://localhost:%d/api/v1/storage/sessions/:sessionID\n", *apiPort)
+		fmt.Printf("  POST   http://localhost:%d/api/v1/storage/links\n", *apiPort)
+		fmt.Printf("  GET    http://localhost:%d/api/v1/links/:token\n", *apiPort)
+		fmt.Printf("  POST   http://localhost:%d/api/v1/public\n", *apiPort)
+		fmt.Printf("  GET    http://localhost:%d/api/v1/public/:hash\n", *apiPort)
+		fmt.Printf("  DELETE http://localhost:%d/api/v1/public/:hash\n", *apiPort)
+	}
 	fmt.Printf("  GET    http://localhost:%d/api/v1/network/info\n", *apiPort)
 	fmt.Printf("  GET    http://localhost:%d/api/v1/network/peers\n", *apiPort)
+	fmt.Printf("  GET    http://localhost:%d/api/v1/network/seeds\n", *apiPort)
 	fmt.Printf("  GET    http://localhost:%d/api/v1/node/info\n", *apiPort)
-	fmt.Printf("  GET    http://localhost:%d/api/v1/node/stats\n", *apiPort)
+	if !*bootstrapOnly {
+		fmt.Printf("  GET    http://localhost:%d/api/v1/node/stats\n", *apiPort)
+	}
 	fmt.Printf("  GET    http://localhost:%d/health\n", *apiPort)
+	if *adminToken != "" {
+		fmt.Printf("  *      http://localhost:%d/api/v1/admin/... (operator, see zentalk-admin)\n", *apiPort)
+	}
 	fmt.Println()
 
 	// Wait for interrupt signal
@@ -119,8 +271,18 @@ func main() {
 
 	fmt.Println("\n🛑 Shutting down...")
 
-	// Graceful shutdown
-	apiCancel() // Cancel context to stop API server
+	if *maintenance > 0 && !*bootstrapOnly {
+		announceCtx, announceCancel := context.WithTimeout(ctx, 10*time.Second)
+		notified := node.AnnounceMaintenance(announceCtx, *maintenance, "planned shutdown")
+		announceCancel()
+		fmt.Printf("🛠️  Announced %v maintenance window to %d peers\n", *maintenance, notified)
+	}
+
+	// Graceful shutdown: drain uploads before the node (and its storage) goes away
+	if err := apiServer.Shutdown(); err != nil {
+		fmt.Printf("Error shutting down API server: %v\n", err)
+	}
+	apiCancel()
 
 	// Close node
 	if err := node.Close(); err != nil {
@@ -129,3 +291,14 @@ func main() {
 
 	fmt.Println("👋 Goodbye!")
 }
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
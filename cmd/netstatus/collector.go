@@ -0,0 +1,310 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/meshstorage/api"
+	"github.com/ZentaChain/zentalk-node/pkg/network"
+)
+
+// RelayStatus is the last observation of one relay
+type RelayStatus struct {
+	Endpoint  string    `json:"endpoint"`
+	Address   string    `json:"address,omitempty"` // Hex protocol address, when known from the registry
+	Region    string    `json:"region,omitempty"`
+	Online    bool      `json:"online"`
+	PingMs    float64   `json:"pingMs,omitempty"` // Ping round trip from this service, not message delivery time
+	CheckedAt time.Time `json:"checkedAt"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// StorageStatus is the last observation of one mesh storage node
+type StorageStatus struct {
+	URL         string    `json:"url"`
+	Online      bool      `json:"online"`
+	Health      string    `json:"health,omitempty"` // "healthy", "degraded" or "unhealthy" as reported by the node
+	Chunks      int       `json:"chunks"`
+	StoredBytes int64     `json:"storedBytes"`
+	Users       int       `json:"users"`
+	SuccessRate float64   `json:"successRate"`
+	CheckedAt   time.Time `json:"checkedAt"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// NetworkStatus is the aggregated view served to the dashboard
+type NetworkStatus struct {
+	GeneratedAt time.Time `json:"generatedAt"`
+
+	Relays struct {
+		Known        int     `json:"known"`
+		Active       int     `json:"active"`
+		AvgPingMs    float64 `json:"avgPingMs"`    // Mean ping round trip over active relays
+		MedianPingMs float64 `json:"medianPingMs"` // Less sensitive to one slow relay
+	} `json:"relays"`
+
+	Storage struct {
+		Nodes       int   `json:"nodes"`
+		Online      int   `json:"online"`
+		Healthy     int   `json:"healthy"`
+		Chunks      int   `json:"chunks"`
+		StoredBytes int64 `json:"storedBytes"`
+	} `json:"storage"`
+
+	RelayDetails   []RelayStatus   `json:"relayDetails"`
+	StorageDetails []StorageStatus `json:"storageDetails"`
+}
+
+// relayTarget is a relay to watch
+type relayTarget struct {
+	endpoint string
+	address  string
+	region   string
+}
+
+// Collector polls relays and storage nodes and keeps the latest aggregate
+type Collector struct {
+	staticRelays []string // From -relays
+	registry     string   // Registry file or URL, re-read every round
+	meshNodes    []string
+	timeout      time.Duration
+	httpClient   *http.Client
+
+	mu     sync.RWMutex
+	status *NetworkStatus
+}
+
+// NewCollector creates a collector; call Run to start polling
+func NewCollector(relays []string, registry string, meshNodes []string, timeout time.Duration) *Collector {
+	return &Collector{
+		staticRelays: relays,
+		registry:     registry,
+		meshNodes:    meshNodes,
+		timeout:      timeout,
+		httpClient:   &http.Client{Timeout: timeout},
+		status:       &NetworkStatus{},
+	}
+}
+
+// Status returns the latest aggregate
+func (c *Collector) Status() *NetworkStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.status
+}
+
+// Run polls every interval until stop is closed
+func (c *Collector) Run(interval time.Duration, stop <-chan struct{}) {
+	c.Collect()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.Collect()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Collect runs one polling round and publishes the result
+func (c *Collector) Collect() {
+	targets := c.relayTargets()
+
+	relays := make([]RelayStatus, len(targets))
+	storage := make([]StorageStatus, len(c.meshNodes))
+
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target relayTarget) {
+			defer wg.Done()
+			relays[i] = c.checkRelay(target)
+		}(i, target)
+	}
+	for i, url := range c.meshNodes {
+		wg.Add(1)
+		go func(i int, url string) {
+			defer wg.Done()
+			storage[i] = c.checkStorage(url)
+		}(i, url)
+	}
+	wg.Wait()
+
+	status := aggregate(relays, storage)
+
+	c.mu.Lock()
+	c.status = status
+	c.mu.Unlock()
+
+	log.Printf("📊 %d/%d relays active (avg ping %.1f ms), %d/%d storage nodes online, %d chunks",
+		status.Relays.Active, status.Relays.Known, status.Relays.AvgPingMs,
+		status.Storage.Online, status.Storage.Nodes, status.Storage.Chunks)
+}
+
+// relayTargets merges static relays with the current registry contents
+func (c *Collector) relayTargets() []relayTarget {
+	seen := make(map[string]bool)
+	var targets []relayTarget
+
+	if c.registry != "" {
+		registry := network.NewRelayRegistry()
+		var err error
+		if strings.HasPrefix(c.registry, "http://") || strings.HasPrefix(c.registry, "https://") {
+			err = registry.FetchFromURL(c.registry)
+		} else {
+			err = registry.LoadFromFile(c.registry)
+		}
+		if err != nil {
+			log.Printf("⚠️  Relay registry unavailable: %v", err)
+		}
+		for _, info := range registry.GetAllRelays() {
+			if seen[info.Endpoint] {
+				continue
+			}
+			seen[info.Endpoint] = true
+			targets = append(targets, relayTarget{
+				endpoint: info.Endpoint,
+				address:  hex.EncodeToString(info.Address[:]),
+				region:   info.Region,
+			})
+		}
+	}
+
+	for _, endpoint := range c.staticRelays {
+		if seen[endpoint] {
+			continue
+		}
+		seen[endpoint] = true
+		targets = append(targets, relayTarget{endpoint: endpoint})
+	}
+
+	sort.Slice(targets, func(i, j int) bool { return targets[i].endpoint < targets[j].endpoint })
+	return targets
+}
+
+// checkRelay pings one relay
+func (c *Collector) checkRelay(target relayTarget) RelayStatus {
+	status := RelayStatus{
+		Endpoint:  target.endpoint,
+		Address:   target.address,
+		Region:    target.region,
+		CheckedAt: time.Now(),
+	}
+
+	rtt, err := network.PingRelay(target.endpoint, c.timeout)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Online = true
+	status.PingMs = float64(rtt.Microseconds()) / 1000
+	return status
+}
+
+// checkStorage reads health and stats from one mesh API node
+func (c *Collector) checkStorage(baseURL string) StorageStatus {
+	status := StorageStatus{
+		URL:       baseURL,
+		CheckedAt: time.Now(),
+	}
+
+	var health api.HealthResponse
+	if err := c.getJSON(baseURL+"/health", &health); err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Online = true
+	status.Health = health.Status
+
+	var stats api.NodeStatsResponse
+	if err := c.getJSON(baseURL+"/api/v1/node/stats", &stats); err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Chunks = stats.Stats.TotalChunks
+	status.StoredBytes = stats.Stats.TotalSizeBytes
+	status.Users = stats.Stats.UniqueUsers
+	status.SuccessRate = stats.Stats.SuccessRate
+	return status
+}
+
+// getJSON fetches url and decodes the JSON body
+func (c *Collector) getJSON(url string, out interface{}) error {
+	resp, err := c.httpClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("unexpected response: %w", err)
+	}
+	return nil
+}
+
+// aggregate builds the network view from individual observations
+func aggregate(relays []RelayStatus, storage []StorageStatus) *NetworkStatus {
+	status := &NetworkStatus{
+		GeneratedAt:    time.Now(),
+		RelayDetails:   relays,
+		StorageDetails: storage,
+	}
+
+	var pings []float64
+	for _, r := range relays {
+		if r.Online {
+			pings = append(pings, r.PingMs)
+		}
+	}
+	status.Relays.Known = len(relays)
+	status.Relays.Active = len(pings)
+	if len(pings) > 0 {
+		var sum float64
+		for _, p := range pings {
+			sum += p
+		}
+		status.Relays.AvgPingMs = sum / float64(len(pings))
+
+		sort.Float64s(pings)
+		mid := len(pings) / 2
+		if len(pings)%2 == 0 {
+			status.Relays.MedianPingMs = (pings[mid-1] + pings[mid]) / 2
+		} else {
+			status.Relays.MedianPingMs = pings[mid]
+		}
+	}
+
+	status.Storage.Nodes = len(storage)
+	for _, s := range storage {
+		if !s.Online {
+			continue
+		}
+		status.Storage.Online++
+		if s.Health == "healthy" {
+			status.Storage.Healthy++
+		}
+		status.Storage.Chunks += s.Chunks
+		status.Storage.StoredBytes += s.StoredBytes
+	}
+
+	return status
+}
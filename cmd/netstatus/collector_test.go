@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/network"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// startPongRelay answers every ping on a local listener with a pong
+func startPongRelay(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				header, err := protocol.ReadHeader(conn)
+				if err != nil || header.Type != protocol.MsgTypePing {
+					return
+				}
+				protocol.WriteHeader(conn, &protocol.Header{
+					Magic:     protocol.ProtocolMagic,
+					Version:   protocol.ProtocolVersion,
+					Type:      protocol.MsgTypePong,
+					MessageID: header.MessageID,
+				})
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// closedEndpoint returns a local address nothing listens on
+func closedEndpoint(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint := listener.Addr().String()
+	listener.Close()
+	return endpoint
+}
+
+// startMeshNode serves the health and stats endpoints of a mesh API node
+func startMeshNode(t *testing.T, health string, chunks int, stored int64) string {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "status": health})
+	})
+	mux.HandleFunc("/api/v1/node/stats", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"stats":   map[string]interface{}{"totalChunks": chunks, "totalSizeBytes": stored, "uniqueUsers": 2, "successRate": 99.5},
+		})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestCollect(t *testing.T) {
+	online := startPongRelay(t)
+	offline := closedEndpoint(t)
+
+	// The registry lists one relay; -relays repeats it and adds a dead one
+	registry := network.NewRelayRegistry()
+	registry.AddRelay(&network.RelayInfo{Address: protocol.Address{0xab}, Endpoint: online, Region: "eu-west"})
+	registryFile := filepath.Join(t.TempDir(), "relays.json")
+	if err := registry.SaveToFile(registryFile); err != nil {
+		t.Fatal(err)
+	}
+
+	broken := httptest.NewServer(http.NotFoundHandler())
+	defer broken.Close()
+	meshNodes := []string{
+		startMeshNode(t, "healthy", 10, 1000),
+		startMeshNode(t, "degraded", 5, 500),
+		broken.URL,
+	}
+
+	c := NewCollector([]string{online, offline}, registryFile, meshNodes, 2*time.Second)
+	c.Collect()
+	status := c.Status()
+
+	if status.Relays.Known != 2 || status.Relays.Active != 1 {
+		t.Fatalf("relays known/active = %d/%d, want 2/1", status.Relays.Known, status.Relays.Active)
+	}
+	for _, relay := range status.RelayDetails {
+		switch relay.Endpoint {
+		case online:
+			if !relay.Online || relay.Region != "eu-west" || relay.Address[:2] != "ab" {
+				t.Errorf("registry relay = %+v, want online with its registry details", relay)
+			}
+			if relay.PingMs <= 0 {
+				t.Errorf("registry relay ping = %v ms, want measured", relay.PingMs)
+			}
+		case offline:
+			if relay.Online || relay.Error == "" {
+				t.Errorf("dead relay = %+v, want offline with an error", relay)
+			}
+		}
+	}
+
+	if status.Storage.Nodes != 3 || status.Storage.Online != 2 || status.Storage.Healthy != 1 {
+		t.Errorf("storage nodes/online/healthy = %d/%d/%d, want 3/2/1",
+			status.Storage.Nodes, status.Storage.Online, status.Storage.Healthy)
+	}
+	if status.Storage.Chunks != 15 || status.Storage.StoredBytes != 1500 {
+		t.Errorf("storage chunks/bytes = %d/%d, want 15/1500", status.Storage.Chunks, status.Storage.StoredBytes)
+	}
+	if details := status.StorageDetails[0]; details.Users != 2 || details.SuccessRate != 99.5 {
+		t.Errorf("storage details = %+v, want the node's stats", details)
+	}
+	if details := status.StorageDetails[2]; details.Online || details.Error == "" {
+		t.Errorf("broken node = %+v, want offline with an error", details)
+	}
+}
+
+func TestAggregatePings(t *testing.T) {
+	relays := []RelayStatus{
+		{Online: true, PingMs: 10},
+		{Online: true, PingMs: 40},
+		{Online: false},
+		{Online: true, PingMs: 20},
+		{Online: true, PingMs: 30},
+	}
+
+	status := aggregate(relays, nil)
+	if status.Relays.AvgPingMs != 25 {
+		t.Errorf("AvgPingMs = %v, want 25", status.Relays.AvgPingMs)
+	}
+	if status.Relays.MedianPingMs != 25 {
+		t.Errorf("MedianPingMs = %v, want 25", status.Relays.MedianPingMs)
+	}
+
+	status = aggregate(relays[:3], nil)
+	if status.Relays.MedianPingMs != 25 {
+		t.Errorf("MedianPingMs of two = %v, want 25", status.Relays.MedianPingMs)
+	}
+	if status = aggregate(relays[2:3], nil); status.Relays.AvgPingMs != 0 || status.Relays.Active != 0 {
+		t.Errorf("no active relays gave avg %v over %d", status.Relays.AvgPingMs, status.Relays.Active)
+	}
+}
+
+func TestStatusPage(t *testing.T) {
+	status := aggregate([]RelayStatus{{Endpoint: "relay:9000", Online: true, PingMs: 12.5}}, nil)
+
+	rec := httptest.NewRecorder()
+	if err := statusPage.Execute(rec, status); err != nil {
+		t.Fatalf("statusPage.Execute() error = %v", err)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{"Relay ping (avg / median)", "12.5 ms", "relay:9000"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("status page is missing %q", want)
+		}
+	}
+
+	encoded, err := json.Marshal(status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(encoded), `"avgPingMs":12.5`) {
+		t.Errorf("JSON status = %s, want avgPingMs", encoded)
+	}
+}
+
+func TestRegistryUnavailable(t *testing.T) {
+	c := NewCollector([]string{"b:1", "a:1", "b:1"}, filepath.Join(t.TempDir(), "missing.json"), nil, time.Second)
+	targets := c.relayTargets()
+	if len(targets) != 2 || targets[0].endpoint != "a:1" || targets[1].endpoint != "b:1" {
+		t.Errorf("relayTargets() = %+v, want the static relays once each, sorted", targets)
+	}
+}
@@ -0,0 +1,174 @@
+// Command netstatus aggregates relay and storage node health into one network view
+//
+// Relays come from a relay registry (the JSON format of network.RelayRegistry, read
+// from a file or URL and re-read every round) plus any given with -relays. Each is
+// pinged over the relay protocol. Storage nodes are polled through their mesh API.
+// The result is served as JSON at /api/status and as an HTML page at /.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	listenAddr = flag.String("listen", ":8090", "Address to serve the status page on")
+	relays     = flag.String("relays", "", "Relay endpoints to watch (host:port,host:port,...)")
+	registry   = flag.String("registry", "", "Relay registry JSON file or http(s) URL, re-read every round")
+	meshNodes  = flag.String("mesh", "", "Mesh API base URLs to watch (http://host:8080,...)")
+	interval   = flag.Duration("interval", 30*time.Second, "Polling interval")
+	timeout    = flag.Duration("timeout", 5*time.Second, "Per-node check timeout")
+)
+
+func main() {
+	flag.Parse()
+
+	if *relays == "" && *registry == "" && *meshNodes == "" {
+		log.Fatal("Error: nothing to watch; set -registry, -relays and/or -mesh")
+	}
+
+	collector := NewCollector(splitList(*relays), *registry, trimURLs(splitList(*meshNodes)), *timeout)
+
+	stop := make(chan struct{})
+	go collector.Run(*interval, stop)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		json.NewEncoder(w).Encode(collector.Status())
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := statusPage.Execute(w, collector.Status()); err != nil {
+			log.Printf("⚠️  Failed to render status page: %v", err)
+		}
+	})
+
+	server := &http.Server{
+		Addr:         *listenAddr,
+		Handler:      mux,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		log.Printf("🌐 Network status on http://%s (JSON at /api/status)", *listenAddr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Server error: %v", err)
+		}
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	<-sigCh
+
+	log.Println("🛑 Shutting down...")
+	close(stop)
+	server.Close()
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// trimURLs removes trailing slashes so paths can be appended
+func trimURLs(urls []string) []string {
+	for i, u := range urls {
+		urls[i] = strings.TrimRight(u, "/")
+	}
+	return urls
+}
+
+// formatBytes renders a byte count for humans
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+var statusPage = template.Must(template.New("status").Funcs(template.FuncMap{
+	"bytes": formatBytes,
+	"time":  func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04:05 UTC") },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="30">
+<title>ZenTalk Network Status</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { padding: 4px 12px; text-align: left; border-bottom: 1px solid #ddd; }
+.up { color: #1a7f37; } .down { color: #cf222e; }
+.summary td { font-size: 1.2em; }
+</style>
+</head>
+<body>
+<h1>ZenTalk Network Status</h1>
+<p>Updated {{time .GeneratedAt}}</p>
+
+<table class="summary">
+<tr><th>Active relays</th><td>{{.Relays.Active}} / {{.Relays.Known}}</td></tr>
+<tr><th>Relay ping (avg / median)</th><td>{{printf "%.1f" .Relays.AvgPingMs}} ms / {{printf "%.1f" .Relays.MedianPingMs}} ms</td></tr>
+<tr><th>Storage nodes online</th><td>{{.Storage.Online}} / {{.Storage.Nodes}} ({{.Storage.Healthy}} healthy)</td></tr>
+<tr><th>Data stored</th><td>{{bytes .Storage.StoredBytes}} in {{.Storage.Chunks}} chunks</td></tr>
+</table>
+
+{{if .RelayDetails}}
+<h2>Relays</h2>
+<table>
+<tr><th>Endpoint</th><th>Region</th><th>Status</th><th>Ping</th><th>Address</th></tr>
+{{range .RelayDetails}}
+<tr>
+<td>{{.Endpoint}}</td><td>{{.Region}}</td>
+{{if .Online}}<td class="up">online</td><td>{{printf "%.1f" .PingMs}} ms</td>{{else}}<td class="down" title="{{.Error}}">offline</td><td>-</td>{{end}}
+<td><code>{{.Address}}</code></td>
+</tr>
+{{end}}
+</table>
+{{end}}
+
+{{if .StorageDetails}}
+<h2>Storage Nodes</h2>
+<table>
+<tr><th>Node</th><th>Status</th><th>Chunks</th><th>Stored</th><th>Users</th><th>Success rate</th></tr>
+{{range .StorageDetails}}
+<tr>
+<td>{{.URL}}</td>
+{{if .Online}}<td class="up">{{.Health}}</td>{{else}}<td class="down" title="{{.Error}}">offline</td>{{end}}
+<td>{{.Chunks}}</td><td>{{bytes .StoredBytes}}</td><td>{{.Users}}</td><td>{{printf "%.1f" .SuccessRate}}%</td>
+</tr>
+{{end}}
+</table>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/ZentaChain/zentalk-node/pkg/blockchain"
+	"github.com/ZentaChain/zentalk-node/pkg/network"
+	"github.com/ZentaChain/zentalk-node/pkg/storage"
+)
+
+// dialRegistry connects to the registry contract as the operator (nil without -eth-key)
+func dialRegistry() (*blockchain.Registry, error) {
+	if *ethKeyPath == "" {
+		return nil, nil
+	}
+
+	key, err := blockchain.LoadKey(*ethKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	return blockchain.Dial(context.Background(), blockchain.Config{
+		RPCURL:   *rpcURL,
+		Contract: *contractAddr,
+		Operator: *operatorAddr,
+		Key:      key,
+	})
+}
+
+// newReporter creates the relay's reporter with counts persisted under ./data
+// Counts a previous run saved but never reported are picked up and sent with the next report.
+func newReporter(registry *blockchain.Registry, relay *network.RelayServer) (*blockchain.Reporter, *storage.RelayCountStore, error) {
+	if err := os.MkdirAll("./data", 0755); err != nil {
+		return nil, nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+	counts, err := storage.OpenRelayCountStore(fmt.Sprintf("./data/relay-%d-counts.db", *port))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reporter := blockchain.NewReporter(registry, relay.Address)
+	recovered, err := reporter.AttachStore(counts)
+	if err != nil {
+		counts.Close()
+		return nil, nil, err
+	}
+	if recovered > 0 {
+		log.Printf("⛓️  Recovered %d unreported relay counts from the last run", recovered)
+	}
+	return reporter, counts, nil
+}
+
+// registerOnChain adds the relay to the registry if it isn't there yet
+func registerOnChain(registry *blockchain.Registry, relay *network.RelayServer) error {
+	endpoint := relay.AdvertisedAddress()
+
+	log.Println("⏳ Registering on blockchain...")
+	log.Printf("   Operator: %s", registry.Operator().Hex())
+	log.Printf("   Contract: %s", *contractAddr)
+	log.Printf("   Endpoint: %s", endpoint)
+
+	return registry.Register(context.Background(), relay.Address, endpoint, *region)
+}
+
+// announceTakeover points the registry at this relay after it took over from its primary
+func announceTakeover(registry *blockchain.Registry, relay *network.RelayServer) error {
+	endpoint := relay.AdvertisedAddress()
+	log.Printf("⏳ Announcing takeover: endpoint %s", endpoint)
+	return registry.UpdateEndpoint(context.Background(), relay.Address, endpoint)
+}
+
+// reportOnChain sends a heartbeat and the messages relayed since the last report
+func reportOnChain(reporter *blockchain.Reporter) {
+	before := reporter.Reported()
+	if err := reporter.Report(context.Background()); err != nil {
+		log.Printf("⚠️  On-chain report failed: %v (%d messages kept for the next one)", err, reporter.Pending())
+		return
+	}
+	log.Printf("⛓️  Heartbeat recorded on-chain (%d messages reported, %d since startup)", reporter.Reported()-before, reporter.Reported())
+}
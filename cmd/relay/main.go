@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
 	"crypto/rsa"
+	"crypto/tls"
+	"encoding/hex"
 	"flag"
 	"fmt"
 	"log"
@@ -10,7 +13,9 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/ZentaChain/zentalk-node/pkg/blockchain"
 	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/logging"
 	"github.com/ZentaChain/zentalk-node/pkg/network"
 	"github.com/ZentaChain/zentalk-node/pkg/storage"
 )
@@ -28,13 +33,92 @@ var (
 	operatorAddr   = flag.String("operator", "", "Operator ETH address (required)")
 	contractAddr   = flag.String("contract", "", "Registry contract address (required)")
 	rpcURL         = flag.String("rpc", "https://rpc.sepolia.org", "RPC URL")
+	ethKeyPath     = flag.String("eth-key", "", "Hex private key file of the -operator account; enables on-chain registration and reporting")
+	region         = flag.String("region", "", "Region announced in the registry (e.g. us-west)")
+	reportInterval = flag.Duration("report-interval", blockchain.DefaultReportInterval, "Longest time between on-chain relay count reports (with -eth-key)")
+	reportBatch    = flag.Uint64("report-batch", 10000, "Report early once this many relayed messages are pending (0 to report only on -report-interval)")
 	enableMesh     = flag.Bool("mesh", true, "Enable auto-mesh formation")
 	targetPeers    = flag.Int("peers", 5, "Target number of relay peers for mesh")
+	exitPolicy     = flag.String("exit-policy", "both", "Onion roles to accept: both, forward (relay-to-relay only), delivery (final delivery only)")
+	probeInterval  = flag.Duration("probe-interval", network.DefaultProbeInterval, "Bandwidth self-test interval (0 to disable)")
+	headerTimeout  = flag.Duration("header-timeout", network.DefaultConnectionLimits().HeaderTimeout, "Time allowed to send a message header")
+	idleTimeout    = flag.Duration("idle-timeout", network.DefaultConnectionLimits().IdleTimeout, "Close user connections idle this long (0 to disable)")
+	minPayloadRate = flag.Int("min-payload-rate", network.DefaultConnectionLimits().MinPayloadRate, "Slowest accepted payload transfer in bytes/s (0 to disable)")
+	maxHalfOpen    = flag.Int("max-half-open", network.DefaultConnectionLimits().MaxHalfOpen, "Max connections waiting for a handshake (0 for no limit)")
+	forwardRate    = flag.Int("forward-rate", network.DefaultForwardRateLimits().ConnectionRate, "Max messages/s one client connection may send (0 for no limit)")
+	forwardBurst   = flag.Int("forward-burst", network.DefaultForwardRateLimits().ConnectionBurst, "Messages one client connection may send at once")
+	sourceRate     = flag.Int("source-rate", network.DefaultForwardRateLimits().SourceRate, "Max messages/s from one IP address across its connections (0 for no limit)")
+	sourceBurst    = flag.Int("source-burst", network.DefaultForwardRateLimits().SourceBurst, "Messages one IP address may send at once")
+	uniformRecords = flag.Bool("uniform-records", false, "Pad frames to fixed record sizes on links that request it")
+	recordJitter   = flag.Duration("record-jitter", network.DefaultUniformRecordConfig().MaxJitter, "Max random delay before each frame with -uniform-records")
+	enableTLS      = flag.Bool("tls", false, "Offer TLS to clients (StartTLS) with -tls-cert/-tls-key or a self-signed certificate for the relay key")
+	tlsCert        = flag.String("tls-cert", "", "PEM certificate chain for -tls (default: self-signed)")
+	tlsKey         = flag.String("tls-key", "", "PEM private key for -tls-cert")
+	requireTLS     = flag.Bool("require-tls", false, "Refuse users that handshake over plain TCP (implies -tls)")
+	resumeLifetime = flag.Duration("resume-lifetime", network.DefaultResumeTicketLifetime, "How long after a drop users may resume their session without a handshake (0 to disable)")
+	listenHosts    = flag.String("listen", "", "Comma-separated IP addresses to listen on (default: all interfaces)")
+	addrFamily     = flag.String("address-family", "dual", "IP versions to listen and dial on: dual, ipv4, ipv6")
+	wsPort         = flag.Int("ws-port", 0, "Also accept browser clients over WebSocket on this port (0 to disable)")
+	enableNAT      = flag.Bool("nat", true, "Forward the relay port on the local router via UPnP/NAT-PMP")
+	exportQueue    = flag.String("export-queue", "", "Export the offline message queue to this file (encrypted) for relay migration")
+	importQueue    = flag.String("import-queue", "", "Import an offline message queue export from this file on startup")
+	queuePass      = flag.String("queue-passphrase", "", "Passphrase for queue export/import (or set ZENTALK_QUEUE_PASSPHRASE)")
+	movedTo        = flag.String("moved-to", "", "Replacement relay endpoint (host:port); users are redirected there after export")
+	movedToAddr    = flag.String("moved-to-address", "", "Replacement relay address (hex) announced with -moved-to")
+	queueDSN       = flag.String("queue-dsn", "", "PostgreSQL DSN for a shared message queue (default: local SQLite)")
+	clusterNode    = flag.String("cluster-node", "", "This process's node ID in a relay cluster (requires -queue-dsn)")
+	clusterNodes   = flag.String("cluster-nodes", "", "Cluster members as id=host:port,id=host:port,...")
+	replicateOn    = flag.String("replication-listen", "", "Let a warm standby with this relay's key replicate it from this address (host:port)")
+	standbyOf      = flag.String("standby-of", "", "Run as warm standby of the relay replicating on this address (host:port), with its -key; takes over when it fails")
+	standbyEvery   = flag.Duration("standby-interval", network.DefaultStandbySyncInterval, "How often a -standby-of relay replicates its primary")
+	standbyFails   = flag.Int("standby-fail-after", network.DefaultStandbyFailAfter, "Failed syncs in a row before a -standby-of relay takes over")
+	tenantsFile    = flag.String("tenants", "", "JSON file of tenants to serve; users must then name one of them in the handshake")
+	botsFile       = flag.String("bots", "", "JSON file of bot accounts and their API keys (see zentalk-admin bot-key)")
+	meshPeerRate   = flag.Int("mesh-peer-rate", 0, "Max bytes/s forwarded to any one relay peer (0 for no limit)")
+	meshTotalRate  = flag.Int("mesh-total-rate", 0, "Max bytes/s forwarded to all relay peers together (0 for no limit)")
+	meshRouting    = flag.Bool("mesh-routing", false, "Route messages for users connected to other relays through relay peers (announces who is online here to them)")
+	routeHops      = flag.Int("route-hops", network.DefaultRouteHopLimit, "Max relays a routed message may pass through (with -mesh-routing)")
+	policyFile     = flag.String("policy", "", "JSON file of message types and sizes accepted from users, advertised in handshakes")
+	mediaDirFile   = flag.String("media-directory", "", "JSON file of storage endpoints and media size limits advertised to users")
+	stakeFile      = flag.String("stake-file", "", "JSON file of addresses' stake and vouchers; enables queue priority")
+	priorityFile   = flag.String("queue-priority", "", "JSON file of queue priority weights (used with -stake-file)")
+	proofWindow    = flag.Duration("proof-window", 30*24*time.Hour, "How long to keep signed delivery proofs for reward disputes (0 to disable)")
+	exportProofs   = flag.String("export-proofs", "", "Export the delivery proofs to this JSON file and exit")
+	keyBundles     = flag.Bool("key-bundles", true, "Hold users' key bundles so contacts can start sessions while they are offline (shared through -queue-dsn in a cluster)")
+	updateURL      = flag.String("update-manifest", "", "URL of the signed release manifest to check for new versions (empty to disable)")
+	updateKey      = flag.String("update-key", "", "Hex Ed25519 public key release manifests must be signed with")
+	updateInterval = flag.Duration("update-interval", 6*time.Hour, "How often to check the release manifest")
+	selfUpdate     = flag.Bool("self-update", false, "Download and install new releases in place of this binary (takes effect on restart)")
+	logFile        = flag.String("log-file", "", "Write logs to this file, rotated at -log-max-size (default: stdout only)")
+	logMaxSize     = flag.Int("log-max-size", int(logging.DefaultConfig().MaxSize>>20), "Rotate -log-file at this size in MB (0 to never rotate)")
+	logMaxAge      = flag.Duration("log-max-age", logging.DefaultConfig().MaxAge, "Delete rotated log files older than this (0 to keep -log-backups files)")
+	logBackups     = flag.Int("log-backups", logging.DefaultConfig().MaxBackups, "Rotated log files to keep (0 to keep by -log-max-age only)")
+	logConsole     = flag.Bool("log-console", false, "Also print logs to stdout with -log-file")
+	logRepeats     = flag.Int("log-repeats", logging.DefaultConfig().RepeatBurst, "Similar log lines printed per minute before the rest are suppressed (0 to disable)")
 )
 
+// logs receives everything the relay logs; closed on shutdown to flush suppression counts
+var logs *logging.Sink
+
 func main() {
 	flag.Parse()
 
+	logCfg := logging.DefaultConfig()
+	logCfg.Path = *logFile
+	logCfg.MaxSize = int64(*logMaxSize) << 20
+	logCfg.MaxAge = *logMaxAge
+	logCfg.MaxBackups = *logBackups
+	logCfg.Console = *logConsole
+	logCfg.RepeatBurst = *logRepeats
+	var err error
+	if logs, err = logging.New(logCfg); err != nil {
+		log.Fatalf("Failed to set up logging: %v", err)
+	}
+	logs.Install()
+	if err := logs.CaptureStdout(); err != nil {
+		log.Fatalf("Failed to set up logging: %v", err)
+	}
+
 	printBanner()
 
 	// Validate required flags
@@ -57,31 +141,274 @@ func main() {
 	// Create relay server
 	relay := network.NewRelayServer(*port, privateKey)
 
-	// Set callback for relay counting
-	relay.OnMessageRelayed = func() {
-		// TODO: Implement batch reporting to blockchain
-		log.Println("Message relayed (will report to blockchain)")
+	policy, err := network.ParseExitPolicy(*exitPolicy)
+	if err != nil {
+		log.Fatalf("Invalid -exit-policy: %v", err)
 	}
+	relay.SetExitPolicy(policy)
 
-	// Create message queue for offline message persistence
-	queuePath := fmt.Sprintf("./data/relay-%d-queue.db", *port)
-	// Create data directory if it doesn't exist
-	if err := os.MkdirAll("./data", 0755); err != nil {
-		log.Fatalf("Failed to create data directory: %v", err)
+	family, err := network.ParseAddressFamily(*addrFamily)
+	if err != nil {
+		log.Fatalf("Invalid -address-family: %v", err)
+	}
+	hosts, err := network.ParseListenHosts(*listenHosts)
+	if err != nil {
+		log.Fatalf("Invalid -listen: %v", err)
+	}
+	relay.SetListenConfig(network.ListenConfig{Hosts: hosts, Family: family, WebSocketPort: *wsPort})
+
+	relay.SetConnectionLimits(network.ConnectionLimits{
+		HeaderTimeout:  *headerTimeout,
+		IdleTimeout:    *idleTimeout,
+		MinPayloadRate: *minPayloadRate,
+		MaxHalfOpen:    *maxHalfOpen,
+	})
+
+	if *forwardRate > 0 || *sourceRate > 0 {
+		relay.EnableForwardRateLimits(network.ForwardRateLimits{
+			ConnectionRate:  *forwardRate,
+			ConnectionBurst: *forwardBurst,
+			SourceRate:      *sourceRate,
+			SourceBurst:     *sourceBurst,
+		})
+	}
+
+	if *uniformRecords {
+		relay.EnableUniformRecords(network.UniformRecordConfig{MaxJitter: *recordJitter})
+	}
+
+	if *enableTLS || *requireTLS {
+		cert, err := loadTLSCertificate(*tlsCert, *tlsKey, privateKey)
+		if err != nil {
+			log.Fatalf("Failed to set up TLS: %v", err)
+		}
+		relay.EnableTLS(cert, *requireTLS)
+	}
+
+	relay.SetResumeTicketLifetime(*resumeLifetime)
+
+	if *meshPeerRate > 0 || *meshTotalRate > 0 {
+		shaping := network.DefaultMeshShapingConfig()
+		shaping.PeerRate = *meshPeerRate
+		shaping.TotalRate = *meshTotalRate
+		relay.EnableMeshShaping(shaping)
+	}
+
+	if *meshRouting {
+		if *routeHops < 1 || *routeHops > 255 {
+			log.Fatalf("Invalid -route-hops: must be between 1 and 255")
+		}
+		routing := network.DefaultMeshRoutingConfig()
+		routing.HopLimit = uint8(*routeHops)
+		relay.EnableMeshRouting(routing)
+	}
+
+	if *tenantsFile != "" {
+		tenants, err := network.LoadTenantConfigs(*tenantsFile)
+		if err != nil {
+			log.Fatalf("Invalid -tenants: %v", err)
+		}
+		for _, tenant := range tenants {
+			if err := relay.AddTenant(tenant); err != nil {
+				log.Fatalf("Invalid -tenants: %v", err)
+			}
+		}
+		log.Printf("✓ Serving %d tenants", len(tenants))
+	}
+
+	if *botsFile != "" {
+		bots, err := network.LoadBotConfigs(*botsFile)
+		if err != nil {
+			log.Fatalf("Invalid -bots: %v", err)
+		}
+		for _, bot := range bots {
+			if err := relay.RegisterBot(bot); err != nil {
+				log.Fatalf("Invalid -bots: %v", err)
+			}
+		}
+		log.Printf("✓ %d bot accounts registered", len(bots))
+	}
+
+	if *policyFile != "" {
+		policy, err := network.LoadRelayPolicy(*policyFile)
+		if err != nil {
+			log.Fatalf("Invalid -policy: %v", err)
+		}
+		relay.SetRelayPolicy(policy)
+	}
+
+	if *mediaDirFile != "" {
+		dir, err := network.LoadMediaDirectory(*mediaDirFile)
+		if err != nil {
+			log.Fatalf("Invalid -media-directory: %v", err)
+		}
+		relay.SetMediaDirectory(dir)
+	}
+
+	if *stakeFile != "" {
+		source, err := network.LoadStakeFile(*stakeFile)
+		if err != nil {
+			log.Fatalf("Invalid -stake-file: %v", err)
+		}
+		cfg := network.DefaultQueuePriorityConfig()
+		if *priorityFile != "" {
+			if cfg, err = network.LoadQueuePriorityConfig(*priorityFile); err != nil {
+				log.Fatalf("Invalid -queue-priority: %v", err)
+			}
+		}
+		relay.EnableQueuePriority(source, cfg)
 	}
-	messageQueue, err := storage.NewRelayMessageQueue(queuePath, 30*24*time.Hour) // 30 days TTL
+
+	var updates *updateChecker
+	if *updateURL != "" {
+		if *updateInterval <= 0 {
+			log.Fatal("Error: -update-interval must be positive")
+		}
+		updates, err = newUpdateChecker(*updateURL, *updateKey, *updateInterval, *selfUpdate)
+		if err != nil {
+			log.Fatalf("Failed to enable update checks: %v", err)
+		}
+	}
+
+	// Relay counts are saved locally and recorded on-chain in batches
+	registry, err := dialRegistry()
 	if err != nil {
-		log.Fatalf("Failed to create message queue: %v", err)
+		log.Fatalf("Failed to connect to the registry contract: %v", err)
+	}
+	var reporter *blockchain.Reporter
+	var relayCounts *storage.RelayCountStore
+	if registry != nil {
+		reporter, relayCounts, err = newReporter(registry, relay)
+		if err != nil {
+			log.Fatalf("Failed to open relay count store: %v", err)
+		}
+		relay.OnMessageRelayed = reporter.MessageRelayed
+	}
+
+	// Create message queue for offline message persistence
+	var messageQueue *storage.RelayMessageQueue
+	if *queueDSN != "" {
+		messageQueue, err = storage.NewPostgresRelayMessageQueue(*queueDSN, 30*24*time.Hour) // 30 days TTL
+		if err != nil {
+			log.Fatalf("Failed to connect to shared message queue: %v", err)
+		}
+		log.Println("📬 Shared PostgreSQL message queue initialized (TTL: 30 days)")
+	} else {
+		queuePath := fmt.Sprintf("./data/relay-%d-queue.db", *port)
+		// Create data directory if it doesn't exist
+		if err := os.MkdirAll("./data", 0755); err != nil {
+			log.Fatalf("Failed to create data directory: %v", err)
+		}
+		messageQueue, err = storage.NewRelayMessageQueue(queuePath, 30*24*time.Hour) // 30 days TTL
+		if err != nil {
+			log.Fatalf("Failed to create message queue: %v", err)
+		}
+		log.Printf("📬 Message queue initialized at %s (TTL: 30 days)", queuePath)
 	}
 	relay.AttachMessageQueue(messageQueue)
-	log.Printf("📬 Message queue initialized at %s (TTL: 30 days)", queuePath)
+
+	// Queue migration: export for a replacement relay, or import from the relay being replaced
+	if done := runQueueMigration(relay); done {
+		messageQueue.Close()
+		return
+	}
+
+	// Delivery proofs back the relay counts reported for rewards
+	var deliveryProofs *storage.DeliveryProofStore
+	if *proofWindow > 0 {
+		proofPath := fmt.Sprintf("./data/relay-%d-proofs.db", *port)
+		if err := os.MkdirAll("./data", 0755); err != nil {
+			log.Fatalf("Failed to create data directory: %v", err)
+		}
+		deliveryProofs, err = storage.OpenDeliveryProofStore(proofPath, relay.Address, relay.PrivateKey, *proofWindow)
+		if err != nil {
+			log.Fatalf("Failed to open delivery proof store: %v", err)
+		}
+		relay.AttachDeliveryProofs(deliveryProofs)
+	}
+
+	// Key bundles live beside the queue so every cluster node hands out the same prekeys
+	var keyBundleStore *storage.KeyBundleStore
+	if *keyBundles {
+		bundleDSN := *queueDSN
+		if bundleDSN == "" {
+			bundleDSN = fmt.Sprintf("./data/relay-%d-keybundles.db", *port)
+			if err := os.MkdirAll("./data", 0755); err != nil {
+				log.Fatalf("Failed to create data directory: %v", err)
+			}
+		}
+		keyBundleStore, err = storage.OpenKeyBundleStore(bundleDSN)
+		if err != nil {
+			log.Fatalf("Failed to open key bundle store: %v", err)
+		}
+		relay.AttachKeyBundleStore(keyBundleStore)
+	}
+
+	if *exportProofs != "" {
+		count, err := relay.ExportDeliveryProofsToFile(*exportProofs, 0, 0)
+		if err != nil {
+			log.Fatalf("Failed to export delivery proofs: %v", err)
+		}
+		log.Printf("✓ Exported %d delivery proofs to %s", count, *exportProofs)
+		deliveryProofs.Close()
+		messageQueue.Close()
+		return
+	}
+
+	// Join a relay cluster sharing the queue
+	if *clusterNode != "" {
+		if *queueDSN == "" {
+			log.Fatal("Error: -cluster-node requires a shared queue (-queue-dsn)")
+		}
+		nodes, err := network.ParseClusterNodes(*clusterNodes)
+		if err != nil {
+			log.Fatalf("Invalid -cluster-nodes: %v", err)
+		}
+		if err := relay.EnableCluster(network.ClusterConfig{NodeID: *clusterNode, Nodes: nodes}); err != nil {
+			log.Fatalf("Failed to enable cluster mode: %v", err)
+		}
+	}
+
+	// A warm standby replicates its primary until it fails, then starts up in its place
+	tookOver := false
+	if *standbyOf != "" {
+		if *clusterNode != "" {
+			log.Fatal("Error: -standby-of cannot be combined with -cluster-node")
+		}
+		standby := network.StandbyConfig{Primary: *standbyOf, SyncInterval: *standbyEvery, FailAfter: *standbyFails}
+		if err := relay.RunStandby(context.Background(), standby); err != nil {
+			log.Fatalf("Standby failed: %v", err)
+		}
+		tookOver = true
+	}
 
 	// Start relay server
 	if err := relay.Start(); err != nil {
 		log.Fatalf("Failed to start relay server: %v", err)
 	}
 
-	log.Printf("✓ Relay server listening on port %d", *port)
+	log.Printf("✓ Relay server listening on port %d (%s)", relay.Port, family)
+
+	if *replicateOn != "" {
+		if err := relay.EnableReplication(*replicateOn); err != nil {
+			log.Fatalf("Failed to enable replication: %v", err)
+		}
+	}
+
+	// Forward the port on a home router so peers outside the LAN can reach the relay
+	var portMapper *network.PortMapper
+	if *enableNAT {
+		portMapper = network.NewPortMapper(relay, network.DefaultPortMappingLease)
+		if err := portMapper.Start(); err != nil {
+			log.Printf("⚠️  Automatic port forwarding unavailable: %v", err)
+			log.Printf("   If this relay is behind a router, forward TCP port %d to it manually", relay.Port)
+			portMapper = nil
+		} else {
+			log.Printf("✓ Port forwarding enabled (public address: %s)", portMapper.ExternalAddress())
+		}
+	} else {
+		log.Println("⚠️  Automatic port forwarding disabled")
+	}
 
 	// Start auto-mesh formation if enabled
 	var meshManager *network.MeshManager
@@ -95,21 +422,111 @@ func main() {
 		log.Println("⚠️  Auto-mesh formation disabled")
 	}
 
-	// TODO: Register on blockchain
-	log.Println("⏳ Registering on blockchain...")
-	log.Printf("   Operator: %s", *operatorAddr)
-	log.Printf("   Contract: %s", *contractAddr)
-	log.Printf("   RPC URL: %s", *rpcURL)
-	log.Println("   (Blockchain integration coming soon)")
+	// Start bandwidth self-test so heartbeats and DHT metadata carry measured capacity
+	var prober *network.BandwidthProber
+	if *probeInterval > 0 {
+		prober = network.NewBandwidthProber(relay, network.DefaultProbePeers, *probeInterval)
+		if err := prober.Start(); err != nil {
+			log.Fatalf("Failed to start bandwidth prober: %v", err)
+		}
+		log.Printf("✓ Bandwidth self-test enabled (every %v)", *probeInterval)
+	} else {
+		log.Println("⚠️  Bandwidth self-test disabled")
+	}
+
+	// Check for new releases so the relay doesn't keep running a vulnerable version
+	if updates != nil {
+		go updates.run()
+		if *selfUpdate {
+			log.Printf("✓ Automatic updates enabled (every %v)", *updateInterval)
+		} else {
+			log.Printf("✓ Update checks enabled (every %v)", *updateInterval)
+		}
+	}
+
+	// Register after port mapping so the registry gets the public endpoint
+	if registry != nil {
+		if err := registerOnChain(registry, relay); err != nil {
+			log.Fatalf("Failed to register on blockchain: %v", err)
+		}
+		log.Println("✓ Registered on blockchain")
+
+		if tookOver {
+			if err := announceTakeover(registry, relay); err != nil {
+				log.Printf("⚠️  Failed to announce takeover in the registry: %v", err)
+			} else {
+				log.Println("✓ Registry now points at this relay")
+			}
+		}
+
+		batch := blockchain.BatchConfig{Interval: *reportInterval, MaxPending: *reportBatch}
+		if err := reporter.Start(batch); err != nil {
+			log.Fatalf("Failed to start on-chain reporting: %v", err)
+		}
+	} else {
+		log.Println("⚠️  On-chain reporting disabled (no -eth-key)")
+	}
 
 	// Start heartbeat loop
 	go startHeartbeatLoop(relay, meshManager)
 
 	// Print status
-	printStatus(relay, meshManager)
+	printStatus(relay, meshManager, reporter)
 
 	// Wait for shutdown signal
-	waitForShutdown(relay, meshManager, messageQueue)
+	waitForShutdown(relay, meshManager, prober, portMapper, messageQueue, deliveryProofs, keyBundleStore, reporter, relayCounts)
+}
+
+// runQueueMigration handles -import-queue, -export-queue and -moved-to
+// Returns true if the relay should exit (export without a redirect target)
+func runQueueMigration(relay *network.RelayServer) bool {
+	if *exportQueue == "" && *importQueue == "" && *movedTo == "" {
+		return false
+	}
+
+	passphrase := *queuePass
+	if passphrase == "" {
+		passphrase = os.Getenv("ZENTALK_QUEUE_PASSPHRASE")
+	}
+
+	if *importQueue != "" {
+		if passphrase == "" {
+			log.Fatal("Error: -queue-passphrase is required to import a queue")
+		}
+		count, err := relay.ImportQueueFromFile(*importQueue, passphrase)
+		if err != nil {
+			log.Fatalf("Failed to import queue: %v", err)
+		}
+		log.Printf("✓ Imported %d queued messages from %s", count, *importQueue)
+	}
+
+	if *exportQueue != "" {
+		if passphrase == "" {
+			log.Fatal("Error: -queue-passphrase is required to export a queue")
+		}
+		count, err := relay.ExportQueueToFile(*exportQueue, passphrase)
+		if err != nil {
+			log.Fatalf("Failed to export queue: %v", err)
+		}
+		log.Printf("✓ Exported %d queued messages to %s", count, *exportQueue)
+	}
+
+	if *movedTo != "" {
+		addrBytes, err := hex.DecodeString(*movedToAddr)
+		if err != nil || len(addrBytes) != 20 {
+			log.Fatal("Error: -moved-to-address must be the replacement relay's 20-byte hex address")
+		}
+		var newRelay [20]byte
+		copy(newRelay[:], addrBytes)
+
+		if err := relay.SetMigrationTarget(newRelay, *movedTo); err != nil {
+			log.Fatalf("Invalid -moved-to: %v", err)
+		}
+		return false
+	}
+
+	// Export only: the old relay has nothing left to do
+	return *exportQueue != ""
 }
 
 func printBanner() {
@@ -173,6 +590,17 @@ func loadOrGenerateKey(keyPath string, generate bool) (*rsa.PrivateKey, error) {
 	return privateKey, nil
 }
 
+// loadTLSCertificate loads the -tls-cert/-tls-key pair, or makes a self-signed one for the relay key
+func loadTLSCertificate(certPath, keyPath string, privateKey *rsa.PrivateKey) (tls.Certificate, error) {
+	if certPath == "" && keyPath == "" {
+		return network.IdentityCertificate(privateKey)
+	}
+	if certPath == "" || keyPath == "" {
+		return tls.Certificate{}, fmt.Errorf("-tls-cert and -tls-key must be given together")
+	}
+	return tls.LoadX509KeyPair(certPath, keyPath)
+}
+
 func startHeartbeatLoop(relay *network.RelayServer, meshManager *network.MeshManager) {
 	ticker := time.NewTicker(heartbeatInterval)
 	defer ticker.Stop()
@@ -184,6 +612,12 @@ func startHeartbeatLoop(relay *network.RelayServer, meshManager *network.MeshMan
 		log.Println("💓 Heartbeat")
 		log.Printf("   Messages relayed: %v", stats["messages_relayed"])
 		log.Printf("   Connected peers: %v", stats["connected_peers"])
+		if address, ok := stats["external_address"]; ok {
+			log.Printf("   Public address: %v (via %v)", address, stats["port_mapping"])
+		}
+		if bandwidth, ok := stats["bandwidth_kbps"]; ok {
+			log.Printf("   Measured bandwidth: %v kbps (latency: %v ms)", bandwidth, stats["probe_latency_ms"])
+		}
 
 		// Show mesh status if enabled
 		if meshManager != nil {
@@ -193,18 +627,10 @@ func startHeartbeatLoop(relay *network.RelayServer, meshManager *network.MeshMan
 		}
 
 		log.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-
-		// TODO: Send heartbeat to blockchain
-		// blockchain.SendHeartbeat()
-
-		// TODO: Report relay count to blockchain
-		// if messagesRelayed > 0 {
-		//     blockchain.RecordRelays(messagesRelayed)
-		// }
 	}
 }
 
-func printStatus(relay *network.RelayServer, meshManager *network.MeshManager) {
+func printStatus(relay *network.RelayServer, meshManager *network.MeshManager, reporter *blockchain.Reporter) {
 	stats := relay.GetStats()
 
 	fmt.Println()
@@ -212,8 +638,17 @@ func printStatus(relay *network.RelayServer, meshManager *network.MeshManager) {
 	fmt.Println("🚀 Relay Server Status")
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 	fmt.Printf("   Status: ✅ RUNNING\n")
+	fmt.Printf("   Version: v%s\n", version)
 	fmt.Printf("   Port: %d\n", *port)
+	if address, ok := stats["external_address"]; ok {
+		fmt.Printf("   Public address: %v (via %v)\n", address, stats["port_mapping"])
+	}
 	fmt.Printf("   Operator: %s\n", *operatorAddr)
+	fmt.Printf("   Exit policy: %s\n", relay.GetExitPolicy())
+	fmt.Printf("   Features: %s\n", relay.Features().Active())
+	if node, ok := stats["cluster_node"]; ok {
+		fmt.Printf("   Cluster: node %v of %v\n", node, stats["cluster_size"])
+	}
 	fmt.Printf("   Messages relayed: %v\n", stats["messages_relayed"])
 	fmt.Printf("   Connected peers: %v\n", stats["connected_peers"])
 
@@ -235,13 +670,21 @@ func printStatus(relay *network.RelayServer, meshManager *network.MeshManager) {
 	fmt.Println()
 	fmt.Println("💡 Keep this running to earn rewards!")
 	fmt.Println("   Heartbeat: Every 5 minutes")
-	fmt.Println("   Rewards: Automatic")
+	if reporter != nil {
+		if *reportBatch > 0 {
+			fmt.Printf("   Rewards: Relay counts recorded on-chain every %v (or every %d messages)\n", *reportInterval, *reportBatch)
+		} else {
+			fmt.Printf("   Rewards: Relay counts recorded on-chain every %v\n", *reportInterval)
+		}
+	} else {
+		fmt.Println("   Rewards: ⚠️  Not reported (start with -eth-key)")
+	}
 	fmt.Println()
 	fmt.Println("Press Ctrl+C to stop")
 	fmt.Println()
 }
 
-func waitForShutdown(relay *network.RelayServer, meshManager *network.MeshManager, messageQueue *storage.RelayMessageQueue) {
+func waitForShutdown(relay *network.RelayServer, meshManager *network.MeshManager, prober *network.BandwidthProber, portMapper *network.PortMapper, messageQueue *storage.RelayMessageQueue, deliveryProofs *storage.DeliveryProofStore, keyBundles *storage.KeyBundleStore, reporter *blockchain.Reporter, relayCounts *storage.RelayCountStore) {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
@@ -256,11 +699,37 @@ func waitForShutdown(relay *network.RelayServer, meshManager *network.MeshManage
 		log.Println("✓ Mesh manager stopped")
 	}
 
+	if prober != nil {
+		prober.Stop()
+		log.Println("✓ Bandwidth prober stopped")
+	}
+
+	// Remove the router mapping so the port isn't left open
+	if portMapper != nil {
+		portMapper.Stop()
+		log.Println("✓ Port forwarding removed")
+	}
+
 	// Stop relay server
 	if err := relay.Stop(); err != nil {
 		log.Printf("Error stopping relay: %v", err)
 	}
 
+	// Record the last batch on-chain; if that fails it stays in the count store for the next run
+	if reporter != nil {
+		if err := reporter.Stop(); err != nil {
+			log.Printf("Error saving relay count: %v", err)
+		}
+		if reporter.Pending() > 0 {
+			reportOnChain(reporter)
+		}
+	}
+	if relayCounts != nil {
+		if err := relayCounts.Close(); err != nil {
+			log.Printf("Error closing relay count store: %v", err)
+		}
+	}
+
 	// Close message queue database
 	if messageQueue != nil {
 		if err := messageQueue.Close(); err != nil {
@@ -270,13 +739,25 @@ func waitForShutdown(relay *network.RelayServer, meshManager *network.MeshManage
 		}
 	}
 
+	if deliveryProofs != nil {
+		if err := deliveryProofs.Close(); err != nil {
+			log.Printf("Error closing delivery proofs: %v", err)
+		} else {
+			log.Println("✓ Delivery proofs closed")
+		}
+	}
+
+	if keyBundles != nil {
+		if err := keyBundles.Close(); err != nil {
+			log.Printf("Error closing key bundles: %v", err)
+		} else {
+			log.Println("✓ Key bundles closed")
+		}
+	}
+
 	log.Println("✓ Relay server stopped")
 	log.Println("Goodbye! 👋")
+	logs.Close()
 	os.Exit(0)
 }
 
-// TODO: Implement these functions with actual blockchain integration
-// func registerOnBlockchain() error
-// func sendHeartbeat() error
-// func recordRelays(count uint64) error
-// func claimRewards() error
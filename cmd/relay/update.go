@@ -0,0 +1,183 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/release"
+)
+
+// version is this build's release version; release builds set it with
+// -ldflags "-X main.version=1.2.0"
+var version = "1.0.0"
+
+const (
+	maxManifestSize = 1 << 20   // 1 MB
+	maxBinarySize   = 256 << 20 // 256 MB
+)
+
+// updateChecker polls the signed release manifest and tells the operator about new versions
+type updateChecker struct {
+	url        string
+	key        ed25519.PublicKey
+	interval   time.Duration
+	selfUpdate bool
+	http       *http.Client
+	staged     string // Version already swapped in, waiting for a restart
+	notified   string // Version the operator was last told about
+}
+
+// newUpdateChecker creates a checker for a manifest URL signed by keyHex
+func newUpdateChecker(url, keyHex string, interval time.Duration, selfUpdate bool) (*updateChecker, error) {
+	key, err := hex.DecodeString(keyHex)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("-update-key must be a %d-byte hex Ed25519 public key", ed25519.PublicKeySize)
+	}
+	if _, err := release.CompareVersions(version, version); err != nil {
+		return nil, fmt.Errorf("this build's version: %w", err)
+	}
+
+	return &updateChecker{
+		url:        url,
+		key:        ed25519.PublicKey(key),
+		interval:   interval,
+		selfUpdate: selfUpdate,
+		http:       &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+// run checks now and then every interval; it never returns
+func (u *updateChecker) run() {
+	ticker := time.NewTicker(u.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := u.check(); err != nil {
+			log.Printf("⚠️  Update check failed: %v", err)
+		}
+		<-ticker.C
+	}
+}
+
+// check fetches and verifies the manifest, then notifies or stages an update
+func (u *updateChecker) check() error {
+	data, err := u.fetch(u.url, maxManifestSize)
+	if err != nil {
+		return fmt.Errorf("failed to fetch manifest: %w", err)
+	}
+
+	var signed release.SignedManifest
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	manifest, err := signed.Verify(u.key)
+	if err != nil {
+		return err
+	}
+
+	if manifest.MinVersion != "" {
+		if cmp, _ := release.CompareVersions(version, manifest.MinVersion); cmp < 0 {
+			log.Printf("🚨 Relay v%s has known vulnerabilities: upgrade to v%s or later", version, manifest.MinVersion)
+		}
+	}
+
+	if cmp, _ := release.CompareVersions(manifest.Version, version); cmp <= 0 {
+		return nil
+	}
+	if manifest.Version == u.staged {
+		return nil
+	}
+
+	if u.notified != manifest.Version {
+		log.Printf("⬆️  Relay v%s is available (running v%s)", manifest.Version, version)
+		if manifest.Notes != "" {
+			log.Printf("   %s", manifest.Notes)
+		}
+		u.notified = manifest.Version
+	}
+
+	if !u.selfUpdate {
+		return nil
+	}
+	if err := u.stage(manifest); err != nil {
+		return fmt.Errorf("failed to stage v%s: %w", manifest.Version, err)
+	}
+	u.staged = manifest.Version
+	return nil
+}
+
+// stage downloads the new binary and swaps it in place of the running one
+// The old binary is kept next to it with a .old suffix for rollback. The
+// running process is left alone: the new version starts on the next restart.
+func (u *updateChecker) stage(manifest *release.Manifest) error {
+	bin, err := manifest.Binary(runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return err
+	}
+
+	data, err := u.fetch(bin.URL, maxBinarySize)
+	if err != nil {
+		return fmt.Errorf("failed to download binary: %w", err)
+	}
+	if err := bin.Check(data); err != nil {
+		return err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+
+	// Write beside the executable so the renames stay on one filesystem
+	staged := exe + ".new"
+	if err := os.WriteFile(staged, data, 0755); err != nil {
+		return fmt.Errorf("failed to write staged binary: %w", err)
+	}
+	if err := os.Rename(exe, exe+".old"); err != nil {
+		os.Remove(staged)
+		return fmt.Errorf("failed to back up current binary: %w", err)
+	}
+	if err := os.Rename(staged, exe); err != nil {
+		os.Rename(exe+".old", exe)
+		os.Remove(staged)
+		return fmt.Errorf("failed to install new binary: %w", err)
+	}
+
+	log.Printf("✓ Relay v%s installed at %s (previous binary kept as %s.old)", manifest.Version, exe, exe)
+	log.Println("   Restart the relay to run the new version")
+	return nil
+}
+
+// fetch GETs a URL, refusing bodies larger than limit
+func (u *updateChecker) fetch(url string, limit int64) ([]byte, error) {
+	resp, err := u.http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: HTTP %d", url, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%s: response larger than %d bytes", url, limit)
+	}
+	return data, nil
+}
@@ -0,0 +1,182 @@
+// Command smtp-gateway turns authenticated SMTP submissions into ZenTalk direct messages
+//
+//	smtp-gateway -relay relay.example.org:8080 -key ./keys/gateway.pem -address <hex> \
+//	  -config gateway.json -tls-cert cert.pem -tls-key key.pem \
+//	  -smtp-relay mail.example.org:587 -smtp-user gateway
+//
+// gateway.json maps aliases under the gateway's mail domain to ZenTalk
+// addresses and lists the accounts that may submit mail (see smtpgw.Config):
+//
+//	{"domain": "zentalk.example.org",
+//	 "users": [{"username": "alerts", "password_hash": "$2y$10$...", "recipients": ["oncall"]}],
+//	 "recipients": [{"alias": "oncall", "address": "<hex>", "public_key": "keys/oncall.pub",
+//	                 "email": "oncall@example.org"}]}
+//
+// Text messages a mapped address sends to the gateway are emailed to its
+// "email" mailbox through -smtp-relay.
+package main
+
+import (
+	"crypto/tls"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/network"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+	"github.com/ZentaChain/zentalk-node/pkg/smtpgw"
+)
+
+var (
+	listenAddr    = flag.String("listen", ":2525", "SMTP submission listen address")
+	hostname      = flag.String("hostname", "localhost", "Name announced in the SMTP greeting")
+	configPath    = flag.String("config", "gateway.json", "Gateway config file")
+	tlsCert       = flag.String("tls-cert", "", "TLS certificate for STARTTLS")
+	tlsKey        = flag.String("tls-key", "", "TLS private key for STARTTLS")
+	insecureAuth  = flag.Bool("insecure-auth", false, "Allow AUTH without TLS (testing only)")
+	relayAddr     = flag.String("relay", "localhost:8080", "Relay to connect to (host:port)")
+	relayID       = flag.String("relay-address", "", "Relay address (hex); with -relay-key, messages are routed through the connected relay only")
+	relayKey      = flag.String("relay-key", "", "Relay public key file (PEM)")
+	keyPath       = flag.String("key", "./keys/gateway.pem", "Private key file")
+	address       = flag.String("address", "", "Our address (hex, required)")
+	asBot         = flag.Bool("bot", false, "Run as a bot account (see zentalk-admin bot-key)")
+	botLabel      = flag.String("bot-label", "", "Label the bot API key was derived with (zentalk-admin bot-key -label)")
+	smtpRelay     = flag.String("smtp-relay", "", "Mail server for outbound email (host:port; empty disables it)")
+	smtpUser      = flag.String("smtp-user", "", "Outbound mail server username (optional)")
+	smtpPassword  = flag.String("smtp-password", os.Getenv("ZENTALK_SMTP_PASSWORD"), "Outbound mail server password (or set ZENTALK_SMTP_PASSWORD)")
+	maxMessageLen = flag.Int("max-message-bytes", smtpgw.DefaultServerConfig().MaxMessageBytes, "Largest email accepted")
+)
+
+func main() {
+	flag.Parse()
+
+	if *address == "" {
+		log.Fatal("Error: -address is required")
+	}
+	var self protocol.Address
+	if err := decodeHex(*address, self[:]); err != nil {
+		log.Fatalf("Invalid -address: %v", err)
+	}
+
+	keyPEM, err := crypto.LoadKeyFromFile(*keyPath)
+	if err != nil {
+		log.Fatalf("Failed to read key: %v", err)
+	}
+	key, err := crypto.ImportPrivateKeyPEM(keyPEM)
+	if err != nil {
+		log.Fatalf("Invalid key: %v", err)
+	}
+
+	config, err := smtpgw.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("Invalid -config: %v", err)
+	}
+
+	serverConfig := smtpgw.DefaultServerConfig()
+	serverConfig.AllowInsecureAuth = *insecureAuth
+	serverConfig.MaxMessageBytes = *maxMessageLen
+	serverConfig.Hostname = *hostname
+	if *tlsCert != "" || *tlsKey != "" {
+		cert, err := tls.LoadX509KeyPair(*tlsCert, *tlsKey)
+		if err != nil {
+			log.Fatalf("Invalid -tls-cert/-tls-key: %v", err)
+		}
+		serverConfig.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	} else if !*insecureAuth {
+		log.Fatal("Error: -tls-cert and -tls-key are required unless -insecure-auth is set")
+	}
+
+	client := network.NewClient(key)
+	client.Address = self
+	if *asBot {
+		if err := client.EnableBotMode(*botLabel); err != nil {
+			log.Fatalf("Failed to enable bot mode: %v", err)
+		}
+	}
+
+	var relayPath []*crypto.RelayInfo
+	if *relayID != "" || *relayKey != "" {
+		hop, err := loadRelayInfo(*relayID, *relayKey)
+		if err != nil {
+			log.Fatalf("Invalid -relay-address/-relay-key: %v", err)
+		}
+		relayPath = []*crypto.RelayInfo{hop}
+	}
+
+	var mailer smtpgw.Mailer
+	if *smtpRelay != "" {
+		m := &smtpgw.SMTPMailer{Addr: *smtpRelay}
+		if *smtpUser != "" {
+			host, _, err := net.SplitHostPort(*smtpRelay)
+			if err != nil {
+				log.Fatalf("Invalid -smtp-relay: %v", err)
+			}
+			m.Auth = smtp.PlainAuth("", *smtpUser, *smtpPassword, host)
+		}
+		mailer = m
+	}
+
+	gateway, err := smtpgw.NewGateway(config, smtpgw.NewClientSender(client, relayPath), mailer)
+	if err != nil {
+		log.Fatalf("Invalid -config: %v", err)
+	}
+	client.OnMessageReceived = gateway.HandleDirectMessage
+
+	if err := client.ConnectToRelay(*relayAddr); err != nil {
+		log.Fatalf("Failed to connect to relay: %v", err)
+	}
+	log.Printf("✓ Connected to %s as %x", *relayAddr, self[:8])
+
+	server := smtpgw.NewServer(gateway, serverConfig)
+	go func() {
+		if err := server.ListenAndServe(*listenAddr); err != nil {
+			log.Fatalf("SMTP server failed: %v", err)
+		}
+	}()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	<-sigChan
+
+	log.Println("Shutting down...")
+	server.Close()
+	client.Disconnect()
+}
+
+// loadRelayInfo builds the one-hop path through the connected relay
+func loadRelayInfo(addrHex, keyFile string) (*crypto.RelayInfo, error) {
+	info := &crypto.RelayInfo{}
+	if err := decodeHex(addrHex, info.Address[:]); err != nil {
+		return nil, err
+	}
+
+	pemData, err := crypto.LoadKeyFromFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if info.PublicKey, err = crypto.ImportPublicKeyPEM(pemData); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// decodeHex decodes a hex string (optional 0x prefix) that must fill dst exactly
+func decodeHex(s string, dst []byte) error {
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		return err
+	}
+	if len(b) != len(dst) {
+		return fmt.Errorf("want %d bytes of hex, got %d", len(dst), len(b))
+	}
+	copy(dst, b)
+	return nil
+}
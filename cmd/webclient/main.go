@@ -0,0 +1,205 @@
+//go:build js && wasm
+
+// Command webclient is the ZenTalk browser client, compiled to WebAssembly
+//
+//	GOOS=js GOARCH=wasm go build -o zentalk.wasm ./cmd/webclient
+//	cp "$(go env GOROOT)/lib/wasm/wasm_exec.js" .
+//
+// Once loaded with wasm_exec.js it installs a global zentalk object:
+//
+//	await zentalk.connect("wss://relay.example.org:9443/ws", addressHex, privateKeyPEM)
+//	zentalk.onmessage = (msg) => console.log(msg.from, msg.text)
+//	await zentalk.sendText(toHex, toPublicKeyPEM, "hi")
+//	zentalk.close()
+//
+// Keys are PEM (PKCS#1 or PKCS#8, e.g. exported from WebCrypto). sendText
+// takes an optional relay path as a last argument: an array of
+// {address, publicKey} objects; by default messages go through the connected relay.
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"syscall/js"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+	"github.com/ZentaChain/zentalk-node/pkg/webclient"
+)
+
+// connectTimeout bounds dialing and the handshake
+const connectTimeout = 15 * time.Second
+
+// storageNamespace prefixes the client's localStorage keys
+const storageNamespace = "zentalk"
+
+var client *webclient.Client
+
+func main() {
+	api := js.Global().Get("Object").New()
+	api.Set("connect", js.FuncOf(connect))
+	api.Set("sendText", js.FuncOf(sendText))
+	api.Set("close", js.FuncOf(closeClient))
+	js.Global().Set("zentalk", api)
+
+	// Keep the Go runtime alive for callbacks
+	select {}
+}
+
+// connect(relayURL, address, privateKeyPEM) -> Promise
+func connect(_ js.Value, args []js.Value) any {
+	return promise(func() (any, error) {
+		if len(args) < 3 {
+			return nil, fmt.Errorf("connect(relayURL, address, privateKeyPEM)")
+		}
+
+		address, err := parseAddress(args[1].String())
+		if err != nil {
+			return nil, err
+		}
+		key, err := crypto.ImportPrivateKeyPEM([]byte(args[2].String()))
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key: %w", err)
+		}
+
+		store, err := webclient.NewStorage(storageNamespace + "/" + hex.EncodeToString(address[:]))
+		if err != nil {
+			store = nil // Private browsing: sequence numbers last for this page only
+		}
+
+		c, err := webclient.New(address, key, store)
+		if err != nil {
+			return nil, err
+		}
+		c.OnMessage = dispatchMessage
+		c.OnDisconnect = func(err error) {
+			if handler := js.Global().Get("zentalk").Get("ondisconnect"); handler.Type() == js.TypeFunction {
+				handler.Invoke(err.Error())
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+		defer cancel()
+		if err := c.Connect(ctx, args[0].String()); err != nil {
+			return nil, err
+		}
+
+		if client != nil {
+			client.Close()
+		}
+		client = c
+		return nil, nil
+	})
+}
+
+// sendText(to, publicKeyPEM, text, [relayPath]) -> Promise
+func sendText(_ js.Value, args []js.Value) any {
+	return promise(func() (any, error) {
+		if client == nil {
+			return nil, webclient.ErrNotConnected
+		}
+		if len(args) < 3 {
+			return nil, fmt.Errorf("sendText(to, publicKeyPEM, text, [relayPath])")
+		}
+
+		to, err := parseAddress(args[0].String())
+		if err != nil {
+			return nil, err
+		}
+		pubKey, err := crypto.ImportPublicKeyPEM([]byte(args[1].String()))
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key: %w", err)
+		}
+
+		var path []*crypto.RelayInfo
+		if len(args) > 3 && args[3].Type() == js.TypeObject {
+			if path, err = parseRelayPath(args[3]); err != nil {
+				return nil, err
+			}
+		}
+
+		return nil, client.SendText(to, pubKey, args[2].String(), path)
+	})
+}
+
+// close()
+func closeClient(js.Value, []js.Value) any {
+	if client != nil {
+		client.Close()
+		client = nil
+	}
+	return nil
+}
+
+// dispatchMessage passes a received message to zentalk.onmessage
+func dispatchMessage(msg *protocol.DirectMessage) {
+	handler := js.Global().Get("zentalk").Get("onmessage")
+	if handler.Type() != js.TypeFunction {
+		return
+	}
+
+	obj := js.Global().Get("Object").New()
+	obj.Set("from", hex.EncodeToString(msg.From[:]))
+	obj.Set("timestamp", float64(msg.Timestamp))
+	obj.Set("contentType", int(msg.ContentType))
+	if msg.ContentType == protocol.ContentTypeText {
+		obj.Set("text", string(msg.Content))
+	}
+	content := js.Global().Get("Uint8Array").New(len(msg.Content))
+	js.CopyBytesToJS(content, msg.Content)
+	obj.Set("content", content)
+
+	handler.Invoke(obj)
+}
+
+// promise runs fn on a goroutine and settles a JS Promise with its result
+// Blocking inside a JS callback would deadlock the event loop.
+func promise(fn func() (any, error)) js.Value {
+	executor := js.FuncOf(func(_ js.Value, args []js.Value) any {
+		resolve, reject := args[0], args[1]
+		go func() {
+			result, err := fn()
+			if err != nil {
+				reject.Invoke(js.Global().Get("Error").New(err.Error()))
+				return
+			}
+			resolve.Invoke(result)
+		}()
+		return nil
+	})
+	defer executor.Release()
+
+	return js.Global().Get("Promise").New(executor)
+}
+
+// parseRelayPath converts an array of {address, publicKey} objects
+func parseRelayPath(value js.Value) ([]*crypto.RelayInfo, error) {
+	path := make([]*crypto.RelayInfo, 0, value.Length())
+	for i := 0; i < value.Length(); i++ {
+		hop := value.Index(i)
+		address, err := parseAddress(hop.Get("address").String())
+		if err != nil {
+			return nil, fmt.Errorf("relay %d: %w", i, err)
+		}
+		pubKey, err := crypto.ImportPublicKeyPEM([]byte(hop.Get("publicKey").String()))
+		if err != nil {
+			return nil, fmt.Errorf("relay %d: invalid public key: %w", i, err)
+		}
+		path = append(path, &crypto.RelayInfo{Address: address, PublicKey: pubKey})
+	}
+	return path, nil
+}
+
+// parseAddress parses a 20-byte hex address, with or without 0x prefix
+func parseAddress(s string) (protocol.Address, error) {
+	var addr protocol.Address
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil || len(b) != len(addr) {
+		return addr, fmt.Errorf("invalid address %q: want 20 bytes of hex", s)
+	}
+	copy(addr[:], b)
+	return addr, nil
+}
@@ -0,0 +1,287 @@
+// Package blockchain reports relays to the on-chain registry contract
+//
+// A relay registers once with its protocol address, endpoint and region, then
+// sends a heartbeat and the number of messages it relayed every few minutes.
+// The registry credits the operator account that signed those transactions,
+// and operators claim the rewards from the same account.
+//
+// Storage nodes use the same contract to commit the digest of each closed
+// usage epoch, so the usage reports they export can be checked later.
+package blockchain
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// RegistryABI is the part of the relay registry contract's interface relays use
+const RegistryABI = `[
+	{"type":"function","name":"registerRelay","stateMutability":"nonpayable","inputs":[{"name":"relay","type":"bytes20"},{"name":"endpoint","type":"string"},{"name":"region","type":"string"}],"outputs":[]},
+	{"type":"function","name":"updateEndpoint","stateMutability":"nonpayable","inputs":[{"name":"relay","type":"bytes20"},{"name":"endpoint","type":"string"}],"outputs":[]},
+	{"type":"function","name":"isRegistered","stateMutability":"view","inputs":[{"name":"relay","type":"bytes20"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"function","name":"heartbeat","stateMutability":"nonpayable","inputs":[{"name":"relay","type":"bytes20"}],"outputs":[]},
+	{"type":"function","name":"recordRelays","stateMutability":"nonpayable","inputs":[{"name":"relay","type":"bytes20"},{"name":"count","type":"uint256"}],"outputs":[]},
+	{"type":"function","name":"pendingRewards","stateMutability":"view","inputs":[{"name":"operator","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"claimRewards","stateMutability":"nonpayable","inputs":[],"outputs":[]},
+	{"type":"function","name":"commitUsage","stateMutability":"nonpayable","inputs":[{"name":"epochStart","type":"uint64"},{"name":"digest","type":"bytes32"}],"outputs":[]}
+]`
+
+// DefaultTxTimeout bounds sending one transaction and waiting for it to be mined
+const DefaultTxTimeout = 2 * time.Minute
+
+// ErrTxFailed is returned when a transaction is mined but reverted
+var ErrTxFailed = errors.New("transaction reverted")
+
+// ErrTxDropped is returned for a transaction that will never be mined, because
+// another transaction from the operator account took its nonce
+var ErrTxDropped = errors.New("transaction dropped")
+
+// PendingTxError is returned when a transaction was sent but not mined in time
+// It may still be mined; WaitTx waits for it again.
+type PendingTxError struct {
+	Method string
+	Tx     *types.Transaction
+	Err    error
+}
+
+func (e *PendingTxError) Error() string {
+	return fmt.Sprintf("%s: waiting for %s (nonce %d): %v", e.Method, e.Tx.Hash().Hex(), e.Tx.Nonce(), e.Err)
+}
+
+func (e *PendingTxError) Unwrap() error {
+	return e.Err
+}
+
+// chainBackend is the part of an Ethereum client the registry uses
+type chainBackend interface {
+	bind.ContractBackend
+	bind.DeployBackend
+	NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+	Close()
+}
+
+// Config selects the chain, contract and operator account
+type Config struct {
+	RPCURL   string            // Ethereum JSON-RPC endpoint
+	Contract string            // Registry contract address (hex)
+	Operator string            // Operator account (hex); must match Key (empty = Key's account)
+	Key      *ecdsa.PrivateKey // Signs the operator's transactions
+	Timeout  time.Duration     // Per-transaction timeout (0 = DefaultTxTimeout)
+}
+
+// Registry is a connection to the relay registry contract
+type Registry struct {
+	eth      chainBackend
+	contract *bind.BoundContract
+	auth     *bind.TransactOpts
+	operator common.Address
+	timeout  time.Duration
+
+	// Transactions are sent one at a time so they don't race for the account nonce
+	mu sync.Mutex
+}
+
+// Dial connects to the chain and binds the registry contract
+func Dial(ctx context.Context, cfg Config) (*Registry, error) {
+	if !common.IsHexAddress(cfg.Contract) {
+		return nil, fmt.Errorf("invalid contract address %q", cfg.Contract)
+	}
+	if cfg.Key == nil {
+		return nil, fmt.Errorf("operator key is required")
+	}
+	operator := ethcrypto.PubkeyToAddress(cfg.Key.PublicKey)
+	if cfg.Operator != "" {
+		if !common.IsHexAddress(cfg.Operator) {
+			return nil, fmt.Errorf("invalid operator address %q", cfg.Operator)
+		}
+		if configured := common.HexToAddress(cfg.Operator); configured != operator {
+			return nil, fmt.Errorf("operator key belongs to %s, not %s", operator.Hex(), configured.Hex())
+		}
+	}
+
+	parsed, err := abi.JSON(strings.NewReader(RegistryABI))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse registry ABI: %w", err)
+	}
+
+	eth, err := ethclient.DialContext(ctx, cfg.RPCURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", cfg.RPCURL, err)
+	}
+	chainID, err := eth.ChainID(ctx)
+	if err != nil {
+		eth.Close()
+		return nil, fmt.Errorf("failed to get chain ID: %w", err)
+	}
+	auth, err := bind.NewKeyedTransactorWithChainID(cfg.Key, chainID)
+	if err != nil {
+		eth.Close()
+		return nil, err
+	}
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTxTimeout
+	}
+
+	address := common.HexToAddress(cfg.Contract)
+	return &Registry{
+		eth:      eth,
+		contract: bind.NewBoundContract(address, parsed, eth, eth, eth),
+		auth:     auth,
+		operator: operator,
+		timeout:  timeout,
+	}, nil
+}
+
+// Close closes the RPC connection
+func (r *Registry) Close() {
+	r.eth.Close()
+}
+
+// Operator returns the account the registry credits
+func (r *Registry) Operator() common.Address {
+	return r.operator
+}
+
+// IsRegistered reports whether a relay is in the registry
+func (r *Registry) IsRegistered(ctx context.Context, relay protocol.Address) (bool, error) {
+	var out []any
+	if err := r.contract.Call(&bind.CallOpts{Context: ctx}, &out, "isRegistered", [20]byte(relay)); err != nil {
+		return false, fmt.Errorf("isRegistered: %w", err)
+	}
+	return *abi.ConvertType(out[0], new(bool)).(*bool), nil
+}
+
+// Register adds a relay to the registry, unless it is already there
+func (r *Registry) Register(ctx context.Context, relay protocol.Address, endpoint, region string) error {
+	registered, err := r.IsRegistered(ctx, relay)
+	if err != nil {
+		return err
+	}
+	if registered {
+		return nil
+	}
+	return r.transact(ctx, "registerRelay", [20]byte(relay), endpoint, region)
+}
+
+// UpdateEndpoint points a registered relay at a new endpoint, e.g. after its standby took over
+func (r *Registry) UpdateEndpoint(ctx context.Context, relay protocol.Address, endpoint string) error {
+	return r.transact(ctx, "updateEndpoint", [20]byte(relay), endpoint)
+}
+
+// Heartbeat tells the registry the relay is still up
+func (r *Registry) Heartbeat(ctx context.Context, relay protocol.Address) error {
+	return r.transact(ctx, "heartbeat", [20]byte(relay))
+}
+
+// RecordRelays adds to the relay's count of relayed messages
+func (r *Registry) RecordRelays(ctx context.Context, relay protocol.Address, count uint64) error {
+	return r.transact(ctx, "recordRelays", [20]byte(relay), new(big.Int).SetUint64(count))
+}
+
+// PendingRewards returns the operator's unclaimed rewards (in wei)
+func (r *Registry) PendingRewards(ctx context.Context) (*big.Int, error) {
+	var out []any
+	if err := r.contract.Call(&bind.CallOpts{Context: ctx}, &out, "pendingRewards", r.operator); err != nil {
+		return nil, fmt.Errorf("pendingRewards: %w", err)
+	}
+	return abi.ConvertType(out[0], new(big.Int)).(*big.Int), nil
+}
+
+// ClaimRewards transfers the operator's pending rewards to the operator account
+func (r *Registry) ClaimRewards(ctx context.Context) error {
+	return r.transact(ctx, "claimRewards")
+}
+
+// CommitUsage records the digest of the operator's usage report for an epoch
+func (r *Registry) CommitUsage(ctx context.Context, epochStart uint64, digest [32]byte) error {
+	return r.transact(ctx, "commitUsage", epochStart, digest)
+}
+
+// transact sends a contract call and waits for it to be mined
+func (r *Registry) transact(ctx context.Context, method string, params ...any) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	opts := *r.auth
+	opts.Context = ctx
+	tx, err := r.contract.Transact(&opts, method, params...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+
+	receipt, err := bind.WaitMined(ctx, r.eth, tx)
+	if err != nil {
+		return &PendingTxError{Method: method, Tx: tx, Err: err}
+	}
+	return checkReceipt(method, receipt)
+}
+
+// WaitTx waits for a transaction that transact gave up on, see PendingTxError
+// Returns ErrTxDropped if another transaction was mined with its nonce instead.
+func (r *Registry) WaitTx(ctx context.Context, tx *types.Transaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	// Once the account's nonce has passed the transaction's, it was mined or never will be
+	nonce, err := r.eth.NonceAt(ctx, r.operator, nil)
+	if err != nil {
+		return fmt.Errorf("getting nonce: %w", err)
+	}
+	if nonce > tx.Nonce() {
+		receipt, err := r.eth.TransactionReceipt(ctx, tx.Hash())
+		if errors.Is(err, ethereum.NotFound) {
+			return fmt.Errorf("%w (%s)", ErrTxDropped, tx.Hash().Hex())
+		}
+		if err != nil {
+			return fmt.Errorf("getting receipt for %s: %w", tx.Hash().Hex(), err)
+		}
+		return checkReceipt("transaction", receipt)
+	}
+
+	receipt, err := bind.WaitMined(ctx, r.eth, tx)
+	if err != nil {
+		return &PendingTxError{Method: "transaction", Tx: tx, Err: err}
+	}
+	return checkReceipt("transaction", receipt)
+}
+
+// checkReceipt returns ErrTxFailed for a reverted transaction
+func checkReceipt(method string, receipt *types.Receipt) error {
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return fmt.Errorf("%s: %w (%s)", method, ErrTxFailed, receipt.TxHash.Hex())
+	}
+	return nil
+}
+
+// LoadKey reads an operator key stored as hex
+func LoadKey(path string) (*ecdsa.PrivateKey, error) {
+	key, err := ethcrypto.LoadECDSA(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load operator key: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,222 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// fakeChain is a chain that mines transactions as they are sent, unless held
+type fakeChain struct {
+	mu     sync.Mutex
+	sent   []*types.Transaction
+	mined  map[common.Hash]bool
+	hold   []byte // Leave transactions whose data starts with this unmined (empty = all)
+	nonce  uint64 // Next nonce the account will use
+	latest uint64 // Nonce of the account in the latest block
+}
+
+func newFakeChain() *fakeChain {
+	return &fakeChain{mined: make(map[common.Hash]bool)}
+}
+
+// mine includes a sent transaction in a block
+func (c *fakeChain) mine(tx *types.Transaction) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mined[tx.Hash()] = true
+	c.latest = max(c.latest, tx.Nonce()+1)
+}
+
+func (c *fakeChain) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent = append(c.sent, tx)
+	c.nonce = tx.Nonce() + 1
+	if c.hold == nil || !bytes.HasPrefix(tx.Data(), c.hold) {
+		c.mined[tx.Hash()] = true
+		c.latest = c.nonce
+	}
+	return nil
+}
+
+func (c *fakeChain) TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.mined[hash] {
+		return nil, ethereum.NotFound
+	}
+	return &types.Receipt{Status: types.ReceiptStatusSuccessful, TxHash: hash}, nil
+}
+
+func (c *fakeChain) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.nonce, nil
+}
+
+func (c *fakeChain) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.latest, nil
+}
+
+func (c *fakeChain) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return []byte{1}, nil
+}
+
+func (c *fakeChain) PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error) {
+	return []byte{1}, nil
+}
+
+func (c *fakeChain) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return nil, nil
+}
+
+func (c *fakeChain) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return &types.Header{Number: big.NewInt(1)}, nil
+}
+
+func (c *fakeChain) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(1), nil
+}
+
+func (c *fakeChain) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(1), nil
+}
+
+func (c *fakeChain) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	return 50000, nil
+}
+
+func (c *fakeChain) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	return nil, nil
+}
+
+func (c *fakeChain) SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	return event.NewSubscription(func(quit <-chan struct{}) error { <-quit; return nil }), nil
+}
+
+func (c *fakeChain) Close() {}
+
+// newFakeRegistry returns a registry on chain that waits timeout for each transaction
+func newFakeRegistry(t *testing.T, chain *fakeChain, timeout time.Duration) *Registry {
+	t.Helper()
+	key, err := ethcrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth, err := bind.NewKeyedTransactorWithChainID(key, big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := abi.JSON(strings.NewReader(RegistryABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Registry{
+		eth:      chain,
+		contract: bind.NewBoundContract(common.Address{1}, parsed, chain, chain, chain),
+		auth:     auth,
+		operator: auth.From,
+		timeout:  timeout,
+	}
+}
+
+func TestReporterWaitsForLateReceipt(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(RegistryABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := newFakeChain()
+	registry := newFakeRegistry(t, chain, 50*time.Millisecond)
+	reporter := &Reporter{registry: registry, relay: protocol.Address{1}}
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		reporter.MessageRelayed()
+	}
+
+	// The heartbeat is mined, the count is not before the timeout
+	chain.hold = parsed.Methods["recordRelays"].ID
+	err = reporter.Report(ctx)
+	if err == nil {
+		t.Fatal("Report() expected a timeout, got nil")
+	}
+	chain.hold = nil
+	if reporter.Pending() != 3 {
+		t.Errorf("Pending() = %d after the timeout, want 3", reporter.Pending())
+	}
+
+	// The receipt arrives after the timeout; the retry waits for it instead of resending
+	var pending *PendingTxError
+	if !errors.As(err, &pending) {
+		t.Fatalf("Report() error = %v, want a PendingTxError", err)
+	}
+	chain.mine(pending.Tx)
+	reporter.MessageRelayed()
+	before := len(chain.sent)
+	if err := reporter.Report(ctx); err != nil {
+		t.Fatalf("Report() error = %v", err)
+	}
+	if reporter.Reported() != 4 || reporter.Pending() != 0 {
+		t.Errorf("Reported() = %d, Pending() = %d; want 4, 0", reporter.Reported(), reporter.Pending())
+	}
+
+	// A heartbeat and a count of the one new message
+	var counts []uint64
+	for _, tx := range chain.sent[before:] {
+		method, err := parsed.MethodById(tx.Data())
+		if err != nil || method.Name != "recordRelays" {
+			continue
+		}
+		args, err := method.Inputs.Unpack(tx.Data()[4:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts = append(counts, args[1].(*big.Int).Uint64())
+	}
+	if len(counts) != 1 || counts[0] != 1 {
+		t.Errorf("retry recorded counts %v, want [1]", counts)
+	}
+}
+
+func TestRegistryWaitTxDropped(t *testing.T) {
+	chain := newFakeChain()
+	chain.hold = []byte{}
+	registry := newFakeRegistry(t, chain, 50*time.Millisecond)
+
+	err := registry.RecordRelays(context.Background(), protocol.Address{1}, 5)
+	var pending *PendingTxError
+	if !errors.As(err, &pending) {
+		t.Fatalf("RecordRelays() error = %v, want a PendingTxError", err)
+	}
+
+	// Another transaction took the nonce, so this one can be sent again
+	chain.mu.Lock()
+	chain.latest = pending.Tx.Nonce() + 1
+	chain.mu.Unlock()
+	if err := registry.WaitTx(context.Background(), pending.Tx); !errors.Is(err, ErrTxDropped) {
+		t.Errorf("WaitTx() error = %v, want ErrTxDropped", err)
+	}
+
+	chain.mine(pending.Tx)
+	if err := registry.WaitTx(context.Background(), pending.Tx); err != nil {
+		t.Errorf("WaitTx() error = %v after mining", err)
+	}
+}
@@ -0,0 +1,283 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// Batching defaults
+const (
+	DefaultReportInterval     = 5 * time.Minute  // Longest gap between on-chain reports
+	DefaultCheckpointInterval = 10 * time.Second // How often counts are saved to the CountStore
+	DefaultRetryDelay         = 30 * time.Second // First retry after a failed report; doubles up to the report interval
+)
+
+// relayRecorder is the part of the registry a Reporter sends to
+type relayRecorder interface {
+	Heartbeat(ctx context.Context, relay protocol.Address) error
+	RecordRelays(ctx context.Context, relay protocol.Address, count uint64) error
+	WaitTx(ctx context.Context, tx *types.Transaction) error
+}
+
+// CountStore persists counts that are not on-chain yet, so they survive restarts
+type CountStore interface {
+	AddPending(count uint64) error
+	MarkReported(count uint64) error
+	Pending() (uint64, error)
+}
+
+// BatchConfig controls when a started Reporter sends its counts
+type BatchConfig struct {
+	Interval           time.Duration // Report at least this often (0 = DefaultReportInterval)
+	MaxPending         uint64        // Report early once this many messages are pending (0 = only on Interval)
+	CheckpointInterval time.Duration // How often counts are saved to the store (0 = DefaultCheckpointInterval)
+}
+
+// Reporter batches a relay's message counts into periodic registry updates
+// Counting is cheap enough to call for every relayed message; the count is
+// only sent with each report, so one transaction covers a whole batch.
+type Reporter struct {
+	registry relayRecorder
+	relay    protocol.Address
+	store    CountStore    // Optional; nil keeps counts in memory only
+	pending  atomic.Uint64 // Messages relayed since the last checkpoint (or report, without a store)
+	saved    atomic.Uint64 // Messages checkpointed to the store but not yet reported
+	reported atomic.Uint64 // Messages recorded on-chain since startup
+	inFlight atomic.Uint64 // Messages in unconfirmed, taken from pending or saved
+
+	mu          sync.Mutex         // Serializes checkpoints and reports
+	unconfirmed *types.Transaction // recordRelays sent but not mined in time; nil if none
+	stopChan    chan struct{}
+	done        chan struct{}
+}
+
+// NewReporter creates a reporter for one relay
+func NewReporter(registry *Registry, relay protocol.Address) *Reporter {
+	return &Reporter{registry: registry, relay: relay}
+}
+
+// AttachStore persists counts in store and picks up any it holds from a previous run
+// Returns the number of messages recovered from the store.
+func (r *Reporter) AttachStore(store CountStore) (uint64, error) {
+	recovered, err := store.Pending()
+	if err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.store = store
+	r.saved.Store(recovered)
+	return recovered, nil
+}
+
+// MessageRelayed counts one relayed message
+func (r *Reporter) MessageRelayed() {
+	r.pending.Add(1)
+}
+
+// Pending returns the messages not yet recorded on-chain
+func (r *Reporter) Pending() uint64 {
+	return r.pending.Load() + r.saved.Load() + r.inFlight.Load()
+}
+
+// Reported returns the messages recorded on-chain since startup
+func (r *Reporter) Reported() uint64 {
+	return r.reported.Load()
+}
+
+// Checkpoint saves the messages counted since the last checkpoint to the store
+func (r *Reporter) Checkpoint() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.checkpointLocked()
+}
+
+// checkpointLocked moves the in-memory count to the store (caller holds mu)
+func (r *Reporter) checkpointLocked() error {
+	if r.store == nil {
+		return nil
+	}
+	count := r.pending.Swap(0)
+	if count == 0 {
+		return nil
+	}
+	if err := r.store.AddPending(count); err != nil {
+		r.pending.Add(count)
+		return err
+	}
+	r.saved.Add(count)
+	return nil
+}
+
+// Report sends a heartbeat and the messages relayed since the last report
+// A count that fails to be recorded is kept for the next report. One whose
+// transaction was sent but not mined in time stays with that transaction, and
+// the next report waits for it instead of recording the count twice.
+func (r *Reporter) Report(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.registry.Heartbeat(ctx, r.relay); err != nil {
+		return err
+	}
+	if err := r.confirmLocked(ctx); err != nil {
+		return err
+	}
+
+	counts := &r.pending
+	if r.store != nil {
+		if err := r.checkpointLocked(); err != nil {
+			return err
+		}
+		counts = &r.saved
+	}
+	count := counts.Swap(0)
+	if count == 0 {
+		return nil
+	}
+
+	err := r.registry.RecordRelays(ctx, r.relay, count)
+	var pending *PendingTxError
+	if errors.As(err, &pending) {
+		r.unconfirmed = pending.Tx
+		r.inFlight.Store(count)
+		return err
+	}
+	if err != nil {
+		counts.Add(count)
+		return err
+	}
+	return r.recordedLocked(count)
+}
+
+// confirmLocked waits for the transaction of an earlier report (caller holds mu)
+// Its count is recorded once the transaction is mined, or returned to the
+// pending messages if it never will be.
+func (r *Reporter) confirmLocked(ctx context.Context) error {
+	if r.unconfirmed == nil {
+		return nil
+	}
+
+	err := r.registry.WaitTx(ctx, r.unconfirmed)
+	if err != nil && !errors.Is(err, ErrTxFailed) && !errors.Is(err, ErrTxDropped) {
+		return err
+	}
+
+	count := r.inFlight.Swap(0)
+	r.unconfirmed = nil
+	if err != nil {
+		log.Printf("⚠️  Earlier report of %d messages was not recorded: %v", count, err)
+		if r.store != nil {
+			r.saved.Add(count)
+		} else {
+			r.pending.Add(count)
+		}
+		return nil
+	}
+	return r.recordedLocked(count)
+}
+
+// recordedLocked counts messages the registry recorded (caller holds mu)
+func (r *Reporter) recordedLocked(count uint64) error {
+	r.reported.Add(count)
+	if r.store == nil {
+		return nil
+	}
+
+	// The count is on-chain either way; a store that still holds it would report it
+	// again after a restart, so the error is returned for the operator to see
+	if err := r.store.MarkReported(count); err != nil {
+		return fmt.Errorf("%d messages recorded on-chain but not in the count store: %w", count, err)
+	}
+	return nil
+}
+
+// Start reports counts in the background according to cfg
+func (r *Reporter) Start(cfg BatchConfig) error {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultReportInterval
+	}
+	if cfg.CheckpointInterval <= 0 {
+		cfg.CheckpointInterval = DefaultCheckpointInterval
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopChan != nil {
+		return fmt.Errorf("reporter already running")
+	}
+	r.stopChan = make(chan struct{})
+	r.done = make(chan struct{})
+
+	go r.reportLoop(cfg, r.stopChan, r.done)
+	return nil
+}
+
+// Stop ends background reporting and checkpoints the remaining count
+// Whatever is still pending is left for a final Report or the next run.
+func (r *Reporter) Stop() error {
+	r.mu.Lock()
+	stopChan, done := r.stopChan, r.done
+	r.stopChan, r.done = nil, nil
+	r.mu.Unlock()
+
+	if stopChan != nil {
+		close(stopChan)
+		<-done
+	}
+	return r.Checkpoint()
+}
+
+// reportLoop checkpoints counts and reports them when the interval or batch size is reached
+// Failed reports are retried with exponential backoff, capped at the interval.
+func (r *Reporter) reportLoop(cfg BatchConfig, stopChan, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(cfg.CheckpointInterval)
+	defer ticker.Stop()
+
+	next := time.Now().Add(cfg.Interval)
+	var retryDelay time.Duration
+
+	for {
+		select {
+		case <-stopChan:
+			return
+		case now := <-ticker.C:
+			if err := r.Checkpoint(); err != nil {
+				log.Printf("⚠️  Failed to save relay count: %v", err)
+			}
+
+			batchFull := cfg.MaxPending > 0 && r.Pending() >= cfg.MaxPending
+			if now.Before(next) && !(batchFull && retryDelay == 0) {
+				continue
+			}
+
+			before := r.Reported()
+			if err := r.Report(context.Background()); err != nil {
+				if retryDelay == 0 {
+					retryDelay = DefaultRetryDelay
+				} else {
+					retryDelay = min(retryDelay*2, cfg.Interval)
+				}
+				next = now.Add(retryDelay)
+				log.Printf("⚠️  On-chain report failed: %v (%d messages kept, retrying in %v)", err, r.Pending(), retryDelay)
+				continue
+			}
+
+			retryDelay = 0
+			next = now.Add(cfg.Interval)
+			log.Printf("⛓️  Heartbeat recorded on-chain (%d messages reported, %d since startup)", r.Reported()-before, r.Reported())
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// fakeRegistry records the calls a Reporter makes
+type fakeRegistry struct {
+	heartbeats int
+	recorded   []uint64
+	failRecord bool
+}
+
+func (f *fakeRegistry) Heartbeat(ctx context.Context, relay protocol.Address) error {
+	f.heartbeats++
+	return nil
+}
+
+func (f *fakeRegistry) RecordRelays(ctx context.Context, relay protocol.Address, count uint64) error {
+	if f.failRecord {
+		return errors.New("rpc unavailable")
+	}
+	f.recorded = append(f.recorded, count)
+	return nil
+}
+
+func (f *fakeRegistry) WaitTx(ctx context.Context, tx *types.Transaction) error {
+	return nil
+}
+
+func TestReporterBatchesCounts(t *testing.T) {
+	registry := &fakeRegistry{}
+	reporter := &Reporter{registry: registry, relay: protocol.Address{1}}
+	ctx := context.Background()
+
+	// Nothing relayed: only a heartbeat
+	if err := reporter.Report(ctx); err != nil {
+		t.Fatalf("Report() error = %v", err)
+	}
+	if registry.heartbeats != 1 || len(registry.recorded) != 0 {
+		t.Errorf("heartbeats = %d, recorded = %v", registry.heartbeats, registry.recorded)
+	}
+
+	for i := 0; i < 5; i++ {
+		reporter.MessageRelayed()
+	}
+
+	// A failed report keeps the count for the next one
+	registry.failRecord = true
+	if err := reporter.Report(ctx); err == nil {
+		t.Fatal("Report() expected error, got nil")
+	}
+	if reporter.Pending() != 5 {
+		t.Errorf("Pending() = %d after failed report, want 5", reporter.Pending())
+	}
+
+	reporter.MessageRelayed()
+	registry.failRecord = false
+	if err := reporter.Report(ctx); err != nil {
+		t.Fatalf("Report() error = %v", err)
+	}
+	if len(registry.recorded) != 1 || registry.recorded[0] != 6 {
+		t.Errorf("recorded = %v, want [6]", registry.recorded)
+	}
+	if reporter.Pending() != 0 || reporter.Reported() != 6 {
+		t.Errorf("Pending() = %d, Reported() = %d", reporter.Pending(), reporter.Reported())
+	}
+}
+
+// memoryCounts is a CountStore that can be shared between reporters, like a file across restarts
+type memoryCounts struct {
+	pending, reported uint64
+}
+
+func (m *memoryCounts) AddPending(count uint64) error {
+	m.pending += count
+	return nil
+}
+
+func (m *memoryCounts) MarkReported(count uint64) error {
+	m.pending -= count
+	m.reported += count
+	return nil
+}
+
+func (m *memoryCounts) Pending() (uint64, error) {
+	return m.pending, nil
+}
+
+func TestReporterRecoversSavedCounts(t *testing.T) {
+	registry := &fakeRegistry{}
+	counts := &memoryCounts{}
+	ctx := context.Background()
+
+	reporter := &Reporter{registry: registry, relay: protocol.Address{1}}
+	if _, err := reporter.AttachStore(counts); err != nil {
+		t.Fatalf("AttachStore() error = %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		reporter.MessageRelayed()
+	}
+	if err := reporter.Checkpoint(); err != nil {
+		t.Fatalf("Checkpoint() error = %v", err)
+	}
+
+	// The report fails and the relay "crashes"; the next run picks the count up
+	registry.failRecord = true
+	if err := reporter.Report(ctx); err == nil {
+		t.Fatal("Report() expected error, got nil")
+	}
+
+	registry.failRecord = false
+	restarted := &Reporter{registry: registry, relay: protocol.Address{1}}
+	recovered, err := restarted.AttachStore(counts)
+	if err != nil || recovered != 4 {
+		t.Fatalf("AttachStore() = %d, %v; want 4", recovered, err)
+	}
+	restarted.MessageRelayed()
+	if err := restarted.Report(ctx); err != nil {
+		t.Fatalf("Report() error = %v", err)
+	}
+	if len(registry.recorded) != 1 || registry.recorded[0] != 5 {
+		t.Errorf("recorded = %v, want [5]", registry.recorded)
+	}
+	if counts.pending != 0 || counts.reported != 5 {
+		t.Errorf("store pending = %d, reported = %d; want 0, 5", counts.pending, counts.reported)
+	}
+}
+
+func TestRegistryABI(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(RegistryABI))
+	if err != nil {
+		t.Fatalf("abi.JSON() error = %v", err)
+	}
+
+	relay := [20]byte(protocol.Address{7})
+	if _, err := parsed.Pack("registerRelay", relay, "relay.example.com:9001", "eu-west"); err != nil {
+		t.Errorf("Pack(registerRelay) error = %v", err)
+	}
+	if _, err := parsed.Pack("updateEndpoint", relay, "standby.example.com:9001"); err != nil {
+		t.Errorf("Pack(updateEndpoint) error = %v", err)
+	}
+	if _, err := parsed.Pack("heartbeat", relay); err != nil {
+		t.Errorf("Pack(heartbeat) error = %v", err)
+	}
+	if _, err := parsed.Pack("claimRewards"); err != nil {
+		t.Errorf("Pack(claimRewards) error = %v", err)
+	}
+}
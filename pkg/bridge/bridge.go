@@ -0,0 +1,171 @@
+// Package bridge relays group chat between ZenTalk and other messaging networks
+//
+// A bridge runs as a ZenTalk bot account (see network.Client.EnableBotMode)
+// that is a member of every bridged group. A Remote connects it to the other
+// network (Matrix, XMPP, IRC, ...), and a Mapping pairs each external room with
+// a ZenTalk group. Messages are copied both ways with the original sender named
+// in the text, so a community can move to ZenTalk one member at a time.
+//
+// Users who have moved can link their old external ID to their ZenTalk address;
+// their ZenTalk messages are then shown under that ID on the other network.
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// sendTimeout bounds delivery of one message to the remote network
+const sendTimeout = 30 * time.Second
+
+// Message is a chat message on the remote network
+type Message struct {
+	Room        string    // Remote room ID
+	User        string    // Remote user ID ("" for ZenTalk users without a linked ID)
+	DisplayName string    // Name shown for the sender
+	Text        string    // Plain text body
+	Time        time.Time // When the message was sent
+}
+
+// Remote is the other network's side of a bridge
+type Remote interface {
+	// Protocol names the network, e.g. "matrix"
+	Protocol() string
+
+	// Run receives messages from bridged rooms until ctx is done
+	// Messages sent by the bridge itself must not be delivered back.
+	Run(ctx context.Context, incoming chan<- *Message) error
+
+	// Send posts a message from a ZenTalk user to a remote room
+	Send(ctx context.Context, msg *Message) error
+}
+
+// Local is the ZenTalk side of a bridge
+type Local interface {
+	// SendGroupText posts text to a ZenTalk group as the bridge account
+	SendGroupText(group protocol.GroupID, text string) error
+}
+
+// Bridge copies messages between remote rooms and ZenTalk groups
+type Bridge struct {
+	remote  Remote
+	local   Local
+	mapping *Mapping
+	self    protocol.Address // Bridge account; its own group messages are echoes
+
+	names map[protocol.Address]string // ZenTalk usernames from profile updates
+	mu    sync.RWMutex
+}
+
+// New creates a bridge between remote and local for the bridge account self
+func New(remote Remote, local Local, mapping *Mapping, self protocol.Address) *Bridge {
+	return &Bridge{
+		remote:  remote,
+		local:   local,
+		mapping: mapping,
+		self:    self,
+		names:   make(map[protocol.Address]string),
+	}
+}
+
+// Run relays remote messages into ZenTalk until ctx is done or the remote fails
+// ZenTalk messages go the other way through HandleGroupMessage.
+func (b *Bridge) Run(ctx context.Context) error {
+	incoming := make(chan *Message, 64)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- b.remote.Run(ctx, incoming)
+	}()
+
+	log.Printf("🌉 %s bridge running (%d rooms)", b.remote.Protocol(), b.mapping.RoomCount())
+
+	for {
+		select {
+		case msg := <-incoming:
+			b.toZenTalk(msg)
+		case err := <-errc:
+			return err
+		}
+	}
+}
+
+// HandleGroupMessage relays a ZenTalk group message to its remote room
+// Set it as the client's OnGroupMessageReceived callback.
+func (b *Bridge) HandleGroupMessage(msg *protocol.GroupMessage) {
+	if msg.From == b.self || msg.ContentType != protocol.ContentTypeText {
+		return
+	}
+
+	room, ok := b.mapping.RoomFor(msg.GroupID)
+	if !ok {
+		return
+	}
+
+	user, _ := b.mapping.UserFor(msg.From)
+	out := &Message{
+		Room:        room,
+		User:        user,
+		DisplayName: b.nameOf(msg.From),
+		Text:        string(msg.Content),
+		Time:        time.UnixMilli(int64(msg.Timestamp)),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	if err := b.remote.Send(ctx, out); err != nil {
+		log.Printf("⚠️  Failed to relay message to %s room %s: %v", b.remote.Protocol(), room, err)
+	}
+}
+
+// HandleProfileUpdate remembers a ZenTalk user's name for messages sent to the remote
+// Set it as the client's OnProfileUpdate callback.
+func (b *Bridge) HandleProfileUpdate(profile *protocol.ProfileUpdate) {
+	name := string(bytes.Trim(profile.Username[:], "\x00"))
+	if name == "" {
+		return
+	}
+
+	b.mu.Lock()
+	b.names[profile.Address] = name
+	b.mu.Unlock()
+}
+
+// toZenTalk posts a remote message to its ZenTalk group
+func (b *Bridge) toZenTalk(msg *Message) {
+	group, ok := b.mapping.GroupFor(msg.Room)
+	if !ok {
+		return
+	}
+
+	name := msg.DisplayName
+	if name == "" {
+		name = msg.User
+	}
+
+	if err := b.local.SendGroupText(group, fmt.Sprintf("<%s> %s", name, msg.Text)); err != nil {
+		log.Printf("⚠️  Failed to relay message to group %x: %v", group[:8], err)
+	}
+}
+
+// nameOf returns the name shown on the remote for a ZenTalk user
+// A linked remote ID wins, then the profile username, then a short address.
+func (b *Bridge) nameOf(addr protocol.Address) string {
+	if user, ok := b.mapping.UserFor(addr); ok {
+		return user
+	}
+
+	b.mu.RLock()
+	name, ok := b.names[addr]
+	b.mu.RUnlock()
+	if ok {
+		return name
+	}
+
+	return fmt.Sprintf("%x", addr[:4])
+}
@@ -0,0 +1,129 @@
+package bridge
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// fakeRemote delivers queued messages and records sent ones
+type fakeRemote struct {
+	incoming []*Message
+	sent     []*Message
+	mu       sync.Mutex
+}
+
+func (r *fakeRemote) Protocol() string { return "fake" }
+
+func (r *fakeRemote) Run(ctx context.Context, incoming chan<- *Message) error {
+	for _, msg := range r.incoming {
+		incoming <- msg
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (r *fakeRemote) Send(_ context.Context, msg *Message) error {
+	r.mu.Lock()
+	r.sent = append(r.sent, msg)
+	r.mu.Unlock()
+	return nil
+}
+
+// fakeLocal records group texts
+type fakeLocal struct {
+	texts chan string
+}
+
+func (l *fakeLocal) SendGroupText(_ protocol.GroupID, text string) error {
+	l.texts <- text
+	return nil
+}
+
+func TestMappingSaveLoad(t *testing.T) {
+	m := NewMapping()
+	m.LinkRoom("!a:example.org", protocol.GroupID{1})
+	m.LinkRoom("!b:example.org", protocol.GroupID{1}) // Moves group 1 to room b
+	m.LinkUser("@alice:example.org", protocol.Address{2})
+
+	if _, ok := m.GroupFor("!a:example.org"); ok {
+		t.Error("GroupFor(!a) still linked after the group moved")
+	}
+
+	path := filepath.Join(t.TempDir(), "mapping.json")
+	if err := m.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	loaded, err := LoadMapping(path)
+	if err != nil {
+		t.Fatalf("LoadMapping() error = %v", err)
+	}
+
+	if room, ok := loaded.RoomFor(protocol.GroupID{1}); !ok || room != "!b:example.org" {
+		t.Errorf("RoomFor() = %q, %v", room, ok)
+	}
+	if addr, ok := loaded.AddressFor("@alice:example.org"); !ok || addr != (protocol.Address{2}) {
+		t.Errorf("AddressFor() = %x, %v", addr, ok)
+	}
+
+	if m, err := LoadMapping(filepath.Join(t.TempDir(), "missing.json")); err != nil || m.RoomCount() != 0 {
+		t.Errorf("LoadMapping(missing) = %d rooms, %v", m.RoomCount(), err)
+	}
+}
+
+func TestBridgeRelaysBothWays(t *testing.T) {
+	self := protocol.Address{9}
+	alice := protocol.Address{2}
+	group := protocol.GroupID{1}
+
+	mapping := NewMapping()
+	mapping.LinkRoom("!room:example.org", group)
+	mapping.LinkUser("@alice:example.org", alice)
+
+	remote := &fakeRemote{incoming: []*Message{
+		{Room: "!other:example.org", User: "@bob:example.org", Text: "not bridged"},
+		{Room: "!room:example.org", User: "@bob:example.org", DisplayName: "bob", Text: "hi"},
+	}}
+	local := &fakeLocal{texts: make(chan string, 2)}
+	b := New(remote, local, mapping, self)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go b.Run(ctx)
+
+	select {
+	case text := <-local.texts:
+		if text != "<bob> hi" {
+			t.Errorf("group text = %q, want %q", text, "<bob> hi")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("remote message was not relayed")
+	}
+
+	// Echoes of our own posts and non-text messages stay in ZenTalk
+	b.HandleGroupMessage(&protocol.GroupMessage{From: self, GroupID: group, ContentType: protocol.ContentTypeText, Content: []byte("<bob> hi")})
+	b.HandleGroupMessage(&protocol.GroupMessage{From: alice, GroupID: group, ContentType: protocol.ContentTypeImage})
+
+	b.HandleGroupMessage(&protocol.GroupMessage{From: alice, GroupID: group, ContentType: protocol.ContentTypeText, Content: []byte("moved!")})
+	carol := protocol.Address{3}
+	profile := &protocol.ProfileUpdate{Address: carol}
+	copy(profile.Username[:], "carol")
+	b.HandleProfileUpdate(profile)
+	b.HandleGroupMessage(&protocol.GroupMessage{From: carol, GroupID: group, ContentType: protocol.ContentTypeText, Content: []byte("hello")})
+
+	remote.mu.Lock()
+	defer remote.mu.Unlock()
+	if len(remote.sent) != 2 {
+		t.Fatalf("sent %d messages to the remote, want 2", len(remote.sent))
+	}
+	if got := remote.sent[0]; got.Room != "!room:example.org" || got.DisplayName != "@alice:example.org" || got.Text != "moved!" {
+		t.Errorf("linked user's message = %+v", got)
+	}
+	if got := remote.sent[1]; got.DisplayName != "carol" || got.User != "" {
+		t.Errorf("profile user's message = %+v", got)
+	}
+}
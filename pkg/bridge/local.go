@@ -0,0 +1,61 @@
+package bridge
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/network"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// ClientLocal is the ZenTalk side of a bridge backed by a connected client
+// Groups are managed by the application, so the bridge must be told each
+// bridged group's members with AddGroup.
+type ClientLocal struct {
+	client *network.Client
+	groups map[protocol.GroupID]*network.Group
+	relays []*crypto.RelayInfo // Fixed onion path; empty = the client's route policy
+	mu     sync.RWMutex
+}
+
+// NewClientLocal wraps a client, normally one in bot mode
+func NewClientLocal(client *network.Client) *ClientLocal {
+	return &ClientLocal{
+		client: client,
+		groups: make(map[protocol.GroupID]*network.Group),
+	}
+}
+
+// AddGroup registers or replaces a bridged group and its members
+func (l *ClientLocal) AddGroup(group *network.Group) {
+	l.mu.Lock()
+	l.groups[group.ID] = group
+	l.mu.Unlock()
+}
+
+// SetRelayPath sends through fixed relays instead of the client's route policy
+func (l *ClientLocal) SetRelayPath(path []*crypto.RelayInfo) {
+	l.mu.Lock()
+	l.relays = path
+	l.mu.Unlock()
+}
+
+// SendGroupText posts text to a registered group
+func (l *ClientLocal) SendGroupText(id protocol.GroupID, text string) error {
+	l.mu.RLock()
+	group, ok := l.groups[id]
+	path := append([]*crypto.RelayInfo(nil), l.relays...)
+	l.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("group %x is not registered with the bridge", id[:8])
+	}
+
+	if len(path) == 0 {
+		var err error
+		if path, err = l.client.BuildPolicyRelayPath(); err != nil {
+			return err
+		}
+	}
+	return l.client.SendGroupMessage(group, text, path)
+}
@@ -0,0 +1,212 @@
+package bridge
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// Mapping pairs remote rooms with ZenTalk groups and remote users with ZenTalk addresses
+// Both pairings are one-to-one.
+type Mapping struct {
+	rooms  map[string]protocol.GroupID
+	groups map[protocol.GroupID]string
+	users  map[string]protocol.Address
+	addrs  map[protocol.Address]string
+	mu     sync.RWMutex
+}
+
+// mappingFile is the JSON form of a Mapping
+type mappingFile struct {
+	Rooms []mappingRoom `json:"rooms"`
+	Users []mappingUser `json:"users"`
+}
+
+type mappingRoom struct {
+	Room  string `json:"room"`
+	Group string `json:"group"` // Hex group ID
+}
+
+type mappingUser struct {
+	User    string `json:"user"`
+	Address string `json:"address"` // Hex address
+}
+
+// NewMapping creates an empty mapping
+func NewMapping() *Mapping {
+	return &Mapping{
+		rooms:  make(map[string]protocol.GroupID),
+		groups: make(map[protocol.GroupID]string),
+		users:  make(map[string]protocol.Address),
+		addrs:  make(map[protocol.Address]string),
+	}
+}
+
+// LoadMapping reads a mapping from a JSON file; a missing file gives an empty mapping
+// Format: {"rooms": [{"room": "!id:example.org", "group": "<hex group ID>"}],
+// "users": [{"user": "@alice:example.org", "address": "<hex address>"}]}
+func LoadMapping(path string) (*Mapping, error) {
+	m := NewMapping()
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mapping file: %w", err)
+	}
+
+	var file mappingFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse mapping file: %w", err)
+	}
+
+	for _, room := range file.Rooms {
+		var group protocol.GroupID
+		if err := decodeHex(room.Group, group[:]); err != nil {
+			return nil, fmt.Errorf("room %s: invalid group ID %q", room.Room, room.Group)
+		}
+		m.LinkRoom(room.Room, group)
+	}
+
+	for _, user := range file.Users {
+		var addr protocol.Address
+		if err := decodeHex(user.Address, addr[:]); err != nil {
+			return nil, fmt.Errorf("user %s: invalid address %q", user.User, user.Address)
+		}
+		m.LinkUser(user.User, addr)
+	}
+
+	return m, nil
+}
+
+// Save writes the mapping to a JSON file
+func (m *Mapping) Save(path string) error {
+	m.mu.RLock()
+	var file mappingFile
+	for room, group := range m.rooms {
+		file.Rooms = append(file.Rooms, mappingRoom{Room: room, Group: hex.EncodeToString(group[:])})
+	}
+	for user, addr := range m.users {
+		file.Users = append(file.Users, mappingUser{User: user, Address: hex.EncodeToString(addr[:])})
+	}
+	m.mu.RUnlock()
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode mapping: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write mapping file: %w", err)
+	}
+	return nil
+}
+
+// LinkRoom bridges a remote room and a ZenTalk group, replacing their previous links
+func (m *Mapping) LinkRoom(room string, group protocol.GroupID) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if old, ok := m.rooms[room]; ok {
+		delete(m.groups, old)
+	}
+	if old, ok := m.groups[group]; ok {
+		delete(m.rooms, old)
+	}
+	m.rooms[room] = group
+	m.groups[group] = room
+}
+
+// UnlinkRoom stops bridging a remote room
+func (m *Mapping) UnlinkRoom(room string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if group, ok := m.rooms[room]; ok {
+		delete(m.groups, group)
+		delete(m.rooms, room)
+	}
+}
+
+// GroupFor returns the ZenTalk group bridged with a remote room
+func (m *Mapping) GroupFor(room string) (protocol.GroupID, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	group, ok := m.rooms[room]
+	return group, ok
+}
+
+// RoomFor returns the remote room bridged with a ZenTalk group
+func (m *Mapping) RoomFor(group protocol.GroupID) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	room, ok := m.groups[group]
+	return room, ok
+}
+
+// RoomCount returns the number of bridged rooms
+func (m *Mapping) RoomCount() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.rooms)
+}
+
+// LinkUser records that a remote user has moved to a ZenTalk address
+func (m *Mapping) LinkUser(user string, addr protocol.Address) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if old, ok := m.users[user]; ok {
+		delete(m.addrs, old)
+	}
+	if old, ok := m.addrs[addr]; ok {
+		delete(m.users, old)
+	}
+	m.users[user] = addr
+	m.addrs[addr] = user
+}
+
+// UnlinkUser removes a remote user's link
+func (m *Mapping) UnlinkUser(user string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if addr, ok := m.users[user]; ok {
+		delete(m.addrs, addr)
+		delete(m.users, user)
+	}
+}
+
+// AddressFor returns the ZenTalk address linked to a remote user
+func (m *Mapping) AddressFor(user string) (protocol.Address, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	addr, ok := m.users[user]
+	return addr, ok
+}
+
+// UserFor returns the remote user linked to a ZenTalk address
+func (m *Mapping) UserFor(addr protocol.Address) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	user, ok := m.addrs[addr]
+	return user, ok
+}
+
+// decodeHex decodes s into dst, which it must fill exactly
+func decodeHex(s string, dst []byte) error {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	if len(b) != len(dst) {
+		return fmt.Errorf("want %d bytes, got %d", len(dst), len(b))
+	}
+	copy(dst, b)
+	return nil
+}
@@ -0,0 +1,227 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// matrixSyncTimeout is how long the homeserver holds a sync open waiting for events
+	matrixSyncTimeout = 30 * time.Second
+
+	// matrixRetryDelay is the pause after a failed sync
+	matrixRetryDelay = 5 * time.Second
+)
+
+// MatrixConfig is the Matrix account a bridge posts as
+// The account must already be joined to every bridged room.
+type MatrixConfig struct {
+	Homeserver  string // Base URL, e.g. https://matrix.example.org
+	UserID      string // e.g. @zentalk:example.org
+	AccessToken string
+}
+
+// MatrixRemote bridges Matrix rooms over the client-server API
+// ZenTalk senders are named in the message body ("<name> text"), since the
+// bridge account posts every message.
+type MatrixRemote struct {
+	config MatrixConfig
+	http   *http.Client
+
+	txnPrefix string
+	txnSeq    atomic.Uint64
+}
+
+// matrixEvent is a room timeline event
+type matrixEvent struct {
+	Type           string `json:"type"`
+	Sender         string `json:"sender"`
+	OriginServerTS int64  `json:"origin_server_ts"`
+	Content        struct {
+		MsgType string `json:"msgtype"`
+		Body    string `json:"body"`
+	} `json:"content"`
+}
+
+// matrixSyncResponse is the part of a /sync response the bridge reads
+type matrixSyncResponse struct {
+	NextBatch string `json:"next_batch"`
+	Rooms     struct {
+		Join map[string]struct {
+			Timeline struct {
+				Events []matrixEvent `json:"events"`
+			} `json:"timeline"`
+		} `json:"join"`
+	} `json:"rooms"`
+}
+
+// NewMatrixRemote creates a Matrix remote for the given account
+func NewMatrixRemote(config MatrixConfig) *MatrixRemote {
+	config.Homeserver = strings.TrimSuffix(config.Homeserver, "/")
+	return &MatrixRemote{
+		config:    config,
+		http:      &http.Client{Timeout: matrixSyncTimeout + 30*time.Second},
+		txnPrefix: fmt.Sprintf("zentalk-%d-", time.Now().UnixNano()),
+	}
+}
+
+// Protocol returns "matrix"
+func (m *MatrixRemote) Protocol() string {
+	return "matrix"
+}
+
+// Run long-polls /sync and delivers new text messages until ctx is done
+// The first sync only finds the current position: room history is not replayed.
+func (m *MatrixRemote) Run(ctx context.Context, incoming chan<- *Message) error {
+	since := ""
+	for {
+		timeout := matrixSyncTimeout
+		if since == "" {
+			timeout = 0
+		}
+
+		resp, err := m.sync(ctx, since, timeout)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("⚠️  Matrix sync failed: %v", err)
+			select {
+			case <-time.After(matrixRetryDelay):
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		if since != "" {
+			for room, joined := range resp.Rooms.Join {
+				for i := range joined.Timeline.Events {
+					msg := m.toMessage(room, &joined.Timeline.Events[i])
+					if msg == nil {
+						continue
+					}
+					select {
+					case incoming <- msg:
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				}
+			}
+		}
+		since = resp.NextBatch
+	}
+}
+
+// Send posts a ZenTalk user's message to a Matrix room
+func (m *MatrixRemote) Send(ctx context.Context, msg *Message) error {
+	body, err := json.Marshal(map[string]string{
+		"msgtype": "m.text",
+		"body":    fmt.Sprintf("<%s> %s", msg.DisplayName, msg.Text),
+	})
+	if err != nil {
+		return err
+	}
+
+	// The transaction ID makes a retried request idempotent on the homeserver
+	txnID := m.txnPrefix + fmt.Sprint(m.txnSeq.Add(1))
+	path := "/_matrix/client/v3/rooms/" + url.PathEscape(msg.Room) + "/send/m.room.message/" + url.PathEscape(txnID)
+
+	return m.call(ctx, http.MethodPut, path, nil, body, nil)
+}
+
+// sync fetches events after since, waiting up to timeout for new ones
+func (m *MatrixRemote) sync(ctx context.Context, since string, timeout time.Duration) (*matrixSyncResponse, error) {
+	query := url.Values{"timeout": {fmt.Sprint(timeout.Milliseconds())}}
+	if since != "" {
+		query.Set("since", since)
+	}
+
+	var resp matrixSyncResponse
+	if err := m.call(ctx, http.MethodGet, "/_matrix/client/v3/sync", query, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// toMessage converts a timeline event, or returns nil for events that aren't bridged
+func (m *MatrixRemote) toMessage(room string, event *matrixEvent) *Message {
+	if event.Type != "m.room.message" || event.Sender == m.config.UserID {
+		return nil
+	}
+	if event.Content.MsgType != "m.text" && event.Content.MsgType != "m.notice" {
+		return nil
+	}
+
+	return &Message{
+		Room:        room,
+		User:        event.Sender,
+		DisplayName: matrixLocalpart(event.Sender),
+		Text:        event.Content.Body,
+		Time:        time.UnixMilli(event.OriginServerTS),
+	}
+}
+
+// call sends an authenticated request and decodes the JSON response into out
+func (m *MatrixRemote) call(ctx context.Context, method, path string, query url.Values, body []byte, out interface{}) error {
+	u := m.config.Homeserver + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+m.config.AccessToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := m.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var matrixErr struct {
+			ErrCode string `json:"errcode"`
+			Error   string `json:"error"`
+		}
+		if json.Unmarshal(data, &matrixErr) == nil && matrixErr.ErrCode != "" {
+			return fmt.Errorf("matrix %s %s: %s: %s", method, path, matrixErr.ErrCode, matrixErr.Error)
+		}
+		return fmt.Errorf("matrix %s %s: HTTP %d", method, path, resp.StatusCode)
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("invalid matrix response: %w", err)
+		}
+	}
+	return nil
+}
+
+// matrixLocalpart returns "alice" for "@alice:example.org"
+func matrixLocalpart(userID string) string {
+	localpart := strings.TrimPrefix(userID, "@")
+	if i := strings.IndexByte(localpart, ':'); i >= 0 {
+		localpart = localpart[:i]
+	}
+	return localpart
+}
@@ -0,0 +1,87 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatrixRemote(t *testing.T) {
+	var sentBody map[string]string
+	var sentPath string
+	syncs := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"errcode": "M_UNKNOWN_TOKEN", "error": "bad token"}`))
+			return
+		}
+
+		switch {
+		case r.URL.Path == "/_matrix/client/v3/sync":
+			syncs++
+			if syncs == 1 {
+				// Initial sync: history the bridge must not replay
+				w.Write([]byte(`{"next_batch": "s1", "rooms": {"join": {"!room:example.org": {"timeline": {"events": [
+					{"type": "m.room.message", "sender": "@old:example.org", "content": {"msgtype": "m.text", "body": "old"}}]}}}}}`))
+				return
+			}
+			if syncs > 2 {
+				// Nothing new: hold the long poll until the bridge stops
+				<-r.Context().Done()
+				return
+			}
+			if r.URL.Query().Get("since") != "s1" {
+				t.Errorf("sync since = %q, want s1", r.URL.Query().Get("since"))
+			}
+			w.Write([]byte(`{"next_batch": "s2", "rooms": {"join": {"!room:example.org": {"timeline": {"events": [
+				{"type": "m.room.message", "sender": "@bridge:example.org", "content": {"msgtype": "m.text", "body": "echo"}},
+				{"type": "m.room.member", "sender": "@bob:example.org", "content": {}},
+				{"type": "m.room.message", "sender": "@bob:example.org", "origin_server_ts": 1700000000000, "content": {"msgtype": "m.text", "body": "hi"}}]}}}}}`))
+		case r.Method == http.MethodPut && strings.Contains(r.URL.Path, "/send/m.room.message/"):
+			sentPath = r.URL.Path
+			json.NewDecoder(r.Body).Decode(&sentBody)
+			w.Write([]byte(`{"event_id": "$1"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	remote := NewMatrixRemote(MatrixConfig{Homeserver: server.URL + "/", UserID: "@bridge:example.org", AccessToken: "token"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	incoming := make(chan *Message, 4)
+	go remote.Run(ctx, incoming)
+
+	select {
+	case msg := <-incoming:
+		if msg.Room != "!room:example.org" || msg.User != "@bob:example.org" || msg.DisplayName != "bob" || msg.Text != "hi" {
+			t.Errorf("received %+v", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message received")
+	}
+
+	err := remote.Send(context.Background(), &Message{Room: "!room:example.org", DisplayName: "alice", Text: "hello"})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if sentBody["body"] != "<alice> hello" || sentBody["msgtype"] != "m.text" {
+		t.Errorf("sent body = %v", sentBody)
+	}
+	if !strings.HasPrefix(sentPath, "/_matrix/client/v3/rooms/!room:example.org/send/") {
+		t.Errorf("sent to %s", sentPath)
+	}
+
+	bad := NewMatrixRemote(MatrixConfig{Homeserver: server.URL, AccessToken: "wrong"})
+	if err := bad.Send(context.Background(), &Message{Room: "!room:example.org"}); err == nil || !strings.Contains(err.Error(), "M_UNKNOWN_TOKEN") {
+		t.Errorf("Send(bad token) error = %v", err)
+	}
+}
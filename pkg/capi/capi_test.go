@@ -0,0 +1,197 @@
+package capi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	msg := &protocol.DirectMessage{
+		From:        protocol.Address{1},
+		To:          protocol.Address{2},
+		Timestamp:   1700000000000,
+		ContentType: protocol.ContentTypeText,
+		Content:     []byte("hello"),
+	}
+	value, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoded, err := Encode(uint32(protocol.MsgTypeDirectMessage), value)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if !bytes.Equal(encoded, msg.Encode()) {
+		t.Error("Encode() differs from DirectMessage.Encode()")
+	}
+
+	decoded, err := Decode(uint32(protocol.MsgTypeDirectMessage), encoded)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	var got protocol.DirectMessage
+	if err := json.Unmarshal(decoded, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.From != msg.From || got.To != msg.To || string(got.Content) != "hello" {
+		t.Errorf("Decode() = %s", decoded)
+	}
+
+	if _, err := Decode(0x9999, encoded); !errors.Is(err, ErrUnknownKind) {
+		t.Errorf("Decode(unknown kind) error = %v, want ErrUnknownKind", err)
+	}
+}
+
+func TestX3DHAndRatchet(t *testing.T) {
+	alice, bob := protocol.Address{1}, protocol.Address{2}
+
+	aliceState, err := GenerateX3DHState(1, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobState, err := GenerateX3DHState(2, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, err := KeyBundle(bobState, bob[:])
+	if err != nil {
+		t.Fatalf("KeyBundle() error = %v", err)
+	}
+	decoded, err := Decode(KindKeyBundle, bundle)
+	if err != nil {
+		t.Fatalf("Decode(KindKeyBundle) error = %v", err)
+	}
+	var kb protocol.KeyBundle
+	if err := json.Unmarshal(decoded, &kb); err != nil || kb.Address != bob || len(kb.OneTimePreKeys) != 2 {
+		t.Fatalf("decoded bundle = %s (%v)", decoded, err)
+	}
+
+	aliceSession, initialMsg, err := X3DHInitiate(aliceState, alice[:], bundle)
+	if err != nil {
+		t.Fatalf("X3DHInitiate() error = %v", err)
+	}
+	bobSession, newBobState, err := X3DHRespond(bobState, bob[:], initialMsg)
+	if err != nil {
+		t.Fatalf("X3DHRespond() error = %v", err)
+	}
+
+	var remaining X3DHState
+	if err := json.Unmarshal(newBobState, &remaining); err != nil {
+		t.Fatal(err)
+	}
+	if len(remaining.OneTimePreKeys) != 1 {
+		t.Errorf("one-time prekeys after respond = %d, want 1", len(remaining.OneTimePreKeys))
+	}
+
+	aliceSession, payload, err := RatchetEncrypt(aliceSession, []byte("first"))
+	if err != nil {
+		t.Fatalf("RatchetEncrypt() error = %v", err)
+	}
+
+	// A corrupted payload fails without consuming the session
+	corrupted := append([]byte(nil), payload...)
+	corrupted[len(corrupted)-1] ^= 0xFF
+	if _, _, err := RatchetDecrypt(bobSession, corrupted); err == nil {
+		t.Fatal("RatchetDecrypt(corrupted) succeeded")
+	}
+
+	bobSession, plaintext, err := RatchetDecrypt(bobSession, payload)
+	if err != nil {
+		t.Fatalf("RatchetDecrypt() error = %v", err)
+	}
+	if string(plaintext) != "first" {
+		t.Errorf("RatchetDecrypt() = %q, want %q", plaintext, "first")
+	}
+
+	if _, _, err := RatchetDecrypt(bobSession, payload); err == nil {
+		t.Error("RatchetDecrypt(replay) succeeded")
+	}
+
+	// The state survives the JSON round trip between calls
+	_, payload, err = RatchetEncrypt(aliceSession, []byte("second"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, plaintext, err := RatchetDecrypt(bobSession, payload); err != nil || string(plaintext) != "second" {
+		t.Errorf("second RatchetDecrypt() = %q, %v", plaintext, err)
+	}
+}
+
+func TestX3DHCipherSuites(t *testing.T) {
+	alice, bob := protocol.Address{1}, protocol.Address{2}
+
+	// withSuites generates a state that accepts only suites
+	withSuites := func(registrationID uint32, suites ...protocol.CipherSuite) []byte {
+		t.Helper()
+		state, err := GenerateX3DHState(registrationID, 1, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := parseX3DHState(state)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.CipherSuites = suites
+		state, err = json.Marshal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return state
+	}
+
+	// Bob only accepts ChaCha20; Alice only AES-GCM, so they share nothing
+	bobState := withSuites(2, protocol.CipherSuiteChaCha20Poly1305)
+	bundle, err := KeyBundle(bobState, bob[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliceState := withSuites(1, protocol.CipherSuiteAES256GCM)
+	if _, _, err := X3DHInitiate(aliceState, alice[:], bundle); !errors.Is(err, protocol.ErrCipherSuiteRejected) {
+		t.Errorf("X3DHInitiate() with no common suite error = %v, want ErrCipherSuiteRejected", err)
+	}
+
+	// An initial message on a suite Bob turned off is refused
+	openState, err := GenerateX3DHState(2, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	openBundle, err := KeyBundle(openState, bob[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, initialMsg, err := X3DHInitiate(aliceState, alice[:], openBundle)
+	if err != nil {
+		t.Fatalf("X3DHInitiate() error = %v", err)
+	}
+	s, err := parseX3DHState(openState)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.CipherSuites = []protocol.CipherSuite{protocol.CipherSuiteChaCha20Poly1305}
+	restricted, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := X3DHRespond(restricted, bob[:], initialMsg); !errors.Is(err, protocol.ErrCipherSuiteRejected) {
+		t.Errorf("X3DHRespond() error = %v, want ErrCipherSuiteRejected", err)
+	}
+	if _, _, err := X3DHRespond(openState, bob[:], initialMsg); err != nil {
+		t.Errorf("X3DHRespond() accepting all suites error = %v", err)
+	}
+}
+
+func TestInvalidAddress(t *testing.T) {
+	state, err := GenerateX3DHState(1, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := KeyBundle(state, []byte{1, 2, 3}); !errors.Is(err, ErrInvalidAddress) {
+		t.Errorf("KeyBundle(short address) error = %v, want ErrInvalidAddress", err)
+	}
+}
@@ -0,0 +1,156 @@
+// Package capi is the byte-in, byte-out core behind the libzentalk C library
+//
+// Every function takes and returns plain byte slices so cmd/libzentalk can
+// expose it over a C ABI unchanged: wire formats are the bytes the protocol
+// package produces, and structured values (decoded messages, X3DH key
+// material, ratchet sessions) are JSON with the protocol package's field
+// names. Nothing is kept between calls; callers own and persist all state.
+package capi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// ABIVersion changes whenever a function signature, kind or JSON layout changes incompatibly
+const ABIVersion = 2
+
+// Kinds for values that are not a message payload
+// Payloads are identified by their protocol.MsgType* value.
+const (
+	KindHeader         uint32 = 0x10000 // protocol.Header
+	KindKeyBundle      uint32 = 0x10001 // protocol.KeyBundle
+	KindInitialMessage uint32 = 0x10002 // protocol.InitialMessage
+	KindRatchetHeader  uint32 = 0x10003 // protocol.MessageHeader
+)
+
+var ErrUnknownKind = errors.New("unknown kind")
+
+// codec is implemented by every fixed-format protocol value
+type codec interface {
+	Encode() []byte
+	Decode([]byte) error
+}
+
+// keyBundleCodec adapts KeyBundle, whose decoder is a function
+type keyBundleCodec struct {
+	*protocol.KeyBundle
+}
+
+func (c keyBundleCodec) Decode(buf []byte) error {
+	kb, err := protocol.DecodeKeyBundle(buf)
+	if err != nil {
+		return err
+	}
+	*c.KeyBundle = *kb
+	return nil
+}
+
+// newCodec returns an empty value of the given kind
+func newCodec(kind uint32) (codec, error) {
+	switch kind {
+	case KindHeader:
+		return &protocol.Header{}, nil
+	case KindKeyBundle:
+		return &keyBundleCodec{&protocol.KeyBundle{}}, nil
+	case KindInitialMessage:
+		return &protocol.InitialMessage{}, nil
+	case KindRatchetHeader:
+		return &protocol.MessageHeader{}, nil
+	}
+
+	if kind > 0xFFFF {
+		return nil, fmt.Errorf("%w: %#x", ErrUnknownKind, kind)
+	}
+
+	switch uint16(kind) {
+	case protocol.MsgTypeHandshake, protocol.MsgTypeHandshakeAck:
+		return &protocol.HandshakeMessage{}, nil
+	case protocol.MsgTypeResume:
+		return &protocol.ResumeRequest{}, nil
+	case protocol.MsgTypeResumeAck:
+		return &protocol.ResumeAck{}, nil
+	case protocol.MsgTypeTicket:
+		return &protocol.SessionTicket{}, nil
+	case protocol.MsgTypeQueueProgress:
+		return &protocol.QueueProgress{}, nil
+	case protocol.MsgTypeQueueWindow:
+		return &protocol.QueueWindow{}, nil
+	case protocol.MsgTypeOfflineSync:
+		return &protocol.OfflineSyncRequest{}, nil
+	case protocol.MsgTypeOfflineSyncResponse:
+		return &protocol.OfflineSyncResponse{}, nil
+	case protocol.MsgTypeRelayForward:
+		return &protocol.RelayForward{}, nil
+	case protocol.MsgTypeRelayError:
+		return &protocol.RelayErrorMessage{}, nil
+	case protocol.MsgTypeRelayMoved:
+		return &protocol.RelayMovedNotice{}, nil
+	case protocol.MsgTypeRouteUpdate:
+		return &protocol.RouteUpdate{}, nil
+	case protocol.MsgTypeRoutedMessage:
+		return &protocol.RoutedMessage{}, nil
+	case protocol.MsgTypeDirectMessage:
+		return &protocol.DirectMessage{}, nil
+	case protocol.MsgTypeGroupMessage:
+		return &protocol.GroupMessage{}, nil
+	case protocol.MsgTypeTyping:
+		return &protocol.TypingIndicator{}, nil
+	case protocol.MsgTypeReadReceipt:
+		return &protocol.ReadReceipt{}, nil
+	case protocol.MsgTypePresence:
+		return &protocol.PresenceUpdate{}, nil
+	case protocol.MsgTypeDeviceFanout:
+		return &protocol.DeviceFanout{}, nil
+	case protocol.MsgTypeProfileUpdate:
+		return &protocol.ProfileUpdate{}, nil
+	case protocol.MsgTypeGroupCreate:
+		return &protocol.GroupCreateMessage{}, nil
+	case protocol.MsgTypeGroupJoin:
+		return &protocol.GroupJoinMessage{}, nil
+	case protocol.MsgTypeGroupLeave:
+		return &protocol.GroupLeaveMessage{}, nil
+	case protocol.MsgTypeGroupUpdate:
+		return &protocol.GroupUpdateMessage{}, nil
+	case protocol.MsgTypeKeyBundlePublish:
+		return &keyBundleCodec{&protocol.KeyBundle{}}, nil
+	case protocol.MsgTypeKeyBundleRequest:
+		return &protocol.KeyBundleRequest{}, nil
+	case protocol.MsgTypeKeyBundleResponse:
+		return &protocol.KeyBundleResponse{}, nil
+	case protocol.MsgTypeMediaDirectory:
+		return &protocol.MediaDirectory{}, nil
+	case protocol.MsgTypeAck:
+		return &protocol.AckMessage{}, nil
+	case protocol.MsgTypeNack:
+		return &protocol.NackMessage{}, nil
+	}
+	return nil, fmt.Errorf("%w: %#x", ErrUnknownKind, kind)
+}
+
+// Encode encodes a JSON value of the given kind to its wire format
+func Encode(kind uint32, value []byte) ([]byte, error) {
+	c, err := newCodec(kind)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(value, c); err != nil {
+		return nil, fmt.Errorf("invalid JSON for kind %#x: %w", kind, err)
+	}
+	return c.Encode(), nil
+}
+
+// Decode decodes wire bytes of the given kind to JSON
+func Decode(kind uint32, data []byte) ([]byte, error) {
+	c, err := newCodec(kind)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Decode(data); err != nil {
+		return nil, err
+	}
+	return json.Marshal(c)
+}
@@ -0,0 +1,265 @@
+package capi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+var (
+	ErrInvalidAddress = errors.New("address must be 20 bytes")
+	ErrInvalidPayload = errors.New("invalid ratchet payload")
+	ErrNoPrekeys      = errors.New("X3DH state has no identity or signed prekey")
+)
+
+// X3DHState is a user's private X3DH key material
+// Same JSON layout as the x3dh_state.json written by network.SessionStorage,
+// plus the cipher suites the caller accepts.
+type X3DHState struct {
+	IdentityKeyPair *protocol.IdentityKeyPair                 `json:"identity"`
+	SignedPreKey    *protocol.SignedPreKeyPrivate             `json:"signed_prekey"`
+	OneTimePreKeys  map[string]*protocol.OneTimePreKeyPrivate `json:"one_time_prekeys"` // key is string(uint32)
+	RegistrationID  uint32                                    `json:"registration_id"`
+	CipherSuites    []protocol.CipherSuite                    `json:"cipher_suites,omitempty"` // Accepted, preferred first (none = all registered)
+}
+
+// GenerateX3DHState generates an identity, a signed prekey and oneTimePreKeys one-time prekeys
+func GenerateX3DHState(registrationID, signedPreKeyID uint32, oneTimePreKeys int) ([]byte, error) {
+	identity, err := protocol.GenerateIdentityKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	spk, err := protocol.GenerateSignedPreKey(signedPreKeyID, identity)
+	if err != nil {
+		return nil, err
+	}
+	opks, err := protocol.GenerateOneTimePreKeys(1, oneTimePreKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &X3DHState{
+		IdentityKeyPair: identity,
+		SignedPreKey:    spk,
+		OneTimePreKeys:  make(map[string]*protocol.OneTimePreKeyPrivate, len(opks)),
+		RegistrationID:  registrationID,
+	}
+	for _, opk := range opks {
+		state.OneTimePreKeys[strconv.FormatUint(uint64(opk.KeyID), 10)] = opk
+	}
+	return json.Marshal(state)
+}
+
+// KeyBundle encodes the public key bundle to publish for address
+func KeyBundle(state []byte, address []byte) ([]byte, error) {
+	s, err := parseX3DHState(state)
+	if err != nil {
+		return nil, err
+	}
+	addr, err := parseAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := protocol.CreateKeyBundle(addr, s.IdentityKeyPair, s.SignedPreKey, s.oneTimePreKeyList(), s.RegistrationID)
+	if suites := s.cipherSuites(); suites != nil {
+		bundle.CipherSuites = suites
+	}
+	return bundle.Encode(), nil
+}
+
+// X3DHInitiate starts a session with the owner of an encoded key bundle
+// Returns the new ratchet session and the encoded initial message to deliver
+// to the recipient ahead of the first ratchet message.
+func X3DHInitiate(state []byte, address []byte, bundle []byte) (session []byte, initialMessage []byte, err error) {
+	s, err := parseX3DHState(state)
+	if err != nil {
+		return nil, nil, err
+	}
+	addr, err := parseAddress(address)
+	if err != nil {
+		return nil, nil, err
+	}
+	kb, err := protocol.DecodeKeyBundle(bundle)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sharedSecret, ephemPriv, ephemPub, initialMsg, err := protocol.X3DHInitiator(addr, s.IdentityKeyPair, kb)
+	if err != nil {
+		return nil, nil, fmt.Errorf("X3DH failed: %w", err)
+	}
+
+	ratchet, err := protocol.NewRatchetState(sharedSecret, kb.SignedPreKey.PublicKey, ephemPriv, ephemPub, addr, kb.Address)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize ratchet state: %w", err)
+	}
+	ours := s.cipherSuites()
+	if ours == nil {
+		ours = protocol.PreferredCipherSuites()
+	}
+	if initialMsg.CipherSuite, err = protocol.AgreeCipherSuite(ours, kb.CipherSuites); err != nil {
+		return nil, nil, err
+	}
+	ratchet.CipherSuite = initialMsg.CipherSuite
+
+	session, err = json.Marshal(ratchet)
+	if err != nil {
+		return nil, nil, err
+	}
+	return session, initialMsg.Encode(), nil
+}
+
+// X3DHRespond accepts an encoded initial message addressed to us
+// Returns the new ratchet session and the updated X3DH state, which no longer
+// holds the consumed one-time prekey and must replace the caller's copy.
+func X3DHRespond(state []byte, address []byte, initialMessage []byte) (session []byte, newState []byte, err error) {
+	s, err := parseX3DHState(state)
+	if err != nil {
+		return nil, nil, err
+	}
+	addr, err := parseAddress(address)
+	if err != nil {
+		return nil, nil, err
+	}
+	var initialMsg protocol.InitialMessage
+	if err := initialMsg.Decode(initialMessage); err != nil {
+		return nil, nil, err
+	}
+
+	opks := make(map[uint32]*protocol.OneTimePreKeyPrivate, len(s.OneTimePreKeys))
+	for _, opk := range s.OneTimePreKeys {
+		opks[opk.KeyID] = opk
+	}
+
+	sharedSecret, err := protocol.X3DHResponder(s.IdentityKeyPair, s.SignedPreKey, opks, &initialMsg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("X3DH responder failed: %w", err)
+	}
+
+	ratchet, err := protocol.NewRatchetStateResponder(sharedSecret, s.SignedPreKey, &initialMsg, addr, s.cipherSuites())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	delete(s.OneTimePreKeys, strconv.FormatUint(uint64(initialMsg.UsedOneTimePreKeyID), 10))
+
+	if session, err = json.Marshal(ratchet); err != nil {
+		return nil, nil, err
+	}
+	if newState, err = json.Marshal(s); err != nil {
+		return nil, nil, err
+	}
+	return session, newState, nil
+}
+
+// RatchetEncrypt encrypts plaintext with a ratchet session
+// The payload is [header length 2][header][ciphertext], as network.Client sends it.
+func RatchetEncrypt(session []byte, plaintext []byte) (newSession []byte, payload []byte, err error) {
+	ratchet, err := parseSession(session)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	header, ciphertext, err := ratchet.Seal(plaintext)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ratchet encryption failed: %w", err)
+	}
+
+	payload = make([]byte, 2+len(header)+len(ciphertext))
+	payload[0] = byte(len(header) >> 8)
+	payload[1] = byte(len(header))
+	copy(payload[2:], header)
+	copy(payload[2+len(header):], ciphertext)
+
+	if newSession, err = json.Marshal(ratchet); err != nil {
+		return nil, nil, err
+	}
+	return newSession, payload, nil
+}
+
+// RatchetDecrypt decrypts a payload produced by RatchetEncrypt
+// On error the caller's session is unchanged and remains usable.
+func RatchetDecrypt(session []byte, payload []byte) (newSession []byte, plaintext []byte, err error) {
+	ratchet, err := parseSession(session)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(payload) < 2 {
+		return nil, nil, ErrInvalidPayload
+	}
+	headerLen := int(payload[0])<<8 | int(payload[1])
+	if len(payload) < 2+headerLen {
+		return nil, nil, ErrInvalidPayload
+	}
+
+	plaintext, err = ratchet.Open(payload[2:2+headerLen], payload[2+headerLen:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if newSession, err = json.Marshal(ratchet); err != nil {
+		return nil, nil, err
+	}
+	return newSession, plaintext, nil
+}
+
+// parseX3DHState decodes X3DH state JSON
+func parseX3DHState(data []byte) (*X3DHState, error) {
+	var s X3DHState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("invalid X3DH state: %w", err)
+	}
+	if s.IdentityKeyPair == nil || s.SignedPreKey == nil {
+		return nil, ErrNoPrekeys
+	}
+	if s.OneTimePreKeys == nil {
+		s.OneTimePreKeys = make(map[string]*protocol.OneTimePreKeyPrivate)
+	}
+	return &s, nil
+}
+
+// oneTimePreKeyList returns the one-time prekeys in ID order
+func (s *X3DHState) oneTimePreKeyList() []*protocol.OneTimePreKeyPrivate {
+	opks := make([]*protocol.OneTimePreKeyPrivate, 0, len(s.OneTimePreKeys))
+	for _, opk := range s.OneTimePreKeys {
+		opks = append(opks, opk)
+	}
+	sort.Slice(opks, func(i, j int) bool { return opks[i].KeyID < opks[j].KeyID })
+	return opks
+}
+
+// cipherSuites returns the suites the state accepts (nil = all registered)
+func (s *X3DHState) cipherSuites() []protocol.CipherSuite {
+	if len(s.CipherSuites) == 0 {
+		return nil
+	}
+	return s.CipherSuites
+}
+
+// parseSession decodes ratchet session JSON
+func parseSession(data []byte) (*protocol.RatchetState, error) {
+	var state protocol.RatchetState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("invalid ratchet session: %w", err)
+	}
+	if state.SkippedMessageKeys == nil {
+		state.SkippedMessageKeys = make(map[protocol.MessageKeyID]protocol.MessageKey)
+	}
+	return &state, nil
+}
+
+// parseAddress checks a raw 20-byte address
+func parseAddress(b []byte) (protocol.Address, error) {
+	var addr protocol.Address
+	if len(b) != len(addr) {
+		return addr, ErrInvalidAddress
+	}
+	copy(addr[:], b)
+	return addr, nil
+}
@@ -0,0 +1,117 @@
+// Package chaos injects faults into relay and storage nodes for resilience testing
+//
+// Hooks are compiled in only with the "chaos" build tag:
+//
+//	go build -tags chaos ./cmd/relay
+//
+// Without the tag every hook is a no-op the compiler inlines away, so production
+// binaries cannot inject faults whatever their configuration says. With the tag,
+// faults are configured from ZENTALK_CHAOS at startup and may be changed at runtime
+// with Configure (the mesh API exposes this as /api/v1/admin/chaos).
+//
+// Fault points are named "<component>.<operation>", e.g. "relay.forward" or
+// "storage.shard"; Stats counts injections per point.
+package chaos
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvVar holds the fault spec applied at startup in chaos builds
+const EnvVar = "ZENTALK_CHAOS"
+
+// ErrDisabled is returned when configuring a binary built without the chaos tag
+var ErrDisabled = errors.New("chaos hooks not compiled in (build with -tags chaos)")
+
+// Config sets the probability of each fault, applied independently at every hook
+type Config struct {
+	DropRate       float64       `json:"drop"`       // Message or response silently discarded
+	DelayRate      float64       `json:"delay"`      // Handling paused for up to MaxDelay
+	MaxDelay       time.Duration `json:"maxDelay"`   // Upper bound of an injected delay
+	CorruptRate    float64       `json:"corrupt"`    // One byte of a shard flipped before it is served
+	DisconnectRate float64       `json:"disconnect"` // Connection or stream closed abruptly
+}
+
+// DefaultMaxDelay is used when a delay rate is set without a bound
+const DefaultMaxDelay = 500 * time.Millisecond
+
+// ParseConfig parses a spec such as "drop=0.05,delay=0.1,max-delay=2s,corrupt=0.01,disconnect=0.01"
+// An empty spec is the zero Config, which injects nothing
+func ParseConfig(spec string) (Config, error) {
+	var cfg Config
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return Config{}, fmt.Errorf("invalid chaos setting %q: expected key=value", field)
+		}
+
+		if key == "max-delay" {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return Config{}, fmt.Errorf("invalid max-delay: %w", err)
+			}
+			cfg.MaxDelay = d
+			continue
+		}
+
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid %s rate: %w", key, err)
+		}
+		switch key {
+		case "drop":
+			cfg.DropRate = rate
+		case "delay":
+			cfg.DelayRate = rate
+		case "corrupt":
+			cfg.CorruptRate = rate
+		case "disconnect":
+			cfg.DisconnectRate = rate
+		default:
+			return Config{}, fmt.Errorf("unknown chaos setting %q", key)
+		}
+	}
+
+	if cfg.DelayRate > 0 && cfg.MaxDelay == 0 {
+		cfg.MaxDelay = DefaultMaxDelay
+	}
+	return cfg, cfg.Validate()
+}
+
+// Validate checks that rates are probabilities and the delay bound is sane
+func (c Config) Validate() error {
+	rates := map[string]float64{
+		"drop":       c.DropRate,
+		"delay":      c.DelayRate,
+		"corrupt":    c.CorruptRate,
+		"disconnect": c.DisconnectRate,
+	}
+	for name, rate := range rates {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("%s rate %v must be between 0 and 1", name, rate)
+		}
+	}
+	if c.MaxDelay < 0 {
+		return fmt.Errorf("max-delay must not be negative")
+	}
+	return nil
+}
+
+// Active reports whether any fault can fire
+func (c Config) Active() bool {
+	return c.DropRate > 0 || c.DelayRate > 0 || c.CorruptRate > 0 || c.DisconnectRate > 0
+}
+
+// String formats the config in ParseConfig syntax
+func (c Config) String() string {
+	return fmt.Sprintf("drop=%g,delay=%g,max-delay=%s,corrupt=%g,disconnect=%g",
+		c.DropRate, c.DelayRate, c.MaxDelay, c.CorruptRate, c.DisconnectRate)
+}
@@ -0,0 +1,39 @@
+package chaos
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := ParseConfig("drop=0.05, delay=0.1,max-delay=2s,corrupt=0.01,disconnect=0.5")
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+	want := Config{DropRate: 0.05, DelayRate: 0.1, MaxDelay: 2 * time.Second, CorruptRate: 0.01, DisconnectRate: 0.5}
+	if cfg != want {
+		t.Errorf("got %+v, want %+v", cfg, want)
+	}
+
+	// String round-trips through ParseConfig
+	again, err := ParseConfig(cfg.String())
+	if err != nil || again != cfg {
+		t.Errorf("round trip of %q gave %+v, %v", cfg.String(), again, err)
+	}
+
+	cfg, err = ParseConfig("delay=0.2")
+	if err != nil || cfg.MaxDelay != DefaultMaxDelay {
+		t.Errorf("expected default max delay, got %+v, %v", cfg, err)
+	}
+
+	cfg, err = ParseConfig("")
+	if err != nil || cfg.Active() {
+		t.Errorf("empty spec should inject nothing, got %+v, %v", cfg, err)
+	}
+
+	for _, bad := range []string{"drop", "drop=abc", "drop=1.5", "corrupt=-0.1", "explode=0.1", "max-delay=soon"} {
+		if _, err := ParseConfig(bad); err == nil {
+			t.Errorf("ParseConfig(%q) should fail", bad)
+		}
+	}
+}
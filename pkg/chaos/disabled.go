@@ -0,0 +1,27 @@
+//go:build !chaos
+
+package chaos
+
+// Enabled reports whether fault injection is compiled in
+const Enabled = false
+
+// Configure always fails: this binary was built without the chaos tag
+func Configure(Config) error { return ErrDisabled }
+
+// Current returns the zero Config
+func Current() Config { return Config{} }
+
+// Stats returns no injections
+func Stats() map[string]uint64 { return nil }
+
+// Drop never drops
+func Drop(string) bool { return false }
+
+// Delay never delays
+func Delay(string) {}
+
+// Corrupt returns data unchanged
+func Corrupt(_ string, data []byte) []byte { return data }
+
+// Disconnect never disconnects
+func Disconnect(string) bool { return false }
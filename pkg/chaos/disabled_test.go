@@ -0,0 +1,17 @@
+//go:build !chaos
+
+package chaos
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDisabledBuildInjectsNothing(t *testing.T) {
+	if err := Configure(Config{DropRate: 1}); !errors.Is(err, ErrDisabled) {
+		t.Errorf("Configure should fail without the chaos tag, got %v", err)
+	}
+	if Drop("test.point") || Disconnect("test.point") {
+		t.Error("fault fired in a non-chaos build")
+	}
+}
@@ -0,0 +1,137 @@
+//go:build chaos
+
+package chaos
+
+import (
+	"log"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+)
+
+// Enabled reports whether fault injection is compiled in
+const Enabled = true
+
+var (
+	mu     sync.Mutex
+	config Config
+	rng    = rand.New(rand.NewSource(time.Now().UnixNano()))
+	counts = make(map[string]uint64)
+)
+
+func init() {
+	log.Printf("⚠️  CHAOS BUILD: fault injection is compiled in; never deploy this binary to production")
+
+	spec := os.Getenv(EnvVar)
+	if spec == "" {
+		return
+	}
+	cfg, err := ParseConfig(spec)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", EnvVar, err)
+	}
+	Configure(cfg)
+}
+
+// Configure replaces the fault configuration
+func Configure(cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if cfg.DelayRate > 0 && cfg.MaxDelay == 0 {
+		cfg.MaxDelay = DefaultMaxDelay
+	}
+
+	mu.Lock()
+	config = cfg
+	mu.Unlock()
+
+	log.Printf("🌀 Chaos configured: %s", cfg)
+	return nil
+}
+
+// Current returns the fault configuration in effect
+func Current() Config {
+	mu.Lock()
+	defer mu.Unlock()
+	return config
+}
+
+// Stats returns how many faults were injected at each point
+func Stats() map[string]uint64 {
+	mu.Lock()
+	defer mu.Unlock()
+	out := make(map[string]uint64, len(counts))
+	for point, n := range counts {
+		out[point] = n
+	}
+	return out
+}
+
+// roll reports whether a fault with the given rate fires, counting it under kind
+func roll(point, kind string, rate func(Config) float64) (bool, Config) {
+	mu.Lock()
+	defer mu.Unlock()
+	r := rate(config)
+	if r <= 0 || rng.Float64() >= r {
+		return false, config
+	}
+	counts[point+":"+kind]++
+	return true, config
+}
+
+// Drop reports whether the message at point should be discarded
+func Drop(point string) bool {
+	fire, _ := roll(point, "drop", func(c Config) float64 { return c.DropRate })
+	if fire {
+		log.Printf("🌀 chaos: dropped at %s", point)
+	}
+	return fire
+}
+
+// Delay pauses the caller for a random time up to MaxDelay
+func Delay(point string) {
+	fire, cfg := roll(point, "delay", func(c Config) float64 { return c.DelayRate })
+	if !fire || cfg.MaxDelay <= 0 {
+		return
+	}
+
+	mu.Lock()
+	d := time.Duration(rng.Int63n(int64(cfg.MaxDelay)))
+	mu.Unlock()
+
+	log.Printf("🌀 chaos: delaying %s by %v", point, d)
+	time.Sleep(d)
+}
+
+// Corrupt returns data with one byte flipped, or data unchanged
+// The input is never modified; a corrupted copy is returned
+func Corrupt(point string, data []byte) []byte {
+	if len(data) == 0 {
+		return data
+	}
+	fire, _ := roll(point, "corrupt", func(c Config) float64 { return c.CorruptRate })
+	if !fire {
+		return data
+	}
+
+	mu.Lock()
+	i := rng.Intn(len(data))
+	mu.Unlock()
+
+	corrupted := make([]byte, len(data))
+	copy(corrupted, data)
+	corrupted[i] ^= 0xFF
+	log.Printf("🌀 chaos: corrupted byte %d at %s", i, point)
+	return corrupted
+}
+
+// Disconnect reports whether the connection at point should be closed abruptly
+func Disconnect(point string) bool {
+	fire, _ := roll(point, "disconnect", func(c Config) float64 { return c.DisconnectRate })
+	if fire {
+		log.Printf("🌀 chaos: disconnecting at %s", point)
+	}
+	return fire
+}
@@ -0,0 +1,51 @@
+//go:build chaos
+
+package chaos
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestFaultInjection(t *testing.T) {
+	defer Configure(Config{})
+
+	data := []byte("shard contents")
+
+	if err := Configure(Config{}); err != nil {
+		t.Fatal(err)
+	}
+	if Drop("test.point") || Disconnect("test.point") {
+		t.Error("faults fired with zero rates")
+	}
+	if !bytes.Equal(Corrupt("test.point", data), data) {
+		t.Error("data corrupted with zero rate")
+	}
+
+	if err := Configure(Config{DropRate: 1, CorruptRate: 1, DisconnectRate: 1, DelayRate: 1, MaxDelay: time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	if !Drop("test.point") || !Disconnect("test.point") {
+		t.Error("faults did not fire with rate 1")
+	}
+	corrupted := Corrupt("test.point", data)
+	if bytes.Equal(corrupted, data) {
+		t.Error("data not corrupted with rate 1")
+	}
+	if !bytes.Equal(data, []byte("shard contents")) {
+		t.Error("Corrupt modified its input")
+	}
+	Delay("test.point")
+
+	stats := Stats()
+	for _, key := range []string{"test.point:drop", "test.point:disconnect", "test.point:corrupt", "test.point:delay"} {
+		if stats[key] == 0 {
+			t.Errorf("no injection counted for %s", key)
+		}
+	}
+
+	if err := Configure(Config{DropRate: 2}); err == nil {
+		t.Error("invalid config accepted")
+	}
+}
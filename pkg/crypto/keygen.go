@@ -0,0 +1,15 @@
+//go:build !js
+
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+)
+
+// GenerateRSAKeyPair generates a new RSA-4096 key pair
+// Not built for the browser, where generating a 4096-bit key in WebAssembly
+// takes minutes; web clients generate keys with WebCrypto and import the PEM.
+func GenerateRSAKeyPair() (*rsa.PrivateKey, error) {
+	return rsa.GenerateKey(rand.Reader, 4096)
+}
@@ -7,7 +7,10 @@ import (
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
+	"io"
 	"os"
+
+	"golang.org/x/crypto/hkdf"
 )
 
 var (
@@ -16,11 +19,6 @@ var (
 	ErrDecryptionFailed = errors.New("decryption failed")
 )
 
-// GenerateRSAKeyPair generates a new RSA-4096 key pair
-func GenerateRSAKeyPair() (*rsa.PrivateKey, error) {
-	return rsa.GenerateKey(rand.Reader, 4096)
-}
-
 // ExportPrivateKeyPEM exports private key to PEM format
 func ExportPrivateKeyPEM(key *rsa.PrivateKey) ([]byte, error) {
 	privASN1 := x509.MarshalPKCS1PrivateKey(key)
@@ -55,6 +53,19 @@ func ImportPrivateKeyPEM(pemData []byte) (*rsa.PrivateKey, error) {
 		return nil, ErrInvalidKey
 	}
 
+	// Keys exported by WebCrypto and OpenSSL 3 are PKCS#8
+	if block.Type == "PRIVATE KEY" {
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := parsed.(*rsa.PrivateKey)
+		if !ok {
+			return nil, ErrInvalidKey
+		}
+		return key, nil
+	}
+
 	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
 	if err != nil {
 		return nil, err
@@ -135,3 +146,28 @@ func VerifySignature(data []byte, signature []byte, publicKey *rsa.PublicKey) er
 
 	return rsa.VerifyPKCS1v15(publicKey, 0, hashed, signature)
 }
+
+// DeriveBotAPIKey derives a bot's API key from its identity key
+// The key is never stored: the bot rederives it at startup and the relay
+// operator registers the same value. A new label gives a new key, e.g. after a leak.
+func DeriveBotAPIKey(identityKey *rsa.PrivateKey, label string) ([]byte, error) {
+	reader := hkdf.New(sha256.New, x509.MarshalPKCS1PrivateKey(identityKey), nil, []byte("zentalk-bot-api-key:"+label))
+
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(reader, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// DeriveStandbyKey derives the AES key a relay and its warm standby replicate with
+// Both run with the same identity key, so only they can read or forge replication traffic.
+func DeriveStandbyKey(identityKey *rsa.PrivateKey) ([]byte, error) {
+	reader := hkdf.New(sha256.New, x509.MarshalPKCS1PrivateKey(identityKey), nil, []byte("zentalk-relay-standby"))
+
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(reader, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
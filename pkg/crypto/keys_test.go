@@ -1,8 +1,14 @@
+//go:build !js
+
 package crypto
 
 import (
 	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
 	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
 	"os"
 	"path/filepath"
 	"strings"
@@ -68,6 +74,41 @@ func TestExportImportPrivateKeyPEM(t *testing.T) {
 	}
 }
 
+func TestImportPrivateKeyPEMPKCS8(t *testing.T) {
+	originalKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// WebCrypto exports private keys as PKCS#8
+	der, err := x509.MarshalPKCS8PrivateKey(originalKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	importedKey, err := ImportPrivateKeyPEM(pemData)
+	if err != nil {
+		t.Fatalf("ImportPrivateKeyPEM(PKCS#8) error = %v", err)
+	}
+	if !originalKey.Equal(importedKey) {
+		t.Error("ImportPrivateKeyPEM(PKCS#8) key mismatch")
+	}
+
+	// PKCS#8 keys that aren't RSA are rejected
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err = x509.MarshalPKCS8PrivateKey(edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ImportPrivateKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})); err != ErrInvalidKey {
+		t.Errorf("ImportPrivateKeyPEM(Ed25519) error = %v, want ErrInvalidKey", err)
+	}
+}
+
 func TestExportImportPublicKeyPEM(t *testing.T) {
 	// Generate a key
 	privateKey, _ := GenerateRSAKeyPair()
@@ -405,3 +446,27 @@ func TestExportImportRoundtrip(t *testing.T) {
 		t.Error("Export/Import roundtrip failed: signature verification failed")
 	}
 }
+
+func TestDeriveBotAPIKey(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	apiKey, err := DeriveBotAPIKey(key, "default")
+	if err != nil {
+		t.Fatalf("DeriveBotAPIKey() error = %v", err)
+	}
+	if len(apiKey) != 32 {
+		t.Errorf("DeriveBotAPIKey() length = %d, want 32", len(apiKey))
+	}
+
+	again, _ := DeriveBotAPIKey(key, "default")
+	if !bytes.Equal(apiKey, again) {
+		t.Error("DeriveBotAPIKey() is not deterministic")
+	}
+
+	rotated, _ := DeriveBotAPIKey(key, "2026-rotation")
+	otherKey, _ := DeriveBotAPIKey(other, "default")
+	if bytes.Equal(apiKey, rotated) || bytes.Equal(apiKey, otherKey) {
+		t.Error("DeriveBotAPIKey() returned the same key for a different label or identity")
+	}
+}
@@ -1,12 +1,14 @@
 package crypto
 
 import (
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/rsa"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 
 	"github.com/ZentaChain/zentalk-node/pkg/protocol"
@@ -24,17 +26,32 @@ type OnionLayer struct {
 	PayloadHash protocol.Hash    // BLAKE2b hash of payload
 }
 
+// onionLayerOverhead is what a layer adds to its JSON besides the encrypted AES key:
+// the 2-byte key length plus the AES-GCM nonce and tag
+const onionLayerOverhead = 2 + 12 + 16
+
 // RelayInfo contains relay routing information
 type RelayInfo struct {
 	Address   protocol.Address
 	PublicKey *rsa.PublicKey
+
+	// Sizes the relay expects its layer padded to, ascending (see protocol.RelayPolicy.PaddingBuckets)
+	PaddingBuckets []uint32
 }
 
 // BuildOnionLayers builds onion layers for a message using hybrid encryption
+// Layers for relays with padding buckets are padded up to one of them, so a
+// relay sees the same few sizes whatever the message. The padding is JSON
+// whitespace after the layer, which relays without buckets also parse.
 // path: ordered list of relay info (first to last)
 // recipientAddr: final recipient address
 // finalPayload: the actual message to deliver
 func BuildOnionLayers(path []*RelayInfo, recipientAddr protocol.Address, finalPayload []byte) ([]byte, error) {
+	return BuildOnionLayersContext(context.Background(), path, recipientAddr, finalPayload)
+}
+
+// BuildOnionLayersContext builds onion layers, giving up between layers once ctx is done
+func BuildOnionLayersContext(ctx context.Context, path []*RelayInfo, recipientAddr protocol.Address, finalPayload []byte) ([]byte, error) {
 	if len(path) == 0 {
 		return nil, ErrInvalidPath
 	}
@@ -44,6 +61,10 @@ func BuildOnionLayers(path []*RelayInfo, recipientAddr protocol.Address, finalPa
 
 	// Build layers from inside out (reverse order)
 	for i := len(path) - 1; i >= 0; i-- {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		// Create layer
 		layer := &OnionLayer{
 			Payload: currentPayload,
@@ -78,14 +99,17 @@ func BuildOnionLayers(path []*RelayInfo, recipientAddr protocol.Address, finalPa
 			return nil, err
 		}
 
-		// Encrypt layer data with AES
-		encryptedData, err := AESEncrypt(layerJSON, aesKey)
+		// Encrypt AES key with RSA
+		encryptedKey, err := RSAEncrypt(aesKey, path[i].PublicKey)
 		if err != nil {
 			return nil, err
 		}
 
-		// Encrypt AES key with RSA
-		encryptedKey, err := RSAEncrypt(aesKey, path[i].PublicKey)
+		// Pad to the relay's bucket now the key's size is known
+		layerJSON = padOnionLayer(layerJSON, len(encryptedKey), path[i].PaddingBuckets)
+
+		// Encrypt layer data with AES
+		encryptedData, err := AESEncrypt(layerJSON, aesKey)
 		if err != nil {
 			return nil, err
 		}
@@ -105,16 +129,32 @@ func BuildOnionLayers(path []*RelayInfo, recipientAddr protocol.Address, finalPa
 	return currentPayload, nil
 }
 
+// padOnionLayer appends whitespace to a layer's JSON so the encrypted layer fills a bucket
+func padOnionLayer(layerJSON []byte, keyLen int, buckets []uint32) []byte {
+	overhead := onionLayerOverhead + keyLen
+	size := BucketSize(overhead+len(layerJSON), buckets)
+	if size == overhead+len(layerJSON) {
+		return layerJSON
+	}
+
+	padded := make([]byte, size-overhead)
+	copy(padded, layerJSON)
+	for j := len(layerJSON); j < len(padded); j++ {
+		padded[j] = ' '
+	}
+	return padded
+}
+
 // DecryptOnionLayer decrypts one layer of the onion using hybrid encryption
 func DecryptOnionLayer(encryptedLayer []byte, privateKey *rsa.PrivateKey) (*OnionLayer, error) {
 	// Extract key length
 	if len(encryptedLayer) < 2 {
-		return nil, errors.New("encrypted layer too short")
+		return nil, fmt.Errorf("%w: encrypted layer too short", ErrInvalidOnionLayer)
 	}
 
 	keyLen := uint16(encryptedLayer[0])<<8 | uint16(encryptedLayer[1])
 	if len(encryptedLayer) < int(2+keyLen) {
-		return nil, errors.New("encrypted layer incomplete")
+		return nil, fmt.Errorf("%w: encrypted layer incomplete", ErrInvalidOnionLayer)
 	}
 
 	// Extract encrypted AES key and encrypted data
@@ -146,7 +186,7 @@ func DecryptOnionLayer(encryptedLayer []byte, privateKey *rsa.PrivateKey) (*Onio
 	}
 
 	if !valid {
-		return nil, errors.New("payload hash mismatch")
+		return nil, fmt.Errorf("%w: payload hash mismatch", ErrInvalidOnionLayer)
 	}
 
 	return &layer, nil
@@ -187,13 +227,13 @@ func AESDecrypt(ciphertext []byte, key []byte) ([]byte, error) {
 
 	nonceSize := gcm.NonceSize()
 	if len(ciphertext) < nonceSize {
-		return nil, errors.New("ciphertext too short")
+		return nil, fmt.Errorf("%w: ciphertext too short", ErrDecryptionFailed)
 	}
 
 	nonce, ciphertext := ciphertext[:nonceSize], ciphertext[nonceSize:]
 	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %w", ErrDecryptionFailed, err)
 	}
 
 	return plaintext, nil
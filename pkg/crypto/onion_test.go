@@ -1,7 +1,11 @@
+//go:build !js
+
 package crypto
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"testing"
 
 	"github.com/ZentaChain/zentalk-node/pkg/protocol"
@@ -318,6 +322,77 @@ func TestBuildOnionLayersEmptyPath(t *testing.T) {
 	}
 }
 
+func TestBuildOnionLayersContextCancelled(t *testing.T) {
+	relayKey, _ := GenerateRSAKeyPair()
+	relayPath := []*RelayInfo{{Address: protocol.Address{1}, PublicKey: &relayKey.PublicKey}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := BuildOnionLayersContext(ctx, relayPath, protocol.Address{2}, []byte("late")); !errors.Is(err, context.Canceled) {
+		t.Errorf("BuildOnionLayersContext() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestBuildOnionLayersPaddingBuckets(t *testing.T) {
+	relay1Key, _ := GenerateRSAKeyPair()
+	relay2Key, _ := GenerateRSAKeyPair()
+	relayPath := []*RelayInfo{
+		{Address: protocol.Address{1}, PublicKey: &relay1Key.PublicKey, PaddingBuckets: []uint32{4096, 8192}},
+		{Address: protocol.Address{2}, PublicKey: &relay2Key.PublicKey, PaddingBuckets: []uint32{1024, 2048}},
+	}
+	recipientAddr := protocol.Address{3}
+
+	for _, payload := range [][]byte{bytes.Repeat([]byte("x"), 600), bytes.Repeat([]byte("x"), 800)} {
+		onion, err := BuildOnionLayers(relayPath, recipientAddr, payload)
+		if err != nil {
+			t.Fatalf("BuildOnionLayers() error = %v", err)
+		}
+		if len(onion) != 4096 {
+			t.Errorf("%d-byte payload: first layer is %d bytes, want 4096", len(payload), len(onion))
+		}
+
+		layer1, err := DecryptOnionLayer(onion, relay1Key)
+		if err != nil {
+			t.Fatalf("DecryptOnionLayer() relay 1 error = %v", err)
+		}
+		if len(layer1.Payload) != 2048 {
+			t.Errorf("%d-byte payload: second layer is %d bytes, want 2048", len(payload), len(layer1.Payload))
+		}
+
+		layer2, err := DecryptOnionLayer(layer1.Payload, relay2Key)
+		if err != nil {
+			t.Fatalf("DecryptOnionLayer() relay 2 error = %v", err)
+		}
+		if !bytes.Equal(layer2.Payload, payload) || layer2.NextHop != recipientAddr {
+			t.Errorf("final layer = %x to %x, want %x to %x", layer2.Payload, layer2.NextHop, payload, recipientAddr)
+		}
+	}
+}
+
+func TestBucketSize(t *testing.T) {
+	buckets := []uint32{512, 2048}
+	tests := []struct {
+		size, want int
+	}{
+		{0, 512},
+		{512, 512},
+		{513, 2048},
+		{2048, 2048},
+		{2049, 4096},
+		{5000, 6144},
+	}
+	for _, tt := range tests {
+		if got := BucketSize(tt.size, buckets); got != tt.want {
+			t.Errorf("BucketSize(%d) = %d, want %d", tt.size, got, tt.want)
+		}
+	}
+
+	if got := BucketSize(700, nil); got != 700 {
+		t.Errorf("BucketSize(700, nil) = %d, want 700", got)
+	}
+}
+
 func TestBuildOnionLayersSingleRelay(t *testing.T) {
 	relayKey, _ := GenerateRSAKeyPair()
 	relayPath := []*RelayInfo{
@@ -18,6 +18,25 @@ const (
 	CellSize8192 = 8192 // Very large messages
 )
 
+// standardCells are the cell sizes of PaddingFixedSize
+var standardCells = []uint32{CellSize512, CellSize1024, CellSize4096, CellSize8192}
+
+// BucketSize returns the smallest bucket that fits size bytes
+// buckets must be ascending. Past the largest bucket, sizes round up to a
+// multiple of it. With no buckets, size is returned unchanged.
+func BucketSize(size int, buckets []uint32) int {
+	if len(buckets) == 0 {
+		return size
+	}
+	for _, bucket := range buckets {
+		if size <= int(bucket) {
+			return int(bucket)
+		}
+	}
+	largest := int(buckets[len(buckets)-1])
+	return ((size + largest - 1) / largest) * largest
+}
+
 // PaddingScheme represents different padding strategies
 type PaddingScheme int
 
@@ -54,22 +73,8 @@ func AddPadding(message []byte, scheme PaddingScheme) ([]byte, int, error) {
 
 // addFixedSizePadding pads message to nearest cell size (512, 1024, 4096, 8192)
 func addFixedSizePadding(message []byte, originalLen int) ([]byte, int, error) {
-	var targetSize int
-
-	// Choose appropriate cell size
-	switch {
-	case originalLen <= CellSize512:
-		targetSize = CellSize512
-	case originalLen <= CellSize1024:
-		targetSize = CellSize1024
-	case originalLen <= CellSize4096:
-		targetSize = CellSize4096
-	case originalLen <= CellSize8192:
-		targetSize = CellSize8192
-	default:
-		// For very large messages, round up to nearest 8KB
-		targetSize = ((originalLen + CellSize8192 - 1) / CellSize8192) * CellSize8192
-	}
+	// Choose appropriate cell size; very large messages round up to the nearest 8KB
+	targetSize := BucketSize(originalLen, standardCells)
 
 	paddingLen := targetSize - originalLen
 	if paddingLen == 0 {
@@ -133,18 +138,7 @@ func EstimatePaddedSize(messageLen int, scheme PaddingScheme) int {
 		return messageLen
 
 	case PaddingFixedSize:
-		switch {
-		case messageLen <= CellSize512:
-			return CellSize512
-		case messageLen <= CellSize1024:
-			return CellSize1024
-		case messageLen <= CellSize4096:
-			return CellSize4096
-		case messageLen <= CellSize8192:
-			return CellSize8192
-		default:
-			return ((messageLen + CellSize8192 - 1) / CellSize8192) * CellSize8192
-		}
+		return BucketSize(messageLen, standardCells)
 
 	case PaddingRandom:
 		return messageLen + 128 // Average padding
@@ -0,0 +1,129 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// MaxShares is the most shares a secret can be split into (share indexes are one byte)
+const MaxShares = 255
+
+var ErrInvalidShares = errors.New("invalid secret shares")
+
+// gf256Exp and gf256Log are lookup tables for GF(2^8) with the AES polynomial
+var gf256Exp, gf256Log = gf256Tables()
+
+// gf256Tables builds exp/log tables from the generator 3
+func gf256Tables() (exp [510]byte, log [256]byte) {
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		exp[i] = x
+		exp[i+255] = x
+		log[x] = byte(i)
+
+		// x *= 3
+		hi := x & 0x80
+		x ^= x << 1
+		if hi != 0 {
+			x ^= 0x1b
+		}
+	}
+	return exp, log
+}
+
+// gf256Mul multiplies in GF(2^8)
+func gf256Mul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gf256Exp[int(gf256Log[a])+int(gf256Log[b])]
+}
+
+// gf256Div divides in GF(2^8); b must not be 0
+func gf256Div(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gf256Exp[int(gf256Log[a])+255-int(gf256Log[b])]
+}
+
+// SplitSecret splits secret into n shares, any threshold of which recover it
+// Shamir's scheme over GF(2^8), byte by byte. Each share is its index (1-n)
+// followed by len(secret) bytes; fewer than threshold shares reveal nothing.
+func SplitSecret(secret []byte, threshold, n int) ([][]byte, error) {
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("secret is empty")
+	}
+	if threshold < 2 || threshold > n || n > MaxShares {
+		return nil, fmt.Errorf("need 2 <= threshold <= shares <= %d, got %d of %d", MaxShares, threshold, n)
+	}
+
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, 1+len(secret))
+		shares[i][0] = byte(i + 1)
+	}
+
+	// One random polynomial per byte, with the secret byte as its constant term
+	coeffs := make([]byte, threshold-1)
+	for b, s := range secret {
+		if _, err := rand.Read(coeffs); err != nil {
+			return nil, fmt.Errorf("failed to generate coefficients: %w", err)
+		}
+
+		for _, share := range shares {
+			x := share[0]
+
+			// Horner's rule, highest coefficient first
+			y := byte(0)
+			for j := len(coeffs) - 1; j >= 0; j-- {
+				y = gf256Mul(y, x) ^ coeffs[j]
+			}
+			share[1+b] = gf256Mul(y, x) ^ s
+		}
+	}
+
+	return shares, nil
+}
+
+// CombineShares recovers a secret from threshold or more shares made by SplitSecret
+// With fewer shares than the threshold the result is garbage, not an error:
+// callers should authenticate what they recover.
+func CombineShares(shares [][]byte) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, fmt.Errorf("%w: need at least 2 shares", ErrInvalidShares)
+	}
+
+	size := len(shares[0])
+	seen := make(map[byte]bool, len(shares))
+	for _, share := range shares {
+		if len(share) < 2 || len(share) != size {
+			return nil, fmt.Errorf("%w: shares differ in length", ErrInvalidShares)
+		}
+		if share[0] == 0 || seen[share[0]] {
+			return nil, fmt.Errorf("%w: duplicate or zero index %d", ErrInvalidShares, share[0])
+		}
+		seen[share[0]] = true
+	}
+
+	// Lagrange basis at x = 0 for each share
+	basis := make([]byte, len(shares))
+	for i, si := range shares {
+		basis[i] = 1
+		for j, sj := range shares {
+			if i != j {
+				basis[i] = gf256Mul(basis[i], gf256Div(sj[0], sj[0]^si[0]))
+			}
+		}
+	}
+
+	secret := make([]byte, size-1)
+	for b := range secret {
+		for i, share := range shares {
+			secret[b] ^= gf256Mul(share[1+b], basis[i])
+		}
+	}
+
+	return secret, nil
+}
@@ -0,0 +1,80 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSplitCombineSecret(t *testing.T) {
+	secret := []byte("thirty-two byte recovery key....")
+
+	shares, err := SplitSecret(secret, 3, 5)
+	if err != nil {
+		t.Fatalf("SplitSecret() error = %v", err)
+	}
+	if len(shares) != 5 || len(shares[0]) != len(secret)+1 {
+		t.Fatalf("SplitSecret() = %d shares of %d bytes", len(shares), len(shares[0]))
+	}
+
+	subsets := [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}, {0, 1, 2, 3, 4}}
+	for _, subset := range subsets {
+		var picked [][]byte
+		for _, i := range subset {
+			picked = append(picked, shares[i])
+		}
+		got, err := CombineShares(picked)
+		if err != nil {
+			t.Fatalf("CombineShares(%v) error = %v", subset, err)
+		}
+		if !bytes.Equal(got, secret) {
+			t.Errorf("CombineShares(%v) = %q, want %q", subset, got, secret)
+		}
+	}
+
+	// Below the threshold the secret is not recovered
+	if got, _ := CombineShares(shares[:2]); bytes.Equal(got, secret) {
+		t.Error("CombineShares() with 2 of 3 shares recovered the secret")
+	}
+}
+
+func TestSplitSecretInvalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold int
+		n         int
+	}{
+		{"Threshold 1", 1, 3},
+		{"Threshold above shares", 4, 3},
+		{"Too many shares", 2, MaxShares + 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := SplitSecret([]byte{1}, tt.threshold, tt.n); err == nil {
+				t.Error("SplitSecret() expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestCombineSharesInvalid(t *testing.T) {
+	shares, _ := SplitSecret([]byte{1, 2, 3}, 2, 3)
+
+	tests := []struct {
+		name   string
+		shares [][]byte
+	}{
+		{"One share", shares[:1]},
+		{"Duplicate index", [][]byte{shares[0], shares[0]}},
+		{"Length mismatch", [][]byte{shares[0], shares[1][:3]}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := CombineShares(tt.shares); !errors.Is(err, ErrInvalidShares) {
+				t.Errorf("CombineShares() error = %v, want ErrInvalidShares", err)
+			}
+		})
+	}
+}
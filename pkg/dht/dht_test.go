@@ -349,6 +349,156 @@ func TestConcurrentAccess(t *testing.T) {
 	}
 }
 
+// TestStorageRefreshDue tests selecting values that need replicating
+func TestStorageRefreshDue(t *testing.T) {
+	storage := NewStorage()
+
+	key := RandomNodeID()
+	storage.Store(key, []byte("value"), time.Hour, RandomNodeID())
+	storage.Store(RandomNodeID(), []byte("expired"), time.Millisecond, RandomNodeID())
+
+	time.Sleep(10 * time.Millisecond)
+	cutoff := time.Now()
+
+	due := storage.RefreshDue(cutoff)
+	if len(due) != 1 || !due[0].Key.Equals(key) {
+		t.Fatalf("RefreshDue() returned %d values, want only the unexpired one", len(due))
+	}
+
+	// Touching a value delays its next replication without extending its expiry
+	expiresAt := due[0].ExpiresAt
+	storage.Touch(key)
+
+	if due := storage.RefreshDue(cutoff); len(due) != 0 {
+		t.Errorf("RefreshDue() after Touch returned %d values, want 0", len(due))
+	}
+	if !storage.data[key].ExpiresAt.Equal(expiresAt) {
+		t.Error("Touch should not change the expiry")
+	}
+}
+
+// TestRepublishOwnRecords tests that published records outlive their TTL
+func TestRepublishOwnRecords(t *testing.T) {
+	node1 := NewNode(RandomNodeID(), "localhost:0")
+	node2 := NewNode(RandomNodeID(), "localhost:0")
+
+	if err := node1.Start(); err != nil {
+		t.Fatalf("Failed to start node1: %v", err)
+	}
+	defer node1.Stop()
+
+	if err := node2.Start(); err != nil {
+		t.Fatalf("Failed to start node2: %v", err)
+	}
+	defer node2.Stop()
+
+	node1.AddPeer(NewContact(node2.ID, node2.Address))
+	node2.AddPeer(NewContact(node1.ID, node1.Address))
+
+	// A TTL shorter than two republish intervals is refreshed on every round
+	node1.SetRecordTTL(2 * time.Second)
+
+	key := RandomNodeID()
+	value := []byte("published value")
+	if err := node1.Publish(key, value); err != nil {
+		t.Fatalf("Failed to publish value: %v", err)
+	}
+
+	time.Sleep(1 * time.Second)
+	node1.republish()
+
+	// The first copy has expired by now; only the republished one remains
+	time.Sleep(1500 * time.Millisecond)
+	signedData, found := node2.storage.Get(key)
+	if !found {
+		t.Fatal("Republished value should still be stored on node2")
+	}
+	retrieved, err := VerifyAndExtract(signedData)
+	if err != nil {
+		t.Fatalf("Republished value should verify: %v", err)
+	}
+	if string(retrieved) != string(value) {
+		t.Errorf("Retrieved value mismatch: expected %s, got %s", value, retrieved)
+	}
+
+	// Unpublished records are left to expire
+	node1.Unpublish(key)
+	before := node2.storage.data[key].ExpiresAt
+	node1.republish()
+	if !node2.storage.data[key].ExpiresAt.Equal(before) {
+		t.Error("Unpublished value should not be republished")
+	}
+}
+
+// TestRepublishReplicatesHeldValues tests that held values reach new close nodes
+func TestRepublishReplicatesHeldValues(t *testing.T) {
+	node1 := NewNode(RandomNodeID(), "localhost:0")
+	node2 := NewNode(RandomNodeID(), "localhost:0")
+
+	if err := node1.Start(); err != nil {
+		t.Fatalf("Failed to start node1: %v", err)
+	}
+	defer node1.Stop()
+
+	if err := node2.Start(); err != nil {
+		t.Fatalf("Failed to start node2: %v", err)
+	}
+	defer node2.Stop()
+
+	// node1 holds a record signed by some publisher that has since left
+	publisher := NewNode(RandomNodeID(), "localhost:0")
+	key := RandomNodeID()
+	entry, err := SignEntry(key, []byte("held value"), publisher.PrivateKey, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to sign entry: %v", err)
+	}
+	signedData, err := entry.Encode()
+	if err != nil {
+		t.Fatalf("Failed to encode entry: %v", err)
+	}
+	node1.storage.Store(key, signedData, time.Hour, publisher.ID)
+
+	// node2 joins after the value was stored
+	node1.AddPeer(NewContact(node2.ID, node2.Address))
+	node2.AddPeer(NewContact(node1.ID, node1.Address))
+
+	// Values stored within the last interval are not replicated yet
+	node1.republish()
+	if node2.storage.Has(key) {
+		t.Fatal("Freshly stored value should not be replicated")
+	}
+
+	node1.SetRepublishInterval(10 * time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	node1.republish()
+
+	if !node2.storage.Has(key) {
+		t.Fatal("Held value should be replicated to node2")
+	}
+	if node2.storage.data[key].ExpiresAt.After(node1.storage.data[key].ExpiresAt.Add(time.Second)) {
+		t.Error("Replicated value should keep its original expiry")
+	}
+}
+
+// TestRepublishRoutineStops tests that stopping doesn't wait out the republish interval
+func TestRepublishRoutineStops(t *testing.T) {
+	node := NewNode(RandomNodeID(), "localhost:0")
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		node.republishRoutine(stop)
+		close(done)
+	}()
+
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("republishRoutine should return once stopped")
+	}
+}
+
 // Benchmark tests
 
 func BenchmarkNodeIDXOR(b *testing.B) {
@@ -18,6 +18,7 @@ type Node struct {
 	storage      *Storage
 	listener     net.Listener
 	running      bool
+	stopChan     chan struct{} // Closed by Stop to end the background routines
 	mu           sync.RWMutex
 
 	// Ed25519 keys for signing DHT entries (security enhancement)
@@ -27,6 +28,12 @@ type Node struct {
 	// Pending RPC requests
 	pendingRequests map[string]chan *RPCMessage
 	pendingMu       sync.RWMutex
+
+	// Record lifetime and republishing
+	recordTTL         time.Duration
+	republishInterval time.Duration
+	published         map[NodeID]*publishedRecord
+	publishedMu       sync.Mutex
 }
 
 // NewNode creates a new DHT node
@@ -38,13 +45,16 @@ func NewNode(id NodeID, address string) *Node {
 	}
 
 	return &Node{
-		ID:              id,
-		Address:         address,
-		routingTable:    NewRoutingTable(id),
-		storage:         NewStorage(),
-		pendingRequests: make(map[string]chan *RPCMessage),
-		PrivateKey:      privateKey,
-		PublicKey:       publicKey,
+		ID:                id,
+		Address:           address,
+		routingTable:      NewRoutingTable(id),
+		storage:           NewStorage(),
+		pendingRequests:   make(map[string]chan *RPCMessage),
+		PrivateKey:        privateKey,
+		PublicKey:         publicKey,
+		recordTTL:         DefaultRecordTTL,
+		republishInterval: DefaultRepublishInterval,
+		published:         make(map[NodeID]*publishedRecord),
 	}
 }
 
@@ -55,8 +65,12 @@ func (n *Node) Start() error {
 		return fmt.Errorf("failed to start DHT node: %w", err)
 	}
 
+	stop := make(chan struct{})
+	n.mu.Lock()
 	n.listener = listener
 	n.running = true
+	n.stopChan = stop
+	n.mu.Unlock()
 
 	// Update address to the actual listening address
 	// This is important when n.Address was "localhost:0" (random port)
@@ -65,8 +79,9 @@ func (n *Node) Start() error {
 	log.Printf("DHT node %s listening on %s", n.ID.String()[:8], n.Address)
 
 	// Start background tasks
-	go n.handleConnections()
-	go n.expireRoutine()
+	go n.handleConnections(listener, stop)
+	go n.expireRoutine(stop)
+	go n.republishRoutine(stop)
 
 	return nil
 }
@@ -81,6 +96,7 @@ func (n *Node) Stop() error {
 	}
 
 	n.running = false
+	close(n.stopChan)
 	if n.listener != nil {
 		return n.listener.Close()
 	}
@@ -88,12 +104,14 @@ func (n *Node) Stop() error {
 }
 
 // handleConnections handles incoming connections
-func (n *Node) handleConnections() {
-	for n.running {
-		conn, err := n.listener.Accept()
+func (n *Node) handleConnections(listener net.Listener, stop <-chan struct{}) {
+	for {
+		conn, err := listener.Accept()
 		if err != nil {
-			if !n.running {
+			select {
+			case <-stop:
 				return
+			default:
 			}
 			log.Printf("Accept error: %v", err)
 			continue
@@ -247,13 +265,17 @@ func (n *Node) handleFindValue(msg *RPCMessage, sender *Contact) *RPCMessage {
 }
 
 // expireRoutine periodically removes expired values
-func (n *Node) expireRoutine() {
+func (n *Node) expireRoutine(stop <-chan struct{}) {
 	ticker := time.NewTicker(5 * time.Minute)
 	defer ticker.Stop()
 
-	for n.running {
-		<-ticker.C
-		n.storage.ExpireOldValues()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			n.storage.ExpireOldValues()
+		}
 	}
 }
 
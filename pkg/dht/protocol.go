@@ -59,15 +59,29 @@ func (n *Node) Store(key NodeID, value []byte, ttl time.Duration) error {
 	}
 
 	// Store on all closest nodes
-	sender := NewContact(n.ID, n.Address)
 	req := &StoreRequest{
 		Key:   key,
 		Value: signedData, // Now storing signed entry instead of raw value
 		TTL:   int64(ttl.Seconds()),
 	}
 
+	successCount := n.storeOn(closestNodes, req)
+
+	log.Printf("✅ Successfully stored signed key %s on %d/%d nodes", key.String()[:8], successCount, len(closestNodes))
+
+	if successCount == 0 {
+		return fmt.Errorf("failed to store on any node")
+	}
+
+	return nil
+}
+
+// storeOn sends a STORE request to each contact and returns how many acknowledged it
+func (n *Node) storeOn(contacts []*Contact, req *StoreRequest) int {
+	sender := NewContact(n.ID, n.Address)
+
 	successCount := 0
-	for _, contact := range closestNodes {
+	for _, contact := range contacts {
 		msg, err := NewRPCMessage(RPCStore, sender, req)
 		if err != nil {
 			log.Printf("Failed to create STORE message: %v", err)
@@ -84,14 +98,7 @@ func (n *Node) Store(key NodeID, value []byte, ttl time.Duration) error {
 			successCount++
 		}
 	}
-
-	log.Printf("✅ Successfully stored signed key %s on %d/%d nodes", key.String()[:8], successCount, len(closestNodes))
-
-	if successCount == 0 {
-		return fmt.Errorf("failed to store on any node")
-	}
-
-	return nil
+	return successCount
 }
 
 // Lookup performs iterative value lookup in the DHT with signature verification
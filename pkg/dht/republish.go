@@ -0,0 +1,153 @@
+package dht
+
+import (
+	"log"
+	"time"
+)
+
+const (
+	// DefaultRecordTTL is how long published records live without a refresh
+	DefaultRecordTTL = 24 * time.Hour
+
+	// DefaultRepublishInterval is how often records are checked for republishing
+	DefaultRepublishInterval = 1 * time.Hour
+)
+
+// publishedRecord is a record this node published and keeps alive
+type publishedRecord struct {
+	value     []byte
+	expiresAt time.Time // Expiry of the copies from the last successful store (zero = none stored)
+}
+
+// SetRecordTTL sets the TTL used by Publish and republishing
+func (n *Node) SetRecordTTL(ttl time.Duration) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.recordTTL = ttl
+}
+
+// RecordTTL returns the TTL used by Publish and republishing
+func (n *Node) RecordTTL() time.Duration {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.recordTTL
+}
+
+// SetRepublishInterval sets how often records are republished
+// Takes effect after the current interval elapses.
+func (n *Node) SetRepublishInterval(interval time.Duration) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.republishInterval = interval
+}
+
+// RepublishInterval returns how often records are republished
+func (n *Node) RepublishInterval() time.Duration {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.republishInterval
+}
+
+// Publish stores a value with the node's record TTL and keeps it alive
+// The record is re-signed and stored again before it expires, until Unpublish is
+// called. It stays registered if the initial store fails, so the next republish
+// retries it.
+func (n *Node) Publish(key NodeID, value []byte) error {
+	n.publishedMu.Lock()
+	n.published[key] = &publishedRecord{value: value}
+	n.publishedMu.Unlock()
+
+	return n.refreshPublished(key, value)
+}
+
+// Unpublish stops republishing a record
+// Copies already stored on other nodes remain until they expire.
+func (n *Node) Unpublish(key NodeID) {
+	n.publishedMu.Lock()
+	defer n.publishedMu.Unlock()
+	delete(n.published, key)
+}
+
+// refreshPublished stores a published record and records when the copies expire
+func (n *Node) refreshPublished(key NodeID, value []byte) error {
+	ttl := n.RecordTTL()
+	if err := n.Store(key, value, ttl); err != nil {
+		return err
+	}
+
+	n.publishedMu.Lock()
+	defer n.publishedMu.Unlock()
+
+	// Skip if the record was unpublished or replaced in the meantime
+	if record, exists := n.published[key]; exists && string(record.value) == string(value) {
+		record.expiresAt = time.Now().Add(ttl)
+	}
+	return nil
+}
+
+// republishRoutine periodically refreshes records before they expire, until stop is closed
+func (n *Node) republishRoutine(stop <-chan struct{}) {
+	timer := time.NewTimer(n.RepublishInterval())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+			n.republish()
+			timer.Reset(n.RepublishInterval())
+		}
+	}
+}
+
+// republish runs one round of republishing
+// Our own records are re-signed and stored again once they would expire within two
+// intervals. Values held for other publishers are replicated to the nodes now
+// closest to their key, keeping their original expiry, unless a STORE for them
+// arrived during the last interval (another replica already pushed them).
+func (n *Node) republish() {
+	interval := n.RepublishInterval()
+	now := time.Now()
+
+	n.publishedMu.Lock()
+	due := make(map[NodeID][]byte)
+	for key, record := range n.published {
+		if record.expiresAt.Sub(now) < 2*interval {
+			due[key] = record.value
+		}
+	}
+	n.publishedMu.Unlock()
+
+	for key, value := range due {
+		if err := n.refreshPublished(key, value); err != nil {
+			log.Printf("⚠️  Failed to republish key %s: %v", key.String()[:8], err)
+		}
+	}
+
+	replicated := 0
+	for _, stored := range n.storage.RefreshDue(now.Add(-interval)) {
+		if _, own := due[stored.Key]; own {
+			continue
+		}
+
+		remaining := time.Until(stored.ExpiresAt)
+		if remaining < time.Second {
+			continue
+		}
+
+		req := &StoreRequest{
+			Key:   stored.Key,
+			Value: stored.Value,
+			TTL:   int64(remaining.Seconds()),
+		}
+		if n.storeOn(n.iterativeFindNode(stored.Key, K), req) > 0 {
+			replicated++
+		}
+		n.storage.Touch(stored.Key)
+	}
+
+	if len(due) > 0 || replicated > 0 {
+		log.Printf("🔄 Republished %d own and %d replicated DHT records", len(due), replicated)
+	}
+}
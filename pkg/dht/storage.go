@@ -7,10 +7,11 @@ import (
 
 // StoredValue represents a value stored in the DHT
 type StoredValue struct {
-	Key       NodeID
-	Value     []byte
-	ExpiresAt time.Time
-	Publisher NodeID // Original publisher
+	Key         NodeID
+	Value       []byte
+	ExpiresAt   time.Time
+	Publisher   NodeID    // Original publisher
+	RefreshedAt time.Time // Last time the value was stored or replicated
 }
 
 // Storage represents the local key-value storage for a DHT node
@@ -31,11 +32,13 @@ func (s *Storage) Store(key NodeID, value []byte, ttl time.Duration, publisher N
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	now := time.Now()
 	s.data[key] = &StoredValue{
-		Key:       key,
-		Value:     value,
-		ExpiresAt: time.Now().Add(ttl),
-		Publisher: publisher,
+		Key:         key,
+		Value:       value,
+		ExpiresAt:   now.Add(ttl),
+		Publisher:   publisher,
+		RefreshedAt: now,
 	}
 }
 
@@ -80,6 +83,32 @@ func (s *Storage) GetAll() []*StoredValue {
 	return result
 }
 
+// RefreshDue returns copies of unexpired values last refreshed before the given time
+func (s *Storage) RefreshDue(before time.Time) []*StoredValue {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	var result []*StoredValue
+	for _, v := range s.data {
+		if now.Before(v.ExpiresAt) && v.RefreshedAt.Before(before) {
+			copied := *v
+			result = append(result, &copied)
+		}
+	}
+	return result
+}
+
+// Touch marks a value as refreshed without changing its expiry
+func (s *Storage) Touch(key NodeID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if v, exists := s.data[key]; exists {
+		v.RefreshedAt = time.Now()
+	}
+}
+
 // ExpireOldValues removes expired values
 func (s *Storage) ExpireOldValues() {
 	s.mu.Lock()
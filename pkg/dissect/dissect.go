@@ -0,0 +1,262 @@
+// Package dissect decodes captured ZenTalk wire traffic for protocol debugging
+//
+// It finds the 32-byte frame headers of the relay TCP protocol in pcap captures or
+// raw stream dumps and reports each frame's type, flags, length and message ID.
+// Payloads are measured but never decrypted or decoded, so a capture can be examined
+// without any keys.
+package dissect
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// MaxFrameLength is the largest payload length accepted as a real header
+// Anything bigger is taken to be payload bytes that happen to contain the magic.
+const MaxFrameLength = 64 * 1024 * 1024
+
+// ErrPcapNG is returned for pcapng files, which are not supported
+var ErrPcapNG = errors.New("pcapng captures are not supported; convert with: editcap -F pcap in.pcapng out.pcap")
+
+// magic is ProtocolMagic as it appears on the wire
+var magic = binary.BigEndian.AppendUint32(nil, protocol.ProtocolMagic)
+
+// Flow is one direction of a TCP connection
+type Flow struct {
+	Src netip.AddrPort
+	Dst netip.AddrPort
+}
+
+// String formats the flow as "src → dst", or "stream" for raw dumps
+func (f Flow) String() string {
+	if !f.Src.IsValid() {
+		return "stream"
+	}
+	return f.Src.String() + " → " + f.Dst.String()
+}
+
+// Reverse returns the opposite direction of the connection
+func (f Flow) Reverse() Flow {
+	return Flow{Src: f.Dst, Dst: f.Src}
+}
+
+// Frame is one protocol message found in a capture
+type Frame struct {
+	Time        time.Time       // Capture time of the packet completing the header (zero for raw dumps)
+	Flow        Flow            // Direction it travelled (zero for raw dumps)
+	Offset      int64           // Byte offset of the header within its stream
+	Header      protocol.Header // Decoded header
+	PayloadSeen uint32          // Payload bytes present in the capture
+	Payload     []byte          // Captured payload bytes, still encrypted (nil when not kept)
+	Skipped     int64           // Unrecognised bytes discarded just before this header
+	AfterGap    bool            // Stream data was missing from the capture before this header
+}
+
+// Truncated reports whether part of the payload is missing from the capture
+func (f *Frame) Truncated() bool {
+	return f.PayloadSeen < f.Header.Length
+}
+
+// Encode returns the frame as it appeared on the wire
+// Only meaningful for frames read with their payload and not truncated.
+func (f *Frame) Encode() []byte {
+	return append(f.Header.Encode(), f.Payload...)
+}
+
+// streamDecoder finds frames in one direction of a byte stream
+// Bytes that do not form a plausible header are skipped until the magic is seen again,
+// so decoding recovers from captures that start mid-stream or lose segments.
+type streamDecoder struct {
+	flow      Flow
+	emit      func(Frame)
+	keep      bool   // Collect payload bytes into Frame.Payload
+	pending   []byte // Bytes not yet part of a frame
+	pos       int64  // Stream offset of pending[0]
+	current   *Frame // Frame whose payload is being consumed
+	remaining uint32 // Payload bytes still expected for current
+	skipped   int64  // Bytes discarded since the last header
+	gapped    bool   // Data was lost since the last header
+}
+
+func newStreamDecoder(flow Flow, keep bool, emit func(Frame)) *streamDecoder {
+	return &streamDecoder{flow: flow, keep: keep, emit: emit}
+}
+
+// feed processes the next bytes of the stream, captured at t
+func (d *streamDecoder) feed(data []byte, t time.Time) {
+	for len(data) > 0 {
+		if d.current != nil {
+			n := uint32(len(data))
+			if n > d.remaining {
+				n = d.remaining
+			}
+			d.current.PayloadSeen += n
+			// Relay errors are tiny and always kept so their code can be shown
+			if d.keep || d.current.Header.Type == protocol.MsgTypeRelayError {
+				d.current.Payload = append(d.current.Payload, data[:n]...)
+			}
+			d.remaining -= n
+			d.pos += int64(n)
+			data = data[n:]
+			if d.remaining == 0 {
+				d.finish()
+			}
+			continue
+		}
+
+		d.pending = append(d.pending, data...)
+		data = d.scan(t)
+	}
+}
+
+// scan looks for a header at the start of pending
+// Returns the bytes after a complete header, which belong to its payload or later frames.
+func (d *streamDecoder) scan(t time.Time) []byte {
+	for {
+		i := bytes.Index(d.pending, magic)
+		if i < 0 {
+			// Keep a tail that may be the start of a split magic
+			keep := len(magic) - 1
+			if len(d.pending) > keep {
+				d.discard(len(d.pending) - keep)
+			}
+			return nil
+		}
+		d.discard(i)
+
+		if len(d.pending) < protocol.HeaderSize {
+			return nil
+		}
+
+		var header protocol.Header
+		header.Decode(d.pending)
+		if header.Length > MaxFrameLength {
+			d.discard(1)
+			continue
+		}
+
+		d.current = &Frame{
+			Time:     t,
+			Flow:     d.flow,
+			Offset:   d.pos,
+			Header:   header,
+			Skipped:  d.skipped,
+			AfterGap: d.gapped,
+		}
+		d.remaining = header.Length
+		d.skipped = 0
+		d.gapped = false
+
+		rest := d.pending[protocol.HeaderSize:]
+		d.pos += protocol.HeaderSize
+		d.pending = nil
+		if d.remaining == 0 {
+			d.finish()
+		}
+		return rest
+	}
+}
+
+// discard drops n unrecognised bytes from pending
+func (d *streamDecoder) discard(n int) {
+	d.pending = d.pending[n:]
+	d.pos += int64(n)
+	d.skipped += int64(n)
+}
+
+// finish emits the current frame
+func (d *streamDecoder) finish() {
+	d.emit(*d.current)
+	d.current = nil
+	d.remaining = 0
+}
+
+// gap records that n stream bytes are missing from the capture
+// The frame in progress is emitted truncated and decoding resyncs on the next magic.
+func (d *streamDecoder) gap(n int64) {
+	if d.current != nil {
+		if int64(d.remaining) > n {
+			// The gap falls inside this payload; keep consuming after it
+			d.remaining -= uint32(n)
+			d.pos += n
+			return
+		}
+		n -= int64(d.remaining)
+		d.pos += int64(d.remaining)
+		d.finish()
+	}
+	d.skipped += int64(len(d.pending))
+	d.pos += int64(len(d.pending)) + n
+	d.pending = nil
+	d.gapped = true
+}
+
+// close emits a frame left incomplete at the end of the capture
+func (d *streamDecoder) close() {
+	if d.current != nil {
+		d.finish()
+	}
+}
+
+// ReadStream decodes frames from a raw dump of one direction of a connection
+func ReadStream(r io.Reader) ([]Frame, error) {
+	var frames []Frame
+	dec := newStreamDecoder(Flow{}, true, func(f Frame) { frames = append(frames, f) })
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		dec.feed(buf[:n], time.Time{})
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return frames, fmt.Errorf("read stream: %w", err)
+		}
+	}
+	dec.close()
+	return frames, nil
+}
+
+// Read decodes a pcap capture or, failing that, a raw stream dump
+// With a pcap, only TCP traffic to or from port is decoded (0 decodes every TCP flow).
+func Read(r io.Reader, port uint16) ([]Frame, error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(4)
+	if err != nil && len(head) == 0 {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if len(head) == 4 {
+		switch binary.BigEndian.Uint32(head) {
+		case pcapMagicMicro, pcapMagicNano, pcapMagicMicroSwapped, pcapMagicNanoSwapped:
+			return ReadPcap(br, port)
+		case pcapngMagic:
+			return nil, ErrPcapNG
+		}
+	}
+	return ReadStream(br)
+}
+
+// MessageTime returns the creation time encoded in a message ID
+// IDs from protocol.GenerateMessageID start with a nanosecond timestamp; other IDs
+// give the zero time.
+func MessageTime(id protocol.MessageID) time.Time {
+	ns := int64(binary.BigEndian.Uint64(id[0:8]))
+	t := time.Unix(0, ns)
+	if t.Year() < 2020 || t.Year() > 2100 {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,234 @@
+package dissect
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// encodeFrame builds a wire frame with a payload of n bytes
+func encodeFrame(msgType uint16, flags uint16, n int) []byte {
+	header := &protocol.Header{
+		Magic:     protocol.ProtocolMagic,
+		Version:   protocol.ProtocolVersion,
+		Type:      msgType,
+		Length:    uint32(n),
+		Flags:     flags,
+		MessageID: protocol.GenerateMessageID(),
+	}
+	return append(header.Encode(), bytes.Repeat([]byte{0xEE}, n)...)
+}
+
+func TestReadStream(t *testing.T) {
+	var stream []byte
+	stream = append(stream, []byte("garbage before the first frame")...)
+	stream = append(stream, encodeFrame(protocol.MsgTypeHandshake, 0, 100)...)
+	stream = append(stream, encodeFrame(protocol.MsgTypeRelayForward, protocol.FlagEncrypted|protocol.FlagPadded, 5000)...)
+
+	// A magic followed by an absurd length is payload, not a header
+	bogus := append([]byte(nil), magic...)
+	bogus = append(bogus, make([]byte, 4)...)
+	bogus = binary.BigEndian.AppendUint32(bogus, MaxFrameLength+1)
+	bogus = append(bogus, make([]byte, protocol.HeaderSize)...)
+	stream = append(stream, bogus...)
+
+	stream = append(stream, encodeFrame(protocol.MsgTypePing, 0, 0)...)
+	stream = append(stream, encodeFrame(protocol.MsgTypeAck, 0, 64)[:protocol.HeaderSize+10]...)
+
+	// Feed one byte at a time so every header and magic is split across reads
+	frames, err := ReadStream(iotest.OneByteReader(bytes.NewReader(stream)))
+	if err != nil {
+		t.Fatalf("ReadStream: %v", err)
+	}
+
+	want := []uint16{protocol.MsgTypeHandshake, protocol.MsgTypeRelayForward, protocol.MsgTypePing, protocol.MsgTypeAck}
+	if len(frames) != len(want) {
+		t.Fatalf("got %d frames, want %d", len(frames), len(want))
+	}
+	for i, f := range frames {
+		if f.Header.Type != want[i] {
+			t.Errorf("frame %d type = %s, want %s", i, TypeName(f.Header.Type), TypeName(want[i]))
+		}
+	}
+
+	if frames[0].Skipped != int64(len("garbage before the first frame")) || frames[0].Offset != frames[0].Skipped {
+		t.Errorf("first frame skipped %d at offset %d", frames[0].Skipped, frames[0].Offset)
+	}
+	if frames[1].Truncated() || frames[1].PayloadSeen != 5000 {
+		t.Errorf("relay forward payload seen = %d, want 5000", frames[1].PayloadSeen)
+	}
+	if frames[2].Skipped != int64(len(bogus)) {
+		t.Errorf("ping skipped %d bytes, want %d", frames[2].Skipped, len(bogus))
+	}
+	if !frames[3].Truncated() || frames[3].PayloadSeen != 10 {
+		t.Errorf("last frame payload seen = %d, want 10 of 64", frames[3].PayloadSeen)
+	}
+}
+
+// pcapWriter builds an Ethernet/IPv4 libpcap capture in memory
+type pcapWriter struct {
+	buf     bytes.Buffer
+	snaplen int
+	start   time.Time
+}
+
+func newPcapWriter(snaplen int) *pcapWriter {
+	w := &pcapWriter{snaplen: snaplen, start: time.Unix(1700000000, 0)}
+	hdr := make([]byte, 24)
+	binary.LittleEndian.PutUint32(hdr[0:4], pcapMagicMicro)
+	binary.LittleEndian.PutUint16(hdr[4:6], 2)
+	binary.LittleEndian.PutUint16(hdr[6:8], 4)
+	binary.LittleEndian.PutUint32(hdr[16:20], uint32(snaplen))
+	binary.LittleEndian.PutUint32(hdr[20:24], linkTypeEthernet)
+	w.buf.Write(hdr)
+	return w
+}
+
+// packet records a TCP segment sent at ms milliseconds into the capture
+func (w *pcapWriter) packet(ms int, flow Flow, seq uint32, syn bool, payload []byte) {
+	tcp := make([]byte, 20)
+	binary.BigEndian.PutUint16(tcp[0:2], flow.Src.Port())
+	binary.BigEndian.PutUint16(tcp[2:4], flow.Dst.Port())
+	binary.BigEndian.PutUint32(tcp[4:8], seq)
+	tcp[12] = 5 << 4
+	tcp[13] = 0x10 // ACK
+	if syn {
+		tcp[13] |= 0x02
+	}
+	tcp = append(tcp, payload...)
+
+	ip := make([]byte, 20)
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:4], uint16(20+len(tcp)))
+	ip[8] = 64
+	ip[9] = 6
+	src, dst := flow.Src.Addr().As4(), flow.Dst.Addr().As4()
+	copy(ip[12:16], src[:])
+	copy(ip[16:20], dst[:])
+
+	frame := make([]byte, 14)
+	binary.BigEndian.PutUint16(frame[12:14], 0x0800)
+	frame = append(frame, ip...)
+	frame = append(frame, tcp...)
+
+	captured := frame
+	if len(captured) > w.snaplen {
+		captured = captured[:w.snaplen]
+	}
+
+	ts := w.start.Add(time.Duration(ms) * time.Millisecond)
+	rec := make([]byte, 16)
+	binary.LittleEndian.PutUint32(rec[0:4], uint32(ts.Unix()))
+	binary.LittleEndian.PutUint32(rec[4:8], uint32(ts.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(rec[8:12], uint32(len(captured)))
+	binary.LittleEndian.PutUint32(rec[12:16], uint32(len(frame)))
+	w.buf.Write(rec)
+	w.buf.Write(captured)
+}
+
+func TestReadPcap(t *testing.T) {
+	client := Flow{
+		Src: netip.MustParseAddrPort("10.0.0.1:50000"),
+		Dst: netip.MustParseAddrPort("10.0.0.2:8080"),
+	}
+	server := client.Reverse()
+	other := Flow{
+		Src: netip.MustParseAddrPort("10.0.0.1:50001"),
+		Dst: netip.MustParseAddrPort("10.0.0.3:443"),
+	}
+
+	handshake := encodeFrame(protocol.MsgTypeHandshake, 0, 200)
+	forward := encodeFrame(protocol.MsgTypeRelayForward, protocol.FlagEncrypted, 300)
+	ack := encodeFrame(protocol.MsgTypeRelayAck, 0, 8)
+	big := encodeFrame(protocol.MsgTypeMediaUpload, 0, 2000)
+
+	w := newPcapWriter(1500)
+	w.packet(0, client, 1000, true, nil)
+	w.packet(1, server, 5000, true, nil)
+	// Handshake split in two, second half arriving first, then retransmitted
+	w.packet(2, client, 1001+100, false, handshake[100:])
+	w.packet(3, client, 1001, false, handshake[:100])
+	w.packet(4, client, 1001+100, false, handshake[100:])
+	w.packet(5, server, 5001, false, ack)
+	w.packet(6, client, 1001+uint32(len(handshake)), false, forward)
+	w.packet(7, other, 1, false, []byte("not zentalk"))
+	// Larger than the snap length: the capture keeps only part of the payload
+	w.packet(8, server, 5001+uint32(len(ack)), false, big)
+
+	frames, err := Read(bytes.NewReader(w.buf.Bytes()), 8080)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+
+	want := []struct {
+		msgType uint16
+		flow    Flow
+	}{
+		{protocol.MsgTypeHandshake, client},
+		{protocol.MsgTypeRelayAck, server},
+		{protocol.MsgTypeRelayForward, client},
+		{protocol.MsgTypeMediaUpload, server},
+	}
+	if len(frames) != len(want) {
+		t.Fatalf("got %d frames, want %d", len(frames), len(want))
+	}
+	for i, f := range frames {
+		if f.Header.Type != want[i].msgType || f.Flow != want[i].flow {
+			t.Errorf("frame %d = %s on %s, want %s on %s",
+				i, TypeName(f.Header.Type), f.Flow, TypeName(want[i].msgType), want[i].flow)
+		}
+		if f.Skipped != 0 {
+			t.Errorf("frame %d skipped %d bytes", i, f.Skipped)
+		}
+	}
+
+	if frames[0].Truncated() {
+		t.Errorf("reassembled handshake is truncated (%d/%d)", frames[0].PayloadSeen, frames[0].Header.Length)
+	}
+	if !frames[3].Truncated() {
+		t.Error("frame cut by the snap length is not reported truncated")
+	}
+	if got := frames[0].Time.Sub(w.start); got != 3*time.Millisecond {
+		t.Errorf("handshake completed at +%v, want +3ms", got)
+	}
+
+	var out strings.Builder
+	if err := WriteTimeline(&out, frames); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"[1] 10.0.0.1:50000 → 10.0.0.2:8080", "[1] ←", "RelayForward", "ENC", "payload"} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("timeline missing %q:\n%s", s, out.String())
+		}
+	}
+}
+
+func TestReadRejectsPcapNG(t *testing.T) {
+	data := binary.BigEndian.AppendUint32(nil, pcapngMagic)
+	data = append(data, make([]byte, 24)...)
+	if _, err := Read(bytes.NewReader(data), 0); !errors.Is(err, ErrPcapNG) {
+		t.Errorf("Read(pcapng) error = %v, want ErrPcapNG", err)
+	}
+}
+
+func TestNames(t *testing.T) {
+	if got := TypeName(protocol.MsgTypeRelayMoved); got != "RelayMoved" {
+		t.Errorf("TypeName(RelayMoved) = %q", got)
+	}
+	if got := TypeName(0x0999); got != "0x0999" {
+		t.Errorf("TypeName(0x0999) = %q", got)
+	}
+	if got := FlagNames(protocol.FlagEncrypted | protocol.FlagPadded | 0x8000); got != "ENC|PAD|0x8000" {
+		t.Errorf("FlagNames = %q", got)
+	}
+	if got := FlagNames(0); got != "-" {
+		t.Errorf("FlagNames(0) = %q", got)
+	}
+}
@@ -0,0 +1,483 @@
+package dissect
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+)
+
+// pcap file magics, read big-endian
+const (
+	pcapMagicMicro        = 0xA1B2C3D4
+	pcapMagicNano         = 0xA1B23C4D
+	pcapMagicMicroSwapped = 0xD4C3B2A1
+	pcapMagicNanoSwapped  = 0x4D3CB2A1
+	pcapngMagic           = 0x0A0D0D0A
+)
+
+// Link-layer types understood by ReadPcap
+const (
+	linkTypeNull     = 0
+	linkTypeEthernet = 1
+	linkTypeRaw      = 101
+	linkTypeLinuxSLL = 113
+	linkTypeIPv4     = 228
+	linkTypeIPv6     = 229
+	linkTypeLoop     = 108
+	linkTypeSLL2     = 276
+)
+
+// maxPcapRecord bounds a single captured packet, guarding against corrupt files
+const maxPcapRecord = 256 * 1024
+
+// tcpSegment is the TCP payload of one captured packet
+type tcpSegment struct {
+	flow    Flow
+	seq     uint32
+	syn     bool
+	data    []byte // Captured payload
+	missing int    // Payload bytes cut off by the snap length
+	time    time.Time
+}
+
+// tcpStream reassembles one direction of a TCP connection in sequence order
+type tcpStream struct {
+	dec     *streamDecoder
+	started bool
+	next    uint32       // Next expected sequence number
+	held    []tcpSegment // Segments that arrived ahead of a hole
+}
+
+// add delivers seg to the decoder, or holds it until the data before it arrives
+func (s *tcpStream) add(seg tcpSegment) {
+	if seg.syn {
+		s.started = true
+		s.next = seg.seq + 1
+		seg.seq++
+	}
+	if len(seg.data)+seg.missing == 0 {
+		return
+	}
+	if !s.started {
+		// Capture began mid-connection; the decoder resyncs on the next header
+		s.started = true
+		s.next = seg.seq
+	}
+
+	s.held = append(s.held, seg)
+	s.drain()
+}
+
+// drain delivers every held segment that continues the stream
+func (s *tcpStream) drain() {
+	for {
+		progressed := false
+		kept := s.held[:0]
+		for _, seg := range s.held {
+			if !progressed && s.deliver(seg) {
+				progressed = true
+				continue
+			}
+			kept = append(kept, seg)
+		}
+		s.held = kept
+		if !progressed {
+			return
+		}
+	}
+}
+
+// deliver feeds seg if it starts at or before the next expected byte
+// Retransmitted bytes already delivered are trimmed. Returns false if seg is ahead of a hole.
+func (s *tcpStream) deliver(seg tcpSegment) bool {
+	ahead := int32(seg.seq - s.next)
+	if ahead > 0 {
+		return false
+	}
+
+	overlap := int(-ahead)
+	size := len(seg.data) + seg.missing
+	if overlap >= size {
+		return true // Pure retransmission
+	}
+
+	if overlap < len(seg.data) {
+		s.dec.feed(seg.data[overlap:], seg.time)
+		overlap = 0
+	} else {
+		overlap -= len(seg.data)
+	}
+	if seg.missing-overlap > 0 {
+		s.dec.gap(int64(seg.missing - overlap))
+	}
+	s.next = seg.seq + uint32(size)
+	return true
+}
+
+// flush delivers held segments across holes the capture never filled
+func (s *tcpStream) flush() {
+	sort.Slice(s.held, func(i, j int) bool {
+		return int32(s.held[i].seq-s.held[j].seq) < 0
+	})
+	for len(s.held) > 0 {
+		seg := s.held[0]
+		if hole := int32(seg.seq - s.next); hole > 0 {
+			s.dec.gap(int64(hole))
+			s.next = seg.seq
+		}
+		s.held = s.held[1:]
+		s.deliver(seg)
+		s.drain()
+	}
+	s.dec.close()
+}
+
+// ReadPcap decodes every frame in a libpcap capture, in capture time order
+// Only TCP traffic to or from port is decoded (0 decodes every TCP flow). Segments are
+// reassembled per direction, so frames split across packets, retransmitted or
+// reordered still decode; data the capture lost is reported as a gap.
+func ReadPcap(r io.Reader, port uint16) ([]Frame, error) {
+	var global [24]byte
+	if _, err := io.ReadFull(r, global[:]); err != nil {
+		return nil, fmt.Errorf("read pcap header: %w", err)
+	}
+
+	var order binary.ByteOrder
+	nano := false
+	switch binary.BigEndian.Uint32(global[0:4]) {
+	case pcapMagicMicro:
+		order = binary.BigEndian
+	case pcapMagicNano:
+		order, nano = binary.BigEndian, true
+	case pcapMagicMicroSwapped:
+		order = binary.LittleEndian
+	case pcapMagicNanoSwapped:
+		order, nano = binary.LittleEndian, true
+	case pcapngMagic:
+		return nil, ErrPcapNG
+	default:
+		return nil, fmt.Errorf("not a pcap file")
+	}
+	linkType := order.Uint32(global[20:24]) & 0x0FFFFFFF
+
+	var frames []Frame
+	emit := func(f Frame) { frames = append(frames, f) }
+	streams := make(map[Flow]*tcpStream)
+	var flowOrder []Flow // Streams in first-seen order, for deterministic output
+
+	var record [16]byte
+	for {
+		if _, err := io.ReadFull(r, record[:]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			return nil, fmt.Errorf("read pcap record: %w", err)
+		}
+
+		sec := int64(order.Uint32(record[0:4]))
+		frac := int64(order.Uint32(record[4:8]))
+		inclLen := order.Uint32(record[8:12])
+		origLen := order.Uint32(record[12:16])
+		if inclLen > maxPcapRecord {
+			return nil, fmt.Errorf("pcap record of %d bytes is too large", inclLen)
+		}
+		if !nano {
+			frac *= int64(time.Microsecond)
+		}
+
+		packet := make([]byte, inclLen)
+		if _, err := io.ReadFull(r, packet); err != nil {
+			// A capture cut off mid-packet still has usable data before it
+			break
+		}
+
+		cut := 0
+		if origLen > inclLen {
+			cut = int(origLen - inclLen)
+		}
+		seg, ok := parsePacket(linkType, packet, cut)
+		if !ok {
+			continue
+		}
+		if port != 0 && seg.flow.Src.Port() != port && seg.flow.Dst.Port() != port {
+			continue
+		}
+		seg.time = time.Unix(sec, frac)
+
+		stream, ok := streams[seg.flow]
+		if !ok {
+			stream = &tcpStream{dec: newStreamDecoder(seg.flow, true, emit)}
+			streams[seg.flow] = stream
+			flowOrder = append(flowOrder, seg.flow)
+		}
+		stream.add(seg)
+	}
+
+	for _, flow := range flowOrder {
+		streams[flow].flush()
+	}
+
+	sort.SliceStable(frames, func(i, j int) bool {
+		return frames[i].Time.Before(frames[j].Time)
+	})
+	return frames, nil
+}
+
+// parsePacket extracts the TCP segment from a captured packet
+// cut is how many bytes of the original packet the snap length dropped.
+func parsePacket(linkType uint32, packet []byte, cut int) (tcpSegment, bool) {
+	var etherType uint16
+	switch linkType {
+	case linkTypeEthernet:
+		if len(packet) < 14 {
+			return tcpSegment{}, false
+		}
+		etherType = binary.BigEndian.Uint16(packet[12:14])
+		packet = packet[14:]
+		if etherType == 0x8100 && len(packet) >= 4 { // 802.1Q VLAN tag
+			etherType = binary.BigEndian.Uint16(packet[2:4])
+			packet = packet[4:]
+		}
+	case linkTypeNull, linkTypeLoop:
+		if len(packet) < 4 {
+			return tcpSegment{}, false
+		}
+		// Address family, in the capturing host's byte order for DLT_NULL
+		family := binary.LittleEndian.Uint32(packet[0:4])
+		if family > 0xFFFF {
+			family = binary.BigEndian.Uint32(packet[0:4])
+		}
+		packet = packet[4:]
+		switch family {
+		case 2:
+			etherType = 0x0800
+		case 10, 24, 28, 30:
+			etherType = 0x86DD
+		}
+	case linkTypeLinuxSLL:
+		if len(packet) < 16 {
+			return tcpSegment{}, false
+		}
+		etherType = binary.BigEndian.Uint16(packet[14:16])
+		packet = packet[16:]
+	case linkTypeSLL2:
+		if len(packet) < 20 {
+			return tcpSegment{}, false
+		}
+		etherType = binary.BigEndian.Uint16(packet[0:2])
+		packet = packet[20:]
+	case linkTypeRaw, linkTypeIPv4, linkTypeIPv6:
+		if len(packet) == 0 {
+			return tcpSegment{}, false
+		}
+		switch packet[0] >> 4 {
+		case 4:
+			etherType = 0x0800
+		case 6:
+			etherType = 0x86DD
+		}
+	default:
+		return tcpSegment{}, false
+	}
+
+	switch etherType {
+	case 0x0800:
+		return parseIPv4(packet, cut)
+	case 0x86DD:
+		return parseIPv6(packet, cut)
+	}
+	return tcpSegment{}, false
+}
+
+// parseIPv4 extracts a TCP segment from an IPv4 packet
+func parseIPv4(packet []byte, cut int) (tcpSegment, bool) {
+	if len(packet) < 20 {
+		return tcpSegment{}, false
+	}
+	ihl := int(packet[0]&0x0F) * 4
+	total := int(binary.BigEndian.Uint16(packet[2:4]))
+	fragment := binary.BigEndian.Uint16(packet[6:8])
+	if packet[9] != 6 || ihl < 20 || total < ihl || fragment&0x3FFF != 0 {
+		return tcpSegment{}, false // Not TCP, malformed, or a fragment
+	}
+
+	src, _ := netip.AddrFromSlice(packet[12:16])
+	dst, _ := netip.AddrFromSlice(packet[16:20])
+	return parseTCP(src, dst, packet, ihl, total, cut)
+}
+
+// parseIPv6 extracts a TCP segment from an IPv6 packet without extension headers
+func parseIPv6(packet []byte, cut int) (tcpSegment, bool) {
+	if len(packet) < 40 || packet[6] != 6 {
+		return tcpSegment{}, false
+	}
+	total := 40 + int(binary.BigEndian.Uint16(packet[4:6]))
+
+	src, _ := netip.AddrFromSlice(packet[8:24])
+	dst, _ := netip.AddrFromSlice(packet[24:40])
+	return parseTCP(src, dst, packet, 40, total, cut)
+}
+
+// parseTCP extracts the segment starting at offset in an IP packet of total bytes
+func parseTCP(src, dst netip.Addr, packet []byte, offset, total, cut int) (tcpSegment, bool) {
+	if len(packet) < offset+20 {
+		return tcpSegment{}, false
+	}
+	tcp := packet[offset:]
+	dataOffset := int(tcp[12]>>4) * 4
+	if dataOffset < 20 || len(tcp) < dataOffset {
+		return tcpSegment{}, false
+	}
+
+	seg := tcpSegment{
+		flow: Flow{
+			Src: netip.AddrPortFrom(src, binary.BigEndian.Uint16(tcp[0:2])),
+			Dst: netip.AddrPortFrom(dst, binary.BigEndian.Uint16(tcp[2:4])),
+		},
+		seq: binary.BigEndian.Uint32(tcp[4:8]),
+		syn: tcp[13]&0x02 != 0,
+	}
+
+	// The IP length is authoritative: Ethernet may pad short frames
+	payloadLen := total - offset - dataOffset
+	if payloadLen < 0 {
+		return tcpSegment{}, false
+	}
+	data := tcp[dataOffset:]
+	if len(data) > payloadLen {
+		data = data[:payloadLen]
+	}
+	seg.data = data
+	if cut > 0 {
+		seg.missing = payloadLen - len(data)
+	}
+	return seg, true
+}
+
+// maxRecordedSegment keeps synthetic packets under the IPv4 length limit
+const maxRecordedSegment = 60000
+
+// PcapWriter records TCP streams as a libpcap capture
+// Packets are synthesised at the IP layer from the bytes each side sent, so a
+// session seen through a proxy can be read back with ReadPcap, Wireshark or Replay.
+type PcapWriter struct {
+	mu  sync.Mutex
+	w   io.Writer
+	seq map[Flow]uint32 // Next sequence number per direction
+}
+
+// NewPcapWriter writes the capture file header to w
+func NewPcapWriter(w io.Writer) (*PcapWriter, error) {
+	hdr := make([]byte, 24)
+	binary.BigEndian.PutUint32(hdr[0:4], pcapMagicNano)
+	binary.BigEndian.PutUint16(hdr[4:6], 2)
+	binary.BigEndian.PutUint16(hdr[6:8], 4)
+	binary.BigEndian.PutUint32(hdr[16:20], 0xFFFF)
+	binary.BigEndian.PutUint32(hdr[20:24], linkTypeRaw)
+	if _, err := w.Write(hdr); err != nil {
+		return nil, fmt.Errorf("write pcap header: %w", err)
+	}
+	return &PcapWriter{w: w, seq: make(map[Flow]uint32)}, nil
+}
+
+// Open records the handshake of a new connection
+func (pw *PcapWriter) Open(t time.Time, flow Flow) error {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	for _, dir := range []Flow{flow, flow.Reverse()} {
+		pw.seq[dir] = 1
+		if err := pw.writePacket(t, dir, 0, true, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write records data sent in the direction of flow
+func (pw *PcapWriter) Write(t time.Time, flow Flow, data []byte) error {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	for len(data) > 0 {
+		n := len(data)
+		if n > maxRecordedSegment {
+			n = maxRecordedSegment
+		}
+		seq := pw.seq[flow]
+		if err := pw.writePacket(t, flow, seq, false, data[:n]); err != nil {
+			return err
+		}
+		pw.seq[flow] = seq + uint32(n)
+		data = data[n:]
+	}
+	return nil
+}
+
+// writePacket writes one IPv4 or IPv6 TCP packet
+func (pw *PcapWriter) writePacket(t time.Time, flow Flow, seq uint32, syn bool, payload []byte) error {
+	tcp := make([]byte, 20, 20+len(payload))
+	binary.BigEndian.PutUint16(tcp[0:2], flow.Src.Port())
+	binary.BigEndian.PutUint16(tcp[2:4], flow.Dst.Port())
+	binary.BigEndian.PutUint32(tcp[4:8], seq)
+	tcp[12] = 5 << 4
+	tcp[13] = 0x18 // PSH|ACK
+	if syn {
+		tcp[13] = 0x02
+	}
+	binary.BigEndian.PutUint16(tcp[14:16], 0xFFFF)
+	tcp = append(tcp, payload...)
+
+	src, dst := flow.Src.Addr().Unmap(), flow.Dst.Addr().Unmap()
+	var ip []byte
+	if src.Is4() && dst.Is4() {
+		ip = make([]byte, 20)
+		ip[0] = 0x45
+		binary.BigEndian.PutUint16(ip[2:4], uint16(20+len(tcp)))
+		ip[8] = 64
+		ip[9] = 6
+		s4, d4 := src.As4(), dst.As4()
+		copy(ip[12:16], s4[:])
+		copy(ip[16:20], d4[:])
+		binary.BigEndian.PutUint16(ip[10:12], ipv4Checksum(ip))
+	} else {
+		ip = make([]byte, 40)
+		ip[0] = 0x60
+		binary.BigEndian.PutUint16(ip[4:6], uint16(len(tcp)))
+		ip[6] = 6
+		ip[7] = 64
+		s16, d16 := src.As16(), dst.As16()
+		copy(ip[8:24], s16[:])
+		copy(ip[24:40], d16[:])
+	}
+
+	rec := make([]byte, 16)
+	size := uint32(len(ip) + len(tcp))
+	binary.BigEndian.PutUint32(rec[0:4], uint32(t.Unix()))
+	binary.BigEndian.PutUint32(rec[4:8], uint32(t.Nanosecond()))
+	binary.BigEndian.PutUint32(rec[8:12], size)
+	binary.BigEndian.PutUint32(rec[12:16], size)
+
+	for _, part := range [][]byte{rec, ip, tcp} {
+		if _, err := pw.w.Write(part); err != nil {
+			return fmt.Errorf("write pcap record: %w", err)
+		}
+	}
+	return nil
+}
+
+// ipv4Checksum computes the header checksum of an IPv4 header
+func ipv4Checksum(header []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(header); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(header[i : i+2]))
+	}
+	for sum > 0xFFFF {
+		sum = (sum >> 16) + (sum & 0xFFFF)
+	}
+	return ^uint16(sum)
+}
@@ -0,0 +1,187 @@
+package dissect
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// proxyDialTimeout bounds connecting to the upstream relay
+const proxyDialTimeout = 10 * time.Second
+
+// Proxy sits between clients and a relay, forwarding bytes unchanged while logging
+// every decoded header with reply latencies and retransmissions
+// Optionally the traffic is recorded as a pcap that Replay and ReadPcap accept.
+type Proxy struct {
+	upstream string
+	listener net.Listener
+	recorder *PcapWriter // nil when not recording
+	nextID   atomic.Int64
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+}
+
+// NewProxy listens on listenAddr and forwards each connection to upstream
+// record may be nil; otherwise the session is written to it as a pcap capture.
+func NewProxy(listenAddr, upstream string, record io.Writer) (*Proxy, error) {
+	p := &Proxy{
+		upstream: upstream,
+		conns:    make(map[net.Conn]struct{}),
+	}
+	if record != nil {
+		recorder, err := NewPcapWriter(record)
+		if err != nil {
+			return nil, err
+		}
+		p.recorder = recorder
+	}
+
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("proxy listen: %w", err)
+	}
+	p.listener = listener
+	return p, nil
+}
+
+// Addr returns the address the proxy listens on
+func (p *Proxy) Addr() net.Addr {
+	return p.listener.Addr()
+}
+
+// Serve accepts connections until Close is called
+func (p *Proxy) Serve() error {
+	log.Printf("🔍 Debug proxy on %s → %s", p.listener.Addr(), p.upstream)
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		p.wg.Add(1)
+		go p.handle(conn)
+	}
+}
+
+// Close stops accepting, drops open connections and waits for their logs to finish
+func (p *Proxy) Close() error {
+	err := p.listener.Close()
+
+	p.mu.Lock()
+	for conn := range p.conns {
+		conn.Close()
+	}
+	p.mu.Unlock()
+
+	p.wg.Wait()
+	return err
+}
+
+// track registers a connection so Close can drop it
+func (p *Proxy) track(conns ...net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range conns {
+		p.conns[c] = struct{}{}
+	}
+}
+
+// untrack forgets and closes connections
+func (p *Proxy) untrack(conns ...net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range conns {
+		delete(p.conns, c)
+		c.Close()
+	}
+}
+
+// handle proxies one client connection
+func (p *Proxy) handle(client net.Conn) {
+	defer p.wg.Done()
+
+	id := int(p.nextID.Add(1))
+	upstream, err := net.DialTimeout("tcp", p.upstream, proxyDialTimeout)
+	if err != nil {
+		log.Printf("[%d] ❌ Failed to reach upstream %s: %v", id, p.upstream, err)
+		client.Close()
+		return
+	}
+	p.track(client, upstream)
+	defer p.untrack(client, upstream)
+
+	flow := Flow{Src: addrPort(client.RemoteAddr()), Dst: addrPort(upstream.RemoteAddr())}
+	log.Printf("[%d] 🔌 %s", id, flow)
+	if p.recorder != nil {
+		if err := p.recorder.Open(time.Now(), flow); err != nil {
+			log.Printf("[%d] ⚠️  Recording failed: %v", id, err)
+		}
+	}
+
+	s := newSession(id, flow)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		p.pipe(s, flow, client, upstream)
+	}()
+	go func() {
+		defer wg.Done()
+		p.pipe(s, flow.Reverse(), upstream, client)
+	}()
+	wg.Wait()
+	s.close()
+}
+
+// pipe copies src to dst, decoding and recording what passes in the direction of flow
+func (p *Proxy) pipe(s *session, flow Flow, src, dst net.Conn) {
+	dec := newStreamDecoder(flow, false, s.frame)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			now := time.Now()
+			if _, werr := dst.Write(buf[:n]); werr != nil {
+				err = werr
+			}
+			if p.recorder != nil {
+				if rerr := p.recorder.Write(now, flow, buf[:n]); rerr != nil {
+					log.Printf("[%d] ⚠️  Recording failed: %v", s.id, rerr)
+				}
+			}
+			dec.feed(buf[:n], now)
+		}
+		if err != nil {
+			dec.close()
+			if errors.Is(err, io.EOF) {
+				// Pass the half-close on so the other direction can finish
+				if tcp, ok := dst.(*net.TCPConn); ok {
+					tcp.CloseWrite()
+					return
+				}
+			}
+			src.Close()
+			dst.Close()
+			return
+		}
+	}
+}
+
+// addrPort converts a TCP address for flow bookkeeping
+func addrPort(addr net.Addr) netip.AddrPort {
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		return tcp.AddrPort()
+	}
+	ap, _ := netip.ParseAddrPort(addr.String())
+	return ap
+}
@@ -0,0 +1,151 @@
+package dissect
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// startAckRelay runs a minimal relay that answers Handshake with HandshakeAck and
+// everything else with an Ack echoing the message ID
+func startAckRelay(t *testing.T) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				for {
+					header, err := protocol.ReadHeader(conn)
+					if err != nil {
+						return
+					}
+					if _, err := io.CopyN(io.Discard, conn, int64(header.Length)); err != nil {
+						return
+					}
+
+					reply := &protocol.Header{
+						Magic:     protocol.ProtocolMagic,
+						Version:   protocol.ProtocolVersion,
+						Type:      protocol.MsgTypeAck,
+						MessageID: header.MessageID,
+					}
+					if header.Type == protocol.MsgTypeHandshake {
+						reply.Type = protocol.MsgTypeHandshakeAck
+						reply.MessageID = protocol.GenerateMessageID()
+					}
+					if err := protocol.WriteHeader(conn, reply); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return ln
+}
+
+// syncBuffer is a bytes.Buffer safe for the proxy's concurrent recording
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func TestProxyRecordAndReplay(t *testing.T) {
+	relay := startAckRelay(t)
+
+	var recording syncBuffer
+	proxy, err := NewProxy("127.0.0.1:0", relay.Addr().String(), &recording)
+	if err != nil {
+		t.Fatalf("NewProxy: %v", err)
+	}
+	go proxy.Serve()
+
+	conn, err := net.Dial("tcp", proxy.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handshake := encodeFrame(protocol.MsgTypeHandshake, 0, 64)
+	forward := encodeFrame(protocol.MsgTypeRelayForward, protocol.FlagEncrypted, 512)
+	// The forward is sent twice, as a client retransmitting after a lost ACK would
+	for _, frame := range [][]byte{handshake, forward, forward} {
+		if _, err := conn.Write(frame); err != nil {
+			t.Fatal(err)
+		}
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if _, err := protocol.ReadHeader(conn); err != nil {
+			t.Fatalf("read reply through proxy: %v", err)
+		}
+	}
+	conn.Close()
+	proxy.Close()
+
+	frames, err := ReadPcap(bytes.NewReader(recording.buf.Bytes()), 0)
+	if err != nil {
+		t.Fatalf("ReadPcap(recording): %v", err)
+	}
+
+	want := []uint16{
+		protocol.MsgTypeHandshake, protocol.MsgTypeHandshakeAck,
+		protocol.MsgTypeRelayForward, protocol.MsgTypeAck,
+		protocol.MsgTypeRelayForward, protocol.MsgTypeAck,
+	}
+	if len(frames) != len(want) {
+		t.Fatalf("recording has %d frames, want %d", len(frames), len(want))
+	}
+	for i, f := range frames {
+		if f.Header.Type != want[i] {
+			t.Errorf("recorded frame %d = %s, want %s", i, TypeName(f.Header.Type), TypeName(want[i]))
+		}
+		if f.Truncated() {
+			t.Errorf("recorded frame %d truncated", i)
+		}
+	}
+	if !bytes.Equal(frames[2].Encode(), forward) {
+		t.Error("recorded RelayForward differs from what the client sent")
+	}
+
+	result, err := Replay(frames, ReplayConfig{
+		Target: relay.Addr().String(),
+		Linger: 200 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if result.Sent != 3 || result.Skipped != 0 || result.Received != 3 {
+		t.Errorf("replay sent %d, skipped %d, received %d; want 3, 0, 3", result.Sent, result.Skipped, result.Received)
+	}
+}
+
+func TestReplayUnknownConnection(t *testing.T) {
+	frames, err := ReadStream(bytes.NewReader(encodeFrame(protocol.MsgTypePing, 0, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Replay(frames, ReplayConfig{Target: "127.0.0.1:1", Connection: 2}); err == nil {
+		t.Error("Replay of a missing connection succeeded")
+	}
+	if _, err := Replay(nil, ReplayConfig{Target: "127.0.0.1:1"}); err == nil {
+		t.Error("Replay of an empty capture succeeded")
+	}
+}
@@ -0,0 +1,114 @@
+package dissect
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// ReplayConfig controls how a captured session is sent to a relay
+type ReplayConfig struct {
+	Target     string        // Relay address (host:port)
+	Connection int           // Connection to replay, numbered as in WriteTimeline (0 = the first)
+	Speed      float64       // 1 keeps the captured timing, 2 halves the gaps; 0 sends back to back
+	Linger     time.Duration // How long to wait for replies after the last frame
+}
+
+// ReplayResult counts what a replay sent and received
+type ReplayResult struct {
+	Sent     int // Frames written to the target
+	Skipped  int // Frames left out because the capture truncated them
+	Received int // Frames the target sent back
+}
+
+// Replay resends the client side of a captured connection to a relay
+// Frames go out byte-for-byte as captured, including their original message IDs,
+// while the relay's responses are decoded and logged with reply latencies. A relay
+// may reject stale handshakes, which is itself useful to see when chasing ACK bugs.
+func Replay(frames []Frame, cfg ReplayConfig) (*ReplayResult, error) {
+	conns := Connections(frames)
+	if len(conns) == 0 {
+		return nil, fmt.Errorf("capture contains no frames")
+	}
+	index := cfg.Connection
+	if index == 0 {
+		index = 1
+	}
+	if index < 1 || index > len(conns) {
+		return nil, fmt.Errorf("connection %d not in capture (%d connections)", index, len(conns))
+	}
+	client := conns[index-1].Client
+
+	conn, err := net.DialTimeout("tcp", cfg.Target, proxyDialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s: %w", cfg.Target, err)
+	}
+	defer conn.Close()
+
+	flow := Flow{Src: addrPort(conn.LocalAddr()), Dst: addrPort(conn.RemoteAddr())}
+	log.Printf("🔁 Replaying connection %d (%s) to %s", index, client, cfg.Target)
+
+	s := newSession(index, flow)
+	result := &ReplayResult{}
+
+	// Read replies until the connection closes
+	var wg sync.WaitGroup
+	var received int
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		dec := newStreamDecoder(flow.Reverse(), false, func(f Frame) {
+			received++
+			s.frame(f)
+		})
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := conn.Read(buf)
+			dec.feed(buf[:n], time.Now())
+			if err != nil {
+				dec.close()
+				return
+			}
+		}
+	}()
+
+	var last time.Time
+	for i := range frames {
+		f := &frames[i]
+		if f.Flow != client {
+			continue
+		}
+		if f.Truncated() || (f.Header.Length > 0 && f.Payload == nil) {
+			log.Printf("[%d] ⏭️  Skipping %s %x: payload not captured", index, TypeName(f.Header.Type), f.Header.MessageID)
+			result.Skipped++
+			continue
+		}
+
+		if cfg.Speed > 0 && !last.IsZero() && !f.Time.IsZero() {
+			time.Sleep(time.Duration(float64(f.Time.Sub(last)) / cfg.Speed))
+		}
+		last = f.Time
+
+		sent := *f
+		sent.Flow = flow
+		sent.Time = time.Now()
+		sent.Skipped, sent.AfterGap = 0, false
+		if _, err := conn.Write(f.Encode()); err != nil {
+			conn.Close()
+			wg.Wait()
+			return result, fmt.Errorf("write %s: %w", TypeName(f.Header.Type), err)
+		}
+		s.frame(sent)
+		result.Sent++
+	}
+
+	time.Sleep(cfg.Linger)
+	conn.Close()
+	wg.Wait()
+	s.close()
+
+	result.Received = received
+	return result, nil
+}
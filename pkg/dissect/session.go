@@ -0,0 +1,142 @@
+package dissect
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// maxTrackedFrames bounds how many message IDs a session remembers
+const maxTrackedFrames = 100000
+
+// trackedFrame is a frame remembered for reply and retransmission matching
+type trackedFrame struct {
+	frame    Frame
+	at       time.Time
+	sends    int  // Times this message ID was sent in the same direction
+	answered bool // A reply with the same ID (or a HandshakeAck) came back
+}
+
+// session logs the frames of one live connection with reply latencies and retransmissions
+// Replies are recognised the way the relay produces them: Ack, Pong and RelayAck
+// echo the message ID of the frame they answer; HandshakeAck answers the last Handshake.
+type session struct {
+	id     int
+	client Flow
+	start  time.Time
+
+	mu        sync.Mutex
+	sent      map[Flow]map[protocol.MessageID]*trackedFrame
+	handshake map[Flow]*trackedFrame // Unanswered Handshake per direction
+	frames    int
+	replies   int
+	retrans   int
+}
+
+func newSession(id int, client Flow) *session {
+	return &session{
+		id:        id,
+		client:    client,
+		start:     time.Now(),
+		sent:      map[Flow]map[protocol.MessageID]*trackedFrame{client: {}, client.Reverse(): {}},
+		handshake: make(map[Flow]*trackedFrame),
+	}
+}
+
+// frame records and logs a decoded frame
+func (s *session) frame(f Frame) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.frames++
+	now := f.Time
+	var extra []string
+
+	other := f.Flow.Reverse()
+	id := f.Header.MessageID
+	if orig, ok := s.sent[other][id]; ok {
+		orig.answered = true
+		s.replies++
+		extra = append(extra, fmt.Sprintf("reply to %s after %s", TypeName(orig.frame.Header.Type), latency(now.Sub(orig.at))))
+	} else if prev, ok := s.sent[f.Flow][id]; ok {
+		prev.sends++
+		s.retrans++
+		extra = append(extra, fmt.Sprintf("RETRANSMISSION #%d, first sent %s ago", prev.sends-1, latency(now.Sub(prev.at))))
+		if prev.answered {
+			extra = append(extra, "already answered")
+		}
+	} else {
+		if len(s.sent[f.Flow]) >= maxTrackedFrames {
+			s.sent[f.Flow] = make(map[protocol.MessageID]*trackedFrame)
+		}
+		tracked := &trackedFrame{frame: f, at: now, sends: 1}
+		tracked.frame.Payload = nil
+		s.sent[f.Flow][id] = tracked
+		if f.Header.Type == protocol.MsgTypeHandshake {
+			s.handshake[f.Flow] = tracked
+		}
+	}
+
+	if f.Header.Type == protocol.MsgTypeHandshakeAck {
+		if hs := s.handshake[other]; hs != nil {
+			hs.answered = true
+			s.replies++
+			delete(s.handshake, other)
+			extra = append(extra, fmt.Sprintf("handshake answered after %s", latency(now.Sub(hs.at))))
+		}
+	}
+
+	if n := notes(&f); n != "" {
+		extra = append(extra, n)
+	}
+
+	dir := "→"
+	if f.Flow != s.client {
+		dir = "←"
+	}
+	line := fmt.Sprintf("[%d] +%.6fs %s %-14s len=%-7d flags=%-8s id=%x",
+		s.id, now.Sub(s.start).Seconds(), dir, TypeName(f.Header.Type),
+		f.Header.Length, FlagNames(f.Header.Flags), id)
+	if len(extra) > 0 {
+		line += "  " + strings.Join(extra, "; ")
+	}
+	log.Println(line)
+}
+
+// close logs the session totals and any frames still waiting for a reply
+func (s *session) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, dir := range []Flow{s.client, s.client.Reverse()} {
+		for id, t := range s.sent[dir] {
+			if t.answered || !expectsReply(t.frame.Header) {
+				continue
+			}
+			log.Printf("[%d] ⚠️  %s %x from %s was never answered", s.id, TypeName(t.frame.Header.Type), id, dir.Src)
+		}
+	}
+	log.Printf("[%d] closed after %s: %d frames, %d replies, %d retransmissions",
+		s.id, time.Since(s.start).Round(time.Millisecond), s.frames, s.replies, s.retrans)
+}
+
+// expectsReply reports whether the relay protocol answers a frame
+func expectsReply(h protocol.Header) bool {
+	switch h.Type {
+	case protocol.MsgTypePing, protocol.MsgTypeHandshake, protocol.MsgTypeRelayForward:
+		return true
+	}
+	return h.Flags&protocol.FlagRequiresAck != 0
+}
+
+// latency formats a duration for log lines
+func latency(d time.Duration) string {
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond).String()
+	}
+	return d.Round(100 * time.Microsecond).String()
+}
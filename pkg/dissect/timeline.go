@@ -0,0 +1,268 @@
+package dissect
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// typeNames names every message type in the protocol package
+var typeNames = map[uint16]string{
+	protocol.MsgTypeHandshake:             "Handshake",
+	protocol.MsgTypeHandshakeAck:          "HandshakeAck",
+	protocol.MsgTypePing:                  "Ping",
+	protocol.MsgTypePong:                  "Pong",
+	protocol.MsgTypeDisconnect:            "Disconnect",
+	protocol.MsgTypeProbe:                 "Probe",
+	protocol.MsgTypeProbeAck:              "ProbeAck",
+	protocol.MsgTypeResume:                "Resume",
+	protocol.MsgTypeResumeAck:             "ResumeAck",
+	protocol.MsgTypeTicket:                "Ticket",
+	protocol.MsgTypeQueueProgress:         "QueueProgress",
+	protocol.MsgTypeQueueWindow:           "QueueWindow",
+	protocol.MsgTypeOfflineSync:           "OfflineSync",
+	protocol.MsgTypeOfflineSyncResponse:   "OfflineSyncResponse",
+	protocol.MsgTypeStartTLS:              "StartTLS",
+	protocol.MsgTypeStartTLSAck:           "StartTLSAck",
+	protocol.MsgTypeRelayForward:          "RelayForward",
+	protocol.MsgTypeRelayAck:              "RelayAck",
+	protocol.MsgTypeRelayError:            "RelayError",
+	protocol.MsgTypeRelayMoved:            "RelayMoved",
+	protocol.MsgTypeRouteUpdate:           "RouteUpdate",
+	protocol.MsgTypeRoutedMessage:         "RoutedMessage",
+	protocol.MsgTypeDirectMessage:         "DirectMessage",
+	protocol.MsgTypeGroupMessage:          "GroupMessage",
+	protocol.MsgTypeTyping:                "Typing",
+	protocol.MsgTypeReadReceipt:           "ReadReceipt",
+	protocol.MsgTypePresence:              "Presence",
+	protocol.MsgTypeDeviceFanout:          "DeviceFanout",
+	protocol.MsgTypeProfileUpdate:         "ProfileUpdate",
+	protocol.MsgTypeProfileRequest:        "ProfileRequest",
+	protocol.MsgTypeGroupCreate:           "GroupCreate",
+	protocol.MsgTypeGroupJoin:             "GroupJoin",
+	protocol.MsgTypeGroupLeave:            "GroupLeave",
+	protocol.MsgTypeGroupUpdate:           "GroupUpdate",
+	protocol.MsgTypeMediaUpload:           "MediaUpload",
+	protocol.MsgTypeMediaDownload:         "MediaDownload",
+	protocol.MsgTypeMediaDirectoryRequest: "MediaDirectoryRequest",
+	protocol.MsgTypeMediaDirectory:        "MediaDirectory",
+	protocol.MsgTypeKeyBundlePublish:      "KeyBundlePublish",
+	protocol.MsgTypeKeyBundleRequest:      "KeyBundleRequest",
+	protocol.MsgTypeKeyBundleResponse:     "KeyBundleResponse",
+	protocol.MsgTypeError:                 "Error",
+	protocol.MsgTypeAck:                   "Ack",
+	protocol.MsgTypeNack:                  "Nack",
+}
+
+// flagNames lists header flags in bit order
+var flagNames = []struct {
+	flag uint16
+	name string
+}{
+	{protocol.FlagEncrypted, "ENC"},
+	{protocol.FlagCompressed, "ZIP"},
+	{protocol.FlagFragmented, "FRAG"},
+	{protocol.FlagUrgent, "URG"},
+	{protocol.FlagRequiresAck, "ACK"},
+	{protocol.FlagPadded, "PAD"},
+	{protocol.FlagUniformRecords, "REC"},
+	{protocol.FlagResumable, "RES"},
+	{protocol.FlagQueued, "QUE"},
+	{protocol.FlagCBOR, "CBOR"},
+	{protocol.FlagQueueFlow, "FLOW"},
+}
+
+// TypeName returns the name of a message type, or its hex value if unknown
+func TypeName(msgType uint16) string {
+	if name, ok := typeNames[msgType]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%04x", msgType)
+}
+
+// FlagNames formats header flags as "ENC|PAD", or "-" if none are set
+// Unknown bits are shown in hex.
+func FlagNames(flags uint16) string {
+	var names []string
+	for _, f := range flagNames {
+		if flags&f.flag != 0 {
+			names = append(names, f.name)
+			flags &^= f.flag
+		}
+	}
+	if flags != 0 {
+		names = append(names, fmt.Sprintf("0x%04x", flags))
+	}
+	if len(names) == 0 {
+		return "-"
+	}
+	return strings.Join(names, "|")
+}
+
+// Connection groups both directions of a TCP connection
+type Connection struct {
+	Index  int  // 1-based, in order of first frame
+	Client Flow // Direction of the first frame seen
+	Frames int
+	Bytes  int64 // Header and payload bytes on the wire
+}
+
+// Connections numbers the connections in frames in order of appearance
+func Connections(frames []Frame) []*Connection {
+	var conns []*Connection
+	byFlow := make(map[Flow]*Connection)
+	for _, f := range frames {
+		conn, ok := byFlow[f.Flow]
+		if !ok {
+			conn = &Connection{Index: len(conns) + 1, Client: f.Flow}
+			conns = append(conns, conn)
+			byFlow[f.Flow] = conn
+			byFlow[f.Flow.Reverse()] = conn
+		}
+		conn.Frames++
+		conn.Bytes += protocol.HeaderSize + int64(f.Header.Length)
+	}
+	return conns
+}
+
+// Summary counts the traffic in a capture
+type Summary struct {
+	Frames     int
+	Bytes      int64          // Header and payload bytes on the wire
+	ByType     map[uint16]int // Frames per message type
+	Truncated  int            // Frames whose payload was not fully captured
+	Skipped    int64          // Bytes that did not decode as frames
+	Gaps       int            // Places where the capture lost stream data
+	BadVersion int            // Frames with an unsupported protocol version
+}
+
+// Summarize counts frames by type and totals anomalies
+func Summarize(frames []Frame) Summary {
+	s := Summary{ByType: make(map[uint16]int)}
+	for i := range frames {
+		f := &frames[i]
+		s.Frames++
+		s.Bytes += protocol.HeaderSize + int64(f.Header.Length)
+		s.ByType[f.Header.Type]++
+		s.Skipped += f.Skipped
+		if f.Truncated() {
+			s.Truncated++
+		}
+		if f.AfterGap {
+			s.Gaps++
+		}
+		if f.Header.Version != protocol.ProtocolVersion {
+			s.BadVersion++
+		}
+	}
+	return s
+}
+
+// WriteTimeline prints frames as a message flow, one line per frame
+// Connections are listed first and numbered; each line shows the connection, the
+// direction relative to its first frame (→ or ←), the time since the first frame
+// (or the stream offset for raw dumps), and the decoded header.
+func WriteTimeline(w io.Writer, frames []Frame) error {
+	conns := Connections(frames)
+	byFlow := make(map[Flow]*Connection, len(conns)*2)
+	for _, c := range conns {
+		fmt.Fprintf(w, "[%d] %s\n", c.Index, c.Client)
+		byFlow[c.Client] = c
+		byFlow[c.Client.Reverse()] = c
+	}
+	if len(conns) > 0 {
+		fmt.Fprintln(w)
+	}
+
+	var start time.Time
+	if len(frames) > 0 {
+		start = frames[0].Time
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tCONN\tTYPE\tLEN\tFLAGS\tMESSAGE ID\tNOTES")
+	for i := range frames {
+		f := &frames[i]
+
+		when := fmt.Sprintf("@%d", f.Offset)
+		if !f.Time.IsZero() {
+			when = fmt.Sprintf("+%.6fs", f.Time.Sub(start).Seconds())
+		}
+
+		conn := byFlow[f.Flow]
+		dir := "→"
+		if f.Flow != conn.Client {
+			dir = "←"
+		}
+
+		fmt.Fprintf(tw, "%s\t[%d] %s\t%s\t%d\t%s\t%x\t%s\n",
+			when, conn.Index, dir, TypeName(f.Header.Type), f.Header.Length,
+			FlagNames(f.Header.Flags), f.Header.MessageID, notes(f))
+	}
+	return tw.Flush()
+}
+
+// notes describes anything unusual about a frame
+func notes(f *Frame) string {
+	var out []string
+	if f.Header.Version != protocol.ProtocolVersion {
+		out = append(out, fmt.Sprintf("version 0x%04x", f.Header.Version))
+	}
+	if f.AfterGap {
+		out = append(out, "after capture gap")
+	}
+	if f.Skipped > 0 {
+		out = append(out, fmt.Sprintf("skipped %d bytes", f.Skipped))
+	}
+	if f.Truncated() {
+		out = append(out, fmt.Sprintf("payload %d/%d captured", f.PayloadSeen, f.Header.Length))
+	}
+	if f.Header.Reserved != 0 {
+		out = append(out, fmt.Sprintf("reserved 0x%04x", f.Header.Reserved))
+	}
+	if f.Header.Type == protocol.MsgTypeRelayError && !f.Truncated() {
+		var relayErr protocol.RelayErrorMessage
+		if err := relayErr.Decode(f.Payload); err == nil {
+			out = append(out, relayErr.Error())
+		}
+	}
+	return strings.Join(out, ", ")
+}
+
+// WriteSummary prints frame counts per type and any anomalies
+func WriteSummary(w io.Writer, s Summary) error {
+	fmt.Fprintf(w, "%d frames, %d bytes\n", s.Frames, s.Bytes)
+
+	types := make([]uint16, 0, len(s.ByType))
+	for t := range s.ByType {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, t := range types {
+		fmt.Fprintf(tw, "  %s\t%d\n", TypeName(t), s.ByType[t])
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if s.Truncated > 0 {
+		fmt.Fprintf(w, "%d frames truncated by the capture\n", s.Truncated)
+	}
+	if s.Gaps > 0 {
+		fmt.Fprintf(w, "%d gaps in captured stream data\n", s.Gaps)
+	}
+	if s.Skipped > 0 {
+		fmt.Fprintf(w, "%d bytes did not decode as frames\n", s.Skipped)
+	}
+	if s.BadVersion > 0 {
+		fmt.Fprintf(w, "%d frames with an unsupported protocol version\n", s.BadVersion)
+	}
+	return nil
+}
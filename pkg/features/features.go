@@ -0,0 +1,148 @@
+// Package features is the registry of optional features a node is built with and has switched on
+//
+// A feature is compiled in when this binary can provide it, and enabled when
+// the node's configuration turns it on. Relays advertise their enabled features
+// in handshake acks and in the metadata they publish to the DHT, and mesh
+// storage nodes list theirs in /api/v1/node/info, so operators and tooling can
+// tell what each node of a mixed deployment supports while a rollout is under way.
+//
+// Versions count incompatible changes to a feature; a node that implements
+// version 2 of a feature may refuse peers that only speak version 1.
+package features
+
+import (
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/ZentaChain/zentalk-node/pkg/chaos"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// Relay features
+const (
+	MeshRouting    = "mesh-routing"    // Final deliveries routed through relay peers
+	MeshShaping    = "mesh-shaping"    // Rate limits on relay-to-relay links
+	UniformRecords = "uniform-records" // Fixed-size records on links that ask for them
+	SessionResume  = "session-resume"  // Resumption tickets after a dropped connection
+	RelayPolicy    = "relay-policy"    // Frame types and sizes accepted from users
+	Cluster        = "cluster"         // Several relay processes sharing one queue
+	Tenants        = "tenants"         // Organizations isolated on one relay
+	Bots           = "bots"            // Bot accounts authenticated by API key
+	QueuePriority  = "queue-priority"  // Queued messages ranked by stake and vouchers
+	DeliveryProofs = "delivery-proofs" // Signed proofs of recipients' acks
+	KeyBundles     = "key-bundles"     // Key bundles fetched while their owner is offline
+	MediaDirectory = "media-directory" // Storage endpoints and media limits for users
+	OfflineSync    = "offline-sync"    // Offline queues pulled by clients in acknowledged pages
+	WebSocket      = "websocket"       // Browser clients over WebSocket
+	TLS            = "tls"             // Client connections switched to TLS with StartTLS
+)
+
+// Mesh storage features
+const (
+	StreamedUploads = "streamed-uploads" // Resumable uploads split across chunks
+	SharedLinks     = "shared-links"     // Signed download links
+	AdminAPI        = "admin-api"        // Operator endpoints under /api/v1/admin
+	ChunkCache      = "chunk-cache"      // In-memory cache of hot chunks
+	StoragePins     = "storage-pins"     // Users' chunks kept partly on nodes they pin
+)
+
+// Chaos is fault injection, only compiled into binaries built with the chaos tag
+const Chaos = "chaos"
+
+// builtin is the version of every feature this binary implements
+var builtin = map[string]uint16{
+	MeshRouting:     1,
+	MeshShaping:     1,
+	UniformRecords:  1,
+	SessionResume:   1,
+	RelayPolicy:     1,
+	Cluster:         1,
+	Tenants:         1,
+	Bots:            1,
+	QueuePriority:   1,
+	DeliveryProofs:  1,
+	KeyBundles:      2,
+	MediaDirectory:  1,
+	OfflineSync:     1,
+	WebSocket:       1,
+	TLS:             1,
+	StreamedUploads: 1,
+	SharedLinks:     1,
+	AdminAPI:        1,
+	ChunkCache:      1,
+	StoragePins:     1,
+}
+
+func init() {
+	if chaos.Enabled {
+		builtin[Chaos] = 1
+	}
+}
+
+// Flag is one compiled-in feature and whether it is switched on
+type Flag struct {
+	Name    string `json:"name"`
+	Version uint16 `json:"version"`
+	Enabled bool   `json:"enabled"`
+}
+
+// Registry tracks which compiled-in features a node has switched on
+type Registry struct {
+	mu      sync.RWMutex
+	enabled map[string]bool
+}
+
+// NewRegistry creates a registry with every feature off
+func NewRegistry() *Registry {
+	return &Registry{enabled: make(map[string]bool)}
+}
+
+// Compiled reports whether this binary implements a feature
+func Compiled(name string) bool {
+	_, ok := builtin[name]
+	return ok
+}
+
+// Set switches a feature on or off
+// Features not compiled into this binary stay off.
+func (r *Registry) Set(name string, enabled bool) {
+	if !Compiled(name) {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.enabled[name] = enabled
+}
+
+// Enabled reports whether a feature is switched on
+func (r *Registry) Enabled(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.enabled[name]
+}
+
+// Flags returns every compiled-in feature, sorted by name
+func (r *Registry) Flags() []Flag {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	flags := make([]Flag, 0, len(builtin))
+	for name, version := range builtin {
+		flags = append(flags, Flag{Name: name, Version: version, Enabled: r.enabled[name]})
+	}
+	slices.SortFunc(flags, func(a, b Flag) int { return strings.Compare(a.Name, b.Name) })
+	return flags
+}
+
+// Active returns the switched-on features as advertised to peers, sorted by name
+func (r *Registry) Active() protocol.FeatureList {
+	var active protocol.FeatureList
+	for _, flag := range r.Flags() {
+		if flag.Enabled {
+			active = append(active, protocol.Feature{Name: flag.Name, Version: flag.Version})
+		}
+	}
+	return active
+}
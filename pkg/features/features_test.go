@@ -0,0 +1,47 @@
+package features
+
+import (
+	"testing"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	if active := registry.Active(); len(active) != 0 {
+		t.Errorf("Active() = %v, want none", active)
+	}
+
+	registry.Set(Tenants, true)
+	registry.Set(Bots, true)
+	registry.Set("teleport", true)
+	if !registry.Enabled(Bots) || registry.Enabled(Cluster) {
+		t.Errorf("Enabled(bots) = %v, Enabled(cluster) = %v", registry.Enabled(Bots), registry.Enabled(Cluster))
+	}
+
+	// Unknown features are never reported on
+	if registry.Enabled("teleport") {
+		t.Error("Enabled(teleport) = true for a feature not compiled in")
+	}
+
+	active := registry.Active()
+	want := protocol.FeatureList{{Name: Bots, Version: 1}, {Name: Tenants, Version: 1}}
+	if len(active) != len(want) || active[0] != want[0] || active[1] != want[1] {
+		t.Errorf("Active() = %v, want %v", active, want)
+	}
+
+	flags := registry.Flags()
+	if len(flags) != len(builtin) {
+		t.Fatalf("Flags() returned %d features, want %d", len(flags), len(builtin))
+	}
+	for i := 1; i < len(flags); i++ {
+		if flags[i-1].Name >= flags[i].Name {
+			t.Errorf("Flags() not sorted: %q before %q", flags[i-1].Name, flags[i].Name)
+		}
+	}
+
+	registry.Set(Bots, false)
+	if registry.Enabled(Bots) {
+		t.Error("Enabled(bots) = true after switching it off")
+	}
+}
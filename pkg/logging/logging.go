@@ -0,0 +1,229 @@
+// Package logging keeps relay and storage node logs within a disk budget
+//
+// A Sink takes the lines written through the standard log package (and, after
+// CaptureStdout, through fmt.Print) and
+//
+//   - stamps each with the time and appends it to a file that is rotated at
+//     MaxSize, keeping at most MaxBackups rotated files no older than MaxAge
+//   - keeps the last RecentLines lines in memory, for the storage node's admin API
+//   - lets each kind of line through RepeatBurst times per RepeatWindow, then
+//     logs how many more were suppressed
+//
+// Lines are of one kind when they differ only in words containing digits, so a
+// repair pass logging "Repaired chunk 12" for every chunk costs a few lines per
+// window rather than one per chunk.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// timeFormat stamps each line like the log package's default flags
+const timeFormat = "2006/01/02 15:04:05 "
+
+// Config controls where a Sink writes and how much it keeps
+type Config struct {
+	Path         string        // Log file; empty writes to stdout only
+	MaxSize      int64         // Rotate the file once it would grow past this many bytes (0 = never)
+	MaxAge       time.Duration // Delete rotated files older than this (0 = keep by count only)
+	MaxBackups   int           // Rotated files kept (0 = keep by age only)
+	Console      bool          // Also write to stdout when logging to Path
+	RecentLines  int           // Lines kept in memory for Recent (0 = none)
+	RepeatBurst  int           // Lines of one kind written per RepeatWindow (0 = no suppression)
+	RepeatWindow time.Duration
+}
+
+// DefaultConfig returns limits that bound a node's logs to about 600 MB
+func DefaultConfig() Config {
+	return Config{
+		MaxSize:      100 << 20,
+		MaxAge:       7 * 24 * time.Hour,
+		MaxBackups:   5,
+		RecentLines:  1000,
+		RepeatBurst:  5,
+		RepeatWindow: time.Minute,
+	}
+}
+
+// Entry is one logged line
+type Entry struct {
+	Time time.Time `json:"time"`
+	Line string    `json:"line"`
+}
+
+// Sink is an io.Writer that stamps, filters, rotates and remembers log lines
+type Sink struct {
+	mu      sync.Mutex
+	file    *rotatingFile // nil = no log file
+	console io.Writer     // nil = no console output
+	recent  *recentLines
+	repeats *repeatFilter
+	partial []byte // Start of a line whose newline hasn't been written yet
+	now     func() time.Time
+
+	stdout   *os.File      // os.Stdout before CaptureStdout
+	pipe     *os.File      // Write end of the pipe standing in for os.Stdout
+	captured chan struct{} // Closed once the pipe is drained
+}
+
+// New creates a Sink from cfg, opening the log file if one is configured
+func New(cfg Config) (*Sink, error) {
+	s := &Sink{
+		recent:  newRecentLines(cfg.RecentLines),
+		repeats: newRepeatFilter(cfg.RepeatBurst, cfg.RepeatWindow),
+		now:     time.Now,
+		stdout:  os.Stdout,
+	}
+	if cfg.Path == "" || cfg.Console {
+		s.console = os.Stdout
+	}
+	if cfg.Path != "" {
+		file, err := openRotatingFile(cfg.Path, cfg.MaxSize, cfg.MaxAge, cfg.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		s.file = file
+	}
+	return s, nil
+}
+
+// Install makes the standard logger write through the Sink
+// The Sink adds its own timestamps, so the logger's date and time flags are cleared.
+func (s *Sink) Install() {
+	log.SetFlags(0)
+	log.SetOutput(s)
+}
+
+// CaptureStdout points os.Stdout at the Sink so fmt.Print output is filtered and rotated too
+// Output the process already holds the old os.Stdout for (e.g. a gin.DefaultWriter
+// set at init) is not captured.
+func (s *Sink) CaptureStdout() error {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("failed to capture stdout: %w", err)
+	}
+
+	s.mu.Lock()
+	s.pipe = w
+	s.captured = make(chan struct{})
+	s.mu.Unlock()
+	os.Stdout = w
+
+	go func() {
+		defer close(s.captured)
+		io.Copy(s, r)
+		r.Close()
+	}()
+	return nil
+}
+
+// Write logs each complete line in p; a trailing partial line waits for its newline
+func (s *Sink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.partial = append(s.partial, p...)
+	for {
+		i := indexNewline(s.partial)
+		if i < 0 {
+			break
+		}
+		s.line(string(s.partial[:i]))
+		s.partial = s.partial[i+1:]
+	}
+	if len(s.partial) == 0 {
+		s.partial = nil
+	}
+	return len(p), nil
+}
+
+// indexNewline returns the index of the first '\n' in b, or -1
+func indexNewline(b []byte) int {
+	for i, c := range b {
+		if c == '\n' {
+			return i
+		}
+	}
+	return -1
+}
+
+// line logs one line unless it is suppressed as a repeat (caller holds mu)
+func (s *Sink) line(text string) {
+	now := s.now()
+	for _, summary := range s.repeats.sweep(now, false) {
+		s.emit(now, summary)
+	}
+
+	allowed, summary := s.repeats.allow(text, now)
+	if summary != "" {
+		s.emit(now, summary)
+	}
+	if allowed {
+		s.emit(now, text)
+	}
+}
+
+// emit writes a line to the console, the log file and the recent lines (caller holds mu)
+func (s *Sink) emit(now time.Time, text string) {
+	stamped := now.Format(timeFormat) + text + "\n"
+	if s.console != nil {
+		io.WriteString(s.console, stamped)
+	}
+	if s.file != nil {
+		if _, err := s.file.Write([]byte(stamped)); err != nil && s.console == nil {
+			fmt.Fprintf(os.Stderr, "failed to write log file: %v\n%s", err, stamped)
+		}
+	}
+	s.recent.add(Entry{Time: now, Line: text})
+}
+
+// Recent returns up to limit of the latest lines, oldest first (limit <= 0 for all kept)
+func (s *Sink) Recent(limit int) []Entry {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.recent.last(limit)
+}
+
+// Close restores os.Stdout, logs pending suppression counts and closes the log file
+func (s *Sink) Close() error {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	pipe, captured := s.pipe, s.captured
+	s.pipe = nil
+	s.mu.Unlock()
+	if pipe != nil {
+		os.Stdout = s.stdout
+		pipe.Close()
+		<-captured
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.partial) > 0 {
+		s.line(string(s.partial))
+		s.partial = nil
+	}
+	now := s.now()
+	for _, summary := range s.repeats.sweep(now, true) {
+		s.emit(now, summary)
+	}
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
@@ -0,0 +1,122 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSinkWritesFileAndRecent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "relay.log")
+	cfg := DefaultConfig()
+	cfg.Path = path
+	cfg.RecentLines = 3
+
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	s.now = func() time.Time { return time.Date(2026, 10, 16, 12, 0, 0, 0, time.Local) }
+
+	// Lines may arrive split across writes
+	fmt.Fprint(s, "first line\nsecond ")
+	fmt.Fprint(s, "line\n")
+	fmt.Fprintln(s, "third line")
+	fmt.Fprintln(s, "fourth line")
+	fmt.Fprint(s, "unterminated")
+
+	recent := s.Recent(0)
+	if len(recent) != 3 || recent[0].Line != "second line" || recent[2].Line != "fourth line" {
+		t.Errorf("Recent(0) = %v, want the last 3 complete lines", recent)
+	}
+	if last := s.Recent(1); len(last) != 1 || last[0].Line != "fourth line" {
+		t.Errorf("Recent(1) = %v", last)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("log file has %d lines, want 5:\n%s", len(lines), data)
+	}
+	if lines[0] != "2026/10/16 12:00:00 first line" {
+		t.Errorf("first line = %q", lines[0])
+	}
+	if lines[4] != "2026/10/16 12:00:00 unterminated" {
+		t.Errorf("partial line flushed on close as %q", lines[4])
+	}
+}
+
+func TestSinkSuppressesFloods(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mesh.log")
+	cfg := DefaultConfig()
+	cfg.Path = path
+
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(s, "⚠️  Failed to repair chunk %d: no peers\n", i)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != cfg.RepeatBurst+1 {
+		t.Fatalf("log file has %d lines, want %d and a summary", len(lines), cfg.RepeatBurst)
+	}
+	if !strings.Contains(lines[len(lines)-1], "995 similar lines suppressed") {
+		t.Errorf("summary line = %q", lines[len(lines)-1])
+	}
+}
+
+func TestSinkCaptureStdout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mesh.log")
+	cfg := DefaultConfig()
+	cfg.Path = path
+
+	stdout := os.Stdout
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CaptureStdout(); err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println("📡 Starting DHT node")
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if os.Stdout != stdout {
+		t.Error("Close() did not restore os.Stdout")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(strings.TrimSpace(string(data)), "📡 Starting DHT node") {
+		t.Errorf("log file = %q, want the captured line", data)
+	}
+
+	// A nil Sink is usable as "no logging configured"
+	var none *Sink
+	if none.Recent(10) != nil || none.Close() != nil {
+		t.Error("nil Sink is not a no-op")
+	}
+}
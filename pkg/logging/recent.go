@@ -0,0 +1,50 @@
+package logging
+
+// recentLines is a ring buffer of the latest log lines
+type recentLines struct {
+	entries []Entry
+	next    int  // Slot the next line goes in
+	full    bool // Every slot holds a line
+}
+
+// newRecentLines creates a buffer for size lines (size <= 0 keeps none)
+func newRecentLines(size int) *recentLines {
+	if size < 0 {
+		size = 0
+	}
+	return &recentLines{entries: make([]Entry, size)}
+}
+
+// add stores a line, overwriting the oldest once full
+func (r *recentLines) add(e Entry) {
+	if len(r.entries) == 0 {
+		return
+	}
+	r.entries[r.next] = e
+	r.next++
+	if r.next == len(r.entries) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+// last returns up to limit of the latest lines, oldest first (limit <= 0 for all)
+func (r *recentLines) last(limit int) []Entry {
+	count := r.next
+	if r.full {
+		count = len(r.entries)
+	}
+	if limit <= 0 || limit > count {
+		limit = count
+	}
+
+	out := make([]Entry, limit)
+	start := r.next - limit
+	if start < 0 {
+		start += len(r.entries)
+	}
+	for i := range out {
+		out[i] = r.entries[(start+i)%len(r.entries)]
+	}
+	return out
+}
@@ -0,0 +1,110 @@
+package logging
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// maxRepeatKinds bounds the kinds of line tracked at once; further kinds are never suppressed
+const maxRepeatKinds = 4096
+
+// repeatFilter suppresses a kind of line once it has been written burst times in a window
+type repeatFilter struct {
+	burst     int
+	window    time.Duration
+	kinds     map[string]*repeatKind
+	nextSweep time.Time
+}
+
+// repeatKind counts one kind of line in the current window
+type repeatKind struct {
+	start      time.Time
+	count      int    // Lines seen since start
+	suppressed string // Latest line not written
+}
+
+// newRepeatFilter creates a filter; burst or window <= 0 disables suppression
+func newRepeatFilter(burst int, window time.Duration) *repeatFilter {
+	if burst <= 0 || window <= 0 {
+		return nil
+	}
+	return &repeatFilter{
+		burst:  burst,
+		window: window,
+		kinds:  make(map[string]*repeatKind),
+	}
+}
+
+// repeatKey reduces a line to its kind by blanking every word that contains a digit
+func repeatKey(line string) string {
+	words := strings.Fields(line)
+	for i, word := range words {
+		if strings.IndexFunc(word, unicode.IsDigit) >= 0 {
+			words[i] = "#"
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// allow reports whether line may be written
+// summary is non-empty when line starts a new window for a kind whose
+// previous window had lines suppressed; it should be written first.
+func (f *repeatFilter) allow(line string, now time.Time) (bool, string) {
+	if f == nil {
+		return true, ""
+	}
+
+	key := repeatKey(line)
+	kind, ok := f.kinds[key]
+	if !ok {
+		if len(f.kinds) >= maxRepeatKinds {
+			return true, ""
+		}
+		f.kinds[key] = &repeatKind{start: now, count: 1}
+		return true, ""
+	}
+
+	if now.Sub(kind.start) >= f.window {
+		summary := kind.summary(f.burst)
+		*kind = repeatKind{start: now, count: 1}
+		return true, summary
+	}
+
+	kind.count++
+	if kind.count <= f.burst {
+		return true, ""
+	}
+	kind.suppressed = line
+	return false, ""
+}
+
+// summary describes the lines suppressed in the kind's window ("" if none were)
+func (k *repeatKind) summary(burst int) string {
+	if k.count <= burst {
+		return ""
+	}
+	return fmt.Sprintf("🔇 %d similar lines suppressed, last: %s", k.count-burst, k.suppressed)
+}
+
+// sweep forgets kinds whose window is over and returns summaries of their suppressed lines
+// Without all, it runs at most once a second and keeps kinds still in their window.
+func (f *repeatFilter) sweep(now time.Time, all bool) []string {
+	if f == nil || (!all && now.Before(f.nextSweep)) {
+		return nil
+	}
+	f.nextSweep = now.Add(time.Second)
+
+	var summaries []string
+	for key, kind := range f.kinds {
+		if !all && now.Sub(kind.start) < f.window {
+			continue
+		}
+		if summary := kind.summary(f.burst); summary != "" {
+			summaries = append(summaries, summary)
+		}
+		delete(f.kinds, key)
+	}
+	return summaries
+}
@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRepeatKey(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"Repaired chunk 12", "Repaired chunk 13", true},
+		{"⚠️  Peer 12D3KooWabc unreachable: timeout", "⚠️  Peer 12D3KooWxyz unreachable: timeout", true},
+		{"Relayed to 10.0.0.1:9001", "Relayed to 10.0.0.2:9001", true},
+		{"Repaired chunk 12", "Deleted chunk 12", false},
+		{"Peer connected", "Peer disconnected", false},
+	}
+
+	for _, tt := range tests {
+		if same := repeatKey(tt.a) == repeatKey(tt.b); same != tt.same {
+			t.Errorf("repeatKey(%q) == repeatKey(%q) is %v, want %v", tt.a, tt.b, same, tt.same)
+		}
+	}
+}
+
+func TestRepeatFilter(t *testing.T) {
+	f := newRepeatFilter(3, time.Minute)
+	now := time.Unix(1_700_000_000, 0)
+
+	written := 0
+	for i := 0; i < 10; i++ {
+		if ok, _ := f.allow("Repaired chunk "+strings.Repeat("1", i+1), now); ok {
+			written++
+		}
+	}
+	if written != 3 {
+		t.Fatalf("%d of 10 repeats written, want 3", written)
+	}
+
+	// Other kinds of line are not affected
+	if ok, _ := f.allow("Peer connected", now); !ok {
+		t.Error("a different line was suppressed")
+	}
+
+	// The sweep leaves kinds in their window alone
+	if summaries := f.sweep(now.Add(30*time.Second), false); len(summaries) != 0 {
+		t.Errorf("sweep() inside the window = %v", summaries)
+	}
+
+	// The next window starts with a count of what was suppressed
+	ok, summary := f.allow("Repaired chunk 5", now.Add(time.Minute))
+	if !ok {
+		t.Error("first line of a new window was suppressed")
+	}
+	if !strings.Contains(summary, "7 similar lines suppressed") || !strings.HasSuffix(summary, "Repaired chunk 1111111111") {
+		t.Errorf("summary = %q", summary)
+	}
+
+	// Close reports every pending count
+	for i := 0; i < 4; i++ {
+		f.allow("Repaired chunk 6", now.Add(time.Minute))
+	}
+	summaries := f.sweep(now.Add(time.Minute), true)
+	if len(summaries) != 1 || !strings.Contains(summaries[0], "2 similar lines") {
+		t.Errorf("sweep(all) = %v, want one summary of 2 lines", summaries)
+	}
+	if len(f.kinds) != 0 {
+		t.Errorf("%d kinds left after sweep(all)", len(f.kinds))
+	}
+
+	var disabled *repeatFilter
+	if ok, _ := disabled.allow("anything", now); !ok {
+		t.Error("disabled filter suppressed a line")
+	}
+}
@@ -0,0 +1,133 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupTimeFormat names rotated files, e.g. relay.log.2026-10-16T12-00-00.000
+// It sorts in time order and has no characters Windows forbids in file names.
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// rotatingFile is an append-only log file that is renamed aside when it gets too big
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+	file       *os.File
+	size       int64
+	now        func() time.Time
+}
+
+// openRotatingFile opens path for appending and deletes rotated files past the limits
+func openRotatingFile(path string, maxSize int64, maxAge time.Duration, maxBackups int) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	f := &rotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxAge:     maxAge,
+		maxBackups: maxBackups,
+		now:        time.Now,
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	f.prune()
+	return f, nil
+}
+
+// open opens the current log file
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// Write appends p, rotating first if p would take the file past maxSize
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate renames the current file aside and starts a new one
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	backup := f.path + "." + f.now().UTC().Format(backupTimeFormat)
+	if err := os.Rename(f.path, backup); err != nil {
+		// Keep appending to the old file rather than losing lines
+		if openErr := f.open(); openErr != nil {
+			return openErr
+		}
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.prune()
+	return nil
+}
+
+// backup is a rotated log file
+type backup struct {
+	path    string
+	rotated time.Time
+}
+
+// backups lists the rotated files, oldest first
+func (f *rotatingFile) backups() []backup {
+	matches, _ := filepath.Glob(f.path + ".*")
+
+	var found []backup
+	for _, match := range matches {
+		rotated, err := time.Parse(backupTimeFormat, strings.TrimPrefix(match, f.path+"."))
+		if err != nil {
+			continue // Not one of ours
+		}
+		found = append(found, backup{path: match, rotated: rotated})
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].rotated.Before(found[j].rotated) })
+	return found
+}
+
+// prune deletes rotated files beyond maxBackups or older than maxAge
+func (f *rotatingFile) prune() {
+	found := f.backups()
+	cutoff := f.now().Add(-f.maxAge)
+	for i, b := range found {
+		tooMany := f.maxBackups > 0 && len(found)-i > f.maxBackups
+		tooOld := f.maxAge > 0 && b.rotated.Before(cutoff)
+		if tooMany || tooOld {
+			os.Remove(b.path)
+		}
+	}
+}
+
+// Close closes the current file
+func (f *rotatingFile) Close() error {
+	return f.file.Close()
+}
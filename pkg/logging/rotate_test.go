@@ -0,0 +1,85 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFileRotatesAtMaxSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "relay.log")
+	f, err := openRotatingFile(path, 100, 0, 2)
+	if err != nil {
+		t.Fatalf("openRotatingFile() error = %v", err)
+	}
+	defer f.Close()
+
+	clock := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	f.now = func() time.Time { return clock }
+
+	line := []byte(strings.Repeat("x", 39) + "\n")
+	for i := 0; i < 10; i++ {
+		clock = clock.Add(time.Second)
+		if _, err := f.Write(line); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	// Two 40-byte lines fit in each file; only the newest two rotated files are kept
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 80 {
+		t.Errorf("current file is %d bytes, want 80", info.Size())
+	}
+
+	backups := f.backups()
+	if len(backups) != 2 {
+		t.Fatalf("%d rotated files kept, want 2", len(backups))
+	}
+	for _, b := range backups {
+		data, err := os.ReadFile(b.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) > 100 {
+			t.Errorf("%s is %d bytes, over the 100 byte limit", b.path, len(data))
+		}
+	}
+	if !backups[0].rotated.Before(backups[1].rotated) {
+		t.Error("backups are not sorted oldest first")
+	}
+}
+
+func TestRotatingFilePrunesByAge(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mesh.log")
+
+	now := time.Now().UTC()
+	old := path + "." + now.Add(-48*time.Hour).Format(backupTimeFormat)
+	recent := path + "." + now.Add(-time.Hour).Format(backupTimeFormat)
+	unrelated := path + ".bak"
+	for _, name := range []string{old, recent, unrelated} {
+		if err := os.WriteFile(name, []byte("line\n"), 0640); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := openRotatingFile(path, 0, 24*time.Hour, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Error("rotated file older than the max age was kept")
+	}
+	for _, name := range []string{recent, unrelated} {
+		if _, err := os.Stat(name); err != nil {
+			t.Errorf("%s was deleted: %v", filepath.Base(name), err)
+		}
+	}
+}
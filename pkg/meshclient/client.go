@@ -0,0 +1,396 @@
+// Package meshclient is a client SDK for ZenTalk mesh storage
+//
+// It wraps the mesh storage HTTP API for end-user applications: data is
+// encrypted on the client before upload, owned chunks are tracked in a local
+// SQLite manifest, transient failures are retried, and downloads are verified
+// against the content hash recorded at upload time.
+package meshclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/meshstorage"
+	"github.com/ZentaChain/zentalk-node/pkg/meshstorage/api"
+)
+
+const (
+	// DefaultMaxRetries is how many times a failed request is retried
+	DefaultMaxRetries = 3
+
+	// DefaultRetryBackoff is the delay before the first retry; it doubles on each attempt
+	DefaultRetryBackoff = 500 * time.Millisecond
+
+	// maxRetryWait caps the delay between attempts, including server Retry-After hints
+	maxRetryWait = 30 * time.Second
+)
+
+// ErrIntegrity is returned when downloaded data fails decryption or does not
+// match the hash recorded in the manifest
+var ErrIntegrity = errors.New("integrity check failed")
+
+// Config configures a Client
+type Config struct {
+	BaseURL      string                     // Mesh API address, e.g. http://localhost:8080
+	UserAddr     string                     // Owner's Ethereum address (0x...)
+	Key          *meshstorage.EncryptionKey // Client-side encryption key (see meshstorage.DeriveKeyFromSignature)
+	ManifestPath string                     // SQLite file for the local manifest
+	Identity     *rsa.PrivateKey            // Optional; signs and receives sharing grants
+	Wallet       *ecdsa.PrivateKey          // Optional; ties Identity to UserAddr for grants and a first pin set
+	HTTPClient   *http.Client               // Optional; defaults to a client with a 2 minute timeout
+	MaxRetries   int                        // 0 = DefaultMaxRetries, negative disables retries
+	RetryBackoff time.Duration              // 0 = DefaultRetryBackoff
+}
+
+// APIError is a non-success response from the mesh API
+type APIError struct {
+	StatusCode int
+	Response   api.ErrorResponse
+}
+
+// Error implements error
+func (e *APIError) Error() string {
+	if e.Response.Message != "" {
+		return fmt.Sprintf("mesh API %d: %s: %s", e.StatusCode, e.Response.Error, e.Response.Message)
+	}
+	return fmt.Sprintf("mesh API %d: %s", e.StatusCode, e.Response.Error)
+}
+
+// IsNotFound reports whether err is a 404 from the mesh API
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// Client uploads and downloads a user's chunks through the mesh storage API
+type Client struct {
+	baseURL    string
+	userAddr   string
+	key        *meshstorage.EncryptionKey
+	http       *http.Client
+	maxRetries int
+	backoff    time.Duration
+	manifest   *manifest
+	identity   *rsa.PrivateKey
+	wallet     *ecdsa.PrivateKey
+}
+
+// New creates a client and opens its manifest
+func New(cfg Config) (*Client, error) {
+	if cfg.BaseURL == "" {
+		return nil, fmt.Errorf("base URL is required")
+	}
+	if len(cfg.UserAddr) != 42 || cfg.UserAddr[:2] != "0x" {
+		return nil, fmt.Errorf("user address must be a valid Ethereum address (0x...)")
+	}
+	if cfg.Key == nil {
+		return nil, fmt.Errorf("encryption key is required")
+	}
+	if cfg.ManifestPath == "" {
+		return nil, fmt.Errorf("manifest path is required")
+	}
+
+	m, err := openManifest(cfg.ManifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 2 * time.Minute}
+	}
+
+	maxRetries := cfg.MaxRetries
+	switch {
+	case maxRetries == 0:
+		maxRetries = DefaultMaxRetries
+	case maxRetries < 0:
+		maxRetries = 0
+	}
+
+	backoff := cfg.RetryBackoff
+	if backoff <= 0 {
+		backoff = DefaultRetryBackoff
+	}
+
+	return &Client{
+		baseURL:    strings.TrimRight(cfg.BaseURL, "/") + "/api/v1/storage",
+		userAddr:   cfg.UserAddr,
+		key:        cfg.Key,
+		http:       httpClient,
+		maxRetries: maxRetries,
+		backoff:    backoff,
+		manifest:   m,
+		identity:   cfg.Identity,
+		wallet:     cfg.Wallet,
+	}, nil
+}
+
+// Close releases the manifest database
+func (c *Client) Close() error {
+	return c.manifest.close()
+}
+
+// Upload encrypts data, stores it as chunkID and records it in the manifest
+// Payloads larger than one session part are sent as a multi-part upload session
+// so a dropped connection only retries the affected part
+func (c *Client) Upload(ctx context.Context, chunkID int, data []byte) (*ChunkRecord, error) {
+	envelope, err := seal(data, deriveChunkKey(c.key, c.userAddr, chunkID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt chunk: %w", err)
+	}
+
+	var resp api.UploadResponse
+	if len(envelope) > api.SessionPartSize {
+		err = c.uploadSession(ctx, chunkID, envelope, &resp)
+	} else {
+		err = c.uploadSingle(ctx, chunkID, envelope, &resp)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rec := &ChunkRecord{
+		UserAddr:    c.userAddr,
+		ChunkID:     chunkID,
+		Size:        len(data),
+		StoredSize:  len(envelope),
+		ContentHash: meshstorage.HashData(data),
+		ShardCount:  resp.ShardCount,
+		UploadedAt:  time.Now(),
+	}
+	if err := c.manifest.put(rec); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// uploadSingle stores an envelope with one upload request
+func (c *Client) uploadSingle(ctx context.Context, chunkID int, envelope []byte, out *api.UploadResponse) error {
+	body, err := json.Marshal(api.UploadRequest{
+		UserAddr:  c.userAddr,
+		ChunkID:   chunkID,
+		Data:      base64.StdEncoding.EncodeToString(envelope),
+		Encrypted: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal upload: %w", err)
+	}
+
+	return c.do(ctx, http.MethodPost, "/upload", body, "application/json", out)
+}
+
+// uploadSession stores an envelope through a multi-part upload session
+// The session is cancelled on the server if any part or the completion fails
+func (c *Client) uploadSession(ctx context.Context, chunkID int, envelope []byte, out *api.UploadResponse) error {
+	body, err := json.Marshal(api.CreateSessionRequest{
+		UserAddr:  c.userAddr,
+		ChunkID:   chunkID,
+		TotalSize: len(envelope),
+		Encrypted: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal session request: %w", err)
+	}
+
+	var session api.SessionResponse
+	if err := c.do(ctx, http.MethodPost, "/sessions", body, "application/json", &session); err != nil {
+		return err
+	}
+	sessionPath := "/sessions/" + session.SessionID
+
+	err = func() error {
+		for part := 0; part < session.PartCount; part++ {
+			start := part * session.PartSize
+			end := min(start+session.PartSize, len(envelope))
+			path := fmt.Sprintf("%s/parts/%d", sessionPath, part)
+			if err := c.do(ctx, http.MethodPut, path, envelope[start:end], "application/octet-stream", nil); err != nil {
+				return fmt.Errorf("part %d: %w", part, err)
+			}
+		}
+		return c.do(ctx, http.MethodPost, sessionPath+"/complete", nil, "", out)
+	}()
+	if err != nil {
+		// Best effort; the server also expires abandoned sessions
+		_ = c.do(context.Background(), http.MethodDelete, sessionPath, nil, "", nil)
+		return err
+	}
+	return nil
+}
+
+// Download fetches, decrypts and verifies a chunk
+// Chunks in the manifest are checked against their recorded hash; chunks
+// uploaded from another device are still authenticated by AES-GCM
+func (c *Client) Download(ctx context.Context, chunkID int) ([]byte, error) {
+	var resp api.DownloadResponse
+	path := fmt.Sprintf("/download/%s/%d", c.userAddr, chunkID)
+	if err := c.do(ctx, http.MethodGet, path, nil, "", &resp); err != nil {
+		return nil, err
+	}
+
+	data, err := decodeEnvelope(resp.Data, deriveChunkKey(c.key, c.userAddr, chunkID), c.key)
+	if err != nil {
+		return nil, err
+	}
+
+	rec, err := c.manifest.get(c.userAddr, chunkID)
+	if err != nil {
+		return nil, err
+	}
+	if rec != nil && !meshstorage.VerifyDataHash(data, rec.ContentHash) {
+		return nil, fmt.Errorf("%w: chunk %d does not match the manifest hash", ErrIntegrity, chunkID)
+	}
+
+	return data, nil
+}
+
+// decodeEnvelope decodes and decrypts a downloaded envelope
+// Authentication failures are reported as ErrIntegrity
+func decodeEnvelope(encoded string, chunkKey, masterKey *meshstorage.EncryptionKey) ([]byte, error) {
+	envelope, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode chunk: %w", err)
+	}
+
+	data, err := open(envelope, chunkKey, masterKey)
+	if err != nil {
+		if errors.Is(err, ErrNotEnvelope) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", ErrIntegrity, err)
+	}
+	return data, nil
+}
+
+// Delete removes a chunk from the mesh and the manifest
+// A chunk the mesh no longer has is still removed from the manifest
+func (c *Client) Delete(ctx context.Context, chunkID int) error {
+	path := fmt.Sprintf("/delete/%s/%d", c.userAddr, chunkID)
+	if err := c.do(ctx, http.MethodDelete, path, nil, "", nil); err != nil && !IsNotFound(err) {
+		return err
+	}
+	if err := c.manifest.removeShare(c.userAddr, chunkID, ""); err != nil {
+		return err
+	}
+	return c.manifest.remove(c.userAddr, chunkID)
+}
+
+// Owned lists the chunks this client has uploaded, from the local manifest
+func (c *Client) Owned() ([]ChunkRecord, error) {
+	return c.manifest.list(c.userAddr)
+}
+
+// Record returns the manifest entry for a chunk, or nil if it is not recorded
+func (c *Client) Record(chunkID int) (*ChunkRecord, error) {
+	return c.manifest.get(c.userAddr, chunkID)
+}
+
+// do sends a request, retrying network errors, 429 and 5xx responses with exponential backoff
+// On success the JSON response is decoded into out (if non-nil)
+func (c *Client) do(ctx context.Context, method, path string, body []byte, contentType string, out any) error {
+	return c.doWithHeaders(ctx, method, path, body, contentType, nil, out)
+}
+
+// doWithHeaders is do with extra request headers
+func (c *Client) doWithHeaders(ctx context.Context, method, path string, body []byte, contentType string, headers map[string]string, out any) error {
+	wait := c.backoff
+
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := c.attempt(ctx, method, path, body, contentType, headers, out)
+		if err == nil {
+			return nil
+		}
+		if attempt >= c.maxRetries || !retryable(ctx, err) {
+			return err
+		}
+
+		delay := max(wait, retryAfter)
+		if delay > maxRetryWait {
+			delay = maxRetryWait
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		wait *= 2
+	}
+}
+
+// attempt sends a request once, returning the server's Retry-After hint on failure
+func (c *Client) attempt(ctx context.Context, method, path string, body []byte, contentType string, headers map[string]string, out any) (time.Duration, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		if json.Unmarshal(respBody, &apiErr.Response) != nil || apiErr.Response.Error == "" {
+			apiErr.Response.Error = http.StatusText(resp.StatusCode)
+		}
+
+		var retryAfter time.Duration
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+			retryAfter = time.Duration(secs) * time.Second
+		}
+		return retryAfter, apiErr
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return 0, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return 0, nil
+}
+
+// retryable reports whether a failed request should be tried again
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests ||
+			(apiErr.StatusCode >= 500 && apiErr.StatusCode != http.StatusNotImplemented)
+	}
+
+	// Transport errors (connection refused, reset, timeouts)
+	return true
+}
@@ -0,0 +1,346 @@
+package meshclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/meshstorage"
+	"github.com/ZentaChain/zentalk-node/pkg/meshstorage/api"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testUserAddr = "0x1234567890abcdef1234567890abcdef12345678"
+
+// fakeMesh is a minimal in-memory stand-in for the mesh storage API
+type fakeMesh struct {
+	mu       sync.Mutex
+	chunks   map[string][]byte // "user/chunk" -> stored bytes
+	failNext int               // Respond 503 to this many upcoming requests
+	sessions map[string]*fakeSession
+	grants   map[string]*meshstorage.AccessGrant // "owner/chunk/recipient" -> grant
+	requests int
+}
+
+type fakeSession struct {
+	req   api.CreateSessionRequest
+	parts map[int][]byte
+}
+
+func newFakeMesh() *fakeMesh {
+	return &fakeMesh{
+		chunks:   make(map[string][]byte),
+		sessions: make(map[string]*fakeSession),
+		grants:   make(map[string]*meshstorage.AccessGrant),
+	}
+}
+
+func (f *fakeMesh) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests++
+	if f.failNext > 0 {
+		f.failNext--
+		writeJSON(w, http.StatusServiceUnavailable, api.ErrorResponse{Error: "Unavailable"})
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1/storage")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	switch {
+	case r.Method == http.MethodPost && path == "/upload":
+		var req api.UploadRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		data, _ := base64.StdEncoding.DecodeString(req.Data)
+		f.chunks[fmt.Sprintf("%s/%d", req.UserAddr, req.ChunkID)] = data
+		writeJSON(w, http.StatusOK, api.UploadResponse{Success: true, ChunkID: req.ChunkID, ShardCount: 15})
+
+	case r.Method == http.MethodGet && parts[0] == "download":
+		data, ok := f.chunks[parts[1]+"/"+parts[2]]
+		if !ok {
+			writeJSON(w, http.StatusNotFound, api.ErrorResponse{Error: "Data not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, api.DownloadResponse{Success: true, Data: base64.StdEncoding.EncodeToString(data)})
+
+	case r.Method == http.MethodDelete && parts[0] == "delete":
+		key := parts[1] + "/" + parts[2]
+		if _, ok := f.chunks[key]; !ok {
+			writeJSON(w, http.StatusNotFound, api.ErrorResponse{Error: "Data not found"})
+			return
+		}
+		delete(f.chunks, key)
+		writeJSON(w, http.StatusOK, api.SuccessResponse{Success: true})
+
+	case r.Method == http.MethodPost && path == "/sessions":
+		var req api.CreateSessionRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		id := fmt.Sprintf("s%d", len(f.sessions))
+		f.sessions[id] = &fakeSession{req: req, parts: make(map[int][]byte)}
+		writeJSON(w, http.StatusCreated, api.SessionResponse{
+			Success:   true,
+			SessionID: id,
+			PartSize:  api.SessionPartSize,
+			PartCount: (req.TotalSize + api.SessionPartSize - 1) / api.SessionPartSize,
+		})
+
+	case r.Method == http.MethodPut && parts[0] == "sessions":
+		var part int
+		fmt.Sscanf(parts[3], "%d", &part)
+		data, _ := io.ReadAll(r.Body)
+		f.sessions[parts[1]].parts[part] = data
+		writeJSON(w, http.StatusOK, api.SessionResponse{Success: true})
+
+	case r.Method == http.MethodPost && parts[0] == "sessions":
+		session := f.sessions[parts[1]]
+		var data []byte
+		for i := 0; i < len(session.parts); i++ {
+			data = append(data, session.parts[i]...)
+		}
+		f.chunks[fmt.Sprintf("%s/%d", session.req.UserAddr, session.req.ChunkID)] = data
+		writeJSON(w, http.StatusOK, api.UploadResponse{Success: true, ChunkID: session.req.ChunkID, ShardCount: 15})
+
+	case r.Method == http.MethodPost && path == "/grants":
+		var grant meshstorage.AccessGrant
+		json.NewDecoder(r.Body).Decode(&grant)
+		if err := grant.Verify(); err != nil {
+			writeJSON(w, http.StatusUnauthorized, api.ErrorResponse{Error: "Invalid grant", Message: err.Error()})
+			return
+		}
+		f.grants[fmt.Sprintf("%s/%d/%s", grant.OwnerAddr, grant.ChunkID, grant.RecipientAddr)] = &grant
+		writeJSON(w, http.StatusCreated, api.SuccessResponse{Success: true})
+
+	case r.Method == http.MethodGet && parts[0] == "grants":
+		resp := api.GrantsResponse{Success: true, RecipientAddr: parts[1]}
+		for _, grant := range f.grants {
+			if grant.RecipientAddr == parts[1] {
+				resp.Grants = append(resp.Grants, grant)
+			}
+		}
+		writeJSON(w, http.StatusOK, resp)
+
+	case r.Method == http.MethodDelete && parts[0] == "grants":
+		delete(f.grants, strings.Join(parts[1:], "/"))
+		writeJSON(w, http.StatusOK, api.SuccessResponse{Success: true})
+
+	default:
+		writeJSON(w, http.StatusNotFound, api.ErrorResponse{Error: "Not found"})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func newTestClient(t *testing.T, baseURL string) *Client {
+	return newTestClientFor(t, baseURL, testUserAddr, nil)
+}
+
+func newTestClientFor(t *testing.T, baseURL, userAddr string, identity *rsa.PrivateKey) *Client {
+	var key meshstorage.EncryptionKey
+	copy(key[:], userAddr)
+
+	client, err := New(Config{
+		BaseURL:      baseURL,
+		UserAddr:     userAddr,
+		Key:          &key,
+		ManifestPath: filepath.Join(t.TempDir(), "manifest.db"),
+		Identity:     identity,
+		RetryBackoff: time.Millisecond,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// TestClientRoundTrip tests upload, manifest, download and delete
+func TestClientRoundTrip(t *testing.T) {
+	mesh := newFakeMesh()
+	server := httptest.NewServer(mesh)
+	defer server.Close()
+
+	client := newTestClient(t, server.URL)
+	ctx := context.Background()
+	data := []byte("Hello from the meshclient SDK")
+
+	rec, err := client.Upload(ctx, 7, data)
+	require.NoError(t, err)
+	assert.Equal(t, len(data), rec.Size)
+	assert.Equal(t, 15, rec.ShardCount)
+	assert.Equal(t, meshstorage.HashData(data), rec.ContentHash)
+
+	// The mesh only ever sees ciphertext
+	stored := mesh.chunks[fmt.Sprintf("%s/%d", testUserAddr, 7)]
+	assert.False(t, bytes.Contains(stored, data))
+
+	owned, err := client.Owned()
+	require.NoError(t, err)
+	require.Len(t, owned, 1)
+	assert.Equal(t, 7, owned[0].ChunkID)
+
+	downloaded, err := client.Download(ctx, 7)
+	require.NoError(t, err)
+	assert.Equal(t, data, downloaded)
+
+	require.NoError(t, client.Delete(ctx, 7))
+	owned, err = client.Owned()
+	require.NoError(t, err)
+	assert.Empty(t, owned)
+
+	_, err = client.Download(ctx, 7)
+	assert.True(t, IsNotFound(err))
+}
+
+// TestClientRetry tests that transient server errors are retried
+func TestClientRetry(t *testing.T) {
+	mesh := newFakeMesh()
+	server := httptest.NewServer(mesh)
+	defer server.Close()
+
+	client := newTestClient(t, server.URL)
+
+	mesh.failNext = 2
+	_, err := client.Upload(context.Background(), 1, []byte("retry me"))
+	require.NoError(t, err)
+	assert.Equal(t, 3, mesh.requests)
+
+	// Out of retries: the API error surfaces
+	mesh.failNext = DefaultMaxRetries + 1
+	_, err = client.Download(context.Background(), 1)
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+}
+
+// TestClientIntegrity tests that tampered or replaced data is rejected
+func TestClientIntegrity(t *testing.T) {
+	mesh := newFakeMesh()
+	server := httptest.NewServer(mesh)
+	defer server.Close()
+
+	client := newTestClient(t, server.URL)
+	ctx := context.Background()
+	key := fmt.Sprintf("%s/%d", testUserAddr, 1)
+
+	_, err := client.Upload(ctx, 1, []byte("original"))
+	require.NoError(t, err)
+
+	// Flipped ciphertext bit fails authentication
+	tampered := append([]byte(nil), mesh.chunks[key]...)
+	tampered[len(tampered)-1] ^= 0xFF
+	mesh.chunks[key] = tampered
+	_, err = client.Download(ctx, 1)
+	assert.ErrorIs(t, err, ErrIntegrity)
+
+	// A validly encrypted but different chunk fails the manifest hash
+	replacement, err := seal([]byte("replaced"), deriveChunkKey(client.key, testUserAddr, 1))
+	require.NoError(t, err)
+	mesh.chunks[key] = replacement
+	_, err = client.Download(ctx, 1)
+	assert.ErrorIs(t, err, ErrIntegrity)
+}
+
+// TestClientSessionUpload tests that large payloads go through an upload session
+func TestClientSessionUpload(t *testing.T) {
+	mesh := newFakeMesh()
+	server := httptest.NewServer(mesh)
+	defer server.Close()
+
+	client := newTestClient(t, server.URL)
+	ctx := context.Background()
+
+	data := bytes.Repeat([]byte("0123456789"), api.SessionPartSize/5) // ~8 MB
+	_, err := client.Upload(ctx, 2, data)
+	require.NoError(t, err)
+	assert.Len(t, mesh.sessions, 1)
+
+	downloaded, err := client.Download(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, data, downloaded)
+}
+
+// TestClientShare tests sharing a chunk with another user and revoking the grant
+func TestClientShare(t *testing.T) {
+	mesh := newFakeMesh()
+	server := httptest.NewServer(mesh)
+	defer server.Close()
+
+	aliceKey, err := crypto.GenerateRSAKeyPair()
+	require.NoError(t, err)
+	bobKey, err := crypto.GenerateRSAKeyPair()
+	require.NoError(t, err)
+	eveKey, err := crypto.GenerateRSAKeyPair()
+	require.NoError(t, err)
+
+	const bobAddr = "0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	alice := newTestClientFor(t, server.URL, testUserAddr, aliceKey)
+	aliceWallet, err := ethcrypto.GenerateKey()
+	require.NoError(t, err)
+	bob := newTestClientFor(t, server.URL, bobAddr, bobKey)
+	eve := newTestClientFor(t, server.URL, bobAddr, eveKey) // Claims Bob's address without his key
+	ctx := context.Background()
+
+	album := []byte("shared album photo")
+	_, err = alice.Upload(ctx, 5, album)
+	require.NoError(t, err)
+	_, err = alice.Upload(ctx, 6, []byte("private"))
+	require.NoError(t, err)
+
+	// Grants need the wallet to vouch for the identity key
+	_, err = alice.Share(ctx, 5, bobAddr, &bobKey.PublicKey, time.Hour)
+	assert.ErrorIs(t, err, ErrNoWallet)
+	alice.wallet = aliceWallet
+
+	_, err = alice.Share(ctx, 5, bobAddr, &bobKey.PublicKey, time.Hour)
+	require.NoError(t, err)
+
+	shares, err := alice.Shares(5)
+	require.NoError(t, err)
+	require.Len(t, shares, 1)
+	assert.Equal(t, bobAddr, shares[0].RecipientAddr)
+
+	grants, err := bob.ReceivedGrants(ctx)
+	require.NoError(t, err)
+	require.Len(t, grants, 1)
+
+	data, err := bob.DownloadShared(ctx, grants[0])
+	require.NoError(t, err)
+	assert.Equal(t, album, data)
+
+	// The wrapped key only opens with the recipient's identity
+	_, err = eve.DownloadShared(ctx, grants[0])
+	assert.Error(t, err)
+
+	// The chunk key opens only the shared chunk
+	other := *grants[0]
+	other.ChunkID = 6
+	_, err = bob.DownloadShared(ctx, &other)
+	assert.Error(t, err, "tampered grant must fail signature verification")
+
+	require.NoError(t, alice.Revoke(ctx, 5, bobAddr))
+	grants, err = bob.ReceivedGrants(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, grants)
+	shares, err = alice.Shares(5)
+	require.NoError(t, err)
+	assert.Empty(t, shares)
+}
@@ -0,0 +1,74 @@
+package meshclient
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ZentaChain/zentalk-node/pkg/meshstorage"
+)
+
+// Envelope magics prefix client-encrypted chunks
+// Envelopes are binary rather than JSON so the API server, which tries to
+// decrypt JSON-encoded EncryptedData on download, returns them untouched
+var (
+	envelopeMagicV1 = []byte("ZMC1") // Encrypted with the master key
+	envelopeMagicV2 = []byte("ZMC2") // Encrypted with a per-chunk key, which can be shared
+)
+
+// ErrNotEnvelope is returned when downloaded data was not written by this SDK
+var ErrNotEnvelope = errors.New("data is not a meshclient envelope")
+
+// deriveChunkKey derives the key for one chunk from the master key
+// Sharing a chunk reveals only its own key, never the master key
+func deriveChunkKey(master *meshstorage.EncryptionKey, userAddr string, chunkID int) *meshstorage.EncryptionKey {
+	mac := hmac.New(sha256.New, master[:])
+	fmt.Fprintf(mac, "zentalk-chunk-key|%s|%d", strings.ToLower(userAddr), chunkID)
+
+	var key meshstorage.EncryptionKey
+	copy(key[:], mac.Sum(nil))
+	return &key
+}
+
+// seal encrypts plaintext with a chunk key into an envelope: magic | nonce | ciphertext
+func seal(plaintext []byte, chunkKey *meshstorage.EncryptionKey) ([]byte, error) {
+	encrypted, err := meshstorage.Encrypt(plaintext, chunkKey)
+	if err != nil {
+		return nil, err
+	}
+
+	envelope := make([]byte, 0, len(envelopeMagicV2)+len(encrypted.Nonce)+len(encrypted.Ciphertext))
+	envelope = append(envelope, envelopeMagicV2...)
+	envelope = append(envelope, encrypted.Nonce...)
+	envelope = append(envelope, encrypted.Ciphertext...)
+	return envelope, nil
+}
+
+// open decrypts an envelope
+// masterKey opens v1 envelopes and may be nil when only the chunk key is known
+func open(envelope []byte, chunkKey, masterKey *meshstorage.EncryptionKey) ([]byte, error) {
+	var key *meshstorage.EncryptionKey
+	switch {
+	case bytes.HasPrefix(envelope, envelopeMagicV2):
+		key = chunkKey
+	case bytes.HasPrefix(envelope, envelopeMagicV1) && masterKey != nil:
+		key = masterKey
+	case bytes.HasPrefix(envelope, envelopeMagicV1):
+		return nil, fmt.Errorf("chunk predates per-chunk keys and cannot be shared")
+	default:
+		return nil, ErrNotEnvelope
+	}
+
+	body := envelope[len(envelopeMagicV2):]
+	if len(body) < meshstorage.NonceSize {
+		return nil, fmt.Errorf("envelope too short")
+	}
+
+	return meshstorage.Decrypt(&meshstorage.EncryptedData{
+		Nonce:      body[:meshstorage.NonceSize],
+		Ciphertext: body[meshstorage.NonceSize:],
+	}, key)
+}
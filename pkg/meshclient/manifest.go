@@ -0,0 +1,217 @@
+package meshclient
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/sqldb"
+)
+
+// ChunkRecord is a manifest entry for a chunk this client uploaded
+type ChunkRecord struct {
+	UserAddr    string
+	ChunkID     int
+	Size        int    // Plaintext size in bytes
+	StoredSize  int    // Size of the encrypted envelope sent to the mesh
+	ContentHash string // SHA-256 of the plaintext (hex)
+	ShardCount  int
+	UploadedAt  time.Time
+}
+
+// manifestSchema is the local record of owned chunks
+const manifestSchema = `
+CREATE TABLE IF NOT EXISTS owned_chunks (
+	user_addr TEXT NOT NULL,
+	chunk_id INTEGER NOT NULL,
+	size INTEGER NOT NULL,
+	stored_size INTEGER NOT NULL,
+	content_hash TEXT NOT NULL,
+	shard_count INTEGER NOT NULL,
+	uploaded_at INTEGER NOT NULL,
+	PRIMARY KEY (user_addr, chunk_id)
+);
+CREATE TABLE IF NOT EXISTS shares (
+	user_addr TEXT NOT NULL,
+	chunk_id INTEGER NOT NULL,
+	recipient_addr TEXT NOT NULL,
+	issued_at INTEGER NOT NULL,
+	expires_at INTEGER NOT NULL,
+	PRIMARY KEY (user_addr, chunk_id, recipient_addr)
+);
+`
+
+// manifest is the SQLite cache of chunks owned by the local user
+// It lets applications list their data without a network round trip and
+// holds the content hash used to verify downloads
+type manifest struct {
+	db *sql.DB
+}
+
+// openManifest opens (or creates) the manifest database at path
+func openManifest(path string) (*manifest, error) {
+	db, dialect, err := sqldb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if dialect != sqldb.SQLite {
+		db.Close()
+		return nil, fmt.Errorf("manifest must be a local SQLite database")
+	}
+
+	// A single connection keeps :memory: manifests consistent and serializes writers
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(manifestSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create manifest schema: %w", err)
+	}
+
+	return &manifest{db: db}, nil
+}
+
+// put records or replaces a chunk
+func (m *manifest) put(rec *ChunkRecord) error {
+	_, err := m.db.Exec(`
+		INSERT OR REPLACE INTO owned_chunks
+			(user_addr, chunk_id, size, stored_size, content_hash, shard_count, uploaded_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		rec.UserAddr, rec.ChunkID, rec.Size, rec.StoredSize, rec.ContentHash, rec.ShardCount, rec.UploadedAt.Unix())
+	if err != nil {
+		return fmt.Errorf("failed to record chunk: %w", err)
+	}
+	return nil
+}
+
+// get returns the record for a chunk, or nil if the chunk is not in the manifest
+func (m *manifest) get(userAddr string, chunkID int) (*ChunkRecord, error) {
+	row := m.db.QueryRow(`
+		SELECT user_addr, chunk_id, size, stored_size, content_hash, shard_count, uploaded_at
+		FROM owned_chunks WHERE user_addr = ? AND chunk_id = ?`, userAddr, chunkID)
+
+	rec, err := scanRecord(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	return rec, nil
+}
+
+// list returns every chunk owned by a user, ordered by chunk ID
+func (m *manifest) list(userAddr string) ([]ChunkRecord, error) {
+	rows, err := m.db.Query(`
+		SELECT user_addr, chunk_id, size, stored_size, content_hash, shard_count, uploaded_at
+		FROM owned_chunks WHERE user_addr = ? ORDER BY chunk_id`, userAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	defer rows.Close()
+
+	var records []ChunkRecord
+	for rows.Next() {
+		rec, err := scanRecord(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read manifest: %w", err)
+		}
+		records = append(records, *rec)
+	}
+	return records, rows.Err()
+}
+
+// remove deletes a chunk from the manifest
+func (m *manifest) remove(userAddr string, chunkID int) error {
+	if _, err := m.db.Exec(`DELETE FROM owned_chunks WHERE user_addr = ? AND chunk_id = ?`, userAddr, chunkID); err != nil {
+		return fmt.Errorf("failed to remove chunk from manifest: %w", err)
+	}
+	return nil
+}
+
+// Share is a manifest entry for a grant this client issued
+type Share struct {
+	ChunkID       int
+	RecipientAddr string
+	IssuedAt      time.Time
+	ExpiresAt     time.Time // Zero = no expiry
+}
+
+// putShare records an issued grant
+func (m *manifest) putShare(userAddr string, share *Share) error {
+	var expiresAt int64
+	if !share.ExpiresAt.IsZero() {
+		expiresAt = share.ExpiresAt.Unix()
+	}
+
+	_, err := m.db.Exec(`
+		INSERT OR REPLACE INTO shares (user_addr, chunk_id, recipient_addr, issued_at, expires_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		userAddr, share.ChunkID, share.RecipientAddr, share.IssuedAt.Unix(), expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to record share: %w", err)
+	}
+	return nil
+}
+
+// listShares returns the grants issued on a chunk
+func (m *manifest) listShares(userAddr string, chunkID int) ([]Share, error) {
+	rows, err := m.db.Query(`
+		SELECT chunk_id, recipient_addr, issued_at, expires_at
+		FROM shares WHERE user_addr = ? AND chunk_id = ? ORDER BY issued_at`, userAddr, chunkID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read shares: %w", err)
+	}
+	defer rows.Close()
+
+	var shares []Share
+	for rows.Next() {
+		var share Share
+		var issuedAt, expiresAt int64
+		if err := rows.Scan(&share.ChunkID, &share.RecipientAddr, &issuedAt, &expiresAt); err != nil {
+			return nil, fmt.Errorf("failed to read shares: %w", err)
+		}
+		share.IssuedAt = time.Unix(issuedAt, 0)
+		if expiresAt > 0 {
+			share.ExpiresAt = time.Unix(expiresAt, 0)
+		}
+		shares = append(shares, share)
+	}
+	return shares, rows.Err()
+}
+
+// removeShare deletes one issued grant; recipientAddr "" removes all grants on the chunk
+func (m *manifest) removeShare(userAddr string, chunkID int, recipientAddr string) error {
+	var err error
+	if recipientAddr == "" {
+		_, err = m.db.Exec(`DELETE FROM shares WHERE user_addr = ? AND chunk_id = ?`, userAddr, chunkID)
+	} else {
+		_, err = m.db.Exec(`DELETE FROM shares WHERE user_addr = ? AND chunk_id = ? AND recipient_addr = ?`,
+			userAddr, chunkID, recipientAddr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to remove share: %w", err)
+	}
+	return nil
+}
+
+// close closes the manifest database
+func (m *manifest) close() error {
+	return m.db.Close()
+}
+
+// rowScanner is satisfied by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanRecord reads one manifest row
+func scanRecord(row rowScanner) (*ChunkRecord, error) {
+	var rec ChunkRecord
+	var uploadedAt int64
+	if err := row.Scan(&rec.UserAddr, &rec.ChunkID, &rec.Size, &rec.StoredSize,
+		&rec.ContentHash, &rec.ShardCount, &uploadedAt); err != nil {
+		return nil, err
+	}
+	rec.UploadedAt = time.Unix(uploadedAt, 0)
+	return &rec, nil
+}
@@ -0,0 +1,76 @@
+package meshclient
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/meshstorage"
+	"github.com/ZentaChain/zentalk-node/pkg/meshstorage/api"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// PinNodes requires nodes (e.g. the user's own) to hold minShards of each of this user's chunks
+// The signed pin set replaces any earlier one on the mesh API node, which honors
+// it when placing and repairing the user's chunks. The node only accepts the
+// user's first pin set if the client has the user's wallet key.
+func (c *Client) PinNodes(ctx context.Context, nodes []peer.ID, minShards int) (*meshstorage.PinSet, error) {
+	if c.identity == nil {
+		return nil, ErrNoIdentity
+	}
+
+	pins := &meshstorage.PinSet{
+		UserAddr:  c.userAddr,
+		MinShards: minShards,
+		IssuedAt:  time.Now().Unix(),
+	}
+	for _, id := range nodes {
+		pins.Nodes = append(pins.Nodes, id.String())
+	}
+	if _, err := pins.PeerIDs(); err != nil {
+		return nil, err
+	}
+	if err := pins.Sign(c.identity); err != nil {
+		return nil, err
+	}
+	if c.wallet != nil {
+		if err := pins.BindWallet(c.wallet); err != nil {
+			return nil, err
+		}
+	}
+
+	body, err := json.Marshal(pins)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal pin set: %w", err)
+	}
+	if err := c.do(ctx, http.MethodPut, "/pins", body, "application/json", nil); err != nil {
+		return nil, err
+	}
+	return pins, nil
+}
+
+// Unpin lets this user's chunks be placed on any node again
+func (c *Client) Unpin(ctx context.Context) error {
+	if c.identity == nil {
+		return ErrNoIdentity
+	}
+
+	timestamp := time.Now().UTC().Format(time.RFC3339)
+	signature, err := crypto.SignData(api.UnpinMessage(c.userAddr, timestamp), c.identity)
+	if err != nil {
+		return fmt.Errorf("failed to sign unpin request: %w", err)
+	}
+
+	err = c.doWithHeaders(ctx, http.MethodDelete, "/pins/"+c.userAddr, nil, "", map[string]string{
+		"X-Timestamp": timestamp,
+		"X-Signature": base64.StdEncoding.EncodeToString(signature),
+	}, nil)
+	if err != nil && !IsNotFound(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package meshclient
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/meshstorage"
+	"github.com/ZentaChain/zentalk-node/pkg/meshstorage/api"
+)
+
+// ErrNoIdentity is returned by sharing operations when the client has no identity key
+var ErrNoIdentity = errors.New("client has no identity key")
+
+// ErrNoWallet is returned by Share when the client has no wallet key to vouch for its identity
+var ErrNoWallet = errors.New("client has no wallet key")
+
+// Share grants recipientAddr access to one of this user's chunks
+// The chunk key is wrapped for the recipient's identity key and the signed grant
+// is published to the mesh API; the chunk itself is not re-uploaded.
+// ttl of 0 issues a grant that does not expire. The client's wallet key vouches
+// for its identity key, so Share needs both.
+func (c *Client) Share(ctx context.Context, chunkID int, recipientAddr string, recipientKey *rsa.PublicKey, ttl time.Duration) (*meshstorage.AccessGrant, error) {
+	if c.identity == nil {
+		return nil, ErrNoIdentity
+	}
+	if c.wallet == nil {
+		return nil, ErrNoWallet
+	}
+
+	chunkKey := deriveChunkKey(c.key, c.userAddr, chunkID)
+	wrapped, err := crypto.RSAEncrypt(chunkKey[:], recipientKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap chunk key: %w", err)
+	}
+
+	grant := &meshstorage.AccessGrant{
+		OwnerAddr:     c.userAddr,
+		ChunkID:       chunkID,
+		RecipientAddr: recipientAddr,
+		WrappedKey:    wrapped,
+		IssuedAt:      time.Now().Unix(),
+	}
+	if ttl > 0 {
+		grant.ExpiresAt = time.Now().Add(ttl).Unix()
+	}
+
+	// Let the recipient verify content too, when this client uploaded the chunk
+	rec, err := c.manifest.get(c.userAddr, chunkID)
+	if err != nil {
+		return nil, err
+	}
+	if rec != nil {
+		grant.ContentHash = rec.ContentHash
+	}
+
+	if err := grant.Sign(c.identity); err != nil {
+		return nil, err
+	}
+	if err := grant.BindWallet(c.wallet); err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(grant)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal grant: %w", err)
+	}
+	if err := c.do(ctx, http.MethodPost, "/grants", body, "application/json", nil); err != nil {
+		return nil, err
+	}
+
+	share := &Share{ChunkID: chunkID, RecipientAddr: recipientAddr, IssuedAt: time.Unix(grant.IssuedAt, 0)}
+	if grant.ExpiresAt > 0 {
+		share.ExpiresAt = time.Unix(grant.ExpiresAt, 0)
+	}
+	if err := c.manifest.putShare(c.userAddr, share); err != nil {
+		return nil, err
+	}
+
+	return grant, nil
+}
+
+// Revoke withdraws a recipient's grant on a chunk
+// A recipient who already downloaded the chunk keeps that copy; revocation stops
+// further access through the mesh API
+func (c *Client) Revoke(ctx context.Context, chunkID int, recipientAddr string) error {
+	if c.identity == nil {
+		return ErrNoIdentity
+	}
+
+	timestamp := time.Now().UTC().Format(time.RFC3339)
+	signature, err := crypto.SignData(api.RevokeMessage(c.userAddr, chunkID, recipientAddr, timestamp), c.identity)
+	if err != nil {
+		return fmt.Errorf("failed to sign revocation: %w", err)
+	}
+
+	path := fmt.Sprintf("/grants/%s/%d/%s", c.userAddr, chunkID, recipientAddr)
+	err = c.doWithHeaders(ctx, http.MethodDelete, path, nil, "", map[string]string{
+		"X-Timestamp": timestamp,
+		"X-Signature": base64.StdEncoding.EncodeToString(signature),
+	}, nil)
+	if err != nil && !IsNotFound(err) {
+		return err
+	}
+
+	return c.manifest.removeShare(c.userAddr, chunkID, recipientAddr)
+}
+
+// Shares lists the grants this client issued on a chunk, from the local manifest
+func (c *Client) Shares(chunkID int) ([]Share, error) {
+	return c.manifest.listShares(c.userAddr, chunkID)
+}
+
+// ReceivedGrants returns grants issued to this user
+// Grants with an invalid signature or that expired are dropped
+func (c *Client) ReceivedGrants(ctx context.Context) ([]*meshstorage.AccessGrant, error) {
+	var resp api.GrantsResponse
+	if err := c.do(ctx, http.MethodGet, "/grants/"+c.userAddr, nil, "", &resp); err != nil {
+		return nil, err
+	}
+
+	grants := make([]*meshstorage.AccessGrant, 0, len(resp.Grants))
+	for _, grant := range resp.Grants {
+		if !strings.EqualFold(grant.RecipientAddr, c.userAddr) || grant.Verify() != nil {
+			continue
+		}
+		grants = append(grants, grant)
+	}
+	return grants, nil
+}
+
+// DownloadShared fetches and decrypts a chunk shared with this user
+// The grant's signature is checked before its key is used, and the content is
+// verified against the hash the owner signed into the grant
+func (c *Client) DownloadShared(ctx context.Context, grant *meshstorage.AccessGrant) ([]byte, error) {
+	if c.identity == nil {
+		return nil, ErrNoIdentity
+	}
+	if !strings.EqualFold(grant.RecipientAddr, c.userAddr) {
+		return nil, fmt.Errorf("grant is for %s, not %s", grant.RecipientAddr, c.userAddr)
+	}
+	if err := grant.Verify(); err != nil {
+		return nil, err
+	}
+
+	keyBytes, err := crypto.RSADecrypt(grant.WrappedKey, c.identity)
+	if err != nil || len(keyBytes) != meshstorage.EncryptionKeySize {
+		return nil, fmt.Errorf("failed to unwrap chunk key: grant was not issued for this identity")
+	}
+	var chunkKey meshstorage.EncryptionKey
+	copy(chunkKey[:], keyBytes)
+
+	var resp api.DownloadResponse
+	path := fmt.Sprintf("/download/%s/%d", grant.OwnerAddr, grant.ChunkID)
+	if err := c.do(ctx, http.MethodGet, path, nil, "", &resp); err != nil {
+		return nil, err
+	}
+
+	data, err := decodeEnvelope(resp.Data, &chunkKey, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if grant.ContentHash != "" && !meshstorage.VerifyDataHash(data, grant.ContentHash) {
+		return nil, fmt.Errorf("%w: shared chunk %d does not match the granted hash", ErrIntegrity, grant.ChunkID)
+	}
+	return data, nil
+}
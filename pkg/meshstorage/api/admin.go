@@ -0,0 +1,499 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/chaos"
+	"github.com/ZentaChain/zentalk-node/pkg/logging"
+	"github.com/ZentaChain/zentalk-node/pkg/meshstorage"
+	"github.com/gin-gonic/gin"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// AdminStatusResponse describes a node's operational state
+type AdminStatusResponse struct {
+	Success          bool     `json:"success"`
+	NodeID           string   `json:"nodeId"`
+	Draining         bool     `json:"draining"`     // Uploads paused by an operator
+	ShuttingDown     bool     `json:"shuttingDown"` // Server shutdown in progress
+	UploadsInFlight  int64    `json:"uploadsInFlight"`
+	TrackedChunks    int      `json:"trackedChunks"`
+	ConnectedPeers   int      `json:"connectedPeers"`
+	BlockedPeers     int      `json:"blockedPeers"`
+	MaintenancePeers []string `json:"maintenancePeers"`
+}
+
+// RepairStatusResponse reports the state of operator-triggered repair
+type RepairStatusResponse struct {
+	Success    bool                      `json:"success"`
+	Running    bool                      `json:"running"`
+	StartedAt  time.Time                 `json:"startedAt,omitempty"`
+	FinishedAt time.Time                 `json:"finishedAt,omitempty"`
+	Report     *meshstorage.HealthReport `json:"report,omitempty"` // Last completed pass
+}
+
+// BlockPeerRequest bans a peer from this node
+type BlockPeerRequest struct {
+	PeerID string `json:"peerId" binding:"required"`
+	Reason string `json:"reason"`
+}
+
+// BlocklistResponse lists banned peers
+type BlocklistResponse struct {
+	Success bool                      `json:"success"`
+	Count   int                       `json:"count"`
+	Peers   []meshstorage.BlockedPeer `json:"peers"`
+}
+
+// PeerIntegrityInfo reports one peer's checksummed shard transfers
+type PeerIntegrityInfo struct {
+	PeerID string `json:"peerId"`
+	meshstorage.PeerIntegrity
+	CorruptionRate float64 `json:"corruptionRate"`
+	Score          float64 `json:"score"`
+	Excluded       bool    `json:"excluded"` // Score below meshstorage.MinPlacementScore; given no new shards
+}
+
+// IntegrityResponse lists the peers shards were exchanged with, lowest score first
+type IntegrityResponse struct {
+	Success bool                `json:"success"`
+	Count   int                 `json:"count"`
+	Peers   []PeerIntegrityInfo `json:"peers"`
+}
+
+// ChaosRequest replaces the fault injection config (chaos builds only)
+type ChaosRequest struct {
+	Spec string `json:"spec"` // chaos.ParseConfig syntax; empty turns faults off
+}
+
+// ChaosResponse describes fault injection on this node
+type ChaosResponse struct {
+	Success bool              `json:"success"`
+	Spec    string            `json:"spec"`
+	Stats   map[string]uint64 `json:"stats"` // Injections per "<point>:<fault>"
+}
+
+// UsageEpochsResponse lists the epochs a node has metered usage for
+type UsageEpochsResponse struct {
+	Success bool    `json:"success"`
+	Epochs  []int64 `json:"epochs"` // Epoch starts (Unix seconds), newest first
+}
+
+// LogsResponse holds a node's latest log lines
+type LogsResponse struct {
+	Success bool            `json:"success"`
+	Count   int             `json:"count"`
+	Entries []logging.Entry `json:"entries"` // Oldest first
+}
+
+// repairRun tracks the single repair pass an operator may have running
+type repairRun struct {
+	mu         sync.Mutex
+	running    bool
+	startedAt  time.Time
+	finishedAt time.Time
+	report     *meshstorage.HealthReport
+}
+
+// status returns a snapshot of the repair state
+func (r *repairRun) status() RepairStatusResponse {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return RepairStatusResponse{
+		Success:    true,
+		Running:    r.running,
+		StartedAt:  r.startedAt,
+		FinishedAt: r.finishedAt,
+		Report:     r.report,
+	}
+}
+
+// setupAdminRoutes registers operator endpoints behind the admin token
+func (s *Server) setupAdminRoutes(token string) {
+	admin := s.router.Group("/api/v1/admin", AdminAuthMiddleware(token))
+	{
+		admin.GET("/status", s.handleAdminStatus)
+		admin.POST("/drain", s.handleAdminDrain)
+		admin.POST("/resume", s.handleAdminResume)
+		if !s.node.IsBootstrapOnly() {
+			admin.POST("/repair", s.handleAdminRepair)
+			admin.GET("/repair", s.handleAdminRepairStatus)
+			admin.GET("/usage", s.handleAdminUsageEpochs)
+			admin.GET("/usage/:epoch", s.handleAdminUsageReport)
+			admin.GET("/integrity", s.handleAdminIntegrity)
+		}
+		admin.GET("/logs", s.handleAdminLogs)
+		admin.GET("/blocklist", s.handleAdminBlocklist)
+		admin.POST("/blocklist", s.handleAdminBlockPeer)
+		admin.DELETE("/blocklist/:peerID", s.handleAdminUnblockPeer)
+
+		// Only binaries built with -tags chaos can inject faults
+		if chaos.Enabled {
+			admin.GET("/chaos", s.handleAdminChaos)
+			admin.PUT("/chaos", s.handleAdminSetChaos)
+		}
+	}
+}
+
+// handleAdminStatus handles GET /api/v1/admin/status
+func (s *Server) handleAdminStatus(c *gin.Context) {
+	s.drainMu.Lock()
+	paused, shuttingDown := s.paused, s.draining
+	s.drainMu.Unlock()
+
+	s.metadataMu.RLock()
+	tracked := len(s.chunkMetadata)
+	s.metadataMu.RUnlock()
+
+	maintenance := []string{}
+	for id := range s.node.MaintenancePeers() {
+		maintenance = append(maintenance, id.String())
+	}
+
+	c.JSON(http.StatusOK, AdminStatusResponse{
+		Success:          true,
+		NodeID:           s.node.ID().String(),
+		Draining:         paused,
+		ShuttingDown:     shuttingDown,
+		UploadsInFlight:  s.inFlight.Load(),
+		TrackedChunks:    tracked,
+		ConnectedPeers:   len(s.node.Host().Network().Peers()),
+		BlockedPeers:     len(s.node.BlockedPeers()),
+		MaintenancePeers: maintenance,
+	})
+}
+
+// handleAdminDrain handles POST /api/v1/admin/drain
+// New uploads are refused so a load balancer can move traffic away; running uploads finish
+// and downloads keep working. Unlike shutdown, the node stays up and can be resumed.
+func (s *Server) handleAdminDrain(c *gin.Context) {
+	s.drainMu.Lock()
+	s.paused = true
+	s.drainMu.Unlock()
+
+	fmt.Printf("🚧 Uploads paused by operator (%d in flight)\n", s.inFlight.Load())
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: fmt.Sprintf("Node draining; %d uploads still in flight", s.inFlight.Load()),
+	})
+}
+
+// handleAdminResume handles POST /api/v1/admin/resume
+func (s *Server) handleAdminResume(c *gin.Context) {
+	s.drainMu.Lock()
+	s.paused = false
+	s.drainMu.Unlock()
+
+	fmt.Printf("▶️  Uploads resumed by operator\n")
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Node accepting uploads",
+	})
+}
+
+// handleAdminRepair handles POST /api/v1/admin/repair
+// Starts a health check and repair pass over every chunk this node tracks.
+// A pass can outlast the request timeout, so it runs in the background; poll GET /admin/repair.
+func (s *Server) handleAdminRepair(c *gin.Context) {
+	s.repair.mu.Lock()
+	if s.repair.running {
+		s.repair.mu.Unlock()
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "Repair already running",
+			Message: "Wait for the current pass to finish",
+		})
+		return
+	}
+	s.repair.running = true
+	s.repair.startedAt = time.Now()
+	s.repair.mu.Unlock()
+
+	s.metadataMu.RLock()
+	chunks := make([]*meshstorage.DistributedChunk, 0, len(s.chunkMetadata))
+	for _, chunk := range s.chunkMetadata {
+		chunks = append(chunks, chunk)
+	}
+	s.metadataMu.RUnlock()
+
+	fmt.Printf("🔧 Repair triggered by operator for %d chunks\n", len(chunks))
+
+	go func() {
+		report := s.distributedStore.CheckChunks(chunks)
+
+		s.repair.mu.Lock()
+		s.repair.running = false
+		s.repair.finishedAt = time.Now()
+		s.repair.report = report
+		s.repair.mu.Unlock()
+	}()
+
+	c.JSON(http.StatusAccepted, SuccessResponse{
+		Success: true,
+		Message: fmt.Sprintf("Repair started for %d chunks", len(chunks)),
+	})
+}
+
+// handleAdminRepairStatus handles GET /api/v1/admin/repair
+func (s *Server) handleAdminRepairStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, s.repair.status())
+}
+
+// handleAdminUsageEpochs handles GET /api/v1/admin/usage
+func (s *Server) handleAdminUsageEpochs(c *gin.Context) {
+	meter := s.node.UsageMeter()
+	if meter == nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Metering disabled",
+			Message: "This node does not meter usage",
+		})
+		return
+	}
+
+	epochs, err := meter.Epochs()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to list usage epochs",
+			Message: err.Error(),
+		})
+		return
+	}
+	if epochs == nil {
+		epochs = []int64{}
+	}
+
+	c.JSON(http.StatusOK, UsageEpochsResponse{
+		Success: true,
+		Epochs:  epochs,
+	})
+}
+
+// handleAdminUsageReport handles GET /api/v1/admin/usage/:epoch
+// The report is JSON unless ?format=csv is given.
+func (s *Server) handleAdminUsageReport(c *gin.Context) {
+	meter := s.node.UsageMeter()
+	if meter == nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Metering disabled",
+			Message: "This node does not meter usage",
+		})
+		return
+	}
+
+	epoch, err := strconv.ParseInt(c.Param("epoch"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid epoch",
+			Message: "Epoch must be its start in Unix seconds",
+		})
+		return
+	}
+
+	report, err := meter.Report(epoch)
+	if errors.Is(err, meshstorage.ErrNoUsage) {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "No usage",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to build usage report",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		c.JSON(http.StatusOK, report)
+	case "csv":
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"usage-%d.csv\"", epoch))
+		c.Header("Content-Type", "text/csv")
+		c.Status(http.StatusOK)
+		if err := report.WriteCSV(c.Writer); err != nil {
+			fmt.Printf("⚠️  Failed to write usage CSV: %v\n", err)
+		}
+	default:
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid format",
+			Message: "Format must be json or csv",
+		})
+	}
+}
+
+// handleAdminLogs handles GET /api/v1/admin/logs?limit=N
+// Returns the latest N (default 100) lines the node logged, after repeat suppression.
+func (s *Server) handleAdminLogs(c *gin.Context) {
+	if s.logs == nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Logs unavailable",
+			Message: "This node does not keep recent log lines",
+		})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid limit",
+			Message: "Limit must be a positive number of lines",
+		})
+		return
+	}
+
+	entries := s.logs.Recent(limit)
+	c.JSON(http.StatusOK, LogsResponse{
+		Success: true,
+		Count:   len(entries),
+		Entries: entries,
+	})
+}
+
+// handleAdminBlocklist handles GET /api/v1/admin/blocklist
+func (s *Server) handleAdminBlocklist(c *gin.Context) {
+	peers := s.node.BlockedPeers()
+	c.JSON(http.StatusOK, BlocklistResponse{
+		Success: true,
+		Count:   len(peers),
+		Peers:   peers,
+	})
+}
+
+// handleAdminIntegrity handles GET /api/v1/admin/integrity
+func (s *Server) handleAdminIntegrity(c *gin.Context) {
+	peers := []PeerIntegrityInfo{}
+	for id, stats := range s.node.IntegrityStats() {
+		peers = append(peers, PeerIntegrityInfo{
+			PeerID:         id.String(),
+			PeerIntegrity:  stats,
+			CorruptionRate: stats.CorruptionRate(),
+			Score:          stats.Score(),
+			Excluded:       stats.Score() < meshstorage.MinPlacementScore,
+		})
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		if peers[i].Score != peers[j].Score {
+			return peers[i].Score < peers[j].Score
+		}
+		return peers[i].PeerID < peers[j].PeerID
+	})
+
+	c.JSON(http.StatusOK, IntegrityResponse{
+		Success: true,
+		Count:   len(peers),
+		Peers:   peers,
+	})
+}
+
+// handleAdminBlockPeer handles POST /api/v1/admin/blocklist
+func (s *Server) handleAdminBlockPeer(c *gin.Context) {
+	var req BlockPeerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	peerID, err := peer.Decode(req.PeerID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid peer ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := s.node.BlockPeer(peerID, req.Reason); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Failed to block peer",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: fmt.Sprintf("Peer %s blocked", peerID),
+	})
+}
+
+// handleAdminUnblockPeer handles DELETE /api/v1/admin/blocklist/:peerID
+func (s *Server) handleAdminUnblockPeer(c *gin.Context) {
+	peerID, err := peer.Decode(c.Param("peerID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid peer ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	removed, err := s.node.UnblockPeer(peerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to unblock peer",
+			Message: err.Error(),
+		})
+		return
+	}
+	if !removed {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Peer not blocked",
+			Message: fmt.Sprintf("Peer %s is not on the blocklist", peerID),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: fmt.Sprintf("Peer %s unblocked", peerID),
+	})
+}
+
+// handleAdminChaos handles GET /api/v1/admin/chaos
+func (s *Server) handleAdminChaos(c *gin.Context) {
+	c.JSON(http.StatusOK, ChaosResponse{
+		Success: true,
+		Spec:    chaos.Current().String(),
+		Stats:   chaos.Stats(),
+	})
+}
+
+// handleAdminSetChaos handles PUT /api/v1/admin/chaos
+func (s *Server) handleAdminSetChaos(c *gin.Context) {
+	var req ChaosRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	cfg, err := chaos.ParseConfig(req.Spec)
+	if err == nil {
+		err = chaos.Configure(cfg)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid chaos config",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ChaosResponse{
+		Success: true,
+		Spec:    chaos.Current().String(),
+		Stats:   chaos.Stats(),
+	})
+}
@@ -3,13 +3,22 @@ package api
 import (
 	"bytes"
 	"context"
+	"crypto/rsa"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
-
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/features"
+	"github.com/ZentaChain/zentalk-node/pkg/logging"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	libp2pcrypto "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/stretchr/testify/assert"
 	"github.com/ZentaChain/zentalk-node/pkg/meshstorage"
 )
@@ -166,6 +175,8 @@ func TestAPINetworkEndpoints(t *testing.T) {
 
 		assert.True(t, response.Success)
 		assert.NotEmpty(t, response.NodeID)
+		assert.Contains(t, response.Features, features.Flag{Name: features.StreamedUploads, Version: 1, Enabled: true})
+		assert.Contains(t, response.Features, features.Flag{Name: features.AdminAPI, Version: 1, Enabled: false})
 	})
 }
 
@@ -256,6 +267,178 @@ func TestAPIValidation(t *testing.T) {
 	})
 }
 
+// TestAPIQuotaExceeded tests that uploads over the user's storage quota are refused with 413
+func TestAPIQuotaExceeded(t *testing.T) {
+	ctx := context.Background()
+	config := &meshstorage.NodeConfig{
+		Port:    9112,
+		DataDir: t.TempDir(),
+		Quota:   meshstorage.QuotaConfig{UserMaxChunks: 15}, // One chunk's shards
+	}
+	node, err := meshstorage.NewDHTNode(ctx, config)
+	assert.NoError(t, err)
+	defer node.Close()
+
+	server, err := NewServer(node, DefaultConfig())
+	assert.NoError(t, err)
+
+	upload := func(chunkID int) *httptest.ResponseRecorder {
+		reqBody, _ := json.Marshal(UploadRequest{
+			UserAddr: "0x1234567890abcdef1234567890abcdef12345678",
+			ChunkID:  chunkID,
+			Data:     base64Encode([]byte("quota test data")),
+		})
+		req := httptest.NewRequest("POST", "/api/v1/storage/upload", bytes.NewReader(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = "192.0.2.12:1234" // Keep out of the other tests' rate limit budget
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, upload(1).Code)
+
+	w := upload(2)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	var response ErrorResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "QUOTA_EXCEEDED", response.Code)
+
+	// The refused upload left nothing behind to count against the user
+	count, err := node.Storage().GetChunkCount()
+	assert.NoError(t, err)
+	assert.Equal(t, 15, count)
+}
+
+// TestAPIPinOwnership tests that a pin set can't be set for an address by a key its wallet didn't bind
+func TestAPIPinOwnership(t *testing.T) {
+	ctx := context.Background()
+	node, err := meshstorage.NewDHTNode(ctx, &meshstorage.NodeConfig{Port: 9113, DataDir: t.TempDir()})
+	assert.NoError(t, err)
+	defer node.Close()
+
+	server, err := NewServer(node, DefaultConfig())
+	assert.NoError(t, err)
+
+	walletKey, err := ethcrypto.GenerateKey()
+	assert.NoError(t, err)
+	userAddr := ethcrypto.PubkeyToAddress(walletKey.PublicKey).Hex()
+	issuedAt := time.Now().Unix()
+
+	pinSet := func(minShards int) *meshstorage.PinSet {
+		issuedAt++
+		return &meshstorage.PinSet{
+			UserAddr:  userAddr,
+			Nodes:     []string{node.ID().String()},
+			MinShards: minShards,
+			IssuedAt:  issuedAt,
+		}
+	}
+	put := func(pins *meshstorage.PinSet) int {
+		reqBody, _ := json.Marshal(pins)
+		req := httptest.NewRequest("PUT", "/api/v1/storage/pins", bytes.NewReader(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = "192.0.2.13:1234" // Keep out of the other tests' rate limit budget
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	ownerKey, err := crypto.GenerateRSAKeyPair()
+	assert.NoError(t, err)
+	attackerKey, err := crypto.GenerateRSAKeyPair()
+	assert.NoError(t, err)
+
+	// A first pin set without the address's wallet binding is refused
+	claim := pinSet(meshstorage.TotalShards)
+	assert.NoError(t, claim.Sign(attackerKey))
+	assert.Equal(t, http.StatusForbidden, put(claim))
+
+	// More shards than this node splits chunks into are refused
+	tooMany := pinSet(meshstorage.MaxTotalShards)
+	assert.NoError(t, tooMany.Sign(ownerKey))
+	assert.NoError(t, tooMany.BindWallet(walletKey))
+	assert.Equal(t, http.StatusBadRequest, put(tooMany))
+
+	pins := pinSet(3)
+	assert.NoError(t, pins.Sign(ownerKey))
+	assert.NoError(t, pins.BindWallet(walletKey))
+	assert.Equal(t, http.StatusOK, put(pins))
+
+	// A second key can't take over the address, even with the user's binding attached
+	claim = pinSet(meshstorage.TotalShards)
+	assert.NoError(t, claim.Sign(attackerKey))
+	claim.WalletSignature = pins.WalletSignature
+	assert.Equal(t, http.StatusForbidden, put(claim))
+
+	stored, err := node.Storage().GetPinSet(userAddr)
+	assert.NoError(t, err)
+	assert.Equal(t, pins.OwnerPublicKey, stored.OwnerPublicKey)
+
+	// The owner key updates its pin set without the wallet
+	update := pinSet(4)
+	assert.NoError(t, update.Sign(ownerKey))
+	assert.Equal(t, http.StatusOK, put(update))
+}
+
+// TestAPIGrantOwnership tests that grants signed by a stranger's key are refused
+func TestAPIGrantOwnership(t *testing.T) {
+	ctx := context.Background()
+	node, err := meshstorage.NewDHTNode(ctx, &meshstorage.NodeConfig{Port: 9114, DataDir: t.TempDir()})
+	assert.NoError(t, err)
+	defer node.Close()
+
+	server, err := NewServer(node, DefaultConfig())
+	assert.NoError(t, err)
+
+	walletKey, err := ethcrypto.GenerateKey()
+	assert.NoError(t, err)
+	ownerAddr := ethcrypto.PubkeyToAddress(walletKey.PublicKey).Hex()
+	const recipientAddr = "0x2222222222222222222222222222222222222222"
+
+	post := func(grant *meshstorage.AccessGrant) int {
+		reqBody, _ := json.Marshal(grant)
+		req := httptest.NewRequest("POST", "/api/v1/storage/grants", bytes.NewReader(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = "192.0.2.14:1234" // Keep out of the other tests' rate limit budget
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w.Code
+	}
+	newGrant := func() *meshstorage.AccessGrant {
+		return &meshstorage.AccessGrant{
+			OwnerAddr:     ownerAddr,
+			ChunkID:       1,
+			RecipientAddr: recipientAddr,
+			WrappedKey:    []byte("wrapped-key"),
+			IssuedAt:      time.Now().Unix(),
+		}
+	}
+
+	ownerKey, err := crypto.GenerateRSAKeyPair()
+	assert.NoError(t, err)
+	strangerKey, err := crypto.GenerateRSAKeyPair()
+	assert.NoError(t, err)
+
+	// A stranger registering first can't claim the owner's chunks
+	forged := newGrant()
+	assert.NoError(t, forged.Sign(strangerKey))
+	assert.Equal(t, http.StatusForbidden, post(forged))
+
+	grant := newGrant()
+	assert.NoError(t, grant.Sign(ownerKey))
+	assert.NoError(t, grant.BindWallet(walletKey))
+	assert.Equal(t, http.StatusCreated, post(grant))
+
+	// Nor replace the owner's grant with the owner's binding attached
+	forged.WalletSignature = grant.WalletSignature
+	assert.Equal(t, http.StatusForbidden, post(forged))
+
+	stored, err := node.Storage().GetGrant(ownerAddr, 1, recipientAddr)
+	assert.NoError(t, err)
+	assert.Equal(t, grant.OwnerPublicKey, stored.OwnerPublicKey)
+}
+
 // TestAPIConcurrency tests concurrent uploads
 func TestAPIConcurrency(t *testing.T) {
 	ctx := context.Background()
@@ -308,6 +491,651 @@ func TestAPIConcurrency(t *testing.T) {
 
 // Helper functions
 
+// TestAPIShutdownRejectsUploads tests that uploads are refused once the server drains
+func TestAPIShutdownRejectsUploads(t *testing.T) {
+	ctx := context.Background()
+	config := &meshstorage.NodeConfig{
+		Port:    9105,
+		DataDir: t.TempDir(),
+	}
+	node, err := meshstorage.NewDHTNode(ctx, config)
+	assert.NoError(t, err)
+	defer node.Close()
+
+	server, err := NewServer(node, DefaultConfig())
+	assert.NoError(t, err)
+
+	// Never started, so shutdown only drains (nothing in flight)
+	assert.NoError(t, server.Shutdown())
+
+	uploadReq := UploadRequest{
+		UserAddr: "0x1234567890abcdef1234567890abcdef12345678",
+		ChunkID:  1,
+		Data:     base64Encode([]byte("too late")),
+	}
+	reqBody, _ := json.Marshal(uploadReq)
+	req := httptest.NewRequest("POST", "/api/v1/storage/upload", bytes.NewReader(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	server.router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+}
+
+// TestAPIUploadSession tests the multi-part upload session flow
+func TestAPIUploadSession(t *testing.T) {
+	ctx := context.Background()
+	config := &meshstorage.NodeConfig{
+		Port:    9106,
+		DataDir: t.TempDir(),
+	}
+	node, err := meshstorage.NewDHTNode(ctx, config)
+	assert.NoError(t, err)
+	defer node.Close()
+
+	server, err := NewServer(node, DefaultConfig())
+	assert.NoError(t, err)
+
+	do := func(method, url string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Two parts: one full, one partial
+	payload := bytes.Repeat([]byte("z"), SessionPartSize+100)
+	createBody, _ := json.Marshal(CreateSessionRequest{
+		UserAddr:  "0x1234567890abcdef1234567890abcdef12345678",
+		ChunkID:   7,
+		TotalSize: len(payload),
+	})
+
+	w := do("POST", "/api/v1/storage/sessions", createBody)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var session SessionResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &session))
+	assert.Equal(t, 2, session.PartCount)
+
+	base := "/api/v1/storage/sessions/" + session.SessionID
+
+	// Completing with parts missing is refused
+	w = do("POST", base+"/complete", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// A wrongly sized part is rejected
+	w = do("PUT", base+"/parts/0", payload[:10])
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = do("PUT", base+"/parts/0", payload[:SessionPartSize])
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = do("PUT", base+"/parts/1", payload[SessionPartSize:])
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = do("GET", base, nil)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &session))
+	assert.Equal(t, len(payload), session.ReceivedBytes)
+	assert.Equal(t, SessionReceiving, session.State)
+
+	w = do("POST", base+"/complete", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = do("GET", base, nil)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &session))
+	assert.Equal(t, SessionCompleted, session.State)
+	assert.Equal(t, 1.0, session.Progress)
+	assert.Equal(t, 15, session.ShardsStored)
+
+	// Completed sessions cannot be cancelled; unfinished ones can
+	w = do("DELETE", base, nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = do("POST", "/api/v1/storage/sessions", createBody)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &session))
+	w = do("DELETE", "/api/v1/storage/sessions/"+session.SessionID, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = do("PUT", "/api/v1/storage/sessions/"+session.SessionID+"/parts/0", payload[:SessionPartSize])
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+// TestAPIUploadStream tests resumable streamed uploads split into several chunks
+func TestAPIUploadStream(t *testing.T) {
+	ctx := context.Background()
+	config := &meshstorage.NodeConfig{
+		Port:    9111,
+		DataDir: t.TempDir(),
+	}
+	node, err := meshstorage.NewDHTNode(ctx, config)
+	assert.NoError(t, err)
+	defer node.Close()
+
+	server, err := NewServer(node, DefaultConfig())
+	assert.NoError(t, err)
+	server.segmentSize = 1024
+
+	do := func(method, url string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	userAddr := "0x1234567890abcdef1234567890abcdef12345678"
+	payload := make([]byte, 2500)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	createBody, _ := json.Marshal(CreateSessionRequest{
+		UserAddr:  userAddr,
+		ChunkID:   20,
+		TotalSize: len(payload),
+	})
+
+	w := do("POST", "/api/v1/storage/upload/stream", createBody)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var stream StreamResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &stream))
+	assert.Equal(t, 3, stream.SegmentCount)
+
+	base := "/api/v1/storage/upload/stream/" + stream.SessionID
+
+	// First write stops mid-segment, as if the connection dropped
+	w = do("PATCH", base+"?offset=0", payload[:1500])
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &stream))
+	assert.Equal(t, 1500, stream.Offset)
+	assert.Len(t, stream.Segments, 1)
+
+	// Writing from the wrong offset reports where to resume
+	w = do("PATCH", base+"?offset=0", payload)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &stream))
+	assert.Equal(t, 1500, stream.Offset)
+
+	w = do("PATCH", base+"?offset=1500", payload[1500:])
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &stream))
+	assert.Equal(t, SessionCompleted, stream.State)
+	assert.Equal(t, 1.0, stream.Progress)
+	if assert.Len(t, stream.Segments, 3) {
+		assert.Equal(t, 22, stream.Segments[2].ChunkID)
+		assert.Equal(t, 452, stream.Segments[2].Size)
+	}
+
+	// Each segment downloads as an ordinary chunk
+	var downloaded []byte
+	for _, segment := range stream.Segments {
+		w = do("GET", fmt.Sprintf("/api/v1/storage/download/%s/%d", userAddr, segment.ChunkID), nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response DownloadResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		downloaded = append(downloaded, base64Decode(response.Data)...)
+	}
+	assert.Equal(t, payload, downloaded)
+
+	// Cancelling an unfinished stream removes its stored segments
+	w = do("POST", "/api/v1/storage/upload/stream", createBody)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &stream))
+	base = "/api/v1/storage/upload/stream/" + stream.SessionID
+	w = do("PATCH", base+"?offset=0", payload[:1024])
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = do("DELETE", base, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, exists := server.getChunkMetadata(userAddr, 20)
+	assert.False(t, exists)
+	w = do("PATCH", base+"?offset=1024", payload[1024:])
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+// TestAPIPublicContent tests hash-addressed public objects and access tokens
+func TestAPIPublicContent(t *testing.T) {
+	ctx := context.Background()
+	config := &meshstorage.NodeConfig{
+		Port:    9107,
+		DataDir: t.TempDir(),
+	}
+	node, err := meshstorage.NewDHTNode(ctx, config)
+	assert.NoError(t, err)
+	defer node.Close()
+
+	server, err := NewServer(node, DefaultConfig())
+	assert.NoError(t, err)
+
+	do := func(method, url string, body []byte, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Open content: anyone can read it by hash
+	avatar := []byte("group avatar bytes")
+	body, _ := json.Marshal(PublicUploadRequest{Data: base64Encode(avatar), ContentType: "image/png"})
+	w := do("POST", "/api/v1/public", body, nil)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var uploaded PublicUploadResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &uploaded))
+	assert.Equal(t, meshstorage.HashData(avatar), uploaded.Hash)
+
+	w = do("GET", uploaded.URL, nil, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, avatar, w.Body.Bytes())
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	assert.Contains(t, w.Header().Get("Content-Security-Policy"), "sandbox")
+	assert.Empty(t, w.Header().Get("Content-Disposition"), "images are shown inline")
+
+	// Content that a browser could run is only ever downloaded
+	page := []byte("<script>alert(document.cookie)</script>")
+	body, _ = json.Marshal(PublicUploadRequest{Data: base64Encode(page), ContentType: "text/html"})
+	var html PublicUploadResponse
+	assert.NoError(t, json.Unmarshal(do("POST", "/api/v1/public", body, nil).Body.Bytes(), &html))
+	w = do("GET", html.URL, nil, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "attachment", w.Header().Get("Content-Disposition"))
+	assert.Equal(t, "default-src 'none'; sandbox", w.Header().Get("Content-Security-Policy"))
+	body, _ = json.Marshal(PublicUploadRequest{Data: base64Encode(avatar), ContentType: "image/png"})
+
+	// Re-uploading the same bytes is deduplicated
+	w = do("POST", "/api/v1/public", body, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &uploaded))
+	assert.True(t, uploaded.Existing)
+
+	// Token-gated content
+	ownerKey, err := crypto.GenerateRSAKeyPair()
+	assert.NoError(t, err)
+	ownerPEM, err := crypto.ExportPublicKeyPEM(&ownerKey.PublicKey)
+	assert.NoError(t, err)
+
+	media := []byte("channel media for subscribers")
+	body, _ = json.Marshal(PublicUploadRequest{Data: base64Encode(media), OwnerPublicKey: string(ownerPEM), RequireToken: true})
+	w = do("POST", "/api/v1/public", body, nil)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &uploaded))
+
+	w = do("GET", uploaded.URL, nil, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	expired, err := meshstorage.IssuePublicAccessToken(uploaded.Hash, time.Now().Add(-time.Minute), ownerKey)
+	assert.NoError(t, err)
+	w = do("GET", uploaded.URL+"?token="+expired, nil, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	token, err := meshstorage.IssuePublicAccessToken(uploaded.Hash, time.Now().Add(time.Hour), ownerKey)
+	assert.NoError(t, err)
+	w = do("GET", uploaded.URL, nil, map[string]string{"X-Access-Token": token})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, media, w.Body.Bytes())
+
+	// Only the owner can delete
+	timestamp := time.Now().UTC().Format(time.RFC3339)
+	signature, err := crypto.SignData(PublicDeleteMessage(uploaded.Hash, timestamp), ownerKey)
+	assert.NoError(t, err)
+	w = do("DELETE", uploaded.URL, nil, map[string]string{"X-Timestamp": timestamp, "X-Signature": "bm90IGEgc2lnbmF0dXJl"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = do("DELETE", uploaded.URL, nil, map[string]string{"X-Timestamp": timestamp, "X-Signature": base64Encode(signature)})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = do("GET", uploaded.URL+"?token="+token, nil, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// TestAPISharedLink tests signed, expiring download links
+func TestAPISharedLink(t *testing.T) {
+	ctx := context.Background()
+	config := &meshstorage.NodeConfig{
+		Port:    9108,
+		DataDir: t.TempDir(),
+	}
+	node, err := meshstorage.NewDHTNode(ctx, config)
+	assert.NoError(t, err)
+	defer node.Close()
+
+	apiConfig := DefaultConfig()
+	apiConfig.LinkSecret = "shared-across-nodes"
+	server, err := NewServer(node, apiConfig)
+	assert.NoError(t, err)
+
+	do := func(method, url string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	walletKey, err := ethcrypto.GenerateKey()
+	assert.NoError(t, err)
+	userAddr := ethcrypto.PubkeyToAddress(walletKey.PublicKey).Hex()
+	fileData := []byte("holiday-photo.jpg contents")
+
+	ownerKey, err := crypto.GenerateRSAKeyPair()
+	assert.NoError(t, err)
+	ownerPEM, err := crypto.ExportPublicKeyPEM(&ownerKey.PublicKey)
+	assert.NoError(t, err)
+	binding, err := meshstorage.SignWalletBinding("link", userAddr, string(ownerPEM), walletKey)
+	assert.NoError(t, err)
+
+	// createLink signs a link request with signer for the owner key in the body
+	createLink := func(linkReq CreateLinkRequest, signer *rsa.PrivateKey) *httptest.ResponseRecorder {
+		linkReq.UserAddr = userAddr
+		if linkReq.OwnerPublicKey == "" {
+			linkReq.OwnerPublicKey = string(ownerPEM)
+			linkReq.WalletSignature = binding
+		}
+		body, _ := json.Marshal(linkReq)
+		req := httptest.NewRequest("POST", "/api/v1/storage/links", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if signer != nil {
+			timestamp := time.Now().UTC().Format(time.RFC3339)
+			signature, err := crypto.SignData(LinkMessage(userAddr, linkReq.ChunkID, timestamp), signer)
+			assert.NoError(t, err)
+			req.Header.Set("X-Timestamp", timestamp)
+			req.Header.Set("X-Signature", base64Encode(signature))
+		}
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Default upload: encrypted with the wallet-derived key
+	body, _ := json.Marshal(UploadRequest{UserAddr: userAddr, ChunkID: 3, Data: base64Encode(fileData)})
+	assert.Equal(t, http.StatusOK, do("POST", "/api/v1/storage/upload", body).Code)
+
+	// Only the owner may create links
+	assert.Equal(t, http.StatusUnauthorized, createLink(CreateLinkRequest{ChunkID: 3}, nil).Code)
+	strangerKey, err := crypto.GenerateRSAKeyPair()
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, createLink(CreateLinkRequest{ChunkID: 3}, strangerKey).Code)
+	strangerPEM, err := crypto.ExportPublicKeyPEM(&strangerKey.PublicKey)
+	assert.NoError(t, err)
+	unbound := CreateLinkRequest{ChunkID: 3, OwnerPublicKey: string(strangerPEM), WalletSignature: binding}
+	assert.Equal(t, http.StatusForbidden, createLink(unbound, strangerKey).Code)
+
+	w := createLink(CreateLinkRequest{ChunkID: 3, ExpiresIn: 600, Filename: "holiday.jpg"}, ownerKey)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var link CreateLinkResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &link))
+
+	w = do("GET", "/api/v1/links/"+link.Token, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, fileData, w.Body.Bytes())
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "holiday.jpg")
+
+	// Pointing a token at another chunk breaks the MAC
+	claims, _ := json.Marshal(linkClaims{UserAddr: userAddr, ChunkID: 4, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	forged := base64.RawURLEncoding.EncodeToString(claims) + link.Token[strings.Index(link.Token, "."):]
+	assert.Equal(t, http.StatusForbidden, do("GET", "/api/v1/links/"+forged, nil).Code)
+
+	// Another node with the same secret accepts the link; a different secret does not
+	peer, _ := newLinkSigner("shared-across-nodes")
+	_, err = peer.verify(link.Token)
+	assert.NoError(t, err)
+	stranger, _ := newLinkSigner("other-secret")
+	_, err = stranger.verify(link.Token)
+	assert.ErrorIs(t, err, errLinkInvalid)
+
+	expired, err := server.links.sign(linkClaims{UserAddr: userAddr, ChunkID: 3, ExpiresAt: time.Now().Add(-time.Second).Unix()})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusGone, do("GET", "/api/v1/links/"+expired, nil).Code)
+
+	// Password-protected content cannot be opened without the password
+	body, _ = json.Marshal(UploadRequest{UserAddr: userAddr, ChunkID: 5, Data: base64Encode(fileData), Password: "secret"})
+	assert.Equal(t, http.StatusOK, do("POST", "/api/v1/storage/upload", body).Code)
+	w = createLink(CreateLinkRequest{ChunkID: 5}, ownerKey)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &link))
+	assert.Equal(t, http.StatusForbidden, do("GET", "/api/v1/links/"+link.Token, nil).Code)
+
+	tooLong := CreateLinkRequest{ChunkID: 3, ExpiresIn: int((MaxLinkTTL + time.Hour).Seconds())}
+	assert.Equal(t, http.StatusBadRequest, createLink(tooLong, ownerKey).Code)
+}
+
+// TestAPIRenewLease tests that only the owner can renew a chunk's lease
+func TestAPIRenewLease(t *testing.T) {
+	ctx := context.Background()
+	node, err := meshstorage.NewDHTNode(ctx, &meshstorage.NodeConfig{Port: 9115, DataDir: t.TempDir()})
+	assert.NoError(t, err)
+	defer node.Close()
+
+	server, err := NewServer(node, DefaultConfig())
+	assert.NoError(t, err)
+
+	walletKey, err := ethcrypto.GenerateKey()
+	assert.NoError(t, err)
+	userAddr := ethcrypto.PubkeyToAddress(walletKey.PublicKey).Hex()
+
+	ownerKey, err := crypto.GenerateRSAKeyPair()
+	assert.NoError(t, err)
+	ownerPEM, err := crypto.ExportPublicKeyPEM(&ownerKey.PublicKey)
+	assert.NoError(t, err)
+	binding, err := meshstorage.SignWalletBinding("lease", userAddr, string(ownerPEM), walletKey)
+	assert.NoError(t, err)
+
+	// renew signs a renewal of chunkID for signedSeconds and sends leaseReq
+	renew := func(chunkID int, leaseReq RenewLeaseRequest, signer *rsa.PrivateKey, signedSeconds int64) *httptest.ResponseRecorder {
+		if leaseReq.OwnerPublicKey == "" {
+			leaseReq.OwnerPublicKey = string(ownerPEM)
+			leaseReq.WalletSignature = binding
+		}
+		body, _ := json.Marshal(leaseReq)
+		req := httptest.NewRequest("POST", fmt.Sprintf("/api/v1/storage/lease/%s/%d", userAddr, chunkID), bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = "192.0.2.15:1234" // Keep out of the other tests' rate limit budget
+		if signer != nil {
+			timestamp := time.Now().UTC().Format(time.RFC3339)
+			signature, err := crypto.SignData(RenewLeaseMessage(userAddr, chunkID, signedSeconds, timestamp), signer)
+			assert.NoError(t, err)
+			req.Header.Set("X-Timestamp", timestamp)
+			req.Header.Set("X-Signature", base64Encode(signature))
+		}
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	body, _ := json.Marshal(UploadRequest{UserAddr: userAddr, ChunkID: 1, Data: base64Encode([]byte("leased")), LeaseSeconds: 60})
+	req := httptest.NewRequest("POST", "/api/v1/storage/upload", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = "192.0.2.15:1234"
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var uploaded UploadResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &uploaded))
+	assert.NotNil(t, uploaded.LeaseExpires)
+
+	// Anyone else is refused, including with the owner's signature over another lease
+	week := RenewLeaseRequest{LeaseSeconds: 7 * 24 * 3600}
+	assert.Equal(t, http.StatusUnauthorized, renew(1, week, nil, 0).Code)
+	strangerKey, err := crypto.GenerateRSAKeyPair()
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, renew(1, week, strangerKey, week.LeaseSeconds).Code)
+	assert.Equal(t, http.StatusUnauthorized, renew(1, week, ownerKey, 3600).Code)
+	strangerPEM, err := crypto.ExportPublicKeyPEM(&strangerKey.PublicKey)
+	assert.NoError(t, err)
+	unbound := RenewLeaseRequest{LeaseSeconds: week.LeaseSeconds, OwnerPublicKey: string(strangerPEM), WalletSignature: binding}
+	assert.Equal(t, http.StatusForbidden, renew(1, unbound, strangerKey, week.LeaseSeconds).Code)
+
+	w = renew(1, week, ownerKey, week.LeaseSeconds)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var renewed LeaseResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &renewed))
+	assert.True(t, renewed.LeaseExpires.After(*uploaded.LeaseExpires))
+}
+
+// TestAPIAdmin tests the token-protected operator endpoints
+func TestAPIAdmin(t *testing.T) {
+	ctx := context.Background()
+	config := &meshstorage.NodeConfig{
+		Port:    9109,
+		DataDir: t.TempDir(),
+	}
+	node, err := meshstorage.NewDHTNode(ctx, config)
+	assert.NoError(t, err)
+	defer node.Close()
+
+	logs, err := logging.New(logging.Config{RecentLines: 10})
+	assert.NoError(t, err)
+	defer logs.Close()
+
+	apiConfig := DefaultConfig()
+	apiConfig.AdminToken = "operator-token"
+	apiConfig.Logs = logs
+	server, err := NewServer(node, apiConfig)
+	assert.NoError(t, err)
+
+	do := func(method, url, token string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, do("GET", "/api/v1/admin/status", "", nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, do("GET", "/api/v1/admin/status", "wrong", nil).Code)
+	assert.Equal(t, http.StatusOK, do("GET", "/api/v1/admin/status", "operator-token", nil).Code)
+
+	// Drain refuses uploads until resumed
+	userAddr := "0x1234567890abcdef1234567890abcdef12345678"
+	upload, _ := json.Marshal(UploadRequest{UserAddr: userAddr, ChunkID: 1, Data: base64Encode([]byte("admin test"))})
+	assert.Equal(t, http.StatusOK, do("POST", "/api/v1/admin/drain", "operator-token", nil).Code)
+	w := do("POST", "/api/v1/storage/upload", "", upload)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "DRAINING")
+
+	var status AdminStatusResponse
+	assert.NoError(t, json.Unmarshal(do("GET", "/api/v1/admin/status", "operator-token", nil).Body.Bytes(), &status))
+	assert.True(t, status.Draining)
+	assert.False(t, status.ShuttingDown)
+
+	assert.Equal(t, http.StatusOK, do("POST", "/api/v1/admin/resume", "operator-token", nil).Code)
+	assert.Equal(t, http.StatusOK, do("POST", "/api/v1/storage/upload", "", upload).Code)
+
+	// Repair runs in the background and reports when done
+	assert.Equal(t, http.StatusAccepted, do("POST", "/api/v1/admin/repair", "operator-token", nil).Code)
+	var repair RepairStatusResponse
+	assert.Eventually(t, func() bool {
+		w := do("GET", "/api/v1/admin/repair", "operator-token", nil)
+		return json.Unmarshal(w.Body.Bytes(), &repair) == nil && !repair.Running
+	}, 30*time.Second, 100*time.Millisecond)
+	if assert.NotNil(t, repair.Report) {
+		assert.Equal(t, 1, repair.Report.Checked)
+	}
+
+	// Blocklist
+	priv, _, err := libp2pcrypto.GenerateEd25519Key(nil)
+	assert.NoError(t, err)
+	banned, err := peer.IDFromPrivateKey(priv)
+	assert.NoError(t, err)
+
+	body, _ := json.Marshal(BlockPeerRequest{PeerID: banned.String(), Reason: "serving corrupt shards"})
+	assert.Equal(t, http.StatusOK, do("POST", "/api/v1/admin/blocklist", "operator-token", body).Code)
+	assert.True(t, node.PeerBlocked(banned))
+
+	body, _ = json.Marshal(BlockPeerRequest{PeerID: "not-a-peer"})
+	assert.Equal(t, http.StatusBadRequest, do("POST", "/api/v1/admin/blocklist", "operator-token", body).Code)
+
+	body, _ = json.Marshal(BlockPeerRequest{PeerID: node.ID().String()})
+	assert.Equal(t, http.StatusBadRequest, do("POST", "/api/v1/admin/blocklist", "operator-token", body).Code)
+
+	var list BlocklistResponse
+	assert.NoError(t, json.Unmarshal(do("GET", "/api/v1/admin/blocklist", "operator-token", nil).Body.Bytes(), &list))
+	if assert.Equal(t, 1, list.Count) {
+		assert.Equal(t, banned, list.Peers[0].ID)
+		assert.Equal(t, "serving corrupt shards", list.Peers[0].Reason)
+	}
+
+	assert.Equal(t, http.StatusOK, do("DELETE", "/api/v1/admin/blocklist/"+banned.String(), "operator-token", nil).Code)
+	assert.False(t, node.PeerBlocked(banned))
+	assert.Equal(t, http.StatusNotFound, do("DELETE", "/api/v1/admin/blocklist/"+banned.String(), "operator-token", nil).Code)
+
+	// Usage reports
+	var epochs UsageEpochsResponse
+	w = do("GET", "/api/v1/admin/usage", "operator-token", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &epochs))
+	assert.NotNil(t, epochs.Epochs)
+	assert.Equal(t, http.StatusBadRequest, do("GET", "/api/v1/admin/usage/yesterday", "operator-token", nil).Code)
+	assert.Equal(t, http.StatusNotFound, do("GET", "/api/v1/admin/usage/0", "operator-token", nil).Code)
+
+	// Recent log lines
+	fmt.Fprintln(logs, "🔧 Repair triggered by operator")
+	fmt.Fprintln(logs, "✅ Repair finished")
+	var recent LogsResponse
+	w = do("GET", "/api/v1/admin/logs?limit=1", "operator-token", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &recent))
+	if assert.Len(t, recent.Entries, 1) {
+		assert.Equal(t, "✅ Repair finished", recent.Entries[0].Line)
+	}
+
+	// Without a token the admin API does not exist
+	plain, err := NewServer(node, DefaultConfig())
+	assert.NoError(t, err)
+	w = httptest.NewRecorder()
+	plain.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/admin/status", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// TestAPIBootstrapOnly tests the routes of a bootstrap-only node
+func TestAPIBootstrapOnly(t *testing.T) {
+	ctx := context.Background()
+	node, err := meshstorage.NewDHTNode(ctx, &meshstorage.NodeConfig{Port: 9110, BootstrapOnly: true})
+	assert.NoError(t, err)
+	defer node.Close()
+
+	server, err := NewServer(node, DefaultConfig())
+	assert.NoError(t, err)
+
+	do := func(method, url string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, nil)
+		req.RemoteAddr = "192.0.2.16:1234" // Keep out of the other tests' rate limit budget
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("GET", "/api/v1/network/seeds")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var seeds SeedListResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &seeds))
+	assert.True(t, seeds.Success)
+	if assert.NotNil(t, seeds.SeedList) {
+		assert.NoError(t, seeds.SeedList.Verify(node.ID()))
+		assert.Equal(t, node.ID().String(), seeds.SeedList.Peers[0].ID)
+	}
+
+	assert.Equal(t, http.StatusBadRequest, do("GET", "/api/v1/network/seeds?limit=0").Code)
+	assert.Equal(t, http.StatusBadRequest, do("GET", "/api/v1/network/seeds?limit=many").Code)
+
+	var info NodeInfoResponse
+	assert.NoError(t, json.Unmarshal(do("GET", "/api/v1/node/info").Body.Bytes(), &info))
+	assert.True(t, info.IsBootstrap)
+	assert.Contains(t, info.Features, features.Flag{Name: features.StreamedUploads, Version: 1, Enabled: false})
+
+	// Health does not depend on storage the node doesn't have
+	var health HealthResponse
+	assert.NoError(t, json.Unmarshal(do("GET", "/health").Body.Bytes(), &health))
+	assert.True(t, health.Checks.StorageWritable)
+
+	// Storage endpoints are not served
+	assert.Equal(t, http.StatusNotFound, do("POST", "/api/v1/storage/upload").Code)
+	assert.Equal(t, http.StatusNotFound, do("GET", "/api/v1/node/stats").Code)
+}
+
 func base64Encode(data []byte) string {
 	return base64.StdEncoding.EncodeToString(data)
 }
@@ -316,3 +1144,20 @@ func base64Decode(encoded string) []byte {
 	decoded, _ := base64.StdEncoding.DecodeString(encoded)
 	return decoded
 }
+
+// TestInlinePublicType tests which public content types are shown inline
+func TestInlinePublicType(t *testing.T) {
+	for contentType, inline := range map[string]bool{
+		"image/png":                true,
+		"image/jpeg; charset=x":    true,
+		"audio/ogg":                true,
+		"video/mp4":                true,
+		"image/svg+xml":            false,
+		"text/html":                false,
+		"application/xhtml+xml":    false,
+		"application/octet-stream": false,
+		"not a type":               false,
+	} {
+		assert.Equal(t, inline, inlinePublicType(contentType), contentType)
+	}
+}
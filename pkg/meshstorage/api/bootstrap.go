@@ -0,0 +1,70 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ZentaChain/zentalk-node/pkg/meshstorage"
+	"github.com/gin-gonic/gin"
+)
+
+// MaxSeedListSize caps the limit a client may request from the seed list endpoint
+const MaxSeedListSize = 200
+
+// SeedListResponse contains a signed list of peers to bootstrap from
+type SeedListResponse struct {
+	Success  bool                  `json:"success"`
+	SeedList *meshstorage.SeedList `json:"seedList"`
+}
+
+// setupBootstrapRoutes configures the routes of a bootstrap-only node
+// There is no storage to serve, so only network and node information is exposed.
+func (s *Server) setupBootstrapRoutes() {
+	v1 := s.router.Group("/api/v1")
+	{
+		network := v1.Group("/network")
+		{
+			network.GET("/info", s.handleNetworkInfo)
+			network.GET("/peers", s.handlePeers)
+			network.GET("/health", s.handleHealth)
+			network.GET("/seeds", s.handleSeedList)
+		}
+
+		v1.GET("/node/info", s.handleNodeInfo)
+	}
+
+	s.router.GET("/health", s.handleHealth)
+}
+
+// handleSeedList handles GET /api/v1/network/seeds
+// Returns this node and known-good peers, signed with the node's libp2p key.
+// Optional ?limit=N bounds the list (default meshstorage.DefaultSeedListSize).
+func (s *Server) handleSeedList(c *gin.Context) {
+	limit := meshstorage.DefaultSeedListSize
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > MaxSeedListSize {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid limit",
+				Message: "Limit must be a number between 1 and " + strconv.Itoa(MaxSeedListSize),
+			})
+			return
+		}
+		limit = n
+	}
+
+	list, err := s.node.SeedList(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to build seed list",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=60")
+	c.JSON(http.StatusOK, SeedListResponse{
+		Success:  true,
+		SeedList: list,
+	})
+}
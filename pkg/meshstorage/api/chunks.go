@@ -0,0 +1,73 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/meshstorage"
+	"github.com/gin-gonic/gin"
+)
+
+// ChunkListResponse lists the chunks of a user this node tracks
+type ChunkListResponse struct {
+	Success  bool           `json:"success"`
+	UserAddr string         `json:"userAddr"`
+	Chunks   []ChunkSummary `json:"chunks"`
+}
+
+// ChunkSummary describes one tracked chunk
+type ChunkSummary struct {
+	ChunkID      int        `json:"chunkID"`
+	SizeBytes    int        `json:"sizeBytes"`
+	Erasure      string     `json:"erasure"`     // Data+parity shards, e.g. "10+5"
+	TotalShards  int        `json:"totalShards"` // Shards placed, including any that failed to store
+	LeaseExpires *time.Time `json:"leaseExpires,omitempty"`
+}
+
+// loadChunkMetadata serves the chunks stored by earlier runs, whose manifests the distributed store reloaded
+func (s *Server) loadChunkMetadata() {
+	chunks := s.distributedStore.ListChunks("")
+	for _, chunk := range chunks {
+		s.storeChunkMetadata(chunk)
+	}
+	if len(chunks) > 0 {
+		fmt.Printf("📋 Serving %d chunks stored before restart\n", len(chunks))
+	}
+}
+
+// handleListChunks handles GET /api/v1/storage/chunks/:userAddr
+func (s *Server) handleListChunks(c *gin.Context) {
+	userAddr := c.Param("userAddr")
+	if !validAddress(userAddr) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid user address",
+			Message: "User address must be a valid Ethereum address (0x...)",
+		})
+		return
+	}
+
+	chunks := s.distributedStore.ListChunks(userAddr)
+	summaries := make([]ChunkSummary, len(chunks))
+	for i, chunk := range chunks {
+		summaries[i] = chunkSummary(chunk)
+	}
+
+	c.JSON(http.StatusOK, ChunkListResponse{
+		Success:  true,
+		UserAddr: userAddr,
+		Chunks:   summaries,
+	})
+}
+
+// chunkSummary describes a chunk for a listing
+func chunkSummary(chunk *meshstorage.DistributedChunk) ChunkSummary {
+	erasure := chunk.Coding()
+	return ChunkSummary{
+		ChunkID:      chunk.ChunkID,
+		SizeBytes:    chunk.OriginalSize,
+		Erasure:      erasure.String(),
+		TotalShards:  erasure.TotalShards(),
+		LeaseExpires: leaseExpiresPtr(chunk),
+	}
+}
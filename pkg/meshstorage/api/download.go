@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -83,7 +84,7 @@ func (s *Server) handleDownload(c *gin.Context) {
 		fmt.Printf("❌ Download failed: %v\n", err)
 
 		// Check if data not found vs other errors
-		if err.Error() == "chunk not found" || err.Error() == "insufficient shards" {
+		if errors.Is(err, meshstorage.ErrChunkNotFound) || errors.Is(err, meshstorage.ErrInsufficientShards) {
 			c.JSON(http.StatusNotFound, ErrorResponse{
 				Error:   "Data not found",
 				Message: fmt.Sprintf("Failed to retrieve data for user %s chunk %d", userAddr, chunkID),
@@ -97,6 +98,7 @@ func (s *Server) handleDownload(c *gin.Context) {
 		})
 		return
 	}
+	s.node.UsageMeter().RecordServed(userAddr, len(encryptedData))
 
 	downloadDuration := time.Since(startTime)
 
@@ -172,8 +174,8 @@ func (s *Server) handleDownload(c *gin.Context) {
 		ChunkID:      chunkID,
 		Data:         encodedData,
 		SizeBytes:    len(decryptedData),
-		ShardsUsed:   10, // Minimum needed for recovery
-		ShardsTotal:  15, // Total distributed
+		ShardsUsed:   chunk.Coding().DataShards,    // Minimum needed for recovery
+		ShardsTotal:  chunk.Coding().TotalShards(), // Total distributed
 		DownloadedAt: time.Now(),
 	}
 
@@ -225,6 +227,7 @@ func (s *Server) handleDownloadBinary(c *gin.Context) {
 		})
 		return
 	}
+	s.node.UsageMeter().RecordServed(userAddr, len(data))
 
 	// Return binary data
 	c.Header("Content-Type", "application/octet-stream")
@@ -0,0 +1,213 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/meshstorage"
+	"github.com/gin-gonic/gin"
+)
+
+// GrantsResponse lists access grants issued to a recipient
+type GrantsResponse struct {
+	Success       bool                       `json:"success"`
+	RecipientAddr string                     `json:"recipientAddr"`
+	Grants        []*meshstorage.AccessGrant `json:"grants"`
+}
+
+// handleCreateGrant handles POST /api/v1/storage/grants
+// The body is an AccessGrant signed by the chunk owner and bound to the owner's
+// address by their wallet; the node verifies and stores it
+func (s *Server) handleCreateGrant(c *gin.Context) {
+	var grant meshstorage.AccessGrant
+	if err := c.ShouldBindJSON(&grant); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if !validAddress(grant.OwnerAddr) || !validAddress(grant.RecipientAddr) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid user address",
+			Message: "Owner and recipient must be valid Ethereum addresses (0x...)",
+		})
+		return
+	}
+
+	if err := grant.Verify(); err != nil {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "Invalid grant",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	// Only the owner's wallet can name the key that issues grants for its chunks
+	if err := grant.VerifyWallet(); err != nil {
+		c.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "Owner key not bound to address",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := s.node.Storage().StoreGrant(&grant); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to store grant",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	fmt.Printf("🤝 Grant stored: owner=%s chunk=%d → recipient=%s\n", grant.OwnerAddr, grant.ChunkID, grant.RecipientAddr)
+
+	c.JSON(http.StatusCreated, SuccessResponse{
+		Success: true,
+		Message: fmt.Sprintf("Chunk %d shared with %s", grant.ChunkID, grant.RecipientAddr),
+	})
+}
+
+// handleListGrants handles GET /api/v1/storage/grants/:recipientAddr
+func (s *Server) handleListGrants(c *gin.Context) {
+	recipientAddr := c.Param("recipientAddr")
+	if !validAddress(recipientAddr) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid user address",
+			Message: "User address must be a valid Ethereum address (0x...)",
+		})
+		return
+	}
+
+	grants, err := s.node.Storage().ListGrantsForRecipient(recipientAddr)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to list grants",
+			Message: err.Error(),
+		})
+		return
+	}
+	if grants == nil {
+		grants = []*meshstorage.AccessGrant{}
+	}
+
+	c.JSON(http.StatusOK, GrantsResponse{
+		Success:       true,
+		RecipientAddr: recipientAddr,
+		Grants:        grants,
+	})
+}
+
+// handleRevokeGrant handles DELETE /api/v1/storage/grants/:ownerAddr/:chunkID/:recipientAddr
+// Requires X-Signature and X-Timestamp headers signed by the key that issued the grant
+func (s *Server) handleRevokeGrant(c *gin.Context) {
+	ownerAddr := c.Param("ownerAddr")
+	recipientAddr := c.Param("recipientAddr")
+	chunkID, err := strconv.Atoi(c.Param("chunkID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid chunk ID",
+			Message: "Chunk ID must be a number",
+		})
+		return
+	}
+
+	grant, err := s.node.Storage().GetGrant(ownerAddr, chunkID, recipientAddr)
+	if errors.Is(err, meshstorage.ErrGrantNotFound) {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Grant not found",
+			Message: fmt.Sprintf("No grant on chunk %d for %s", chunkID, recipientAddr),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to load grant",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	timestamp := c.GetHeader("X-Timestamp")
+	signatureB64 := c.GetHeader("X-Signature")
+	if err := verifyRevokeSignature(grant, timestamp, signatureB64); err != nil {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "Invalid signature",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := s.node.Storage().DeleteGrant(ownerAddr, chunkID, recipientAddr); err != nil && !errors.Is(err, meshstorage.ErrGrantNotFound) {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to revoke grant",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	fmt.Printf("🚫 Grant revoked: owner=%s chunk=%d recipient=%s\n", ownerAddr, chunkID, recipientAddr)
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: fmt.Sprintf("Access to chunk %d revoked for %s", chunkID, recipientAddr),
+	})
+}
+
+// RevokeMessage returns the message an owner signs to revoke a grant
+// Format: revoke|ownerAddr|chunkID|recipientAddr|timestamp (RFC3339)
+func RevokeMessage(ownerAddr string, chunkID int, recipientAddr, timestamp string) []byte {
+	return []byte(fmt.Sprintf("revoke|%s|%d|%s|%s", ownerAddr, chunkID, recipientAddr, timestamp))
+}
+
+// verifyRevokeSignature checks a revocation against the key that signed the grant
+func verifyRevokeSignature(grant *meshstorage.AccessGrant, timestamp, signatureB64 string) error {
+	message := RevokeMessage(grant.OwnerAddr, grant.ChunkID, grant.RecipientAddr, timestamp)
+	return verifyOwnerSignature(grant.OwnerPublicKey, message, timestamp, signatureB64)
+}
+
+// verifyOwnerSignature checks a timestamped request signed by an owner's PEM key
+// The timestamp must be within 5 minutes so captured signatures cannot be replayed later
+func verifyOwnerSignature(publicKeyPEM string, message []byte, timestamp, signatureB64 string) error {
+	if timestamp == "" || signatureB64 == "" {
+		return fmt.Errorf("X-Signature and X-Timestamp headers are required")
+	}
+
+	ts, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp format: %w", err)
+	}
+	diff := time.Since(ts)
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff > 5*time.Minute {
+		return fmt.Errorf("timestamp too old or in future (age: %v)", diff)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(signatureB64)
+	if err != nil {
+		return fmt.Errorf("invalid base64 signature: %w", err)
+	}
+
+	publicKey, err := crypto.ImportPublicKeyPEM([]byte(publicKeyPEM))
+	if err != nil {
+		return fmt.Errorf("invalid owner key: %w", err)
+	}
+
+	if err := crypto.VerifySignature(message, signature, publicKey); err != nil {
+		return fmt.Errorf("signature verification failed: %w", err)
+	}
+	return nil
+}
+
+// validAddress reports whether s looks like an Ethereum address
+func validAddress(s string) bool {
+	return len(s) == 42 && s[:2] == "0x"
+}
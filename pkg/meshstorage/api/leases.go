@@ -0,0 +1,146 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/meshstorage"
+	"github.com/gin-gonic/gin"
+)
+
+// RenewLeaseRequest asks for a chunk to be kept LeaseSeconds from now
+// Only the chunk's owner may renew: the request is signed (X-Signature,
+// X-Timestamp) by OwnerPublicKey, which the owner's wallet vouches for.
+type RenewLeaseRequest struct {
+	LeaseSeconds    int64  `json:"leaseSeconds" binding:"required"`
+	OwnerPublicKey  string `json:"ownerPublicKey"`  // PEM; verifies X-Signature
+	WalletSignature []byte `json:"walletSignature"` // userAddr's wallet over meshstorage.WalletBindingMessage("lease", ...)
+}
+
+// LeaseResponse reports a chunk's lease after renewal
+type LeaseResponse struct {
+	Success      bool      `json:"success"`
+	UserAddr     string    `json:"userAddr"`
+	ChunkID      int       `json:"chunkID"`
+	LeaseExpires time.Time `json:"leaseExpires"`
+}
+
+// leaseDuration converts a requested lease in seconds (0 = no lease)
+func leaseDuration(seconds int64) (time.Duration, bool) {
+	if seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// leaseExpiresPtr returns a chunk's lease expiry for a response (nil = no lease)
+func leaseExpiresPtr(chunk *meshstorage.DistributedChunk) *time.Time {
+	if chunk.LeaseExpires.IsZero() {
+		return nil
+	}
+	expires := chunk.LeaseExpires
+	return &expires
+}
+
+// RenewLeaseMessage returns the message an owner signs to renew a chunk's lease
+// Format: lease|userAddr|chunkID|leaseSeconds|timestamp (RFC3339)
+func RenewLeaseMessage(userAddr string, chunkID int, leaseSeconds int64, timestamp string) []byte {
+	return []byte(fmt.Sprintf("lease|%s|%d|%d|%s", userAddr, chunkID, leaseSeconds, timestamp))
+}
+
+// handleRenewLease handles POST /api/v1/storage/lease/:userAddr/:chunkID
+// Requires X-Signature and X-Timestamp headers over RenewLeaseMessage, signed by
+// the owner key the user's wallet binds in the request. Chunks stored without a
+// lease, and ones whose lease has run out, can't be renewed.
+func (s *Server) handleRenewLease(c *gin.Context) {
+	userAddr := c.Param("userAddr")
+	if !validAddress(userAddr) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid user address",
+			Message: "User address must be a valid Ethereum address (0x...)",
+		})
+		return
+	}
+
+	chunkID, err := strconv.Atoi(c.Param("chunkID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid chunk ID",
+			Message: "Chunk ID must be a number",
+		})
+		return
+	}
+
+	var req RenewLeaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+	lease, ok := leaseDuration(req.LeaseSeconds)
+	if !ok || lease == 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid lease",
+			Message: "leaseSeconds must be positive",
+		})
+		return
+	}
+
+	timestamp := c.GetHeader("X-Timestamp")
+	signatureB64 := c.GetHeader("X-Signature")
+	message := RenewLeaseMessage(userAddr, chunkID, req.LeaseSeconds, timestamp)
+	if err := verifyOwnerSignature(req.OwnerPublicKey, message, timestamp, signatureB64); err != nil {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "Invalid signature",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err := meshstorage.VerifyWalletBinding("lease", userAddr, req.OwnerPublicKey, req.WalletSignature); err != nil {
+		c.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "Owner key not bound to address",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	chunk, exists := s.getChunkMetadata(userAddr, chunkID)
+	if !exists {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Chunk not found",
+			Message: "No chunk with this ID is known to this node",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := s.distributedStore.RenewLease(ctx, chunk, lease); err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, meshstorage.ErrNoLease):
+			status = http.StatusConflict
+		case errors.Is(err, meshstorage.ErrLeaseExpired):
+			status = http.StatusGone
+		}
+		c.JSON(status, ErrorResponse{
+			Error:   "Lease renewal failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, LeaseResponse{
+		Success:      true,
+		UserAddr:     userAddr,
+		ChunkID:      chunkID,
+		LeaseExpires: chunk.LeaseExpires,
+	})
+}
@@ -0,0 +1,299 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/meshstorage"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// DefaultLinkTTL is how long a shared link is valid when no expiry is requested
+	DefaultLinkTTL = 24 * time.Hour
+
+	// MaxLinkTTL caps the lifetime of a shared link
+	MaxLinkTTL = 7 * 24 * time.Hour
+)
+
+var (
+	errLinkInvalid = errors.New("invalid link")
+	errLinkExpired = errors.New("link expired")
+)
+
+// CreateLinkRequest asks for a time-boxed download link to a stored chunk
+// Only the chunk's owner may create links: the request is signed (X-Signature,
+// X-Timestamp) by OwnerPublicKey, which the owner's wallet vouches for.
+type CreateLinkRequest struct {
+	UserAddr        string `json:"userAddr" binding:"required"`
+	ChunkID         int    `json:"chunkID" binding:"required"`
+	ExpiresIn       int    `json:"expiresIn"`       // Seconds; 0 = DefaultLinkTTL
+	Filename        string `json:"filename"`        // Optional download filename
+	OwnerPublicKey  string `json:"ownerPublicKey"`  // PEM; verifies X-Signature
+	WalletSignature []byte `json:"walletSignature"` // UserAddr's wallet over meshstorage.WalletBindingMessage("link", ...)
+}
+
+// CreateLinkResponse returns a shareable link
+type CreateLinkResponse struct {
+	Success   bool      `json:"success"`
+	URL       string    `json:"url"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// linkClaims is the chunk reference carried by a link token
+type linkClaims struct {
+	UserAddr  string `json:"u"`
+	ChunkID   int    `json:"c"`
+	ExpiresAt int64  `json:"e"`
+	Filename  string `json:"f,omitempty"`
+}
+
+// linkSigner issues and verifies link tokens
+// Tokens are <base64url claims>.<base64url HMAC-SHA256>; any API node configured
+// with the same secret accepts links issued by the others
+type linkSigner struct {
+	secret []byte
+}
+
+// newLinkSigner creates a signer; without a secret a random one is generated,
+// so links only work on this node until it restarts
+func newLinkSigner(secret string) (*linkSigner, error) {
+	if secret != "" {
+		return &linkSigner{secret: []byte(secret)}, nil
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, fmt.Errorf("failed to generate link secret: %w", err)
+	}
+	fmt.Printf("⚠️  No link secret configured; shared links are valid on this node only and reset on restart\n")
+	return &linkSigner{secret: random}, nil
+}
+
+// sign returns a token for the claims
+func (ls *linkSigner) sign(claims linkClaims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(ls.mac(encoded)), nil
+}
+
+// verify checks a token's MAC and expiry and returns its claims
+func (ls *linkSigner) verify(token string) (*linkClaims, error) {
+	encoded, macStr, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, errLinkInvalid
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(macStr)
+	if err != nil || !hmac.Equal(mac, ls.mac(encoded)) {
+		return nil, errLinkInvalid
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errLinkInvalid
+	}
+	var claims linkClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, errLinkInvalid
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, errLinkExpired
+	}
+	return &claims, nil
+}
+
+// mac computes the HMAC of an encoded claims payload
+func (ls *linkSigner) mac(encoded string) []byte {
+	h := hmac.New(sha256.New, ls.secret)
+	h.Write([]byte(encoded))
+	return h.Sum(nil)
+}
+
+// LinkMessage returns the message an owner signs to create a link to a chunk
+// Format: link|userAddr|chunkID|timestamp (RFC3339)
+func LinkMessage(userAddr string, chunkID int, timestamp string) []byte {
+	return []byte(fmt.Sprintf("link|%s|%d|%s", userAddr, chunkID, timestamp))
+}
+
+// handleCreateLink handles POST /api/v1/storage/links
+// Requires X-Signature and X-Timestamp headers over LinkMessage, signed by the
+// owner key the user's wallet binds in the request
+func (s *Server) handleCreateLink(c *gin.Context) {
+	var req CreateLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if !validAddress(req.UserAddr) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid user address",
+			Message: "User address must be a valid Ethereum address (0x...)",
+		})
+		return
+	}
+
+	timestamp := c.GetHeader("X-Timestamp")
+	signatureB64 := c.GetHeader("X-Signature")
+	if err := verifyOwnerSignature(req.OwnerPublicKey, LinkMessage(req.UserAddr, req.ChunkID, timestamp), timestamp, signatureB64); err != nil {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "Invalid signature",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err := meshstorage.VerifyWalletBinding("link", req.UserAddr, req.OwnerPublicKey, req.WalletSignature); err != nil {
+		c.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "Owner key not bound to address",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ttl := DefaultLinkTTL
+	if req.ExpiresIn > 0 {
+		ttl = time.Duration(req.ExpiresIn) * time.Second
+	}
+	if ttl > MaxLinkTTL {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Expiry too long",
+			Message: fmt.Sprintf("Links can be valid for at most %s", MaxLinkTTL),
+		})
+		return
+	}
+
+	if _, exists := s.getChunkMetadata(req.UserAddr, req.ChunkID); !exists {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Data not found",
+			Message: fmt.Sprintf("No data found for user %s chunk %d", req.UserAddr, req.ChunkID),
+		})
+		return
+	}
+
+	expiresAt := time.Now().Add(ttl)
+	token, err := s.links.sign(linkClaims{
+		UserAddr:  req.UserAddr,
+		ChunkID:   req.ChunkID,
+		ExpiresAt: expiresAt.Unix(),
+		Filename:  req.Filename,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Link creation failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+
+	fmt.Printf("🔗 Shared link created: user=%s chunk=%d expires=%s\n", req.UserAddr, req.ChunkID, expiresAt.Format(time.RFC3339))
+
+	c.JSON(http.StatusCreated, CreateLinkResponse{
+		Success:   true,
+		URL:       fmt.Sprintf("%s://%s/api/v1/links/%s", scheme, c.Request.Host, token),
+		Token:     token,
+		ExpiresAt: expiresAt,
+	})
+}
+
+// handleOpenLink handles GET /api/v1/links/:token
+// Serves the chunk as a file download, for recipients without a ZenTalk client
+func (s *Server) handleOpenLink(c *gin.Context) {
+	claims, err := s.links.verify(c.Param("token"))
+	if errors.Is(err, errLinkExpired) {
+		c.JSON(http.StatusGone, ErrorResponse{
+			Error:   "Link expired",
+			Message: "Ask the sender for a new link",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "Invalid link",
+			Message: "The link is malformed or was not issued by this network",
+		})
+		return
+	}
+
+	chunk, exists := s.getChunkMetadata(claims.UserAddr, claims.ChunkID)
+	if !exists {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Data not found",
+			Message: "The shared file is no longer available on this node",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
+	defer cancel()
+
+	stored, err := s.distributedStore.RetrieveDistributed(ctx, chunk)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Retrieval failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	data, err := decryptForLink(claims.UserAddr, stored)
+	if err != nil {
+		c.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "Content cannot be shared by link",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	s.node.UsageMeter().RecordServed(claims.UserAddr, len(data))
+
+	filename := claims.Filename
+	if filename == "" {
+		filename = fmt.Sprintf("zentalk_%d.bin", claims.ChunkID)
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Header("Cache-Control", "private, no-store")
+	c.Data(http.StatusOK, "application/octet-stream", data)
+}
+
+// decryptForLink returns the plaintext a link recipient should receive
+// Links carry no key, so only wallet-derived encryption (the server default) can be
+// opened; password- and signature-encrypted chunks are refused rather than served
+// as ciphertext. Unencrypted and client-encrypted chunks are served as stored.
+func decryptForLink(userAddr string, stored []byte) ([]byte, error) {
+	var encrypted meshstorage.EncryptedData
+	if err := json.Unmarshal(stored, &encrypted); err != nil {
+		return stored, nil
+	}
+
+	key, err := meshstorage.DeriveKeyFromWalletAddress(userAddr)
+	if err != nil {
+		return nil, err
+	}
+	data, err := meshstorage.Decrypt(&encrypted, key)
+	if err != nil {
+		return nil, fmt.Errorf("chunk is protected by a password or signature key")
+	}
+	return data, nil
+}
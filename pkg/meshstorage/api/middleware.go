@@ -1,6 +1,7 @@
 package api
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"net/http"
 	"sync"
@@ -192,6 +193,21 @@ func AuthMiddleware(validAPIKeys map[string]bool) gin.HandlerFunc {
 	}
 }
 
+// AdminAuthMiddleware requires "Authorization: Bearer <token>" for operator endpoints
+func AdminAuthMiddleware(token string) gin.HandlerFunc {
+	expected := []byte("Bearer " + token)
+	return func(c *gin.Context) {
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), expected) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{
+				Error:   "Unauthorized",
+				Message: "A valid admin token is required",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
 // ErrorResponse is a standard error response
 type ErrorResponse struct {
 	Error   string `json:"error"`
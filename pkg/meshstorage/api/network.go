@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ZentaChain/zentalk-node/pkg/chaos"
+	"github.com/ZentaChain/zentalk-node/pkg/features"
 	"github.com/ZentaChain/zentalk-node/pkg/meshstorage"
 )
 
@@ -59,6 +61,7 @@ type NodeInfoResponse struct {
 	ConnectedAt   int      `json:"connectedPeers"`
 	StoragePath   string   `json:"storagePath"`
 	StartedAt     time.Time `json:"startedAt"`
+	Features      []features.Flag `json:"features"` // Compiled-in features and whether this node has them on
 }
 
 // NodeStatsResponse contains statistics about this node
@@ -73,6 +76,8 @@ type NodeStatsResponse struct {
 		UploadCount       int64   `json:"uploadCount"`
 		DownloadCount     int64   `json:"downloadCount"`
 		SuccessRate       float64 `json:"successRate"`
+		CacheBytes        int64   `json:"cacheBytes"`
+		CacheHitRate      float64 `json:"cacheHitRate"`
 	} `json:"stats"`
 }
 
@@ -136,16 +141,18 @@ func (s *Server) handleHealth(c *gin.Context) {
 	// 1. Check DHT reachability (check if node is bootstrapped and has routing table entries)
 	dhtReachable := s.node.IsBootstrapped() && len(s.node.GetPeers()) > 0
 
-	// 2. Check storage writability with a test write
+	// 2. Check storage writability with a test write (bootstrap-only nodes have no storage)
 	storageWritable := true
 	testData := []byte("health-check-test")
 	testAddr := "0x0000000000000000000000000000000000000000"
 	testChunkID := -1 // Special negative ID for health checks
-	if err := s.node.Storage().StoreChunk(testAddr, testChunkID, testData); err != nil {
-		storageWritable = false
-	} else {
-		// Clean up test data
-		s.node.Storage().DeleteChunk(testAddr, testChunkID)
+	if storage := s.node.Storage(); storage != nil {
+		if err := storage.StoreChunk(testAddr, testChunkID, testData); err != nil {
+			storageWritable = false
+		} else {
+			// Clean up test data
+			storage.DeleteChunk(testAddr, testChunkID)
+		}
 	}
 
 	// 3. Check peer connectivity
@@ -189,8 +196,25 @@ func (s *Server) handleHealth(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// nodeFeatures returns the features a node serves with this configuration
+func nodeFeatures(node *meshstorage.DHTNode, config *Config) *features.Registry {
+	registry := features.NewRegistry()
+
+	// Bootstrap-only nodes serve no storage endpoints
+	storing := !node.IsBootstrapOnly()
+	registry.Set(features.StreamedUploads, storing)
+	registry.Set(features.SharedLinks, storing)
+	registry.Set(features.StoragePins, storing)
+	registry.Set(features.AdminAPI, config.AdminToken != "")
+	registry.Set(features.ChunkCache, node.Storage() != nil && node.Storage().Cache() != nil)
+	return registry
+}
+
 // handleNodeInfo handles GET /api/v1/node/info
 func (s *Server) handleNodeInfo(c *gin.Context) {
+	// Faults can be switched on and off at runtime through the admin API
+	s.features.Set(features.Chaos, chaos.Current().Active())
+
 	addrs := s.node.Addresses()
 	addrStrs := make([]string, len(addrs))
 	for i, addr := range addrs {
@@ -206,6 +230,7 @@ func (s *Server) handleNodeInfo(c *gin.Context) {
 		ConnectedAt:   len(s.node.GetPeers()),
 		StoragePath:   s.storagePath,
 		StartedAt:     nodeStartTime,
+		Features:      s.features.Flags(),
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -250,6 +275,11 @@ func (s *Server) handleNodeStats(c *gin.Context) {
 	response.Stats.UploadCount = uploadCounter
 	response.Stats.DownloadCount = downloadCounter
 	response.Stats.SuccessRate = successRate
+	if cache := s.node.Storage().Cache(); cache != nil {
+		cacheStats := cache.Stats()
+		response.Stats.CacheBytes = cacheStats.Bytes
+		response.Stats.CacheHitRate = cacheStats.HitRate()
+	}
 
 	c.JSON(http.StatusOK, response)
 }
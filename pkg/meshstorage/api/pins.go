@@ -0,0 +1,198 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ZentaChain/zentalk-node/pkg/meshstorage"
+	"github.com/gin-gonic/gin"
+)
+
+// PinSetResponse returns a user's pinned storage nodes
+type PinSetResponse struct {
+	Success bool                `json:"success"`
+	Pins    *meshstorage.PinSet `json:"pins"`
+}
+
+// handleSetPins handles PUT /api/v1/storage/pins
+// The body is a PinSet signed by the user. It replaces the user's pin set, which
+// must have been signed by the same key, and applies to this node's placement
+// and repair of the user's chunks from now on. A first pin set, or one with a
+// new owner key, must carry the user's wallet signature over that key.
+func (s *Server) handleSetPins(c *gin.Context) {
+	var pins meshstorage.PinSet
+	if err := c.ShouldBindJSON(&pins); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if !validAddress(pins.UserAddr) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid user address",
+			Message: "User address must be a valid Ethereum address (0x...)",
+		})
+		return
+	}
+
+	if err := pins.Verify(); err != nil {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "Invalid pin set",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := s.distributedStore.CheckPinSet(&pins); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid pin set",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	existing, err := s.node.Storage().GetPinSet(pins.UserAddr)
+	if err != nil && !errors.Is(err, meshstorage.ErrPinSetNotFound) {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to load pin set",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil || existing.OwnerPublicKey != pins.OwnerPublicKey {
+		// Only the address's wallet can name the key that controls its pins
+		if walletErr := pins.VerifyWallet(); walletErr != nil {
+			c.JSON(http.StatusForbidden, ErrorResponse{
+				Error:   "Owner key not bound to address",
+				Message: walletErr.Error(),
+			})
+			return
+		}
+	}
+	if err == nil {
+		if pins.IssuedAt <= existing.IssuedAt {
+			c.JSON(http.StatusConflict, ErrorResponse{
+				Error:   "Stale pin set",
+				Message: "A pin set issued at the same time or later is already in place",
+			})
+			return
+		}
+	}
+
+	if err := s.node.Storage().StorePinSet(&pins); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to store pin set",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err := s.distributedStore.SetPinSet(&pins); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to apply pin set",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: fmt.Sprintf("%d shards of each chunk pinned to %d nodes", pins.MinShards, len(pins.Nodes)),
+	})
+}
+
+// handleGetPins handles GET /api/v1/storage/pins/:userAddr
+func (s *Server) handleGetPins(c *gin.Context) {
+	userAddr := c.Param("userAddr")
+
+	pins, err := s.node.Storage().GetPinSet(userAddr)
+	if errors.Is(err, meshstorage.ErrPinSetNotFound) {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Pin set not found",
+			Message: fmt.Sprintf("%s has no pinned nodes", userAddr),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to load pin set",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, PinSetResponse{
+		Success: true,
+		Pins:    pins,
+	})
+}
+
+// handleDeletePins handles DELETE /api/v1/storage/pins/:userAddr
+// Requires X-Signature and X-Timestamp headers signed by the key that issued the pin set
+func (s *Server) handleDeletePins(c *gin.Context) {
+	userAddr := c.Param("userAddr")
+
+	pins, err := s.node.Storage().GetPinSet(userAddr)
+	if errors.Is(err, meshstorage.ErrPinSetNotFound) {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Pin set not found",
+			Message: fmt.Sprintf("%s has no pinned nodes", userAddr),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to load pin set",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	timestamp := c.GetHeader("X-Timestamp")
+	signatureB64 := c.GetHeader("X-Signature")
+	if err := verifyOwnerSignature(pins.OwnerPublicKey, UnpinMessage(userAddr, timestamp), timestamp, signatureB64); err != nil {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "Invalid signature",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := s.node.Storage().DeletePinSet(userAddr); err != nil && !errors.Is(err, meshstorage.ErrPinSetNotFound) {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to delete pin set",
+			Message: err.Error(),
+		})
+		return
+	}
+	s.distributedStore.RemovePinSet(userAddr)
+
+	fmt.Printf("📌 Pins removed for %s\n", userAddr)
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: fmt.Sprintf("Pinned nodes removed for %s", userAddr),
+	})
+}
+
+// UnpinMessage returns the message a user signs to remove their pin set
+// Format: unpin|userAddr|timestamp (RFC3339)
+func UnpinMessage(userAddr, timestamp string) []byte {
+	return []byte(fmt.Sprintf("unpin|%s|%s", userAddr, timestamp))
+}
+
+// loadPinSets applies the pin sets stored on this node to placement and repair
+func (s *Server) loadPinSets() error {
+	sets, err := s.node.Storage().ListPinSets()
+	if err != nil {
+		return err
+	}
+	for _, pins := range sets {
+		if err := s.distributedStore.SetPinSet(pins); err != nil {
+			fmt.Printf("⚠️  Ignoring stored pin set for %s: %v\n", pins.UserAddr, err)
+		}
+	}
+	return nil
+}
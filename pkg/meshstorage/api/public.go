@@ -0,0 +1,332 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/meshstorage"
+	"github.com/gin-gonic/gin"
+)
+
+// maxPublicSize is the same limit as /storage/upload
+const maxPublicSize = 100 * 1024 * 1024
+
+// hashPattern matches a hex SHA-256 digest
+var hashPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// PublicUploadRequest stores unencrypted content addressed by its hash
+type PublicUploadRequest struct {
+	Data           string `json:"data" binding:"required"` // Base64 encoded content
+	ContentType    string `json:"contentType"`             // Defaults to application/octet-stream
+	OwnerPublicKey string `json:"ownerPublicKey"`          // Optional PEM; allows deletion and access tokens
+	RequireToken   bool   `json:"requireToken"`            // Reads need a token signed by the owner
+}
+
+// PublicUploadResponse describes a stored public object
+type PublicUploadResponse struct {
+	Success      bool   `json:"success"`
+	Hash         string `json:"hash"`
+	URL          string `json:"url"`
+	Size         int    `json:"sizeBytes"`
+	ContentType  string `json:"contentType"`
+	RequireToken bool   `json:"requireToken"`
+	Existing     bool   `json:"existing"` // Content was already stored
+}
+
+// handlePublicUpload handles POST /api/v1/public
+func (s *Server) handlePublicUpload(c *gin.Context) {
+	var req PublicUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	data, err := base64.StdEncoding.DecodeString(req.Data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid data encoding",
+			Message: "Data must be base64 encoded",
+		})
+		return
+	}
+	if len(data) == 0 || len(data) > maxPublicSize {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid size",
+			Message: fmt.Sprintf("Content must be between 1 byte and %d MB", maxPublicSize/(1024*1024)),
+		})
+		return
+	}
+
+	if req.OwnerPublicKey != "" {
+		if _, err := crypto.ImportPublicKeyPEM([]byte(req.OwnerPublicKey)); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid owner key",
+				Message: err.Error(),
+			})
+			return
+		}
+	} else if req.RequireToken {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Owner key required",
+			Message: "Token-gated content needs an ownerPublicKey to verify tokens",
+		})
+		return
+	}
+
+	if req.ContentType == "" {
+		req.ContentType = "application/octet-stream"
+	}
+
+	hash := meshstorage.HashData(data)
+
+	// Content addressing makes re-uploads free, as long as the access settings agree
+	if existing, err := s.node.Storage().GetPublicObject(hash); err == nil {
+		if existing.OwnerPublicKey != req.OwnerPublicKey || existing.RequireToken != req.RequireToken {
+			c.JSON(http.StatusConflict, ErrorResponse{
+				Error:   "Content already published",
+				Message: "The same content is stored with different owner or access settings",
+			})
+			return
+		}
+		c.JSON(http.StatusOK, publicUploadResponse(existing, true))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(s.uploadCtx, 60*time.Second)
+	defer cancel()
+
+	chunk, err := s.distributedStore.StoreDistributed(ctx, meshstorage.PublicContentAddr(hash), 0, data)
+	if err != nil {
+		fmt.Printf("❌ Public upload failed: %v\n", err)
+		storeFailed(c, err)
+		return
+	}
+
+	obj := &meshstorage.PublicObject{
+		Hash:           hash,
+		ContentType:    req.ContentType,
+		Size:           len(data),
+		OwnerPublicKey: req.OwnerPublicKey,
+		RequireToken:   req.RequireToken,
+		CreatedAt:      time.Now().Unix(),
+		Chunk:          chunk,
+	}
+	err = s.node.Storage().StorePublicObject(obj)
+	if errors.Is(err, meshstorage.ErrPublicObjectExists) {
+		// A concurrent upload of the same content recorded it first; its settings stand
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "Content already published",
+			Message: "The same content was published concurrently",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to record public object",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	fmt.Printf("🌐 Public object stored: %s (%d bytes, %s, token: %v)\n", hash, len(data), req.ContentType, req.RequireToken)
+	c.JSON(http.StatusCreated, publicUploadResponse(obj, false))
+}
+
+// handlePublicDownload handles GET /api/v1/public/:hash
+// Token-gated objects need ?token= or an X-Access-Token header
+func (s *Server) handlePublicDownload(c *gin.Context) {
+	obj, ok := s.lookupPublicObject(c)
+	if !ok {
+		return
+	}
+
+	if obj.RequireToken {
+		token := c.Query("token")
+		if token == "" {
+			token = c.GetHeader("X-Access-Token")
+		}
+		if token == "" {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{
+				Error:   "Access token required",
+				Message: "This content requires a token issued by its owner",
+			})
+			return
+		}
+		if err := meshstorage.VerifyPublicAccessToken(obj, token); err != nil {
+			c.JSON(http.StatusForbidden, ErrorResponse{
+				Error:   "Invalid access token",
+				Message: err.Error(),
+			})
+			return
+		}
+	}
+
+	etag := `"` + obj.Hash + `"`
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
+	defer cancel()
+
+	data, err := s.distributedStore.RetrieveDistributed(ctx, obj.Chunk)
+	if err != nil {
+		fmt.Printf("❌ Public download failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Retrieval failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	// The address is the hash, so what we serve must match it
+	if !meshstorage.VerifyDataHash(data, obj.Hash) {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Integrity check failed",
+			Message: "Retrieved content does not match its hash",
+		})
+		return
+	}
+
+	s.node.UsageMeter().RecordServed(obj.Chunk.UserAddr, len(data))
+
+	// Content never changes for a given hash
+	if obj.RequireToken {
+		c.Header("Cache-Control", "private, max-age=3600")
+	} else {
+		c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	}
+	c.Header("ETag", etag)
+
+	// The uploader picks the content type, so nothing served here may run as a page on this origin
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Security-Policy", "default-src 'none'; sandbox")
+	if !inlinePublicType(obj.ContentType) {
+		c.Header("Content-Disposition", "attachment")
+	}
+	c.Data(http.StatusOK, obj.ContentType, data)
+}
+
+// inlinePublicType reports whether public content of this type may be shown inline
+// Only images (except SVG, which can carry script), audio and video are; everything
+// else is served as a download.
+func inlinePublicType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if mediaType == "image/svg+xml" {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "image/") ||
+		strings.HasPrefix(mediaType, "audio/") ||
+		strings.HasPrefix(mediaType, "video/")
+}
+
+// handlePublicDelete handles DELETE /api/v1/public/:hash
+// Requires X-Timestamp and X-Signature headers signed by the owner key given at upload
+func (s *Server) handlePublicDelete(c *gin.Context) {
+	obj, ok := s.lookupPublicObject(c)
+	if !ok {
+		return
+	}
+
+	if obj.OwnerPublicKey == "" {
+		c.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "Content has no owner",
+			Message: "Public content uploaded without an owner key cannot be deleted",
+		})
+		return
+	}
+
+	timestamp := c.GetHeader("X-Timestamp")
+	signatureB64 := c.GetHeader("X-Signature")
+	if err := verifyOwnerSignature(obj.OwnerPublicKey, PublicDeleteMessage(obj.Hash, timestamp), timestamp, signatureB64); err != nil {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "Invalid signature",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := s.distributedStore.DeleteChunk(c.Request.Context(), meshstorage.PublicContentAddr(obj.Hash), 0); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Deletion failed",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err := s.node.Storage().DeletePublicObject(obj.Hash); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Deletion failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	fmt.Printf("🗑️  Public object deleted: %s\n", obj.Hash)
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: fmt.Sprintf("Public object %s deleted", obj.Hash),
+	})
+}
+
+// PublicDeleteMessage returns the message an owner signs to delete a public object
+// Format: delete-public|hash|timestamp (RFC3339)
+func PublicDeleteMessage(hash, timestamp string) []byte {
+	return []byte(fmt.Sprintf("delete-public|%s|%s", hash, timestamp))
+}
+
+// lookupPublicObject finds the object named in the URL, writing an error if missing
+func (s *Server) lookupPublicObject(c *gin.Context) (*meshstorage.PublicObject, bool) {
+	hash := c.Param("hash")
+	if !hashPattern.MatchString(hash) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid hash",
+			Message: "Public content is addressed by its lowercase hex SHA-256",
+		})
+		return nil, false
+	}
+
+	obj, err := s.node.Storage().GetPublicObject(hash)
+	if errors.Is(err, meshstorage.ErrPublicObjectNotFound) {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Data not found",
+			Message: fmt.Sprintf("No public object with hash %s", hash),
+		})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Lookup failed",
+			Message: err.Error(),
+		})
+		return nil, false
+	}
+	return obj, true
+}
+
+// publicUploadResponse describes a public object to the uploader
+func publicUploadResponse(obj *meshstorage.PublicObject, existing bool) PublicUploadResponse {
+	return PublicUploadResponse{
+		Success:      true,
+		Hash:         obj.Hash,
+		URL:          "/api/v1/public/" + obj.Hash,
+		Size:         obj.Size,
+		ContentType:  obj.ContentType,
+		RequireToken: obj.RequireToken,
+		Existing:     existing,
+	}
+}
@@ -6,9 +6,12 @@ import (
 	"fmt"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ZentaChain/zentalk-node/pkg/features"
+	"github.com/ZentaChain/zentalk-node/pkg/logging"
 	"github.com/ZentaChain/zentalk-node/pkg/meshstorage"
 )
 
@@ -23,6 +26,25 @@ type Server struct {
 	metadataMu       sync.RWMutex
 	storagePath      string // Path to storage directory
 	isBootstrap      bool   // Whether this node is a bootstrap node
+	sessions         *sessionStore // Multi-part upload sessions
+	streams          *streamStore  // Streamed (resumable) uploads stored as several chunks
+	segmentSize      int           // Bytes per chunk of a streamed upload
+	links            *linkSigner   // Issues and verifies shared download links
+	repair           repairRun     // Operator-triggered repair pass
+	features         *features.Registry // Features this node serves, reported in /node/info
+	logs             *logging.Sink      // Recent log lines for /admin/logs (nil = not kept)
+
+	// Graceful shutdown: uploads in flight are drained before the node goes away
+	drainTimeout  time.Duration
+	drainMu       sync.Mutex
+	draining      bool
+	uploads       sync.WaitGroup
+	uploadCtx     context.Context    // Parent of every upload's context
+	cancelUploads context.CancelFunc // Aborts (and rolls back) uploads still running after the drain timeout
+	shutdownOnce  sync.Once
+	shutdownErr   error
+	paused        bool         // Uploads refused at an operator's request (guarded by drainMu)
+	inFlight      atomic.Int64 // Uploads currently running
 }
 
 // Config holds server configuration
@@ -35,6 +57,12 @@ type Config struct {
 	WriteTimeout    time.Duration
 	StoragePath     string // Path to storage directory (optional, defaults to node's storage path)
 	IsBootstrap     bool   // Whether this node is a bootstrap node (optional, defaults to false)
+	DrainTimeout    time.Duration // How long shutdown waits for in-flight uploads (optional, defaults to 30s)
+	LinkSecret      string        // HMAC secret for shared links; share it across API nodes (optional, random per process)
+	AdminToken      string        // Bearer token for /api/v1/admin (optional, admin endpoints disabled when empty)
+	Logs            *logging.Sink // Process log whose recent lines /api/v1/admin/logs serves (optional)
+	Erasure         meshstorage.ErasureConfig // Erasure coding of new uploads (optional, defaults to 10+5)
+	Rebalance       meshstorage.RebalanceConfig // Shard migration toward closer nodes (optional, disabled when Interval is 0)
 }
 
 // DefaultConfig returns default server configuration
@@ -46,19 +74,28 @@ func DefaultConfig() *Config {
 		MaxUploadSizeMB: 100,
 		ReadTimeout:     30 * time.Second,
 		WriteTimeout:    30 * time.Second,
+		DrainTimeout:    DefaultDrainTimeout,
 	}
 }
 
+// DefaultDrainTimeout is how long shutdown waits for in-flight uploads before aborting them
+const DefaultDrainTimeout = 30 * time.Second
+
 // NewServer creates a new HTTP API server
 func NewServer(node *meshstorage.DHTNode, config *Config) (*Server, error) {
 	if config == nil {
 		config = DefaultConfig()
 	}
 
-	// Create distributed storage instance
-	distributedStore, err := meshstorage.NewDistributedStorage(node)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create distributed storage: %w", err)
+	// Create distributed storage instance (bootstrap-only nodes have no storage)
+	var distributedStore *meshstorage.DistributedStorage
+	if !node.IsBootstrapOnly() {
+		var err error
+		distributedStore, err = meshstorage.NewDistributedStorageWithErasure(node, config.Erasure)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create distributed storage: %w", err)
+		}
+		distributedStore.StartRebalancing(config.Rebalance)
 	}
 
 	// Set Gin to release mode for production
@@ -68,10 +105,22 @@ func NewServer(node *meshstorage.DHTNode, config *Config) (*Server, error) {
 
 	// Get storage path from node if not provided
 	storagePath := config.StoragePath
-	if storagePath == "" {
+	if storagePath == "" && node.Storage() != nil {
 		storagePath = node.Storage().Path() // Get actual storage path from node
 	}
 
+	drainTimeout := config.DrainTimeout
+	if drainTimeout <= 0 {
+		drainTimeout = DefaultDrainTimeout
+	}
+
+	links, err := newLinkSigner(config.LinkSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	uploadCtx, cancelUploads := context.WithCancel(context.Background())
+
 	server := &Server{
 		node:             node,
 		distributedStore: distributedStore,
@@ -79,14 +128,39 @@ func NewServer(node *meshstorage.DHTNode, config *Config) (*Server, error) {
 		port:             config.Port,
 		chunkMetadata:    make(map[string]*meshstorage.DistributedChunk),
 		storagePath:      storagePath,
-		isBootstrap:      config.IsBootstrap,
+		isBootstrap:      config.IsBootstrap || node.IsBootstrapOnly(),
+		sessions:         newSessionStore(),
+		streams:          newStreamStore(),
+		segmentSize:      StreamSegmentSize,
+		links:            links,
+		features:         nodeFeatures(node, config),
+		logs:             config.Logs,
+		drainTimeout:     drainTimeout,
+		uploadCtx:        uploadCtx,
+		cancelUploads:    cancelUploads,
+	}
+
+	// Pins stored by earlier runs apply to placement and repair straight away,
+	// and chunks stored by them can be downloaded again
+	if distributedStore != nil {
+		if err := server.loadPinSets(); err != nil {
+			return nil, fmt.Errorf("failed to load pin sets: %w", err)
+		}
+		server.loadChunkMetadata()
 	}
 
 	// Setup middleware
 	server.setupMiddleware(config)
 
 	// Setup routes
-	server.setupRoutes()
+	if node.IsBootstrapOnly() {
+		server.setupBootstrapRoutes()
+	} else {
+		server.setupRoutes()
+	}
+	if config.AdminToken != "" {
+		server.setupAdminRoutes(config.AdminToken)
+	}
 
 	return server, nil
 }
@@ -119,18 +193,57 @@ func (s *Server) setupRoutes() {
 		// Storage endpoints
 		storage := v1.Group("/storage")
 		{
-			storage.POST("/upload", s.handleUpload)
+			storage.POST("/upload", s.drainGuard(), s.handleUpload)
 			storage.GET("/download/:userAddr/:chunkID", s.handleDownload)
 			storage.GET("/status/:userAddr/:chunkID", s.handleStatus)
 			storage.DELETE("/delete/:userAddr/:chunkID", s.handleDelete)
+			storage.POST("/lease/:userAddr/:chunkID", s.handleRenewLease)
+			storage.GET("/chunks/:userAddr", s.handleListChunks)
+
+			// Multi-part upload sessions with progress and cancellation
+			storage.POST("/sessions", s.drainGuard(), s.handleCreateSession)
+			storage.PUT("/sessions/:sessionID/parts/:part", s.drainGuard(), s.handleUploadPart)
+			storage.GET("/sessions/:sessionID", s.handleSessionStatus)
+			storage.POST("/sessions/:sessionID/complete", s.drainGuard(), s.handleCompleteSession)
+			storage.DELETE("/sessions/:sessionID", s.handleCancelSession)
+
+			// Streamed uploads: resumable, split into several chunks, never held whole in memory
+			storage.POST("/upload/stream", s.drainGuard(), s.handleCreateStream)
+			storage.PATCH("/upload/stream/:sessionID", s.drainGuard(), s.handleStreamWrite)
+			storage.GET("/upload/stream/:sessionID", s.handleStreamStatus)
+			storage.DELETE("/upload/stream/:sessionID", s.handleCancelStream)
+
+			// Chunk sharing: signed grants wrapping a chunk key for a recipient
+			storage.POST("/grants", s.handleCreateGrant)
+			storage.GET("/grants/:recipientAddr", s.handleListGrants)
+			storage.DELETE("/grants/:ownerAddr/:chunkID/:recipientAddr", s.handleRevokeGrant)
+
+			// Time-boxed download links for recipients outside ZenTalk
+			storage.POST("/links", s.handleCreateLink)
+
+			// Storage nodes a user requires to hold some of each of their chunks
+			storage.PUT("/pins", s.handleSetPins)
+			storage.GET("/pins/:userAddr", s.handleGetPins)
+			storage.DELETE("/pins/:userAddr", s.handleDeletePins)
+		}
+
+		// Public content: unencrypted, addressed by SHA-256, optionally token-gated
+		public := v1.Group("/public")
+		{
+			public.POST("", s.drainGuard(), s.handlePublicUpload)
+			public.GET("/:hash", s.handlePublicDownload)
+			public.DELETE("/:hash", s.handlePublicDelete)
 		}
 
+		v1.GET("/links/:token", s.handleOpenLink)
+
 		// Network endpoints
 		network := v1.Group("/network")
 		{
 			network.GET("/info", s.handleNetworkInfo)
 			network.GET("/peers", s.handlePeers)
 			network.GET("/health", s.handleHealth)
+			network.GET("/seeds", s.handleSeedList)
 		}
 
 		// Node endpoints
@@ -166,22 +279,93 @@ func (s *Server) Start(ctx context.Context) error {
 	// Wait for context cancellation
 	<-ctx.Done()
 
-	// Graceful shutdown
+	return s.Shutdown()
+}
+
+// Stop stops the HTTP server
+func (s *Server) Stop() error {
+	return s.Shutdown()
+}
+
+// Shutdown drains the server: new uploads are rejected, in-flight uploads get up to
+// the drain timeout to finish distributing their shards, and any still running after
+// that are cancelled so StoreDistributed rolls back their partially stored shards
+func (s *Server) Shutdown() error {
+	s.shutdownOnce.Do(func() {
+		s.shutdownErr = s.shutdown()
+	})
+	return s.shutdownErr
+}
+
+// shutdown performs the drain (called once)
+func (s *Server) shutdown() error {
 	fmt.Println("\n🛑 Shutting down HTTP API server...")
+
+	s.drainMu.Lock()
+	s.draining = true
+	s.drainMu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		s.uploads.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-time.After(s.drainTimeout):
+		fmt.Printf("⚠️  Uploads still running after %v, aborting and rolling back\n", s.drainTimeout)
+		s.cancelUploads()
+		select {
+		case <-drained:
+		case <-time.After(10 * time.Second):
+			fmt.Println("⚠️  Upload rollback did not finish in time")
+		}
+	}
+	s.cancelUploads()
+
+	if s.httpServer == nil {
+		return nil
+	}
+
+	// Uploads are done; this only waits for other in-flight requests
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-
 	return s.httpServer.Shutdown(shutdownCtx)
 }
 
-// Stop stops the HTTP server
-func (s *Server) Stop() error {
-	if s.httpServer != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		return s.httpServer.Shutdown(ctx)
+// drainGuard tracks uploads for shutdown and rejects new ones while draining
+func (s *Server) drainGuard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		s.drainMu.Lock()
+		if s.draining {
+			s.drainMu.Unlock()
+			c.Header("Retry-After", "30")
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, ErrorResponse{
+				Error:   "Server shutting down",
+				Message: "Uploads are not accepted while the node drains; retry on another node",
+				Code:    "DRAINING",
+			})
+			return
+		}
+		if s.paused {
+			s.drainMu.Unlock()
+			c.Header("Retry-After", "60")
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, ErrorResponse{
+				Error:   "Node draining",
+				Message: "The operator has paused uploads on this node; retry on another node",
+				Code:    "DRAINING",
+			})
+			return
+		}
+		s.uploads.Add(1)
+		s.inFlight.Add(1)
+		s.drainMu.Unlock()
+
+		defer s.uploads.Done()
+		defer s.inFlight.Add(-1)
+		c.Next()
 	}
-	return nil
 }
 
 // getChunkKey generates a metadata key from userAddr and chunkID
@@ -0,0 +1,432 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// SessionPartSize is the maximum size of one uploaded part
+	SessionPartSize = 4 * 1024 * 1024
+
+	// SessionTTL is how long an unfinished upload session is kept
+	SessionTTL = time.Hour
+
+	// maxSessionSize matches the single-request upload limit
+	maxSessionSize = 100 * 1024 * 1024
+)
+
+// Upload session states
+const (
+	SessionReceiving = "receiving" // Accepting parts
+	SessionStoring   = "storing"   // Distributing shards
+	SessionCompleted = "completed"
+	SessionCancelled = "cancelled"
+	SessionFailed    = "failed"
+)
+
+// CreateSessionRequest starts a multi-part upload
+type CreateSessionRequest struct {
+	UserAddr  string `json:"userAddr" binding:"required"`
+	ChunkID   int    `json:"chunkID" binding:"required"`
+	TotalSize int    `json:"totalSize" binding:"required"` // Total bytes across all parts
+	Signature string `json:"signature"`                    // Optional: wallet signature for encryption
+	Password  string `json:"password"`                     // Optional: password for encryption
+	Encrypted bool   `json:"encrypted"`                    // Whether data is already client-encrypted
+}
+
+// SessionResponse reports the state and progress of an upload session
+type SessionResponse struct {
+	Success       bool            `json:"success"`
+	SessionID     string          `json:"sessionId"`
+	State         string          `json:"state"`
+	TotalSize     int             `json:"totalSize"`
+	ReceivedBytes int             `json:"receivedBytes"`
+	PartSize      int             `json:"partSize"`
+	PartCount     int             `json:"partCount"`
+	PartsReceived int             `json:"partsReceived"`
+	ShardsStored  int             `json:"shardsStored"`
+	ShardsTotal   int             `json:"shardsTotal"`
+	Progress      float64         `json:"progress"` // 0.0 - 1.0 across receiving and storing
+	Error         string          `json:"error,omitempty"`
+	ExpiresAt     time.Time       `json:"expiresAt"`
+	Result        *UploadResponse `json:"result,omitempty"`
+}
+
+// uploadSession is a multi-part upload in progress
+type uploadSession struct {
+	id        string
+	req       CreateSessionRequest
+	parts     map[int][]byte
+	received  int
+	state     string
+	errMsg    string
+	stored    int
+	total     int
+	expiresAt time.Time
+	cancel    context.CancelFunc // Set while storing
+	result    *UploadResponse
+	mu        sync.Mutex
+}
+
+// partCount returns the number of parts the session expects
+func (us *uploadSession) partCount() int {
+	return (us.req.TotalSize + SessionPartSize - 1) / SessionPartSize
+}
+
+// snapshotLocked returns the session's progress (caller holds us.mu)
+func (us *uploadSession) snapshotLocked() SessionResponse {
+	// Receiving counts for the first half of progress, shard distribution for the second
+	progress := 0.5 * float64(us.received) / float64(us.req.TotalSize)
+	if us.total > 0 {
+		progress += 0.5 * float64(us.stored) / float64(us.total)
+	}
+	if us.state == SessionCompleted {
+		progress = 1
+	}
+
+	return SessionResponse{
+		Success:       us.state != SessionFailed,
+		SessionID:     us.id,
+		State:         us.state,
+		TotalSize:     us.req.TotalSize,
+		ReceivedBytes: us.received,
+		PartSize:      SessionPartSize,
+		PartCount:     us.partCount(),
+		PartsReceived: len(us.parts),
+		ShardsStored:  us.stored,
+		ShardsTotal:   us.total,
+		Progress:      progress,
+		Error:         us.errMsg,
+		ExpiresAt:     us.expiresAt,
+		Result:        us.result,
+	}
+}
+
+// sessionStore keeps upload sessions in memory
+type sessionStore struct {
+	sessions map[string]*uploadSession
+	mu       sync.Mutex
+}
+
+// newSessionStore creates an empty session store
+func newSessionStore() *sessionStore {
+	return &sessionStore{
+		sessions: make(map[string]*uploadSession),
+	}
+}
+
+// add registers a session, dropping expired ones
+func (ss *sessionStore) add(session *uploadSession) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	now := time.Now()
+	for id, existing := range ss.sessions {
+		existing.mu.Lock()
+		expired := now.After(existing.expiresAt) && existing.state != SessionStoring
+		existing.mu.Unlock()
+		if expired {
+			delete(ss.sessions, id)
+		}
+	}
+
+	ss.sessions[session.id] = session
+}
+
+// get returns a session by ID
+func (ss *sessionStore) get(id string) (*uploadSession, bool) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	session, ok := ss.sessions[id]
+	return session, ok
+}
+
+// handleCreateSession handles POST /api/v1/storage/sessions
+func (s *Server) handleCreateSession(c *gin.Context) {
+	var req CreateSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if len(req.UserAddr) != 42 || req.UserAddr[:2] != "0x" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid user address",
+			Message: "User address must be a valid Ethereum address (0x...)",
+		})
+		return
+	}
+
+	if req.TotalSize <= 0 || req.TotalSize > maxSessionSize {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid size",
+			Message: fmt.Sprintf("totalSize must be between 1 byte and %d MB", maxSessionSize/(1024*1024)),
+		})
+		return
+	}
+
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Session creation failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	session := &uploadSession{
+		id:        hex.EncodeToString(idBytes),
+		req:       req,
+		parts:     make(map[int][]byte),
+		state:     SessionReceiving,
+		expiresAt: time.Now().Add(SessionTTL),
+	}
+	s.sessions.add(session)
+
+	fmt.Printf("📦 Upload session %s: user=%s chunk=%d size=%d bytes (%d parts)\n",
+		session.id, req.UserAddr, req.ChunkID, req.TotalSize, session.partCount())
+
+	session.mu.Lock()
+	response := session.snapshotLocked()
+	session.mu.Unlock()
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// handleUploadPart handles PUT /api/v1/storage/sessions/:sessionID/parts/:part
+// The body is the raw part bytes; parts are numbered from 0
+func (s *Server) handleUploadPart(c *gin.Context) {
+	session, ok := s.lookupSession(c)
+	if !ok {
+		return
+	}
+
+	part, err := strconv.Atoi(c.Param("part"))
+	if err != nil || part < 0 || part >= session.partCount() {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid part number",
+			Message: fmt.Sprintf("part must be between 0 and %d", session.partCount()-1),
+		})
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, SessionPartSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Failed to read part",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	// Every part is full-size except the last
+	expected := SessionPartSize
+	if part == session.partCount()-1 {
+		expected = session.req.TotalSize - part*SessionPartSize
+	}
+	if len(data) != expected {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid part size",
+			Message: fmt.Sprintf("part %d must be %d bytes, got %d", part, expected, len(data)),
+		})
+		return
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	if session.state != SessionReceiving {
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "Session not accepting parts",
+			Message: fmt.Sprintf("session is %s", session.state),
+		})
+		return
+	}
+
+	// Re-uploading a part replaces it (retries after a dropped connection)
+	if previous, exists := session.parts[part]; exists {
+		session.received -= len(previous)
+	}
+	session.parts[part] = data
+	session.received += len(data)
+
+	c.JSON(http.StatusOK, session.snapshotLocked())
+}
+
+// handleSessionStatus handles GET /api/v1/storage/sessions/:sessionID
+func (s *Server) handleSessionStatus(c *gin.Context) {
+	session, ok := s.lookupSession(c)
+	if !ok {
+		return
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	c.JSON(http.StatusOK, session.snapshotLocked())
+}
+
+// handleCompleteSession handles POST /api/v1/storage/sessions/:sessionID/complete
+// Assembles the parts and distributes the chunk; progress is visible via the status endpoint
+func (s *Server) handleCompleteSession(c *gin.Context) {
+	session, ok := s.lookupSession(c)
+	if !ok {
+		return
+	}
+
+	session.mu.Lock()
+	if session.state != SessionReceiving {
+		state := session.state
+		session.mu.Unlock()
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "Session cannot be completed",
+			Message: fmt.Sprintf("session is %s", state),
+		})
+		return
+	}
+	if len(session.parts) != session.partCount() {
+		missing := session.partCount() - len(session.parts)
+		session.mu.Unlock()
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Upload incomplete",
+			Message: fmt.Sprintf("%d parts missing", missing),
+		})
+		return
+	}
+
+	data := make([]byte, 0, session.req.TotalSize)
+	for i := 0; i < session.partCount(); i++ {
+		data = append(data, session.parts[i]...)
+	}
+	session.parts = make(map[int][]byte) // Free the copies; received stays for progress
+
+	ctx, cancel := context.WithTimeout(s.uploadCtx, 60*time.Second)
+	defer cancel()
+	session.state = SessionStoring
+	session.cancel = cancel
+	req := session.req
+	session.mu.Unlock()
+
+	dataToStore, encryptionInfo, isEncrypted, uerr := prepareUploadData(data, req.UserAddr, req.Signature, req.Password, req.Encrypted)
+	if uerr != nil {
+		s.failSession(session, uerr.response.Message)
+		c.JSON(uerr.status, uerr.response)
+		return
+	}
+
+	distributedChunk, err := s.distributedStore.StoreDistributedWithProgress(ctx, req.UserAddr, req.ChunkID, dataToStore,
+		func(stored, total int) {
+			session.mu.Lock()
+			session.stored = stored
+			session.total = total
+			session.mu.Unlock()
+		})
+	if err != nil {
+		session.mu.Lock()
+		cancelled := session.state == SessionCancelled
+		session.mu.Unlock()
+
+		if cancelled {
+			c.JSON(http.StatusConflict, ErrorResponse{
+				Error:   "Upload cancelled",
+				Message: "Partially stored shards were removed",
+			})
+			return
+		}
+
+		fmt.Printf("❌ Upload session %s failed: %v\n", session.id, err)
+		s.failSession(session, err.Error())
+		storeFailed(c, err)
+		return
+	}
+
+	session.mu.Lock()
+	if session.state == SessionCancelled {
+		// Cancelled just as the last shard landed; undo the store
+		session.mu.Unlock()
+		if err := s.distributedStore.DeleteChunk(context.Background(), req.UserAddr, req.ChunkID); err != nil {
+			fmt.Printf("⚠️  Failed to remove cancelled upload %s: %v\n", session.id, err)
+		}
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "Upload cancelled",
+			Message: "Stored shards were removed",
+		})
+		return
+	}
+	session.mu.Unlock()
+
+	s.storeChunkMetadata(distributedChunk)
+	response := buildUploadResponse(distributedChunk, len(data), len(dataToStore), isEncrypted, encryptionInfo)
+
+	session.mu.Lock()
+	session.state = SessionCompleted
+	session.cancel = nil
+	session.result = &response
+	session.mu.Unlock()
+
+	fmt.Printf("✅ Upload session %s complete: %d bytes → %d shards\n", session.id, len(data), len(response.ShardLocations))
+	c.JSON(http.StatusOK, response)
+}
+
+// handleCancelSession handles DELETE /api/v1/storage/sessions/:sessionID
+// A session that is distributing shards is aborted and its stored shards are removed
+func (s *Server) handleCancelSession(c *gin.Context) {
+	session, ok := s.lookupSession(c)
+	if !ok {
+		return
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	switch session.state {
+	case SessionCompleted:
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "Session already completed",
+			Message: "Use the delete endpoint to remove a stored chunk",
+		})
+		return
+	case SessionStoring:
+		session.cancel()
+	}
+
+	session.state = SessionCancelled
+	session.parts = make(map[int][]byte)
+
+	fmt.Printf("🚫 Upload session %s cancelled\n", session.id)
+	c.JSON(http.StatusOK, session.snapshotLocked())
+}
+
+// lookupSession finds the session named in the URL, writing a 404 if missing
+func (s *Server) lookupSession(c *gin.Context) (*uploadSession, bool) {
+	session, ok := s.sessions.get(c.Param("sessionID"))
+	if !ok {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Session not found",
+			Message: "Unknown or expired upload session",
+		})
+		return nil, false
+	}
+	return session, true
+}
+
+// failSession marks a session as failed
+func (s *Server) failSession(session *uploadSession, reason string) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.state = SessionFailed
+	session.errMsg = reason
+	session.cancel = nil
+}
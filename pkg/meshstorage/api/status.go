@@ -10,7 +10,6 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/ZentaChain/zentalk-node/pkg/crypto"
-	"github.com/ZentaChain/zentalk-node/pkg/meshstorage"
 )
 
 // StatusResponse represents storage status information
@@ -25,6 +24,10 @@ type StatusResponse struct {
 	TotalShards     int               `json:"totalShards"`
 	MinRequired     int               `json:"minRequiredShards"`
 	ShardStatus     []ShardStatusInfo `json:"shardStatus"`
+	PinnedShards    int               `json:"pinnedShards,omitempty"` // Available shards on the user's pinned nodes
+	PinRequired     int               `json:"pinRequired,omitempty"`  // Shards the pinned nodes must hold (0 = no pins)
+	PinViolation    bool              `json:"pinViolation,omitempty"` // PinnedShards < PinRequired
+	LeaseExpires    *time.Time        `json:"leaseExpires,omitempty"` // When nodes may drop the chunk unless its lease is renewed
 	CheckedAt       time.Time         `json:"checkedAt"`
 }
 
@@ -33,6 +36,7 @@ type ShardStatusInfo struct {
 	ShardIndex int    `json:"shardIndex"`
 	Available  bool   `json:"available"`
 	NodeID     string `json:"nodeId,omitempty"`
+	Pinned     bool   `json:"pinned,omitempty"` // On one of the user's pinned nodes
 	Error      string `json:"error,omitempty"`
 }
 
@@ -96,14 +100,17 @@ func (s *Server) handleStatus(c *gin.Context) {
 
 	for i, available := range shardStatus {
 		nodeID := ""
+		pinned := false
 		if i < len(chunk.ShardLocations) {
 			nodeID = chunk.ShardLocations[i].PeerID.String()
+			pinned = s.distributedStore.IsPinned(userAddr, chunk.ShardLocations[i].PeerID)
 		}
 
 		shardStatusList[i] = ShardStatusInfo{
 			ShardIndex: i,
 			Available:  available,
 			NodeID:     nodeID,
+			Pinned:     pinned,
 		}
 
 		if available {
@@ -111,20 +118,24 @@ func (s *Server) handleStatus(c *gin.Context) {
 		}
 	}
 
-	// Calculate health using constants
-	totalShards := meshstorage.TotalShards
-	minRequired := meshstorage.MinShardsForRecovery
+	// Calculate health against the coding the chunk was stored with
+	erasure := chunk.Coding()
+	totalShards := erasure.TotalShards()
+	minRequired := erasure.MinRecovery
 	healthScore := float64(availableCount) / float64(totalShards)
+	excellent, good, _, _ := erasure.HealthThresholds()
+
+	pinnedShards, pinRequired := s.distributedStore.PinStatus(chunk, shardStatus)
 
 	var health string
 	switch {
-	case availableCount >= meshstorage.HealthExcellent:
+	case availableCount >= excellent:
 		health = "excellent" // All shards available
-	case availableCount >= meshstorage.HealthGood:
+	case availableCount >= good:
 		health = "good" // Some redundancy lost
-	case availableCount >= meshstorage.MinShardsForRecovery:
+	case availableCount >= erasure.DataShards:
 		health = "degraded" // Minimal redundancy
-	case availableCount >= 8:
+	case availableCount >= erasure.DataShards*4/5:
 		health = "critical" // Below minimum, but might still recover
 	default:
 		health = "lost" // Cannot recover data
@@ -141,6 +152,10 @@ func (s *Server) handleStatus(c *gin.Context) {
 		TotalShards:     totalShards,
 		MinRequired:     minRequired,
 		ShardStatus:     shardStatusList,
+		PinnedShards:    pinnedShards,
+		PinRequired:     pinRequired,
+		PinViolation:    pinnedShards < pinRequired,
+		LeaseExpires:    leaseExpiresPtr(chunk),
 		CheckedAt:       time.Now(),
 	}
 
@@ -229,6 +244,11 @@ func (s *Server) handleDelete(c *gin.Context) {
 		s.deleteChunkMetadata(userAddr, chunkID)
 	}
 
+	// Access grants are meaningless once the chunk is gone
+	if err := s.node.Storage().DeleteGrantsForChunk(userAddr, chunkID); err != nil {
+		fmt.Printf("⚠️  Failed to remove grants for deleted chunk: %v\n", err)
+	}
+
 	fmt.Printf("✅ Deleted successfully from all shard nodes\n")
 
 	c.JSON(http.StatusOK, SuccessResponse{
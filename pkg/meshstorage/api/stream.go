@@ -0,0 +1,460 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// StreamSegmentSize is how many bytes of a streamed upload are stored as one chunk
+	StreamSegmentSize = 8 * 1024 * 1024
+
+	// maxStreamSize caps a streamed upload (sizes are ints, so 32-bit builds stop at 2 GB)
+	maxStreamSize = min(4*1024*1024*1024, math.MaxInt)
+
+	// streamIdleTimeout is how long a stream write may go without receiving bytes
+	streamIdleTimeout = 30 * time.Second
+
+	// streamReadSize is how much of the request body is read at a time
+	streamReadSize = 64 * 1024
+)
+
+// StreamSegment is one stored chunk of a streamed upload
+type StreamSegment struct {
+	ChunkID    int `json:"chunkID"`
+	Size       int `json:"sizeBytes"` // Bytes of the file in this chunk, before encryption
+	ShardCount int `json:"shardCount"`
+}
+
+// StreamResponse reports the state of a streamed upload
+// Offset is where the next write must start; a client whose connection dropped
+// fetches it and resumes from there.
+type StreamResponse struct {
+	Success      bool            `json:"success"`
+	SessionID    string          `json:"sessionId"`
+	State        string          `json:"state"`
+	TotalSize    int             `json:"totalSize"`
+	Offset       int             `json:"offset"`
+	SegmentSize  int             `json:"segmentSize"`
+	SegmentCount int             `json:"segmentCount"`
+	Segments     []StreamSegment `json:"segments"` // Stored so far, in file order
+	Progress     float64         `json:"progress"` // 0.0 - 1.0 of segments stored
+	Error        string          `json:"error,omitempty"`
+	ExpiresAt    time.Time       `json:"expiresAt"`
+}
+
+// streamSession is a streamed upload in progress
+// Only the current segment is held in memory; full segments are stored as
+// chunks ChunkID, ChunkID+1, ... as soon as they fill.
+type streamSession struct {
+	id          string
+	req         CreateSessionRequest
+	segmentSize int
+	buf         []byte // Current segment; owned by the writer while writing is set
+	received    int
+	segments    []StreamSegment
+	state       string
+	errMsg      string
+	writing     bool
+	cancel      context.CancelFunc // Set while writing
+	expiresAt   time.Time
+	mu          sync.Mutex
+}
+
+// segmentCount returns the number of chunks the upload will be stored as
+func (ss *streamSession) segmentCount() int {
+	return (ss.req.TotalSize + ss.segmentSize - 1) / ss.segmentSize
+}
+
+// snapshotLocked returns the stream's progress (caller holds ss.mu)
+func (ss *streamSession) snapshotLocked() StreamResponse {
+	segments := make([]StreamSegment, len(ss.segments))
+	copy(segments, ss.segments)
+
+	return StreamResponse{
+		Success:      ss.state != SessionFailed,
+		SessionID:    ss.id,
+		State:        ss.state,
+		TotalSize:    ss.req.TotalSize,
+		Offset:       ss.received,
+		SegmentSize:  ss.segmentSize,
+		SegmentCount: ss.segmentCount(),
+		Segments:     segments,
+		Progress:     float64(len(ss.segments)) / float64(ss.segmentCount()),
+		Error:        ss.errMsg,
+		ExpiresAt:    ss.expiresAt,
+	}
+}
+
+// streamStore keeps streamed uploads in memory
+type streamStore struct {
+	streams map[string]*streamSession
+	mu      sync.Mutex
+}
+
+// newStreamStore creates an empty stream store
+func newStreamStore() *streamStore {
+	return &streamStore{
+		streams: make(map[string]*streamSession),
+	}
+}
+
+// add registers a stream, dropping expired ones
+// Abandoned streams are passed to onExpire so their stored segments can be removed.
+func (st *streamStore) add(stream *streamSession, onExpire func(*streamSession)) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	now := time.Now()
+	for id, existing := range st.streams {
+		existing.mu.Lock()
+		expired := now.After(existing.expiresAt) && !existing.writing
+		abandoned := expired && existing.state == SessionReceiving && len(existing.segments) > 0
+		if abandoned {
+			existing.state = SessionCancelled
+		}
+		existing.mu.Unlock()
+
+		if expired {
+			delete(st.streams, id)
+		}
+		if abandoned {
+			go onExpire(existing)
+		}
+	}
+
+	st.streams[stream.id] = stream
+}
+
+// get returns a stream by ID
+func (st *streamStore) get(id string) (*streamSession, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	stream, ok := st.streams[id]
+	return stream, ok
+}
+
+// handleCreateStream handles POST /api/v1/storage/upload/stream
+// Takes the same body as a multi-part session; the file is stored as
+// segmentCount chunks starting at chunkID
+func (s *Server) handleCreateStream(c *gin.Context) {
+	var req CreateSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if len(req.UserAddr) != 42 || req.UserAddr[:2] != "0x" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid user address",
+			Message: "User address must be a valid Ethereum address (0x...)",
+		})
+		return
+	}
+
+	if req.TotalSize <= 0 || req.TotalSize > maxStreamSize {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid size",
+			Message: fmt.Sprintf("totalSize must be between 1 byte and %d MB", maxStreamSize/(1024*1024)),
+		})
+		return
+	}
+
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Session creation failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	stream := &streamSession{
+		id:          hex.EncodeToString(idBytes),
+		req:         req,
+		segmentSize: s.segmentSize,
+		state:       SessionReceiving,
+		expiresAt:   time.Now().Add(SessionTTL),
+	}
+	s.streams.add(stream, s.removeStreamSegments)
+
+	fmt.Printf("📦 Upload stream %s: user=%s chunks=%d+ size=%d bytes (%d segments)\n",
+		stream.id, req.UserAddr, req.ChunkID, req.TotalSize, stream.segmentCount())
+
+	stream.mu.Lock()
+	response := stream.snapshotLocked()
+	stream.mu.Unlock()
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// handleStreamWrite handles PATCH /api/v1/storage/upload/stream/:sessionID?offset=N
+// The body is raw file bytes starting at offset, which must equal the stream's
+// current offset. Bytes received before a dropped connection are kept, so the
+// client resumes from the offset the status endpoint reports.
+func (s *Server) handleStreamWrite(c *gin.Context) {
+	stream, ok := s.lookupStream(c)
+	if !ok {
+		return
+	}
+
+	offset, err := strconv.Atoi(c.Query("offset"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid offset",
+			Message: "offset query parameter is required",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithCancel(s.uploadCtx)
+	defer cancel()
+
+	stream.mu.Lock()
+	switch {
+	case stream.state != SessionReceiving:
+		state := stream.state
+		stream.mu.Unlock()
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "Stream not accepting data",
+			Message: fmt.Sprintf("stream is %s", state),
+		})
+		return
+	case stream.writing:
+		stream.mu.Unlock()
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "Stream busy",
+			Message: "Another request is writing to this stream",
+		})
+		return
+	case offset != stream.received:
+		response := stream.snapshotLocked()
+		stream.mu.Unlock()
+		c.JSON(http.StatusConflict, response)
+		return
+	}
+	stream.writing = true
+	stream.cancel = cancel
+	stream.mu.Unlock()
+
+	defer func() {
+		stream.mu.Lock()
+		stream.writing = false
+		stream.cancel = nil
+		stream.expiresAt = time.Now().Add(SessionTTL)
+		stream.mu.Unlock()
+	}()
+
+	// The server's read timeout would cut long uploads off; only idle connections are dropped
+	rc := http.NewResponseController(c.Writer)
+	chunk := make([]byte, streamReadSize)
+	for {
+		// A segment that failed to store is retried before more data is read
+		if err := s.flushStream(ctx, stream); err != nil {
+			s.respondStream(c, rc, stream, err)
+			return
+		}
+		if stream.received == stream.req.TotalSize || ctx.Err() != nil {
+			break
+		}
+
+		want := min(len(chunk), stream.segmentSize-len(stream.buf), stream.req.TotalSize-stream.received)
+		rc.SetReadDeadline(time.Now().Add(streamIdleTimeout))
+		n, err := c.Request.Body.Read(chunk[:want])
+		if n > 0 {
+			stream.buf = append(stream.buf, chunk[:n]...)
+			stream.mu.Lock()
+			stream.received += n
+			stream.mu.Unlock()
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// Connection dropped; what arrived is kept for the resume
+			fmt.Printf("⚠️  Upload stream %s interrupted at %d bytes: %v\n", stream.id, stream.received, err)
+			break
+		}
+	}
+
+	if stream.received == stream.req.TotalSize {
+		if n, _ := c.Request.Body.Read(chunk[:1]); n > 0 {
+			rc.SetWriteDeadline(time.Now().Add(streamIdleTimeout))
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Body too long",
+				Message: fmt.Sprintf("data runs past totalSize (%d bytes)", stream.req.TotalSize),
+			})
+			return
+		}
+	}
+
+	s.respondStream(c, rc, stream, nil)
+}
+
+// flushStream stores the current segment once it is full, or once the upload is complete
+func (s *Server) flushStream(ctx context.Context, stream *streamSession) error {
+	last := stream.received == stream.req.TotalSize
+	if len(stream.buf) == 0 || (len(stream.buf) < stream.segmentSize && !last) {
+		return nil
+	}
+
+	stream.mu.Lock()
+	index := len(stream.segments)
+	stream.mu.Unlock()
+
+	req := stream.req
+	chunkID := req.ChunkID + index
+
+	dataToStore, _, _, uerr := prepareUploadData(stream.buf, req.UserAddr, req.Signature, req.Password, req.Encrypted)
+	if uerr != nil {
+		return fmt.Errorf("%s", uerr.response.Message)
+	}
+
+	storeCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	distributedChunk, err := s.distributedStore.StoreDistributed(storeCtx, req.UserAddr, chunkID, dataToStore)
+	if err != nil {
+		return fmt.Errorf("failed to store segment %d: %w", index, err)
+	}
+
+	stream.mu.Lock()
+	if stream.state == SessionCancelled {
+		// Cancelled while this segment was being stored; undo it
+		stream.mu.Unlock()
+		if err := s.distributedStore.DeleteChunk(context.Background(), req.UserAddr, chunkID); err != nil {
+			fmt.Printf("⚠️  Failed to remove cancelled stream segment %s/%d: %v\n", stream.id, index, err)
+		}
+		return fmt.Errorf("stream cancelled")
+	}
+	stream.segments = append(stream.segments, StreamSegment{
+		ChunkID:    chunkID,
+		Size:       len(stream.buf),
+		ShardCount: len(distributedChunk.ShardLocations),
+	})
+	if last {
+		stream.state = SessionCompleted
+	}
+	stream.mu.Unlock()
+
+	s.storeChunkMetadata(distributedChunk)
+	stream.buf = stream.buf[:0]
+
+	if last {
+		fmt.Printf("✅ Upload stream %s complete: %d bytes → %d chunks\n", stream.id, req.TotalSize, index+1)
+	}
+	return nil
+}
+
+// respondStream writes the stream's state, or the error that stopped a write
+func (s *Server) respondStream(c *gin.Context, rc *http.ResponseController, stream *streamSession, err error) {
+	// Storing segments can outlast the server's write timeout
+	rc.SetWriteDeadline(time.Now().Add(streamIdleTimeout))
+
+	stream.mu.Lock()
+	if err != nil && stream.state != SessionCancelled {
+		stream.errMsg = err.Error()
+	} else if err == nil {
+		stream.errMsg = ""
+	}
+	response := stream.snapshotLocked()
+	state := stream.state
+	stream.mu.Unlock()
+
+	switch {
+	case state == SessionCancelled:
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "Upload cancelled",
+			Message: "Stored segments were removed",
+		})
+	case err != nil:
+		fmt.Printf("❌ Upload stream %s: %v\n", stream.id, err)
+		c.JSON(storeStatus(err), response)
+	default:
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// handleStreamStatus handles GET /api/v1/storage/upload/stream/:sessionID
+func (s *Server) handleStreamStatus(c *gin.Context) {
+	stream, ok := s.lookupStream(c)
+	if !ok {
+		return
+	}
+
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	c.JSON(http.StatusOK, stream.snapshotLocked())
+}
+
+// handleCancelStream handles DELETE /api/v1/storage/upload/stream/:sessionID
+// Segments already stored are removed
+func (s *Server) handleCancelStream(c *gin.Context) {
+	stream, ok := s.lookupStream(c)
+	if !ok {
+		return
+	}
+
+	stream.mu.Lock()
+	if stream.state == SessionCompleted {
+		stream.mu.Unlock()
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "Stream already completed",
+			Message: "Use the delete endpoint to remove stored chunks",
+		})
+		return
+	}
+	stream.state = SessionCancelled
+	if stream.cancel != nil {
+		stream.cancel()
+	}
+	response := stream.snapshotLocked()
+	stream.mu.Unlock()
+
+	s.removeStreamSegments(stream)
+
+	fmt.Printf("🚫 Upload stream %s cancelled\n", stream.id)
+	c.JSON(http.StatusOK, response)
+}
+
+// removeStreamSegments deletes the chunks a cancelled or abandoned stream stored
+func (s *Server) removeStreamSegments(stream *streamSession) {
+	stream.mu.Lock()
+	segments := stream.segments
+	stream.segments = nil
+	stream.mu.Unlock()
+
+	for _, segment := range segments {
+		if err := s.distributedStore.DeleteChunk(context.Background(), stream.req.UserAddr, segment.ChunkID); err != nil {
+			fmt.Printf("⚠️  Failed to remove stream segment %s/%d: %v\n", stream.id, segment.ChunkID, err)
+			continue
+		}
+		s.deleteChunkMetadata(stream.req.UserAddr, segment.ChunkID)
+	}
+}
+
+// lookupStream finds the stream named in the URL, writing a 404 if missing
+func (s *Server) lookupStream(c *gin.Context) (*streamSession, bool) {
+	stream, ok := s.streams.get(c.Param("sessionID"))
+	if !ok {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Session not found",
+			Message: "Unknown or expired upload stream",
+		})
+		return nil, false
+	}
+	return stream, true
+}
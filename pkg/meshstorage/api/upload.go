@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -22,6 +23,7 @@ type UploadRequest struct {
 	Signature string `json:"signature"`                    // Optional: wallet signature for encryption
 	Password  string `json:"password"`                     // Optional: password for encryption
 	Encrypted bool   `json:"encrypted"`                    // Whether data is already client-encrypted
+	LeaseSeconds int64 `json:"leaseSeconds"`               // Optional: nodes may drop the data this long after upload (0 = no lease)
 }
 
 // UploadResponse represents a successful upload response
@@ -40,6 +42,7 @@ type UploadResponse struct {
 	EncryptionInfo string            `json:"encryptionInfo"`
 	UploadedAt     time.Time         `json:"uploadedAt"`
 	ShardLocations []ShardLocationInfo `json:"shardLocations"`
+	LeaseExpires   *time.Time        `json:"leaseExpires,omitempty"` // When the lease runs out unless renewed
 }
 
 // ShardLocationInfo contains info about where a shard is stored
@@ -81,6 +84,15 @@ func (s *Server) handleUpload(c *gin.Context) {
 		return
 	}
 
+	lease, ok := leaseDuration(req.LeaseSeconds)
+	if !ok {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid lease",
+			Message: "leaseSeconds must not be negative",
+		})
+		return
+	}
+
 	// Validate data size (max 100MB)
 	if len(data) == 0 {
 		c.JSON(http.StatusBadRequest, ErrorResponse{
@@ -100,46 +112,170 @@ func (s *Server) handleUpload(c *gin.Context) {
 	}
 
 	// Encryption: Encrypt data before storage if not already encrypted
+	originalSize := len(data)
+	dataToStore, encryptionInfo, isEncrypted, uerr := prepareUploadData(data, req.UserAddr, req.Signature, req.Password, req.Encrypted)
+	if uerr != nil {
+		c.JSON(uerr.status, uerr.response)
+		return
+	}
+
+	// Log upload
+	fmt.Printf("📤 Upload request: user=%s chunk=%d size=%d bytes (encrypted: %v)\n",
+		req.UserAddr, req.ChunkID, len(dataToStore), isEncrypted)
+
+	// Store encrypted data in distributed storage
+	ctx, cancel := context.WithTimeout(s.uploadCtx, 60*time.Second)
+	defer cancel()
+
+	startTime := time.Now()
+
+	distributedChunk, err := s.distributedStore.StoreDistributedWithLease(
+		ctx,
+		req.UserAddr,
+		req.ChunkID,
+		dataToStore,
+		lease,
+	)
+
+	if err != nil {
+		fmt.Printf("❌ Upload failed: %v\n", err)
+		storeFailed(c, err)
+		return
+	}
+
+	uploadDuration := time.Since(startTime)
+
+	// Store chunk metadata for later retrieval
+	s.storeChunkMetadata(distributedChunk)
+
+	response := buildUploadResponse(distributedChunk, originalSize, len(dataToStore), isEncrypted, encryptionInfo)
+
+	fmt.Printf("✅ Upload successful: %d bytes (encrypted: %d bytes) → %d shards across %d nodes (%.2fs)\n",
+		originalSize,
+		len(dataToStore),
+		len(response.ShardLocations),
+		len(response.StorageNodes),
+		uploadDuration.Seconds(),
+	)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// buildUploadResponse describes a stored chunk to the client
+func buildUploadResponse(distributedChunk *meshstorage.DistributedChunk, originalSize, encryptedSize int, isEncrypted bool, encryptionInfo string) UploadResponse {
+	// Build shard location info
+	shardLocations := make([]ShardLocationInfo, len(distributedChunk.ShardLocations))
+	nodeIDs := make([]string, 0)
+	nodeIDMap := make(map[string]bool)
+
+	for i, loc := range distributedChunk.ShardLocations {
+		nodeIDStr := loc.PeerID.String()
+		shardLocations[i] = ShardLocationInfo{
+			ShardIndex: loc.ShardIndex,
+			NodeID:     nodeIDStr,
+			Addresses:  loc.PeerAddrs,
+		}
+
+		// Collect unique node IDs
+		if !nodeIDMap[nodeIDStr] {
+			nodeIDs = append(nodeIDs, nodeIDStr)
+			nodeIDMap[nodeIDStr] = true
+		}
+	}
+
+	// Calculate redundancy and fault tolerance using constants
+	redundancy := meshstorage.CalculateRedundancy()
+	faultTolerance := meshstorage.CalculateFaultTolerance()
+
+	return UploadResponse{
+		Success:        true,
+		UserAddr:       distributedChunk.UserAddr,
+		ChunkID:        distributedChunk.ChunkID,
+		OriginalSize:   originalSize,
+		EncryptedSize:  encryptedSize,
+		ShardCount:     len(distributedChunk.ShardLocations),
+		ShardSize:      distributedChunk.ShardSize,
+		StorageNodes:   nodeIDs,
+		Redundancy:     redundancy,
+		FaultTolerance: faultTolerance,
+		Encrypted:      isEncrypted,
+		EncryptionInfo: encryptionInfo,
+		UploadedAt:     time.Now(),
+		ShardLocations: shardLocations,
+		LeaseExpires:   leaseExpiresPtr(distributedChunk),
+	}
+}
+
+// storeStatus is the HTTP status of a failed store: 413 when this or another node is out of quota
+func storeStatus(err error) int {
+	if errors.Is(err, meshstorage.ErrQuotaExceeded) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusInternalServerError
+}
+
+// storeFailed responds to an upload whose data could not be stored
+func storeFailed(c *gin.Context, err error) {
+	if status := storeStatus(err); status == http.StatusRequestEntityTooLarge {
+		c.JSON(status, ErrorResponse{
+			Error:   "Storage quota exceeded",
+			Message: err.Error(),
+			Code:    "QUOTA_EXCEEDED",
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, ErrorResponse{
+		Error:   "Storage failed",
+		Message: err.Error(),
+	})
+}
+
+// uploadError is an HTTP error produced while preparing upload data
+type uploadError struct {
+	status   int
+	response ErrorResponse
+}
+
+// prepareUploadData encrypts upload data for storage unless the client already encrypted it
+// Returns the bytes to store, a description of the encryption and whether the data is encrypted
+func prepareUploadData(data []byte, userAddr, signature, password string, clientEncrypted bool) ([]byte, string, bool, *uploadError) {
 	var dataToStore []byte
 	var encryptionInfo string
 	var isEncrypted bool
 	originalSize := len(data)
 
-	if !req.Encrypted {
+	if !clientEncrypted {
 		// Data needs to be encrypted on server-side
 		var encryptionKey *meshstorage.EncryptionKey
 		var err error
 
-		if req.Signature != "" {
+		if signature != "" {
 			// Derive key from wallet signature (most secure)
-			encryptionKey, err = meshstorage.DeriveKeyFromSignature(req.Signature)
+			encryptionKey, err = meshstorage.DeriveKeyFromSignature(signature)
 			if err != nil {
-				c.JSON(http.StatusBadRequest, ErrorResponse{
+				return nil, "", false, &uploadError{http.StatusBadRequest, ErrorResponse{
 					Error:   "Invalid signature",
 					Message: fmt.Sprintf("Failed to derive key from signature: %v", err),
-				})
-				return
+				}}
 			}
 			encryptionInfo = "AES-256-GCM (signature-derived)"
-		} else if req.Password != "" {
+		} else if password != "" {
 			// Use password-based encryption
-			encrypted, err := meshstorage.EncryptWithPassword(data, req.Password)
+			encrypted, err := meshstorage.EncryptWithPassword(data, password)
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, ErrorResponse{
+				return nil, "", false, &uploadError{http.StatusInternalServerError, ErrorResponse{
 					Error:   "Encryption failed",
 					Message: err.Error(),
-				})
-				return
+				}}
 			}
 
 			// Convert encrypted data to JSON for storage
 			encryptedJSON, err := json.Marshal(encrypted)
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, ErrorResponse{
+				return nil, "", false, &uploadError{http.StatusInternalServerError, ErrorResponse{
 					Error:   "Serialization failed",
 					Message: err.Error(),
-				})
-				return
+				}}
 			}
 
 			dataToStore = encryptedJSON
@@ -147,13 +283,12 @@ func (s *Server) handleUpload(c *gin.Context) {
 			isEncrypted = true
 		} else {
 			// Default: Use wallet address for key derivation
-			encryptionKey, err = meshstorage.DeriveKeyFromWalletAddress(req.UserAddr)
+			encryptionKey, err = meshstorage.DeriveKeyFromWalletAddress(userAddr)
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, ErrorResponse{
+				return nil, "", false, &uploadError{http.StatusInternalServerError, ErrorResponse{
 					Error:   "Key derivation failed",
 					Message: err.Error(),
-				})
-				return
+				}}
 			}
 			encryptionInfo = "AES-256-GCM (wallet-derived)"
 		}
@@ -162,21 +297,19 @@ func (s *Server) handleUpload(c *gin.Context) {
 		if encryptionKey != nil {
 			encrypted, err := meshstorage.Encrypt(data, encryptionKey)
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, ErrorResponse{
+				return nil, "", false, &uploadError{http.StatusInternalServerError, ErrorResponse{
 					Error:   "Encryption failed",
 					Message: err.Error(),
-				})
-				return
+				}}
 			}
 
 			// Convert encrypted data to JSON for storage
 			encryptedJSON, err := json.Marshal(encrypted)
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, ErrorResponse{
+				return nil, "", false, &uploadError{http.StatusInternalServerError, ErrorResponse{
 					Error:   "Serialization failed",
 					Message: err.Error(),
-				})
-				return
+				}}
 			}
 
 			dataToStore = encryptedJSON
@@ -193,87 +326,7 @@ func (s *Server) handleUpload(c *gin.Context) {
 		fmt.Printf("🔒 Storing client-encrypted data: %d bytes\n", len(dataToStore))
 	}
 
-	// Log upload
-	fmt.Printf("📤 Upload request: user=%s chunk=%d size=%d bytes (encrypted: %v)\n",
-		req.UserAddr, req.ChunkID, len(dataToStore), isEncrypted)
-
-	// Store encrypted data in distributed storage
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancel()
-
-	startTime := time.Now()
-
-	distributedChunk, err := s.distributedStore.StoreDistributed(
-		ctx,
-		req.UserAddr,
-		req.ChunkID,
-		dataToStore,
-	)
-
-	if err != nil {
-		fmt.Printf("❌ Upload failed: %v\n", err)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "Storage failed",
-			Message: err.Error(),
-		})
-		return
-	}
-
-	uploadDuration := time.Since(startTime)
-
-	// Store chunk metadata for later retrieval
-	s.storeChunkMetadata(distributedChunk)
-
-	// Build shard location info
-	shardLocations := make([]ShardLocationInfo, len(distributedChunk.ShardLocations))
-	nodeIDs := make([]string, 0)
-	nodeIDMap := make(map[string]bool)
-
-	for i, loc := range distributedChunk.ShardLocations {
-		nodeIDStr := loc.PeerID.String()
-		shardLocations[i] = ShardLocationInfo{
-			ShardIndex: loc.ShardIndex,
-			NodeID:     nodeIDStr,
-			Addresses:  loc.PeerAddrs,
-		}
-
-		// Collect unique node IDs
-		if !nodeIDMap[nodeIDStr] {
-			nodeIDs = append(nodeIDs, nodeIDStr)
-			nodeIDMap[nodeIDStr] = true
-		}
-	}
-
-	// Calculate redundancy and fault tolerance using constants
-	redundancy := meshstorage.CalculateRedundancy()
-	faultTolerance := meshstorage.CalculateFaultTolerance()
-
-	response := UploadResponse{
-		Success:        true,
-		UserAddr:       req.UserAddr,
-		ChunkID:        req.ChunkID,
-		OriginalSize:   originalSize,
-		EncryptedSize:  len(dataToStore),
-		ShardCount:     len(distributedChunk.ShardLocations),
-		ShardSize:      distributedChunk.ShardSize,
-		StorageNodes:   nodeIDs,
-		Redundancy:     redundancy,
-		FaultTolerance: faultTolerance,
-		Encrypted:      isEncrypted,
-		EncryptionInfo: encryptionInfo,
-		UploadedAt:     time.Now(),
-		ShardLocations: shardLocations,
-	}
-
-	fmt.Printf("✅ Upload successful: %d bytes (encrypted: %d bytes) → %d shards across %d nodes (%.2fs)\n",
-		originalSize,
-		len(dataToStore),
-		len(shardLocations),
-		len(nodeIDs),
-		uploadDuration.Seconds(),
-	)
-
-	c.JSON(http.StatusOK, response)
+	return dataToStore, encryptionInfo, isEncrypted, nil
 }
 
 // handleUploadMultipart handles multipart file uploads
@@ -300,6 +353,22 @@ func (s *Server) handleUploadMultipart(c *gin.Context) {
 		return
 	}
 
+	var lease time.Duration
+	if leaseStr := c.PostForm("leaseSeconds"); leaseStr != "" {
+		seconds, err := strconv.ParseInt(leaseStr, 10, 64)
+		valid := err == nil
+		if valid {
+			lease, valid = leaseDuration(seconds)
+		}
+		if !valid {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid lease",
+				Message: "leaseSeconds must be a non-negative number",
+			})
+			return
+		}
+	}
+
 	// Get uploaded file
 	file, err := c.FormFile("file")
 	if err != nil {
@@ -389,21 +458,19 @@ func (s *Server) handleUploadMultipart(c *gin.Context) {
 		originalSize, len(encryptedJSON))
 
 	// Store encrypted data using distributed storage
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	ctx, cancel := context.WithTimeout(s.uploadCtx, 60*time.Second)
 	defer cancel()
 
-	distributedChunk, err := s.distributedStore.StoreDistributed(
+	distributedChunk, err := s.distributedStore.StoreDistributedWithLease(
 		ctx,
 		userAddr,
 		chunkID,
 		encryptedJSON,
+		lease,
 	)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "Storage failed",
-			Message: err.Error(),
-		})
+		storeFailed(c, err)
 		return
 	}
 
@@ -434,6 +501,7 @@ func (s *Server) handleUploadMultipart(c *gin.Context) {
 		EncryptionInfo: "AES-256-GCM (wallet-derived)",
 		UploadedAt:     time.Now(),
 		ShardLocations: shardLocations,
+		LeaseExpires:   leaseExpiresPtr(distributedChunk),
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -0,0 +1,199 @@
+// Package meshstorage provides distributed storage for ZenTalk encrypted chat history
+package meshstorage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/control"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// BlockedPeer is a peer an operator has banned from this node
+type BlockedPeer struct {
+	ID        peer.ID `json:"id"`
+	Reason    string  `json:"reason,omitempty"`
+	BlockedAt int64   `json:"blockedAt"`
+}
+
+// peerBlocklist is a libp2p connection gater that refuses blocked peers
+// Entries are persisted as JSON in the data directory so bans survive restarts
+type peerBlocklist struct {
+	mu    sync.RWMutex
+	path  string // Empty disables persistence
+	peers map[peer.ID]BlockedPeer
+}
+
+// loadPeerBlocklist reads the blocklist at path; a missing file is an empty list
+func loadPeerBlocklist(path string) (*peerBlocklist, error) {
+	bl := &peerBlocklist{
+		path:  path,
+		peers: make(map[peer.ID]BlockedPeer),
+	}
+	if path == "" {
+		return bl, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return bl, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blocklist: %w", err)
+	}
+
+	var entries []BlockedPeer
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse blocklist %s: %w", path, err)
+	}
+	for _, entry := range entries {
+		bl.peers[entry.ID] = entry
+	}
+	return bl, nil
+}
+
+// blocked reports whether a peer is on the list
+func (bl *peerBlocklist) blocked(id peer.ID) bool {
+	bl.mu.RLock()
+	defer bl.mu.RUnlock()
+	_, ok := bl.peers[id]
+	return ok
+}
+
+// list returns the blocked peers, oldest ban first
+func (bl *peerBlocklist) list() []BlockedPeer {
+	bl.mu.RLock()
+	entries := make([]BlockedPeer, 0, len(bl.peers))
+	for _, entry := range bl.peers {
+		entries = append(entries, entry)
+	}
+	bl.mu.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].BlockedAt != entries[j].BlockedAt {
+			return entries[i].BlockedAt < entries[j].BlockedAt
+		}
+		return entries[i].ID < entries[j].ID
+	})
+	return entries
+}
+
+// add bans a peer and persists the list
+func (bl *peerBlocklist) add(id peer.ID, reason string) error {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	bl.peers[id] = BlockedPeer{ID: id, Reason: reason, BlockedAt: time.Now().Unix()}
+	return bl.saveLocked()
+}
+
+// remove lifts a ban, reporting whether the peer was blocked
+func (bl *peerBlocklist) remove(id peer.ID) (bool, error) {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	if _, ok := bl.peers[id]; !ok {
+		return false, nil
+	}
+	delete(bl.peers, id)
+	return true, bl.saveLocked()
+}
+
+// saveLocked writes the list atomically; callers hold bl.mu
+func (bl *peerBlocklist) saveLocked() error {
+	if bl.path == "" {
+		return nil
+	}
+
+	entries := make([]BlockedPeer, 0, len(bl.peers))
+	for _, entry := range bl.peers {
+		entries = append(entries, entry)
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode blocklist: %w", err)
+	}
+
+	tmp := bl.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write blocklist: %w", err)
+	}
+	if err := os.Rename(tmp, bl.path); err != nil {
+		return fmt.Errorf("failed to write blocklist: %w", err)
+	}
+	return nil
+}
+
+// InterceptPeerDial refuses outbound dials to blocked peers
+func (bl *peerBlocklist) InterceptPeerDial(p peer.ID) bool {
+	return !bl.blocked(p)
+}
+
+// InterceptAddrDial refuses outbound dials to blocked peers
+func (bl *peerBlocklist) InterceptAddrDial(p peer.ID, _ multiaddr.Multiaddr) bool {
+	return !bl.blocked(p)
+}
+
+// InterceptAccept allows every inbound connection; the peer is not known yet
+func (bl *peerBlocklist) InterceptAccept(network.ConnMultiaddrs) bool {
+	return true
+}
+
+// InterceptSecured refuses blocked peers once their identity is authenticated
+func (bl *peerBlocklist) InterceptSecured(_ network.Direction, p peer.ID, _ network.ConnMultiaddrs) bool {
+	return !bl.blocked(p)
+}
+
+// InterceptUpgraded allows connections that passed InterceptSecured
+func (bl *peerBlocklist) InterceptUpgraded(network.Conn) (bool, control.DisconnectReason) {
+	return true, 0
+}
+
+// BlockPeer bans a peer: open connections are closed and new ones refused in both
+// directions, so the peer is never chosen for shard placement or served RPCs
+func (n *DHTNode) BlockPeer(peerID peer.ID, reason string) error {
+	if peerID == n.host.ID() {
+		return fmt.Errorf("cannot block this node's own peer ID")
+	}
+	if err := n.blocklist.add(peerID, reason); err != nil {
+		return err
+	}
+
+	if err := n.host.Network().ClosePeer(peerID); err != nil {
+		fmt.Printf("⚠️  Failed to close connections to blocked peer %s: %v\n", peerID, err)
+	}
+	n.host.Peerstore().RemovePeer(peerID)
+
+	n.mu.Lock()
+	delete(n.peers, peerID)
+	n.mu.Unlock()
+
+	fmt.Printf("⛔ Blocked peer %s (%s)\n", peerID, reason)
+	return nil
+}
+
+// UnblockPeer lifts a ban, reporting whether the peer was blocked
+func (n *DHTNode) UnblockPeer(peerID peer.ID) (bool, error) {
+	removed, err := n.blocklist.remove(peerID)
+	if err != nil {
+		return false, err
+	}
+	if removed {
+		fmt.Printf("✅ Unblocked peer %s\n", peerID)
+	}
+	return removed, nil
+}
+
+// PeerBlocked reports whether a peer is banned from this node
+func (n *DHTNode) PeerBlocked(peerID peer.ID) bool {
+	return n.blocklist.blocked(peerID)
+}
+
+// BlockedPeers returns the banned peers, oldest ban first
+func (n *DHTNode) BlockedPeers() []BlockedPeer {
+	return n.blocklist.list()
+}
@@ -0,0 +1,50 @@
+// Package meshstorage provides distributed storage for ZenTalk encrypted chat history
+package meshstorage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
+	"github.com/libp2p/go-libp2p/p2p/net/connmgr"
+)
+
+// DefaultBootstrapConnections is the connection limit of bootstrap-only nodes
+// Storage nodes keep libp2p's defaults (a few hundred connections).
+const DefaultBootstrapConnections = 4096
+
+// bootstrapOptions returns the libp2p options for a bootstrap-only node
+// Bootstrap nodes are the first contact for every joining peer, so they hold many
+// more connections than storage nodes and trim them less aggressively.
+func bootstrapOptions(maxConns int) ([]libp2p.Option, error) {
+	if maxConns <= 0 {
+		maxConns = DefaultBootstrapConnections
+	}
+
+	cm, err := connmgr.NewConnManager(maxConns*3/4, maxConns, connmgr.WithGracePeriod(time.Minute))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create connection manager: %w", err)
+	}
+
+	scaling := rcmgr.DefaultLimits
+	libp2p.SetDefaultServiceLimits(&scaling)
+	conns := rcmgr.ResourceLimits{
+		Conns:        rcmgr.LimitVal(maxConns),
+		ConnsInbound: rcmgr.LimitVal(maxConns),
+	}
+	limits := rcmgr.PartialLimitConfig{System: conns, Transient: conns}.Build(scaling.AutoScale())
+
+	rm, err := rcmgr.NewResourceManager(rcmgr.NewFixedLimiter(limits))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resource manager: %w", err)
+	}
+
+	return []libp2p.Option{libp2p.ConnectionManager(cm), libp2p.ResourceManager(rm)}, nil
+}
+
+// IsBootstrapOnly reports whether the node only helps peers join the network
+// Bootstrap-only nodes have no local storage: Storage returns nil.
+func (n *DHTNode) IsBootstrapOnly() bool {
+	return n.storage == nil
+}
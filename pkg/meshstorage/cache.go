@@ -0,0 +1,160 @@
+// Package meshstorage provides distributed storage for ZenTalk encrypted chat history
+package meshstorage
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+)
+
+// DefaultCacheBytes is the default memory budget for the shard cache (64 MB)
+const DefaultCacheBytes = 64 * 1024 * 1024
+
+// ChunkCache is an LRU cache of chunk and shard data bounded by total bytes
+// Popular chunks fetched by many clients are served from memory instead of SQLite or RPC
+type ChunkCache struct {
+	maxBytes int64
+	bytes    int64
+	order    *list.List               // Front = most recently used
+	entries  map[string]*list.Element // key -> element holding *cacheEntry
+	mu       sync.Mutex
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+// cacheEntry is a single cached value
+type cacheEntry struct {
+	key  string
+	data []byte
+}
+
+// CacheStats reports cache usage
+type CacheStats struct {
+	Entries   int
+	Bytes     int64
+	MaxBytes  int64
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+// HitRate returns the fraction of lookups served from the cache
+func (s CacheStats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// NewChunkCache creates a cache holding at most maxBytes of data
+func NewChunkCache(maxBytes int64) *ChunkCache {
+	return &ChunkCache{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// Get returns cached data and marks it as recently used
+// The returned slice is shared; callers must not modify it
+func (c *ChunkCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	c.hits++
+	return elem.Value.(*cacheEntry).data, true
+}
+
+// Put adds or replaces data, evicting least recently used entries to stay within budget
+// Items larger than the whole budget are not cached
+func (c *ChunkCache) Put(key string, data []byte) {
+	size := int64(len(data))
+	if size > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.removeElementLocked(elem)
+	}
+
+	elem := c.order.PushFront(&cacheEntry{key: key, data: data})
+	c.entries[key] = elem
+	c.bytes += size
+
+	for c.bytes > c.maxBytes {
+		oldest := c.order.Back()
+		if oldest == nil {
+			break
+		}
+		c.removeElementLocked(oldest)
+		c.evictions++
+	}
+}
+
+// Remove drops a key from the cache
+func (c *ChunkCache) Remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.removeElementLocked(elem)
+	}
+}
+
+// RemovePrefix drops every key starting with prefix
+func (c *ChunkCache) RemovePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, elem := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			c.removeElementLocked(elem)
+		}
+	}
+}
+
+// Clear empties the cache (statistics are kept)
+func (c *ChunkCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	c.entries = make(map[string]*list.Element)
+	c.bytes = 0
+}
+
+// Stats returns a snapshot of cache usage
+func (c *ChunkCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CacheStats{
+		Entries:   len(c.entries),
+		Bytes:     c.bytes,
+		MaxBytes:  c.maxBytes,
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+	}
+}
+
+// removeElementLocked unlinks an entry (caller holds c.mu)
+func (c *ChunkCache) removeElementLocked(elem *list.Element) {
+	entry := elem.Value.(*cacheEntry)
+	c.order.Remove(elem)
+	delete(c.entries, entry.key)
+	c.bytes -= int64(len(entry.data))
+}
@@ -0,0 +1,185 @@
+package meshstorage
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestChunkCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewChunkCache(30)
+
+	cache.Put("a", make([]byte, 10))
+	cache.Put("b", make([]byte, 10))
+	cache.Put("c", make([]byte, 10))
+
+	// Touch "a" so "b" becomes the oldest
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatal("expected a to be cached")
+	}
+
+	cache.Put("d", make([]byte, 10))
+
+	if _, ok := cache.Get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if _, ok := cache.Get(key); !ok {
+			t.Errorf("expected %s to be cached", key)
+		}
+	}
+
+	stats := cache.Stats()
+	if stats.Bytes != 30 || stats.Entries != 3 || stats.Evictions != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	// Items larger than the budget are never cached
+	cache.Put("huge", make([]byte, 31))
+	if _, ok := cache.Get("huge"); ok {
+		t.Error("expected oversized item to be skipped")
+	}
+}
+
+func TestChunkCacheRemovePrefix(t *testing.T) {
+	cache := NewChunkCache(1024)
+	cache.Put(localCacheKey("0xabc", 1), []byte("one"))
+	cache.Put(localCacheKey("0xabc", 2), []byte("two"))
+	cache.Put(localCacheKey("0xdef", 1), []byte("other"))
+
+	cache.RemovePrefix(localCacheKeyPrefix("0xabc"))
+
+	if stats := cache.Stats(); stats.Entries != 1 || stats.Bytes != 5 {
+		t.Errorf("unexpected stats after RemovePrefix: %+v", stats)
+	}
+}
+
+func TestLocalStorageReadThroughCache(t *testing.T) {
+	storage, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStorage() error = %v", err)
+	}
+	defer storage.Close()
+
+	storage.EnableCache(1024)
+
+	if err := storage.StoreChunk("0xabc", 1, []byte("first")); err != nil {
+		t.Fatalf("StoreChunk() error = %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		data, err := storage.GetChunk("0xabc", 1)
+		if err != nil || !bytes.Equal(data, []byte("first")) {
+			t.Fatalf("GetChunk() = %q, %v", data, err)
+		}
+	}
+
+	stats := storage.Cache().Stats()
+	if stats.Misses != 1 || stats.Hits != 2 {
+		t.Errorf("expected 1 miss and 2 hits, got %+v", stats)
+	}
+
+	// Overwriting must not serve stale data
+	if err := storage.StoreChunk("0xabc", 1, []byte("second")); err != nil {
+		t.Fatalf("StoreChunk() error = %v", err)
+	}
+	data, err := storage.GetChunk("0xabc", 1)
+	if err != nil || !bytes.Equal(data, []byte("second")) {
+		t.Errorf("GetChunk() after overwrite = %q, %v", data, err)
+	}
+
+	if err := storage.DeleteChunk("0xabc", 1); err != nil {
+		t.Fatalf("DeleteChunk() error = %v", err)
+	}
+	if _, err := storage.GetChunk("0xabc", 1); err == nil {
+		t.Error("expected deleted chunk to be gone")
+	}
+}
+
+func TestRemoteShardCacheFollowsManifest(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Three nodes, so each holds one shard and most of them are remote to any one node
+	nodes := make([]*DHTNode, 3)
+	for i := range nodes {
+		node, err := NewDHTNode(ctx, &NodeConfig{Port: 0, DataDir: t.TempDir()})
+		if err != nil {
+			t.Fatalf("NewDHTNode() error = %v", err)
+		}
+		defer node.Close()
+		NewRPCHandler(node).SetupStreamHandler()
+		nodes[i] = node
+	}
+	reader, writer := nodes[0], nodes[1]
+	readerAddr := reader.Addresses()[0].String() + "/p2p/" + reader.ID().String()
+	for _, node := range nodes[1:] {
+		if err := node.Connect(ctx, readerAddr); err != nil {
+			t.Fatalf("Connect() error = %v", err)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for _, node := range nodes[:2] {
+		for {
+			if closest, err := node.FindClosestNodes(ctx, "any", 3); err == nil && len(closest) == 2 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("nodes never learned about each other")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	erasure := ErasureConfig{DataShards: 2, ParityShards: 1}
+	readerDS, err := NewDistributedStorageWithErasure(reader, erasure)
+	if err != nil {
+		t.Fatalf("NewDistributedStorageWithErasure() error = %v", err)
+	}
+	defer readerDS.StopMonitoring()
+	writerDS, err := NewDistributedStorageWithErasure(writer, erasure)
+	if err != nil {
+		t.Fatalf("NewDistributedStorageWithErasure() error = %v", err)
+	}
+	defer writerDS.StopMonitoring()
+	reader.Storage().EnableCache(1024 * 1024)
+
+	// The reader stores and reads a chunk, caching the shards it fetched from peers
+	chunk, err := readerDS.StoreDistributed(ctx, "0xcache", 1, []byte("first version of the history"))
+	if err != nil {
+		t.Fatalf("StoreDistributed() error = %v", err)
+	}
+	if _, err := readerDS.RetrieveDistributed(ctx, chunk); err != nil {
+		t.Fatalf("RetrieveDistributed() error = %v", err)
+	}
+	if reader.Storage().Cache().Stats().Entries == 0 {
+		t.Fatal("no remote shards were cached")
+	}
+
+	// Another node rewrites the chunk; the reader learns its new manifest (as from the DHT)
+	second := []byte("second version, written elsewhere")
+	rewritten, err := writerDS.StoreDistributed(ctx, "0xcache", 1, second)
+	if err != nil {
+		t.Fatalf("StoreDistributed() error = %v", err)
+	}
+	if !rewritten.UpdatedAt.After(chunk.UpdatedAt) {
+		t.Fatalf("rewritten manifest updated at %v, not after %v", rewritten.UpdatedAt, chunk.UpdatedAt)
+	}
+	encoded, err := encodeManifest(rewritten)
+	if err != nil {
+		t.Fatal(err)
+	}
+	published, err := decodeManifest(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := readerDS.RetrieveDistributed(ctx, published)
+	if err != nil {
+		t.Fatalf("RetrieveDistributed() after rewrite error = %v", err)
+	}
+	if !bytes.Equal(data, second) {
+		t.Errorf("RetrieveDistributed() after rewrite = %q, want %q", data, second)
+	}
+}
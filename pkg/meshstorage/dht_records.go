@@ -0,0 +1,465 @@
+package meshstorage
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+)
+
+// ZenTalk DHT records live under "/zentalk/<type>/<name>"; each type is
+// validated by its own rules and anything else under the namespace is refused.
+const (
+	// DHTNamespace is the DHT namespace of ZenTalk records
+	DHTNamespace = "zentalk"
+
+	// ManifestNamespace holds shard location records, named by the chunk's storage key
+	ManifestNamespace = "manifest"
+
+	// UsernameNamespace holds username claims, named by the username
+	UsernameNamespace = "username"
+
+	// BundleNamespace holds key bundles, named by the ID of the peer they belong to
+	BundleNamespace = "bundle"
+)
+
+const (
+	// Largest record accepted in each namespace, in bytes of JSON. Manifests
+	// leave room for MaxTotalShards shards, each with a handful of addresses.
+	maxManifestRecordSize = 512 << 10
+	maxUsernameRecordSize = 4 << 10
+	maxBundleRecordSize   = 32 << 10
+
+	// maxUsernameLength matches the username field of profiles, in bytes
+	maxUsernameLength = 32
+
+	// maxRecordClockSkew is how far ahead of a DHT node's clock a record may be dated
+	// The newest record wins, so one dated years ahead would otherwise shadow
+	// every honest update until it expires.
+	maxRecordClockSkew = 10 * time.Minute
+
+	// recordHistoryTTL is how long a node remembers who published a record
+	// DHT nodes drop records after 36 hours unless they are published again.
+	recordHistoryTTL = 36 * time.Hour
+)
+
+// ErrRecordNotFound is returned when no valid record is published under a DHT key
+var ErrRecordNotFound = errors.New("dht record not found")
+
+// ErrUsernameTaken is returned when publishing a username another peer claimed first
+var ErrUsernameTaken = errors.New("username claimed by another peer")
+
+// dhtRecordKey returns the DHT key of a record
+func dhtRecordKey(namespace, name string) string {
+	return "/" + DHTNamespace + "/" + namespace + "/" + name
+}
+
+// SignedRecord is a value a peer publishes in the DHT under its own signature
+// The signature covers the full DHT key, so a record cannot be replayed under
+// another name or in another namespace.
+type SignedRecord struct {
+	Key       string `json:"key"`
+	Value     []byte `json:"value"`
+	Publisher string `json:"publisher"`           // Peer ID of the publishing node
+	PublicKey []byte `json:"publicKey"`           // Publisher's libp2p public key; verifies Signature
+	UpdatedAt int64  `json:"updatedAt"`           // Unix nanoseconds; the newest valid record wins
+	ClaimedAt int64  `json:"claimedAt,omitempty"` // Username claims: Unix nanoseconds the name was first claimed
+	Signature []byte `json:"signature"`
+}
+
+// SigningPayload returns the bytes covered by the publisher's signature
+// ClaimedAt is only covered when set, so records published before it still verify.
+func (r *SignedRecord) SigningPayload() []byte {
+	payload := fmt.Sprintf("zentalk-record|%s|%s|%s|%d",
+		r.Key, base64.StdEncoding.EncodeToString(r.Value), r.Publisher, r.UpdatedAt)
+	if r.ClaimedAt != 0 {
+		payload += fmt.Sprintf("|%d", r.ClaimedAt)
+	}
+	return []byte(payload)
+}
+
+// Sign signs the record with a node's libp2p key and embeds the matching public key
+func (r *SignedRecord) Sign(key crypto.PrivKey) error {
+	publisher, publicKey, err := recordIdentity(key)
+	if err != nil {
+		return err
+	}
+	r.Publisher = publisher
+	r.PublicKey = publicKey
+
+	signature, err := key.Sign(r.SigningPayload())
+	if err != nil {
+		return fmt.Errorf("failed to sign record: %w", err)
+	}
+	r.Signature = signature
+	return nil
+}
+
+// Verify checks that the record was signed by its publisher
+func (r *SignedRecord) Verify() error {
+	return verifyRecordSignature(r.Publisher, r.PublicKey, r.SigningPayload(), r.Signature)
+}
+
+// recordIdentity returns the peer ID and marshalled public key of a record signed with key
+func recordIdentity(key crypto.PrivKey) (string, []byte, error) {
+	id, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to derive publisher ID: %w", err)
+	}
+	publicKey, err := crypto.MarshalPublicKey(key.GetPublic())
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal publisher key: %w", err)
+	}
+	return id.String(), publicKey, nil
+}
+
+// verifyRecordSignature checks that publicKey is the publisher's and signed payload
+func verifyRecordSignature(publisher string, publicKey, payload, signature []byte) error {
+	key, err := crypto.UnmarshalPublicKey(publicKey)
+	if err != nil {
+		return fmt.Errorf("invalid publisher key: %w", err)
+	}
+	id, err := peer.IDFromPublicKey(key)
+	if err != nil {
+		return fmt.Errorf("invalid publisher key: %w", err)
+	}
+	if id.String() != publisher {
+		return fmt.Errorf("publisher key does not match peer %s", publisher)
+	}
+
+	ok, err := key.Verify(payload, signature)
+	if err != nil {
+		return fmt.Errorf("failed to verify record signature: %w", err)
+	}
+	if !ok {
+		return errors.New("invalid record signature")
+	}
+	return nil
+}
+
+// parseSignedRecord decodes a record fetched from the DHT and checks it was signed for key
+func parseSignedRecord(key string, value []byte) (*SignedRecord, error) {
+	var r SignedRecord
+	if err := json.Unmarshal(value, &r); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal record: %w", err)
+	}
+	if r.Key != key {
+		return nil, fmt.Errorf("record for %q stored under %q", r.Key, key)
+	}
+	if err := r.Verify(); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// recordInfo is what the validator needs of a checked record to order it
+type recordInfo struct {
+	publisher string
+	updatedAt int64
+	claimedAt int64    // Username claims: when the publisher first claimed the name
+	holders   []string // Manifests: peers holding the chunk's shards
+	seenAt    time.Time
+}
+
+// recordRule is how the records of one namespace are checked
+type recordRule struct {
+	maxSize int
+
+	// parse checks a record of the named entry and returns what orders it
+	parse func(key, name string, value []byte) (*recordInfo, error)
+
+	// succeeds reports whether a record from another publisher may replace prev
+	// Nil lets any valid record replace an older one.
+	succeeds func(next, prev *recordInfo) bool
+
+	// firstClaim orders records of different publishers by claimedAt instead of
+	// updatedAt, so the first peer to claim a name keeps it
+	firstClaim bool
+}
+
+// recordRules are the record types accepted under DHTNamespace
+var recordRules = map[string]recordRule{
+	ManifestNamespace: {
+		maxSize: maxManifestRecordSize,
+		parse: func(key, name string, value []byte) (*recordInfo, error) {
+			r, err := parseShardLocationRecord(key, value)
+			if err != nil {
+				return nil, err
+			}
+			info := &recordInfo{publisher: r.Publisher, updatedAt: r.UpdatedAt}
+			if !r.Deleted() {
+				chunk, err := r.Chunk()
+				if err != nil {
+					return nil, err
+				}
+				for _, loc := range chunk.ShardLocations {
+					info.holders = append(info.holders, loc.PeerID.String())
+				}
+			}
+			return info, nil
+		},
+		// Only a node holding the chunk's shards takes over its record, so a
+		// stranger cannot point the chunk elsewhere or delete it
+		succeeds: func(next, prev *recordInfo) bool {
+			return slices.Contains(prev.holders, next.publisher)
+		},
+	},
+	UsernameNamespace: {
+		maxSize: maxUsernameRecordSize,
+		parse: func(key, name string, value []byte) (*recordInfo, error) {
+			if len(name) > maxUsernameLength || !utf8.ValidString(name) {
+				return nil, fmt.Errorf("invalid username %q", name)
+			}
+			r, err := parseSignedRecord(key, value)
+			if err != nil {
+				return nil, err
+			}
+			if r.ClaimedAt <= 0 || r.ClaimedAt > r.UpdatedAt {
+				return nil, fmt.Errorf("username %q claimed at invalid time %d", name, r.ClaimedAt)
+			}
+			return &recordInfo{publisher: r.Publisher, updatedAt: r.UpdatedAt, claimedAt: r.ClaimedAt}, nil
+		},
+		succeeds: func(next, prev *recordInfo) bool {
+			return false
+		},
+		firstClaim: true,
+	},
+	BundleNamespace: {
+		maxSize: maxBundleRecordSize,
+		parse: func(key, name string, value []byte) (*recordInfo, error) {
+			r, err := parseSignedRecord(key, value)
+			if err != nil {
+				return nil, err
+			}
+			if r.Publisher != name {
+				return nil, fmt.Errorf("key bundle of %s published by %s", name, r.Publisher)
+			}
+			return &recordInfo{publisher: r.Publisher, updatedAt: r.UpdatedAt}, nil
+		},
+	},
+}
+
+// checkRecord applies a namespace's rule to a record
+func checkRecord(rule recordRule, key, name string, value []byte) (*recordInfo, error) {
+	if len(value) > rule.maxSize {
+		return nil, fmt.Errorf("record is %d bytes, limit is %d", len(value), rule.maxSize)
+	}
+	info, err := rule.parse(key, name, value)
+	if err != nil {
+		return nil, err
+	}
+	if time.Unix(0, info.updatedAt).After(time.Now().Add(maxRecordClockSkew)) {
+		return nil, fmt.Errorf("record dated %s is in the future", time.Unix(0, info.updatedAt).UTC().Format(time.RFC3339))
+	}
+	return info, nil
+}
+
+// dhtRecordRule returns the rule and entry name of a key under DHTNamespace
+func dhtRecordRule(key string) (recordRule, string, error) {
+	rest, ok := strings.CutPrefix(key, "/"+DHTNamespace+"/")
+	if !ok {
+		return recordRule{}, "", fmt.Errorf("key %q is not in the %s namespace", key, DHTNamespace)
+	}
+	namespace, name, _ := strings.Cut(rest, "/")
+	rule, ok := recordRules[namespace]
+	if !ok {
+		return recordRule{}, "", fmt.Errorf("unknown record namespace %q", namespace)
+	}
+	if name == "" || strings.Contains(name, "/") {
+		return recordRule{}, "", fmt.Errorf("invalid record name %q", name)
+	}
+	return rule, name, nil
+}
+
+// parseDHTRecord checks a record under DHTNamespace against its namespace's rule
+func parseDHTRecord(key string, value []byte) (*recordInfo, error) {
+	rule, name, err := dhtRecordRule(key)
+	if err != nil {
+		return nil, err
+	}
+	return checkRecord(rule, key, name, value)
+}
+
+// prefers reports whether record a is kept over record b
+// The answer does not depend on which of the two a DHT node already holds,
+// as libp2p passes them to Select in a different order when storing and
+// when looking up.
+func (rule recordRule) prefers(a, b *recordInfo) bool {
+	if rule.succeeds == nil || a.publisher == b.publisher {
+		return a.updatedAt > b.updatedAt
+	}
+	if rule.firstClaim {
+		if a.claimedAt != b.claimedAt {
+			return a.claimedAt < b.claimedAt
+		}
+		return a.publisher < b.publisher
+	}
+	// The newer record wins only if the older one's publisher handed over to it
+	if a.updatedAt > b.updatedAt {
+		return rule.succeeds(a, b)
+	}
+	return !rule.succeeds(b, a)
+}
+
+// selectRecord returns the index of the valid record a namespace's rule keeps
+func selectRecord(rule recordRule, values [][]byte, parse func([]byte) (*recordInfo, error)) (int, error) {
+	best := -1
+	var bestInfo *recordInfo
+	for i, value := range values {
+		info, err := parse(value)
+		if err != nil {
+			continue
+		}
+		if best < 0 || rule.prefers(info, bestInfo) {
+			best, bestInfo = i, info
+		}
+	}
+	if best < 0 {
+		return 0, errors.New("no valid record")
+	}
+	return best, nil
+}
+
+// recordHistory remembers who published the last record a node accepted under each key
+// Records are dated by their publisher, so a stranger could backdate one to
+// win Select on nodes that never saw the original; nodes that did refuse it.
+type recordHistory struct {
+	mu      sync.Mutex
+	records map[string]*recordInfo
+	swept   time.Time
+}
+
+func newRecordHistory() *recordHistory {
+	return &recordHistory{records: make(map[string]*recordInfo), swept: time.Now()}
+}
+
+// check refuses a record its rule does not let replace the one last accepted under key
+// Otherwise a newer record becomes the last accepted one.
+func (h *recordHistory) check(rule recordRule, key string, info *recordInfo) error {
+	if h == nil || rule.succeeds == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(h.swept) > recordHistoryTTL {
+		for k, prev := range h.records {
+			if now.Sub(prev.seenAt) > recordHistoryTTL {
+				delete(h.records, k)
+			}
+		}
+		h.swept = now
+	}
+
+	prev, ok := h.records[key]
+	if ok && now.Sub(prev.seenAt) <= recordHistoryTTL && info.publisher != prev.publisher && !rule.succeeds(info, prev) {
+		return fmt.Errorf("record %s is held by %s, not %s", key, prev.publisher, info.publisher)
+	}
+	if !ok || now.Sub(prev.seenAt) > recordHistoryTTL || info.updatedAt >= prev.updatedAt {
+		info.seenAt = now
+		h.records[key] = info
+	}
+	return nil
+}
+
+// recordValidator lets DHT nodes check and order the records under DHTNamespace
+type recordValidator struct {
+	history *recordHistory // Nil checks each record on its own
+}
+
+// Validate rejects records of unknown types, over their size limit, unsigned,
+// under another name or replacing a record their publisher may not replace
+func (v recordValidator) Validate(key string, value []byte) error {
+	rule, name, err := dhtRecordRule(key)
+	if err != nil {
+		return err
+	}
+	info, err := checkRecord(rule, key, name, value)
+	if err != nil {
+		return err
+	}
+	return v.history.check(rule, key, info)
+}
+
+// Select picks the valid record its namespace's rule keeps, usually the most recently updated
+func (recordValidator) Select(key string, values [][]byte) (int, error) {
+	rule, _, err := dhtRecordRule(key)
+	if err != nil {
+		return 0, err
+	}
+	return selectRecord(rule, values, func(value []byte) (*recordInfo, error) {
+		return parseDHTRecord(key, value)
+	})
+}
+
+// PutRecord signs value with the node's key and publishes it in the DHT as namespace/name
+// For the username and bundle namespaces; key bundles must be named by the
+// node's own peer ID. Shard location records are published by DistributedStorage.
+// Returns ErrUsernameTaken if another peer claimed the username first.
+func (n *DHTNode) PutRecord(ctx context.Context, namespace, name string, value []byte) error {
+	key := n.host.Peerstore().PrivKey(n.ID())
+	if key == nil {
+		return errors.New("node key not available")
+	}
+
+	record := &SignedRecord{
+		Key:       dhtRecordKey(namespace, name),
+		Value:     value,
+		UpdatedAt: time.Now().UnixNano(),
+	}
+	if namespace == UsernameNamespace {
+		// Keep the time of the first claim, which decides who owns the name
+		record.ClaimedAt = record.UpdatedAt
+		existing, err := n.GetRecord(ctx, namespace, name)
+		switch {
+		case errors.Is(err, ErrRecordNotFound):
+		case err != nil:
+			return err
+		case existing.Publisher != n.ID().String():
+			return ErrUsernameTaken
+		case existing.ClaimedAt > 0:
+			record.ClaimedAt = existing.ClaimedAt
+		}
+	}
+	if err := record.Sign(key); err != nil {
+		return err
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+	if _, err := parseDHTRecord(record.Key, data); err != nil {
+		return err
+	}
+
+	// The DHT keeps the record locally before looking for peers, so a node
+	// without any still answers lookups for it
+	if err := n.dht.PutValue(ctx, record.Key, data); err != nil && n.dht.RoutingTable().Size() > 0 {
+		return err
+	}
+	return nil
+}
+
+// GetRecord looks up the newest valid record published as namespace/name
+func (n *DHTNode) GetRecord(ctx context.Context, namespace, name string) (*SignedRecord, error) {
+	key := dhtRecordKey(namespace, name)
+	value, err := n.dht.GetValue(ctx, key)
+	if errors.Is(err, routing.ErrNotFound) {
+		return nil, ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up record: %w", err)
+	}
+	return parseSignedRecord(key, value)
+}
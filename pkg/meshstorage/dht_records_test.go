@@ -0,0 +1,179 @@
+package meshstorage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	libp2pcrypto "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	libp2ptest "github.com/libp2p/go-libp2p/core/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signedRecord returns a record signed by key, as stored in the DHT
+func signedRecord(t *testing.T, key libp2pcrypto.PrivKey, dhtKey string, value []byte, updatedAt int64) []byte {
+	t.Helper()
+	record := &SignedRecord{Key: dhtKey, Value: value, UpdatedAt: updatedAt}
+	require.NoError(t, record.Sign(key))
+	data, err := json.Marshal(record)
+	require.NoError(t, err)
+	return data
+}
+
+// signedClaim returns a username claim signed by key, as stored in the DHT
+func signedClaim(t *testing.T, key libp2pcrypto.PrivKey, dhtKey string, value []byte, claimedAt, updatedAt int64) []byte {
+	t.Helper()
+	record := &SignedRecord{Key: dhtKey, Value: value, ClaimedAt: claimedAt, UpdatedAt: updatedAt}
+	require.NoError(t, record.Sign(key))
+	data, err := json.Marshal(record)
+	require.NoError(t, err)
+	return data
+}
+
+// TestRecordValidator tests that DHT nodes accept only signed records within their namespace's rules
+func TestRecordValidator(t *testing.T) {
+	key, _, err := libp2pcrypto.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+	id, err := peer.IDFromPrivateKey(key)
+	require.NoError(t, err)
+	other, _, err := libp2pcrypto.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+
+	validator := recordValidator{}
+	now := time.Now().UnixNano()
+
+	// Key bundles can only be published by the peer they belong to
+	bundleKey := dhtRecordKey(BundleNamespace, id.String())
+	bundle := signedRecord(t, key, bundleKey, []byte(`{"identityKey":"..."}`), now)
+	require.NoError(t, validator.Validate(bundleKey, bundle))
+	assert.Error(t, validator.Validate(bundleKey, signedRecord(t, other, bundleKey, []byte("{}"), now)), "bundle of another peer")
+	assert.Error(t, validator.Validate(bundleKey, signedRecord(t, key, bundleKey, make([]byte, maxBundleRecordSize), now)), "oversized bundle")
+
+	// Records are bound to the key they were signed for
+	usernameKey := dhtRecordKey(UsernameNamespace, "alice")
+	assert.Error(t, validator.Validate(usernameKey, bundle), "record replayed under another key")
+
+	claim := signedClaim(t, other, usernameKey, []byte("0xalice"), now, now)
+	require.NoError(t, validator.Validate(usernameKey, claim))
+	longKey := dhtRecordKey(UsernameNamespace, strings.Repeat("a", maxUsernameLength+1))
+	assert.Error(t, validator.Validate(longKey, signedClaim(t, other, longKey, []byte("0xalice"), now, now)), "username too long")
+	assert.Error(t, validator.Validate(usernameKey, signedRecord(t, other, usernameKey, []byte("0xalice"), now)), "claim without a claim time")
+	assert.Error(t, validator.Validate(usernameKey, signedClaim(t, other, usernameKey, []byte("0xalice"), now+1, now)), "claimed after update")
+
+	var tampered SignedRecord
+	require.NoError(t, json.Unmarshal(claim, &tampered))
+	tampered.Value = []byte("0xmallory")
+	value, err := json.Marshal(tampered)
+	require.NoError(t, err)
+	assert.Error(t, validator.Validate(usernameKey, value), "record changed after signing")
+
+	future := time.Now().Add(time.Hour).UnixNano()
+	assert.Error(t, validator.Validate(usernameKey, signedClaim(t, other, usernameKey, []byte("0xalice"), now, future)), "record dated in the future")
+
+	// Nothing else is accepted under the namespace
+	for _, dhtKey := range []string{"/zentalk/profile/alice", "/zentalk/username/", "/zentalk/username/a/b", "/other/username/alice"} {
+		assert.Error(t, validator.Validate(dhtKey, signedRecord(t, key, dhtKey, []byte("x"), now)), dhtKey)
+	}
+	assert.Error(t, validator.Validate(usernameKey, []byte("garbage")))
+
+	newer := signedClaim(t, other, usernameKey, []byte("0xalice"), now, now+1)
+	best, err := validator.Select(usernameKey, [][]byte{claim, []byte("garbage"), newer})
+	require.NoError(t, err)
+	assert.Equal(t, 2, best)
+}
+
+// TestUsernameFirstClaim tests that a username stays with the peer that claimed it first
+func TestUsernameFirstClaim(t *testing.T) {
+	owner, _, err := libp2pcrypto.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+	stranger, _, err := libp2pcrypto.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+
+	usernameKey := dhtRecordKey(UsernameNamespace, "alice")
+	now := time.Now().UnixNano()
+	claim := signedClaim(t, owner, usernameKey, []byte("0xalice"), now-10, now-10)
+	republished := signedClaim(t, owner, usernameKey, []byte("0xalice"), now-10, now)
+	takeover := signedClaim(t, stranger, usernameKey, []byte("0xmallory"), now-5, now-5)
+
+	// A newer record from another publisher loses whichever the node already holds
+	validator := recordValidator{}
+	for _, values := range [][][]byte{{takeover, claim}, {claim, takeover}, {takeover, republished}, {republished, takeover}} {
+		best, err := validator.Select(usernameKey, values)
+		require.NoError(t, err)
+		assert.NotEqual(t, takeover, values[best])
+	}
+
+	// A node that accepted the claim refuses another publisher's, even backdated
+	validator = recordValidator{history: newRecordHistory()}
+	require.NoError(t, validator.Validate(usernameKey, claim))
+	assert.Error(t, validator.Validate(usernameKey, takeover))
+	backdated := signedClaim(t, stranger, usernameKey, []byte("0xmallory"), now-20, now-20)
+	assert.Error(t, validator.Validate(usernameKey, backdated))
+	assert.NoError(t, validator.Validate(usernameKey, republished))
+	bobKey := dhtRecordKey(UsernameNamespace, "bob")
+	assert.NoError(t, validator.Validate(bobKey, signedClaim(t, stranger, bobKey, []byte("0xmallory"), now, now)), "unclaimed name")
+}
+
+// TestLegacyShardRecords tests that shard location records in the old namespace are held to the manifest rules
+func TestLegacyShardRecords(t *testing.T) {
+	key, _, err := libp2pcrypto.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+
+	chunk := &DistributedChunk{UserAddr: "0xuser", ChunkID: 1, Erasure: ErasureConfig{DataShards: 1, ParityShards: 0}}
+	legacyKey := legacyShardRecordKey("0xuser", 1)
+	validator := shardRecordValidator{}
+
+	record := signedShardRecord(t, key, chunk, 1)
+	require.NoError(t, validator.Validate(legacyKey, record))
+	assert.Error(t, validator.Validate(shardRecordKey("0xuser", 1), record), "key outside the legacy namespace")
+
+	chunk.ShardLocations = make([]ShardLocation, maxManifestRecordSize/10)
+	assert.Error(t, validator.Validate(legacyKey, signedShardRecord(t, key, chunk, 2)), "oversized manifest")
+
+	// The largest chunk a node can store still fits
+	addrs := []string{"/ip4/203.0.113.10/tcp/9000", "/ip4/203.0.113.10/udp/9000/quic-v1", "/ip6/2001:db8::10/tcp/9000", "/ip6/2001:db8::10/udp/9000/quic-v1"}
+	chunk.ShardLocations = make([]ShardLocation, MaxTotalShards)
+	for i := range chunk.ShardLocations {
+		chunk.ShardLocations[i] = ShardLocation{ShardIndex: i, PeerID: libp2ptest.RandPeerIDFatal(t), PeerAddrs: addrs}
+	}
+	assert.NoError(t, validator.Validate(legacyKey, signedShardRecord(t, key, chunk, 3)))
+}
+
+// TestPutRecord tests publishing and looking up records from a node
+func TestPutRecord(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	node, err := NewDHTNode(ctx, &NodeConfig{Port: 0, DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer node.Close()
+
+	require.NoError(t, node.PutRecord(ctx, BundleNamespace, node.ID().String(), []byte("bundle")))
+	record, err := node.GetRecord(ctx, BundleNamespace, node.ID().String())
+	require.NoError(t, err)
+	assert.Equal(t, []byte("bundle"), record.Value)
+	assert.Equal(t, node.ID().String(), record.Publisher)
+
+	// Invalid records are refused before they reach the DHT
+	assert.Error(t, node.PutRecord(ctx, BundleNamespace, "someone-else", []byte("bundle")))
+	assert.Error(t, node.PutRecord(ctx, "profile", "alice", []byte("profile")))
+	assert.Error(t, node.DHT().PutValue(ctx, dhtRecordKey(UsernameNamespace, "alice"), []byte("garbage")))
+
+	_, err = node.GetRecord(ctx, UsernameNamespace, "alice")
+	assert.True(t, errors.Is(err, ErrRecordNotFound), "got %v", err)
+
+	// Publishing a username again keeps the time it was first claimed
+	require.NoError(t, node.PutRecord(ctx, UsernameNamespace, "alice", []byte("0xalice")))
+	claim, err := node.GetRecord(ctx, UsernameNamespace, "alice")
+	require.NoError(t, err)
+	require.NoError(t, node.PutRecord(ctx, UsernameNamespace, "alice", []byte("0xalice")))
+	record, err = node.GetRecord(ctx, UsernameNamespace, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, claim.ClaimedAt, record.ClaimedAt)
+	assert.Greater(t, record.UpdatedAt, claim.UpdatedAt)
+}
@@ -4,6 +4,7 @@ package meshstorage
 import (
 	"context"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -14,21 +15,53 @@ import (
 // DistributedStorage manages distributed storage across the mesh network
 type DistributedStorage struct {
 	node    *DHTNode
-	encoder *ErasureEncoder
 	client  *RPCClient
 	mu      sync.RWMutex
 
+	// Erasure coding: new uploads use erasure, chunks are decoded with the coding they recorded
+	erasure    ErasureConfig
+	encoders   map[ErasureConfig]*ErasureEncoder
+	encodersMu sync.Mutex
+
 	// Health monitoring
 	monitorInterval time.Duration
 	monitorStop     chan struct{}
 	monitorWg       sync.WaitGroup
 	chunks          map[string]*DistributedChunk // Track chunks for monitoring
+	published       map[string]time.Time         // When each monitored chunk's shard locations were last published
 	chunksMu        sync.RWMutex
+	moveMu          sync.Mutex                   // Held while repair or rebalancing rewrites shard locations
+
+	// Peer shard inventories, refreshed incrementally by health cycles
+	inventories map[peer.ID]*peerInventory
+	inventoryMu sync.Mutex
+
+	// Tiered retrieval: data shards first, parity only on failure
+	dataTierTimeout   time.Duration
+	parityTierTimeout time.Duration
+
+	// Users' pinned nodes, by user address
+	pins   map[string]*pinTarget
+	pinsMu sync.RWMutex
 }
 
+const (
+	// DefaultDataTierTimeout is how long retrieval waits for the data shards before falling back to parity
+	DefaultDataTierTimeout = 5 * time.Second
+	// DefaultParityTierTimeout is how long retrieval waits for parity shards once the data tier falls short
+	DefaultParityTierTimeout = 15 * time.Second
+)
+
 // NewDistributedStorage creates a new distributed storage manager
 func NewDistributedStorage(node *DHTNode) (*DistributedStorage, error) {
-	encoder, err := NewErasureEncoder()
+	return NewDistributedStorageWithErasure(node, DefaultErasureConfig())
+}
+
+// NewDistributedStorageWithErasure creates a distributed storage manager that stores new chunks with erasure
+// Chunks already stored keep the configuration recorded in their metadata, so
+// changing it only affects what is uploaded from now on.
+func NewDistributedStorageWithErasure(node *DHTNode, erasure ErasureConfig) (*DistributedStorage, error) {
+	encoder, err := NewErasureEncoder(erasure)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create erasure encoder: %w", err)
 	}
@@ -37,22 +70,60 @@ func NewDistributedStorage(node *DHTNode) (*DistributedStorage, error) {
 
 	ds := &DistributedStorage{
 		node:            node,
-		encoder:         encoder,
 		client:          client,
+		erasure:         encoder.Config(),
+		encoders:        map[ErasureConfig]*ErasureEncoder{encoder.Config(): encoder},
 		monitorInterval: 10 * time.Minute, // Check health every 10 minutes
 		monitorStop:     make(chan struct{}),
 		chunks:          make(map[string]*DistributedChunk),
+		published:       make(map[string]time.Time),
+		inventories:     make(map[peer.ID]*peerInventory),
+		pins:            make(map[string]*pinTarget),
+
+		dataTierTimeout:   DefaultDataTierTimeout,
+		parityTierTimeout: DefaultParityTierTimeout,
+	}
+
+	// Chunks stored before a restart are monitored, repaired and served again
+	if err := ds.loadManifests(); err != nil {
+		return nil, fmt.Errorf("failed to load chunk manifests: %w", err)
 	}
 
+	// Re-verify a peer's shards as soon as it comes back from maintenance
+	node.OnPeerReturn(ds.reverifyPeer)
+
 	// Start background health monitoring
 	ds.StartMonitoring()
 
 	return ds, nil
 }
 
+// Erasure returns the configuration new chunks are stored with
+func (ds *DistributedStorage) Erasure() ErasureConfig {
+	return ds.erasure
+}
+
+// encoderFor returns an encoder for a chunk's erasure configuration
+func (ds *DistributedStorage) encoderFor(erasure ErasureConfig) (*ErasureEncoder, error) {
+	erasure = erasure.withDefaults()
+
+	ds.encodersMu.Lock()
+	defer ds.encodersMu.Unlock()
+
+	if encoder, ok := ds.encoders[erasure]; ok {
+		return encoder, nil
+	}
+	encoder, err := NewErasureEncoder(erasure)
+	if err != nil {
+		return nil, err
+	}
+	ds.encoders[erasure] = encoder
+	return encoder, nil
+}
+
 // ShardLocation represents where a shard is stored
 type ShardLocation struct {
-	ShardIndex int       // Index of the shard (0-14)
+	ShardIndex int       // Index of the shard (0 to TotalShards-1)
 	PeerID     peer.ID   // Peer storing this shard
 	PeerAddrs  []string  // Peer addresses
 }
@@ -64,47 +135,82 @@ type DistributedChunk struct {
 	OriginalSize  int             // Original data size
 	ShardSize     int             // Size of each shard
 	ShardLocations []ShardLocation // Where each shard is stored
+	LeaseExpires  time.Time       // When nodes may collect the shards (zero = no lease)
+	Erasure       ErasureConfig   // Coding the shards were made with (zero = DefaultErasureConfig)
+	UpdatedAt     time.Time       // When the shards were last placed, repaired or moved; remote shards are cached under it
+}
+
+// Coding returns the erasure configuration the chunk was stored with
+// Chunks stored before the configuration was recorded used the default.
+func (chunk *DistributedChunk) Coding() ErasureConfig {
+	return chunk.Erasure.withDefaults()
 }
 
 // StoreDistributed encodes data and distributes shards across the network
 func (ds *DistributedStorage) StoreDistributed(ctx context.Context, userAddr string, chunkID int, data []byte) (*DistributedChunk, error) {
+	return ds.StoreDistributedWithProgress(ctx, userAddr, chunkID, data, nil)
+}
+
+// StoreProgressFunc is called each time a shard is stored (stored of total so far)
+type StoreProgressFunc func(stored, total int)
+
+// StoreDistributedWithProgress is StoreDistributed with per-shard progress reporting
+// Cancelling ctx aborts the store and removes the shards already placed
+func (ds *DistributedStorage) StoreDistributedWithProgress(ctx context.Context, userAddr string, chunkID int, data []byte, progress StoreProgressFunc) (*DistributedChunk, error) {
+	return ds.storeDistributed(ctx, userAddr, chunkID, data, 0, progress)
+}
+
+// StoreDistributedWithLease is StoreDistributed for data the network may drop after lease
+// Nodes collect the shards once the lease runs out unless RenewLease extends
+// it first. Leases are capped at MaxLeaseDuration.
+func (ds *DistributedStorage) StoreDistributedWithLease(ctx context.Context, userAddr string, chunkID int, data []byte, lease time.Duration) (*DistributedChunk, error) {
+	return ds.storeDistributed(ctx, userAddr, chunkID, data, lease, nil)
+}
+
+// storeDistributed encodes data and places its shards, under a lease if lease > 0
+func (ds *DistributedStorage) storeDistributed(ctx context.Context, userAddr string, chunkID int, data []byte, lease time.Duration, progress StoreProgressFunc) (*DistributedChunk, error) {
+	expires := leaseExpiry(lease)
+	erasure := ds.erasure
+	totalShards := erasure.TotalShards()
+
 	// Encode data into shards
-	encoded, err := ds.encoder.Encode(data)
+	encoder, err := ds.encoderFor(erasure)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := encoder.Encode(data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to encode data: %w", err)
 	}
 
+	// Rewriting a chunk replaces its shards, so previously cached ones are stale
+	ds.invalidateShards(userAddr, chunkID)
+
 	// Generate a deterministic key for finding storage nodes
 	key := generateStorageKey(userAddr, chunkID)
 
 	// Find nodes to store shards
-	targetPeers, err := ds.findStorageNodes(ctx, key, TotalShards)
+	targetPeers, err := ds.findPlacementNodes(ctx, key, totalShards)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find storage nodes: %w", err)
 	}
 
-	// If we don't have enough peers, store locally and on available peers
-	if len(targetPeers) < TotalShards {
-		// Store remaining shards locally
-		for i := len(targetPeers); i < TotalShards; i++ {
-			shardKey := fmt.Sprintf("%s_%d_shard_%d", userAddr, chunkID, i)
-			if err := ds.node.Storage().StoreChunk(shardKey, i, encoded.Shards[i]); err != nil {
-				return nil, fmt.Errorf("failed to store local shard %d: %w", i, err)
-			}
-		}
-
-		// Add local node to target peers for the remaining shards
-		for i := len(targetPeers); i < TotalShards; i++ {
-			targetPeers = append(targetPeers, ds.node.ID())
-		}
+	// If we don't have enough peers, the local node stores the remaining shards
+	for len(targetPeers) < totalShards {
+		targetPeers = append(targetPeers, ds.node.ID())
 	}
 
+	// The user's pinned nodes get their share before anyone else
+	targetPeers = ds.applyPins(userAddr, targetPeers, nil)
+
 	// Distribute shards to peers
-	shardLocations := make([]ShardLocation, TotalShards)
+	shardLocations := make([]ShardLocation, totalShards)
 	var wg sync.WaitGroup
-	errChan := make(chan error, TotalShards)
+	errChan := make(chan error, totalShards)
+	var progressMu sync.Mutex
+	storedCount := 0
 
-	for i := 0; i < TotalShards; i++ {
+	for i := 0; i < totalShards; i++ {
 		wg.Add(1)
 		go func(shardIndex int) {
 			defer wg.Done()
@@ -114,13 +220,13 @@ func (ds *DistributedStorage) StoreDistributed(ctx context.Context, userAddr str
 
 			// If it's the local node, store locally
 			if targetPeer == ds.node.ID() {
-				if err := ds.node.Storage().StoreChunk(shardKey, shardIndex, encoded.Shards[shardIndex]); err != nil {
+				if err := ds.node.Storage().StoreChunkWithLease(shardKey, shardIndex, encoded.Shards[shardIndex], expires); err != nil {
 					errChan <- fmt.Errorf("failed to store local shard %d: %w", shardIndex, err)
 					return
 				}
 			} else {
 				// Store on remote peer via RPC
-				if err := ds.client.StoreChunk(ctx, targetPeer, shardKey, shardIndex, encoded.Shards[shardIndex]); err != nil {
+				if err := ds.client.StoreShardWithLease(ctx, targetPeer, shardKey, shardIndex, encoded.Shards[shardIndex], lease, erasure); err != nil {
 					errChan <- fmt.Errorf("failed to store shard %d on peer %s: %w", shardIndex, targetPeer, err)
 					return
 				}
@@ -138,12 +244,25 @@ func (ds *DistributedStorage) StoreDistributed(ctx context.Context, userAddr str
 				PeerID:     targetPeer,
 				PeerAddrs:  addrs,
 			}
+
+			if progress != nil {
+				progressMu.Lock()
+				storedCount++
+				progress(storedCount, totalShards)
+				progressMu.Unlock()
+			}
 		}(i)
 	}
 
 	wg.Wait()
 	close(errChan)
 
+	if err := ctx.Err(); err != nil {
+		// Cancelled or timed out: the caller gave up, so the chunk must not half-exist
+		ds.rollbackShards(userAddr, chunkID, shardLocations)
+		return nil, fmt.Errorf("store aborted: %w", err)
+	}
+
 	// Check for errors
 	var errs []error
 	for err := range errChan {
@@ -151,9 +270,18 @@ func (ds *DistributedStorage) StoreDistributed(ctx context.Context, userAddr str
 	}
 
 	if len(errs) > 0 {
-		// If we failed to store more than 5 shards, return error
-		if len(errs) > ParityShards {
-			return nil, fmt.Errorf("failed to store %d shards (too many failures): %v", len(errs), errs)
+		// Fail if fewer shards than the configured minimum were stored
+		if totalShards-len(errs) < erasure.MinRecovery {
+			// Don't leave an undecodable fragment of the chunk behind
+			ds.rollbackShards(userAddr, chunkID, shardLocations)
+			storeErr := fmt.Errorf("failed to store %d shards (too many failures): %v", len(errs), errs)
+			for _, err := range errs {
+				if errors.Is(err, ErrQuotaExceeded) {
+					// Let callers tell a full node (or user) from an unreachable mesh
+					return nil, fmt.Errorf("%w: %v", ErrQuotaExceeded, storeErr)
+				}
+			}
+			return nil, storeErr
 		}
 		// Otherwise, just log the errors but continue (we have redundancy)
 		fmt.Printf("Warning: failed to store %d shards, but continuing due to redundancy: %v\n", len(errs), errs)
@@ -165,6 +293,9 @@ func (ds *DistributedStorage) StoreDistributed(ctx context.Context, userAddr str
 		OriginalSize:   encoded.OriginalSize,
 		ShardSize:      encoded.ShardSize,
 		ShardLocations: shardLocations,
+		LeaseExpires:   expires,
+		Erasure:        erasure,
+		UpdatedAt:      time.Now(),
 	}
 
 	// Register chunk for automatic health monitoring
@@ -173,63 +304,144 @@ func (ds *DistributedStorage) StoreDistributed(ctx context.Context, userAddr str
 	return chunk, nil
 }
 
+// rollbackShards deletes the shards of a failed store (best effort)
+// It uses its own context because the store's context is often the reason it failed
+func (ds *DistributedStorage) rollbackShards(userAddr string, chunkID int, locations []ShardLocation) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	removed := 0
+	for _, loc := range locations {
+		if loc.PeerID == "" {
+			continue // Never stored
+		}
+
+		if err := ds.deleteShard(ctx, userAddr, chunkID, loc.ShardIndex, loc.PeerID); err != nil {
+			fmt.Printf("⚠️  Failed to roll back shard %d: %v\n", loc.ShardIndex, err)
+			continue
+		}
+		removed++
+	}
+
+	ds.invalidateShards(userAddr, chunkID)
+	fmt.Printf("↩️  Rolled back %d shards of chunk %d\n", removed, chunkID)
+}
+
+// deleteShard deletes one shard from the peer holding it (the local node or a remote one)
+func (ds *DistributedStorage) deleteShard(ctx context.Context, userAddr string, chunkID, shardIndex int, peerID peer.ID) error {
+	if peerID == ds.node.ID() {
+		shardKey := fmt.Sprintf("%s_%d_shard_%d", userAddr, chunkID, shardIndex)
+		return ds.node.Storage().DeleteChunk(shardKey, shardIndex)
+	}
+	return ds.client.DeleteShard(ctx, peerID, userAddr, chunkID, shardIndex)
+}
+
 // RetrieveDistributed retrieves and reconstructs data from distributed shards
+// The data shards are fetched first: when all of them arrive they are simply
+// joined, with no Reed-Solomon work. Parity shards are only requested if a data
+// shard fails or the data tier times out.
 func (ds *DistributedStorage) RetrieveDistributed(ctx context.Context, distributedChunk *DistributedChunk) ([]byte, error) {
 	if distributedChunk == nil {
 		return nil, fmt.Errorf("distributed chunk is nil")
 	}
 
+	erasure := distributedChunk.Coding()
+	encoder, err := ds.encoderFor(erasure)
+	if err != nil {
+		return nil, err
+	}
+
 	// Prepare encoded data structure
 	encoded := &EncodedData{
-		Shards:       make([][]byte, TotalShards),
+		Shards:       make([][]byte, erasure.TotalShards()),
 		ShardSize:    distributedChunk.ShardSize,
 		OriginalSize: distributedChunk.OriginalSize,
 	}
 
-	// Retrieve shards from peers in parallel
-	var wg sync.WaitGroup
-	mu := &sync.Mutex{}
-	successCount := 0
-
-	for _, location := range distributedChunk.ShardLocations {
-		wg.Add(1)
-		go func(loc ShardLocation) {
-			defer wg.Done()
-
-			shardKey := fmt.Sprintf("%s_%d_shard_%d", distributedChunk.UserAddr, distributedChunk.ChunkID, loc.ShardIndex)
+	var dataLocations, parityLocations []ShardLocation
+	for _, loc := range distributedChunk.ShardLocations {
+		if loc.ShardIndex >= len(encoded.Shards) {
+			continue // Not part of this coding; the metadata is damaged
+		}
+		if loc.ShardIndex < erasure.DataShards {
+			dataLocations = append(dataLocations, loc)
+		} else {
+			parityLocations = append(parityLocations, loc)
+		}
+	}
 
-			var shard []byte
-			var err error
+	// Fetches share a context that is cancelled once enough shards have arrived,
+	// so slow peers don't hold up (or keep streaming to) the caller
+	fetchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-			// If it's the local node, retrieve locally
-			if loc.PeerID == ds.node.ID() {
-				shard, err = ds.node.Storage().GetChunk(shardKey, loc.ShardIndex)
-			} else {
-				// Retrieve from remote peer via RPC
-				shard, err = ds.client.GetChunk(ctx, loc.PeerID, shardKey, loc.ShardIndex)
+	results := make(chan shardResult, len(distributedChunk.ShardLocations))
+	successCount := 0
+	pending := 0
+
+	// collect stores arriving shards until done reports true, the tier times out or all fetches finish
+	// In the data tier, a lost data shard ends collection early since parity is needed anyway
+	collect := func(timeout time.Duration, dataTier bool, done func() bool) error {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		for pending > 0 && !done() {
+			select {
+			case result := <-results:
+				pending--
+				if result.shard == nil {
+					if dataTier && len(parityLocations) > 0 {
+						return nil // A data shard is lost; move on to parity now
+					}
+					continue
+				}
+				encoded.Shards[result.index] = result.shard
+				successCount++
+			case <-timer.C:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
 			}
+		}
+		return nil
+	}
 
-			if err != nil {
-				fmt.Printf("Failed to retrieve shard %d from peer %s: %v\n", loc.ShardIndex, loc.PeerID, err)
-				return
-			}
+	// Tier 1: data shards only
+	for _, loc := range dataLocations {
+		pending++
+		go ds.fetchShard(fetchCtx, distributedChunk, loc, results)
+	}
+	if err := collect(ds.dataTierTimeout, true, func() bool { return hasAllDataShards(encoded, erasure.DataShards) }); err != nil {
+		return nil, err
+	}
 
-			mu.Lock()
-			encoded.Shards[loc.ShardIndex] = shard
-			successCount++
-			mu.Unlock()
-		}(location)
+	if hasAllDataShards(encoded, erasure.DataShards) {
+		return encoder.JoinDataShards(encoded)
 	}
 
-	wg.Wait()
+	// Tier 2: add parity shards; data fetches still in flight keep counting
+	fmt.Printf("⚠️  Data shards incomplete for chunk %d (%d/%d), fetching parity\n",
+		distributedChunk.ChunkID, successCount, erasure.DataShards)
+	for _, loc := range parityLocations {
+		pending++
+		go ds.fetchShard(fetchCtx, distributedChunk, loc, results)
+	}
+	if err := collect(ds.parityTierTimeout, false, func() bool { return successCount >= erasure.DataShards }); err != nil {
+		return nil, err
+	}
+	cancel() // Remaining fetches are no longer needed
 
 	// Check if we have enough shards to reconstruct
-	if successCount < MinShardsForRecovery {
-		return nil, fmt.Errorf("insufficient shards retrieved: have %d, need %d", successCount, MinShardsForRecovery)
+	if successCount < erasure.DataShards {
+		return nil, fmt.Errorf("%w retrieved: have %d, need %d", ErrInsufficientShards, successCount, erasure.DataShards)
+	}
+
+	if hasAllDataShards(encoded, erasure.DataShards) {
+		return encoder.JoinDataShards(encoded)
 	}
 
 	// Decode the data
-	data, err := ds.encoder.Decode(encoded)
+	data, err := encoder.Decode(encoded)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode data: %w", err)
 	}
@@ -237,6 +449,89 @@ func (ds *DistributedStorage) RetrieveDistributed(ctx context.Context, distribut
 	return data, nil
 }
 
+// shardResult is the outcome of one shard fetch (shard is nil on failure)
+type shardResult struct {
+	index int
+	shard []byte
+}
+
+// fetchShard retrieves one shard locally, from the cache or from its peer and reports it on results
+func (ds *DistributedStorage) fetchShard(ctx context.Context, distributedChunk *DistributedChunk, loc ShardLocation, results chan<- shardResult) {
+	shardKey := fmt.Sprintf("%s_%d_shard_%d", distributedChunk.UserAddr, distributedChunk.ChunkID, loc.ShardIndex)
+
+	var shard []byte
+	var err error
+
+	// If it's the local node, retrieve locally
+	if loc.PeerID == ds.node.ID() {
+		shard, err = ds.node.Storage().GetChunk(shardKey, loc.ShardIndex)
+	} else if cached, ok := ds.cachedShard(distributedChunk, loc.ShardIndex); ok {
+		shard = cached
+	} else {
+		// Retrieve from remote peer via RPC
+		shard, err = ds.client.GetChunk(ctx, loc.PeerID, shardKey, loc.ShardIndex)
+		if err == nil {
+			ds.cacheShard(distributedChunk, loc.ShardIndex, shard)
+		}
+	}
+
+	if err != nil {
+		if ctx.Err() == nil {
+			fmt.Printf("Failed to retrieve shard %d from peer %s: %v\n", loc.ShardIndex, loc.PeerID, err)
+		}
+		shard = nil
+	}
+
+	results <- shardResult{index: loc.ShardIndex, shard: shard}
+}
+
+// hasAllDataShards reports whether every data shard is present
+func hasAllDataShards(encoded *EncodedData, dataShards int) bool {
+	for i := 0; i < dataShards; i++ {
+		if encoded.Shards[i] == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// SetRetrievalTimeouts sets how long retrieval waits on the data tier and on the parity fallback
+func (ds *DistributedStorage) SetRetrievalTimeouts(dataTier, parityTier time.Duration) {
+	ds.dataTierTimeout = dataTier
+	ds.parityTierTimeout = parityTier
+}
+
+// shardCacheKey returns the cache key of a remote shard under a chunk's manifest
+// A chunk rewritten, repaired or rebalanced by any node gets a new UpdatedAt,
+// so shards cached under an older manifest are no longer found and age out.
+func shardCacheKey(chunk *DistributedChunk, shardIndex int) string {
+	return fmt.Sprintf("shard:%s_%d_shard_%d@%d", chunk.UserAddr, chunk.ChunkID, shardIndex, manifestTime(chunk.UpdatedAt))
+}
+
+// cachedShard returns a remote shard from the node's chunk cache
+func (ds *DistributedStorage) cachedShard(chunk *DistributedChunk, shardIndex int) ([]byte, bool) {
+	cache := ds.node.Storage().Cache()
+	if cache == nil {
+		return nil, false
+	}
+	return cache.Get(shardCacheKey(chunk, shardIndex))
+}
+
+// cacheShard keeps a remote shard in the node's chunk cache
+// Local shards are cached by LocalStorage itself and are not stored twice
+func (ds *DistributedStorage) cacheShard(chunk *DistributedChunk, shardIndex int, shard []byte) {
+	if cache := ds.node.Storage().Cache(); cache != nil {
+		cache.Put(shardCacheKey(chunk, shardIndex), shard)
+	}
+}
+
+// invalidateShards drops cached remote shards of a chunk that was rewritten or deleted
+func (ds *DistributedStorage) invalidateShards(userAddr string, chunkID int) {
+	if cache := ds.node.Storage().Cache(); cache != nil {
+		cache.RemovePrefix(fmt.Sprintf("shard:%s_%d_shard_", userAddr, chunkID))
+	}
+}
+
 // findStorageNodes finds the best nodes to store shards based on DHT proximity
 func (ds *DistributedStorage) findStorageNodes(ctx context.Context, key string, count int) ([]peer.ID, error) {
 	// Use the DHT to find closest nodes to the key
@@ -262,6 +557,30 @@ func (ds *DistributedStorage) findStorageNodes(ctx context.Context, key string,
 	return peerIDs, nil
 }
 
+// findPlacementNodes is findStorageNodes minus peers in announced maintenance or with corrupted transfers
+// Used only for new placements; lookups of existing shards must keep using findStorageNodes
+func (ds *DistributedStorage) findPlacementNodes(ctx context.Context, key string, count int) ([]peer.ID, error) {
+	peerIDs, err := ds.findStorageNodes(ctx, key, count)
+	if err != nil {
+		return nil, err
+	}
+
+	available := peerIDs[:0]
+	for _, id := range peerIDs {
+		if id != ds.node.ID() && (ds.node.PeerInMaintenance(id) || ds.node.PeerIntegrity(id).Score() < MinPlacementScore) {
+			continue
+		}
+		available = append(available, id)
+	}
+
+	// Fill up with ourselves, as findStorageNodes does when peers are scarce
+	if len(available) < count && (len(available) == 0 || available[len(available)-1] != ds.node.ID()) {
+		available = append(available, ds.node.ID())
+	}
+
+	return available, nil
+}
+
 // generateStorageKey creates a deterministic key for DHT lookups
 func generateStorageKey(userAddr string, chunkID int) string {
 	data := fmt.Sprintf("%s:%d", userAddr, chunkID)
@@ -271,25 +590,25 @@ func generateStorageKey(userAddr string, chunkID int) string {
 
 // GetShardStatus returns the status of all shards for a distributed chunk
 func (ds *DistributedStorage) GetShardStatus(ctx context.Context, distributedChunk *DistributedChunk) ([]bool, error) {
-	status := make([]bool, TotalShards)
+	return ds.shardStatus(ctx, distributedChunk, newHealthCycle(ds, false))
+}
+
+// shardStatus returns shard availability using the peer checks of a health cycle
+func (ds *DistributedStorage) shardStatus(ctx context.Context, distributedChunk *DistributedChunk, cycle *healthCycle) ([]bool, error) {
+	status := make([]bool, distributedChunk.Coding().TotalShards())
 	var wg sync.WaitGroup
 	mu := &sync.Mutex{}
 
 	for _, location := range distributedChunk.ShardLocations {
+		if location.ShardIndex < 0 || location.ShardIndex >= len(status) {
+			continue
+		}
+
 		wg.Add(1)
 		go func(loc ShardLocation) {
 			defer wg.Done()
 
-			// Try to ping the peer
-			var available bool
-			if loc.PeerID == ds.node.ID() {
-				// Local node is always available
-				available = true
-			} else {
-				// Check if peer is reachable
-				err := ds.client.Ping(ctx, loc.PeerID)
-				available = (err == nil)
-			}
+			available := cycle.shardAvailable(ctx, distributedChunk, loc)
 
 			mu.Lock()
 			status[loc.ShardIndex] = available
@@ -304,7 +623,12 @@ func (ds *DistributedStorage) GetShardStatus(ctx context.Context, distributedChu
 
 // CalculateHealth returns a health score for the distributed chunk (0.0 - 1.0)
 func (ds *DistributedStorage) CalculateHealth(ctx context.Context, distributedChunk *DistributedChunk) (float64, error) {
-	status, err := ds.GetShardStatus(ctx, distributedChunk)
+	return ds.calculateHealth(ctx, distributedChunk, newHealthCycle(ds, false))
+}
+
+// calculateHealth returns a health score using the peer checks of a health cycle
+func (ds *DistributedStorage) calculateHealth(ctx context.Context, distributedChunk *DistributedChunk, cycle *healthCycle) (float64, error) {
+	status, err := ds.shardStatus(ctx, distributedChunk, cycle)
 	if err != nil {
 		return 0, err
 	}
@@ -316,7 +640,7 @@ func (ds *DistributedStorage) CalculateHealth(ctx context.Context, distributedCh
 		}
 	}
 
-	return float64(availableCount) / float64(TotalShards), nil
+	return float64(availableCount) / float64(len(status)), nil
 }
 
 // DeleteChunk deletes a chunk from all distributed shard nodes
@@ -324,17 +648,36 @@ func (ds *DistributedStorage) DeleteChunk(ctx context.Context, userAddr string,
 	// Create deletion key
 	key := fmt.Sprintf("%s:%d", userAddr, chunkID)
 
+	// Tracked chunks record their coding and where each shard went, including
+	// pinned nodes and repair targets. Chunks stored through other nodes are
+	// looked up in the DHT; without a record they are assumed to use the
+	// current coding and the nodes closest to their key.
+	ds.chunksMu.RLock()
+	tracked := ds.chunks[key]
+	ds.chunksMu.RUnlock()
+	if tracked == nil {
+		published, err := ds.LookupShardLocations(ctx, userAddr, chunkID)
+		if err != nil && !errors.Is(err, ErrManifestNotFound) {
+			fmt.Printf("⚠️  Failed to look up shard locations of chunk %s: %v\n", key, err)
+		}
+		tracked = published
+	}
+	totalShards := ds.erasure.TotalShards()
+	if tracked != nil {
+		totalShards = tracked.Coding().TotalShards()
+	}
+
 	// Find the nodes that should have stored this chunk
-	// This returns unique peers, but we need to map them to all 15 shards
-	storageNodes, err := ds.findStorageNodes(ctx, key, TotalShards)
+	// This returns unique peers, but we need to map them to all of its shards
+	storageNodes, err := ds.findStorageNodes(ctx, key, totalShards)
 	if err != nil {
 		return fmt.Errorf("failed to find storage nodes: %w", err)
 	}
 
 	// Build shard-to-node mapping (same logic as StoreDistributed)
 	// If we don't have enough unique peers, local node stores remaining shards
-	shardNodes := make([]peer.ID, TotalShards)
-	for i := 0; i < TotalShards; i++ {
+	shardNodes := make([]peer.ID, totalShards)
+	for i := 0; i < totalShards; i++ {
 		if i < len(storageNodes) {
 			shardNodes[i] = storageNodes[i]
 		} else {
@@ -343,11 +686,19 @@ func (ds *DistributedStorage) DeleteChunk(ctx context.Context, userAddr string,
 		}
 	}
 
-	// Delete each of the 15 shards
+	if tracked != nil {
+		for _, loc := range tracked.ShardLocations {
+			if loc.PeerID != "" && loc.ShardIndex < totalShards {
+				shardNodes[loc.ShardIndex] = loc.PeerID
+			}
+		}
+	}
+
+	// Delete each shard
 	successCount := 0
 	var lastErr error
 
-	for shardIndex := 0; shardIndex < TotalShards; shardIndex++ {
+	for shardIndex := 0; shardIndex < totalShards; shardIndex++ {
 		peerID := shardNodes[shardIndex]
 
 		// If it's the local node, delete locally
@@ -372,16 +723,18 @@ func (ds *DistributedStorage) DeleteChunk(ctx context.Context, userAddr string,
 	}
 
 	// Require at least 2/3 of shards deleted
-	minRequired := (TotalShards * 2) / 3
+	minRequired := (totalShards * 2) / 3
 	if successCount < minRequired {
 		return fmt.Errorf("failed to delete enough shards (%d/%d deleted, %d required): %w",
-			successCount, TotalShards, minRequired, lastErr)
+			successCount, totalShards, minRequired, lastErr)
 	}
 
-	fmt.Printf("✅ Deleted chunk from %d/%d shard nodes\n", successCount, TotalShards)
+	fmt.Printf("✅ Deleted chunk from %d/%d shard nodes\n", successCount, totalShards)
 
-	// Unregister chunk from monitoring
+	// Unregister chunk from monitoring and tell other nodes it is gone
 	ds.UnregisterChunk(userAddr, chunkID)
+	ds.invalidateShards(userAddr, chunkID)
+	ds.publishShardLocations(ctx, userAddr, chunkID, nil)
 
 	return nil
 }
@@ -389,20 +742,37 @@ func (ds *DistributedStorage) DeleteChunk(ctx context.Context, userAddr string,
 // RepairChunk repairs a degraded chunk by recreating missing shards
 // This is called when shard count drops below HealthDegraded threshold
 func (ds *DistributedStorage) RepairChunk(ctx context.Context, distributedChunk *DistributedChunk) error {
+	return ds.repairChunk(ctx, distributedChunk, newHealthCycle(ds, false))
+}
+
+// repairChunk repairs a chunk using the peer checks of a health cycle
+func (ds *DistributedStorage) repairChunk(ctx context.Context, distributedChunk *DistributedChunk, cycle *healthCycle) error {
 	if distributedChunk == nil {
 		return fmt.Errorf("distributed chunk is nil")
 	}
 
+	// Nodes are collecting a lapsed chunk; rebuilding it would store it again for free
+	if distributedChunk.leaseLapsed(time.Now()) {
+		return fmt.Errorf("%w: chunk %d", ErrLeaseExpired, distributedChunk.ChunkID)
+	}
+
+	erasure := distributedChunk.Coding()
+	totalShards := erasure.TotalShards()
+	encoder, err := ds.encoderFor(erasure)
+	if err != nil {
+		return err
+	}
+
 	// Check current shard status
-	status, err := ds.GetShardStatus(ctx, distributedChunk)
+	status, err := ds.shardStatus(ctx, distributedChunk, cycle)
 	if err != nil {
 		return fmt.Errorf("failed to get shard status: %w", err)
 	}
 
 	// Count available shards
 	availableCount := 0
-	availableShards := make([]int, 0, TotalShards)
-	missingShards := make([]int, 0, TotalShards)
+	availableShards := make([]int, 0, totalShards)
+	missingShards := make([]int, 0, totalShards)
 
 	for i, available := range status {
 		if available {
@@ -413,21 +783,22 @@ func (ds *DistributedStorage) RepairChunk(ctx context.Context, distributedChunk
 		}
 	}
 
-	// Check if repair is needed
-	if availableCount >= HealthExcellent {
-		fmt.Printf("✅ Chunk health excellent (%d/%d shards), no repair needed\n", availableCount, TotalShards)
+	// Check if repair is needed (a chunk short of its user's pins is moved even when whole)
+	pinShort := ds.pinViolation(distributedChunk, status) != nil
+	if availableCount >= totalShards && !pinShort {
+		fmt.Printf("✅ Chunk health excellent (%d/%d shards), no repair needed\n", availableCount, totalShards)
 		return nil
 	}
 
-	if availableCount < MinShardsForRecovery {
-		return fmt.Errorf("insufficient shards for recovery: have %d, need %d", availableCount, MinShardsForRecovery)
+	if availableCount < erasure.DataShards {
+		return fmt.Errorf("%w for recovery: have %d, need %d", ErrInsufficientShards, availableCount, erasure.DataShards)
 	}
 
-	fmt.Printf("🔧 Repairing chunk: %d/%d shards available, %d missing\n", availableCount, TotalShards, len(missingShards))
+	fmt.Printf("🔧 Repairing chunk: %d/%d shards available, %d missing\n", availableCount, totalShards, len(missingShards))
 
 	// Step 1: Retrieve available shards
 	encoded := &EncodedData{
-		Shards:       make([][]byte, TotalShards),
+		Shards:       make([][]byte, totalShards),
 		ShardSize:    distributedChunk.ShardSize,
 		OriginalSize: distributedChunk.OriginalSize,
 	}
@@ -467,14 +838,14 @@ func (ds *DistributedStorage) RepairChunk(ctx context.Context, distributedChunk
 
 	wg.Wait()
 
-	if retrievedCount < MinShardsForRecovery {
-		return fmt.Errorf("failed to retrieve enough shards: got %d, need %d", retrievedCount, MinShardsForRecovery)
+	if retrievedCount < erasure.DataShards {
+		return fmt.Errorf("failed to retrieve enough shards: %w: got %d, need %d", ErrInsufficientShards, retrievedCount, erasure.DataShards)
 	}
 
 	fmt.Printf("✅ Retrieved %d shards for reconstruction\n", retrievedCount)
 
 	// Step 2: Reconstruct missing shards using erasure coding
-	err = ds.encoder.encoder.Reconstruct(encoded.Shards)
+	err = encoder.encoder.Reconstruct(encoded.Shards)
 	if err != nil {
 		return fmt.Errorf("failed to reconstruct shards: %w", err)
 	}
@@ -483,27 +854,53 @@ func (ds *DistributedStorage) RepairChunk(ctx context.Context, distributedChunk
 
 	// Step 3: Find new storage nodes for missing shards
 	key := generateStorageKey(distributedChunk.UserAddr, distributedChunk.ChunkID)
-	storageNodes, err := ds.findStorageNodes(ctx, key, TotalShards)
+	storageNodes, err := ds.findPlacementNodes(ctx, key, totalShards)
 	if err != nil {
 		return fmt.Errorf("failed to find storage nodes: %w", err)
 	}
 
-	// Build shard-to-node mapping
-	shardNodes := make([]peer.ID, TotalShards)
-	for i := 0; i < TotalShards; i++ {
-		if i < len(storageNodes) {
+	// Rebalancing must not move shards while they are reassigned here
+	ds.moveMu.Lock()
+
+	// Build shard-to-node mapping; available shards stay where they are
+	shardNodes := make([]peer.ID, totalShards)
+	for i := 0; i < totalShards; i++ {
+		if status[i] {
+			shardNodes[i] = distributedChunk.ShardLocations[i].PeerID
+		} else if i < len(storageNodes) {
 			shardNodes[i] = storageNodes[i]
 		} else {
 			shardNodes[i] = ds.node.ID()
 		}
 	}
 
+	// Missing shards go to the user's pinned nodes first; if that is not
+	// enough, available shards are moved onto them too
+	shardNodes = ds.applyPins(distributedChunk.UserAddr, shardNodes, status)
+	rewrite := append([]int(nil), missingShards...)
+	moved := make(map[int]peer.ID)
+	for _, idx := range availableShards {
+		if shardNodes[idx] != distributedChunk.ShardLocations[idx].PeerID {
+			moved[idx] = distributedChunk.ShardLocations[idx].PeerID
+			rewrite = append(rewrite, idx)
+		}
+	}
+	if len(rewrite) == 0 {
+		ds.moveMu.Unlock()
+		fmt.Printf("📌 No pinned node available, shards left in place\n")
+		return nil
+	}
+	if len(moved) > 0 {
+		fmt.Printf("📌 Moving %d shards onto pinned nodes\n", len(moved))
+	}
+
 	// Step 4: Store recreated shards on new nodes
 	successCount := 0
+	movedCount := 0
 	var storeMu sync.Mutex
 	var storeWg sync.WaitGroup
 
-	for _, shardIndex := range missingShards {
+	for _, shardIndex := range rewrite {
 		storeWg.Add(1)
 		go func(idx int) {
 			defer storeWg.Done()
@@ -511,11 +908,12 @@ func (ds *DistributedStorage) RepairChunk(ctx context.Context, distributedChunk
 			targetPeer := shardNodes[idx]
 			shardKey := fmt.Sprintf("%s_%d_shard_%d", distributedChunk.UserAddr, distributedChunk.ChunkID, idx)
 
+			// Rebuilt shards keep what is left of the chunk's lease
 			var err error
 			if targetPeer == ds.node.ID() {
-				err = ds.node.Storage().StoreChunk(shardKey, idx, encoded.Shards[idx])
+				err = ds.node.Storage().StoreChunkWithLease(shardKey, idx, encoded.Shards[idx], distributedChunk.LeaseExpires)
 			} else {
-				err = ds.client.StoreChunk(ctx, targetPeer, shardKey, idx, encoded.Shards[idx])
+				err = ds.client.StoreShardWithLease(ctx, targetPeer, shardKey, idx, encoded.Shards[idx], remainingLease(distributedChunk.LeaseExpires), erasure)
 			}
 
 			if err != nil {
@@ -523,8 +921,19 @@ func (ds *DistributedStorage) RepairChunk(ctx context.Context, distributedChunk
 				return
 			}
 
+			// A moved shard's old copy is no longer referenced
+			if oldPeer, wasMoved := moved[idx]; wasMoved {
+				if err := ds.deleteShard(ctx, distributedChunk.UserAddr, distributedChunk.ChunkID, idx, oldPeer); err != nil {
+					fmt.Printf("⚠️  Failed to delete moved shard %d from %s: %v\n", idx, oldPeer, err)
+				}
+			}
+
 			storeMu.Lock()
-			successCount++
+			if _, wasMoved := moved[idx]; wasMoved {
+				movedCount++
+			} else {
+				successCount++
+			}
 			// Update shard location in metadata
 			peerAddrs := ds.node.Host().Peerstore().Addrs(targetPeer)
 			addrs := make([]string, len(peerAddrs))
@@ -541,70 +950,98 @@ func (ds *DistributedStorage) RepairChunk(ctx context.Context, distributedChunk
 	}
 
 	storeWg.Wait()
+	if successCount > 0 || movedCount > 0 {
+		distributedChunk.UpdatedAt = time.Now()
+	}
+	ds.moveMu.Unlock()
 
-	if successCount == 0 {
+	if successCount == 0 && movedCount == 0 {
 		return fmt.Errorf("failed to store any repaired shards")
 	}
+	ds.invalidateShards(distributedChunk.UserAddr, distributedChunk.ChunkID)
+	ds.updateManifest(distributedChunk)
+	ds.publishShardLocations(ctx, distributedChunk.UserAddr, distributedChunk.ChunkID, distributedChunk)
 
-	fmt.Printf("✅ Repair complete: stored %d/%d missing shards\n", successCount, len(missingShards))
-	fmt.Printf("📊 New health: %d/%d shards available\n", availableCount+successCount, TotalShards)
+	fmt.Printf("✅ Repair complete: stored %d/%d missing shards, moved %d/%d onto pinned nodes\n",
+		successCount, len(missingShards), movedCount, len(moved))
+	fmt.Printf("📊 New health: %d/%d shards available\n", availableCount+successCount, totalShards)
 
 	return nil
 }
 
 // CheckAndRepairIfNeeded checks chunk health and repairs if below threshold
 func (ds *DistributedStorage) CheckAndRepairIfNeeded(ctx context.Context, distributedChunk *DistributedChunk) error {
+	// Health and repair share peer checks, so each peer is contacted once
+	cycle := newHealthCycle(ds, false)
+
 	// Calculate current health
-	health, err := ds.CalculateHealth(ctx, distributedChunk)
+	health, err := ds.calculateHealth(ctx, distributedChunk, cycle)
 	if err != nil {
 		return fmt.Errorf("failed to calculate health: %w", err)
 	}
 
-	availableShards := int(health * float64(TotalShards))
+	totalShards := distributedChunk.Coding().TotalShards()
+	availableShards := int(health*float64(totalShards) + 0.5)
+	_, healthGood, healthDegraded, healthCritical := distributedChunk.Coding().HealthThresholds()
 
 	// Determine if repair is needed
-	if availableShards >= HealthGood {
+	if availableShards >= healthGood {
+		if ds.pinViolation(distributedChunk, nil) != nil {
+			fmt.Printf("📌 Chunk has too few shards on pinned nodes, moving shards...\n")
+			return ds.repairChunk(ctx, distributedChunk, cycle)
+		}
 		// Health is good, no repair needed
 		return nil
 	}
 
-	if availableShards >= HealthDegraded {
-		fmt.Printf("⚠️  Chunk health degraded (%d/%d shards), triggering repair...\n", availableShards, TotalShards)
-		return ds.RepairChunk(ctx, distributedChunk)
+	if availableShards >= healthDegraded {
+		fmt.Printf("⚠️  Chunk health degraded (%d/%d shards), triggering repair...\n", availableShards, totalShards)
+		return ds.repairChunk(ctx, distributedChunk, cycle)
 	}
 
-	if availableShards >= HealthCritical {
-		fmt.Printf("🚨 Chunk health CRITICAL (%d/%d shards), urgent repair needed!\n", availableShards, TotalShards)
-		return ds.RepairChunk(ctx, distributedChunk)
+	if availableShards >= healthCritical {
+		fmt.Printf("🚨 Chunk health CRITICAL (%d/%d shards), urgent repair needed!\n", availableShards, totalShards)
+		return ds.repairChunk(ctx, distributedChunk, cycle)
 	}
 
 	// Below critical threshold - cannot recover
-	return fmt.Errorf("chunk health too low for repair: %d/%d shards (need at least %d)", availableShards, TotalShards, HealthCritical)
+	return fmt.Errorf("chunk health too low for repair: %d/%d shards (need at least %d)", availableShards, totalShards, healthCritical)
 }
 
 // RegisterChunk registers a chunk for health monitoring
+// Its manifest is saved so monitoring resumes after a restart, and published
+// to the DHT so other nodes can find its shards.
 func (ds *DistributedStorage) RegisterChunk(chunk *DistributedChunk) {
 	if chunk == nil {
 		return
 	}
 
-	key := fmt.Sprintf("%s:%d", chunk.UserAddr, chunk.ChunkID)
+	key := chunkKey(chunk.UserAddr, chunk.ChunkID)
 
 	ds.chunksMu.Lock()
 	ds.chunks[key] = chunk
 	ds.chunksMu.Unlock()
 
+	ds.saveManifest(chunk)
+
+	ctx, cancel := context.WithTimeout(context.Background(), shardRecordTimeout)
+	ds.publishShardLocations(ctx, chunk.UserAddr, chunk.ChunkID, chunk)
+	cancel()
+
 	fmt.Printf("📋 Registered chunk for monitoring: %s\n", key)
 }
 
 // UnregisterChunk removes a chunk from health monitoring
 func (ds *DistributedStorage) UnregisterChunk(userAddr string, chunkID int) {
-	key := fmt.Sprintf("%s:%d", userAddr, chunkID)
+	key := chunkKey(userAddr, chunkID)
 
 	ds.chunksMu.Lock()
 	delete(ds.chunks, key)
+	delete(ds.published, key)
 	ds.chunksMu.Unlock()
 
+	ds.dropManifest(userAddr, chunkID)
+
 	fmt.Printf("📋 Unregistered chunk from monitoring: %s\n", key)
 }
 
@@ -643,16 +1080,79 @@ func (ds *DistributedStorage) monitorLoop() {
 }
 
 // checkAllChunks checks health of all registered chunks and repairs if needed
+// Chunks whose lease has run out are no longer monitored.
 func (ds *DistributedStorage) checkAllChunks() {
-	ds.chunksMu.RLock()
+	now := time.Now()
+
+	ds.chunksMu.Lock()
 	chunks := make([]*DistributedChunk, 0, len(ds.chunks))
-	for _, chunk := range ds.chunks {
+	var lapsed []*DistributedChunk
+	for key, chunk := range ds.chunks {
+		if chunk.leaseLapsed(now) {
+			delete(ds.chunks, key)
+			delete(ds.published, key)
+			lapsed = append(lapsed, chunk)
+			fmt.Printf("📅 Lease on %s ran out, no longer monitoring it\n", key)
+			continue
+		}
 		chunks = append(chunks, chunk)
 	}
+	ds.chunksMu.Unlock()
+
+	for _, chunk := range lapsed {
+		ds.dropManifest(chunk.UserAddr, chunk.ChunkID)
+	}
+
+	ds.checkChunks(chunks)
+	ds.republishShardLocations(chunks)
+}
+
+// reverifyPeer checks every monitored chunk with a shard on a peer that just left maintenance
+func (ds *DistributedStorage) reverifyPeer(peerID peer.ID) {
+	// Force a full inventory instead of trusting the pre-maintenance one
+	ds.inventoryMu.Lock()
+	delete(ds.inventories, peerID)
+	ds.inventoryMu.Unlock()
+
+	ds.chunksMu.RLock()
+	var chunks []*DistributedChunk
+	for _, chunk := range ds.chunks {
+		for _, loc := range chunk.ShardLocations {
+			if loc.PeerID == peerID {
+				chunks = append(chunks, chunk)
+				break
+			}
+		}
+	}
 	ds.chunksMu.RUnlock()
 
+	ds.checkChunks(chunks)
+}
+
+// HealthReport summarizes one health check pass
+type HealthReport struct {
+	Checked       int `json:"checked"`
+	Healthy       int `json:"healthy"`
+	Repaired      int `json:"repaired"`
+	RepairFailed  int `json:"repairFailed"`
+	Unrecoverable int `json:"unrecoverable"` // Below HealthCritical; data may be lost
+	CheckFailed   int `json:"checkFailed"`   // Health could not be determined
+
+	// Chunks left with fewer shards on their user's pinned nodes than required
+	PinViolations []PinViolation `json:"pinViolations,omitempty"`
+}
+
+// CheckChunks runs a health check over chunks now, repairing those that need it
+// Used by operators to trigger repair outside the monitoring interval
+func (ds *DistributedStorage) CheckChunks(chunks []*DistributedChunk) *HealthReport {
+	return ds.checkChunks(chunks)
+}
+
+// checkChunks runs one health cycle over chunks, repairing those that need it
+func (ds *DistributedStorage) checkChunks(chunks []*DistributedChunk) *HealthReport {
+	report := &HealthReport{Checked: len(chunks)}
 	if len(chunks) == 0 {
-		return
+		return report
 	}
 
 	fmt.Printf("\n🔍 Health check starting for %d chunks...\n", len(chunks))
@@ -660,6 +1160,27 @@ func (ds *DistributedStorage) checkAllChunks() {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 
+	// One check per peer for the whole pass, verified against its shard inventory
+	ds.pruneInventories(time.Now())
+	cycle := newHealthCycle(ds, true)
+
+	var reportMu sync.Mutex
+	count := func(field *int) {
+		reportMu.Lock()
+		*field++
+		reportMu.Unlock()
+	}
+	notePins := func(c *DistributedChunk, status []bool) {
+		v := ds.pinViolation(c, status)
+		if v == nil {
+			return
+		}
+		fmt.Printf("📌 %s:%d: %d/%d required shards on pinned nodes\n", c.UserAddr, c.ChunkID, v.PinnedShards, v.Required)
+		reportMu.Lock()
+		report.PinViolations = append(report.PinViolations, *v)
+		reportMu.Unlock()
+	}
+
 	var wg sync.WaitGroup
 	for _, chunk := range chunks {
 		wg.Add(1)
@@ -669,44 +1190,79 @@ func (ds *DistributedStorage) checkAllChunks() {
 			key := fmt.Sprintf("%s:%d", c.UserAddr, c.ChunkID)
 
 			// Calculate health
-			health, err := ds.CalculateHealth(ctx, c)
+			status, err := ds.shardStatus(ctx, c, cycle)
 			if err != nil {
 				fmt.Printf("⚠️  %s: failed to check health: %v\n", key, err)
+				count(&report.CheckFailed)
 				return
 			}
 
-			availableShards := int(health * float64(TotalShards))
+			availableShards := 0
+			for _, available := range status {
+				if available {
+					availableShards++
+				}
+			}
+			totalShards := len(status)
+			_, healthGood, healthDegraded, healthCritical := c.Coding().HealthThresholds()
 
 			// Check if repair is needed
-			if availableShards >= HealthGood {
-				// Health is good
-				fmt.Printf("✅ %s: health excellent (%d/%d shards)\n", key, availableShards, TotalShards)
+			if availableShards >= healthGood {
+				if ds.pinViolation(c, status) == nil {
+					// Health is good
+					fmt.Printf("✅ %s: health excellent (%d/%d shards)\n", key, availableShards, totalShards)
+					count(&report.Healthy)
+					return
+				}
+
+				fmt.Printf("📌 %s: too few shards on pinned nodes, moving shards...\n", key)
+				if err := ds.repairChunk(ctx, c, cycle); err != nil {
+					fmt.Printf("❌ %s: pin repair failed: %v\n", key, err)
+					count(&report.RepairFailed)
+					notePins(c, status)
+					return
+				}
+				count(&report.Repaired)
+				notePins(c, nil)
 				return
 			}
 
-			if availableShards >= HealthDegraded {
-				fmt.Printf("⚠️  %s: health degraded (%d/%d shards), triggering repair...\n", key, availableShards, TotalShards)
-				if err := ds.RepairChunk(ctx, c); err != nil {
+			if availableShards >= healthDegraded {
+				fmt.Printf("⚠️  %s: health degraded (%d/%d shards), triggering repair...\n", key, availableShards, totalShards)
+				if err := ds.repairChunk(ctx, c, cycle); err != nil {
 					fmt.Printf("❌ %s: repair failed: %v\n", key, err)
+					count(&report.RepairFailed)
+					notePins(c, status)
+					return
 				}
+				count(&report.Repaired)
+				notePins(c, nil)
 				return
 			}
 
-			if availableShards >= HealthCritical {
-				fmt.Printf("🚨 %s: health CRITICAL (%d/%d shards), urgent repair!\n", key, availableShards, TotalShards)
-				if err := ds.RepairChunk(ctx, c); err != nil {
+			if availableShards >= healthCritical {
+				fmt.Printf("🚨 %s: health CRITICAL (%d/%d shards), urgent repair!\n", key, availableShards, totalShards)
+				if err := ds.repairChunk(ctx, c, cycle); err != nil {
 					fmt.Printf("❌ %s: critical repair failed: %v\n", key, err)
+					count(&report.RepairFailed)
+					notePins(c, status)
+					return
 				}
+				count(&report.Repaired)
+				notePins(c, nil)
 				return
 			}
 
 			// Below critical - data may be lost
-			fmt.Printf("💀 %s: health too low (%d/%d shards), cannot recover\n", key, availableShards, TotalShards)
+			fmt.Printf("💀 %s: health too low (%d/%d shards), cannot recover\n", key, availableShards, totalShards)
+			count(&report.Unrecoverable)
+			notePins(c, status)
 		}(chunk)
 	}
 
 	wg.Wait()
 	fmt.Printf("🔍 Health check completed\n\n")
+	return report
 }
 
 // SetMonitorInterval changes the monitoring interval
@@ -45,10 +45,14 @@ func TestNewDistributedStorage(t *testing.T) {
 		t.Fatal("Node is nil")
 	}
 
-	if ds.encoder == nil {
+	if ds.encoders[ds.Erasure()] == nil {
 		t.Fatal("Encoder is nil")
 	}
 
+	if ds.Erasure() != DefaultErasureConfig() {
+		t.Fatalf("Expected default erasure coding, got %+v", ds.Erasure())
+	}
+
 	if ds.client == nil {
 		t.Fatal("RPC client is nil")
 	}
@@ -1364,3 +1368,100 @@ func TestCheckAndRepairIfNeeded(t *testing.T) {
 		t.Log("✓ Critical health repaired successfully")
 	})
 }
+
+func TestDistributedErasureConfig(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	node, err := NewDHTNode(ctx, &NodeConfig{
+		Port:    0,
+		DataDir: filepath.Join(t.TempDir(), "node1"),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create DHT node: %v", err)
+	}
+	defer node.Close()
+
+	if _, err := NewDistributedStorageWithErasure(node, ErasureConfig{DataShards: 4, ParityShards: 0}); err == nil {
+		t.Fatal("Expected an invalid erasure config to be rejected")
+	}
+
+	// A chunk stored before the coding was configurable
+	legacy, err := NewDistributedStorage(node)
+	if err != nil {
+		t.Fatalf("Failed to create distributed storage: %v", err)
+	}
+	defer legacy.StopMonitoring()
+
+	userAddr := "0x1234567890abcdef1234567890abcdef12345678"
+	oldData := []byte("stored under the default 10+5 coding")
+	oldChunk, err := legacy.StoreDistributed(ctx, userAddr, 1, oldData)
+	if err != nil {
+		t.Fatalf("Failed to store distributed: %v", err)
+	}
+	oldChunk.Erasure = ErasureConfig{}
+
+	erasure := ErasureConfig{DataShards: 4, ParityShards: 2, MinRecovery: 5}
+	ds, err := NewDistributedStorageWithErasure(node, erasure)
+	if err != nil {
+		t.Fatalf("Failed to create distributed storage: %v", err)
+	}
+	defer ds.StopMonitoring()
+
+	newData := []byte("stored under a 4+2 coding")
+	chunk, err := ds.StoreDistributed(ctx, userAddr, 2, newData)
+	if err != nil {
+		t.Fatalf("Failed to store distributed: %v", err)
+	}
+	if len(chunk.ShardLocations) != 6 || chunk.Coding() != erasure {
+		t.Fatalf("Expected 6 shards with %+v, got %d with %+v", erasure, len(chunk.ShardLocations), chunk.Coding())
+	}
+
+	// Lose two shards: the chunk is still decodable and repair rebuilds them
+	for _, idx := range []int{0, 5} {
+		shardKey := fmt.Sprintf("%s_%d_shard_%d", userAddr, 2, idx)
+		if err := node.Storage().DeleteChunk(shardKey, idx); err != nil {
+			t.Fatalf("Failed to delete shard %d: %v", idx, err)
+		}
+	}
+	retrieved, err := ds.RetrieveDistributed(ctx, chunk)
+	if err != nil {
+		t.Fatalf("Failed to retrieve 4+2 chunk: %v", err)
+	}
+	if !bytes.Equal(retrieved, newData) {
+		t.Fatal("4+2 chunk data mismatch")
+	}
+	if err := ds.RepairChunk(ctx, chunk); err != nil {
+		t.Fatalf("Failed to repair 4+2 chunk: %v", err)
+	}
+	if health, _ := ds.CalculateHealth(ctx, chunk); health != 1 {
+		t.Fatalf("Expected full health after repair, got %.2f", health)
+	}
+
+	// The old chunk still decodes with the coding it was stored under
+	retrieved, err = ds.RetrieveDistributed(ctx, oldChunk)
+	if err != nil {
+		t.Fatalf("Failed to retrieve legacy chunk: %v", err)
+	}
+	if !bytes.Equal(retrieved, oldData) {
+		t.Fatal("Legacy chunk data mismatch")
+	}
+	if status, _ := ds.GetShardStatus(ctx, oldChunk); len(status) != TotalShards {
+		t.Fatalf("Expected %d shard statuses for the legacy chunk, got %d", TotalShards, len(status))
+	}
+}
+
+func TestCheckShardIndex(t *testing.T) {
+	if err := checkShardIndex(40, 0, 0); err != nil {
+		t.Fatalf("Requests without a coding should not be checked: %v", err)
+	}
+	if err := checkShardIndex(5, 4, 2); err != nil {
+		t.Fatalf("Shard 5 of a 4+2 chunk should be accepted: %v", err)
+	}
+	if err := checkShardIndex(6, 4, 2); err == nil {
+		t.Fatal("Shard 6 of a 4+2 chunk should be rejected")
+	}
+	if err := checkShardIndex(0, 4, -1); err == nil {
+		t.Fatal("An invalid coding should be rejected")
+	}
+}
@@ -2,22 +2,27 @@
 package meshstorage
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/klauspost/reedsolomon"
 )
 
 const (
-	// DataShards is the number of data shards (10)
+	// DataShards is the default number of data shards (10)
 	DataShards = 10
-	// ParityShards is the number of parity shards (5)
+	// ParityShards is the default number of parity shards (5)
 	ParityShards = 5
-	// TotalShards is the total number of shards (15)
+	// TotalShards is the default total number of shards (15)
 	TotalShards = DataShards + ParityShards
-	// MinShardsForRecovery is the minimum number of shards needed to reconstruct data
+	// MinShardsForRecovery is the minimum number of shards needed to reconstruct data by default
 	MinShardsForRecovery = DataShards
 
-	// Health thresholds for automatic repair
+	// MaxTotalShards is the most shards a chunk can be split into
+	MaxTotalShards = 256
+
+	// Health thresholds for automatic repair under the default configuration
+	// (see ErasureConfig.HealthThresholds for others)
 	// HealthExcellent: All shards available (15/15)
 	HealthExcellent = 15
 	// HealthGood: Minor redundancy loss (13-14/15) - monitor but don't repair yet
@@ -28,32 +33,123 @@ const (
 	HealthCritical = 10
 )
 
+// ErrInsufficientShards is returned when fewer than MinShardsForRecovery shards are available
+var ErrInsufficientShards = errors.New("insufficient shards")
+
+// ErasureConfig is how chunks are split into shards
+// Any DataShards of the DataShards+ParityShards shards rebuild a chunk, which
+// takes (DataShards+ParityShards)/DataShards times its size to store. More
+// parity survives more lost nodes; more data shards lower the overhead.
+type ErasureConfig struct {
+	DataShards   int `json:"dataShards"`
+	ParityShards int `json:"parityShards"`
+	// MinRecovery is how many shards must be stored (or renewed) for an upload to succeed
+	// It is at least DataShards, the fewest that rebuild the chunk (0 = DataShards);
+	// a higher value keeps some redundancy from the start.
+	MinRecovery int `json:"minRecovery,omitempty"`
+}
+
+// DefaultErasureConfig returns the 10+5 configuration chunks used before it was configurable
+func DefaultErasureConfig() ErasureConfig {
+	return ErasureConfig{
+		DataShards:   DataShards,
+		ParityShards: ParityShards,
+		MinRecovery:  MinShardsForRecovery,
+	}
+}
+
+// withDefaults returns the configuration with unset values filled in
+// The zero value is DefaultErasureConfig, which is also what chunks stored
+// without a recorded configuration used.
+func (cfg ErasureConfig) withDefaults() ErasureConfig {
+	if cfg.DataShards == 0 && cfg.ParityShards == 0 {
+		return DefaultErasureConfig()
+	}
+	if cfg.MinRecovery == 0 {
+		cfg.MinRecovery = cfg.DataShards
+	}
+	return cfg
+}
+
+// Validate checks the configuration can encode chunks
+func (cfg ErasureConfig) Validate() error {
+	cfg = cfg.withDefaults()
+
+	if cfg.DataShards < 1 {
+		return fmt.Errorf("data shards must be at least 1, is %d", cfg.DataShards)
+	}
+	if cfg.ParityShards < 1 {
+		return fmt.Errorf("parity shards must be at least 1, is %d", cfg.ParityShards)
+	}
+	if total := cfg.TotalShards(); total > MaxTotalShards {
+		return fmt.Errorf("total shards must be at most %d, is %d", MaxTotalShards, total)
+	}
+	if cfg.MinRecovery < cfg.DataShards || cfg.MinRecovery > cfg.TotalShards() {
+		return fmt.Errorf("min recovery must be %d to %d shards, is %d", cfg.DataShards, cfg.TotalShards(), cfg.MinRecovery)
+	}
+	return nil
+}
+
+// TotalShards returns the number of shards a chunk is split into
+func (cfg ErasureConfig) TotalShards() int {
+	cfg = cfg.withDefaults()
+	return cfg.DataShards + cfg.ParityShards
+}
+
+// HealthThresholds returns the shard counts at which a chunk is excellent, good, degraded and critical
+// They scale the default 15/13/11/10 with the parity: a chunk is excellent
+// with every shard, good while it has lost less than 2/5 of its parity,
+// degraded after that, and critical with just enough shards to rebuild it.
+func (cfg ErasureConfig) HealthThresholds() (excellent, good, degraded, critical int) {
+	cfg = cfg.withDefaults()
+	total := cfg.TotalShards()
+	return total, total - cfg.ParityShards*2/5, total - cfg.ParityShards*4/5, cfg.DataShards
+}
+
+// String formats the configuration as data+parity
+func (cfg ErasureConfig) String() string {
+	cfg = cfg.withDefaults()
+	return fmt.Sprintf("%d+%d", cfg.DataShards, cfg.ParityShards)
+}
+
 // ErasureEncoder handles erasure coding of data
 type ErasureEncoder struct {
 	encoder reedsolomon.Encoder
+	config  ErasureConfig
 }
 
 // EncodedData represents data split into shards
 type EncodedData struct {
-	Shards     [][]byte // All 15 shards (10 data + 5 parity)
-	ShardSize  int      // Size of each shard in bytes
-	OriginalSize int    // Original data size in bytes
+	Shards       [][]byte // All shards, data first then parity
+	ShardSize    int      // Size of each shard in bytes
+	OriginalSize int      // Original data size in bytes
 }
 
-// NewErasureEncoder creates a new erasure encoder
-func NewErasureEncoder() (*ErasureEncoder, error) {
-	enc, err := reedsolomon.New(DataShards, ParityShards)
+// NewErasureEncoder creates a new erasure encoder (the zero config is DefaultErasureConfig)
+func NewErasureEncoder(config ErasureConfig) (*ErasureEncoder, error) {
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid erasure config: %w", err)
+	}
+	config = config.withDefaults()
+
+	enc, err := reedsolomon.New(config.DataShards, config.ParityShards)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Reed-Solomon encoder: %w", err)
 	}
 
 	return &ErasureEncoder{
 		encoder: enc,
+		config:  config,
 	}, nil
 }
 
+// Config returns the configuration the encoder splits data with
+func (e *ErasureEncoder) Config() ErasureConfig {
+	return e.config
+}
+
 // Encode splits data into shards using Reed-Solomon encoding
-// Returns 15 shards (10 data + 5 parity), where any 10 shards can reconstruct the original data
+// Returns DataShards+ParityShards shards, where any DataShards of them can reconstruct the original data
 func (e *ErasureEncoder) Encode(data []byte) (*EncodedData, error) {
 	if len(data) == 0 {
 		return nil, fmt.Errorf("cannot encode empty data")
@@ -83,15 +179,16 @@ func (e *ErasureEncoder) Encode(data []byte) (*EncodedData, error) {
 }
 
 // Decode reconstructs original data from available shards
-// Requires at least 10 of the 15 shards to succeed
+// Requires at least DataShards of the shards to succeed
 // Missing shards should be nil in the input slice
 func (e *ErasureEncoder) Decode(encodedData *EncodedData) ([]byte, error) {
 	if encodedData == nil {
 		return nil, fmt.Errorf("encoded data is nil")
 	}
 
-	if len(encodedData.Shards) != TotalShards {
-		return nil, fmt.Errorf("invalid number of shards: expected %d, got %d", TotalShards, len(encodedData.Shards))
+	total := e.config.TotalShards()
+	if len(encodedData.Shards) != total {
+		return nil, fmt.Errorf("invalid number of shards: expected %d, got %d", total, len(encodedData.Shards))
 	}
 
 	// Count available shards
@@ -102,12 +199,12 @@ func (e *ErasureEncoder) Decode(encodedData *EncodedData) ([]byte, error) {
 		}
 	}
 
-	if availableCount < MinShardsForRecovery {
-		return nil, fmt.Errorf("insufficient shards for recovery: have %d, need %d", availableCount, MinShardsForRecovery)
+	if availableCount < e.config.DataShards {
+		return nil, fmt.Errorf("%w for recovery: have %d, need %d", ErrInsufficientShards, availableCount, e.config.DataShards)
 	}
 
 	// Make a copy of shards to avoid modifying the original
-	shardsCopy := make([][]byte, TotalShards)
+	shardsCopy := make([][]byte, total)
 	copy(shardsCopy, encodedData.Shards)
 
 	// Verify shards and reconstruct missing ones
@@ -126,7 +223,7 @@ func (e *ErasureEncoder) Decode(encodedData *EncodedData) ([]byte, error) {
 
 	// Join the data shards back together
 	buf := make([]byte, 0, encodedData.OriginalSize)
-	for i := 0; i < DataShards; i++ {
+	for i := 0; i < e.config.DataShards; i++ {
 		buf = append(buf, shardsCopy[i]...)
 	}
 
@@ -138,10 +235,39 @@ func (e *ErasureEncoder) Decode(encodedData *EncodedData) ([]byte, error) {
 	return buf, nil
 }
 
+// JoinDataShards reassembles original data directly from the data shards
+// It skips reconstruction and parity verification entirely, so it is only valid
+// when all data shards are present; use Decode otherwise
+func (e *ErasureEncoder) JoinDataShards(encodedData *EncodedData) ([]byte, error) {
+	if encodedData == nil {
+		return nil, fmt.Errorf("encoded data is nil")
+	}
+
+	dataShards := e.config.DataShards
+	if len(encodedData.Shards) < dataShards {
+		return nil, fmt.Errorf("invalid number of shards: expected at least %d, got %d", dataShards, len(encodedData.Shards))
+	}
+
+	buf := make([]byte, 0, encodedData.OriginalSize)
+	for i := 0; i < dataShards; i++ {
+		if encodedData.Shards[i] == nil {
+			return nil, fmt.Errorf("data shard %d is missing", i)
+		}
+		buf = append(buf, encodedData.Shards[i]...)
+	}
+
+	if len(buf) < encodedData.OriginalSize {
+		return nil, fmt.Errorf("data shards too short: have %d bytes, need %d", len(buf), encodedData.OriginalSize)
+	}
+
+	// Trim to original size (remove padding)
+	return buf[:encodedData.OriginalSize], nil
+}
+
 // VerifyShards checks if the shards are valid and can reconstruct data
 func (e *ErasureEncoder) VerifyShards(shards [][]byte) (bool, error) {
-	if len(shards) != TotalShards {
-		return false, fmt.Errorf("invalid number of shards: expected %d, got %d", TotalShards, len(shards))
+	if total := e.config.TotalShards(); len(shards) != total {
+		return false, fmt.Errorf("invalid number of shards: expected %d, got %d", total, len(shards))
 	}
 
 	return e.encoder.Verify(shards)
@@ -149,38 +275,57 @@ func (e *ErasureEncoder) VerifyShards(shards [][]byte) (bool, error) {
 
 // ErasureShardInfo contains metadata about erasure coding shard distribution
 type ErasureShardInfo struct {
-	ShardIndex    int    // Index of this shard (0-14)
-	IsDataShard   bool   // True if data shard (0-9), false if parity shard (10-14)
-	ShardSize     int    // Size of this shard in bytes
-	OriginalSize  int    // Original data size before encoding
-	TotalShards   int    // Total number of shards
-	MinForRecovery int   // Minimum shards needed for recovery
+	ShardIndex     int  // Index of this shard (0 to TotalShards-1)
+	IsDataShard    bool // True if data shard (below DataShards), false if parity shard
+	ShardSize      int  // Size of this shard in bytes
+	OriginalSize   int  // Original data size before encoding
+	TotalShards    int  // Total number of shards
+	MinForRecovery int  // Minimum shards needed for recovery
 }
 
-// GetShardInfo returns metadata for a given shard index
+// GetShardInfo returns metadata for a given shard index under the default configuration
 func GetShardInfo(shardIndex int, shardSize int, originalSize int) (*ErasureShardInfo, error) {
-	if shardIndex < 0 || shardIndex >= TotalShards {
-		return nil, fmt.Errorf("invalid shard index: %d (must be 0-%d)", shardIndex, TotalShards-1)
+	return DefaultErasureConfig().ShardInfo(shardIndex, shardSize, originalSize)
+}
+
+// ShardInfo returns metadata for a given shard index
+func (cfg ErasureConfig) ShardInfo(shardIndex int, shardSize int, originalSize int) (*ErasureShardInfo, error) {
+	cfg = cfg.withDefaults()
+	total := cfg.TotalShards()
+	if shardIndex < 0 || shardIndex >= total {
+		return nil, fmt.Errorf("invalid shard index: %d (must be 0-%d)", shardIndex, total-1)
 	}
 
 	return &ErasureShardInfo{
-		ShardIndex:    shardIndex,
-		IsDataShard:   shardIndex < DataShards,
-		ShardSize:     shardSize,
-		OriginalSize:  originalSize,
-		TotalShards:   TotalShards,
-		MinForRecovery: MinShardsForRecovery,
+		ShardIndex:     shardIndex,
+		IsDataShard:    shardIndex < cfg.DataShards,
+		ShardSize:      shardSize,
+		OriginalSize:   originalSize,
+		TotalShards:    total,
+		MinForRecovery: cfg.DataShards,
 	}, nil
 }
 
 // CalculateRedundancy returns the redundancy factor (storage overhead)
 // For 10+5 configuration, this is 1.5x (store 15 shards, need 10)
 func CalculateRedundancy() float64 {
-	return float64(TotalShards) / float64(DataShards)
+	return DefaultErasureConfig().Redundancy()
 }
 
 // CalculateFaultTolerance returns how many shards can be lost while still recovering data
 // For 10+5 configuration, can lose up to 5 shards
 func CalculateFaultTolerance() int {
-	return TotalShards - MinShardsForRecovery
+	return DefaultErasureConfig().FaultTolerance()
+}
+
+// Redundancy returns the storage overhead: bytes stored per byte of data
+func (cfg ErasureConfig) Redundancy() float64 {
+	cfg = cfg.withDefaults()
+	return float64(cfg.TotalShards()) / float64(cfg.DataShards)
+}
+
+// FaultTolerance returns how many shards can be lost while still recovering data
+func (cfg ErasureConfig) FaultTolerance() int {
+	cfg = cfg.withDefaults()
+	return cfg.TotalShards() - cfg.DataShards
 }
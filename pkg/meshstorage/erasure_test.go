@@ -2,12 +2,13 @@ package meshstorage
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"testing"
 )
 
 func TestErasureEncoderCreation(t *testing.T) {
-	encoder, err := NewErasureEncoder()
+	encoder, err := NewErasureEncoder(DefaultErasureConfig())
 	if err != nil {
 		t.Fatalf("Failed to create encoder: %v", err)
 	}
@@ -20,7 +21,7 @@ func TestErasureEncoderCreation(t *testing.T) {
 }
 
 func TestBasicEncodeAndDecode(t *testing.T) {
-	encoder, err := NewErasureEncoder()
+	encoder, err := NewErasureEncoder(DefaultErasureConfig())
 	if err != nil {
 		t.Fatalf("Failed to create encoder: %v", err)
 	}
@@ -59,7 +60,7 @@ func TestBasicEncodeAndDecode(t *testing.T) {
 }
 
 func TestRecoveryWithMissingShards(t *testing.T) {
-	encoder, err := NewErasureEncoder()
+	encoder, err := NewErasureEncoder(DefaultErasureConfig())
 	if err != nil {
 		t.Fatalf("Failed to create encoder: %v", err)
 	}
@@ -114,8 +115,8 @@ func TestRecoveryWithMissingShards(t *testing.T) {
 
 				t.Logf("Successfully recovered data with %d missing shards", tc.missingShard)
 			} else {
-				if err == nil {
-					t.Fatalf("Expected decode to fail with %d missing shards, but it succeeded", tc.missingShard)
+				if !errors.Is(err, ErrInsufficientShards) {
+					t.Fatalf("Expected ErrInsufficientShards with %d missing shards, got: %v", tc.missingShard, err)
 				}
 				t.Logf("Correctly failed to decode with %d missing shards: %v", tc.missingShard, err)
 			}
@@ -124,7 +125,7 @@ func TestRecoveryWithMissingShards(t *testing.T) {
 }
 
 func TestRecoveryWithMixedShardLoss(t *testing.T) {
-	encoder, err := NewErasureEncoder()
+	encoder, err := NewErasureEncoder(DefaultErasureConfig())
 	if err != nil {
 		t.Fatalf("Failed to create encoder: %v", err)
 	}
@@ -210,7 +211,7 @@ func TestRecoveryWithMixedShardLoss(t *testing.T) {
 }
 
 func TestLargeDataEncoding(t *testing.T) {
-	encoder, err := NewErasureEncoder()
+	encoder, err := NewErasureEncoder(DefaultErasureConfig())
 	if err != nil {
 		t.Fatalf("Failed to create encoder: %v", err)
 	}
@@ -247,7 +248,7 @@ func TestLargeDataEncoding(t *testing.T) {
 }
 
 func TestEmptyData(t *testing.T) {
-	encoder, err := NewErasureEncoder()
+	encoder, err := NewErasureEncoder(DefaultErasureConfig())
 	if err != nil {
 		t.Fatalf("Failed to create encoder: %v", err)
 	}
@@ -264,7 +265,7 @@ func TestEmptyData(t *testing.T) {
 }
 
 func TestVerifyShards(t *testing.T) {
-	encoder, err := NewErasureEncoder()
+	encoder, err := NewErasureEncoder(DefaultErasureConfig())
 	if err != nil {
 		t.Fatalf("Failed to create encoder: %v", err)
 	}
@@ -365,7 +366,7 @@ func TestCalculations(t *testing.T) {
 }
 
 func TestMultipleEncodeDecode(t *testing.T) {
-	encoder, err := NewErasureEncoder()
+	encoder, err := NewErasureEncoder(DefaultErasureConfig())
 	if err != nil {
 		t.Fatalf("Failed to create encoder: %v", err)
 	}
@@ -407,3 +408,98 @@ func TestMultipleEncodeDecode(t *testing.T) {
 
 	t.Log("Multiple encode/decode test passed!")
 }
+
+func TestJoinDataShards(t *testing.T) {
+	encoder, err := NewErasureEncoder(DefaultErasureConfig())
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %v", err)
+	}
+
+	originalData := []byte("Data shards alone are enough when none of them are missing")
+	encoded, err := encoder.Encode(originalData)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+
+	// Drop all parity shards; the data shards should join without reconstruction
+	for i := DataShards; i < TotalShards; i++ {
+		encoded.Shards[i] = nil
+	}
+
+	joined, err := encoder.JoinDataShards(encoded)
+	if err != nil {
+		t.Fatalf("Failed to join data shards: %v", err)
+	}
+	if !bytes.Equal(joined, originalData) {
+		t.Fatalf("Joined data doesn't match original.\nOriginal: %s\nJoined: %s", string(originalData), string(joined))
+	}
+
+	// A missing data shard must be reported rather than silently producing garbage
+	encoded.Shards[3] = nil
+	if _, err := encoder.JoinDataShards(encoded); err == nil {
+		t.Fatal("Expected error when a data shard is missing")
+	}
+}
+
+func TestErasureConfig(t *testing.T) {
+	if (ErasureConfig{}).withDefaults() != DefaultErasureConfig() {
+		t.Fatalf("Zero config should be the default, got %+v", ErasureConfig{}.withDefaults())
+	}
+
+	excellent, good, degraded, critical := DefaultErasureConfig().HealthThresholds()
+	if excellent != HealthExcellent || good != HealthGood || degraded != HealthDegraded || critical != HealthCritical {
+		t.Fatalf("Default thresholds %d/%d/%d/%d don't match the constants", excellent, good, degraded, critical)
+	}
+
+	invalid := map[string]ErasureConfig{
+		"no data":           {DataShards: 0, ParityShards: 3},
+		"no parity":         {DataShards: 4, ParityShards: 0},
+		"too many":          {DataShards: 200, ParityShards: 100},
+		"recovery too low":  {DataShards: 4, ParityShards: 2, MinRecovery: 3},
+		"recovery too high": {DataShards: 4, ParityShards: 2, MinRecovery: 7},
+	}
+	for name, cfg := range invalid {
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: expected %+v to be invalid", name, cfg)
+		}
+		if _, err := NewErasureEncoder(cfg); err == nil {
+			t.Errorf("%s: expected NewErasureEncoder to fail", name)
+		}
+	}
+
+	// A 4+2 coding survives losing any two shards
+	cfg := ErasureConfig{DataShards: 4, ParityShards: 2}
+	encoder, err := NewErasureEncoder(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %v", err)
+	}
+	if encoder.Config().MinRecovery != 4 {
+		t.Fatalf("MinRecovery should default to the data shards, got %d", encoder.Config().MinRecovery)
+	}
+	if cfg.Redundancy() != 1.5 || cfg.FaultTolerance() != 2 {
+		t.Fatalf("Unexpected redundancy %.2f or fault tolerance %d", cfg.Redundancy(), cfg.FaultTolerance())
+	}
+
+	originalData := []byte("Smaller deployments trade redundancy for fewer nodes")
+	encoded, err := encoder.Encode(originalData)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	if len(encoded.Shards) != 6 {
+		t.Fatalf("Expected 6 shards, got %d", len(encoded.Shards))
+	}
+
+	encoded.Shards[1], encoded.Shards[5] = nil, nil
+	decoded, err := encoder.Decode(encoded)
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if !bytes.Equal(decoded, originalData) {
+		t.Fatal("Decoded data doesn't match original")
+	}
+
+	encoded.Shards[2] = nil
+	if _, err := encoder.Decode(encoded); !errors.Is(err, ErrInsufficientShards) {
+		t.Fatalf("Expected ErrInsufficientShards with 3 of 6 shards, got %v", err)
+	}
+}
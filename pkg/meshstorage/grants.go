@@ -0,0 +1,193 @@
+// Package meshstorage provides distributed storage for ZenTalk encrypted chat history
+package meshstorage
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+)
+
+// ErrGrantNotFound is returned when no grant matches the owner, chunk and recipient
+var ErrGrantNotFound = errors.New("grant not found")
+
+// AccessGrant lets a recipient decrypt one of the owner's chunks
+// The chunk key is wrapped (RSA-OAEP) for the recipient's identity key and the grant
+// is signed by the owner, so storage nodes can hold and serve grants without being
+// able to read the chunk or forge access. The owner's wallet vouches for the
+// signing key, so nobody else can issue grants in the owner's name.
+type AccessGrant struct {
+	OwnerAddr      string `json:"ownerAddr"`
+	ChunkID        int    `json:"chunkID"`
+	RecipientAddr  string `json:"recipientAddr"`
+	WrappedKey     []byte `json:"wrappedKey"`            // Chunk key encrypted for the recipient
+	ContentHash    string `json:"contentHash,omitempty"` // SHA-256 of the plaintext, if known
+	OwnerPublicKey string `json:"ownerPublicKey"`        // PEM; verifies Signature
+	IssuedAt       int64  `json:"issuedAt"`
+	ExpiresAt      int64  `json:"expiresAt,omitempty"` // Unix time; 0 = no expiry
+	Signature      []byte `json:"signature"`
+	// WalletSignature is OwnerAddr's wallet over OwnerBindingMessage
+	WalletSignature []byte `json:"walletSignature,omitempty"`
+}
+
+// SigningPayload returns the bytes covered by the owner's signature
+func (g *AccessGrant) SigningPayload() []byte {
+	return []byte(fmt.Sprintf("zentalk-grant|%s|%d|%s|%s|%s|%d|%d",
+		g.OwnerAddr, g.ChunkID, g.RecipientAddr,
+		base64.StdEncoding.EncodeToString(g.WrappedKey), g.ContentHash,
+		g.IssuedAt, g.ExpiresAt))
+}
+
+// Sign signs the grant with the owner's identity key and embeds the matching public key
+func (g *AccessGrant) Sign(ownerKey *rsa.PrivateKey) error {
+	publicPEM, err := crypto.ExportPublicKeyPEM(&ownerKey.PublicKey)
+	if err != nil {
+		return fmt.Errorf("failed to export owner key: %w", err)
+	}
+	g.OwnerPublicKey = string(publicPEM)
+
+	signature, err := crypto.SignData(g.SigningPayload(), ownerKey)
+	if err != nil {
+		return fmt.Errorf("failed to sign grant: %w", err)
+	}
+	g.Signature = signature
+	return nil
+}
+
+// OwnerBindingMessage returns the text OwnerAddr's wallet signs to vouch for OwnerPublicKey
+// Wallets sign it as an Ethereum personal message (EIP-191).
+func (g *AccessGrant) OwnerBindingMessage() []byte {
+	return WalletBindingMessage("grant", g.OwnerAddr, g.OwnerPublicKey)
+}
+
+// BindWallet signs OwnerBindingMessage with the owner's wallet key
+// Call it after Sign, which sets the owner key the binding covers.
+func (g *AccessGrant) BindWallet(walletKey *ecdsa.PrivateKey) error {
+	signature, err := signWalletMessage(g.OwnerBindingMessage(), walletKey)
+	if err != nil {
+		return err
+	}
+	g.WalletSignature = signature
+	return nil
+}
+
+// VerifyWallet checks the wallet signature was made by OwnerAddr
+func (g *AccessGrant) VerifyWallet() error {
+	return verifyWalletMessage(g.OwnerAddr, g.OwnerBindingMessage(), g.WalletSignature)
+}
+
+// Verify checks the grant's signature and expiry
+func (g *AccessGrant) Verify() error {
+	if g.OwnerAddr == "" || g.RecipientAddr == "" || len(g.WrappedKey) == 0 {
+		return fmt.Errorf("incomplete grant")
+	}
+	if g.Expired() {
+		return fmt.Errorf("grant expired at %s", time.Unix(g.ExpiresAt, 0).Format(time.RFC3339))
+	}
+
+	publicKey, err := crypto.ImportPublicKeyPEM([]byte(g.OwnerPublicKey))
+	if err != nil {
+		return fmt.Errorf("invalid owner public key: %w", err)
+	}
+	if err := crypto.VerifySignature(g.SigningPayload(), g.Signature, publicKey); err != nil {
+		return fmt.Errorf("invalid grant signature: %w", err)
+	}
+	return nil
+}
+
+// Expired reports whether the grant's expiry has passed
+func (g *AccessGrant) Expired() bool {
+	return g.ExpiresAt > 0 && time.Now().Unix() >= g.ExpiresAt
+}
+
+// StoreGrant saves a grant, replacing any earlier grant for the same chunk and recipient
+func (s *LocalStorage) StoreGrant(grant *AccessGrant) error {
+	data, err := json.Marshal(grant)
+	if err != nil {
+		return fmt.Errorf("failed to marshal grant: %w", err)
+	}
+
+	query := `INSERT INTO access_grants (owner_addr, chunk_id, recipient_addr, grant_data, issued_at, expires_at)
+	          VALUES (?, ?, ?, ?, ?, ?)
+	          ON CONFLICT (owner_addr, chunk_id, recipient_addr) DO UPDATE SET
+	              grant_data = excluded.grant_data, issued_at = excluded.issued_at, expires_at = excluded.expires_at`
+
+	_, err = s.exec(query,
+		grant.OwnerAddr, grant.ChunkID, grant.RecipientAddr, data, grant.IssuedAt, grant.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to store grant: %w", err)
+	}
+	return nil
+}
+
+// GetGrant returns the grant for a chunk and recipient
+func (s *LocalStorage) GetGrant(ownerAddr string, chunkID int, recipientAddr string) (*AccessGrant, error) {
+	var data []byte
+	err := s.queryRow(`SELECT grant_data FROM access_grants WHERE owner_addr = ? AND chunk_id = ? AND recipient_addr = ?`,
+		ownerAddr, chunkID, recipientAddr).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, ErrGrantNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get grant: %w", err)
+	}
+
+	var grant AccessGrant
+	if err := json.Unmarshal(data, &grant); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal grant: %w", err)
+	}
+	return &grant, nil
+}
+
+// ListGrantsForRecipient returns unexpired grants issued to a recipient
+func (s *LocalStorage) ListGrantsForRecipient(recipientAddr string) ([]*AccessGrant, error) {
+	rows, err := s.query(`
+		SELECT grant_data FROM access_grants
+		WHERE recipient_addr = ? AND (expires_at = 0 OR expires_at > ?)
+		ORDER BY issued_at`, recipientAddr, time.Now().Unix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list grants: %w", err)
+	}
+	defer rows.Close()
+
+	var grants []*AccessGrant
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to scan grant: %w", err)
+		}
+		var grant AccessGrant
+		if err := json.Unmarshal(data, &grant); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal grant: %w", err)
+		}
+		grants = append(grants, &grant)
+	}
+	return grants, rows.Err()
+}
+
+// DeleteGrant revokes the grant for a chunk and recipient
+func (s *LocalStorage) DeleteGrant(ownerAddr string, chunkID int, recipientAddr string) error {
+	result, err := s.exec(`DELETE FROM access_grants WHERE owner_addr = ? AND chunk_id = ? AND recipient_addr = ?`,
+		ownerAddr, chunkID, recipientAddr)
+	if err != nil {
+		return fmt.Errorf("failed to delete grant: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrGrantNotFound
+	}
+	return nil
+}
+
+// DeleteGrantsForChunk revokes every grant on a chunk (used when the chunk is deleted)
+func (s *LocalStorage) DeleteGrantsForChunk(ownerAddr string, chunkID int) error {
+	if _, err := s.exec(`DELETE FROM access_grants WHERE owner_addr = ? AND chunk_id = ?`, ownerAddr, chunkID); err != nil {
+		return fmt.Errorf("failed to delete grants: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package meshstorage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	grantOwner     = "0x1111111111111111111111111111111111111111"
+	grantRecipient = "0x2222222222222222222222222222222222222222"
+)
+
+func newSignedGrant(t *testing.T, chunkID int, expiresAt int64) *AccessGrant {
+	ownerKey, err := crypto.GenerateRSAKeyPair()
+	require.NoError(t, err)
+
+	grant := &AccessGrant{
+		OwnerAddr:     grantOwner,
+		ChunkID:       chunkID,
+		RecipientAddr: grantRecipient,
+		WrappedKey:    []byte("wrapped-key"),
+		IssuedAt:      time.Now().Unix(),
+		ExpiresAt:     expiresAt,
+	}
+	require.NoError(t, grant.Sign(ownerKey))
+	return grant
+}
+
+// TestAccessGrantVerify tests grant signatures and expiry
+func TestAccessGrantVerify(t *testing.T) {
+	grant := newSignedGrant(t, 1, 0)
+	assert.NoError(t, grant.Verify())
+
+	// Redirecting the grant to another recipient breaks the signature
+	forged := *grant
+	forged.RecipientAddr = "0x3333333333333333333333333333333333333333"
+	assert.Error(t, forged.Verify())
+
+	expired := newSignedGrant(t, 1, time.Now().Add(-time.Minute).Unix())
+	assert.True(t, expired.Expired())
+	assert.Error(t, expired.Verify())
+}
+
+// TestAccessGrantWallet tests that a grant signed by a stranger's key is not bound to the owner
+func TestAccessGrantWallet(t *testing.T) {
+	walletKey, err := ethcrypto.GenerateKey()
+	require.NoError(t, err)
+	ownerKey, err := crypto.GenerateRSAKeyPair()
+	require.NoError(t, err)
+
+	grant := &AccessGrant{
+		OwnerAddr:     ethcrypto.PubkeyToAddress(walletKey.PublicKey).Hex(),
+		ChunkID:       1,
+		RecipientAddr: grantRecipient,
+		WrappedKey:    []byte("wrapped-key"),
+		IssuedAt:      time.Now().Unix(),
+	}
+	require.NoError(t, grant.Sign(ownerKey))
+	require.NoError(t, grant.BindWallet(walletKey))
+	assert.NoError(t, grant.VerifyWallet())
+
+	// A stranger signs a grant in the owner's name: valid signature, no binding
+	strangerKey, err := crypto.GenerateRSAKeyPair()
+	require.NoError(t, err)
+	strangerWallet, err := ethcrypto.GenerateKey()
+	require.NoError(t, err)
+
+	forged := *grant
+	forged.RecipientAddr = "0x3333333333333333333333333333333333333333"
+	require.NoError(t, forged.Sign(strangerKey))
+	assert.NoError(t, forged.Verify())
+	assert.Error(t, forged.VerifyWallet(), "owner's binding reused for a stranger's key")
+
+	require.NoError(t, forged.BindWallet(strangerWallet))
+	assert.Error(t, forged.VerifyWallet(), "stranger's own wallet")
+}
+
+// TestGrantStorage tests storing, listing and revoking grants
+func TestGrantStorage(t *testing.T) {
+	storage, err := NewLocalStorage(t.TempDir())
+	require.NoError(t, err)
+	defer storage.Close()
+
+	require.NoError(t, storage.StoreGrant(newSignedGrant(t, 1, 0)))
+	require.NoError(t, storage.StoreGrant(newSignedGrant(t, 2, 0)))
+	require.NoError(t, storage.StoreGrant(newSignedGrant(t, 3, time.Now().Add(-time.Minute).Unix())))
+
+	// Re-issuing replaces rather than duplicates
+	require.NoError(t, storage.StoreGrant(newSignedGrant(t, 1, 0)))
+
+	grants, err := storage.ListGrantsForRecipient(grantRecipient)
+	require.NoError(t, err)
+	assert.Len(t, grants, 2, "expired grant should not be listed")
+	for _, grant := range grants {
+		assert.NoError(t, grant.Verify())
+	}
+
+	grant, err := storage.GetGrant(grantOwner, 2, grantRecipient)
+	require.NoError(t, err)
+	assert.Equal(t, 2, grant.ChunkID)
+
+	require.NoError(t, storage.DeleteGrant(grantOwner, 2, grantRecipient))
+	_, err = storage.GetGrant(grantOwner, 2, grantRecipient)
+	assert.True(t, errors.Is(err, ErrGrantNotFound))
+	assert.True(t, errors.Is(storage.DeleteGrant(grantOwner, 2, grantRecipient), ErrGrantNotFound))
+
+	require.NoError(t, storage.DeleteGrantsForChunk(grantOwner, 1))
+	grants, err = storage.ListGrantsForRecipient(grantRecipient)
+	require.NoError(t, err)
+	assert.Empty(t, grants)
+}
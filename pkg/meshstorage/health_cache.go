@@ -0,0 +1,174 @@
+// Package meshstorage provides distributed storage for ZenTalk encrypted chat history
+package meshstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+)
+
+// inventoryTTL is how long the inventory of a disconnected peer is kept without a refresh
+// It matches how long the peerstore keeps such a peer's addresses; a peer that
+// comes back later gets a full inventory.
+var inventoryTTL = peerstore.RecentlyConnectedAddrTTL
+
+// peerInventory is the last known set of shards held by a peer
+// It is refreshed incrementally: only shards stored since asOf are fetched, and a
+// full refresh happens when the peer's total no longer matches (shards were deleted)
+type peerInventory struct {
+	shards    map[string]bool // inventoryKey -> present
+	asOf      int64           // Peer clock at the last query
+	refreshed time.Time       // Our clock at the last query
+}
+
+// peerCheck is the result of checking one peer during a health cycle
+type peerCheck struct {
+	once   sync.Once
+	alive  bool
+	shards map[string]bool // nil if the inventory is unavailable (old peer or disabled)
+}
+
+// healthCycle shares peer checks across all chunks examined in one pass,
+// so checking N chunks on M peers costs M round trips instead of N×M
+type healthCycle struct {
+	ds           *DistributedStorage
+	useInventory bool
+
+	mu     sync.Mutex
+	checks map[peer.ID]*peerCheck
+}
+
+// newHealthCycle starts a health cycle
+// With useInventory, peers are asked which shards they hold, so a reachable peer
+// that lost a shard counts it as missing; otherwise liveness alone is checked
+func newHealthCycle(ds *DistributedStorage, useInventory bool) *healthCycle {
+	return &healthCycle{
+		ds:           ds,
+		useInventory: useInventory,
+		checks:       make(map[peer.ID]*peerCheck),
+	}
+}
+
+// shardAvailable reports whether a shard location is available in this cycle
+func (hc *healthCycle) shardAvailable(ctx context.Context, chunk *DistributedChunk, loc ShardLocation) bool {
+	if loc.PeerID == hc.ds.node.ID() {
+		// Local node is always available
+		return true
+	}
+
+	if hc.ds.node.PeerInMaintenance(loc.PeerID) {
+		// Planned downtime: don't raise repair alarms; the shard is re-verified on return
+		return true
+	}
+
+	check := hc.check(ctx, loc.PeerID)
+	if !check.alive {
+		return false
+	}
+	if check.shards == nil {
+		return true
+	}
+
+	shardKey := fmt.Sprintf("%s_%d_shard_%d", chunk.UserAddr, chunk.ChunkID, loc.ShardIndex)
+	return check.shards[inventoryKey(shardKey, loc.ShardIndex)]
+}
+
+// check returns the peer's result for this cycle, contacting it at most once
+func (hc *healthCycle) check(ctx context.Context, peerID peer.ID) *peerCheck {
+	hc.mu.Lock()
+	check, ok := hc.checks[peerID]
+	if !ok {
+		check = &peerCheck{}
+		hc.checks[peerID] = check
+	}
+	hc.mu.Unlock()
+
+	check.once.Do(func() {
+		if hc.useInventory {
+			shards, err := hc.ds.refreshInventory(ctx, peerID)
+			var remoteErr *RemoteError
+			switch {
+			case err == nil:
+				check.alive = true
+				check.shards = shards
+				return
+			case errors.As(err, &remoteErr):
+				// Reachable but the inventory failed (e.g. an older node); fall back to liveness
+				check.alive = true
+				return
+			}
+		}
+
+		check.alive = hc.ds.client.Ping(ctx, peerID) == nil
+	})
+
+	return check
+}
+
+// refreshInventory brings the cached inventory of a peer up to date and returns a snapshot
+func (ds *DistributedStorage) refreshInventory(ctx context.Context, peerID peer.ID) (map[string]bool, error) {
+	ds.inventoryMu.Lock()
+	inv := ds.inventories[peerID]
+	ds.inventoryMu.Unlock()
+
+	var since int64
+	if inv != nil {
+		since = inv.asOf
+	}
+
+	infos, total, asOf, err := ds.client.GetInventory(ctx, peerID, since)
+	if err != nil {
+		return nil, err
+	}
+
+	next := &peerInventory{shards: make(map[string]bool), asOf: asOf, refreshed: time.Now()}
+	if inv != nil {
+		for key := range inv.shards {
+			next.shards[key] = true
+		}
+	}
+	for _, info := range infos {
+		next.shards[inventoryKey(info.ShardKey, info.ShardIndex)] = true
+	}
+
+	if since > 0 && len(next.shards) != total {
+		// Shards were removed since the last query; an incremental answer can't show that
+		infos, total, asOf, err = ds.client.GetInventory(ctx, peerID, 0)
+		if err != nil {
+			return nil, err
+		}
+		next = &peerInventory{shards: make(map[string]bool, total), asOf: asOf, refreshed: time.Now()}
+		for _, info := range infos {
+			next.shards[inventoryKey(info.ShardKey, info.ShardIndex)] = true
+		}
+	}
+
+	ds.inventoryMu.Lock()
+	ds.inventories[peerID] = next
+	ds.inventoryMu.Unlock()
+
+	return next.shards, nil
+}
+
+// pruneInventories drops the inventories of peers disconnected and not refreshed within inventoryTTL
+func (ds *DistributedStorage) pruneInventories(now time.Time) {
+	ds.inventoryMu.Lock()
+	defer ds.inventoryMu.Unlock()
+
+	for peerID, inv := range ds.inventories {
+		if now.Sub(inv.refreshed) > inventoryTTL && ds.node.Host().Network().Connectedness(peerID) != network.Connected {
+			delete(ds.inventories, peerID)
+		}
+	}
+}
+
+// inventoryKey identifies a stored shard in a peer inventory
+func inventoryKey(shardKey string, shardIndex int) string {
+	return fmt.Sprintf("%s#%d", shardKey, shardIndex)
+}
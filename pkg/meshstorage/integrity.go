@@ -0,0 +1,137 @@
+package meshstorage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	// MinPlacementScore is the integrity score below which a peer is given no new shards
+	MinPlacementScore = 0.8
+
+	// integrityPrior is how many clean transfers a peer is credited with before its first one
+	// It keeps a single corrupted transfer from a new peer from ruling it out.
+	integrityPrior = 10
+)
+
+// ErrChecksumMismatch is returned when shard data still fails its checksum after a retry
+var ErrChecksumMismatch = errors.New("shard checksum mismatch")
+
+// shardChecksum returns the checksum shard data is transferred with (hex SHA-256)
+func shardChecksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// PeerIntegrity counts a peer's checksummed shard transfers
+type PeerIntegrity struct {
+	Transfers      uint64    `json:"transfers"` // Including corrupted ones and retries
+	Corrupted      uint64    `json:"corrupted"`
+	LastCorruption time.Time `json:"lastCorruption,omitempty"`
+}
+
+// CorruptionRate returns the share of the peer's transfers that failed their checksum
+func (p PeerIntegrity) CorruptionRate() float64 {
+	if p.Transfers == 0 {
+		return 0
+	}
+	return float64(p.Corrupted) / float64(p.Transfers)
+}
+
+// Score rates the peer from 0 to 1 (no corruption seen); peers under MinPlacementScore get no new shards
+func (p PeerIntegrity) Score() float64 {
+	return 1 - float64(p.Corrupted)/float64(p.Transfers+integrityPrior)
+}
+
+// integrityTracker counts checksummed transfers per peer
+type integrityTracker struct {
+	mu    sync.Mutex
+	peers map[peer.ID]*PeerIntegrity
+}
+
+// newIntegrityTracker creates a tracker with no transfers recorded
+func newIntegrityTracker() *integrityTracker {
+	return &integrityTracker{
+		peers: make(map[peer.ID]*PeerIntegrity),
+	}
+}
+
+// record counts one transfer with a peer
+func (t *integrityTracker) record(peerID peer.ID, corrupted bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.peers[peerID]
+	if !ok {
+		p = &PeerIntegrity{}
+		t.peers[peerID] = p
+	}
+	p.Transfers++
+	if corrupted {
+		p.Corrupted++
+		p.LastCorruption = time.Now()
+	}
+}
+
+// PeerIntegrity returns a peer's transfer counts
+func (n *DHTNode) PeerIntegrity(peerID peer.ID) PeerIntegrity {
+	t := n.integrity
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if p, ok := t.peers[peerID]; ok {
+		return *p
+	}
+	return PeerIntegrity{}
+}
+
+// IntegrityStats returns the transfer counts of every peer shards were exchanged with
+func (n *DHTNode) IntegrityStats() map[peer.ID]PeerIntegrity {
+	t := n.integrity
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := make(map[peer.ID]PeerIntegrity, len(t.peers))
+	for id, p := range t.peers {
+		stats[id] = *p
+	}
+	return stats
+}
+
+// sendVerified sends a shard transfer and checks the shard against its checksum, retrying once on mismatch
+// sent is the checksum of the shard a store request carries; for reads it is
+// empty and the shard in the response is checked. Responses from peers that
+// predate checksums are returned unchecked.
+func (c *RPCClient) sendVerified(ctx context.Context, peerID peer.ID, msg RPCMessage, sent string) (*RPCResponse, error) {
+	for attempt := 1; ; attempt++ {
+		response, err := c.sendRequest(ctx, peerID, msg)
+		if err != nil {
+			return nil, err
+		}
+		if response.Checksum == "" && !response.ChecksumMismatch {
+			return response, nil
+		}
+
+		want := sent
+		if want == "" {
+			want = shardChecksum(response.Data)
+		}
+		corrupted := response.ChecksumMismatch || response.Checksum != want
+		c.node.integrity.record(peerID, corrupted)
+		if !corrupted {
+			return response, nil
+		}
+
+		fmt.Printf("⚠️  Transfer of %s with %s failed its checksum (attempt %d)\n", msg.ID, peerID, attempt)
+		if attempt == 2 {
+			return nil, fmt.Errorf("%w: %s with %s", ErrChecksumMismatch, msg.ID, peerID)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package meshstorage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newIntegrityPair creates a server and a client node connected to it, without RPC handlers
+func newIntegrityPair(t *testing.T) (server, client *DHTNode) {
+	ctx := context.Background()
+
+	server, err := NewDHTNode(ctx, &NodeConfig{Port: 0, DataDir: t.TempDir()})
+	require.NoError(t, err)
+	t.Cleanup(func() { server.Close() })
+
+	client, err = NewDHTNode(ctx, &NodeConfig{Port: 0, DataDir: t.TempDir()})
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	peerAddr := server.Addresses()[0].String() + "/p2p/" + server.ID().String()
+	require.NoError(t, client.Connect(ctx, peerAddr))
+	return server, client
+}
+
+// serveCorruptShards answers every request with shard, flipping a byte in the first corrupt answers
+func serveCorruptShards(server *DHTNode, shard []byte, corrupt int) {
+	h := NewRPCHandler(server)
+	served := 0
+	server.host.SetStreamHandler(ProtocolID, func(stream network.Stream) {
+		defer stream.Close()
+
+		var msg RPCMessage
+		if err := json.NewDecoder(stream).Decode(&msg); err != nil {
+			return
+		}
+
+		data := append([]byte(nil), shard...)
+		if served < corrupt {
+			data[0] ^= 0xFF
+		}
+		served++
+		h.sendResponse(stream, msg.ID, RPCResponse{Success: true, Data: data, Checksum: shardChecksum(shard)})
+	})
+}
+
+// TestShardChecksumRetry tests that a corrupted shard is fetched again once, and counted against its peer
+func TestShardChecksumRetry(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	shard := []byte("shard data")
+	server, client := newIntegrityPair(t)
+	rpc := NewRPCClient(client)
+
+	// One bad transfer: the retry gets the shard
+	serveCorruptShards(server, shard, 1)
+	data, err := rpc.GetChunk(ctx, server.ID(), "0xsum_1_shard_0", 0)
+	require.NoError(t, err)
+	assert.Equal(t, shard, data)
+	assert.Equal(t, uint64(2), client.PeerIntegrity(server.ID()).Transfers)
+	assert.Equal(t, uint64(1), client.PeerIntegrity(server.ID()).Corrupted)
+
+	// Corrupted every time: no more than one retry
+	serveCorruptShards(server, shard, 100)
+	_, err = rpc.GetShard(ctx, server.ID(), "0xsum_1_shard_0")
+	assert.True(t, errors.Is(err, ErrChecksumMismatch), "got %v", err)
+
+	stats := client.PeerIntegrity(server.ID())
+	assert.Equal(t, uint64(4), stats.Transfers)
+	assert.Equal(t, uint64(3), stats.Corrupted)
+	assert.False(t, stats.LastCorruption.IsZero())
+	assert.Contains(t, client.IntegrityStats(), server.ID())
+}
+
+// TestStoreRejectsCorruptShard tests that nodes refuse shards that do not match their checksum
+func TestStoreRejectsCorruptShard(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	server, client := newIntegrityPair(t)
+	NewRPCHandler(server).SetupStreamHandler()
+	rpc := NewRPCClient(client)
+
+	req := StoreChunkRequest{UserAddr: "0xsum_2_shard_0", ChunkID: 0, Data: []byte("shard"), Checksum: shardChecksum([]byte("other"))}
+	payload, err := json.Marshal(req)
+	require.NoError(t, err)
+	response, err := rpc.sendRequest(ctx, server.ID(), RPCMessage{Type: MsgTypeStoreChunk, ID: "corrupt", Payload: payload})
+	require.NoError(t, err)
+	assert.False(t, response.Success)
+	assert.True(t, response.ChecksumMismatch)
+	_, err = server.Storage().GetChunk(req.UserAddr, req.ChunkID)
+	assert.Error(t, err, "corrupted shard was stored")
+
+	// Intact shards are stored and counted as clean transfers
+	require.NoError(t, rpc.StoreShardWithLease(ctx, server.ID(), req.UserAddr, 0, []byte("shard"), 0, DefaultErasureConfig()))
+	stored, err := server.Storage().GetChunk(req.UserAddr, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("shard"), stored)
+	assert.Equal(t, PeerIntegrity{Transfers: 1}, client.PeerIntegrity(server.ID()))
+}
+
+// TestPeerIntegrityScore tests when corruption keeps a peer from getting new shards
+func TestPeerIntegrityScore(t *testing.T) {
+	assert.Equal(t, 1.0, PeerIntegrity{}.Score())
+	assert.Zero(t, PeerIntegrity{}.CorruptionRate())
+
+	// A new peer's first bad transfer does not rule it out
+	first := PeerIntegrity{Transfers: 1, Corrupted: 1}
+	assert.Equal(t, 1.0, first.CorruptionRate())
+	assert.GreaterOrEqual(t, first.Score(), MinPlacementScore)
+
+	// A peer corrupting a third of its transfers does
+	assert.Less(t, PeerIntegrity{Transfers: 30, Corrupted: 10}.Score(), MinPlacementScore)
+	assert.GreaterOrEqual(t, PeerIntegrity{Transfers: 1000, Corrupted: 10}.Score(), MinPlacementScore)
+}
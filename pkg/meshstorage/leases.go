@@ -0,0 +1,296 @@
+// Package meshstorage provides distributed storage for ZenTalk encrypted chat history
+package meshstorage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Storage lease limits
+const (
+	// MaxLeaseDuration is the longest lease a node grants; longer requests are shortened to it
+	MaxLeaseDuration = 365 * 24 * time.Hour
+
+	// DefaultLeaseGCInterval is how often a node deletes chunks whose lease has run out
+	DefaultLeaseGCInterval = time.Hour
+)
+
+// ErrLeaseExpired is returned when renewing or repairing a chunk whose lease has already run out
+var ErrLeaseExpired = errors.New("storage lease expired")
+
+// ErrNoLease is returned when renewing a chunk that was stored without a lease
+var ErrNoLease = errors.New("chunk has no storage lease")
+
+// RenewLeaseRequest asks a node to keep a chunk for LeaseSeconds from now
+type RenewLeaseRequest struct {
+	UserAddr     string `json:"user_addr"`
+	ChunkID      int    `json:"chunk_id"`
+	LeaseSeconds int64  `json:"lease_seconds"`
+}
+
+// leaseUnix converts a lease expiry to its stored form (0 = no lease)
+func leaseUnix(expires time.Time) int64 {
+	if expires.IsZero() {
+		return 0
+	}
+	return expires.Unix()
+}
+
+// leaseTime converts a stored lease expiry back (zero time = no lease)
+func leaseTime(expires int64) time.Time {
+	if expires == 0 {
+		return time.Time{}
+	}
+	return time.Unix(expires, 0)
+}
+
+// leaseExpiry returns when a lease of d granted now ends, capped at MaxLeaseDuration
+// A zero or negative d means no lease.
+func leaseExpiry(d time.Duration) time.Time {
+	if d <= 0 {
+		return time.Time{}
+	}
+	if d > MaxLeaseDuration {
+		d = MaxLeaseDuration
+	}
+	return time.Now().Add(d).Truncate(time.Second)
+}
+
+// remainingLease returns the lease left until expires (0 = no lease)
+// A lease that has just run out leaves a second, so the shard is not stored
+// without one.
+func remainingLease(expires time.Time) time.Duration {
+	if expires.IsZero() {
+		return 0
+	}
+	if remaining := time.Until(expires); remaining > time.Second {
+		return remaining
+	}
+	return time.Second
+}
+
+// RenewLease extends a chunk's lease to leaseExpires and returns the lease it now has
+// Renewal only extends: an earlier expiry leaves the lease as it is, and a
+// chunk stored without a lease keeps none. A chunk whose lease has run out is
+// not revived, even if it has not been collected yet.
+func (s *LocalStorage) RenewLease(userAddr string, chunkID int, leaseExpires time.Time) (time.Time, error) {
+	current, err := s.ChunkLease(userAddr, chunkID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if current.IsZero() {
+		return current, nil
+	}
+	if !current.After(time.Now()) {
+		return time.Time{}, fmt.Errorf("%w: user=%s chunk=%d", ErrLeaseExpired, userAddr, chunkID)
+	}
+	if !leaseExpires.After(current) {
+		return current, nil
+	}
+
+	query := `UPDATE chunks SET lease_expires = ? WHERE user_addr = ? AND chunk_id = ? AND lease_expires < ?`
+	if _, err := s.exec(query, leaseUnix(leaseExpires), userAddr, chunkID, leaseUnix(leaseExpires)); err != nil {
+		return time.Time{}, fmt.Errorf("failed to renew lease: %w", err)
+	}
+	return leaseExpires.Truncate(time.Second), nil
+}
+
+// ChunkLease returns when a chunk's lease expires (zero time = no lease)
+func (s *LocalStorage) ChunkLease(userAddr string, chunkID int) (time.Time, error) {
+	query := `SELECT lease_expires FROM chunks WHERE user_addr = ? AND chunk_id = ?`
+
+	var expires int64
+	err := s.queryRow(query, userAddr, chunkID).Scan(&expires)
+	if err == sql.ErrNoRows {
+		return time.Time{}, fmt.Errorf("%w: user=%s chunk=%d", ErrChunkNotFound, userAddr, chunkID)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read lease: %w", err)
+	}
+	return leaseTime(expires), nil
+}
+
+// CollectExpiredLeases deletes the chunks whose lease ended at or before now
+// Chunks stored without a lease are never collected.
+func (s *LocalStorage) CollectExpiredLeases(now time.Time) (int, error) {
+	query := `DELETE FROM chunks WHERE lease_expires != 0 AND lease_expires <= ?`
+
+	result, err := s.exec(query, now.Unix())
+	if err != nil {
+		return 0, fmt.Errorf("failed to collect expired leases: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows > 0 && s.cache != nil {
+		s.cache.Clear() // Collected keys are not known here; start cold
+	}
+
+	return int(rows), nil
+}
+
+// collectLeases periodically deletes the chunks whose lease has run out
+func (n *DHTNode) collectLeases(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case <-ticker.C:
+			collected, err := n.storage.CollectExpiredLeases(time.Now())
+			if err != nil {
+				fmt.Printf("⚠️  Lease collection failed: %v\n", err)
+				continue
+			}
+			if collected > 0 {
+				fmt.Printf("🧹 Collected %d chunks with expired leases\n", collected)
+			}
+		}
+	}
+}
+
+// handleRenewLease processes a lease renewal request
+func (h *RPCHandler) handleRenewLease(payload []byte) RPCResponse {
+	var req RenewLeaseRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return RPCResponse{
+			Success: false,
+			Error:   fmt.Sprintf("failed to unmarshal request: %v", err),
+		}
+	}
+	if req.LeaseSeconds <= 0 {
+		return RPCResponse{
+			Success: false,
+			Error:   "lease must be positive",
+		}
+	}
+
+	expires, err := h.node.storage.RenewLease(req.UserAddr, req.ChunkID, leaseExpiry(time.Duration(req.LeaseSeconds)*time.Second))
+	if err != nil {
+		return RPCResponse{
+			Success: false,
+			Error:   fmt.Sprintf("failed to renew lease: %v", err),
+		}
+	}
+
+	return RPCResponse{
+		Success:      true,
+		LeaseExpires: leaseUnix(expires),
+	}
+}
+
+// RenewLease asks a remote node to keep a chunk for lease from now
+// Returns the expiry the node granted (zero time = the chunk has no lease).
+func (c *RPCClient) RenewLease(ctx context.Context, peerID peer.ID, userAddr string, chunkID int, lease time.Duration) (time.Time, error) {
+	req := RenewLeaseRequest{
+		UserAddr:     userAddr,
+		ChunkID:      chunkID,
+		LeaseSeconds: int64(lease / time.Second),
+	}
+
+	reqData, err := json.Marshal(req)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	msg := RPCMessage{
+		Type:    MsgTypeRenewLease,
+		ID:      fmt.Sprintf("lease-%s-%d", userAddr, chunkID),
+		Payload: reqData,
+	}
+
+	response, err := c.sendRequest(ctx, peerID, msg)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if !response.Success {
+		return time.Time{}, &RemoteError{Message: response.Error}
+	}
+
+	return leaseTime(response.LeaseExpires), nil
+}
+
+// RenewLease extends the lease on every shard of a chunk to lease from now
+// The renewal succeeds if at least the chunk's MinRecovery shards were renewed;
+// shards that weren't lapse with the old lease and are rebuilt by repair.
+// chunk.LeaseExpires is updated to the new expiry.
+func (ds *DistributedStorage) RenewLease(ctx context.Context, chunk *DistributedChunk, lease time.Duration) error {
+	if chunk == nil {
+		return fmt.Errorf("distributed chunk is nil")
+	}
+	if chunk.LeaseExpires.IsZero() {
+		return fmt.Errorf("%w: chunk %d", ErrNoLease, chunk.ChunkID)
+	}
+	if !chunk.LeaseExpires.After(time.Now()) {
+		return fmt.Errorf("%w: chunk %d", ErrLeaseExpired, chunk.ChunkID)
+	}
+	if lease <= 0 {
+		return fmt.Errorf("lease must be positive")
+	}
+
+	expires := leaseExpiry(lease)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	renewed := 0
+
+	for _, loc := range chunk.ShardLocations {
+		if loc.PeerID == "" {
+			continue // Never stored
+		}
+
+		wg.Add(1)
+		go func(loc ShardLocation) {
+			defer wg.Done()
+
+			shardKey := fmt.Sprintf("%s_%d_shard_%d", chunk.UserAddr, chunk.ChunkID, loc.ShardIndex)
+
+			var err error
+			if loc.PeerID == ds.node.ID() {
+				_, err = ds.node.Storage().RenewLease(shardKey, loc.ShardIndex, expires)
+			} else {
+				_, err = ds.client.RenewLease(ctx, loc.PeerID, shardKey, loc.ShardIndex, lease)
+			}
+			if err != nil {
+				fmt.Printf("⚠️  Failed to renew lease on shard %d: %v\n", loc.ShardIndex, err)
+				return
+			}
+
+			mu.Lock()
+			renewed++
+			mu.Unlock()
+		}(loc)
+	}
+
+	wg.Wait()
+
+	erasure := chunk.Coding()
+	if renewed < erasure.MinRecovery {
+		return fmt.Errorf("%w: renewed %d, need %d", ErrInsufficientShards, renewed, erasure.MinRecovery)
+	}
+
+	chunk.LeaseExpires = expires
+	ds.updateManifest(chunk)
+
+	fmt.Printf("📅 Renewed lease on %d/%d shards of chunk %d until %s\n",
+		renewed, erasure.TotalShards(), chunk.ChunkID, expires.Format(time.RFC3339))
+	return nil
+}
+
+// leaseLapsed reports whether a chunk's lease has run out
+func (chunk *DistributedChunk) leaseLapsed(now time.Time) bool {
+	return !chunk.LeaseExpires.IsZero() && !chunk.LeaseExpires.After(now)
+}
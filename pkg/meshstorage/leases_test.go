@@ -0,0 +1,122 @@
+package meshstorage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLocalStorageLeases(t *testing.T) {
+	storage, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	now := time.Now()
+	data := []byte("leased")
+
+	// One chunk whose lease has run out, one still leased, one without a lease
+	if err := storage.StoreChunkWithLease("0xuser", 1, data, now.Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.StoreChunkWithLease("0xuser", 2, data, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.StoreChunk("0xuser", 3, data); err != nil {
+		t.Fatal(err)
+	}
+
+	// Renewal extends but never shortens a lease
+	later := now.Add(2 * time.Hour)
+	if got, err := storage.RenewLease("0xuser", 2, later); err != nil || got.Unix() != later.Unix() {
+		t.Fatalf("RenewLease() = %v, %v; want %v", got, err, later)
+	}
+	if got, err := storage.RenewLease("0xuser", 2, now.Add(time.Minute)); err != nil || got.Unix() != later.Unix() {
+		t.Errorf("shortening RenewLease() = %v, %v; want the lease kept at %v", got, err, later)
+	}
+
+	// A lapsed lease is not revived, and an unleased chunk gains none
+	if _, err := storage.RenewLease("0xuser", 1, later); !errors.Is(err, ErrLeaseExpired) {
+		t.Errorf("renewing a lapsed lease: err = %v, want ErrLeaseExpired", err)
+	}
+	if got, err := storage.RenewLease("0xuser", 3, later); err != nil || !got.IsZero() {
+		t.Errorf("renewing an unleased chunk = %v, %v; want no lease", got, err)
+	}
+	if _, err := storage.RenewLease("0xuser", 4, later); !errors.Is(err, ErrChunkNotFound) {
+		t.Errorf("renewing a missing chunk: err = %v, want ErrChunkNotFound", err)
+	}
+
+	collected, err := storage.CollectExpiredLeases(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if collected != 1 {
+		t.Errorf("CollectExpiredLeases() = %d, want 1", collected)
+	}
+
+	chunks, err := storage.ListChunks("0xuser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 2 || chunks[0] != 2 || chunks[1] != 3 {
+		t.Errorf("chunks left = %v, want [2 3]", chunks)
+	}
+}
+
+func TestLeaseExpiryCapped(t *testing.T) {
+	if got := leaseExpiry(0); !got.IsZero() {
+		t.Errorf("leaseExpiry(0) = %v, want no lease", got)
+	}
+	if got := leaseExpiry(10 * MaxLeaseDuration); got.After(time.Now().Add(MaxLeaseDuration)) {
+		t.Errorf("leaseExpiry() = %v, beyond MaxLeaseDuration", got)
+	}
+}
+
+func TestDistributedLeaseRenewal(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	node, err := NewDHTNode(ctx, &NodeConfig{Port: 0, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create DHT node: %v", err)
+	}
+	defer node.Close()
+
+	ds, err := NewDistributedStorage(node)
+	if err != nil {
+		t.Fatalf("Failed to create distributed storage: %v", err)
+	}
+	defer ds.StopMonitoring()
+
+	chunk, err := ds.StoreDistributedWithLease(ctx, "0xuser", 7, []byte("data the network may drop"), time.Hour)
+	if err != nil {
+		t.Fatalf("StoreDistributedWithLease() error = %v", err)
+	}
+	if chunk.LeaseExpires.IsZero() {
+		t.Fatal("chunk stored without a lease")
+	}
+
+	shardKey := "0xuser_7_shard_0"
+	stored, err := node.Storage().ChunkLease(shardKey, 0)
+	if err != nil || stored.Unix() != chunk.LeaseExpires.Unix() {
+		t.Fatalf("shard lease = %v, %v; want %v", stored, err, chunk.LeaseExpires)
+	}
+
+	if err := ds.RenewLease(ctx, chunk, 3*time.Hour); err != nil {
+		t.Fatalf("RenewLease() error = %v", err)
+	}
+	renewed, err := node.Storage().ChunkLease(shardKey, 0)
+	if err != nil || !renewed.After(stored) || renewed.Unix() != chunk.LeaseExpires.Unix() {
+		t.Errorf("renewed shard lease = %v, %v; want %v, after %v", renewed, err, chunk.LeaseExpires, stored)
+	}
+
+	unleased, err := ds.StoreDistributed(ctx, "0xuser", 8, []byte("kept until deleted"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.RenewLease(ctx, unleased, time.Hour); !errors.Is(err, ErrNoLease) {
+		t.Errorf("renewing an unleased chunk: err = %v, want ErrNoLease", err)
+	}
+}
@@ -0,0 +1,59 @@
+// Package meshstorage provides distributed storage for ZenTalk encrypted chat history
+package meshstorage
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Address families accepted by NodeConfig.AddressFamily
+const (
+	AddressFamilyDual = "dual" // Listen on IPv4 and IPv6 (default)
+	AddressFamilyIPv4 = "ipv4"
+	AddressFamilyIPv6 = "ipv6" // For IPv6-only hosts
+)
+
+// listenMultiaddrs returns the libp2p listen addresses for a port
+// Without explicit hosts, dual mode listens on both 0.0.0.0 and ::, so the node
+// announces /ip4 and /ip6 addresses and peers of either family can reach it.
+func listenMultiaddrs(hosts []string, family string, port int) ([]string, error) {
+	family = strings.ToLower(family)
+	switch family {
+	case "":
+		family = AddressFamilyDual
+	case AddressFamilyDual, AddressFamilyIPv4, AddressFamilyIPv6:
+	default:
+		return nil, fmt.Errorf("unknown address family %q (want dual, ipv4 or ipv6)", family)
+	}
+
+	if len(hosts) == 0 {
+		switch family {
+		case AddressFamilyIPv4:
+			hosts = []string{"0.0.0.0"}
+		case AddressFamilyIPv6:
+			hosts = []string{"::"}
+		default:
+			hosts = []string{"0.0.0.0", "::"}
+		}
+	}
+
+	addrs := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		ip := net.ParseIP(strings.Trim(host, "[]"))
+		if ip == nil {
+			return nil, fmt.Errorf("listen address %q is not an IP address", host)
+		}
+
+		proto := "ip6"
+		if ip.To4() != nil {
+			proto = "ip4"
+		}
+		if (family == AddressFamilyIPv4 && proto != "ip4") || (family == AddressFamilyIPv6 && proto != "ip6") {
+			return nil, fmt.Errorf("listen address %s is not %s", host, family)
+		}
+
+		addrs = append(addrs, fmt.Sprintf("/%s/%s/tcp/%d", proto, ip, port))
+	}
+	return addrs, nil
+}
@@ -0,0 +1,77 @@
+package meshstorage
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestListenMultiaddrs(t *testing.T) {
+	tests := []struct {
+		name    string
+		hosts   []string
+		family  string
+		want    []string
+		wantErr bool
+	}{
+		{"Default is dual-stack", nil, "", []string{"/ip4/0.0.0.0/tcp/9000", "/ip6/::/tcp/9000"}, false},
+		{"IPv4 only", nil, "ipv4", []string{"/ip4/0.0.0.0/tcp/9000"}, false},
+		{"IPv6 only", nil, "IPv6", []string{"/ip6/::/tcp/9000"}, false},
+		{"Explicit hosts", []string{"127.0.0.1", "[::1]"}, "dual", []string{"/ip4/127.0.0.1/tcp/9000", "/ip6/::1/tcp/9000"}, false},
+		{"IPv6 host in IPv4 mode", []string{"::1"}, "ipv4", nil, true},
+		{"IPv4 host in IPv6 mode", []string{"10.0.0.1"}, "ipv6", nil, true},
+		{"Hostname", []string{"localhost"}, "dual", nil, true},
+		{"Unknown family", nil, "ipx", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := listenMultiaddrs(tt.hosts, tt.family, 9000)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("listenMultiaddrs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("listenMultiaddrs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDHTNodeIPv6(t *testing.T) {
+	if l, err := net.Listen("tcp6", "[::1]:0"); err != nil {
+		t.Skipf("IPv6 loopback not available: %v", err)
+	} else {
+		l.Close()
+	}
+
+	ctx := context.Background()
+	node1, err := NewDHTNode(ctx, &NodeConfig{Port: 0, ListenHosts: []string{"::1"}, AddressFamily: AddressFamilyIPv6, BootstrapOnly: true})
+	if err != nil {
+		t.Fatalf("Failed to create IPv6 node: %v", err)
+	}
+	defer node1.Close()
+
+	for _, addr := range node1.Addresses() {
+		if !strings.HasPrefix(addr.String(), "/ip6/") {
+			t.Errorf("IPv6-only node listens on %s", addr)
+		}
+	}
+
+	// A dual-stack node reaches it over IPv6
+	bootstrapAddr := node1.Addresses()[0].String() + "/p2p/" + node1.ID().String()
+	node2, err := NewDHTNode(ctx, &NodeConfig{Port: 0, DataDir: t.TempDir(), BootstrapPeers: []string{bootstrapAddr}})
+	if err != nil {
+		t.Fatalf("Failed to bootstrap over IPv6: %v", err)
+	}
+	defer node2.Close()
+
+	var hasIPv4, hasIPv6 bool
+	for _, addr := range node2.Addresses() {
+		hasIPv4 = hasIPv4 || strings.HasPrefix(addr.String(), "/ip4/")
+		hasIPv6 = hasIPv6 || strings.HasPrefix(addr.String(), "/ip6/")
+	}
+	if !hasIPv4 || !hasIPv6 {
+		t.Errorf("Dual-stack node addresses = %v, want both /ip4 and /ip6", node2.Addresses())
+	}
+}
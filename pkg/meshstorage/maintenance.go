@@ -0,0 +1,203 @@
+// Package meshstorage provides distributed storage for ZenTalk encrypted chat history
+package meshstorage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	// MaintenanceGracePeriod is how long after an announced window a peer's shards stay exempt from repair
+	MaintenanceGracePeriod = 10 * time.Minute
+
+	// MaxMaintenanceWindow caps announced windows so a peer cannot suppress repairs indefinitely
+	MaxMaintenanceWindow = 24 * time.Hour
+)
+
+// MaintenanceNotice announces planned downtime (Until > 0) or the end of it (Until == 0)
+type MaintenanceNotice struct {
+	Until  int64  `json:"until"`            // Unix time the node expects to be back
+	Reason string `json:"reason,omitempty"` // Free-form, for operators
+}
+
+// maintenanceRegistry tracks peers that announced planned downtime
+type maintenanceRegistry struct {
+	mu       sync.Mutex
+	peers    map[peer.ID]time.Time // peer -> end of exemption (announced end + grace)
+	onReturn []func(peer.ID)
+}
+
+// newMaintenanceRegistry creates an empty registry
+func newMaintenanceRegistry() *maintenanceRegistry {
+	return &maintenanceRegistry{
+		peers: make(map[peer.ID]time.Time),
+	}
+}
+
+// PeerInMaintenance reports whether a peer is inside an announced maintenance window
+// An expired window is treated as the peer returning
+func (n *DHTNode) PeerInMaintenance(peerID peer.ID) bool {
+	m := n.maintenance
+
+	m.mu.Lock()
+	until, ok := m.peers[peerID]
+	if !ok {
+		m.mu.Unlock()
+		return false
+	}
+	if time.Now().Before(until) {
+		m.mu.Unlock()
+		return true
+	}
+	delete(m.peers, peerID)
+	hooks := m.onReturn
+	m.mu.Unlock()
+
+	fmt.Printf("⏰ Maintenance window of %s expired\n", peerID)
+	for _, hook := range hooks {
+		go hook(peerID)
+	}
+	return false
+}
+
+// MaintenancePeers returns peers currently in maintenance and when their exemption ends
+func (n *DHTNode) MaintenancePeers() map[peer.ID]time.Time {
+	m := n.maintenance
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	peers := make(map[peer.ID]time.Time, len(m.peers))
+	for id, until := range m.peers {
+		if now.Before(until) {
+			peers[id] = until
+		}
+	}
+	return peers
+}
+
+// OnPeerReturn registers a callback run when a peer leaves maintenance
+func (n *DHTNode) OnPeerReturn(fn func(peer.ID)) {
+	m := n.maintenance
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onReturn = append(m.onReturn, fn)
+}
+
+// applyMaintenanceNotice records a notice received from a peer
+func (n *DHTNode) applyMaintenanceNotice(from peer.ID, notice *MaintenanceNotice) {
+	m := n.maintenance
+
+	if notice.Until == 0 {
+		m.mu.Lock()
+		_, wasAway := m.peers[from]
+		delete(m.peers, from)
+		hooks := m.onReturn
+		m.mu.Unlock()
+
+		if wasAway {
+			fmt.Printf("✅ Peer %s is back from maintenance\n", from)
+			for _, hook := range hooks {
+				go hook(from)
+			}
+		}
+		return
+	}
+
+	until := time.Unix(notice.Until, 0)
+	if limit := time.Now().Add(MaxMaintenanceWindow); until.After(limit) {
+		until = limit
+	}
+
+	m.mu.Lock()
+	m.peers[from] = until.Add(MaintenanceGracePeriod)
+	m.mu.Unlock()
+
+	fmt.Printf("🛠️  Peer %s entering maintenance until %s (%s)\n", from, until.Format(time.RFC3339), notice.Reason)
+}
+
+// AnnounceMaintenance tells connected peers this node is going down for planned maintenance
+// Peers stop placing shards here and hold off repairing this node's shards until
+// window plus MaintenanceGracePeriod has passed. Returns the number of peers notified.
+func (n *DHTNode) AnnounceMaintenance(ctx context.Context, window time.Duration, reason string) int {
+	return n.broadcastMaintenance(ctx, &MaintenanceNotice{
+		Until:  time.Now().Add(window).Unix(),
+		Reason: reason,
+	})
+}
+
+// AnnounceReturn tells connected peers that maintenance is over so they re-verify this node's shards
+func (n *DHTNode) AnnounceReturn(ctx context.Context) int {
+	return n.broadcastMaintenance(ctx, &MaintenanceNotice{})
+}
+
+// broadcastMaintenance sends a notice to every connected peer
+func (n *DHTNode) broadcastMaintenance(ctx context.Context, notice *MaintenanceNotice) int {
+	client := NewRPCClient(n)
+	peers := n.host.Network().Peers()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	notified := 0
+
+	for _, peerID := range peers {
+		wg.Add(1)
+		go func(id peer.ID) {
+			defer wg.Done()
+			if err := client.SendMaintenanceNotice(ctx, id, notice); err != nil {
+				fmt.Printf("⚠️  Failed to send maintenance notice to %s: %v\n", id, err)
+				return
+			}
+			mu.Lock()
+			notified++
+			mu.Unlock()
+		}(peerID)
+	}
+
+	wg.Wait()
+	return notified
+}
+
+// handleMaintenance processes a maintenance notice from a peer
+func (h *RPCHandler) handleMaintenance(from peer.ID, payload []byte) RPCResponse {
+	var notice MaintenanceNotice
+	if err := json.Unmarshal(payload, &notice); err != nil {
+		return RPCResponse{
+			Success: false,
+			Error:   fmt.Sprintf("failed to unmarshal notice: %v", err),
+		}
+	}
+
+	h.node.applyMaintenanceNotice(from, &notice)
+	return RPCResponse{Success: true}
+}
+
+// SendMaintenanceNotice delivers a maintenance notice to a remote node
+func (c *RPCClient) SendMaintenanceNotice(ctx context.Context, peerID peer.ID, notice *MaintenanceNotice) error {
+	reqData, err := json.Marshal(notice)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notice: %w", err)
+	}
+
+	msg := RPCMessage{
+		Type:    MsgTypeMaintenance,
+		ID:      fmt.Sprintf("maintenance-%d", notice.Until),
+		Payload: reqData,
+	}
+
+	response, err := c.sendRequest(ctx, peerID, msg)
+	if err != nil {
+		return err
+	}
+
+	if !response.Success {
+		return &RemoteError{Message: response.Error}
+	}
+
+	return nil
+}
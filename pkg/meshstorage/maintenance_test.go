@@ -0,0 +1,51 @@
+package meshstorage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestMaintenanceRegistry(t *testing.T) {
+	node, err := NewDHTNode(context.Background(), &NodeConfig{Port: 0, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+	defer node.Close()
+
+	returned := make(chan peer.ID, 1)
+	node.OnPeerReturn(func(id peer.ID) { returned <- id })
+
+	away := peer.ID("peer-away")
+
+	node.applyMaintenanceNotice(away, &MaintenanceNotice{
+		Until:  time.Now().Add(time.Hour).Unix(),
+		Reason: "kernel upgrade",
+	})
+	if !node.PeerInMaintenance(away) {
+		t.Fatal("Expected peer to be in maintenance")
+	}
+
+	// Windows beyond the cap are clamped
+	node.applyMaintenanceNotice(away, &MaintenanceNotice{Until: time.Now().Add(30 * 24 * time.Hour).Unix()})
+	limit := time.Now().Add(MaxMaintenanceWindow + MaintenanceGracePeriod + time.Minute)
+	if until := node.MaintenancePeers()[away]; until.After(limit) {
+		t.Fatalf("Maintenance window not capped: %v", until)
+	}
+
+	node.applyMaintenanceNotice(away, &MaintenanceNotice{})
+	if node.PeerInMaintenance(away) {
+		t.Fatal("Expected peer to have left maintenance")
+	}
+
+	select {
+	case id := <-returned:
+		if id != away {
+			t.Fatalf("Return hook got %s, want %s", id, away)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Return hook was not called")
+	}
+}
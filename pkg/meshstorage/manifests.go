@@ -0,0 +1,258 @@
+package meshstorage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// ErrManifestNotFound is returned when no manifest is stored for the user and chunk ID
+var ErrManifestNotFound = errors.New("chunk manifest not found")
+
+// chunkManifest is the stored form of a DistributedChunk
+type chunkManifest struct {
+	UserAddr     string          `json:"userAddr"`
+	ChunkID      int             `json:"chunkID"`
+	OriginalSize int             `json:"originalSize"`
+	ShardSize    int             `json:"shardSize"`
+	Shards       []shardManifest `json:"shards"`
+	LeaseExpires int64           `json:"leaseExpires,omitempty"` // Unix seconds (0 = no lease)
+	Erasure      ErasureConfig   `json:"erasure"`
+	UpdatedAt    int64           `json:"updatedAt,omitempty"` // Unix nanoseconds (0 = before it was recorded)
+}
+
+// shardManifest is the stored form of a ShardLocation
+// Peer IDs are kept as strings because a shard that was never stored has none.
+type shardManifest struct {
+	Index int      `json:"index"`
+	Peer  string   `json:"peer,omitempty"`
+	Addrs []string `json:"addrs,omitempty"`
+}
+
+// encodeManifest serializes a chunk's manifest to JSON
+func encodeManifest(chunk *DistributedChunk) ([]byte, error) {
+	m := chunkManifest{
+		UserAddr:     chunk.UserAddr,
+		ChunkID:      chunk.ChunkID,
+		OriginalSize: chunk.OriginalSize,
+		ShardSize:    chunk.ShardSize,
+		Shards:       make([]shardManifest, len(chunk.ShardLocations)),
+		LeaseExpires: leaseUnix(chunk.LeaseExpires),
+		Erasure:      chunk.Erasure,
+		UpdatedAt:    manifestTime(chunk.UpdatedAt),
+	}
+	for i, loc := range chunk.ShardLocations {
+		m.Shards[i] = shardManifest{Index: loc.ShardIndex, Addrs: loc.PeerAddrs}
+		if loc.PeerID != "" {
+			m.Shards[i].Peer = loc.PeerID.String()
+		}
+	}
+	return json.Marshal(m)
+}
+
+// decodeManifest deserializes a chunk's manifest from JSON
+func decodeManifest(data []byte) (*DistributedChunk, error) {
+	var m chunkManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal chunk manifest: %w", err)
+	}
+
+	chunk := &DistributedChunk{
+		UserAddr:       m.UserAddr,
+		ChunkID:        m.ChunkID,
+		OriginalSize:   m.OriginalSize,
+		ShardSize:      m.ShardSize,
+		ShardLocations: make([]ShardLocation, len(m.Shards)),
+		LeaseExpires:   leaseTime(m.LeaseExpires),
+		Erasure:        m.Erasure,
+	}
+	if m.UpdatedAt != 0 {
+		chunk.UpdatedAt = time.Unix(0, m.UpdatedAt)
+	}
+	for i, shard := range m.Shards {
+		chunk.ShardLocations[i] = ShardLocation{ShardIndex: shard.Index, PeerAddrs: shard.Addrs}
+		if shard.Peer == "" {
+			continue
+		}
+		id, err := peer.Decode(shard.Peer)
+		if err != nil {
+			return nil, fmt.Errorf("invalid peer of shard %d in chunk manifest: %w", shard.Index, err)
+		}
+		chunk.ShardLocations[i].PeerID = id
+	}
+	return chunk, nil
+}
+
+// manifestTime converts a manifest's update time for storage (0 = not recorded)
+func manifestTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// StoreChunkManifest saves where a distributed chunk's shards are, replacing any earlier manifest
+func (s *LocalStorage) StoreChunkManifest(chunk *DistributedChunk) error {
+	data, err := encodeManifest(chunk)
+	if err != nil {
+		return fmt.Errorf("failed to marshal chunk manifest: %w", err)
+	}
+
+	query := `INSERT INTO chunk_manifests (user_addr, chunk_id, manifest, updated_at)
+	          VALUES (?, ?, ?, ?)
+	          ON CONFLICT (user_addr, chunk_id) DO UPDATE SET
+	              manifest = excluded.manifest, updated_at = excluded.updated_at`
+
+	if _, err := s.exec(query, chunk.UserAddr, chunk.ChunkID, data, time.Now().Unix()); err != nil {
+		return fmt.Errorf("failed to store chunk manifest: %w", err)
+	}
+	return nil
+}
+
+// GetChunkManifest returns the manifest of a user's distributed chunk
+func (s *LocalStorage) GetChunkManifest(userAddr string, chunkID int) (*DistributedChunk, error) {
+	var data []byte
+	err := s.queryRow(`SELECT manifest FROM chunk_manifests WHERE user_addr = ? AND chunk_id = ?`, userAddr, chunkID).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, ErrManifestNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chunk manifest: %w", err)
+	}
+	return decodeManifest(data)
+}
+
+// ListChunkManifests returns every stored chunk manifest, ordered by user and chunk ID
+func (s *LocalStorage) ListChunkManifests() ([]*DistributedChunk, error) {
+	rows, err := s.query(`SELECT manifest FROM chunk_manifests ORDER BY user_addr, chunk_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chunk manifests: %w", err)
+	}
+	defer rows.Close()
+
+	var chunks []*DistributedChunk
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to scan chunk manifest: %w", err)
+		}
+		chunk, err := decodeManifest(data)
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks, rows.Err()
+}
+
+// DeleteChunkManifest removes the manifest of a user's distributed chunk
+func (s *LocalStorage) DeleteChunkManifest(userAddr string, chunkID int) error {
+	result, err := s.exec(`DELETE FROM chunk_manifests WHERE user_addr = ? AND chunk_id = ?`, userAddr, chunkID)
+	if err != nil {
+		return fmt.Errorf("failed to delete chunk manifest: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrManifestNotFound
+	}
+	return nil
+}
+
+// saveManifest records a monitored chunk's manifest so it survives a restart (best effort)
+func (ds *DistributedStorage) saveManifest(chunk *DistributedChunk) {
+	store := ds.node.Storage()
+	if store == nil {
+		return
+	}
+	if err := store.StoreChunkManifest(chunk); err != nil {
+		fmt.Printf("⚠️  Failed to save manifest of chunk %s:%d: %v\n", chunk.UserAddr, chunk.ChunkID, err)
+	}
+}
+
+// updateManifest saves a chunk's manifest after its shards or lease changed, if it is still monitored
+func (ds *DistributedStorage) updateManifest(chunk *DistributedChunk) {
+	if tracked, ok := ds.Chunk(chunk.UserAddr, chunk.ChunkID); ok && tracked == chunk {
+		ds.saveManifest(chunk)
+	}
+}
+
+// dropManifest removes the manifest of a chunk no longer monitored (best effort)
+func (ds *DistributedStorage) dropManifest(userAddr string, chunkID int) {
+	store := ds.node.Storage()
+	if store == nil {
+		return
+	}
+	if err := store.DeleteChunkManifest(userAddr, chunkID); err != nil && !errors.Is(err, ErrManifestNotFound) {
+		fmt.Printf("⚠️  Failed to delete manifest of chunk %s:%d: %v\n", userAddr, chunkID, err)
+	}
+}
+
+// loadManifests resumes monitoring the chunks recorded by earlier runs
+// Chunks whose lease ran out while the node was down are dropped.
+func (ds *DistributedStorage) loadManifests() error {
+	store := ds.node.Storage()
+	if store == nil {
+		return nil
+	}
+
+	chunks, err := store.ListChunkManifests()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	loaded := 0
+	ds.chunksMu.Lock()
+	for _, chunk := range chunks {
+		if chunk.leaseLapsed(now) {
+			ds.dropManifest(chunk.UserAddr, chunk.ChunkID)
+			continue
+		}
+		ds.chunks[chunkKey(chunk.UserAddr, chunk.ChunkID)] = chunk
+		loaded++
+	}
+	ds.chunksMu.Unlock()
+
+	if loaded > 0 {
+		fmt.Printf("📋 Loaded %d chunk manifests for monitoring\n", loaded)
+	}
+	return nil
+}
+
+// chunkKey identifies a monitored chunk
+func chunkKey(userAddr string, chunkID int) string {
+	return fmt.Sprintf("%s:%d", userAddr, chunkID)
+}
+
+// Chunk returns the manifest of a monitored chunk
+func (ds *DistributedStorage) Chunk(userAddr string, chunkID int) (*DistributedChunk, bool) {
+	ds.chunksMu.RLock()
+	defer ds.chunksMu.RUnlock()
+	chunk, ok := ds.chunks[chunkKey(userAddr, chunkID)]
+	return chunk, ok
+}
+
+// ListChunks returns the monitored chunks of a user (empty = every user), ordered by user and chunk ID
+// Chunks stored by earlier runs of the node are included.
+func (ds *DistributedStorage) ListChunks(userAddr string) []*DistributedChunk {
+	ds.chunksMu.RLock()
+	chunks := make([]*DistributedChunk, 0, len(ds.chunks))
+	for _, chunk := range ds.chunks {
+		if userAddr == "" || chunk.UserAddr == userAddr {
+			chunks = append(chunks, chunk)
+		}
+	}
+	ds.chunksMu.RUnlock()
+
+	sort.Slice(chunks, func(i, j int) bool {
+		if chunks[i].UserAddr != chunks[j].UserAddr {
+			return chunks[i].UserAddr < chunks[j].UserAddr
+		}
+		return chunks[i].ChunkID < chunks[j].ChunkID
+	})
+	return chunks
+}
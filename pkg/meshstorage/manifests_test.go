@@ -0,0 +1,103 @@
+package meshstorage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	libp2pcrypto "github.com/libp2p/go-libp2p/core/crypto"
+	libp2ptest "github.com/libp2p/go-libp2p/core/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestChunkManifestStorage tests manifests round-trip, including shards that were never stored
+func TestChunkManifestStorage(t *testing.T) {
+	storage, err := NewLocalStorage(t.TempDir())
+	require.NoError(t, err)
+	defer storage.Close()
+
+	_, err = storage.GetChunkManifest("0xuser", 1)
+	assert.True(t, errors.Is(err, ErrManifestNotFound))
+
+	chunk := &DistributedChunk{
+		UserAddr:     "0xuser",
+		ChunkID:      1,
+		OriginalSize: 100,
+		ShardSize:    50,
+		ShardLocations: []ShardLocation{
+			{ShardIndex: 0, PeerID: libp2ptest.RandPeerIDFatal(t), PeerAddrs: []string{"/ip4/127.0.0.1/tcp/4001"}},
+			{ShardIndex: 1},
+		},
+		LeaseExpires: time.Unix(1900000000, 0),
+		Erasure:      ErasureConfig{DataShards: 1, ParityShards: 1},
+		UpdatedAt:    time.Unix(0, 1800000000123456789),
+	}
+	require.NoError(t, storage.StoreChunkManifest(chunk))
+
+	got, err := storage.GetChunkManifest("0xuser", 1)
+	require.NoError(t, err)
+	assert.Equal(t, chunk.ShardLocations, got.ShardLocations)
+	assert.True(t, got.LeaseExpires.Equal(chunk.LeaseExpires))
+	assert.Equal(t, chunk.Erasure, got.Erasure)
+	assert.True(t, got.UpdatedAt.Equal(chunk.UpdatedAt))
+
+	chunk.ShardLocations[1].PeerID = libp2ptest.RandPeerIDFatal(t)
+	require.NoError(t, storage.StoreChunkManifest(chunk))
+	chunks, err := storage.ListChunkManifests()
+	require.NoError(t, err)
+	require.Len(t, chunks, 1)
+	assert.Equal(t, chunk.ShardLocations[1].PeerID, chunks[0].ShardLocations[1].PeerID)
+
+	require.NoError(t, storage.DeleteChunkManifest("0xuser", 1))
+	assert.True(t, errors.Is(storage.DeleteChunkManifest("0xuser", 1), ErrManifestNotFound))
+}
+
+// TestManifestsSurviveRestart tests a restarted node still lists, serves and forgets its chunks
+func TestManifestsSurviveRestart(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	priv, _, err := libp2pcrypto.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+	config := &NodeConfig{Port: 0, DataDir: t.TempDir(), PrivateKey: priv}
+
+	node, err := NewDHTNode(ctx, config)
+	require.NoError(t, err)
+	ds, err := NewDistributedStorage(node)
+	require.NoError(t, err)
+
+	data := []byte("still here after a restart")
+	_, err = ds.StoreDistributed(ctx, "0xuser", 1, data)
+	require.NoError(t, err)
+	_, err = ds.StoreDistributed(ctx, "0xuser", 2, []byte("deleted before the restart"))
+	require.NoError(t, err)
+	_, err = ds.StoreDistributed(ctx, "0xother", 1, []byte("someone else's"))
+	require.NoError(t, err)
+	require.NoError(t, ds.DeleteChunk(ctx, "0xuser", 2))
+
+	ds.StopMonitoring()
+	require.NoError(t, node.Close())
+
+	node, err = NewDHTNode(ctx, config)
+	require.NoError(t, err)
+	defer node.Close()
+	ds, err = NewDistributedStorage(node)
+	require.NoError(t, err)
+	defer ds.StopMonitoring()
+
+	chunks := ds.ListChunks("0xuser")
+	require.Len(t, chunks, 1)
+	assert.Equal(t, 1, chunks[0].ChunkID)
+	assert.Len(t, ds.ListChunks(""), 2)
+
+	retrieved, err := ds.RetrieveDistributed(ctx, chunks[0])
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(retrieved, data))
+
+	require.NoError(t, ds.DeleteChunk(ctx, "0xuser", 1))
+	_, err = node.Storage().GetChunkManifest("0xuser", 1)
+	assert.True(t, errors.Is(err, ErrManifestNotFound))
+}
@@ -0,0 +1,427 @@
+// Package meshstorage provides distributed storage for ZenTalk encrypted chat history
+package meshstorage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Usage metering defaults
+const (
+	// DefaultUsageEpoch is the period usage reports cover
+	DefaultUsageEpoch = 24 * time.Hour
+
+	// usageSampleInterval is how often stored bytes are sampled into byte-seconds
+	usageSampleInterval = 5 * time.Minute
+)
+
+// ErrNoUsage is returned for an epoch with no usage recorded on this node
+var ErrNoUsage = errors.New("no usage recorded for epoch")
+
+// UserUsage is one user's metered usage in an epoch
+type UserUsage struct {
+	UserAddr    string `json:"user_addr"`
+	ByteSeconds int64  `json:"byte_seconds"` // Bytes of the user's shards held, times seconds held
+	BytesServed int64  `json:"bytes_served"` // Bytes of the user's data sent to peers and downloaders
+}
+
+// UsageReport is a node's metered usage for one epoch
+// The digest covers the node ID, the epoch and every user's usage, so a
+// report handed to a billing or reward system can be checked against the
+// digest the node committed.
+type UsageReport struct {
+	NodeID      string      `json:"node_id"`
+	EpochStart  int64       `json:"epoch_start"` // Unix seconds
+	EpochEnd    int64       `json:"epoch_end"`   // Unix seconds, exclusive
+	Closed      bool        `json:"closed"`      // The epoch is over; the report won't change
+	Users       []UserUsage `json:"users"`       // Sorted by address
+	Digest      string      `json:"digest"`      // Hex SHA-256 (see ComputeDigest)
+	CommittedAt int64       `json:"committed_at,omitempty"`
+}
+
+// UsageCommitter records the digest of a closed epoch's usage report, e.g. on-chain
+type UsageCommitter interface {
+	CommitUsage(ctx context.Context, epochStart uint64, digest [32]byte) error
+}
+
+// usageKey is a user's usage within one epoch
+type usageKey struct {
+	epochStart int64
+	user       string
+}
+
+// UsageMeter accounts the storage and bandwidth each user's data costs this node
+// Stored bytes are sampled periodically, so byte-seconds accrue only while the
+// node is running; bytes served are counted as they are sent.
+type UsageMeter struct {
+	storage *LocalStorage
+	nodeID  string
+	epoch   int64 // Epoch length in seconds
+
+	mu         sync.Mutex
+	served     map[usageKey]int64 // Served since the last sample
+	lastSample time.Time
+	committer  UsageCommitter
+}
+
+// newUsageMeter creates a meter with the given epoch length
+func newUsageMeter(storage *LocalStorage, nodeID string, epoch time.Duration) *UsageMeter {
+	if epoch < time.Second {
+		epoch = DefaultUsageEpoch
+	}
+	return &UsageMeter{
+		storage: storage,
+		nodeID:  nodeID,
+		epoch:   int64(epoch / time.Second),
+		served:  make(map[usageKey]int64),
+	}
+}
+
+// usageOwner returns the user a stored key belongs to
+// Shards are stored under "<user>_<chunk>_shard_<index>".
+func usageOwner(storedKey string) string {
+	user, _, _ := strings.Cut(storedKey, "_")
+	return user
+}
+
+// EpochStart returns the start (Unix seconds) of the epoch holding t
+func (m *UsageMeter) EpochStart(t time.Time) int64 {
+	return t.Unix() - t.Unix()%m.epoch
+}
+
+// SetCommitter commits the digest of each closed epoch through c (nil stops committing)
+func (m *UsageMeter) SetCommitter(c UsageCommitter) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.committer = c
+}
+
+// RecordServed counts bytes of a stored key's data sent from this node
+// A nil meter (metering disabled) records nothing.
+func (m *UsageMeter) RecordServed(storedKey string, n int) {
+	if m == nil || n <= 0 {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.served[usageKey{epochStart: m.EpochStart(time.Now()), user: usageOwner(storedKey)}] += int64(n)
+}
+
+// sample accrues byte-seconds for the time since the last sample and saves the bytes served
+// Time spanning an epoch boundary is split between the epochs.
+func (m *UsageMeter) sample(now time.Time) error {
+	now = now.Truncate(time.Second)
+
+	stored, err := m.storage.storedBytesByOwner()
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	last := m.lastSample
+	m.lastSample = now
+	served := m.served
+	m.served = make(map[usageKey]int64)
+	m.mu.Unlock()
+
+	usage := make(map[usageKey]*UserUsage)
+	entry := func(key usageKey) *UserUsage {
+		if usage[key] == nil {
+			usage[key] = &UserUsage{UserAddr: key.user}
+		}
+		return usage[key]
+	}
+
+	if !last.IsZero() {
+		for t := last.Unix(); t < now.Unix(); {
+			start := t - t%m.epoch
+			end := start + m.epoch
+			if end > now.Unix() {
+				end = now.Unix()
+			}
+			for user, size := range stored {
+				entry(usageKey{epochStart: start, user: user}).ByteSeconds += size * (end - t)
+			}
+			t = end
+		}
+	}
+	for key, n := range served {
+		entry(key).BytesServed += n
+	}
+
+	for key, u := range usage {
+		if err := m.storage.addUsage(key.epochStart, key.epochStart+m.epoch, u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Report builds the usage report of the epoch starting at epochStart
+func (m *UsageMeter) Report(epochStart int64) (*UsageReport, error) {
+	users, epochEnd, err := m.storage.usageForEpoch(epochStart)
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, fmt.Errorf("%w %d", ErrNoUsage, epochStart)
+	}
+
+	report := &UsageReport{
+		NodeID:     m.nodeID,
+		EpochStart: epochStart,
+		EpochEnd:   epochEnd,
+		Closed:     epochEnd <= time.Now().Unix(),
+		Users:      users,
+	}
+	digest := report.ComputeDigest()
+	report.Digest = hex.EncodeToString(digest[:])
+
+	committed, committedAt, err := m.storage.usageCommitment(epochStart)
+	if err != nil {
+		return nil, err
+	}
+	if committed == report.Digest {
+		report.CommittedAt = committedAt
+	}
+	return report, nil
+}
+
+// Epochs lists the starts of the epochs with recorded usage, newest first
+func (m *UsageMeter) Epochs() ([]int64, error) {
+	return m.storage.usageEpochs()
+}
+
+// commitClosedEpochs commits the digest of every closed epoch not committed yet
+// An epoch whose commit fails is retried after the next sample.
+func (m *UsageMeter) commitClosedEpochs(ctx context.Context, now time.Time) {
+	m.mu.Lock()
+	committer := m.committer
+	m.mu.Unlock()
+	if committer == nil {
+		return
+	}
+
+	epochs, err := m.storage.uncommittedUsageEpochs(m.EpochStart(now))
+	if err != nil {
+		fmt.Printf("⚠️  Failed to list uncommitted usage epochs: %v\n", err)
+		return
+	}
+
+	for _, start := range epochs {
+		report, err := m.Report(start)
+		if err != nil {
+			fmt.Printf("⚠️  Failed to build usage report for epoch %d: %v\n", start, err)
+			continue
+		}
+
+		digest := report.ComputeDigest()
+		if err := committer.CommitUsage(ctx, uint64(start), digest); err != nil {
+			fmt.Printf("⚠️  Failed to commit usage for epoch %d: %v\n", start, err)
+			return
+		}
+		if err := m.storage.recordUsageCommitment(start, report.Digest, now.Unix()); err != nil {
+			fmt.Printf("⚠️  Failed to record usage commitment for epoch %d: %v\n", start, err)
+			return
+		}
+		fmt.Printf("🧾 Committed usage of %d users for epoch %s\n", len(report.Users), time.Unix(start, 0).UTC().Format(time.RFC3339))
+	}
+}
+
+// meterUsage samples usage until the node shuts down
+func (n *DHTNode) meterUsage() {
+	if err := n.meter.sample(time.Now()); err != nil {
+		fmt.Printf("⚠️  Usage sample failed: %v\n", err)
+	}
+
+	ticker := time.NewTicker(usageSampleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := n.meter.sample(now); err != nil {
+				fmt.Printf("⚠️  Usage sample failed: %v\n", err)
+				continue
+			}
+			n.meter.commitClosedEpochs(n.ctx, now)
+		}
+	}
+}
+
+// ComputeDigest hashes the report's node, epoch and usage in a fixed encoding
+func (r *UsageReport) ComputeDigest() [32]byte {
+	var buf bytes.Buffer
+	writeString := func(s string) {
+		binary.Write(&buf, binary.BigEndian, uint32(len(s)))
+		buf.WriteString(s)
+	}
+
+	writeString("zentalk-usage-report")
+	writeString(r.NodeID)
+	binary.Write(&buf, binary.BigEndian, r.EpochStart)
+	binary.Write(&buf, binary.BigEndian, r.EpochEnd)
+	binary.Write(&buf, binary.BigEndian, uint32(len(r.Users)))
+	for _, u := range r.Users {
+		writeString(u.UserAddr)
+		binary.Write(&buf, binary.BigEndian, u.ByteSeconds)
+		binary.Write(&buf, binary.BigEndian, u.BytesServed)
+	}
+
+	return sha256.Sum256(buf.Bytes())
+}
+
+// WriteCSV writes the report as CSV, one row per user
+// gb_hours is byte_seconds in GB-hours, the usual unit for storage billing.
+func (r *UsageReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"epoch_start", "epoch_end", "user_addr", "byte_seconds", "gb_hours", "bytes_served"})
+
+	start := time.Unix(r.EpochStart, 0).UTC().Format(time.RFC3339)
+	end := time.Unix(r.EpochEnd, 0).UTC().Format(time.RFC3339)
+	for _, u := range r.Users {
+		cw.Write([]string{
+			start,
+			end,
+			u.UserAddr,
+			strconv.FormatInt(u.ByteSeconds, 10),
+			strconv.FormatFloat(float64(u.ByteSeconds)/(1e9*3600), 'f', 6, 64),
+			strconv.FormatInt(u.BytesServed, 10),
+		})
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// storedBytesByOwner returns the bytes stored for each user
+func (s *LocalStorage) storedBytesByOwner() (map[string]int64, error) {
+	rows, err := s.query(`SELECT user_addr, SUM(size) FROM chunks GROUP BY user_addr`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum stored bytes: %w", err)
+	}
+	defer rows.Close()
+
+	stored := make(map[string]int64)
+	for rows.Next() {
+		var key string
+		var size int64
+		if err := rows.Scan(&key, &size); err != nil {
+			return nil, fmt.Errorf("failed to scan stored bytes: %w", err)
+		}
+		stored[usageOwner(key)] += size
+	}
+	return stored, rows.Err()
+}
+
+// addUsage adds to a user's usage in an epoch
+func (s *LocalStorage) addUsage(epochStart, epochEnd int64, u *UserUsage) error {
+	query := `INSERT INTO usage_records (epoch_start, epoch_end, user_addr, byte_seconds, bytes_served)
+	          VALUES (?, ?, ?, ?, ?)
+	          ON CONFLICT (epoch_start, user_addr) DO UPDATE SET
+	              byte_seconds = usage_records.byte_seconds + excluded.byte_seconds,
+	              bytes_served = usage_records.bytes_served + excluded.bytes_served`
+
+	if _, err := s.exec(query, epochStart, epochEnd, u.UserAddr, u.ByteSeconds, u.BytesServed); err != nil {
+		return fmt.Errorf("failed to record usage: %w", err)
+	}
+	return nil
+}
+
+// usageForEpoch returns every user's usage in an epoch, sorted by address, and the epoch's end
+func (s *LocalStorage) usageForEpoch(epochStart int64) ([]UserUsage, int64, error) {
+	query := `SELECT user_addr, byte_seconds, bytes_served, epoch_end FROM usage_records WHERE epoch_start = ?`
+	rows, err := s.query(query, epochStart)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read usage: %w", err)
+	}
+	defer rows.Close()
+
+	var users []UserUsage
+	var epochEnd int64
+	for rows.Next() {
+		var u UserUsage
+		if err := rows.Scan(&u.UserAddr, &u.ByteSeconds, &u.BytesServed, &epochEnd); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan usage: %w", err)
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	sort.Slice(users, func(i, j int) bool { return users[i].UserAddr < users[j].UserAddr })
+	return users, epochEnd, nil
+}
+
+// usageEpochs lists the epochs with recorded usage, newest first
+func (s *LocalStorage) usageEpochs() ([]int64, error) {
+	return s.scanEpochs(`SELECT DISTINCT epoch_start FROM usage_records ORDER BY epoch_start DESC`)
+}
+
+// uncommittedUsageEpochs lists epochs starting before before whose digest is not committed, oldest first
+func (s *LocalStorage) uncommittedUsageEpochs(before int64) ([]int64, error) {
+	query := `SELECT DISTINCT epoch_start FROM usage_records
+	          WHERE epoch_start < ?
+	            AND epoch_start NOT IN (SELECT epoch_start FROM usage_commitments)
+	          ORDER BY epoch_start`
+	return s.scanEpochs(query, before)
+}
+
+// scanEpochs runs a query returning one epoch start per row
+func (s *LocalStorage) scanEpochs(query string, args ...interface{}) ([]int64, error) {
+	rows, err := s.query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list usage epochs: %w", err)
+	}
+	defer rows.Close()
+
+	var epochs []int64
+	for rows.Next() {
+		var start int64
+		if err := rows.Scan(&start); err != nil {
+			return nil, fmt.Errorf("failed to scan usage epoch: %w", err)
+		}
+		epochs = append(epochs, start)
+	}
+	return epochs, rows.Err()
+}
+
+// recordUsageCommitment notes that an epoch's digest was committed
+func (s *LocalStorage) recordUsageCommitment(epochStart int64, digest string, committedAt int64) error {
+	query := `INSERT INTO usage_commitments (epoch_start, digest, committed_at) VALUES (?, ?, ?)`
+	if _, err := s.exec(query, epochStart, digest, committedAt); err != nil {
+		return fmt.Errorf("failed to record usage commitment: %w", err)
+	}
+	return nil
+}
+
+// usageCommitment returns the digest committed for an epoch and when ("" = not committed)
+func (s *LocalStorage) usageCommitment(epochStart int64) (string, int64, error) {
+	var digest string
+	var committedAt int64
+	err := s.queryRow(`SELECT digest, committed_at FROM usage_commitments WHERE epoch_start = ?`, epochStart).Scan(&digest, &committedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", 0, nil
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read usage commitment: %w", err)
+	}
+	return digest, committedAt, nil
+}
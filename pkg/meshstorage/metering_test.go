@@ -0,0 +1,227 @@
+package meshstorage
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingCommitter remembers the digests committed to it
+type recordingCommitter struct {
+	commits map[uint64][32]byte
+	err     error
+}
+
+func (c *recordingCommitter) CommitUsage(ctx context.Context, epochStart uint64, digest [32]byte) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.commits[epochStart] = digest
+	return nil
+}
+
+func TestUsageMeterSplitsEpochs(t *testing.T) {
+	storage, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	// Shards of one user's chunks count toward that user
+	if err := storage.StoreChunk("0xalice_1_shard_0", 0, make([]byte, 100)); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.StoreChunk("0xalice_2_shard_3", 3, make([]byte, 50)); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.StoreChunk("0xbob_1_shard_1", 1, make([]byte, 10)); err != nil {
+		t.Fatal(err)
+	}
+
+	meter := newUsageMeter(storage, "node-1", time.Hour)
+	boundary := time.Unix(1_700_000_000-1_700_000_000%3600, 0)
+
+	// Half an hour on each side of the boundary
+	if err := meter.sample(boundary.Add(-30 * time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := meter.sample(boundary.Add(30 * time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, start := range []int64{boundary.Unix() - 3600, boundary.Unix()} {
+		report, err := meter.Report(start)
+		if err != nil {
+			t.Fatalf("Report(%d) error = %v", start, err)
+		}
+		if report.EpochEnd != start+3600 {
+			t.Errorf("EpochEnd = %d, want %d", report.EpochEnd, start+3600)
+		}
+		if len(report.Users) != 2 {
+			t.Fatalf("Report(%d) has %d users, want 2", start, len(report.Users))
+		}
+		if u := report.Users[0]; u.UserAddr != "0xalice" || u.ByteSeconds != 150*1800 {
+			t.Errorf("alice usage = %+v, want 150 bytes for 1800s", u)
+		}
+		if u := report.Users[1]; u.UserAddr != "0xbob" || u.ByteSeconds != 10*1800 {
+			t.Errorf("bob usage = %+v, want 10 bytes for 1800s", u)
+		}
+	}
+
+	epochs, err := meter.Epochs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(epochs) != 2 || epochs[0] != boundary.Unix() {
+		t.Errorf("Epochs() = %v, want newest first starting at %d", epochs, boundary.Unix())
+	}
+
+	if _, err := meter.Report(boundary.Unix() + 3600); !errors.Is(err, ErrNoUsage) {
+		t.Errorf("Report() of an unmetered epoch: err = %v, want ErrNoUsage", err)
+	}
+}
+
+func TestUsageMeterServedBytes(t *testing.T) {
+	storage, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	meter := newUsageMeter(storage, "node-1", time.Hour)
+	meter.RecordServed("0xalice_1_shard_0", 300)
+	meter.RecordServed("0xalice", 200)
+	meter.RecordServed("0xbob_4_shard_2", 0)
+
+	// Metering disabled: nothing to record into
+	var disabled *UsageMeter
+	disabled.RecordServed("0xalice", 1)
+
+	now := time.Now()
+	if err := meter.sample(now); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := meter.Report(meter.EpochStart(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Users) != 1 || report.Users[0].BytesServed != 500 {
+		t.Fatalf("Users = %+v, want alice with 500 bytes served", report.Users)
+	}
+	if report.Closed {
+		t.Error("Report of the current epoch is closed")
+	}
+
+	// Served bytes were flushed by the sample and are not counted twice
+	if err := meter.sample(now); err != nil {
+		t.Fatal(err)
+	}
+	if report, _ = meter.Report(meter.EpochStart(now)); report.Users[0].BytesServed != 500 {
+		t.Errorf("BytesServed after a second sample = %d, want 500", report.Users[0].BytesServed)
+	}
+}
+
+func TestUsageReportDigestAndCSV(t *testing.T) {
+	report := &UsageReport{
+		NodeID:     "node-1",
+		EpochStart: 1_699_999_200,
+		EpochEnd:   1_700_002_800,
+		Users: []UserUsage{
+			{UserAddr: "0xalice", ByteSeconds: 3_600_000_000_000, BytesServed: 42},
+			{UserAddr: "0xbob", ByteSeconds: 18000, BytesServed: 0},
+		},
+	}
+
+	digest := report.ComputeDigest()
+	if digest != report.ComputeDigest() {
+		t.Fatal("ComputeDigest() is not deterministic")
+	}
+
+	changed := *report
+	changed.Users = append([]UserUsage(nil), report.Users...)
+	changed.Users[1].BytesServed = 1
+	if changed.ComputeDigest() == digest {
+		t.Error("digest did not change with the usage")
+	}
+	changed = *report
+	changed.NodeID = "node-2"
+	if changed.ComputeDigest() == digest {
+		t.Error("digest did not change with the node")
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("CSV has %d lines, want header and 2 rows:\n%s", len(lines), buf.String())
+	}
+	if lines[0] != "epoch_start,epoch_end,user_addr,byte_seconds,gb_hours,bytes_served" {
+		t.Errorf("header = %q", lines[0])
+	}
+	if want := "2023-11-14T22:00:00Z,2023-11-14T23:00:00Z,0xalice,3600000000000,1.000000,42"; lines[1] != want {
+		t.Errorf("row = %q, want %q", lines[1], want)
+	}
+}
+
+func TestUsageMeterCommitsClosedEpochs(t *testing.T) {
+	storage, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	if err := storage.StoreChunk("0xalice_1_shard_0", 0, make([]byte, 100)); err != nil {
+		t.Fatal(err)
+	}
+
+	meter := newUsageMeter(storage, "node-1", time.Hour)
+	boundary := time.Unix(1_700_000_000-1_700_000_000%3600, 0)
+	now := boundary.Add(30 * time.Minute)
+	meter.sample(boundary.Add(-30 * time.Minute))
+	meter.sample(now)
+
+	// Without a committer nothing is committed
+	meter.commitClosedEpochs(context.Background(), now)
+
+	committer := &recordingCommitter{commits: make(map[uint64][32]byte), err: errors.New("chain down")}
+	meter.SetCommitter(committer)
+	meter.commitClosedEpochs(context.Background(), now)
+
+	closed := boundary.Unix() - 3600
+	if report, _ := meter.Report(closed); report.CommittedAt != 0 {
+		t.Fatal("failed commit was recorded")
+	}
+
+	// The epoch is retried once the committer works; the open epoch is left alone
+	committer.err = nil
+	meter.commitClosedEpochs(context.Background(), now)
+	if len(committer.commits) != 1 {
+		t.Fatalf("committed %d epochs, want only the closed one", len(committer.commits))
+	}
+
+	report, err := meter.Report(closed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := committer.commits[uint64(closed)]
+	if hex.EncodeToString(digest[:]) != report.Digest {
+		t.Errorf("committed digest %x, report digest %s", digest, report.Digest)
+	}
+	if report.CommittedAt != now.Unix() {
+		t.Errorf("CommittedAt = %d, want %d", report.CommittedAt, now.Unix())
+	}
+
+	// A committed epoch is not committed again
+	delete(committer.commits, uint64(closed))
+	meter.commitClosedEpochs(context.Background(), now)
+	if len(committer.commits) != 0 {
+		t.Error("closed epoch committed twice")
+	}
+}
@@ -3,21 +3,27 @@ package meshstorage
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/sqldb"
 )
 
 // Storage schema version constants
 const (
 	// CurrentSchemaVersion is the current database schema version
-	CurrentSchemaVersion = 1
+	CurrentSchemaVersion = 7
 
 	// MinSchemaVersion is the minimum supported schema version
 	MinSchemaVersion = 1
 )
 
+// ErrUnsupportedSchema is returned when the database schema is outside MinSchemaVersion..CurrentSchemaVersion
+var ErrUnsupportedSchema = errors.New("unsupported schema version")
+
 // SchemaVersion represents the database schema version metadata
 type SchemaVersion struct {
 	Version   int
@@ -26,6 +32,8 @@ type SchemaVersion struct {
 }
 
 // MigrationFunc is a function that performs a schema migration
+// Migrations are shared by SQLite and PostgreSQL; write DDL in SQLite syntax and
+// pass it through sqldb.DialectOf(db).Translate
 type MigrationFunc func(db *sql.DB) error
 
 // Migration represents a single database migration
@@ -44,31 +52,61 @@ var migrations = []Migration{
 		Up:          migration1Up,
 		Down:        migration1Down,
 	},
-	// Future migrations will be added here:
-	// {
-	//     Version:     2,
-	//     Description: "Add compression support",
-	//     Up:          migration2Up,
-	//     Down:        migration2Down,
-	// },
+	{
+		Version:     2,
+		Description: "Add access grants for chunk sharing",
+		Up:          migration2Up,
+		Down:        migration2Down,
+	},
+	{
+		Version:     3,
+		Description: "Add public content registry",
+		Up:          migration3Up,
+		Down:        migration3Down,
+	},
+	{
+		Version:     4,
+		Description: "Add users' storage node pins",
+		Up:          migration4Up,
+		Down:        migration4Down,
+	},
+	{
+		Version:     5,
+		Description: "Add storage lease expiry to chunks",
+		Up:          migration5Up,
+		Down:        migration5Down,
+	},
+	{
+		Version:     6,
+		Description: "Add per-user usage metering",
+		Up:          migration6Up,
+		Down:        migration6Down,
+	},
+	{
+		Version:     7,
+		Description: "Add distributed chunk manifests",
+		Up:          migration7Up,
+		Down:        migration7Down,
+	},
 }
 
 // GetSchemaVersion returns the current schema version from the database
 func GetSchemaVersion(db *sql.DB) (int, error) {
 	// Check if schema_version table exists
-	query := `SELECT name FROM sqlite_master WHERE type='table' AND name='schema_version'`
-	var tableName string
-	err := db.QueryRow(query).Scan(&tableName)
-	if err == sql.ErrNoRows {
-		// No schema_version table = version 0 (needs initialization)
-		return 0, nil
-	}
+	exists, err := sqldb.TableExists(db, "schema_version")
 	if err != nil {
 		return 0, fmt.Errorf("failed to check schema_version table: %w", err)
 	}
+	if !exists {
+		// No schema_version table = version 0 (needs initialization)
+		return 0, nil
+	}
 
-	// Get current version (order by ROWID to get the most recently inserted row)
-	query = `SELECT version FROM schema_version ORDER BY ROWID DESC LIMIT 1`
+	// Get current version (most recently inserted row; PostgreSQL has no ROWID)
+	query := `SELECT version FROM schema_version ORDER BY ROWID DESC LIMIT 1`
+	if sqldb.DialectOf(db) == sqldb.Postgres {
+		query = `SELECT version FROM schema_version ORDER BY applied_at DESC, version DESC LIMIT 1`
+	}
 	var version int
 	err = db.QueryRow(query).Scan(&version)
 	if err == sql.ErrNoRows {
@@ -84,7 +122,7 @@ func GetSchemaVersion(db *sql.DB) (int, error) {
 // setSchemaVersion records a new schema version
 func setSchemaVersion(db *sql.DB, version int, comment string) error {
 	query := `INSERT INTO schema_version (version, applied_at, comment) VALUES (?, ?, ?)`
-	_, err := db.Exec(query, version, time.Now().Unix(), comment)
+	_, err := db.Exec(sqldb.DialectOf(db).Rebind(query), version, time.Now().Unix(), comment)
 	if err != nil {
 		return fmt.Errorf("failed to set schema version: %w", err)
 	}
@@ -120,16 +158,19 @@ func RunMigrations(db *sql.DB, dataDir string) error {
 	}
 
 	if currentVersion > CurrentSchemaVersion {
-		return fmt.Errorf("database schema version (%d) is newer than supported version (%d) - please upgrade software",
-			currentVersion, CurrentSchemaVersion)
+		return fmt.Errorf("%w: database schema version (%d) is newer than supported version (%d) - please upgrade software",
+			ErrUnsupportedSchema, currentVersion, CurrentSchemaVersion)
 	}
 
-	// Create backup before migration
-	backupPath, err := createBackup(dataDir)
-	if err != nil {
-		return fmt.Errorf("failed to create backup: %w", err)
+	// Create backup before migration (PostgreSQL operators back up with pg_dump)
+	backupPath := "none (back up PostgreSQL with pg_dump)"
+	if sqldb.DialectOf(db) == sqldb.SQLite {
+		backupPath, err = createBackup(dataDir)
+		if err != nil {
+			return fmt.Errorf("failed to create backup: %w", err)
+		}
+		fmt.Printf("💾 Created backup: %s\n", backupPath)
 	}
-	fmt.Printf("💾 Created backup: %s\n", backupPath)
 
 	// Run migrations in order
 	for _, migration := range migrations {
@@ -165,6 +206,22 @@ func RunMigrations(db *sql.DB, dataDir string) error {
 	return nil
 }
 
+// initializeSchema brings a new, empty database straight to the current schema
+// Every migration is applied but only the final version is recorded, and no
+// backup is taken since there is nothing to restore
+func initializeSchema(db *sql.DB) error {
+	for _, migration := range migrations {
+		if migration.Version > CurrentSchemaVersion {
+			break
+		}
+		if err := migration.Up(db); err != nil {
+			return fmt.Errorf("migration %d failed: %w", migration.Version, err)
+		}
+	}
+
+	return setSchemaVersion(db, CurrentSchemaVersion, fmt.Sprintf("Initial schema (v%d)", CurrentSchemaVersion))
+}
+
 // createBackup creates a backup of the database and data directory
 func createBackup(dataDir string) (string, error) {
 	timestamp := time.Now().Format("20060102_150405")
@@ -258,25 +315,23 @@ func ValidateSchema(db *sql.DB) error {
 	}
 
 	if version < MinSchemaVersion {
-		return fmt.Errorf("schema version %d is too old (minimum: %d) - migration required", version, MinSchemaVersion)
+		return fmt.Errorf("%w: schema version %d is too old (minimum: %d) - migration required", ErrUnsupportedSchema, version, MinSchemaVersion)
 	}
 
 	if version > CurrentSchemaVersion {
-		return fmt.Errorf("schema version %d is too new (current: %d) - software upgrade required", version, CurrentSchemaVersion)
+		return fmt.Errorf("%w: schema version %d is too new (current: %d) - software upgrade required", ErrUnsupportedSchema, version, CurrentSchemaVersion)
 	}
 
 	// Check required tables exist
-	requiredTables := []string{"chunks", "schema_version"}
+	requiredTables := []string{"chunks", "schema_version", "access_grants", "public_content", "storage_pins", "usage_records", "usage_commitments", "chunk_manifests"}
 	for _, table := range requiredTables {
-		query := `SELECT name FROM sqlite_master WHERE type='table' AND name=?`
-		var tableName string
-		err := db.QueryRow(query, table).Scan(&tableName)
-		if err == sql.ErrNoRows {
-			return fmt.Errorf("required table missing: %s", table)
-		}
+		exists, err := sqldb.TableExists(db, table)
 		if err != nil {
 			return fmt.Errorf("failed to check table %s: %w", table, err)
 		}
+		if !exists {
+			return fmt.Errorf("required table missing: %s", table)
+		}
 	}
 
 	return nil
@@ -298,7 +353,7 @@ func migration1Up(db *sql.DB) error {
 		CREATE INDEX IF NOT EXISTS idx_schema_version ON schema_version(version);
 	`
 
-	if _, err := db.Exec(schema); err != nil {
+	if _, err := db.Exec(sqldb.DialectOf(db).Translate(schema)); err != nil {
 		return fmt.Errorf("failed to create schema_version table: %w", err)
 	}
 
@@ -311,18 +366,162 @@ func migration1Down(db *sql.DB) error {
 	return err
 }
 
-// Example future migration (commented out):
-// func migration2Up(db *sql.DB) error {
-//     // Add compression field to chunks table
-//     _, err := db.Exec(`ALTER TABLE chunks ADD COLUMN compression TEXT DEFAULT 'none'`)
-//     return err
-// }
-//
-// func migration2Down(db *sql.DB) error {
-//     // SQLite doesn't support DROP COLUMN, so we'd need to:
-//     // 1. Create new table without compression column
-//     // 2. Copy data
-//     // 3. Drop old table
-//     // 4. Rename new table
-//     return fmt.Errorf("downgrade from v2 to v1 not supported")
-// }
+// migration2Up creates the access grant table used by chunk sharing
+func migration2Up(db *sql.DB) error {
+	schema := `
+		CREATE TABLE IF NOT EXISTS access_grants (
+			owner_addr TEXT NOT NULL,
+			chunk_id INTEGER NOT NULL,
+			recipient_addr TEXT NOT NULL,
+			grant_data BLOB NOT NULL,
+			issued_at INTEGER NOT NULL,
+			expires_at INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (owner_addr, chunk_id, recipient_addr)
+		);
+		CREATE INDEX IF NOT EXISTS idx_grants_recipient ON access_grants(recipient_addr);
+	`
+
+	if _, err := db.Exec(sqldb.DialectOf(db).Translate(schema)); err != nil {
+		return fmt.Errorf("failed to create access_grants table: %w", err)
+	}
+
+	return nil
+}
+
+// migration2Down rolls back migration 2
+func migration2Down(db *sql.DB) error {
+	_, err := db.Exec(`DROP TABLE IF EXISTS access_grants`)
+	return err
+}
+
+// migration3Up creates the registry of public (unencrypted, hash-addressed) objects
+func migration3Up(db *sql.DB) error {
+	schema := `
+		CREATE TABLE IF NOT EXISTS public_content (
+			hash TEXT PRIMARY KEY,
+			content_type TEXT NOT NULL,
+			size INTEGER NOT NULL,
+			owner_public_key TEXT NOT NULL DEFAULT '',
+			require_token INTEGER NOT NULL DEFAULT 0,
+			chunk_meta BLOB NOT NULL,
+			created_at INTEGER NOT NULL
+		);
+	`
+
+	if _, err := db.Exec(sqldb.DialectOf(db).Translate(schema)); err != nil {
+		return fmt.Errorf("failed to create public_content table: %w", err)
+	}
+
+	return nil
+}
+
+// migration3Down rolls back migration 3
+func migration3Down(db *sql.DB) error {
+	_, err := db.Exec(`DROP TABLE IF EXISTS public_content`)
+	return err
+}
+
+// migration4Up creates the table of users' pinned storage nodes
+func migration4Up(db *sql.DB) error {
+	schema := `
+		CREATE TABLE IF NOT EXISTS storage_pins (
+			user_addr TEXT PRIMARY KEY,
+			pin_data BLOB NOT NULL,
+			issued_at INTEGER NOT NULL
+		);
+	`
+
+	if _, err := db.Exec(sqldb.DialectOf(db).Translate(schema)); err != nil {
+		return fmt.Errorf("failed to create storage_pins table: %w", err)
+	}
+
+	return nil
+}
+
+// migration4Down rolls back migration 4
+func migration4Down(db *sql.DB) error {
+	_, err := db.Exec(`DROP TABLE IF EXISTS storage_pins`)
+	return err
+}
+
+// migration5Up adds the lease expiry of each chunk (0 = no lease, kept indefinitely)
+func migration5Up(db *sql.DB) error {
+	schema := `
+		ALTER TABLE chunks ADD COLUMN lease_expires INTEGER NOT NULL DEFAULT 0;
+		CREATE INDEX IF NOT EXISTS idx_lease_expires ON chunks(lease_expires);
+	`
+
+	if _, err := db.Exec(sqldb.DialectOf(db).Translate(schema)); err != nil {
+		return fmt.Errorf("failed to add lease_expires column: %w", err)
+	}
+
+	return nil
+}
+
+// migration5Down rolls back migration 5
+func migration5Down(db *sql.DB) error {
+	if _, err := db.Exec(`DROP INDEX IF EXISTS idx_lease_expires`); err != nil {
+		return err
+	}
+	_, err := db.Exec(`ALTER TABLE chunks DROP COLUMN lease_expires`)
+	return err
+}
+
+// migration6Up adds per-user, per-epoch usage records and the digests committed for closed epochs
+func migration6Up(db *sql.DB) error {
+	schema := `
+		CREATE TABLE IF NOT EXISTS usage_records (
+			epoch_start INTEGER NOT NULL,
+			epoch_end INTEGER NOT NULL,
+			user_addr TEXT NOT NULL,
+			byte_seconds INTEGER NOT NULL DEFAULT 0,
+			bytes_served INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (epoch_start, user_addr)
+		);
+		CREATE TABLE IF NOT EXISTS usage_commitments (
+			epoch_start INTEGER PRIMARY KEY,
+			digest TEXT NOT NULL,
+			committed_at INTEGER NOT NULL
+		);
+	`
+
+	if _, err := db.Exec(sqldb.DialectOf(db).Translate(schema)); err != nil {
+		return fmt.Errorf("failed to create usage tables: %w", err)
+	}
+
+	return nil
+}
+
+// migration6Down rolls back migration 6
+func migration6Down(db *sql.DB) error {
+	if _, err := db.Exec(`DROP TABLE IF EXISTS usage_commitments`); err != nil {
+		return err
+	}
+	_, err := db.Exec(`DROP TABLE IF EXISTS usage_records`)
+	return err
+}
+
+// migration7Up creates the table of distributed chunk manifests (where each shard of a chunk went)
+func migration7Up(db *sql.DB) error {
+	schema := `
+		CREATE TABLE IF NOT EXISTS chunk_manifests (
+			user_addr TEXT NOT NULL,
+			chunk_id INTEGER NOT NULL,
+			manifest BLOB NOT NULL,
+			updated_at INTEGER NOT NULL,
+			PRIMARY KEY (user_addr, chunk_id)
+		);
+	`
+
+	if _, err := db.Exec(sqldb.DialectOf(db).Translate(schema)); err != nil {
+		return fmt.Errorf("failed to create chunk_manifests table: %w", err)
+	}
+
+	return nil
+}
+
+// migration7Down rolls back migration 7
+func migration7Down(db *sql.DB) error {
+	_, err := db.Exec(`DROP TABLE IF EXISTS chunk_manifests`)
+	return err
+}
@@ -6,6 +6,7 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"fmt"
+	"path/filepath"
 	"sync"
 	"time"
 
@@ -30,6 +31,10 @@ type DHTNode struct {
 	mu        sync.RWMutex
 	peers     map[peer.ID]*PeerInfo
 	bootstrapped bool
+	maintenance  *maintenanceRegistry // Peers that announced planned downtime
+	blocklist    *peerBlocklist       // Peers banned by the operator
+	integrity    *integrityTracker    // Checksummed shard transfers per peer
+	meter        *UsageMeter          // Per-user usage accounting (nil = disabled)
 }
 
 // PeerInfo contains information about a connected peer
@@ -46,6 +51,15 @@ type NodeConfig struct {
 	DataDir       string
 	BootstrapPeers []string
 	PrivateKey    crypto.PrivKey // Optional: provide your own key
+	DatabaseDSN   string         // Optional: postgres:// DSN for chunk storage (default: SQLite in DataDir)
+	CacheBytes    int64          // Optional: hot chunk cache budget (0 = DefaultCacheBytes, negative disables)
+	ListenHosts   []string       // Optional: IP addresses to listen on (default: all interfaces of AddressFamily)
+	AddressFamily string         // Optional: "dual" (default), "ipv4" or "ipv6"
+	BootstrapOnly bool           // Optional: run without storage, only helping peers join the network
+	MaxConnections int           // Optional: connection limit for bootstrap-only nodes (0 = DefaultBootstrapConnections)
+	LeaseGCInterval time.Duration // Optional: how often chunks with expired leases are deleted (0 = DefaultLeaseGCInterval, negative disables)
+	UsageEpoch    time.Duration  // Optional: period of usage reports (0 = DefaultUsageEpoch, negative disables metering)
+	Quota         QuotaConfig    // Optional: limits on stored data, in total and per user (zero = unlimited)
 }
 
 // NewDHTNode creates a new DHT node
@@ -64,37 +78,73 @@ func NewDHTNode(ctx context.Context, config *NodeConfig) (*DHTNode, error) {
 		}
 	}
 
+	// Banned peers are refused at the connection level
+	blocklistPath := ""
+	if config.DataDir != "" {
+		blocklistPath = filepath.Join(config.DataDir, "blocklist.json")
+	}
+	blocklist, err := loadPeerBlocklist(blocklistPath)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create libp2p host
-	listenAddr := fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", config.Port)
+	listenAddrs, err := listenMultiaddrs(config.ListenHosts, config.AddressFamily, config.Port)
+	if err != nil {
+		return nil, err
+	}
 
-	h, err := libp2p.New(
+	opts := []libp2p.Option{
 		libp2p.Identity(priv),
-		libp2p.ListenAddrStrings(listenAddr),
+		libp2p.ListenAddrStrings(listenAddrs...),
 		libp2p.DefaultTransports,
 		libp2p.DefaultMuxers,
 		libp2p.DefaultSecurity,
 		libp2p.NATPortMap(),
 		libp2p.EnableNATService(),
-	)
+		libp2p.ConnectionGater(blocklist),
+	}
+	if config.BootstrapOnly {
+		bootstrapOpts, err := bootstrapOptions(config.MaxConnections)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, bootstrapOpts...)
+	}
+
+	h, err := libp2p.New(opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create libp2p host: %w", err)
 	}
 
-	// Create DHT
+	// Create DHT (a protocol prefix of our own is required to validate ZenTalk records)
 	dhtInst, err := dht.New(ctx, h,
 		dht.Mode(dht.ModeServer),
 		dht.BootstrapPeers(),
+		dht.ProtocolPrefix(DHTProtocolPrefix),
+		dht.NamespacedValidator(DHTNamespace, recordValidator{history: newRecordHistory()}),
+		dht.NamespacedValidator(LegacyShardRecordNamespace, shardRecordValidator{history: newRecordHistory()}),
 	)
 	if err != nil {
 		h.Close()
 		return nil, fmt.Errorf("failed to create DHT: %w", err)
 	}
 
-	// Create local storage
-	storage, err := NewLocalStorage(config.DataDir)
-	if err != nil {
-		h.Close()
-		return nil, fmt.Errorf("failed to create storage: %w", err)
+	// Create local storage (bootstrap-only nodes store nothing)
+	var storage *LocalStorage
+	if !config.BootstrapOnly {
+		storage, err = NewLocalStorageWithDSN(config.DatabaseDSN, config.DataDir)
+		if err != nil {
+			h.Close()
+			return nil, fmt.Errorf("failed to create storage: %w", err)
+		}
+
+		cacheBytes := config.CacheBytes
+		if cacheBytes == 0 {
+			cacheBytes = DefaultCacheBytes
+		}
+		storage.EnableCache(cacheBytes)
+		storage.SetQuota(config.Quota)
 	}
 
 	nodeCtx, cancel := context.WithCancel(ctx)
@@ -107,6 +157,9 @@ func NewDHTNode(ctx context.Context, config *NodeConfig) (*DHTNode, error) {
 		storage:   storage,
 		peers:     make(map[peer.ID]*PeerInfo),
 		bootstrapped: false,
+		maintenance:  newMaintenanceRegistry(),
+		blocklist:    blocklist,
+		integrity:    newIntegrityTracker(),
 	}
 
 	// Bootstrap DHT if peers provided
@@ -120,9 +173,29 @@ func NewDHTNode(ctx context.Context, config *NodeConfig) (*DHTNode, error) {
 	// Start peer monitoring
 	go node.monitorPeers()
 
+	// Collect chunks whose storage lease has run out
+	if storage != nil && config.LeaseGCInterval >= 0 {
+		interval := config.LeaseGCInterval
+		if interval == 0 {
+			interval = DefaultLeaseGCInterval
+		}
+		go node.collectLeases(interval)
+	}
+
+	// Meter each user's storage and bandwidth for usage reports
+	if storage != nil && config.UsageEpoch >= 0 {
+		node.meter = newUsageMeter(storage, node.ID().String(), config.UsageEpoch)
+		go node.meterUsage()
+	}
+
 	return node, nil
 }
 
+// UsageMeter returns the node's usage meter (nil when metering is disabled)
+func (n *DHTNode) UsageMeter() *UsageMeter {
+	return n.meter
+}
+
 // Bootstrap connects to bootstrap peers and joins the DHT network
 func (n *DHTNode) Bootstrap(bootstrapPeers []string) error {
 	n.mu.Lock()
@@ -309,7 +382,7 @@ func (n *DHTNode) checkPeerHealth() {
 	}
 }
 
-// Storage returns the local storage instance
+// Storage returns the local storage instance (nil for bootstrap-only nodes)
 func (n *DHTNode) Storage() *LocalStorage {
 	return n.storage
 }
@@ -384,9 +457,13 @@ type NodeInfo struct {
 }
 
 func (n *DHTNode) GetNodeInfo() (*NodeInfo, error) {
-	stats, err := n.storage.GetStats()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get storage stats: %w", err)
+	var stats *StorageStats
+	if n.storage != nil {
+		var err error
+		stats, err = n.storage.GetStats()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get storage stats: %w", err)
+		}
 	}
 
 	addrs := make([]string, len(n.host.Addrs()))
@@ -418,8 +495,10 @@ func (n *DHTNode) Close() error {
 	}
 
 	// Close storage
-	if err := n.storage.Close(); err != nil {
-		fmt.Printf("Error closing storage: %v\n", err)
+	if n.storage != nil {
+		if err := n.storage.Close(); err != nil {
+			fmt.Printf("Error closing storage: %v\n", err)
+		}
 	}
 
 	return nil
@@ -0,0 +1,358 @@
+// Package meshstorage provides distributed storage for ZenTalk encrypted chat history
+package meshstorage
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// MaxPinnedNodes caps the storage nodes a user may pin their data to
+const MaxPinnedNodes = 8
+
+// ErrPinSetNotFound is returned when a user has no pin set
+var ErrPinSetNotFound = errors.New("pin set not found")
+
+// PinSet names storage nodes (e.g. the user's own) that must hold at least
+// MinShards of each of the user's chunks
+// Placement and repair put shards on the pinned nodes before any others, and
+// health checks report chunks that fall short. Like grants, pin sets are signed
+// by the user so a node only applies ones the user issued. The owner key is
+// tied to UserAddr by a wallet signature, which a node requires for a user's
+// first pin set and for any that changes the owner key.
+type PinSet struct {
+	UserAddr        string   `json:"userAddr"`
+	Nodes           []string `json:"nodes"`          // Peer IDs
+	MinShards       int      `json:"minShards"`      // Shards of each chunk the pinned nodes must hold
+	OwnerPublicKey  string   `json:"ownerPublicKey"` // PEM; verifies Signature
+	IssuedAt        int64    `json:"issuedAt"`
+	Signature       []byte   `json:"signature"`
+	WalletSignature []byte   `json:"walletSignature,omitempty"` // UserAddr's wallet over OwnerBindingMessage
+}
+
+// PinViolation is a chunk with fewer available shards on pinned nodes than its user requires
+type PinViolation struct {
+	UserAddr     string `json:"userAddr"`
+	ChunkID      int    `json:"chunkID"`
+	PinnedShards int    `json:"pinnedShards"`
+	Required     int    `json:"required"`
+}
+
+// SigningPayload returns the bytes covered by the user's signature
+func (p *PinSet) SigningPayload() []byte {
+	return []byte(fmt.Sprintf("zentalk-pins|%s|%s|%d|%d",
+		p.UserAddr, strings.Join(p.Nodes, ","), p.MinShards, p.IssuedAt))
+}
+
+// Sign signs the pin set with the user's identity key and embeds the matching public key
+func (p *PinSet) Sign(ownerKey *rsa.PrivateKey) error {
+	publicPEM, err := crypto.ExportPublicKeyPEM(&ownerKey.PublicKey)
+	if err != nil {
+		return fmt.Errorf("failed to export owner key: %w", err)
+	}
+	p.OwnerPublicKey = string(publicPEM)
+
+	signature, err := crypto.SignData(p.SigningPayload(), ownerKey)
+	if err != nil {
+		return fmt.Errorf("failed to sign pin set: %w", err)
+	}
+	p.Signature = signature
+	return nil
+}
+
+// OwnerBindingMessage returns the text UserAddr's wallet signs to vouch for OwnerPublicKey
+// Wallets sign it as an Ethereum personal message (EIP-191).
+func (p *PinSet) OwnerBindingMessage() []byte {
+	return WalletBindingMessage("pins", p.UserAddr, p.OwnerPublicKey)
+}
+
+// BindWallet signs OwnerBindingMessage with the user's wallet key
+// Call it after Sign, which sets the owner key the binding covers.
+func (p *PinSet) BindWallet(walletKey *ecdsa.PrivateKey) error {
+	signature, err := signWalletMessage(p.OwnerBindingMessage(), walletKey)
+	if err != nil {
+		return err
+	}
+	p.WalletSignature = signature
+	return nil
+}
+
+// VerifyWallet checks the wallet signature was made by UserAddr
+func (p *PinSet) VerifyWallet() error {
+	return verifyWalletMessage(p.UserAddr, p.OwnerBindingMessage(), p.WalletSignature)
+}
+
+// Verify checks the pin set is well formed and signed
+func (p *PinSet) Verify() error {
+	if _, err := p.PeerIDs(); err != nil {
+		return err
+	}
+
+	publicKey, err := crypto.ImportPublicKeyPEM([]byte(p.OwnerPublicKey))
+	if err != nil {
+		return fmt.Errorf("invalid owner public key: %w", err)
+	}
+	if err := crypto.VerifySignature(p.SigningPayload(), p.Signature, publicKey); err != nil {
+		return fmt.Errorf("invalid pin set signature: %w", err)
+	}
+	return nil
+}
+
+// PeerIDs returns the pinned nodes, checking the pin set's bounds
+func (p *PinSet) PeerIDs() ([]peer.ID, error) {
+	if p.UserAddr == "" {
+		return nil, fmt.Errorf("pin set has no user")
+	}
+	if len(p.Nodes) == 0 || len(p.Nodes) > MaxPinnedNodes {
+		return nil, fmt.Errorf("pin set must name 1 to %d nodes, has %d", MaxPinnedNodes, len(p.Nodes))
+	}
+	if p.MinShards < 1 || p.MinShards > MaxTotalShards {
+		return nil, fmt.Errorf("pin set minimum must be 1 to %d shards, is %d", MaxTotalShards, p.MinShards)
+	}
+
+	ids := make([]peer.ID, 0, len(p.Nodes))
+	for _, node := range p.Nodes {
+		id, err := peer.Decode(node)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pinned node %q: %w", node, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// pinTarget is a parsed pin set as placement uses it
+type pinTarget struct {
+	nodes     []peer.ID
+	minShards int
+}
+
+// has reports whether id is one of the pinned nodes
+func (t *pinTarget) has(id peer.ID) bool {
+	for _, node := range t.nodes {
+		if node == id {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckPinSet checks a pin set's bounds against the coding new chunks are stored with
+func (ds *DistributedStorage) CheckPinSet(pins *PinSet) error {
+	if _, err := pins.PeerIDs(); err != nil {
+		return err
+	}
+	if total := ds.erasure.TotalShards(); pins.MinShards > total {
+		return fmt.Errorf("pin set minimum must be 1 to %d shards, is %d", total, pins.MinShards)
+	}
+	return nil
+}
+
+// SetPinSet makes placement and repair of a user's chunks honor pins
+// The pin set is not verified here; callers taking it from users call Verify.
+func (ds *DistributedStorage) SetPinSet(pins *PinSet) error {
+	if err := ds.CheckPinSet(pins); err != nil {
+		return err
+	}
+	ids, err := pins.PeerIDs()
+	if err != nil {
+		return err
+	}
+
+	ds.pinsMu.Lock()
+	ds.pins[pins.UserAddr] = &pinTarget{nodes: ids, minShards: pins.MinShards}
+	ds.pinsMu.Unlock()
+
+	fmt.Printf("📌 Pinned %d shards of each chunk of %s to %d nodes\n", pins.MinShards, pins.UserAddr, len(ids))
+	return nil
+}
+
+// RemovePinSet lets a user's chunks be placed anywhere again
+func (ds *DistributedStorage) RemovePinSet(userAddr string) {
+	ds.pinsMu.Lock()
+	delete(ds.pins, userAddr)
+	ds.pinsMu.Unlock()
+}
+
+// pinTargetFor returns a user's pins (nil = none)
+func (ds *DistributedStorage) pinTargetFor(userAddr string) *pinTarget {
+	ds.pinsMu.RLock()
+	defer ds.pinsMu.RUnlock()
+	return ds.pins[userAddr]
+}
+
+// applyPins moves shard placements onto a user's pinned nodes until MinShards are there
+// targets maps every shard index to a peer. Positions not marked in fixed are
+// moved first, data shards before parity, so reads can often be served by the
+// pinned nodes alone; fixed positions (shards already stored) are moved only if
+// that is not enough. Pinned nodes in announced maintenance are skipped.
+func (ds *DistributedStorage) applyPins(userAddr string, targets []peer.ID, fixed []bool) []peer.ID {
+	pins := ds.pinTargetFor(userAddr)
+	if pins == nil {
+		return targets
+	}
+
+	var nodes []peer.ID
+	for _, id := range pins.nodes {
+		if id == ds.node.ID() || !ds.node.PeerInMaintenance(id) {
+			nodes = append(nodes, id)
+		}
+	}
+	if len(nodes) == 0 {
+		return targets
+	}
+
+	pinned := 0
+	for _, id := range targets {
+		if pins.has(id) {
+			pinned++
+		}
+	}
+
+	// Unstored positions first, then stored ones, each in shard order
+	order := make([]int, 0, len(targets))
+	for _, stored := range []bool{false, true} {
+		for i := range targets {
+			if (fixed != nil && fixed[i]) == stored {
+				order = append(order, i)
+			}
+		}
+	}
+
+	// A chunk stored with fewer shards than the minimum pins all of them
+	required := min(pins.minShards, len(targets))
+
+	placed := append([]peer.ID(nil), targets...)
+	next := 0
+	for _, i := range order {
+		if pinned >= required {
+			break
+		}
+		if pins.has(placed[i]) {
+			continue
+		}
+		placed[i] = nodes[next%len(nodes)]
+		next++
+		pinned++
+	}
+	return placed
+}
+
+// PinStatus returns how many of a chunk's available shards are on pinned nodes, and how many must be
+// status marks the shards known to be available; nil counts every recorded
+// location. required is 0 when the chunk's user has no pin set, and at most
+// the chunk's shard count.
+func (ds *DistributedStorage) PinStatus(chunk *DistributedChunk, status []bool) (pinned, required int) {
+	pins := ds.pinTargetFor(chunk.UserAddr)
+	if pins == nil {
+		return 0, 0
+	}
+
+	for _, loc := range chunk.ShardLocations {
+		if status != nil && (loc.ShardIndex >= len(status) || !status[loc.ShardIndex]) {
+			continue
+		}
+		if pins.has(loc.PeerID) {
+			pinned++
+		}
+	}
+	return pinned, min(pins.minShards, chunk.Coding().TotalShards())
+}
+
+// pinViolation returns how a chunk falls short of its user's pins (nil = it doesn't)
+func (ds *DistributedStorage) pinViolation(chunk *DistributedChunk, status []bool) *PinViolation {
+	pinned, required := ds.PinStatus(chunk, status)
+	if pinned >= required {
+		return nil
+	}
+	return &PinViolation{
+		UserAddr:     chunk.UserAddr,
+		ChunkID:      chunk.ChunkID,
+		PinnedShards: pinned,
+		Required:     required,
+	}
+}
+
+// IsPinned reports whether a shard location is on one of its user's pinned nodes
+func (ds *DistributedStorage) IsPinned(userAddr string, id peer.ID) bool {
+	pins := ds.pinTargetFor(userAddr)
+	return pins != nil && pins.has(id)
+}
+
+// StorePinSet saves a user's pin set, replacing any earlier one
+func (s *LocalStorage) StorePinSet(pins *PinSet) error {
+	data, err := json.Marshal(pins)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pin set: %w", err)
+	}
+
+	query := `INSERT INTO storage_pins (user_addr, pin_data, issued_at)
+	          VALUES (?, ?, ?)
+	          ON CONFLICT (user_addr) DO UPDATE SET
+	              pin_data = excluded.pin_data, issued_at = excluded.issued_at`
+
+	if _, err := s.exec(query, pins.UserAddr, data, pins.IssuedAt); err != nil {
+		return fmt.Errorf("failed to store pin set: %w", err)
+	}
+	return nil
+}
+
+// GetPinSet returns a user's pin set
+func (s *LocalStorage) GetPinSet(userAddr string) (*PinSet, error) {
+	var data []byte
+	err := s.queryRow(`SELECT pin_data FROM storage_pins WHERE user_addr = ?`, userAddr).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, ErrPinSetNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pin set: %w", err)
+	}
+
+	var pins PinSet
+	if err := json.Unmarshal(data, &pins); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal pin set: %w", err)
+	}
+	return &pins, nil
+}
+
+// ListPinSets returns every stored pin set
+func (s *LocalStorage) ListPinSets() ([]*PinSet, error) {
+	rows, err := s.query(`SELECT pin_data FROM storage_pins ORDER BY issued_at`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pin sets: %w", err)
+	}
+	defer rows.Close()
+
+	var sets []*PinSet
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to scan pin set: %w", err)
+		}
+		var pins PinSet
+		if err := json.Unmarshal(data, &pins); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal pin set: %w", err)
+		}
+		sets = append(sets, &pins)
+	}
+	return sets, rows.Err()
+}
+
+// DeletePinSet removes a user's pin set
+func (s *LocalStorage) DeletePinSet(userAddr string) error {
+	result, err := s.exec(`DELETE FROM storage_pins WHERE user_addr = ?`, userAddr)
+	if err != nil {
+		return fmt.Errorf("failed to delete pin set: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrPinSetNotFound
+	}
+	return nil
+}
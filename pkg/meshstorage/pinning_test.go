@@ -0,0 +1,218 @@
+package meshstorage
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	libp2ptest "github.com/libp2p/go-libp2p/core/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const pinUser = "0x4444444444444444444444444444444444444444"
+
+func newSignedPinSet(t *testing.T, minShards int, nodes ...peer.ID) *PinSet {
+	ownerKey, err := crypto.GenerateRSAKeyPair()
+	require.NoError(t, err)
+
+	pins := &PinSet{UserAddr: pinUser, MinShards: minShards, IssuedAt: time.Now().Unix()}
+	for _, id := range nodes {
+		pins.Nodes = append(pins.Nodes, id.String())
+	}
+	require.NoError(t, pins.Sign(ownerKey))
+	return pins
+}
+
+// TestPinSetVerify tests pin set signatures and bounds
+func TestPinSetVerify(t *testing.T) {
+	pins := newSignedPinSet(t, 3, libp2ptest.RandPeerIDFatal(t))
+	assert.NoError(t, pins.Verify())
+
+	// Lowering the minimum breaks the signature
+	forged := *pins
+	forged.MinShards = 1
+	assert.Error(t, forged.Verify())
+
+	for name, bad := range map[string]PinSet{
+		"no nodes":      {UserAddr: pinUser, MinShards: 1},
+		"zero minimum":  {UserAddr: pinUser, MinShards: 0, Nodes: pins.Nodes},
+		"over maximum":  {UserAddr: pinUser, MinShards: MaxTotalShards + 1, Nodes: pins.Nodes},
+		"invalid node":  {UserAddr: pinUser, MinShards: 1, Nodes: []string{"not-a-peer"}},
+		"too many node": {UserAddr: pinUser, MinShards: 1, Nodes: make([]string, MaxPinnedNodes+1)},
+	} {
+		_, err := bad.PeerIDs()
+		assert.Error(t, err, name)
+	}
+}
+
+// TestPinSetWallet tests that only the address's wallet can bind an owner key to it
+func TestPinSetWallet(t *testing.T) {
+	walletKey, err := ethcrypto.GenerateKey()
+	require.NoError(t, err)
+	ownerKey, err := crypto.GenerateRSAKeyPair()
+	require.NoError(t, err)
+
+	pins := &PinSet{
+		UserAddr:  ethcrypto.PubkeyToAddress(walletKey.PublicKey).Hex(),
+		Nodes:     []string{libp2ptest.RandPeerIDFatal(t).String()},
+		MinShards: 2,
+		IssuedAt:  time.Now().Unix(),
+	}
+	require.NoError(t, pins.Sign(ownerKey))
+	assert.Error(t, pins.VerifyWallet(), "unbound pin set")
+
+	require.NoError(t, pins.BindWallet(walletKey))
+	assert.NoError(t, pins.VerifyWallet())
+
+	// Wallets report the recovery ID as 27/28
+	pins.WalletSignature[ethcrypto.RecoveryIDOffset] += 27
+	assert.NoError(t, pins.VerifyWallet())
+
+	// A second key claiming the address can't reuse the user's binding or make its own
+	attackerKey, err := crypto.GenerateRSAKeyPair()
+	require.NoError(t, err)
+	attackerWallet, err := ethcrypto.GenerateKey()
+	require.NoError(t, err)
+
+	claim := &PinSet{UserAddr: pins.UserAddr, Nodes: pins.Nodes, MinShards: TotalShards, IssuedAt: pins.IssuedAt + 1}
+	require.NoError(t, claim.Sign(attackerKey))
+	assert.NoError(t, claim.Verify())
+
+	claim.WalletSignature = pins.WalletSignature
+	assert.Error(t, claim.VerifyWallet())
+
+	require.NoError(t, claim.BindWallet(attackerWallet))
+	assert.Error(t, claim.VerifyWallet())
+}
+
+// TestPinSetStorage tests storing, replacing and removing pin sets
+func TestPinSetStorage(t *testing.T) {
+	storage, err := NewLocalStorage(t.TempDir())
+	require.NoError(t, err)
+	defer storage.Close()
+
+	_, err = storage.GetPinSet(pinUser)
+	assert.True(t, errors.Is(err, ErrPinSetNotFound))
+
+	require.NoError(t, storage.StorePinSet(newSignedPinSet(t, 2, libp2ptest.RandPeerIDFatal(t))))
+	replacement := newSignedPinSet(t, 4, libp2ptest.RandPeerIDFatal(t))
+	require.NoError(t, storage.StorePinSet(replacement))
+
+	got, err := storage.GetPinSet(pinUser)
+	require.NoError(t, err)
+	assert.Equal(t, replacement.Nodes, got.Nodes)
+	assert.NoError(t, got.Verify())
+
+	sets, err := storage.ListPinSets()
+	require.NoError(t, err)
+	assert.Len(t, sets, 1)
+
+	require.NoError(t, storage.DeletePinSet(pinUser))
+	assert.True(t, errors.Is(storage.DeletePinSet(pinUser), ErrPinSetNotFound))
+}
+
+// TestApplyPins tests that placement and repair move shards onto pinned nodes
+func TestApplyPins(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	node, err := NewDHTNode(ctx, &NodeConfig{Port: 0, DataDir: filepath.Join(t.TempDir(), "node1")})
+	require.NoError(t, err)
+	defer node.Close()
+
+	ds, err := NewDistributedStorage(node)
+	require.NoError(t, err)
+	defer ds.StopMonitoring()
+
+	a, b := libp2ptest.RandPeerIDFatal(t), libp2ptest.RandPeerIDFatal(t)
+	targets := make([]peer.ID, TotalShards)
+	for i := range targets {
+		targets[i] = node.ID()
+	}
+
+	// No pins: placement is untouched
+	assert.Equal(t, targets, ds.applyPins(pinUser, targets, nil))
+
+	require.NoError(t, ds.SetPinSet(newSignedPinSet(t, 3, a, b)))
+
+	// New placements: data shards go to the pinned nodes in turn
+	placed := ds.applyPins(pinUser, targets, nil)
+	assert.Equal(t, []peer.ID{a, b, a}, placed[:3])
+	assert.Equal(t, targets[3:], placed[3:])
+
+	// Repair: missing shards are placed first, stored ones moved only to make up the rest
+	fixed := make([]bool, TotalShards)
+	for i := 0; i < TotalShards-2; i++ {
+		fixed[i] = true
+	}
+	placed = ds.applyPins(pinUser, targets, fixed)
+	assert.Equal(t, a, placed[0])
+	assert.Equal(t, []peer.ID{a, b}, placed[TotalShards-2:])
+	assert.Equal(t, targets[1:TotalShards-2], placed[1:TotalShards-2])
+
+	// Health reports count available shards on pinned nodes only
+	chunk := &DistributedChunk{UserAddr: pinUser, ChunkID: 1, ShardLocations: make([]ShardLocation, TotalShards)}
+	status := make([]bool, TotalShards)
+	for i, id := range placed {
+		chunk.ShardLocations[i] = ShardLocation{ShardIndex: i, PeerID: id}
+		status[i] = true
+	}
+	assert.Nil(t, ds.pinViolation(chunk, status))
+
+	status[TotalShards-1] = false
+	violation := ds.pinViolation(chunk, status)
+	require.NotNil(t, violation)
+	assert.Equal(t, 2, violation.PinnedShards)
+	assert.Equal(t, 3, violation.Required)
+
+	ds.RemovePinSet(pinUser)
+	assert.Nil(t, ds.pinViolation(chunk, status))
+}
+
+// TestPinsFollowCoding tests that pin minimums are bounded by the erasure coding
+func TestPinsFollowCoding(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	node, err := NewDHTNode(ctx, &NodeConfig{Port: 0, DataDir: filepath.Join(t.TempDir(), "node1")})
+	require.NoError(t, err)
+	defer node.Close()
+
+	coding := ErasureConfig{DataShards: 4, ParityShards: 2}
+	ds, err := NewDistributedStorageWithErasure(node, coding)
+	require.NoError(t, err)
+	defer ds.StopMonitoring()
+
+	a := libp2ptest.RandPeerIDFatal(t)
+	assert.Error(t, ds.CheckPinSet(newSignedPinSet(t, coding.TotalShards()+1, a)))
+	assert.Error(t, ds.SetPinSet(newSignedPinSet(t, TotalShards, a)))
+	assert.NoError(t, ds.CheckPinSet(newSignedPinSet(t, coding.TotalShards(), a)))
+
+	// A pin set accepted under a larger coding pins every shard of a smaller chunk
+	ds.pinsMu.Lock()
+	ds.pins[pinUser] = &pinTarget{nodes: []peer.ID{a}, minShards: TotalShards}
+	ds.pinsMu.Unlock()
+
+	targets := make([]peer.ID, coding.TotalShards())
+	for i := range targets {
+		targets[i] = node.ID()
+	}
+	placed := ds.applyPins(pinUser, targets, nil)
+
+	chunk := &DistributedChunk{UserAddr: pinUser, ChunkID: 1, Erasure: coding}
+	for i, id := range placed {
+		assert.Equal(t, a, id)
+		chunk.ShardLocations = append(chunk.ShardLocations, ShardLocation{ShardIndex: i, PeerID: id})
+	}
+
+	pinned, required := ds.PinStatus(chunk, nil)
+	assert.Equal(t, coding.TotalShards(), pinned)
+	assert.Equal(t, coding.TotalShards(), required)
+	assert.Nil(t, ds.pinViolation(chunk, nil))
+}
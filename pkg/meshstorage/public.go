@@ -0,0 +1,151 @@
+// Package meshstorage provides distributed storage for ZenTalk encrypted chat history
+package meshstorage
+
+import (
+	"crypto/rsa"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+)
+
+// PublicContentPrefix namespaces public objects in distributed storage
+// Public objects are stored as chunk 0 of "public:<sha256>", which cannot collide
+// with a user's Ethereum address
+const PublicContentPrefix = "public:"
+
+// ErrPublicObjectNotFound is returned when no public object has the requested hash
+var ErrPublicObjectNotFound = errors.New("public object not found")
+
+// ErrPublicObjectExists is returned when a public object with the same hash is already recorded
+var ErrPublicObjectExists = errors.New("public object already exists")
+
+// PublicObject is unencrypted content addressed by the SHA-256 of its bytes
+// Used for group avatars, sticker packs and channel media that every viewer can read
+type PublicObject struct {
+	Hash           string            `json:"hash"` // Hex SHA-256 of the content
+	ContentType    string            `json:"contentType"`
+	Size           int               `json:"size"`
+	OwnerPublicKey string            `json:"ownerPublicKey,omitempty"` // PEM; may delete and issue tokens
+	RequireToken   bool              `json:"requireToken"`             // Reads need a token signed by the owner
+	CreatedAt      int64             `json:"createdAt"`
+	Chunk          *DistributedChunk `json:"chunk"` // Where the shards live
+}
+
+// PublicContentAddr returns the storage address of a public object
+func PublicContentAddr(hash string) string {
+	return PublicContentPrefix + hash
+}
+
+// publicTokenMessage returns the bytes an owner signs to grant read access until expiresAt
+func publicTokenMessage(hash string, expiresAt int64) []byte {
+	return []byte(fmt.Sprintf("zentalk-public|%s|%d", hash, expiresAt))
+}
+
+// IssuePublicAccessToken creates a token granting read access to a public object until expiresAt
+// Token format: <expiresAt unix>.<base64url signature>
+func IssuePublicAccessToken(hash string, expiresAt time.Time, ownerKey *rsa.PrivateKey) (string, error) {
+	exp := expiresAt.Unix()
+	signature, err := crypto.SignData(publicTokenMessage(hash, exp), ownerKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign access token: %w", err)
+	}
+	return strconv.FormatInt(exp, 10) + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// VerifyPublicAccessToken checks a token against the object's owner key and expiry
+func VerifyPublicAccessToken(obj *PublicObject, token string) error {
+	if obj.OwnerPublicKey == "" {
+		return fmt.Errorf("object has no owner key")
+	}
+
+	expStr, sigStr, ok := strings.Cut(token, ".")
+	if !ok {
+		return fmt.Errorf("malformed access token")
+	}
+	exp, err := strconv.ParseInt(expStr, 10, 64)
+	if err != nil {
+		return fmt.Errorf("malformed access token expiry: %w", err)
+	}
+	if time.Now().Unix() >= exp {
+		return fmt.Errorf("access token expired at %s", time.Unix(exp, 0).Format(time.RFC3339))
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(sigStr)
+	if err != nil {
+		return fmt.Errorf("malformed access token signature: %w", err)
+	}
+
+	publicKey, err := crypto.ImportPublicKeyPEM([]byte(obj.OwnerPublicKey))
+	if err != nil {
+		return fmt.Errorf("invalid owner public key: %w", err)
+	}
+	if err := crypto.VerifySignature(publicTokenMessage(obj.Hash, exp), signature, publicKey); err != nil {
+		return fmt.Errorf("invalid access token: %w", err)
+	}
+	return nil
+}
+
+// StorePublicObject records a public object
+// An object already recorded under the hash is kept, and ErrPublicObjectExists returned.
+func (s *LocalStorage) StorePublicObject(obj *PublicObject) error {
+	chunkData, err := json.Marshal(obj.Chunk)
+	if err != nil {
+		return fmt.Errorf("failed to marshal chunk metadata: %w", err)
+	}
+
+	query := `INSERT INTO public_content (hash, content_type, size, owner_public_key, require_token, chunk_meta, created_at)
+	          VALUES (?, ?, ?, ?, ?, ?, ?)
+	          ON CONFLICT (hash) DO NOTHING`
+
+	requireToken := 0
+	if obj.RequireToken {
+		requireToken = 1
+	}
+
+	result, err := s.exec(query, obj.Hash, obj.ContentType, obj.Size, obj.OwnerPublicKey, requireToken, chunkData, obj.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to store public object: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrPublicObjectExists
+	}
+	return nil
+}
+
+// GetPublicObject returns a public object by hash
+func (s *LocalStorage) GetPublicObject(hash string) (*PublicObject, error) {
+	query := `SELECT hash, content_type, size, owner_public_key, require_token, chunk_meta, created_at
+	          FROM public_content WHERE hash = ?`
+
+	var obj PublicObject
+	var requireToken int
+	var chunkData []byte
+	err := s.queryRow(query, hash).Scan(&obj.Hash, &obj.ContentType, &obj.Size, &obj.OwnerPublicKey,
+		&requireToken, &chunkData, &obj.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrPublicObjectNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get public object: %w", err)
+	}
+
+	obj.RequireToken = requireToken != 0
+	if err := json.Unmarshal(chunkData, &obj.Chunk); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal chunk metadata: %w", err)
+	}
+	return &obj, nil
+}
+
+// DeletePublicObject removes a public object's record
+func (s *LocalStorage) DeletePublicObject(hash string) error {
+	if _, err := s.exec(`DELETE FROM public_content WHERE hash = ?`, hash); err != nil {
+		return fmt.Errorf("failed to delete public object: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package meshstorage
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestStorePublicObjectOnce tests that concurrent records of the same hash don't overwrite each other
+func TestStorePublicObjectOnce(t *testing.T) {
+	storage, err := NewLocalStorage(t.TempDir())
+	require.NoError(t, err)
+	defer storage.Close()
+
+	hash := HashData([]byte("sticker pack"))
+	const uploads = 8
+
+	var wg sync.WaitGroup
+	errs := make(chan error, uploads)
+	for i := 0; i < uploads; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- storage.StorePublicObject(&PublicObject{
+				Hash:        hash,
+				ContentType: fmt.Sprintf("image/x-%d", i),
+				Size:        12,
+				CreatedAt:   time.Now().Unix(),
+				Chunk:       &DistributedChunk{UserAddr: PublicContentAddr(hash)},
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	stored := 0
+	for err := range errs {
+		if err == nil {
+			stored++
+			continue
+		}
+		assert.True(t, errors.Is(err, ErrPublicObjectExists), err)
+	}
+	assert.Equal(t, 1, stored)
+
+	// The object is the one upload that won, not a mix of several
+	obj, err := storage.GetPublicObject(hash)
+	require.NoError(t, err)
+	assert.Regexp(t, `^image/x-\d$`, obj.ContentType)
+	assert.Error(t, storage.StorePublicObject(&PublicObject{Hash: hash, ContentType: "text/html", Chunk: obj.Chunk}))
+	again, err := storage.GetPublicObject(hash)
+	require.NoError(t, err)
+	assert.Equal(t, obj.ContentType, again.ContentType)
+}
@@ -0,0 +1,123 @@
+package meshstorage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrQuotaExceeded is matched (via errors.Is) by every error refusing a store over quota
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+// QuotaConfig limits what a node stores, in total and for any one user address (0 = unlimited)
+// A user's usage counts the shards of every chunk stored under their address.
+type QuotaConfig struct {
+	MaxBytes      int64 // Chunk data the node holds
+	MaxChunks     int64 // Chunks and shards the node holds
+	UserMaxBytes  int64 // Chunk data the node holds for one user
+	UserMaxChunks int64 // Chunks and shards the node holds for one user
+}
+
+// enabled reports whether any limit is set
+func (q QuotaConfig) enabled() bool {
+	return q.MaxBytes > 0 || q.MaxChunks > 0 || q.UserMaxBytes > 0 || q.UserMaxChunks > 0
+}
+
+// QuotaError is returned when storing a chunk would take the node or a user over a limit
+type QuotaError struct {
+	UserAddr string // Empty when the node-wide limit was hit
+	Resource string // "bytes" or "chunks"
+	Used     int64  // Held before the store
+	Limit    int64
+}
+
+func (e *QuotaError) Error() string {
+	holder := "node"
+	if e.UserAddr != "" {
+		holder = "user " + e.UserAddr
+	}
+	return fmt.Sprintf("%v: %s holds %d of %d %s", ErrQuotaExceeded, holder, e.Used, e.Limit, e.Resource)
+}
+
+// Is makes errors.Is(err, ErrQuotaExceeded) match
+func (e *QuotaError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// SetQuota sets the storage limits StoreChunk enforces
+func (s *LocalStorage) SetQuota(q QuotaConfig) {
+	s.quotaMu.Lock()
+	defer s.quotaMu.Unlock()
+	s.quota = q
+}
+
+// Quota returns the storage limits
+func (s *LocalStorage) Quota() QuotaConfig {
+	s.quotaMu.Lock()
+	defer s.quotaMu.Unlock()
+	return s.quota
+}
+
+// checkQuota returns a QuotaError if storing size bytes under userAddr and chunkID would exceed a limit
+// Rewriting a chunk only counts the difference in size. The caller holds quotaMu.
+func (s *LocalStorage) checkQuota(userAddr string, chunkID int, size int64) error {
+	q := s.quota
+
+	var oldSize, oldChunks int64
+	err := s.queryRow(`SELECT COUNT(*), COALESCE(SUM(size), 0) FROM chunks WHERE user_addr = ? AND chunk_id = ?`,
+		userAddr, chunkID).Scan(&oldChunks, &oldSize)
+	if err != nil {
+		return fmt.Errorf("failed to check quota: %w", err)
+	}
+
+	if q.MaxBytes > 0 || q.MaxChunks > 0 {
+		usedBytes, err := s.GetStorageSize()
+		if err != nil {
+			return err
+		}
+		usedChunks, err := s.GetChunkCount()
+		if err != nil {
+			return err
+		}
+		if err := quotaCheck("", usedBytes, int64(usedChunks), size-oldSize, 1-oldChunks, q.MaxBytes, q.MaxChunks); err != nil {
+			return err
+		}
+	}
+
+	if q.UserMaxBytes > 0 || q.UserMaxChunks > 0 {
+		owner := usageOwner(userAddr)
+		usedBytes, usedChunks, err := s.ownerUsage(owner)
+		if err != nil {
+			return err
+		}
+		if err := quotaCheck(owner, usedBytes, usedChunks, size-oldSize, 1-oldChunks, q.UserMaxBytes, q.UserMaxChunks); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// quotaCheck returns a QuotaError if adding addBytes and addChunks to what is used goes over a limit
+func quotaCheck(userAddr string, usedBytes, usedChunks, addBytes, addChunks, maxBytes, maxChunks int64) error {
+	if maxBytes > 0 && addBytes > 0 && usedBytes+addBytes > maxBytes {
+		return &QuotaError{UserAddr: userAddr, Resource: "bytes", Used: usedBytes, Limit: maxBytes}
+	}
+	if maxChunks > 0 && addChunks > 0 && usedChunks+addChunks > maxChunks {
+		return &QuotaError{UserAddr: userAddr, Resource: "chunks", Used: usedChunks, Limit: maxChunks}
+	}
+	return nil
+}
+
+// ownerUsage returns the bytes and chunks stored for a user, shards included
+func (s *LocalStorage) ownerUsage(owner string) (int64, int64, error) {
+	// Shards are stored under "<user>_<chunk>_shard_<index>". LIKE would treat
+	// the "_" as a wildcard, so the prefix is compared directly.
+	query := `SELECT COALESCE(SUM(size), 0), COUNT(*) FROM chunks
+	          WHERE user_addr = ? OR substr(user_addr, 1, ?) = ?`
+
+	prefix := owner + "_"
+	var bytes, chunks int64
+	if err := s.queryRow(query, owner, len(prefix), prefix).Scan(&bytes, &chunks); err != nil {
+		return 0, 0, fmt.Errorf("failed to get user usage: %w", err)
+	}
+	return bytes, chunks, nil
+}
@@ -0,0 +1,77 @@
+package meshstorage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestStorageQuota tests that stores over a user's or the node's quota are refused
+func TestStorageQuota(t *testing.T) {
+	storage, err := NewLocalStorage(t.TempDir())
+	require.NoError(t, err)
+	defer storage.Close()
+
+	storage.SetQuota(QuotaConfig{UserMaxBytes: 10, MaxChunks: 4})
+	assert.Equal(t, QuotaConfig{UserMaxBytes: 10, MaxChunks: 4}, storage.Quota())
+
+	// Chunks and shards stored under the address count toward its quota
+	require.NoError(t, storage.StoreChunk("0xalice", 1, []byte("1234")))
+	require.NoError(t, storage.StoreChunk("0xalice_2_shard_0", 0, []byte("1234")))
+
+	err = storage.StoreChunk("0xalice_2_shard_1", 1, []byte("1234"))
+	require.True(t, errors.Is(err, ErrQuotaExceeded), "got %v", err)
+	var quotaErr *QuotaError
+	require.True(t, errors.As(err, &quotaErr))
+	assert.Equal(t, QuotaError{UserAddr: "0xalice", Resource: "bytes", Used: 8, Limit: 10}, *quotaErr)
+	_, err = storage.GetChunk("0xalice_2_shard_1", 1)
+	assert.True(t, errors.Is(err, ErrChunkNotFound), "refused shard was stored")
+
+	// Rewriting a chunk only counts the difference
+	require.NoError(t, storage.StoreChunk("0xalice", 1, []byte("123456")))
+	assert.Error(t, storage.StoreChunk("0xalice", 1, []byte("1234567")))
+
+	// Other addresses, even sharing a prefix, have quotas of their own
+	require.NoError(t, storage.StoreChunk("0xalicia", 1, []byte("1234")))
+	require.NoError(t, storage.StoreChunk("0xbob_1_shard_0", 0, []byte("1234")))
+
+	// The node is full at 4 chunks, whoever they belong to
+	err = storage.StoreChunk("0xcarol", 1, []byte("1"))
+	require.True(t, errors.As(err, &quotaErr), "got %v", err)
+	assert.Equal(t, QuotaError{Resource: "chunks", Used: 4, Limit: 4}, *quotaErr)
+
+	// Freeing space makes room again
+	require.NoError(t, storage.DeleteChunk("0xbob_1_shard_0", 0))
+	require.NoError(t, storage.StoreChunk("0xcarol", 1, []byte("1")))
+
+	storage.SetQuota(QuotaConfig{})
+	require.NoError(t, storage.StoreChunk("0xalice_2_shard_1", 1, []byte("1234")))
+}
+
+// TestRPCQuotaExceeded tests that a peer refusing a shard for lack of quota is reported as such
+func TestRPCQuotaExceeded(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	server, client := newIntegrityPair(t)
+	server.Storage().SetQuota(QuotaConfig{UserMaxChunks: 1})
+	NewRPCHandler(server).SetupStreamHandler()
+	rpc := NewRPCClient(client)
+
+	_, err := rpc.StoreShard(ctx, server.ID(), "0xquota_1_shard_0", 0, []byte("shard"), "0xquota", 1)
+	require.NoError(t, err)
+
+	_, err = rpc.StoreShard(ctx, server.ID(), "0xquota_1_shard_1", 1, []byte("shard"), "0xquota", 1)
+	assert.True(t, errors.Is(err, ErrQuotaExceeded), "got %v", err)
+	err = rpc.StoreShardWithLease(ctx, server.ID(), "0xquota_2_shard_0", 0, []byte("shard"), 0, DefaultErasureConfig())
+	assert.True(t, errors.Is(err, ErrQuotaExceeded), "got %v", err)
+
+	// Other failures are not mistaken for quota
+	_, err = rpc.StoreShard(ctx, server.ID(), "0xother_1_shard_0", 0, nil, "0xother", 1)
+	require.Error(t, err)
+	assert.False(t, errors.Is(err, ErrQuotaExceeded))
+}
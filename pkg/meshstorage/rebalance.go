@@ -0,0 +1,309 @@
+package meshstorage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	// DefaultRebalanceInterval is how often monitored chunks are checked for misplaced shards
+	DefaultRebalanceInterval = time.Hour
+
+	// DefaultRebalanceRate is the migration bandwidth, in bytes per second
+	DefaultRebalanceRate = 1 << 20
+)
+
+// errShardChanged is returned when a shard was repaired or moved while it was being migrated
+var errShardChanged = errors.New("shard location changed during migration")
+
+// RebalanceConfig controls how shards are migrated toward the nodes closest to their chunk
+type RebalanceConfig struct {
+	// Interval between rebalance passes over the monitored chunks
+	Interval time.Duration
+
+	// BytesPerSecond caps migration traffic through this node (0 = unthrottled)
+	// Each move counts the shard once per network transfer: fetching it, storing
+	// it and reading it back to verify the copy.
+	BytesPerSecond int64
+}
+
+// DefaultRebalanceConfig returns the rebalancing storage nodes use unless configured otherwise
+func DefaultRebalanceConfig() RebalanceConfig {
+	return RebalanceConfig{
+		Interval:       DefaultRebalanceInterval,
+		BytesPerSecond: DefaultRebalanceRate,
+	}
+}
+
+// RebalanceReport summarizes one rebalance pass
+type RebalanceReport struct {
+	Checked int   `json:"checked"`
+	Moved   int   `json:"moved"`  // Shards migrated to a closer node
+	Failed  int   `json:"failed"` // Moves abandoned; the shard stays where it was
+	Bytes   int64 `json:"bytes"`  // Shard data migrated
+}
+
+// StartRebalancing periodically migrates monitored chunks' shards toward their ideal nodes
+// Shards placed when the mesh was small stay on the nodes that existed then;
+// each pass moves them to the nodes now closest to their chunk in Kademlia
+// distance. StopMonitoring also stops rebalancing. Call it at most once.
+func (ds *DistributedStorage) StartRebalancing(cfg RebalanceConfig) {
+	if cfg.Interval <= 0 {
+		return
+	}
+
+	ds.monitorWg.Add(1)
+	go ds.rebalanceLoop(cfg)
+	fmt.Printf("⚖️  Started shard rebalancing (interval: %v, rate: %d B/s)\n", cfg.Interval, cfg.BytesPerSecond)
+}
+
+// rebalanceLoop runs rebalance passes until monitoring stops
+func (ds *DistributedStorage) rebalanceLoop(cfg RebalanceConfig) {
+	defer ds.monitorWg.Done()
+
+	// Stopping aborts a pass between (not during) moves
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-ds.monitorStop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ds.Rebalance(ctx, ds.ListChunks(""), cfg.BytesPerSecond)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Rebalance migrates the shards of chunks that are not on their ideal nodes, at most bytesPerSecond
+// A node holding more of a chunk's shards than placement would give it now
+// (none, if it is no longer among the closest) hands the surplus to ideal nodes
+// holding too few. Shards on the user's pinned nodes and shards that cannot
+// be read are left alone; restoring those is repair's job.
+func (ds *DistributedStorage) Rebalance(ctx context.Context, chunks []*DistributedChunk, bytesPerSecond int64) *RebalanceReport {
+	report := &RebalanceReport{}
+	throttle := &rebalanceThrottle{rate: bytesPerSecond}
+
+	for _, chunk := range chunks {
+		if ctx.Err() != nil {
+			break
+		}
+		if chunk.leaseLapsed(time.Now()) {
+			continue
+		}
+		report.Checked++
+
+		moves, err := ds.planRebalance(ctx, chunk)
+		if err != nil {
+			fmt.Printf("⚠️  %s:%d: failed to plan rebalance: %v\n", chunk.UserAddr, chunk.ChunkID, err)
+			continue
+		}
+
+		moved := 0
+		for idx, target := range moves {
+			n, err := ds.migrateShard(ctx, chunk, idx, target, throttle)
+			if err != nil {
+				fmt.Printf("⚠️  %s:%d: failed to move shard %d to %s: %v\n", chunk.UserAddr, chunk.ChunkID, idx, target, err)
+				report.Failed++
+				continue
+			}
+			moved++
+			report.Bytes += int64(n)
+		}
+
+		if moved > 0 {
+			report.Moved += moved
+			ds.invalidateShards(chunk.UserAddr, chunk.ChunkID)
+			ds.updateManifest(chunk)
+			ds.publishShardLocations(ctx, chunk.UserAddr, chunk.ChunkID, chunk)
+			fmt.Printf("⚖️  %s:%d: moved %d shards closer to the chunk\n", chunk.UserAddr, chunk.ChunkID, moved)
+		}
+	}
+
+	if report.Moved > 0 || report.Failed > 0 {
+		fmt.Printf("⚖️  Rebalance completed: %d chunks checked, %d shards moved (%d bytes), %d failed\n",
+			report.Checked, report.Moved, report.Bytes, report.Failed)
+	}
+	return report
+}
+
+// planRebalance returns the shards of a chunk to move, by shard index, and where to
+func (ds *DistributedStorage) planRebalance(ctx context.Context, chunk *DistributedChunk) (map[int]peer.ID, error) {
+	totalShards := chunk.Coding().TotalShards()
+
+	// Where placement would put the shards now, padded with ourselves as storeDistributed does
+	key := generateStorageKey(chunk.UserAddr, chunk.ChunkID)
+	ideal, err := ds.findPlacementNodes(ctx, key, totalShards)
+	if err != nil {
+		return nil, err
+	}
+	want := make(map[peer.ID]int)
+	for i := 0; i < totalShards; i++ {
+		if i < len(ideal) {
+			want[ideal[i]]++
+		} else {
+			want[ds.node.ID()]++
+		}
+	}
+
+	ds.moveMu.Lock()
+	locations := append([]ShardLocation(nil), chunk.ShardLocations...)
+	ds.moveMu.Unlock()
+
+	// Shards stay on a node until it holds as many as placement gives it
+	held := make(map[peer.ID]int)
+	var surplus []int
+	for _, loc := range locations {
+		if loc.PeerID == "" {
+			continue // Never stored
+		}
+		if held[loc.PeerID] < want[loc.PeerID] || ds.IsPinned(chunk.UserAddr, loc.PeerID) {
+			held[loc.PeerID]++
+			continue
+		}
+		surplus = append(surplus, loc.ShardIndex)
+	}
+
+	moves := make(map[int]peer.ID)
+	for _, id := range ideal {
+		for held[id] < want[id] && len(surplus) > 0 {
+			moves[surplus[0]] = id
+			surplus = surplus[1:]
+			held[id]++
+		}
+	}
+	return moves, nil
+}
+
+// migrateShard copies one shard to target, verifies the copy and deletes the original
+// Returns the shard's size.
+func (ds *DistributedStorage) migrateShard(ctx context.Context, chunk *DistributedChunk, idx int, target peer.ID, throttle *rebalanceThrottle) (int, error) {
+	if idx < 0 || idx >= len(chunk.ShardLocations) {
+		return 0, fmt.Errorf("shard %d not in manifest", idx)
+	}
+
+	// Transfers through this node: fetching the shard, then storing and reading it back
+	source := chunk.ShardLocations[idx].PeerID
+	transfers := 0
+	if source != ds.node.ID() {
+		transfers++
+	}
+	if target != ds.node.ID() {
+		transfers += 2
+	}
+	if err := throttle.wait(ctx, transfers*chunk.ShardSize); err != nil {
+		return 0, err
+	}
+
+	// Repair must not rewrite the shard between the copy and the manifest update
+	ds.moveMu.Lock()
+	defer ds.moveMu.Unlock()
+
+	if chunk.ShardLocations[idx].PeerID != source {
+		return 0, errShardChanged
+	}
+
+	shard, err := ds.readShard(ctx, chunk, idx, source)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read shard: %w", err)
+	}
+	if chunk.ShardSize > 0 && len(shard) != chunk.ShardSize {
+		return 0, fmt.Errorf("shard is %d bytes, manifest says %d", len(shard), chunk.ShardSize)
+	}
+
+	if err := ds.writeShard(ctx, chunk, idx, target, shard); err != nil {
+		return 0, fmt.Errorf("failed to store shard: %w", err)
+	}
+	copied, err := ds.readShard(ctx, chunk, idx, target)
+	if err == nil && !bytes.Equal(copied, shard) {
+		err = errors.New("copy differs from original")
+	}
+	if err != nil {
+		// Don't leave an unreferenced copy behind
+		if err := ds.deleteShard(ctx, chunk.UserAddr, chunk.ChunkID, idx, target); err != nil {
+			fmt.Printf("⚠️  Failed to delete unverified copy of shard %d from %s: %v\n", idx, target, err)
+		}
+		return 0, fmt.Errorf("failed to verify shard: %w", err)
+	}
+
+	peerAddrs := ds.node.Host().Peerstore().Addrs(target)
+	addrs := make([]string, len(peerAddrs))
+	for j, addr := range peerAddrs {
+		addrs[j] = addr.String()
+	}
+	chunk.ShardLocations[idx] = ShardLocation{
+		ShardIndex: idx,
+		PeerID:     target,
+		PeerAddrs:  addrs,
+	}
+	chunk.UpdatedAt = time.Now()
+
+	// The manifest no longer references the original, so losing it is harmless
+	if err := ds.deleteShard(ctx, chunk.UserAddr, chunk.ChunkID, idx, source); err != nil {
+		fmt.Printf("⚠️  Failed to delete moved shard %d from %s: %v\n", idx, source, err)
+	}
+	return len(shard), nil
+}
+
+// readShard reads one shard of a chunk from a node, bypassing the shard cache
+func (ds *DistributedStorage) readShard(ctx context.Context, chunk *DistributedChunk, idx int, from peer.ID) ([]byte, error) {
+	shardKey := fmt.Sprintf("%s_%d_shard_%d", chunk.UserAddr, chunk.ChunkID, idx)
+	if from == ds.node.ID() {
+		return ds.node.Storage().GetChunk(shardKey, idx)
+	}
+	return ds.client.GetChunk(ctx, from, shardKey, idx)
+}
+
+// writeShard stores one shard of a chunk on a node for what is left of the chunk's lease
+func (ds *DistributedStorage) writeShard(ctx context.Context, chunk *DistributedChunk, idx int, to peer.ID, shard []byte) error {
+	shardKey := fmt.Sprintf("%s_%d_shard_%d", chunk.UserAddr, chunk.ChunkID, idx)
+	if to == ds.node.ID() {
+		return ds.node.Storage().StoreChunkWithLease(shardKey, idx, shard, chunk.LeaseExpires)
+	}
+	return ds.client.StoreShardWithLease(ctx, to, shardKey, idx, shard, remainingLease(chunk.LeaseExpires), chunk.Coding())
+}
+
+// rebalanceThrottle spaces out migrations so they average at most rate bytes per second
+type rebalanceThrottle struct {
+	rate int64     // 0 = unthrottled
+	next time.Time // When the next transfer may start
+}
+
+// wait blocks until n more bytes may be transferred, then books them
+func (t *rebalanceThrottle) wait(ctx context.Context, n int) error {
+	if t.rate <= 0 {
+		return ctx.Err()
+	}
+
+	now := time.Now()
+	if t.next.Before(now) {
+		t.next = now
+	}
+	if delay := t.next.Sub(now); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	t.next = t.next.Add(time.Duration(int64(n) * int64(time.Second) / t.rate))
+	return nil
+}
@@ -0,0 +1,107 @@
+package meshstorage
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRebalanceMovesShardsToNewNodes tests that shards placed on a lone node spread out once peers join
+func TestRebalanceMovesShardsToNewNodes(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	owner, err := NewDHTNode(ctx, &NodeConfig{Port: 0, DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer owner.Close()
+
+	ds, err := NewDistributedStorageWithErasure(owner, ErasureConfig{DataShards: 2, ParityShards: 1})
+	require.NoError(t, err)
+	defer ds.StopMonitoring()
+
+	// Alone in the mesh, the owner keeps every shard
+	data := []byte("rebalanced chat history")
+	chunk, err := ds.StoreDistributed(ctx, "0xrebalance", 1, data)
+	require.NoError(t, err)
+	for _, loc := range chunk.ShardLocations {
+		require.Equal(t, owner.ID(), loc.PeerID)
+	}
+
+	peerAddr := owner.Addresses()[0].String() + "/p2p/" + owner.ID().String()
+	joined := make(map[peer.ID]*DHTNode)
+	for i := 0; i < 2; i++ {
+		node, err := NewDHTNode(ctx, &NodeConfig{Port: 0, DataDir: t.TempDir()})
+		require.NoError(t, err)
+		defer node.Close()
+		NewRPCHandler(node).SetupStreamHandler()
+		require.NoError(t, node.Connect(ctx, peerAddr))
+		joined[node.ID()] = node
+	}
+
+	// Inbound peers count for placement once identify has learned their addresses
+	require.Eventually(t, func() bool {
+		closest, err := owner.FindClosestNodes(ctx, "any", 3)
+		return err == nil && len(closest) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	report := ds.Rebalance(ctx, ds.ListChunks(""), 0)
+	assert.Equal(t, 1, report.Checked)
+	assert.Equal(t, 2, report.Moved)
+	assert.Zero(t, report.Failed)
+
+	// One shard each: the moved ones live on the new nodes, and only there
+	holders := make(map[peer.ID]int)
+	for _, loc := range chunk.ShardLocations {
+		holders[loc.PeerID]++
+		shardKey := fmt.Sprintf("%s_%d_shard_%d", chunk.UserAddr, chunk.ChunkID, loc.ShardIndex)
+		_, err := owner.Storage().GetChunk(shardKey, loc.ShardIndex)
+		if node, ok := joined[loc.PeerID]; ok {
+			assert.Error(t, err, "moved shard %d still on the owner", loc.ShardIndex)
+			_, err = node.Storage().GetChunk(shardKey, loc.ShardIndex)
+		}
+		assert.NoError(t, err, "shard %d missing from its node", loc.ShardIndex)
+	}
+	assert.Equal(t, 1, holders[owner.ID()])
+	assert.Len(t, holders, 3)
+
+	retrieved, err := ds.RetrieveDistributed(ctx, chunk)
+	require.NoError(t, err)
+	assert.Equal(t, data, retrieved)
+
+	saved, err := owner.Storage().GetChunkManifest(chunk.UserAddr, chunk.ChunkID)
+	require.NoError(t, err)
+	for i, loc := range chunk.ShardLocations {
+		assert.Equal(t, loc.PeerID, saved.ShardLocations[i].PeerID, "saved manifest has shard %d on the old node", i)
+	}
+
+	// Balanced chunks are left alone
+	report = ds.Rebalance(ctx, ds.ListChunks(""), 0)
+	assert.Zero(t, report.Moved)
+}
+
+// TestRebalanceThrottle tests that migration is spaced out to the configured rate
+func TestRebalanceThrottle(t *testing.T) {
+	ctx := context.Background()
+	throttle := &rebalanceThrottle{rate: 1000}
+
+	start := time.Now()
+	require.NoError(t, throttle.wait(ctx, 100)) // First transfer starts at once
+	require.NoError(t, throttle.wait(ctx, 100)) // Waits for the first 100 bytes at 1000 B/s
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.ErrorIs(t, throttle.wait(cancelled, 100), context.Canceled)
+
+	unthrottled := &rebalanceThrottle{}
+	start = time.Now()
+	for i := 0; i < 10; i++ {
+		require.NoError(t, unthrottled.wait(ctx, 1<<20))
+	}
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+}
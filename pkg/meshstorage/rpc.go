@@ -5,13 +5,16 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"sync"
 	"time"
 
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/ZentaChain/zentalk-node/pkg/chaos"
 	"github.com/ZentaChain/zentalk-node/pkg/crypto"
 )
 
@@ -28,6 +31,9 @@ const (
 	MsgTypeGetShard    = "get_shard"    // Retrieve a single shard
 	MsgTypeShardStatus = "shard_status" // Get status of stored shards
 	MsgTypeDeleteShard = "delete_shard" // Delete a shard
+	MsgTypeInventory   = "shard_inventory" // List stored shard keys (incremental)
+	MsgTypeMaintenance = "maintenance"     // Planned downtime announcement
+	MsgTypeRenewLease  = "renew_lease"     // Extend a stored chunk's lease
 	MsgTypePing        = "ping"
 	MsgTypeResponse    = "response"
 	MsgTypeError       = "error"
@@ -42,10 +48,17 @@ type RPCMessage struct {
 }
 
 // StoreChunkRequest represents a request to store a chunk
+// LeaseSeconds lets the node collect the chunk that long after storing it
+// (0 = no lease); nodes cap it at MaxLeaseDuration. Shards of a distributed
+// chunk carry the chunk's erasure coding, and ChunkID is then the shard index.
 type StoreChunkRequest struct {
-	UserAddr string `json:"user_addr"`
-	ChunkID  int    `json:"chunk_id"`
-	Data     []byte `json:"data"`
+	UserAddr     string `json:"user_addr"`
+	ChunkID      int    `json:"chunk_id"`
+	Data         []byte `json:"data"`
+	LeaseSeconds int64  `json:"lease_seconds,omitempty"`
+	DataShards   int    `json:"data_shards,omitempty"`   // Erasure coding of the shard's chunk (0 = not a shard)
+	ParityShards int    `json:"parity_shards,omitempty"`
+	Checksum     string `json:"checksum,omitempty"` // Of Data, see shardChecksum (empty = unchecked)
 }
 
 // GetChunkRequest represents a request to retrieve a chunk
@@ -61,6 +74,7 @@ type StoreShardRequest struct {
 	Data       []byte `json:"data"`        // Shard data
 	UserAddr   string `json:"user_addr"`   // User's address (for organization)
 	ChunkID    int    `json:"chunk_id"`    // Chunk ID (for organization)
+	Checksum   string `json:"checksum,omitempty"` // Of Data, see shardChecksum (empty = unchecked)
 }
 
 // GetShardRequest represents a request to retrieve a single shard
@@ -74,6 +88,12 @@ type ShardStatusRequest struct {
 	ChunkID  int    `json:"chunk_id,omitempty"`  // Optional: filter by chunk
 }
 
+// InventoryRequest asks a node which shards it holds
+// Since limits the answer to shards stored at or after that Unix time (0 = all)
+type InventoryRequest struct {
+	Since int64 `json:"since,omitempty"`
+}
+
 // DeleteShardRequest represents a request to delete a shard
 // Requires cryptographic signature to prevent unauthorized deletion
 type DeleteShardRequest struct {
@@ -103,17 +123,33 @@ type RPCResponse struct {
 	// Extended fields for shard operations
 	ShardInfo  *ShardInfo   `json:"shard_info,omitempty"`  // Info about a single shard
 	ShardInfos []ShardInfo  `json:"shard_infos,omitempty"` // Info about multiple shards
+	// Inventory fields: total shards held and the node's clock when the inventory was taken
+	InventoryTotal int   `json:"inventory_total,omitempty"`
+	InventoryAsOf  int64 `json:"inventory_as_of,omitempty"`
+	// Lease the node granted or renewed (Unix seconds, 0 = no lease)
+	LeaseExpires int64 `json:"lease_expires,omitempty"`
+	// Shard transfers: checksum of the shard served or stored, and whether a stored one failed it
+	Checksum         string `json:"checksum,omitempty"`
+	ChecksumMismatch bool   `json:"checksum_mismatch,omitempty"`
+	// Stores refused because the node or the user is over quota
+	QuotaExceeded bool `json:"quota_exceeded,omitempty"`
 }
 
 // RPCHandler handles incoming RPC requests
 type RPCHandler struct {
 	node *DHTNode
+
+	mu      sync.Mutex
+	limits  RPCLimits
+	streams map[peer.ID]int // Requests in progress per peer
 }
 
-// NewRPCHandler creates a new RPC handler
+// NewRPCHandler creates a new RPC handler with DefaultRPCLimits
 func NewRPCHandler(node *DHTNode) *RPCHandler {
 	return &RPCHandler{
-		node: node,
+		node:    node,
+		limits:  DefaultRPCLimits(),
+		streams: make(map[peer.ID]int),
 	}
 }
 
@@ -126,14 +162,40 @@ func (h *RPCHandler) SetupStreamHandler() {
 func (h *RPCHandler) handleStream(stream network.Stream) {
 	defer stream.Close()
 
+	// One peer cannot tie up the node with streams it never finishes
+	limits := h.Limits()
+	from := stream.Conn().RemotePeer()
+	if !h.acquireStream(from, limits.MaxStreamsPerPeer) {
+		fmt.Printf("⚠️  Refused RPC stream from %s: %d requests already in progress\n", from, limits.MaxStreamsPerPeer)
+		stream.Reset()
+		return
+	}
+	defer h.releaseStream(from)
+
 	// Read the request
-	decoder := json.NewDecoder(stream)
+	decoder := json.NewDecoder(limits.requestReader(stream))
 	var msg RPCMessage
 	if err := decoder.Decode(&msg); err != nil {
+		// The peer may still be sending, so there is no point answering
+		if errors.Is(err, ErrRPCMessageTooLarge) || isStreamTimeout(err) {
+			fmt.Printf("⚠️  Dropped RPC request from %s: %v\n", from, err)
+			stream.Reset()
+			return
+		}
 		h.sendError(stream, "", fmt.Sprintf("failed to decode message: %v", err))
 		return
 	}
 
+	// Fault injection (chaos builds only): callers see resets, timeouts and missing replies
+	if chaos.Disconnect("storage.rpc") {
+		stream.Reset()
+		return
+	}
+	chaos.Delay("storage.rpc")
+	if chaos.Drop("storage.rpc") {
+		return
+	}
+
 	// Check protocol version
 	requestVersion := msg.Version
 	if requestVersion == "" {
@@ -168,6 +230,12 @@ func (h *RPCHandler) handleStream(stream network.Stream) {
 		response = h.handleShardStatus(msg.Payload)
 	case MsgTypeDeleteShard:
 		response = h.handleDeleteShard(msg.Payload)
+	case MsgTypeInventory:
+		response = h.handleInventory(msg.Payload)
+	case MsgTypeMaintenance:
+		response = h.handleMaintenance(from, msg.Payload)
+	case MsgTypeRenewLease:
+		response = h.handleRenewLease(msg.Payload)
 	case MsgTypePing:
 		response = RPCResponse{Success: true}
 	default:
@@ -194,15 +262,57 @@ func (h *RPCHandler) handleStoreChunk(payload []byte) RPCResponse {
 		}
 	}
 
-	// Store the chunk in local storage
-	if err := h.node.storage.StoreChunk(req.UserAddr, req.ChunkID, req.Data); err != nil {
+	if err := checkShardIndex(req.ChunkID, req.DataShards, req.ParityShards); err != nil {
 		return RPCResponse{
 			Success: false,
-			Error:   fmt.Sprintf("failed to store chunk: %v", err),
+			Error:   err.Error(),
 		}
 	}
+	if response, ok := checkTransfer(req.Data, req.Checksum); !ok {
+		return response
+	}
 
-	return RPCResponse{Success: true}
+	// Store the chunk in local storage
+	expires := leaseExpiry(time.Duration(req.LeaseSeconds) * time.Second)
+	if err := h.node.storage.StoreChunkWithLease(req.UserAddr, req.ChunkID, req.Data, expires); err != nil {
+		return RPCResponse{
+			Success:       false,
+			Error:         fmt.Sprintf("failed to store chunk: %v", err),
+			QuotaExceeded: errors.Is(err, ErrQuotaExceeded),
+		}
+	}
+
+	return RPCResponse{Success: true, LeaseExpires: leaseUnix(expires), Checksum: req.Checksum}
+}
+
+// checkTransfer rejects shard data that does not match the checksum it was sent with
+// Requests without a checksum predate them and are not checked.
+func checkTransfer(data []byte, checksum string) (RPCResponse, bool) {
+	if checksum == "" || shardChecksum(data) == checksum {
+		return RPCResponse{}, true
+	}
+	return RPCResponse{
+		Success:          false,
+		Error:            ErrChecksumMismatch.Error(),
+		ChecksumMismatch: true,
+	}, false
+}
+
+// checkShardIndex rejects a shard index outside the erasure coding it was sent with
+// Requests without a coding (dataShards = 0) predate it and are not checked.
+func checkShardIndex(shardIndex, dataShards, parityShards int) error {
+	if dataShards == 0 {
+		return nil
+	}
+
+	erasure := ErasureConfig{DataShards: dataShards, ParityShards: parityShards}
+	if err := erasure.Validate(); err != nil {
+		return fmt.Errorf("invalid erasure coding: %v", err)
+	}
+	if shardIndex < 0 || shardIndex >= erasure.TotalShards() {
+		return fmt.Errorf("shard index %d out of range for %s coding", shardIndex, erasure)
+	}
+	return nil
 }
 
 // handleGetChunk processes a get chunk request
@@ -223,10 +333,12 @@ func (h *RPCHandler) handleGetChunk(payload []byte) RPCResponse {
 			Error:   fmt.Sprintf("failed to get chunk: %v", err),
 		}
 	}
+	h.node.meter.RecordServed(req.UserAddr, len(data))
 
 	return RPCResponse{
-		Success: true,
-		Data:    data,
+		Success:  true,
+		Data:     chaos.Corrupt("storage.shard", data),
+		Checksum: shardChecksum(data),
 	}
 }
 
@@ -240,11 +352,16 @@ func (h *RPCHandler) handleStoreShard(payload []byte) RPCResponse {
 		}
 	}
 
+	if response, ok := checkTransfer(req.Data, req.Checksum); !ok {
+		return response
+	}
+
 	// Store the shard using the shard key
 	if err := h.node.storage.StoreChunk(req.ShardKey, req.ShardIndex, req.Data); err != nil {
 		return RPCResponse{
-			Success: false,
-			Error:   fmt.Sprintf("failed to store shard: %v", err),
+			Success:       false,
+			Error:         fmt.Sprintf("failed to store shard: %v", err),
+			QuotaExceeded: errors.Is(err, ErrQuotaExceeded),
 		}
 	}
 
@@ -260,6 +377,7 @@ func (h *RPCHandler) handleStoreShard(payload []byte) RPCResponse {
 	return RPCResponse{
 		Success:   true,
 		ShardInfo: shardInfo,
+		Checksum:  req.Checksum,
 	}
 }
 
@@ -282,10 +400,12 @@ func (h *RPCHandler) handleGetShard(payload []byte) RPCResponse {
 			Error:   fmt.Sprintf("failed to get shard: %v", err),
 		}
 	}
+	h.node.meter.RecordServed(req.ShardKey, len(data))
 
 	return RPCResponse{
-		Success: true,
-		Data:    data,
+		Success:  true,
+		Data:     chaos.Corrupt("storage.shard", data),
+		Checksum: shardChecksum(data),
 	}
 }
 
@@ -336,6 +456,51 @@ func (h *RPCHandler) handleShardStatus(payload []byte) RPCResponse {
 	}
 }
 
+// handleInventory lists the shard keys held by this node
+// Only keys are returned, so a peer can verify shard presence for many chunks in one round trip
+func (h *RPCHandler) handleInventory(payload []byte) RPCResponse {
+	var req InventoryRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return RPCResponse{
+			Success: false,
+			Error:   fmt.Sprintf("failed to unmarshal request: %v", err),
+		}
+	}
+
+	asOf := time.Now().Unix()
+
+	keys, err := h.node.storage.ListChunkKeysSince(req.Since)
+	if err != nil {
+		return RPCResponse{
+			Success: false,
+			Error:   fmt.Sprintf("failed to list shards: %v", err),
+		}
+	}
+
+	total, err := h.node.storage.GetChunkCount()
+	if err != nil {
+		return RPCResponse{
+			Success: false,
+			Error:   fmt.Sprintf("failed to count shards: %v", err),
+		}
+	}
+
+	shardInfos := make([]ShardInfo, len(keys))
+	for i, key := range keys {
+		shardInfos[i] = ShardInfo{
+			ShardKey:   key.UserAddr,
+			ShardIndex: key.ChunkID,
+		}
+	}
+
+	return RPCResponse{
+		Success:        true,
+		ShardInfos:     shardInfos,
+		InventoryTotal: total,
+		InventoryAsOf:  asOf,
+	}
+}
+
 // handleDeleteShard processes a delete shard request
 // Verifies cryptographic signature to prevent unauthorized deletion
 func (h *RPCHandler) handleDeleteShard(payload []byte) RPCResponse {
@@ -438,6 +603,7 @@ func (h *RPCHandler) sendResponse(stream network.Stream, requestID string, respo
 		Payload: responseData,
 	}
 
+	stream.SetWriteDeadline(rpcDeadline(h.Limits().WriteTimeout))
 	encoder := json.NewEncoder(stream)
 	if err := encoder.Encode(msg); err != nil {
 		fmt.Printf("Failed to send response: %v\n", err)
@@ -458,6 +624,7 @@ func (h *RPCHandler) sendError(stream network.Stream, requestID string, errMsg s
 		Payload: responseData,
 	}
 
+	stream.SetWriteDeadline(rpcDeadline(h.Limits().WriteTimeout))
 	encoder := json.NewEncoder(stream)
 	encoder.Encode(msg)
 }
@@ -476,13 +643,37 @@ func NewRPCClient(node *DHTNode) *RPCClient {
 
 // StoreChunk sends a store chunk request to a remote node
 func (c *RPCClient) StoreChunk(ctx context.Context, peerID peer.ID, userAddr string, chunkID int, data []byte) error {
-	// Create the request
-	req := StoreChunkRequest{
-		UserAddr: userAddr,
-		ChunkID:  chunkID,
-		Data:     data,
-	}
-
+	return c.StoreChunkWithLease(ctx, peerID, userAddr, chunkID, data, 0)
+}
+
+// StoreChunkWithLease stores a chunk on a remote node for lease (0 = no lease)
+// Nodes that predate leases ignore it and keep the chunk until it is deleted.
+func (c *RPCClient) StoreChunkWithLease(ctx context.Context, peerID peer.ID, userAddr string, chunkID int, data []byte, lease time.Duration) error {
+	return c.storeChunk(ctx, peerID, StoreChunkRequest{
+		UserAddr:     userAddr,
+		ChunkID:      chunkID,
+		Data:         data,
+		LeaseSeconds: int64(lease / time.Second),
+	})
+}
+
+// StoreShardWithLease stores one shard of a distributed chunk on a remote node for lease (0 = no lease)
+// The chunk's erasure coding goes with it so the node can check the shard index.
+func (c *RPCClient) StoreShardWithLease(ctx context.Context, peerID peer.ID, shardKey string, shardIndex int, data []byte, lease time.Duration, erasure ErasureConfig) error {
+	erasure = erasure.withDefaults()
+	return c.storeChunk(ctx, peerID, StoreChunkRequest{
+		UserAddr:     shardKey,
+		ChunkID:      shardIndex,
+		Data:         data,
+		LeaseSeconds: int64(lease / time.Second),
+		DataShards:   erasure.DataShards,
+		ParityShards: erasure.ParityShards,
+	})
+}
+
+// storeChunk sends a store chunk request to a remote node
+func (c *RPCClient) storeChunk(ctx context.Context, peerID peer.ID, req StoreChunkRequest) error {
+	req.Checksum = shardChecksum(req.Data)
 	reqData, err := json.Marshal(req)
 	if err != nil {
 		return fmt.Errorf("failed to marshal request: %w", err)
@@ -490,18 +681,18 @@ func (c *RPCClient) StoreChunk(ctx context.Context, peerID peer.ID, userAddr str
 
 	msg := RPCMessage{
 		Type:    MsgTypeStoreChunk,
-		ID:      fmt.Sprintf("%s-%d", userAddr, chunkID),
+		ID:      fmt.Sprintf("%s-%d", req.UserAddr, req.ChunkID),
 		Payload: reqData,
 	}
 
 	// Send the request and get response
-	response, err := c.sendRequest(ctx, peerID, msg)
+	response, err := c.sendVerified(ctx, peerID, msg, req.Checksum)
 	if err != nil {
 		return err
 	}
 
 	if !response.Success {
-		return fmt.Errorf("remote node error: %s", response.Error)
+		return &RemoteError{Message: response.Error, QuotaExceeded: response.QuotaExceeded}
 	}
 
 	return nil
@@ -527,7 +718,7 @@ func (c *RPCClient) GetChunk(ctx context.Context, peerID peer.ID, userAddr strin
 	}
 
 	// Send the request and get response
-	response, err := c.sendRequest(ctx, peerID, msg)
+	response, err := c.sendVerified(ctx, peerID, msg, "")
 	if err != nil {
 		return nil, err
 	}
@@ -567,6 +758,7 @@ func (c *RPCClient) StoreShard(ctx context.Context, peerID peer.ID, shardKey str
 		Data:       data,
 		UserAddr:   userAddr,
 		ChunkID:    chunkID,
+		Checksum:   shardChecksum(data),
 	}
 
 	reqData, err := json.Marshal(req)
@@ -581,13 +773,13 @@ func (c *RPCClient) StoreShard(ctx context.Context, peerID peer.ID, shardKey str
 	}
 
 	// Send the request and get response
-	response, err := c.sendRequest(ctx, peerID, msg)
+	response, err := c.sendVerified(ctx, peerID, msg, req.Checksum)
 	if err != nil {
 		return nil, err
 	}
 
 	if !response.Success {
-		return nil, fmt.Errorf("remote node error: %s", response.Error)
+		return nil, &RemoteError{Message: response.Error, QuotaExceeded: response.QuotaExceeded}
 	}
 
 	return response.ShardInfo, nil
@@ -612,7 +804,7 @@ func (c *RPCClient) GetShard(ctx context.Context, peerID peer.ID, shardKey strin
 	}
 
 	// Send the request and get response
-	response, err := c.sendRequest(ctx, peerID, msg)
+	response, err := c.sendVerified(ctx, peerID, msg, "")
 	if err != nil {
 		return nil, err
 	}
@@ -656,6 +848,47 @@ func (c *RPCClient) GetShardStatus(ctx context.Context, peerID peer.ID, userAddr
 	return response.ShardInfos, nil
 }
 
+// GetInventory asks a remote node for the shards it stored at or after since
+// It returns the shards, the node's total shard count and the node's clock at query time
+func (c *RPCClient) GetInventory(ctx context.Context, peerID peer.ID, since int64) ([]ShardInfo, int, int64, error) {
+	reqData, err := json.Marshal(InventoryRequest{Since: since})
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	msg := RPCMessage{
+		Type:    MsgTypeInventory,
+		ID:      fmt.Sprintf("inventory-%d", since),
+		Payload: reqData,
+	}
+
+	response, err := c.sendRequest(ctx, peerID, msg)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	if !response.Success {
+		return nil, 0, 0, &RemoteError{Message: response.Error}
+	}
+
+	return response.ShardInfos, response.InventoryTotal, response.InventoryAsOf, nil
+}
+
+// RemoteError is an error reported by a reachable peer (as opposed to a transport failure)
+type RemoteError struct {
+	Message       string
+	QuotaExceeded bool // The peer refused a store for lack of quota
+}
+
+func (e *RemoteError) Error() string {
+	return "remote node error: " + e.Message
+}
+
+// Is makes errors.Is(err, ErrQuotaExceeded) match stores the peer refused for lack of quota
+func (e *RemoteError) Is(target error) bool {
+	return e.QuotaExceeded && target == ErrQuotaExceeded
+}
+
 // DeleteShard deletes a shard from a remote node
 func (c *RPCClient) DeleteShard(ctx context.Context, peerID peer.ID, userAddr string, chunkID int, shardIndex int) error {
 	req := DeleteShardRequest{
@@ -696,6 +929,17 @@ func (c *RPCClient) sendRequest(ctx context.Context, peerID peer.ID, msg RPCMess
 	}
 	defer stream.Close()
 
+	// Abort the exchange if the caller gives up (e.g. a retrieval already has enough shards)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			stream.Reset()
+		case <-done:
+		}
+	}()
+
 	// Always include our protocol version in requests
 	msg.Version = CurrentVersion
 
@@ -0,0 +1,135 @@
+package meshstorage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// ErrRPCMessageTooLarge is returned when a peer sends a request over RPCLimits.MaxMessageSize
+var ErrRPCMessageTooLarge = errors.New("rpc message exceeds size limit")
+
+// RPCLimits bounds what one peer can make a storage node hold for it
+// Without them a peer can send an endless JSON value, open streams and never
+// write to them, or open thousands of streams at once until the node runs out
+// of memory or goroutines.
+type RPCLimits struct {
+	// MaxMessageSize is the largest request accepted, in bytes of JSON. Shard
+	// data is base64 encoded twice on the wire (~1.8x). 0 disables.
+	MaxMessageSize int64
+
+	// ReadTimeout bounds receiving a request from the moment its stream opens. 0 disables.
+	ReadTimeout time.Duration
+
+	// WriteTimeout bounds sending the response once the request is handled. 0 disables.
+	WriteTimeout time.Duration
+
+	// MaxStreamsPerPeer caps requests a single peer can have in progress
+	// Uploads send all of a chunk's shards at once, so in small meshes one
+	// peer may legitimately hold a stream per shard. 0 disables.
+	MaxStreamsPerPeer int
+}
+
+// DefaultRPCLimits returns the limits RPC handlers use unless configured otherwise
+// Sized for the API's default 100 MB upload limit stored as a single chunk.
+func DefaultRPCLimits() RPCLimits {
+	return RPCLimits{
+		MaxMessageSize:    256 << 20,
+		ReadTimeout:       2 * time.Minute,
+		WriteTimeout:      2 * time.Minute,
+		MaxStreamsPerPeer: 64,
+	}
+}
+
+// SetLimits sets the message size, deadlines and per-peer stream cap
+// Applies to streams opened afterwards.
+func (h *RPCHandler) SetLimits(limits RPCLimits) {
+	h.mu.Lock()
+	h.limits = limits
+	h.mu.Unlock()
+
+	fmt.Printf("⏱️  RPC limits set: max message %d bytes, read %s, write %s, %d streams per peer\n",
+		limits.MaxMessageSize, limits.ReadTimeout, limits.WriteTimeout, limits.MaxStreamsPerPeer)
+}
+
+// Limits returns the handler's RPC limits
+func (h *RPCHandler) Limits() RPCLimits {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.limits
+}
+
+// acquireStream reserves one of a peer's stream slots, returning false if the cap is reached
+func (h *RPCHandler) acquireStream(from peer.ID, max int) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if max > 0 && h.streams[from] >= max {
+		return false
+	}
+	h.streams[from]++
+	return true
+}
+
+// releaseStream frees a slot taken by acquireStream
+func (h *RPCHandler) releaseStream(from peer.ID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.streams[from]--; h.streams[from] <= 0 {
+		delete(h.streams, from)
+	}
+}
+
+// ActiveStreams returns how many requests a peer has in progress
+func (h *RPCHandler) ActiveStreams(from peer.ID) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.streams[from]
+}
+
+// requestReader returns a reader of the stream that fails with ErrRPCMessageTooLarge past MaxMessageSize
+func (l RPCLimits) requestReader(stream network.Stream) io.Reader {
+	stream.SetReadDeadline(rpcDeadline(l.ReadTimeout))
+	if l.MaxMessageSize <= 0 {
+		return stream
+	}
+	return &sizeLimitedReader{r: stream, remaining: l.MaxMessageSize}
+}
+
+// sizeLimitedReader is an io.LimitedReader that reports running out instead of EOF
+type sizeLimitedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *sizeLimitedReader) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		return 0, ErrRPCMessageTooLarge
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
+
+// isStreamTimeout reports whether err is a stream deadline expiring
+func isStreamTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// rpcDeadline returns now+d, or no deadline for d <= 0
+func rpcDeadline(d time.Duration) time.Time {
+	if d <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(d)
+}
@@ -2,6 +2,7 @@ package meshstorage
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -245,3 +246,210 @@ func TestRPCMultipleChunks(t *testing.T) {
 
 	t.Log("RPC multiple chunks test passed!")
 }
+
+func TestRPCInventory(t *testing.T) {
+	ctx := context.Background()
+
+	node1, err := NewDHTNode(ctx, &NodeConfig{Port: 0, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create node1: %v", err)
+	}
+	defer node1.Close()
+	NewRPCHandler(node1).SetupStreamHandler()
+
+	node2, err := NewDHTNode(ctx, &NodeConfig{Port: 0, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create node2: %v", err)
+	}
+	defer node2.Close()
+
+	peerAddr := node1.Addresses()[0].String() + "/p2p/" + node1.ID().String()
+	if err := node2.Connect(ctx, peerAddr); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := node1.Storage().StoreChunk("0xinv_1_shard_"+fmt.Sprint(i), i, []byte("shard")); err != nil {
+			t.Fatalf("StoreChunk failed: %v", err)
+		}
+	}
+
+	ds, err := NewDistributedStorage(node2)
+	if err != nil {
+		t.Fatalf("Failed to create distributed storage: %v", err)
+	}
+	defer ds.StopMonitoring()
+
+	shards, err := ds.refreshInventory(ctx, node1.ID())
+	if err != nil {
+		t.Fatalf("refreshInventory failed: %v", err)
+	}
+	if len(shards) != 3 || !shards[inventoryKey("0xinv_1_shard_1", 1)] {
+		t.Fatalf("Unexpected inventory: %v", shards)
+	}
+
+	// A deletion is invisible to an incremental query; the total mismatch must force a full refresh
+	if err := node1.Storage().DeleteChunk("0xinv_1_shard_1", 1); err != nil {
+		t.Fatalf("DeleteChunk failed: %v", err)
+	}
+
+	shards, err = ds.refreshInventory(ctx, node1.ID())
+	if err != nil {
+		t.Fatalf("refreshInventory failed: %v", err)
+	}
+	if len(shards) != 2 || shards[inventoryKey("0xinv_1_shard_1", 1)] {
+		t.Fatalf("Deleted shard still in inventory: %v", shards)
+	}
+}
+
+func TestInventoryExpiry(t *testing.T) {
+	ctx := context.Background()
+	_, server, client := newLimitedRPCPair(t, DefaultRPCLimits())
+
+	ds, err := NewDistributedStorage(client)
+	if err != nil {
+		t.Fatalf("Failed to create distributed storage: %v", err)
+	}
+	defer ds.StopMonitoring()
+
+	if _, err := ds.refreshInventory(ctx, server.ID()); err != nil {
+		t.Fatalf("refreshInventory failed: %v", err)
+	}
+	held := func() bool {
+		ds.inventoryMu.Lock()
+		defer ds.inventoryMu.Unlock()
+		return ds.inventories[server.ID()] != nil
+	}
+
+	// Connected peers keep their inventory however long ago it was refreshed
+	expired := time.Now().Add(inventoryTTL + time.Minute)
+	ds.pruneInventories(expired)
+	if !held() {
+		t.Fatal("Inventory of a connected peer was dropped")
+	}
+
+	// Once disconnected, it lasts until the TTL runs out
+	if err := client.Host().Network().ClosePeer(server.ID()); err != nil {
+		t.Fatalf("ClosePeer failed: %v", err)
+	}
+	ds.pruneInventories(time.Now())
+	if !held() {
+		t.Fatal("Fresh inventory of a disconnected peer was dropped")
+	}
+	ds.pruneInventories(expired)
+	if held() {
+		t.Fatal("Expired inventory of a disconnected peer was kept")
+	}
+}
+
+// newLimitedRPCPair starts a node serving RPC under limits and a second node connected to it
+func newLimitedRPCPair(t *testing.T, limits RPCLimits) (*RPCHandler, *DHTNode, *DHTNode) {
+	t.Helper()
+	ctx := context.Background()
+
+	server, err := NewDHTNode(ctx, &NodeConfig{Port: 0, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create server node: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+	handler := NewRPCHandler(server)
+	handler.SetLimits(limits)
+	handler.SetupStreamHandler()
+
+	client, err := NewDHTNode(ctx, &NodeConfig{Port: 0, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create client node: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	peerAddr := server.Addresses()[0].String() + "/p2p/" + server.ID().String()
+	if err := client.Connect(ctx, peerAddr); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	return handler, server, client
+}
+
+// waitActiveStreams waits until the handler counts want requests in progress from the client
+func waitActiveStreams(t *testing.T, handler *RPCHandler, client *DHTNode, want int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for handler.ActiveStreams(client.ID()) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("Active streams = %d, want %d", handler.ActiveStreams(client.ID()), want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRPCMessageSizeLimit(t *testing.T) {
+	limits := DefaultRPCLimits()
+	limits.MaxMessageSize = 4 << 10
+	_, server, client := newLimitedRPCPair(t, limits)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	rpc := NewRPCClient(client)
+
+	if err := rpc.StoreChunk(ctx, server.ID(), "0xlimit", 1, make([]byte, 16<<10)); err == nil {
+		t.Fatal("Oversized request was accepted")
+	}
+	if _, err := server.Storage().GetChunk("0xlimit", 1); err == nil {
+		t.Fatal("Oversized chunk was stored")
+	}
+
+	if err := rpc.StoreChunk(ctx, server.ID(), "0xlimit", 2, make([]byte, 1<<10)); err != nil {
+		t.Fatalf("Request under the limit failed: %v", err)
+	}
+}
+
+func TestRPCReadTimeout(t *testing.T) {
+	limits := DefaultRPCLimits()
+	limits.ReadTimeout = 200 * time.Millisecond
+	handler, server, client := newLimitedRPCPair(t, limits)
+
+	// A stream that never finishes its request is dropped once the read deadline passes
+	stream, err := client.host.NewStream(context.Background(), server.ID(), ProtocolID)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	defer stream.Close()
+	if _, err := stream.Write([]byte(`{"type":`)); err != nil {
+		t.Fatalf("Failed to write to stream: %v", err)
+	}
+	waitActiveStreams(t, handler, client, 1)
+
+	stream.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := stream.Read(make([]byte, 1)); err == nil || isStreamTimeout(err) {
+		t.Fatalf("Read from stalled stream = %v, want reset by the server", err)
+	}
+	waitActiveStreams(t, handler, client, 0)
+}
+
+func TestRPCStreamsPerPeer(t *testing.T) {
+	limits := DefaultRPCLimits()
+	limits.MaxStreamsPerPeer = 1
+	handler, server, client := newLimitedRPCPair(t, limits)
+
+	stream, err := client.host.NewStream(context.Background(), server.ID(), ProtocolID)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	if _, err := stream.Write([]byte(`{"type":`)); err != nil {
+		t.Fatalf("Failed to write to stream: %v", err)
+	}
+	waitActiveStreams(t, handler, client, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	rpc := NewRPCClient(client)
+	if err := rpc.Ping(ctx, server.ID()); err == nil {
+		t.Fatal("Ping over the stream limit succeeded")
+	}
+
+	// Finishing the stalled request frees the slot
+	stream.Reset()
+	waitActiveStreams(t, handler, client, 0)
+	if err := rpc.Ping(ctx, server.ID()); err != nil {
+		t.Fatalf("Ping after the slot was freed failed: %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+// Package meshstorage provides distributed storage for ZenTalk encrypted chat history
+package meshstorage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+const (
+	// DefaultSeedListSize is how many peers a seed list holds unless asked otherwise
+	DefaultSeedListSize = 50
+
+	// SeedListTTL is how long a signed seed list stays valid
+	SeedListTTL = time.Hour
+)
+
+// SeedPeer is one entry of a seed list
+type SeedPeer struct {
+	ID    string   `json:"id"`
+	Addrs []string `json:"addrs"`
+}
+
+// SeedList is a signed list of peers to bootstrap from
+// It is signed with the serving node's libp2p key, so a client that knows the
+// bootstrap node's peer ID can fetch the list over plain HTTP and still detect
+// tampering.
+type SeedList struct {
+	Signer    string     `json:"signer"` // Peer ID of the node that signed the list
+	IssuedAt  int64      `json:"issuedAt"`
+	ExpiresAt int64      `json:"expiresAt"`
+	Peers     []SeedPeer `json:"peers"`
+	Signature []byte     `json:"signature"`
+}
+
+// SigningPayload returns the bytes covered by the signer's signature
+func (s *SeedList) SigningPayload() []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "zentalk-seeds|%s|%d|%d", s.Signer, s.IssuedAt, s.ExpiresAt)
+	for _, p := range s.Peers {
+		fmt.Fprintf(&b, "|%s=%s", p.ID, strings.Join(p.Addrs, ","))
+	}
+	return []byte(b.String())
+}
+
+// Sign signs the list with a node's libp2p key and records the node as signer
+func (s *SeedList) Sign(key crypto.PrivKey) error {
+	signer, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to derive signer ID: %w", err)
+	}
+	s.Signer = signer.String()
+
+	signature, err := key.Sign(s.SigningPayload())
+	if err != nil {
+		return fmt.Errorf("failed to sign seed list: %w", err)
+	}
+	s.Signature = signature
+	return nil
+}
+
+// Verify checks the list was signed by the given peer and has not expired
+func (s *SeedList) Verify(signer peer.ID) error {
+	if s.Signer != signer.String() {
+		return fmt.Errorf("seed list signed by %s, expected %s", s.Signer, signer)
+	}
+	if s.Expired() {
+		return fmt.Errorf("seed list expired at %s", time.Unix(s.ExpiresAt, 0).Format(time.RFC3339))
+	}
+
+	publicKey, err := signer.ExtractPublicKey()
+	if err != nil {
+		return fmt.Errorf("signer ID does not embed a public key: %w", err)
+	}
+	ok, err := publicKey.Verify(s.SigningPayload(), s.Signature)
+	if err != nil || !ok {
+		return fmt.Errorf("invalid seed list signature")
+	}
+	return nil
+}
+
+// Expired reports whether the list's expiry has passed
+func (s *SeedList) Expired() bool {
+	return time.Now().Unix() >= s.ExpiresAt
+}
+
+// BootstrapAddrs returns the list as /p2p multiaddrs accepted by Bootstrap
+func (s *SeedList) BootstrapAddrs() []string {
+	var addrs []string
+	for _, p := range s.Peers {
+		for _, addr := range p.Addrs {
+			addrs = append(addrs, addr+"/p2p/"+p.ID)
+		}
+	}
+	return addrs
+}
+
+// SeedList returns a signed list of this node and up to max-1 known-good peers
+// Known-good peers are connected, not blocked and not in announced maintenance.
+func (n *DHTNode) SeedList(max int) (*SeedList, error) {
+	if max <= 0 {
+		max = DefaultSeedListSize
+	}
+
+	key := n.host.Peerstore().PrivKey(n.host.ID())
+	if key == nil {
+		return nil, fmt.Errorf("node private key not available")
+	}
+
+	// Bootstrap nodes mostly see inbound connections, which GetPeers only picks up on
+	// the next health check, so ask the host directly
+	var candidates []SeedPeer
+	for _, id := range n.host.Network().Peers() {
+		addrs := n.host.Peerstore().Addrs(id)
+		if len(addrs) == 0 || n.PeerBlocked(id) || n.PeerInMaintenance(id) {
+			continue
+		}
+		candidates = append(candidates, SeedPeer{ID: id.String(), Addrs: addrStrings(addrs)})
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].ID < candidates[j].ID })
+
+	self := SeedPeer{ID: n.ID().String(), Addrs: addrStrings(n.Addresses())}
+	peers := append([]SeedPeer{self}, candidates...)
+	if len(peers) > max {
+		peers = peers[:max]
+	}
+
+	now := time.Now()
+	list := &SeedList{
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(SeedListTTL).Unix(),
+		Peers:     peers,
+	}
+	if err := list.Sign(key); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// addrStrings converts multiaddrs to their string form
+func addrStrings(addrs []multiaddr.Multiaddr) []string {
+	out := make([]string, len(addrs))
+	for i, addr := range addrs {
+		out[i] = addr.String()
+	}
+	return out
+}
@@ -0,0 +1,121 @@
+package meshstorage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBootstrapOnlyNode(t *testing.T) {
+	ctx := context.Background()
+
+	seed, err := NewDHTNode(ctx, &NodeConfig{Port: 0, BootstrapOnly: true, MaxConnections: 64})
+	if err != nil {
+		t.Fatalf("Failed to create bootstrap node: %v", err)
+	}
+	defer seed.Close()
+
+	if !seed.IsBootstrapOnly() || seed.Storage() != nil {
+		t.Fatal("Bootstrap-only node should have no storage")
+	}
+	info, err := seed.GetNodeInfo()
+	if err != nil {
+		t.Fatalf("GetNodeInfo() error = %v", err)
+	}
+	if info.StorageStats != nil {
+		t.Errorf("StorageStats = %+v, want nil", info.StorageStats)
+	}
+
+	// A storage node joins through the bootstrap node
+	bootstrapAddr := seed.Addresses()[0].String() + "/p2p/" + seed.ID().String()
+	member, err := NewDHTNode(ctx, &NodeConfig{Port: 0, DataDir: t.TempDir(), BootstrapPeers: []string{bootstrapAddr}})
+	if err != nil {
+		t.Fatalf("Failed to create member node: %v", err)
+	}
+	defer member.Close()
+
+	// Wait for identify to record the member's listen addresses
+	deadline := time.Now().Add(5 * time.Second)
+	var list *SeedList
+	for {
+		list, err = seed.SeedList(10)
+		if err != nil {
+			t.Fatalf("SeedList() error = %v", err)
+		}
+		if len(list.Peers) == 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if len(list.Peers) != 2 {
+		t.Fatalf("Seed list has %d peers, want 2", len(list.Peers))
+	}
+	if list.Peers[0].ID != seed.ID().String() || list.Peers[1].ID != member.ID().String() {
+		t.Errorf("Seed list peers = %+v, want bootstrap node then member", list.Peers)
+	}
+	if err := list.Verify(seed.ID()); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+
+	// Limits include the bootstrap node itself
+	if short, err := seed.SeedList(1); err != nil || len(short.Peers) != 1 {
+		t.Errorf("SeedList(1) = %v, %v; want one peer", short, err)
+	}
+
+	// Another node can join from the list alone
+	joiner, err := NewDHTNode(ctx, &NodeConfig{Port: 0, DataDir: t.TempDir(), BootstrapPeers: list.BootstrapAddrs()})
+	if err != nil {
+		t.Fatalf("Failed to join from seed list: %v", err)
+	}
+	joiner.Close()
+}
+
+func TestSeedListVerify(t *testing.T) {
+	node, err := NewDHTNode(context.Background(), &NodeConfig{Port: 0, BootstrapOnly: true})
+	if err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+	defer node.Close()
+
+	other, err := NewDHTNode(context.Background(), &NodeConfig{Port: 0, BootstrapOnly: true})
+	if err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+	defer other.Close()
+
+	tests := []struct {
+		name   string
+		modify func(*SeedList)
+	}{
+		{"Added peer", func(s *SeedList) {
+			s.Peers = append(s.Peers, SeedPeer{ID: other.ID().String(), Addrs: []string{"/ip4/10.0.0.1/tcp/9000"}})
+		}},
+		{"Changed address", func(s *SeedList) { s.Peers[0].Addrs = []string{"/ip4/10.0.0.1/tcp/9000"} }},
+		{"Extended expiry", func(s *SeedList) { s.ExpiresAt += 3600 }},
+		{"Expired", func(s *SeedList) { s.ExpiresAt = time.Now().Add(-time.Minute).Unix() }},
+		{"Claims other signer", func(s *SeedList) { s.Signer = other.ID().String() }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := node.SeedList(0)
+			if err != nil {
+				t.Fatalf("SeedList() error = %v", err)
+			}
+			tt.modify(list)
+			if err := list.Verify(node.ID()); err == nil {
+				t.Error("Verify() expected error, got nil")
+			}
+		})
+	}
+
+	// A list is only valid for the signer the client expects
+	list, err := node.SeedList(0)
+	if err != nil {
+		t.Fatalf("SeedList() error = %v", err)
+	}
+	if err := list.Verify(other.ID()); err == nil {
+		t.Error("Verify(other signer) expected error, got nil")
+	}
+}
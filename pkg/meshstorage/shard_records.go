@@ -0,0 +1,252 @@
+package meshstorage
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/routing"
+)
+
+const (
+	// DHTProtocolPrefix is the prefix of the mesh's DHT protocols
+	// Nodes only exchange DHT messages with nodes using the same prefix.
+	DHTProtocolPrefix = "/zentalk"
+
+	// LegacyShardRecordNamespace is where shard location records were published before ManifestNamespace
+	// Records there are still accepted and looked up until they expire.
+	LegacyShardRecordNamespace = "zentalk-shards"
+)
+
+const (
+	// shardRecordTimeout bounds publishing a record from calls that have no context
+	shardRecordTimeout = 30 * time.Second
+	// shardRecordRepublish is how often monitored chunks' records are published again
+	// DHT nodes drop records after 36 hours, so this must stay well below that.
+	shardRecordRepublish = 12 * time.Hour
+)
+
+// ShardLocationRecord tells any node where a chunk's shards actually live
+// It is published in the DHT under the chunk's storage key by the node that
+// placed or last repaired the shards, and signed with that node's libp2p key
+// so DHT nodes reject records altered in transit or storage. Deleting the
+// chunk publishes a record without a manifest. Only the same node or one
+// holding the chunk's shards may publish a record replacing it.
+type ShardLocationRecord struct {
+	UserAddr  string `json:"userAddr"`
+	ChunkID   int    `json:"chunkID"`
+	Manifest  []byte `json:"manifest,omitempty"` // Chunk manifest (see encodeManifest); empty once the chunk is deleted
+	Publisher string `json:"publisher"`          // Peer ID of the publishing node
+	PublicKey []byte `json:"publicKey"`          // Publisher's libp2p public key; verifies Signature
+	UpdatedAt int64  `json:"updatedAt"`          // Unix nanoseconds; the newest valid record wins
+	Signature []byte `json:"signature"`
+}
+
+// shardRecordKey returns the DHT key of a chunk's shard location record
+func shardRecordKey(userAddr string, chunkID int) string {
+	return dhtRecordKey(ManifestNamespace, generateStorageKey(userAddr, chunkID))
+}
+
+// legacyShardRecordKey returns the key a chunk's shard location record had in LegacyShardRecordNamespace
+func legacyShardRecordKey(userAddr string, chunkID int) string {
+	return "/" + LegacyShardRecordNamespace + "/" + generateStorageKey(userAddr, chunkID)
+}
+
+// newShardLocationRecord creates an unsigned record of a chunk's shards (nil chunk = deleted)
+func newShardLocationRecord(userAddr string, chunkID int, chunk *DistributedChunk) (*ShardLocationRecord, error) {
+	r := &ShardLocationRecord{UserAddr: userAddr, ChunkID: chunkID, UpdatedAt: time.Now().UnixNano()}
+	if chunk != nil {
+		manifest, err := encodeManifest(chunk)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal chunk manifest: %w", err)
+		}
+		r.Manifest = manifest
+	}
+	return r, nil
+}
+
+// SigningPayload returns the bytes covered by the publisher's signature
+func (r *ShardLocationRecord) SigningPayload() []byte {
+	return []byte(fmt.Sprintf("zentalk-shards|%s|%d|%s|%s|%d",
+		r.UserAddr, r.ChunkID, base64.StdEncoding.EncodeToString(r.Manifest), r.Publisher, r.UpdatedAt))
+}
+
+// Sign signs the record with a node's libp2p key and embeds the matching public key
+func (r *ShardLocationRecord) Sign(key crypto.PrivKey) error {
+	publisher, publicKey, err := recordIdentity(key)
+	if err != nil {
+		return err
+	}
+	r.Publisher = publisher
+	r.PublicKey = publicKey
+
+	signature, err := key.Sign(r.SigningPayload())
+	if err != nil {
+		return fmt.Errorf("failed to sign shard location record: %w", err)
+	}
+	r.Signature = signature
+	return nil
+}
+
+// Verify checks that the record was signed by its publisher
+func (r *ShardLocationRecord) Verify() error {
+	return verifyRecordSignature(r.Publisher, r.PublicKey, r.SigningPayload(), r.Signature)
+}
+
+// Deleted reports whether the record marks the chunk as deleted
+func (r *ShardLocationRecord) Deleted() bool {
+	return len(r.Manifest) == 0
+}
+
+// Chunk returns the chunk manifest carried by the record
+func (r *ShardLocationRecord) Chunk() (*DistributedChunk, error) {
+	if r.Deleted() {
+		return nil, ErrManifestNotFound
+	}
+	return decodeManifest(r.Manifest)
+}
+
+// parseShardLocationRecord decodes and verifies a record fetched from the DHT
+func parseShardLocationRecord(key string, value []byte) (*ShardLocationRecord, error) {
+	var r ShardLocationRecord
+	if err := json.Unmarshal(value, &r); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal shard location record: %w", err)
+	}
+	if key != shardRecordKey(r.UserAddr, r.ChunkID) && key != legacyShardRecordKey(r.UserAddr, r.ChunkID) {
+		return nil, fmt.Errorf("shard location record of %s:%d stored under wrong key", r.UserAddr, r.ChunkID)
+	}
+	if err := r.Verify(); err != nil {
+		return nil, err
+	}
+	if !r.Deleted() {
+		chunk, err := r.Chunk()
+		if err != nil {
+			return nil, err
+		}
+		if chunk.UserAddr != r.UserAddr || chunk.ChunkID != r.ChunkID {
+			return nil, errors.New("shard location record carries another chunk's manifest")
+		}
+	}
+	return &r, nil
+}
+
+// shardRecordValidator lets DHT nodes check and order records in LegacyShardRecordNamespace
+// They are held to the same rules as records in ManifestNamespace.
+type shardRecordValidator struct {
+	history *recordHistory // Nil checks each record on its own
+}
+
+// Validate rejects records that are malformed, unsigned, under another chunk's
+// key or published by a node that does not hold the chunk
+func (v shardRecordValidator) Validate(key string, value []byte) error {
+	info, err := parseLegacyShardRecord(key, value)
+	if err != nil {
+		return err
+	}
+	return v.history.check(recordRules[ManifestNamespace], key, info)
+}
+
+// Select picks the most recently updated valid record from a holder of the chunk
+func (shardRecordValidator) Select(key string, values [][]byte) (int, error) {
+	return selectRecord(recordRules[ManifestNamespace], values, func(value []byte) (*recordInfo, error) {
+		return parseLegacyShardRecord(key, value)
+	})
+}
+
+// parseLegacyShardRecord checks a record in LegacyShardRecordNamespace
+func parseLegacyShardRecord(key string, value []byte) (*recordInfo, error) {
+	if !strings.HasPrefix(key, "/"+LegacyShardRecordNamespace+"/") {
+		return nil, fmt.Errorf("key %q is not in the %s namespace", key, LegacyShardRecordNamespace)
+	}
+	return checkRecord(recordRules[ManifestNamespace], key, "", value)
+}
+
+// publishShardLocations publishes where a chunk's shards live (nil chunk = deleted) (best effort)
+func (ds *DistributedStorage) publishShardLocations(ctx context.Context, userAddr string, chunkID int, chunk *DistributedChunk) {
+	if err := ds.putShardRecord(ctx, userAddr, chunkID, chunk); err != nil {
+		fmt.Printf("⚠️  Failed to publish shard locations of chunk %s:%d: %v\n", userAddr, chunkID, err)
+		return
+	}
+
+	// Monitored chunks have their record published again before it expires
+	key := chunkKey(userAddr, chunkID)
+	ds.chunksMu.Lock()
+	if _, tracked := ds.chunks[key]; tracked && chunk != nil {
+		ds.published[key] = time.Now()
+	}
+	ds.chunksMu.Unlock()
+}
+
+// putShardRecord signs a chunk's shard location record and puts it in the DHT
+func (ds *DistributedStorage) putShardRecord(ctx context.Context, userAddr string, chunkID int, chunk *DistributedChunk) error {
+	key := ds.node.Host().Peerstore().PrivKey(ds.node.ID())
+	if key == nil {
+		return errors.New("node key not available")
+	}
+
+	record, err := newShardLocationRecord(userAddr, chunkID, chunk)
+	if err != nil {
+		return err
+	}
+	if err := record.Sign(key); err != nil {
+		return err
+	}
+	value, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal shard location record: %w", err)
+	}
+
+	// The DHT keeps the record locally before looking for peers, so a node
+	// without any still answers lookups for it
+	dhtInst := ds.node.DHT()
+	if err := dhtInst.PutValue(ctx, shardRecordKey(userAddr, chunkID), value); err != nil && dhtInst.RoutingTable().Size() > 0 {
+		return err
+	}
+	return nil
+}
+
+// LookupShardLocations finds where a chunk's shards live from its record in the DHT
+// Returns ErrManifestNotFound if no record was published or the chunk was deleted.
+func (ds *DistributedStorage) LookupShardLocations(ctx context.Context, userAddr string, chunkID int) (*DistributedChunk, error) {
+	key := shardRecordKey(userAddr, chunkID)
+	value, err := ds.node.DHT().GetValue(ctx, key)
+	if errors.Is(err, routing.ErrNotFound) {
+		// Published by a node from before ManifestNamespace
+		key = legacyShardRecordKey(userAddr, chunkID)
+		value, err = ds.node.DHT().GetValue(ctx, key)
+	}
+	if errors.Is(err, routing.ErrNotFound) {
+		return nil, ErrManifestNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up shard locations: %w", err)
+	}
+
+	record, err := parseShardLocationRecord(key, value)
+	if err != nil {
+		return nil, err
+	}
+	return record.Chunk()
+}
+
+// republishShardLocations publishes again the records of monitored chunks before DHT nodes expire them
+func (ds *DistributedStorage) republishShardLocations(chunks []*DistributedChunk) {
+	now := time.Now()
+	for _, chunk := range chunks {
+		ds.chunksMu.RLock()
+		last := ds.published[chunkKey(chunk.UserAddr, chunk.ChunkID)]
+		ds.chunksMu.RUnlock()
+		if now.Sub(last) < shardRecordRepublish {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), shardRecordTimeout)
+		ds.publishShardLocations(ctx, chunk.UserAddr, chunk.ChunkID, chunk)
+		cancel()
+	}
+}
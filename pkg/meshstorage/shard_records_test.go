@@ -0,0 +1,159 @@
+package meshstorage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	libp2pcrypto "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	libp2ptest "github.com/libp2p/go-libp2p/core/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signedShardRecord returns a shard location record signed by key, as stored in the DHT
+func signedShardRecord(t *testing.T, key libp2pcrypto.PrivKey, chunk *DistributedChunk, updatedAt int64) []byte {
+	t.Helper()
+	record, err := newShardLocationRecord(chunk.UserAddr, chunk.ChunkID, chunk)
+	require.NoError(t, err)
+	record.UpdatedAt = updatedAt
+	require.NoError(t, record.Sign(key))
+	value, err := json.Marshal(record)
+	require.NoError(t, err)
+	return value
+}
+
+// TestShardRecordValidator tests DHT nodes accept only signed records under their chunk's key, newest first
+func TestShardRecordValidator(t *testing.T) {
+	key, _, err := libp2pcrypto.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+
+	chunk := &DistributedChunk{
+		UserAddr:       "0xuser",
+		ChunkID:        1,
+		ShardLocations: []ShardLocation{{ShardIndex: 0, PeerID: libp2ptest.RandPeerIDFatal(t)}},
+		Erasure:        ErasureConfig{DataShards: 1, ParityShards: 0},
+	}
+	dhtKey := shardRecordKey("0xuser", 1)
+	validator := recordValidator{}
+
+	older := signedShardRecord(t, key, chunk, 1)
+	require.NoError(t, validator.Validate(dhtKey, older))
+	assert.Error(t, validator.Validate(shardRecordKey("0xuser", 2), older), "record under another chunk's key")
+
+	var tampered ShardLocationRecord
+	require.NoError(t, json.Unmarshal(older, &tampered))
+	tampered.UpdatedAt++
+	value, err := json.Marshal(tampered)
+	require.NoError(t, err)
+	assert.Error(t, validator.Validate(dhtKey, value), "record changed after signing")
+
+	other, _, err := libp2pcrypto.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+	tampered.PublicKey, err = libp2pcrypto.MarshalPublicKey(other.GetPublic())
+	require.NoError(t, err)
+	value, err = json.Marshal(tampered)
+	require.NoError(t, err)
+	assert.Error(t, validator.Validate(dhtKey, value), "key of another peer")
+
+	newer := signedShardRecord(t, key, chunk, 2)
+	best, err := validator.Select(dhtKey, [][]byte{older, []byte("garbage"), newer})
+	require.NoError(t, err)
+	assert.Equal(t, 2, best)
+
+	_, err = validator.Select(dhtKey, [][]byte{[]byte("garbage")})
+	assert.Error(t, err)
+}
+
+// TestShardRecordHolders tests that only the publisher or a holder of a chunk's shards replaces its record
+func TestShardRecordHolders(t *testing.T) {
+	publisher, _, err := libp2pcrypto.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+	holder, _, err := libp2pcrypto.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+	holderID, err := peer.IDFromPrivateKey(holder)
+	require.NoError(t, err)
+	stranger, _, err := libp2pcrypto.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+
+	chunk := &DistributedChunk{
+		UserAddr:       "0xuser",
+		ChunkID:        1,
+		ShardLocations: []ShardLocation{{ShardIndex: 0, PeerID: holderID}},
+		Erasure:        ErasureConfig{DataShards: 1, ParityShards: 0},
+	}
+	dhtKey := shardRecordKey("0xuser", 1)
+	original := signedShardRecord(t, publisher, chunk, 1)
+	repaired := signedShardRecord(t, holder, chunk, 2)
+
+	hijacked := &DistributedChunk{UserAddr: chunk.UserAddr, ChunkID: chunk.ChunkID, Erasure: chunk.Erasure,
+		ShardLocations: []ShardLocation{{ShardIndex: 0, PeerID: libp2ptest.RandPeerIDFatal(t)}}}
+	takeover := signedShardRecord(t, stranger, hijacked, 3)
+	record, err := newShardLocationRecord("0xuser", 1, nil)
+	require.NoError(t, err)
+	record.UpdatedAt = 3
+	require.NoError(t, record.Sign(stranger))
+	tombstone, err := json.Marshal(record)
+	require.NoError(t, err)
+
+	// A newer record from a stranger loses whichever the node already holds
+	validator := recordValidator{}
+	for _, values := range [][][]byte{{original, takeover}, {takeover, original}, {original, tombstone}, {tombstone, original}} {
+		best, err := validator.Select(dhtKey, values)
+		require.NoError(t, err)
+		assert.Equal(t, original, values[best])
+	}
+	for _, values := range [][][]byte{{original, repaired}, {repaired, original}} {
+		best, err := validator.Select(dhtKey, values)
+		require.NoError(t, err)
+		assert.Equal(t, repaired, values[best], "holder takes over")
+	}
+
+	// A node that accepted the record refuses the stranger's
+	validator = recordValidator{history: newRecordHistory()}
+	require.NoError(t, validator.Validate(dhtKey, original))
+	assert.Error(t, validator.Validate(dhtKey, takeover))
+	assert.Error(t, validator.Validate(dhtKey, tombstone))
+	require.NoError(t, validator.Validate(dhtKey, repaired))
+	assert.NoError(t, validator.Validate(dhtKey, signedShardRecord(t, holder, chunk, 4)))
+
+	legacy := shardRecordValidator{history: newRecordHistory()}
+	legacyKey := legacyShardRecordKey("0xuser", 1)
+	require.NoError(t, legacy.Validate(legacyKey, original))
+	assert.Error(t, legacy.Validate(legacyKey, takeover))
+}
+
+// TestDeleteChunkUsesShardRecord tests a node that does not track a chunk deletes it where its record says it is
+func TestDeleteChunkUsesShardRecord(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	node, err := NewDHTNode(ctx, &NodeConfig{Port: 0, DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer node.Close()
+
+	// The chunk uses a coding the deleting storage manager would not guess
+	stored, err := NewDistributedStorageWithErasure(node, ErasureConfig{DataShards: 2, ParityShards: 1})
+	require.NoError(t, err)
+	defer stored.StopMonitoring()
+
+	chunk, err := stored.StoreDistributed(ctx, "0xuser", 1, []byte("placed by another node"))
+	require.NoError(t, err)
+	stored.UnregisterChunk("0xuser", 1)
+
+	found, err := stored.LookupShardLocations(ctx, "0xuser", 1)
+	require.NoError(t, err)
+	assert.Equal(t, chunk.ShardLocations, found.ShardLocations)
+	assert.Equal(t, chunk.Erasure, found.Erasure)
+
+	ds, err := NewDistributedStorage(node)
+	require.NoError(t, err)
+	defer ds.StopMonitoring()
+
+	require.NoError(t, ds.DeleteChunk(ctx, "0xuser", 1))
+	_, err = ds.LookupShardLocations(ctx, "0xuser", 1)
+	assert.True(t, errors.Is(err, ErrManifestNotFound), "deleted chunk's record is replaced")
+}
@@ -3,18 +3,29 @@ package meshstorage
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/ZentaChain/zentalk-node/pkg/sqldb"
 )
 
-// LocalStorage handles storing encrypted chunks locally using SQLite
+// ErrChunkNotFound is returned when no chunk is stored for the user and chunk ID
+var ErrChunkNotFound = errors.New("chunk not found")
+
+// LocalStorage handles storing encrypted chunks locally using SQLite (or PostgreSQL)
 type LocalStorage struct {
-	db   *sql.DB
-	path string
+	db      *sql.DB
+	dialect sqldb.Dialect
+	path    string
+	cache   *ChunkCache // Optional read-through cache (nil = disabled)
+
+	quotaMu sync.Mutex // Held by stores while a quota is set
+	quota   QuotaConfig
 }
 
 // Chunk represents a stored data chunk
@@ -24,32 +35,53 @@ type Chunk struct {
 	Data      []byte
 	StoredAt  time.Time
 	Size      int
+	LeaseExpires time.Time // Zero = no lease, kept until deleted
 }
 
 // NewLocalStorage creates a new local storage instance
 func NewLocalStorage(dataDir string) (*LocalStorage, error) {
-	// Create data directory if it doesn't exist
+	return NewLocalStorageWithDSN("", dataDir)
+}
+
+// NewLocalStorageWithDSN creates a local storage instance on the database named by dsn
+// An empty dsn keeps the default SQLite database in dataDir; a postgres:// DSN
+// stores chunks in PostgreSQL for deployments where SQLite's single writer is the bottleneck
+func NewLocalStorageWithDSN(dsn, dataDir string) (*LocalStorage, error) {
+	// Create data directory if it doesn't exist (also holds SQLite backups)
 	if err := os.MkdirAll(dataDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create data directory: %w", err)
 	}
 
-	dbPath := filepath.Join(dataDir, "chunks.db")
+	if dsn == "" {
+		dsn = filepath.Join(dataDir, "chunks.db")
+	}
+	dialect, source := sqldb.ParseDSN(dsn)
 
 	// Check if this is a new database
 	isNewDB := false
-	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
-		isNewDB = true
+	if dialect == sqldb.SQLite {
+		if _, err := os.Stat(source); os.IsNotExist(err) {
+			isNewDB = true
+		}
 	}
 
-	// Open SQLite database
-	db, err := sql.Open("sqlite3", dbPath)
+	db, _, err := sqldb.Open(dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
+	if dialect == sqldb.Postgres {
+		exists, err := sqldb.TableExists(db, "chunks")
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to inspect database: %w", err)
+		}
+		isNewDB = !exists
+	}
+
 	if isNewDB {
 		// New database - create initial schema
-		fmt.Println("📊 Creating new database with current schema...")
+		fmt.Printf("📊 Creating new %s database with current schema...\n", dialect)
 
 		schema := `
 			CREATE TABLE IF NOT EXISTS chunks (
@@ -64,13 +96,13 @@ func NewLocalStorage(dataDir string) (*LocalStorage, error) {
 			CREATE INDEX IF NOT EXISTS idx_stored_at ON chunks(stored_at);
 		`
 
-		if _, err := db.Exec(schema); err != nil {
+		if _, err := db.Exec(dialect.Translate(schema)); err != nil {
 			db.Close()
 			return nil, fmt.Errorf("failed to create schema: %w", err)
 		}
 
-		// Run migrations to set version
-		if err := RunMigrations(db, dataDir); err != nil {
+		// Apply migrations and record the current version
+		if err := initializeSchema(db); err != nil {
 			db.Close()
 			return nil, fmt.Errorf("failed to initialize schema version: %w", err)
 		}
@@ -98,42 +130,111 @@ func NewLocalStorage(dataDir string) (*LocalStorage, error) {
 		}
 	}
 
+	path := source
+	if dialect == sqldb.Postgres {
+		path = redactDSN(dsn)
+	}
+
 	return &LocalStorage{
-		db:   db,
-		path: dbPath,
+		db:      db,
+		dialect: dialect,
+		path:    path,
 	}, nil
 }
 
+// redactDSN removes the password from a database URL so it can be shown
+func redactDSN(dsn string) string {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "postgres"
+	}
+	if u.User != nil {
+		u.User = url.User(u.User.Username())
+	}
+	return u.String()
+}
+
+// exec runs a statement with dialect-specific placeholders
+func (s *LocalStorage) exec(query string, args ...interface{}) (sql.Result, error) {
+	return s.db.Exec(s.dialect.Rebind(query), args...)
+}
+
+// query runs a query with dialect-specific placeholders
+func (s *LocalStorage) query(query string, args ...interface{}) (*sql.Rows, error) {
+	return s.db.Query(s.dialect.Rebind(query), args...)
+}
+
+// queryRow runs a single-row query with dialect-specific placeholders
+func (s *LocalStorage) queryRow(query string, args ...interface{}) *sql.Row {
+	return s.db.QueryRow(s.dialect.Rebind(query), args...)
+}
+
 // StoreChunk stores an encrypted chunk for a user
+// The chunk has no lease; rewriting a leased chunk this way drops its lease.
 func (s *LocalStorage) StoreChunk(userAddr string, chunkID int, data []byte) error {
+	return s.StoreChunkWithLease(userAddr, chunkID, data, time.Time{})
+}
+
+// StoreChunkWithLease stores an encrypted chunk that may be collected after leaseExpires
+// A zero leaseExpires stores the chunk without a lease. Rewriting a chunk
+// replaces its lease. Returns a *QuotaError if the chunk does not fit the quota.
+func (s *LocalStorage) StoreChunkWithLease(userAddr string, chunkID int, data []byte, leaseExpires time.Time) error {
 	if len(data) == 0 {
 		return fmt.Errorf("cannot store empty chunk")
 	}
 
-	query := `INSERT OR REPLACE INTO chunks (user_addr, chunk_id, data, stored_at, size)
-	          VALUES (?, ?, ?, ?, ?)`
+	// With quotas, stores are serialized so concurrent ones can't each fit and together exceed them
+	s.quotaMu.Lock()
+	if s.quota.enabled() {
+		defer s.quotaMu.Unlock()
+		if err := s.checkQuota(userAddr, chunkID, int64(len(data))); err != nil {
+			return err
+		}
+	} else {
+		s.quotaMu.Unlock()
+	}
+
+	query := `INSERT INTO chunks (user_addr, chunk_id, data, stored_at, size, lease_expires)
+	          VALUES (?, ?, ?, ?, ?, ?)
+	          ON CONFLICT (user_addr, chunk_id) DO UPDATE SET
+	              data = excluded.data, stored_at = excluded.stored_at, size = excluded.size,
+	              lease_expires = excluded.lease_expires`
 
-	_, err := s.db.Exec(query, userAddr, chunkID, data, time.Now().Unix(), len(data))
+	_, err := s.exec(query, userAddr, chunkID, data, time.Now().Unix(), len(data), leaseUnix(leaseExpires))
 	if err != nil {
 		return fmt.Errorf("failed to store chunk: %w", err)
 	}
 
+	if s.cache != nil {
+		s.cache.Remove(localCacheKey(userAddr, chunkID))
+	}
+
 	return nil
 }
 
 // GetChunk retrieves an encrypted chunk for a user
 func (s *LocalStorage) GetChunk(userAddr string, chunkID int) ([]byte, error) {
+	if s.cache != nil {
+		if data, ok := s.cache.Get(localCacheKey(userAddr, chunkID)); ok {
+			return data, nil
+		}
+	}
+
 	query := `SELECT data FROM chunks WHERE user_addr = ? AND chunk_id = ?`
 
 	var data []byte
-	err := s.db.QueryRow(query, userAddr, chunkID).Scan(&data)
+	err := s.queryRow(query, userAddr, chunkID).Scan(&data)
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("chunk not found: user=%s chunk=%d", userAddr, chunkID)
+		return nil, fmt.Errorf("%w: user=%s chunk=%d", ErrChunkNotFound, userAddr, chunkID)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve chunk: %w", err)
 	}
 
+	if s.cache != nil {
+		s.cache.Put(localCacheKey(userAddr, chunkID), data)
+	}
+
 	return data, nil
 }
 
@@ -141,7 +242,7 @@ func (s *LocalStorage) GetChunk(userAddr string, chunkID int) ([]byte, error) {
 func (s *LocalStorage) ListChunks(userAddr string) ([]int, error) {
 	query := `SELECT chunk_id FROM chunks WHERE user_addr = ? ORDER BY chunk_id`
 
-	rows, err := s.db.Query(query, userAddr)
+	rows, err := s.query(query, userAddr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query chunks: %w", err)
 	}
@@ -163,7 +264,7 @@ func (s *LocalStorage) ListChunks(userAddr string) ([]int, error) {
 func (s *LocalStorage) GetAllChunks(userAddr string) (map[int][]byte, error) {
 	query := `SELECT chunk_id, data FROM chunks WHERE user_addr = ? ORDER BY chunk_id`
 
-	rows, err := s.db.Query(query, userAddr)
+	rows, err := s.query(query, userAddr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query chunks: %w", err)
 	}
@@ -186,18 +287,22 @@ func (s *LocalStorage) GetAllChunks(userAddr string) (map[int][]byte, error) {
 func (s *LocalStorage) DeleteChunk(userAddr string, chunkID int) error {
 	query := `DELETE FROM chunks WHERE user_addr = ? AND chunk_id = ?`
 
-	result, err := s.db.Exec(query, userAddr, chunkID)
+	result, err := s.exec(query, userAddr, chunkID)
 	if err != nil {
 		return fmt.Errorf("failed to delete chunk: %w", err)
 	}
 
+	if s.cache != nil {
+		s.cache.Remove(localCacheKey(userAddr, chunkID))
+	}
+
 	rows, err := result.RowsAffected()
 	if err != nil {
 		return fmt.Errorf("failed to get affected rows: %w", err)
 	}
 
 	if rows == 0 {
-		return fmt.Errorf("chunk not found: user=%s chunk=%d", userAddr, chunkID)
+		return fmt.Errorf("%w: user=%s chunk=%d", ErrChunkNotFound, userAddr, chunkID)
 	}
 
 	return nil
@@ -207,11 +312,15 @@ func (s *LocalStorage) DeleteChunk(userAddr string, chunkID int) error {
 func (s *LocalStorage) DeleteAllChunks(userAddr string) error {
 	query := `DELETE FROM chunks WHERE user_addr = ?`
 
-	_, err := s.db.Exec(query, userAddr)
+	_, err := s.exec(query, userAddr)
 	if err != nil {
 		return fmt.Errorf("failed to delete chunks: %w", err)
 	}
 
+	if s.cache != nil {
+		s.cache.RemovePrefix(localCacheKeyPrefix(userAddr))
+	}
+
 	return nil
 }
 
@@ -220,7 +329,7 @@ func (s *LocalStorage) GetStorageSize() (int64, error) {
 	query := `SELECT COALESCE(SUM(size), 0) FROM chunks`
 
 	var totalSize int64
-	err := s.db.QueryRow(query).Scan(&totalSize)
+	err := s.queryRow(query).Scan(&totalSize)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get storage size: %w", err)
 	}
@@ -233,7 +342,7 @@ func (s *LocalStorage) GetStorageSizeForUser(userAddr string) (int64, error) {
 	query := `SELECT COALESCE(SUM(size), 0) FROM chunks WHERE user_addr = ?`
 
 	var totalSize int64
-	err := s.db.QueryRow(query, userAddr).Scan(&totalSize)
+	err := s.queryRow(query, userAddr).Scan(&totalSize)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get user storage size: %w", err)
 	}
@@ -246,7 +355,7 @@ func (s *LocalStorage) GetChunkCount() (int, error) {
 	query := `SELECT COUNT(*) FROM chunks`
 
 	var count int
-	err := s.db.QueryRow(query).Scan(&count)
+	err := s.queryRow(query).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get chunk count: %w", err)
 	}
@@ -259,7 +368,7 @@ func (s *LocalStorage) GetUserCount() (int, error) {
 	query := `SELECT COUNT(DISTINCT user_addr) FROM chunks`
 
 	var count int
-	err := s.db.QueryRow(query).Scan(&count)
+	err := s.queryRow(query).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get user count: %w", err)
 	}
@@ -297,6 +406,31 @@ func (s *LocalStorage) GetStats() (*StorageStats, error) {
 	}, nil
 }
 
+// EnableCache turns on the read-through chunk cache with a byte budget
+// A budget of zero or less disables the cache
+func (s *LocalStorage) EnableCache(maxBytes int64) {
+	if maxBytes <= 0 {
+		s.cache = nil
+		return
+	}
+	s.cache = NewChunkCache(maxBytes)
+}
+
+// Cache returns the chunk cache (nil if disabled)
+func (s *LocalStorage) Cache() *ChunkCache {
+	return s.cache
+}
+
+// localCacheKey is the cache key of a locally stored chunk
+func localCacheKey(userAddr string, chunkID int) string {
+	return fmt.Sprintf("%s%d", localCacheKeyPrefix(userAddr), chunkID)
+}
+
+// localCacheKeyPrefix is the cache key prefix shared by all of a user's local chunks
+func localCacheKeyPrefix(userAddr string) string {
+	return "local:" + userAddr + ":"
+}
+
 // Close closes the database connection
 func (s *LocalStorage) Close() error {
 	if s.db != nil {
@@ -305,7 +439,7 @@ func (s *LocalStorage) Close() error {
 	return nil
 }
 
-// Path returns the database file path
+// Path returns the database file path (or the redacted DSN for PostgreSQL)
 func (s *LocalStorage) Path() string {
 	return s.path
 }
@@ -317,11 +451,15 @@ func (s *LocalStorage) Cleanup(maxAge int64) (int, error) {
 
 	query := `DELETE FROM chunks WHERE stored_at < ?`
 
-	result, err := s.db.Exec(query, cutoff)
+	result, err := s.exec(query, cutoff)
 	if err != nil {
 		return 0, fmt.Errorf("failed to cleanup old chunks: %w", err)
 	}
 
+	if s.cache != nil {
+		s.cache.Clear() // Expired keys are not known here; start cold
+	}
+
 	rows, err := result.RowsAffected()
 	if err != nil {
 		return 0, fmt.Errorf("failed to get affected rows: %w", err)
@@ -330,11 +468,40 @@ func (s *LocalStorage) Cleanup(maxAge int64) (int, error) {
 	return int(rows), nil
 }
 
+// ChunkKey identifies a stored chunk without its data
+type ChunkKey struct {
+	UserAddr string
+	ChunkID  int
+}
+
+// ListChunkKeysSince returns the keys of chunks stored at or after since (Unix seconds)
+// Pass 0 to list every chunk; data is not loaded, so this is cheap for inventories
+func (s *LocalStorage) ListChunkKeysSince(since int64) ([]ChunkKey, error) {
+	query := `SELECT user_addr, chunk_id FROM chunks WHERE stored_at >= ?`
+
+	rows, err := s.query(query, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chunk keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []ChunkKey
+	for rows.Next() {
+		var key ChunkKey
+		if err := rows.Scan(&key.UserAddr, &key.ChunkID); err != nil {
+			return nil, fmt.Errorf("failed to scan chunk key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
 // ListAllChunks returns all chunks from all users
 func (s *LocalStorage) ListAllChunks() ([]Chunk, error) {
-	query := `SELECT user_addr, chunk_id, data, stored_at, size FROM chunks ORDER BY stored_at DESC`
+	query := `SELECT user_addr, chunk_id, data, stored_at, size, lease_expires FROM chunks ORDER BY stored_at DESC`
 
-	rows, err := s.db.Query(query)
+	rows, err := s.query(query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query all chunks: %w", err)
 	}
@@ -343,11 +510,12 @@ func (s *LocalStorage) ListAllChunks() ([]Chunk, error) {
 	var chunks []Chunk
 	for rows.Next() {
 		var chunk Chunk
-		var storedAt int64
-		if err := rows.Scan(&chunk.UserAddr, &chunk.ChunkID, &chunk.Data, &storedAt, &chunk.Size); err != nil {
+		var storedAt, leaseExpires int64
+		if err := rows.Scan(&chunk.UserAddr, &chunk.ChunkID, &chunk.Data, &storedAt, &chunk.Size, &leaseExpires); err != nil {
 			return nil, fmt.Errorf("failed to scan chunk: %w", err)
 		}
 		chunk.StoredAt = time.Unix(storedAt, 0)
+		chunk.LeaseExpires = leaseTime(leaseExpires)
 		chunks = append(chunks, chunk)
 	}
 
@@ -51,10 +51,15 @@ func getSupportedVersions() []string {
 // getSupportedFeatures returns optional features this node supports
 func getSupportedFeatures() []string {
 	return []string{
-		"erasure_coding",      // Reed-Solomon 10+5
+		"erasure_coding",      // Reed-Solomon, 10+5 unless configured
 		"signature_auth",      // Cryptographic signatures for deletion
 		"automatic_repair",    // Automatic shard repair
 		"health_monitoring",   // Background health checks
+		"storage_leases",      // Time-limited storage with renewal
+		"shard_records",       // Signed shard locations published in the DHT
+		"shard_checksums",     // Shard transfers carry and verify SHA-256 checksums
+		"storage_quotas",      // Stores over a node or user quota are refused as such
+		"record_namespaces",   // DHT records under /zentalk/manifest, /zentalk/username and /zentalk/bundle
 	}
 }
 
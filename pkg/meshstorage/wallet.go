@@ -0,0 +1,59 @@
+// Package meshstorage provides distributed storage for ZenTalk encrypted chat history
+package meshstorage
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+)
+
+// WalletBindingMessage returns the text addr's wallet signs to vouch for an owner key
+// purpose scopes the binding to one kind of request (e.g. "grant"), so a binding
+// given for one cannot be presented for another.
+func WalletBindingMessage(purpose, addr, publicKeyPEM string) []byte {
+	return []byte(fmt.Sprintf("zentalk-%s-owner|%s|%s", purpose, strings.ToLower(addr), publicKeyPEM))
+}
+
+// SignWalletBinding signs WalletBindingMessage with addr's wallet key
+func SignWalletBinding(purpose, addr, publicKeyPEM string, walletKey *ecdsa.PrivateKey) ([]byte, error) {
+	return signWalletMessage(WalletBindingMessage(purpose, addr, publicKeyPEM), walletKey)
+}
+
+// VerifyWalletBinding checks signature is addr's wallet vouching for publicKeyPEM
+func VerifyWalletBinding(purpose, addr, publicKeyPEM string, signature []byte) error {
+	return verifyWalletMessage(addr, WalletBindingMessage(purpose, addr, publicKeyPEM), signature)
+}
+
+// signWalletMessage signs message as an Ethereum personal message (EIP-191)
+func signWalletMessage(message []byte, walletKey *ecdsa.PrivateKey) ([]byte, error) {
+	signature, err := ethcrypto.Sign(accounts.TextHash(message), walletKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign owner binding: %w", err)
+	}
+	return signature, nil
+}
+
+// verifyWalletMessage checks signature is addr's personal-message signature over message
+func verifyWalletMessage(addr string, message, signature []byte) error {
+	if len(signature) != ethcrypto.SignatureLength {
+		return fmt.Errorf("no wallet signature for %s", addr)
+	}
+
+	// Wallets report the recovery ID as 27/28
+	signature = append([]byte(nil), signature...)
+	if signature[ethcrypto.RecoveryIDOffset] >= 27 {
+		signature[ethcrypto.RecoveryIDOffset] -= 27
+	}
+
+	publicKey, err := ethcrypto.SigToPub(accounts.TextHash(message), signature)
+	if err != nil {
+		return fmt.Errorf("invalid wallet signature: %w", err)
+	}
+	if signer := ethcrypto.PubkeyToAddress(*publicKey); !strings.EqualFold(signer.Hex(), addr) {
+		return fmt.Errorf("wallet signature is from %s, not %s", signer.Hex(), addr)
+	}
+	return nil
+}
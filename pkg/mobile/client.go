@@ -0,0 +1,230 @@
+// Package mobile is a gomobile-friendly facade over the ZenTalk client
+//
+// gomobile bind only exports strings, numbers, bools, byte slices, pointers to
+// structs and interfaces; maps, channels and other slices are dropped from the
+// generated bindings. This package wraps network.Client in those types so iOS
+// and Android apps can embed the Go client directly:
+//
+//	gomobile bind -target=android ./pkg/mobile
+//	gomobile bind -target=ios ./pkg/mobile
+//
+// Addresses, message IDs and group IDs are hex strings and keys are PEM.
+// Incoming traffic is delivered through a Listener implemented by the app.
+package mobile
+
+import (
+	"crypto/rsa"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/network"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+var (
+	ErrUnknownContact = errors.New("unknown contact - call AddContact first")
+	ErrUnknownGroup   = errors.New("unknown group - call CreateGroup or AddGroup first")
+	ErrNoMediaStore   = errors.New("no media store - call SetMediaStore first")
+)
+
+// Client is a ZenTalk client for mobile apps
+type Client struct {
+	inner *network.Client
+
+	listener     Listener
+	connListener ConnectionListener
+	store        MediaStore
+
+	contacts map[protocol.Address]*rsa.PublicKey
+	groups   map[protocol.GroupID]*network.Group
+	relays   []*crypto.RelayInfo // Fixed onion path; empty = discover via the route policy
+
+	mu sync.RWMutex
+}
+
+// GenerateKey generates a new identity key and returns it PEM-encoded
+// Apps store it in the platform keystore and pass it to NewClient.
+func GenerateKey() ([]byte, error) {
+	key, err := crypto.GenerateRSAKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	return crypto.ExportPrivateKeyPEM(key)
+}
+
+// NewClient creates a client for the given address and PEM private key
+func NewClient(address string, privateKeyPEM []byte) (*Client, error) {
+	addr, err := parseAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := crypto.ImportPrivateKeyPEM(privateKeyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+
+	c := &Client{
+		inner:    network.NewClient(key),
+		contacts: make(map[protocol.Address]*rsa.PublicKey),
+		groups:   make(map[protocol.GroupID]*network.Group),
+	}
+	c.inner.Address = addr
+	c.attachCallbacks()
+
+	return c, nil
+}
+
+// Address returns the client's address as hex
+func (c *Client) Address() string {
+	return hex.EncodeToString(c.inner.Address[:])
+}
+
+// PublicKey returns the client's public key as PEM, for sharing with contacts
+func (c *Client) PublicKey() (string, error) {
+	pemData, err := crypto.ExportPublicKeyPEM(c.inner.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	return string(pemData), nil
+}
+
+// Connect connects to a relay (host:port)
+func (c *Client) Connect(relayAddress string) error {
+	return c.inner.ConnectToRelay(relayAddress)
+}
+
+// Disconnect disconnects from the relay
+func (c *Client) Disconnect() error {
+	return c.inner.Disconnect()
+}
+
+// IsConnected reports whether the client is connected to a relay
+func (c *Client) IsConnected() bool {
+	return c.inner.IsConnected()
+}
+
+// SetLowPowerMode switches low-power mode on or off (see network.Client.SetLowPowerMode)
+func (c *Client) SetLowPowerMode(enabled bool) {
+	c.inner.SetLowPowerMode(enabled)
+}
+
+// SetUnmeteredNetwork reports whether the device is on Wi-Fi
+func (c *Client) SetUnmeteredNetwork(unmetered bool) {
+	c.inner.SetUnmeteredNetwork(unmetered)
+}
+
+// AddContact registers a contact's public key so messages can be sent to them
+func (c *Client) AddContact(address, publicKeyPEM string) error {
+	addr, err := parseAddress(address)
+	if err != nil {
+		return err
+	}
+
+	key, err := crypto.ImportPublicKeyPEM([]byte(publicKeyPEM))
+	if err != nil {
+		return fmt.Errorf("invalid public key: %w", err)
+	}
+
+	c.mu.Lock()
+	c.contacts[addr] = key
+	c.mu.Unlock()
+
+	return nil
+}
+
+// RemoveContact forgets a contact
+func (c *Client) RemoveContact(address string) {
+	addr, err := parseAddress(address)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	delete(c.contacts, addr)
+	c.mu.Unlock()
+}
+
+// AddRelay appends a relay to the fixed onion path used for sending
+// Without fixed relays, paths are built from relay discovery.
+func (c *Client) AddRelay(address, publicKeyPEM string) error {
+	addr, err := parseAddress(address)
+	if err != nil {
+		return err
+	}
+
+	key, err := crypto.ImportPublicKeyPEM([]byte(publicKeyPEM))
+	if err != nil {
+		return fmt.Errorf("invalid relay public key: %w", err)
+	}
+
+	c.mu.Lock()
+	c.relays = append(c.relays, &crypto.RelayInfo{Address: addr, PublicKey: key})
+	c.mu.Unlock()
+
+	return nil
+}
+
+// ClearRelays removes all fixed relays
+func (c *Client) ClearRelays() {
+	c.mu.Lock()
+	c.relays = nil
+	c.mu.Unlock()
+}
+
+// contact returns the address and public key of a registered contact
+func (c *Client) contact(address string) (protocol.Address, *rsa.PublicKey, error) {
+	addr, err := parseAddress(address)
+	if err != nil {
+		return protocol.Address{}, nil, err
+	}
+
+	c.mu.RLock()
+	key, ok := c.contacts[addr]
+	c.mu.RUnlock()
+
+	if !ok {
+		return protocol.Address{}, nil, ErrUnknownContact
+	}
+	return addr, key, nil
+}
+
+// relayPath returns the fixed relays, or builds a path from the route policy
+func (c *Client) relayPath() ([]*crypto.RelayInfo, error) {
+	c.mu.RLock()
+	path := append([]*crypto.RelayInfo(nil), c.relays...)
+	c.mu.RUnlock()
+
+	if len(path) > 0 {
+		return path, nil
+	}
+	return c.inner.BuildPolicyRelayPath()
+}
+
+// parseAddress parses a 20-byte hex address, with or without 0x prefix
+func parseAddress(s string) (protocol.Address, error) {
+	var addr protocol.Address
+
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil || len(b) != len(addr) {
+		return addr, fmt.Errorf("invalid address %q: want 20 bytes of hex", s)
+	}
+
+	copy(addr[:], b)
+	return addr, nil
+}
+
+// parseID parses a hex message or group ID into dst
+func parseID(s string, dst []byte) error {
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil || len(b) != len(dst) {
+		return fmt.Errorf("invalid ID %q: want %d bytes of hex", s, len(dst))
+	}
+
+	copy(dst, b)
+	return nil
+}
@@ -0,0 +1,204 @@
+package mobile
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/ZentaChain/zentalk-node/pkg/network"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+const (
+	aliceAddr = "0x0101010101010101010101010101010101010101"
+	bobAddr   = "0202020202020202020202020202020202020202"
+)
+
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(aliceAddr, key)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	return c
+}
+
+func TestNewClient(t *testing.T) {
+	c := newTestClient(t)
+
+	if got := c.Address(); got != aliceAddr[2:] {
+		t.Errorf("Address() = %s, want %s", got, aliceAddr[2:])
+	}
+	if _, err := c.PublicKey(); err != nil {
+		t.Errorf("PublicKey() error = %v", err)
+	}
+	if c.IsConnected() {
+		t.Error("IsConnected() = true before Connect")
+	}
+
+	if _, err := NewClient("0xabc", nil); err == nil {
+		t.Error("NewClient(short address) expected error, got nil")
+	}
+}
+
+func TestContactsAndGroups(t *testing.T) {
+	c := newTestClient(t)
+	pub, err := c.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.SendText(bobAddr, "hi"); !errors.Is(err, ErrUnknownContact) {
+		t.Errorf("SendText(unknown contact) error = %v, want ErrUnknownContact", err)
+	}
+	if err := c.AddContact(bobAddr, "not a key"); err == nil {
+		t.Error("AddContact(bad key) expected error, got nil")
+	}
+	if err := c.AddContact(bobAddr, pub); err != nil {
+		t.Fatalf("AddContact() error = %v", err)
+	}
+
+	members := NewAddressList()
+	members.Add(bobAddr)
+	members.Add("0303030303030303030303030303030303030303")
+	if members.Len() != 2 || members.Get(0) != bobAddr || members.Get(5) != "" {
+		t.Errorf("AddressList = %v", members.items)
+	}
+
+	groupID := hex.EncodeToString(bytes.Repeat([]byte{7}, 32))
+	if err := c.AddGroup(groupID, "friends", members); !errors.Is(err, ErrUnknownContact) {
+		t.Errorf("AddGroup(unknown member) error = %v, want ErrUnknownContact", err)
+	}
+
+	members = NewAddressList()
+	members.Add(bobAddr)
+	if err := c.AddGroup(groupID, "friends", members); err != nil {
+		t.Fatalf("AddGroup() error = %v", err)
+	}
+	group, err := c.group(groupID)
+	if err != nil || len(group.Members) != 1 {
+		t.Errorf("group() = %+v, %v", group, err)
+	}
+
+	if _, err := c.SendGroupText(hex.EncodeToString(make([]byte, 32)), "hi"); !errors.Is(err, ErrUnknownGroup) {
+		t.Errorf("SendGroupText(unknown group) error = %v, want ErrUnknownGroup", err)
+	}
+}
+
+type recordingListener struct {
+	messages []*Message
+	group    []*GroupMessage
+}
+
+func (l *recordingListener) OnMessage(msg *Message)           { l.messages = append(l.messages, msg) }
+func (l *recordingListener) OnGroupMessage(msg *GroupMessage) { l.group = append(l.group, msg) }
+func (l *recordingListener) OnTyping(string, bool)            {}
+func (l *recordingListener) OnPresence(string, int)           {}
+
+func TestListener(t *testing.T) {
+	c := newTestClient(t)
+	listener := &recordingListener{}
+	c.SetListener(listener)
+
+	from := protocol.Address{2}
+	c.inner.OnMessageReceived(&protocol.DirectMessage{
+		From:        from,
+		Timestamp:   1700000000000,
+		ContentType: protocol.ContentTypeText,
+		Content:     []byte("hello"),
+	})
+	c.inner.OnGroupMessageReceived(&protocol.GroupMessage{
+		From:         from,
+		GroupID:      protocol.GroupID{9},
+		Content:      []byte("hi all"),
+		MessageID:    protocol.GenerateMessageID(),
+		ThreadParent: protocol.MessageID{1},
+	})
+
+	if len(listener.messages) != 1 || listener.messages[0].Text != "hello" || listener.messages[0].Timestamp != 1700000000000 {
+		t.Errorf("OnMessage got %+v", listener.messages)
+	}
+	if len(listener.group) != 1 || listener.group[0].Text != "hi all" || listener.group[0].ThreadParent == "" {
+		t.Errorf("OnGroupMessage got %+v", listener.group)
+	}
+
+	// No listener: callbacks are dropped
+	c.SetListener(nil)
+	c.inner.OnMessageReceived(&protocol.DirectMessage{From: from})
+}
+
+type memoryStore struct {
+	chunks map[int64][]byte
+}
+
+func (s *memoryStore) Upload(data []byte) (*StoredChunk, error) {
+	id := int64(len(s.chunks) + 1)
+	s.chunks[id] = data
+	return &StoredChunk{ChunkID: id, Key: make([]byte, 32)}, nil
+}
+
+func (s *memoryStore) Download(chunkID int64, key []byte) ([]byte, error) {
+	data, ok := s.chunks[chunkID]
+	if !ok {
+		return nil, errors.New("no such chunk")
+	}
+	return data, nil
+}
+
+func TestDownloadMedia(t *testing.T) {
+	c := newTestClient(t)
+	msg := &Message{ContentType: ContentTypeImage}
+
+	if _, err := c.DownloadMedia(msg); !errors.Is(err, ErrNoMediaStore) {
+		t.Errorf("DownloadMedia() without store error = %v, want ErrNoMediaStore", err)
+	}
+
+	store := &memoryStore{chunks: make(map[int64][]byte)}
+	c.SetMediaStore(store)
+
+	adapter, _ := c.mediaStore()
+	chunkID, key, err := adapter.UploadEncrypted([]byte("picture"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Media message content: [ChunkID 8][Key 32]
+	content := make([]byte, 40)
+	content[7] = byte(chunkID)
+	copy(content[8:], key)
+	msg.Content = content
+
+	data, err := c.DownloadMedia(msg)
+	if err != nil || string(data) != "picture" {
+		t.Errorf("DownloadMedia() = %q, %v", data, err)
+	}
+}
+
+type qualityListener struct {
+	qualities []int
+}
+
+func (l *qualityListener) OnConnectionQuality(quality int) {
+	l.qualities = append(l.qualities, quality)
+}
+
+func TestConnectionListener(t *testing.T) {
+	c := newTestClient(t)
+	listener := &qualityListener{}
+	c.SetConnectionListener(listener)
+
+	c.inner.OnConnectionQualityChanged(network.ConnectionFair)
+	if len(listener.qualities) != 1 || listener.qualities[0] != ConnectionFair {
+		t.Errorf("OnConnectionQuality got %v", listener.qualities)
+	}
+
+	if diag := c.Diagnostics(); diag.Connected || diag.Quality != ConnectionOffline || diag.RelayRTTMs != 0 {
+		t.Errorf("Diagnostics() before connecting = %+v", diag)
+	}
+}
@@ -0,0 +1,70 @@
+package mobile
+
+import "github.com/ZentaChain/zentalk-node/pkg/network"
+
+// Connection qualities (see network.Connection*)
+const (
+	ConnectionOffline = int(network.ConnectionOffline)
+	ConnectionPoor    = int(network.ConnectionPoor)
+	ConnectionFair    = int(network.ConnectionFair)
+	ConnectionGood    = int(network.ConnectionGood)
+)
+
+// ConnectionListener is told when the relay connection gets better or worse
+// Apps use it for a connection indicator and call Diagnostics for details.
+type ConnectionListener interface {
+	OnConnectionQuality(quality int)
+}
+
+// Diagnostics is a snapshot of the relay connection's health (see network.ConnectionDiagnostics)
+type Diagnostics struct {
+	Connected        bool
+	Reconnecting     bool
+	RelayAddress     string
+	Quality          int   // Connection*
+	RelayRTTMs       int64 // 0 = not measured yet
+	LastAckLatencyMs int64 // 0 = no message acknowledged yet
+
+	UnackedMessages int
+	BatchedControl  int
+	DeferredMedia   int
+	OfflineBacklog  int
+
+	RatchetSessions     int
+	MessageDBBytes      int64
+	SessionStorageBytes int64
+}
+
+// Diagnostics returns the relay connection's current health
+func (c *Client) Diagnostics() *Diagnostics {
+	diag := c.inner.Diagnostics()
+	return &Diagnostics{
+		Connected:           diag.Connected,
+		Reconnecting:        diag.Reconnecting,
+		RelayAddress:        diag.RelayAddress,
+		Quality:             int(diag.Quality),
+		RelayRTTMs:          diag.RelayRTT.Milliseconds(),
+		LastAckLatencyMs:    diag.LastAckLatency.Milliseconds(),
+		UnackedMessages:     diag.UnackedMessages,
+		BatchedControl:      diag.BatchedControl,
+		DeferredMedia:       diag.DeferredMedia,
+		OfflineBacklog:      diag.OfflineBacklog,
+		RatchetSessions:     diag.RatchetSessions,
+		MessageDBBytes:      diag.MessageDBBytes,
+		SessionStorageBytes: diag.SessionStorageBytes,
+	}
+}
+
+// SetConnectionListener sets the listener for connection quality changes (nil stops them)
+func (c *Client) SetConnectionListener(listener ConnectionListener) {
+	c.mu.Lock()
+	c.connListener = listener
+	c.mu.Unlock()
+}
+
+// getConnectionListener returns the current connection listener
+func (c *Client) getConnectionListener() ConnectionListener {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.connListener
+}
@@ -0,0 +1,205 @@
+package mobile
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/ZentaChain/zentalk-node/pkg/network"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// AddressList is a list of hex addresses
+// gomobile can't bind []string, so group members are passed this way.
+type AddressList struct {
+	items []string
+}
+
+// NewAddressList creates an empty address list
+func NewAddressList() *AddressList {
+	return &AddressList{}
+}
+
+// Add appends an address
+func (l *AddressList) Add(address string) {
+	l.items = append(l.items, address)
+}
+
+// Len returns the number of addresses
+func (l *AddressList) Len() int {
+	return len(l.items)
+}
+
+// Get returns the address at index i ("" if out of range)
+func (l *AddressList) Get(i int) string {
+	if i < 0 || i >= len(l.items) {
+		return ""
+	}
+	return l.items[i]
+}
+
+// GroupMessage is a received group message
+type GroupMessage struct {
+	GroupID      string
+	From         string
+	MessageID    string
+	ThreadParent string // Empty for top-level messages
+	Timestamp    int64  // Unix milliseconds
+	Text         string
+}
+
+// CreateGroup creates a group of contacts, notifies them and returns the group ID
+func (c *Client) CreateGroup(name string, members *AddressList) (string, error) {
+	var groupID protocol.GroupID
+	if _, err := rand.Read(groupID[:]); err != nil {
+		return "", fmt.Errorf("failed to generate group ID: %w", err)
+	}
+
+	group, err := c.newGroup(groupID, name, members)
+	if err != nil {
+		return "", err
+	}
+
+	path, err := c.relayPath()
+	if err != nil {
+		return "", err
+	}
+
+	if err := c.inner.CreateGroup(groupID, name, group.Members, path); err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	c.groups[groupID] = group
+	c.mu.Unlock()
+
+	return hex.EncodeToString(groupID[:]), nil
+}
+
+// AddGroup registers a group created by someone else
+func (c *Client) AddGroup(groupID, name string, members *AddressList) error {
+	var id protocol.GroupID
+	if err := parseID(groupID, id[:]); err != nil {
+		return err
+	}
+
+	group, err := c.newGroup(id, name, members)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.groups[id] = group
+	c.mu.Unlock()
+
+	return nil
+}
+
+// SendGroupText sends a text message to a group and returns its message ID
+func (c *Client) SendGroupText(groupID, text string) (string, error) {
+	return c.sendGroupText(groupID, "", text)
+}
+
+// ReplyInThread sends a group text message in the thread of parentID
+func (c *Client) ReplyInThread(groupID, parentID, text string) (string, error) {
+	return c.sendGroupText(groupID, parentID, text)
+}
+
+// LeaveGroup leaves a group and notifies its members
+func (c *Client) LeaveGroup(groupID string) error {
+	group, err := c.group(groupID)
+	if err != nil {
+		return err
+	}
+
+	path, err := c.relayPath()
+	if err != nil {
+		return err
+	}
+
+	if err := c.inner.LeaveGroup(group.ID, group.Members, path); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	delete(c.groups, group.ID)
+	c.mu.Unlock()
+
+	return nil
+}
+
+// sendGroupText sends a group text message, optionally as a thread reply
+func (c *Client) sendGroupText(groupID, parentID, text string) (string, error) {
+	group, err := c.group(groupID)
+	if err != nil {
+		return "", err
+	}
+
+	opts := &network.GroupMessageOptions{}
+	if parentID != "" {
+		if err := parseID(parentID, opts.ThreadParent[:]); err != nil {
+			return "", err
+		}
+	}
+
+	path, err := c.relayPath()
+	if err != nil {
+		return "", err
+	}
+
+	id, err := c.inner.SendGroupMessageWithOptions(group, text, opts, path)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id[:]), nil
+}
+
+// group returns a registered group
+func (c *Client) group(groupID string) (*network.Group, error) {
+	var id protocol.GroupID
+	if err := parseID(groupID, id[:]); err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	group, ok := c.groups[id]
+	c.mu.RUnlock()
+
+	if !ok {
+		return nil, ErrUnknownGroup
+	}
+	return group, nil
+}
+
+// newGroup builds a group from contacts; every member must be a known contact
+func (c *Client) newGroup(id protocol.GroupID, name string, members *AddressList) (*network.Group, error) {
+	group := &network.Group{ID: id, Name: name}
+	if members == nil {
+		return group, nil
+	}
+
+	for _, address := range members.items {
+		addr, key, err := c.contact(address)
+		if err != nil {
+			return nil, fmt.Errorf("group member %s: %w", address, err)
+		}
+		group.Members = append(group.Members, &network.GroupMember{Address: addr, PublicKey: key})
+	}
+
+	return group, nil
+}
+
+// newGroupMessage converts a group message for the bindings
+func newGroupMessage(msg *protocol.GroupMessage) *GroupMessage {
+	m := &GroupMessage{
+		GroupID:   hex.EncodeToString(msg.GroupID[:]),
+		From:      hex.EncodeToString(msg.From[:]),
+		MessageID: hex.EncodeToString(msg.MessageID[:]),
+		Timestamp: int64(msg.Timestamp),
+		Text:      string(msg.Content),
+	}
+	if msg.ThreadParent != (protocol.MessageID{}) {
+		m.ThreadParent = hex.EncodeToString(msg.ThreadParent[:])
+	}
+	return m
+}
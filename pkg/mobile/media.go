@@ -0,0 +1,154 @@
+package mobile
+
+import (
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/network"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// MediaStore uploads and downloads encrypted media chunks
+// Apps implement it on top of the mesh storage API (or any other store).
+type MediaStore interface {
+	Upload(data []byte) (*StoredChunk, error)
+	Download(chunkID int64, key []byte) ([]byte, error)
+}
+
+// StoredChunk identifies an uploaded chunk and the key it was encrypted with
+type StoredChunk struct {
+	ChunkID int64
+	Key     []byte
+}
+
+// MediaCallback receives the result of FetchMedia
+type MediaCallback interface {
+	OnMedia(data []byte)
+	OnError(message string)
+}
+
+// mediaStoreAdapter adapts a MediaStore to network's uploader/downloader interfaces
+type mediaStoreAdapter struct {
+	store MediaStore
+}
+
+// UploadEncrypted implements network.MeshStorageUploader
+func (a mediaStoreAdapter) UploadEncrypted(data []byte) (uint64, []byte, error) {
+	chunk, err := a.store.Upload(data)
+	if err != nil {
+		return 0, nil, err
+	}
+	return uint64(chunk.ChunkID), chunk.Key, nil
+}
+
+// DownloadEncrypted implements network.MeshStorageDownloader
+func (a mediaStoreAdapter) DownloadEncrypted(chunkID uint64, key []byte) ([]byte, error) {
+	return a.store.Download(int64(chunkID), key)
+}
+
+// SetMediaStore sets the store used for sending and fetching media
+func (c *Client) SetMediaStore(store MediaStore) {
+	c.mu.Lock()
+	c.store = store
+	c.mu.Unlock()
+}
+
+// SendMedia uploads data and sends it to a contact as an image, video, audio or file message
+func (c *Client) SendMedia(to string, data []byte, contentType int) error {
+	store, err := c.mediaStore()
+	if err != nil {
+		return err
+	}
+
+	addr, key, err := c.contact(to)
+	if err != nil {
+		return err
+	}
+
+	path, err := c.relayPath()
+	if err != nil {
+		return err
+	}
+
+	_, _, err = c.inner.SendMediaMessage(addr, key, data, uint8(contentType), store, path)
+	return err
+}
+
+// SendVoiceNote uploads audio and sends it to a contact as a voice note
+func (c *Client) SendVoiceNote(to string, audio []byte, mimeType string, durationMs int64) error {
+	store, err := c.mediaStore()
+	if err != nil {
+		return err
+	}
+
+	addr, key, err := c.contact(to)
+	if err != nil {
+		return err
+	}
+
+	path, err := c.relayPath()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.inner.SendVoiceNote(addr, key, audio, mimeType, time.Duration(durationMs)*time.Millisecond, nil, store, path)
+	return err
+}
+
+// FetchMedia downloads the media referenced by a received message
+// The callback runs on a background goroutine. In low-power mode the download
+// waits for Wi-Fi; FetchMedia returns false when it was deferred.
+func (c *Client) FetchMedia(msg *Message, callback MediaCallback) bool {
+	return c.inner.ScheduleMediaDownload(func() {
+		data, err := c.DownloadMedia(msg)
+		if err != nil {
+			callback.OnError(err.Error())
+			return
+		}
+		callback.OnMedia(data)
+	})
+}
+
+// DownloadMedia downloads the media referenced by a received message now
+func (c *Client) DownloadMedia(msg *Message) ([]byte, error) {
+	adapter, err := c.mediaStore()
+	if err != nil {
+		return nil, err
+	}
+
+	// Count the download towards the sender's conversation
+	var store network.MeshStorageDownloader = adapter
+	if conversation, err := c.conversationID(msg.From); err == nil {
+		store = c.inner.MeterMediaDownloads(conversation, adapter)
+	}
+
+	switch uint8(msg.ContentType) {
+	case protocol.ContentTypeVoiceNote:
+		var note protocol.VoiceNoteMessage
+		if err := note.Decode(msg.Content); err != nil {
+			return nil, err
+		}
+		return network.FetchVoiceNote(&note, store)
+
+	case protocol.ContentTypeSticker:
+		return network.FetchSticker(msg.Content, store)
+
+	default:
+		chunkID, key, err := network.ParseMediaMessage(msg.Content)
+		if err != nil {
+			return nil, err
+		}
+		return store.DownloadEncrypted(chunkID, key)
+	}
+}
+
+// mediaStore returns the configured store wrapped for the network package
+func (c *Client) mediaStore() (mediaStoreAdapter, error) {
+	c.mu.RLock()
+	store := c.store
+	c.mu.RUnlock()
+
+	if store == nil {
+		return mediaStoreAdapter{}, ErrNoMediaStore
+	}
+	return mediaStoreAdapter{store: store}, nil
+}
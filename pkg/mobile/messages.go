@@ -0,0 +1,154 @@
+package mobile
+
+import (
+	"encoding/hex"
+
+	"github.com/ZentaChain/zentalk-node/pkg/network"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// Content types (see protocol.ContentType*)
+const (
+	ContentTypeText      = int(protocol.ContentTypeText)
+	ContentTypeImage     = int(protocol.ContentTypeImage)
+	ContentTypeVideo     = int(protocol.ContentTypeVideo)
+	ContentTypeAudio     = int(protocol.ContentTypeAudio)
+	ContentTypeFile      = int(protocol.ContentTypeFile)
+	ContentTypeSticker   = int(protocol.ContentTypeSticker)
+	ContentTypeVoiceNote = int(protocol.ContentTypeVoiceNote)
+)
+
+// Presence statuses (see protocol.Presence*)
+const (
+	PresenceOffline = int(protocol.PresenceOffline)
+	PresenceOnline  = int(protocol.PresenceOnline)
+	PresenceAway    = int(protocol.PresenceAway)
+	PresenceBusy    = int(protocol.PresenceBusy)
+)
+
+// Listener receives incoming traffic
+// Methods are called from the client's receive goroutine; apps should hand
+// work off to their UI thread rather than block.
+type Listener interface {
+	OnMessage(msg *Message)
+	OnGroupMessage(msg *GroupMessage)
+	OnTyping(from string, typing bool)
+	OnPresence(from string, status int)
+}
+
+// Message is a received direct message
+type Message struct {
+	From        string
+	Timestamp   int64 // Unix milliseconds
+	Sequence    int64
+	ContentType int
+	Text        string // Set for text messages
+	Content     []byte // Raw content; pass media messages to FetchMedia
+}
+
+// SetListener sets the listener for incoming traffic (nil stops delivery)
+func (c *Client) SetListener(listener Listener) {
+	c.mu.Lock()
+	c.listener = listener
+	c.mu.Unlock()
+}
+
+// SendText sends a text message to a contact
+func (c *Client) SendText(to, text string) error {
+	addr, key, err := c.contact(to)
+	if err != nil {
+		return err
+	}
+
+	path, err := c.relayPath()
+	if err != nil {
+		return err
+	}
+
+	return c.inner.SendTextMessage(addr, key, text, path)
+}
+
+// SendTyping tells a contact whether we are typing
+func (c *Client) SendTyping(to string, typing bool) error {
+	addr, key, err := c.contact(to)
+	if err != nil {
+		return err
+	}
+
+	path, err := c.relayPath()
+	if err != nil {
+		return err
+	}
+
+	return c.inner.SendTypingIndicator(addr, key, typing, path)
+}
+
+// SendPresence sends our status (Presence*) to a contact
+func (c *Client) SendPresence(to string, status int) error {
+	addr, key, err := c.contact(to)
+	if err != nil {
+		return err
+	}
+
+	path, err := c.relayPath()
+	if err != nil {
+		return err
+	}
+
+	return c.inner.SendPresence(addr, key, uint8(status), path)
+}
+
+// attachCallbacks routes the inner client's callbacks to the listener
+func (c *Client) attachCallbacks() {
+	c.inner.OnMessageReceived = func(msg *protocol.DirectMessage) {
+		if l := c.getListener(); l != nil {
+			l.OnMessage(newMessage(msg))
+		}
+	}
+
+	c.inner.OnGroupMessageReceived = func(msg *protocol.GroupMessage) {
+		if l := c.getListener(); l != nil {
+			l.OnGroupMessage(newGroupMessage(msg))
+		}
+	}
+
+	c.inner.OnTypingIndicator = func(indicator *protocol.TypingIndicator) {
+		if l := c.getListener(); l != nil {
+			l.OnTyping(hex.EncodeToString(indicator.From[:]), indicator.IsTyping)
+		}
+	}
+
+	c.inner.OnPresence = func(update *protocol.PresenceUpdate) {
+		if l := c.getListener(); l != nil {
+			l.OnPresence(hex.EncodeToString(update.Address[:]), int(update.Status))
+		}
+	}
+
+	c.inner.OnConnectionQualityChanged = func(quality network.ConnectionQuality) {
+		if l := c.getConnectionListener(); l != nil {
+			l.OnConnectionQuality(int(quality))
+		}
+	}
+}
+
+// getListener returns the current listener
+func (c *Client) getListener() Listener {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.listener
+}
+
+// newMessage converts a direct message for the bindings
+func newMessage(msg *protocol.DirectMessage) *Message {
+	m := &Message{
+		From:        hex.EncodeToString(msg.From[:]),
+		Timestamp:   int64(msg.Timestamp),
+		Sequence:    int64(msg.SequenceNumber),
+		ContentType: int(msg.ContentType),
+		Content:     msg.Content,
+	}
+	if msg.ContentType == protocol.ContentTypeText {
+		m.Text = string(msg.Content)
+	}
+	return m
+}
@@ -0,0 +1,80 @@
+package mobile
+
+import (
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+	"github.com/ZentaChain/zentalk-node/pkg/storage"
+)
+
+// BandwidthUsage is traffic in bytes by category (see network.Client.BandwidthUsage)
+type BandwidthUsage struct {
+	MessagesSent     int64
+	MessagesReceived int64
+	MediaSent        int64
+	MediaReceived    int64
+	ControlSent      int64 // Typing, receipts, presence, ACKs, pings and the like
+	ControlReceived  int64
+}
+
+// BandwidthUsage returns all traffic over the last days UTC days (1 = today)
+func (c *Client) BandwidthUsage(days int) (*BandwidthUsage, error) {
+	return c.bandwidthUsage(days, func(string) bool { return true })
+}
+
+// ConversationBandwidthUsage returns the traffic with a contact or in a group over the last days UTC days
+// peer is a contact's address or a group ID.
+func (c *Client) ConversationBandwidthUsage(peer string, days int) (*BandwidthUsage, error) {
+	conversation, err := c.conversationID(peer)
+	if err != nil {
+		return nil, err
+	}
+	return c.bandwidthUsage(days, func(id string) bool { return id == conversation })
+}
+
+// bandwidthUsage sums the usage of the conversations match accepts
+func (c *Client) bandwidthUsage(days int, match func(conversation string) bool) (*BandwidthUsage, error) {
+	if days < 1 {
+		return nil, fmt.Errorf("days must be at least 1, got %d", days)
+	}
+
+	now := time.Now()
+	records, err := c.inner.BandwidthUsage(now.AddDate(0, 0, 1-days), now)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &BandwidthUsage{}
+	for _, r := range records {
+		if !match(r.Conversation) {
+			continue
+		}
+		switch r.Category {
+		case storage.UsageMessages:
+			usage.MessagesSent += r.BytesSent
+			usage.MessagesReceived += r.BytesReceived
+		case storage.UsageMedia:
+			usage.MediaSent += r.BytesSent
+			usage.MediaReceived += r.BytesReceived
+		default:
+			usage.ControlSent += r.BytesSent
+			usage.ControlReceived += r.BytesReceived
+		}
+	}
+	return usage, nil
+}
+
+// conversationID returns the message database conversation ID for a contact address or group ID
+func (c *Client) conversationID(peer string) (string, error) {
+	if addr, err := parseAddress(peer); err == nil {
+		return storage.GetConversationID(hex.EncodeToString(c.inner.Address[:]), hex.EncodeToString(addr[:])), nil
+	}
+
+	var groupID protocol.GroupID
+	if err := parseID(peer, groupID[:]); err != nil {
+		return "", fmt.Errorf("invalid peer %q: want a contact address or group ID", peer)
+	}
+	return storage.GetGroupConversationID(hex.EncodeToString(groupID[:])), nil
+}
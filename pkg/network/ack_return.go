@@ -0,0 +1,58 @@
+package network
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"log"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+	"github.com/ZentaChain/zentalk-node/pkg/storage"
+)
+
+// attachReturnRelay names our relay in msg, so the recipient's relay can route the ACK to it
+// The RSA-OAEP path can only carry so much, so messages the token would push
+// past the recipient key's limit go without; their ACK then only reaches us if
+// the recipient shares our relay.
+func (c *Client) attachReturnRelay(msg *protocol.DirectMessage, recipientPubKey *rsa.PublicKey) {
+	if protocol.IsZeroAddress(c.relayPeer) {
+		return
+	}
+
+	maxPlaintext := recipientPubKey.Size() - 2*sha256.Size - 2
+	msg.SetReturnRelay(c.relayPeer)
+	if len(protocol.TagPayload(protocol.MsgTypeDirectMessage, msg.Encode())) > maxPlaintext {
+		msg.Extensions = msg.Extensions[:len(msg.Extensions)-1]
+	}
+}
+
+// ackReceived marks the message an ACK answers as delivered and reports the ACK
+func (c *Client) ackReceived(ack *protocol.AckMessage) {
+	if !c.isOwnIdentity(ack.To) {
+		log.Printf("Dropping ACK addressed to %x", ack.To[:8])
+		return
+	}
+
+	log.Printf("✓ ACK received from %x (seq: %d)", ack.From[:8], ack.SequenceNumber)
+
+	storedID := c.ackSend(ack.From, ack.SequenceNumber)
+	if storedID != "" && c.messageDB != nil {
+		if err := c.messageDB.UpdateMessageStatus(storedID, storage.MessageStatusDelivered); err != nil {
+			log.Printf("Failed to mark message %s delivered: %v", storedID, err)
+		}
+	}
+
+	// Call application callback
+	if c.OnAckReceived != nil {
+		c.OnAckReceived(ack)
+	}
+}
+
+// handleReturnedAck handles an ACK a relay routed back to us inside a direct message
+func (c *Client) handleReturnedAck(body []byte) {
+	var ack protocol.AckMessage
+	if err := ack.Decode(body); err != nil {
+		log.Printf("Failed to decode returned ACK: %v", err)
+		return
+	}
+	c.ackReceived(&ack)
+}
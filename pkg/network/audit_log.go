@@ -0,0 +1,117 @@
+package network
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+	"github.com/ZentaChain/zentalk-node/pkg/storage"
+)
+
+// EnableAuditLog turns on the signed audit log of sent and received messages
+// Only for deployments where the user has agreed to it. binding is our wallet's
+// signature over AuditBindingMessage, which exports carry to show the log is
+// ours. Profiles created from now on carry ProfileFlagAuditLog, so call
+// UpdateProfile and broadcast it to let contacts know. Records hold addresses,
+// message IDs and times, never content.
+func (c *Client) EnableAuditLog(binding []byte) error {
+	if c.messageDB == nil {
+		return ErrNoMessageDB
+	}
+
+	pubKeyPEM, err := crypto.ExportPublicKeyPEM(c.PublicKey)
+	if err != nil {
+		return err
+	}
+	if err := storage.VerifyAuditBinding(hex.EncodeToString(c.Address[:]), pubKeyPEM, binding); err != nil {
+		return err
+	}
+
+	binding = append([]byte(nil), binding...)
+	c.auditBinding.Store(&binding)
+	c.auditLog.Store(true)
+	log.Printf("📋 Audit log enabled")
+	return nil
+}
+
+// AuditBindingMessage returns the text our wallet signs for EnableAuditLog
+func (c *Client) AuditBindingMessage() ([]byte, error) {
+	pubKeyPEM, err := crypto.ExportPublicKeyPEM(c.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return storage.AuditBindingMessage(hex.EncodeToString(c.Address[:]), pubKeyPEM), nil
+}
+
+// DisableAuditLog stops recording; existing records are kept for export
+func (c *Client) DisableAuditLog() {
+	c.auditLog.Store(false)
+	log.Printf("📋 Audit log disabled")
+}
+
+// AuditLogEnabled returns true if message events are being recorded
+func (c *Client) AuditLogEnabled() bool {
+	return c.auditLog.Load()
+}
+
+// ExportAuditLog returns the records in [since, until) as a JSON AuditExport
+// A zero until exports up to now. The export includes our public key and our
+// wallet's binding to it, so a compliance system can check it with
+// AuditExport.Verify.
+func (c *Client) ExportAuditLog(since, until time.Time) ([]byte, error) {
+	if c.messageDB == nil {
+		return nil, ErrNoMessageDB
+	}
+	binding := c.auditBinding.Load()
+	if binding == nil {
+		return nil, fmt.Errorf("%w: audit log was never enabled", storage.ErrAuditOwner)
+	}
+
+	var untilMs int64
+	if !until.IsZero() {
+		untilMs = until.UnixMilli()
+	}
+
+	records, err := c.messageDB.GetAuditRecords(since.UnixMilli(), untilMs)
+	if err != nil {
+		return nil, err
+	}
+
+	pubKeyPEM, err := crypto.ExportPublicKeyPEM(c.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	export := storage.NewAuditExport(hex.EncodeToString(c.Address[:]), pubKeyPEM, *binding, time.Now().UnixMilli(), records)
+	data, err := json.Marshal(export)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode audit export: %w", err)
+	}
+
+	log.Printf("📋 Exported %d audit records", len(records))
+	return data, nil
+}
+
+// recordAudit appends a message event to the audit log if it is enabled
+// A failed append is logged rather than failing the send or delivery.
+func (c *Client) recordAudit(event storage.AuditEvent, peer protocol.Address, messageID string, contentType uint8) {
+	if !c.auditLog.Load() || c.messageDB == nil {
+		return
+	}
+
+	rec := &storage.AuditRecord{
+		Event:       event,
+		Peer:        hex.EncodeToString(peer[:]),
+		MessageID:   messageID,
+		ContentType: contentType,
+		Timestamp:   time.Now().UnixMilli(),
+	}
+
+	if err := c.messageDB.AppendAuditRecord(rec, c.PrivateKey); err != nil {
+		log.Printf("⚠️  Failed to append audit record: %v", err)
+	}
+}
@@ -0,0 +1,218 @@
+package network
+
+import (
+	"encoding/hex"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+	"github.com/ZentaChain/zentalk-node/pkg/storage"
+)
+
+// usageFlushInterval is how often counted bytes are added to the message database
+const usageFlushInterval = time.Minute
+
+// usageKey attributes traffic to a conversation and category
+// The zero value is control traffic not tied to a conversation.
+type usageKey struct {
+	conversation string
+	category     storage.UsageCategory
+}
+
+// usageEntry is a usageKey on one UTC day
+type usageEntry struct {
+	day string
+	usageKey
+}
+
+// bandwidthUsage counts bytes until they are flushed to the message database
+// Without a database the counts stay in memory for the life of the client.
+type bandwidthUsage struct {
+	mu        sync.Mutex
+	pending   map[usageEntry]*storage.UsageRecord
+	lastFlush time.Time
+}
+
+// directUsage attributes traffic to the conversation with a contact
+func (c *Client) directUsage(peer protocol.Address, category storage.UsageCategory) usageKey {
+	return usageKey{
+		conversation: storage.GetConversationID(hex.EncodeToString(c.Address[:]), hex.EncodeToString(peer[:])),
+		category:     category,
+	}
+}
+
+// groupUsage attributes traffic to a group's conversation
+func groupUsage(groupID protocol.GroupID, category storage.UsageCategory) usageKey {
+	return usageKey{
+		conversation: storage.GetGroupConversationID(hex.EncodeToString(groupID[:])),
+		category:     category,
+	}
+}
+
+// contentUsage returns the category messages of a content type count towards
+func contentUsage(contentType uint8) storage.UsageCategory {
+	switch contentType {
+	case protocol.ContentTypeImage, protocol.ContentTypeVideo, protocol.ContentTypeAudio, protocol.ContentTypeFile,
+		protocol.ContentTypeSticker, protocol.ContentTypeVoiceNote, protocol.ContentTypeGroupFile:
+		return storage.UsageMedia
+	case protocol.ContentTypeReadStateSync, protocol.ContentTypeRecoveryShare, protocol.ContentTypeRecoveryRequest:
+		return storage.UsageControl
+	}
+	return storage.UsageMessages
+}
+
+// recordUsage counts bytes sent and received under key
+func (c *Client) recordUsage(key usageKey, sent, received int) {
+	if sent == 0 && received == 0 {
+		return
+	}
+	if key.category == "" {
+		key.category = storage.UsageControl
+	}
+
+	now := time.Now()
+	entry := usageEntry{day: now.UTC().Format(storage.UsageDayFormat), usageKey: key}
+
+	u := &c.usage
+	u.mu.Lock()
+	if u.pending == nil {
+		u.pending = make(map[usageEntry]*storage.UsageRecord)
+		u.lastFlush = now
+	}
+	record := u.pending[entry]
+	if record == nil {
+		record = &storage.UsageRecord{Day: entry.day, Conversation: key.conversation, Category: key.category}
+		u.pending[entry] = record
+	}
+	record.BytesSent += int64(sent)
+	record.BytesReceived += int64(received)
+	due := now.Sub(u.lastFlush) >= usageFlushInterval
+	u.mu.Unlock()
+
+	if due && c.messageDB != nil {
+		if err := c.flushUsage(); err != nil {
+			log.Printf("⚠️  Failed to save bandwidth usage: %v", err)
+		}
+	}
+}
+
+// flushUsage adds the counted bytes to the message database
+// Counts that fail to save are kept for the next flush.
+func (c *Client) flushUsage() error {
+	u := &c.usage
+	u.mu.Lock()
+	pending := u.pending
+	u.pending = make(map[usageEntry]*storage.UsageRecord)
+	u.lastFlush = time.Now()
+	u.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	records := make([]*storage.UsageRecord, 0, len(pending))
+	for _, record := range pending {
+		records = append(records, record)
+	}
+	err := c.messageDB.AddBandwidthUsage(records)
+	if err == nil {
+		return nil
+	}
+
+	u.mu.Lock()
+	for entry, record := range pending {
+		if current := u.pending[entry]; current != nil {
+			record.BytesSent += current.BytesSent
+			record.BytesReceived += current.BytesReceived
+		}
+		u.pending[entry] = record
+	}
+	u.mu.Unlock()
+	return err
+}
+
+// BandwidthUsage returns the bytes sent and received from one time to another, by day, conversation and category
+// Days are UTC and both ends are inclusive. Conversations are message database
+// conversation IDs (see storage.GetConversationID); traffic not tied to one,
+// like pings and key bundle requests, has an empty conversation. Counts are
+// of frames to and from the relay, plus media moved through MeshStorage by the
+// client or a store from MeterMediaDownloads.
+func (c *Client) BandwidthUsage(from, to time.Time) ([]*storage.UsageRecord, error) {
+	fromDay := from.UTC().Format(storage.UsageDayFormat)
+	toDay := to.UTC().Format(storage.UsageDayFormat)
+
+	if c.messageDB != nil {
+		if err := c.flushUsage(); err != nil {
+			return nil, err
+		}
+		return c.messageDB.GetBandwidthUsage(fromDay, toDay)
+	}
+
+	u := &c.usage
+	u.mu.Lock()
+	var records []*storage.UsageRecord
+	for _, record := range u.pending {
+		if record.Day >= fromDay && record.Day <= toDay {
+			copied := *record
+			records = append(records, &copied)
+		}
+	}
+	u.mu.Unlock()
+
+	sort.Slice(records, func(i, j int) bool {
+		a, b := records[i], records[j]
+		if a.Day != b.Day {
+			return a.Day < b.Day
+		}
+		if a.Conversation != b.Conversation {
+			return a.Conversation < b.Conversation
+		}
+		return a.Category < b.Category
+	})
+	return records, nil
+}
+
+// meteredStore counts MeshStorage transfers as media traffic of a conversation
+type meteredStore struct {
+	c          *Client
+	key        usageKey
+	uploader   MeshStorageUploader   // nil when only downloading
+	downloader MeshStorageDownloader // nil when only uploading
+}
+
+// UploadEncrypted uploads through the wrapped store and counts the bytes sent
+func (m *meteredStore) UploadEncrypted(data []byte) (uint64, []byte, error) {
+	chunkID, key, err := m.uploader.UploadEncrypted(data)
+	if err == nil {
+		m.c.recordUsage(m.key, len(data), 0)
+	}
+	return chunkID, key, err
+}
+
+// DownloadEncrypted downloads through the wrapped store and counts the bytes received
+func (m *meteredStore) DownloadEncrypted(chunkID uint64, key []byte) ([]byte, error) {
+	data, err := m.downloader.DownloadEncrypted(chunkID, key)
+	if err == nil {
+		m.c.recordUsage(m.key, 0, len(data))
+	}
+	return data, err
+}
+
+// MeterMediaDownloads wraps a store so media downloaded through it counts towards a conversation
+// Apps wrap the store they pass to FetchVoiceNote, FetchSticker and the like;
+// the client meters the transfers it makes itself.
+func (c *Client) MeterMediaDownloads(conversation string, store MeshStorageDownloader) MeshStorageDownloader {
+	return &meteredStore{c: c, key: usageKey{conversation: conversation, category: storage.UsageMedia}, downloader: store}
+}
+
+// meterUploads wraps a store so media uploaded through it is counted under key
+func (c *Client) meterUploads(key usageKey, store MeshStorageUploader) MeshStorageUploader {
+	return &meteredStore{c: c, key: key, uploader: store}
+}
+
+// meterTransfers wraps a store so media moved through it is counted under key
+func (c *Client) meterTransfers(key usageKey, store MeshStorageTransfer) MeshStorageTransfer {
+	return &meteredStore{c: c, key: key, uploader: store, downloader: store}
+}
@@ -0,0 +1,96 @@
+package network
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+	"github.com/ZentaChain/zentalk-node/pkg/storage"
+)
+
+// usageTotals sums a client's usage today by conversation and category
+func usageTotals(t *testing.T, c *Client) map[usageKey]storage.UsageRecord {
+	t.Helper()
+
+	records, err := c.BandwidthUsage(time.Now(), time.Now())
+	if err != nil {
+		t.Fatalf("BandwidthUsage() error = %v", err)
+	}
+	totals := make(map[usageKey]storage.UsageRecord)
+	for _, r := range records {
+		totals[usageKey{r.Conversation, r.Category}] = *r
+	}
+	return totals
+}
+
+func TestBandwidthUsage(t *testing.T) {
+	c := newForwardTestClient(t)
+	peer := protocol.Address{2}
+	chat := c.directUsage(peer, storage.UsageMessages)
+
+	clientSide, relaySide := net.Pipe()
+	defer relaySide.Close()
+	c.relayConn = clientSide
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			if _, err := relaySide.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	header := &protocol.Header{Magic: protocol.ProtocolMagic, Version: protocol.ProtocolVersion, Type: protocol.MsgTypeRelayForward, Length: 100}
+	if err := c.writeFrameAs(t.Context(), chat, header, make([]byte, 100)); err != nil {
+		t.Fatal(err)
+	}
+	header.Type, header.Length = protocol.MsgTypePing, 0
+	if err := c.writeFrame(t.Context(), header, nil); err != nil {
+		t.Fatal(err)
+	}
+	c.recordUsage(chat, 0, 40)
+
+	// Media moved through the client's stores counts as media
+	store := newMemStore()
+	chunkID, key, _ := c.meterTransfers(c.directUsage(peer, storage.UsageMedia), store).UploadEncrypted([]byte("picture"))
+	c.MeterMediaDownloads(chat.conversation, store).DownloadEncrypted(chunkID, key)
+
+	totals := usageTotals(t, c)
+	if got := totals[chat]; got.BytesSent != protocol.HeaderSize+100 || got.BytesReceived != 40 {
+		t.Errorf("messages to %x = %+v", peer[:4], got)
+	}
+	if got := totals[usageKey{category: storage.UsageControl}]; got.BytesSent != protocol.HeaderSize {
+		t.Errorf("control = %+v, want the ping", got)
+	}
+	if got := totals[c.directUsage(peer, storage.UsageMedia)]; got.BytesSent != 7 || got.BytesReceived != 7 {
+		t.Errorf("media = %+v, want 7 bytes each way", got)
+	}
+
+	// Counts saved to the database keep adding up
+	c.recordUsage(chat, 10, 0)
+	if got := usageTotals(t, c)[chat]; got.BytesSent != protocol.HeaderSize+110 {
+		t.Errorf("messages sent after a second flush = %d", got.BytesSent)
+	}
+}
+
+func TestBandwidthUsageWithoutDatabase(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(key)
+	group := groupUsage(protocol.GroupID{7}, contentUsage(protocol.ContentTypeVoiceNote))
+
+	c.recordUsage(group, 0, 500)
+	c.recordUsage(group, 0, 500)
+
+	if got := usageTotals(t, c)[group]; got.BytesReceived != 1000 || got.Category != storage.UsageMedia {
+		t.Errorf("group usage = %+v, want 1000 media bytes received", got)
+	}
+	if records, _ := c.BandwidthUsage(time.Now().AddDate(0, 0, -3), time.Now().AddDate(0, 0, -1)); len(records) != 0 {
+		t.Errorf("usage before today = %+v", records)
+	}
+}
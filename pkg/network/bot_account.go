@@ -0,0 +1,48 @@
+package network
+
+import (
+	"errors"
+	"log"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+var ErrBotPresence = errors.New("presence is not shared with bot accounts")
+
+// EnableBotMode runs this client as a bot account
+// The API key is derived from our identity key with label; the relay operator
+// registers the same key (see the admin bot-key command). Bots are flagged in
+// profiles from now on, rate-limited as bots by relays, and can neither send
+// nor receive presence. Takes effect on the next connection.
+func (c *Client) EnableBotMode(label string) error {
+	apiKey, err := crypto.DeriveBotAPIKey(c.PrivateKey, label)
+	if err != nil {
+		return err
+	}
+	c.botAPIKey = apiKey
+	log.Printf("🤖 Bot mode enabled")
+	return nil
+}
+
+// IsBot returns true if this client runs as a bot account
+func (c *Client) IsBot() bool {
+	return c.botAPIKey != nil
+}
+
+// notePeerBot remembers whether a contact's latest profile is a bot's
+func (c *Client) notePeerBot(profile *protocol.ProfileUpdate) {
+	if profile.IsBot() {
+		if _, known := c.botPeers.Swap(profile.Address, true); !known {
+			log.Printf("🤖 %x is a bot", profile.Address[:8])
+		}
+		return
+	}
+	c.botPeers.Delete(profile.Address)
+}
+
+// isBotPeer returns true if the contact's profile says they are a bot
+func (c *Client) isBotPeer(addr protocol.Address) bool {
+	_, ok := c.botPeers.Load(addr)
+	return ok
+}
@@ -1,18 +1,24 @@
 package network
 
 import (
+	"context"
 	"crypto/rsa"
+	"crypto/tls"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ZentaChain/zentalk-node/pkg/crypto"
 	"github.com/ZentaChain/zentalk-node/pkg/dht"
 	"github.com/ZentaChain/zentalk-node/pkg/protocol"
 	"github.com/ZentaChain/zentalk-node/pkg/storage"
+	"github.com/ZentaChain/zentalk-node/pkg/transport"
 )
 
 var (
@@ -27,11 +33,53 @@ type Client struct {
 	PublicKey    *rsa.PublicKey
 	relayConn    net.Conn
 	relayAddress string
-	connected    bool
+	connected    atomic.Bool
+
+	// Serializes frames written to relayConn, and swapping relayConn on reconnect
+	writeMu sync.Mutex
+
+	// Payload limits negotiated with the relay in the handshake (nil = protocol defaults)
+	payloadLimits *protocol.PayloadLimits
+
+	// What the relay accepts from users, from its handshake ack (nil = no policy)
+	relayPolicy   *protocol.RelayPolicy
+	relayPeer     protocol.Address     // The relay's protocol address, from the same ack
+	relayFeatures protocol.FeatureList // Features the relay has on, from the same ack
+
+	// Uniform-records wire mode requested in the handshake (nil = plain frames)
+	uniformRecords *UniformRecordConfig
+
+	// TLS started before every handshake (nil = plain TCP)
+	tlsConfig *tls.Config
+
+	// IP version used to reach relays (empty = dual)
+	addressFamily AddressFamily
+
+	// Tenant named in the handshake on multi-tenant relays (empty = none)
+	tenant string
+
+	// Bot account: API key proven in handshakes (nil = normal user)
+	botAPIKey []byte
+
+	// Contacts whose profile says they are bots (address -> true)
+	botPeers sync.Map
+
+	// Session resumption: ticket from the relay and how far queued delivery got
+	resumeTicket   *protocol.SessionTicket
+	ticketDeadline time.Time     // Zero while connected; set when the connection drops
+	queueCursor    uint64        // Sequence of the last queued message received
+	queueWindow    atomic.Uint32 // Queued messages granted per window (0 = protocol.DefaultQueueWindow)
+
+	// Power mode: keepalive interval, control batching and deferred media
+	power *powerState
 
 	// Message persistence
 	messageDB *storage.MessageDB
 
+	// Signed audit log of message events (opt-in, announced in our profile)
+	auditLog     atomic.Bool
+	auditBinding atomic.Pointer[[]byte] // Wallet signature vouching for our key in exports
+
 	// Session persistence (X3DH & ratchet state)
 	sessionStorage *SessionStorage
 
@@ -44,28 +92,100 @@ type Client struct {
 	// Guard relays (persistent entry nodes for privacy)
 	guardRelayManager *GuardRelayManager
 
+	// Onion route policy (hop count range, guard usage)
+	routePolicy *RoutePolicy
+
+	// Content type validation and per-type delivery handlers
+	contentTypes    *protocol.ContentTypeRegistry
+	contentHandlers map[uint8]ContentHandler
+	contentMu       sync.RWMutex // Guards contentTypes and contentHandlers
+
+	// Spam/scam filters run before delivery
+	messageFilters   []MessageFilter
+	messageFiltersMu sync.RWMutex
+
+	// Installed sticker packs (loaded lazily from session storage)
+	stickerPacks map[protocol.StickerPackID]*protocol.StickerPackManifest
+
+	// Social recovery: shares held for contacts and any recovery in progress
+	socialRecovery *socialRecovery
+
+	// Linked devices and the read/mute state synced between them
+	readStateSync *readStateSync
+
+	// Users' devices, for fanning messages out, and fan-out IDs already delivered
+	devices *deviceRegistry
+
 	// X3DH & Double Ratchet (Forward Secrecy)
+	// sessionMu guards the X3DH keys, ratchet sessions and init keys; ratchet
+	// states are only advanced, and ratchet messages written, while it is held.
+	// keyBundleMu guards keyBundleCache.
+	sessionMu      sync.Mutex
+	keyBundleMu    sync.RWMutex
 	x3dhIdentity   *protocol.IdentityKeyPair                   // Our X3DH identity
 	signedPreKey   *protocol.SignedPreKeyPrivate               // Our current signed prekey
 	oneTimePreKeys map[uint32]*protocol.OneTimePreKeyPrivate   // Pool of one-time prekeys
 	ratchetSessions  map[protocol.Address]*protocol.RatchetState // Active ratchet sessions
 	keyBundleCache map[protocol.Address]*protocol.KeyBundle    // Cached key bundles
+	cipherSuites   []protocol.CipherSuite                       // Ratchet AEADs we accept, preferred first (nil = protocol.PreferredCipherSuites)
 	registrationID uint32                                       // Unique registration ID
+	lastPreKeyID   uint32                                       // Highest one-time prekey ID generated, so IDs are never reused
+
+	// Ratchet messages kept for resending after a decryption NACK, and the
+	// ephemeral key of each peer's latest X3DH initial message
+	ratchetOutbox   ratchetOutbox
+	ratchetInitKeys map[protocol.Address][32]byte
+
+	// Key bundle requests awaiting the relay's response, by message ID
+	keyBundleWaitMu  sync.Mutex
+	keyBundleWaiters map[protocol.MessageID]chan *protocol.KeyBundleResponse
+	replenishing     atomic.Bool // One-time prekeys are being generated and republished
+
+	// Media directory requests awaiting the relay's answer, and the last answer
+	mediaDirMu      sync.Mutex
+	mediaDirWaiters map[protocol.MessageID]chan *protocol.MediaDirectory
+	mediaDirectory  *protocol.MediaDirectory
+
+	// Offline sync: pull the queue instead of having it pushed, and the
+	// requests awaiting a page, by message ID
+	offlineSync        atomic.Bool
+	offlineSyncMu      sync.Mutex
+	offlineSyncWaiters map[protocol.MessageID]chan offlineSyncResult
 
 	// Message ordering and reliability
+	// seqMu guards sendSequenceNumbers; orderingMu guards the receive-side maps
+	seqMu                  sync.Mutex
+	orderingMu             sync.Mutex
 	sendSequenceNumbers    map[protocol.Address]uint64                    // Next sequence number to send per peer
 	receiveSequenceNumbers map[protocol.Address]uint64                    // Next expected sequence number per peer
 	messageBuffer          map[protocol.Address]map[uint64]*protocol.DirectMessage // Out-of-order message buffer
 	receivedMessageIDs     map[protocol.Address]map[uint64]bool           // Deduplication tracking
 
+	// Sent messages awaiting their ACK, retransmitted until it arrives
+	sendQueue sendQueue
+
+	// Round trips, ACK latency and link state reported by Diagnostics
+	health connectionHealth
+
+	// Bytes sent and received, counted until saved to the message database
+	usage bandwidthUsage
+
 	// Callbacks
 	OnMessageReceived      func(*protocol.DirectMessage)
 	OnGroupMessageReceived func(*protocol.GroupMessage)
 	OnProfileUpdate        func(*protocol.ProfileUpdate)
 	OnTypingIndicator      func(*protocol.TypingIndicator)
 	OnReadReceipt          func(*protocol.ReadReceipt)
+	OnPresence             func(*protocol.PresenceUpdate)
 	OnAckReceived          func(*protocol.AckMessage)
 	OnNackReceived         func(*protocol.NackMessage)
+	OnMessageFlagged       func(*protocol.DirectMessage, FilterDecision)
+	OnMessageRequest       func(*protocol.DirectMessage)
+	OnRelayMoved           func(*protocol.RelayMovedNotice)
+	OnQueueProgress        func(*protocol.QueueProgress) // Relay's progress delivering our offline queue
+	OnRelayError           func(protocol.MessageID, *protocol.RelayErrorMessage) // Relay refused the message with this ID
+	OnMessageFailed        func(protocol.Address, protocol.MessageID)            // No ACK for the message with this ID after every retransmission
+	OnConnectionQualityChanged func(ConnectionQuality)                           // The relay connection got better or worse (see Diagnostics)
 }
 
 // NewClient creates a new client
@@ -80,12 +200,16 @@ func NewClient(privateKey *rsa.PrivateKey) *Client {
 		receiveSequenceNumbers: make(map[protocol.Address]uint64),
 		messageBuffer:          make(map[protocol.Address]map[uint64]*protocol.DirectMessage),
 		receivedMessageIDs:     make(map[protocol.Address]map[uint64]bool),
+		power:                  newPowerState(),
+		devices:                newDeviceRegistry(),
 	}
 }
 
 // AttachDatabase attaches a message database for persistence
+// Messages a previous run left unacknowledged are queued for retransmission.
 func (c *Client) AttachDatabase(db *storage.MessageDB) {
 	c.messageDB = db
+	c.restoreSendQueue()
 }
 
 // GetRelayDiscovery returns the relay discovery manager
@@ -121,6 +245,7 @@ func (c *Client) LoadPersistedState() error {
 		log.Printf("⚠️  Failed to load ratchet sessions: %v", err)
 	} else if len(sessions) > 0 {
 		// Convert string-keyed map to Address-keyed map
+		c.sessionMu.Lock()
 		for addrHex, session := range sessions {
 			addrBytes, err := hex.DecodeString(addrHex)
 			if err != nil {
@@ -131,6 +256,7 @@ func (c *Client) LoadPersistedState() error {
 			copy(addr[:], addrBytes)
 			c.ratchetSessions[addr] = session
 		}
+		c.sessionMu.Unlock()
 		log.Printf("✅ Loaded %d ratchet sessions from storage", len(sessions))
 	}
 
@@ -139,7 +265,9 @@ func (c *Client) LoadPersistedState() error {
 	if err != nil {
 		log.Printf("⚠️  Failed to load key bundle cache: %v", err)
 	} else if len(cache) > 0 {
+		c.keyBundleMu.Lock()
 		c.keyBundleCache = cache
+		c.keyBundleMu.Unlock()
 		log.Printf("✅ Loaded %d cached key bundles from storage", len(cache))
 	}
 
@@ -147,23 +275,41 @@ func (c *Client) LoadPersistedState() error {
 }
 
 // ConnectToRelay connects to a relay server
+// relayAddress is host:port for TCP, or a ws:// or wss:// URL for relays
+// accepting WebSocket connections (see ListenConfig.WebSocketPort).
 func (c *Client) ConnectToRelay(relayAddress string) error {
-	conn, err := net.Dial("tcp", relayAddress)
+	return c.ConnectToRelayContext(context.Background(), relayAddress)
+}
+
+// ConnectToRelayContext connects to a relay server, giving up on the dial and
+// handshake when ctx is done
+// The context only bounds connecting; the connection outlives it.
+func (c *Client) ConnectToRelayContext(ctx context.Context, relayAddress string) error {
+	conn, err := c.dialRelay(ctx, relayAddress)
 	if err != nil {
 		return err
 	}
 
+	c.writeMu.Lock()
 	c.relayConn = conn
 	c.relayAddress = relayAddress
 
-	// Perform handshake
-	if err := c.performHandshake(); err != nil {
+	// Switch to TLS if wanted, then handshake
+	release := bindDeadline(ctx, conn.SetDeadline)
+	err = c.startTLS(ctx)
+	if err == nil {
+		err = c.performHandshake()
+	}
+	release()
+	c.writeMu.Unlock()
+	if err != nil {
 		conn.Close()
-		return err
+		return contextError(ctx, err)
 	}
 
-	c.connected = true
+	c.connected.Store(true)
 	log.Printf("Connected to relay %s", relayAddress)
+	c.updateConnectionQuality()
 
 	// Start receive loop with auto-reconnection
 	go c.receiveLoopWithReconnect()
@@ -171,14 +317,49 @@ func (c *Client) ConnectToRelay(relayAddress string) error {
 	// Start keepalive routine
 	go c.keepaliveLoop()
 
+	// Retransmit messages until they are acknowledged
+	c.startRetransmitLoop()
+
+	return nil
+}
+
+// dialRelay opens a connection to a relay over TCP or, for ws:// and wss:// URLs, WebSocket
+// WebSocket dials honor HTTPS_PROXY and HTTP_PROXY, so clients on networks that
+// only let web traffic out can still reach a relay. Frames are the same on both.
+func (c *Client) dialRelay(ctx context.Context, relayAddress string) (net.Conn, error) {
+	if transport.IsWebSocketURL(relayAddress) {
+		return transport.DialWebSocket(ctx, relayAddress)
+	}
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, c.addressFamily.Network(), relayAddress)
+}
+
+// SetTenant sets the organization named in handshakes with multi-tenant relays
+// Takes effect on the next connection.
+func (c *Client) SetTenant(id string) error {
+	if len(id) > protocol.MaxTenantIDLength {
+		return fmt.Errorf("tenant ID is longer than %d bytes", protocol.MaxTenantIDLength)
+	}
+	c.tenant = id
 	return nil
 }
 
 // Disconnect disconnects from relay
 func (c *Client) Disconnect() error {
+	if c.messageDB != nil {
+		if err := c.flushUsage(); err != nil {
+			log.Printf("⚠️  Failed to save bandwidth usage: %v", err)
+		}
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
 	if c.relayConn != nil {
-		c.connected = false
-		return c.relayConn.Close()
+		c.connected.Store(false)
+		err := c.relayConn.Close()
+		c.updateConnectionQuality()
+		return err
 	}
 	return nil
 }
@@ -198,6 +379,11 @@ func (c *Client) performHandshake() error {
 		PublicKey:       pubKeyPEM,
 		ClientType:      protocol.ClientTypeUser,
 		Timestamp:       uint64(time.Now().Unix()),
+		Limits:          protocol.DefaultPayloadLimits(),
+		Tenant:          c.tenant,
+	}
+	if c.botAPIKey != nil {
+		hs.BotProof = protocol.BotProof(c.botAPIKey, hs.Address, hs.Timestamp)
 	}
 
 	payload := hs.Encode()
@@ -208,11 +394,12 @@ func (c *Client) performHandshake() error {
 		Version:   protocol.ProtocolVersion,
 		Type:      protocol.MsgTypeHandshake,
 		Length:    uint32(len(payload)),
-		Flags:     0,
+		Flags:     recordFlag(c.uniformRecords) | protocol.FlagResumable | c.queueFlags(),
 		MessageID: protocol.GenerateMessageID(),
 	}
 
 	// Send handshake
+	sent := time.Now()
 	if err := protocol.WriteHeader(c.relayConn, header); err != nil {
 		return err
 	}
@@ -226,27 +413,64 @@ func (c *Client) performHandshake() error {
 	if err != nil {
 		return err
 	}
+	rtt := time.Since(sent)
+
+	if err := hs.Limits.Check(ackHeader); err != nil {
+		return err
+	}
 
+	// Relays explain refusals (e.g. unknown tenant) with a relay error
+	if ackHeader.Type == protocol.MsgTypeRelayError {
+		payload := make([]byte, ackHeader.Length)
+		if _, err := io.ReadFull(c.relayConn, payload); err != nil {
+			return err
+		}
+		var relayErr protocol.RelayErrorMessage
+		if err := protocol.DecodePayload(payload, ackHeader.Flags, &relayErr); err != nil {
+			return ErrHandshakeFailed
+		}
+		return fmt.Errorf("%w: %w", ErrHandshakeFailed, &relayErr)
+	}
 	if ackHeader.Type != protocol.MsgTypeHandshakeAck {
 		return ErrHandshakeFailed
 	}
 
-	// Read and discard the ACK payload (relay's public key)
+	// Read the ACK payload (relay's public key and payload limits)
+	var ack protocol.HandshakeMessage
 	if ackHeader.Length > 0 {
 		payload := make([]byte, ackHeader.Length)
 		if _, err := io.ReadFull(c.relayConn, payload); err != nil {
 			return err
 		}
-		// Could decode and store relay's public key here if needed
+		if err := protocol.DecodePayload(payload, ackHeader.Flags, &ack); err != nil {
+			return fmt.Errorf("%w: %v", ErrHandshakeFailed, err)
+		}
+		// Could store relay's public key here if needed
+	}
+	c.payloadLimits = protocol.NegotiatePayloadLimits(hs.Limits, ack.Limits)
+	c.relayPolicy = ack.Policy
+	c.relayFeatures = ack.Features
+	c.relayPeer = ack.Address
+
+	// The relay confirms uniform records by echoing the flag
+	if c.uniformRecords != nil && recordsRequested(ackHeader) {
+		c.relayConn = newUniformConn(c.relayConn, *c.uniformRecords)
+		log.Println("🧱 Using uniform records")
 	}
 
+	c.recordLinkUp(rtt)
 	log.Println("Handshake successful")
 	return nil
 }
 
 // SendPing sends a ping to relay
 func (c *Client) SendPing() error {
-	if !c.connected {
+	return c.SendPingContext(context.Background())
+}
+
+// SendPingContext sends a ping to relay, giving up on the write when ctx is done
+func (c *Client) SendPingContext(ctx context.Context) error {
+	if !c.connected.Load() {
 		return ErrNotConnected
 	}
 
@@ -259,12 +483,14 @@ func (c *Client) SendPing() error {
 		MessageID: protocol.GenerateMessageID(),
 	}
 
-	return protocol.WriteHeader(c.relayConn, header)
+	// The relay echoes the message ID in its pong, which times the round trip
+	c.recordPingSent(header.MessageID, time.Now())
+	return c.writeFrame(ctx, header, nil)
 }
 
 // IsConnected returns connection status
 func (c *Client) IsConnected() bool {
-	return c.connected
+	return c.connected.Load()
 }
 
 // GetRelayAddress returns connected relay address
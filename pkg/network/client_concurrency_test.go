@@ -0,0 +1,295 @@
+package network
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// Run with -race: these exercise the client's session, sequence, key bundle and
+// ordering state from several goroutines at once.
+
+// testFrame is a frame queued by the fake relay for delivery
+type testFrame struct {
+	header  *protocol.Header
+	payload []byte
+}
+
+// newTestClient creates a connected client with X3DH keys, returning the relay's end of its connection
+func newTestClient(t *testing.T, addr byte) (*Client, net.Conn) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(key)
+	c.Address[0] = addr
+	if err := c.InitializeX3DH(); err != nil {
+		t.Fatal(err)
+	}
+
+	clientSide, relaySide := net.Pipe()
+	c.relayConn = clientSide
+	c.connected.Store(true)
+	return c, relaySide
+}
+
+// runTestReceiver feeds direct messages from a client's connection to its handler
+// Other frames never reach the client from the fake relay.
+func runTestReceiver(c *Client, wg *sync.WaitGroup) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			header, err := protocol.ReadHeader(c.relayConn)
+			if err != nil {
+				return
+			}
+			c.handleDirectMessage(header)
+		}
+	}()
+}
+
+// runTestRelay peels the onion layer off frames sent on from and delivers them on to
+// Delivery is queued so a client writing an ACK never blocks the other side's sends.
+func runTestRelay(t *testing.T, relayKey *rsa.PrivateKey, from, to net.Conn, wg *sync.WaitGroup) {
+	queue := make(chan testFrame, 1024)
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer close(queue)
+		for {
+			header, err := protocol.ReadHeader(from)
+			if err != nil {
+				return
+			}
+			payload := make([]byte, header.Length)
+			if _, err := io.ReadFull(from, payload); err != nil {
+				return
+			}
+			if header.Type != protocol.MsgTypeRelayForward {
+				continue // ACKs stop at the relay
+			}
+
+			layer, err := crypto.DecryptOnionLayer(payload, relayKey)
+			if err != nil {
+				t.Errorf("relay failed to decrypt onion layer: %v", err)
+				continue
+			}
+			queue <- testFrame{
+				header: &protocol.Header{
+					Magic:     protocol.ProtocolMagic,
+					Version:   protocol.ProtocolVersion,
+					Type:      protocol.MsgTypeDirectMessage,
+					Length:    uint32(len(layer.Payload)),
+					MessageID: header.MessageID,
+				},
+				payload: layer.Payload,
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for frame := range queue {
+			if err := protocol.WriteHeader(to, frame.header); err != nil {
+				return
+			}
+			if _, err := to.Write(frame.payload); err != nil {
+				return
+			}
+		}
+	}()
+}
+
+// sendTestMessage sends a sequenced direct message over the client's ratchet session
+func sendTestMessage(c *Client, to protocol.Address, content string, path []*crypto.RelayInfo) error {
+	msg := &protocol.DirectMessage{
+		From:           c.Address,
+		To:             to,
+		Timestamp:      uint64(time.Now().UnixMilli()),
+		SequenceNumber: c.GetNextSequenceNumber(to),
+		ContentType:    protocol.ContentTypeText,
+		Content:        []byte(content),
+	}
+	return c.SendRatchetMessage(to, nil, protocol.TagPayload(protocol.MsgTypeDirectMessage, msg.Encode()), path)
+}
+
+// collectDeliveries records delivered messages and signals once want have arrived
+func collectDeliveries(c *Client, want int) (*[]uint64, <-chan struct{}) {
+	var seqs []uint64
+	done := make(chan struct{})
+	c.OnMessageReceived = func(msg *protocol.DirectMessage) {
+		seqs = append(seqs, msg.SequenceNumber)
+		if len(seqs) == want {
+			close(done)
+		}
+	}
+	return &seqs, done
+}
+
+func TestConcurrentRatchetSendReceive(t *testing.T) {
+	const senders, perSender = 4, 10
+
+	relayKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var relayAddr protocol.Address
+	relayAddr[0] = 0xff
+	path := []*crypto.RelayInfo{{Address: relayAddr, PublicKey: &relayKey.PublicKey}}
+
+	alice, aliceRelay := newTestClient(t, 0xa1)
+	bob, bobRelay := newTestClient(t, 0xb0)
+
+	// Bob behaves like a device without AES hardware; the session uses his choice
+	if err := bob.SetCipherSuites(protocol.CipherSuiteChaCha20Poly1305, protocol.CipherSuiteAES256GCM); err != nil {
+		t.Fatal(err)
+	}
+
+	aliceBundle, err := alice.GetKeyBundle()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobBundle, err := bob.GetKeyBundle()
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice.CacheKeyBundle(bob.Address, bobBundle)
+	bob.CacheKeyBundle(alice.Address, aliceBundle)
+
+	var wg sync.WaitGroup
+	runTestRelay(t, relayKey, aliceRelay, bobRelay, &wg)
+	runTestRelay(t, relayKey, bobRelay, aliceRelay, &wg)
+	runTestReceiver(alice, &wg)
+	runTestReceiver(bob, &wg)
+	defer func() {
+		alice.relayConn.Close()
+		bob.relayConn.Close()
+		wg.Wait()
+	}()
+
+	const total = senders*perSender + 1
+	bobGot, bobDone := collectDeliveries(bob, total)
+	aliceGot, aliceDone := collectDeliveries(alice, senders*perSender)
+
+	// Alice opens the session first; two simultaneous X3DH initiations would replace each other
+	if err := sendTestMessage(alice, bob.Address, "hello", path); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.After(10 * time.Second)
+	for {
+		if _, ok := bob.GetRatchetSession(alice.Address); ok {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("bob never set up a ratchet session")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if session, _ := alice.GetRatchetSession(bob.Address); session.CipherSuite != protocol.CipherSuiteChaCha20Poly1305 {
+		t.Fatalf("session cipher suite = %s, want %s", session.CipherSuite, protocol.CipherSuiteChaCha20Poly1305)
+	}
+
+	var senderWG sync.WaitGroup
+	for i := 0; i < senders; i++ {
+		senderWG.Add(2)
+		go func() {
+			defer senderWG.Done()
+			for j := 0; j < perSender; j++ {
+				if err := sendTestMessage(alice, bob.Address, fmt.Sprintf("a%d-%d", i, j), path); err != nil {
+					t.Errorf("alice send: %v", err)
+				}
+			}
+		}()
+		go func() {
+			defer senderWG.Done()
+			for j := 0; j < perSender; j++ {
+				if err := sendTestMessage(bob, alice.Address, fmt.Sprintf("b%d-%d", i, j), path); err != nil {
+					t.Errorf("bob send: %v", err)
+				}
+			}
+		}()
+	}
+	senderWG.Wait()
+
+	for name, done := range map[string]<-chan struct{}{"bob": bobDone, "alice": aliceDone} {
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Fatalf("%s did not receive every message", name)
+		}
+	}
+
+	// Ordering delivers each peer's messages in sequence despite concurrent sends
+	for name, got := range map[string][]uint64{"bob": *bobGot, "alice": *aliceGot} {
+		for i, seq := range got {
+			if seq != uint64(i) {
+				t.Fatalf("%s received seq %d at position %d", name, seq, i)
+			}
+		}
+	}
+}
+
+func TestConcurrentSequenceNumbers(t *testing.T) {
+	const goroutines, perGoroutine = 8, 100
+
+	c := NewClient(&rsa.PrivateKey{})
+	var peer protocol.Address
+
+	seen := make([]bool, goroutines*perGoroutine)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perGoroutine; j++ {
+				seq := c.GetNextSequenceNumber(peer)
+				mu.Lock()
+				if seq >= uint64(len(seen)) || seen[seq] {
+					t.Errorf("sequence number %d handed out twice or out of range", seq)
+				} else {
+					seen[seq] = true
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestConcurrentKeyBundleCache(t *testing.T) {
+	c := NewClient(&rsa.PrivateKey{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var addr protocol.Address
+			addr[0] = byte(i)
+			for j := 0; j < 100; j++ {
+				c.CacheKeyBundle(addr, &protocol.KeyBundle{})
+				if _, ok := c.GetCachedKeyBundle(addr); !ok {
+					t.Errorf("bundle for %x missing right after caching", addr[:1])
+				}
+				if j%10 == 9 {
+					c.RemoveCachedKeyBundle(addr)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,98 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// bindDeadline applies ctx to a connection deadline until the returned func is called
+// The context's deadline becomes the connection's, and cancelling the context
+// moves the deadline into the past so blocked I/O returns immediately.
+func bindDeadline(ctx context.Context, setDeadline func(time.Time) error) (release func()) {
+	if deadline, ok := ctx.Deadline(); ok {
+		setDeadline(deadline)
+	}
+
+	cancelled := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		setDeadline(time.Unix(1, 0))
+		close(cancelled)
+	})
+
+	return func() {
+		if !stop() {
+			<-cancelled
+		}
+		setDeadline(time.Time{})
+	}
+}
+
+// contextError reports an I/O error caused by ctx as the context's error
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("%w: %w", ctxErr, err)
+	}
+	if _, ok := ctx.Deadline(); ok && errors.Is(err, os.ErrDeadlineExceeded) {
+		return fmt.Errorf("%w: %w", context.DeadlineExceeded, err)
+	}
+	return err
+}
+
+// writeFrame writes a header and payload to the relay, giving up when ctx is done
+// Frames from concurrent senders never interleave. A frame cut off part way
+// would desynchronize the stream, so the connection is closed in that case and
+// the receive loop reconnects (resuming the session). The frame counts as
+// control traffic; see writeFrameAs.
+func (c *Client) writeFrame(ctx context.Context, header *protocol.Header, payload []byte) error {
+	return c.writeFrameAs(ctx, usageKey{}, header, payload)
+}
+
+// writeFrameAs is writeFrame, counting the frame's bytes under key once written
+func (c *Client) writeFrameAs(ctx context.Context, key usageKey, header *protocol.Header, payload []byte) error {
+	var frame bytes.Buffer
+	if err := protocol.WriteHeader(&frame, header); err != nil {
+		return err
+	}
+	frame.Write(payload)
+	if err := c.writeBytes(ctx, frame.Bytes()); err != nil {
+		return err
+	}
+	c.recordUsage(key, frame.Len(), 0)
+	return nil
+}
+
+// writeBytes writes whole frames to the relay in one call (see writeFrame)
+func (c *Client) writeBytes(ctx context.Context, frames []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	conn := c.relayConn
+	if conn == nil {
+		return ErrNotConnected
+	}
+	release := bindDeadline(ctx, conn.SetWriteDeadline)
+	defer release()
+
+	_, err := conn.Write(frames)
+	if err == nil {
+		return nil
+	}
+
+	err = contextError(ctx, err)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		log.Printf("⚠️  Write to relay abandoned (%v), reconnecting", err)
+		conn.Close()
+	}
+	return err
+}
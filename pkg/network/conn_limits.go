@@ -0,0 +1,119 @@
+package network
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// ConnectionLimits bounds how slowly and how idly peers may talk to a relay
+// Without them a peer can open connections and trickle bytes (slow-loris) until the
+// relay runs out of file descriptors or memory for half-read payloads.
+type ConnectionLimits struct {
+	// HeaderTimeout bounds reading one header. Before the handshake it runs from
+	// accept (or the previous message); afterwards from the header's first byte.
+	HeaderTimeout time.Duration
+
+	// IdleTimeout closes user connections with no traffic after the handshake
+	// Clients ping every 30s. Relay peers are never idled out. 0 disables.
+	IdleTimeout time.Duration
+
+	// MinPayloadRate is the slowest payload transfer accepted, in bytes per second
+	// Payloads must arrive within HeaderTimeout plus their length at this rate.
+	MinPayloadRate int
+
+	// MaxHalfOpen caps connections that have not completed a handshake
+	// Further connections are closed on accept. 0 disables.
+	MaxHalfOpen int
+}
+
+// DefaultConnectionLimits returns the limits relays use unless configured otherwise
+func DefaultConnectionLimits() ConnectionLimits {
+	return ConnectionLimits{
+		HeaderTimeout:  10 * time.Second,
+		IdleTimeout:    90 * time.Second,
+		MinPayloadRate: 16 * 1024,
+		MaxHalfOpen:    256,
+	}
+}
+
+// SetConnectionLimits sets read deadlines and the half-open connection cap
+// Applies to connections accepted afterwards.
+func (rs *RelayServer) SetConnectionLimits(limits ConnectionLimits) {
+	rs.mu.Lock()
+	rs.connLimits = limits
+	rs.mu.Unlock()
+
+	log.Printf("⏱️  Connection limits set: header %s, idle %s, min rate %d B/s, max half-open %d",
+		limits.HeaderTimeout, limits.IdleTimeout, limits.MinPayloadRate, limits.MaxHalfOpen)
+}
+
+// GetConnectionLimits returns the relay's connection limits
+func (rs *RelayServer) GetConnectionLimits() ConnectionLimits {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.connLimits
+}
+
+// acquireHalfOpen reserves a half-open slot, returning false if the cap is reached
+func (rs *RelayServer) acquireHalfOpen(max int) bool {
+	if n := rs.halfOpen.Add(1); max > 0 && int(n) > max {
+		rs.halfOpen.Add(-1)
+		return false
+	}
+	return true
+}
+
+// releaseHalfOpen frees a slot taken by acquireHalfOpen
+func (rs *RelayServer) releaseHalfOpen() {
+	rs.halfOpen.Add(-1)
+}
+
+// HalfOpenConnections returns how many connections are waiting for a handshake
+func (rs *RelayServer) HalfOpenConnections() int {
+	return int(rs.halfOpen.Load())
+}
+
+// readHeader reads a header under the connection's deadlines
+// wait bounds the whole read (0 = no limit); once the first byte arrives the
+// rest must also follow within HeaderTimeout.
+func (l ConnectionLimits) readHeader(conn net.Conn, wait time.Duration) (*protocol.Header, error) {
+	buf := make([]byte, protocol.HeaderSize)
+
+	first := deadline(wait)
+	conn.SetReadDeadline(first)
+	if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+		return nil, err
+	}
+
+	if rest := deadline(l.HeaderTimeout); first.IsZero() || (!rest.IsZero() && rest.Before(first)) {
+		conn.SetReadDeadline(rest)
+	}
+	if _, err := io.ReadFull(conn, buf[1:]); err != nil {
+		return nil, err
+	}
+
+	return protocol.ReadHeader(bytes.NewReader(buf))
+}
+
+// payloadDeadline sets the deadline for reading a payload of the given length
+func (l ConnectionLimits) payloadDeadline(conn net.Conn, length uint32) {
+	if l.MinPayloadRate <= 0 || l.HeaderTimeout <= 0 {
+		conn.SetReadDeadline(time.Time{})
+		return
+	}
+	transfer := time.Duration(int64(length) * int64(time.Second) / int64(l.MinPayloadRate))
+	conn.SetReadDeadline(time.Now().Add(l.HeaderTimeout + transfer))
+}
+
+// deadline returns now+d, or no deadline for d <= 0
+func deadline(d time.Duration) time.Time {
+	if d <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(d)
+}
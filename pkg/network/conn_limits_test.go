@@ -0,0 +1,155 @@
+package network
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// isTimeout reports whether err is a read deadline expiring
+func isTimeout(err error) bool {
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
+
+// encodedHeader returns a valid header on the wire
+func encodedHeader(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	header := &protocol.Header{Magic: protocol.ProtocolMagic, Version: protocol.ProtocolVersion, Type: protocol.MsgTypePing}
+	if err := protocol.WriteHeader(&buf, header); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadHeaderDeadlines(t *testing.T) {
+	limits := ConnectionLimits{HeaderTimeout: 50 * time.Millisecond}
+	header := encodedHeader(t)
+
+	t.Run("silent", func(t *testing.T) {
+		relaySide, peerSide := net.Pipe()
+		defer relaySide.Close()
+		defer peerSide.Close()
+
+		if _, err := limits.readHeader(relaySide, limits.HeaderTimeout); !isTimeout(err) {
+			t.Fatalf("readHeader() error = %v, want timeout", err)
+		}
+	})
+
+	t.Run("trickled", func(t *testing.T) {
+		relaySide, peerSide := net.Pipe()
+		defer relaySide.Close()
+		defer peerSide.Close()
+
+		// No wait for the first byte (an idle relay peer), but the rest is too slow
+		go func() {
+			peerSide.Write(header[:1])
+			time.Sleep(4 * limits.HeaderTimeout)
+			peerSide.Write(header[1:])
+		}()
+		if _, err := limits.readHeader(relaySide, 0); !isTimeout(err) {
+			t.Fatalf("readHeader() error = %v, want timeout", err)
+		}
+	})
+
+	t.Run("prompt", func(t *testing.T) {
+		relaySide, peerSide := net.Pipe()
+		defer relaySide.Close()
+		defer peerSide.Close()
+
+		go peerSide.Write(header)
+		got, err := limits.readHeader(relaySide, limits.HeaderTimeout)
+		if err != nil {
+			t.Fatalf("readHeader() error = %v", err)
+		}
+		if got.Type != protocol.MsgTypePing {
+			t.Errorf("readHeader() type = 0x%04x, want ping", got.Type)
+		}
+	})
+}
+
+func TestPayloadDeadline(t *testing.T) {
+	relaySide, peerSide := net.Pipe()
+	defer relaySide.Close()
+	defer peerSide.Close()
+
+	// 50ms for the header plus 1000 bytes at 10000 B/s
+	limits := ConnectionLimits{HeaderTimeout: 50 * time.Millisecond, MinPayloadRate: 10000}
+	start := time.Now()
+	limits.payloadDeadline(relaySide, 1000)
+	if _, err := relaySide.Read(make([]byte, 1)); !isTimeout(err) {
+		t.Fatalf("payload read error = %v, want timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("payload read timed out after %s, want at least 150ms", elapsed)
+	}
+
+	// Without a minimum rate the deadline is cleared
+	limits.MinPayloadRate = 0
+	limits.payloadDeadline(relaySide, 1000)
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		peerSide.Write([]byte{1})
+	}()
+	if _, err := relaySide.Read(make([]byte, 1)); err != nil {
+		t.Fatalf("payload read without a rate error = %v", err)
+	}
+}
+
+func TestHalfOpenCap(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := NewRelayServer(0, key)
+	rs.SetConnectionLimits(ConnectionLimits{HeaderTimeout: 50 * time.Millisecond, MaxHalfOpen: 2})
+	limit := rs.GetConnectionLimits().MaxHalfOpen
+
+	// Two silent connections fill the cap
+	var peers []net.Conn
+	for range limit {
+		if !rs.acquireHalfOpen(limit) {
+			t.Fatal("acquireHalfOpen() refused below the cap")
+		}
+		relaySide, peerSide := net.Pipe()
+		peers = append(peers, peerSide)
+		go rs.handleConnection(relaySide, nil)
+	}
+	if rs.acquireHalfOpen(limit) {
+		t.Fatal("acquireHalfOpen() allowed past the cap")
+	}
+	if got := rs.HalfOpenConnections(); got != limit {
+		t.Fatalf("HalfOpenConnections() = %d, want %d", got, limit)
+	}
+
+	// Neither sends a header, so both are closed and their slots freed
+	for _, peerSide := range peers {
+		peerSide.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if _, err := peerSide.Read(make([]byte, 1)); err == nil || isTimeout(err) {
+			t.Fatalf("silent connection read = %v, want closed by the relay", err)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for rs.HalfOpenConnections() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("HalfOpenConnections() = %d after timeouts, want 0", rs.HalfOpenConnections())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !rs.acquireHalfOpen(limit) {
+		t.Fatal("acquireHalfOpen() refused after slots were freed")
+	}
+
+	// No cap when disabled
+	for range 10 {
+		if !rs.acquireHalfOpen(0) {
+			t.Fatal("acquireHalfOpen(0) refused")
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package network
+
+import (
+	"log"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// ContentHandler handles a delivered direct message of a specific content type
+type ContentHandler func(msg *protocol.DirectMessage)
+
+// SetContentTypeRegistry replaces the registry used to validate sent and received content
+func (c *Client) SetContentTypeRegistry(registry *protocol.ContentTypeRegistry) {
+	c.contentMu.Lock()
+	c.contentTypes = registry
+	c.contentMu.Unlock()
+}
+
+// ContentTypes returns the client's content type registry (default registry if none set)
+func (c *Client) ContentTypes() *protocol.ContentTypeRegistry {
+	c.contentMu.RLock()
+	defer c.contentMu.RUnlock()
+
+	if c.contentTypes == nil {
+		return protocol.DefaultContentTypes
+	}
+	return c.contentTypes
+}
+
+// RegisterContentHandler registers a handler for messages of the given content type
+// Registered handlers take precedence over OnMessageReceived for that type
+func (c *Client) RegisterContentHandler(contentType uint8, handler ContentHandler) {
+	c.contentMu.Lock()
+	if c.contentHandlers == nil {
+		c.contentHandlers = make(map[uint8]ContentHandler)
+	}
+	c.contentHandlers[contentType] = handler
+	c.contentMu.Unlock()
+
+	log.Printf("📎 Content handler registered for %s", protocol.ContentTypeName(contentType))
+}
+
+// UnregisterContentHandler removes the handler for a content type
+func (c *Client) UnregisterContentHandler(contentType uint8) {
+	c.contentMu.Lock()
+	delete(c.contentHandlers, contentType)
+	c.contentMu.Unlock()
+}
+
+// dispatchContent calls the handler for the message's content type
+// Falls back to OnMessageReceived when no handler is registered
+func (c *Client) dispatchContent(msg *protocol.DirectMessage) {
+	c.contentMu.RLock()
+	handler, exists := c.contentHandlers[msg.ContentType]
+	c.contentMu.RUnlock()
+
+	if exists {
+		handler(msg)
+		return
+	}
+
+	if c.OnMessageReceived != nil {
+		c.OnMessageReceived(msg)
+	}
+}
@@ -0,0 +1,52 @@
+package network
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+func TestContentHandlersConcurrent(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(key)
+
+	var handled, fallback atomic.Int32
+	client.OnMessageReceived = func(msg *protocol.DirectMessage) { fallback.Add(1) }
+
+	// Handlers are registered while messages are being dispatched
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			client.RegisterContentHandler(protocol.ContentTypeText, func(msg *protocol.DirectMessage) { handled.Add(1) })
+			client.UnregisterContentHandler(protocol.ContentTypeText)
+			client.SetContentTypeRegistry(protocol.DefaultContentTypes)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			client.dispatchContent(&protocol.DirectMessage{ContentType: protocol.ContentTypeText})
+			client.ContentTypes()
+		}
+	}()
+	wg.Wait()
+	if handled.Load()+fallback.Load() != 100 {
+		t.Errorf("dispatched %d messages, want 100", handled.Load()+fallback.Load())
+	}
+
+	client.RegisterContentHandler(protocol.ContentTypeText, func(msg *protocol.DirectMessage) { handled.Add(1) })
+	before := handled.Load()
+	client.dispatchContent(&protocol.DirectMessage{ContentType: protocol.ContentTypeText})
+	if handled.Load() != before+1 {
+		t.Error("registered handler was not called")
+	}
+}
@@ -0,0 +1,245 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+const (
+	// ratchetOutboxTTL is how long sent ratchet messages are kept for resending
+	ratchetOutboxTTL = 10 * time.Minute
+
+	// ratchetOutboxPerPeer caps the ratchet messages kept per peer
+	ratchetOutboxPerPeer = 64
+
+	// MaxSessionResets is how many times a session with one peer is reset
+	// automatically within SessionResetWindow. Past that, decryption NACKs are
+	// only reported, so two clients that keep failing can't reset each other forever.
+	MaxSessionResets   = 3
+	SessionResetWindow = 10 * time.Minute
+)
+
+// sentRatchetMessage is a ratchet message kept until it is NACKed or expires
+// Receivers that can't decrypt a message can't read what's inside it, so a
+// decryption NACK names the message by its ratchet header instead: the first
+// 16 bytes of the DH key as MessageID and the chain message number as
+// SequenceNumber (see ratchetMessageRef).
+type sentRatchetMessage struct {
+	ref        protocol.MessageID
+	messageNum uint32
+	plaintext  []byte
+	relayPath  []*crypto.RelayInfo
+	sentAt     time.Time
+	resend     bool // Already a resend; a NACK for it is not resent again
+}
+
+// ratchetOutbox holds recently sent ratchet messages and session reset history
+type ratchetOutbox struct {
+	sent   map[protocol.Address][]*sentRatchetMessage
+	resets map[protocol.Address][]time.Time
+	mu     sync.Mutex
+}
+
+// ratchetMessageRef identifies a ratchet message by its header
+func ratchetMessageRef(header *protocol.MessageHeader) protocol.MessageID {
+	var ref protocol.MessageID
+	copy(ref[:], header.DHPublicKey[:])
+	return ref
+}
+
+// record keeps a sent ratchet message for a possible resend
+func (o *ratchetOutbox) record(to protocol.Address, header *protocol.MessageHeader, plaintext []byte, relayPath []*crypto.RelayInfo, resend bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.sent == nil {
+		o.sent = make(map[protocol.Address][]*sentRatchetMessage)
+	}
+
+	now := time.Now()
+	kept := o.sent[to][:0]
+	for _, m := range o.sent[to] {
+		if now.Sub(m.sentAt) < ratchetOutboxTTL {
+			kept = append(kept, m)
+		}
+	}
+	if len(kept) >= ratchetOutboxPerPeer {
+		kept = append(kept[:0], kept[len(kept)-ratchetOutboxPerPeer+1:]...)
+	}
+
+	o.sent[to] = append(kept, &sentRatchetMessage{
+		ref:        ratchetMessageRef(header),
+		messageNum: header.MessageNum,
+		plaintext:  plaintext,
+		relayPath:  relayPath,
+		sentAt:     now,
+		resend:     resend,
+	})
+}
+
+// take removes and returns the message a NACK refers to
+func (o *ratchetOutbox) take(from protocol.Address, ref protocol.MessageID, messageNum uint64) *sentRatchetMessage {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i, m := range o.sent[from] {
+		if m.ref == ref && uint64(m.messageNum) == messageNum {
+			o.sent[from] = append(o.sent[from][:i], o.sent[from][i+1:]...)
+			if time.Since(m.sentAt) >= ratchetOutboxTTL {
+				return nil
+			}
+			return m
+		}
+	}
+	return nil
+}
+
+// takeAfter removes and returns all messages sent to a peer after a message
+// These were encrypted on the same broken session and need resending too.
+func (o *ratchetOutbox) takeAfter(to protocol.Address, after time.Time) []*sentRatchetMessage {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var later, kept []*sentRatchetMessage
+	for _, m := range o.sent[to] {
+		if m.sentAt.After(after) && time.Since(m.sentAt) < ratchetOutboxTTL {
+			later = append(later, m)
+		} else {
+			kept = append(kept, m)
+		}
+	}
+	o.sent[to] = kept
+	return later
+}
+
+// allowReset records a session reset with a peer unless the limit is reached
+func (o *ratchetOutbox) allowReset(peer protocol.Address) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.resets == nil {
+		o.resets = make(map[protocol.Address][]time.Time)
+	}
+
+	now := time.Now()
+	kept := o.resets[peer][:0]
+	for _, t := range o.resets[peer] {
+		if now.Sub(t) < SessionResetWindow {
+			kept = append(kept, t)
+		}
+	}
+	if len(kept) >= MaxSessionResets {
+		o.resets[peer] = kept
+		return false
+	}
+	o.resets[peer] = append(kept, now)
+	return true
+}
+
+// recoverFromDecryptionNack re-keys the session with a peer that couldn't decrypt
+// our ratchet message and resends it: the peer's key bundle is refreshed, the
+// session reset (so the next send performs X3DH again) and the message, plus
+// any sent after it on the same session, encrypted anew.
+func (c *Client) recoverFromDecryptionNack(nack *protocol.NackMessage) error {
+	failed := c.ratchetOutbox.take(nack.From, nack.MessageID, nack.SequenceNumber)
+	if failed == nil {
+		return fmt.Errorf("message not found among recently sent ratchet messages")
+	}
+	if failed.resend {
+		return fmt.Errorf("resent message failed again, giving up")
+	}
+	if !c.ratchetOutbox.allowReset(nack.From) {
+		return fmt.Errorf("session reset %d times in %s, giving up", MaxSessionResets, SessionResetWindow)
+	}
+
+	bundle := c.refreshKeyBundle(nack.From)
+	if bundle == nil {
+		return fmt.Errorf("%w for %x", ErrNoKeyBundle, nack.From[:8])
+	}
+
+	c.ResetRatchetSession(nack.From)
+
+	pending := append([]*sentRatchetMessage{failed}, c.ratchetOutbox.takeAfter(nack.From, failed.sentAt)...)
+	for _, m := range pending {
+		if err := c.sendRatchetMessage(context.Background(), nack.From, bundle, m.plaintext, m.relayPath, true); err != nil {
+			return fmt.Errorf("resend failed: %w", err)
+		}
+	}
+
+	log.Printf("🔄 Re-keyed session with %x and resent %d message(s)", nack.From[:8], len(pending))
+	return nil
+}
+
+// refreshKeyBundle fetches a peer's current key bundle from the DHT
+// Without a DHT (or if the lookup fails) the cached bundle is used.
+func (c *Client) refreshKeyBundle(peer protocol.Address) *protocol.KeyBundle {
+	if c.dhtNode != nil {
+		bundle, err := c.DiscoverKeyBundle(peer)
+		if err == nil {
+			return bundle
+		}
+		log.Printf("⚠️  Key bundle refresh for %x failed, using cached bundle: %v", peer[:8], err)
+	}
+
+	cached, ok := c.GetCachedKeyBundle(peer)
+	if !ok {
+		return nil
+	}
+
+	// Its one-time prekey went into the session being replaced and is gone
+	bundle := *cached
+	bundle.OneTimePreKeys = nil
+	return &bundle
+}
+
+// ResetRatchetSession drops the ratchet session with a peer
+// The next ratchet message to the peer performs X3DH and starts a new session.
+func (c *Client) ResetRatchetSession(peer protocol.Address) {
+	c.sessionMu.Lock()
+	delete(c.ratchetSessions, peer)
+	c.sessionMu.Unlock()
+
+	if c.sessionStorage != nil {
+		if err := c.sessionStorage.DeleteRatchetSession(peer); err != nil {
+			log.Printf("⚠️  Failed to delete persisted ratchet session: %v", err)
+		}
+	}
+
+	log.Printf("🔄 Ratchet session with %x reset", peer[:8])
+}
+
+// nackUndecryptableRatchet tells the sender of a ratchet message we couldn't decrypt
+// Only messages on a chain we know can be attributed to a sender; keys that were
+// already used are replays and are ignored.
+func (c *Client) nackUndecryptableRatchet(headerBytes []byte) {
+	var header protocol.MessageHeader
+	if err := header.Decode(headerBytes); err != nil {
+		return
+	}
+
+	c.sessionMu.Lock()
+	var sender protocol.Address
+	found := false
+	for addr, session := range c.ratchetSessions {
+		if session.DHReceivingPublic != header.DHPublicKey {
+			continue
+		}
+		if header.MessageNum < session.ReceivingMsgNum {
+			c.sessionMu.Unlock()
+			return
+		}
+		sender, found = addr, true
+		break
+	}
+	c.sessionMu.Unlock()
+
+	if found {
+		c.sendNack(sender, ratchetMessageRef(&header), uint64(header.MessageNum), protocol.NackErrorDecryption, "ratchet decryption failed")
+	}
+}
@@ -0,0 +1,203 @@
+package network
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// testRatchetHeader returns a ratchet header on chain key with message number n
+func testRatchetHeader(key byte, n uint32) *protocol.MessageHeader {
+	header := &protocol.MessageHeader{MessageNum: n}
+	header.DHPublicKey[0] = key
+	return header
+}
+
+func TestRatchetOutbox(t *testing.T) {
+	var o ratchetOutbox
+	peer := protocol.Address{1}
+
+	for n := range uint32(3) {
+		o.record(peer, testRatchetHeader(7, n), []byte{byte(n)}, nil, false)
+	}
+
+	// A NACK names a message by chain and number; neither alone is enough
+	if m := o.take(peer, ratchetMessageRef(testRatchetHeader(8, 1)), 1); m != nil {
+		t.Fatal("take() matched a message on another chain")
+	}
+	if m := o.take(protocol.Address{2}, ratchetMessageRef(testRatchetHeader(7, 1)), 1); m != nil {
+		t.Fatal("take() matched a message sent to another peer")
+	}
+	failed := o.take(peer, ratchetMessageRef(testRatchetHeader(7, 1)), 1)
+	if failed == nil || failed.plaintext[0] != 1 {
+		t.Fatalf("take() = %v, want message 1", failed)
+	}
+	if m := o.take(peer, ratchetMessageRef(testRatchetHeader(7, 1)), 1); m != nil {
+		t.Fatal("take() returned a message twice")
+	}
+
+	// Messages sent after the failed one are taken with it, earlier ones stay
+	o.sent[peer][0].sentAt = failed.sentAt.Add(-time.Second)
+	o.sent[peer][1].sentAt = failed.sentAt.Add(time.Second)
+	later := o.takeAfter(peer, failed.sentAt)
+	if len(later) != 1 || later[0].plaintext[0] != 2 {
+		t.Fatalf("takeAfter() = %d messages, want message 2", len(later))
+	}
+	if len(o.sent[peer]) != 1 || o.sent[peer][0].plaintext[0] != 0 {
+		t.Fatalf("outbox kept %d messages, want message 0", len(o.sent[peer]))
+	}
+
+	// Expired messages are not resent
+	o.sent[peer][0].sentAt = time.Now().Add(-ratchetOutboxTTL)
+	if m := o.take(peer, ratchetMessageRef(testRatchetHeader(7, 0)), 0); m != nil {
+		t.Fatal("take() returned an expired message")
+	}
+
+	// Only the most recent messages per peer are kept
+	for n := range uint32(ratchetOutboxPerPeer + 5) {
+		o.record(peer, testRatchetHeader(9, n), nil, nil, false)
+	}
+	if got := len(o.sent[peer]); got != ratchetOutboxPerPeer {
+		t.Fatalf("outbox kept %d messages, want %d", got, ratchetOutboxPerPeer)
+	}
+	if m := o.take(peer, ratchetMessageRef(testRatchetHeader(9, 0)), 0); m != nil {
+		t.Fatal("take() returned a message past the per-peer cap")
+	}
+	if m := o.take(peer, ratchetMessageRef(testRatchetHeader(9, ratchetOutboxPerPeer+4)), ratchetOutboxPerPeer+4); m == nil {
+		t.Fatal("take() lost the most recent message")
+	}
+}
+
+func TestAllowReset(t *testing.T) {
+	var o ratchetOutbox
+	peer, other := protocol.Address{1}, protocol.Address{2}
+
+	for i := range MaxSessionResets {
+		if !o.allowReset(peer) {
+			t.Fatalf("reset %d refused, want allowed", i+1)
+		}
+	}
+	if o.allowReset(peer) {
+		t.Fatalf("reset %d allowed, want refused", MaxSessionResets+1)
+	}
+	if !o.allowReset(other) {
+		t.Fatal("reset with another peer refused")
+	}
+
+	// Resets older than the window no longer count
+	o.resets[peer][0] = time.Now().Add(-SessionResetWindow)
+	if !o.allowReset(peer) {
+		t.Fatal("reset refused after the oldest left the window")
+	}
+	if o.allowReset(peer) {
+		t.Fatal("reset allowed past the limit")
+	}
+}
+
+func TestRecoverFromDecryptionNack(t *testing.T) {
+	relayKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := []*crypto.RelayInfo{{Address: protocol.Address{0xff}, PublicKey: &relayKey.PublicKey}}
+
+	alice, aliceRelay := newTestClient(t, 0xa1)
+	bob, bobRelay := newTestClient(t, 0xb0)
+	bobBundle, err := bob.GetKeyBundle()
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice.CacheKeyBundle(bob.Address, bobBundle)
+
+	var wg sync.WaitGroup
+	runTestRelay(t, relayKey, aliceRelay, bobRelay, &wg)
+	runTestRelay(t, relayKey, bobRelay, aliceRelay, &wg)
+	runTestReceiver(alice, &wg)
+	runTestReceiver(bob, &wg)
+	defer func() {
+		alice.relayConn.Close()
+		bob.relayConn.Close()
+		wg.Wait()
+	}()
+
+	if err := sendTestMessage(alice, bob.Address, "hello", path); err != nil {
+		t.Fatal(err)
+	}
+	waitBobSession := func(prev *protocol.RatchetState) {
+		t.Helper()
+		deadline := time.After(10 * time.Second)
+		for {
+			if session, ok := bob.GetRatchetSession(alice.Address); ok && session != prev {
+				return
+			}
+			select {
+			case <-deadline:
+				t.Fatal("bob never set up a new ratchet session")
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+	waitBobSession(nil)
+	bobSession, _ := bob.GetRatchetSession(alice.Address)
+	aliceSession, _ := alice.GetRatchetSession(bob.Address)
+
+	nackFor := func() *protocol.NackMessage {
+		alice.ratchetOutbox.mu.Lock()
+		defer alice.ratchetOutbox.mu.Unlock()
+		sent := alice.ratchetOutbox.sent[bob.Address]
+		if len(sent) != 1 {
+			t.Fatalf("alice's outbox holds %d messages for bob, want 1", len(sent))
+		}
+		return &protocol.NackMessage{From: bob.Address, MessageID: sent[0].ref, SequenceNumber: uint64(sent[0].messageNum)}
+	}
+
+	// A NACK for a message we never sent changes nothing
+	unknown := &protocol.NackMessage{From: bob.Address, SequenceNumber: 99}
+	if err := alice.recoverFromDecryptionNack(unknown); err == nil {
+		t.Fatal("recoverFromDecryptionNack() accepted a NACK for an unknown message")
+	}
+	if session, _ := alice.GetRatchetSession(bob.Address); session != aliceSession {
+		t.Fatal("unknown NACK reset the session")
+	}
+
+	// A real one re-keys the session and resends the message over it
+	nack := nackFor()
+	if err := alice.recoverFromDecryptionNack(nack); err != nil {
+		t.Fatalf("recoverFromDecryptionNack() error = %v", err)
+	}
+	if session, ok := alice.GetRatchetSession(bob.Address); !ok || session == aliceSession {
+		t.Fatal("alice kept the broken session")
+	}
+	waitBobSession(bobSession)
+
+	// The resend is not resent again if it fails too
+	resent := nackFor()
+	if resent.MessageID == nack.MessageID {
+		t.Fatal("resend went out on the old chain")
+	}
+	if err := alice.recoverFromDecryptionNack(resent); err == nil || !strings.Contains(err.Error(), "failed again") {
+		t.Fatalf("recoverFromDecryptionNack() on a resend error = %v, want giving up", err)
+	}
+}
+
+func TestRecoverFromDecryptionNackResetLimit(t *testing.T) {
+	c := NewClient(&rsa.PrivateKey{})
+	peer := protocol.Address{1}
+
+	for range MaxSessionResets {
+		c.ratchetOutbox.allowReset(peer)
+	}
+	header := testRatchetHeader(7, 0)
+	c.ratchetOutbox.record(peer, header, []byte("hi"), nil, false)
+
+	nack := &protocol.NackMessage{From: peer, MessageID: ratchetMessageRef(header), SequenceNumber: 0}
+	if err := c.recoverFromDecryptionNack(nack); err == nil || !strings.Contains(err.Error(), "giving up") {
+		t.Fatalf("recoverFromDecryptionNack() error = %v, want the reset limit", err)
+	}
+}
@@ -0,0 +1,232 @@
+package network
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// MaxDevicesPerIdentity bounds how many devices a message to one user fans out to
+const MaxDevicesPerIdentity = 16
+
+// fanoutDedupSize is how many recent fan-out IDs a device remembers to drop repeats
+const fanoutDedupSize = 4096
+
+var (
+	ErrTooManyDevices = errors.New("too many devices registered for identity")
+	ErrDeviceTaken    = errors.New("device address belongs to another identity")
+)
+
+// deviceRegistry maps user identities to their devices and remembers fan-out IDs already delivered
+type deviceRegistry struct {
+	identity protocol.Address  // Our identity (zero = our own address)
+	deviceID protocol.DeviceID // Our device ID under it
+
+	devices map[protocol.Address][]protocol.Device // Identity -> devices, ordered by ID
+	owners  map[protocol.Address]protocol.Address  // Device address -> identity
+
+	seen     map[protocol.FanoutID]struct{}
+	seenRing [fanoutDedupSize]protocol.FanoutID
+	seenNext int
+
+	mu sync.Mutex
+}
+
+// newDeviceRegistry returns an empty registry for a primary device
+func newDeviceRegistry() *deviceRegistry {
+	return &deviceRegistry{
+		deviceID: protocol.PrimaryDeviceID,
+		devices:  make(map[protocol.Address][]protocol.Device),
+		owners:   make(map[protocol.Address]protocol.Address),
+		seen:     make(map[protocol.FanoutID]struct{}),
+	}
+}
+
+// SetDeviceIdentity makes this client device id of the user identity
+// Fan-out copies for identity are then accepted here, as are direct messages
+// addressed to it. A client is its own identity's primary device by default.
+func (c *Client) SetDeviceIdentity(identity protocol.Address, id protocol.DeviceID) {
+	reg := c.devices
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	reg.identity = identity
+	reg.deviceID = id
+}
+
+// DeviceIdentity returns the user this client is a device of, and its device ID
+func (c *Client) DeviceIdentity() (protocol.Address, protocol.DeviceID) {
+	reg := c.devices
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if reg.identity == (protocol.Address{}) {
+		return c.Address, reg.deviceID
+	}
+	return reg.identity, reg.deviceID
+}
+
+// RegisterDevice adds a device to a user's device list, replacing any with the same ID
+// Once a user has devices registered, SendRatchetMessage to their identity goes
+// to every one of them, so the list should include the primary device.
+func (c *Client) RegisterDevice(identity protocol.Address, device protocol.Device) error {
+	reg := c.devices
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if owner, exists := reg.owners[device.Address]; exists && owner != identity {
+		return fmt.Errorf("%w: %x is a device of %x", ErrDeviceTaken, device.Address[:8], owner[:8])
+	}
+
+	devices := reg.devices[identity]
+	i, found := slices.BinarySearchFunc(devices, device.ID, func(d protocol.Device, id protocol.DeviceID) int {
+		return cmp.Compare(d.ID, id)
+	})
+	if found {
+		delete(reg.owners, devices[i].Address)
+		devices[i] = device
+	} else {
+		if len(devices) >= MaxDevicesPerIdentity {
+			return fmt.Errorf("%w %x (max %d)", ErrTooManyDevices, identity[:8], MaxDevicesPerIdentity)
+		}
+		devices = slices.Insert(devices, i, device)
+	}
+	reg.devices[identity] = devices
+	reg.owners[device.Address] = identity
+
+	log.Printf("📱 Device %d of %x registered at %x", device.ID, identity[:8], device.Address[:8])
+	return nil
+}
+
+// RemoveDevice drops a device from a user's device list
+func (c *Client) RemoveDevice(identity protocol.Address, id protocol.DeviceID) {
+	reg := c.devices
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	devices := reg.devices[identity]
+	for i, device := range devices {
+		if device.ID == id {
+			delete(reg.owners, device.Address)
+			devices = slices.Delete(devices, i, i+1)
+			break
+		}
+	}
+	if len(devices) == 0 {
+		delete(reg.devices, identity)
+	} else {
+		reg.devices[identity] = devices
+	}
+}
+
+// Devices returns a user's registered devices, ordered by device ID
+func (c *Client) Devices(identity protocol.Address) []protocol.Device {
+	reg := c.devices
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	return slices.Clone(reg.devices[identity])
+}
+
+// IdentityOf returns the user a device address belongs to (addr itself if it isn't a registered device)
+func (c *Client) IdentityOf(addr protocol.Address) protocol.Address {
+	reg := c.devices
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if identity, exists := reg.owners[addr]; exists {
+		return identity
+	}
+	return addr
+}
+
+// isOwnIdentity returns true if addr is this device's address or the identity it belongs to
+func (c *Client) isOwnIdentity(addr protocol.Address) bool {
+	if addr == c.Address {
+		return true
+	}
+	identity, _ := c.DeviceIdentity()
+	return addr == identity
+}
+
+// sendRatchetFanout sends one copy of plaintext to each device, each under its own ratchet session
+// The copies share a fan-out ID, which a copy resent after a decryption NACK
+// keeps, so a device that ends up with two delivers the message once.
+// recipientKeyBundle is used only for the device it belongs to; the others use
+// cached or fetched bundles.
+func (c *Client) sendRatchetFanout(ctx context.Context, identity protocol.Address, devices []protocol.Device, recipientKeyBundle *protocol.KeyBundle, plaintext []byte, relayPath []*crypto.RelayInfo) error {
+	fanout := &protocol.DeviceFanout{Identity: identity, Payload: plaintext}
+	if _, err := rand.Read(fanout.FanoutID[:]); err != nil {
+		return fmt.Errorf("failed to generate fan-out ID: %w", err)
+	}
+	_, fanout.SenderDevice = c.DeviceIdentity()
+	payload := protocol.TagPayload(protocol.MsgTypeDeviceFanout, fanout.Encode())
+
+	var errs []error
+	for _, device := range devices {
+		var bundle *protocol.KeyBundle
+		if recipientKeyBundle != nil && recipientKeyBundle.Address == device.Address {
+			bundle = recipientKeyBundle
+		}
+		if err := c.sendRatchetMessage(ctx, device.Address, bundle, payload, relayPath, false); err != nil {
+			errs = append(errs, fmt.Errorf("device %d (%x): %w", device.ID, device.Address[:8], err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("fan-out to %x reached %d of %d devices: %w", identity[:8], len(devices)-len(errs), len(devices), errors.Join(errs...))
+	}
+
+	log.Printf("📱 Message fanned out to %d devices of %x", len(devices), identity[:8])
+	return nil
+}
+
+// handleDeviceFanout delivers a fan-out copy's payload unless an earlier copy was delivered
+func (c *Client) handleDeviceFanout(body []byte) usageKey {
+	var fanout protocol.DeviceFanout
+	if err := fanout.Decode(body); err != nil {
+		log.Printf("Failed to decode device fan-out: %v", err)
+		return usageKey{}
+	}
+	if !c.isOwnIdentity(fanout.Identity) {
+		log.Printf("Dropping device fan-out for %x", fanout.Identity[:8])
+		return usageKey{}
+	}
+
+	msgType, inner, tagged := protocol.UntagPayload(fanout.Payload)
+	if !tagged || msgType == protocol.MsgTypeDeviceFanout {
+		log.Printf("Dropping device fan-out with an invalid payload")
+		return usageKey{}
+	}
+
+	if !c.devices.markDelivered(fanout.FanoutID) {
+		log.Printf("⚠️  Duplicate device fan-out %x from device %d - discarding", fanout.FanoutID[:8], fanout.SenderDevice)
+		return usageKey{}
+	}
+	return c.dispatchPayload(msgType, inner)
+}
+
+// markDelivered records a fan-out ID, returning false if it was already recorded
+// Only the latest fanoutDedupSize IDs are kept.
+func (reg *deviceRegistry) markDelivered(id protocol.FanoutID) bool {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if _, exists := reg.seen[id]; exists {
+		return false
+	}
+	if len(reg.seen) == fanoutDedupSize {
+		delete(reg.seen, reg.seenRing[reg.seenNext])
+	}
+	reg.seen[id] = struct{}{}
+	reg.seenRing[reg.seenNext] = id
+	reg.seenNext = (reg.seenNext + 1) % fanoutDedupSize
+	return true
+}
@@ -2,6 +2,7 @@ package network
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -10,6 +11,9 @@ import (
 	"github.com/ZentaChain/zentalk-node/pkg/protocol"
 )
 
+// ErrDHTNotAttached is returned by DHT operations before AttachDHT is called
+var ErrDHTNotAttached = errors.New("DHT not attached - call AttachDHT() first")
+
 // AttachDHT attaches a DHT node for decentralized key bundle discovery
 func (c *Client) AttachDHT(node *dht.Node) {
 	c.dhtNode = node
@@ -20,11 +24,11 @@ func (c *Client) AttachDHT(node *dht.Node) {
 // This makes the client discoverable by others in the network
 func (c *Client) PublishKeyBundle() error {
 	if c.dhtNode == nil {
-		return fmt.Errorf("DHT not attached - call AttachDHT() first")
+		return ErrDHTNotAttached
 	}
 
-	if c.x3dhIdentity == nil {
-		return fmt.Errorf("X3DH not initialized - call InitializeX3DH() first")
+	if c.GetX3DHIdentity() == nil {
+		return ErrX3DHNotInitialized
 	}
 
 	// Get our key bundle
@@ -42,8 +46,8 @@ func (c *Client) PublishKeyBundle() error {
 	// Use our address as the DHT key
 	dhtKey := dht.NewNodeID(c.Address[:])
 
-	// Publish to DHT with 24-hour TTL
-	if err := c.dhtNode.Store(dhtKey, bundleJSON, 24*time.Hour); err != nil {
+	// Publish to DHT (republished by the node before its record TTL runs out)
+	if err := c.dhtNode.Publish(dhtKey, bundleJSON); err != nil {
 		return fmt.Errorf("failed to publish to DHT: %w", err)
 	}
 
@@ -57,7 +61,7 @@ func (c *Client) PublishKeyBundle() error {
 // Returns the key bundle if found, or error if not found
 func (c *Client) DiscoverKeyBundle(peerAddress protocol.Address) (*protocol.KeyBundle, error) {
 	if c.dhtNode == nil {
-		return nil, fmt.Errorf("DHT not attached - call AttachDHT() first")
+		return nil, ErrDHTNotAttached
 	}
 
 	// Use peer's address as the DHT key
@@ -68,7 +72,7 @@ func (c *Client) DiscoverKeyBundle(peerAddress protocol.Address) (*protocol.KeyB
 	// Lookup in DHT
 	bundleJSON, found := c.dhtNode.Lookup(dhtKey)
 	if !found {
-		return nil, fmt.Errorf("key bundle not found in DHT for %x", peerAddress[:8])
+		return nil, fmt.Errorf("%w in DHT for %x", ErrNoKeyBundle, peerAddress[:8])
 	}
 
 	// Deserialize key bundle
@@ -111,7 +115,7 @@ func (c *Client) AutoPublishKeyBundle(interval time.Duration) {
 // BootstrapDHT bootstraps the DHT from a known peer
 func (c *Client) BootstrapDHT(bootstrapAddress string, bootstrapNodeID dht.NodeID) error {
 	if c.dhtNode == nil {
-		return fmt.Errorf("DHT not attached - call AttachDHT() first")
+		return ErrDHTNotAttached
 	}
 
 	bootstrapContact := dht.NewContact(bootstrapNodeID, bootstrapAddress)
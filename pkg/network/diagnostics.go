@@ -0,0 +1,217 @@
+package network
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+const (
+	// goodRTT and fairRTT are the relay round trips below which a connection is good or fair
+	goodRTT = 300 * time.Millisecond
+	fairRTT = time.Second
+)
+
+// ConnectionQuality summarizes the relay connection for showing to users
+type ConnectionQuality uint8
+
+const (
+	ConnectionOffline ConnectionQuality = iota // Not connected, or reconnecting
+	ConnectionPoor                             // Slow round trips, or a ping went unanswered
+	ConnectionFair                             // Usable but noticeably slow
+	ConnectionGood                             // Fast round trips
+)
+
+// String returns the quality's name
+func (q ConnectionQuality) String() string {
+	switch q {
+	case ConnectionOffline:
+		return "offline"
+	case ConnectionPoor:
+		return "poor"
+	case ConnectionFair:
+		return "fair"
+	case ConnectionGood:
+		return "good"
+	default:
+		return "unknown"
+	}
+}
+
+// ConnectionDiagnostics is a snapshot of the relay connection's health
+// Sizes that can't be read (or have nothing attached) are 0.
+type ConnectionDiagnostics struct {
+	Connected      bool
+	Reconnecting   bool // The connection dropped and is being re-established
+	RelayAddress   string
+	Quality        ConnectionQuality
+	RelayRTT       time.Duration // Last ping or handshake round trip to the relay (0 = not measured)
+	LastAckLatency time.Duration // From first sending the last acknowledged message to its ACK (0 = none yet)
+
+	UnackedMessages int // Sent messages awaiting their ACK
+	BatchedControl  int // Typing, receipts and presence held by low-power mode
+	DeferredMedia   int // Media downloads held until the network allows them
+	OfflineBacklog  int // Messages the relay still had queued for us at its last progress report
+
+	RatchetSessions     int
+	MessageDBBytes      int64
+	SessionStorageBytes int64
+}
+
+// connectionHealth holds the measurements behind Diagnostics
+type connectionHealth struct {
+	mu             sync.Mutex
+	pingID         protocol.MessageID // Last ping sent, answered by a pong with the same ID
+	pingSent       time.Time          // Zero once the ping is answered
+	missedPong     bool               // A ping went unanswered until the next one
+	rtt            time.Duration
+	ackLatency     time.Duration
+	offlineBacklog uint32
+	reconnecting   bool
+	reported       ConnectionQuality // Last quality passed to OnConnectionQualityChanged
+}
+
+// Diagnostics returns the relay connection's current health
+// Apps poll it for a connection details screen; OnConnectionQualityChanged
+// reports just the changes in quality.
+func (c *Client) Diagnostics() ConnectionDiagnostics {
+	h := &c.health
+	h.mu.Lock()
+	diag := ConnectionDiagnostics{
+		Connected:      c.connected.Load(),
+		Reconnecting:   h.reconnecting,
+		RelayAddress:   c.relayAddress,
+		RelayRTT:       h.rtt,
+		LastAckLatency: h.ackLatency,
+		OfflineBacklog: int(h.offlineBacklog),
+	}
+	diag.Quality = h.qualityLocked(diag.Connected)
+	h.mu.Unlock()
+
+	c.sendQueue.mu.Lock()
+	diag.UnackedMessages = c.sendQueue.size
+	c.sendQueue.mu.Unlock()
+
+	c.power.mu.Lock()
+	diag.BatchedControl = len(c.power.batch)
+	diag.DeferredMedia = len(c.power.media)
+	c.power.mu.Unlock()
+
+	c.sessionMu.Lock()
+	diag.RatchetSessions = len(c.ratchetSessions)
+	c.sessionMu.Unlock()
+
+	if c.messageDB != nil {
+		if size, err := c.messageDB.Size(); err == nil {
+			diag.MessageDBBytes = size
+		}
+	}
+	if c.sessionStorage != nil {
+		if size, err := c.sessionStorage.Size(); err == nil {
+			diag.SessionStorageBytes = size
+		}
+	}
+
+	return diag
+}
+
+// qualityLocked rates the connection from the latest measurements; h.mu must be held
+func (h *connectionHealth) qualityLocked(connected bool) ConnectionQuality {
+	switch {
+	case !connected || h.reconnecting:
+		return ConnectionOffline
+	case h.missedPong || h.rtt >= fairRTT:
+		return ConnectionPoor
+	case h.rtt >= goodRTT:
+		return ConnectionFair
+	default:
+		return ConnectionGood
+	}
+}
+
+// updateConnectionQuality reports a change in quality to OnConnectionQualityChanged
+func (c *Client) updateConnectionQuality() {
+	h := &c.health
+	h.mu.Lock()
+	quality := h.qualityLocked(c.connected.Load())
+	changed := quality != h.reported
+	h.reported = quality
+	h.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	log.Printf("📶 Connection quality: %s", quality)
+	if c.OnConnectionQualityChanged != nil {
+		c.OnConnectionQualityChanged(quality)
+	}
+}
+
+// recordLinkUp notes a completed handshake and the round trip it took
+func (c *Client) recordLinkUp(rtt time.Duration) {
+	h := &c.health
+	h.mu.Lock()
+	h.rtt = rtt
+	h.reconnecting = false
+	h.missedPong = false
+	h.pingSent = time.Time{}
+	h.mu.Unlock()
+}
+
+// recordLinkDown notes that the connection dropped and is being re-established
+func (c *Client) recordLinkDown() {
+	h := &c.health
+	h.mu.Lock()
+	h.reconnecting = true
+	h.mu.Unlock()
+
+	c.updateConnectionQuality()
+}
+
+// recordPingSent starts timing a ping; a previous ping still unanswered counts as lost
+func (c *Client) recordPingSent(id protocol.MessageID, now time.Time) {
+	h := &c.health
+	h.mu.Lock()
+	if !h.pingSent.IsZero() {
+		h.missedPong = true
+	}
+	h.pingID = id
+	h.pingSent = now
+	h.mu.Unlock()
+
+	c.updateConnectionQuality()
+}
+
+// recordPong measures the round trip of the ping a pong answers
+func (c *Client) recordPong(id protocol.MessageID, now time.Time) {
+	h := &c.health
+	h.mu.Lock()
+	if h.pingSent.IsZero() || id != h.pingID {
+		h.mu.Unlock()
+		return
+	}
+	h.rtt = now.Sub(h.pingSent)
+	h.pingSent = time.Time{}
+	h.missedPong = false
+	h.mu.Unlock()
+
+	c.updateConnectionQuality()
+}
+
+// recordAckLatency notes how long the last acknowledged message took to be ACKed
+func (c *Client) recordAckLatency(latency time.Duration) {
+	h := &c.health
+	h.mu.Lock()
+	h.ackLatency = latency
+	h.mu.Unlock()
+}
+
+// recordOfflineBacklog notes how many messages the relay still has queued for us
+func (c *Client) recordOfflineBacklog(remaining uint32) {
+	h := &c.health
+	h.mu.Lock()
+	h.offlineBacklog = remaining
+	h.mu.Unlock()
+}
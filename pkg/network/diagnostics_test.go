@@ -0,0 +1,71 @@
+package network
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+func TestConnectionQualityChanges(t *testing.T) {
+	c := newForwardTestClient(t)
+	var reported []ConnectionQuality
+	c.OnConnectionQualityChanged = func(q ConnectionQuality) { reported = append(reported, q) }
+
+	c.connected.Store(true)
+	c.recordLinkUp(50 * time.Millisecond)
+	c.updateConnectionQuality()
+
+	// A slow pong makes the connection fair
+	now := time.Now()
+	c.recordPingSent(protocol.MessageID{1}, now)
+	c.recordPong(protocol.MessageID{1}, now.Add(500*time.Millisecond))
+	if rtt := c.Diagnostics().RelayRTT; rtt != 500*time.Millisecond {
+		t.Errorf("RelayRTT = %v, want 500ms", rtt)
+	}
+
+	// A ping still unanswered when the next goes out makes it poor
+	c.recordPingSent(protocol.MessageID{2}, now.Add(time.Minute))
+	c.recordPingSent(protocol.MessageID{3}, now.Add(2*time.Minute))
+
+	// Pongs for older pings don't count; the latest one brings it back
+	c.recordPong(protocol.MessageID{2}, now.Add(2*time.Minute))
+	c.recordPong(protocol.MessageID{3}, now.Add(2*time.Minute+100*time.Millisecond))
+
+	c.recordLinkDown()
+
+	want := []ConnectionQuality{ConnectionGood, ConnectionFair, ConnectionPoor, ConnectionGood, ConnectionOffline}
+	if !reflect.DeepEqual(reported, want) {
+		t.Errorf("reported qualities = %v, want %v", reported, want)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	c := newForwardTestClient(t)
+	peer := protocol.Address{2}
+
+	c.trackSend(peer, 0, protocol.MessageID{1}, []byte("onion"), true)
+	c.recordOfflineBacklog(12)
+
+	diag := c.Diagnostics()
+	if diag.Connected || diag.Quality != ConnectionOffline {
+		t.Errorf("unconnected client reports %v, connected = %v", diag.Quality, diag.Connected)
+	}
+	if diag.UnackedMessages != 1 || diag.OfflineBacklog != 12 {
+		t.Errorf("UnackedMessages = %d, OfflineBacklog = %d; want 1, 12", diag.UnackedMessages, diag.OfflineBacklog)
+	}
+	if diag.MessageDBBytes == 0 {
+		t.Error("MessageDBBytes = 0 with a database attached")
+	}
+	if diag.LastAckLatency != 0 {
+		t.Errorf("LastAckLatency = %v before any ACK", diag.LastAckLatency)
+	}
+
+	time.Sleep(time.Millisecond)
+	c.ackSend(peer, 0)
+	diag = c.Diagnostics()
+	if diag.UnackedMessages != 0 || diag.LastAckLatency <= 0 {
+		t.Errorf("after the ACK: UnackedMessages = %d, LastAckLatency = %v", diag.UnackedMessages, diag.LastAckLatency)
+	}
+}
@@ -0,0 +1,173 @@
+package network
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// ExitPolicy controls which roles a relay is willing to play in an onion route
+type ExitPolicy uint8
+
+const (
+	// ExitPolicyBoth forwards to other relays and performs final delivery (default)
+	ExitPolicyBoth ExitPolicy = iota
+	// ExitPolicyForwardOnly only forwards to other relays (middle/entry hops)
+	ExitPolicyForwardOnly
+	// ExitPolicyDeliveryOnly only performs final delivery to clients (exit hops)
+	ExitPolicyDeliveryOnly
+)
+
+// String returns the policy name used in config flags and relay metadata
+func (p ExitPolicy) String() string {
+	switch p {
+	case ExitPolicyForwardOnly:
+		return "forward"
+	case ExitPolicyDeliveryOnly:
+		return "delivery"
+	default:
+		return "both"
+	}
+}
+
+// ParseExitPolicy parses a policy name ("both", "forward", "delivery")
+// An empty string is treated as "both" for relays that predate exit policies
+func ParseExitPolicy(s string) (ExitPolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "both":
+		return ExitPolicyBoth, nil
+	case "forward", "forward-only":
+		return ExitPolicyForwardOnly, nil
+	case "delivery", "delivery-only", "exit":
+		return ExitPolicyDeliveryOnly, nil
+	default:
+		return ExitPolicyBoth, fmt.Errorf("unknown exit policy: %q", s)
+	}
+}
+
+// AllowsDelivery returns true if the relay performs final delivery to clients
+func (p ExitPolicy) AllowsDelivery() bool {
+	return p != ExitPolicyForwardOnly
+}
+
+// AllowsForwarding returns true if the relay forwards to other relays
+func (p ExitPolicy) AllowsForwarding() bool {
+	return p != ExitPolicyDeliveryOnly
+}
+
+// SetExitPolicy sets the relay's exit policy and updates advertised metadata
+func (rs *RelayServer) SetExitPolicy(policy ExitPolicy) {
+	rs.mu.Lock()
+	rs.exitPolicy = policy
+	if rs.metadata != nil {
+		rs.metadata.ExitPolicy = policy.String()
+	}
+	rs.mu.Unlock()
+
+	log.Printf("🚪 Exit policy set: %s", policy)
+}
+
+// GetExitPolicy returns the relay's exit policy
+func (rs *RelayServer) GetExitPolicy() ExitPolicy {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.exitPolicy
+}
+
+// validateNextHop checks a decrypted next hop against the exit policy
+// isRelay is true when the next hop is a connected relay peer; anything else is final delivery
+// Errors are *protocol.RelayErrorMessage so they can be sent back to the previous hop as is
+func (rs *RelayServer) validateNextHop(nextHop protocol.Address, isRelay bool) *protocol.RelayErrorMessage {
+	if protocol.IsZeroAddress(nextHop) {
+		return protocol.NewRelayError(protocol.RelayErrMalformed, "next hop is the zero address")
+	}
+
+	// A route that points back at us would loop forever
+	if nextHop == rs.Address {
+		return protocol.NewRelayError(protocol.RelayErrRoutingLoop, "next hop is this relay")
+	}
+
+	policy := rs.GetExitPolicy()
+	if isRelay && !policy.AllowsForwarding() {
+		return protocol.NewRelayError(protocol.RelayErrExitPolicy, "exit policy %q does not allow forwarding to relays", policy)
+	}
+	if !isRelay && !policy.AllowsDelivery() {
+		return protocol.NewRelayError(protocol.RelayErrExitPolicy, "exit policy %q does not allow final delivery", policy)
+	}
+
+	return nil
+}
+
+// sendRelayError tells the previous hop why a message was refused
+func (rs *RelayServer) sendRelayError(conn net.Conn, messageID protocol.MessageID, relayErr *protocol.RelayErrorMessage) error {
+	payload := relayErr.Encode()
+
+	header := &protocol.Header{
+		Magic:     protocol.ProtocolMagic,
+		Version:   protocol.ProtocolVersion,
+		Type:      protocol.MsgTypeRelayError,
+		Length:    uint32(len(payload)),
+		Flags:     0,
+		MessageID: messageID,
+	}
+
+	if err := protocol.WriteHeader(conn, header); err != nil {
+		return err
+	}
+	_, err := conn.Write(payload)
+	return err
+}
+
+// AllowsDelivery returns true if the advertised relay performs final delivery
+func (r *RelayMetadata) AllowsDelivery() bool {
+	policy, err := ParseExitPolicy(r.ExitPolicy)
+	return err == nil && policy.AllowsDelivery()
+}
+
+// AllowsForwarding returns true if the advertised relay forwards to other relays
+func (r *RelayMetadata) AllowsForwarding() bool {
+	policy, err := ParseExitPolicy(r.ExitPolicy)
+	return err == nil && policy.AllowsForwarding()
+}
+
+// arrangeForExitPolicy orders candidates into a route of n hops that respects exit policies:
+// every hop except the last must forward, and the last hop must deliver
+func arrangeForExitPolicy(candidates []*RelayMetadata, n int) ([]*RelayMetadata, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("route must have at least 1 hop")
+	}
+
+	// Pick the exit first, preferring delivery-only relays so dual-role relays stay free for forwarding
+	exitIdx := -1
+	for i, relay := range candidates {
+		if !relay.AllowsDelivery() {
+			continue
+		}
+		if exitIdx == -1 || !relay.AllowsForwarding() {
+			exitIdx = i
+		}
+	}
+	if exitIdx == -1 {
+		return nil, fmt.Errorf("no relay with a delivery-capable exit policy")
+	}
+
+	route := make([]*RelayMetadata, 0, n)
+	for i, relay := range candidates {
+		if len(route) == n-1 {
+			break
+		}
+		if i == exitIdx || !relay.AllowsForwarding() {
+			continue
+		}
+		route = append(route, relay)
+	}
+
+	if len(route) < n-1 {
+		return nil, fmt.Errorf("not enough forwarding relays (need %d, found %d)", n-1, len(route))
+	}
+
+	return append(route, candidates[exitIdx]), nil
+}
@@ -0,0 +1,81 @@
+package network
+
+import (
+	"testing"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+func TestArrangeForExitPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		policies []string // Candidate i has address {i+1}
+		hops     int
+		want     []byte // First address byte of each hop; nil means an error
+	}{
+		{
+			name:     "delivery-only exit preferred",
+			policies: []string{"both", "delivery", "both", "delivery"},
+			hops:     3,
+			want:     []byte{1, 3, 4},
+		},
+		{
+			name:     "first dual-role exit when no delivery-only relay",
+			policies: []string{"both", "forward", "both"},
+			hops:     2,
+			want:     []byte{2, 1},
+		},
+		{
+			name:     "forward-only never last",
+			policies: []string{"forward", "forward", "both"},
+			hops:     3,
+			want:     []byte{1, 2, 3},
+		},
+		{
+			name:     "no delivery-capable relay",
+			policies: []string{"forward", "forward"},
+			hops:     1,
+		},
+		{
+			name:     "too few forwarders",
+			policies: []string{"delivery", "delivery", "both"},
+			hops:     3,
+		},
+		{
+			name:     "no hops",
+			policies: []string{"both"},
+			hops:     0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidates := make([]*RelayMetadata, len(tt.policies))
+			for i, policy := range tt.policies {
+				candidates[i] = &RelayMetadata{Address: protocol.Address{byte(i + 1)}, ExitPolicy: policy}
+			}
+
+			route, err := arrangeForExitPolicy(candidates, tt.hops)
+			if tt.want == nil {
+				if err == nil {
+					t.Fatalf("arrangeForExitPolicy() = %d hops, want error", len(route))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("arrangeForExitPolicy() error = %v", err)
+			}
+
+			got := make([]byte, len(route))
+			for i, relay := range route {
+				got[i] = relay.Address[0]
+			}
+			if string(got) != string(tt.want) {
+				t.Errorf("arrangeForExitPolicy() route = %v, want %v", got, tt.want)
+			}
+			if exit := route[len(route)-1]; !exit.AllowsDelivery() {
+				t.Errorf("arrangeForExitPolicy() exit %v does not deliver", exit.Address[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package network
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+	"github.com/ZentaChain/zentalk-node/pkg/storage"
+)
+
+// ErrNotForwardable is returned for messages that only make sense to the user they were sent to
+var ErrNotForwardable = errors.New("message cannot be forwarded")
+
+// MeshStorageTransfer downloads and uploads encrypted MeshStorage chunks
+type MeshStorageTransfer interface {
+	MeshStorageUploader
+	MeshStorageDownloader
+}
+
+// ForwardMessage forwards a stored message to a contact, attributed to its original sender
+// Media is copied to new chunks under fresh keys (see reshareContent), so the
+// recipient never learns the keys of the chunks the message was sent with.
+func (c *Client) ForwardMessage(messageID string, to protocol.Address, recipientPubKey *rsa.PublicKey, store MeshStorageTransfer, relayPath []*crypto.RelayInfo) error {
+	if !c.connected.Load() {
+		return ErrNotConnected
+	}
+
+	msg, forwarded, err := c.loadForwardable(messageID)
+	if err != nil {
+		return err
+	}
+	store = c.meterTransfers(c.directUsage(to, storage.UsageMedia), store)
+	content, err := c.reshareContent(msg, nil, store)
+	if err != nil {
+		return err
+	}
+
+	extensions := []protocol.MessageExtension{
+		{Type: protocol.ExtensionForwarded, Data: forwarded.Encode()},
+	}
+	if err := c.sendMessageWithExtensions(context.Background(), to, recipientPubKey, content, msg.ContentType, extensions, relayPath); err != nil {
+		return err
+	}
+
+	log.Printf("↪️  Forwarded message %s to %x (hop %d)", messageID, to[:8], forwarded.HopCount)
+	return nil
+}
+
+// ForwardMessageToGroup forwards a stored message to every member of a group
+// Group files are re-shared into the target group under fresh keys.
+func (c *Client) ForwardMessageToGroup(messageID string, group *Group, store MeshStorageTransfer, relayPath []*crypto.RelayInfo) error {
+	if !c.connected.Load() {
+		return ErrNotConnected
+	}
+
+	msg, forwarded, err := c.loadForwardable(messageID)
+	if err != nil {
+		return err
+	}
+	store = c.meterTransfers(groupUsage(group.ID, storage.UsageMedia), store)
+	content, err := c.reshareContent(msg, &group.ID, store)
+	if err != nil {
+		return err
+	}
+	if err := c.ContentTypes().Validate(msg.ContentType, content); err != nil {
+		return err
+	}
+
+	groupMsg := &protocol.GroupMessage{
+		From:        c.Address,
+		GroupID:     group.ID,
+		Timestamp:   uint64(time.Now().UnixMilli()),
+		ContentType: msg.ContentType,
+		Content:     content,
+		MessageID:   protocol.GenerateMessageID(),
+		Forwarded:   forwarded,
+	}
+	if err := c.broadcastGroupMessage(context.Background(), group, groupMsg, relayPath); err != nil {
+		return err
+	}
+
+	log.Printf("↪️  Forwarded message %s to group %x (hop %d)", messageID, group.ID[:8], forwarded.HopCount)
+	return nil
+}
+
+// loadForwardable loads a stored message and the attribution to forward it with
+// A message that was itself forwarded keeps its original sender and timestamp.
+func (c *Client) loadForwardable(messageID string) (*storage.StoredMessage, *protocol.Forwarded, error) {
+	if c.messageDB == nil {
+		return nil, nil, fmt.Errorf("message database not attached")
+	}
+
+	msg, err := c.messageDB.GetMessage(messageID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sender := msg.FromAddress
+	original := &protocol.Forwarded{OriginalTimestamp: uint64(msg.Timestamp)}
+	if msg.ForwardedFrom != "" {
+		sender = msg.ForwardedFrom
+		original.OriginalTimestamp = uint64(msg.ForwardedAt)
+		original.HopCount = uint8(min(msg.ForwardHops, 255))
+	}
+	if original.OriginalSender, err = decodeAddress(sender); err != nil {
+		return nil, nil, fmt.Errorf("message %s has an %w", messageID, err)
+	}
+
+	return msg, original.Next(), nil
+}
+
+// reshareContent returns a stored message's content ready to forward
+// Content referencing MeshStorage chunks is downloaded and uploaded again under
+// fresh keys, so whoever receives the forward can neither fetch the original
+// chunks nor tell that both messages share them. Stickers and GIFs point at
+// published packs and are forwarded as they are. targetGroup is the group the
+// message is forwarded to (nil for a contact).
+func (c *Client) reshareContent(msg *storage.StoredMessage, targetGroup *protocol.GroupID, store MeshStorageTransfer) ([]byte, error) {
+	switch msg.ContentType {
+	case protocol.ContentTypeImage, protocol.ContentTypeVideo, protocol.ContentTypeAudio, protocol.ContentTypeFile:
+		if len(msg.Content) != 8+32 {
+			return msg.Content, nil // Inline media carries no keys
+		}
+		chunkID, key, err := ParseMediaMessage(msg.Content)
+		if err != nil {
+			return nil, err
+		}
+		data, err := store.DownloadEncrypted(chunkID, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download media to forward: %w", err)
+		}
+		newChunkID, newKey, err := store.UploadEncrypted(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload forwarded media: %w", err)
+		}
+		content := make([]byte, 8+32)
+		binary.BigEndian.PutUint64(content, newChunkID)
+		copy(content[8:], newKey)
+		return content, nil
+
+	case protocol.ContentTypeVoiceNote:
+		var note protocol.VoiceNoteMessage
+		if err := note.Decode(msg.Content); err != nil {
+			return nil, err
+		}
+		audio, err := FetchVoiceNote(&note, store)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download voice note to forward: %w", err)
+		}
+		duration := time.Duration(note.DurationMs) * time.Millisecond
+		copied, err := UploadVoiceNote(audio, note.MIMEType, duration, note.Waveform, store)
+		if err != nil {
+			return nil, err
+		}
+		return copied.Encode(), nil
+
+	case protocol.ContentTypeGroupFile:
+		if targetGroup == nil {
+			return nil, fmt.Errorf("%w: group files can only be forwarded to groups", ErrNotForwardable)
+		}
+		var share protocol.GroupFileShare
+		if err := share.Decode(msg.Content); err != nil {
+			return nil, err
+		}
+		var sourceGroup protocol.GroupID
+		groupID, err := hex.DecodeString(msg.ToAddress)
+		if err != nil || len(groupID) != len(sourceGroup) {
+			return nil, fmt.Errorf("group file message %s has no group", msg.MessageID)
+		}
+		copy(sourceGroup[:], groupID)
+		manifest, data, err := FetchGroupFile(sourceGroup, &share, store)
+		if err != nil {
+			return nil, err
+		}
+		copied, err := UploadGroupFile(*targetGroup, c.Address, manifest.Name, manifest.MIMEType, data, store)
+		if err != nil {
+			return nil, err
+		}
+		return copied.Encode(), nil
+
+	case protocol.ContentTypeRecoveryShare, protocol.ContentTypeRecoveryRequest, protocol.ContentTypeReadStateSync:
+		return nil, fmt.Errorf("%w: content type 0x%02x", ErrNotForwardable, msg.ContentType)
+	}
+
+	return msg.Content, nil
+}
+
+// recordForwarded copies a message's forward attribution into its stored form (nil = not forwarded)
+func recordForwarded(stored *storage.StoredMessage, forwarded *protocol.Forwarded) {
+	if forwarded == nil {
+		return
+	}
+	stored.ForwardedFrom = hex.EncodeToString(forwarded.OriginalSender[:])
+	stored.ForwardedAt = int64(forwarded.OriginalTimestamp)
+	stored.ForwardHops = int(forwarded.HopCount)
+}
@@ -0,0 +1,132 @@
+package network
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+	"github.com/ZentaChain/zentalk-node/pkg/storage"
+)
+
+// newForwardTestClient creates an unconnected client with a message database
+func newForwardTestClient(t *testing.T) *Client {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(key)
+	c.messageDB = newSendQueueDB(t)
+	return c
+}
+
+func TestForwardAttribution(t *testing.T) {
+	c := newForwardTestClient(t)
+	alice, bob := protocol.Address{0xA1}, protocol.Address{0xB0}
+	group := protocol.GroupID{5}
+
+	// Bob's own message: forwarding it attributes it to Bob
+	c.saveGroupMessage(&protocol.GroupMessage{
+		From:      bob,
+		GroupID:   group,
+		Timestamp: 1_700_000_000_000,
+		Content:   []byte("written by bob"),
+		MessageID: protocol.MessageID{1},
+	}, false)
+
+	_, forwarded, err := c.loadForwardable(fmt.Sprintf("%x", protocol.MessageID{1}))
+	if err != nil {
+		t.Fatalf("loadForwardable() error = %v", err)
+	}
+	want := protocol.Forwarded{OriginalSender: bob, OriginalTimestamp: 1_700_000_000_000, HopCount: 1}
+	if *forwarded != want {
+		t.Errorf("attribution = %+v, want %+v", *forwarded, want)
+	}
+
+	// Bob forwarding Alice's message: forwarding it again still credits Alice
+	c.saveGroupMessage(&protocol.GroupMessage{
+		From:      bob,
+		GroupID:   group,
+		Timestamp: 1_700_000_500_000,
+		Content:   []byte("written by alice"),
+		MessageID: protocol.MessageID{2},
+		Forwarded: &protocol.Forwarded{OriginalSender: alice, OriginalTimestamp: 1_600_000_000_000, HopCount: 2},
+	}, false)
+
+	_, forwarded, err = c.loadForwardable(fmt.Sprintf("%x", protocol.MessageID{2}))
+	if err != nil {
+		t.Fatalf("loadForwardable() error = %v", err)
+	}
+	want = protocol.Forwarded{OriginalSender: alice, OriginalTimestamp: 1_600_000_000_000, HopCount: 3}
+	if *forwarded != want {
+		t.Errorf("attribution = %+v, want %+v", *forwarded, want)
+	}
+
+	if _, _, err := c.loadForwardable("unknown"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("loadForwardable(unknown) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestForwardReuploadsMedia(t *testing.T) {
+	c := newForwardTestClient(t)
+	store := newMemStore()
+
+	image := []byte("not really a jpeg")
+	chunkID, key, _ := store.UploadEncrypted(image)
+	content := make([]byte, 40)
+	content[7] = byte(chunkID)
+	copy(content[8:], key)
+
+	msg := &storage.StoredMessage{ContentType: protocol.ContentTypeImage, Content: content}
+	forwarded, err := c.reshareContent(msg, nil, store)
+	if err != nil {
+		t.Fatalf("reshareContent(image) error = %v", err)
+	}
+	newChunkID, newKey, _ := ParseMediaMessage(forwarded)
+	if newChunkID == chunkID || bytes.Equal(newKey, key) {
+		t.Error("forwarded image shares the original chunk or key")
+	}
+	if data, err := store.DownloadEncrypted(newChunkID, newKey); err != nil || !bytes.Equal(data, image) {
+		t.Errorf("forwarded image = %q, %v", data, err)
+	}
+
+	// Group files are re-shared into the target group, never to contacts
+	source, target := protocol.GroupID{1}, protocol.GroupID{2}
+	share, err := UploadGroupFile(source, protocol.Address{7}, "notes.txt", "text/plain", []byte("notes"), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg = &storage.StoredMessage{
+		ContentType: protocol.ContentTypeGroupFile,
+		Content:     share.Encode(),
+		ToAddress:   hex.EncodeToString(source[:]),
+	}
+	if _, err := c.reshareContent(msg, nil, store); !errors.Is(err, ErrNotForwardable) {
+		t.Errorf("reshareContent(group file to contact) error = %v, want ErrNotForwardable", err)
+	}
+	forwarded, err = c.reshareContent(msg, &target, store)
+	if err != nil {
+		t.Fatalf("reshareContent(group file) error = %v", err)
+	}
+	var copied protocol.GroupFileShare
+	if err := copied.Decode(forwarded); err != nil {
+		t.Fatal(err)
+	}
+	if copied.ManifestKey == share.ManifestKey {
+		t.Error("re-shared group file reuses the original manifest key")
+	}
+	if _, data, err := FetchGroupFile(target, &copied, store); err != nil || string(data) != "notes" {
+		t.Errorf("FetchGroupFile(re-shared) = %q, %v", data, err)
+	}
+
+	msg = &storage.StoredMessage{ContentType: protocol.ContentTypeRecoveryShare, Content: []byte{1}}
+	if _, err := c.reshareContent(msg, nil, store); !errors.Is(err, ErrNotForwardable) {
+		t.Errorf("reshareContent(recovery share) error = %v, want ErrNotForwardable", err)
+	}
+}
@@ -0,0 +1,233 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+	"github.com/ZentaChain/zentalk-node/pkg/storage"
+)
+
+// GroupFileChunkSize is the size of each encrypted chunk a shared file is split into
+const GroupFileChunkSize = 1 << 20
+
+// ErrGroupFileMismatch is returned when a downloaded file is not the one its share describes
+var ErrGroupFileMismatch = errors.New("group file does not match its share")
+
+// SharedGroupFile is a file shared in a group, as recorded in the message history
+type SharedGroupFile struct {
+	Share     protocol.GroupFileShare
+	From      protocol.Address
+	MessageID string
+	SharedAt  time.Time
+}
+
+// ShareGroupFile uploads a file and shares it with every member of a group
+// The file's chunks and manifest are stored encrypted in MeshStorage; the group
+// message carries only the manifest's location and key, encrypted to each member.
+func (c *Client) ShareGroupFile(group *Group, name, mimeType string, data []byte, store MeshStorageUploader, relayPath []*crypto.RelayInfo) (*protocol.GroupFileShare, error) {
+	if !c.connected.Load() {
+		return nil, ErrNotConnected
+	}
+
+	store = c.meterUploads(groupUsage(group.ID, storage.UsageMedia), store)
+	share, err := UploadGroupFile(group.ID, c.Address, name, mimeType, data, store)
+	if err != nil {
+		return nil, err
+	}
+
+	content := share.Encode()
+	if err := c.ContentTypes().Validate(protocol.ContentTypeGroupFile, content); err != nil {
+		return nil, err
+	}
+
+	groupMsg := &protocol.GroupMessage{
+		From:        c.Address,
+		GroupID:     group.ID,
+		Timestamp:   uint64(time.Now().UnixMilli()),
+		ContentType: protocol.ContentTypeGroupFile,
+		Content:     content,
+		MessageID:   protocol.GenerateMessageID(),
+	}
+	if err := c.broadcastGroupMessage(context.Background(), group, groupMsg, relayPath); err != nil {
+		return nil, err
+	}
+
+	log.Printf("📁 Shared %s in group %x (%d bytes)", name, group.ID[:8], len(data))
+	return share, nil
+}
+
+// UploadGroupFile splits a file into GroupFileChunkSize chunks, uploads them and their manifest
+// Returns the share to send to the group; nothing is sent.
+func UploadGroupFile(groupID protocol.GroupID, owner protocol.Address, name, mimeType string, data []byte, store MeshStorageUploader) (*protocol.GroupFileShare, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("group file is empty")
+	}
+	numChunks := (len(data) + GroupFileChunkSize - 1) / GroupFileChunkSize
+	if numChunks > protocol.MaxGroupFileChunks {
+		return nil, fmt.Errorf("group file too large: %d bytes (max %d)", len(data), int64(protocol.MaxGroupFileChunks)*GroupFileChunkSize)
+	}
+
+	digest := sha256.Sum256(data)
+	manifest := &protocol.GroupFileManifest{
+		Version:   protocol.GroupFileManifestVersion,
+		GroupID:   groupID,
+		Name:      name,
+		MIMEType:  mimeType,
+		Size:      int64(len(data)),
+		SHA256:    digest[:],
+		Owner:     owner,
+		CreatedAt: time.Now().UnixMilli(),
+		Chunks:    make([]protocol.GroupFileChunk, 0, numChunks),
+	}
+	if _, err := rand.Read(manifest.FileID[:]); err != nil {
+		return nil, fmt.Errorf("failed to generate file ID: %w", err)
+	}
+
+	for offset := 0; offset < len(data); offset += GroupFileChunkSize {
+		end := offset + GroupFileChunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+
+		chunkID, key, err := store.UploadEncrypted(data[offset:end])
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload group file chunk %d: %w", len(manifest.Chunks), err)
+		}
+		manifest.Chunks = append(manifest.Chunks, protocol.GroupFileChunk{
+			ChunkID:       chunkID,
+			EncryptionKey: key,
+			Size:          end - offset,
+		})
+	}
+
+	if err := manifest.Validate(); err != nil {
+		return nil, err
+	}
+	encoded, err := manifest.Encode()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	manifestChunk, manifestKey, err := store.UploadEncrypted(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload manifest: %w", err)
+	}
+
+	share := &protocol.GroupFileShare{
+		FileID:        manifest.FileID,
+		ManifestChunk: manifestChunk,
+		Size:          uint64(len(data)),
+		Name:          name,
+		MIMEType:      mimeType,
+	}
+	copy(share.ManifestKey[:], manifestKey)
+	return share, nil
+}
+
+// FetchGroupFile downloads a file shared in a group and checks it against its manifest
+// The manifest must name the share's file and groupID, and the downloaded bytes
+// must match its size and digest; otherwise ErrGroupFileMismatch is returned.
+func FetchGroupFile(groupID protocol.GroupID, share *protocol.GroupFileShare, store MeshStorageDownloader) (*protocol.GroupFileManifest, []byte, error) {
+	encoded, err := store.DownloadEncrypted(share.ManifestChunk, share.ManifestKey[:])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to download manifest: %w", err)
+	}
+	manifest, err := protocol.DecodeGroupFileManifest(encoded)
+	if err != nil {
+		return nil, nil, err
+	}
+	if manifest.FileID != share.FileID {
+		return nil, nil, fmt.Errorf("%w: manifest is for another file", ErrGroupFileMismatch)
+	}
+	if manifest.GroupID != groupID {
+		return nil, nil, fmt.Errorf("%w: file was shared in another group", ErrGroupFileMismatch)
+	}
+
+	data := make([]byte, 0, manifest.Size)
+	for i, chunk := range manifest.Chunks {
+		part, err := store.DownloadEncrypted(chunk.ChunkID, chunk.EncryptionKey)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to download group file chunk %d: %w", i, err)
+		}
+		if len(part) != chunk.Size {
+			return nil, nil, fmt.Errorf("%w: chunk %d is %d bytes, want %d", ErrGroupFileMismatch, i, len(part), chunk.Size)
+		}
+		data = append(data, part...)
+	}
+
+	digest := sha256.Sum256(data)
+	if !bytes.Equal(digest[:], manifest.SHA256) {
+		return nil, nil, fmt.Errorf("%w: digest differs", ErrGroupFileMismatch)
+	}
+	return manifest, data, nil
+}
+
+// GroupFiles returns the files shared in a group, newest first
+// Files come from the stored message history, so they include those this
+// client shared and those it received while a member.
+func (c *Client) GroupFiles(groupID protocol.GroupID, limit, offset int) ([]*SharedGroupFile, error) {
+	if c.messageDB == nil {
+		return nil, fmt.Errorf("message database not attached")
+	}
+
+	conversationID := storage.GetGroupConversationID(hex.EncodeToString(groupID[:]))
+	messages, err := c.messageDB.GetMessagesByContentType(conversationID, protocol.ContentTypeGroupFile, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]*SharedGroupFile, 0, len(messages))
+	for _, msg := range messages {
+		file := &SharedGroupFile{
+			MessageID: msg.MessageID,
+			SharedAt:  time.UnixMilli(msg.Timestamp),
+		}
+		if err := file.Share.Decode(msg.Content); err != nil {
+			log.Printf("⚠️  Skipping malformed group file share %s: %v", msg.MessageID, err)
+			continue
+		}
+		if from, err := decodeAddress(msg.FromAddress); err == nil {
+			file.From = from
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// FetchSharedGroupFile downloads a file listed by GroupFiles
+func (c *Client) FetchSharedGroupFile(groupID protocol.GroupID, fileID protocol.GroupFileID, store MeshStorageDownloader) (*protocol.GroupFileManifest, []byte, error) {
+	share, err := c.findGroupFile(groupID, fileID)
+	if err != nil {
+		return nil, nil, err
+	}
+	store = c.MeterMediaDownloads(groupUsage(groupID, storage.UsageMedia).conversation, store)
+	return FetchGroupFile(groupID, share, store)
+}
+
+// findGroupFile looks up a file's share in the group's message history
+func (c *Client) findGroupFile(groupID protocol.GroupID, fileID protocol.GroupFileID) (*protocol.GroupFileShare, error) {
+	const page = 100
+	for offset := 0; ; offset += page {
+		files, err := c.GroupFiles(groupID, page, offset)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if file.Share.FileID == fileID {
+				return &file.Share, nil
+			}
+		}
+		if len(files) < page {
+			return nil, fmt.Errorf("file %x was not shared in group %x", fileID[:4], groupID[:8])
+		}
+	}
+}
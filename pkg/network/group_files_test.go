@@ -0,0 +1,151 @@
+package network
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// memStore is an in-memory MeshStorage that checks keys on download
+type memStore struct {
+	chunks map[uint64][]byte
+	keys   map[uint64][]byte
+}
+
+func newMemStore() *memStore {
+	return &memStore{chunks: make(map[uint64][]byte), keys: make(map[uint64][]byte)}
+}
+
+func (s *memStore) UploadEncrypted(data []byte) (uint64, []byte, error) {
+	id := uint64(len(s.chunks) + 1)
+	key := make([]byte, 32)
+	rand.Read(key)
+	s.chunks[id] = append([]byte(nil), data...)
+	s.keys[id] = key
+	return id, key, nil
+}
+
+func (s *memStore) DownloadEncrypted(chunkID uint64, key []byte) ([]byte, error) {
+	data, ok := s.chunks[chunkID]
+	if !ok || !bytes.Equal(s.keys[chunkID], key) {
+		return nil, fmt.Errorf("chunk %d not found", chunkID)
+	}
+	return data, nil
+}
+
+func TestGroupFileRoundTrip(t *testing.T) {
+	store := newMemStore()
+	group := protocol.GroupID{1}
+	data := make([]byte, 2*GroupFileChunkSize+123)
+	rand.Read(data)
+
+	share, err := UploadGroupFile(group, protocol.Address{7}, "backup.tar", "application/x-tar", data, store)
+	if err != nil {
+		t.Fatalf("UploadGroupFile() error = %v", err)
+	}
+	if share.Size != uint64(len(data)) || share.Name != "backup.tar" {
+		t.Errorf("share = %+v", share)
+	}
+	if len(store.chunks) != 4 {
+		t.Errorf("uploaded %d chunks, want 3 and the manifest", len(store.chunks))
+	}
+
+	manifest, fetched, err := FetchGroupFile(group, share, store)
+	if err != nil {
+		t.Fatalf("FetchGroupFile() error = %v", err)
+	}
+	if !bytes.Equal(fetched, data) {
+		t.Error("fetched file differs from the shared one")
+	}
+	if manifest.Owner != (protocol.Address{7}) || manifest.MIMEType != "application/x-tar" {
+		t.Errorf("manifest = %+v", manifest)
+	}
+
+	// A share forwarded into another group is refused
+	if _, _, err := FetchGroupFile(protocol.GroupID{2}, share, store); !errors.Is(err, ErrGroupFileMismatch) {
+		t.Errorf("FetchGroupFile(other group) error = %v, want ErrGroupFileMismatch", err)
+	}
+
+	// So is a chunk that was swapped in storage
+	store.chunks[2][0] ^= 0xFF
+	if _, _, err := FetchGroupFile(group, share, store); !errors.Is(err, ErrGroupFileMismatch) {
+		t.Errorf("FetchGroupFile(tampered) error = %v, want ErrGroupFileMismatch", err)
+	}
+
+	if _, err := UploadGroupFile(group, protocol.Address{7}, "empty", "text/plain", nil, store); err == nil {
+		t.Error("UploadGroupFile() accepted an empty file")
+	}
+}
+
+func TestGroupFilesListsHistory(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(key)
+	c.messageDB = newSendQueueDB(t)
+
+	store := newMemStore()
+	group := protocol.GroupID{3}
+	sender := protocol.Address{9}
+
+	var shares []*protocol.GroupFileShare
+	for i, name := range []string{"a.txt", "b.txt"} {
+		share, err := UploadGroupFile(group, sender, name, "text/plain", []byte(name), store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		shares = append(shares, share)
+
+		// As received from another member
+		c.saveGroupMessage(&protocol.GroupMessage{
+			From:        sender,
+			GroupID:     group,
+			Timestamp:   uint64(1_700_000_000_000 + i),
+			ContentType: protocol.ContentTypeGroupFile,
+			Content:     share.Encode(),
+			MessageID:   protocol.GenerateMessageID(),
+		}, false)
+	}
+
+	// Text in the same group is not a file
+	c.saveGroupMessage(&protocol.GroupMessage{
+		From:        sender,
+		GroupID:     group,
+		Timestamp:   1_700_000_000_005,
+		ContentType: protocol.ContentTypeText,
+		Content:     []byte("see the files above"),
+		MessageID:   protocol.GenerateMessageID(),
+	}, false)
+
+	files, err := c.GroupFiles(group, 10, 0)
+	if err != nil {
+		t.Fatalf("GroupFiles() error = %v", err)
+	}
+	if len(files) != 2 || files[0].Share.Name != "b.txt" || files[1].Share.Name != "a.txt" {
+		t.Fatalf("GroupFiles() = %+v, want b.txt then a.txt", files)
+	}
+	if files[0].From != sender {
+		t.Errorf("From = %x, want %x", files[0].From, sender)
+	}
+
+	_, data, err := c.FetchSharedGroupFile(group, shares[0].FileID, store)
+	if err != nil {
+		t.Fatalf("FetchSharedGroupFile() error = %v", err)
+	}
+	if string(data) != "a.txt" {
+		t.Errorf("fetched %q, want a.txt", data)
+	}
+
+	if _, _, err := c.FetchSharedGroupFile(group, protocol.GroupFileID{0xFF}, store); err == nil {
+		t.Error("FetchSharedGroupFile() found a file never shared")
+	}
+	if files, _ := c.GroupFiles(protocol.GroupID{4}, 10, 0); len(files) != 0 {
+		t.Errorf("GroupFiles(other group) = %d files", len(files))
+	}
+}
@@ -1,12 +1,14 @@
 package network
 
 import (
+	"context"
 	"crypto/rsa"
 	"log"
 	"time"
 
 	"github.com/ZentaChain/zentalk-node/pkg/crypto"
 	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+	"github.com/ZentaChain/zentalk-node/pkg/storage"
 )
 
 // GroupMember represents a member of a group
@@ -24,8 +26,28 @@ type Group struct {
 
 // SendGroupMessage sends a message to all group members through onion routing
 func (c *Client) SendGroupMessage(group *Group, content string, relayPath []*crypto.RelayInfo) error {
-	if !c.connected {
-		return ErrNotConnected
+	_, err := c.SendGroupMessageWithOptions(group, content, nil, relayPath)
+	return err
+}
+
+// SendGroupMessageContext is SendGroupMessage bounded by ctx
+func (c *Client) SendGroupMessageContext(ctx context.Context, group *Group, content string, relayPath []*crypto.RelayInfo) error {
+	_, err := c.SendGroupMessageWithOptionsContext(ctx, group, content, nil, relayPath)
+	return err
+}
+
+// SendGroupMessageWithOptions sends a group message with optional thread parent and mentions
+// Returns the message ID so later replies can reference it
+func (c *Client) SendGroupMessageWithOptions(group *Group, content string, opts *GroupMessageOptions, relayPath []*crypto.RelayInfo) (protocol.MessageID, error) {
+	return c.SendGroupMessageWithOptionsContext(context.Background(), group, content, opts, relayPath)
+}
+
+// SendGroupMessageWithOptionsContext is SendGroupMessageWithOptions bounded by ctx
+// Members not reached before ctx is done are skipped and the context's error is
+// returned; the message is not saved locally in that case.
+func (c *Client) SendGroupMessageWithOptionsContext(ctx context.Context, group *Group, content string, opts *GroupMessageOptions, relayPath []*crypto.RelayInfo) (protocol.MessageID, error) {
+	if !c.connected.Load() {
+		return protocol.MessageID{}, ErrNotConnected
 	}
 
 	// Create group message
@@ -35,10 +57,29 @@ func (c *Client) SendGroupMessage(group *Group, content string, relayPath []*cry
 		Timestamp:   uint64(time.Now().UnixMilli()),
 		ContentType: protocol.ContentTypeText,
 		Content:     []byte(content),
+		MessageID:   protocol.GenerateMessageID(),
+	}
+
+	if opts != nil {
+		groupMsg.ThreadParent = opts.ThreadParent
+		groupMsg.Mentions = opts.Mentions
+		if err := groupMsg.ValidateMentions(); err != nil {
+			return protocol.MessageID{}, err
+		}
 	}
 
+	if err := c.broadcastGroupMessage(ctx, group, groupMsg, relayPath); err != nil {
+		return protocol.MessageID{}, err
+	}
+	return groupMsg.MessageID, nil
+}
+
+// broadcastGroupMessage encrypts a group message to each member, sends it and saves it locally
+// Members not reached before ctx is done are skipped and the context's error is
+// returned without saving the message.
+func (c *Client) broadcastGroupMessage(ctx context.Context, group *Group, groupMsg *protocol.GroupMessage, relayPath []*crypto.RelayInfo) error {
 	// Encode the group message once
-	groupMsgPayload := groupMsg.Encode()
+	groupMsgPayload := protocol.TagPayload(protocol.MsgTypeGroupMessage, groupMsg.Encode())
 
 	log.Printf("Sending group message to %d members", len(group.Members))
 
@@ -48,6 +89,9 @@ func (c *Client) SendGroupMessage(group *Group, content string, relayPath []*cry
 		if member.Address == c.Address {
 			continue
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
 		// Encrypt group message with each member's public key (E2E encryption)
 		encryptedMsg, err := crypto.RSAEncrypt(groupMsgPayload, member.PublicKey)
@@ -57,8 +101,11 @@ func (c *Client) SendGroupMessage(group *Group, content string, relayPath []*cry
 		}
 
 		// Build onion layers for this member
-		onion, err := crypto.BuildOnionLayers(relayPath, member.Address, encryptedMsg)
+		onion, err := c.buildOnionLayersContext(ctx, relayPath, member.Address, encryptedMsg)
 		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
 			log.Printf("Failed to build onion for member %x: %v", member.Address, err)
 			continue
 		}
@@ -74,13 +121,15 @@ func (c *Client) SendGroupMessage(group *Group, content string, relayPath []*cry
 		}
 
 		// Send to relay
-		if err := protocol.WriteHeader(c.relayConn, header); err != nil {
-			log.Printf("Failed to send header for member %x: %v", member.Address, err)
+		if err := c.checkSend(header, relayPath, len(encryptedMsg)); err != nil {
+			log.Printf("Not sending to member %x: %v", member.Address, err)
 			continue
 		}
-
-		if _, err := c.relayConn.Write(onion); err != nil {
-			log.Printf("Failed to send payload for member %x: %v", member.Address, err)
+		if err := c.writeFrameAs(ctx, groupUsage(group.ID, contentUsage(groupMsg.ContentType)), header, onion); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			log.Printf("Failed to send to member %x: %v", member.Address, err)
 			continue
 		}
 
@@ -88,12 +137,14 @@ func (c *Client) SendGroupMessage(group *Group, content string, relayPath []*cry
 	}
 
 	log.Printf("Group message broadcast complete to group %x", group.ID)
+
+	c.saveGroupMessage(groupMsg, true)
 	return nil
 }
 
 // CreateGroup creates a new group and notifies all members
 func (c *Client) CreateGroup(groupID protocol.GroupID, groupName string, members []*GroupMember, relayPath []*crypto.RelayInfo) error {
-	if !c.connected {
+	if !c.connected.Load() {
 		return ErrNotConnected
 	}
 
@@ -111,7 +162,7 @@ func (c *Client) CreateGroup(groupID protocol.GroupID, groupName string, members
 		Members:     memberAddrs,
 	}
 
-	createPayload := createMsg.Encode()
+	createPayload := protocol.TagPayload(protocol.MsgTypeGroupCreate, createMsg.Encode())
 
 	log.Printf("Creating group '%s' with %d members", groupName, len(members))
 
@@ -130,7 +181,7 @@ func (c *Client) CreateGroup(groupID protocol.GroupID, groupName string, members
 		}
 
 		// Build onion layers
-		onion, err := crypto.BuildOnionLayers(relayPath, member.Address, encryptedMsg)
+		onion, err := c.buildOnionLayers(relayPath, member.Address, encryptedMsg)
 		if err != nil {
 			log.Printf("Failed to build onion for member %x: %v", member.Address, err)
 			continue
@@ -147,13 +198,12 @@ func (c *Client) CreateGroup(groupID protocol.GroupID, groupName string, members
 		}
 
 		// Send to relay
-		if err := protocol.WriteHeader(c.relayConn, header); err != nil {
-			log.Printf("Failed to send header for member %x: %v", member.Address, err)
+		if err := c.checkSend(header, relayPath, len(encryptedMsg)); err != nil {
+			log.Printf("Not sending to member %x: %v", member.Address, err)
 			continue
 		}
-
-		if _, err := c.relayConn.Write(onion); err != nil {
-			log.Printf("Failed to send payload for member %x: %v", member.Address, err)
+		if err := c.writeFrameAs(context.Background(), groupUsage(groupID, storage.UsageControl), header, onion); err != nil {
+			log.Printf("Failed to send to member %x: %v", member.Address, err)
 			continue
 		}
 
@@ -166,7 +216,7 @@ func (c *Client) CreateGroup(groupID protocol.GroupID, groupName string, members
 
 // LeaveGroup leaves a group and notifies all members
 func (c *Client) LeaveGroup(groupID protocol.GroupID, members []*GroupMember, relayPath []*crypto.RelayInfo) error {
-	if !c.connected {
+	if !c.connected.Load() {
 		return ErrNotConnected
 	}
 
@@ -185,7 +235,7 @@ func (c *Client) LeaveGroup(groupID protocol.GroupID, members []*GroupMember, re
 	}
 	leaveMsg.Signature = signature
 
-	leavePayload := leaveMsg.Encode()
+	leavePayload := protocol.TagPayload(protocol.MsgTypeGroupLeave, leaveMsg.Encode())
 
 	log.Printf("Leaving group %x", groupID)
 
@@ -204,7 +254,7 @@ func (c *Client) LeaveGroup(groupID protocol.GroupID, members []*GroupMember, re
 		}
 
 		// Build onion layers
-		onion, err := crypto.BuildOnionLayers(relayPath, member.Address, encryptedMsg)
+		onion, err := c.buildOnionLayers(relayPath, member.Address, encryptedMsg)
 		if err != nil {
 			log.Printf("Failed to build onion for member %x: %v", member.Address, err)
 			continue
@@ -221,13 +271,12 @@ func (c *Client) LeaveGroup(groupID protocol.GroupID, members []*GroupMember, re
 		}
 
 		// Send to relay
-		if err := protocol.WriteHeader(c.relayConn, header); err != nil {
-			log.Printf("Failed to send header for member %x: %v", member.Address, err)
+		if err := c.checkSend(header, relayPath, len(encryptedMsg)); err != nil {
+			log.Printf("Not sending to member %x: %v", member.Address, err)
 			continue
 		}
-
-		if _, err := c.relayConn.Write(onion); err != nil {
-			log.Printf("Failed to send payload for member %x: %v", member.Address, err)
+		if err := c.writeFrameAs(context.Background(), groupUsage(groupID, storage.UsageControl), header, onion); err != nil {
+			log.Printf("Failed to send to member %x: %v", member.Address, err)
 			continue
 		}
 
@@ -248,7 +297,7 @@ func (c *Client) UpdateGroup(
 	newGroupName string,
 	targetMember *GroupMember,
 ) error {
-	if !c.connected {
+	if !c.connected.Load() {
 		return ErrNotConnected
 	}
 
@@ -274,7 +323,7 @@ func (c *Client) UpdateGroup(
 	}
 	updateMsg.Signature = signature
 
-	updatePayload := updateMsg.Encode()
+	updatePayload := protocol.TagPayload(protocol.MsgTypeGroupUpdate, updateMsg.Encode())
 
 	// Log the update type
 	switch updateType {
@@ -303,7 +352,7 @@ func (c *Client) UpdateGroup(
 		}
 
 		// Build onion layers
-		onion, err := crypto.BuildOnionLayers(relayPath, member.Address, encryptedMsg)
+		onion, err := c.buildOnionLayers(relayPath, member.Address, encryptedMsg)
 		if err != nil {
 			log.Printf("Failed to build onion for member %x: %v", member.Address, err)
 			continue
@@ -320,13 +369,12 @@ func (c *Client) UpdateGroup(
 		}
 
 		// Send to relay
-		if err := protocol.WriteHeader(c.relayConn, header); err != nil {
-			log.Printf("Failed to send header for member %x: %v", member.Address, err)
+		if err := c.checkSend(header, relayPath, len(encryptedMsg)); err != nil {
+			log.Printf("Not sending to member %x: %v", member.Address, err)
 			continue
 		}
-
-		if _, err := c.relayConn.Write(onion); err != nil {
-			log.Printf("Failed to send payload for member %x: %v", member.Address, err)
+		if err := c.writeFrameAs(context.Background(), groupUsage(groupID, storage.UsageControl), header, onion); err != nil {
+			log.Printf("Failed to send to member %x: %v", member.Address, err)
 			continue
 		}
 
@@ -344,7 +392,7 @@ func (c *Client) JoinGroup(
 	adminPubKey *rsa.PublicKey,
 	relayPath []*crypto.RelayInfo,
 ) error {
-	if !c.connected {
+	if !c.connected.Load() {
 		return ErrNotConnected
 	}
 
@@ -363,7 +411,7 @@ func (c *Client) JoinGroup(
 	}
 	joinMsg.Signature = signature
 
-	joinPayload := joinMsg.Encode()
+	joinPayload := protocol.TagPayload(protocol.MsgTypeGroupJoin, joinMsg.Encode())
 
 	log.Printf("Requesting to join group %x", groupID)
 
@@ -374,7 +422,7 @@ func (c *Client) JoinGroup(
 	}
 
 	// Build onion layers to admin
-	onion, err := crypto.BuildOnionLayers(relayPath, adminAddr, encryptedMsg)
+	onion, err := c.buildOnionLayers(relayPath, adminAddr, encryptedMsg)
 	if err != nil {
 		return err
 	}
@@ -390,11 +438,10 @@ func (c *Client) JoinGroup(
 	}
 
 	// Send to relay
-	if err := protocol.WriteHeader(c.relayConn, header); err != nil {
+	if err := c.checkSend(header, relayPath, len(encryptedMsg)); err != nil {
 		return err
 	}
-
-	if _, err := c.relayConn.Write(onion); err != nil {
+	if err := c.writeFrameAs(context.Background(), groupUsage(groupID, storage.UsageControl), header, onion); err != nil {
 		return err
 	}
 
@@ -0,0 +1,108 @@
+package network
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+	"github.com/ZentaChain/zentalk-node/pkg/storage"
+)
+
+// GroupMessageOptions carries optional threading and mention metadata for a group message
+type GroupMessageOptions struct {
+	ThreadParent protocol.MessageID // Reply to this message's thread (zero = top level)
+	Mentions     []protocol.Mention // @mention ranges within the content
+}
+
+// MentionText returns the mention for the first occurrence of text (e.g. "@alice") in content
+func MentionText(content, text string, addr protocol.Address) (protocol.Mention, error) {
+	idx := strings.Index(content, text)
+	if idx < 0 {
+		return protocol.Mention{}, fmt.Errorf("%q not found in content", text)
+	}
+	if idx+len(text) > 0xFFFF {
+		return protocol.Mention{}, fmt.Errorf("mention is beyond the addressable content range")
+	}
+
+	return protocol.Mention{
+		Address: addr,
+		Offset:  uint16(idx),
+		Length:  uint16(len(text)),
+	}, nil
+}
+
+// ReplyInThread sends a group message as a reply in the thread of parent
+func (c *Client) ReplyInThread(group *Group, parent protocol.MessageID, content string, mentions []protocol.Mention, relayPath []*crypto.RelayInfo) (protocol.MessageID, error) {
+	return c.SendGroupMessageWithOptions(group, content, &GroupMessageOptions{
+		ThreadParent: parent,
+		Mentions:     mentions,
+	}, relayPath)
+}
+
+// GetThreadReplies returns stored replies to a group message, oldest first
+func (c *Client) GetThreadReplies(parent protocol.MessageID, limit, offset int) ([]*storage.StoredMessage, error) {
+	if c.messageDB == nil {
+		return nil, fmt.Errorf("message database not attached")
+	}
+	return c.messageDB.GetThreadReplies(fmt.Sprintf("%x", parent), limit, offset)
+}
+
+// GetMyMentions returns stored messages that mention this client, newest first
+func (c *Client) GetMyMentions(limit int) ([]*storage.StoredMessage, error) {
+	if c.messageDB == nil {
+		return nil, fmt.Errorf("message database not attached")
+	}
+	return c.messageDB.GetMentions(hex.EncodeToString(c.Address[:]), limit)
+}
+
+// saveGroupMessage persists a sent or received group message with its thread metadata
+func (c *Client) saveGroupMessage(msg *protocol.GroupMessage, outgoing bool) {
+	if c.messageDB == nil {
+		return
+	}
+
+	groupIDHex := hex.EncodeToString(msg.GroupID[:])
+
+	// Messages from clients that predate group message IDs get a local one
+	messageID := fmt.Sprintf("%x", msg.MessageID)
+	if msg.MessageID == (protocol.MessageID{}) {
+		messageID = fmt.Sprintf("%x-%d", msg.From, msg.Timestamp)
+	}
+
+	var threadParent string
+	if msg.IsThreadReply() {
+		threadParent = fmt.Sprintf("%x", msg.ThreadParent)
+	}
+
+	mentions := make([]string, 0, len(msg.Mentions))
+	for _, mention := range msg.Mentions {
+		mentions = append(mentions, hex.EncodeToString(mention.Address[:]))
+	}
+
+	status := storage.MessageStatusDelivered
+	if outgoing {
+		status = storage.MessageStatusSent
+	}
+
+	storedMsg := &storage.StoredMessage{
+		ConversationID: storage.GetGroupConversationID(groupIDHex),
+		MessageID:      messageID,
+		FromAddress:    hex.EncodeToString(msg.From[:]),
+		ToAddress:      groupIDHex,
+		Content:        msg.Content,
+		ContentType:    msg.ContentType,
+		Timestamp:      int64(msg.Timestamp),
+		Status:         status,
+		IsOutgoing:     outgoing,
+		ThreadParentID: threadParent,
+		Mentions:       mentions,
+	}
+	recordForwarded(storedMsg, msg.Forwarded)
+
+	if err := c.messageDB.SaveMessage(storedMsg); err != nil {
+		log.Printf("Failed to save group message to DB: %v", err)
+	}
+}
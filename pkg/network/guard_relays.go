@@ -1,9 +1,11 @@
 package network
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"math/rand"
+	"os"
 	"sync"
 	"time"
 
@@ -40,6 +42,12 @@ type GuardRelayManager struct {
 	persistPath    string // Optional: path to persist guards
 }
 
+// guardState is the on-disk representation of the selected guards
+type guardState struct {
+	Guards       []*GuardRelay `json:"guards"`
+	RotationTime time.Time     `json:"rotation_time"`
+}
+
 // NewGuardRelayManager creates a new guard relay manager
 func NewGuardRelayManager(discovery *RelayDiscovery) *GuardRelayManager {
 	return &GuardRelayManager{
@@ -49,6 +57,72 @@ func NewGuardRelayManager(discovery *RelayDiscovery) *GuardRelayManager {
 	}
 }
 
+// SetPersistPath enables guard persistence and restores previously selected guards
+// Keeping the same guards across restarts is what makes them useful: a client that
+// picks fresh entry relays on every launch eventually picks a malicious one
+func (grm *GuardRelayManager) SetPersistPath(path string) error {
+	grm.mu.Lock()
+	defer grm.mu.Unlock()
+
+	grm.persistPath = path
+	return grm.loadLocked()
+}
+
+// loadLocked restores guards from persistPath (must hold lock)
+func (grm *GuardRelayManager) loadLocked() error {
+	if grm.persistPath == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(grm.persistPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // Nothing persisted yet
+		}
+		return fmt.Errorf("failed to read guard state: %w", err)
+	}
+
+	var state guardState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to unmarshal guard state: %w", err)
+	}
+
+	guards := make([]*GuardRelay, 0, len(state.Guards))
+	for _, guard := range state.Guards {
+		if guard != nil && guard.Metadata != nil {
+			guards = append(guards, guard)
+		}
+	}
+
+	grm.guards = guards
+	if !state.RotationTime.IsZero() {
+		grm.rotationTime = state.RotationTime
+	}
+
+	log.Printf("🛡️  Restored %d guard relays (rotation due %s)", len(grm.guards), grm.rotationTime.Format(time.RFC3339))
+	return nil
+}
+
+// saveLocked writes the current guards to persistPath (must hold lock)
+func (grm *GuardRelayManager) saveLocked() {
+	if grm.persistPath == "" {
+		return
+	}
+
+	data, err := json.MarshalIndent(&guardState{
+		Guards:       grm.guards,
+		RotationTime: grm.rotationTime,
+	}, "", "  ")
+	if err != nil {
+		log.Printf("⚠️  Failed to marshal guard state: %v", err)
+		return
+	}
+
+	if err := os.WriteFile(grm.persistPath, data, 0600); err != nil {
+		log.Printf("⚠️  Failed to persist guard state: %v", err)
+	}
+}
+
 // GetGuardRelay returns a random guard relay for use as entry node
 // If no guards are set, selects new ones automatically
 func (grm *GuardRelayManager) GetGuardRelay() (*RelayMetadata, error) {
@@ -69,6 +143,7 @@ func (grm *GuardRelayManager) GetGuardRelay() (*RelayMetadata, error) {
 			log.Printf("⚠️  Failed to rotate guards: %v", err)
 		} else {
 			grm.rotationTime = time.Now().Add(GuardRotationPeriod)
+			grm.saveLocked()
 		}
 	}
 
@@ -131,6 +206,7 @@ func (grm *GuardRelayManager) RecordFailure(guardAddr protocol.Address) {
 					log.Printf("⚠️  Failed to select replacement guard: %v", err)
 				}
 			}
+			grm.saveLocked()
 			return
 		}
 	}
@@ -149,7 +225,7 @@ func (grm *GuardRelayManager) selectNewGuardsLocked() error {
 	}
 
 	if len(candidates) == 0 {
-		return fmt.Errorf("no relays available")
+		return ErrNoRelays
 	}
 
 	// Filter candidates: require minimum uptime and good health
@@ -196,6 +272,8 @@ func (grm *GuardRelayManager) selectNewGuardsLocked() error {
 		log.Printf("   - %s (%s)", guard.Metadata.NetworkAddress, guard.Metadata.Region)
 	}
 
+	grm.saveLocked()
+
 	return nil
 }
 
@@ -209,6 +287,19 @@ func (grm *GuardRelayManager) removeGuardLocked(addr protocol.Address) {
 	}
 }
 
+// IsGuard reports whether addr is one of the current guard relays
+func (grm *GuardRelayManager) IsGuard(addr protocol.Address) bool {
+	grm.mu.RLock()
+	defer grm.mu.RUnlock()
+
+	for _, guard := range grm.guards {
+		if guard.Metadata.Address == addr {
+			return true
+		}
+	}
+	return false
+}
+
 // GetGuardRelays returns the current list of guard relays (for debugging/UI)
 func (grm *GuardRelayManager) GetGuardRelays() []*GuardRelay {
 	grm.mu.RLock()
@@ -230,5 +321,6 @@ func (grm *GuardRelayManager) ForceRotation() error {
 	}
 
 	grm.rotationTime = time.Now().Add(GuardRotationPeriod)
+	grm.saveLocked()
 	return nil
 }
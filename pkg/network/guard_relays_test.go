@@ -0,0 +1,66 @@
+package network
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+func TestGuardRelaysPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), guardStateFile)
+
+	grm := NewGuardRelayManager(nil)
+	if err := grm.SetPersistPath(path); err != nil {
+		t.Fatalf("SetPersistPath(no state yet) error = %v", err)
+	}
+
+	firstUsed := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	rotation := time.Now().Add(GuardRotationPeriod / 2).Truncate(time.Second)
+
+	grm.mu.Lock()
+	for i := byte(1); i <= NumGuardRelays; i++ {
+		grm.guards = append(grm.guards, &GuardRelay{
+			Metadata:     &RelayMetadata{Address: protocol.Address{i}, NetworkAddress: "relay.example:9001", Region: "eu"},
+			FirstUsed:    firstUsed,
+			LastUsed:     firstUsed.Add(time.Hour),
+			SuccessCount: int(i) * 10,
+			FailCount:    int(i),
+		})
+	}
+	grm.rotationTime = rotation
+	grm.saveLocked()
+	grm.mu.Unlock()
+
+	// A fresh manager, as after a restart, picks up the same guards
+	restored := NewGuardRelayManager(nil)
+	if err := restored.SetPersistPath(path); err != nil {
+		t.Fatalf("SetPersistPath() error = %v", err)
+	}
+
+	want := grm.GetGuardRelays()
+	got := restored.GetGuardRelays()
+	if len(got) != len(want) {
+		t.Fatalf("restored %d guards, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Metadata.Address != want[i].Metadata.Address ||
+			got[i].Metadata.NetworkAddress != want[i].Metadata.NetworkAddress ||
+			got[i].SuccessCount != want[i].SuccessCount ||
+			got[i].FailCount != want[i].FailCount ||
+			!got[i].FirstUsed.Equal(want[i].FirstUsed) {
+			t.Errorf("guard %d = %+v, want %+v", i, got[i], want[i])
+		}
+		if !restored.IsGuard(want[i].Metadata.Address) {
+			t.Errorf("IsGuard(%v) = false after restore", want[i].Metadata.Address[0])
+		}
+	}
+
+	restored.mu.RLock()
+	gotRotation := restored.rotationTime
+	restored.mu.RUnlock()
+	if !gotRotation.Equal(rotation) {
+		t.Errorf("rotation time = %v, want %v", gotRotation, rotation)
+	}
+}
@@ -0,0 +1,219 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/features"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// KeyBundleReplenishCount is how many one-time prekeys are added when the relay runs low
+const KeyBundleReplenishCount = 100
+
+// KeyBundleRequestTimeout bounds the wait for the relay's answer when ctx has no deadline
+const KeyBundleRequestTimeout = 10 * time.Second
+
+var (
+	ErrKeyBundleRejected      = errors.New("relay rejected key bundle")
+	ErrKeyBundlesNotSupported = errors.New("relay does not hold key bundles")
+	ErrKeyBundleMismatch      = errors.New("relay returned another user's key bundle")
+	ErrKeyBundleIdentity      = errors.New("key bundle identity differs from the cached one")
+)
+
+// PublishKeyBundleToRelay publishes our key bundle to the connected relay
+// Contacts can then fetch it, with one of our one-time prekeys, while we are
+// offline. Returns how many one-time prekeys the relay now holds for us.
+func (c *Client) PublishKeyBundleToRelay() (int, error) {
+	return c.PublishKeyBundleToRelayContext(context.Background())
+}
+
+// PublishKeyBundleToRelayContext is PublishKeyBundleToRelay bounded by ctx
+func (c *Client) PublishKeyBundleToRelayContext(ctx context.Context) (int, error) {
+	if !c.relaySupportsKeyBundles() {
+		return 0, ErrKeyBundlesNotSupported
+	}
+
+	bundle, err := c.GetKeyBundle()
+	if err != nil {
+		return 0, err
+	}
+	c.sessionMu.Lock()
+	publish := &protocol.KeyBundlePublish{Signature: protocol.SignKeyBundle(bundle, c.x3dhIdentity), Bundle: bundle.Encode()}
+	c.sessionMu.Unlock()
+
+	resp, err := c.keyBundleRoundTrip(ctx, protocol.MsgTypeKeyBundlePublish, publish.Encode())
+	if err != nil {
+		return 0, err
+	}
+	if resp.Status != protocol.KeyBundleStatusOK {
+		return 0, ErrKeyBundleRejected
+	}
+
+	log.Printf("🔑 Published key bundle to relay (relay holds %d one-time prekeys)", resp.OneTimePreKeys)
+	return int(resp.OneTimePreKeys), nil
+}
+
+// FetchKeyBundle fetches a user's key bundle from the connected relay and caches it
+// The bundle carries at most one one-time prekey, which the relay will not hand
+// out again; it has none once the user's prekeys run out. It must be signed by
+// its owner, and keep the identity key of any bundle already cached for them;
+// remove the cached bundle to accept a new identity.
+func (c *Client) FetchKeyBundle(addr protocol.Address) (*protocol.KeyBundle, error) {
+	return c.FetchKeyBundleContext(context.Background(), addr)
+}
+
+// FetchKeyBundleContext is FetchKeyBundle bounded by ctx
+func (c *Client) FetchKeyBundleContext(ctx context.Context, addr protocol.Address) (*protocol.KeyBundle, error) {
+	if !c.relaySupportsKeyBundles() {
+		return nil, ErrKeyBundlesNotSupported
+	}
+
+	req := &protocol.KeyBundleRequest{Address: addr}
+	resp, err := c.keyBundleRoundTrip(ctx, protocol.MsgTypeKeyBundleRequest, req.Encode())
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status == protocol.KeyBundleStatusNotFound {
+		return nil, fmt.Errorf("%w on relay for %x", ErrNoKeyBundle, addr[:8])
+	}
+
+	bundle, err := resp.KeyBundle()
+	if err != nil {
+		return nil, fmt.Errorf("invalid key bundle from relay: %w", err)
+	}
+	if bundle.Address != addr {
+		return nil, fmt.Errorf("%w: asked for %x, got %x", ErrKeyBundleMismatch, addr[:8], bundle.Address[:8])
+	}
+	if cached, ok := c.GetCachedKeyBundle(addr); ok && cached.IdentityKey != bundle.IdentityKey {
+		return nil, fmt.Errorf("%w for %x", ErrKeyBundleIdentity, addr[:8])
+	}
+
+	c.CacheKeyBundle(addr, bundle)
+	return bundle, nil
+}
+
+// relaySupportsKeyBundles reports whether the connected relay advertised a key bundle registry
+// Version 1 registries took bundles without their owner's signature.
+func (c *Client) relaySupportsKeyBundles() bool {
+	version, ok := c.relayFeatures.Version(features.KeyBundles)
+	return ok && version >= 2
+}
+
+// keyBundleRoundTrip sends a key bundle request and waits for the relay's response
+// Must not be called from the receive loop, which delivers the response.
+func (c *Client) keyBundleRoundTrip(ctx context.Context, msgType uint16, payload []byte) (*protocol.KeyBundleResponse, error) {
+	if !c.connected.Load() {
+		return nil, ErrNotConnected
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, KeyBundleRequestTimeout)
+		defer cancel()
+	}
+
+	header := &protocol.Header{
+		Magic:     protocol.ProtocolMagic,
+		Version:   protocol.ProtocolVersion,
+		Type:      msgType,
+		Length:    uint32(len(payload)),
+		Flags:     0,
+		MessageID: protocol.GenerateMessageID(),
+	}
+
+	done := make(chan *protocol.KeyBundleResponse, 1)
+	c.keyBundleWaitMu.Lock()
+	if c.keyBundleWaiters == nil {
+		c.keyBundleWaiters = make(map[protocol.MessageID]chan *protocol.KeyBundleResponse)
+	}
+	c.keyBundleWaiters[header.MessageID] = done
+	c.keyBundleWaitMu.Unlock()
+
+	defer func() {
+		c.keyBundleWaitMu.Lock()
+		delete(c.keyBundleWaiters, header.MessageID)
+		c.keyBundleWaitMu.Unlock()
+	}()
+
+	if err := c.writeFrame(ctx, header, payload); err != nil {
+		return nil, err
+	}
+
+	select {
+	case resp := <-done:
+		return resp, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("no key bundle response from relay: %w", ctx.Err())
+	}
+}
+
+// handleKeyBundleResponse hands a relay's response to the request waiting for it
+// An unsolicited low-prekey notice starts replenishment instead.
+func (c *Client) handleKeyBundleResponse(header *protocol.Header) {
+	payload := make([]byte, header.Length)
+	if _, err := io.ReadFull(c.relayConn, payload); err != nil {
+		log.Printf("Read key bundle response error: %v", err)
+		return
+	}
+
+	var resp protocol.KeyBundleResponse
+	if err := protocol.DecodePayload(payload, header.Flags, &resp); err != nil {
+		log.Printf("Failed to decode key bundle response: %v", err)
+		return
+	}
+
+	if resp.Status == protocol.KeyBundleStatusLow {
+		log.Printf("🔑 Relay holds only %d of our one-time prekeys", resp.OneTimePreKeys)
+		go c.replenishOneTimePreKeys()
+		return
+	}
+
+	c.keyBundleWaitMu.Lock()
+	done, ok := c.keyBundleWaiters[header.MessageID]
+	c.keyBundleWaitMu.Unlock()
+	if !ok {
+		log.Printf("Key bundle response %x matches no request", header.MessageID[:8])
+		return
+	}
+	select {
+	case done <- &resp:
+	default: // A duplicate; the first answer stands
+	}
+}
+
+// replenishOneTimePreKeys generates more one-time prekeys and republishes our bundle
+// Notices arriving while a replenishment is running are dropped.
+func (c *Client) replenishOneTimePreKeys() {
+	if !c.replenishing.CompareAndSwap(false, true) {
+		return
+	}
+	defer c.replenishing.Store(false)
+
+	c.sessionMu.Lock()
+	if c.x3dhIdentity == nil {
+		c.sessionMu.Unlock()
+		return
+	}
+	err := c.addOneTimePreKeysLocked(KeyBundleReplenishCount)
+	if err == nil {
+		if saveErr := c.saveX3DHState(); saveErr != nil {
+			log.Printf("⚠️  Failed to persist X3DH state after replenishing: %v", saveErr)
+		}
+	}
+	c.sessionMu.Unlock()
+	if err != nil {
+		log.Printf("⚠️  Failed to replenish one-time prekeys: %v", err)
+		return
+	}
+
+	count, err := c.PublishKeyBundleToRelay()
+	if err != nil {
+		log.Printf("⚠️  Failed to republish key bundle: %v", err)
+		return
+	}
+	log.Printf("✅ Replenished one-time prekeys: relay now holds %d", count)
+}
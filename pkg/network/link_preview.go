@@ -0,0 +1,214 @@
+package network
+
+import (
+	"context"
+	"crypto/rsa"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+const (
+	// maxPreviewPageSize caps how much of a page is read when generating a preview
+	maxPreviewPageSize = 512 * 1024
+
+	// maxPreviewThumbnailSize caps the thumbnail image downloaded for a preview
+	maxPreviewThumbnailSize = 1024 * 1024
+
+	// previewFetchTimeout bounds the page and thumbnail fetch
+	previewFetchTimeout = 10 * time.Second
+)
+
+var (
+	urlPattern       = regexp.MustCompile(`https?://[^\s<>"]+`)
+	titlePattern     = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	metaTagPattern   = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	metaAttrPattern  = regexp.MustCompile(`(?is)(property|name|content)\s*=\s*["']([^"']*)["']`)
+	whitespaceRegexp = regexp.MustCompile(`\s+`)
+)
+
+// ExtractURL returns the first http(s) URL in text ("" if none)
+func ExtractURL(text string) string {
+	match := urlPattern.FindString(text)
+	return strings.TrimRight(match, ".,;:!?)]}'")
+}
+
+// LinkPreviewGenerator fetches pages on the sender's side to build link previews
+// Recipients never contact the linked site, so they don't leak their IP address to it
+type LinkPreviewGenerator struct {
+	httpClient *http.Client
+	userAgent  string
+}
+
+// NewLinkPreviewGenerator creates a preview generator with sane timeouts
+func NewLinkPreviewGenerator() *LinkPreviewGenerator {
+	return &LinkPreviewGenerator{
+		httpClient: &http.Client{Timeout: previewFetchTimeout},
+		userAgent:  "ZentalkLinkPreview/1.0",
+	}
+}
+
+// Generate fetches rawURL and builds a preview; if store is non-nil the page's
+// og:image is uploaded encrypted to MeshStorage as the thumbnail
+func (g *LinkPreviewGenerator) Generate(ctx context.Context, rawURL string, store MeshStorageUploader) (*protocol.LinkPreview, error) {
+	pageURL, err := url.Parse(rawURL)
+	if err != nil || (pageURL.Scheme != "http" && pageURL.Scheme != "https") {
+		return nil, fmt.Errorf("unsupported preview URL: %q", rawURL)
+	}
+
+	page, err := g.fetch(ctx, rawURL, maxPreviewPageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := parsePageMetadata(string(page))
+
+	preview := &protocol.LinkPreview{
+		URL:         rawURL,
+		Title:       truncateUTF8(firstNonEmpty(meta["og:title"], meta["twitter:title"], meta["title"]), protocol.MaxLinkPreviewTitle),
+		Description: truncateUTF8(firstNonEmpty(meta["og:description"], meta["twitter:description"], meta["description"]), protocol.MaxLinkPreviewDescription),
+		SiteName:    truncateUTF8(firstNonEmpty(meta["og:site_name"], pageURL.Hostname()), protocol.MaxLinkPreviewSiteName),
+	}
+
+	imageRef := firstNonEmpty(meta["og:image"], meta["twitter:image"])
+	if store != nil && imageRef != "" {
+		if err := g.attachThumbnail(ctx, preview, pageURL, imageRef, store); err != nil {
+			log.Printf("⚠️  Link preview thumbnail skipped: %v", err)
+		}
+	}
+
+	if err := preview.Validate(); err != nil {
+		return nil, err
+	}
+
+	return preview, nil
+}
+
+// attachThumbnail downloads the preview image and uploads it encrypted
+func (g *LinkPreviewGenerator) attachThumbnail(ctx context.Context, preview *protocol.LinkPreview, pageURL *url.URL, imageRef string, store MeshStorageUploader) error {
+	imageURL, err := pageURL.Parse(imageRef)
+	if err != nil {
+		return fmt.Errorf("invalid image URL: %w", err)
+	}
+
+	image, err := g.fetch(ctx, imageURL.String(), maxPreviewThumbnailSize)
+	if err != nil {
+		return err
+	}
+
+	chunkID, key, err := store.UploadEncrypted(image)
+	if err != nil {
+		return fmt.Errorf("failed to upload thumbnail: %w", err)
+	}
+
+	preview.ThumbnailID = chunkID
+	copy(preview.ThumbnailKey[:], key)
+	return nil
+}
+
+// fetch GETs a URL and reads at most limit bytes
+func (g *LinkPreviewGenerator) fetch(ctx context.Context, rawURL string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", g.userAgent)
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: status %d", rawURL, resp.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, limit))
+}
+
+// parsePageMetadata extracts <title> and <meta> name/property values from HTML
+func parsePageMetadata(page string) map[string]string {
+	meta := make(map[string]string)
+
+	if m := titlePattern.FindStringSubmatch(page); m != nil {
+		meta["title"] = cleanText(m[1])
+	}
+
+	for _, tag := range metaTagPattern.FindAllString(page, -1) {
+		var key, content string
+		for _, attr := range metaAttrPattern.FindAllStringSubmatch(tag, -1) {
+			switch strings.ToLower(attr[1]) {
+			case "property", "name":
+				key = strings.ToLower(attr[2])
+			case "content":
+				content = attr[2]
+			}
+		}
+		if key != "" && content != "" {
+			if _, exists := meta[key]; !exists {
+				meta[key] = cleanText(content)
+			}
+		}
+	}
+
+	return meta
+}
+
+// cleanText unescapes HTML entities and collapses whitespace
+func cleanText(s string) string {
+	return strings.TrimSpace(whitespaceRegexp.ReplaceAllString(html.UnescapeString(s), " "))
+}
+
+// firstNonEmpty returns the first non-empty string
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// truncateUTF8 shortens s to at most limit bytes without splitting a rune
+func truncateUTF8(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	for limit > 0 && !isRuneStart(s[limit]) {
+		limit--
+	}
+	return s[:limit]
+}
+
+// isRuneStart reports whether b begins a UTF-8 sequence
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+// SendTextMessageWithPreview sends a text message with an attached link preview
+// The preview is encrypted with the message, so relays see nothing extra
+func (c *Client) SendTextMessageWithPreview(to protocol.Address, recipientPubKey *rsa.PublicKey, text string, preview *protocol.LinkPreview, relayPath []*crypto.RelayInfo) error {
+	if preview == nil {
+		return c.SendTextMessage(to, recipientPubKey, text, relayPath)
+	}
+
+	if err := preview.Validate(); err != nil {
+		return fmt.Errorf("invalid link preview: %w", err)
+	}
+
+	extensions := []protocol.MessageExtension{
+		{Type: protocol.ExtensionLinkPreview, Data: preview.Encode()},
+	}
+
+	return c.sendMessageWithExtensions(context.Background(), to, recipientPubKey, []byte(text), protocol.ContentTypeText, extensions, relayPath)
+}
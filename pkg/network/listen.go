@@ -0,0 +1,209 @@
+package network
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/ZentaChain/zentalk-node/pkg/transport"
+)
+
+// AddressFamily selects the IP versions used for listening and dialing
+type AddressFamily string
+
+const (
+	AddressFamilyDual AddressFamily = "dual" // IPv4 and IPv6 (default)
+	AddressFamilyIPv4 AddressFamily = "ipv4" // IPv4 only
+	AddressFamilyIPv6 AddressFamily = "ipv6" // IPv6 only, e.g. on IPv6-only hosts
+)
+
+// ParseAddressFamily parses "dual", "ipv4" or "ipv6" (empty means dual)
+func ParseAddressFamily(s string) (AddressFamily, error) {
+	switch AddressFamily(strings.ToLower(strings.TrimSpace(s))) {
+	case "", AddressFamilyDual:
+		return AddressFamilyDual, nil
+	case AddressFamilyIPv4:
+		return AddressFamilyIPv4, nil
+	case AddressFamilyIPv6:
+		return AddressFamilyIPv6, nil
+	}
+	return "", fmt.Errorf("unknown address family %q (want dual, ipv4 or ipv6)", s)
+}
+
+// Network returns the network name to pass to net.Dial for TCP in this family
+func (f AddressFamily) Network() string {
+	switch f {
+	case AddressFamilyIPv4:
+		return "tcp4"
+	case AddressFamilyIPv6:
+		return "tcp6"
+	}
+	return "tcp"
+}
+
+// allows reports whether an IP belongs to the family
+func (f AddressFamily) allows(ip net.IP) bool {
+	switch f {
+	case AddressFamilyIPv4:
+		return ip.To4() != nil
+	case AddressFamilyIPv6:
+		return ip.To4() == nil
+	}
+	return true
+}
+
+// ListenConfig selects the addresses a relay listens on
+type ListenConfig struct {
+	// Hosts are IP addresses to listen on. Empty listens on all interfaces of the
+	// family; in dual mode that is a single dual-stack socket.
+	Hosts []string
+
+	// Family restricts listening and outgoing relay connections to one IP version
+	Family AddressFamily
+
+	// WebSocketPort also accepts connections over WebSocket, for browser
+	// clients, on the same hosts (0 = TCP only)
+	WebSocketPort int
+}
+
+// ParseListenHosts splits a comma-separated list of IP addresses
+// IPv6 addresses may be written with or without brackets.
+func ParseListenHosts(s string) ([]string, error) {
+	var hosts []string
+	for _, host := range strings.Split(s, ",") {
+		host = strings.Trim(strings.TrimSpace(host), "[]")
+		if host == "" {
+			continue
+		}
+		if net.ParseIP(host) == nil {
+			return nil, fmt.Errorf("listen address %q is not an IP address", host)
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
+}
+
+// listenAddr is one socket to open: a net.Listen network and host
+type listenAddr struct {
+	network string
+	host    string
+}
+
+// listenAddrs returns the sockets to open
+// Explicit hosts get one socket each, bound to their own IP version, so 0.0.0.0
+// and :: can be listed together without the IPv6 socket claiming IPv4 too.
+func (lc ListenConfig) listenAddrs() ([]listenAddr, error) {
+	if len(lc.Hosts) == 0 {
+		switch lc.Family {
+		case AddressFamilyIPv4:
+			return []listenAddr{{"tcp4", "0.0.0.0"}}, nil
+		case AddressFamilyIPv6:
+			return []listenAddr{{"tcp6", "::"}}, nil
+		}
+		return []listenAddr{{"tcp", ""}}, nil
+	}
+
+	var out []listenAddr
+	for _, host := range lc.Hosts {
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return nil, fmt.Errorf("listen address %q is not an IP address", host)
+		}
+		if !lc.Family.allows(ip) {
+			return nil, fmt.Errorf("listen address %s is not %s", host, lc.Family)
+		}
+		network := "tcp6"
+		if ip.To4() != nil {
+			network = "tcp4"
+		}
+		out = append(out, listenAddr{network, host})
+	}
+	return out, nil
+}
+
+// SetListenConfig sets the addresses and IP versions the relay uses
+// Takes effect on Start; outgoing relay connections use the family right away.
+func (rs *RelayServer) SetListenConfig(cfg ListenConfig) {
+	rs.mu.Lock()
+	rs.listenConfig = cfg
+	rs.mu.Unlock()
+}
+
+// GetListenConfig returns the relay's listen configuration
+func (rs *RelayServer) GetListenConfig() ListenConfig {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.listenConfig
+}
+
+// listen opens every socket of the listen configuration
+// With port 0 the first socket picks a port and the others reuse it.
+func (rs *RelayServer) listen() ([]net.Listener, error) {
+	cfg := rs.GetListenConfig()
+	addrs, err := cfg.listenAddrs()
+	if err != nil {
+		return nil, err
+	}
+
+	var listeners []net.Listener
+	for _, addr := range addrs {
+		address := net.JoinHostPort(addr.host, strconv.Itoa(rs.Port))
+		listener, err := net.Listen(addr.network, address)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("failed to listen on %s: %w", address, err)
+		}
+		if rs.Port == 0 {
+			rs.Port = listener.Addr().(*net.TCPAddr).Port
+		}
+		log.Printf("Relay server listening on %s", listener.Addr())
+		listeners = append(listeners, listener)
+	}
+
+	if cfg.WebSocketPort == 0 {
+		return listeners, nil
+	}
+
+	// Browsers can't open TCP sockets; serve them the same protocol over WebSocket
+	for _, addr := range addrs {
+		address := net.JoinHostPort(addr.host, strconv.Itoa(cfg.WebSocketPort))
+		listener, err := transport.ListenWebSocket(addr.network, address)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("failed to listen for WebSocket on %s: %w", address, err)
+		}
+		log.Printf("🌐 Relay server accepting WebSocket connections on ws://%s%s", listener.Addr(), transport.WebSocketPath)
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+// AdvertisedAddress returns the host:port published in the relay's metadata
+// A router-forwarded public address wins, then a single explicit listen address; otherwise localhost.
+func (rs *RelayServer) AdvertisedAddress() string {
+	rs.mu.RLock()
+	mapper := rs.portMapper
+	rs.mu.RUnlock()
+	if mapper != nil {
+		if address := mapper.ExternalAddress(); address != "" {
+			return address
+		}
+	}
+
+	host := "localhost"
+	if hosts := rs.GetListenConfig().Hosts; len(hosts) == 1 && !net.ParseIP(hosts[0]).IsUnspecified() {
+		host = hosts[0]
+	}
+	return net.JoinHostPort(host, strconv.Itoa(rs.Port))
+}
+
+// SetAddressFamily restricts the client's relay connections to one IP version
+func (c *Client) SetAddressFamily(family AddressFamily) {
+	c.addressFamily = family
+}
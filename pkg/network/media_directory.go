@@ -0,0 +1,110 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/ZentaChain/zentalk-node/pkg/features"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// ErrMediaDirectoryNotSupported is returned when the relay advertises no media directory
+var ErrMediaDirectoryNotSupported = errors.New("relay does not advertise a media directory")
+
+// FetchMediaDirectory asks the connected relay where to upload media and how large it may be
+// The answer is cached for CachedMediaDirectory.
+func (c *Client) FetchMediaDirectory() (*protocol.MediaDirectory, error) {
+	return c.FetchMediaDirectoryContext(context.Background())
+}
+
+// FetchMediaDirectoryContext is FetchMediaDirectory bounded by ctx
+// Must not be called from the receive loop, which delivers the answer.
+func (c *Client) FetchMediaDirectoryContext(ctx context.Context) (*protocol.MediaDirectory, error) {
+	if _, ok := c.relayFeatures.Version(features.MediaDirectory); !ok {
+		return nil, ErrMediaDirectoryNotSupported
+	}
+	if !c.connected.Load() {
+		return nil, ErrNotConnected
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, KeyBundleRequestTimeout)
+		defer cancel()
+	}
+
+	header := &protocol.Header{
+		Magic:     protocol.ProtocolMagic,
+		Version:   protocol.ProtocolVersion,
+		Type:      protocol.MsgTypeMediaDirectoryRequest,
+		Length:    0,
+		Flags:     0,
+		MessageID: protocol.GenerateMessageID(),
+	}
+
+	done := make(chan *protocol.MediaDirectory, 1)
+	c.mediaDirMu.Lock()
+	if c.mediaDirWaiters == nil {
+		c.mediaDirWaiters = make(map[protocol.MessageID]chan *protocol.MediaDirectory)
+	}
+	c.mediaDirWaiters[header.MessageID] = done
+	c.mediaDirMu.Unlock()
+
+	defer func() {
+		c.mediaDirMu.Lock()
+		delete(c.mediaDirWaiters, header.MessageID)
+		c.mediaDirMu.Unlock()
+	}()
+
+	if err := c.writeFrame(ctx, header, nil); err != nil {
+		return nil, err
+	}
+
+	select {
+	case dir := <-done:
+		c.mediaDirMu.Lock()
+		c.mediaDirectory = dir
+		c.mediaDirMu.Unlock()
+
+		log.Printf("🗂️  Relay media directory: %d storage endpoints, %d size limits", len(dir.Endpoints), len(dir.MaxSize))
+		return dir, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("no media directory from relay: %w", ctx.Err())
+	}
+}
+
+// CachedMediaDirectory returns the directory from the last successful fetch (nil = none yet)
+func (c *Client) CachedMediaDirectory() *protocol.MediaDirectory {
+	c.mediaDirMu.Lock()
+	defer c.mediaDirMu.Unlock()
+	return c.mediaDirectory
+}
+
+// handleMediaDirectory hands a relay's media directory to the request waiting for it
+func (c *Client) handleMediaDirectory(header *protocol.Header) {
+	payload := make([]byte, header.Length)
+	if _, err := io.ReadFull(c.relayConn, payload); err != nil {
+		log.Printf("Read media directory error: %v", err)
+		return
+	}
+
+	var dir protocol.MediaDirectory
+	if err := protocol.DecodePayload(payload, header.Flags, &dir); err != nil {
+		log.Printf("Failed to decode media directory: %v", err)
+		return
+	}
+
+	c.mediaDirMu.Lock()
+	done, ok := c.mediaDirWaiters[header.MessageID]
+	c.mediaDirMu.Unlock()
+	if !ok {
+		log.Printf("Media directory %x matches no request", header.MessageID[:8])
+		return
+	}
+	select {
+	case done <- &dir:
+	default: // A duplicate; the first answer stands
+	}
+}
@@ -0,0 +1,210 @@
+package network
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// meshBucketIdle is how long an unused per-peer bucket is kept
+const meshBucketIdle = 10 * time.Minute
+
+// MeshShapingConfig bounds outbound traffic on relay-to-relay links
+// Forwards to other relays draw from a token bucket per peer and one shared by
+// all relay peers; delivery to users is never shaped. A forward that would wait
+// longer than MaxWait for tokens is refused as rate-limited, so a congested peer
+// or a burst of forwarding can't take the bandwidth users' messages need.
+type MeshShapingConfig struct {
+	PeerRate   int // Bytes per second to any one relay peer (0 = unlimited)
+	PeerBurst  int // Bytes a peer may send at once (0 = one second of PeerRate)
+	TotalRate  int // Bytes per second across all relay peers (0 = unlimited)
+	TotalBurst int // Bytes all peers may send at once (0 = one second of TotalRate)
+
+	// MaxWait is the longest a forward waits for tokens before it is refused
+	MaxWait time.Duration
+
+	// WriteTimeout bounds one write to a relay peer, so a peer that stops
+	// reading can't hold the forwarding connection (0 = no limit)
+	WriteTimeout time.Duration
+}
+
+// DefaultMeshShapingConfig returns the wait and write limits; rates are left unlimited
+func DefaultMeshShapingConfig() MeshShapingConfig {
+	return MeshShapingConfig{
+		MaxWait:      500 * time.Millisecond,
+		WriteTimeout: 10 * time.Second,
+	}
+}
+
+// MeshShapingStats counts forwards affected by shaping
+type MeshShapingStats struct {
+	Delayed uint64 // Forwards that waited for tokens
+	Refused uint64 // Forwards refused because the wait would exceed MaxWait
+}
+
+// tokenBucket is a byte-rate token bucket
+// Tokens may go negative: a frame larger than the burst is admitted once the
+// bucket is full, and the debt delays the frames after it.
+type tokenBucket struct {
+	rate   float64 // Tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket creates a full bucket (nil if rate is 0)
+func newTokenBucket(rate, burst int, now time.Time) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = rate
+	}
+	return &tokenBucket{rate: float64(rate), burst: float64(burst), tokens: float64(burst), last: now}
+}
+
+// refill adds the tokens accrued since the last call
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = min(b.burst, b.tokens+elapsed*b.rate)
+		b.last = now
+	}
+}
+
+// wait returns how long n bytes must wait for tokens, without taking them
+func (b *tokenBucket) wait(n int, now time.Time) time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.refill(now)
+
+	// A frame larger than the burst needs a full bucket, not more
+	need := min(float64(n), b.burst)
+	if b.tokens >= need {
+		return 0
+	}
+	return time.Duration((need - b.tokens) / b.rate * float64(time.Second))
+}
+
+// take removes n bytes' worth of tokens
+func (b *tokenBucket) take(n int) {
+	if b != nil {
+		b.tokens -= float64(n)
+	}
+}
+
+// meshShaper holds the relay's mesh link buckets
+type meshShaper struct {
+	cfg   MeshShapingConfig
+	total *tokenBucket
+	peers map[protocol.Address]*tokenBucket
+	stats MeshShapingStats
+	mu    sync.Mutex
+}
+
+// reserve takes tokens for n bytes to a peer and returns how long to wait before sending
+// ok is false (and nothing is taken) if the wait would exceed MaxWait.
+func (s *meshShaper) reserve(peer protocol.Address, n int, now time.Time) (wait time.Duration, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket, exists := s.peers[peer]
+	if !exists {
+		s.pruneLocked(now)
+		bucket = newTokenBucket(s.cfg.PeerRate, s.cfg.PeerBurst, now)
+		s.peers[peer] = bucket
+	}
+
+	wait = max(bucket.wait(n, now), s.total.wait(n, now))
+	if wait > s.cfg.MaxWait {
+		s.stats.Refused++
+		return wait, false
+	}
+
+	bucket.take(n)
+	s.total.take(n)
+	if wait > 0 {
+		s.stats.Delayed++
+	}
+	return wait, true
+}
+
+// pruneLocked forgets peer buckets that have been idle (and so full) for a while
+func (s *meshShaper) pruneLocked(now time.Time) {
+	for addr, bucket := range s.peers {
+		if bucket == nil || now.Sub(bucket.last) > meshBucketIdle {
+			delete(s.peers, addr)
+		}
+	}
+}
+
+// EnableMeshShaping shapes outbound traffic to relay peers
+func (rs *RelayServer) EnableMeshShaping(cfg MeshShapingConfig) {
+	now := time.Now()
+	shaper := &meshShaper{
+		cfg:   cfg,
+		total: newTokenBucket(cfg.TotalRate, cfg.TotalBurst, now),
+		peers: make(map[protocol.Address]*tokenBucket),
+	}
+
+	rs.mu.Lock()
+	rs.meshShaper = shaper
+	rs.mu.Unlock()
+
+	log.Printf("🚦 Mesh shaping enabled: %d B/s per peer, %d B/s total, wait up to %s",
+		cfg.PeerRate, cfg.TotalRate, cfg.MaxWait)
+}
+
+// GetMeshShapingStats returns counts of shaped forwards (zero if shaping is off)
+func (rs *RelayServer) GetMeshShapingStats() MeshShapingStats {
+	shaper := rs.getMeshShaper()
+	if shaper == nil {
+		return MeshShapingStats{}
+	}
+	return shaper.snapshot()
+}
+
+// snapshot returns a copy of the stats
+func (s *meshShaper) snapshot() MeshShapingStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// getMeshShaper returns the mesh shaper (nil = disabled)
+func (rs *RelayServer) getMeshShaper() *meshShaper {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.meshShaper
+}
+
+// shapeForward waits until n bytes may be sent to a relay peer
+// Returns the error for the previous hop if the link is too busy.
+func (rs *RelayServer) shapeForward(peer *Peer, n int) *protocol.RelayErrorMessage {
+	shaper := rs.getMeshShaper()
+	if shaper == nil {
+		return nil
+	}
+
+	wait, ok := shaper.reserve(peer.Address, n, time.Now())
+	if !ok {
+		log.Printf("🚦 Link to relay %x is saturated, refusing %d-byte forward", peer.Address[:8], n)
+		relayErr := protocol.NewRelayError(protocol.RelayErrRateLimited, "link to next hop %x is saturated", peer.Address[:8])
+		relayErr.RetryAfter = wait
+		return relayErr
+	}
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+	return nil
+}
+
+// meshWriteTimeout returns how long one write to a relay peer may block (0 = no limit)
+func (rs *RelayServer) meshWriteTimeout() time.Duration {
+	if shaper := rs.getMeshShaper(); shaper != nil {
+		return shaper.cfg.WriteTimeout
+	}
+	return 0
+}
@@ -0,0 +1,148 @@
+package network
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	if b := newTokenBucket(0, 100, now); b != nil {
+		t.Fatal("newTokenBucket() with no rate is not unlimited")
+	}
+	var unlimited *tokenBucket
+	if wait := unlimited.wait(1<<20, now); wait != 0 {
+		t.Fatalf("unlimited wait = %s, want 0", wait)
+	}
+
+	b := newTokenBucket(1000, 0, now) // Burst defaults to one second of rate
+	if wait := b.wait(1000, now); wait != 0 {
+		t.Fatalf("full bucket wait = %s, want 0", wait)
+	}
+	b.take(1000)
+	if wait := b.wait(500, now); wait != 500*time.Millisecond {
+		t.Fatalf("empty bucket wait = %s, want 500ms", wait)
+	}
+
+	// Refill is capped at the burst
+	now = now.Add(time.Hour)
+	if wait := b.wait(1000, now); wait != 0 {
+		t.Fatalf("refilled bucket wait = %s, want 0", wait)
+	}
+	if b.tokens != b.burst {
+		t.Fatalf("refilled tokens = %v, want burst %v", b.tokens, b.burst)
+	}
+
+	// A frame larger than the burst needs only a full bucket, and its debt delays the next
+	if wait := b.wait(5000, now); wait != 0 {
+		t.Fatalf("oversized frame wait = %s, want 0", wait)
+	}
+	b.take(5000)
+	if wait := b.wait(1000, now); wait != 5*time.Second {
+		t.Fatalf("wait after oversized frame = %s, want 5s", wait)
+	}
+}
+
+func TestMeshShaperReserve(t *testing.T) {
+	now := time.Now()
+	s := &meshShaper{
+		cfg:   MeshShapingConfig{PeerRate: 1000, TotalRate: 1500, MaxWait: time.Second},
+		total: newTokenBucket(1500, 0, now),
+		peers: make(map[protocol.Address]*tokenBucket),
+	}
+	a, b := protocol.Address{1}, protocol.Address{2}
+
+	if wait, ok := s.reserve(a, 1000, now); !ok || wait != 0 {
+		t.Fatalf("first reserve = %s, %v; want immediate", wait, ok)
+	}
+	// Peer b has its own bucket but shares what is left of the total
+	if wait, ok := s.reserve(b, 1000, now); !ok || wait != time.Second/3 {
+		t.Fatalf("reserve on another peer = %s, %v; want 333ms", wait, ok)
+	}
+	// Peer a's bucket is empty and would need two seconds
+	if wait, ok := s.reserve(a, 2000, now); ok {
+		t.Fatalf("reserve past MaxWait = %s, accepted", wait)
+	}
+
+	if stats := s.snapshot(); stats.Delayed != 1 || stats.Refused != 1 {
+		t.Fatalf("stats = %+v, want 1 delayed and 1 refused", stats)
+	}
+
+	// Idle peer buckets are forgotten when a new peer shows up
+	s.reserve(protocol.Address{3}, 1, now.Add(meshBucketIdle+time.Second))
+	if _, ok := s.peers[a]; ok {
+		t.Fatal("idle peer bucket was not pruned")
+	}
+}
+
+func TestShapeForward(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := NewRelayServer(0, key)
+	peer := &Peer{Address: protocol.Address{1}, ClientType: protocol.ClientTypeRelay}
+
+	if relayErr := rs.shapeForward(peer, 1<<20); relayErr != nil {
+		t.Fatalf("unshaped forward refused: %v", relayErr)
+	}
+	if timeout := rs.meshWriteTimeout(); timeout != 0 {
+		t.Fatalf("unshaped write timeout = %s, want none", timeout)
+	}
+
+	cfg := DefaultMeshShapingConfig()
+	cfg.PeerRate = 1000
+	rs.EnableMeshShaping(cfg)
+
+	if relayErr := rs.shapeForward(peer, 1000); relayErr != nil {
+		t.Fatalf("forward within burst refused: %v", relayErr)
+	}
+	relayErr := rs.shapeForward(peer, 1000)
+	if relayErr == nil || relayErr.Code != protocol.RelayErrRateLimited {
+		t.Fatalf("forward past the burst = %v, want rate limited", relayErr)
+	}
+	if relayErr.RetryAfter <= cfg.MaxWait || relayErr.RetryAfter > time.Second {
+		t.Errorf("RetryAfter = %s, want up to 1s", relayErr.RetryAfter)
+	}
+	if timeout := rs.meshWriteTimeout(); timeout != cfg.WriteTimeout {
+		t.Errorf("write timeout = %s, want %s", timeout, cfg.WriteTimeout)
+	}
+}
+
+// Run with -race: the status read races the shaper being enabled
+func TestMeshStatusShapingStats(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := NewRelayServer(0, key)
+	mm := NewMeshManager(rs, 1)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		cfg := DefaultMeshShapingConfig()
+		cfg.PeerRate, cfg.MaxWait = 1, 0
+		rs.EnableMeshShaping(cfg)
+		rs.shapeForward(&Peer{Address: protocol.Address{1}}, 2)
+		rs.shapeForward(&Peer{Address: protocol.Address{1}}, 2)
+	}()
+	for range 100 {
+		mm.GetMeshStatus()
+	}
+	wg.Wait()
+
+	status := mm.GetMeshStatus()
+	if status["forwards_refused"] != uint64(1) {
+		t.Errorf("forwards_refused = %v, want 1", status["forwards_refused"])
+	}
+	if status["forwards_delayed"] != uint64(0) {
+		t.Errorf("forwards_delayed = %v, want 0", status["forwards_delayed"])
+	}
+}
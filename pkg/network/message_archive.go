@@ -0,0 +1,183 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/storage"
+)
+
+const (
+	// DefaultArchiveBatchSize is how many messages go into one archive chunk by default
+	DefaultArchiveBatchSize = 500
+
+	// ArchiveChunkMaxBytes caps the message content packed into one archive chunk
+	// A single larger message still gets a chunk of its own.
+	ArchiveChunkMaxBytes = 512 * 1024
+
+	// archiveChunkVersion is bumped whenever the archive chunk format changes
+	archiveChunkVersion = 1
+)
+
+// ArchivePolicy decides which stored messages are moved to MeshStorage
+type ArchivePolicy struct {
+	MaxAge    time.Duration // Messages older than this are archived
+	BatchSize int           // Messages per archive chunk (0 = DefaultArchiveBatchSize)
+}
+
+// archiveChunk is the JSON form of the messages stored in one archive chunk
+type archiveChunk struct {
+	Version        int                      `json:"version"`
+	ConversationID string                   `json:"conversation_id"`
+	Messages       []*storage.StoredMessage `json:"messages"`
+}
+
+// ArchiveOldMessages moves messages older than the policy allows to MeshStorage
+// Each conversation's old messages are packed into encrypted chunks, recorded
+// in the archive index and deleted from the database. Returns how many
+// messages were archived; those archived before an error stay archived.
+func (c *Client) ArchiveOldMessages(policy ArchivePolicy, store MeshStorageUploader) (int, error) {
+	if c.messageDB == nil {
+		return 0, ErrNoMessageDB
+	}
+	if policy.MaxAge <= 0 {
+		return 0, fmt.Errorf("archive policy needs a positive MaxAge, got %s", policy.MaxAge)
+	}
+	store = c.meterUploads(usageKey{category: storage.UsageMedia}, store)
+	batchSize := policy.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultArchiveBatchSize
+	}
+
+	before := time.Now().Add(-policy.MaxAge).UnixMilli()
+	conversationIDs, err := c.messageDB.GetArchivableConversations(before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list conversations to archive: %w", err)
+	}
+
+	archived := 0
+	for _, conversationID := range conversationIDs {
+		for {
+			messages, err := c.messageDB.GetMessagesBefore(conversationID, before, batchSize)
+			if err != nil {
+				return archived, fmt.Errorf("failed to read messages to archive: %w", err)
+			}
+			if len(messages) == 0 {
+				break
+			}
+
+			n, err := c.archiveMessages(conversationID, messages, store)
+			archived += n
+			if err != nil {
+				return archived, err
+			}
+		}
+	}
+
+	if archived > 0 {
+		if err := c.messageDB.Compact(); err != nil {
+			log.Printf("⚠️  Failed to compact message database: %v", err)
+		}
+		log.Printf("🗄️  Archived %d messages from %d conversations to MeshStorage", archived, len(conversationIDs))
+	}
+	return archived, nil
+}
+
+// archiveMessages uploads the leading messages that fit in one chunk and removes them from the database
+// messages are oldest first; returns how many were archived.
+func (c *Client) archiveMessages(conversationID string, messages []*storage.StoredMessage, store MeshStorageUploader) (int, error) {
+	count, size := 0, 0
+	for _, msg := range messages {
+		if count > 0 && size+len(msg.Content) > ArchiveChunkMaxBytes {
+			break
+		}
+		size += len(msg.Content)
+		count++
+	}
+	messages = messages[:count]
+
+	data, err := json.Marshal(&archiveChunk{
+		Version:        archiveChunkVersion,
+		ConversationID: conversationID,
+		Messages:       messages,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal archive chunk: %w", err)
+	}
+
+	chunkID, key, err := store.UploadEncrypted(data)
+	if err != nil {
+		return 0, fmt.Errorf("failed to upload archive chunk: %w", err)
+	}
+
+	messageIDs := make([]string, len(messages))
+	for i, msg := range messages {
+		messageIDs[i] = msg.MessageID
+	}
+	archive := &storage.MessageArchive{
+		ConversationID: conversationID,
+		ChunkID:        chunkID,
+		ChunkKey:       key,
+		FirstTimestamp: messages[0].Timestamp,
+		LastTimestamp:  messages[len(messages)-1].Timestamp,
+		MessageCount:   len(messages),
+	}
+	if err := c.messageDB.SaveMessageArchive(archive, messageIDs); err != nil {
+		return 0, fmt.Errorf("failed to record archive chunk %d: %w", chunkID, err)
+	}
+
+	return len(messages), nil
+}
+
+// AutoArchiveMessages applies the archive policy now and then every interval until ctx is done
+// Should be run in a goroutine
+func (c *Client) AutoArchiveMessages(ctx context.Context, policy ArchivePolicy, store MeshStorageUploader, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := c.ArchiveOldMessages(policy, store); err != nil {
+			log.Printf("⚠️  Failed to archive old messages: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// MessageArchives lists a conversation's archived chunks, newest first
+func (c *Client) MessageArchives(conversationID string) ([]*storage.MessageArchive, error) {
+	if c.messageDB == nil {
+		return nil, ErrNoMessageDB
+	}
+	return c.messageDB.GetMessageArchives(conversationID)
+}
+
+// LoadArchivedMessages downloads the messages of an archived chunk, oldest first
+// The messages are returned without being put back in the database.
+func (c *Client) LoadArchivedMessages(archive *storage.MessageArchive, store MeshStorageDownloader) ([]*storage.StoredMessage, error) {
+	store = c.MeterMediaDownloads("", store)
+	data, err := store.DownloadEncrypted(archive.ChunkID, archive.ChunkKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download archive chunk %d: %w", archive.ChunkID, err)
+	}
+
+	var chunk archiveChunk
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal archive chunk %d: %w", archive.ChunkID, err)
+	}
+	if chunk.Version != archiveChunkVersion {
+		return nil, fmt.Errorf("archive chunk %d has unsupported version %d", archive.ChunkID, chunk.Version)
+	}
+	if chunk.ConversationID != archive.ConversationID {
+		return nil, fmt.Errorf("archive chunk %d belongs to another conversation", archive.ChunkID)
+	}
+
+	return chunk.Messages, nil
+}
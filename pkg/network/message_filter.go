@@ -0,0 +1,315 @@
+package network
+
+import (
+	"encoding/hex"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// FilterVerdict is the outcome of running a message through a filter
+type FilterVerdict uint8
+
+const (
+	// FilterAccept delivers the message normally
+	FilterAccept FilterVerdict = iota
+	// FilterFlag delivers the message but reports it through OnMessageFlagged
+	FilterFlag
+	// FilterDrop discards the message without delivering it
+	FilterDrop
+)
+
+// String returns the verdict name
+func (v FilterVerdict) String() string {
+	switch v {
+	case FilterFlag:
+		return "flag"
+	case FilterDrop:
+		return "drop"
+	default:
+		return "accept"
+	}
+}
+
+// FilterDecision is a filter's verdict with an explanation
+type FilterDecision struct {
+	Verdict FilterVerdict
+	Score   float64 // Spam/scam likelihood (0.0-1.0), informational
+	Reason  string
+}
+
+// FilterContext is what the client knows about a message's sender
+type FilterContext struct {
+	KnownSender   bool // Sender is in the local contact list
+	BlockedSender bool // Sender is blocked
+	ReceivedAt    time.Time
+}
+
+// MessageFilter inspects decrypted direct messages before they are delivered
+type MessageFilter interface {
+	FilterMessage(msg *protocol.DirectMessage, ctx FilterContext) FilterDecision
+}
+
+// MessageFilterFunc adapts a function to the MessageFilter interface
+type MessageFilterFunc func(msg *protocol.DirectMessage, ctx FilterContext) FilterDecision
+
+// FilterMessage calls f(msg, ctx)
+func (f MessageFilterFunc) FilterMessage(msg *protocol.DirectMessage, ctx FilterContext) FilterDecision {
+	return f(msg, ctx)
+}
+
+// AddMessageFilter appends a filter; filters run in order and the strictest verdict wins
+func (c *Client) AddMessageFilter(filter MessageFilter) {
+	c.messageFiltersMu.Lock()
+	defer c.messageFiltersMu.Unlock()
+
+	// Copy, so a filterMessage already running keeps its own list
+	filters := make([]MessageFilter, len(c.messageFilters), len(c.messageFilters)+1)
+	copy(filters, c.messageFilters)
+	c.messageFilters = append(filters, filter)
+}
+
+// ClearMessageFilters removes all message filters
+func (c *Client) ClearMessageFilters() {
+	c.messageFiltersMu.Lock()
+	c.messageFilters = nil
+	c.messageFiltersMu.Unlock()
+}
+
+// filterMessage runs all filters and returns the strictest decision
+// A drop verdict short-circuits the remaining filters
+func (c *Client) filterMessage(msg *protocol.DirectMessage) FilterDecision {
+	c.messageFiltersMu.RLock()
+	filters := c.messageFilters
+	c.messageFiltersMu.RUnlock()
+
+	decision := FilterDecision{Verdict: FilterAccept}
+	if len(filters) == 0 {
+		return decision
+	}
+
+	ctx := c.filterContext(msg.From)
+
+	for _, filter := range filters {
+		d := filter.FilterMessage(msg, ctx)
+		if d.Verdict > decision.Verdict || (d.Verdict == decision.Verdict && d.Score > decision.Score) {
+			decision = d
+		}
+		if decision.Verdict == FilterDrop {
+			break
+		}
+	}
+
+	return decision
+}
+
+// filterContext looks up the sender in the contact list
+func (c *Client) filterContext(from protocol.Address) FilterContext {
+	ctx := FilterContext{ReceivedAt: time.Now()}
+
+	if c.messageDB != nil {
+		if contact, err := c.messageDB.GetContact(hex.EncodeToString(from[:])); err == nil {
+			ctx.KnownSender = true
+			ctx.BlockedSender = contact.IsBlocked
+		}
+	}
+
+	return ctx
+}
+
+// RateFilter is the default heuristic filter
+// It drops messages from blocked senders and flags or drops bursts from unknown senders
+type RateFilter struct {
+	Window           time.Duration // Sliding window for counting messages
+	UnknownFlagRate  int           // Messages per window from an unknown sender before flagging
+	UnknownDropRate  int           // Messages per window from an unknown sender before dropping
+	KnownFlagRate    int           // Messages per window from a contact before flagging
+	MaxTrackedSender int           // Cap on tracked senders (oldest forgotten first)
+
+	history map[protocol.Address][]time.Time
+	mu      sync.Mutex
+}
+
+// NewRateFilter creates a rate filter with conservative defaults
+func NewRateFilter() *RateFilter {
+	return &RateFilter{
+		Window:           time.Minute,
+		UnknownFlagRate:  3,
+		UnknownDropRate:  10,
+		KnownFlagRate:    60,
+		MaxTrackedSender: 10000,
+		history:          make(map[protocol.Address][]time.Time),
+	}
+}
+
+// FilterMessage implements MessageFilter
+func (f *RateFilter) FilterMessage(msg *protocol.DirectMessage, ctx FilterContext) FilterDecision {
+	if ctx.BlockedSender {
+		return FilterDecision{Verdict: FilterDrop, Score: 1.0, Reason: "sender is blocked"}
+	}
+
+	count := f.record(msg.From, ctx.ReceivedAt)
+
+	if ctx.KnownSender {
+		if f.KnownFlagRate > 0 && count > f.KnownFlagRate {
+			return FilterDecision{Verdict: FilterFlag, Score: 0.5, Reason: "unusually high message rate from contact"}
+		}
+		return FilterDecision{Verdict: FilterAccept}
+	}
+
+	switch {
+	case f.UnknownDropRate > 0 && count > f.UnknownDropRate:
+		return FilterDecision{Verdict: FilterDrop, Score: 0.9, Reason: "message flood from unknown sender"}
+	case f.UnknownFlagRate > 0 && count > f.UnknownFlagRate:
+		return FilterDecision{Verdict: FilterFlag, Score: 0.6, Reason: "high message rate from unknown sender"}
+	default:
+		return FilterDecision{Verdict: FilterAccept, Score: 0.1}
+	}
+}
+
+// record adds a message timestamp for sender and returns the count within the window
+func (f *RateFilter) record(sender protocol.Address, now time.Time) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.history == nil {
+		f.history = make(map[protocol.Address][]time.Time)
+	}
+
+	cutoff := now.Add(-f.Window)
+	times := f.history[sender]
+	kept := times[:0]
+	for _, t := range times {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	kept = append(kept, now)
+	f.history[sender] = kept
+
+	if f.MaxTrackedSender > 0 && len(f.history) > f.MaxTrackedSender {
+		f.pruneLocked(cutoff)
+	}
+
+	return len(kept)
+}
+
+// pruneLocked forgets senders with no messages inside the window
+func (f *RateFilter) pruneLocked(cutoff time.Time) {
+	for sender, times := range f.history {
+		if len(times) == 0 || !times[len(times)-1].After(cutoff) {
+			delete(f.history, sender)
+		}
+	}
+}
+
+// ===== RELAY QUEUE FILTERING =====
+
+// RelayQueueFilter decides whether a relay should queue a message for an offline recipient
+// Relays only see metadata (recipient, size), never message content
+type RelayQueueFilter interface {
+	AllowQueue(recipient protocol.Address, payloadSize int) FilterVerdict
+}
+
+// SetQueueFilter installs a filter consulted before queueing messages for offline users
+func (rs *RelayServer) SetQueueFilter(filter RelayQueueFilter) {
+	rs.mu.Lock()
+	rs.queueFilter = filter
+	rs.mu.Unlock()
+}
+
+// allowQueue runs the queue filter (accepting if none is set)
+// Returns the error to send back when the filter drops the message
+func (rs *RelayServer) allowQueue(recipient protocol.Address, payloadSize int) *protocol.RelayErrorMessage {
+	rs.mu.RLock()
+	filter := rs.queueFilter
+	rs.mu.RUnlock()
+
+	if filter == nil {
+		return nil
+	}
+
+	verdict := filter.AllowQueue(recipient, payloadSize)
+	if verdict != FilterDrop {
+		return nil
+	}
+
+	log.Printf("🚫 Queue filter dropped message for %x (%d bytes)", recipient[:8], payloadSize)
+	if rf, ok := filter.(*QueueRateFilter); ok && rf.MaxPayloadSize > 0 && payloadSize > rf.MaxPayloadSize {
+		return protocol.NewRelayError(protocol.RelayErrPayloadTooLarge, "queued messages are limited to %d bytes", rf.MaxPayloadSize)
+	}
+	relayErr := protocol.NewRelayError(protocol.RelayErrRateLimited, "too many messages queued for this recipient")
+	if rf, ok := filter.(*QueueRateFilter); ok {
+		relayErr.RetryAfter = rf.Window
+	}
+	return relayErr
+}
+
+// QueueRateFilter limits how many messages can be queued per offline recipient per window
+// This keeps one sender from flooding another user's offline queue
+type QueueRateFilter struct {
+	Window         time.Duration
+	MaxPerWindow   int
+	MaxPayloadSize int // Largest payload accepted for queueing (0 = unlimited)
+
+	counts map[protocol.Address][]time.Time
+	swept  time.Time // Last time recipients with nothing in the window were forgotten
+	mu     sync.Mutex
+}
+
+// NewQueueRateFilter creates a queue filter with default limits (100 msgs/hour, 1 MB each)
+func NewQueueRateFilter() *QueueRateFilter {
+	return &QueueRateFilter{
+		Window:         time.Hour,
+		MaxPerWindow:   100,
+		MaxPayloadSize: 1024 * 1024,
+		counts:         make(map[protocol.Address][]time.Time),
+	}
+}
+
+// AllowQueue implements RelayQueueFilter
+func (f *QueueRateFilter) AllowQueue(recipient protocol.Address, payloadSize int) FilterVerdict {
+	if f.MaxPayloadSize > 0 && payloadSize > f.MaxPayloadSize {
+		return FilterDrop
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.counts == nil {
+		f.counts = make(map[protocol.Address][]time.Time)
+	}
+
+	now := time.Now()
+	cutoff := now.Add(-f.Window)
+	if now.Sub(f.swept) > f.Window {
+		f.sweepLocked(cutoff)
+		f.swept = now
+	}
+
+	kept := f.counts[recipient][:0]
+	for _, t := range f.counts[recipient] {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+
+	if f.MaxPerWindow > 0 && len(kept) >= f.MaxPerWindow {
+		f.counts[recipient] = kept
+		return FilterDrop
+	}
+
+	f.counts[recipient] = append(kept, now)
+	return FilterAccept
+}
+
+// sweepLocked forgets recipients with no messages queued inside the window
+func (f *QueueRateFilter) sweepLocked(cutoff time.Time) {
+	for recipient, times := range f.counts {
+		if len(times) == 0 || !times[len(times)-1].After(cutoff) {
+			delete(f.counts, recipient)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package network
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+func TestQueueRateFilter(t *testing.T) {
+	filter := NewQueueRateFilter()
+	filter.Window = 20 * time.Millisecond
+	filter.MaxPerWindow = 2
+
+	recipient := protocol.Address{1}
+	for i := 0; i < 2; i++ {
+		if v := filter.AllowQueue(recipient, 10); v != FilterAccept {
+			t.Fatalf("AllowQueue() #%d = %v, want accept", i+1, v)
+		}
+	}
+	if v := filter.AllowQueue(recipient, 10); v != FilterDrop {
+		t.Errorf("AllowQueue() over the limit = %v, want drop", v)
+	}
+	if v := filter.AllowQueue(protocol.Address{2}, filter.MaxPayloadSize+1); v != FilterDrop {
+		t.Errorf("AllowQueue() of an oversized payload = %v, want drop", v)
+	}
+
+	// Recipients that go quiet are forgotten rather than kept forever
+	for i := 0; i < 100; i++ {
+		filter.AllowQueue(protocol.Address{2, byte(i)}, 10)
+	}
+	time.Sleep(2 * filter.Window)
+	if v := filter.AllowQueue(recipient, 10); v != FilterAccept {
+		t.Errorf("AllowQueue() after the window = %v, want accept", v)
+	}
+	filter.mu.Lock()
+	tracked := len(filter.counts)
+	filter.mu.Unlock()
+	if tracked != 1 {
+		t.Errorf("tracking %d recipients after the window, want 1", tracked)
+	}
+}
+
+func TestMessageFiltersConcurrent(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(key)
+
+	drop := MessageFilterFunc(func(msg *protocol.DirectMessage, ctx FilterContext) FilterDecision {
+		return FilterDecision{Verdict: FilterDrop, Score: 1, Reason: "test"}
+	})
+	flag := MessageFilterFunc(func(msg *protocol.DirectMessage, ctx FilterContext) FilterDecision {
+		return FilterDecision{Verdict: FilterFlag, Score: 0.5}
+	})
+
+	// Filters may be installed while messages are being delivered
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			client.AddMessageFilter(flag)
+			if i%10 == 0 {
+				client.ClearMessageFilters()
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			client.filterMessage(&protocol.DirectMessage{From: protocol.Address{1}})
+		}
+	}()
+	wg.Wait()
+
+	client.ClearMessageFilters()
+	client.AddMessageFilter(flag)
+	client.AddMessageFilter(drop)
+	if d := client.filterMessage(&protocol.DirectMessage{From: protocol.Address{1}}); d.Verdict != FilterDrop {
+		t.Errorf("filterMessage() = %v, want the strictest verdict (drop)", d.Verdict)
+	}
+}
@@ -2,11 +2,11 @@ package network
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"fmt"
 	"io"
 	"log"
-	"sync"
 	"time"
 
 	"github.com/ZentaChain/zentalk-node/pkg/crypto"
@@ -14,13 +14,9 @@ import (
 	"github.com/ZentaChain/zentalk-node/pkg/storage"
 )
 
-var (
-	messageOrderingMu sync.Mutex // Protects message ordering operations
-)
-
 // receiveLoop receives messages from relay
 func (c *Client) receiveLoop() {
-	for c.connected {
+	for c.connected.Load() {
 		header, err := protocol.ReadHeader(c.relayConn)
 		if err != nil {
 			if err != io.EOF {
@@ -29,6 +25,18 @@ func (c *Client) receiveLoop() {
 			break
 		}
 
+		// A relay announcing more than we negotiated is broken or hostile; don't read it
+		if err := c.payloadLimits.Check(header); err != nil {
+			log.Printf("🚫 %v, closing connection", err)
+			c.relayConn.Close()
+			break
+		}
+
+		// Messages are counted once decrypted, when we know whose they are
+		if header.Type != protocol.MsgTypeDirectMessage && header.Type != protocol.MsgTypeOfflineSyncResponse {
+			c.recordUsage(usageKey{}, 0, protocol.HeaderSize+int(header.Length))
+		}
+
 		// Handle message based on type
 		switch header.Type {
 		case protocol.MsgTypeDirectMessage:
@@ -40,9 +48,13 @@ func (c *Client) receiveLoop() {
 		case protocol.MsgTypeReadReceipt:
 			c.handleReadReceipt(header)
 
+		case protocol.MsgTypePresence:
+			c.handlePresence(header)
+
 		case protocol.MsgTypePong:
 			// Pong received
 			log.Println("Pong received")
+			c.recordPong(header.MessageID, time.Now())
 
 		case protocol.MsgTypeAck:
 			// Acknowledgment received
@@ -52,6 +64,34 @@ func (c *Client) receiveLoop() {
 			// Negative acknowledgment received
 			c.handleNackMessage(header)
 
+		case protocol.MsgTypeRelayMoved:
+			// Our relay migrated; queued messages are on the new relay
+			c.handleRelayMoved(header)
+
+		case protocol.MsgTypeRelayError:
+			// Relay refused one of our messages
+			c.handleRelayError(header)
+
+		case protocol.MsgTypeTicket:
+			// Ticket for resuming this session after a drop
+			c.handleTicket(header)
+
+		case protocol.MsgTypeQueueProgress:
+			// How far the relay has got delivering our offline queue
+			c.handleQueueProgress(header)
+
+		case protocol.MsgTypeKeyBundleResponse:
+			// Answer to a key bundle publish or fetch, or a low-prekey notice
+			c.handleKeyBundleResponse(header)
+
+		case protocol.MsgTypeMediaDirectory:
+			// Storage endpoints and media limits the relay advertises
+			c.handleMediaDirectory(header)
+
+		case protocol.MsgTypeOfflineSyncResponse:
+			// A page of our offline queue we asked for
+			c.handleOfflineSyncResponse(header)
+
 		default:
 			log.Printf("Unknown message type: 0x%04x", header.Type)
 		}
@@ -62,6 +102,20 @@ func (c *Client) receiveLoop() {
 
 // handleDirectMessage handles incoming direct message or group message
 func (c *Client) handleDirectMessage(header *protocol.Header) {
+	// Read payload
+	payload := make([]byte, header.Length)
+	if _, err := io.ReadFull(c.relayConn, payload); err != nil {
+		log.Printf("Read payload error: %v", err)
+		return
+	}
+
+	usage := c.handleDirectPayload(header, payload)
+	c.recordUsage(usage, 0, protocol.HeaderSize+len(payload))
+}
+
+// handleDirectPayload decrypts and delivers the payload of a direct or group message
+// Returns what the payload's traffic counts towards (zero = control traffic of no conversation).
+func (c *Client) handleDirectPayload(header *protocol.Header, payload []byte) (usage usageKey) {
 	// Add panic recovery for decode errors
 	defer func() {
 		if r := recover(); r != nil {
@@ -69,11 +123,9 @@ func (c *Client) handleDirectMessage(header *protocol.Header) {
 		}
 	}()
 
-	// Read payload
-	payload := make([]byte, header.Length)
-	if _, err := io.ReadFull(c.relayConn, payload); err != nil {
-		log.Printf("Read payload error: %v", err)
-		return
+	// Queued deliveries advance the cursor reported when resuming
+	if seq := header.QueueSeq(); seq > c.queueCursor {
+		c.queueCursor = seq
 	}
 
 	// Try different decryption methods in order:
@@ -120,11 +172,11 @@ func (c *Client) handleDirectMessage(header *protocol.Header) {
 		// Ratchet message headers are typically 40-200 bytes
 		if headerLen >= 40 && headerLen <= 200 && len(decrypted) >= int(2+headerLen) {
 			// This might be a ratchet message - try with all known sessions
+			c.sessionMu.Lock()
 			for addr, session := range c.ratchetSessions {
-				plaintext, err := session.RatchetDecrypt(
+				plaintext, err := session.Open(
 					decrypted[2:2+headerLen],
 					decrypted[2+headerLen:],
-					AESDecryptGCM,
 				)
 				if err == nil {
 					finalPlaintext = plaintext
@@ -132,6 +184,10 @@ func (c *Client) handleDirectMessage(header *protocol.Header) {
 					break
 				}
 			}
+			c.sessionMu.Unlock()
+			if finalPlaintext == nil && headerLen == 40 {
+				c.nackUndecryptableRatchet(decrypted[2 : 2+headerLen])
+			}
 		}
 	}
 
@@ -162,160 +218,167 @@ func (c *Client) handleDirectMessage(header *protocol.Header) {
 		finalPlaintext = decrypted
 	}
 
-	// Try to decode as DirectMessage first
-	// Use a function to catch panics
-	isDirectMessage := func() bool {
-		defer func() {
-			if r := recover(); r != nil {
-				// Decode failed, not a direct message
-			}
-		}()
-
-		var directMsg protocol.DirectMessage
-		if err := directMsg.Decode(finalPlaintext); err == nil {
-			// Check if this is actually for us (To field matches our address)
-			if directMsg.To == c.Address {
-				// Handle message with ordering and deduplication
-				c.handleOrderedMessage(&directMsg)
-				return true
-			}
+	msgType, body, tagged := protocol.UntagPayload(finalPlaintext)
+	if !tagged {
+		body = finalPlaintext
+		if msgType = c.legacyPayloadType(body); msgType == 0 {
+			log.Printf("Failed to decode message as direct, group, profile, typing, or receipt")
+			return
 		}
-		return false
-	}()
-
-	if isDirectMessage {
-		return
 	}
+	return c.dispatchPayload(msgType, body)
+}
 
-	// If not a direct message, try group message
-	isGroupMessage := func() bool {
-		defer func() {
-			if r := recover(); r != nil {
-				// Decode failed, not a group message
-			}
-		}()
+// dispatchPayload hands a decrypted end-to-end payload to the handler for its type
+// Returns what the payload's traffic counts towards (zero = control traffic of no conversation).
+func (c *Client) dispatchPayload(msgType uint16, body []byte) usageKey {
+	switch msgType {
+	case protocol.MsgTypeDirectMessage:
+		var directMsg protocol.DirectMessage
+		if err := directMsg.Decode(body); err != nil {
+			log.Printf("Failed to decode direct message: %v", err)
+			return usageKey{}
+		}
+		if !c.isOwnIdentity(directMsg.To) {
+			log.Printf("Dropping direct message addressed to %x", directMsg.To[:8])
+			return usageKey{}
+		}
+		// Handle message with ordering and deduplication
+		c.handleOrderedMessage(&directMsg)
+		return c.directUsage(directMsg.From, contentUsage(directMsg.ContentType))
 
+	case protocol.MsgTypeGroupMessage:
 		var groupMsg protocol.GroupMessage
-		if err := groupMsg.Decode(finalPlaintext); err == nil {
+		if err := groupMsg.Decode(body); err != nil {
+			log.Printf("Failed to decode group message: %v", err)
+			return usageKey{}
+		}
+		if groupMsg.ContentType == protocol.ContentTypeGroupFile {
+			log.Printf("📁 File shared by %x in group %x", groupMsg.From, groupMsg.GroupID)
+		} else {
 			log.Printf("Group message received from %x in group %x: %s", groupMsg.From, groupMsg.GroupID, string(groupMsg.Content))
-			if c.OnGroupMessageReceived != nil {
-				c.OnGroupMessageReceived(&groupMsg)
-			}
-			return true
 		}
-		return false
-	}()
-
-	if isGroupMessage {
-		return
-	}
-
-	// If not group message, try profile update
-	isProfileUpdate := func() bool {
-		defer func() {
-			if r := recover(); r != nil {
-				// Decode failed, not a profile update
-			}
-		}()
+		if groupMsg.Mentioned(c.Address) {
+			log.Printf("🔔 You were mentioned in group %x", groupMsg.GroupID[:8])
+		}
+		c.saveGroupMessage(&groupMsg, false)
+		if c.OnGroupMessageReceived != nil {
+			c.OnGroupMessageReceived(&groupMsg)
+		}
+		return groupUsage(groupMsg.GroupID, contentUsage(groupMsg.ContentType))
 
-		// Try to decode as profile (hybrid decryption already done above)
+	case protocol.MsgTypeProfileUpdate:
 		var profile protocol.ProfileUpdate
-		if err := profile.Decode(finalPlaintext); err == nil {
-			username := string(bytes.Trim(profile.Username[:], "\x00"))
-			log.Printf("Profile update received from %x: %s", profile.Address, username)
-			if c.OnProfileUpdate != nil {
-				c.OnProfileUpdate(&profile)
-			}
-			return true
+		if err := profile.Decode(body); err != nil {
+			log.Printf("Failed to decode profile update: %v", err)
+			return usageKey{}
 		}
-		return false
-	}()
-
-	if !isProfileUpdate {
-		// Try typing indicator
-		isTypingIndicator := func() bool {
-			defer func() {
-				if r := recover(); r != nil {
-					// Decode failed
-				}
-			}()
-
-			var indicator protocol.TypingIndicator
-			if err := indicator.Decode(finalPlaintext); err == nil {
-				if indicator.To == c.Address {
-					if indicator.IsTyping {
-						log.Printf("⌨️  %x is typing...", indicator.From[:8])
-					} else {
-						log.Printf("⌨️  %x stopped typing", indicator.From[:8])
-					}
-					if c.OnTypingIndicator != nil {
-						c.OnTypingIndicator(&indicator)
-					}
-					return true
-				}
-			}
-			return false
-		}()
-
-		if !isTypingIndicator {
-			// Try read receipt
-			isReadReceipt := func() bool {
-				defer func() {
-					if r := recover(); r != nil {
-						// Decode failed
-					}
-				}()
-
-				var receipt protocol.ReadReceipt
-				if err := receipt.Decode(finalPlaintext); err == nil {
-					if receipt.To == c.Address {
-						statusName := "delivered"
-						if receipt.ReadStatus == protocol.ReadStatusRead {
-							statusName = "read"
-						} else if receipt.ReadStatus == protocol.ReadStatusSeen {
-							statusName = "seen"
-						}
-
-						log.Printf("✓✓ Read receipt from %x: message %x is %s", receipt.From[:8], receipt.MessageID[:8], statusName)
-
-						// Update message status in database
-						if c.messageDB != nil {
-							msgIDStr := fmt.Sprintf("%x", receipt.MessageID)
-							var status storage.MessageStatus
-							switch receipt.ReadStatus {
-							case protocol.ReadStatusDelivered:
-								status = storage.MessageStatusDelivered
-							case protocol.ReadStatusRead:
-								status = storage.MessageStatusRead
-							case protocol.ReadStatusSeen:
-								status = storage.MessageStatusRead
-							}
-
-							if err := c.messageDB.UpdateMessageStatus(msgIDStr, status); err != nil {
-								log.Printf("Failed to update message status in DB: %v", err)
-							}
-						}
-
-						if c.OnReadReceipt != nil {
-							c.OnReadReceipt(&receipt)
-						}
-						return true
-					}
-				}
-				return false
-			}()
+		username := string(bytes.Trim(profile.Username[:], "\x00"))
+		log.Printf("Profile update received from %x: %s", profile.Address, username)
+		if profile.AuditLogEnabled() {
+			log.Printf("📋 %x has audit logging enabled", profile.Address[:8])
+		}
+		c.notePeerBot(&profile)
+		if c.OnProfileUpdate != nil {
+			c.OnProfileUpdate(&profile)
+		}
+		return c.directUsage(profile.Address, storage.UsageControl)
 
-			if !isReadReceipt {
-				log.Printf("Failed to decode message as direct, group, profile, typing, or receipt")
-			}
+	case protocol.MsgTypeTyping:
+		var indicator protocol.TypingIndicator
+		if err := indicator.Decode(body); err != nil {
+			log.Printf("Failed to decode typing indicator: %v", err)
+			return usageKey{}
+		}
+		c.deliverTypingIndicator(&indicator)
+		return c.directUsage(indicator.From, storage.UsageControl)
+
+	case protocol.MsgTypeReadReceipt:
+		var receipt protocol.ReadReceipt
+		if err := receipt.Decode(body); err != nil {
+			log.Printf("Failed to decode read receipt: %v", err)
+			return usageKey{}
 		}
+		c.deliverReadReceipt(&receipt)
+		return c.directUsage(receipt.From, storage.UsageControl)
+
+	case protocol.MsgTypePresence:
+		var update protocol.PresenceUpdate
+		if err := update.Decode(body); err != nil {
+			log.Printf("Failed to decode presence update: %v", err)
+			return usageKey{}
+		}
+		c.deliverPresence(&update)
+		return c.directUsage(update.Address, storage.UsageControl)
+
+	case protocol.MsgTypeDeviceFanout:
+		return c.handleDeviceFanout(body)
+
+	case protocol.MsgTypeAck:
+		// Our ACK, routed back from the recipient's relay
+		c.handleReturnedAck(body)
+
+	default:
+		log.Printf("Dropping end-to-end payload of unhandled type %#04x", msgType)
 	}
+	return usageKey{}
+}
+
+// legacyPayloadType works out the type of an untagged payload (0 if nothing matches)
+// Clients that predate payload tags send bare encodings, so the decoders are
+// tried in the order older receivers used. The decoders index short buffers
+// without checking, so one that panics counts as a miss.
+func (c *Client) legacyPayloadType(payload []byte) uint16 {
+	var directMsg protocol.DirectMessage
+	if decodes(payload, &directMsg) && directMsg.To == c.Address {
+		return protocol.MsgTypeDirectMessage
+	}
+	if decodes(payload, &protocol.GroupMessage{}) {
+		return protocol.MsgTypeGroupMessage
+	}
+	if decodes(payload, &protocol.ProfileUpdate{}) {
+		return protocol.MsgTypeProfileUpdate
+	}
+	var indicator protocol.TypingIndicator
+	if decodes(payload, &indicator) && indicator.To == c.Address {
+		return protocol.MsgTypeTyping
+	}
+	var receipt protocol.ReadReceipt
+	if decodes(payload, &receipt) && receipt.To == c.Address {
+		return protocol.MsgTypeReadReceipt
+	}
+	return 0
+}
+
+// untagAs strips the tag from a payload that arrived in a frame of type msgType
+// Untagged payloads from older clients are returned unchanged.
+func untagAs(payload []byte, msgType uint16) ([]byte, error) {
+	tagType, body, ok := protocol.UntagPayload(payload)
+	if !ok {
+		return payload, nil
+	}
+	if tagType != msgType {
+		return nil, fmt.Errorf("payload tagged %#04x in a %#04x frame", tagType, msgType)
+	}
+	return body, nil
+}
+
+// decodes reports whether msg decodes from payload without error or panic
+func decodes(payload []byte, msg interface{ Decode([]byte) error }) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	return msg.Decode(payload) == nil
 }
 
 // handleOrderedMessage handles message ordering, buffering, and deduplication
+// Delivery happens under orderingMu so messages from a peer reach the
+// application in sequence order even if several arrive concurrently.
 func (c *Client) handleOrderedMessage(msg *protocol.DirectMessage) {
-	messageOrderingMu.Lock()
-	defer messageOrderingMu.Unlock()
+	c.orderingMu.Lock()
+	defer c.orderingMu.Unlock()
 
 	from := msg.From
 	seqNum := msg.SequenceNumber
@@ -329,8 +392,12 @@ func (c *Client) handleOrderedMessage(msg *protocol.DirectMessage) {
 	}
 
 	// Check for duplicate message (deduplication)
+	// A retransmission of a delivered message means our ACK was lost: ACK again
 	if c.receivedMessageIDs[from][seqNum] {
 		log.Printf("⚠️  Duplicate message from %x (seq: %d) - discarding", from[:8], seqNum)
+		if seqNum < c.receiveSequenceNumbers[from] {
+			c.sendAck(msg)
+		}
 		return
 	}
 
@@ -365,9 +432,10 @@ func (c *Client) handleOrderedMessage(msg *protocol.DirectMessage) {
 	// Case 3: Message is old (seq < expected) - probably a duplicate, discard
 	log.Printf("⚠️  Old message from %x (seq: %d, expected: %d) - discarding",
 		from[:8], seqNum, expectedSeq)
+	c.sendAck(msg)
 }
 
-// deliverBufferedMessages delivers any buffered messages that are now in order
+// deliverBufferedMessages delivers any buffered messages that are now in order (caller holds orderingMu)
 func (c *Client) deliverBufferedMessages(from protocol.Address) {
 	for {
 		expectedSeq := c.receiveSequenceNumbers[from]
@@ -394,11 +462,48 @@ func (c *Client) deliverBufferedMessages(from protocol.Address) {
 
 // deliverMessage delivers a message to the application layer
 func (c *Client) deliverMessage(msg *protocol.DirectMessage) {
+	// Drop content that fails validation, but still ACK so the sender stops retrying
+	if err := c.ContentTypes().Validate(msg.ContentType, msg.Content); err != nil {
+		log.Printf("⚠️  Dropping message from %x (seq: %d): %v", msg.From[:8], msg.SequenceNumber, err)
+		c.sendAck(msg)
+		return
+	}
+
+	// Run spam/scam filters; dropped messages are ACKed so the sender doesn't retry
+	decision := c.filterMessage(msg)
+	switch decision.Verdict {
+	case FilterDrop:
+		log.Printf("🚫 Filter dropped message from %x (seq: %d): %s", msg.From[:8], msg.SequenceNumber, decision.Reason)
+		c.sendAck(msg)
+		return
+	case FilterFlag:
+		log.Printf("⚠️  Filter flagged message from %x (seq: %d): %s", msg.From[:8], msg.SequenceNumber, decision.Reason)
+		if c.OnMessageFlagged != nil {
+			c.OnMessageFlagged(msg, decision)
+		}
+	}
+
+	// Blocked senders are dropped; unknown senders go to message requests
+	switch c.classifySender(msg.From) {
+	case senderBlocked:
+		log.Printf("🚫 Dropping message from blocked sender %x (seq: %d)", msg.From[:8], msg.SequenceNumber)
+		c.sendAck(msg)
+		return
+	case senderUnknown:
+		log.Printf("📥 Message request from %x (seq: %d)", msg.From[:8], msg.SequenceNumber)
+		c.storeMessageRequest(msg)
+		c.sendAck(msg)
+		if c.OnMessageRequest != nil {
+			c.OnMessageRequest(msg)
+		}
+		return
+	}
+
 	log.Printf("✅ Direct message delivered from %x (seq: %d): %s",
 		msg.From[:8], msg.SequenceNumber, string(msg.Content))
 
-	// Save incoming message to database
-	if c.messageDB != nil {
+	// Save incoming message to database (syncs from our own devices are not conversation messages)
+	if c.messageDB != nil && msg.ContentType != protocol.ContentTypeReadStateSync {
 		conversationID := storage.GetConversationID(
 			hex.EncodeToString(c.Address[:]),
 			hex.EncodeToString(msg.From[:]),
@@ -415,34 +520,50 @@ func (c *Client) deliverMessage(msg *protocol.DirectMessage) {
 			Status:         storage.MessageStatusDelivered,
 			IsOutgoing:     false,
 		}
+		if forwarded, err := msg.Forwarded(); err != nil {
+			log.Printf("⚠️  Ignoring malformed forward attribution from %x: %v", msg.From[:8], err)
+		} else {
+			recordForwarded(storedMsg, forwarded)
+		}
 
 		if err := c.messageDB.SaveMessage(storedMsg); err != nil {
 			log.Printf("Failed to save incoming message to DB: %v", err)
 		}
 	}
 
+	c.recordAudit(storage.AuditEventReceived, msg.From, fmt.Sprintf("%x-%d", msg.From, msg.Timestamp), msg.ContentType)
+
 	// Send ACK to sender
-	c.sendAck(msg.From, msg.ReplyTo, msg.SequenceNumber)
+	c.sendAck(msg)
 
-	// Call application callback
-	if c.OnMessageReceived != nil {
-		c.OnMessageReceived(msg)
-	}
+	// Call content type handler or application callback
+	c.dispatchContent(msg)
 }
 
-// sendAck sends an acknowledgment for a received message
-func (c *Client) sendAck(to protocol.Address, messageID protocol.MessageID, seqNum uint64) {
-	if !c.connected {
+// sendAck sends a signed acknowledgment for a received message
+// Our relay keeps it as a delivery proof and, if the message named the
+// sender's relay, routes it back to the sender.
+func (c *Client) sendAck(msg *protocol.DirectMessage) {
+	if !c.connected.Load() {
 		return
 	}
 
 	ack := &protocol.AckMessage{
 		From:           c.Address,
-		To:             to,
-		MessageID:      messageID,
-		SequenceNumber: seqNum,
+		To:             msg.From,
+		MessageID:      msg.ReplyTo,
+		SequenceNumber: msg.SequenceNumber,
 		Timestamp:      uint64(time.Now().UnixMilli()),
 	}
+	ack.ReturnRelay, _ = msg.ReturnRelay()
+
+	// Signed so the relay can prove the delivery to whoever disputes its counts
+	signature, err := crypto.SignData(ack.Digest(), c.PrivateKey)
+	if err != nil {
+		log.Printf("Failed to sign ACK: %v", err)
+		return
+	}
+	ack.Signature = signature
 
 	payload := ack.Encode()
 
@@ -455,22 +576,17 @@ func (c *Client) sendAck(to protocol.Address, messageID protocol.MessageID, seqN
 		MessageID: protocol.GenerateMessageID(),
 	}
 
-	if err := protocol.WriteHeader(c.relayConn, header); err != nil {
-		log.Printf("Failed to send ACK header: %v", err)
-		return
-	}
-
-	if _, err := c.relayConn.Write(payload); err != nil {
-		log.Printf("Failed to send ACK payload: %v", err)
+	if err := c.writeFrame(context.Background(), header, payload); err != nil {
+		log.Printf("Failed to send ACK: %v", err)
 		return
 	}
 
-	log.Printf("✓ ACK sent to %x (seq: %d)", to[:8], seqNum)
+	log.Printf("✓ ACK sent to %x (seq: %d)", ack.To[:8], ack.SequenceNumber)
 }
 
 // sendNack sends a negative acknowledgment for a failed message
 func (c *Client) sendNack(to protocol.Address, messageID protocol.MessageID, seqNum uint64, errorCode uint8, errorMsg string) {
-	if !c.connected {
+	if !c.connected.Load() {
 		return
 	}
 
@@ -495,13 +611,8 @@ func (c *Client) sendNack(to protocol.Address, messageID protocol.MessageID, seq
 		MessageID: protocol.GenerateMessageID(),
 	}
 
-	if err := protocol.WriteHeader(c.relayConn, header); err != nil {
-		log.Printf("Failed to send NACK header: %v", err)
-		return
-	}
-
-	if _, err := c.relayConn.Write(payload); err != nil {
-		log.Printf("Failed to send NACK payload: %v", err)
+	if err := c.writeFrame(context.Background(), header, payload); err != nil {
+		log.Printf("Failed to send NACK: %v", err)
 		return
 	}
 
@@ -517,17 +628,12 @@ func (c *Client) handleAckMessage(header *protocol.Header) {
 	}
 
 	var ack protocol.AckMessage
-	if err := ack.Decode(payload); err != nil {
+	if err := protocol.DecodePayload(payload, header.Flags, &ack); err != nil {
 		log.Printf("Failed to decode ACK: %v", err)
 		return
 	}
 
-	log.Printf("✓ ACK received from %x (seq: %d)", ack.From[:8], ack.SequenceNumber)
-
-	// Call application callback
-	if c.OnAckReceived != nil {
-		c.OnAckReceived(&ack)
-	}
+	c.ackReceived(&ack)
 }
 
 // handleNackMessage handles incoming NACK messages
@@ -539,7 +645,7 @@ func (c *Client) handleNackMessage(header *protocol.Header) {
 	}
 
 	var nack protocol.NackMessage
-	if err := nack.Decode(payload); err != nil {
+	if err := protocol.DecodePayload(payload, header.Flags, &nack); err != nil {
 		log.Printf("Failed to decode NACK: %v", err)
 		return
 	}
@@ -547,8 +653,44 @@ func (c *Client) handleNackMessage(header *protocol.Header) {
 	log.Printf("✗ NACK received from %x (seq: %d, error: %d): %s",
 		nack.From[:8], nack.SequenceNumber, nack.ErrorCode, string(nack.ErrorMessage))
 
+	// The peer couldn't decrypt a ratchet message: re-key and resend it
+	if nack.ErrorCode == protocol.NackErrorDecryption {
+		if err := c.recoverFromDecryptionNack(&nack); err != nil {
+			log.Printf("⚠️  Could not recover from decryption failure at %x: %v", nack.From[:8], err)
+		}
+	}
+
 	// Call application callback
 	if c.OnNackReceived != nil {
 		c.OnNackReceived(&nack)
 	}
 }
+
+// handleRelayError handles a relay's refusal of a message we sent
+func (c *Client) handleRelayError(header *protocol.Header) {
+	payload := make([]byte, header.Length)
+	if _, err := io.ReadFull(c.relayConn, payload); err != nil {
+		log.Printf("Read relay error payload error: %v", err)
+		return
+	}
+
+	var relayErr protocol.RelayErrorMessage
+	if err := protocol.DecodePayload(payload, header.Flags, &relayErr); err != nil {
+		log.Printf("Failed to decode relay error: %v", err)
+		return
+	}
+
+	if relayErr.RetryAfter > 0 {
+		log.Printf("⚠️  Relay refused message %x: %v (retry after %s)", header.MessageID, &relayErr, relayErr.RetryAfter)
+	} else {
+		log.Printf("⚠️  Relay refused message %x: %v", header.MessageID, &relayErr)
+	}
+
+	// A refused offline sync request fails the sync waiting for it
+	c.failOfflineSync(header.MessageID, &relayErr)
+
+	// Call application callback
+	if c.OnRelayError != nil {
+		c.OnRelayError(header.MessageID, &relayErr)
+	}
+}
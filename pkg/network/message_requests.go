@@ -0,0 +1,168 @@
+package network
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+	"github.com/ZentaChain/zentalk-node/pkg/storage"
+)
+
+var (
+	ErrMessageRequestPending = errors.New("message request not accepted")
+	ErrNoMessageDB           = errors.New("no message database attached")
+)
+
+// senderStatus classifies the sender of an incoming message
+type senderStatus int
+
+const (
+	senderKnown   senderStatus = iota // Contact, or a conversation we started
+	senderUnknown                     // First contact: goes to message requests
+	senderBlocked                     // Blocked contact: dropped
+)
+
+// classifySender decides whether a message is a normal message, a request, or blocked
+// Without a message database there is no contact list, so every sender is known
+// Our own linked devices are always known.
+func (c *Client) classifySender(from protocol.Address) senderStatus {
+	if c.messageDB == nil || c.isLinkedDevice(from) {
+		return senderKnown
+	}
+
+	fromHex := hex.EncodeToString(from[:])
+
+	if contact, err := c.messageDB.GetContact(fromHex); err == nil {
+		if contact.IsBlocked {
+			return senderBlocked
+		}
+		return senderKnown
+	}
+
+	conversationID := storage.GetConversationID(hex.EncodeToString(c.Address[:]), fromHex)
+	if started, err := c.messageDB.HasOutgoingMessages(conversationID); err == nil && started {
+		return senderKnown
+	}
+
+	return senderUnknown
+}
+
+// isPendingRequest returns true if the address has an unaccepted message request
+func (c *Client) isPendingRequest(addr protocol.Address) bool {
+	if c.messageDB == nil {
+		return false
+	}
+	pending, err := c.messageDB.HasMessageRequest(hex.EncodeToString(addr[:]))
+	return err == nil && pending
+}
+
+// storeMessageRequest saves a first-contact message outside the inbox
+func (c *Client) storeMessageRequest(msg *protocol.DirectMessage) {
+	storedMsg := &storage.StoredMessage{
+		MessageID:   fmt.Sprintf("%x-%d", msg.From, msg.Timestamp),
+		FromAddress: hex.EncodeToString(msg.From[:]),
+		ToAddress:   hex.EncodeToString(msg.To[:]),
+		Content:     msg.Content,
+		ContentType: msg.ContentType,
+		Timestamp:   int64(msg.Timestamp),
+	}
+
+	if err := c.messageDB.SaveMessageRequest(storedMsg); err != nil {
+		log.Printf("Failed to save message request to DB: %v", err)
+	}
+}
+
+// GetMessageRequests lists pending message requests, newest first
+func (c *Client) GetMessageRequests() ([]*storage.MessageRequest, error) {
+	if c.messageDB == nil {
+		return nil, ErrNoMessageDB
+	}
+	return c.messageDB.GetMessageRequests()
+}
+
+// GetMessageRequestMessages returns the pending messages from a sender
+func (c *Client) GetMessageRequestMessages(from protocol.Address) ([]*storage.StoredMessage, error) {
+	if c.messageDB == nil {
+		return nil, ErrNoMessageDB
+	}
+	return c.messageDB.GetMessageRequestMessages(hex.EncodeToString(from[:]))
+}
+
+// AcceptMessageRequest adds the sender as a contact and moves their messages into the inbox
+// Read receipts and typing indicators are allowed from this point on
+func (c *Client) AcceptMessageRequest(from protocol.Address, username string) ([]*storage.StoredMessage, error) {
+	if c.messageDB == nil {
+		return nil, ErrNoMessageDB
+	}
+
+	fromHex := hex.EncodeToString(from[:])
+	if username == "" {
+		username = fromHex[:16]
+	}
+
+	now := time.Now().Unix()
+	contact := &storage.Contact{
+		Address:  fromHex,
+		Username: username,
+		AddedAt:  now,
+		LastSeen: now,
+	}
+	if err := c.messageDB.SaveContact(contact); err != nil {
+		return nil, fmt.Errorf("failed to save contact: %w", err)
+	}
+
+	messages, err := c.messageDB.AcceptMessageRequest(fromHex)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("✅ Accepted message request from %x (%d messages)", from[:8], len(messages))
+	return messages, nil
+}
+
+// DeclineMessageRequest discards a sender's pending messages without blocking them
+func (c *Client) DeclineMessageRequest(from protocol.Address) error {
+	if c.messageDB == nil {
+		return ErrNoMessageDB
+	}
+
+	if err := c.messageDB.DeleteMessageRequests(hex.EncodeToString(from[:])); err != nil {
+		return err
+	}
+
+	log.Printf("🗑️  Declined message request from %x", from[:8])
+	return nil
+}
+
+// BlockMessageRequest discards a sender's pending messages and blocks future ones
+func (c *Client) BlockMessageRequest(from protocol.Address) error {
+	if c.messageDB == nil {
+		return ErrNoMessageDB
+	}
+
+	fromHex := hex.EncodeToString(from[:])
+
+	contact, err := c.messageDB.GetContact(fromHex)
+	if err != nil {
+		contact = &storage.Contact{
+			Address:  fromHex,
+			Username: fromHex[:16],
+			AddedAt:  time.Now().Unix(),
+		}
+	}
+	contact.IsBlocked = true
+
+	if err := c.messageDB.SaveContact(contact); err != nil {
+		return fmt.Errorf("failed to block sender: %w", err)
+	}
+
+	if err := c.messageDB.DeleteMessageRequests(fromHex); err != nil {
+		return err
+	}
+
+	log.Printf("🚫 Blocked message request from %x", from[:8])
+	return nil
+}
@@ -1,6 +1,7 @@
 package network
 
 import (
+	"context"
 	"crypto/rsa"
 	"encoding/hex"
 	"errors"
@@ -17,12 +18,65 @@ import (
 // Provides forward secrecy - each message uses a unique key
 // If recipientKeyBundle is nil, it will try to use a cached bundle
 func (c *Client) SendRatchetMessage(to protocol.Address, recipientKeyBundle *protocol.KeyBundle, plaintext []byte, relayPath []*crypto.RelayInfo) error {
-	if !c.connected {
+	return c.SendRatchetMessageContext(context.Background(), to, recipientKeyBundle, plaintext, relayPath)
+}
+
+// SendRatchetMessageContext is SendRatchetMessage bounded by ctx
+// The context limits onion building and the write to the relay. A user with
+// registered devices gets a copy on each of them (see RegisterDevice).
+func (c *Client) SendRatchetMessageContext(ctx context.Context, to protocol.Address, recipientKeyBundle *protocol.KeyBundle, plaintext []byte, relayPath []*crypto.RelayInfo) error {
+	if devices := c.Devices(to); len(devices) > 0 {
+		return c.sendRatchetFanout(ctx, to, devices, recipientKeyBundle, plaintext, relayPath)
+	}
+	return c.sendRatchetMessage(ctx, to, recipientKeyBundle, plaintext, relayPath, false)
+}
+
+// sendRatchetMessage sends a ratchet message and keeps it for resending after a decryption NACK
+func (c *Client) sendRatchetMessage(ctx context.Context, to protocol.Address, recipientKeyBundle *protocol.KeyBundle, plaintext []byte, relayPath []*crypto.RelayInfo, resend bool) error {
+	if !c.connected.Load() {
 		return ErrNotConnected
 	}
 
+	// Starting a session with no bundle at hand: ask our relay for one. The
+	// fetch can't wait under sessionMu, which the receive loop needs.
+	if recipientKeyBundle == nil && c.relaySupportsKeyBundles() {
+		if _, exists := c.GetRatchetSession(to); !exists {
+			if _, cached := c.GetCachedKeyBundle(to); !cached {
+				if bundle, err := c.FetchKeyBundleContext(ctx, to); err == nil {
+					recipientKeyBundle = bundle
+				} else {
+					log.Printf("⚠️  Key bundle fetch for %x failed: %v", to[:8], err)
+				}
+			}
+		}
+	}
+
+	// Encrypting and writing under one lock keeps each chain in order on the wire
+	c.sessionMu.Lock()
+	ratchetHeader, messageID, err := c.writeRatchetMessage(ctx, to, recipientKeyBundle, plaintext, relayPath)
+	c.sessionMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// The ratchet plaintext is opaque here, so no content type is recorded
+	c.recordAudit(storage.AuditEventSent, to, fmt.Sprintf("%x", messageID), 0)
+
+	var sentHeader protocol.MessageHeader
+	if err := sentHeader.Decode(ratchetHeader); err == nil {
+		c.ratchetOutbox.record(to, &sentHeader, plaintext, relayPath, resend)
+	}
+
+	log.Printf("📤 Ratchet message sent to %x via %d relays (forward secrecy enabled)", to[:8], len(relayPath))
+	return nil
+}
+
+// writeRatchetMessage encrypts plaintext for a peer and writes it to the relay (caller holds sessionMu)
+// A session is started with X3DH if needed, its initial message going out first.
+// Returns the ratchet header and the relay message ID.
+func (c *Client) writeRatchetMessage(ctx context.Context, to protocol.Address, recipientKeyBundle *protocol.KeyBundle, plaintext []byte, relayPath []*crypto.RelayInfo) ([]byte, protocol.MessageID, error) {
 	if c.x3dhIdentity == nil {
-		return errors.New("X3DH not initialized - call InitializeX3DH() first")
+		return nil, protocol.MessageID{}, ErrX3DHNotInitialized
 	}
 
 	// Check if we have an existing ratchet session
@@ -36,7 +90,7 @@ func (c *Client) SendRatchetMessage(to protocol.Address, recipientKeyBundle *pro
 		if recipientKeyBundle == nil {
 			cachedBundle, found := c.GetCachedKeyBundle(to)
 			if !found {
-				return fmt.Errorf("no key bundle available for %x - provide bundle or cache it first", to[:8])
+				return nil, protocol.MessageID{}, fmt.Errorf("%w available for %x - provide bundle or cache it first", ErrNoKeyBundle, to[:8])
 			}
 			recipientKeyBundle = cachedBundle
 			log.Printf("Using cached key bundle for %x", to[:8])
@@ -45,10 +99,16 @@ func (c *Client) SendRatchetMessage(to protocol.Address, recipientKeyBundle *pro
 		// Perform X3DH as initiator
 		sharedSecret, ephemPriv, ephemPub, initialMsg, err := protocol.X3DHInitiator(c.Address, c.x3dhIdentity, recipientKeyBundle)
 		if err != nil {
-			return fmt.Errorf("X3DH failed: %w", err)
+			return nil, protocol.MessageID{}, fmt.Errorf("X3DH failed: %w", err)
+		}
+
+		// Pick the session's cipher suite from our preference and the bundle's
+		initialMsg.CipherSuite, err = protocol.AgreeCipherSuite(c.cipherSuitesLocked(), recipientKeyBundle.CipherSuites)
+		if err != nil {
+			return nil, protocol.MessageID{}, fmt.Errorf("cannot start a session with %x: %w", to[:8], err)
 		}
 
-		log.Printf("✅ X3DH completed: SharedSecret=%x..., UsedOPK=%d", sharedSecret[:8], initialMsg.UsedOneTimePreKeyID)
+		log.Printf("✅ X3DH completed: SharedSecret=%x..., UsedOPK=%d, Cipher=%s", sharedSecret[:8], initialMsg.UsedOneTimePreKeyID, initialMsg.CipherSuite)
 
 		// Initialize ratchet session with shared secret
 		// Use ephemeral keys from X3DH for the initial ratchet DH
@@ -61,8 +121,9 @@ func (c *Client) SendRatchetMessage(to protocol.Address, recipientKeyBundle *pro
 			to,
 		)
 		if err != nil {
-			return fmt.Errorf("failed to initialize ratchet state: %w", err)
+			return nil, protocol.MessageID{}, fmt.Errorf("failed to initialize ratchet state: %w", err)
 		}
+		session.CipherSuite = initialMsg.CipherSuite
 
 		// Store session
 		c.ratchetSessions[to] = session
@@ -75,17 +136,17 @@ func (c *Client) SendRatchetMessage(to protocol.Address, recipientKeyBundle *pro
 		}
 
 		// Send X3DH initial message to recipient so they can set up their session
-		if err := c.sendX3DHInitialMessage(to, initialMsg, relayPath); err != nil {
-			return fmt.Errorf("failed to send X3DH initial message: %w", err)
+		if err := c.sendX3DHInitialMessage(ctx, to, initialMsg, relayPath); err != nil {
+			return nil, protocol.MessageID{}, fmt.Errorf("failed to send X3DH initial message: %w", err)
 		}
 
 		log.Printf("✅ X3DH initial message sent to %x", to[:8])
 	}
 
 	// Encrypt message using ratchet
-	ratchetHeader, ciphertext, err := session.RatchetEncrypt(plaintext, AESEncryptGCM)
+	ratchetHeader, ciphertext, err := session.Seal(plaintext)
 	if err != nil {
-		return fmt.Errorf("ratchet encryption failed: %w", err)
+		return nil, protocol.MessageID{}, fmt.Errorf("ratchet encryption failed: %w", err)
 	}
 
 	// Persist updated session state (ratchet advances keys after each message)
@@ -106,9 +167,9 @@ func (c *Client) SendRatchetMessage(to protocol.Address, recipientKeyBundle *pro
 	copy(ratchetPayload[2+len(ratchetHeader):], ciphertext)
 
 	// Build onion layers around the ratchet payload
-	onion, err := crypto.BuildOnionLayers(relayPath, to, ratchetPayload)
+	onion, err := c.buildOnionLayersContext(ctx, relayPath, to, ratchetPayload)
 	if err != nil {
-		return err
+		return nil, protocol.MessageID{}, err
 	}
 
 	// Create relay forward message
@@ -122,20 +183,18 @@ func (c *Client) SendRatchetMessage(to protocol.Address, recipientKeyBundle *pro
 	}
 
 	// Send to relay
-	if err := protocol.WriteHeader(c.relayConn, header); err != nil {
-		return err
+	if err := c.checkSend(header, relayPath, len(ratchetPayload)); err != nil {
+		return nil, protocol.MessageID{}, err
 	}
-
-	if _, err := c.relayConn.Write(onion); err != nil {
-		return err
+	if err := c.writeFrameAs(ctx, c.directUsage(to, storage.UsageMessages), header, onion); err != nil {
+		return nil, protocol.MessageID{}, err
 	}
 
-	log.Printf("📤 Ratchet message sent to %x via %d relays (forward secrecy enabled)", to[:8], len(relayPath))
-	return nil
+	return ratchetHeader, header.MessageID, nil
 }
 
 // sendX3DHInitialMessage sends the X3DH initial message to recipient
-func (c *Client) sendX3DHInitialMessage(to protocol.Address, initialMsg *protocol.InitialMessage, relayPath []*crypto.RelayInfo) error {
+func (c *Client) sendX3DHInitialMessage(ctx context.Context, to protocol.Address, initialMsg *protocol.InitialMessage, relayPath []*crypto.RelayInfo) error {
 	// Encode initial message
 	encoded := initialMsg.Encode()
 
@@ -146,7 +205,7 @@ func (c *Client) sendX3DHInitialMessage(to protocol.Address, initialMsg *protoco
 	copy(payload[4:], encoded)
 
 	// Build onion layers
-	onion, err := crypto.BuildOnionLayers(relayPath, to, payload)
+	onion, err := c.buildOnionLayersContext(ctx, relayPath, to, payload)
 	if err != nil {
 		return err
 	}
@@ -162,19 +221,17 @@ func (c *Client) sendX3DHInitialMessage(to protocol.Address, initialMsg *protoco
 	}
 
 	// Send to relay
-	if err := protocol.WriteHeader(c.relayConn, header); err != nil {
-		return err
-	}
-
-	if _, err := c.relayConn.Write(onion); err != nil {
+	if err := c.checkSend(header, relayPath, len(payload)); err != nil {
 		return err
 	}
-
-	return nil
+	return c.writeFrameAs(ctx, c.directUsage(to, storage.UsageControl), header, onion)
 }
 
 // GetNextSequenceNumber gets and increments the sequence number for a peer
 func (c *Client) GetNextSequenceNumber(to protocol.Address) uint64 {
+	c.seqMu.Lock()
+	defer c.seqMu.Unlock()
+
 	seqNum := c.sendSequenceNumbers[to]
 	c.sendSequenceNumbers[to] = seqNum + 1
 	return seqNum
@@ -182,10 +239,26 @@ func (c *Client) GetNextSequenceNumber(to protocol.Address) uint64 {
 
 // SendMessage sends a message through the relay network with specified content type
 func (c *Client) SendMessage(to protocol.Address, recipientPubKey *rsa.PublicKey, content []byte, contentType uint8, relayPath []*crypto.RelayInfo) error {
-	if !c.connected {
+	return c.SendMessageContext(context.Background(), to, recipientPubKey, content, contentType, relayPath)
+}
+
+// SendMessageContext is SendMessage bounded by ctx
+// The context limits onion building and the write to the relay.
+func (c *Client) SendMessageContext(ctx context.Context, to protocol.Address, recipientPubKey *rsa.PublicKey, content []byte, contentType uint8, relayPath []*crypto.RelayInfo) error {
+	return c.sendMessageWithExtensions(ctx, to, recipientPubKey, content, contentType, nil, relayPath)
+}
+
+// sendMessageWithExtensions sends a message with optional extensions (link previews, ...)
+func (c *Client) sendMessageWithExtensions(ctx context.Context, to protocol.Address, recipientPubKey *rsa.PublicKey, content []byte, contentType uint8, extensions []protocol.MessageExtension, relayPath []*crypto.RelayInfo) error {
+	if !c.connected.Load() {
 		return ErrNotConnected
 	}
 
+	// Reject content the recipient would drop (unknown type, oversized, malformed)
+	if err := c.ContentTypes().Validate(contentType, content); err != nil {
+		return err
+	}
+
 	// Create direct message with sequence number
 	msg := &protocol.DirectMessage{
 		From:           c.Address,
@@ -194,10 +267,12 @@ func (c *Client) SendMessage(to protocol.Address, recipientPubKey *rsa.PublicKey
 		SequenceNumber: c.GetNextSequenceNumber(to),
 		ContentType:    contentType,
 		Content:        content,
+		Extensions:     extensions,
 	}
+	c.attachReturnRelay(msg, recipientPubKey)
 
-	// Encode message
-	msgPayload := msg.Encode()
+	// Encode message, tagged so the recipient knows its type
+	msgPayload := protocol.TagPayload(protocol.MsgTypeDirectMessage, msg.Encode())
 
 	// Encrypt message with recipient's public key (end-to-end encryption)
 	encryptedMsg, err := crypto.RSAEncrypt(msgPayload, recipientPubKey)
@@ -206,7 +281,7 @@ func (c *Client) SendMessage(to protocol.Address, recipientPubKey *rsa.PublicKey
 	}
 
 	// Build onion layers around encrypted message
-	onion, err := crypto.BuildOnionLayers(relayPath, to, encryptedMsg)
+	onion, err := c.buildOnionLayersContext(ctx, relayPath, to, encryptedMsg)
 	if err != nil {
 		return err
 	}
@@ -222,12 +297,22 @@ func (c *Client) SendMessage(to protocol.Address, recipientPubKey *rsa.PublicKey
 	}
 
 	// Send to relay
-	if err := protocol.WriteHeader(c.relayConn, header); err != nil {
+	if err := c.checkSend(header, relayPath, len(encryptedMsg)); err != nil {
 		return err
 	}
+	// A failed write is retried with the retransmissions unless the caller gave up
+	writeErr := c.writeFrameAs(ctx, c.directUsage(to, contentUsage(contentType)), header, onion)
+	if writeErr != nil && ctx.Err() != nil {
+		return writeErr
+	}
+	if !c.trackSend(to, msg.SequenceNumber, header.MessageID, onion, writeErr == nil) && writeErr != nil {
+		return writeErr
+	}
 
-	if _, err := c.relayConn.Write(onion); err != nil {
-		return err
+	status := storage.MessageStatusSent
+	if writeErr != nil {
+		log.Printf("⚠️  Send to %x failed, queued for retransmission: %v", to[:8], writeErr)
+		status = storage.MessageStatusSending
 	}
 
 	// Save outgoing message to database
@@ -245,22 +330,31 @@ func (c *Client) SendMessage(to protocol.Address, recipientPubKey *rsa.PublicKey
 			Content:        content,
 			ContentType:    contentType,
 			Timestamp:      int64(msg.Timestamp),
-			Status:         storage.MessageStatusSent,
+			Status:         status,
 			IsOutgoing:     true,
 		}
+		forwarded, _ := msg.Forwarded()
+		recordForwarded(storedMsg, forwarded)
 
 		if err := c.messageDB.SaveMessage(storedMsg); err != nil {
 			log.Printf("Failed to save outgoing message to DB: %v", err)
 		}
 	}
 
+	c.recordAudit(storage.AuditEventSent, to, fmt.Sprintf("%x", header.MessageID), contentType)
+
 	log.Printf("Message sent to %x via %d relays (type: 0x%02x)", to, len(relayPath), contentType)
 	return nil
 }
 
 // SendTextMessage sends a text message (convenience wrapper)
 func (c *Client) SendTextMessage(to protocol.Address, recipientPubKey *rsa.PublicKey, text string, relayPath []*crypto.RelayInfo) error {
-	return c.SendMessage(to, recipientPubKey, []byte(text), protocol.ContentTypeText, relayPath)
+	return c.SendTextMessageContext(context.Background(), to, recipientPubKey, text, relayPath)
+}
+
+// SendTextMessageContext is SendTextMessage bounded by ctx
+func (c *Client) SendTextMessageContext(ctx context.Context, to protocol.Address, recipientPubKey *rsa.PublicKey, text string, relayPath []*crypto.RelayInfo) error {
+	return c.SendMessageContext(ctx, to, recipientPubKey, []byte(text), protocol.ContentTypeText, relayPath)
 }
 
 // MediaMessage represents the content structure for media messages
@@ -274,7 +368,7 @@ type MediaMessage struct {
 // mediaType: Image, Video, Audio, or File
 // Returns: (ChunkID, encryption key, error)
 func (c *Client) SendMediaMessage(to protocol.Address, recipientPubKey *rsa.PublicKey, mediaData []byte, mediaType uint8, meshStorageClient interface{}, relayPath []*crypto.RelayInfo) (uint64, []byte, error) {
-	if !c.connected {
+	if !c.connected.Load() {
 		return 0, nil, ErrNotConnected
 	}
 
@@ -287,6 +381,7 @@ func (c *Client) SendMediaMessage(to protocol.Address, recipientPubKey *rsa.Publ
 	if !ok {
 		return 0, nil, errors.New("invalid MeshStorage client - must implement UploadEncrypted")
 	}
+	uploader = c.meterUploads(c.directUsage(to, storage.UsageMedia), uploader)
 
 	// Upload encrypted media to MeshStorage
 	chunkID, encryptionKey, err := uploader.UploadEncrypted(mediaData)
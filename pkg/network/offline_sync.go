@@ -0,0 +1,183 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/features"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// ErrOfflineSyncNotSupported is returned when the relay does not advertise offline sync
+var ErrOfflineSyncNotSupported = errors.New("relay does not support offline sync")
+
+// offlineSyncResult is a page of the offline queue, or the relay's refusal to send one
+type offlineSyncResult struct {
+	page *protocol.OfflineSyncResponse
+	err  error
+}
+
+// SetOfflineSync makes the relay leave our offline queue for SyncOfflineMessages instead of pushing it
+// Takes effect on the next handshake or resume. Relays without offline sync
+// push the queue regardless.
+func (c *Client) SetOfflineSync(enabled bool) {
+	c.offlineSync.Store(enabled)
+}
+
+// queueFlags returns the handshake and resume flags saying how we take our offline queue
+func (c *Client) queueFlags() uint16 {
+	if c.offlineSync.Load() {
+		return protocol.FlagQueueFlow | protocol.FlagOfflineSync
+	}
+	return protocol.FlagQueueFlow
+}
+
+// SyncOfflineMessages pulls the messages queued for us while we were offline
+// Pages arrive oldest first and are delivered like pushed queued messages; each
+// request acknowledges the page before it, so the relay prunes what we have.
+// since skips messages queued before it (zero = all). Returns how many
+// messages were synced. The connection must have been opened with
+// SetOfflineSync(true), and this must not be called from the receive loop.
+func (c *Client) SyncOfflineMessages(ctx context.Context, since time.Time) (int, error) {
+	if _, ok := c.relayFeatures.Version(features.OfflineSync); !ok {
+		return 0, ErrOfflineSyncNotSupported
+	}
+
+	req := &protocol.OfflineSyncRequest{}
+	if !since.IsZero() {
+		req.Since = uint64(since.Unix())
+	}
+
+	synced := 0
+	for {
+		page, err := c.offlineSyncRoundTrip(ctx, req)
+		if err != nil {
+			return synced, err
+		}
+		if len(page.Messages) == 0 {
+			break // This request acknowledged the last page
+		}
+		synced += len(page.Messages)
+		req.After = page.Cursor()
+	}
+
+	if synced > 0 {
+		log.Printf("📬 Synced %d offline messages", synced)
+	}
+	return synced, nil
+}
+
+// offlineSyncRoundTrip sends one offline sync request and waits for its page
+func (c *Client) offlineSyncRoundTrip(ctx context.Context, req *protocol.OfflineSyncRequest) (*protocol.OfflineSyncResponse, error) {
+	if !c.connected.Load() {
+		return nil, ErrNotConnected
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, KeyBundleRequestTimeout)
+		defer cancel()
+	}
+
+	payload := req.Encode()
+	header := &protocol.Header{
+		Magic:     protocol.ProtocolMagic,
+		Version:   protocol.ProtocolVersion,
+		Type:      protocol.MsgTypeOfflineSync,
+		Length:    uint32(len(payload)),
+		Flags:     0,
+		MessageID: protocol.GenerateMessageID(),
+	}
+
+	done := make(chan offlineSyncResult, 1)
+	c.offlineSyncMu.Lock()
+	if c.offlineSyncWaiters == nil {
+		c.offlineSyncWaiters = make(map[protocol.MessageID]chan offlineSyncResult)
+	}
+	c.offlineSyncWaiters[header.MessageID] = done
+	c.offlineSyncMu.Unlock()
+
+	defer func() {
+		c.offlineSyncMu.Lock()
+		delete(c.offlineSyncWaiters, header.MessageID)
+		c.offlineSyncMu.Unlock()
+	}()
+
+	if err := c.writeFrame(ctx, header, payload); err != nil {
+		return nil, err
+	}
+
+	select {
+	case result := <-done:
+		return result.page, result.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("no offline sync page from relay: %w", ctx.Err())
+	}
+}
+
+// handleOfflineSyncResponse delivers a page of the offline queue and hands it to the sync waiting for it
+// Messages are delivered here, on the receive loop, so they are handled in
+// order with everything else the relay sends.
+func (c *Client) handleOfflineSyncResponse(header *protocol.Header) {
+	payload := make([]byte, header.Length)
+	if _, err := io.ReadFull(c.relayConn, payload); err != nil {
+		log.Printf("Read offline sync page error: %v", err)
+		return
+	}
+
+	var page protocol.OfflineSyncResponse
+	if err := protocol.DecodePayload(payload, header.Flags, &page); err != nil {
+		log.Printf("Failed to decode offline sync page: %v", err)
+		c.recordUsage(usageKey{}, 0, protocol.HeaderSize+len(payload))
+		return
+	}
+
+	c.offlineSyncMu.Lock()
+	done, ok := c.offlineSyncWaiters[header.MessageID]
+	c.offlineSyncMu.Unlock()
+	if !ok {
+		// Not delivered: the next sync gets these again, as nothing acknowledged them
+		log.Printf("Offline sync page %x matches no request", header.MessageID[:8])
+		c.recordUsage(usageKey{}, 0, protocol.HeaderSize+len(payload))
+		return
+	}
+
+	// Each message counts towards its conversation; the rest of the page is control traffic
+	envelope := protocol.HeaderSize + len(payload)
+	for _, msg := range page.Messages {
+		envelope -= len(msg.Payload)
+		queued := &protocol.Header{
+			Magic:     protocol.ProtocolMagic,
+			Version:   protocol.ProtocolVersion,
+			Type:      protocol.MsgTypeDirectMessage,
+			Length:    uint32(len(msg.Payload)),
+			Flags:     protocol.FlagEncrypted | protocol.FlagQueued,
+			MessageID: protocol.QueuedMessageID(msg.Seq),
+		}
+		c.recordUsage(c.handleDirectPayload(queued, msg.Payload), 0, len(msg.Payload))
+	}
+	c.recordUsage(usageKey{}, 0, max(envelope, 0))
+
+	select {
+	case done <- offlineSyncResult{page: &page}:
+	default: // A duplicate; the first answer stands
+	}
+}
+
+// failOfflineSync ends the offline sync request a relay error answers, if any
+func (c *Client) failOfflineSync(messageID protocol.MessageID, relayErr *protocol.RelayErrorMessage) {
+	c.offlineSyncMu.Lock()
+	done, ok := c.offlineSyncWaiters[messageID]
+	c.offlineSyncMu.Unlock()
+	if !ok {
+		return
+	}
+
+	select {
+	case done <- offlineSyncResult{err: fmt.Errorf("relay refused offline sync: %w", relayErr)}:
+	default:
+	}
+}
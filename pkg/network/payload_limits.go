@@ -0,0 +1,38 @@
+package network
+
+import (
+	"log"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// SetPayloadLimits sets the payload limits this relay advertises and enforces
+// Connections negotiate the smaller of these and the peer's limits during the
+// handshake; frames over the negotiated limit close the connection.
+func (rs *RelayServer) SetPayloadLimits(limits *protocol.PayloadLimits) {
+	rs.mu.Lock()
+	rs.payloadLimits = limits
+	rs.mu.Unlock()
+
+	log.Printf("📏 Payload limits set: %d bytes default, %d per-type", limits.Limit(0), len(limits.PerType))
+}
+
+// GetPayloadLimits returns the payload limits this relay advertises
+func (rs *RelayServer) GetPayloadLimits() *protocol.PayloadLimits {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	if rs.payloadLimits == nil {
+		return protocol.DefaultPayloadLimits()
+	}
+	return rs.payloadLimits
+}
+
+// checkPeerLimits refuses a frame the peer negotiated not to accept
+func checkPeerLimits(peer *Peer, header *protocol.Header) *protocol.RelayErrorMessage {
+	if err := peer.Limits.Check(header); err != nil {
+		return protocol.NewRelayError(protocol.RelayErrPayloadTooLarge, "%x accepts at most %d bytes",
+			peer.Address[:8], peer.Limits.Limit(header.Type))
+	}
+	return nil
+}
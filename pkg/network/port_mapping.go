@@ -0,0 +1,474 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/huin/goupnp/dcps/internetgateway2"
+	"github.com/huin/goupnp/soap"
+	natpmp "github.com/jackpal/go-nat-pmp"
+	"github.com/libp2p/go-netroute"
+)
+
+const (
+	// DefaultPortMappingLease is how long a router keeps the relay's port mapping without renewal
+	DefaultPortMappingLease = time.Hour
+
+	// portMappingTimeout bounds gateway discovery and each request to the router
+	portMappingTimeout = 10 * time.Second
+
+	// natPMPTimeout bounds a single NAT-PMP request (the library retries for up to 128s otherwise)
+	natPMPTimeout = 3 * time.Second
+
+	// portMappingDescription labels the mapping in the router's admin page
+	portMappingDescription = "ZenTalk relay"
+
+	// upnpOnlyPermanentLeases is the UPnP error code of routers that reject lease durations
+	upnpOnlyPermanentLeases = 725
+)
+
+// ErrNoGateway is returned when neither UPnP nor NAT-PMP finds a router to configure
+var ErrNoGateway = errors.New("no UPnP or NAT-PMP gateway found")
+
+// carrierGradeNAT is the shared address space ISPs use behind their own NAT (RFC 6598)
+var carrierGradeNAT = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// natGateway is a router that can forward a TCP port to this host
+type natGateway interface {
+	Type() string
+	ExternalIP(ctx context.Context) (net.IP, error)
+
+	// AddMapping forwards externalPort to internalPort and returns the external port the router chose
+	AddMapping(ctx context.Context, internalPort, externalPort int, lease time.Duration) (int, error)
+	DeleteMapping(ctx context.Context, internalPort, externalPort int) error
+}
+
+// PortMapper forwards the relay's port on a home router via UPnP or NAT-PMP
+// The mapping is renewed at half its lease; when the router's external address
+// changes the relay republishes its metadata so the DHT registry stays current.
+type PortMapper struct {
+	relay    *RelayServer
+	lease    time.Duration
+	discover func(ctx context.Context) (natGateway, error) // Finds the router (discoverGateway)
+
+	gateway      natGateway
+	externalIP   net.IP
+	externalPort int
+
+	running  bool
+	stopChan chan struct{}
+	mu       sync.RWMutex
+}
+
+// NewPortMapper creates a port mapper for the relay's listening port
+func NewPortMapper(relay *RelayServer, lease time.Duration) *PortMapper {
+	if lease <= 0 {
+		lease = DefaultPortMappingLease
+	}
+
+	return &PortMapper{
+		relay:    relay,
+		lease:    lease,
+		discover: discoverGateway,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start maps the relay port on the local router and attaches the mapper to the relay
+// Call after the relay has started so the listening port is known.
+func (pm *PortMapper) Start() error {
+	if pm.relay.GetListenConfig().Family == AddressFamilyIPv6 {
+		return fmt.Errorf("port mapping needs an IPv4 listener")
+	}
+
+	pm.mu.Lock()
+	if pm.running {
+		pm.mu.Unlock()
+		return fmt.Errorf("port mapper already running")
+	}
+	pm.running = true
+	pm.mu.Unlock()
+
+	if err := pm.mapPort(); err != nil {
+		pm.mu.Lock()
+		pm.running = false
+		pm.mu.Unlock()
+		return err
+	}
+
+	pm.relay.mu.Lock()
+	pm.relay.portMapper = pm
+	pm.relay.mu.Unlock()
+
+	log.Printf("🌐 Port %d forwarded via %s, reachable at %s", pm.relay.Port, pm.GatewayType(), pm.ExternalAddress())
+	pm.relay.announceAddress()
+
+	go pm.renewLoop()
+	return nil
+}
+
+// Stop stops renewal and removes the mapping from the router
+func (pm *PortMapper) Stop() {
+	pm.mu.Lock()
+	if !pm.running {
+		pm.mu.Unlock()
+		return
+	}
+	pm.running = false
+	close(pm.stopChan)
+	gateway, externalPort := pm.gateway, pm.externalPort
+	pm.gateway, pm.externalIP, pm.externalPort = nil, nil, 0
+	pm.mu.Unlock()
+
+	if gateway == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), portMappingTimeout)
+	defer cancel()
+	if err := gateway.DeleteMapping(ctx, pm.relay.Port, externalPort); err != nil {
+		log.Printf("⚠️  Failed to remove port mapping: %v", err)
+		return
+	}
+	log.Printf("🛑 Removed %s port mapping for port %d", gateway.Type(), externalPort)
+}
+
+// ExternalAddress returns the public host:port forwarded to the relay ("" if not mapped)
+func (pm *PortMapper) ExternalAddress() string {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	if pm.externalIP == nil {
+		return ""
+	}
+	return net.JoinHostPort(pm.externalIP.String(), strconv.Itoa(pm.externalPort))
+}
+
+// GatewayType returns the protocol used for the mapping ("" if not mapped)
+func (pm *PortMapper) GatewayType() string {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	if pm.gateway == nil {
+		return ""
+	}
+	return pm.gateway.Type()
+}
+
+// renewLoop renews the mapping at half its lease until stopped
+func (pm *PortMapper) renewLoop() {
+	ticker := time.NewTicker(pm.lease / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			pm.renew()
+		case <-pm.stopChan:
+			return
+		}
+	}
+}
+
+// renew refreshes the mapping, rediscovering the gateway if it stopped answering
+func (pm *PortMapper) renew() {
+	pm.mu.RLock()
+	running := pm.running
+	pm.mu.RUnlock()
+	if !running {
+		return
+	}
+
+	previous := pm.ExternalAddress()
+
+	if err := pm.refresh(); err != nil {
+		log.Printf("⚠️  Port mapping renewal failed: %v (rediscovering gateway)", err)
+		if err := pm.mapPort(); err != nil {
+			log.Printf("⚠️  Port mapping lost: %v", err)
+			pm.mu.Lock()
+			pm.gateway, pm.externalIP, pm.externalPort = nil, nil, 0
+			pm.mu.Unlock()
+		}
+	}
+
+	if current := pm.ExternalAddress(); current != previous {
+		log.Printf("🌐 External address changed: %q -> %q", previous, current)
+		pm.relay.announceAddress()
+	}
+}
+
+// refresh renews the existing mapping and re-reads the router's external address
+func (pm *PortMapper) refresh() error {
+	pm.mu.RLock()
+	gateway, externalPort := pm.gateway, pm.externalPort
+	pm.mu.RUnlock()
+
+	if gateway == nil {
+		return ErrNoGateway
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), portMappingTimeout)
+	defer cancel()
+
+	mapped, err := gateway.AddMapping(ctx, pm.relay.Port, externalPort, pm.lease)
+	if err != nil {
+		return fmt.Errorf("%s: failed to renew mapping: %w", gateway.Type(), err)
+	}
+	externalIP, err := publicExternalIP(ctx, gateway)
+	if err != nil {
+		return err
+	}
+
+	pm.mu.Lock()
+	pm.externalIP, pm.externalPort = externalIP, mapped
+	pm.mu.Unlock()
+	return nil
+}
+
+// mapPort discovers a gateway and maps the relay port, preferring the same external port
+func (pm *PortMapper) mapPort() error {
+	ctx, cancel := context.WithTimeout(context.Background(), portMappingTimeout)
+	defer cancel()
+
+	gateway, err := pm.discover(ctx)
+	if err != nil {
+		return err
+	}
+
+	// An address the router itself got from another NAT is no use to remote peers
+	externalIP, err := publicExternalIP(ctx, gateway)
+	if err != nil {
+		return err
+	}
+
+	externalPort, err := addMapping(ctx, gateway, pm.relay.Port, pm.lease)
+	if err != nil {
+		return err
+	}
+
+	pm.mu.Lock()
+	pm.gateway, pm.externalIP, pm.externalPort = gateway, externalIP, externalPort
+	pm.mu.Unlock()
+	return nil
+}
+
+// addMapping maps internalPort to the same external port, falling back to a few random ones
+func addMapping(ctx context.Context, gateway natGateway, internalPort int, lease time.Duration) (int, error) {
+	ports := []int{internalPort}
+	for range 3 {
+		ports = append(ports, 10000+rand.IntN(65535-10000))
+	}
+
+	var err error
+	for _, port := range ports {
+		var mapped int
+		if mapped, err = gateway.AddMapping(ctx, internalPort, port, lease); err == nil {
+			return mapped, nil
+		}
+	}
+	return 0, fmt.Errorf("%s: failed to map port %d: %w", gateway.Type(), internalPort, err)
+}
+
+// publicExternalIP returns the gateway's external address if it is publicly routable
+func publicExternalIP(ctx context.Context, gateway natGateway) (net.IP, error) {
+	ip, err := gateway.ExternalIP(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to get external address: %w", gateway.Type(), err)
+	}
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() || carrierGradeNAT.Contains(ip) {
+		return nil, fmt.Errorf("%s: router's external address %s is not public (double NAT?)", gateway.Type(), ip)
+	}
+	return ip, nil
+}
+
+// announceAddress republishes relay metadata after the advertised address changed
+func (rs *RelayServer) announceAddress() {
+	if rs.dhtNode == nil {
+		return
+	}
+
+	address := rs.AdvertisedAddress()
+	rs.mu.Lock()
+	published := rs.metadata != nil
+	if published {
+		rs.metadata.NetworkAddress = address
+	}
+	rs.mu.Unlock()
+	if !published {
+		return
+	}
+
+	if err := rs.PublishToDHT(); err != nil {
+		log.Printf("⚠️  Failed to publish new relay address: %v", err)
+	}
+}
+
+// discoverGateway finds the local router, trying UPnP before NAT-PMP
+func discoverGateway(ctx context.Context) (natGateway, error) {
+	gatewayIP, localIP, err := defaultRoute()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNoGateway, err)
+	}
+
+	upnpGateway, upnpErr := discoverUPnP(ctx, localIP)
+	if upnpErr == nil {
+		return upnpGateway, nil
+	}
+	pmpGateway, pmpErr := discoverNATPMP(gatewayIP)
+	if pmpErr == nil {
+		return pmpGateway, nil
+	}
+	return nil, fmt.Errorf("%w (UPnP: %v, NAT-PMP: %v)", ErrNoGateway, upnpErr, pmpErr)
+}
+
+// defaultRoute returns the IPv4 default gateway and this host's address towards it
+func defaultRoute() (gateway, local net.IP, err error) {
+	router, err := netroute.New()
+	if err != nil {
+		return nil, nil, err
+	}
+	_, gateway, local, err = router.Route(net.IPv4zero)
+	if err != nil {
+		return nil, nil, err
+	}
+	if gateway == nil || local == nil {
+		return nil, nil, fmt.Errorf("no IPv4 default route")
+	}
+	return gateway, local, nil
+}
+
+// upnpClient is implemented by the WANIPConnection and WANPPPConnection clients
+type upnpClient interface {
+	GetExternalIPAddressCtx(ctx context.Context) (string, error)
+	AddPortMappingCtx(ctx context.Context, remoteHost string, externalPort uint16, protocol string,
+		internalPort uint16, internalClient string, enabled bool, description string, lease uint32) error
+	DeletePortMappingCtx(ctx context.Context, remoteHost string, externalPort uint16, protocol string) error
+}
+
+// upnpGateway maps ports through a UPnP Internet Gateway Device
+type upnpGateway struct {
+	client  upnpClient
+	typ     string
+	localIP net.IP
+}
+
+// discoverUPnP searches for IGDv2 and IGDv1 WAN connection services
+func discoverUPnP(ctx context.Context, localIP net.IP) (*upnpGateway, error) {
+	var gateways []*upnpGateway
+	if clients, _, err := internetgateway2.NewWANIPConnection2ClientsCtx(ctx); err == nil {
+		for _, client := range clients {
+			gateways = append(gateways, &upnpGateway{client, "UPnP (IP2)", localIP})
+		}
+	}
+	if clients, _, err := internetgateway2.NewWANIPConnection1ClientsCtx(ctx); err == nil {
+		for _, client := range clients {
+			gateways = append(gateways, &upnpGateway{client, "UPnP (IP1)", localIP})
+		}
+	}
+	if clients, _, err := internetgateway2.NewWANPPPConnection1ClientsCtx(ctx); err == nil {
+		for _, client := range clients {
+			gateways = append(gateways, &upnpGateway{client, "UPnP (PPP1)", localIP})
+		}
+	}
+
+	// Some devices advertise services they can't serve; use the first that answers
+	for _, gateway := range gateways {
+		if _, err := gateway.client.GetExternalIPAddressCtx(ctx); err == nil {
+			return gateway, nil
+		}
+	}
+	return nil, fmt.Errorf("no UPnP internet gateway answered")
+}
+
+// Type returns the UPnP service used
+func (g *upnpGateway) Type() string {
+	return g.typ
+}
+
+// ExternalIP returns the router's WAN address
+func (g *upnpGateway) ExternalIP(ctx context.Context) (net.IP, error) {
+	address, err := g.client.GetExternalIPAddressCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid external address %q", address)
+	}
+	return ip, nil
+}
+
+// AddMapping forwards externalPort, retrying as permanent on routers without lease support
+func (g *upnpGateway) AddMapping(ctx context.Context, internalPort, externalPort int, lease time.Duration) (int, error) {
+	add := func(seconds uint32) error {
+		return g.client.AddPortMappingCtx(ctx, "", uint16(externalPort), "TCP",
+			uint16(internalPort), g.localIP.String(), true, portMappingDescription, seconds)
+	}
+
+	err := add(uint32(lease / time.Second))
+	var fault *soap.SOAPFaultError
+	if errors.As(err, &fault) && fault.Detail.UPnPError.Errorcode == upnpOnlyPermanentLeases {
+		err = add(0)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return externalPort, nil
+}
+
+// DeleteMapping removes the forwarding for externalPort
+func (g *upnpGateway) DeleteMapping(ctx context.Context, internalPort, externalPort int) error {
+	return g.client.DeletePortMappingCtx(ctx, "", uint16(externalPort), "TCP")
+}
+
+// pmpGateway maps ports through NAT-PMP
+// The library has no context support; natPMPTimeout bounds each request instead.
+type pmpGateway struct {
+	client *natpmp.Client
+}
+
+// discoverNATPMP checks whether the default gateway answers NAT-PMP
+func discoverNATPMP(gatewayIP net.IP) (*pmpGateway, error) {
+	client := natpmp.NewClientWithTimeout(gatewayIP, natPMPTimeout)
+	if _, err := client.GetExternalAddress(); err != nil {
+		return nil, err
+	}
+	return &pmpGateway{client: client}, nil
+}
+
+// Type returns "NAT-PMP"
+func (g *pmpGateway) Type() string {
+	return "NAT-PMP"
+}
+
+// ExternalIP returns the router's WAN address
+func (g *pmpGateway) ExternalIP(ctx context.Context) (net.IP, error) {
+	result, err := g.client.GetExternalAddress()
+	if err != nil {
+		return nil, err
+	}
+	a := result.ExternalIPAddress
+	return net.IPv4(a[0], a[1], a[2], a[3]), nil
+}
+
+// AddMapping requests externalPort; the router may assign a different one
+func (g *pmpGateway) AddMapping(ctx context.Context, internalPort, externalPort int, lease time.Duration) (int, error) {
+	result, err := g.client.AddPortMapping("tcp", internalPort, externalPort, int(lease/time.Second))
+	if err != nil {
+		return 0, err
+	}
+	return int(result.MappedExternalPort), nil
+}
+
+// DeleteMapping removes the forwarding for internalPort (NAT-PMP deletes by internal port)
+func (g *pmpGateway) DeleteMapping(ctx context.Context, internalPort, externalPort int) error {
+	_, err := g.client.AddPortMapping("tcp", internalPort, 0, 0)
+	return err
+}
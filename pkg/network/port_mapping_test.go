@@ -0,0 +1,233 @@
+package network
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/huin/goupnp/soap"
+)
+
+// fakeGateway is a router that records the mappings it holds
+type fakeGateway struct {
+	ip      net.IP
+	taken   map[int]bool // External ports already forwarded to another host
+	broken  bool         // Stops answering requests
+	renewed int
+
+	mappings map[int]time.Duration // External port -> lease
+	mu       sync.Mutex
+}
+
+func newFakeGateway(ip string) *fakeGateway {
+	return &fakeGateway{ip: net.ParseIP(ip), taken: make(map[int]bool), mappings: make(map[int]time.Duration)}
+}
+
+func (g *fakeGateway) Type() string {
+	return "fake"
+}
+
+func (g *fakeGateway) ExternalIP(ctx context.Context) (net.IP, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.broken {
+		return nil, errors.New("gateway not answering")
+	}
+	return g.ip, nil
+}
+
+func (g *fakeGateway) AddMapping(ctx context.Context, internalPort, externalPort int, lease time.Duration) (int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.broken {
+		return 0, errors.New("gateway not answering")
+	}
+	if g.taken[externalPort] {
+		return 0, errors.New("conflict in mapping entry")
+	}
+	if _, ok := g.mappings[externalPort]; ok {
+		g.renewed++
+	}
+	g.mappings[externalPort] = lease
+	return externalPort, nil
+}
+
+func (g *fakeGateway) DeleteMapping(ctx context.Context, internalPort, externalPort int) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.mappings, externalPort)
+	return nil
+}
+
+// set changes the gateway's state under its lock
+func (g *fakeGateway) set(f func(g *fakeGateway)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	f(g)
+}
+
+// newTestPortMapper returns a port mapper for a relay on port 7000 whose discovery finds gateways in turn
+func newTestPortMapper(t *testing.T, gateways ...*fakeGateway) *PortMapper {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pm := NewPortMapper(NewRelayServer(7000, key), time.Hour)
+
+	var mu sync.Mutex
+	pm.discover = func(ctx context.Context) (natGateway, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(gateways) == 0 {
+			return nil, ErrNoGateway
+		}
+		gateway := gateways[0]
+		gateways = gateways[1:]
+		return gateway, nil
+	}
+	return pm
+}
+
+func TestPortMapperStartStop(t *testing.T) {
+	gateway := newFakeGateway("203.0.113.5")
+	pm := newTestPortMapper(t, gateway)
+
+	if err := pm.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if got := pm.ExternalAddress(); got != "203.0.113.5:7000" {
+		t.Errorf("ExternalAddress() = %q, want the relay port on the router's address", got)
+	}
+	if lease := gateway.mappings[7000]; lease != time.Hour {
+		t.Errorf("mapping lease = %s, want 1h", lease)
+	}
+	if pm.relay.portMapper != pm {
+		t.Error("Start() did not attach the mapper to the relay")
+	}
+
+	// Shutting down removes the forwarding from the router
+	pm.Stop()
+	if len(gateway.mappings) != 0 {
+		t.Errorf("router still holds %d mappings after Stop()", len(gateway.mappings))
+	}
+	if got := pm.ExternalAddress(); got != "" {
+		t.Errorf("ExternalAddress() after Stop() = %q, want none", got)
+	}
+	pm.Stop()
+}
+
+func TestPortMapperStartFailures(t *testing.T) {
+	// A router behind another NAT can't make the relay reachable
+	pm := newTestPortMapper(t, newFakeGateway("100.64.1.2"))
+	if err := pm.Start(); err == nil {
+		t.Error("Start() behind carrier-grade NAT succeeded")
+	}
+
+	pm = newTestPortMapper(t)
+	if err := pm.Start(); !errors.Is(err, ErrNoGateway) {
+		t.Errorf("Start() without a gateway error = %v, want ErrNoGateway", err)
+	}
+	// A failed start can be retried
+	if pm.running {
+		t.Error("mapper still marked running after a failed Start()")
+	}
+
+	// A port forwarded to another host falls back to a random one
+	gateway := newFakeGateway("203.0.113.5")
+	gateway.taken[7000] = true
+	pm = newTestPortMapper(t, gateway)
+	if err := pm.Start(); err != nil {
+		t.Fatalf("Start() with the port taken error = %v", err)
+	}
+	defer pm.Stop()
+	if pm.externalPort == 7000 || pm.externalPort < 10000 {
+		t.Errorf("external port = %d, want a random fallback port", pm.externalPort)
+	}
+}
+
+func TestPortMapperRenew(t *testing.T) {
+	gateway := newFakeGateway("203.0.113.5")
+	replacement := newFakeGateway("198.51.100.9")
+	pm := newTestPortMapper(t, gateway, replacement)
+	if err := pm.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer pm.Stop()
+
+	// Renewal refreshes the lease on the same port
+	pm.renew()
+	if gateway.renewed != 1 {
+		t.Fatalf("mapping renewed %d times, want 1", gateway.renewed)
+	}
+
+	// A new WAN address is picked up on renewal
+	gateway.set(func(g *fakeGateway) { g.ip = net.ParseIP("203.0.113.77") })
+	pm.renew()
+	if got := pm.ExternalAddress(); got != "203.0.113.77:7000" {
+		t.Errorf("ExternalAddress() after renewal = %q, want the new WAN address", got)
+	}
+
+	// A router that stops answering is replaced by rediscovery
+	gateway.set(func(g *fakeGateway) { g.broken = true })
+	pm.renew()
+	if got := pm.ExternalAddress(); got != "198.51.100.9:7000" {
+		t.Errorf("ExternalAddress() after rediscovery = %q, want the new router's", got)
+	}
+
+	// With no router left the mapping is dropped rather than advertised stale
+	replacement.set(func(g *fakeGateway) { g.broken = true })
+	pm.renew()
+	if got := pm.ExternalAddress(); got != "" {
+		t.Errorf("ExternalAddress() with no gateway = %q, want none", got)
+	}
+	if got := pm.GatewayType(); got != "" {
+		t.Errorf("GatewayType() with no gateway = %q, want none", got)
+	}
+}
+
+// fakeUPnPClient is a UPnP WAN connection service
+type fakeUPnPClient struct {
+	permanentOnly bool
+	leases        []uint32
+}
+
+func (c *fakeUPnPClient) GetExternalIPAddressCtx(ctx context.Context) (string, error) {
+	return "203.0.113.5", nil
+}
+
+func (c *fakeUPnPClient) AddPortMappingCtx(ctx context.Context, remoteHost string, externalPort uint16, protocol string,
+	internalPort uint16, internalClient string, enabled bool, description string, lease uint32) error {
+	c.leases = append(c.leases, lease)
+	if c.permanentOnly && lease != 0 {
+		fault := &soap.SOAPFaultError{}
+		fault.Detail.UPnPError.Errorcode = upnpOnlyPermanentLeases
+		return fault
+	}
+	return nil
+}
+
+func (c *fakeUPnPClient) DeletePortMappingCtx(ctx context.Context, remoteHost string, externalPort uint16, protocol string) error {
+	return nil
+}
+
+func TestUPnPPermanentLeaseFallback(t *testing.T) {
+	client := &fakeUPnPClient{permanentOnly: true}
+	gateway := &upnpGateway{client: client, typ: "UPnP (IP1)", localIP: net.ParseIP("192.168.1.10")}
+
+	port, err := gateway.AddMapping(context.Background(), 7000, 7000, time.Hour)
+	if err != nil {
+		t.Fatalf("AddMapping() error = %v", err)
+	}
+	if port != 7000 {
+		t.Errorf("AddMapping() = %d, want 7000", port)
+	}
+	if len(client.leases) != 2 || client.leases[0] != 3600 || client.leases[1] != 0 {
+		t.Errorf("requested leases %v, want [3600 0]", client.leases)
+	}
+}
@@ -0,0 +1,280 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+	"github.com/ZentaChain/zentalk-node/pkg/storage"
+)
+
+// PowerConfig controls how often the client wakes the radio
+type PowerConfig struct {
+	PingInterval  time.Duration // Keepalive interval; must stay below the relay's idle timeout
+	BatchInterval time.Duration // How long typing, receipts and presence are held before sending (0 = immediately)
+	DeferMedia    bool          // Hold media downloads until the network is unmetered
+}
+
+// DefaultPowerConfig returns the settings used while plugged in or in the foreground
+func DefaultPowerConfig() PowerConfig {
+	return PowerConfig{
+		PingInterval: 30 * time.Second,
+	}
+}
+
+// LowPowerConfig returns the settings used in low-power mode
+// The ping interval leaves headroom under the relay's default 90s idle timeout.
+func LowPowerConfig() PowerConfig {
+	return PowerConfig{
+		PingInterval:  75 * time.Second,
+		BatchInterval: 10 * time.Second,
+		DeferMedia:    true,
+	}
+}
+
+// controlKind identifies control traffic that can be coalesced
+type controlKind uint8
+
+const (
+	controlTyping controlKind = iota + 1
+	controlReceipt
+	controlPresence
+)
+
+// controlFrame is an encoded header and onion waiting in the batch
+type controlFrame struct {
+	kind controlKind
+	to   protocol.Address
+	data []byte
+}
+
+// powerState holds the power mode, the control batch and deferred media
+type powerState struct {
+	lowPower  bool
+	unmetered bool
+	normal    PowerConfig
+	low       PowerConfig
+
+	batch      []controlFrame
+	flushTimer *time.Timer
+	media      []func()
+
+	mu sync.Mutex
+}
+
+// newPowerState returns a power state in normal mode
+func newPowerState() *powerState {
+	return &powerState{
+		normal: DefaultPowerConfig(),
+		low:    LowPowerConfig(),
+	}
+}
+
+// SetLowPowerMode switches low-power mode on or off
+// Mobile wrappers call this when the app is backgrounded or the battery saver
+// changes. Turning it off sends anything batched and starts deferred media.
+func (c *Client) SetLowPowerMode(enabled bool) {
+	ps := c.power
+	ps.mu.Lock()
+	ps.lowPower = enabled
+	ps.mu.Unlock()
+
+	if enabled {
+		log.Printf("🔋 Low-power mode enabled")
+		return
+	}
+
+	log.Printf("🔋 Low-power mode disabled")
+	c.FlushControl()
+	c.runDeferredMedia()
+}
+
+// IsLowPowerMode reports whether low-power mode is on
+func (c *Client) IsLowPowerMode() bool {
+	ps := c.power
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	return ps.lowPower
+}
+
+// SetUnmeteredNetwork tells the client whether it is on Wi-Fi (or another unmetered network)
+// Deferred media downloads start as soon as the network becomes unmetered.
+func (c *Client) SetUnmeteredNetwork(unmetered bool) {
+	ps := c.power
+	ps.mu.Lock()
+	ps.unmetered = unmetered
+	ps.mu.Unlock()
+
+	if unmetered {
+		c.runDeferredMedia()
+	}
+}
+
+// SetPowerConfig replaces the settings used in normal and low-power mode
+func (c *Client) SetPowerConfig(normal, low PowerConfig) {
+	ps := c.power
+	ps.mu.Lock()
+	ps.normal = normal
+	ps.low = low
+	ps.mu.Unlock()
+
+	log.Printf("🔋 Power config: ping %v/%v, batch %v/%v", normal.PingInterval, low.PingInterval, normal.BatchInterval, low.BatchInterval)
+}
+
+// activeConfigLocked returns the settings for the current mode; ps.mu must be held
+func (ps *powerState) activeConfigLocked() PowerConfig {
+	if ps.lowPower {
+		return ps.low
+	}
+	return ps.normal
+}
+
+// pingInterval returns the keepalive interval for the current mode
+func (c *Client) pingInterval() time.Duration {
+	ps := c.power
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if interval := ps.activeConfigLocked().PingInterval; interval > 0 {
+		return interval
+	}
+	return DefaultPowerConfig().PingInterval
+}
+
+// sendControl sends a typing indicator, read receipt or presence update
+// In low-power mode the frame joins a batch that is written in one go when the
+// batch interval expires or the next ping goes out. A newer typing indicator or
+// presence update for the same recipient replaces the queued one.
+func (c *Client) sendControl(kind controlKind, to protocol.Address, payload []byte) error {
+	header := &protocol.Header{
+		Magic:     protocol.ProtocolMagic,
+		Version:   protocol.ProtocolVersion,
+		Type:      protocol.MsgTypeRelayForward,
+		Length:    uint32(len(payload)),
+		Flags:     protocol.FlagEncrypted,
+		MessageID: protocol.GenerateMessageID(),
+	}
+
+	var frame bytes.Buffer
+	if err := protocol.WriteHeader(&frame, header); err != nil {
+		return err
+	}
+	frame.Write(payload)
+
+	ps := c.power
+	ps.mu.Lock()
+	interval := ps.activeConfigLocked().BatchInterval
+	if interval <= 0 {
+		ps.mu.Unlock()
+		if err := c.writeBytes(context.Background(), frame.Bytes()); err != nil {
+			return err
+		}
+		c.recordUsage(c.directUsage(to, storage.UsageControl), frame.Len(), 0)
+		return nil
+	}
+
+	if kind != controlReceipt {
+		for i, queued := range ps.batch {
+			if queued.kind == kind && queued.to == to {
+				ps.batch = append(ps.batch[:i], ps.batch[i+1:]...)
+				break
+			}
+		}
+	}
+	ps.batch = append(ps.batch, controlFrame{kind: kind, to: to, data: frame.Bytes()})
+
+	if ps.flushTimer == nil {
+		ps.flushTimer = time.AfterFunc(interval, func() {
+			if err := c.FlushControl(); err != nil {
+				log.Printf("⚠️  Control batch flush failed: %v", err)
+			}
+		})
+	}
+	ps.mu.Unlock()
+
+	return nil
+}
+
+// FlushControl sends batched control traffic now
+// Frames stay queued if the client is disconnected and go out with the next flush.
+func (c *Client) FlushControl() error {
+	ps := c.power
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if ps.flushTimer != nil {
+		ps.flushTimer.Stop()
+		ps.flushTimer = nil
+	}
+
+	if len(ps.batch) == 0 || !c.connected.Load() {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for _, frame := range ps.batch {
+		buf.Write(frame.data)
+	}
+
+	if err := c.writeBytes(context.Background(), buf.Bytes()); err != nil {
+		return err
+	}
+
+	for _, frame := range ps.batch {
+		c.recordUsage(c.directUsage(frame.to, storage.UsageControl), len(frame.data), 0)
+	}
+	log.Printf("🔋 Sent %d batched control messages", len(ps.batch))
+	ps.batch = nil
+
+	return nil
+}
+
+// ScheduleMediaDownload runs a media download now or defers it until the network is unmetered
+// Returns true if the download started immediately. Deferred downloads start
+// when SetUnmeteredNetwork(true) or SetLowPowerMode(false) is called.
+func (c *Client) ScheduleMediaDownload(download func()) bool {
+	ps := c.power
+	ps.mu.Lock()
+	if ps.activeConfigLocked().DeferMedia && !ps.unmetered {
+		ps.media = append(ps.media, download)
+		ps.mu.Unlock()
+		return false
+	}
+	ps.mu.Unlock()
+
+	go download()
+	return true
+}
+
+// PendingMediaDownloads returns the number of deferred media downloads
+func (c *Client) PendingMediaDownloads() int {
+	ps := c.power
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	return len(ps.media)
+}
+
+// runDeferredMedia starts deferred downloads if the current mode and network allow it
+func (c *Client) runDeferredMedia() {
+	ps := c.power
+	ps.mu.Lock()
+	if ps.activeConfigLocked().DeferMedia && !ps.unmetered {
+		ps.mu.Unlock()
+		return
+	}
+	pending := ps.media
+	ps.media = nil
+	ps.mu.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+
+	log.Printf("📥 Starting %d deferred media downloads", len(pending))
+	for _, download := range pending {
+		go download()
+	}
+}
@@ -1,6 +1,7 @@
 package network
 
 import (
+	"context"
 	"crypto/rsa"
 	"log"
 	"time"
@@ -13,7 +14,7 @@ import (
 // avatarChunkID: MeshStorage chunk ID of the encrypted avatar
 // avatarKey: AES-256 key to decrypt the avatar (32 bytes)
 func (c *Client) UpdateProfile(username, bio string, avatarChunkID uint64, avatarKey []byte) (*protocol.ProfileUpdate, error) {
-	if !c.connected {
+	if !c.connected.Load() {
 		return nil, ErrNotConnected
 	}
 
@@ -24,6 +25,16 @@ func (c *Client) UpdateProfile(username, bio string, avatarChunkID uint64, avata
 		Timestamp:     uint64(time.Now().UnixMilli()),
 	}
 
+	// Let contacts know their messages to us are being audited
+	if c.auditLog.Load() {
+		profile.Flags |= protocol.ProfileFlagAuditLog
+	}
+
+	// Bots say so, so clients can treat them differently (e.g. no presence)
+	if c.IsBot() {
+		profile.Flags |= protocol.ProfileFlagBot
+	}
+
 	// Set username (max 32 bytes)
 	copy(profile.Username[:], []byte(username))
 
@@ -56,12 +67,12 @@ func (c *Client) UpdateProfile(username, bio string, avatarChunkID uint64, avata
 
 // BroadcastProfile sends profile update to a specific user
 func (c *Client) BroadcastProfile(profile *protocol.ProfileUpdate, toAddr protocol.Address, toPubKey *rsa.PublicKey, relayPath []*crypto.RelayInfo) error {
-	if !c.connected {
+	if !c.connected.Load() {
 		return ErrNotConnected
 	}
 
 	// Encode profile
-	profilePayload := profile.Encode()
+	profilePayload := protocol.TagPayload(protocol.MsgTypeProfileUpdate, profile.Encode())
 
 	log.Printf("Broadcasting profile to %x", toAddr)
 
@@ -93,7 +104,7 @@ func (c *Client) BroadcastProfile(profile *protocol.ProfileUpdate, toAddr protoc
 	copy(combined[2+len(encryptedKey):], encryptedProfile)
 
 	// Build onion layers
-	onion, err := crypto.BuildOnionLayers(relayPath, toAddr, combined)
+	onion, err := c.buildOnionLayers(relayPath, toAddr, combined)
 	if err != nil {
 		return err
 	}
@@ -109,11 +120,10 @@ func (c *Client) BroadcastProfile(profile *protocol.ProfileUpdate, toAddr protoc
 	}
 
 	// Send to relay
-	if err := protocol.WriteHeader(c.relayConn, header); err != nil {
+	if err := c.checkSend(header, relayPath, len(combined)); err != nil {
 		return err
 	}
-
-	if _, err := c.relayConn.Write(onion); err != nil {
+	if err := c.writeFrame(context.Background(), header, onion); err != nil {
 		return err
 	}
 
@@ -123,7 +133,7 @@ func (c *Client) BroadcastProfile(profile *protocol.ProfileUpdate, toAddr protoc
 
 // RequestProfile requests a profile from another user
 func (c *Client) RequestProfile(targetAddr protocol.Address, targetPubKey *rsa.PublicKey, relayPath []*crypto.RelayInfo) error {
-	if !c.connected {
+	if !c.connected.Load() {
 		return ErrNotConnected
 	}
 
@@ -136,7 +146,7 @@ func (c *Client) RequestProfile(targetAddr protocol.Address, targetPubKey *rsa.P
 		Content:     []byte("PROFILE_REQUEST"),
 	}
 
-	msgPayload := msg.Encode()
+	msgPayload := protocol.TagPayload(protocol.MsgTypeDirectMessage, msg.Encode())
 
 	// Encrypt with target's public key
 	encryptedMsg, err := crypto.RSAEncrypt(msgPayload, targetPubKey)
@@ -145,7 +155,7 @@ func (c *Client) RequestProfile(targetAddr protocol.Address, targetPubKey *rsa.P
 	}
 
 	// Build onion layers
-	onion, err := crypto.BuildOnionLayers(relayPath, targetAddr, encryptedMsg)
+	onion, err := c.buildOnionLayers(relayPath, targetAddr, encryptedMsg)
 	if err != nil {
 		return err
 	}
@@ -161,11 +171,10 @@ func (c *Client) RequestProfile(targetAddr protocol.Address, targetPubKey *rsa.P
 	}
 
 	// Send to relay
-	if err := protocol.WriteHeader(c.relayConn, header); err != nil {
+	if err := c.checkSend(header, relayPath, len(encryptedMsg)); err != nil {
 		return err
 	}
-
-	if _, err := c.relayConn.Write(onion); err != nil {
+	if err := c.writeFrame(context.Background(), header, onion); err != nil {
 		return err
 	}
 
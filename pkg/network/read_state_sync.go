@@ -0,0 +1,416 @@
+package network
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+	"github.com/ZentaChain/zentalk-node/pkg/storage"
+)
+
+// readStateFile is the file name used for linked devices and conversation read state inside session storage
+const readStateFile = "read_state.json"
+
+// LinkedDevice is another of the user's own devices, kept in sync with this one
+type LinkedDevice struct {
+	Address   protocol.Address
+	PublicKey *rsa.PublicKey
+}
+
+// ReadStateSyncHandler is called when a linked device reads or (un)mutes conversations
+// states holds only the conversations whose state changed on this device.
+type ReadStateSyncHandler func(from protocol.Address, states []protocol.ReadState)
+
+// readStateSync is a client's linked devices and the merged read state of its conversations
+type readStateSync struct {
+	devices map[protocol.Address]*rsa.PublicKey
+	states  map[protocol.Address]*protocol.ReadState
+	onSync  ReadStateSyncHandler
+	mu      sync.Mutex
+}
+
+// readStateStore is the JSON form of readStateSync kept in session storage
+type readStateStore struct {
+	Devices map[string]string    `json:"devices"` // Address (hex) -> public key PEM
+	States  []protocol.ReadState `json:"states"`
+}
+
+// EnableReadStateSync accepts read state from linked devices
+// Call it at startup: until it (or LinkDevice) has run, syncs from linked
+// devices are treated as messages from strangers. onSync may be nil.
+func (c *Client) EnableReadStateSync(onSync ReadStateSyncHandler) error {
+	readState, err := c.getReadStateSync()
+	if err != nil {
+		return err
+	}
+
+	readState.mu.Lock()
+	readState.onSync = onSync
+	readState.mu.Unlock()
+
+	log.Printf("📖 Read state sync enabled (%d linked devices)", len(readState.devices))
+	return nil
+}
+
+// getReadStateSync returns the read state, loading it and registering the handler on first use
+func (c *Client) getReadStateSync() (*readStateSync, error) {
+	if c.readStateSync != nil {
+		return c.readStateSync, nil
+	}
+
+	readState := &readStateSync{
+		devices: make(map[protocol.Address]*rsa.PublicKey),
+		states:  make(map[protocol.Address]*protocol.ReadState),
+	}
+	if path := c.readStatePath(); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read read state: %w", err)
+		}
+		if err == nil {
+			var stored readStateStore
+			if err := json.Unmarshal(data, &stored); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal read state: %w", err)
+			}
+			for addrHex, keyPEM := range stored.Devices {
+				addrBytes, err := hex.DecodeString(addrHex)
+				if err != nil || len(addrBytes) != len(protocol.Address{}) {
+					return nil, fmt.Errorf("invalid linked device address %q", addrHex)
+				}
+				key, err := crypto.ImportPublicKeyPEM([]byte(keyPEM))
+				if err != nil {
+					return nil, fmt.Errorf("invalid key for linked device %s: %w", addrHex, err)
+				}
+				readState.devices[protocol.Address(addrBytes)] = key
+			}
+			for i := range stored.States {
+				state := stored.States[i]
+				readState.states[state.Conversation] = &state
+			}
+		}
+	}
+
+	c.readStateSync = readState
+	c.RegisterContentHandler(protocol.ContentTypeReadStateSync, c.handleReadStateSync)
+	return readState, nil
+}
+
+// LinkDevice adds another of our devices to sync read state with
+// Both devices must link each other. Follow with SyncReadState to bring the
+// new device up to date.
+func (c *Client) LinkDevice(addr protocol.Address, pubKey *rsa.PublicKey) error {
+	if addr == c.Address {
+		return fmt.Errorf("cannot link this device to itself")
+	}
+
+	readState, err := c.getReadStateSync()
+	if err != nil {
+		return err
+	}
+
+	readState.mu.Lock()
+	defer readState.mu.Unlock()
+
+	readState.devices[addr] = pubKey
+	if err := c.persistReadState(readState); err != nil {
+		return err
+	}
+
+	log.Printf("📖 Linked device %x", addr[:8])
+	return nil
+}
+
+// UnlinkDevice stops syncing read state with a device
+func (c *Client) UnlinkDevice(addr protocol.Address) error {
+	readState, err := c.getReadStateSync()
+	if err != nil {
+		return err
+	}
+
+	readState.mu.Lock()
+	defer readState.mu.Unlock()
+
+	if _, exists := readState.devices[addr]; !exists {
+		return nil
+	}
+	delete(readState.devices, addr)
+	return c.persistReadState(readState)
+}
+
+// LinkedDevices returns the devices read state is synced with, ordered by address
+func (c *Client) LinkedDevices() ([]LinkedDevice, error) {
+	readState, err := c.getReadStateSync()
+	if err != nil {
+		return nil, err
+	}
+
+	readState.mu.Lock()
+	defer readState.mu.Unlock()
+
+	return readState.linkedDevicesLocked(), nil
+}
+
+// ConversationReadState returns the merged read state of a conversation
+// A conversation never read or muted on any device has the zero state.
+func (c *Client) ConversationReadState(peer protocol.Address) (protocol.ReadState, error) {
+	readState, err := c.getReadStateSync()
+	if err != nil {
+		return protocol.ReadState{}, err
+	}
+
+	readState.mu.Lock()
+	defer readState.mu.Unlock()
+
+	if state, exists := readState.states[peer]; exists {
+		return *state, nil
+	}
+	return protocol.ReadState{Conversation: peer}, nil
+}
+
+// MarkConversationRead marks a conversation read up to a message and tells our other devices
+// upTo is the Timestamp of the newest message read; newer ones stay unread.
+func (c *Client) MarkConversationRead(peer protocol.Address, upTo uint64, relayPath []*crypto.RelayInfo) error {
+	return c.updateReadState(peer, relayPath, func(state *protocol.ReadState) bool {
+		if upTo <= state.LastRead {
+			return false
+		}
+		state.LastRead = upTo
+		return true
+	})
+}
+
+// SetConversationMuted mutes or unmutes a conversation and tells our other devices
+func (c *Client) SetConversationMuted(peer protocol.Address, muted bool, relayPath []*crypto.RelayInfo) error {
+	return c.updateReadState(peer, relayPath, func(state *protocol.ReadState) bool {
+		if muted == state.Muted && state.MutedAt != 0 {
+			return false
+		}
+		// Stay ahead of a change synced from a device whose clock runs fast
+		state.MutedAt = max(uint64(time.Now().UnixMilli()), state.MutedAt+1)
+		state.Muted = muted
+		return true
+	})
+}
+
+// SyncReadState sends the state of every known conversation to all linked devices
+func (c *Client) SyncReadState(relayPath []*crypto.RelayInfo) error {
+	readState, err := c.getReadStateSync()
+	if err != nil {
+		return err
+	}
+
+	readState.mu.Lock()
+	states := make([]protocol.ReadState, 0, len(readState.states))
+	for _, state := range readState.states {
+		states = append(states, *state)
+	}
+	devices := readState.linkedDevicesLocked()
+	readState.mu.Unlock()
+
+	if len(states) == 0 {
+		return nil
+	}
+	return c.sendReadState(devices, states, relayPath)
+}
+
+// updateReadState changes a conversation's state locally and fans the change out to linked devices
+// update reports whether it changed the state; nothing is sent if it didn't.
+func (c *Client) updateReadState(peer protocol.Address, relayPath []*crypto.RelayInfo, update func(*protocol.ReadState) bool) error {
+	readState, err := c.getReadStateSync()
+	if err != nil {
+		return err
+	}
+
+	readState.mu.Lock()
+	state, exists := readState.states[peer]
+	if !exists {
+		state = &protocol.ReadState{Conversation: peer}
+	}
+	if !update(state) {
+		readState.mu.Unlock()
+		return nil
+	}
+	readState.states[peer] = state
+	c.applyReadState(state)
+	if err := c.persistReadState(readState); err != nil {
+		log.Printf("⚠️  Failed to persist read state: %v", err)
+	}
+	changed := *state
+	devices := readState.linkedDevicesLocked()
+	readState.mu.Unlock()
+
+	return c.sendReadState(devices, []protocol.ReadState{changed}, relayPath)
+}
+
+// sendReadState sends states to each device, MaxReadStateEntries per message
+// A device that can't be reached doesn't stop the others from being sent to.
+func (c *Client) sendReadState(devices []LinkedDevice, states []protocol.ReadState, relayPath []*crypto.RelayInfo) error {
+	if len(devices) == 0 {
+		return nil
+	}
+	if !c.connected.Load() {
+		return ErrNotConnected
+	}
+
+	var errs []error
+	for start := 0; start < len(states); start += protocol.MaxReadStateEntries {
+		batch := &protocol.ReadStateSync{States: states[start:min(start+protocol.MaxReadStateEntries, len(states))]}
+		content := batch.Encode()
+		for _, device := range devices {
+			if err := c.SendMessage(device.Address, device.PublicKey, content, protocol.ContentTypeReadStateSync, relayPath); err != nil {
+				errs = append(errs, fmt.Errorf("failed to sync read state to %x: %w", device.Address[:8], err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// handleReadStateSync merges read state sent by one of our linked devices
+func (c *Client) handleReadStateSync(msg *protocol.DirectMessage) {
+	readState := c.readStateSync
+	readState.mu.Lock()
+	if _, linked := readState.devices[msg.From]; !linked {
+		readState.mu.Unlock()
+		log.Printf("⚠️  Ignoring read state from %x, which is not a linked device", msg.From[:8])
+		return
+	}
+
+	var content protocol.ReadStateSync
+	if err := content.Decode(msg.Content); err != nil {
+		readState.mu.Unlock()
+		log.Printf("Invalid read state sync from %x: %v", msg.From[:8], err)
+		return
+	}
+
+	var changed []protocol.ReadState
+	for i := range content.States {
+		incoming := &content.States[i]
+		state, exists := readState.states[incoming.Conversation]
+		if !exists {
+			state = &protocol.ReadState{Conversation: incoming.Conversation}
+		}
+		if !state.Merge(incoming) {
+			continue
+		}
+		readState.states[incoming.Conversation] = state
+		c.applyReadState(state)
+		changed = append(changed, *state)
+	}
+	if len(changed) > 0 {
+		if err := c.persistReadState(readState); err != nil {
+			log.Printf("⚠️  Failed to persist read state: %v", err)
+		}
+	}
+	onSync := readState.onSync
+	readState.mu.Unlock()
+
+	if len(changed) == 0 {
+		return
+	}
+	log.Printf("📖 Read state of %d conversations synced from %x", len(changed), msg.From[:8])
+	if onSync != nil {
+		onSync(msg.From, changed)
+	}
+}
+
+// isLinkedDevice returns true if addr is one of our linked devices
+func (c *Client) isLinkedDevice(addr protocol.Address) bool {
+	readState := c.readStateSync
+	if readState == nil {
+		return false
+	}
+
+	readState.mu.Lock()
+	defer readState.mu.Unlock()
+	_, linked := readState.devices[addr]
+	return linked
+}
+
+// applyReadState updates the conversation's unread count and mute flag in the message database
+// Called with readState.mu held; a no-op without a database.
+func (c *Client) applyReadState(state *protocol.ReadState) {
+	if c.messageDB == nil {
+		return
+	}
+
+	conversationID := storage.GetConversationID(
+		hex.EncodeToString(c.Address[:]),
+		hex.EncodeToString(state.Conversation[:]),
+	)
+	if state.LastRead != 0 {
+		if err := c.messageDB.MarkConversationReadUntil(conversationID, int64(state.LastRead)); err != nil {
+			log.Printf("Failed to mark conversation %x read: %v", state.Conversation[:8], err)
+		}
+	}
+	if state.MutedAt != 0 {
+		if err := c.messageDB.SetConversationMuted(conversationID, state.Muted); err != nil {
+			log.Printf("Failed to update mute for conversation %x: %v", state.Conversation[:8], err)
+		}
+	}
+}
+
+// linkedDevicesLocked returns the linked devices ordered by address (caller holds readState.mu)
+func (s *readStateSync) linkedDevicesLocked() []LinkedDevice {
+	devices := make([]LinkedDevice, 0, len(s.devices))
+	for addr, key := range s.devices {
+		devices = append(devices, LinkedDevice{Address: addr, PublicKey: key})
+	}
+	slices.SortFunc(devices, func(a, b LinkedDevice) int {
+		return bytes.Compare(a.Address[:], b.Address[:])
+	})
+	return devices
+}
+
+// persistReadState writes linked devices and read state to session storage (no-op without storage)
+// Called with readState.mu held.
+func (c *Client) persistReadState(readState *readStateSync) error {
+	path := c.readStatePath()
+	if path == "" {
+		return nil
+	}
+
+	stored := readStateStore{
+		Devices: make(map[string]string, len(readState.devices)),
+		States:  make([]protocol.ReadState, 0, len(readState.states)),
+	}
+	for addr, key := range readState.devices {
+		keyPEM, err := crypto.ExportPublicKeyPEM(key)
+		if err != nil {
+			return fmt.Errorf("failed to export key of linked device %x: %w", addr[:8], err)
+		}
+		stored.Devices[hex.EncodeToString(addr[:])] = string(keyPEM)
+	}
+	for _, state := range readState.states {
+		stored.States = append(stored.States, *state)
+	}
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal read state: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write read state: %w", err)
+	}
+
+	return nil
+}
+
+// readStatePath returns where read state is persisted ("" if no session storage)
+func (c *Client) readStatePath() string {
+	if c.sessionStorage == nil {
+		return ""
+	}
+	return filepath.Join(c.sessionStorage.storageDir, readStateFile)
+}
@@ -1,9 +1,12 @@
 package network
 
 import (
+	"context"
+	"io"
 	"log"
-	"net"
 	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
 )
 
 // receiveLoopWithReconnect wraps receiveLoop with automatic reconnection
@@ -16,12 +19,18 @@ func (c *Client) receiveLoopWithReconnect() {
 		c.receiveLoop()
 
 		// If explicitly disconnected, don't reconnect
-		if !c.connected {
+		if !c.connected.Load() {
 			log.Println("Client disconnected, stopping receive loop")
 			return
 		}
 
-		// Connection dropped, attempt reconnection
+		// Connection dropped; the resumption ticket's lifetime starts now
+		if c.resumeTicket != nil && c.ticketDeadline.IsZero() {
+			c.ticketDeadline = time.Now().Add(time.Duration(c.resumeTicket.Lifetime) * time.Second)
+		}
+		c.recordLinkDown()
+
+		// Attempt reconnection
 		log.Printf("🔄 Connection lost, reconnecting in %v...", backoff)
 		time.Sleep(backoff)
 
@@ -35,6 +44,7 @@ func (c *Client) receiveLoopWithReconnect() {
 			}
 		} else {
 			log.Println("✅ Reconnected successfully")
+			c.updateConnectionQuality()
 			backoff = time.Second // Reset backoff on success
 		}
 	}
@@ -48,13 +58,31 @@ func (c *Client) reconnect() error {
 	}
 
 	// Establish new connection
-	conn, err := net.Dial("tcp", c.relayAddress)
+	conn, err := c.dialRelay(context.Background(), c.relayAddress)
 	if err != nil {
 		return err
 	}
 
+	// Frames from other goroutines wait until the new connection is ready
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
 	c.relayConn = conn
 
+	if err := c.startTLS(context.Background()); err != nil {
+		conn.Close()
+		return err
+	}
+
+	// Resume the previous session if we can; otherwise handshake on the same connection
+	resumed, err := c.resumeSession()
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if resumed {
+		return nil
+	}
+
 	// Perform handshake
 	if err := c.performHandshake(); err != nil {
 		conn.Close()
@@ -64,18 +92,152 @@ func (c *Client) reconnect() error {
 	return nil
 }
 
+// resumeSession redeems the relay's ticket instead of a full handshake
+// Returns false if there is no usable ticket or the relay refused it (relays
+// predating resumption answer with an error); the connection can still handshake.
+func (c *Client) resumeSession() (bool, error) {
+	ticket := c.resumeTicket
+	if ticket == nil || (!c.ticketDeadline.IsZero() && time.Now().After(c.ticketDeadline)) {
+		return false, nil
+	}
+
+	// Tickets are single-use; the relay sends a new one if it accepts this
+	c.resumeTicket = nil
+	c.ticketDeadline = time.Time{}
+
+	req := &protocol.ResumeRequest{Ticket: ticket.Ticket, Cursor: c.queueCursor}
+	payload := req.Encode()
+
+	header := &protocol.Header{
+		Magic:     protocol.ProtocolMagic,
+		Version:   protocol.ProtocolVersion,
+		Type:      protocol.MsgTypeResume,
+		Length:    uint32(len(payload)),
+		Flags:     c.queueFlags(),
+		MessageID: protocol.GenerateMessageID(),
+	}
+
+	sent := time.Now()
+	if err := protocol.WriteHeader(c.relayConn, header); err != nil {
+		return false, err
+	}
+	if _, err := c.relayConn.Write(payload); err != nil {
+		return false, err
+	}
+
+	ackHeader, err := protocol.ReadHeader(c.relayConn)
+	if err != nil {
+		return false, err
+	}
+	rtt := time.Since(sent)
+	if err := c.payloadLimits.Check(ackHeader); err != nil {
+		return false, err
+	}
+
+	reply := make([]byte, ackHeader.Length)
+	if _, err := io.ReadFull(c.relayConn, reply); err != nil {
+		return false, err
+	}
+
+	var ack protocol.ResumeAck
+	if ackHeader.Type != protocol.MsgTypeResumeAck || ack.Decode(reply) != nil || ack.Status != protocol.ResumeAccepted {
+		log.Println("🎫 Relay refused session resumption, handshaking")
+		return false, nil
+	}
+
+	// The session keeps its wire mode; the relay echoes the flag as in the handshake
+	if c.uniformRecords != nil && recordsRequested(ackHeader) {
+		c.relayConn = newUniformConn(c.relayConn, *c.uniformRecords)
+	}
+
+	c.recordLinkUp(rtt)
+	log.Printf("🎫 Session resumed (queue cursor %d)", c.queueCursor)
+	return true, nil
+}
+
+// SetQueueWindow sets how many queued messages the relay may send before we ask for more
+// Smaller windows spread a large backlog out; 0 restores the default. Relays
+// clamp it to protocol.MaxQueueWindow.
+func (c *Client) SetQueueWindow(window int) {
+	c.queueWindow.Store(uint32(min(max(window, 0), protocol.MaxQueueWindow)))
+}
+
+// handleQueueProgress reports the relay's progress flushing our queue and opens the next window
+func (c *Client) handleQueueProgress(header *protocol.Header) {
+	payload := make([]byte, header.Length)
+	if _, err := io.ReadFull(c.relayConn, payload); err != nil {
+		log.Printf("Read payload error: %v", err)
+		return
+	}
+
+	var progress protocol.QueueProgress
+	if err := protocol.DecodePayload(payload, header.Flags, &progress); err != nil {
+		log.Printf("Decode queue progress error: %v", err)
+		return
+	}
+
+	c.recordOfflineBacklog(progress.Remaining)
+	if progress.Remaining == 0 {
+		log.Printf("📬 Offline queue delivered (%d messages)", progress.Delivered)
+	} else {
+		window := c.queueWindow.Load()
+		if window == 0 {
+			window = protocol.DefaultQueueWindow
+		}
+
+		// Everything before the cursor has been read by now, since frames arrive in order
+		grant := &protocol.QueueWindow{Cursor: c.queueCursor, Window: window}
+		payload := grant.Encode()
+		header := &protocol.Header{
+			Magic:     protocol.ProtocolMagic,
+			Version:   protocol.ProtocolVersion,
+			Type:      protocol.MsgTypeQueueWindow,
+			Length:    uint32(len(payload)),
+			Flags:     0,
+			MessageID: protocol.GenerateMessageID(),
+		}
+		if err := c.writeFrame(context.Background(), header, payload); err != nil {
+			log.Printf("Failed to open queue window: %v", err)
+		}
+	}
+
+	if c.OnQueueProgress != nil {
+		c.OnQueueProgress(&progress)
+	}
+}
+
+// handleTicket stores a session resumption ticket from the relay
+func (c *Client) handleTicket(header *protocol.Header) {
+	payload := make([]byte, header.Length)
+	if _, err := io.ReadFull(c.relayConn, payload); err != nil {
+		log.Printf("Read payload error: %v", err)
+		return
+	}
+
+	var ticket protocol.SessionTicket
+	if err := protocol.DecodePayload(payload, header.Flags, &ticket); err != nil {
+		log.Printf("Decode session ticket error: %v", err)
+		return
+	}
+
+	c.resumeTicket = &ticket
+	c.ticketDeadline = time.Time{}
+}
+
 // keepaliveLoop sends periodic pings to keep connection alive
+// The interval follows the power mode; batched control traffic rides along.
 func (c *Client) keepaliveLoop() {
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
-
 	for {
-		<-ticker.C
+		time.Sleep(c.pingInterval())
 
-		if !c.connected {
+		if !c.connected.Load() {
 			return
 		}
 
+		if err := c.FlushControl(); err != nil {
+			log.Printf("⚠️  Control batch flush failed: %v", err)
+		}
+
 		if err := c.SendPing(); err != nil {
 			log.Printf("⚠️  Keepalive ping failed: %v", err)
 		}
@@ -8,6 +8,7 @@ import (
 	"log"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ZentaChain/zentalk-node/pkg/crypto"
@@ -23,19 +24,91 @@ type RelayServer struct {
 	PrivateKey *rsa.PrivateKey
 	PublicKey  *rsa.PublicKey
 
-	listener net.Listener
-	peers    map[string]*Peer
-	mu       sync.RWMutex
+	listeners    []net.Listener
+	listenConfig ListenConfig
+	peers        map[string]*Peer
+	mu           sync.RWMutex
 
 	// Message queue for offline users
 	messageQueue *storage.RelayMessageQueue
 
+	// Signed proofs of recipients' acks, backing reported relay counts
+	deliveryProofs *storage.DeliveryProofStore
+
+	// Users' X3DH key bundles, fetched by contacts while they are offline
+	keyBundles *storage.KeyBundleStore
+
+	// Offline queue flushes in progress, one per user
+	flushMu      sync.Mutex
+	queueFlushes map[protocol.Address]*queueFlush
+
 	// DHT for relay discovery
 	dhtNode        *dht.Node
 	relayDiscovery *RelayDiscovery
 	metadata       *RelayMetadata
 	startTime      time.Time
 
+	// Which onion roles this relay accepts (forward, deliver, or both)
+	exitPolicy ExitPolicy
+
+	// Consulted before queueing messages for offline users (optional)
+	queueFilter RelayQueueFilter
+
+	// Replacement relay announced to users after a queue migration (nil = not migrating)
+	migrationTarget *protocol.RelayMovedNotice
+
+	// Cluster membership when several processes share one queue (nil = standalone)
+	cluster *relayCluster
+
+	// Serves the queue and sessions to a warm standby (nil = no standby)
+	replication *replicationServer
+
+	// Periodic bandwidth/latency self-measurement (optional)
+	prober *BandwidthProber
+
+	// Payload limits advertised in handshakes (nil = protocol defaults)
+	payloadLimits *protocol.PayloadLimits
+
+	// What users may send and how large delivered messages may be (nil = no policy)
+	relayPolicy *protocol.RelayPolicy
+
+	// Storage endpoints and media limits advertised to users (nil = none)
+	mediaDirectory *protocol.MediaDirectory
+
+	// Outbound token buckets for relay-to-relay links (nil = unshaped)
+	meshShaper *meshShaper
+
+	// Inbound forward limits per connection and IP address (nil = unlimited)
+	forwardLimiter *forwardLimiter
+
+	// Ranks queued messages by stake and payment vouchers (nil = all equal)
+	queuePriority *queuePriority
+
+	// Routes to users connected to other relays (nil = mesh routing disabled)
+	routing *forwardingTable
+
+	// Read deadlines and half-open cap against slow or idle peers
+	connLimits ConnectionLimits
+	halfOpen   atomic.Int32 // Accepted connections still waiting for a handshake
+
+	// Uniform-records wire mode for links that request it (nil = disabled)
+	uniformRecords *UniformRecordConfig
+
+	// TLS offered to clients with StartTLS (nil = plain TCP only)
+	tls *relayTLS
+
+	// Resumption tickets for users reconnecting after a brief drop (nil = disabled)
+	resumption *resumptionStore
+
+	// Router port forwarding via UPnP/NAT-PMP (nil = not attempted or failed)
+	portMapper *PortMapper
+
+	// Organizations sharing this relay (nil = single-tenant)
+	tenants *tenantRegistry
+
+	// Registered bot accounts (nil = none)
+	bots *botRegistry
+
 	// Statistics
 	messagesRelayed uint64
 	lastHeartbeat   time.Time
@@ -46,11 +119,16 @@ type RelayServer struct {
 
 // Peer represents a connected peer (relay or client)
 type Peer struct {
-	Conn       net.Conn
-	Address    protocol.Address
-	PublicKey  *rsa.PublicKey
-	ClientType uint8
-	LastSeen   time.Time
+	Conn        net.Conn
+	Address     protocol.Address
+	PublicKey   *rsa.PublicKey
+	ClientType  uint8
+	LastSeen    time.Time
+	Limits      *protocol.PayloadLimits // Negotiated in the handshake
+	Tenant      string                  // Tenant named in the handshake (users on multi-tenant relays)
+	Bot         bool                    // Authenticated as a registered bot
+	QueueFlow   bool                    // Takes its offline queue in windows it grants (FlagQueueFlow)
+	OfflineSync bool                    // Pulls its offline queue with OfflineSync instead (FlagOfflineSync)
 }
 
 // NewRelayServer creates a new relay server
@@ -61,6 +139,8 @@ func NewRelayServer(port int, privateKey *rsa.PrivateKey) *RelayServer {
 		PublicKey:  &privateKey.PublicKey,
 		peers:      make(map[string]*Peer),
 		startTime:  time.Now(),
+		connLimits: DefaultConnectionLimits(),
+		resumption: newResumptionStore(DefaultResumeTicketLifetime),
 	}
 }
 
@@ -77,26 +157,32 @@ func (rs *RelayServer) GetMessageQueue() *storage.RelayMessageQueue {
 
 // Start starts the relay server
 func (rs *RelayServer) Start() error {
-	addr := fmt.Sprintf(":%d", rs.Port)
-	listener, err := net.Listen("tcp", addr)
+	listeners, err := rs.listen()
 	if err != nil {
 		return err
 	}
 
-	rs.listener = listener
-	log.Printf("Relay server listening on %s", addr)
-
-	go rs.acceptLoop()
+	rs.listeners = listeners
+	for _, listener := range listeners {
+		go rs.acceptLoop(listener)
+	}
 
 	return nil
 }
 
 // Stop stops the relay server
 func (rs *RelayServer) Stop() error {
-	if rs.listener != nil {
-		return rs.listener.Close()
+	rs.DisableCluster()
+	rs.DisableMeshRouting()
+	rs.DisableReplication()
+
+	var firstErr error
+	for _, listener := range rs.listeners {
+		if err := listener.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
 	}
-	return nil
+	return firstErr
 }
 
 // ConnectToRelay connects this relay to another relay
@@ -114,7 +200,7 @@ func (rs *RelayServer) ConnectToRelay(relayAddress string, relayAddr protocol.Ad
 	log.Printf("Connecting to relay %s (%x)", relayAddress, relayAddr)
 
 	// Dial the relay
-	conn, err := net.Dial("tcp", relayAddress)
+	conn, err := net.Dial(rs.GetListenConfig().Family.Network(), relayAddress)
 	if err != nil {
 		return fmt.Errorf("failed to connect to relay: %v", err)
 	}
@@ -133,16 +219,18 @@ func (rs *RelayServer) ConnectToRelay(relayAddress string, relayAddr protocol.Ad
 		PublicKey:       pubKeyPEM,
 		ClientType:      protocol.ClientTypeRelay,
 		Timestamp:       uint64(time.Now().Unix()),
+		Limits:          rs.GetPayloadLimits(),
 	}
 
 	payload := hs.Encode()
 
+	records := rs.getUniformRecords()
 	header := &protocol.Header{
 		Magic:     protocol.ProtocolMagic,
 		Version:   protocol.ProtocolVersion,
 		Type:      protocol.MsgTypeHandshake,
 		Length:    uint32(len(payload)),
-		Flags:     0,
+		Flags:     recordFlag(records),
 		MessageID: protocol.GenerateMessageID(),
 	}
 
@@ -156,7 +244,8 @@ func (rs *RelayServer) ConnectToRelay(relayAddress string, relayAddr protocol.Ad
 		return err
 	}
 
-	// Wait for handshake ACK
+	// Wait for handshake ACK, without letting a stalled relay hold us forever
+	conn.SetReadDeadline(deadline(rs.GetConnectionLimits().HeaderTimeout))
 	ackHeader, err := protocol.ReadHeader(conn)
 	if err != nil {
 		conn.Close()
@@ -168,13 +257,29 @@ func (rs *RelayServer) ConnectToRelay(relayAddress string, relayAddr protocol.Ad
 		return fmt.Errorf("expected handshake ACK, got %x", ackHeader.Type)
 	}
 
-	// Read and discard ACK payload
+	if err := rs.GetPayloadLimits().Check(ackHeader); err != nil {
+		conn.Close()
+		return err
+	}
+
+	// Read the ACK payload for the relay's payload limits
+	var ack protocol.HandshakeMessage
 	if ackHeader.Length > 0 {
 		ackPayload := make([]byte, ackHeader.Length)
 		if _, err := io.ReadFull(conn, ackPayload); err != nil {
 			conn.Close()
 			return err
 		}
+		if err := ack.Decode(ackPayload); err != nil {
+			conn.Close()
+			return fmt.Errorf("invalid handshake ACK: %v", err)
+		}
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	// The relay confirms uniform records by echoing the flag
+	if records != nil && recordsRequested(ackHeader) {
+		conn = newUniformConn(conn, *records)
 	}
 
 	// Store peer
@@ -184,6 +289,7 @@ func (rs *RelayServer) ConnectToRelay(relayAddress string, relayAddr protocol.Ad
 		PublicKey:  nil,                      // Could decode from ACK if needed
 		ClientType: protocol.ClientTypeRelay, // Connecting to another relay
 		LastSeen:   time.Now(),
+		Limits:     protocol.NegotiatePayloadLimits(rs.GetPayloadLimits(), ack.Limits),
 	}
 
 	rs.mu.Lock()
@@ -193,7 +299,7 @@ func (rs *RelayServer) ConnectToRelay(relayAddress string, relayAddr protocol.Ad
 	log.Printf("✅ Connected to relay %x", relayAddr)
 
 	// Start handling messages from this relay
-	go rs.handleConnection(conn)
+	go rs.handleConnection(conn, peer)
 
 	return nil
 }
@@ -215,6 +321,60 @@ func (rs *RelayServer) GetStats() map[string]interface{} {
 		stats["queued_messages"] = queueSize
 	}
 
+	if rs.deliveryProofs != nil {
+		proofs, _ := rs.deliveryProofs.CountDeliveryProofs(0, 0)
+		stats["delivery_proofs"] = proofs
+	}
+
+	// Add cluster membership if clustered
+	if rs.cluster != nil {
+		stats["cluster_node"] = rs.cluster.self.ID
+		stats["cluster_size"] = len(rs.cluster.ring.nodes)
+	}
+
+	if rs.routing != nil {
+		stats["mesh_routes"] = rs.routing.size()
+	}
+
+	if rs.tenants != nil {
+		rs.tenants.mu.Lock()
+		stats["tenants"] = len(rs.tenants.tenants)
+		rs.tenants.mu.Unlock()
+	}
+
+	if rs.bots != nil {
+		rs.bots.mu.Lock()
+		stats["bots"] = len(rs.bots.bots)
+		rs.bots.mu.Unlock()
+	}
+
+	if rs.forwardLimiter != nil {
+		limited := rs.forwardLimiter.snapshot()
+		stats["forwards_rate_limited"] = limited.RefusedConnection + limited.RefusedSource
+	}
+
+	if port := rs.listenConfig.WebSocketPort; port != 0 {
+		stats["websocket_port"] = port
+	}
+
+	// Add the router-forwarded public address if mapped
+	if rs.portMapper != nil {
+		if address := rs.portMapper.ExternalAddress(); address != "" {
+			stats["external_address"] = address
+			stats["port_mapping"] = rs.portMapper.GatewayType()
+		}
+	}
+
+	// Add self-measured capacity if a probe round has completed
+	if rs.prober != nil {
+		if m := rs.prober.LastMeasurement(); m != nil {
+			stats["bandwidth_kbps"] = m.BandwidthKbps
+			stats["probe_latency_ms"] = m.LatencyMs
+			stats["peers_probed"] = m.PeersProbed
+			stats["last_probe"] = m.MeasuredAt
+		}
+	}
+
 	return stats
 }
 
@@ -228,7 +388,7 @@ func (rs *RelayServer) AttachDHT(node *dht.Node) {
 // SetRelayMetadata sets the relay's metadata for DHT publishing
 func (rs *RelayServer) SetRelayMetadata(region, operator, version string, maxConnections int) error {
 	if rs.dhtNode == nil {
-		return fmt.Errorf("DHT not attached - call AttachDHT() first")
+		return ErrDHTNotAttached
 	}
 
 	// Export public key to PEM
@@ -238,9 +398,9 @@ func (rs *RelayServer) SetRelayMetadata(region, operator, version string, maxCon
 	}
 
 	// Create metadata
-	rs.metadata = &RelayMetadata{
+	metadata := &RelayMetadata{
 		Address:        rs.Address,
-		NetworkAddress: fmt.Sprintf("localhost:%d", rs.Port),
+		NetworkAddress: rs.AdvertisedAddress(),
 		PublicKeyPEM:   string(pubKeyPEM),
 		Region:         region,
 		Operator:       operator,
@@ -249,8 +409,15 @@ func (rs *RelayServer) SetRelayMetadata(region, operator, version string, maxCon
 		Uptime:         uint64(time.Since(rs.startTime).Seconds()),
 		LastSeen:       time.Now().Unix(),
 		Reliability:    0.95, // Default high reliability
+		ExitPolicy:     rs.GetExitPolicy().String(),
+		Features:       rs.Features().Active(),
+		PaddingBuckets: rs.GetRelayPolicy().Buckets(),
 	}
 
+	rs.mu.Lock()
+	rs.metadata = metadata
+	rs.mu.Unlock()
+
 	log.Printf("✅ Relay metadata set: region=%s, operator=%s", region, operator)
 	return nil
 }
@@ -258,23 +425,31 @@ func (rs *RelayServer) SetRelayMetadata(region, operator, version string, maxCon
 // PublishToDHT publishes the relay's metadata to the DHT
 func (rs *RelayServer) PublishToDHT() error {
 	if rs.dhtNode == nil {
-		return fmt.Errorf("DHT not attached - call AttachDHT() first")
+		return ErrDHTNotAttached
 	}
 
+	active := rs.Features().Active()
+	buckets := rs.GetRelayPolicy().Buckets()
+
+	// Update dynamic fields and publish a copy, as the prober may update the original
+	rs.mu.Lock()
 	if rs.metadata == nil {
+		rs.mu.Unlock()
 		return fmt.Errorf("metadata not set - call SetRelayMetadata() first")
 	}
-
-	// Update dynamic fields
 	rs.metadata.Uptime = uint64(time.Since(rs.startTime).Seconds())
 	rs.metadata.LastSeen = time.Now().Unix()
+	rs.metadata.Features = active
+	rs.metadata.PaddingBuckets = buckets
+	metadata := *rs.metadata
+	rs.mu.Unlock()
 
 	// Publish to DHT
-	if err := rs.relayDiscovery.PublishRelay(rs.metadata); err != nil {
+	if err := rs.relayDiscovery.PublishRelay(&metadata); err != nil {
 		return fmt.Errorf("failed to publish relay: %w", err)
 	}
 
-	log.Printf("✅ Relay published to DHT (uptime: %ds)", rs.metadata.Uptime)
+	log.Printf("✅ Relay published to DHT (uptime: %ds)", metadata.Uptime)
 	return nil
 }
 
@@ -305,14 +480,20 @@ func (rs *RelayServer) AutoPublishToDHT(interval time.Duration) {
 	}
 }
 
-// GetMetadata returns the relay's metadata
+// GetMetadata returns a copy of the relay's metadata (nil if not set)
 func (rs *RelayServer) GetMetadata() *RelayMetadata {
-	if rs.metadata != nil {
-		// Update dynamic fields before returning
-		rs.metadata.Uptime = uint64(time.Since(rs.startTime).Seconds())
-		rs.metadata.LastSeen = time.Now().Unix()
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if rs.metadata == nil {
+		return nil
 	}
-	return rs.metadata
+
+	// Update dynamic fields before returning
+	rs.metadata.Uptime = uint64(time.Since(rs.startTime).Seconds())
+	rs.metadata.LastSeen = time.Now().Unix()
+	metadata := *rs.metadata
+	return &metadata
 }
 
 // Helper to write uint64
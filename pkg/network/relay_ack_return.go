@@ -0,0 +1,50 @@
+package network
+
+import (
+	"log"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// returnAck routes a user's ack to the sender it acknowledges
+// Acks are only routed when they name a return relay, which senders set for
+// clients that understand acks arriving as direct messages. The sender's relay
+// gets the ack as a routed message with no hops to spare, so it delivers it
+// or queues it for the sender rather than passing it on.
+func (rs *RelayServer) returnAck(peer *Peer, header *protocol.Header, payload []byte) {
+	var ack protocol.AckMessage
+	if err := protocol.DecodePayload(payload, header.Flags, &ack); err != nil {
+		return // recordDeliveryProof already logged it
+	}
+	if ack.From != peer.Address || protocol.IsZeroAddress(ack.ReturnRelay) || protocol.IsZeroAddress(ack.To) {
+		return
+	}
+
+	tagged := protocol.TagPayload(protocol.MsgTypeAck, ack.Encode())
+
+	rs.mu.RLock()
+	sender, senderHere := rs.peers[string(ack.To[:])]
+	returnRelay, relayConnected := rs.peers[string(ack.ReturnRelay[:])]
+	rs.mu.RUnlock()
+
+	switch {
+	case ack.ReturnRelay == rs.Address || (senderHere && sender.ClientType == protocol.ClientTypeUser):
+		if err := rs.deliverMessage(ack.To, tagged, &peer.Address, nil); err != nil {
+			log.Printf("Failed to return ack to %x: %v", ack.To[:8], err)
+		}
+
+	case relayConnected && returnRelay.ClientType == protocol.ClientTypeRelay:
+		msg := &protocol.RoutedMessage{Recipient: ack.To, HopLimit: 0, Payload: tagged}
+		if err := rs.sendToRelay(returnRelay, protocol.MsgTypeRoutedMessage, msg.Encode()); err != nil {
+			log.Printf("Failed to return ack for %x via relay %x: %v", ack.To[:8], ack.ReturnRelay[:8], err)
+			return
+		}
+		log.Printf("↩️  Returned ack for %x via relay %x", ack.To[:8], ack.ReturnRelay[:8])
+
+	default:
+		// The mesh may still know where the sender is
+		if !rs.routeMessage(ack.To, tagged, nil) {
+			log.Printf("No route to return ack for %x: relay %x not connected", ack.To[:8], ack.ReturnRelay[:8])
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package network
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// readFrame reads one frame the relay wrote to conn
+func readFrame(t *testing.T, conn net.Conn) (*protocol.Header, []byte) {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	header, err := protocol.ReadHeader(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := make([]byte, header.Length)
+	if _, err := io.ReadFull(conn, body); err != nil {
+		t.Fatal(err)
+	}
+	return header, body
+}
+
+// untagAck checks payload is a tagged ACK and decodes it
+func untagAck(t *testing.T, payload []byte) *protocol.AckMessage {
+	t.Helper()
+
+	msgType, body, ok := protocol.UntagPayload(payload)
+	if !ok || msgType != protocol.MsgTypeAck {
+		t.Fatalf("payload is not a tagged ACK (type 0x%04x, tagged %v)", msgType, ok)
+	}
+	var ack protocol.AckMessage
+	if err := ack.Decode(body); err != nil {
+		t.Fatal(err)
+	}
+	return &ack
+}
+
+func newAckReturnRelay(t *testing.T) *RelayServer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := NewRelayServer(0, key)
+	rs.Address = protocol.Address{0xB0}
+	return rs
+}
+
+func ackHeader(payload []byte) *protocol.Header {
+	return &protocol.Header{Type: protocol.MsgTypeAck, Length: uint32(len(payload)), MessageID: protocol.GenerateMessageID()}
+}
+
+func TestReturnAckViaSenderRelay(t *testing.T) {
+	rs := newAckReturnRelay(t)
+	recipient, recipientConn := connectQueuePeer(rs, protocol.Address{0x0B})
+	defer recipientConn.Close()
+
+	relaySide, senderRelayConn := net.Pipe()
+	defer senderRelayConn.Close()
+	senderRelay := &Peer{Conn: relaySide, Address: protocol.Address{0xA0}, ClientType: protocol.ClientTypeRelay, Limits: rs.GetPayloadLimits()}
+	rs.registerPeer(senderRelay)
+
+	ack := &protocol.AckMessage{From: recipient.Address, To: protocol.Address{0x0A}, SequenceNumber: 3, ReturnRelay: senderRelay.Address}
+	payload := ack.Encode()
+	go rs.returnAck(recipient, ackHeader(payload), payload)
+
+	header, body := readFrame(t, senderRelayConn)
+	if header.Type != protocol.MsgTypeRoutedMessage {
+		t.Fatalf("sender's relay got type 0x%04x, want a routed message", header.Type)
+	}
+	var routed protocol.RoutedMessage
+	if err := routed.Decode(body); err != nil {
+		t.Fatal(err)
+	}
+	if routed.Recipient != ack.To || routed.HopLimit != 0 {
+		t.Errorf("routed to %x with %d hops left, want %x with none", routed.Recipient[:8], routed.HopLimit, ack.To[:8])
+	}
+	if got := untagAck(t, routed.Payload); !reflect.DeepEqual(got, ack) {
+		t.Errorf("returned ACK = %+v, want %+v", got, ack)
+	}
+}
+
+func TestReturnAckToLocalSender(t *testing.T) {
+	rs := newAckReturnRelay(t)
+	recipient, recipientConn := connectQueuePeer(rs, protocol.Address{0x0B})
+	defer recipientConn.Close()
+	sender, senderConn := connectQueuePeer(rs, protocol.Address{0x0A})
+	defer senderConn.Close()
+
+	ack := &protocol.AckMessage{From: recipient.Address, To: sender.Address, SequenceNumber: 5, ReturnRelay: rs.Address}
+	payload := ack.Encode()
+	go rs.returnAck(recipient, ackHeader(payload), payload)
+
+	header, body := readFrame(t, senderConn)
+	if header.Type != protocol.MsgTypeDirectMessage {
+		t.Fatalf("sender got type 0x%04x, want a direct message", header.Type)
+	}
+	if got := untagAck(t, body); got.SequenceNumber != ack.SequenceNumber {
+		t.Errorf("returned ACK for seq %d, want %d", got.SequenceNumber, ack.SequenceNumber)
+	}
+}
+
+func TestReturnAckIgnoresForgedSender(t *testing.T) {
+	rs := newAckReturnRelay(t)
+	recipient, recipientConn := connectQueuePeer(rs, protocol.Address{0x0B})
+	defer recipientConn.Close()
+	sender, senderConn := connectQueuePeer(rs, protocol.Address{0x0A})
+	defer senderConn.Close()
+
+	// An ACK claiming another user's identity is not routed anywhere
+	forged := &protocol.AckMessage{From: protocol.Address{0x0C}, To: sender.Address, ReturnRelay: rs.Address}
+	payload := forged.Encode()
+	go rs.returnAck(recipient, ackHeader(payload), payload)
+
+	senderConn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, err := protocol.ReadHeader(senderConn); err == nil {
+		t.Error("forged ACK reached the sender")
+	}
+}
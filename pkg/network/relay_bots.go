@@ -0,0 +1,230 @@
+package network
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// DefaultBotMessagesPerMinute is the send rate of bots registered without one
+const DefaultBotMessagesPerMinute = 30
+
+// BotProofMaxAge is how far a bot's handshake timestamp (Unix seconds) may be
+// from our clock. Nonces of accepted proofs are remembered this long past the
+// timestamp, after which the proof is stale anyway.
+const BotProofMaxAge = 5 * time.Minute
+
+// BotConfig registers a bot account with this relay
+// The API key comes from the bot's operator (crypto.DeriveBotAPIKey). Once
+// registered, the address can only connect with a valid proof of the key.
+type BotConfig struct {
+	Address           protocol.Address
+	APIKey            []byte
+	MessagesPerMinute int // 0 = DefaultBotMessagesPerMinute
+}
+
+// bot is a registered bot and its rate-limit window
+type bot struct {
+	config      BotConfig
+	windowStart time.Time
+	windowCount int
+}
+
+// botRegistry holds the bots registered with the relay
+type botRegistry struct {
+	bots   map[protocol.Address]*bot
+	nonces map[[protocol.BotNonceSize]byte]time.Time // Nonce of an accepted proof -> when it goes stale
+	mu     sync.Mutex
+}
+
+// RegisterBot adds or replaces a bot account
+func (rs *RelayServer) RegisterBot(config BotConfig) error {
+	if len(config.APIKey) == 0 {
+		return fmt.Errorf("bot %x has no API key", config.Address[:8])
+	}
+	if config.MessagesPerMinute <= 0 {
+		config.MessagesPerMinute = DefaultBotMessagesPerMinute
+	}
+
+	rs.mu.Lock()
+	if rs.bots == nil {
+		rs.bots = &botRegistry{
+			bots:   make(map[protocol.Address]*bot),
+			nonces: make(map[[protocol.BotNonceSize]byte]time.Time),
+		}
+	}
+	registry := rs.bots
+	rs.mu.Unlock()
+
+	registry.mu.Lock()
+	registry.bots[config.Address] = &bot{config: config}
+	registry.mu.Unlock()
+
+	log.Printf("🤖 Bot %x registered (rate: %d/min)", config.Address[:8], config.MessagesPerMinute)
+	return nil
+}
+
+// UnregisterBot removes a bot account and disconnects it
+func (rs *RelayServer) UnregisterBot(addr protocol.Address) {
+	registry := rs.getBots()
+	if registry == nil {
+		return
+	}
+
+	registry.mu.Lock()
+	delete(registry.bots, addr)
+	registry.mu.Unlock()
+
+	rs.mu.RLock()
+	var conns []net.Conn
+	for _, peer := range rs.peers {
+		if peer.Bot && peer.Address == addr {
+			conns = append(conns, peer.Conn)
+		}
+	}
+	rs.mu.RUnlock()
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+	log.Printf("🤖 Bot %x unregistered", addr[:8])
+}
+
+// getBots returns the bot registry (nil = no bots registered)
+func (rs *RelayServer) getBots() *botRegistry {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.bots
+}
+
+// authenticate checks a handshake against the registered bots
+// Returns whether the peer is a bot; addresses registered as bots must prove
+// the API key, and proofs from unregistered addresses are refused.
+func (r *botRegistry) authenticate(hs *protocol.HandshakeMessage, now time.Time) (bool, *protocol.RelayErrorMessage) {
+	var b *bot
+	if r != nil {
+		r.mu.Lock()
+		b = r.bots[hs.Address]
+		r.mu.Unlock()
+	}
+
+	if b == nil {
+		if len(hs.BotProof) > 0 {
+			return false, protocol.NewRelayError(protocol.RelayErrBotUnauthorized, "bot is not registered with this relay")
+		}
+		return false, nil
+	}
+
+	if len(hs.BotProof) == 0 {
+		return false, protocol.NewRelayError(protocol.RelayErrBotUnauthorized, "bot connected without an API key proof")
+	}
+	signedAt := time.Unix(int64(hs.Timestamp), 0)
+	skew := now.Sub(signedAt)
+	if skew > BotProofMaxAge || skew < -BotProofMaxAge {
+		return false, protocol.NewRelayError(protocol.RelayErrBotUnauthorized, "bot proof is stale")
+	}
+	if !protocol.VerifyBotProof(b.config.APIKey, hs.Address, hs.Timestamp, hs.BotProof) {
+		return false, protocol.NewRelayError(protocol.RelayErrBotUnauthorized, "bot API key proof is invalid")
+	}
+	if !r.useNonce(protocol.BotProofNonce(hs.BotProof), signedAt.Add(BotProofMaxAge), now) {
+		return false, protocol.NewRelayError(protocol.RelayErrBotUnauthorized, "bot proof was already used")
+	}
+	return true, nil
+}
+
+// useNonce records a proof's nonce until expires, returning false if it was already used
+func (r *botRegistry) useNonce(nonce [protocol.BotNonceSize]byte, expires, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for seen, stale := range r.nonces {
+		if now.After(stale) {
+			delete(r.nonces, seen)
+		}
+	}
+	if _, used := r.nonces[nonce]; used {
+		return false
+	}
+	r.nonces[nonce] = expires
+	return true
+}
+
+// registered returns true if the address is still a registered bot
+func (r *botRegistry) registered(addr protocol.Address) bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.bots[addr] != nil
+}
+
+// allowSend counts a message from a bot against its rate limit
+func (r *botRegistry) allowSend(addr protocol.Address, now time.Time) *protocol.RelayErrorMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b := r.bots[addr]
+	if b == nil {
+		return protocol.NewRelayError(protocol.RelayErrBotUnauthorized, "bot is not registered with this relay")
+	}
+
+	if now.Sub(b.windowStart) >= time.Minute {
+		b.windowStart = now
+		b.windowCount = 0
+	}
+	if b.windowCount >= b.config.MessagesPerMinute {
+		relayErr := protocol.NewRelayError(protocol.RelayErrRateLimited, "bot limit of %d messages per minute reached", b.config.MessagesPerMinute)
+		relayErr.RetryAfter = b.windowStart.Add(time.Minute).Sub(now)
+		return relayErr
+	}
+
+	b.windowCount++
+	return nil
+}
+
+// botFileEntry is one bot in a bots file
+type botFileEntry struct {
+	Address           string `json:"address"` // Hex address
+	APIKey            string `json:"api_key"` // Hex key from the admin bot-key command
+	MessagesPerMinute int    `json:"messages_per_minute"`
+}
+
+// LoadBotConfigs reads bots from a JSON file
+// Format: [{"address": "<hex address>", "api_key": "<hex key>", "messages_per_minute": 30}, ...]
+func LoadBotConfigs(path string) ([]BotConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bots file: %w", err)
+	}
+
+	var entries []botFileEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse bots file: %w", err)
+	}
+
+	configs := make([]BotConfig, 0, len(entries))
+	for _, entry := range entries {
+		addrBytes, err := hex.DecodeString(entry.Address)
+		if err != nil || len(addrBytes) != len(protocol.Address{}) {
+			return nil, fmt.Errorf("invalid bot address %q", entry.Address)
+		}
+		apiKey, err := hex.DecodeString(entry.APIKey)
+		if err != nil || len(apiKey) == 0 {
+			return nil, fmt.Errorf("bot %s: invalid API key", entry.Address)
+		}
+
+		config := BotConfig{APIKey: apiKey, MessagesPerMinute: entry.MessagesPerMinute}
+		copy(config.Address[:], addrBytes)
+		configs = append(configs, config)
+	}
+
+	return configs, nil
+}
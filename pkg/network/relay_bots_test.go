@@ -0,0 +1,60 @@
+package network
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+func TestBotAuthenticate(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := NewRelayServer(0, key)
+	apiKey := []byte("0123456789abcdef0123456789abcdef")
+	botAddr := protocol.Address{0xb0}
+	if err := rs.RegisterBot(BotConfig{Address: botAddr, APIKey: apiKey}); err != nil {
+		t.Fatal(err)
+	}
+	registry := rs.getBots()
+
+	now := time.Now()
+	handshake := func(addr protocol.Address, signedAt time.Time) *protocol.HandshakeMessage {
+		hs := &protocol.HandshakeMessage{Address: addr, Timestamp: uint64(signedAt.Unix())}
+		hs.BotProof = protocol.BotProof(apiKey, addr, hs.Timestamp)
+		return hs
+	}
+
+	hs := handshake(botAddr, now)
+	if isBot, relayErr := registry.authenticate(hs, now); !isBot || relayErr != nil {
+		t.Fatalf("authenticate() = %v, %v; want a bot", isBot, relayErr)
+	}
+
+	// The same proof is refused for as long as its timestamp would be accepted
+	if _, relayErr := registry.authenticate(hs, now.Add(BotProofMaxAge-time.Second)); relayErr == nil {
+		t.Fatal("authenticate() accepted a replayed proof")
+	}
+	if isBot, relayErr := registry.authenticate(handshake(botAddr, now), now); !isBot || relayErr != nil {
+		t.Fatalf("authenticate() with a new nonce = %v, %v; want a bot", isBot, relayErr)
+	}
+
+	if _, relayErr := registry.authenticate(handshake(botAddr, now.Add(-BotProofMaxAge-time.Second)), now); relayErr == nil {
+		t.Fatal("authenticate() accepted a stale proof")
+	}
+	if _, relayErr := registry.authenticate(&protocol.HandshakeMessage{Address: botAddr}, now); relayErr == nil {
+		t.Fatal("authenticate() accepted a bot without a proof")
+	}
+	if _, relayErr := registry.authenticate(handshake(protocol.Address{0xcc}, now), now); relayErr == nil {
+		t.Fatal("authenticate() accepted a proof from an unregistered address")
+	}
+
+	// Nonces are forgotten once their proofs are stale
+	registry.authenticate(handshake(botAddr, now.Add(2*BotProofMaxAge)), now.Add(2*BotProofMaxAge))
+	if got := len(registry.nonces); got != 1 {
+		t.Fatalf("registry remembers %d nonces, want 1", got)
+	}
+}
@@ -0,0 +1,265 @@
+package network
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+const (
+	// DefaultClusterReplicas is the number of virtual nodes per cluster member on the hash ring
+	DefaultClusterReplicas = 128
+
+	// DefaultClusterPollInterval is how often a node checks the shared queue for its connected users
+	DefaultClusterPollInterval = 2 * time.Second
+)
+
+// ClusterNode is one relay process in a cluster
+type ClusterNode struct {
+	ID       string // Stable node identifier
+	Endpoint string // Direct network address (host:port), bypassing the load balancer
+}
+
+// ParseClusterNodes parses a comma-separated list of id=host:port entries
+func ParseClusterNodes(s string) ([]ClusterNode, error) {
+	var nodes []ClusterNode
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, endpoint, ok := strings.Cut(entry, "=")
+		if !ok || id == "" || endpoint == "" {
+			return nil, fmt.Errorf("invalid cluster node %q (expected id=host:port)", entry)
+		}
+		if _, _, err := net.SplitHostPort(endpoint); err != nil {
+			return nil, fmt.Errorf("invalid cluster node endpoint %q: %w", endpoint, err)
+		}
+
+		nodes = append(nodes, ClusterNode{ID: id, Endpoint: endpoint})
+	}
+	return nodes, nil
+}
+
+// HashRing assigns recipients to cluster nodes with consistent hashing
+// Adding or removing a node only reassigns the recipients adjacent to it on the ring
+type HashRing struct {
+	points []uint64
+	owners map[uint64]*ClusterNode
+	nodes  []ClusterNode
+}
+
+// NewHashRing builds a ring with replicas virtual points per node
+func NewHashRing(nodes []ClusterNode, replicas int) (*HashRing, error) {
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("hash ring needs at least one node")
+	}
+	if replicas <= 0 {
+		replicas = DefaultClusterReplicas
+	}
+
+	ring := &HashRing{
+		owners: make(map[uint64]*ClusterNode),
+		nodes:  make([]ClusterNode, len(nodes)),
+	}
+	copy(ring.nodes, nodes)
+
+	seen := make(map[string]bool)
+	for i := range ring.nodes {
+		node := &ring.nodes[i]
+		if seen[node.ID] {
+			return nil, fmt.Errorf("duplicate cluster node ID: %s", node.ID)
+		}
+		seen[node.ID] = true
+
+		for r := 0; r < replicas; r++ {
+			point := ringHash([]byte(fmt.Sprintf("%s#%d", node.ID, r)))
+			if _, taken := ring.owners[point]; taken {
+				continue // Astronomically unlikely; skip rather than steal the point
+			}
+			ring.owners[point] = node
+			ring.points = append(ring.points, point)
+		}
+	}
+
+	sort.Slice(ring.points, func(i, j int) bool { return ring.points[i] < ring.points[j] })
+	return ring, nil
+}
+
+// Owner returns the node responsible for a recipient
+func (h *HashRing) Owner(addr protocol.Address) *ClusterNode {
+	point := ringHash(addr[:])
+	idx := sort.Search(len(h.points), func(i int) bool { return h.points[i] >= point })
+	if idx == len(h.points) {
+		idx = 0 // Wrap around
+	}
+	return h.owners[h.points[idx]]
+}
+
+// Nodes returns the ring's members
+func (h *HashRing) Nodes() []ClusterNode {
+	return h.nodes
+}
+
+// ringHash maps data onto the ring
+func ringHash(data []byte) uint64 {
+	sum := sha256.Sum256(data)
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+// ClusterConfig configures a relay process as a member of a cluster
+// All members share the relay's private key and a PostgreSQL message queue,
+// so they behave as one logical relay behind a load balancer
+type ClusterConfig struct {
+	NodeID       string
+	Nodes        []ClusterNode
+	Replicas     int
+	PollInterval time.Duration
+}
+
+// relayCluster is the running cluster state of a relay
+type relayCluster struct {
+	self *ClusterNode
+	ring *HashRing
+	stop chan struct{}
+}
+
+// EnableCluster joins the relay to a cluster
+// A shared message queue must be attached first, otherwise messages queued
+// on one node are invisible to the others
+func (rs *RelayServer) EnableCluster(config ClusterConfig) error {
+	if rs.messageQueue == nil {
+		return fmt.Errorf("cluster mode requires a shared message queue")
+	}
+
+	ring, err := NewHashRing(config.Nodes, config.Replicas)
+	if err != nil {
+		return err
+	}
+
+	var self *ClusterNode
+	for i := range ring.nodes {
+		if ring.nodes[i].ID == config.NodeID {
+			self = &ring.nodes[i]
+		}
+	}
+	if self == nil {
+		return fmt.Errorf("node %q is not in the cluster node list", config.NodeID)
+	}
+
+	interval := config.PollInterval
+	if interval <= 0 {
+		interval = DefaultClusterPollInterval
+	}
+
+	cluster := &relayCluster{
+		self: self,
+		ring: ring,
+		stop: make(chan struct{}),
+	}
+
+	rs.mu.Lock()
+	if rs.cluster != nil {
+		rs.mu.Unlock()
+		return fmt.Errorf("cluster mode already enabled")
+	}
+	rs.cluster = cluster
+	rs.mu.Unlock()
+
+	go rs.clusterPollLoop(cluster, interval)
+
+	log.Printf("🔗 Cluster mode enabled: node %s (%d nodes)", self.ID, len(ring.nodes))
+	return nil
+}
+
+// DisableCluster stops cluster polling
+func (rs *RelayServer) DisableCluster() {
+	rs.mu.Lock()
+	cluster := rs.cluster
+	rs.cluster = nil
+	rs.mu.Unlock()
+
+	if cluster != nil {
+		close(cluster.stop)
+	}
+}
+
+// ClusterOwner returns the cluster node responsible for a recipient (nil if not clustered)
+func (rs *RelayServer) ClusterOwner(addr protocol.Address) *ClusterNode {
+	rs.mu.RLock()
+	cluster := rs.cluster
+	rs.mu.RUnlock()
+
+	if cluster == nil {
+		return nil
+	}
+	return cluster.ring.Owner(addr)
+}
+
+// redirectToOwner points a user at the cluster node that owns them
+// The connection is still served, so a client that ignores the hint keeps working
+func (rs *RelayServer) redirectToOwner(conn net.Conn, addr protocol.Address) {
+	rs.mu.RLock()
+	cluster := rs.cluster
+	rs.mu.RUnlock()
+
+	if cluster == nil {
+		return
+	}
+
+	owner := cluster.ring.Owner(addr)
+	if owner == nil || owner.ID == cluster.self.ID {
+		return
+	}
+
+	notice := &protocol.RelayMovedNotice{
+		NewRelay:    rs.Address,
+		NewEndpoint: owner.Endpoint,
+	}
+	if err := rs.sendRelayMoved(conn, addr, notice); err != nil {
+		log.Printf("Failed to redirect %x to cluster node %s: %v", addr[:8], owner.ID, err)
+	}
+}
+
+// clusterPollLoop delivers messages that other nodes queued for users connected here
+func (rs *RelayServer) clusterPollLoop(cluster *relayCluster, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-cluster.stop:
+			return
+		case <-ticker.C:
+			for _, addr := range rs.connectedUsers() {
+				count, err := rs.messageQueue.GetQueuedMessageCount(addr)
+				if err != nil || count == 0 {
+					continue
+				}
+				go rs.deliverQueuedMessages(addr) // Returns at once if a flush is already running
+			}
+		}
+	}
+}
+
+// connectedUsers returns the addresses of locally connected user clients
+func (rs *RelayServer) connectedUsers() []protocol.Address {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	users := make([]protocol.Address, 0, len(rs.peers))
+	for _, peer := range rs.peers {
+		if peer.ClientType == protocol.ClientTypeUser {
+			users = append(users, peer.Address)
+		}
+	}
+	return users
+}
@@ -5,64 +5,219 @@ import (
 	"log"
 	"net"
 
+	"github.com/ZentaChain/zentalk-node/pkg/chaos"
 	"github.com/ZentaChain/zentalk-node/pkg/protocol"
 )
 
-// acceptLoop accepts incoming connections
-func (rs *RelayServer) acceptLoop() {
+// acceptLoop accepts incoming connections on one listener
+func (rs *RelayServer) acceptLoop(listener net.Listener) {
 	for {
-		conn, err := rs.listener.Accept()
+		conn, err := listener.Accept()
 		if err != nil {
 			log.Printf("Accept error: %v", err)
 			return
 		}
 
-		go rs.handleConnection(conn)
+		// Connections stay half-open until they handshake; cap them so idle
+		// sockets can't exhaust file descriptors
+		if !rs.acquireHalfOpen(rs.GetConnectionLimits().MaxHalfOpen) {
+			log.Printf("🚫 Too many half-open connections, dropping %s", conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+
+		go rs.handleConnection(conn, nil)
 	}
 }
 
 // handleConnection handles a peer connection
-func (rs *RelayServer) handleConnection(conn net.Conn) {
+// peer is the relay we dialed and handshook with, or nil for accepted connections,
+// which hold a half-open slot until their handshake completes
+func (rs *RelayServer) handleConnection(conn net.Conn, peer *Peer) {
 	defer conn.Close()
 
 	log.Printf("New connection from %s", conn.RemoteAddr())
 
-	var peerAddr protocol.Address
+	var registered *Peer
+
+	// Relays we dialed are peers; only accepted connections are rate limited
+	var rate *forwardRate
+	if peer == nil {
+		rate = rs.newForwardRate(conn)
+	}
+
+	// Accepted connections must send each header promptly until they handshake
+	connLimits := rs.GetConnectionLimits()
+	limits := rs.GetPayloadLimits()
+	wait := connLimits.HeaderTimeout
+	halfOpen := peer == nil
+	if peer != nil {
+		limits = peer.Limits
+		wait = 0
+	}
+	defer func() {
+		if halfOpen {
+			rs.releaseHalfOpen()
+		}
+	}()
 
 	// Cleanup peer on disconnect
 	defer func() {
-		if peerAddr != (protocol.Address{}) {
-			rs.mu.Lock()
-			delete(rs.peers, string(peerAddr[:]))
-			rs.mu.Unlock()
-			log.Printf("Peer disconnected and removed: %x", peerAddr[:8])
+		if registered != nil {
+			rs.unregisterPeer(registered)
+			log.Printf("Peer disconnected and removed: %x", registered.Address[:8])
 		}
 	}()
 
 	// Loop to handle multiple messages on same connection
 	for {
 		// Read and validate header
-		header, err := protocol.ReadHeader(conn)
+		header, err := connLimits.readHeader(conn, wait)
 		if err != nil {
-			if err != io.EOF {
+			if err == protocol.ErrInvalidVersion {
+				rs.sendRelayError(conn, protocol.MessageID{}, protocol.NewRelayError(protocol.RelayErrVersionUnsupported,
+					"relay speaks protocol version 0x%04x", protocol.ProtocolVersion))
+			}
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				log.Printf("⏱️  Header read from %s timed out, closing connection", conn.RemoteAddr())
+			} else if err != io.EOF {
 				log.Printf("Header error: %v", err)
 			}
 			return
 		}
 
+		// The payload cannot be skipped safely, so an oversized frame ends the connection
+		if err := limits.Check(header); err != nil {
+			log.Printf("🚫 %v, closing connection", err)
+			rs.sendRelayError(conn, header.MessageID, protocol.NewRelayError(protocol.RelayErrPayloadTooLarge,
+				"payload limit for type 0x%04x is %d bytes", header.Type, limits.Limit(header.Type)))
+			return
+		}
+
+		// Handlers read the payload; a peer trickling it in times out
+		connLimits.payloadDeadline(conn, header.Length)
+
+		// Staging builds may cut the connection to exercise client reconnects
+		if chaos.Disconnect("relay.conn") {
+			return
+		}
+
+		// Types and sizes the relay's policy refuses from users
+		if rs.refuseByPolicy(conn, header, registered) {
+			continue
+		}
+
 		// Handle message based on type
 		switch header.Type {
-		case protocol.MsgTypeHandshake:
-			peerAddr = rs.handleHandshake(conn, header)
+		case protocol.MsgTypeStartTLS:
+			secured, err := rs.handleStartTLS(conn, header, registered != nil || peer != nil)
+			if err != nil {
+				log.Printf("StartTLS error: %v", err)
+				return
+			}
+			if secured != nil {
+				conn = secured
+			}
+
+		case protocol.MsgTypeHandshake, protocol.MsgTypeResume:
+			handle := rs.handleHandshake
+			if header.Type == protocol.MsgTypeResume {
+				handle = rs.handleResume
+			}
+			if peer := handle(conn, header); peer != nil {
+				registered = peer
+				limits = peer.Limits
+				conn = peer.Conn // Wrapped if the link switched to uniform records
+				if halfOpen {
+					rs.releaseHalfOpen()
+					halfOpen = false
+				}
+
+				// Users ping regularly; relay peers may be quiet for long stretches
+				wait = 0
+				if peer.ClientType == protocol.ClientTypeUser {
+					wait = connLimits.IdleTimeout
+				}
+			}
 
 		case protocol.MsgTypeRelayForward:
-			rs.handleRelayForward(conn, header)
+			// Refused before the onion layer is decrypted, which is the costly part
+			if relayErr := rate.allowForward(registered); relayErr != nil {
+				if _, err := io.CopyN(io.Discard, conn, int64(header.Length)); err != nil {
+					return
+				}
+				rs.sendRelayError(conn, header.MessageID, relayErr)
+				continue
+			}
+			rs.handleRelayForward(conn, header, registered)
+
+		case protocol.MsgTypeRouteUpdate, protocol.MsgTypeRoutedMessage:
+			from := registered
+			if from == nil {
+				from = peer
+			}
+			handle := rs.handleRouteUpdate
+			if header.Type == protocol.MsgTypeRoutedMessage {
+				handle = rs.handleRoutedMessage
+			}
+			if err := handle(conn, header, from); err != nil {
+				log.Printf("Read route payload error: %v", err)
+				return
+			}
 
 		case protocol.MsgTypePing:
 			rs.handlePing(conn, header)
 
+		case protocol.MsgTypeQueueWindow:
+			if err := rs.handleQueueWindow(conn, header, registered); err != nil {
+				log.Printf("Read queue window error: %v", err)
+				return
+			}
+
+		case protocol.MsgTypeOfflineSync:
+			if err := rs.handleOfflineSync(conn, header, registered); err != nil {
+				log.Printf("Offline sync error: %v", err)
+				return
+			}
+
+		case protocol.MsgTypeKeyBundlePublish, protocol.MsgTypeKeyBundleRequest:
+			handle := rs.handleKeyBundlePublish
+			if header.Type == protocol.MsgTypeKeyBundleRequest {
+				handle = rs.handleKeyBundleRequest
+			}
+			if err := handle(conn, header, registered); err != nil {
+				log.Printf("Key bundle error: %v", err)
+				return
+			}
+
+		case protocol.MsgTypeMediaDirectoryRequest:
+			if err := rs.handleMediaDirectoryRequest(conn, header); err != nil {
+				log.Printf("Media directory error: %v", err)
+				return
+			}
+
+		case protocol.MsgTypeProbe:
+			if err := rs.handleProbe(conn, header); err != nil {
+				log.Printf("Probe error: %v", err)
+				return
+			}
+
+		case protocol.MsgTypeAck, protocol.MsgTypeRelayAck, protocol.MsgTypeRelayError,
+			protocol.MsgTypePong, protocol.MsgTypeHandshakeAck, protocol.MsgTypeProbeAck:
+			// Replies from a relay we forwarded to. Never answered: two relays
+			// replying to each other's errors would loop forever.
+			if err := rs.handleReply(conn, header, registered); err != nil {
+				log.Printf("Read reply error: %v", err)
+				return
+			}
+
 		default:
 			log.Printf("Unknown message type: 0x%04x", header.Type)
+			if _, err := io.CopyN(io.Discard, conn, int64(header.Length)); err != nil {
+				return
+			}
+			rs.sendRelayError(conn, header.MessageID, protocol.NewRelayError(protocol.RelayErrUnsupportedType,
+				"relays do not handle message type 0x%04x", header.Type))
 		}
 	}
 }
@@ -1,7 +1,6 @@
 package network
 
 import (
-	"fmt"
 	"log"
 	"time"
 
@@ -9,6 +8,7 @@ import (
 )
 
 // forwardToNextHop forwards message to next relay
+// Failures are *protocol.RelayErrorMessage for the previous hop
 func (rs *RelayServer) forwardToNextHop(nextHop protocol.Address, payload []byte) error {
 	// Find peer connection
 	rs.mu.RLock()
@@ -17,37 +17,65 @@ func (rs *RelayServer) forwardToNextHop(nextHop protocol.Address, payload []byte
 
 	if !exists {
 		log.Printf("Next hop relay not connected: %x", nextHop)
-		return fmt.Errorf("peer not connected: %x", nextHop)
+		return protocol.NewRelayError(protocol.RelayErrNextHopUnreachable, "next hop relay %x not connected", nextHop[:8])
 	}
 
 	log.Printf("Forwarding to next hop relay %x", nextHop)
 
-	// Create relay forward message
+	if err := rs.sendToRelay(peer, protocol.MsgTypeRelayForward, payload); err != nil {
+		return err
+	}
+	log.Printf("✅ Forwarded to relay %x", nextHop)
+	return nil
+}
+
+// sendToRelay writes one frame to a peer relay, within its limits and the link shaping
+// Failures are *protocol.RelayErrorMessage for the previous hop
+func (rs *RelayServer) sendToRelay(peer *Peer, msgType uint16, payload []byte) error {
 	header := &protocol.Header{
 		Magic:     protocol.ProtocolMagic,
 		Version:   protocol.ProtocolVersion,
-		Type:      protocol.MsgTypeRelayForward,
+		Type:      msgType,
 		Length:    uint32(len(payload)),
 		Flags:     0,
 		MessageID: protocol.GenerateMessageID(),
 	}
+	if relayErr := checkPeerLimits(peer, header); relayErr != nil {
+		return relayErr
+	}
+
+	// Relay links are shaped so forwarding can't crowd out delivery to users
+	if relayErr := rs.shapeForward(peer, protocol.HeaderSize+len(payload)); relayErr != nil {
+		return relayErr
+	}
+	if timeout := rs.meshWriteTimeout(); timeout > 0 {
+		peer.Conn.SetWriteDeadline(time.Now().Add(timeout))
+		defer peer.Conn.SetWriteDeadline(time.Time{})
+	}
 
 	// Send to peer
 	if err := protocol.WriteHeader(peer.Conn, header); err != nil {
-		return err
+		return protocol.NewRelayError(protocol.RelayErrNextHopUnreachable, "write to next hop failed: %v", err)
 	}
 
-	_, err := peer.Conn.Write(payload)
-	if err == nil {
-		log.Printf("✅ Forwarded to relay %x", nextHop)
+	if _, err := peer.Conn.Write(payload); err != nil {
+		return protocol.NewRelayError(protocol.RelayErrNextHopUnreachable, "write to next hop failed: %v", err)
 	}
-	return err
+	return nil
 }
 
 // deliverMessage delivers final message to recipient
-func (rs *RelayServer) deliverMessage(recipientAddr protocol.Address, encryptedPayload []byte) error {
+// Recipients connected to another relay in the mesh get it routed there, and
+// offline recipients get the message queued. sender is the user who handed us
+// the message (nil if it came from another relay); hop is set for messages
+// routed to us by a peer relay. Failures are *protocol.RelayErrorMessage.
+func (rs *RelayServer) deliverMessage(recipientAddr protocol.Address, encryptedPayload []byte, sender *protocol.Address, hop *routedHop) error {
 	log.Printf("Delivering message to %x", recipientAddr)
 
+	if relayErr := rs.checkDeliveryPolicy(len(encryptedPayload), false); relayErr != nil {
+		return relayErr
+	}
+
 	// Find recipient peer
 	rs.mu.RLock()
 	peer, exists := rs.peers[string(recipientAddr[:])]
@@ -56,18 +84,62 @@ func (rs *RelayServer) deliverMessage(recipientAddr protocol.Address, encryptedP
 	if !exists {
 		log.Printf("Recipient not connected: %x", recipientAddr)
 
+		// Another relay may have the recipient connected
+		if rs.routeMessage(recipientAddr, encryptedPayload, hop) {
+			return nil
+		}
+
 		// Queue message if message queue is available
 		if rs.messageQueue != nil {
+			if relayErr := rs.checkDeliveryPolicy(len(encryptedPayload), true); relayErr != nil {
+				return relayErr
+			}
+			if relayErr := rs.allowQueue(recipientAddr, len(encryptedPayload)); relayErr != nil {
+				return relayErr
+			}
+
+			// Stake and vouchers earn a longer TTL and room in a full queue
+			var priority int
+			var ttl time.Duration
+			qp := rs.getQueuePriority()
+			if qp != nil {
+				priority, ttl = qp.priority(sender, recipientAddr, rs.messageQueue.TTL())
+				evict := qp.limitEvictions(sender, recipientAddr, rs.messageQueue.EvictLowerPriority)
+				if relayErr := rs.makeQueueRoom(qp.cfg.Capacity, priority, evict); relayErr != nil {
+					return relayErr
+				}
+			}
+
+			// Each tenant's offline messages are queued and capped separately
+			var tenant string
+			if tenants := rs.getTenants(); tenants != nil {
+				tenant, _ = tenants.tenantOf(recipientAddr)
+				queued, err := rs.messageQueue.GetTenantQueueSize(tenant)
+				if err == nil && qp != nil {
+					evict := qp.limitEvictions(sender, recipientAddr, func(p int) (bool, error) {
+						return rs.messageQueue.EvictTenantLowerPriority(tenant, p)
+					})
+					queued, err = evictForRoom(queued, tenants.queueCapacity(tenant), priority, evict)
+				}
+				if err != nil {
+					log.Printf("Failed to count tenant queue: %v", err)
+					return protocol.NewRelayError(protocol.RelayErrInternal, "recipient offline and queue failed")
+				}
+				if relayErr := tenants.allowQueue(tenant, queued); relayErr != nil {
+					return relayErr
+				}
+			}
+
 			messageID := protocol.GenerateMessageID()
-			if err := rs.messageQueue.QueueMessage(recipientAddr, messageID, encryptedPayload); err != nil {
+			if err := rs.messageQueue.QueuePriorityMessage(tenant, recipientAddr, messageID, encryptedPayload, priority, ttl); err != nil {
 				log.Printf("Failed to queue message: %v", err)
-				return fmt.Errorf("recipient offline and queue failed: %v", err)
+				return protocol.NewRelayError(protocol.RelayErrInternal, "recipient offline and queue failed")
 			}
 			log.Printf("✅ Message queued for offline user %x", recipientAddr[:8])
 			return nil
 		}
 
-		return fmt.Errorf("recipient not connected: %x", recipientAddr)
+		return protocol.NewRelayError(protocol.RelayErrRecipientUnknown, "recipient %x not connected", recipientAddr[:8])
 	}
 
 	// Create header for direct message
@@ -79,16 +151,19 @@ func (rs *RelayServer) deliverMessage(recipientAddr protocol.Address, encryptedP
 		Flags:     protocol.FlagEncrypted,
 		MessageID: protocol.GenerateMessageID(),
 	}
+	if relayErr := checkPeerLimits(peer, header); relayErr != nil {
+		return relayErr
+	}
 
 	// Send to recipient
 	if err := protocol.WriteHeader(peer.Conn, header); err != nil {
 		log.Printf("Write header error: %v", err)
-		return err
+		return protocol.NewRelayError(protocol.RelayErrRecipientUnknown, "recipient connection failed")
 	}
 
 	if _, err := peer.Conn.Write(encryptedPayload); err != nil {
 		log.Printf("Write payload error: %v", err)
-		return err
+		return protocol.NewRelayError(protocol.RelayErrRecipientUnknown, "recipient connection failed")
 	}
 
 	log.Printf("✅ Message delivered to %x", recipientAddr)
@@ -96,8 +171,32 @@ func (rs *RelayServer) deliverMessage(recipientAddr protocol.Address, encryptedP
 }
 
 // deliverQueuedMessages delivers all queued messages to a reconnected user
+// Messages go out oldest first. Users that set FlagQueueFlow get them in
+// windows they grant, with progress reports; others get a paced stream. A
+// flush ends early if the connection drops or is replaced, and whatever it
+// didn't send stays queued for the next connection to pick up. Users that set
+// FlagOfflineSync are skipped; they pull the queue themselves.
 func (rs *RelayServer) deliverQueuedMessages(recipientAddr protocol.Address) {
-	// Get queued messages
+	// Find recipient peer
+	rs.mu.RLock()
+	peer, exists := rs.peers[string(recipientAddr[:])]
+	rs.mu.RUnlock()
+
+	if !exists {
+		log.Printf("Recipient disconnected before queue delivery: %x", recipientAddr[:8])
+		return
+	}
+	if peer.OfflineSync {
+		return
+	}
+
+	flush := rs.beginQueueFlush(peer)
+	if flush == nil {
+		return // Already flushing to this connection
+	}
+	defer rs.endQueueFlush(flush)
+
+	// Read only once any earlier flush has stopped, so nothing is sent twice
 	messages, err := rs.messageQueue.GetQueuedMessages(recipientAddr)
 	if err != nil {
 		log.Printf("Failed to get queued messages: %v", err)
@@ -110,47 +209,83 @@ func (rs *RelayServer) deliverQueuedMessages(recipientAddr protocol.Address) {
 
 	log.Printf("📬 Delivering %d queued messages to %x", len(messages), recipientAddr[:8])
 
-	// Find recipient peer
-	rs.mu.RLock()
-	peer, exists := rs.peers[string(recipientAddr[:])]
-	rs.mu.RUnlock()
-
-	if !exists {
-		log.Printf("Recipient disconnected before queue delivery: %x", recipientAddr[:8])
-		return
-	}
-
 	// Deliver each message
 	successCount := 0
-	for _, msg := range messages {
-		// Create header for direct message
+	window := protocol.DefaultQueueWindow
+	var sent []uint64 // Queue sequences sent, in order
+	acked := 0        // How many of sent the client has confirmed
+	for i, msg := range messages {
+		if flush.stopping() {
+			log.Printf("📬 Queue flush to %x interrupted after %d/%d, rest stays queued", recipientAddr[:8], successCount, len(messages))
+			return
+		}
+
+		// Window used up: report progress and wait for the client to open it again
+		for peer.QueueFlow && len(sent)-acked >= window {
+			grant, ok := rs.awaitQueueWindow(flush, &protocol.QueueProgress{
+				Delivered: uint32(successCount),
+				Remaining: uint32(len(messages) - i),
+				Cursor:    sent[len(sent)-1],
+			})
+			if !ok {
+				return
+			}
+			acked = 0
+			for _, seq := range sent {
+				if seq <= grant.Cursor {
+					acked++
+				}
+			}
+			window = grant.Clamped()
+		}
+
+		// Create header for direct message; the queue sequence lets a resuming client say how far it got
 		header := &protocol.Header{
 			Magic:     protocol.ProtocolMagic,
 			Version:   protocol.ProtocolVersion,
 			Type:      protocol.MsgTypeDirectMessage,
 			Length:    uint32(len(msg.EncryptedPayload)),
-			Flags:     protocol.FlagEncrypted,
-			MessageID: protocol.GenerateMessageID(),
+			Flags:     protocol.FlagEncrypted | protocol.FlagQueued,
+			MessageID: protocol.QueuedMessageID(uint64(msg.ID)),
+		}
+		if relayErr := checkPeerLimits(peer, header); relayErr != nil {
+			// Left in the queue until it expires; the client may negotiate larger limits later
+			log.Printf("Skipping queued message: %v", relayErr)
+			continue
 		}
 
-		// Send to recipient
+		// Send to recipient; a failed write means the connection is gone
 		if err := protocol.WriteHeader(peer.Conn, header); err != nil {
-			log.Printf("Failed to deliver queued message: %v", err)
-			continue
+			log.Printf("Failed to deliver queued message, %d left queued: %v", len(messages)-i, err)
+			return
 		}
 
 		if _, err := peer.Conn.Write(msg.EncryptedPayload); err != nil {
-			log.Printf("Failed to write queued message: %v", err)
-			continue
+			log.Printf("Failed to write queued message, %d left queued: %v", len(messages)-i, err)
+			return
 		}
 
-		// Delete message from queue after successful delivery
+		// Delete message from queue after successful delivery; the session keeps
+		// a copy in case the write went to a socket that is already dead
 		if err := rs.messageQueue.DeleteMessage(msg.MessageID); err != nil {
 			log.Printf("Failed to delete delivered message: %v", err)
 		}
+		if store := rs.getResumption(); store != nil {
+			store.delivered(recipientAddr, uint64(msg.ID), msg.EncryptedPayload)
+		}
 
 		successCount++
-		time.Sleep(50 * time.Millisecond) // Small delay between messages
+		sent = append(sent, uint64(msg.ID))
+		if !peer.QueueFlow {
+			time.Sleep(50 * time.Millisecond) // Small delay between messages
+		}
+	}
+
+	if peer.QueueFlow && len(sent) > 0 {
+		done := &protocol.QueueProgress{Delivered: uint32(successCount), Cursor: sent[len(sent)-1]}
+		if err := rs.sendQueueProgress(peer, done); err != nil {
+			log.Printf("Failed to send queue progress: %v", err)
+		}
 	}
 
 	log.Printf("✅ Delivered %d/%d queued messages to %x", successCount, len(messages), recipientAddr[:8])
@@ -65,8 +65,8 @@ func (rd *RelayDiscovery) PublishRelay(metadata *RelayMetadata) error {
 	// Publish to DHT using relay's address as the key
 	dhtKey := dht.NewNodeID(metadata.Address[:])
 
-	// Use 24-hour TTL for relay metadata
-	if err := rd.dhtNode.Store(dhtKey, data, 24*time.Hour); err != nil {
+	// Publish with the node's record TTL; the DHT node keeps it alive between updates
+	if err := rd.dhtNode.Publish(dhtKey, data); err != nil {
 		return fmt.Errorf("failed to publish to DHT: %w", err)
 	}
 
@@ -116,7 +116,7 @@ func (rd *RelayDiscovery) DiscoverRelays(count int) ([]*RelayMetadata, error) {
 	}
 
 	if len(available) == 0 {
-		return nil, fmt.Errorf("no healthy relays available")
+		return nil, fmt.Errorf("%w: none healthy", ErrNoRelays)
 	}
 
 	// If requesting more than available, return all
@@ -164,7 +164,7 @@ func (rd *RelayDiscovery) DiscoverRelaysByRegion(region string, count int) ([]*R
 	}
 
 	if len(regional) == 0 {
-		return nil, fmt.Errorf("no healthy relays in region %s", region)
+		return nil, fmt.Errorf("%w: none healthy in region %s", ErrNoRelays, region)
 	}
 
 	// If requesting more than available, return all
@@ -244,18 +244,37 @@ func (rd *RelayDiscovery) SelectOptimalCircuit(hopCount int) ([]*crypto.RelayInf
 		usedRegions[relay.Metadata.Region] = true
 	}
 
-	// Convert to RelayInfo
-	circuit := make([]*crypto.RelayInfo, len(selected))
+	// Order hops so middle relays forward and the exit delivers
+	selectedMeta := make([]*RelayMetadata, len(selected))
 	for i, relay := range selected {
+		selectedMeta[i] = relay.Metadata
+	}
+	ordered, err := arrangeForExitPolicy(selectedMeta, hopCount)
+	if err != nil {
+		// Diverse selection can't satisfy exit policies, fall back to best-scored relays
+		allMeta := make([]*RelayMetadata, len(scores))
+		for i, relay := range scores {
+			allMeta[i] = relay.Metadata
+		}
+		ordered, err = arrangeForExitPolicy(allMeta, hopCount)
+		if err != nil {
+			return nil, fmt.Errorf("failed to satisfy exit policies: %w", err)
+		}
+	}
+
+	// Convert to RelayInfo
+	circuit := make([]*crypto.RelayInfo, len(ordered))
+	for i, relay := range ordered {
 		// Parse public key
-		pubKey, err := crypto.ImportPublicKeyPEM([]byte(relay.Metadata.PublicKeyPEM))
+		pubKey, err := crypto.ImportPublicKeyPEM([]byte(relay.PublicKeyPEM))
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse relay public key: %w", err)
 		}
 
 		circuit[i] = &crypto.RelayInfo{
-			Address:   relay.Metadata.Address,
-			PublicKey: pubKey,
+			Address:        relay.Address,
+			PublicKey:      pubKey,
+			PaddingBuckets: relay.paddingBuckets(),
 		}
 	}
 
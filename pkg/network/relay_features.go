@@ -0,0 +1,38 @@
+package network
+
+import (
+	"github.com/ZentaChain/zentalk-node/pkg/chaos"
+	"github.com/ZentaChain/zentalk-node/pkg/features"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// Features returns the relay's feature registry as currently configured
+func (rs *RelayServer) Features() *features.Registry {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	registry := features.NewRegistry()
+	registry.Set(features.MeshRouting, rs.routing != nil)
+	registry.Set(features.MeshShaping, rs.meshShaper != nil)
+	registry.Set(features.UniformRecords, rs.uniformRecords != nil)
+	registry.Set(features.SessionResume, rs.resumption != nil)
+	registry.Set(features.RelayPolicy, rs.relayPolicy != nil)
+	registry.Set(features.MediaDirectory, rs.mediaDirectory != nil)
+	registry.Set(features.OfflineSync, rs.messageQueue != nil)
+	registry.Set(features.Cluster, rs.cluster != nil)
+	registry.Set(features.Tenants, rs.tenants != nil)
+	registry.Set(features.Bots, rs.bots != nil)
+	registry.Set(features.QueuePriority, rs.queuePriority != nil)
+	registry.Set(features.DeliveryProofs, rs.deliveryProofs != nil)
+	registry.Set(features.KeyBundles, rs.keyBundles != nil)
+	registry.Set(features.WebSocket, rs.listenConfig.WebSocketPort != 0)
+	registry.Set(features.TLS, rs.tls != nil)
+	registry.Set(features.Chaos, chaos.Current().Active())
+	return registry
+}
+
+// RelayFeatures returns the features the relay advertised in its handshake ack
+// Empty for relays that predate feature advertisement.
+func (c *Client) RelayFeatures() protocol.FeatureList {
+	return c.relayFeatures
+}
@@ -1,22 +1,24 @@
 package network
 
 import (
+	"errors"
 	"io"
 	"log"
 	"net"
 	"time"
 
+	"github.com/ZentaChain/zentalk-node/pkg/chaos"
 	"github.com/ZentaChain/zentalk-node/pkg/crypto"
 	"github.com/ZentaChain/zentalk-node/pkg/protocol"
 )
 
-// handleHandshake handles connection handshake and returns the peer address
-func (rs *RelayServer) handleHandshake(conn net.Conn, header *protocol.Header) protocol.Address {
+// handleHandshake handles connection handshake and returns the registered peer (nil on failure)
+func (rs *RelayServer) handleHandshake(conn net.Conn, header *protocol.Header) *Peer {
 	// Read payload
 	payload := make([]byte, header.Length)
 	if _, err := io.ReadFull(conn, payload); err != nil {
 		log.Printf("Read payload error: %v", err)
-		return protocol.Address{}
+		return nil
 	}
 
 	// Remove padding if present (traffic analysis resistance)
@@ -31,48 +33,104 @@ func (rs *RelayServer) handleHandshake(conn net.Conn, header *protocol.Header) p
 
 	// Decode handshake
 	var hs protocol.HandshakeMessage
-	if err := hs.Decode(payload); err != nil {
+	if err := protocol.DecodePayload(payload, header.Flags, &hs); err != nil {
 		log.Printf("Decode handshake error: %v", err)
-		return protocol.Address{}
+		return nil
 	}
 
 	log.Printf("Handshake from %x, type=%d", hs.Address, hs.ClientType)
 
+	if hs.ClientType == protocol.ClientTypeUser && rs.requiresTLS(conn) {
+		log.Printf("🚫 Refused plain TCP handshake from %x: TLS required", hs.Address[:8])
+		rs.sendRelayError(conn, header.MessageID, protocol.NewRelayError(protocol.RelayErrTLSRequired,
+			"relay only accepts users over TLS"))
+		return nil
+	}
+
 	// Import public key
 	publicKey, err := crypto.ImportPublicKeyPEM(hs.PublicKey)
 	if err != nil {
 		log.Printf("Import public key error: %v", err)
-		return protocol.Address{}
+		return nil
+	}
+
+	// Multi-tenant relays only admit users of a configured tenant
+	if tenants := rs.getTenants(); tenants != nil && hs.ClientType == protocol.ClientTypeUser {
+		if relayErr := tenants.admit(hs.Tenant, hs.Address); relayErr != nil {
+			log.Printf("🚫 Refused handshake from %x: %v", hs.Address[:8], relayErr)
+			rs.sendRelayError(conn, header.MessageID, relayErr)
+			return nil
+		}
+	}
+
+	// Registered bots must prove their API key; nobody else may claim to be one
+	isBot, relayErr := rs.getBots().authenticate(&hs, time.Now())
+	if relayErr != nil {
+		log.Printf("🚫 Refused handshake from %x: %v", hs.Address[:8], relayErr)
+		rs.sendRelayError(conn, header.MessageID, relayErr)
+		return nil
+	}
+
+	// Send handshake ACK, agreeing to uniform records if both sides want them
+	records := rs.getUniformRecords()
+	if !recordsRequested(header) {
+		records = nil
+	}
+	rs.sendHandshakeAck(conn, recordFlag(records))
+
+	// Everything after the ACK is records, so register the wrapped connection
+	// only now that nothing else can write to it first
+	if records != nil {
+		conn = newUniformConn(conn, *records)
 	}
 
 	// Store peer
 	peer := &Peer{
-		Conn:       conn,
-		Address:    hs.Address,
-		PublicKey:  publicKey,
-		ClientType: hs.ClientType,
-		LastSeen:   time.Now(),
+		Conn:        conn,
+		Address:     hs.Address,
+		PublicKey:   publicKey,
+		ClientType:  hs.ClientType,
+		LastSeen:    time.Now(),
+		Limits:      protocol.NegotiatePayloadLimits(rs.GetPayloadLimits(), hs.Limits),
+		Tenant:      hs.Tenant,
+		Bot:         isBot,
+		QueueFlow:   hs.ClientType == protocol.ClientTypeUser && header.HasFlag(protocol.FlagQueueFlow),
+		OfflineSync: hs.ClientType == protocol.ClientTypeUser && header.HasFlag(protocol.FlagOfflineSync),
 	}
 
-	rs.mu.Lock()
-	rs.peers[string(hs.Address[:])] = peer
-	rs.mu.Unlock()
-
-	// Send handshake ACK
-	rs.sendHandshakeAck(conn)
+	rs.registerPeer(peer)
 
 	log.Printf("Peer registered: %x", hs.Address)
 
+	// Let the user skip this handshake if they drop and come back shortly
+	if hs.ClientType == protocol.ClientTypeUser && header.HasFlag(protocol.FlagResumable) {
+		rs.issueTicket(peer, records)
+	}
+
+	// During a migration the queue lives on the new relay; point the user there
+	if target := rs.GetMigrationTarget(); target != nil && hs.ClientType == protocol.ClientTypeUser {
+		if err := rs.sendRelayMoved(conn, hs.Address, target); err != nil {
+			log.Printf("Failed to send relay moved notice: %v", err)
+		}
+		return peer
+	}
+
+	// In a cluster, hint the user toward the node that owns them
+	if hs.ClientType == protocol.ClientTypeUser {
+		rs.redirectToOwner(conn, hs.Address)
+	}
+
 	// Deliver queued messages for this user (if any)
 	if rs.messageQueue != nil && hs.ClientType == protocol.ClientTypeUser {
 		go rs.deliverQueuedMessages(hs.Address)
 	}
 
-	return hs.Address
+	return peer
 }
 
 // handleRelayForward handles message forwarding
-func (rs *RelayServer) handleRelayForward(conn net.Conn, header *protocol.Header) {
+// sender is the peer the connection handshook as (nil before a handshake)
+func (rs *RelayServer) handleRelayForward(conn net.Conn, header *protocol.Header, sender *Peer) {
 	// Read payload
 	payload := make([]byte, header.Length)
 	if _, err := io.ReadFull(conn, payload); err != nil {
@@ -80,10 +138,37 @@ func (rs *RelayServer) handleRelayForward(conn net.Conn, header *protocol.Header
 		return
 	}
 
+	// Users of a tenant share its rate limit
+	tenants := rs.getTenants()
+	fromTenant := tenants != nil && sender != nil && sender.ClientType == protocol.ClientTypeUser
+	if fromTenant {
+		if relayErr := tenants.allowSend(sender.Tenant, time.Now()); relayErr != nil {
+			log.Printf("🚫 Refusing message %x from tenant %q: %v", header.MessageID[:8], sender.Tenant, relayErr)
+			rs.sendRelayError(conn, header.MessageID, relayErr)
+			return
+		}
+	}
+
+	// Bots have their own, usually lower, rate limit
+	if sender != nil && sender.Bot {
+		if relayErr := rs.getBots().allowSend(sender.Address, time.Now()); relayErr != nil {
+			log.Printf("🚫 Refusing message %x from bot %x: %v", header.MessageID[:8], sender.Address[:8], relayErr)
+			rs.sendRelayError(conn, header.MessageID, relayErr)
+			return
+		}
+	}
+
+	// Fault injection (chaos builds only): senders must retransmit or fail over
+	chaos.Delay("relay.forward")
+	if chaos.Drop("relay.forward") {
+		return
+	}
+
 	// Decrypt onion layer
 	layer, err := crypto.DecryptOnionLayer(payload, rs.PrivateKey)
 	if err != nil {
 		log.Printf("Decrypt onion error: %v", err)
+		rs.sendRelayError(conn, header.MessageID, protocol.NewRelayError(protocol.RelayErrMalformed, "onion layer could not be decrypted"))
 		return
 	}
 
@@ -92,6 +177,7 @@ func (rs *RelayServer) handleRelayForward(conn net.Conn, header *protocol.Header
 	// Otherwise, check if it's a relay or a client
 	if crypto.IsDeliveryAddress(layer.NextHop) {
 		log.Printf("Error: NextHop is zero, cannot deliver")
+		rs.sendRelayError(conn, header.MessageID, protocol.NewRelayError(protocol.RelayErrMalformed, "onion layer has no next hop"))
 		return
 	}
 
@@ -100,23 +186,39 @@ func (rs *RelayServer) handleRelayForward(conn net.Conn, header *protocol.Header
 	peer, exists := rs.peers[string(layer.NextHop[:])]
 	rs.mu.RUnlock()
 
-	if !exists {
-		log.Printf("Next hop not connected: %x", layer.NextHop)
-
-		// Try to queue the message (deliverMessage will handle queuing if messageQueue is available)
-		rs.deliverMessage(layer.NextHop, layer.Payload)
+	// Enforce exit policy: connected relays are forwarding, anything else is final delivery
+	isRelay := exists && peer.ClientType == protocol.ClientTypeRelay
+	if relayErr := rs.validateNextHop(layer.NextHop, isRelay); relayErr != nil {
+		log.Printf("🚫 Refusing to route message %x: %v", header.MessageID[:8], relayErr)
+		rs.sendRelayError(conn, header.MessageID, relayErr)
 		return
 	}
 
-	// Check if it's a relay or client
-	if peer.ClientType == protocol.ClientTypeRelay {
+	// Tenants are isolated: a user may only reach users of their own tenant
+	if fromTenant && !isRelay {
+		if relayErr := tenants.checkRoute(sender.Tenant, layer.NextHop); relayErr != nil {
+			log.Printf("🚫 Refusing to route message %x: %v", header.MessageID[:8], relayErr)
+			rs.sendRelayError(conn, header.MessageID, relayErr)
+			return
+		}
+	}
+
+	if isRelay {
 		// Forward to next relay
 		log.Printf("Forwarding to next hop relay: %x", layer.NextHop)
-		rs.forwardToNextHop(layer.NextHop, layer.Payload)
+		err = rs.forwardToNextHop(layer.NextHop, layer.Payload)
 	} else {
-		// Deliver to client
+		// Deliver to client, or queue it if they are offline
 		log.Printf("Delivering message to client: %x", layer.NextHop)
-		rs.deliverMessage(layer.NextHop, layer.Payload)
+		var from *protocol.Address
+		if sender != nil && sender.ClientType == protocol.ClientTypeUser {
+			from = &sender.Address
+		}
+		err = rs.deliverMessage(layer.NextHop, layer.Payload, from, nil)
+	}
+	if err != nil {
+		rs.sendRelayError(conn, header.MessageID, asRelayError(err))
+		return
 	}
 
 	// Increment relay counter
@@ -129,6 +231,41 @@ func (rs *RelayServer) handleRelayForward(conn net.Conn, header *protocol.Header
 	rs.sendAck(conn, header.MessageID)
 }
 
+// asRelayError returns err's relay error, or wraps it as RelayErrInternal
+func asRelayError(err error) *protocol.RelayErrorMessage {
+	var relayErr *protocol.RelayErrorMessage
+	if errors.As(err, &relayErr) {
+		return relayErr
+	}
+	return protocol.NewRelayError(protocol.RelayErrInternal, "%v", err)
+}
+
+// handleReply consumes a reply from a downstream relay, logging relay errors
+// Acks from connected users are recipients acknowledging delivery; they get a
+// delivery proof and are routed back to the sender if they name its relay.
+func (rs *RelayServer) handleReply(conn net.Conn, header *protocol.Header, peer *Peer) error {
+	payload := make([]byte, header.Length)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return err
+	}
+
+	if header.Type == protocol.MsgTypeAck && peer != nil && peer.ClientType == protocol.ClientTypeUser {
+		rs.recordDeliveryProof(peer, header, payload)
+		rs.returnAck(peer, header, payload)
+		return nil
+	}
+
+	if header.Type == protocol.MsgTypeRelayError {
+		var relayErr protocol.RelayErrorMessage
+		if err := protocol.DecodePayload(payload, header.Flags, &relayErr); err != nil {
+			log.Printf("Decode relay error: %v", err)
+			return nil
+		}
+		log.Printf("⚠️  Next hop refused message %x: %v", header.MessageID[:8], &relayErr)
+	}
+	return nil
+}
+
 // handlePing handles ping messages
 func (rs *RelayServer) handlePing(conn net.Conn, header *protocol.Header) {
 	log.Println("Ping received, sending pong")
@@ -151,7 +288,7 @@ func (rs *RelayServer) handlePing(conn net.Conn, header *protocol.Header) {
 }
 
 // sendHandshakeAck sends handshake acknowledgment
-func (rs *RelayServer) sendHandshakeAck(conn net.Conn) error {
+func (rs *RelayServer) sendHandshakeAck(conn net.Conn, flags uint16) error {
 	// Export public key
 	pubKeyPEM, err := crypto.ExportPublicKeyPEM(rs.PublicKey)
 	if err != nil {
@@ -165,6 +302,9 @@ func (rs *RelayServer) sendHandshakeAck(conn net.Conn) error {
 		PublicKey:       pubKeyPEM,
 		ClientType:      protocol.ClientTypeRelay,
 		Timestamp:       uint64(time.Now().Unix()),
+		Limits:          rs.GetPayloadLimits(),
+		Policy:          rs.GetRelayPolicy(),
+		Features:        rs.Features().Active(),
 	}
 
 	payload := hs.Encode()
@@ -175,7 +315,7 @@ func (rs *RelayServer) sendHandshakeAck(conn net.Conn) error {
 		Version:   protocol.ProtocolVersion,
 		Type:      protocol.MsgTypeHandshakeAck,
 		Length:    uint32(len(payload)),
-		Flags:     0,
+		Flags:     flags,
 		MessageID: protocol.GenerateMessageID(),
 	}
 
@@ -0,0 +1,149 @@
+package network
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+	"github.com/ZentaChain/zentalk-node/pkg/storage"
+)
+
+// KeyBundleLowWater is the one-time prekey count below which a bundle's owner is told to publish more
+const KeyBundleLowWater = 20
+
+// AttachKeyBundleStore lets users publish key bundles for contacts to fetch while they are offline
+func (rs *RelayServer) AttachKeyBundleStore(store *storage.KeyBundleStore) {
+	rs.keyBundles = store
+	log.Println("🔑 Key bundle registry attached to relay server")
+}
+
+// handleKeyBundlePublish stores a bundle published by its owner
+// Only a user may publish, and only their own bundle. The handshake address is
+// not proof of ownership on its own, so once a bundle is stored, later ones
+// must be signed with the same identity key.
+func (rs *RelayServer) handleKeyBundlePublish(conn net.Conn, header *protocol.Header, peer *Peer) error {
+	payload := make([]byte, header.Length)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return err
+	}
+
+	store := rs.keyBundles
+	if store == nil || peer == nil || peer.ClientType != protocol.ClientTypeUser {
+		return rs.sendKeyBundleResponse(conn, header.MessageID, &protocol.KeyBundleResponse{Status: protocol.KeyBundleStatusRejected})
+	}
+
+	var publish protocol.KeyBundlePublish
+	if err := publish.Decode(payload); err != nil {
+		log.Printf("Decode key bundle from %x: %v", peer.Address[:8], err)
+		return rs.sendKeyBundleResponse(conn, header.MessageID, &protocol.KeyBundleResponse{Status: protocol.KeyBundleStatusRejected})
+	}
+	bundle, err := publish.KeyBundle()
+	if err != nil {
+		log.Printf("Decode key bundle from %x: %v", peer.Address[:8], err)
+		return rs.sendKeyBundleResponse(conn, header.MessageID, &protocol.KeyBundleResponse{Status: protocol.KeyBundleStatusRejected})
+	}
+	if bundle.Address != peer.Address {
+		log.Printf("⚠️  %x tried to publish a key bundle for %x", peer.Address[:8], bundle.Address[:8])
+		return rs.sendKeyBundleResponse(conn, header.MessageID, &protocol.KeyBundleResponse{Status: protocol.KeyBundleStatusRejected})
+	}
+
+	count, err := store.Publish(bundle, publish.Signature)
+	if errors.Is(err, storage.ErrKeyBundleSigner) {
+		log.Printf("⚠️  %x tried to replace its key bundle with one signed by another identity", peer.Address[:8])
+		return rs.sendKeyBundleResponse(conn, header.MessageID, &protocol.KeyBundleResponse{Status: protocol.KeyBundleStatusRejected})
+	}
+	if err != nil {
+		log.Printf("Failed to store key bundle for %x: %v", peer.Address[:8], err)
+		return rs.sendKeyBundleResponse(conn, header.MessageID, &protocol.KeyBundleResponse{Status: protocol.KeyBundleStatusRejected})
+	}
+
+	log.Printf("🔑 Key bundle published by %x (%d one-time prekeys)", peer.Address[:8], count)
+	return rs.sendKeyBundleResponse(conn, header.MessageID, &protocol.KeyBundleResponse{
+		Status:         protocol.KeyBundleStatusOK,
+		OneTimePreKeys: uint32(count),
+	})
+}
+
+// handleKeyBundleRequest hands out a user's bundle with one of their one-time prekeys
+// Users of other tenants are answered as if no bundle had been published.
+func (rs *RelayServer) handleKeyBundleRequest(conn net.Conn, header *protocol.Header, peer *Peer) error {
+	payload := make([]byte, header.Length)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return err
+	}
+
+	notFound := &protocol.KeyBundleResponse{Status: protocol.KeyBundleStatusNotFound}
+	store := rs.keyBundles
+	if store == nil || peer == nil {
+		return rs.sendKeyBundleResponse(conn, header.MessageID, notFound)
+	}
+
+	var req protocol.KeyBundleRequest
+	if err := protocol.DecodePayload(payload, header.Flags, &req); err != nil {
+		log.Printf("Decode key bundle request from %x: %v", peer.Address[:8], err)
+		return rs.sendKeyBundleResponse(conn, header.MessageID, notFound)
+	}
+
+	if tenants := rs.getTenants(); tenants != nil && peer.ClientType == protocol.ClientTypeUser {
+		if relayErr := tenants.checkRoute(peer.Tenant, req.Address); relayErr != nil {
+			return rs.sendKeyBundleResponse(conn, header.MessageID, notFound)
+		}
+	}
+
+	bundle, sig, remaining, err := store.Fetch(req.Address)
+	if err != nil {
+		if !errors.Is(err, storage.ErrKeyBundleNotFound) {
+			log.Printf("Failed to fetch key bundle for %x: %v", req.Address[:8], err)
+		}
+		return rs.sendKeyBundleResponse(conn, header.MessageID, notFound)
+	}
+
+	if remaining < KeyBundleLowWater {
+		rs.warnKeyBundleLow(req.Address, remaining)
+	}
+
+	return rs.sendKeyBundleResponse(conn, header.MessageID, &protocol.KeyBundleResponse{
+		Status:         protocol.KeyBundleStatusOK,
+		OneTimePreKeys: uint32(remaining),
+		Bundle:         bundle.Encode(),
+		Signature:      sig,
+	})
+}
+
+// warnKeyBundleLow tells a connected owner their one-time prekeys are running out
+// Owners who are offline find out from the publish response when they next publish.
+func (rs *RelayServer) warnKeyBundleLow(owner protocol.Address, remaining int) {
+	rs.mu.RLock()
+	peer, exists := rs.peers[string(owner[:])]
+	rs.mu.RUnlock()
+	if !exists || peer.ClientType != protocol.ClientTypeUser {
+		return
+	}
+
+	notice := &protocol.KeyBundleResponse{Status: protocol.KeyBundleStatusLow, OneTimePreKeys: uint32(remaining)}
+	if err := rs.sendKeyBundleResponse(peer.Conn, protocol.GenerateMessageID(), notice); err != nil {
+		log.Printf("Failed to warn %x about low prekeys: %v", owner[:8], err)
+	}
+}
+
+// sendKeyBundleResponse writes a key bundle response
+func (rs *RelayServer) sendKeyBundleResponse(conn net.Conn, messageID protocol.MessageID, resp *protocol.KeyBundleResponse) error {
+	payload := resp.Encode()
+
+	header := &protocol.Header{
+		Magic:     protocol.ProtocolMagic,
+		Version:   protocol.ProtocolVersion,
+		Type:      protocol.MsgTypeKeyBundleResponse,
+		Length:    uint32(len(payload)),
+		Flags:     0,
+		MessageID: messageID,
+	}
+
+	if err := protocol.WriteHeader(conn, header); err != nil {
+		return err
+	}
+	_, err := conn.Write(payload)
+	return err
+}
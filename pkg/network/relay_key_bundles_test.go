@@ -0,0 +1,123 @@
+package network
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+	"github.com/ZentaChain/zentalk-node/pkg/storage"
+)
+
+// keyBundleRoundTrip sends a key bundle frame from peer to rs and returns the relay's answer
+func keyBundleRoundTrip(t *testing.T, rs *RelayServer, peer *Peer, msgType uint16, payload []byte) *protocol.KeyBundleResponse {
+	t.Helper()
+
+	header := &protocol.Header{Type: msgType, Length: uint32(len(payload)), MessageID: protocol.GenerateMessageID()}
+	relaySide, userSide := net.Pipe()
+	defer userSide.Close()
+
+	errs := make(chan error, 1)
+	go func() {
+		defer relaySide.Close()
+		if msgType == protocol.MsgTypeKeyBundlePublish {
+			errs <- rs.handleKeyBundlePublish(relaySide, header, peer)
+		} else {
+			errs <- rs.handleKeyBundleRequest(relaySide, header, peer)
+		}
+	}()
+
+	if _, err := userSide.Write(payload); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := protocol.ReadHeader(userSide)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := make([]byte, reply.Length)
+	if _, err := io.ReadFull(userSide, body); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+
+	var resp protocol.KeyBundleResponse
+	if err := resp.Decode(body); err != nil {
+		t.Fatal(err)
+	}
+	return &resp
+}
+
+// signedPublish returns a signed publish of a bundle for addr under a new identity
+func signedPublish(t *testing.T, addr protocol.Address) (*protocol.KeyBundle, []byte) {
+	t.Helper()
+	identity, err := protocol.GenerateIdentityKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	spk, err := protocol.GenerateSignedPreKey(1, identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opks, err := protocol.GenerateOneTimePreKeys(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle := protocol.CreateKeyBundle(addr, identity, spk, opks, 7)
+	publish := &protocol.KeyBundlePublish{Signature: protocol.SignKeyBundle(bundle, identity), Bundle: bundle.Encode()}
+	return bundle, publish.Encode()
+}
+
+func TestKeyBundlePublishHijack(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := NewRelayServer(0, key)
+	store, err := storage.OpenKeyBundleStore(filepath.Join(t.TempDir(), "bundles.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	rs.AttachKeyBundleStore(store)
+
+	addr := protocol.Address{5}
+	owner := &Peer{Address: addr, ClientType: protocol.ClientTypeUser}
+	bundle, publish := signedPublish(t, addr)
+	if resp := keyBundleRoundTrip(t, rs, owner, protocol.MsgTypeKeyBundlePublish, publish); resp.Status != protocol.KeyBundleStatusOK {
+		t.Fatalf("owner publish status = %d, want OK", resp.Status)
+	}
+
+	// Someone who connects under the owner's address cannot replace the bundle
+	impostor := &Peer{Address: addr, ClientType: protocol.ClientTypeUser}
+	_, forged := signedPublish(t, addr)
+	if resp := keyBundleRoundTrip(t, rs, impostor, protocol.MsgTypeKeyBundlePublish, forged); resp.Status != protocol.KeyBundleStatusRejected {
+		t.Fatalf("impostor publish status = %d, want rejected", resp.Status)
+	}
+
+	// Nor publish one that is unsigned or signed over other contents
+	if resp := keyBundleRoundTrip(t, rs, impostor, protocol.MsgTypeKeyBundlePublish, bundle.Encode()); resp.Status != protocol.KeyBundleStatusRejected {
+		t.Fatalf("unsigned publish status = %d, want rejected", resp.Status)
+	}
+	tampered := append([]byte(nil), publish...)
+	tampered[len(tampered)-1] ^= 1
+	if resp := keyBundleRoundTrip(t, rs, impostor, protocol.MsgTypeKeyBundlePublish, tampered); resp.Status != protocol.KeyBundleStatusRejected {
+		t.Fatalf("tampered publish status = %d, want rejected", resp.Status)
+	}
+
+	// Contacts still get the owner's bundle, with a signature they can check
+	contact := &Peer{Address: protocol.Address{6}, ClientType: protocol.ClientTypeUser}
+	req := &protocol.KeyBundleRequest{Address: addr}
+	resp := keyBundleRoundTrip(t, rs, contact, protocol.MsgTypeKeyBundleRequest, req.Encode())
+	got, err := resp.KeyBundle()
+	if err != nil {
+		t.Fatalf("KeyBundle() error = %v", err)
+	}
+	if got.IdentityKey != bundle.IdentityKey {
+		t.Errorf("fetched identity %x, want the owner's %x", got.IdentityKey[:8], bundle.IdentityKey[:8])
+	}
+}
@@ -0,0 +1,118 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// SetMediaDirectory sets the storage endpoints and media limits this relay advertises
+// nil stops advertising them; requests then get an empty directory.
+func (rs *RelayServer) SetMediaDirectory(dir *protocol.MediaDirectory) {
+	rs.mu.Lock()
+	rs.mediaDirectory = dir
+	rs.mu.Unlock()
+
+	if dir != nil {
+		log.Printf("🗂️  Media directory set: %d storage endpoints, %d size limits", len(dir.Endpoints), len(dir.MaxSize))
+	}
+}
+
+// GetMediaDirectory returns the media directory this relay advertises (nil = none)
+func (rs *RelayServer) GetMediaDirectory() *protocol.MediaDirectory {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.mediaDirectory
+}
+
+// mediaDirectoryFile is the JSON form of a media directory
+type mediaDirectoryFile struct {
+	Endpoints []string          `json:"endpoints"`
+	MaxSize   map[string]uint32 `json:"max_size"`
+}
+
+// LoadMediaDirectory reads a media directory from a JSON file
+// Format: {"endpoints": ["https://storage.example.org"], "max_size": {"image": 10485760,
+// "0x05": 52428800}}. Content types are given by name or number.
+func LoadMediaDirectory(path string) (*protocol.MediaDirectory, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read media directory file: %w", err)
+	}
+
+	var file mediaDirectoryFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse media directory file: %w", err)
+	}
+
+	if len(file.Endpoints) > protocol.MaxMediaEndpoints {
+		return nil, fmt.Errorf("media directory lists more than %d endpoints", protocol.MaxMediaEndpoints)
+	}
+	for _, endpoint := range file.Endpoints {
+		if endpoint == "" || len(endpoint) > protocol.MaxMediaEndpointLength {
+			return nil, fmt.Errorf("invalid storage endpoint %q", endpoint)
+		}
+	}
+
+	dir := &protocol.MediaDirectory{Endpoints: file.Endpoints}
+	if len(file.MaxSize) > 0 {
+		dir.MaxSize = make(map[uint8]uint32, len(file.MaxSize))
+	}
+	for name, max := range file.MaxSize {
+		ct, err := parseContentType(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max_size content type: %w", err)
+		}
+		dir.MaxSize[ct] = max
+	}
+
+	return dir, nil
+}
+
+// parseContentType resolves a content type name ("image") or number ("0x02")
+func parseContentType(name string) (uint8, error) {
+	for _, ct := range protocol.DefaultContentTypes.Types() {
+		if info, ok := protocol.DefaultContentTypes.Lookup(ct); ok && info.Name == name {
+			return ct, nil
+		}
+	}
+	ct, err := strconv.ParseUint(name, 0, 8)
+	if err != nil {
+		return 0, fmt.Errorf("unknown content type %q", name)
+	}
+	return uint8(ct), nil
+}
+
+// handleMediaDirectoryRequest answers a media directory request with the relay's directory
+func (rs *RelayServer) handleMediaDirectoryRequest(conn net.Conn, header *protocol.Header) error {
+	if _, err := io.CopyN(io.Discard, conn, int64(header.Length)); err != nil {
+		return err
+	}
+
+	dir := rs.GetMediaDirectory()
+	if dir == nil {
+		dir = &protocol.MediaDirectory{}
+	}
+	payload := dir.Encode()
+
+	resp := &protocol.Header{
+		Magic:     protocol.ProtocolMagic,
+		Version:   protocol.ProtocolVersion,
+		Type:      protocol.MsgTypeMediaDirectory,
+		Length:    uint32(len(payload)),
+		Flags:     0,
+		MessageID: header.MessageID,
+	}
+
+	if err := protocol.WriteHeader(conn, resp); err != nil {
+		return err
+	}
+	_, err := conn.Write(payload)
+	return err
+}
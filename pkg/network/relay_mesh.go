@@ -285,6 +285,9 @@ func (mm *MeshManager) maintainConnections() {
 
 // GetMeshStatus returns current mesh status
 func (mm *MeshManager) GetMeshStatus() map[string]interface{} {
+	// Read before taking the relay lock, which getMeshShaper takes itself
+	shaping := mm.relay.GetMeshShapingStats()
+
 	mm.relay.mu.RLock()
 	defer mm.relay.mu.RUnlock()
 
@@ -300,10 +303,12 @@ func (mm *MeshManager) GetMeshStatus() map[string]interface{} {
 	}
 
 	return map[string]interface{}{
-		"relay_peers":   relayPeers,
-		"client_peers":  clientPeers,
-		"total_peers":   len(mm.relay.peers),
-		"target_peers":  mm.targetPeerCount,
-		"mesh_healthy":  relayPeers >= mm.targetPeerCount,
+		"relay_peers":      relayPeers,
+		"client_peers":     clientPeers,
+		"total_peers":      len(mm.relay.peers),
+		"target_peers":     mm.targetPeerCount,
+		"mesh_healthy":     relayPeers >= mm.targetPeerCount,
+		"forwards_delayed": shaping.Delayed,
+		"forwards_refused": shaping.Refused,
 	}
 }
@@ -19,11 +19,15 @@ type RelayMetadata struct {
 	MaxConnections int              `json:"max_connections"` // Maximum concurrent connections
 	Uptime         uint64           `json:"uptime"`          // Uptime in seconds
 	LastSeen       int64            `json:"last_seen"`       // Unix timestamp (seconds)
+	ExitPolicy     string           `json:"exit_policy,omitempty"` // "both", "forward" or "delivery" (empty = both)
+	Features       protocol.FeatureList `json:"features,omitempty"` // Features the relay has switched on (empty from older relays)
+	PaddingBuckets []uint32         `json:"padding_buckets,omitempty"` // Sizes onion layers to the relay are padded to (see protocol.RelayPolicy)
 
 	// Health metrics (optional, may be empty when first published)
 	Latency        int64  `json:"latency,omitempty"`        // Average latency in milliseconds
 	PacketLoss     float64 `json:"packet_loss,omitempty"`   // Packet loss rate (0.0-1.0)
 	Reliability    float64 `json:"reliability,omitempty"`   // Reliability score (0.0-1.0)
+	Bandwidth      int64   `json:"bandwidth_kbps,omitempty"` // Self-measured throughput in kbit/s
 }
 
 // RelayScore represents a scored relay for circuit selection
@@ -46,6 +50,14 @@ func DecodeRelayMetadata(data []byte) (*RelayMetadata, error) {
 	return &meta, nil
 }
 
+// paddingBuckets returns the relay's padding buckets, or nil if it published invalid ones
+func (r *RelayMetadata) paddingBuckets() []uint32 {
+	if err := protocol.ValidatePaddingBuckets(r.PaddingBuckets); err != nil {
+		return nil
+	}
+	return r.PaddingBuckets
+}
+
 // IsHealthy returns true if the relay appears healthy
 func (r *RelayMetadata) IsHealthy(maxAge time.Duration) bool {
 	// Check if last seen is recent
@@ -119,6 +131,18 @@ func (r *RelayMetadata) CalculateScore() float64 {
 		score += uptimeHours / 168.0 * 10.0
 	}
 
+	// Factor 6: Measured capacity (max 10 points)
+	if r.Bandwidth > 0 {
+		// 10 Mbit/s or more = full points
+		bandwidthScore := float64(r.Bandwidth) / 10000.0 * 10.0
+		if bandwidthScore > 10.0 {
+			bandwidthScore = 10.0
+		}
+		score += bandwidthScore
+	} else {
+		score += 5.0 // Default neutral score
+	}
+
 	return score
 }
 
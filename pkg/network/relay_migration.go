@@ -0,0 +1,127 @@
+package network
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// SetMigrationTarget puts the relay in migration mode
+// Users who connect are told to move to the replacement relay instead of receiving
+// their queued messages here, since the queue was exported to the new relay
+func (rs *RelayServer) SetMigrationTarget(newRelay protocol.Address, endpoint string) error {
+	notice := &protocol.RelayMovedNotice{
+		NewRelay:    newRelay,
+		NewEndpoint: endpoint,
+	}
+	if err := notice.Validate(); err != nil {
+		return err
+	}
+
+	rs.mu.Lock()
+	rs.migrationTarget = notice
+	rs.mu.Unlock()
+
+	log.Printf("🚚 Migration mode: redirecting users to %s (%x)", endpoint, newRelay[:8])
+	return nil
+}
+
+// GetMigrationTarget returns the replacement relay notice (nil if not migrating)
+func (rs *RelayServer) GetMigrationTarget() *protocol.RelayMovedNotice {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.migrationTarget
+}
+
+// ExportQueueToFile writes the encrypted offline queue to path for import on the replacement relay
+func (rs *RelayServer) ExportQueueToFile(path, passphrase string) (int, error) {
+	if rs.messageQueue == nil {
+		return 0, fmt.Errorf("no message queue attached")
+	}
+
+	data, count, err := rs.messageQueue.ExportQueue(passphrase)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return 0, fmt.Errorf("failed to write queue export: %w", err)
+	}
+
+	return count, nil
+}
+
+// ImportQueueFromFile loads an encrypted queue export produced by ExportQueueToFile
+func (rs *RelayServer) ImportQueueFromFile(path, passphrase string) (int, error) {
+	if rs.messageQueue == nil {
+		return 0, fmt.Errorf("no message queue attached")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read queue export: %w", err)
+	}
+
+	return rs.messageQueue.ImportQueue(data, passphrase)
+}
+
+// sendRelayMoved tells a connecting user where their queued messages went
+func (rs *RelayServer) sendRelayMoved(conn net.Conn, recipientAddr protocol.Address, target *protocol.RelayMovedNotice) error {
+	notice := *target
+	notice.Timestamp = uint64(time.Now().UnixMilli())
+
+	if rs.messageQueue != nil {
+		if count, err := rs.messageQueue.GetQueuedMessageCount(recipientAddr); err == nil {
+			notice.PendingMessages = uint32(count)
+		}
+	}
+
+	payload := notice.Encode()
+
+	header := &protocol.Header{
+		Magic:     protocol.ProtocolMagic,
+		Version:   protocol.ProtocolVersion,
+		Type:      protocol.MsgTypeRelayMoved,
+		Length:    uint32(len(payload)),
+		Flags:     0,
+		MessageID: protocol.GenerateMessageID(),
+	}
+
+	if err := protocol.WriteHeader(conn, header); err != nil {
+		return err
+	}
+
+	if _, err := conn.Write(payload); err != nil {
+		return err
+	}
+
+	log.Printf("🚚 Sent relay moved notice to %x (%d pending messages)", recipientAddr[:8], notice.PendingMessages)
+	return nil
+}
+
+// handleRelayMoved handles a notice that our relay has migrated
+func (c *Client) handleRelayMoved(header *protocol.Header) {
+	payload := make([]byte, header.Length)
+	if _, err := io.ReadFull(c.relayConn, payload); err != nil {
+		log.Printf("Read payload error: %v", err)
+		return
+	}
+
+	var notice protocol.RelayMovedNotice
+	if err := protocol.DecodePayload(payload, header.Flags, &notice); err != nil {
+		log.Printf("Decode relay moved notice error: %v", err)
+		return
+	}
+
+	log.Printf("🚚 Relay has moved to %s (%x), %d messages waiting there",
+		notice.NewEndpoint, notice.NewRelay[:8], notice.PendingMessages)
+
+	if c.OnRelayMoved != nil {
+		c.OnRelayMoved(&notice)
+	}
+}
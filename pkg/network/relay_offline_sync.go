@@ -0,0 +1,106 @@
+package network
+
+import (
+	"io"
+	"log"
+	"net"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// handleOfflineSync acknowledges a user's last synced page and answers with the next one
+// Only users that handshook with FlagOfflineSync may sync; the relay pushes the
+// queue to everyone else, and serving both at once would send messages twice.
+// Messages larger than the user accepts in a response are skipped, and pruned
+// once a later page is acknowledged.
+func (rs *RelayServer) handleOfflineSync(conn net.Conn, header *protocol.Header, peer *Peer) error {
+	payload := make([]byte, header.Length)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return err
+	}
+
+	if rs.messageQueue == nil {
+		return rs.sendRelayError(conn, header.MessageID, protocol.NewRelayError(protocol.RelayErrUnsupportedType,
+			"relay has no offline queue"))
+	}
+	if peer == nil || peer.ClientType != protocol.ClientTypeUser || !peer.OfflineSync {
+		return rs.sendRelayError(conn, header.MessageID, protocol.NewRelayError(protocol.RelayErrTypeNotAllowed,
+			"offline sync needs a user connection opened with FlagOfflineSync"))
+	}
+
+	var req protocol.OfflineSyncRequest
+	if err := protocol.DecodePayload(payload, header.Flags, &req); err != nil {
+		return rs.sendRelayError(conn, header.MessageID, protocol.NewRelayError(protocol.RelayErrMalformed,
+			"offline sync request: %v", err))
+	}
+
+	if req.After > 0 {
+		pruned, err := rs.messageQueue.DeleteMessagesThrough(peer.Address, int64(req.After))
+		if err != nil {
+			log.Printf("Failed to prune synced messages for %x: %v", peer.Address[:8], err)
+			return rs.sendRelayError(conn, header.MessageID, protocol.NewRelayError(protocol.RelayErrInternal,
+				"offline queue unavailable"))
+		}
+		if pruned > 0 {
+			log.Printf("📬 %x acknowledged %d synced messages", peer.Address[:8], pruned)
+		}
+	}
+
+	messages, err := rs.messageQueue.GetQueuedMessagesAfter(peer.Address, int64(req.After), int64(req.Since), req.PageSize())
+	if err != nil {
+		log.Printf("Failed to get queued messages for %x: %v", peer.Address[:8], err)
+		return rs.sendRelayError(conn, header.MessageID, protocol.NewRelayError(protocol.RelayErrInternal,
+			"offline queue unavailable"))
+	}
+
+	page := &protocol.OfflineSyncResponse{}
+	budget := int(peer.Limits.Limit(protocol.MsgTypeOfflineSyncResponse)) - page.EncodedSize()
+	last := req.After
+	for _, msg := range messages {
+		entry := protocol.OfflineMessage{
+			Seq:      uint64(msg.ID),
+			QueuedAt: uint64(msg.Timestamp),
+			Payload:  msg.EncryptedPayload,
+		}
+		size := entry.EncodedSize()
+		if size > budget {
+			if len(page.Messages) > 0 {
+				break // Next page
+			}
+			log.Printf("Skipping %d-byte queued message for %x: larger than it accepts", len(msg.EncryptedPayload), peer.Address[:8])
+			last = uint64(msg.ID)
+			continue
+		}
+		page.Messages = append(page.Messages, entry)
+		budget -= size
+		last = uint64(msg.ID)
+	}
+
+	remaining, err := rs.messageQueue.CountQueuedMessagesAfter(peer.Address, int64(last), int64(req.Since))
+	if err != nil {
+		log.Printf("Failed to count queued messages for %x: %v", peer.Address[:8], err)
+	}
+	page.Remaining = uint32(remaining)
+
+	return rs.sendOfflineSyncResponse(conn, header.MessageID, page)
+}
+
+// sendOfflineSyncResponse answers an offline sync request with a page of the queue
+func (rs *RelayServer) sendOfflineSyncResponse(conn net.Conn, messageID protocol.MessageID, page *protocol.OfflineSyncResponse) error {
+	payload := page.Encode()
+
+	header := &protocol.Header{
+		Magic:     protocol.ProtocolMagic,
+		Version:   protocol.ProtocolVersion,
+		Type:      protocol.MsgTypeOfflineSyncResponse,
+		Length:    uint32(len(payload)),
+		Flags:     0,
+		MessageID: messageID,
+	}
+
+	if err := protocol.WriteHeader(conn, header); err != nil {
+		return err
+	}
+	_, err := conn.Write(payload)
+	return err
+}
@@ -0,0 +1,142 @@
+package network
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// syncOffline sends an offline sync request from peer and returns the relay's answer
+// Exactly one of the page and the relay error is set.
+func syncOffline(t *testing.T, rs *RelayServer, peer *Peer, req *protocol.OfflineSyncRequest) (*protocol.OfflineSyncResponse, *protocol.RelayErrorMessage) {
+	t.Helper()
+
+	payload := req.Encode()
+	header := &protocol.Header{Type: protocol.MsgTypeOfflineSync, Length: uint32(len(payload)), MessageID: protocol.GenerateMessageID()}
+	relaySide, userSide := net.Pipe()
+	defer userSide.Close()
+
+	errs := make(chan error, 1)
+	go func() {
+		defer relaySide.Close()
+		errs <- rs.handleOfflineSync(relaySide, header, peer)
+	}()
+
+	if _, err := userSide.Write(payload); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := protocol.ReadHeader(userSide)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := make([]byte, reply.Length)
+	if _, err := io.ReadFull(userSide, body); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if reply.MessageID != header.MessageID {
+		t.Fatalf("reply %x does not answer request %x", reply.MessageID, header.MessageID)
+	}
+
+	switch reply.Type {
+	case protocol.MsgTypeOfflineSyncResponse:
+		var page protocol.OfflineSyncResponse
+		if err := page.Decode(body); err != nil {
+			t.Fatal(err)
+		}
+		return &page, nil
+	case protocol.MsgTypeRelayError:
+		var relayErr protocol.RelayErrorMessage
+		if err := relayErr.Decode(body); err != nil {
+			t.Fatal(err)
+		}
+		return nil, &relayErr
+	default:
+		t.Fatalf("reply is type 0x%04x", reply.Type)
+		return nil, nil
+	}
+}
+
+func TestOfflineSyncPages(t *testing.T) {
+	addr := protocol.Address{9}
+	rs := newTestQueueRelay(t, addr, 10)
+	peer, conn := connectQueuePeer(rs, addr)
+	defer conn.Close()
+	peer.OfflineSync = true
+
+	// Nothing is pushed to a user that syncs
+	rs.deliverQueuedMessages(addr)
+	if count, _ := rs.messageQueue.GetQueuedMessageCount(addr); count != 10 {
+		t.Fatalf("%d messages queued after a flush, want all 10 left for sync", count)
+	}
+
+	req := &protocol.OfflineSyncRequest{Limit: 4}
+	for _, want := range []struct{ from, n, remaining int }{{0, 4, 6}, {4, 4, 2}, {8, 2, 0}, {10, 0, 0}} {
+		page, relayErr := syncOffline(t, rs, peer, req)
+		if relayErr != nil {
+			t.Fatalf("sync after %d: %v", req.After, relayErr)
+		}
+		if len(page.Messages) != want.n || int(page.Remaining) != want.remaining {
+			t.Fatalf("page from %d = %d messages, %d remaining; want %d, %d", want.from, len(page.Messages), page.Remaining, want.n, want.remaining)
+		}
+		for i, msg := range page.Messages {
+			if expected := fmt.Sprintf("msg-%02d", want.from+i); string(msg.Payload) != expected {
+				t.Errorf("got %q, want %q", msg.Payload, expected)
+			}
+		}
+
+		// Acknowledged pages are pruned
+		if count, _ := rs.messageQueue.GetQueuedMessageCount(addr); count != 10-want.from {
+			t.Errorf("%d messages queued after acknowledging %d", count, want.from)
+		}
+		if len(page.Messages) > 0 {
+			req.After = page.Cursor()
+		}
+	}
+
+	if count, _ := rs.messageQueue.GetQueuedMessageCount(addr); count != 0 {
+		t.Errorf("%d messages still queued after the final acknowledgment", count)
+	}
+}
+
+func TestOfflineSyncPageFitsLimits(t *testing.T) {
+	addr := protocol.Address{9}
+	rs := newTestQueueRelay(t, addr, 5)
+	peer, conn := connectQueuePeer(rs, addr)
+	defer conn.Close()
+	peer.OfflineSync = true
+
+	// Room for two of the 6-byte messages in a response
+	peer.Limits = &protocol.PayloadLimits{
+		Default: protocol.MaxRelayPayloadSize,
+		PerType: map[uint16]uint32{protocol.MsgTypeOfflineSyncResponse: 6 + 2*(20+6)},
+	}
+
+	page, relayErr := syncOffline(t, rs, peer, &protocol.OfflineSyncRequest{})
+	if relayErr != nil {
+		t.Fatal(relayErr)
+	}
+	if len(page.Messages) != 2 || page.Remaining != 3 {
+		t.Errorf("page = %d messages, %d remaining; want 2, 3", len(page.Messages), page.Remaining)
+	}
+}
+
+func TestOfflineSyncRefusedWhenPushed(t *testing.T) {
+	addr := protocol.Address{9}
+	rs := newTestQueueRelay(t, addr, 3)
+	peer, conn := connectQueuePeer(rs, addr)
+	defer conn.Close()
+
+	_, relayErr := syncOffline(t, rs, peer, &protocol.OfflineSyncRequest{})
+	if relayErr == nil || relayErr.Code != protocol.RelayErrTypeNotAllowed {
+		t.Fatalf("sync without FlagOfflineSync = %v, want a type-not-allowed error", relayErr)
+	}
+	if count, _ := rs.messageQueue.GetQueuedMessageCount(addr); count != 3 {
+		t.Errorf("%d messages queued, want 3 untouched", count)
+	}
+}
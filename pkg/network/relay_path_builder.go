@@ -14,11 +14,8 @@ func (c *Client) BuildSecureRelayPath(numRelays int) ([]*crypto.RelayInfo, error
 		return nil, fmt.Errorf("relay path must have at least 3 hops")
 	}
 
-	// Initialize guard relay manager if not already done
-	if c.guardRelayManager == nil && c.relayDiscovery != nil {
-		c.guardRelayManager = NewGuardRelayManager(c.relayDiscovery)
-		log.Printf("🛡️  Guard relay manager initialized")
-	}
+	// Initialize guard relay manager if not already done (restores persisted guards)
+	c.ensureGuardRelayManager()
 
 	var path []*crypto.RelayInfo
 
@@ -27,6 +24,8 @@ func (c *Client) BuildSecureRelayPath(numRelays int) ([]*crypto.RelayInfo, error
 		guardRelay, err := c.guardRelayManager.GetGuardRelay()
 		if err != nil {
 			log.Printf("⚠️  Failed to get guard relay: %v, using random entry", err)
+		} else if !guardRelay.AllowsForwarding() {
+			log.Printf("⚠️  Guard %s does not forward (exit policy %q), using random entry", guardRelay.NetworkAddress, guardRelay.ExitPolicy)
 		} else {
 			// Parse guard's public key
 			guardPubKey, err := crypto.ImportPublicKeyPEM([]byte(guardRelay.PublicKeyPEM))
@@ -35,8 +34,9 @@ func (c *Client) BuildSecureRelayPath(numRelays int) ([]*crypto.RelayInfo, error
 			} else {
 				// Convert guard to RelayInfo
 				guardInfo := &crypto.RelayInfo{
-					Address:   guardRelay.Address,
-					PublicKey: guardPubKey,
+					Address:        guardRelay.Address,
+					PublicKey:      guardPubKey,
+					PaddingBuckets: guardRelay.paddingBuckets(),
 				}
 				path = append(path, guardInfo)
 				log.Printf("🛡️  Using guard relay as entry: %s", guardRelay.NetworkAddress)
@@ -56,22 +56,39 @@ func (c *Client) BuildSecureRelayPath(numRelays int) ([]*crypto.RelayInfo, error
 	// Discover remaining relays (non-guards)
 	remainingHops := numRelays - len(path)
 	if remainingHops > 0 && c.relayDiscovery != nil {
-		additionalRelays, err := c.relayDiscovery.DiscoverRelays(remainingHops)
+		// Over-fetch so guards can be skipped without running short
+		additionalRelays, err := c.relayDiscovery.DiscoverRelays(remainingHops + NumGuardRelays)
 		if err != nil {
 			return nil, fmt.Errorf("failed to discover relays: %w", err)
 		}
 
+		// Never reuse a guard as a middle/exit hop
+		candidates := make([]*RelayMetadata, 0, len(additionalRelays))
+		for _, relay := range additionalRelays {
+			if c.guardRelayManager != nil && c.guardRelayManager.IsGuard(relay.Address) {
+				continue
+			}
+			candidates = append(candidates, relay)
+		}
+
+		// Respect exit policies: middle hops must forward, the last hop must deliver
+		ordered, err := arrangeForExitPolicy(candidates, remainingHops)
+		if err != nil {
+			return nil, fmt.Errorf("failed to arrange relay path: %w", err)
+		}
+
 		// Convert to RelayInfo
-		for i := 0; i < remainingHops && i < len(additionalRelays); i++ {
-			pubKey, err := crypto.ImportPublicKeyPEM([]byte(additionalRelays[i].PublicKeyPEM))
+		for _, relay := range ordered {
+			pubKey, err := crypto.ImportPublicKeyPEM([]byte(relay.PublicKeyPEM))
 			if err != nil {
 				log.Printf("⚠️  Failed to parse relay public key: %v", err)
 				continue
 			}
 
 			relayInfo := &crypto.RelayInfo{
-				Address:   additionalRelays[i].Address,
-				PublicKey: pubKey,
+				Address:        relay.Address,
+				PublicKey:      pubKey,
+				PaddingBuckets: relay.paddingBuckets(),
 			}
 			path = append(path, relayInfo)
 		}
@@ -92,13 +109,19 @@ func (c *Client) BuildRelayPath(numRelays int) ([]*crypto.RelayInfo, error) {
 		return nil, fmt.Errorf("relay discovery not initialized")
 	}
 
-	relays, err := c.relayDiscovery.DiscoverRelays(numRelays)
+	// Over-fetch so there is room to satisfy exit policies
+	candidates, err := c.relayDiscovery.DiscoverRelays(numRelays * 2)
 	if err != nil {
 		return nil, fmt.Errorf("failed to discover relays: %w", err)
 	}
 
-	if len(relays) < numRelays {
-		return nil, fmt.Errorf("not enough relays available (need %d, found %d)", numRelays, len(relays))
+	if len(candidates) < numRelays {
+		return nil, fmt.Errorf("not enough relays available (need %d, found %d)", numRelays, len(candidates))
+	}
+
+	relays, err := arrangeForExitPolicy(candidates, numRelays)
+	if err != nil {
+		return nil, fmt.Errorf("failed to arrange relay path: %w", err)
 	}
 
 	// Convert to RelayInfo
@@ -111,8 +134,9 @@ func (c *Client) BuildRelayPath(numRelays int) ([]*crypto.RelayInfo, error) {
 		}
 
 		relayInfo := &crypto.RelayInfo{
-			Address:   relays[i].Address,
-			PublicKey: pubKey,
+			Address:        relays[i].Address,
+			PublicKey:      pubKey,
+			PaddingBuckets: relays[i].paddingBuckets(),
 		}
 		path = append(path, relayInfo)
 	}
@@ -0,0 +1,178 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// SetRelayPolicy sets what this relay accepts from users and advertises in handshake acks
+// nil accepts every type up to the payload limits and sets no delivery ceilings.
+func (rs *RelayServer) SetRelayPolicy(policy *protocol.RelayPolicy) {
+	rs.mu.Lock()
+	rs.relayPolicy = policy
+	rs.mu.Unlock()
+
+	if policy != nil {
+		log.Printf("📜 Relay policy set: %d allowed types, %d per-type ceilings, %d bytes delivered, %d bytes queued, padding buckets %v",
+			len(policy.AllowedTypes), len(policy.MaxPayload), policy.DeliveryCeiling(false), policy.DeliveryCeiling(true), policy.PaddingBuckets)
+	}
+}
+
+// relayPolicyFile is the JSON form of a relay policy
+type relayPolicyFile struct {
+	AllowedTypes   []string          `json:"allowed_types"`
+	MaxPayload     map[string]uint32 `json:"max_payload"`
+	MaxDelivered   uint32            `json:"max_delivered"`
+	MaxQueued      uint32            `json:"max_queued"`
+	PaddingBuckets []uint32          `json:"padding_buckets"`
+}
+
+// LoadRelayPolicy reads a relay policy from a JSON file
+// Format: {"allowed_types": ["0x0100", "0x0003"], "max_payload": {"0x0100": 4194304},
+// "max_delivered": 2097152, "max_queued": 1048576, "padding_buckets": [1024, 4096, 16384]}.
+// Types are message type numbers.
+func LoadRelayPolicy(path string) (*protocol.RelayPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+
+	var file relayPolicyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file: %w", err)
+	}
+
+	policy := &protocol.RelayPolicy{MaxDelivered: file.MaxDelivered, MaxQueued: file.MaxQueued, PaddingBuckets: file.PaddingBuckets}
+	for _, name := range file.AllowedTypes {
+		t, err := strconv.ParseUint(name, 0, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed type %q", name)
+		}
+		policy.AllowedTypes = append(policy.AllowedTypes, uint16(t))
+	}
+	if len(file.MaxPayload) > 0 {
+		policy.MaxPayload = make(map[uint16]uint32, len(file.MaxPayload))
+	}
+	for name, max := range file.MaxPayload {
+		t, err := strconv.ParseUint(name, 0, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max_payload type %q", name)
+		}
+		policy.MaxPayload[uint16(t)] = max
+	}
+	if len(policy.AllowedTypes) > protocol.MaxRelayPolicyEntries || len(policy.MaxPayload) > protocol.MaxRelayPolicyEntries {
+		return nil, fmt.Errorf("policy names more than %d types", protocol.MaxRelayPolicyEntries)
+	}
+	if err := protocol.ValidatePaddingBuckets(policy.PaddingBuckets); err != nil {
+		return nil, fmt.Errorf("invalid padding_buckets: %w", err)
+	}
+
+	return policy, nil
+}
+
+// GetRelayPolicy returns this relay's policy (nil = none)
+func (rs *RelayServer) GetRelayPolicy() *protocol.RelayPolicy {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.relayPolicy
+}
+
+// refuseByPolicy discards a user's frame the policy refuses and reports why
+// Returns false if the frame may be handled. Unlike payload limits, a refused
+// frame leaves the connection open.
+func (rs *RelayServer) refuseByPolicy(conn net.Conn, header *protocol.Header, peer *Peer) bool {
+	if peer == nil || peer.ClientType != protocol.ClientTypeUser {
+		return false
+	}
+
+	err := rs.GetRelayPolicy().CheckFrame(header)
+	if err == nil {
+		return false
+	}
+
+	log.Printf("🚫 Refusing message %x from %x: %v", header.MessageID[:8], peer.Address[:8], err)
+	if _, err := io.CopyN(io.Discard, conn, int64(header.Length)); err != nil {
+		return true
+	}
+	rs.sendRelayError(conn, header.MessageID, policyRelayError(err))
+	return true
+}
+
+// checkDeliveryPolicy refuses a message over the policy's delivery or queue ceiling
+func (rs *RelayServer) checkDeliveryPolicy(size int, queued bool) *protocol.RelayErrorMessage {
+	if err := rs.GetRelayPolicy().CheckDelivery(size, queued); err != nil {
+		return policyRelayError(err)
+	}
+	return nil
+}
+
+// policyRelayError converts a relay policy error to the relay error sent back
+func policyRelayError(err error) *protocol.RelayErrorMessage {
+	if errors.Is(err, protocol.ErrTypeNotAllowed) {
+		return protocol.NewRelayError(protocol.RelayErrTypeNotAllowed, "%v", err)
+	}
+	return protocol.NewRelayError(protocol.RelayErrPayloadTooLarge, "%v", err)
+}
+
+// RelayPolicy returns the policy the connected relay advertised (nil = none)
+func (c *Client) RelayPolicy() *protocol.RelayPolicy {
+	return c.relayPolicy
+}
+
+// onionPath fills in the connected relay's padding buckets from its policy
+// Buckets learned in the handshake are fresher than those in the relay's DHT
+// metadata. Other hops are returned as they are; the caller's path is not modified.
+func (c *Client) onionPath(relayPath []*crypto.RelayInfo) []*crypto.RelayInfo {
+	buckets := c.relayPolicy.Buckets()
+	if len(buckets) == 0 {
+		return relayPath
+	}
+
+	path := make([]*crypto.RelayInfo, len(relayPath))
+	for i, hop := range relayPath {
+		path[i] = hop
+		if hop.Address == c.relayPeer {
+			padded := *hop
+			padded.PaddingBuckets = buckets
+			path[i] = &padded
+		}
+	}
+	return path
+}
+
+// buildOnionLayers builds onion layers padded to the buckets each hop advertises
+func (c *Client) buildOnionLayers(relayPath []*crypto.RelayInfo, to protocol.Address, payload []byte) ([]byte, error) {
+	return crypto.BuildOnionLayers(c.onionPath(relayPath), to, payload)
+}
+
+// buildOnionLayersContext is buildOnionLayers giving up between layers once ctx is done
+func (c *Client) buildOnionLayersContext(ctx context.Context, relayPath []*crypto.RelayInfo, to protocol.Address, payload []byte) ([]byte, error) {
+	return crypto.BuildOnionLayersContext(ctx, c.onionPath(relayPath), to, payload)
+}
+
+// checkSend checks a frame against the negotiated limits and the relay's policy
+// messageSize is what the last relay in relayPath delivers. Its ceiling is
+// known only when that relay is the one we are connected to; a recipient who
+// turns out to be offline may still hit the queue ceiling.
+func (c *Client) checkSend(header *protocol.Header, relayPath []*crypto.RelayInfo, messageSize int) error {
+	if err := c.payloadLimits.Check(header); err != nil {
+		return err
+	}
+	if err := c.relayPolicy.CheckFrame(header); err != nil {
+		return err
+	}
+	if len(relayPath) > 0 && relayPath[len(relayPath)-1].Address == c.relayPeer {
+		return c.relayPolicy.CheckDelivery(messageSize, false)
+	}
+	return nil
+}
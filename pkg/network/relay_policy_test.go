@@ -0,0 +1,55 @@
+package network
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+func TestOnionPathPaddingBuckets(t *testing.T) {
+	c := &Client{relayPeer: protocol.Address{1}}
+	entry := &crypto.RelayInfo{Address: protocol.Address{1}, PaddingBuckets: []uint32{1024}}
+	exit := &crypto.RelayInfo{Address: protocol.Address{2}, PaddingBuckets: []uint32{2048}}
+	relayPath := []*crypto.RelayInfo{entry, exit}
+
+	// Without a policy the path is used as given
+	if path := c.onionPath(relayPath); !reflect.DeepEqual(path, relayPath) {
+		t.Errorf("onionPath(no policy) = %v, want %v", path, relayPath)
+	}
+
+	// The connected relay's handshake buckets replace those from its metadata
+	c.relayPolicy = &protocol.RelayPolicy{PaddingBuckets: []uint32{4096, 8192}}
+	path := c.onionPath(relayPath)
+	if !reflect.DeepEqual(path[0].PaddingBuckets, []uint32{4096, 8192}) || path[1] != exit {
+		t.Errorf("onionPath() buckets = %v, %v, want [4096 8192], [2048]", path[0].PaddingBuckets, path[1].PaddingBuckets)
+	}
+	if !reflect.DeepEqual(entry.PaddingBuckets, []uint32{1024}) {
+		t.Errorf("onionPath() modified the caller's hop: buckets = %v", entry.PaddingBuckets)
+	}
+}
+
+func TestLoadRelayPolicyPaddingBuckets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+
+	if err := os.WriteFile(path, []byte(`{"max_delivered": 1048576, "padding_buckets": [1024, 4096]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := LoadRelayPolicy(path)
+	if err != nil {
+		t.Fatalf("LoadRelayPolicy() error = %v", err)
+	}
+	if !reflect.DeepEqual(policy.PaddingBuckets, []uint32{1024, 4096}) {
+		t.Errorf("LoadRelayPolicy() buckets = %v, want [1024 4096]", policy.PaddingBuckets)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"padding_buckets": [4096, 1024]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadRelayPolicy(path); err == nil {
+		t.Error("LoadRelayPolicy(descending buckets) expected error, got nil")
+	}
+}
@@ -0,0 +1,378 @@
+package network
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// Standing is what an address has committed to the network
+type Standing struct {
+	Stake   float64 // Staked CHAIN
+	Voucher bool    // Holds an unexpired payment voucher
+}
+
+// StakeSource looks up addresses' stake and payment vouchers
+// A source backed by the registry contract would cache its lookups; relays
+// call it for every message they queue.
+type StakeSource interface {
+	Standing(addr protocol.Address) Standing
+}
+
+// QueuePriorityConfig weights how much priority stake and vouchers earn
+// A message's priority is the weighted standing of its recipient plus that of
+// its sender (known only when the sender is connected to this relay), capped at
+// MaxPriority. Priority extends the offline TTL and, once the queue holds
+// Capacity messages, lets a message evict queued ones of lower priority.
+type QueuePriorityConfig struct {
+	StakeWeight     float64 // Priority per staked CHAIN
+	VoucherWeight   float64 // Priority for holding a payment voucher
+	RecipientWeight float64 // Share of the recipient's standing that counts
+	SenderWeight    float64 // Share of the sender's standing that counts
+	MaxPriority     int     // Highest priority a message can get
+
+	TTLPerPriority time.Duration // Extra offline TTL per priority point
+	MaxTTL         time.Duration // Longest TTL a message can get (0 = no cap)
+
+	// Capacity is how many messages the queue holds before queueing a message
+	// evicts one of lower priority (0 = unlimited). Tenants' MaxQueued evict the
+	// same way within the tenant.
+	Capacity int
+
+	// MaxEvictions caps the evictions messages from one sender, or to one
+	// recipient, may cause per EvictionWindow (0 = unlimited). Senders are
+	// known only by the address they connected with, and anyone may message a
+	// staked recipient, so without a cap one client could flush the queue.
+	MaxEvictions   int
+	EvictionWindow time.Duration
+}
+
+// DefaultQueuePriorityConfig returns weights where 1000 CHAIN staked or a voucher earn 10 points
+func DefaultQueuePriorityConfig() QueuePriorityConfig {
+	return QueuePriorityConfig{
+		StakeWeight:     0.01,
+		VoucherWeight:   10,
+		RecipientWeight: 1,
+		SenderWeight:    0.5,
+		MaxPriority:     100,
+		TTLPerPriority:  24 * time.Hour,
+		MaxTTL:          90 * 24 * time.Hour,
+		MaxEvictions:    100,
+		EvictionWindow:  time.Hour,
+	}
+}
+
+// score returns the priority a standing earns with the given share
+func (cfg *QueuePriorityConfig) score(s Standing, share float64) float64 {
+	score := s.Stake * cfg.StakeWeight
+	if s.Voucher {
+		score += cfg.VoucherWeight
+	}
+	return score * share
+}
+
+// queuePriority is the relay's priority scheme
+type queuePriority struct {
+	source StakeSource
+	cfg    QueuePriorityConfig
+
+	mu        sync.Mutex
+	evictions map[evictionKey]*evictionCount
+	swept     time.Time
+}
+
+// evictionKey is a sender or recipient whose messages' evictions are counted
+type evictionKey struct {
+	addr   protocol.Address
+	sender bool
+}
+
+// evictionCount is how many evictions a key caused in the window starting at since
+type evictionCount struct {
+	since time.Time
+	n     int
+}
+
+// limitEvictions wraps evict so that it fails to evict once the sender or
+// recipient has used up its MaxEvictions for the window
+// sender is nil when the message came from another relay.
+func (p *queuePriority) limitEvictions(sender *protocol.Address, recipient protocol.Address, evict func(int) (bool, error)) func(int) (bool, error) {
+	if p.cfg.MaxEvictions <= 0 {
+		return evict
+	}
+
+	return func(priority int) (bool, error) {
+		keys := []evictionKey{{addr: recipient}}
+		if sender != nil {
+			keys = append(keys, evictionKey{addr: *sender, sender: true})
+		}
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		now := time.Now()
+		if p.evictions == nil {
+			p.evictions = make(map[evictionKey]*evictionCount)
+		}
+		if now.Sub(p.swept) > p.cfg.EvictionWindow {
+			for key, count := range p.evictions {
+				if now.Sub(count.since) > p.cfg.EvictionWindow {
+					delete(p.evictions, key)
+				}
+			}
+			p.swept = now
+		}
+
+		for _, key := range keys {
+			count := p.evictions[key]
+			if count != nil && now.Sub(count.since) <= p.cfg.EvictionWindow && count.n >= p.cfg.MaxEvictions {
+				log.Printf("⭐ %x reached %d evictions, not evicting for it", key.addr[:8], p.cfg.MaxEvictions)
+				return false, nil
+			}
+		}
+
+		evicted, err := evict(priority)
+		if err != nil || !evicted {
+			return evicted, err
+		}
+		for _, key := range keys {
+			count := p.evictions[key]
+			if count == nil || now.Sub(count.since) > p.cfg.EvictionWindow {
+				count = &evictionCount{since: now}
+				p.evictions[key] = count
+			}
+			count.n++
+		}
+		return true, nil
+	}
+}
+
+// priority returns a message's priority and TTL (0 = the queue's default)
+// sender is nil when the message came from another relay.
+func (p *queuePriority) priority(sender *protocol.Address, recipient protocol.Address, baseTTL time.Duration) (int, time.Duration) {
+	score := p.cfg.score(p.source.Standing(recipient), p.cfg.RecipientWeight)
+	if sender != nil {
+		score += p.cfg.score(p.source.Standing(*sender), p.cfg.SenderWeight)
+	}
+
+	priority := int(math.Floor(min(score, float64(p.cfg.MaxPriority))))
+	if priority <= 0 {
+		return 0, 0
+	}
+
+	ttl := baseTTL + time.Duration(priority)*p.cfg.TTLPerPriority
+	if p.cfg.MaxTTL > 0 && ttl > p.cfg.MaxTTL {
+		ttl = max(p.cfg.MaxTTL, baseTTL)
+	}
+	return priority, ttl
+}
+
+// EnableQueuePriority ranks queued messages by their sender's and recipient's standing
+func (rs *RelayServer) EnableQueuePriority(source StakeSource, cfg QueuePriorityConfig) {
+	rs.mu.Lock()
+	rs.queuePriority = &queuePriority{source: source, cfg: cfg}
+	rs.mu.Unlock()
+
+	log.Printf("⭐ Queue priority enabled: stake weight %g, voucher weight %g, max priority %d, capacity %d",
+		cfg.StakeWeight, cfg.VoucherWeight, cfg.MaxPriority, cfg.Capacity)
+}
+
+// getQueuePriority returns the priority scheme (nil = disabled)
+func (rs *RelayServer) getQueuePriority() *queuePriority {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.queuePriority
+}
+
+// evictForRoom evicts one lower-priority message if queued has reached capacity
+// Returns the queue size afterwards; the caller's capacity check then decides.
+func evictForRoom(queued, capacity, priority int, evict func(int) (bool, error)) (int, error) {
+	if capacity <= 0 || queued < capacity {
+		return queued, nil
+	}
+
+	evicted, err := evict(priority)
+	if err != nil || !evicted {
+		return queued, err
+	}
+
+	log.Printf("⭐ Evicted a queued message below priority %d to make room", priority)
+	return queued - 1, nil
+}
+
+// makeQueueRoom keeps the whole queue within capacity, evicting by priority
+func (rs *RelayServer) makeQueueRoom(capacity, priority int, evict func(int) (bool, error)) *protocol.RelayErrorMessage {
+	if capacity <= 0 {
+		return nil
+	}
+
+	queued, err := rs.messageQueue.GetTotalQueueSize()
+	if err == nil {
+		queued, err = evictForRoom(queued, capacity, priority, evict)
+	}
+	if err != nil {
+		log.Printf("Failed to make room in queue: %v", err)
+		return protocol.NewRelayError(protocol.RelayErrInternal, "recipient offline and queue failed")
+	}
+	if queued >= capacity {
+		return protocol.NewRelayError(protocol.RelayErrQueueFull, "queue holds %d messages of priority %d or higher", capacity, priority)
+	}
+	return nil
+}
+
+// queuePriorityFile is the JSON form of a priority config; absent fields keep the defaults
+type queuePriorityFile struct {
+	StakeWeight     *float64 `json:"stake_weight"`
+	VoucherWeight   *float64 `json:"voucher_weight"`
+	RecipientWeight *float64 `json:"recipient_weight"`
+	SenderWeight    *float64 `json:"sender_weight"`
+	MaxPriority     *int     `json:"max_priority"`
+	TTLPerPriority  string   `json:"ttl_per_priority"`
+	MaxTTL          string   `json:"max_ttl"`
+	Capacity        int      `json:"capacity"`
+	MaxEvictions    *int     `json:"max_evictions"`
+	EvictionWindow  string   `json:"eviction_window"`
+}
+
+// LoadQueuePriorityConfig reads priority weights from a JSON file
+// Format: {"stake_weight": 0.01, "voucher_weight": 10, "recipient_weight": 1, "sender_weight": 0.5,
+// "max_priority": 100, "ttl_per_priority": "24h", "max_ttl": "2160h", "capacity": 100000,
+// "max_evictions": 100, "eviction_window": "1h"}
+func LoadQueuePriorityConfig(path string) (QueuePriorityConfig, error) {
+	cfg := DefaultQueuePriorityConfig()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read priority file: %w", err)
+	}
+
+	var file queuePriorityFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return cfg, fmt.Errorf("failed to parse priority file: %w", err)
+	}
+
+	for _, f := range []struct {
+		value *float64
+		dst   *float64
+	}{
+		{file.StakeWeight, &cfg.StakeWeight},
+		{file.VoucherWeight, &cfg.VoucherWeight},
+		{file.RecipientWeight, &cfg.RecipientWeight},
+		{file.SenderWeight, &cfg.SenderWeight},
+	} {
+		if f.value != nil {
+			if *f.value < 0 {
+				return cfg, fmt.Errorf("weights must not be negative")
+			}
+			*f.dst = *f.value
+		}
+	}
+	if file.MaxPriority != nil {
+		cfg.MaxPriority = *file.MaxPriority
+	}
+	if file.TTLPerPriority != "" {
+		if cfg.TTLPerPriority, err = time.ParseDuration(file.TTLPerPriority); err != nil {
+			return cfg, fmt.Errorf("invalid ttl_per_priority: %w", err)
+		}
+	}
+	if file.MaxTTL != "" {
+		if cfg.MaxTTL, err = time.ParseDuration(file.MaxTTL); err != nil {
+			return cfg, fmt.Errorf("invalid max_ttl: %w", err)
+		}
+	}
+	cfg.Capacity = file.Capacity
+	if file.MaxEvictions != nil {
+		cfg.MaxEvictions = *file.MaxEvictions
+	}
+	if file.EvictionWindow != "" {
+		if cfg.EvictionWindow, err = time.ParseDuration(file.EvictionWindow); err != nil {
+			return cfg, fmt.Errorf("invalid eviction_window: %w", err)
+		}
+	}
+
+	return cfg, nil
+}
+
+// StaticStakeSource serves standings from a list, such as a periodic export of the registry
+type StaticStakeSource struct {
+	standings map[protocol.Address]stakeEntry
+	mu        sync.RWMutex
+}
+
+// stakeEntry is one address's standing with its voucher expiry
+type stakeEntry struct {
+	stake          float64
+	voucherExpires time.Time
+}
+
+// stakeFileEntry is the JSON form of a stake entry
+type stakeFileEntry struct {
+	Address        string  `json:"address"`
+	Stake          float64 `json:"stake"`
+	VoucherExpires int64   `json:"voucher_expires"` // Unix seconds (0 = no voucher)
+}
+
+// LoadStakeFile reads standings from a JSON file
+// Format: [{"address": "<hex address>", "stake": 2500, "voucher_expires": 1767225600}, ...]
+func LoadStakeFile(path string) (*StaticStakeSource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stake file: %w", err)
+	}
+
+	var entries []stakeFileEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse stake file: %w", err)
+	}
+
+	source := &StaticStakeSource{standings: make(map[protocol.Address]stakeEntry, len(entries))}
+	for _, entry := range entries {
+		addrBytes, err := hex.DecodeString(entry.Address)
+		if err != nil || len(addrBytes) != len(protocol.Address{}) {
+			return nil, fmt.Errorf("invalid stake address %q", entry.Address)
+		}
+		if entry.Stake < 0 {
+			return nil, fmt.Errorf("%s: negative stake", entry.Address)
+		}
+
+		var addr protocol.Address
+		copy(addr[:], addrBytes)
+		e := stakeEntry{stake: entry.Stake}
+		if entry.VoucherExpires > 0 {
+			e.voucherExpires = time.Unix(entry.VoucherExpires, 0)
+		}
+		source.standings[addr] = e
+	}
+
+	return source, nil
+}
+
+// Set records an address's stake and voucher expiry (zero time = no voucher)
+func (s *StaticStakeSource) Set(addr protocol.Address, stake float64, voucherExpires time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.standings == nil {
+		s.standings = make(map[protocol.Address]stakeEntry)
+	}
+	s.standings[addr] = stakeEntry{stake: stake, voucherExpires: voucherExpires}
+}
+
+// Standing implements StakeSource
+func (s *StaticStakeSource) Standing(addr protocol.Address) Standing {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.standings[addr]
+	if !ok {
+		return Standing{}
+	}
+	return Standing{Stake: e.stake, Voucher: time.Now().Before(e.voucherExpires)}
+}
@@ -0,0 +1,91 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+func TestQueueEvictionFlood(t *testing.T) {
+	staked := protocol.Address{1}
+	flooder := protocol.Address{2}
+	other := protocol.Address{3}
+
+	source := &StaticStakeSource{standings: make(map[protocol.Address]stakeEntry)}
+	source.Set(staked, 10000, time.Time{})
+
+	cfg := DefaultQueuePriorityConfig()
+	cfg.Capacity = 50
+	cfg.MaxEvictions = 5
+	qp := &queuePriority{source: source, cfg: cfg}
+
+	// A full queue of ordinary messages, each of priority 0
+	queue := make([]int, cfg.Capacity)
+	evictLower := func(priority int) (bool, error) {
+		for i, p := range queue {
+			if p < priority {
+				queue = append(queue[:i], queue[i+1:]...)
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	enqueue := func(sender *protocol.Address, recipient protocol.Address) bool {
+		priority, _ := qp.priority(sender, recipient, time.Hour)
+		queued, err := evictForRoom(len(queue), cfg.Capacity, priority, qp.limitEvictions(sender, recipient, evictLower))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if queued >= cfg.Capacity {
+			return false
+		}
+		queue = append(queue, priority)
+		return true
+	}
+
+	// One client messaging the staked user only displaces its budget's worth
+	accepted := 0
+	for range 40 {
+		if enqueue(&flooder, staked) {
+			accepted++
+		}
+	}
+	if accepted != cfg.MaxEvictions {
+		t.Fatalf("flooder queued %d messages, want %d", accepted, cfg.MaxEvictions)
+	}
+
+	// Other senders cannot use the recipient's exhausted budget either
+	if enqueue(&other, staked) {
+		t.Error("another sender evicted for an exhausted recipient")
+	}
+	if enqueue(nil, staked) {
+		t.Error("a relay's message evicted for an exhausted recipient")
+	}
+
+	// Budgets renew after the window
+	for key, count := range qp.evictions {
+		count.since = count.since.Add(-2 * cfg.EvictionWindow)
+		qp.evictions[key] = count
+	}
+	if !enqueue(&other, staked) {
+		t.Error("eviction refused after the window passed")
+	}
+}
+
+func TestQueueEvictionUnlimited(t *testing.T) {
+	qp := &queuePriority{cfg: QueuePriorityConfig{MaxEvictions: 0}}
+	calls := 0
+	evict := qp.limitEvictions(nil, protocol.Address{1}, func(int) (bool, error) {
+		calls++
+		return true, nil
+	})
+	for range 10 {
+		if ok, err := evict(1); !ok || err != nil {
+			t.Fatalf("evict() = %v, %v", ok, err)
+		}
+	}
+	if calls != 10 {
+		t.Errorf("evict called %d times, want 10", calls)
+	}
+}
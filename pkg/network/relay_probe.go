@@ -0,0 +1,397 @@
+package network
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+const (
+	// DefaultProbeInterval is how often a relay re-measures its capacity
+	DefaultProbeInterval = 15 * time.Minute
+
+	// DefaultProbePeers is how many mesh peers are probed per round
+	DefaultProbePeers = 3
+
+	// probePayloadSize is the amount of data sent per throughput probe (256 KB)
+	probePayloadSize = 256 * 1024
+
+	// maxProbePayloadSize caps what a relay will accept from a probing peer
+	maxProbePayloadSize = 1024 * 1024
+
+	// probeTimeout bounds a single probe (connect + ping + transfer)
+	probeTimeout = 30 * time.Second
+)
+
+// ProbeResult is a single latency/throughput measurement to a mesh peer
+type ProbeResult struct {
+	Peer           protocol.Address
+	NetworkAddress string
+	Latency        time.Duration
+	ThroughputKbps int64
+	MeasuredAt     time.Time
+	Err            error
+}
+
+// SelfMeasurement summarizes the most recent probe round
+type SelfMeasurement struct {
+	LatencyMs     int64     // Median round-trip latency to probed peers
+	BandwidthKbps int64     // Median achievable throughput to probed peers
+	PeersProbed   int       // Number of peers that answered
+	MeasuredAt    time.Time // When the round finished
+}
+
+// BandwidthProber periodically measures latency and throughput from a relay to its mesh peers
+type BandwidthProber struct {
+	relay      *RelayServer
+	probePeers int
+	interval   time.Duration
+
+	last    *SelfMeasurement
+	results []*ProbeResult
+
+	running  bool
+	stopChan chan struct{}
+	mu       sync.RWMutex
+}
+
+// NewBandwidthProber creates a prober that measures up to probePeers peers per round
+func NewBandwidthProber(relay *RelayServer, probePeers int, interval time.Duration) *BandwidthProber {
+	if probePeers <= 0 {
+		probePeers = DefaultProbePeers
+	}
+	if interval <= 0 {
+		interval = DefaultProbeInterval
+	}
+
+	return &BandwidthProber{
+		relay:      relay,
+		probePeers: probePeers,
+		interval:   interval,
+		stopChan:   make(chan struct{}),
+	}
+}
+
+// Start begins periodic probing and attaches the prober to the relay
+func (bp *BandwidthProber) Start() error {
+	bp.mu.Lock()
+	if bp.running {
+		bp.mu.Unlock()
+		return fmt.Errorf("bandwidth prober already running")
+	}
+	bp.running = true
+	bp.mu.Unlock()
+
+	bp.relay.mu.Lock()
+	bp.relay.prober = bp
+	bp.relay.mu.Unlock()
+
+	log.Printf("📶 Starting bandwidth self-test (%d peers every %v)", bp.probePeers, bp.interval)
+
+	go bp.probeLoop()
+	return nil
+}
+
+// Stop stops periodic probing
+func (bp *BandwidthProber) Stop() {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	if !bp.running {
+		return
+	}
+
+	bp.running = false
+	close(bp.stopChan)
+	log.Println("🛑 Stopped bandwidth self-test")
+}
+
+// probeLoop runs a probe round immediately and then on every interval
+func (bp *BandwidthProber) probeLoop() {
+	ticker := time.NewTicker(bp.interval)
+	defer ticker.Stop()
+
+	bp.RunProbeRound()
+
+	for {
+		select {
+		case <-ticker.C:
+			bp.RunProbeRound()
+		case <-bp.stopChan:
+			return
+		}
+	}
+}
+
+// RunProbeRound probes a few mesh peers and updates the relay's advertised capacity
+func (bp *BandwidthProber) RunProbeRound() *SelfMeasurement {
+	targets := bp.selectTargets()
+	if len(targets) == 0 {
+		log.Println("⚠️  No mesh peers with known addresses to probe")
+		return nil
+	}
+
+	results := make([]*ProbeResult, 0, len(targets))
+	for _, target := range targets {
+		result := ProbePeer(target.NetworkAddress)
+		result.Peer = target.Address
+		results = append(results, result)
+
+		if bp.relay.relayDiscovery != nil {
+			bp.relay.relayDiscovery.UpdateRelayHealth(target.Address, result.Err == nil, result.Latency, result.Err)
+		}
+
+		if result.Err != nil {
+			log.Printf("⚠️  Probe to %s failed: %v", target.NetworkAddress, result.Err)
+		}
+	}
+
+	measurement := summarizeProbes(results)
+
+	bp.mu.Lock()
+	bp.results = results
+	if measurement != nil {
+		bp.last = measurement
+	}
+	bp.mu.Unlock()
+
+	if measurement == nil {
+		log.Printf("⚠️  Bandwidth self-test failed: no peer answered (%d probed)", len(targets))
+		return nil
+	}
+
+	// Advertise the measurement with the next DHT publish / heartbeat
+	bp.relay.setMeasuredCapacity(measurement)
+
+	log.Printf("📶 Self-test: %d kbps, %d ms latency (%d/%d peers)",
+		measurement.BandwidthKbps, measurement.LatencyMs, measurement.PeersProbed, len(targets))
+
+	return measurement
+}
+
+// setMeasuredCapacity advertises a self-test result in the relay's metadata
+func (rs *RelayServer) setMeasuredCapacity(measurement *SelfMeasurement) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if rs.metadata != nil {
+		rs.metadata.Latency = measurement.LatencyMs
+		rs.metadata.Bandwidth = measurement.BandwidthKbps
+	}
+}
+
+// LastMeasurement returns the most recent successful probe round (nil if none yet)
+func (bp *BandwidthProber) LastMeasurement() *SelfMeasurement {
+	bp.mu.RLock()
+	defer bp.mu.RUnlock()
+	return bp.last
+}
+
+// LastResults returns the per-peer results of the most recent probe round
+func (bp *BandwidthProber) LastResults() []*ProbeResult {
+	bp.mu.RLock()
+	defer bp.mu.RUnlock()
+
+	results := make([]*ProbeResult, len(bp.results))
+	copy(results, bp.results)
+	return results
+}
+
+// selectTargets picks up to probePeers relays to probe
+// Connected relay peers with a known network address are preferred over other known relays
+func (bp *BandwidthProber) selectTargets() []*RelayMetadata {
+	if bp.relay.relayDiscovery == nil {
+		return nil
+	}
+
+	known := bp.relay.relayDiscovery.GetKnownRelays()
+
+	bp.relay.mu.RLock()
+	connected := make([]*RelayMetadata, 0)
+	others := make([]*RelayMetadata, 0)
+	for _, meta := range known {
+		if meta.Address == bp.relay.Address || meta.NetworkAddress == "" {
+			continue
+		}
+		if peer, ok := bp.relay.peers[string(meta.Address[:])]; ok && peer.ClientType == protocol.ClientTypeRelay {
+			connected = append(connected, meta)
+		} else {
+			others = append(others, meta)
+		}
+	}
+	bp.relay.mu.RUnlock()
+
+	targets := append(connected, others...)
+	if len(targets) > bp.probePeers {
+		targets = targets[:bp.probePeers]
+	}
+	return targets
+}
+
+// ProbePeer measures round-trip latency (ping/pong) and throughput (probe/ack) to a relay
+// A fresh connection is used so the probe never interleaves with forwarded traffic
+func ProbePeer(networkAddress string) *ProbeResult {
+	result := &ProbeResult{
+		NetworkAddress: networkAddress,
+		MeasuredAt:     time.Now(),
+	}
+
+	conn, err := net.DialTimeout("tcp", networkAddress, probeTimeout)
+	if err != nil {
+		result.Err = fmt.Errorf("failed to connect: %w", err)
+		return result
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(probeTimeout))
+
+	// Latency: ping/pong round trip
+	start := time.Now()
+	if err := writeProbeHeader(conn, protocol.MsgTypePing, 0); err != nil {
+		result.Err = fmt.Errorf("failed to send ping: %w", err)
+		return result
+	}
+	if err := expectProbeReply(conn, protocol.MsgTypePong); err != nil {
+		result.Err = err
+		return result
+	}
+	result.Latency = time.Since(start)
+
+	// Throughput: push a random payload and wait for the ack
+	payload := make([]byte, probePayloadSize)
+	if _, err := rand.Read(payload); err != nil {
+		result.Err = fmt.Errorf("failed to generate probe payload: %w", err)
+		return result
+	}
+
+	start = time.Now()
+	if err := writeProbeHeader(conn, protocol.MsgTypeProbe, uint32(len(payload))); err != nil {
+		result.Err = fmt.Errorf("failed to send probe: %w", err)
+		return result
+	}
+	if _, err := conn.Write(payload); err != nil {
+		result.Err = fmt.Errorf("failed to send probe payload: %w", err)
+		return result
+	}
+	if err := expectProbeReply(conn, protocol.MsgTypeProbeAck); err != nil {
+		result.Err = err
+		return result
+	}
+
+	// Subtract the round trip so the figure reflects transfer time only
+	transfer := time.Since(start) - result.Latency
+	if transfer < time.Millisecond {
+		transfer = time.Millisecond
+	}
+	result.ThroughputKbps = int64(float64(len(payload)*8) / 1000 / transfer.Seconds())
+
+	return result
+}
+
+// PingRelay measures the ping/pong round trip to a relay without a throughput probe
+// Cheap enough for monitors that check many relays on a short interval
+func PingRelay(networkAddress string, timeout time.Duration) (time.Duration, error) {
+	conn, err := net.DialTimeout("tcp", networkAddress, timeout)
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	start := time.Now()
+	if err := writeProbeHeader(conn, protocol.MsgTypePing, 0); err != nil {
+		return 0, fmt.Errorf("failed to send ping: %w", err)
+	}
+	if err := expectProbeReply(conn, protocol.MsgTypePong); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// handleProbe consumes a bandwidth probe payload and acknowledges it
+func (rs *RelayServer) handleProbe(conn net.Conn, header *protocol.Header) error {
+	if header.Length > maxProbePayloadSize {
+		return fmt.Errorf("probe payload too large: %d bytes", header.Length)
+	}
+
+	if _, err := io.CopyN(io.Discard, conn, int64(header.Length)); err != nil {
+		return fmt.Errorf("failed to read probe payload: %w", err)
+	}
+
+	ack := &protocol.Header{
+		Magic:     protocol.ProtocolMagic,
+		Version:   protocol.ProtocolVersion,
+		Type:      protocol.MsgTypeProbeAck,
+		Length:    0,
+		Flags:     0,
+		MessageID: header.MessageID,
+	}
+
+	return protocol.WriteHeader(conn, ack)
+}
+
+// writeProbeHeader writes a header for a probe message with the given payload length
+func writeProbeHeader(conn net.Conn, msgType uint16, length uint32) error {
+	header := &protocol.Header{
+		Magic:     protocol.ProtocolMagic,
+		Version:   protocol.ProtocolVersion,
+		Type:      msgType,
+		Length:    length,
+		Flags:     0,
+		MessageID: protocol.GenerateMessageID(),
+	}
+	return protocol.WriteHeader(conn, header)
+}
+
+// expectProbeReply reads a header and checks its type
+func expectProbeReply(conn net.Conn, want uint16) error {
+	header, err := protocol.ReadHeader(conn)
+	if err != nil {
+		return fmt.Errorf("failed to read reply: %w", err)
+	}
+	if header.Type != want {
+		return fmt.Errorf("expected reply 0x%04x, got 0x%04x", want, header.Type)
+	}
+	return nil
+}
+
+// summarizeProbes reduces a probe round to median latency and throughput
+func summarizeProbes(results []*ProbeResult) *SelfMeasurement {
+	latencies := make([]int64, 0, len(results))
+	throughputs := make([]int64, 0, len(results))
+
+	for _, r := range results {
+		if r.Err != nil {
+			continue
+		}
+		latencies = append(latencies, r.Latency.Milliseconds())
+		throughputs = append(throughputs, r.ThroughputKbps)
+	}
+
+	if len(latencies) == 0 {
+		return nil
+	}
+
+	return &SelfMeasurement{
+		LatencyMs:     median(latencies),
+		BandwidthKbps: median(throughputs),
+		PeersProbed:   len(latencies),
+		MeasuredAt:    time.Now(),
+	}
+}
+
+// median returns the median of values (sorts in place)
+func median(values []int64) int64 {
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
@@ -0,0 +1,137 @@
+package network
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// serveProbes answers pings and throughput probes the way a relay does
+func serveProbes(t *testing.T, rs *RelayServer) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				for {
+					header, err := protocol.ReadHeader(conn)
+					if err != nil {
+						return
+					}
+					switch header.Type {
+					case protocol.MsgTypePing:
+						err = writeProbeHeader(conn, protocol.MsgTypePong, 0)
+					case protocol.MsgTypeProbe:
+						err = rs.handleProbe(conn, header)
+					}
+					if err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func newProbeTestRelay(t *testing.T) *RelayServer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewRelayServer(0, key)
+}
+
+func TestProbePeer(t *testing.T) {
+	address := serveProbes(t, newProbeTestRelay(t))
+
+	result := ProbePeer(address)
+	if result.Err != nil {
+		t.Fatalf("ProbePeer() error = %v", result.Err)
+	}
+	if result.Latency <= 0 || result.ThroughputKbps <= 0 {
+		t.Errorf("ProbePeer() latency = %v, throughput = %d kbps", result.Latency, result.ThroughputKbps)
+	}
+
+	if _, err := PingRelay(address, time.Second); err != nil {
+		t.Errorf("PingRelay() error = %v", err)
+	}
+}
+
+func TestSummarizeProbes(t *testing.T) {
+	failed := errors.New("connection refused")
+	results := []*ProbeResult{
+		{Latency: 30 * time.Millisecond, ThroughputKbps: 900},
+		{Latency: 10 * time.Millisecond, ThroughputKbps: 100},
+		{Err: failed},
+		{Latency: 20 * time.Millisecond, ThroughputKbps: 500},
+	}
+
+	got := summarizeProbes(results)
+	if got == nil || got.LatencyMs != 20 || got.BandwidthKbps != 500 || got.PeersProbed != 3 {
+		t.Errorf("summarizeProbes() = %+v, want 20 ms, 500 kbps from 3 peers", got)
+	}
+
+	if got := summarizeProbes([]*ProbeResult{{Err: failed}}); got != nil {
+		t.Errorf("summarizeProbes() with no answers = %+v, want nil", got)
+	}
+}
+
+func TestProbeRoundAdvertisesCapacity(t *testing.T) {
+	rs := newProbeTestRelay(t)
+	peer := protocol.Address{9}
+	rs.relayDiscovery = NewRelayDiscovery(nil)
+	rs.relayDiscovery.knownRelays[peer] = &RelayMetadata{Address: peer, NetworkAddress: serveProbes(t, newProbeTestRelay(t))}
+	rs.metadata = &RelayMetadata{Address: rs.Address}
+
+	// Metadata is read for publishing while the round updates it
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				rs.GetMetadata()
+			}
+		}
+	}()
+
+	prober := NewBandwidthProber(rs, 1, time.Hour)
+	measurement := prober.RunProbeRound()
+	close(stop)
+	wg.Wait()
+
+	if measurement == nil {
+		t.Fatal("RunProbeRound() = nil, want a measurement")
+	}
+	if prober.LastMeasurement() != measurement || len(prober.LastResults()) != 1 {
+		t.Errorf("LastMeasurement() = %+v, LastResults() = %d", prober.LastMeasurement(), len(prober.LastResults()))
+	}
+	metadata := rs.GetMetadata()
+	if metadata.Latency != measurement.LatencyMs || metadata.Bandwidth != measurement.BandwidthKbps {
+		t.Errorf("advertised %d ms, %d kbps; want %d ms, %d kbps",
+			metadata.Latency, metadata.Bandwidth, measurement.LatencyMs, measurement.BandwidthKbps)
+	}
+}
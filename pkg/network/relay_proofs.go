@@ -0,0 +1,72 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+	"github.com/ZentaChain/zentalk-node/pkg/storage"
+)
+
+// AttachDeliveryProofs keeps a signed proof of every ack a connected recipient sends
+func (rs *RelayServer) AttachDeliveryProofs(store *storage.DeliveryProofStore) {
+	rs.deliveryProofs = store
+	log.Println("🧾 Delivery proofs attached to relay server")
+}
+
+// recordDeliveryProof stores a proof for a user's ack
+// Acks claiming to come from someone else, or not signed with the key the
+// user connected with, are not the user's to prove; a message's ack is only
+// proven once.
+func (rs *RelayServer) recordDeliveryProof(peer *Peer, header *protocol.Header, payload []byte) {
+	if rs.deliveryProofs == nil {
+		return
+	}
+
+	var ack protocol.AckMessage
+	if err := protocol.DecodePayload(payload, header.Flags, &ack); err != nil {
+		log.Printf("Decode ack from %x: %v", peer.Address[:8], err)
+		return
+	}
+	if ack.From != peer.Address {
+		log.Printf("⚠️  Ack from %x claims to be from %x, not recording a proof", peer.Address[:8], ack.From[:8])
+		return
+	}
+
+	_, err := rs.deliveryProofs.Record(peer.Address, peer.PublicKey, &ack)
+	switch {
+	case errors.Is(err, storage.ErrDuplicateDeliveryProof):
+		// A retransmitted message acknowledged again
+	case errors.Is(err, storage.ErrInvalidDeliveryProof):
+		log.Printf("⚠️  Ack from %x is not signed by its key, not recording a proof", peer.Address[:8])
+	case err != nil:
+		log.Printf("Failed to record delivery proof: %v", err)
+	}
+}
+
+// ExportDeliveryProofsToFile writes the proofs in [since, until) as a JSON DeliveryProofExport
+// Timestamps are Unix ms; until = 0 means no upper bound. Anyone holding the
+// file can check it with DeliveryProofExport.Verify.
+func (rs *RelayServer) ExportDeliveryProofsToFile(path string, since, until int64) (int, error) {
+	if rs.deliveryProofs == nil {
+		return 0, fmt.Errorf("no delivery proof store attached")
+	}
+
+	export, err := rs.deliveryProofs.Export(since, until)
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode proof export: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return 0, fmt.Errorf("failed to write proof export: %w", err)
+	}
+
+	return len(export.Proofs), nil
+}
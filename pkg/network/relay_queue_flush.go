@@ -0,0 +1,167 @@
+package network
+
+import (
+	"io"
+	"log"
+	"net"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// QueueFlushGrantTimeout is how long a flush waits for a FlagQueueFlow client to open its window
+// A client that doesn't answer keeps the rest of its queue for the next connection.
+const QueueFlushGrantTimeout = 30 * time.Second
+
+// queueFlush is one flush of a user's offline queue to one of their connections
+type queueFlush struct {
+	peer    *Peer
+	grants  chan protocol.QueueWindow // Latest window granted by the client
+	stop    chan struct{}             // Closed when the connection ends or a newer one takes over
+	stopped bool                      // stop is closed (guarded by flushMu)
+	done    chan struct{}             // Closed once the flush has returned
+}
+
+// beginQueueFlush registers a flush of peer's queue
+// Returns nil if one is already running for this connection. A flush to an
+// older connection is stopped and waited for first, so two never interleave
+// and the new one starts from whatever the old one left queued.
+func (rs *RelayServer) beginQueueFlush(peer *Peer) *queueFlush {
+	flush := &queueFlush{
+		peer:   peer,
+		grants: make(chan protocol.QueueWindow, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	rs.flushMu.Lock()
+	prev := rs.queueFlushes[peer.Address]
+	if prev != nil && prev.peer == peer {
+		rs.flushMu.Unlock()
+		return nil
+	}
+	if rs.queueFlushes == nil {
+		rs.queueFlushes = make(map[protocol.Address]*queueFlush)
+	}
+	rs.queueFlushes[peer.Address] = flush
+	if prev != nil {
+		prev.stopLocked()
+	}
+	rs.flushMu.Unlock()
+
+	if prev != nil {
+		<-prev.done
+	}
+	return flush
+}
+
+// endQueueFlush unregisters a finished flush
+func (rs *RelayServer) endQueueFlush(flush *queueFlush) {
+	rs.flushMu.Lock()
+	if rs.queueFlushes[flush.peer.Address] == flush {
+		delete(rs.queueFlushes, flush.peer.Address)
+	}
+	rs.flushMu.Unlock()
+	close(flush.done)
+}
+
+// stopQueueFlush stops a flush to a connection that has ended
+func (rs *RelayServer) stopQueueFlush(peer *Peer) {
+	rs.flushMu.Lock()
+	defer rs.flushMu.Unlock()
+	if flush := rs.queueFlushes[peer.Address]; flush != nil && flush.peer == peer {
+		flush.stopLocked()
+	}
+}
+
+// stopLocked closes stop once (caller holds flushMu)
+func (f *queueFlush) stopLocked() {
+	if !f.stopped {
+		f.stopped = true
+		close(f.stop)
+	}
+}
+
+// stopping reports whether the flush should give up
+func (f *queueFlush) stopping() bool {
+	select {
+	case <-f.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// awaitQueueWindow reports progress and waits for the client to grant another window
+// Returns false if the flush should stop instead.
+func (rs *RelayServer) awaitQueueWindow(flush *queueFlush, progress *protocol.QueueProgress) (protocol.QueueWindow, bool) {
+	if err := rs.sendQueueProgress(flush.peer, progress); err != nil {
+		log.Printf("Failed to send queue progress: %v", err)
+		return protocol.QueueWindow{}, false
+	}
+
+	timer := time.NewTimer(QueueFlushGrantTimeout)
+	defer timer.Stop()
+
+	select {
+	case grant := <-flush.grants:
+		return grant, true
+	case <-flush.stop:
+		return protocol.QueueWindow{}, false
+	case <-timer.C:
+		log.Printf("⚠️  %x opened no queue window in %s, leaving %d queued", flush.peer.Address[:8], QueueFlushGrantTimeout, progress.Remaining)
+		return protocol.QueueWindow{}, false
+	}
+}
+
+// handleQueueWindow passes a client's window grant to the flush of its queue
+func (rs *RelayServer) handleQueueWindow(conn net.Conn, header *protocol.Header, peer *Peer) error {
+	payload := make([]byte, header.Length)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return err
+	}
+	if peer == nil || peer.ClientType != protocol.ClientTypeUser {
+		return nil
+	}
+
+	var grant protocol.QueueWindow
+	if err := protocol.DecodePayload(payload, header.Flags, &grant); err != nil {
+		log.Printf("Decode queue window from %x: %v", peer.Address[:8], err)
+		return nil
+	}
+
+	rs.flushMu.Lock()
+	flush := rs.queueFlushes[peer.Address]
+	rs.flushMu.Unlock()
+	if flush == nil || flush.peer != peer {
+		return nil // The flush is over
+	}
+
+	// Only the latest grant matters; this connection's read loop is the only sender
+	select {
+	case <-flush.grants:
+	default:
+	}
+	flush.grants <- grant
+	return nil
+}
+
+// sendQueueProgress sends a flush progress report to a user
+func (rs *RelayServer) sendQueueProgress(peer *Peer, progress *protocol.QueueProgress) error {
+	payload := progress.Encode()
+
+	header := &protocol.Header{
+		Magic:     protocol.ProtocolMagic,
+		Version:   protocol.ProtocolVersion,
+		Type:      protocol.MsgTypeQueueProgress,
+		Length:    uint32(len(payload)),
+		Flags:     0,
+		MessageID: protocol.GenerateMessageID(),
+	}
+
+	if err := protocol.WriteHeader(peer.Conn, header); err != nil {
+		return err
+	}
+	_, err := peer.Conn.Write(payload)
+	return err
+}
@@ -0,0 +1,177 @@
+package network
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+	"github.com/ZentaChain/zentalk-node/pkg/storage"
+)
+
+// newTestQueueRelay creates a relay holding count queued messages for addr
+func newTestQueueRelay(t *testing.T, addr protocol.Address, count int) *RelayServer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := NewRelayServer(0, key)
+
+	queue, err := storage.NewRelayMessageQueue(filepath.Join(t.TempDir(), "queue.db"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { queue.Close() })
+	rs.AttachMessageQueue(queue)
+
+	for i := 0; i < count; i++ {
+		if err := queue.QueueMessage(addr, protocol.GenerateMessageID(), fmt.Appendf(nil, "msg-%02d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rs
+}
+
+// connectQueuePeer registers a user connection that takes its queue in windows
+func connectQueuePeer(rs *RelayServer, addr protocol.Address) (*Peer, net.Conn) {
+	relaySide, userSide := net.Pipe()
+	peer := &Peer{
+		Conn:       relaySide,
+		Address:    addr,
+		ClientType: protocol.ClientTypeUser,
+		Limits:     rs.GetPayloadLimits(),
+		QueueFlow:  true,
+	}
+	rs.registerPeer(peer)
+	return peer, userSide
+}
+
+// readQueued reads n queued messages, checking they are msg-from, msg-from+1, ...
+// Returns the queue sequence of the last one.
+func readQueued(t *testing.T, conn net.Conn, from, n int) uint64 {
+	t.Helper()
+
+	var seq uint64
+	for i := from; i < from+n; i++ {
+		header, err := protocol.ReadHeader(conn)
+		if err != nil {
+			t.Fatalf("reading message %d: %v", i, err)
+		}
+		payload := make([]byte, header.Length)
+		if _, err := io.ReadFull(conn, payload); err != nil {
+			t.Fatal(err)
+		}
+		if header.Type != protocol.MsgTypeDirectMessage || !header.HasFlag(protocol.FlagQueued) {
+			t.Fatalf("frame %d is type 0x%04x flags %#x, want a queued direct message", i, header.Type, header.Flags)
+		}
+		if want := fmt.Sprintf("msg-%02d", i); string(payload) != want {
+			t.Fatalf("got %q, want %q", payload, want)
+		}
+		seq = header.QueueSeq()
+	}
+	return seq
+}
+
+// readProgress reads a queue progress report
+func readProgress(t *testing.T, conn net.Conn) *protocol.QueueProgress {
+	t.Helper()
+
+	header, err := protocol.ReadHeader(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := make([]byte, header.Length)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		t.Fatal(err)
+	}
+	if header.Type != protocol.MsgTypeQueueProgress {
+		t.Fatalf("frame is type 0x%04x, want QueueProgress", header.Type)
+	}
+	var progress protocol.QueueProgress
+	if err := progress.Decode(payload); err != nil {
+		t.Fatal(err)
+	}
+	return &progress
+}
+
+// grantQueueWindow passes a window grant from peer to the relay
+func grantQueueWindow(t *testing.T, rs *RelayServer, peer *Peer, grant *protocol.QueueWindow) {
+	t.Helper()
+
+	payload := grant.Encode()
+	header := &protocol.Header{Type: protocol.MsgTypeQueueWindow, Length: uint32(len(payload))}
+	relaySide, userSide := net.Pipe()
+	defer relaySide.Close()
+	go userSide.Write(payload)
+	if err := rs.handleQueueWindow(relaySide, header, peer); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQueueFlushWindows(t *testing.T) {
+	addr := protocol.Address{9}
+	rs := newTestQueueRelay(t, addr, protocol.DefaultQueueWindow+8)
+	peer, conn := connectQueuePeer(rs, addr)
+	defer conn.Close()
+
+	done := make(chan struct{})
+	go func() {
+		rs.deliverQueuedMessages(addr)
+		close(done)
+	}()
+
+	// The first window, then a report and a pause until the client asks for more
+	cursor := readQueued(t, conn, 0, protocol.DefaultQueueWindow)
+	progress := readProgress(t, conn)
+	if progress.Delivered != protocol.DefaultQueueWindow || progress.Remaining != 8 || progress.Cursor != cursor {
+		t.Fatalf("progress = %+v, want %d delivered, 8 remaining, cursor %d", progress, protocol.DefaultQueueWindow, cursor)
+	}
+
+	grantQueueWindow(t, rs, peer, &protocol.QueueWindow{Cursor: cursor, Window: 4})
+	cursor = readQueued(t, conn, protocol.DefaultQueueWindow, 4)
+	if progress := readProgress(t, conn); progress.Remaining != 4 {
+		t.Fatalf("progress after a window of 4 = %+v, want 4 remaining", progress)
+	}
+
+	grantQueueWindow(t, rs, peer, &protocol.QueueWindow{Cursor: cursor, Window: 100})
+	readQueued(t, conn, protocol.DefaultQueueWindow+4, 4)
+	if progress := readProgress(t, conn); progress.Remaining != 0 || progress.Delivered != protocol.DefaultQueueWindow+8 {
+		t.Fatalf("final progress = %+v, want everything delivered", progress)
+	}
+	<-done
+
+	if count, _ := rs.messageQueue.GetQueuedMessageCount(addr); count != 0 {
+		t.Errorf("%d messages still queued", count)
+	}
+}
+
+func TestQueueFlushResumesAfterDrop(t *testing.T) {
+	addr := protocol.Address{9}
+	rs := newTestQueueRelay(t, addr, protocol.DefaultQueueWindow+8)
+	first, conn := connectQueuePeer(rs, addr)
+	go rs.deliverQueuedMessages(addr)
+
+	readQueued(t, conn, 0, protocol.DefaultQueueWindow)
+	readProgress(t, conn)
+
+	// The client drops while the relay waits for the next window
+	conn.Close()
+	rs.unregisterPeer(first)
+
+	_, conn = connectQueuePeer(rs, addr)
+	defer conn.Close()
+	go rs.deliverQueuedMessages(addr)
+
+	// The new connection gets the rest, without repeats
+	readQueued(t, conn, protocol.DefaultQueueWindow, 8)
+	if progress := readProgress(t, conn); progress.Remaining != 0 || progress.Delivered != 8 {
+		t.Fatalf("progress = %+v, want 8 delivered and none remaining", progress)
+	}
+}
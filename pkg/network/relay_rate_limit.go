@@ -0,0 +1,179 @@
+package network
+
+import (
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// sourceBucketIdle is how long an unused per-address bucket is kept
+const sourceBucketIdle = 10 * time.Minute
+
+// ForwardRateLimits bounds how fast clients may send RelayForward messages
+// Every connection draws from its own token bucket and from one shared by all
+// connections from the same IP address, so reconnecting or opening many
+// connections doesn't raise a flooder's limit. Forwards over the limit are
+// refused with RelayErrRateLimited before their onion layer is decrypted.
+// Relay peers are not limited; their traffic is shaped by EnableMeshShaping.
+type ForwardRateLimits struct {
+	ConnectionRate  int // Messages per second on one connection (0 = unlimited)
+	ConnectionBurst int // Messages a connection may send at once (0 = one second of ConnectionRate)
+	SourceRate      int // Messages per second from one IP address (0 = unlimited)
+	SourceBurst     int // Messages an IP address may send at once (0 = one second of SourceRate)
+}
+
+// DefaultForwardRateLimits returns limits well above what people type or bots post
+func DefaultForwardRateLimits() ForwardRateLimits {
+	return ForwardRateLimits{
+		ConnectionRate:  20,
+		ConnectionBurst: 50,
+		SourceRate:      50,
+		SourceBurst:     100,
+	}
+}
+
+// ForwardRateStats counts forwards refused by rate limiting
+type ForwardRateStats struct {
+	RefusedConnection uint64 // Over a connection's limit
+	RefusedSource     uint64 // Over an IP address's limit
+	Sources           int    // IP addresses currently tracked
+}
+
+// forwardLimiter holds the per-connection settings and per-source buckets
+type forwardLimiter struct {
+	limits  ForwardRateLimits
+	sources map[string]*tokenBucket
+	stats   ForwardRateStats
+	mu      sync.Mutex
+}
+
+// newConnectionBucket returns a bucket for a new connection (nil = unlimited)
+func (l *forwardLimiter) newConnectionBucket(now time.Time) *tokenBucket {
+	return newTokenBucket(l.limits.ConnectionRate, l.limits.ConnectionBurst, now)
+}
+
+// allow takes a token from the connection's and the source's bucket
+// Returns how long to wait before retrying if either is empty; nothing is taken then.
+func (l *forwardLimiter) allow(conn *tokenBucket, source string, now time.Time) (retryAfter time.Duration, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if wait := conn.wait(1, now); wait > 0 {
+		l.stats.RefusedConnection++
+		return wait, false
+	}
+
+	var bucket *tokenBucket
+	if l.limits.SourceRate > 0 {
+		var exists bool
+		if bucket, exists = l.sources[source]; !exists {
+			l.pruneLocked(now)
+			bucket = newTokenBucket(l.limits.SourceRate, l.limits.SourceBurst, now)
+			l.sources[source] = bucket
+		}
+		if wait := bucket.wait(1, now); wait > 0 {
+			l.stats.RefusedSource++
+			return wait, false
+		}
+	}
+
+	conn.take(1)
+	bucket.take(1)
+	return 0, true
+}
+
+// pruneLocked forgets source buckets that have been idle (and so full) for a while
+func (l *forwardLimiter) pruneLocked(now time.Time) {
+	for source, bucket := range l.sources {
+		if now.Sub(bucket.last) > sourceBucketIdle {
+			delete(l.sources, source)
+		}
+	}
+}
+
+// snapshot returns a copy of the stats
+func (l *forwardLimiter) snapshot() ForwardRateStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := l.stats
+	stats.Sources = len(l.sources)
+	return stats
+}
+
+// EnableForwardRateLimits limits how fast each connection and IP address may send forwards
+// Applies to connections accepted afterwards.
+func (rs *RelayServer) EnableForwardRateLimits(limits ForwardRateLimits) {
+	limiter := &forwardLimiter{
+		limits:  limits,
+		sources: make(map[string]*tokenBucket),
+	}
+
+	rs.mu.Lock()
+	rs.forwardLimiter = limiter
+	rs.mu.Unlock()
+
+	log.Printf("🚦 Forward rate limits: %d/s per connection (burst %d), %d/s per address (burst %d)",
+		limits.ConnectionRate, limits.ConnectionBurst, limits.SourceRate, limits.SourceBurst)
+}
+
+// GetForwardRateStats returns counts of rate-limited forwards (zero if limiting is off)
+func (rs *RelayServer) GetForwardRateStats() ForwardRateStats {
+	limiter := rs.getForwardLimiter()
+	if limiter == nil {
+		return ForwardRateStats{}
+	}
+	return limiter.snapshot()
+}
+
+// getForwardLimiter returns the forward limiter (nil = disabled)
+func (rs *RelayServer) getForwardLimiter() *forwardLimiter {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.forwardLimiter
+}
+
+// forwardRate is one connection's share of the forward rate limits
+type forwardRate struct {
+	limiter *forwardLimiter
+	bucket  *tokenBucket
+	source  string // Remote IP address
+}
+
+// newForwardRate sets up rate limiting for an accepted connection (nil = unlimited)
+func (rs *RelayServer) newForwardRate(conn net.Conn) *forwardRate {
+	limiter := rs.getForwardLimiter()
+	if limiter == nil {
+		return nil
+	}
+
+	source := conn.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(source); err == nil {
+		source = host
+	}
+	return &forwardRate{
+		limiter: limiter,
+		bucket:  limiter.newConnectionBucket(time.Now()),
+		source:  source,
+	}
+}
+
+// allowForward counts a forward against the connection's limits
+// from is the peer the connection handshook as; relay peers are never limited.
+func (r *forwardRate) allowForward(from *Peer) *protocol.RelayErrorMessage {
+	if r == nil || (from != nil && from.ClientType == protocol.ClientTypeRelay) {
+		return nil
+	}
+
+	retryAfter, ok := r.limiter.allow(r.bucket, r.source, time.Now())
+	if ok {
+		return nil
+	}
+
+	log.Printf("🚦 Refusing forwards from %s: rate limit reached", r.source)
+	relayErr := protocol.NewRelayError(protocol.RelayErrRateLimited, "too many messages; slow down")
+	relayErr.RetryAfter = retryAfter
+	return relayErr
+}
@@ -0,0 +1,82 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+func TestForwardLimiter(t *testing.T) {
+	limiter := &forwardLimiter{
+		limits:  ForwardRateLimits{ConnectionRate: 1, ConnectionBurst: 2, SourceRate: 1, SourceBurst: 3},
+		sources: make(map[string]*tokenBucket),
+	}
+	now := time.Now()
+	first, second := limiter.newConnectionBucket(now), limiter.newConnectionBucket(now)
+
+	// One connection runs out of its own burst first
+	for i := 0; i < 2; i++ {
+		if _, ok := limiter.allow(first, "10.0.0.1", now); !ok {
+			t.Fatalf("forward %d refused within the connection burst", i+1)
+		}
+	}
+	if wait, ok := limiter.allow(first, "10.0.0.1", now); ok || wait <= 0 {
+		t.Fatalf("allow() = %v, %v past the connection burst", wait, ok)
+	}
+
+	// A second connection from the same address shares what is left of the source burst
+	if _, ok := limiter.allow(second, "10.0.0.1", now); !ok {
+		t.Fatal("second connection refused within the source burst")
+	}
+	if _, ok := limiter.allow(second, "10.0.0.1", now); ok {
+		t.Fatal("second connection allowed past the source burst")
+	}
+	if _, ok := limiter.allow(limiter.newConnectionBucket(now), "10.0.0.2", now); !ok {
+		t.Fatal("another address refused")
+	}
+
+	// Buckets refill with time
+	if _, ok := limiter.allow(first, "10.0.0.1", now.Add(time.Second)); !ok {
+		t.Error("forward refused after the buckets refilled")
+	}
+
+	stats := limiter.snapshot()
+	if stats.RefusedConnection != 1 || stats.RefusedSource != 1 || stats.Sources != 2 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	// Idle sources are forgotten when a new one arrives
+	limiter.allow(limiter.newConnectionBucket(now), "10.0.0.3", now.Add(sourceBucketIdle+2*time.Second))
+	if sources := limiter.snapshot().Sources; sources != 1 {
+		t.Errorf("tracking %d sources after the others went idle, want 1", sources)
+	}
+}
+
+func TestForwardRateExemptsRelays(t *testing.T) {
+	limiter := &forwardLimiter{
+		limits:  ForwardRateLimits{ConnectionRate: 1, ConnectionBurst: 1},
+		sources: make(map[string]*tokenBucket),
+	}
+	rate := &forwardRate{limiter: limiter, bucket: limiter.newConnectionBucket(time.Now()), source: "10.0.0.1"}
+
+	relay := &Peer{ClientType: protocol.ClientTypeRelay}
+	for i := 0; i < 5; i++ {
+		if err := rate.allowForward(relay); err != nil {
+			t.Fatalf("relay peer limited: %v", err)
+		}
+	}
+
+	if err := rate.allowForward(nil); err != nil {
+		t.Fatalf("first forward refused: %v", err)
+	}
+	err := rate.allowForward(nil)
+	if err == nil || err.Code != protocol.RelayErrRateLimited || err.RetryAfter <= 0 {
+		t.Errorf("allowForward() = %+v, want RelayErrRateLimited with a retry delay", err)
+	}
+
+	var disabled *forwardRate
+	if err := disabled.allowForward(nil); err != nil {
+		t.Errorf("disabled limits refused a forward: %v", err)
+	}
+}
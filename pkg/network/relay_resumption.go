@@ -0,0 +1,431 @@
+package network
+
+import (
+	"crypto/rsa"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+const (
+	// DefaultResumeTicketLifetime is how long after a disconnect a user may resume their session
+	DefaultResumeTicketLifetime = 5 * time.Minute
+
+	// resumeReplayWindow is how many delivered queued messages a session keeps for replay
+	resumeReplayWindow = 64
+)
+
+// queuedDelivery is a queued message written to a user but not yet confirmed by a resume cursor
+type queuedDelivery struct {
+	seq     uint64
+	payload []byte
+}
+
+// resumableSession is what a ticket restores without a new handshake
+// Queued messages are deleted from the queue once written, so the last few are
+// kept here: writes to a socket that died with a network switch never arrive,
+// and the client's cursor on resume tells us which ones to send again.
+type resumableSession struct {
+	ticket    protocol.Ticket
+	address   protocol.Address
+	publicKey *rsa.PublicKey
+	limits    *protocol.PayloadLimits // Negotiated in the handshake
+	records   *UniformRecordConfig    // Uniform records agreed in the handshake (nil = plain)
+	tenant    string                  // Tenant named in the handshake
+	bot       bool                    // Authenticated as a bot in the handshake
+	peer      *Peer                   // Connection currently using the session (nil = detached)
+
+	expiresAt time.Time // Zero while connected
+	inFlight  []queuedDelivery
+}
+
+// resumptionStore holds one resumable session per user, keyed by its current ticket
+type resumptionStore struct {
+	lifetime time.Duration
+	sessions map[protocol.Ticket]*resumableSession
+	byUser   map[protocol.Address]*resumableSession
+	mu       sync.Mutex
+}
+
+// newResumptionStore creates an empty store issuing tickets valid for lifetime after a disconnect
+func newResumptionStore(lifetime time.Duration) *resumptionStore {
+	return &resumptionStore{
+		lifetime: lifetime,
+		sessions: make(map[protocol.Ticket]*resumableSession),
+		byUser:   make(map[protocol.Address]*resumableSession),
+	}
+}
+
+// issue starts a session for a freshly handshaken user, replacing any previous one
+func (s *resumptionStore) issue(peer *Peer, records *UniformRecordConfig) (*protocol.SessionTicket, error) {
+	ticket, err := protocol.NewTicket()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expireLocked(time.Now())
+	if old := s.byUser[peer.Address]; old != nil {
+		delete(s.sessions, old.ticket)
+	}
+
+	session := &resumableSession{
+		ticket:    ticket,
+		address:   peer.Address,
+		publicKey: peer.PublicKey,
+		limits:    peer.Limits,
+		records:   records,
+		tenant:    peer.Tenant,
+		bot:       peer.Bot,
+		peer:      peer,
+	}
+	s.sessions[ticket] = session
+	s.byUser[peer.Address] = session
+	return s.ticketLocked(session), nil
+}
+
+// redeem consumes a ticket, returning its session with a fresh ticket (nil if unknown or expired)
+// Sessions whose old connection is still registered can be redeemed: after a
+// network switch the relay often hasn't noticed the old socket is dead yet.
+func (s *resumptionStore) redeem(ticket protocol.Ticket) (*resumableSession, *protocol.SessionTicket, error) {
+	next, err := protocol.NewTicket()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expireLocked(time.Now())
+	session := s.sessions[ticket]
+	if session == nil {
+		return nil, nil, nil
+	}
+
+	delete(s.sessions, ticket)
+	session.ticket = next
+	session.expiresAt = time.Time{}
+	s.sessions[next] = session
+	return session, s.ticketLocked(session), nil
+}
+
+// attach hands a redeemed session to its new connection
+func (s *resumptionStore) attach(session *resumableSession, peer *Peer) {
+	s.mu.Lock()
+	session.peer = peer
+	s.mu.Unlock()
+}
+
+// detach starts the ticket's lifetime when the session's connection closes
+func (s *resumptionStore) detach(peer *Peer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if session := s.byUser[peer.Address]; session != nil && session.peer == peer {
+		session.peer = nil
+		session.expiresAt = time.Now().Add(s.lifetime)
+	}
+}
+
+// delivered remembers a queued message written to the user's current connection
+func (s *resumptionStore) delivered(addr protocol.Address, seq uint64, payload []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session := s.byUser[addr]
+	if session == nil {
+		return
+	}
+	session.inFlight = append(session.inFlight, queuedDelivery{seq: seq, payload: payload})
+	if n := len(session.inFlight) - resumeReplayWindow; n > 0 {
+		session.inFlight = session.inFlight[n:]
+	}
+}
+
+// unconfirmed drops deliveries the client confirmed and returns the rest for replay
+func (s *resumptionStore) unconfirmed(session *resumableSession, cursor uint64) []queuedDelivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := session.inFlight[:0]
+	for _, d := range session.inFlight {
+		if d.seq > cursor {
+			kept = append(kept, d)
+		}
+	}
+	session.inFlight = kept
+	return append([]queuedDelivery(nil), kept...)
+}
+
+// ticketLocked returns the wire form of the session's current ticket
+func (s *resumptionStore) ticketLocked(session *resumableSession) *protocol.SessionTicket {
+	return &protocol.SessionTicket{Ticket: session.ticket, Lifetime: uint32(s.lifetime / time.Second)}
+}
+
+// expireLocked forgets sessions whose ticket lifetime has run out
+func (s *resumptionStore) expireLocked(now time.Time) {
+	for ticket, session := range s.sessions {
+		if !session.expiresAt.IsZero() && now.After(session.expiresAt) {
+			delete(s.sessions, ticket)
+			if s.byUser[session.address] == session {
+				delete(s.byUser, session.address)
+			}
+		}
+	}
+}
+
+// SetResumeTicketLifetime sets how long after a disconnect users may resume (0 disables tickets)
+// Applies to tickets issued afterwards; outstanding tickets are dropped.
+func (rs *RelayServer) SetResumeTicketLifetime(lifetime time.Duration) {
+	rs.mu.Lock()
+	rs.resumption = nil
+	if lifetime > 0 {
+		rs.resumption = newResumptionStore(lifetime)
+	}
+	rs.mu.Unlock()
+
+	if lifetime > 0 {
+		log.Printf("🎫 Session resumption enabled (tickets valid %s after disconnect)", lifetime)
+	} else {
+		log.Println("🎫 Session resumption disabled")
+	}
+}
+
+// getResumption returns the ticket store (nil = resumption disabled)
+func (rs *RelayServer) getResumption() *resumptionStore {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.resumption
+}
+
+// issueTicket starts a resumable session for a user who asked for one and sends its ticket
+func (rs *RelayServer) issueTicket(peer *Peer, records *UniformRecordConfig) {
+	store := rs.getResumption()
+	if store == nil {
+		return
+	}
+
+	ticket, err := store.issue(peer, records)
+	if err != nil {
+		log.Printf("Failed to issue session ticket: %v", err)
+		return
+	}
+	if err := rs.sendTicket(peer.Conn, ticket); err != nil {
+		log.Printf("Failed to send session ticket: %v", err)
+	}
+}
+
+// handleResume restores a user's session from a ticket
+// A refused ticket leaves the connection half-open, so the client can follow up
+// with a full handshake on the same socket.
+func (rs *RelayServer) handleResume(conn net.Conn, header *protocol.Header) *Peer {
+	payload := make([]byte, header.Length)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		log.Printf("Read payload error: %v", err)
+		return nil
+	}
+
+	var req protocol.ResumeRequest
+	if err := protocol.DecodePayload(payload, header.Flags, &req); err != nil {
+		log.Printf("Decode resume request error: %v", err)
+		rs.sendResumeAck(conn, protocol.ResumeRejected, 0)
+		return nil
+	}
+
+	// The session may have been set up over TLS; it isn't resumed in the clear
+	if rs.requiresTLS(conn) {
+		log.Printf("🎫 Refused resume from %s: TLS required", conn.RemoteAddr())
+		rs.sendResumeAck(conn, protocol.ResumeRejected, 0)
+		return nil
+	}
+
+	var session *resumableSession
+	var ticket *protocol.SessionTicket
+	store := rs.getResumption()
+	if store != nil {
+		var err error
+		if session, ticket, err = store.redeem(req.Ticket); err != nil {
+			log.Printf("Failed to redeem session ticket: %v", err)
+		}
+	}
+	if session == nil {
+		log.Printf("🎫 Refused resume from %s: unknown or expired ticket", conn.RemoteAddr())
+		rs.sendResumeAck(conn, protocol.ResumeRejected, 0)
+		return nil
+	}
+
+	// The tenant may have been removed, or the user dropped from its allowlist
+	if tenants := rs.getTenants(); tenants != nil {
+		if relayErr := tenants.admit(session.tenant, session.address); relayErr != nil {
+			log.Printf("🎫 Refused resume from %x: %v", session.address[:8], relayErr)
+			rs.sendResumeAck(conn, protocol.ResumeRejected, 0)
+			return nil
+		}
+	}
+
+	// A bot unregistered since its handshake must not come back on its ticket
+	if session.bot && !rs.getBots().registered(session.address) {
+		log.Printf("🎫 Refused resume from %x: bot no longer registered", session.address[:8])
+		rs.sendResumeAck(conn, protocol.ResumeRejected, 0)
+		return nil
+	}
+
+	// The session keeps the wire mode it was handshaken with
+	if err := rs.sendResumeAck(conn, protocol.ResumeAccepted, recordFlag(session.records)); err != nil {
+		log.Printf("Failed to send resume ack: %v", err)
+		return nil
+	}
+	if session.records != nil {
+		conn = newUniformConn(conn, *session.records)
+	}
+
+	peer := &Peer{
+		Conn:        conn,
+		Address:     session.address,
+		PublicKey:   session.publicKey,
+		ClientType:  protocol.ClientTypeUser,
+		LastSeen:    time.Now(),
+		Limits:      session.limits,
+		Tenant:      session.tenant,
+		Bot:         session.bot,
+		QueueFlow:   header.HasFlag(protocol.FlagQueueFlow),
+		OfflineSync: header.HasFlag(protocol.FlagOfflineSync),
+	}
+
+	store.attach(session, peer)
+	rs.registerPeer(peer)
+	log.Printf("🎫 Session resumed for %x (cursor %d)", session.address[:8], req.Cursor)
+
+	if err := rs.sendTicket(conn, ticket); err != nil {
+		log.Printf("Failed to send session ticket: %v", err)
+	}
+
+	if target := rs.GetMigrationTarget(); target != nil {
+		if err := rs.sendRelayMoved(conn, session.address, target); err != nil {
+			log.Printf("Failed to send relay moved notice: %v", err)
+		}
+		return peer
+	}
+	rs.redirectToOwner(conn, session.address)
+
+	// Queued messages the client never saw go first, then whatever is still queued
+	replay := store.unconfirmed(session, req.Cursor)
+	go func() {
+		rs.replayQueued(peer, replay)
+		if rs.messageQueue != nil {
+			rs.deliverQueuedMessages(session.address)
+		}
+	}()
+
+	return peer
+}
+
+// replayQueued resends queued messages lost with the previous connection
+func (rs *RelayServer) replayQueued(peer *Peer, deliveries []queuedDelivery) {
+	if len(deliveries) == 0 {
+		return
+	}
+
+	sent := 0
+	for _, d := range deliveries {
+		header := &protocol.Header{
+			Magic:     protocol.ProtocolMagic,
+			Version:   protocol.ProtocolVersion,
+			Type:      protocol.MsgTypeDirectMessage,
+			Length:    uint32(len(d.payload)),
+			Flags:     protocol.FlagEncrypted | protocol.FlagQueued,
+			MessageID: protocol.QueuedMessageID(d.seq),
+		}
+		if err := protocol.WriteHeader(peer.Conn, header); err != nil {
+			log.Printf("Failed to replay queued message: %v", err)
+			return
+		}
+		if _, err := peer.Conn.Write(d.payload); err != nil {
+			log.Printf("Failed to replay queued message: %v", err)
+			return
+		}
+		sent++
+	}
+
+	log.Printf("📬 Replayed %d unconfirmed queued messages to %x", sent, peer.Address[:8])
+}
+
+// registerPeer adds a peer to the routing table, closing the stale connection of a returning user
+func (rs *RelayServer) registerPeer(peer *Peer) {
+	key := string(peer.Address[:])
+
+	rs.mu.Lock()
+	old := rs.peers[key]
+	rs.peers[key] = peer
+	rs.mu.Unlock()
+
+	// After a network switch the old socket can linger until the idle timeout;
+	// relay links are left alone since two relays may dial each other
+	if old != nil && old != peer && old.ClientType == protocol.ClientTypeUser && peer.ClientType == protocol.ClientTypeUser {
+		old.Conn.Close()
+	}
+}
+
+// unregisterPeer removes a peer when its connection ends, unless it was already replaced
+func (rs *RelayServer) unregisterPeer(peer *Peer) {
+	key := string(peer.Address[:])
+
+	rs.mu.Lock()
+	if rs.peers[key] == peer {
+		delete(rs.peers, key)
+	}
+	rs.mu.Unlock()
+
+	if store := rs.getResumption(); store != nil {
+		store.detach(peer)
+	}
+	rs.stopQueueFlush(peer)
+}
+
+// sendTicket sends a session resumption ticket
+func (rs *RelayServer) sendTicket(conn net.Conn, ticket *protocol.SessionTicket) error {
+	payload := ticket.Encode()
+
+	header := &protocol.Header{
+		Magic:     protocol.ProtocolMagic,
+		Version:   protocol.ProtocolVersion,
+		Type:      protocol.MsgTypeTicket,
+		Length:    uint32(len(payload)),
+		Flags:     0,
+		MessageID: protocol.GenerateMessageID(),
+	}
+
+	if err := protocol.WriteHeader(conn, header); err != nil {
+		return err
+	}
+
+	_, err := conn.Write(payload)
+	return err
+}
+
+// sendResumeAck answers a resume request
+func (rs *RelayServer) sendResumeAck(conn net.Conn, status uint8, flags uint16) error {
+	payload := (&protocol.ResumeAck{Status: status}).Encode()
+
+	header := &protocol.Header{
+		Magic:     protocol.ProtocolMagic,
+		Version:   protocol.ProtocolVersion,
+		Type:      protocol.MsgTypeResumeAck,
+		Length:    uint32(len(payload)),
+		Flags:     flags,
+		MessageID: protocol.GenerateMessageID(),
+	}
+
+	if err := protocol.WriteHeader(conn, header); err != nil {
+		return err
+	}
+
+	_, err := conn.Write(payload)
+	return err
+}
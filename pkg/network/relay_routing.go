@@ -0,0 +1,351 @@
+package network
+
+import (
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+const (
+	// DefaultRouteAnnounceInterval is how often a relay tells its peers who it can reach
+	DefaultRouteAnnounceInterval = 30 * time.Second
+
+	// DefaultRouteHopLimit is how many relays a routed message may pass through
+	DefaultRouteHopLimit = 4
+
+	// routeExpiryIntervals is how many announce intervals a learned route outlives its last update
+	routeExpiryIntervals = 3
+)
+
+// MeshRoutingConfig controls relay-to-relay routing of final deliveries
+// Relays announce the users connected to them, and the routes they learned,
+// to their relay peers. A message for a user who isn't connected here is passed
+// toward the relay that has them instead of being queued.
+type MeshRoutingConfig struct {
+	AnnounceInterval time.Duration // How often routes are announced (0 = DefaultRouteAnnounceInterval)
+	HopLimit         uint8         // Relays a message may be routed through (0 = DefaultRouteHopLimit)
+}
+
+// DefaultMeshRoutingConfig returns the default announce interval and hop limit
+func DefaultMeshRoutingConfig() MeshRoutingConfig {
+	return MeshRoutingConfig{
+		AnnounceInterval: DefaultRouteAnnounceInterval,
+		HopLimit:         DefaultRouteHopLimit,
+	}
+}
+
+// routedHop describes how a routed message reached this relay
+type routedHop struct {
+	limit uint8            // Further relays the message may be passed to
+	from  protocol.Address // Relay that routed it here
+}
+
+// meshRoute is a learned path to a user
+type meshRoute struct {
+	via     protocol.Address // Relay peer to pass the message to
+	hops    uint8            // Relays between via and the user
+	expires time.Time
+}
+
+// forwardingTable holds the routes learned from relay peers
+type forwardingTable struct {
+	cfg    MeshRoutingConfig
+	routes map[protocol.Address]meshRoute
+	mu     sync.Mutex
+	stop   chan struct{}
+}
+
+// learn applies a relay peer's route update
+// The update replaces everything previously learned from that peer; shorter
+// routes through other peers are kept.
+func (ft *forwardingTable) learn(via protocol.Address, update *protocol.RouteUpdate, now time.Time) {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+
+	expires := now.Add(routeExpiryIntervals * ft.cfg.AnnounceInterval)
+	announced := make(map[protocol.Address]bool, len(update.Entries))
+
+	for _, entry := range update.Entries {
+		if entry.Hops >= ft.cfg.HopLimit || protocol.IsZeroAddress(entry.Address) {
+			continue
+		}
+		announced[entry.Address] = true
+
+		hops := entry.Hops + 1
+		current, exists := ft.routes[entry.Address]
+		if !exists || current.via == via || hops < current.hops || now.After(current.expires) {
+			ft.routes[entry.Address] = meshRoute{via: via, hops: hops, expires: expires}
+		}
+	}
+
+	// Addresses the peer no longer announces are withdrawn
+	for addr, route := range ft.routes {
+		if route.via == via && !announced[addr] {
+			delete(ft.routes, addr)
+		}
+	}
+}
+
+// forget drops every route through a relay peer
+func (ft *forwardingTable) forget(via protocol.Address) {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+
+	for addr, route := range ft.routes {
+		if route.via == via {
+			delete(ft.routes, addr)
+		}
+	}
+}
+
+// lookup returns the unexpired route to a user
+func (ft *forwardingTable) lookup(addr protocol.Address, now time.Time) (meshRoute, bool) {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+
+	route, exists := ft.routes[addr]
+	if !exists {
+		return meshRoute{}, false
+	}
+	if now.After(route.expires) {
+		delete(ft.routes, addr)
+		return meshRoute{}, false
+	}
+	return route, true
+}
+
+// announcement builds the update for one relay peer
+// Local users are announced at 0 hops; learned routes are announced too,
+// except those through the peer itself, so two relays never route a user
+// back and forth between them.
+func (ft *forwardingTable) announcement(local []protocol.Address, peer protocol.Address, now time.Time) *protocol.RouteUpdate {
+	update := &protocol.RouteUpdate{Entries: make([]protocol.RouteEntry, 0, len(local))}
+	seen := make(map[protocol.Address]bool, len(local))
+
+	for _, addr := range local {
+		if len(update.Entries) == protocol.MaxRouteEntries {
+			return update
+		}
+		update.Entries = append(update.Entries, protocol.RouteEntry{Address: addr})
+		seen[addr] = true
+	}
+
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+
+	for addr, route := range ft.routes {
+		if len(update.Entries) == protocol.MaxRouteEntries {
+			break
+		}
+		if route.via == peer || seen[addr] || route.hops >= ft.cfg.HopLimit || now.After(route.expires) {
+			continue
+		}
+		update.Entries = append(update.Entries, protocol.RouteEntry{Address: addr, Hops: route.hops})
+	}
+	return update
+}
+
+// size returns the number of learned routes
+func (ft *forwardingTable) size() int {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+	return len(ft.routes)
+}
+
+// EnableMeshRouting starts exchanging routes with relay peers
+// Announcing which users are connected here tells peer relays who is online,
+// so it is only worth enabling among relays that trust each other.
+func (rs *RelayServer) EnableMeshRouting(cfg MeshRoutingConfig) {
+	if cfg.AnnounceInterval <= 0 {
+		cfg.AnnounceInterval = DefaultRouteAnnounceInterval
+	}
+	if cfg.HopLimit == 0 {
+		cfg.HopLimit = DefaultRouteHopLimit
+	}
+
+	table := &forwardingTable{
+		cfg:    cfg,
+		routes: make(map[protocol.Address]meshRoute),
+		stop:   make(chan struct{}),
+	}
+
+	rs.mu.Lock()
+	previous := rs.routing
+	rs.routing = table
+	rs.mu.Unlock()
+
+	if previous != nil {
+		close(previous.stop)
+	}
+	go rs.routeAnnounceLoop(table)
+
+	log.Printf("🧭 Mesh routing enabled: announcing every %s, up to %d hops", cfg.AnnounceInterval, cfg.HopLimit)
+}
+
+// DisableMeshRouting stops announcing routes and forgets the learned ones
+func (rs *RelayServer) DisableMeshRouting() {
+	rs.mu.Lock()
+	table := rs.routing
+	rs.routing = nil
+	rs.mu.Unlock()
+
+	if table != nil {
+		close(table.stop)
+	}
+}
+
+// getRouting returns the forwarding table (nil if mesh routing is disabled)
+func (rs *RelayServer) getRouting() *forwardingTable {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.routing
+}
+
+// relayPeers returns the connected relay peers
+func (rs *RelayServer) relayPeers() []*Peer {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	relays := make([]*Peer, 0)
+	for _, peer := range rs.peers {
+		if peer.ClientType == protocol.ClientTypeRelay {
+			relays = append(relays, peer)
+		}
+	}
+	return relays
+}
+
+// routeAnnounceLoop periodically sends each relay peer the routes it may use
+func (rs *RelayServer) routeAnnounceLoop(table *forwardingTable) {
+	ticker := time.NewTicker(table.cfg.AnnounceInterval)
+	defer ticker.Stop()
+
+	for {
+		rs.announceRoutes(table)
+
+		select {
+		case <-table.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// announceRoutes sends one round of route updates
+func (rs *RelayServer) announceRoutes(table *forwardingTable) {
+	now := time.Now()
+	local := rs.connectedUsers()
+
+	for _, peer := range rs.relayPeers() {
+		update := table.announcement(local, peer.Address, now)
+		if err := rs.sendToRelay(peer, protocol.MsgTypeRouteUpdate, update.Encode()); err != nil {
+			log.Printf("Failed to announce routes to relay %x: %v", peer.Address[:8], err)
+		}
+	}
+}
+
+// routeMessage passes a message for a user who isn't connected here to the relay that has them
+// Returns false if there is no usable route, so the caller queues the message.
+func (rs *RelayServer) routeMessage(recipient protocol.Address, payload []byte, hop *routedHop) bool {
+	table := rs.getRouting()
+	if table == nil {
+		return false
+	}
+
+	limit := table.cfg.HopLimit
+	if hop != nil {
+		limit = hop.limit
+	}
+	if limit == 0 {
+		return false
+	}
+
+	route, ok := table.lookup(recipient, time.Now())
+	if !ok || (hop != nil && route.via == hop.from) {
+		return false
+	}
+
+	rs.mu.RLock()
+	peer, exists := rs.peers[string(route.via[:])]
+	rs.mu.RUnlock()
+	if !exists || peer.ClientType != protocol.ClientTypeRelay {
+		table.forget(route.via)
+		return false
+	}
+
+	msg := &protocol.RoutedMessage{Recipient: recipient, HopLimit: limit - 1, Payload: payload}
+	if err := rs.sendToRelay(peer, protocol.MsgTypeRoutedMessage, msg.Encode()); err != nil {
+		log.Printf("Failed to route message for %x via relay %x: %v", recipient[:8], route.via[:8], err)
+		return false
+	}
+
+	log.Printf("🧭 Routed message for %x via relay %x (%d hops away)", recipient[:8], route.via[:8], route.hops)
+	return true
+}
+
+// handleRouteUpdate learns the routes a relay peer announces
+// from is the peer the connection handshook as (nil before a handshake)
+func (rs *RelayServer) handleRouteUpdate(conn net.Conn, header *protocol.Header, from *Peer) error {
+	payload := make([]byte, header.Length)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return err
+	}
+
+	table := rs.getRouting()
+	if table == nil || from == nil || from.ClientType != protocol.ClientTypeRelay {
+		return nil
+	}
+
+	var update protocol.RouteUpdate
+	if err := update.Decode(payload); err != nil {
+		log.Printf("Invalid route update from relay %x: %v", from.Address[:8], err)
+		return nil
+	}
+
+	table.learn(from.Address, &update, time.Now())
+	return nil
+}
+
+// handleRoutedMessage delivers, or routes on, a message another relay routed here
+// from is the peer the connection handshook as (nil before a handshake)
+func (rs *RelayServer) handleRoutedMessage(conn net.Conn, header *protocol.Header, from *Peer) error {
+	payload := make([]byte, header.Length)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return err
+	}
+
+	if from == nil || from.ClientType != protocol.ClientTypeRelay {
+		rs.sendRelayError(conn, header.MessageID, protocol.NewRelayError(protocol.RelayErrUnsupportedType,
+			"routed messages are only accepted from relays"))
+		return nil
+	}
+
+	var msg protocol.RoutedMessage
+	if err := msg.Decode(payload); err != nil {
+		rs.sendRelayError(conn, header.MessageID, protocol.NewRelayError(protocol.RelayErrMalformed, "%v", err))
+		return nil
+	}
+
+	// A routed message is a final delivery here, so the exit policy applies
+	if relayErr := rs.validateNextHop(msg.Recipient, false); relayErr != nil {
+		log.Printf("🚫 Refusing routed message %x: %v", header.MessageID[:8], relayErr)
+		rs.sendRelayError(conn, header.MessageID, relayErr)
+		return nil
+	}
+
+	hop := &routedHop{limit: msg.HopLimit, from: from.Address}
+	if err := rs.deliverMessage(msg.Recipient, msg.Payload, nil, hop); err != nil {
+		rs.sendRelayError(conn, header.MessageID, asRelayError(err))
+		return nil
+	}
+
+	rs.messagesRelayed++
+	if rs.OnMessageRelayed != nil {
+		rs.OnMessageRelayed()
+	}
+	return nil
+}
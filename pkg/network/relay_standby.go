@@ -0,0 +1,412 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+	"github.com/ZentaChain/zentalk-node/pkg/storage"
+)
+
+const (
+	// DefaultStandbySyncInterval is how often a warm standby replicates from its primary
+	DefaultStandbySyncInterval = 5 * time.Second
+
+	// DefaultStandbyFailAfter is how many syncs in a row must fail before a standby takes over
+	DefaultStandbyFailAfter = 3
+
+	// replicationTimeout bounds one sync, including a large first queue transfer
+	replicationTimeout = 30 * time.Second
+
+	// maxReplicationFrame caps an encrypted replication message
+	maxReplicationFrame = 256 << 20
+)
+
+// StandbyConfig configures a relay as the warm standby of a primary
+// The standby runs with the primary's private key, so users, contacts and the
+// registry see the same relay after a takeover.
+type StandbyConfig struct {
+	Primary      string        // Primary's replication address (host:port, see EnableReplication)
+	SyncInterval time.Duration // Time between syncs (0 = DefaultStandbySyncInterval)
+	FailAfter    int           // Failed syncs in a row before taking over (0 = DefaultStandbyFailAfter)
+}
+
+// replicationRequest is sent by the standby at each sync
+type replicationRequest struct {
+	Nonce []byte   `json:"nonce"` // Echoed in the response so an old one cannot be replayed
+	Have  []string `json:"have"`  // Message IDs in the standby's queue
+}
+
+// replicationResponse is the primary's state as of the request
+type replicationResponse struct {
+	Nonce    []byte                `json:"nonce"`
+	Problem  string                `json:"problem,omitempty"` // Why the primary failed its self-check (empty = healthy)
+	Queue    *storage.QueueReplica `json:"queue,omitempty"`   // nil without a message queue
+	Sessions []sessionHint         `json:"sessions"`
+}
+
+// sessionHint is a resumable session, so users can resume on the standby after a takeover
+type sessionHint struct {
+	Ticket    protocol.Ticket         `json:"ticket"`
+	Address   protocol.Address        `json:"address"`
+	PublicKey string                  `json:"public_key"` // PEM
+	Limits    *protocol.PayloadLimits `json:"limits,omitempty"`
+	Records   *UniformRecordConfig    `json:"records,omitempty"`
+	Tenant    string                  `json:"tenant,omitempty"`
+	Bot       bool                    `json:"bot,omitempty"`
+	ExpiresAt int64                   `json:"expires_at,omitempty"` // Unix milliseconds (0 = connected)
+	InFlight  []replayHint            `json:"in_flight,omitempty"`
+}
+
+// replayHint is a queued message written to a user but not yet confirmed
+type replayHint struct {
+	Seq     uint64 `json:"seq"`
+	Payload []byte `json:"payload"`
+}
+
+// replicationServer serves a warm standby
+type replicationServer struct {
+	listener net.Listener
+	key      []byte
+}
+
+// hints returns the store's live sessions
+func (s *resumptionStore) hints() []sessionHint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expireLocked(time.Now())
+	hints := make([]sessionHint, 0, len(s.byUser))
+	for _, session := range s.byUser {
+		publicKey, err := crypto.ExportPublicKeyPEM(session.publicKey)
+		if err != nil {
+			continue
+		}
+		hint := sessionHint{
+			Ticket:    session.ticket,
+			Address:   session.address,
+			PublicKey: string(publicKey),
+			Limits:    session.limits,
+			Records:   session.records,
+			Tenant:    session.tenant,
+			Bot:       session.bot,
+		}
+		if !session.expiresAt.IsZero() {
+			hint.ExpiresAt = session.expiresAt.UnixMilli()
+		}
+		for _, d := range session.inFlight {
+			hint.InFlight = append(hint.InFlight, replayHint{Seq: d.seq, Payload: d.payload})
+		}
+		hints = append(hints, hint)
+	}
+	return hints
+}
+
+// restore replaces the store's sessions with a primary's
+// Sessions connected to the primary get a full ticket lifetime from now, as if
+// their connection had just dropped, which it will when the primary fails.
+func (s *resumptionStore) restore(hints []sessionHint) {
+	now := time.Now()
+	sessions := make(map[protocol.Ticket]*resumableSession, len(hints))
+	byUser := make(map[protocol.Address]*resumableSession, len(hints))
+
+	for _, hint := range hints {
+		publicKey, err := crypto.ImportPublicKeyPEM([]byte(hint.PublicKey))
+		if err != nil {
+			continue
+		}
+		session := &resumableSession{
+			ticket:    hint.Ticket,
+			address:   hint.Address,
+			publicKey: publicKey,
+			limits:    hint.Limits,
+			records:   hint.Records,
+			tenant:    hint.Tenant,
+			bot:       hint.Bot,
+			expiresAt: now.Add(s.lifetime),
+		}
+		if hint.ExpiresAt != 0 {
+			session.expiresAt = time.UnixMilli(hint.ExpiresAt)
+		}
+		for _, d := range hint.InFlight {
+			session.inFlight = append(session.inFlight, queuedDelivery{seq: d.Seq, payload: d.Payload})
+		}
+		sessions[session.ticket] = session
+		byUser[session.address] = session
+	}
+
+	s.mu.Lock()
+	s.sessions = sessions
+	s.byUser = byUser
+	s.mu.Unlock()
+}
+
+// SelfCheck reports why the relay cannot serve users (nil = healthy)
+// A primary reports the result to its standby at every sync.
+func (rs *RelayServer) SelfCheck() error {
+	if len(rs.listeners) == 0 {
+		return errors.New("not listening for users")
+	}
+	if rs.messageQueue != nil {
+		if _, err := rs.messageQueue.GetTotalQueueSize(); err != nil {
+			return fmt.Errorf("message queue unavailable: %w", err)
+		}
+	}
+	return nil
+}
+
+// EnableReplication lets a warm standby replicate this relay from listenAddr (host:port)
+// Traffic is encrypted with a key derived from the relay's private key, so only
+// a standby running with the same key can connect.
+func (rs *RelayServer) EnableReplication(listenAddr string) error {
+	key, err := crypto.DeriveStandbyKey(rs.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("failed to derive replication key: %w", err)
+	}
+
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for standby: %w", err)
+	}
+	server := &replicationServer{listener: listener, key: key}
+
+	rs.mu.Lock()
+	if rs.replication != nil {
+		rs.mu.Unlock()
+		listener.Close()
+		return fmt.Errorf("replication already enabled")
+	}
+	rs.replication = server
+	rs.mu.Unlock()
+
+	go rs.replicationLoop(server)
+
+	log.Printf("🪞 Replication to a warm standby enabled on %s", listener.Addr())
+	return nil
+}
+
+// DisableReplication stops serving the standby
+func (rs *RelayServer) DisableReplication() {
+	rs.mu.Lock()
+	server := rs.replication
+	rs.replication = nil
+	rs.mu.Unlock()
+
+	if server != nil {
+		server.listener.Close()
+	}
+}
+
+// ReplicationAddress returns the address standbys connect to ("" if replication is disabled)
+func (rs *RelayServer) ReplicationAddress() string {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	if rs.replication == nil {
+		return ""
+	}
+	return rs.replication.listener.Addr().String()
+}
+
+// replicationLoop accepts standby connections until replication is disabled
+func (rs *RelayServer) replicationLoop(server *replicationServer) {
+	for {
+		conn, err := server.listener.Accept()
+		if err != nil {
+			return
+		}
+		go rs.serveReplication(server, conn)
+	}
+}
+
+// serveReplication answers one sync
+func (rs *RelayServer) serveReplication(server *replicationServer, conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(replicationTimeout))
+
+	var req replicationRequest
+	if err := readReplicationFrame(conn, server.key, &req); err != nil {
+		log.Printf("🪞 Refused replication from %s: %v", conn.RemoteAddr(), err)
+		return
+	}
+
+	resp := replicationResponse{Nonce: req.Nonce}
+	if err := rs.SelfCheck(); err != nil {
+		resp.Problem = err.Error()
+	}
+	if rs.messageQueue != nil {
+		replica, err := rs.messageQueue.DiffQueue(req.Have)
+		if err != nil {
+			log.Printf("🪞 Failed to diff queue for standby: %v", err)
+			if resp.Problem == "" {
+				resp.Problem = err.Error()
+			}
+		}
+		resp.Queue = replica
+	}
+	if store := rs.getResumption(); store != nil {
+		resp.Sessions = store.hints()
+	}
+
+	if err := writeReplicationFrame(conn, server.key, &resp); err != nil {
+		log.Printf("🪞 Failed to send replication to %s: %v", conn.RemoteAddr(), err)
+	}
+}
+
+// RunStandby replicates the primary until it fails, then returns so the caller can take over
+// The primary fails when it cannot be reached or reports a failed self-check
+// config.FailAfter syncs in a row. A standby only takes over a primary it has
+// replicated from at least once, so a wrong address doesn't start a second
+// relay. Returns ctx's error if ctx is done first.
+func (rs *RelayServer) RunStandby(ctx context.Context, config StandbyConfig) error {
+	if config.Primary == "" {
+		return fmt.Errorf("standby needs the primary's replication address")
+	}
+	interval := config.SyncInterval
+	if interval <= 0 {
+		interval = DefaultStandbySyncInterval
+	}
+	failAfter := config.FailAfter
+	if failAfter <= 0 {
+		failAfter = DefaultStandbyFailAfter
+	}
+
+	key, err := crypto.DeriveStandbyKey(rs.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("failed to derive replication key: %w", err)
+	}
+
+	log.Printf("🪞 Warm standby for %s (sync every %v, takeover after %d failures)", config.Primary, interval, failAfter)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	synced := false
+	failures := 0
+	for {
+		if err := rs.syncFromPrimary(ctx, config.Primary, key); err != nil {
+			if !synced {
+				log.Printf("⚠️  Standby has not reached the primary yet: %v", err)
+			} else {
+				failures++
+				log.Printf("⚠️  Standby sync failed (%d/%d): %v", failures, failAfter, err)
+				if failures >= failAfter {
+					log.Printf("🪞 Primary %s failed, taking over", config.Primary)
+					return nil
+				}
+			}
+		} else {
+			if !synced {
+				log.Printf("🪞 Standby in sync with %s", config.Primary)
+			}
+			synced = true
+			failures = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// syncFromPrimary copies the primary's queue and sessions once
+// The state is applied even when the primary reports a problem, since a
+// failing primary's queue is still the best copy there is.
+func (rs *RelayServer) syncFromPrimary(ctx context.Context, primary string, key []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, replicationTimeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", primary)
+	if err != nil {
+		return fmt.Errorf("failed to reach primary: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	req := replicationRequest{Nonce: make([]byte, 16)}
+	if _, err := rand.Read(req.Nonce); err != nil {
+		return err
+	}
+	if rs.messageQueue != nil {
+		if req.Have, err = rs.messageQueue.QueuedMessageIDs(); err != nil {
+			return err
+		}
+	}
+
+	if err := writeReplicationFrame(conn, key, &req); err != nil {
+		return fmt.Errorf("failed to send sync request: %w", err)
+	}
+	var resp replicationResponse
+	if err := readReplicationFrame(conn, key, &resp); err != nil {
+		return fmt.Errorf("failed to read sync response: %w", err)
+	}
+	if !bytes.Equal(resp.Nonce, req.Nonce) {
+		return errors.New("sync response does not answer our request")
+	}
+
+	if rs.messageQueue != nil && resp.Queue != nil {
+		if _, _, err := rs.messageQueue.ApplyReplica(resp.Queue); err != nil {
+			return err
+		}
+	}
+	if store := rs.getResumption(); store != nil {
+		store.restore(resp.Sessions)
+	}
+
+	if resp.Problem != "" {
+		return fmt.Errorf("primary failed its self-check: %s", resp.Problem)
+	}
+	return nil
+}
+
+// writeReplicationFrame encrypts v and writes it with a length prefix
+func writeReplicationFrame(w io.Writer, key []byte, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	sealed, err := crypto.AESEncrypt(data, key)
+	if err != nil {
+		return err
+	}
+
+	frame := binary.BigEndian.AppendUint32(nil, uint32(len(sealed)))
+	_, err = w.Write(append(frame, sealed...))
+	return err
+}
+
+// readReplicationFrame reads a frame written by writeReplicationFrame into v
+func readReplicationFrame(r io.Reader, key []byte, v interface{}) error {
+	var length uint32
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return err
+	}
+	if length > maxReplicationFrame {
+		return fmt.Errorf("replication frame too large: %d bytes", length)
+	}
+
+	sealed := make([]byte, length)
+	if _, err := io.ReadFull(r, sealed); err != nil {
+		return err
+	}
+	data, err := crypto.AESDecrypt(sealed, key)
+	if err != nil {
+		return fmt.Errorf("not from a relay with our key: %w", err)
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,130 @@
+package network
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+	"github.com/ZentaChain/zentalk-node/pkg/storage"
+)
+
+// newTestStandby creates a relay with key and an empty queue, not yet listening
+func newTestStandby(t *testing.T, key *rsa.PrivateKey) *RelayServer {
+	t.Helper()
+
+	rs := NewRelayServer(0, key)
+	queue, err := storage.NewRelayMessageQueue(filepath.Join(t.TempDir(), "standby.db"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { queue.Close() })
+	rs.AttachMessageQueue(queue)
+	return rs
+}
+
+// startTestPrimary starts a relay holding three queued messages for addr, serving a standby
+func startTestPrimary(t *testing.T, addr protocol.Address) *RelayServer {
+	t.Helper()
+
+	primary := newTestQueueRelay(t, addr, 3)
+	primary.SetListenConfig(ListenConfig{Hosts: []string{"127.0.0.1"}})
+	if err := primary.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := primary.EnableReplication("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { primary.Stop() })
+	return primary
+}
+
+func TestStandbyTakeover(t *testing.T) {
+	addr := protocol.Address{0x51}
+	primary := startTestPrimary(t, addr)
+
+	// A user connected to the primary holds a resumption ticket
+	relaySide, userSide := net.Pipe()
+	defer relaySide.Close()
+	defer userSide.Close()
+	ticket, err := primary.getResumption().issue(&Peer{
+		Conn:       relaySide,
+		Address:    addr,
+		PublicKey:  primary.PublicKey,
+		ClientType: protocol.ClientTypeUser,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	standby := newTestStandby(t, primary.PrivateKey)
+	done := make(chan error, 1)
+	go func() {
+		done <- standby.RunStandby(context.Background(), StandbyConfig{
+			Primary:      primary.ReplicationAddress(),
+			SyncInterval: 20 * time.Millisecond,
+			FailAfter:    2,
+		})
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if count, _ := standby.GetMessageQueue().GetQueuedMessageCount(addr); count == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("standby never replicated the queue")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	primary.Stop()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("RunStandby() error = %v, want takeover", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("standby did not take over after the primary stopped")
+	}
+
+	// The user resumes on the standby with the ticket the primary issued
+	session, _, err := standby.getResumption().redeem(ticket.Ticket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session == nil || session.address != addr {
+		t.Fatal("standby does not honour the primary's resumption ticket")
+	}
+}
+
+func TestStandbyNeedsPrimaryKey(t *testing.T) {
+	addr := protocol.Address{0x52}
+	primary := startTestPrimary(t, addr)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	standby := newTestStandby(t, key)
+
+	// Refused syncs never count as a failed primary, since it was never reached
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	err = standby.RunStandby(ctx, StandbyConfig{
+		Primary:      primary.ReplicationAddress(),
+		SyncInterval: 20 * time.Millisecond,
+		FailAfter:    2,
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("RunStandby() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if count, _ := standby.GetMessageQueue().GetQueuedMessageCount(addr); count != 0 {
+		t.Errorf("standby with another key replicated %d messages", count)
+	}
+}
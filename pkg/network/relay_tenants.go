@@ -0,0 +1,308 @@
+package network
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// TenantConfig describes one organization served by a multi-tenant relay
+// Users name their tenant in the handshake. Once any tenant is added, users
+// naming an unknown tenant are refused; add a tenant with ID "" to keep
+// serving users that name none.
+type TenantConfig struct {
+	ID                string             // Tenant ID users send in the handshake
+	Allowlist         []protocol.Address // Users allowed to connect as this tenant (empty = anyone)
+	MessagesPerMinute int                // Messages the tenant's users may send per minute, together (0 = unlimited)
+	MaxQueued         int                // Offline messages queued for the tenant's users (0 = unlimited)
+}
+
+// TenantStats counts one tenant's traffic on this relay
+type TenantStats struct {
+	ConnectedUsers int
+	MessagesSent   uint64 // Sent by the tenant's users and counted against the rate limit
+	RateLimited    uint64 // Refused by MessagesPerMinute
+	QueueRefused   uint64 // Refused by MaxQueued
+	Denied         uint64 // Handshakes refused by the allowlist, and cross-tenant messages
+	QueuedMessages int    // Currently waiting for the tenant's offline users
+}
+
+// tenant is a configured tenant and its counters
+type tenant struct {
+	config  TenantConfig
+	allowed map[protocol.Address]bool // nil = anyone
+
+	windowStart time.Time // Start of the current rate-limit minute
+	windowCount int
+	stats       TenantStats
+}
+
+// tenantRegistry holds the relay's tenants and which tenant each user belongs to
+type tenantRegistry struct {
+	tenants map[string]*tenant
+	members map[protocol.Address]string // Tenant named in each user's last handshake
+	mu      sync.Mutex
+}
+
+// newTenantRegistry creates an empty registry
+func newTenantRegistry() *tenantRegistry {
+	return &tenantRegistry{
+		tenants: make(map[string]*tenant),
+		members: make(map[protocol.Address]string),
+	}
+}
+
+// AddTenant adds or reconfigures a tenant and turns on tenancy
+// Reconfiguring keeps the tenant's counters.
+func (rs *RelayServer) AddTenant(config TenantConfig) error {
+	if len(config.ID) > protocol.MaxTenantIDLength {
+		return fmt.Errorf("tenant ID %q is longer than %d bytes", config.ID, protocol.MaxTenantIDLength)
+	}
+
+	rs.mu.Lock()
+	if rs.tenants == nil {
+		rs.tenants = newTenantRegistry()
+	}
+	registry := rs.tenants
+	rs.mu.Unlock()
+
+	t := &tenant{config: config}
+	if len(config.Allowlist) > 0 {
+		t.allowed = make(map[protocol.Address]bool, len(config.Allowlist))
+		for _, addr := range config.Allowlist {
+			t.allowed[addr] = true
+		}
+	}
+
+	registry.mu.Lock()
+	if old := registry.tenants[config.ID]; old != nil {
+		t.stats = old.stats
+	}
+	registry.tenants[config.ID] = t
+	registry.mu.Unlock()
+
+	log.Printf("🏢 Tenant %q configured (allowlist: %d, rate: %d/min, max queued: %d)",
+		config.ID, len(config.Allowlist), config.MessagesPerMinute, config.MaxQueued)
+	return nil
+}
+
+// RemoveTenant removes a tenant and disconnects its users
+func (rs *RelayServer) RemoveTenant(id string) {
+	registry := rs.getTenants()
+	if registry == nil {
+		return
+	}
+
+	registry.mu.Lock()
+	delete(registry.tenants, id)
+	for addr, member := range registry.members {
+		if member == id {
+			delete(registry.members, addr)
+		}
+	}
+	registry.mu.Unlock()
+
+	rs.mu.RLock()
+	var conns []net.Conn
+	for _, peer := range rs.peers {
+		if peer.ClientType == protocol.ClientTypeUser && peer.Tenant == id {
+			conns = append(conns, peer.Conn)
+		}
+	}
+	rs.mu.RUnlock()
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+	log.Printf("🏢 Tenant %q removed, %d users disconnected", id, len(conns))
+}
+
+// GetTenantStats returns a tenant's counters (false if the tenant is unknown)
+func (rs *RelayServer) GetTenantStats(id string) (TenantStats, bool) {
+	registry := rs.getTenants()
+	if registry == nil {
+		return TenantStats{}, false
+	}
+
+	registry.mu.Lock()
+	t, ok := registry.tenants[id]
+	var stats TenantStats
+	if ok {
+		stats = t.stats
+	}
+	registry.mu.Unlock()
+	if !ok {
+		return TenantStats{}, false
+	}
+
+	rs.mu.RLock()
+	for _, peer := range rs.peers {
+		if peer.ClientType == protocol.ClientTypeUser && peer.Tenant == id {
+			stats.ConnectedUsers++
+		}
+	}
+	rs.mu.RUnlock()
+
+	if rs.messageQueue != nil {
+		stats.QueuedMessages, _ = rs.messageQueue.GetTenantQueueSize(id)
+	}
+	return stats, true
+}
+
+// getTenants returns the tenant registry (nil = tenancy off)
+func (rs *RelayServer) getTenants() *tenantRegistry {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.tenants
+}
+
+// admit checks a user may connect as a tenant and records their membership
+func (r *tenantRegistry) admit(id string, addr protocol.Address) *protocol.RelayErrorMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t := r.tenants[id]
+	if t == nil {
+		return protocol.NewRelayError(protocol.RelayErrTenantDenied, "unknown tenant %q", id)
+	}
+	if t.allowed != nil && !t.allowed[addr] {
+		t.stats.Denied++
+		return protocol.NewRelayError(protocol.RelayErrTenantDenied, "not on the allowlist of tenant %q", id)
+	}
+
+	r.members[addr] = id
+	return nil
+}
+
+// tenantOf returns the tenant of a user from their handshake or an allowlist
+func (r *tenantRegistry) tenantOf(addr protocol.Address) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if id, ok := r.members[addr]; ok {
+		return id, true
+	}
+	for id, t := range r.tenants {
+		if t.allowed[addr] {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// allowSend counts a message from one of the tenant's users against its rate limit
+func (r *tenantRegistry) allowSend(id string, now time.Time) *protocol.RelayErrorMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t := r.tenants[id]
+	if t == nil {
+		return protocol.NewRelayError(protocol.RelayErrTenantDenied, "unknown tenant %q", id)
+	}
+
+	if now.Sub(t.windowStart) >= time.Minute {
+		t.windowStart = now
+		t.windowCount = 0
+	}
+	if limit := t.config.MessagesPerMinute; limit > 0 && t.windowCount >= limit {
+		t.stats.RateLimited++
+		relayErr := protocol.NewRelayError(protocol.RelayErrRateLimited, "tenant limit of %d messages per minute reached", limit)
+		relayErr.RetryAfter = t.windowStart.Add(time.Minute).Sub(now)
+		return relayErr
+	}
+
+	t.windowCount++
+	t.stats.MessagesSent++
+	return nil
+}
+
+// checkRoute refuses messages between users of different tenants
+// Only the sender's own relay knows who sent a message, so messages arriving
+// from another relay are checked by that relay instead.
+func (r *tenantRegistry) checkRoute(from string, to protocol.Address) *protocol.RelayErrorMessage {
+	toTenant, known := r.tenantOf(to)
+	if !known || toTenant == from {
+		return nil
+	}
+
+	r.mu.Lock()
+	if t := r.tenants[from]; t != nil {
+		t.stats.Denied++
+	}
+	r.mu.Unlock()
+	return protocol.NewRelayError(protocol.RelayErrTenantDenied, "recipient belongs to another tenant")
+}
+
+// queueCapacity returns the tenant's MaxQueued (0 = unlimited)
+func (r *tenantRegistry) queueCapacity(id string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if t := r.tenants[id]; t != nil {
+		return t.config.MaxQueued
+	}
+	return 0
+}
+
+// allowQueue checks the tenant's offline queue has room
+func (r *tenantRegistry) allowQueue(id string, queued int) *protocol.RelayErrorMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t := r.tenants[id]
+	if t == nil || t.config.MaxQueued <= 0 || queued < t.config.MaxQueued {
+		return nil
+	}
+	t.stats.QueueRefused++
+	return protocol.NewRelayError(protocol.RelayErrQueueFull, "tenant queue holds %d messages", t.config.MaxQueued)
+}
+
+// tenantFileEntry is one tenant in a tenants file
+type tenantFileEntry struct {
+	ID                string   `json:"id"`
+	Allowlist         []string `json:"allowlist"` // Hex addresses
+	MessagesPerMinute int      `json:"messages_per_minute"`
+	MaxQueued         int      `json:"max_queued"`
+}
+
+// LoadTenantConfigs reads tenants from a JSON file
+// Format: [{"id": "acme", "allowlist": ["<hex address>", ...], "messages_per_minute": 600, "max_queued": 10000}, ...]
+func LoadTenantConfigs(path string) ([]TenantConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenants file: %w", err)
+	}
+
+	var entries []tenantFileEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse tenants file: %w", err)
+	}
+
+	configs := make([]TenantConfig, 0, len(entries))
+	for _, entry := range entries {
+		config := TenantConfig{
+			ID:                entry.ID,
+			MessagesPerMinute: entry.MessagesPerMinute,
+			MaxQueued:         entry.MaxQueued,
+		}
+		for _, addrHex := range entry.Allowlist {
+			addrBytes, err := hex.DecodeString(addrHex)
+			if err != nil || len(addrBytes) != len(protocol.Address{}) {
+				return nil, fmt.Errorf("tenant %q: invalid address %q", entry.ID, addrHex)
+			}
+			var addr protocol.Address
+			copy(addr[:], addrBytes)
+			config.Allowlist = append(config.Allowlist, addr)
+		}
+		configs = append(configs, config)
+	}
+
+	return configs, nil
+}
@@ -0,0 +1,130 @@
+package network
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log"
+	"math/big"
+	"path/filepath"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+)
+
+const (
+	// DefaultMinHops is the shortest onion route built by default
+	DefaultMinHops = 3
+
+	// DefaultMaxHops is the longest onion route built by default
+	DefaultMaxHops = 5
+
+	// guardStateFile is the file name used for guard persistence inside session storage
+	guardStateFile = "guard_relays.json"
+)
+
+// RoutePolicy controls how the client builds onion routes
+type RoutePolicy struct {
+	MinHops  int  // Minimum number of relays in a route
+	MaxHops  int  // Maximum number of relays in a route (inclusive)
+	UseGuard bool // Pin the first hop to a persistent guard relay
+
+	// GuardStatePath overrides where guard relays are persisted
+	// Empty means "guard_relays.json" in the attached session storage directory
+	GuardStatePath string
+}
+
+// DefaultRoutePolicy returns the default route policy (3-5 hops, guard enabled)
+func DefaultRoutePolicy() *RoutePolicy {
+	return &RoutePolicy{
+		MinHops:  DefaultMinHops,
+		MaxHops:  DefaultMaxHops,
+		UseGuard: true,
+	}
+}
+
+// Validate checks that the policy describes a usable hop range
+func (p *RoutePolicy) Validate() error {
+	if p.MinHops < 3 {
+		return fmt.Errorf("minimum hops must be at least 3, got %d", p.MinHops)
+	}
+	if p.MaxHops < p.MinHops {
+		return fmt.Errorf("maximum hops (%d) must not be less than minimum hops (%d)", p.MaxHops, p.MinHops)
+	}
+	return nil
+}
+
+// PickHopCount picks a uniformly random hop count in [MinHops, MaxHops]
+// Varying route length stops an observer from inferring position in the route from a fixed hop count
+func (p *RoutePolicy) PickHopCount() (int, error) {
+	if err := p.Validate(); err != nil {
+		return 0, err
+	}
+
+	spread := p.MaxHops - p.MinHops + 1
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(spread)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to pick hop count: %w", err)
+	}
+
+	return p.MinHops + int(n.Int64()), nil
+}
+
+// SetRoutePolicy sets the policy used by BuildPolicyRelayPath
+func (c *Client) SetRoutePolicy(policy *RoutePolicy) error {
+	if policy == nil {
+		policy = DefaultRoutePolicy()
+	}
+	if err := policy.Validate(); err != nil {
+		return fmt.Errorf("invalid route policy: %w", err)
+	}
+
+	c.routePolicy = policy
+	log.Printf("🧭 Route policy set: %d-%d hops (guard: %v)", policy.MinHops, policy.MaxHops, policy.UseGuard)
+	return nil
+}
+
+// GetRoutePolicy returns the active route policy (default if none set)
+func (c *Client) GetRoutePolicy() *RoutePolicy {
+	if c.routePolicy == nil {
+		return DefaultRoutePolicy()
+	}
+	return c.routePolicy
+}
+
+// BuildPolicyRelayPath builds a relay path according to the client's route policy
+// The hop count is re-randomized for every path
+func (c *Client) BuildPolicyRelayPath() ([]*crypto.RelayInfo, error) {
+	policy := c.GetRoutePolicy()
+
+	numRelays, err := policy.PickHopCount()
+	if err != nil {
+		return nil, err
+	}
+
+	if policy.UseGuard {
+		return c.BuildSecureRelayPath(numRelays)
+	}
+	return c.BuildRelayPath(numRelays)
+}
+
+// ensureGuardRelayManager lazily creates the guard relay manager and restores persisted guards
+func (c *Client) ensureGuardRelayManager() *GuardRelayManager {
+	if c.guardRelayManager != nil || c.relayDiscovery == nil {
+		return c.guardRelayManager
+	}
+
+	c.guardRelayManager = NewGuardRelayManager(c.relayDiscovery)
+	log.Printf("🛡️  Guard relay manager initialized")
+
+	persistPath := c.GetRoutePolicy().GuardStatePath
+	if persistPath == "" && c.sessionStorage != nil {
+		persistPath = filepath.Join(c.sessionStorage.storageDir, guardStateFile)
+	}
+
+	if persistPath != "" {
+		if err := c.guardRelayManager.SetPersistPath(persistPath); err != nil {
+			log.Printf("⚠️  Failed to restore guard relays: %v", err)
+		}
+	}
+
+	return c.guardRelayManager
+}
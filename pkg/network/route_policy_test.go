@@ -0,0 +1,58 @@
+package network
+
+import "testing"
+
+func TestRoutePolicyValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  RoutePolicy
+		wantErr bool
+	}{
+		{name: "default", policy: *DefaultRoutePolicy()},
+		{name: "fixed length", policy: RoutePolicy{MinHops: 4, MaxHops: 4}},
+		{name: "min greater than max", policy: RoutePolicy{MinHops: 5, MaxHops: 3}, wantErr: true},
+		{name: "min below 3", policy: RoutePolicy{MinHops: 2, MaxHops: 5}, wantErr: true},
+		{name: "zero", policy: RoutePolicy{}, wantErr: true},
+		{name: "negative", policy: RoutePolicy{MinHops: -1, MaxHops: 3}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			n, err := tt.policy.PickHopCount()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PickHopCount() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (n < tt.policy.MinHops || n > tt.policy.MaxHops) {
+				t.Errorf("PickHopCount() = %d, want %d-%d", n, tt.policy.MinHops, tt.policy.MaxHops)
+			}
+		})
+	}
+}
+
+func TestRoutePolicyPickHopCountRange(t *testing.T) {
+	policy := &RoutePolicy{MinHops: 3, MaxHops: 5}
+
+	seen := make(map[int]bool)
+	for i := 0; i < 300; i++ {
+		n, err := policy.PickHopCount()
+		if err != nil {
+			t.Fatalf("PickHopCount() error = %v", err)
+		}
+		if n < policy.MinHops || n > policy.MaxHops {
+			t.Fatalf("PickHopCount() = %d, want %d-%d", n, policy.MinHops, policy.MaxHops)
+		}
+		seen[n] = true
+	}
+
+	// Every length in the range is used, the ends included
+	for n := policy.MinHops; n <= policy.MaxHops; n++ {
+		if !seen[n] {
+			t.Errorf("PickHopCount() never returned %d in 300 draws", n)
+		}
+	}
+}
@@ -0,0 +1,361 @@
+package network
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+	"github.com/ZentaChain/zentalk-node/pkg/storage"
+)
+
+const (
+	// maxUnackedMessages bounds the sent messages kept for retransmission
+	maxUnackedMessages = 4096
+
+	// retransmitTick is how often the send queue is checked for due messages
+	retransmitTick = time.Second
+)
+
+// RetransmitPolicy controls how sent messages are retried until their ACK arrives
+type RetransmitPolicy struct {
+	InitialBackoff time.Duration // Wait after the first send
+	MaxBackoff     time.Duration // The wait doubles after each send up to this
+	MaxAttempts    int           // Sends before the message is reported failed
+}
+
+// DefaultRetransmitPolicy retries for roughly 20 minutes before giving up
+func DefaultRetransmitPolicy() RetransmitPolicy {
+	return RetransmitPolicy{
+		InitialBackoff: 5 * time.Second,
+		MaxBackoff:     5 * time.Minute,
+		MaxAttempts:    8,
+	}
+}
+
+// backoff returns how long to wait for an ACK after the attempts-th send
+func (p RetransmitPolicy) backoff(attempts int) time.Duration {
+	wait := p.InitialBackoff
+	for i := 1; i < attempts && wait < p.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+	return wait
+}
+
+// pendingSend is a sent direct message awaiting the recipient's ACK
+// The onion is resent unchanged under the original relay message ID, so the
+// recipient sees the same sequence number and drops the copy if it already has it.
+type pendingSend struct {
+	to          protocol.Address
+	seq         uint64
+	messageID   protocol.MessageID
+	onion       []byte
+	attempts    int
+	nextAttempt time.Time
+	sentAt      time.Time // First send, for ACK latency (zero if restored from a previous run)
+}
+
+// storedID is the message's ID in the message database
+func (p *pendingSend) storedID() string {
+	return fmt.Sprintf("%x", p.messageID)
+}
+
+// sendQueue holds each peer's unacknowledged messages by sequence number
+type sendQueue struct {
+	mu      sync.Mutex
+	peers   map[protocol.Address]map[uint64]*pendingSend
+	size    int
+	policy  *RetransmitPolicy // nil = DefaultRetransmitPolicy
+	running bool              // The retransmit loop is started
+}
+
+// SetRetransmitPolicy changes how unacknowledged messages are retried
+// Messages already queued keep their next retry time.
+func (c *Client) SetRetransmitPolicy(policy RetransmitPolicy) {
+	c.sendQueue.mu.Lock()
+	defer c.sendQueue.mu.Unlock()
+	c.sendQueue.policy = &policy
+}
+
+// retransmitPolicy returns the policy in use (caller holds sendQueue.mu)
+func (q *sendQueue) retransmitPolicy() RetransmitPolicy {
+	if q.policy == nil {
+		return DefaultRetransmitPolicy()
+	}
+	return *q.policy
+}
+
+// add queues a message (caller holds mu); false if the queue is full
+func (q *sendQueue) add(p *pendingSend) bool {
+	if q.peers == nil {
+		q.peers = make(map[protocol.Address]map[uint64]*pendingSend)
+	}
+	if q.peers[p.to] == nil {
+		q.peers[p.to] = make(map[uint64]*pendingSend)
+	}
+	if _, exists := q.peers[p.to][p.seq]; !exists {
+		if q.size >= maxUnackedMessages {
+			return false
+		}
+		q.size++
+	}
+	q.peers[p.to][p.seq] = p
+	return true
+}
+
+// remove drops and returns a peer's message with seq (caller holds mu)
+func (q *sendQueue) remove(to protocol.Address, seq uint64) *pendingSend {
+	p, ok := q.peers[to][seq]
+	if !ok {
+		return nil
+	}
+	delete(q.peers[to], seq)
+	if len(q.peers[to]) == 0 {
+		delete(q.peers, to)
+	}
+	q.size--
+	return p
+}
+
+// Unacked returns the sequence numbers sent to a peer that are still awaiting its ACK
+func (c *Client) Unacked(to protocol.Address) []uint64 {
+	c.sendQueue.mu.Lock()
+	defer c.sendQueue.mu.Unlock()
+
+	seqs := make([]uint64, 0, len(c.sendQueue.peers[to]))
+	for seq := range c.sendQueue.peers[to] {
+		seqs = append(seqs, seq)
+	}
+	return seqs
+}
+
+// trackSend queues a sent message for retransmission until it is ACKed
+// written is false when the first write failed; the message then goes out on
+// the next retransmission. Returns false if the queue is full, in which case
+// the message is never retried.
+func (c *Client) trackSend(to protocol.Address, seq uint64, messageID protocol.MessageID, onion []byte, written bool) bool {
+	c.sendQueue.mu.Lock()
+	policy := c.sendQueue.retransmitPolicy()
+	p := &pendingSend{
+		to:          to,
+		seq:         seq,
+		messageID:   messageID,
+		onion:       onion,
+		attempts:    1,
+		nextAttempt: time.Now().Add(policy.backoff(1)),
+		sentAt:      time.Now(),
+	}
+	if !written {
+		p.nextAttempt = time.Now()
+	}
+	added := c.sendQueue.add(p)
+	c.sendQueue.mu.Unlock()
+
+	if !added {
+		return false
+	}
+	c.persistSend(p)
+	return true
+}
+
+// persistSend saves a queued message so it is retried after a restart
+func (c *Client) persistSend(p *pendingSend) {
+	if c.messageDB == nil {
+		return
+	}
+
+	err := c.messageDB.SaveOutboundMessage(&storage.OutboundMessage{
+		MessageID:   p.storedID(),
+		Peer:        hex.EncodeToString(p.to[:]),
+		Seq:         p.seq,
+		Frame:       p.onion,
+		Attempts:    p.attempts,
+		NextAttempt: p.nextAttempt.UnixMilli(),
+	})
+	if err != nil {
+		log.Printf("Failed to persist queued message %s: %v", p.storedID(), err)
+	}
+}
+
+// ackSend removes the message an ACK answers from the queue
+// Returns the message's stored ID, or "" if it wasn't queued.
+func (c *Client) ackSend(from protocol.Address, seq uint64) string {
+	c.sendQueue.mu.Lock()
+	p := c.sendQueue.remove(from, seq)
+	c.sendQueue.mu.Unlock()
+
+	if p == nil {
+		return ""
+	}
+	if !p.sentAt.IsZero() {
+		c.recordAckLatency(time.Since(p.sentAt))
+	}
+	if c.messageDB != nil {
+		if err := c.messageDB.DeleteOutboundMessage(p.storedID()); err != nil {
+			log.Printf("Failed to unqueue message %s: %v", p.storedID(), err)
+		}
+	}
+	return p.storedID()
+}
+
+// restoreSendQueue loads the messages a previous run left unacknowledged
+// Sequence numbers continue after the highest one queued for each peer, so
+// new messages don't reuse a number the peer may still ACK.
+func (c *Client) restoreSendQueue() {
+	if c.messageDB == nil {
+		return
+	}
+
+	queued, err := c.messageDB.GetOutboundMessages()
+	if err != nil {
+		log.Printf("⚠️  Failed to load outbound queue: %v", err)
+		return
+	}
+
+	restored := 0
+	for _, msg := range queued {
+		to, err1 := decodeAddress(msg.Peer)
+		id, err2 := hex.DecodeString(msg.MessageID)
+		if err1 != nil || err2 != nil || len(id) != len(protocol.MessageID{}) {
+			log.Printf("⚠️  Dropping malformed queued message %s", msg.MessageID)
+			c.messageDB.DeleteOutboundMessage(msg.MessageID)
+			continue
+		}
+
+		p := &pendingSend{
+			to:          to,
+			seq:         msg.Seq,
+			onion:       msg.Frame,
+			attempts:    msg.Attempts,
+			nextAttempt: time.UnixMilli(msg.NextAttempt),
+		}
+		copy(p.messageID[:], id)
+
+		c.sendQueue.mu.Lock()
+		added := c.sendQueue.add(p)
+		c.sendQueue.mu.Unlock()
+		if !added {
+			break
+		}
+
+		c.seqMu.Lock()
+		if c.sendSequenceNumbers[to] <= msg.Seq {
+			c.sendSequenceNumbers[to] = msg.Seq + 1
+		}
+		c.seqMu.Unlock()
+		restored++
+	}
+
+	if restored > 0 {
+		log.Printf("✅ Restored %d unacknowledged messages for retransmission", restored)
+	}
+}
+
+// decodeAddress parses a hex protocol address
+func decodeAddress(s string) (protocol.Address, error) {
+	var addr protocol.Address
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != len(addr) {
+		return addr, fmt.Errorf("invalid address: %q", s)
+	}
+	copy(addr[:], b)
+	return addr, nil
+}
+
+// startRetransmitLoop starts the retransmit loop unless it is already running
+func (c *Client) startRetransmitLoop() {
+	c.sendQueue.mu.Lock()
+	defer c.sendQueue.mu.Unlock()
+
+	if c.sendQueue.running {
+		return
+	}
+	c.sendQueue.running = true
+	go c.retransmitLoop()
+}
+
+// retransmitLoop resends unacknowledged messages until the client disconnects
+func (c *Client) retransmitLoop() {
+	ticker := time.NewTicker(retransmitTick)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if !c.connected.Load() {
+			c.sendQueue.mu.Lock()
+			c.sendQueue.running = false
+			c.sendQueue.mu.Unlock()
+			return
+		}
+		c.retransmitDue(time.Now())
+	}
+}
+
+// retransmitDue resends the messages whose wait for an ACK is over
+// A message already sent MaxAttempts times is dropped from the queue, marked
+// failed and reported to OnMessageFailed.
+func (c *Client) retransmitDue(now time.Time) {
+	var due, failed []*pendingSend
+
+	c.sendQueue.mu.Lock()
+	policy := c.sendQueue.retransmitPolicy()
+	for _, pending := range c.sendQueue.peers {
+		for _, p := range pending {
+			if p.nextAttempt.After(now) {
+				continue
+			}
+			if p.attempts >= policy.MaxAttempts {
+				failed = append(failed, c.sendQueue.remove(p.to, p.seq))
+				continue
+			}
+			p.attempts++
+			p.nextAttempt = now.Add(policy.backoff(p.attempts))
+			due = append(due, p)
+		}
+	}
+	c.sendQueue.mu.Unlock()
+
+	for _, p := range due {
+		header := &protocol.Header{
+			Magic:     protocol.ProtocolMagic,
+			Version:   protocol.ProtocolVersion,
+			Type:      protocol.MsgTypeRelayForward,
+			Length:    uint32(len(p.onion)),
+			Flags:     protocol.FlagEncrypted,
+			MessageID: p.messageID,
+		}
+		if err := c.writeFrameAs(context.Background(), c.directUsage(p.to, storage.UsageMessages), header, p.onion); err != nil {
+			log.Printf("⚠️  Retransmission %d of message to %x (seq: %d) failed: %v", p.attempts, p.to[:8], p.seq, err)
+		} else {
+			log.Printf("🔁 Retransmitted message to %x (seq: %d, attempt %d)", p.to[:8], p.seq, p.attempts)
+		}
+
+		if c.messageDB != nil {
+			if err := c.messageDB.UpdateOutboundAttempt(p.storedID(), p.attempts, p.nextAttempt.UnixMilli()); err != nil {
+				log.Printf("Failed to update queued message %s: %v", p.storedID(), err)
+			}
+		}
+	}
+
+	for _, p := range failed {
+		log.Printf("❌ No ACK from %x for message seq %d after %d attempts", p.to[:8], p.seq, p.attempts)
+
+		if c.messageDB != nil {
+			if err := c.messageDB.DeleteOutboundMessage(p.storedID()); err != nil {
+				log.Printf("Failed to unqueue message %s: %v", p.storedID(), err)
+			}
+			if err := c.messageDB.UpdateMessageStatus(p.storedID(), storage.MessageStatusFailed); err != nil {
+				log.Printf("Failed to mark message %s failed: %v", p.storedID(), err)
+			}
+		}
+
+		if c.OnMessageFailed != nil {
+			c.OnMessageFailed(p.to, p.messageID)
+		}
+	}
+}
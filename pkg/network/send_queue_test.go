@@ -0,0 +1,198 @@
+package network
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+	"github.com/ZentaChain/zentalk-node/pkg/storage"
+)
+
+func newSendQueueDB(t *testing.T) *storage.MessageDB {
+	t.Helper()
+
+	db, err := storage.NewMessageDB(filepath.Join(t.TempDir(), "messages.db"), "password")
+	if err != nil {
+		t.Fatalf("NewMessageDB() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// readRetransmission runs retransmitDue at now and returns the frame it wrote
+func readRetransmission(t *testing.T, c *Client, relaySide net.Conn, now time.Time) (*protocol.Header, []byte) {
+	t.Helper()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.retransmitDue(now)
+	}()
+
+	header, payload := readFrame(t, relaySide)
+	<-done
+	return header, payload
+}
+
+// saveSentMessage stores an outgoing message as the sender would
+func saveSentMessage(t *testing.T, db *storage.MessageDB, id protocol.MessageID) string {
+	t.Helper()
+
+	storedID := fmt.Sprintf("%x", id)
+	err := db.SaveMessage(&storage.StoredMessage{
+		ConversationID: "conv",
+		MessageID:      storedID,
+		FromAddress:    "aa",
+		ToAddress:      "bb",
+		Content:        []byte("hello"),
+		Timestamp:      time.Now().UnixMilli(),
+		Status:         storage.MessageStatusSent,
+		IsOutgoing:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return storedID
+}
+
+func messageStatus(t *testing.T, db *storage.MessageDB, storedID string) storage.MessageStatus {
+	t.Helper()
+
+	msg, err := db.GetMessage(storedID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg.Status
+}
+
+func TestRetransmitBackoff(t *testing.T) {
+	policy := RetransmitPolicy{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second, MaxAttempts: 8}
+	for attempts, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 5: 10 * time.Second, 30: 10 * time.Second} {
+		if got := policy.backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestRetransmitUntilAcked(t *testing.T) {
+	c, relaySide := newTestClient(t, 1)
+	defer relaySide.Close()
+	db := newSendQueueDB(t)
+	c.AttachDatabase(db)
+
+	peer := protocol.Address{2}
+	id := protocol.GenerateMessageID()
+	storedID := saveSentMessage(t, db, id)
+	onion := []byte("onion")
+	c.trackSend(peer, 7, id, onion, true)
+
+	// Not due yet
+	c.retransmitDue(time.Now())
+
+	header, payload := readRetransmission(t, c, relaySide, time.Now().Add(time.Hour))
+	if header.MessageID != id || string(payload) != string(onion) {
+		t.Fatalf("retransmitted %x %q, want %x %q", header.MessageID, payload, id, onion)
+	}
+
+	queued, err := db.GetOutboundMessages()
+	if err != nil || len(queued) != 1 || queued[0].Attempts != 2 {
+		t.Fatalf("GetOutboundMessages() = %+v, %v; want one message sent twice", queued, err)
+	}
+
+	c.ackReceived(&protocol.AckMessage{From: peer, To: c.Address, SequenceNumber: 7})
+	if unacked := c.Unacked(peer); len(unacked) != 0 {
+		t.Errorf("Unacked() = %v after the ACK", unacked)
+	}
+	if queued, _ := db.GetOutboundMessages(); len(queued) != 0 {
+		t.Errorf("%d messages still persisted after the ACK", len(queued))
+	}
+	if status := messageStatus(t, db, storedID); status != storage.MessageStatusDelivered {
+		t.Errorf("status = %s, want delivered", status)
+	}
+}
+
+func TestRetransmitGivesUp(t *testing.T) {
+	c, relaySide := newTestClient(t, 1)
+	defer relaySide.Close()
+	db := newSendQueueDB(t)
+	c.AttachDatabase(db)
+	c.SetRetransmitPolicy(RetransmitPolicy{InitialBackoff: time.Second, MaxBackoff: time.Minute, MaxAttempts: 2})
+
+	var failedTo protocol.Address
+	var failedID protocol.MessageID
+	c.OnMessageFailed = func(to protocol.Address, id protocol.MessageID) {
+		failedTo, failedID = to, id
+	}
+
+	peer := protocol.Address{2}
+	id := protocol.GenerateMessageID()
+	storedID := saveSentMessage(t, db, id)
+	c.trackSend(peer, 0, id, []byte("onion"), true)
+
+	now := time.Now()
+	readRetransmission(t, c, relaySide, now.Add(time.Minute))
+
+	c.retransmitDue(now.Add(time.Hour))
+	if failedTo != peer || failedID != id {
+		t.Fatalf("OnMessageFailed(%x, %x), want (%x, %x)", failedTo, failedID, peer, id)
+	}
+	if unacked := c.Unacked(peer); len(unacked) != 0 {
+		t.Errorf("Unacked() = %v after giving up", unacked)
+	}
+	if status := messageStatus(t, db, storedID); status != storage.MessageStatusFailed {
+		t.Errorf("status = %s, want failed", status)
+	}
+}
+
+func TestSendQueueSurvivesRestart(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	relayKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := newSendQueueDB(t)
+	peer := protocol.Address{2}
+
+	// The relay connection is gone: the send is queued rather than failing
+	c := NewClient(key)
+	c.AttachDatabase(db)
+	clientSide, relaySide := net.Pipe()
+	relaySide.Close()
+	c.relayConn = clientSide
+	c.connected.Store(true)
+
+	path := []*crypto.RelayInfo{{Address: protocol.Address{0xB0}, PublicKey: &relayKey.PublicKey}}
+	if err := c.SendTextMessage(peer, &key.PublicKey, "hello", path); err != nil {
+		t.Fatalf("SendTextMessage() error = %v, want the message queued", err)
+	}
+	if unacked := c.Unacked(peer); len(unacked) != 1 || unacked[0] != 0 {
+		t.Fatalf("Unacked() = %v, want [0]", unacked)
+	}
+
+	queued, err := db.GetOutboundMessages()
+	if err != nil || len(queued) != 1 {
+		t.Fatalf("GetOutboundMessages() = %+v, %v", queued, err)
+	}
+	if status := messageStatus(t, db, queued[0].MessageID); status != storage.MessageStatusSending {
+		t.Errorf("status = %s, want sending", status)
+	}
+
+	// A new client on the same database picks the message up
+	restarted := NewClient(key)
+	restarted.AttachDatabase(db)
+	if unacked := restarted.Unacked(peer); len(unacked) != 1 || unacked[0] != 0 {
+		t.Fatalf("Unacked() after restart = %v, want [0]", unacked)
+	}
+	if seq := restarted.GetNextSequenceNumber(peer); seq != 1 {
+		t.Errorf("next sequence number after restart = %d, want 1", seq)
+	}
+}
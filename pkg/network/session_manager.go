@@ -4,7 +4,6 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
-	"errors"
 	"fmt"
 	"log"
 
@@ -56,14 +55,26 @@ func AESDecryptGCM(ciphertext []byte, key []byte) ([]byte, error) {
 // InitializeRatchetSession initializes a ratchet session as responder (Bob's side)
 // This is called when receiving an initial X3DH message from a sender
 func (c *Client) InitializeRatchetSession(from protocol.Address, initialMsg *protocol.InitialMessage) error {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+
 	if c.x3dhIdentity == nil || c.signedPreKey == nil {
-		return errors.New("X3DH not initialized")
+		return ErrX3DHNotInitialized
 	}
 
-	// Check if session already exists
-	if _, exists := c.ratchetSessions[from]; exists {
-		log.Printf("⚠️  Ratchet session with %x already exists", from[:8])
-		return nil
+	// A repeated initial message is ignored; a new one means the peer reset the
+	// session (e.g. after we failed to decrypt its messages) and replaces ours
+	if session, exists := c.ratchetSessions[from]; exists {
+		if session.DHReceivingPublic == initialMsg.EphemeralKey || c.ratchetInitKeys[from] == initialMsg.EphemeralKey {
+			log.Printf("⚠️  Ratchet session with %x already exists", from[:8])
+			return nil
+		}
+		log.Printf("🔄 %x started a new ratchet session, replacing ours", from[:8])
+	}
+
+	// Refuse a cipher suite we turned off before X3DH uses up a one-time prekey
+	if err := protocol.AcceptCipherSuite(initialMsg.CipherSuite, c.cipherSuitesLocked()); err != nil {
+		return err
 	}
 
 	// Perform X3DH as responder
@@ -79,40 +90,26 @@ func (c *Client) InitializeRatchetSession(from protocol.Address, initialMsg *pro
 
 	log.Printf("✅ X3DH completed as responder: SharedSecret=%x...", sharedSecret[:8])
 
-	// Initialize ratchet session as receiver with signed prekey
-	// Bob uses his signed prekey because Alice used Bob's signed prekey public as the remote DH key
-	session := protocol.NewRatchetStateReceiver(
-		sharedSecret,
-		c.signedPreKey.PrivateKey,
-		c.signedPreKey.PublicKey,
-		c.Address,
-		from,
-	)
-
-	// Set the remote DH public key to Alice's ephemeral key from the initial message
-	session.DHReceivingPublic = initialMsg.EphemeralKey
-
-	// Perform initial DH to derive receiving chain key
-	// This matches the initial DH that Alice performed on her side
-	// Bob computes: DH(Bob's SPK private, Alice's ephemeral public)
-	// Alice computed: DH(Alice's ephemeral private, Bob's SPK public)
-	// These produce the same shared secret
-	dhOutput, err := protocol.DH(session.DHSendingPrivate, session.DHReceivingPublic)
-	if err != nil {
-		return fmt.Errorf("initial DH failed: %w", err)
+	// The used one-time prekey is gone; don't bring it back on restart
+	if initialMsg.UsedOneTimePreKeyID != 0 {
+		if err := c.saveX3DHState(); err != nil {
+			log.Printf("⚠️  Failed to persist X3DH state: %v", err)
+		}
 	}
 
-	// Derive receiving chain key from the DH output
-	newRootKey, receivingChainKey, err := protocol.KDF_RK(session.RootKey, dhOutput)
+	// Initialize ratchet session as receiver with signed prekey
+	// Bob uses his signed prekey because Alice used Bob's signed prekey public as the remote DH key
+	session, err := protocol.NewRatchetStateResponder(sharedSecret, c.signedPreKey, initialMsg, c.Address, c.cipherSuitesLocked())
 	if err != nil {
-		return fmt.Errorf("initial KDF failed: %w", err)
+		return err
 	}
 
-	session.RootKey = newRootKey
-	session.ReceivingChainKey = receivingChainKey
-
 	// Store session
 	c.ratchetSessions[from] = session
+	if c.ratchetInitKeys == nil {
+		c.ratchetInitKeys = make(map[protocol.Address][32]byte)
+	}
+	c.ratchetInitKeys[from] = initialMsg.EphemeralKey
 
 	// Persist session if storage is attached
 	if c.sessionStorage != nil {
@@ -121,18 +118,25 @@ func (c *Client) InitializeRatchetSession(from protocol.Address, initialMsg *pro
 		}
 	}
 
-	log.Printf("✅ Ratchet session initialized with %x (responder)", from[:8])
+	log.Printf("✅ Ratchet session initialized with %x (responder, %s)", from[:8], session.CipherSuite)
 	return nil
 }
 
 // GetRatchetSession retrieves an existing ratchet session
+// The returned state must not be advanced concurrently with the client's own
+// sends and receives, which update it under sessionMu.
 func (c *Client) GetRatchetSession(addr protocol.Address) (*protocol.RatchetState, bool) {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
 	session, exists := c.ratchetSessions[addr]
 	return session, exists
 }
 
 // SetRatchetSession stores a ratchet session
 func (c *Client) SetRatchetSession(addr protocol.Address, session *protocol.RatchetState) {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+
 	c.ratchetSessions[addr] = session
 
 	// Persist session if storage is attached
@@ -163,6 +167,9 @@ func (c *Client) tryDecryptRatchetMessage(payload []byte, from protocol.Address)
 	ratchetHeader := payload[2 : 2+headerLen]
 	ciphertext := payload[2+headerLen:]
 
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+
 	// Check if we have a ratchet session with this sender
 	session, exists := c.ratchetSessions[from]
 	if !exists {
@@ -171,7 +178,7 @@ func (c *Client) tryDecryptRatchetMessage(payload []byte, from protocol.Address)
 	}
 
 	// Decrypt using ratchet
-	plaintext, err := session.RatchetDecrypt(ratchetHeader, ciphertext, AESDecryptGCM)
+	plaintext, err := session.Open(ratchetHeader, ciphertext)
 	if err != nil {
 		log.Printf("Failed to decrypt ratchet message from %x: %v", from[:8], err)
 		return nil, false
@@ -34,6 +34,7 @@ type X3DHState struct {
 	SignedPreKey    *protocol.SignedPreKeyPrivate      `json:"signed_prekey"`
 	OneTimePreKeys  map[string]*protocol.OneTimePreKeyPrivate `json:"one_time_prekeys"` // key is string(uint32)
 	RegistrationID  uint32                              `json:"registration_id"`
+	LastPreKeyID    uint32                              `json:"last_prekey_id,omitempty"` // Highest one-time prekey ID ever generated
 }
 
 // RatchetSessionData represents a serializable ratchet session
@@ -218,6 +219,27 @@ func (s *SessionStorage) LoadKeyBundleCache() (map[protocol.Address]*protocol.Ke
 	return cache, nil
 }
 
+// Size returns the bytes used by the files in the storage directory
+func (s *SessionStorage) Size() (int64, error) {
+	entries, err := os.ReadDir(s.storageDir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read storage directory: %w", err)
+	}
+
+	var total int64
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue // Removed since the directory was read
+		}
+		total += info.Size()
+	}
+	return total, nil
+}
+
 // Clear removes all stored session data
 func (s *SessionStorage) Clear() error {
 	files := []string{
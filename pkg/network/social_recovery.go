@@ -0,0 +1,447 @@
+package network
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// recoverySharesFile is the file name used for held recovery shares inside session storage
+const recoverySharesFile = "recovery_shares.json"
+
+var (
+	ErrNoRecoveryShare    = errors.New("no recovery share held for this user")
+	ErrRecoveryKeyChanged = errors.New("new device key does not match the recovery request")
+)
+
+// RecoveryContact is a contact who holds (or is asked for) a recovery share
+type RecoveryContact struct {
+	Address   protocol.Address
+	PublicKey *rsa.PublicKey
+}
+
+// RecoveredIdentity is the identity social recovery restores on a new device
+// Start a client with NewClient(PrivateKey), set its Address and call
+// RestoreX3DHIdentity(X3DHIdentity) before InitializeX3DH would run.
+type RecoveredIdentity struct {
+	Address      protocol.Address
+	PrivateKey   *rsa.PrivateKey
+	X3DHIdentity *protocol.IdentityKeyPair // nil if X3DH was not initialized when the shares were made
+}
+
+// RecoveryRequestHandler is called when a user's new device asks for the share we hold
+// from is the new device's address. Call ReleaseRecoveryShare once the owner has
+// confirmed the request out of band.
+type RecoveryRequestHandler func(from protocol.Address, req *protocol.RecoveryRequest)
+
+// recoveryIdentityFile is the JSON form of the identity stored in MeshStorage
+type recoveryIdentityFile struct {
+	Address      string                    `json:"address"`
+	PrivateKey   string                    `json:"private_key"` // PEM
+	X3DHIdentity *protocol.IdentityKeyPair `json:"x3dh_identity,omitempty"`
+}
+
+// socialRecovery is a client's recovery state: shares held for contacts and any recovery in progress
+type socialRecovery struct {
+	held      map[protocol.Address]*protocol.RecoveryShare
+	onRequest RecoveryRequestHandler
+	pending   *pendingRecovery
+	mu        sync.Mutex
+}
+
+// pendingRecovery collects shares sent back to this (new) device
+type pendingRecovery struct {
+	owner       protocol.Address
+	holders     map[protocol.Address]bool
+	shares      map[protocol.RecoverySetID]map[protocol.Address]*protocol.RecoveryShare
+	store       MeshStorageDownloader
+	onRecovered func(*RecoveredIdentity)
+}
+
+// EnableSocialRecovery holds recovery shares contacts send us and answers their new devices
+// onRequest may be nil to hold shares without releasing them.
+func (c *Client) EnableSocialRecovery(onRequest RecoveryRequestHandler) error {
+	recovery, err := c.getSocialRecovery()
+	if err != nil {
+		return err
+	}
+
+	recovery.mu.Lock()
+	recovery.onRequest = onRequest
+	recovery.mu.Unlock()
+
+	log.Printf("🛟 Social recovery enabled (%d shares held)", len(recovery.held))
+	return nil
+}
+
+// getSocialRecovery returns the recovery state, loading held shares and registering handlers on first use
+func (c *Client) getSocialRecovery() (*socialRecovery, error) {
+	if c.socialRecovery != nil {
+		return c.socialRecovery, nil
+	}
+
+	recovery := &socialRecovery{held: make(map[protocol.Address]*protocol.RecoveryShare)}
+	if path := c.recoverySharesPath(); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read recovery shares: %w", err)
+		}
+		if err == nil {
+			var stored map[string]*protocol.RecoveryShare
+			if err := json.Unmarshal(data, &stored); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal recovery shares: %w", err)
+			}
+			for _, share := range stored {
+				recovery.held[share.Owner] = share
+			}
+		}
+	}
+
+	c.socialRecovery = recovery
+	c.RegisterContentHandler(protocol.ContentTypeRecoveryShare, c.handleRecoveryShare)
+	c.RegisterContentHandler(protocol.ContentTypeRecoveryRequest, c.handleRecoveryRequest)
+	return recovery, nil
+}
+
+// SetupSocialRecovery splits our identity among contacts so any threshold of them can restore it
+// The identity is uploaded encrypted to MeshStorage and each contact gets a
+// share of its key. Running it again replaces the shares contacts hold.
+func (c *Client) SetupSocialRecovery(contacts []RecoveryContact, threshold int, store MeshStorageUploader, relayPath []*crypto.RelayInfo) (protocol.RecoverySetID, error) {
+	var setID protocol.RecoverySetID
+	if !c.connected.Load() {
+		return setID, ErrNotConnected
+	}
+	if threshold < 2 || threshold > len(contacts) || len(contacts) > crypto.MaxShares {
+		return setID, fmt.Errorf("need 2 <= threshold <= contacts <= %d, got %d of %d", crypto.MaxShares, threshold, len(contacts))
+	}
+
+	privateKeyPEM, err := crypto.ExportPrivateKeyPEM(c.PrivateKey)
+	if err != nil {
+		return setID, fmt.Errorf("failed to export private key: %w", err)
+	}
+	identity, err := json.Marshal(&recoveryIdentityFile{
+		Address:      hex.EncodeToString(c.Address[:]),
+		PrivateKey:   string(privateKeyPEM),
+		X3DHIdentity: c.GetX3DHIdentity(),
+	})
+	if err != nil {
+		return setID, fmt.Errorf("failed to marshal identity: %w", err)
+	}
+
+	chunkID, key, err := store.UploadEncrypted(identity)
+	if err != nil {
+		return setID, fmt.Errorf("failed to upload identity: %w", err)
+	}
+
+	shares, err := crypto.SplitSecret(key, threshold, len(contacts))
+	if err != nil {
+		return setID, err
+	}
+	if _, err := rand.Read(setID[:]); err != nil {
+		return setID, fmt.Errorf("failed to generate set ID: %w", err)
+	}
+
+	createdAt := uint64(time.Now().UnixMilli())
+	for i, contact := range contacts {
+		share := &protocol.RecoveryShare{
+			Owner:     c.Address,
+			SetID:     setID,
+			Threshold: uint8(threshold),
+			Total:     uint8(len(contacts)),
+			CreatedAt: createdAt,
+			ChunkID:   chunkID,
+			Share:     shares[i],
+		}
+		if err := c.SendMessage(contact.Address, contact.PublicKey, share.Encode(), protocol.ContentTypeRecoveryShare, relayPath); err != nil {
+			return setID, fmt.Errorf("failed to send recovery share to %x: %w", contact.Address[:8], err)
+		}
+	}
+
+	log.Printf("🛟 Identity split among %d contacts (%d needed to recover)", len(contacts), threshold)
+	return setID, nil
+}
+
+// HeldRecoveryShares returns the owners we hold recovery shares for
+func (c *Client) HeldRecoveryShares() ([]protocol.Address, error) {
+	recovery, err := c.getSocialRecovery()
+	if err != nil {
+		return nil, err
+	}
+
+	recovery.mu.Lock()
+	defer recovery.mu.Unlock()
+
+	owners := make([]protocol.Address, 0, len(recovery.held))
+	for owner := range recovery.held {
+		owners = append(owners, owner)
+	}
+	return owners, nil
+}
+
+// ReleaseRecoveryShare sends the share we hold for req.Owner to their new device
+// newDeviceKey must hash to req.KeyHash; compare its fingerprint with the owner
+// out of band, since anyone can send a recovery request.
+func (c *Client) ReleaseRecoveryShare(req *protocol.RecoveryRequest, newDeviceKey *rsa.PublicKey, relayPath []*crypto.RelayInfo) error {
+	recovery, err := c.getSocialRecovery()
+	if err != nil {
+		return err
+	}
+
+	recovery.mu.Lock()
+	share, exists := recovery.held[req.Owner]
+	recovery.mu.Unlock()
+	if !exists {
+		return ErrNoRecoveryShare
+	}
+
+	keyHash, err := recoveryKeyHash(newDeviceKey)
+	if err != nil {
+		return err
+	}
+	if keyHash != req.KeyHash {
+		return ErrRecoveryKeyChanged
+	}
+
+	if err := c.SendMessage(req.NewAddress, newDeviceKey, share.Encode(), protocol.ContentTypeRecoveryShare, relayPath); err != nil {
+		return err
+	}
+
+	log.Printf("🛟 Released recovery share for %x to new device %x", req.Owner[:8], req.NewAddress[:8])
+	return nil
+}
+
+// RequestRecovery asks holders to send their shares of owner's identity to this device
+// Shares travel as ordinary messages, so relays queue them while either side is
+// offline. onRecovered is called once enough shares have arrived to download
+// and decrypt the identity.
+func (c *Client) RequestRecovery(owner protocol.Address, holders []RecoveryContact, store MeshStorageDownloader, onRecovered func(*RecoveredIdentity), relayPath []*crypto.RelayInfo) error {
+	if !c.connected.Load() {
+		return ErrNotConnected
+	}
+
+	recovery, err := c.getSocialRecovery()
+	if err != nil {
+		return err
+	}
+
+	keyHash, err := recoveryKeyHash(c.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	pending := &pendingRecovery{
+		owner:       owner,
+		holders:     make(map[protocol.Address]bool, len(holders)),
+		shares:      make(map[protocol.RecoverySetID]map[protocol.Address]*protocol.RecoveryShare),
+		store:       store,
+		onRecovered: onRecovered,
+	}
+	for _, holder := range holders {
+		pending.holders[holder.Address] = true
+	}
+
+	recovery.mu.Lock()
+	recovery.pending = pending
+	recovery.mu.Unlock()
+
+	req := &protocol.RecoveryRequest{
+		Owner:      owner,
+		NewAddress: c.Address,
+		Timestamp:  uint64(time.Now().UnixMilli()),
+		KeyHash:    keyHash,
+	}
+	for _, holder := range holders {
+		if err := c.SendMessage(holder.Address, holder.PublicKey, req.Encode(), protocol.ContentTypeRecoveryRequest, relayPath); err != nil {
+			return fmt.Errorf("failed to send recovery request to %x: %w", holder.Address[:8], err)
+		}
+	}
+
+	log.Printf("🛟 Asked %d contacts for recovery shares of %x (key %x)", len(holders), owner[:8], keyHash[:8])
+	return nil
+}
+
+// handleRecoveryShare holds a share an owner sent us, or collects one for a recovery in progress
+func (c *Client) handleRecoveryShare(msg *protocol.DirectMessage) {
+	var share protocol.RecoveryShare
+	if err := share.Decode(msg.Content); err != nil {
+		log.Printf("Invalid recovery share from %x: %v", msg.From[:8], err)
+		return
+	}
+
+	recovery := c.socialRecovery
+	recovery.mu.Lock()
+	defer recovery.mu.Unlock()
+
+	if pending := recovery.pending; pending != nil && share.Owner == pending.owner && pending.holders[msg.From] {
+		c.collectRecoveryShare(recovery, msg.From, &share)
+		return
+	}
+
+	if share.Owner != msg.From {
+		log.Printf("⚠️  Ignoring recovery share for %x sent by %x", share.Owner[:8], msg.From[:8])
+		return
+	}
+
+	// A newer split replaces the one we held
+	if held, exists := recovery.held[share.Owner]; exists && held.CreatedAt > share.CreatedAt {
+		return
+	}
+	recovery.held[share.Owner] = &share
+	if err := c.persistRecoveryShares(recovery); err != nil {
+		log.Printf("⚠️  Failed to persist recovery shares: %v", err)
+	}
+
+	log.Printf("🛟 Holding recovery share for %x (%d of %d needed)", share.Owner[:8], share.Threshold, share.Total)
+}
+
+// collectRecoveryShare adds a returned share and tries to recover once a set reaches its threshold
+// Called with recovery.mu held.
+func (c *Client) collectRecoveryShare(recovery *socialRecovery, from protocol.Address, share *protocol.RecoveryShare) {
+	pending := recovery.pending
+
+	set := pending.shares[share.SetID]
+	if set == nil {
+		set = make(map[protocol.Address]*protocol.RecoveryShare)
+		pending.shares[share.SetID] = set
+	}
+	set[from] = share
+	log.Printf("🛟 Recovery share %d/%d from %x", len(set), share.Threshold, from[:8])
+
+	if len(set) < int(share.Threshold) {
+		return
+	}
+
+	identity, err := recoverIdentity(pending, set)
+	if err != nil {
+		// A holder may have sent a stale or corrupt share; more may still arrive
+		log.Printf("⚠️  Recovery with %d shares failed: %v", len(set), err)
+		return
+	}
+
+	recovery.pending = nil
+	log.Printf("✅ Identity %x recovered", identity.Address[:8])
+	if pending.onRecovered != nil {
+		go pending.onRecovered(identity)
+	}
+}
+
+// recoverIdentity combines a set's shares into the chunk key and decrypts the identity
+func recoverIdentity(pending *pendingRecovery, set map[protocol.Address]*protocol.RecoveryShare) (*RecoveredIdentity, error) {
+	var chunkID uint64
+	shares := make([][]byte, 0, len(set))
+	for _, share := range set {
+		chunkID = share.ChunkID
+		shares = append(shares, share.Share)
+	}
+
+	key, err := crypto.CombineShares(shares)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := pending.store.DownloadEncrypted(chunkID, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download identity: %w", err)
+	}
+
+	var file recoveryIdentityFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal identity: %w", err)
+	}
+
+	addrBytes, err := hex.DecodeString(file.Address)
+	if err != nil || len(addrBytes) != len(protocol.Address{}) {
+		return nil, fmt.Errorf("invalid address in identity")
+	}
+	identity := &RecoveredIdentity{X3DHIdentity: file.X3DHIdentity}
+	copy(identity.Address[:], addrBytes)
+	if identity.Address != pending.owner {
+		return nil, fmt.Errorf("identity is for %x, not %x", identity.Address[:8], pending.owner[:8])
+	}
+
+	identity.PrivateKey, err = crypto.ImportPrivateKeyPEM([]byte(file.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key in identity: %w", err)
+	}
+
+	return identity, nil
+}
+
+// handleRecoveryRequest passes a new device's request to the application if we hold a share
+func (c *Client) handleRecoveryRequest(msg *protocol.DirectMessage) {
+	var req protocol.RecoveryRequest
+	if err := req.Decode(msg.Content); err != nil {
+		log.Printf("Invalid recovery request from %x: %v", msg.From[:8], err)
+		return
+	}
+
+	recovery := c.socialRecovery
+	recovery.mu.Lock()
+	_, holding := recovery.held[req.Owner]
+	onRequest := recovery.onRequest
+	recovery.mu.Unlock()
+
+	if !holding || req.NewAddress != msg.From {
+		log.Printf("⚠️  Ignoring recovery request for %x from %x", req.Owner[:8], msg.From[:8])
+		return
+	}
+
+	log.Printf("🛟 Recovery request for %x from new device %x (key %x)", req.Owner[:8], msg.From[:8], req.KeyHash[:8])
+	if onRequest != nil {
+		onRequest(msg.From, &req)
+	}
+}
+
+// persistRecoveryShares writes held shares to session storage (no-op without storage)
+// Called with recovery.mu held.
+func (c *Client) persistRecoveryShares(recovery *socialRecovery) error {
+	path := c.recoverySharesPath()
+	if path == "" {
+		return nil
+	}
+
+	stored := make(map[string]*protocol.RecoveryShare, len(recovery.held))
+	for owner, share := range recovery.held {
+		stored[hex.EncodeToString(owner[:])] = share
+	}
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal recovery shares: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write recovery shares: %w", err)
+	}
+
+	return nil
+}
+
+// recoverySharesPath returns where held shares are persisted ("" if no session storage)
+func (c *Client) recoverySharesPath() string {
+	if c.sessionStorage == nil {
+		return ""
+	}
+	return filepath.Join(c.sessionStorage.storageDir, recoverySharesFile)
+}
+
+// recoveryKeyHash returns the SHA-256 of a public key's PEM, as carried in recovery requests
+func recoveryKeyHash(key *rsa.PublicKey) ([32]byte, error) {
+	pemData, err := crypto.ExportPublicKeyPEM(key)
+	if err != nil {
+		return [32]byte{}, fmt.Errorf("failed to export public key: %w", err)
+	}
+	return sha256.Sum256(pemData), nil
+}
@@ -0,0 +1,295 @@
+package network
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+	"github.com/ZentaChain/zentalk-node/pkg/storage"
+)
+
+// stickerPacksFile is the file name used for installed packs inside session storage
+const stickerPacksFile = "sticker_packs.json"
+
+var (
+	ErrStickerPackNotInstalled = errors.New("sticker pack not installed")
+	ErrStickerNotFound         = errors.New("sticker not found in pack")
+)
+
+// MeshStorageUploader uploads data encrypted to MeshStorage and returns (chunkID, key)
+type MeshStorageUploader interface {
+	UploadEncrypted(data []byte) (uint64, []byte, error)
+}
+
+// MeshStorageDownloader downloads and decrypts data from MeshStorage
+type MeshStorageDownloader interface {
+	DownloadEncrypted(chunkID uint64, key []byte) ([]byte, error)
+}
+
+// StickerUpload is a sticker image to include in a new pack
+type StickerUpload struct {
+	Emoji    string
+	MIMEType string // image/webp, image/png or image/gif
+	Data     []byte
+}
+
+// CreateStickerPack uploads sticker images and an encrypted manifest to MeshStorage,
+// installs the pack locally and returns a reference that can be shared in conversations
+func (c *Client) CreateStickerPack(name string, stickers []StickerUpload, store MeshStorageUploader) (*protocol.StickerPackReference, error) {
+	if len(stickers) == 0 {
+		return nil, fmt.Errorf("sticker pack must contain at least one sticker")
+	}
+	store = c.meterUploads(usageKey{category: storage.UsageMedia}, store)
+
+	manifest := &protocol.StickerPackManifest{
+		Version:   protocol.StickerPackManifestVersion,
+		Name:      name,
+		Author:    c.Address,
+		CreatedAt: time.Now().UnixMilli(),
+		Stickers:  make([]protocol.StickerEntry, 0, len(stickers)),
+	}
+	if _, err := rand.Read(manifest.PackID[:]); err != nil {
+		return nil, fmt.Errorf("failed to generate pack ID: %w", err)
+	}
+
+	for i, sticker := range stickers {
+		contentType, err := c.ContentTypes().ContentTypeForMIME(sticker.MIMEType)
+		if err != nil || (contentType != protocol.ContentTypeSticker && contentType != protocol.ContentTypeGIF) {
+			return nil, fmt.Errorf("sticker %d: unsupported MIME type %q", i, sticker.MIMEType)
+		}
+
+		// Enforce sticker/GIF size limits before uploading
+		if err := c.ContentTypes().Validate(contentType, sticker.Data); err != nil {
+			return nil, fmt.Errorf("sticker %d: %w", i, err)
+		}
+
+		chunkID, key, err := store.UploadEncrypted(sticker.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload sticker %d: %w", i, err)
+		}
+
+		manifest.Stickers = append(manifest.Stickers, protocol.StickerEntry{
+			ID:            uint16(i),
+			Emoji:         sticker.Emoji,
+			ContentType:   contentType,
+			MIMEType:      sticker.MIMEType,
+			ChunkID:       chunkID,
+			EncryptionKey: key,
+			Size:          len(sticker.Data),
+		})
+	}
+
+	if err := manifest.Validate(); err != nil {
+		return nil, err
+	}
+
+	data, err := manifest.Encode()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	manifestChunk, manifestKey, err := store.UploadEncrypted(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload manifest: %w", err)
+	}
+
+	ref := &protocol.StickerPackReference{
+		PackID:        manifest.PackID,
+		ManifestChunk: manifestChunk,
+		Name:          name,
+	}
+	copy(ref.ManifestKey[:], manifestKey)
+
+	if err := c.saveStickerPack(manifest); err != nil {
+		log.Printf("⚠️  Failed to persist sticker pack: %v", err)
+	}
+
+	log.Printf("🎨 Sticker pack created: %s (%d stickers, manifest chunk %d)", name, len(manifest.Stickers), manifestChunk)
+	return ref, nil
+}
+
+// ShareStickerPack sends a sticker pack reference so the recipient can install it
+func (c *Client) ShareStickerPack(to protocol.Address, recipientPubKey *rsa.PublicKey, ref *protocol.StickerPackReference, relayPath []*crypto.RelayInfo) error {
+	return c.SendMessage(to, recipientPubKey, ref.Encode(), protocol.ContentTypeStickerPack, relayPath)
+}
+
+// InstallStickerPack downloads and verifies a shared pack's manifest and installs it
+func (c *Client) InstallStickerPack(ref *protocol.StickerPackReference, store MeshStorageDownloader) (*protocol.StickerPackManifest, error) {
+	store = c.MeterMediaDownloads("", store)
+	data, err := store.DownloadEncrypted(ref.ManifestChunk, ref.ManifestKey[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to download manifest: %w", err)
+	}
+
+	manifest, err := protocol.DecodeStickerPackManifest(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// The manifest must describe the pack the reference points at
+	if manifest.PackID != ref.PackID {
+		return nil, fmt.Errorf("manifest pack ID does not match reference")
+	}
+
+	if err := c.saveStickerPack(manifest); err != nil {
+		return nil, err
+	}
+
+	log.Printf("🎨 Sticker pack installed: %s (%d stickers)", manifest.Name, len(manifest.Stickers))
+	return manifest, nil
+}
+
+// UninstallStickerPack removes an installed pack
+func (c *Client) UninstallStickerPack(packID protocol.StickerPackID) error {
+	packs, err := c.stickerPackMap()
+	if err != nil {
+		return err
+	}
+
+	if _, exists := packs[packID]; !exists {
+		return ErrStickerPackNotInstalled
+	}
+
+	delete(packs, packID)
+	return c.persistStickerPacks()
+}
+
+// InstalledStickerPacks returns all installed sticker packs
+func (c *Client) InstalledStickerPacks() ([]*protocol.StickerPackManifest, error) {
+	packs, err := c.stickerPackMap()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*protocol.StickerPackManifest, 0, len(packs))
+	for _, manifest := range packs {
+		result = append(result, manifest)
+	}
+	return result, nil
+}
+
+// SendSticker sends a sticker from an installed pack
+func (c *Client) SendSticker(to protocol.Address, recipientPubKey *rsa.PublicKey, packID protocol.StickerPackID, stickerID uint16, relayPath []*crypto.RelayInfo) error {
+	packs, err := c.stickerPackMap()
+	if err != nil {
+		return err
+	}
+
+	manifest, exists := packs[packID]
+	if !exists {
+		return ErrStickerPackNotInstalled
+	}
+
+	entry, exists := manifest.Sticker(stickerID)
+	if !exists {
+		return ErrStickerNotFound
+	}
+
+	msg := &protocol.StickerMessage{
+		PackID:    packID,
+		StickerID: stickerID,
+		ChunkID:   entry.ChunkID,
+	}
+	copy(msg.EncryptionKey[:], entry.EncryptionKey)
+
+	return c.SendMessage(to, recipientPubKey, msg.Encode(), entry.ContentType, relayPath)
+}
+
+// FetchSticker downloads the image referenced by a received sticker message
+func FetchSticker(content []byte, store MeshStorageDownloader) ([]byte, error) {
+	var msg protocol.StickerMessage
+	if err := msg.Decode(content); err != nil {
+		return nil, err
+	}
+
+	data, err := store.DownloadEncrypted(msg.ChunkID, msg.EncryptionKey[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to download sticker: %w", err)
+	}
+	return data, nil
+}
+
+// stickerPackMap returns installed packs, loading them from session storage on first use
+func (c *Client) stickerPackMap() (map[protocol.StickerPackID]*protocol.StickerPackManifest, error) {
+	if c.stickerPacks != nil {
+		return c.stickerPacks, nil
+	}
+
+	c.stickerPacks = make(map[protocol.StickerPackID]*protocol.StickerPackManifest)
+
+	path := c.stickerPacksPath()
+	if path == "" {
+		return c.stickerPacks, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c.stickerPacks, nil
+		}
+		return nil, fmt.Errorf("failed to read sticker packs: %w", err)
+	}
+
+	var stored map[string]*protocol.StickerPackManifest
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal sticker packs: %w", err)
+	}
+
+	for _, manifest := range stored {
+		c.stickerPacks[manifest.PackID] = manifest
+	}
+
+	return c.stickerPacks, nil
+}
+
+// saveStickerPack installs a manifest and persists the installed set
+func (c *Client) saveStickerPack(manifest *protocol.StickerPackManifest) error {
+	packs, err := c.stickerPackMap()
+	if err != nil {
+		return err
+	}
+
+	packs[manifest.PackID] = manifest
+	return c.persistStickerPacks()
+}
+
+// persistStickerPacks writes installed packs to session storage (no-op without storage)
+func (c *Client) persistStickerPacks() error {
+	path := c.stickerPacksPath()
+	if path == "" {
+		return nil
+	}
+
+	stored := make(map[string]*protocol.StickerPackManifest, len(c.stickerPacks))
+	for packID, manifest := range c.stickerPacks {
+		stored[hex.EncodeToString(packID[:])] = manifest
+	}
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal sticker packs: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write sticker packs: %w", err)
+	}
+
+	return nil
+}
+
+// stickerPacksPath returns where installed packs are persisted ("" if no session storage)
+func (c *Client) stickerPacksPath() string {
+	if c.sessionStorage == nil {
+		return ""
+	}
+	return filepath.Join(c.sessionStorage.storageDir, stickerPacksFile)
+}
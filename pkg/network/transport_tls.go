@@ -0,0 +1,233 @@
+package network
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+	"github.com/ZentaChain/zentalk-node/pkg/transport"
+)
+
+// ErrTLSUnavailable is returned when a client wants TLS and the relay doesn't offer it
+var ErrTLSUnavailable = errors.New("relay does not offer TLS")
+
+// identityCertLifetime is how long a relay's self-signed identity certificate is valid
+const identityCertLifetime = 10 * 365 * 24 * time.Hour
+
+// relayTLS is a relay's TLS configuration for client connections
+// A client opens its connection with a header-only StartTLS frame; the relay
+// answers StartTLSAck and both sides run a TLS handshake on the socket. The
+// protocol handshake (or session resumption) and every frame after it then
+// travel inside TLS, so observers see neither frame headers nor who connected.
+type relayTLS struct {
+	config  *tls.Config
+	require bool // Refuse users that handshake over plain TCP
+}
+
+// EnableTLS offers TLS to clients with cert
+// With require, users must switch to TLS before handshaking; plain TCP
+// handshakes are refused with RelayErrTLSRequired. Relay peers and WebSocket
+// connections (secured by wss:// in front of the relay) are not affected.
+func (rs *RelayServer) EnableTLS(cert tls.Certificate, require bool) {
+	rs.mu.Lock()
+	rs.tls = &relayTLS{
+		config: &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS13,
+		},
+		require: require,
+	}
+	rs.mu.Unlock()
+
+	log.Printf("🔒 TLS enabled for client connections (required: %v)", require)
+}
+
+// getTLS returns the TLS configuration (nil = disabled)
+func (rs *RelayServer) getTLS() *relayTLS {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.tls
+}
+
+// IdentityCertificate creates a self-signed TLS certificate for a relay's identity key
+// Relays without a CA-issued certificate use it; clients that know the relay's
+// public key (e.g. from relay discovery) verify it with PinRelayKey.
+func IdentityCertificate(key *rsa.PrivateKey) (tls.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "zentalk-relay"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(identityCertLifetime),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to create identity certificate: %w", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// PinRelayKey returns a client TLS config that only accepts a certificate for the relay's key
+// The certificate's name and issuer are not checked; the TLS handshake proves
+// the relay holds the private key.
+func PinRelayKey(pub *rsa.PublicKey) *tls.Config {
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS13,
+		InsecureSkipVerify: true, // Replaced by the key check below
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return fmt.Errorf("relay sent no certificate")
+			}
+			cert, err := x509.ParseCertificate(rawCerts[0])
+			if err != nil {
+				return err
+			}
+			if !pub.Equal(cert.PublicKey) {
+				return fmt.Errorf("relay certificate is not for the pinned key")
+			}
+			return nil
+		},
+	}
+}
+
+// handleStartTLS switches an accepted connection to TLS before its handshake
+// Returns the TLS connection, or nil if the relay refused and the connection
+// stays plain. An error means the connection must be closed.
+func (rs *RelayServer) handleStartTLS(conn net.Conn, header *protocol.Header, handshaken bool) (net.Conn, error) {
+	cfg := rs.getTLS()
+	if cfg == nil {
+		rs.sendRelayError(conn, header.MessageID, protocol.NewRelayError(protocol.RelayErrUnsupportedType,
+			"relay does not offer TLS"))
+		return nil, nil
+	}
+	if _, secured := conn.(*tls.Conn); secured || handshaken {
+		return nil, fmt.Errorf("StartTLS from %s after the connection was set up", conn.RemoteAddr())
+	}
+
+	ack := &protocol.Header{
+		Magic:     protocol.ProtocolMagic,
+		Version:   protocol.ProtocolVersion,
+		Type:      protocol.MsgTypeStartTLSAck,
+		MessageID: header.MessageID,
+	}
+	if err := protocol.WriteHeader(conn, ack); err != nil {
+		return nil, err
+	}
+
+	// The read deadline set for this frame's payload also bounds the TLS handshake
+	tlsConn := tls.Server(conn, cfg.config)
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, fmt.Errorf("TLS handshake with %s failed: %w", conn.RemoteAddr(), err)
+	}
+	return tlsConn, nil
+}
+
+// requiresTLS reports whether users on conn must switch to TLS before handshaking
+// Only plain TCP connections are refused when the relay requires TLS.
+func (rs *RelayServer) requiresTLS(conn net.Conn) bool {
+	cfg := rs.getTLS()
+	if cfg == nil || !cfg.require {
+		return false
+	}
+	_, plain := conn.(*net.TCPConn)
+	return plain
+}
+
+// EnableTLS switches every relay connection to TLS before the handshake
+// cfg verifies the relay's certificate; nil uses the system roots and the
+// relay's host name. Use PinRelayKey for relays with an identity certificate.
+// A relay that doesn't offer TLS fails the connection with ErrTLSUnavailable;
+// the client never falls back to plain TCP.
+func (c *Client) EnableTLS(cfg *tls.Config) {
+	if cfg == nil {
+		cfg = &tls.Config{MinVersion: tls.VersionTLS13}
+	}
+	c.tlsConfig = cfg
+}
+
+// startTLS switches the relay connection to TLS if the client wants it (caller holds writeMu)
+func (c *Client) startTLS(ctx context.Context) error {
+	if c.tlsConfig == nil {
+		return nil
+	}
+
+	header := &protocol.Header{
+		Magic:     protocol.ProtocolMagic,
+		Version:   protocol.ProtocolVersion,
+		Type:      protocol.MsgTypeStartTLS,
+		MessageID: protocol.GenerateMessageID(),
+	}
+	if err := protocol.WriteHeader(c.relayConn, header); err != nil {
+		return err
+	}
+
+	reply, err := protocol.ReadHeader(c.relayConn)
+	if err != nil {
+		return err
+	}
+	switch reply.Type {
+	case protocol.MsgTypeStartTLSAck:
+	case protocol.MsgTypeRelayError:
+		if err := c.payloadLimits.Check(reply); err != nil {
+			return err
+		}
+		payload := make([]byte, reply.Length)
+		if _, err := io.ReadFull(c.relayConn, payload); err != nil {
+			return err
+		}
+		var relayErr protocol.RelayErrorMessage
+		if err := relayErr.Decode(payload); err != nil {
+			return ErrTLSUnavailable
+		}
+		return fmt.Errorf("%w: %w", ErrTLSUnavailable, &relayErr)
+	default:
+		return fmt.Errorf("%w: relay answered StartTLS with type 0x%04x", ErrTLSUnavailable, reply.Type)
+	}
+
+	cfg := c.tlsConfig.Clone()
+	if cfg.ServerName == "" {
+		cfg.ServerName = relayHost(c.relayAddress)
+	}
+
+	tlsConn := tls.Client(c.relayConn, cfg)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return fmt.Errorf("TLS handshake failed: %w", err)
+	}
+	c.relayConn = tlsConn
+	return nil
+}
+
+// relayHost returns the host in a relay address (host:port or ws:// URL), or "" if there is none
+func relayHost(address string) string {
+	if transport.IsWebSocketURL(address) {
+		if u, err := url.Parse(address); err == nil {
+			return u.Hostname()
+		}
+		return ""
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return ""
+	}
+	return host
+}
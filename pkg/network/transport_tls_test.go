@@ -0,0 +1,165 @@
+package network
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/ZentaChain/zentalk-node/pkg/features"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// startTLSRelay starts a relay on localhost, with TLS on its identity key unless offer is false
+func startTLSRelay(t *testing.T, offer, require bool) (*RelayServer, string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := NewRelayServer(0, key)
+	rs.SetListenConfig(ListenConfig{Hosts: []string{"127.0.0.1"}})
+	if offer {
+		cert, err := IdentityCertificate(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs.EnableTLS(cert, require)
+	}
+	if err := rs.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { rs.Stop() })
+
+	return rs, fmt.Sprintf("127.0.0.1:%d", rs.Port)
+}
+
+// newTLSTestClient creates an unconnected client
+func newTLSTestClient(t *testing.T, addr byte) *Client {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(key)
+	c.Address[0] = addr
+	return c
+}
+
+func TestClientConnectsOverTLS(t *testing.T) {
+	rs, address := startTLSRelay(t, true, true)
+
+	c := newTLSTestClient(t, 1)
+	c.EnableTLS(PinRelayKey(rs.PublicKey))
+	if err := c.ConnectToRelay(address); err != nil {
+		t.Fatalf("ConnectToRelay() error = %v", err)
+	}
+	defer c.Disconnect()
+
+	if _, ok := c.relayConn.(*tls.Conn); !ok {
+		t.Fatalf("relay connection is %T, want *tls.Conn", c.relayConn)
+	}
+	if _, ok := c.RelayFeatures().Version(features.TLS); !ok {
+		t.Error("relay does not advertise the tls feature")
+	}
+	if err := c.SendPing(); err != nil {
+		t.Errorf("SendPing() over TLS error = %v", err)
+	}
+}
+
+func TestRelayRequiresTLS(t *testing.T) {
+	_, address := startTLSRelay(t, true, true)
+
+	c := newTLSTestClient(t, 2)
+	err := c.ConnectToRelay(address)
+
+	var relayErr *protocol.RelayErrorMessage
+	if !errors.As(err, &relayErr) || relayErr.Code != protocol.RelayErrTLSRequired {
+		t.Fatalf("plain ConnectToRelay() error = %v, want RelayErrTLSRequired", err)
+	}
+}
+
+func TestTLSOptional(t *testing.T) {
+	_, address := startTLSRelay(t, true, false)
+
+	// Plain clients still connect when TLS is offered but not required
+	c := newTLSTestClient(t, 3)
+	if err := c.ConnectToRelay(address); err != nil {
+		t.Fatalf("plain ConnectToRelay() error = %v", err)
+	}
+	c.Disconnect()
+}
+
+func TestClientRefusesWrongRelayKey(t *testing.T) {
+	_, address := startTLSRelay(t, true, false)
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTLSTestClient(t, 4)
+	c.EnableTLS(PinRelayKey(&other.PublicKey))
+	if err := c.ConnectToRelay(address); err == nil {
+		c.Disconnect()
+		t.Fatal("connected to a relay whose key is not the pinned one")
+	}
+}
+
+func TestClientWithoutTLSRelay(t *testing.T) {
+	rs, address := startTLSRelay(t, false, false)
+
+	c := newTLSTestClient(t, 5)
+	c.EnableTLS(PinRelayKey(rs.PublicKey))
+	if err := c.ConnectToRelay(address); !errors.Is(err, ErrTLSUnavailable) {
+		t.Fatalf("ConnectToRelay() error = %v, want ErrTLSUnavailable", err)
+	}
+}
+
+func TestClientConnectsOverWebSocket(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wsPort := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := NewRelayServer(0, key)
+	rs.SetListenConfig(ListenConfig{Hosts: []string{"127.0.0.1"}, WebSocketPort: wsPort})
+	cert, err := IdentityCertificate(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.EnableTLS(cert, true)
+	if err := rs.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { rs.Stop() })
+
+	// Requiring TLS leaves WebSocket clients alone, as wss:// is terminated in front of the relay
+	c := newTLSTestClient(t, 6)
+	if err := c.ConnectToRelay(fmt.Sprintf("ws://127.0.0.1:%d/ws", wsPort)); err != nil {
+		t.Fatalf("ConnectToRelay(ws://) error = %v", err)
+	}
+	defer c.Disconnect()
+
+	if _, ok := c.RelayFeatures().Version(features.WebSocket); !ok {
+		t.Error("relay does not advertise the websocket feature")
+	}
+	if err := c.SendPing(); err != nil {
+		t.Errorf("SendPing() over WebSocket error = %v", err)
+	}
+
+	if host := relayHost("wss://relay.example:443/ws"); host != "relay.example" {
+		t.Errorf("relayHost(wss://) = %q", host)
+	}
+}
@@ -14,10 +14,15 @@ import (
 
 // SendTypingIndicator sends a typing status notification
 func (c *Client) SendTypingIndicator(to protocol.Address, recipientPubKey *rsa.PublicKey, isTyping bool, relayPath []*crypto.RelayInfo) error {
-	if !c.connected {
+	if !c.connected.Load() {
 		return ErrNotConnected
 	}
 
+	// Don't reveal activity to a sender until their message request is accepted
+	if c.isPendingRequest(to) {
+		return ErrMessageRequestPending
+	}
+
 	// Create typing indicator
 	indicator := &protocol.TypingIndicator{
 		From:      c.Address,
@@ -26,8 +31,8 @@ func (c *Client) SendTypingIndicator(to protocol.Address, recipientPubKey *rsa.P
 		IsTyping:  isTyping,
 	}
 
-	// Encode
-	payload := indicator.Encode()
+	// Encode and tag with the message type
+	payload := protocol.TagPayload(protocol.MsgTypeTyping, indicator.Encode())
 
 	// Encrypt with recipient's public key
 	encryptedMsg, err := crypto.RSAEncrypt(payload, recipientPubKey)
@@ -36,27 +41,13 @@ func (c *Client) SendTypingIndicator(to protocol.Address, recipientPubKey *rsa.P
 	}
 
 	// Build onion layers
-	onion, err := crypto.BuildOnionLayers(relayPath, to, encryptedMsg)
+	onion, err := c.buildOnionLayers(relayPath, to, encryptedMsg)
 	if err != nil {
 		return err
 	}
 
-	// Create header (use RelayForward since it goes through onion routing)
-	header := &protocol.Header{
-		Magic:     protocol.ProtocolMagic,
-		Version:   protocol.ProtocolVersion,
-		Type:      protocol.MsgTypeRelayForward,
-		Length:    uint32(len(onion)),
-		Flags:     protocol.FlagEncrypted,
-		MessageID: protocol.GenerateMessageID(),
-	}
-
-	// Send to relay
-	if err := protocol.WriteHeader(c.relayConn, header); err != nil {
-		return err
-	}
-
-	if _, err := c.relayConn.Write(onion); err != nil {
+	// Send to relay (batched in low-power mode)
+	if err := c.sendControl(controlTyping, to, onion); err != nil {
 		return err
 	}
 
@@ -71,10 +62,15 @@ func (c *Client) SendTypingIndicator(to protocol.Address, recipientPubKey *rsa.P
 
 // SendReadReceipt sends a read receipt for a message
 func (c *Client) SendReadReceipt(to protocol.Address, recipientPubKey *rsa.PublicKey, messageID protocol.MessageID, readStatus uint8, relayPath []*crypto.RelayInfo) error {
-	if !c.connected {
+	if !c.connected.Load() {
 		return ErrNotConnected
 	}
 
+	// Reading a message request must not tell the sender it was seen
+	if c.isPendingRequest(to) {
+		return ErrMessageRequestPending
+	}
+
 	// Create read receipt
 	receipt := &protocol.ReadReceipt{
 		From:       c.Address,
@@ -84,8 +80,8 @@ func (c *Client) SendReadReceipt(to protocol.Address, recipientPubKey *rsa.Publi
 		ReadStatus: readStatus,
 	}
 
-	// Encode
-	payload := receipt.Encode()
+	// Encode and tag with the message type
+	payload := protocol.TagPayload(protocol.MsgTypeReadReceipt, receipt.Encode())
 
 	// Encrypt with recipient's public key
 	encryptedMsg, err := crypto.RSAEncrypt(payload, recipientPubKey)
@@ -94,27 +90,13 @@ func (c *Client) SendReadReceipt(to protocol.Address, recipientPubKey *rsa.Publi
 	}
 
 	// Build onion layers
-	onion, err := crypto.BuildOnionLayers(relayPath, to, encryptedMsg)
+	onion, err := c.buildOnionLayers(relayPath, to, encryptedMsg)
 	if err != nil {
 		return err
 	}
 
-	// Create header (use RelayForward since it goes through onion routing)
-	header := &protocol.Header{
-		Magic:     protocol.ProtocolMagic,
-		Version:   protocol.ProtocolVersion,
-		Type:      protocol.MsgTypeRelayForward,
-		Length:    uint32(len(onion)),
-		Flags:     protocol.FlagEncrypted,
-		MessageID: protocol.GenerateMessageID(),
-	}
-
-	// Send to relay
-	if err := protocol.WriteHeader(c.relayConn, header); err != nil {
-		return err
-	}
-
-	if _, err := c.relayConn.Write(onion); err != nil {
+	// Send to relay (batched in low-power mode)
+	if err := c.sendControl(controlReceipt, to, onion); err != nil {
 		return err
 	}
 
@@ -144,6 +126,53 @@ func (c *Client) MarkMessageAsRead(from protocol.Address, senderPubKey *rsa.Publ
 	return c.SendReadReceipt(from, senderPubKey, messageID, protocol.ReadStatusRead, relayPath)
 }
 
+// SendPresence sends our online status to a contact
+// In low-power mode only the latest status per contact is sent with the next batch.
+func (c *Client) SendPresence(to protocol.Address, recipientPubKey *rsa.PublicKey, status uint8, relayPath []*crypto.RelayInfo) error {
+	if !c.connected.Load() {
+		return ErrNotConnected
+	}
+
+	// Presence is activity too; keep it from senders we haven't accepted
+	if c.isPendingRequest(to) {
+		return ErrMessageRequestPending
+	}
+
+	// Presence is only shared between people
+	if c.IsBot() || c.isBotPeer(to) {
+		return ErrBotPresence
+	}
+
+	now := uint64(time.Now().UnixMilli())
+	update := &protocol.PresenceUpdate{
+		Address:   c.Address,
+		Status:    status,
+		LastSeen:  now,
+		Timestamp: now,
+	}
+
+	// Encrypt with recipient's public key
+	encryptedMsg, err := crypto.RSAEncrypt(protocol.TagPayload(protocol.MsgTypePresence, update.Encode()), recipientPubKey)
+	if err != nil {
+		return err
+	}
+
+	// Build onion layers
+	onion, err := c.buildOnionLayers(relayPath, to, encryptedMsg)
+	if err != nil {
+		return err
+	}
+
+	// Send to relay (coalesced in low-power mode)
+	if err := c.sendControl(controlPresence, to, onion); err != nil {
+		return err
+	}
+
+	log.Printf("🟢 Presence sent to %x (status: %d)", to[:8], status)
+
+	return nil
+}
+
 // handleTypingIndicator handles incoming typing indicators
 func (c *Client) handleTypingIndicator(header *protocol.Header) {
 	// Read payload
@@ -161,17 +190,31 @@ func (c *Client) handleTypingIndicator(header *protocol.Header) {
 	}
 
 	// Decode typing indicator
+	body, err := untagAs(decrypted, protocol.MsgTypeTyping)
+	if err != nil {
+		log.Printf("Decode typing indicator error: %v", err)
+		return
+	}
 	var indicator protocol.TypingIndicator
-	if err := indicator.Decode(decrypted); err != nil {
+	if err := indicator.Decode(body); err != nil {
 		log.Printf("Decode typing indicator error: %v", err)
 		return
 	}
+	c.deliverTypingIndicator(&indicator)
+}
 
+// deliverTypingIndicator passes a typing indicator meant for us to the application
+func (c *Client) deliverTypingIndicator(indicator *protocol.TypingIndicator) {
 	// Check if it's for us
 	if indicator.To != c.Address {
 		return
 	}
 
+	// Typing indicators only matter for accepted conversations
+	if c.classifySender(indicator.From) != senderKnown {
+		return
+	}
+
 	if indicator.IsTyping {
 		log.Printf("⌨️  %x is typing...", indicator.From[:8])
 	} else {
@@ -180,7 +223,7 @@ func (c *Client) handleTypingIndicator(header *protocol.Header) {
 
 	// Call callback
 	if c.OnTypingIndicator != nil {
-		c.OnTypingIndicator(&indicator)
+		c.OnTypingIndicator(indicator)
 	}
 }
 
@@ -201,12 +244,21 @@ func (c *Client) handleReadReceipt(header *protocol.Header) {
 	}
 
 	// Decode read receipt
+	body, err := untagAs(decrypted, protocol.MsgTypeReadReceipt)
+	if err != nil {
+		log.Printf("Decode read receipt error: %v", err)
+		return
+	}
 	var receipt protocol.ReadReceipt
-	if err := receipt.Decode(decrypted); err != nil {
+	if err := receipt.Decode(body); err != nil {
 		log.Printf("Decode read receipt error: %v", err)
 		return
 	}
+	c.deliverReadReceipt(&receipt)
+}
 
+// deliverReadReceipt records a read receipt meant for us and notifies the application
+func (c *Client) deliverReadReceipt(receipt *protocol.ReadReceipt) {
 	// Check if it's for us
 	if receipt.To != c.Address {
 		return
@@ -241,6 +293,54 @@ func (c *Client) handleReadReceipt(header *protocol.Header) {
 
 	// Call callback
 	if c.OnReadReceipt != nil {
-		c.OnReadReceipt(&receipt)
+		c.OnReadReceipt(receipt)
+	}
+}
+
+// handlePresence handles incoming presence updates
+func (c *Client) handlePresence(header *protocol.Header) {
+	// Read payload
+	payload := make([]byte, header.Length)
+	if _, err := io.ReadFull(c.relayConn, payload); err != nil {
+		log.Printf("Read payload error: %v", err)
+		return
+	}
+
+	// Decrypt with our private key
+	decrypted, err := crypto.RSADecrypt(payload, c.PrivateKey)
+	if err != nil {
+		log.Printf("Decrypt presence update error: %v", err)
+		return
+	}
+
+	// Decode presence update
+	body, err := untagAs(decrypted, protocol.MsgTypePresence)
+	if err != nil {
+		log.Printf("Decode presence update error: %v", err)
+		return
+	}
+	var update protocol.PresenceUpdate
+	if err := update.Decode(body); err != nil {
+		log.Printf("Decode presence update error: %v", err)
+		return
+	}
+	c.deliverPresence(&update)
+}
+
+// deliverPresence passes a contact's presence update to the application
+func (c *Client) deliverPresence(update *protocol.PresenceUpdate) {
+	// Presence only matters for accepted conversations
+	if c.classifySender(update.Address) != senderKnown {
+		return
+	}
+	if c.isBotPeer(update.Address) {
+		return
+	}
+
+	log.Printf("🟢 %x presence: %d", update.Address[:8], update.Status)
+
+	// Call callback
+	if c.OnPresence != nil {
+		c.OnPresence(update)
 	}
 }
@@ -0,0 +1,143 @@
+package network
+
+import (
+	"crypto/rand"
+	"log"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// UniformRecordConfig enables the uniform-records wire mode
+// Both ends request it with FlagUniformRecords in the handshake; once the ACK
+// confirms it, every frame is padded to one of protocol.RecordSizes and sent after
+// a random delay, so a passive observer can't tell acks, typing indicators and
+// messages apart by frame length or back-to-back timing.
+type UniformRecordConfig struct {
+	MaxJitter time.Duration // Upper bound of the random delay before each frame (0 = none)
+}
+
+// DefaultUniformRecordConfig returns the settings used by the -uniform-records flag
+func DefaultUniformRecordConfig() UniformRecordConfig {
+	return UniformRecordConfig{MaxJitter: 20 * time.Millisecond}
+}
+
+// EnableUniformRecords accepts and requests uniform records on relay links
+func (rs *RelayServer) EnableUniformRecords(cfg UniformRecordConfig) {
+	rs.mu.Lock()
+	rs.uniformRecords = &cfg
+	rs.mu.Unlock()
+
+	log.Printf("🧱 Uniform records enabled (jitter up to %s)", cfg.MaxJitter)
+}
+
+// getUniformRecords returns the uniform records config (nil = disabled)
+func (rs *RelayServer) getUniformRecords() *UniformRecordConfig {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.uniformRecords
+}
+
+// EnableUniformRecords requests uniform records from relays on the next connect
+// Relays without the mode ignore the request and the link stays plain.
+func (c *Client) EnableUniformRecords(cfg UniformRecordConfig) {
+	c.uniformRecords = &cfg
+}
+
+// uniformConn carries protocol frames as uniform records
+// Writes are buffered until a whole frame is present, so a header and its payload
+// written separately still share records and one jitter delay.
+type uniformConn struct {
+	net.Conn
+	reader    *protocol.RecordReader
+	maxJitter time.Duration
+
+	mu      sync.Mutex
+	pending []byte
+}
+
+// newUniformConn wraps a connection that has just switched to uniform records
+func newUniformConn(conn net.Conn, cfg UniformRecordConfig) *uniformConn {
+	return &uniformConn{
+		Conn:      conn,
+		reader:    protocol.NewRecordReader(conn),
+		maxJitter: cfg.MaxJitter,
+	}
+}
+
+// Read returns frame bytes with record framing and padding removed
+func (u *uniformConn) Read(p []byte) (int, error) {
+	return u.reader.Read(p)
+}
+
+// Write buffers p and sends every complete frame as padded records
+func (u *uniformConn) Write(p []byte) (int, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.pending = append(u.pending, p...)
+	for {
+		n := u.completeFrame()
+		if n == 0 {
+			return len(p), nil
+		}
+
+		records, err := protocol.EncodeRecords(u.pending[:n])
+		if err != nil {
+			return 0, err
+		}
+		u.jitter()
+		if _, err := u.Conn.Write(records); err != nil {
+			return 0, err
+		}
+		u.pending = u.pending[n:]
+	}
+}
+
+// completeFrame returns the length of the frame at the start of pending, or 0 if incomplete
+// Bytes that don't start with a valid header are flushed as they are.
+func (u *uniformConn) completeFrame() int {
+	if len(u.pending) == 0 {
+		return 0
+	}
+	var header protocol.Header
+	if header.Decode(u.pending) != nil {
+		return 0
+	}
+	if header.Magic != protocol.ProtocolMagic {
+		return len(u.pending)
+	}
+	n := protocol.HeaderSize + int(header.Length)
+	if len(u.pending) < n {
+		return 0
+	}
+	return n
+}
+
+// jitter sleeps a random time up to maxJitter
+func (u *uniformConn) jitter() {
+	if u.maxJitter <= 0 {
+		return
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(u.maxJitter)))
+	if err != nil {
+		return
+	}
+	time.Sleep(time.Duration(n.Int64()))
+}
+
+// recordsRequested reports whether a handshake header asks for uniform records
+func recordsRequested(header *protocol.Header) bool {
+	return header.HasFlag(protocol.FlagUniformRecords)
+}
+
+// recordFlag returns FlagUniformRecords if cfg is set
+func recordFlag(cfg *UniformRecordConfig) uint16 {
+	if cfg != nil {
+		return protocol.FlagUniformRecords
+	}
+	return 0
+}
@@ -0,0 +1,181 @@
+package network
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+	"github.com/ZentaChain/zentalk-node/pkg/storage"
+)
+
+const (
+	// VoiceNoteChunkSize is the size of each audio segment uploaded to MeshStorage
+	// Small enough that playback can start after the first segment arrives
+	VoiceNoteChunkSize = 64 * 1024
+
+	// voiceNotePrefetch is how many segments are downloaded ahead of playback
+	voiceNotePrefetch = 2
+)
+
+// SendVoiceNote uploads audio in segments and sends a voice note descriptor
+// waveform may be nil; use ComputeWaveform to build one from PCM samples
+func (c *Client) SendVoiceNote(to protocol.Address, recipientPubKey *rsa.PublicKey, audio []byte, mimeType string, duration time.Duration, waveform []byte, store MeshStorageUploader, relayPath []*crypto.RelayInfo) (*protocol.VoiceNoteMessage, error) {
+	if !c.connected.Load() {
+		return nil, ErrNotConnected
+	}
+
+	store = c.meterUploads(c.directUsage(to, storage.UsageMedia), store)
+	note, err := UploadVoiceNote(audio, mimeType, duration, waveform, store)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.SendMessage(to, recipientPubKey, note.Encode(), protocol.ContentTypeVoiceNote, relayPath); err != nil {
+		return nil, err
+	}
+
+	log.Printf("🎙️  Voice note sent to %x (%v, %d chunks)", to[:8], duration, len(note.Chunks))
+	return note, nil
+}
+
+// UploadVoiceNote splits audio into VoiceNoteChunkSize segments and uploads each encrypted
+func UploadVoiceNote(audio []byte, mimeType string, duration time.Duration, waveform []byte, store MeshStorageUploader) (*protocol.VoiceNoteMessage, error) {
+	if len(audio) == 0 {
+		return nil, fmt.Errorf("voice note is empty")
+	}
+
+	numChunks := (len(audio) + VoiceNoteChunkSize - 1) / VoiceNoteChunkSize
+	if numChunks > protocol.MaxVoiceNoteChunks {
+		return nil, fmt.Errorf("voice note too long: %d bytes (max %d)", len(audio), protocol.MaxVoiceNoteChunks*VoiceNoteChunkSize)
+	}
+
+	if len(waveform) > protocol.MaxVoiceNoteWaveform {
+		waveform = waveform[:protocol.MaxVoiceNoteWaveform]
+	}
+
+	note := &protocol.VoiceNoteMessage{
+		DurationMs: uint32(duration.Milliseconds()),
+		MIMEType:   mimeType,
+		Waveform:   waveform,
+		Chunks:     make([]protocol.VoiceNoteChunk, 0, numChunks),
+	}
+
+	for offset := 0; offset < len(audio); offset += VoiceNoteChunkSize {
+		end := offset + VoiceNoteChunkSize
+		if end > len(audio) {
+			end = len(audio)
+		}
+
+		chunkID, key, err := store.UploadEncrypted(audio[offset:end])
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload voice note segment %d: %w", len(note.Chunks), err)
+		}
+
+		chunk := protocol.VoiceNoteChunk{
+			ChunkID: chunkID,
+			Size:    uint32(end - offset),
+		}
+		copy(chunk.EncryptionKey[:], key)
+		note.Chunks = append(note.Chunks, chunk)
+	}
+
+	return note, nil
+}
+
+// voiceNoteSegment is a downloaded segment (or the error that stopped the download)
+type voiceNoteSegment struct {
+	data []byte
+	err  error
+}
+
+// StreamVoiceNote returns a reader over a voice note's audio that is filled as segments download
+// The first bytes are readable as soon as the first segment arrives; later segments are prefetched
+// Close the reader to abandon the download early
+func StreamVoiceNote(note *protocol.VoiceNoteMessage, store MeshStorageDownloader) io.ReadCloser {
+	pr, pw := io.Pipe()
+	segments := make(chan voiceNoteSegment, voiceNotePrefetch)
+	done := make(chan struct{})
+
+	// Downloader: fetches segments in order, staying at most voiceNotePrefetch ahead
+	go func() {
+		defer close(segments)
+		for i, chunk := range note.Chunks {
+			data, err := store.DownloadEncrypted(chunk.ChunkID, chunk.EncryptionKey[:])
+			if err != nil {
+				err = fmt.Errorf("failed to download voice note segment %d: %w", i, err)
+			}
+
+			select {
+			case segments <- voiceNoteSegment{data: data, err: err}:
+			case <-done:
+				return
+			}
+
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	// Writer: feeds segments to the pipe in playback order
+	go func() {
+		defer close(done)
+		for segment := range segments {
+			if segment.err != nil {
+				pw.CloseWithError(segment.err)
+				return
+			}
+			if _, err := pw.Write(segment.data); err != nil {
+				return // Reader closed
+			}
+		}
+		pw.Close()
+	}()
+
+	return pr
+}
+
+// FetchVoiceNote downloads the full audio of a voice note
+func FetchVoiceNote(note *protocol.VoiceNoteMessage, store MeshStorageDownloader) ([]byte, error) {
+	stream := StreamVoiceNote(note, store)
+	defer stream.Close()
+	return io.ReadAll(stream)
+}
+
+// ComputeWaveform reduces 16-bit PCM samples to buckets peak amplitudes (0-255)
+func ComputeWaveform(samples []int16, buckets int) []byte {
+	if buckets <= 0 || len(samples) == 0 {
+		return nil
+	}
+	if buckets > protocol.MaxVoiceNoteWaveform {
+		buckets = protocol.MaxVoiceNoteWaveform
+	}
+	if buckets > len(samples) {
+		buckets = len(samples)
+	}
+
+	waveform := make([]byte, buckets)
+	for b := 0; b < buckets; b++ {
+		start := b * len(samples) / buckets
+		end := (b + 1) * len(samples) / buckets
+
+		var peak int32
+		for _, s := range samples[start:end] {
+			v := int32(s)
+			if v < 0 {
+				v = -v
+			}
+			if v > peak {
+				peak = v
+			}
+		}
+
+		waveform[b] = byte(peak * 255 / 32768)
+	}
+
+	return waveform
+}
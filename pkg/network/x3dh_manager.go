@@ -4,11 +4,17 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"maps"
 	"time"
 
 	"github.com/ZentaChain/zentalk-node/pkg/protocol"
 )
 
+var (
+	ErrX3DHNotInitialized = errors.New("X3DH not initialized - call InitializeX3DH() first")
+	ErrNoKeyBundle        = errors.New("no key bundle")
+)
+
 // InitializeX3DH initializes X3DH identity and prekeys for forward secrecy
 // Returns error if key generation fails
 func (c *Client) InitializeX3DH() error {
@@ -17,6 +23,20 @@ func (c *Client) InitializeX3DH() error {
 	if err != nil {
 		return fmt.Errorf("failed to generate identity keypair: %w", err)
 	}
+	return c.initializeX3DH(identity)
+}
+
+// RestoreX3DHIdentity initializes X3DH around an existing identity, e.g. one
+// restored by social recovery, so contacts keep their sessions' identity key
+func (c *Client) RestoreX3DHIdentity(identity *protocol.IdentityKeyPair) error {
+	return c.initializeX3DH(identity)
+}
+
+// initializeX3DH generates fresh prekeys for an identity
+func (c *Client) initializeX3DH(identity *protocol.IdentityKeyPair) error {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+
 	c.x3dhIdentity = identity
 
 	// Generate signed prekey
@@ -35,6 +55,7 @@ func (c *Client) InitializeX3DH() error {
 	// Store one-time prekeys in map
 	for _, opk := range oneTimePreKeys {
 		c.oneTimePreKeys[opk.KeyID] = opk
+		c.lastPreKeyID = max(c.lastPreKeyID, opk.KeyID)
 	}
 
 	// Generate random registration ID (use timestamp + random component)
@@ -54,8 +75,11 @@ func (c *Client) InitializeX3DH() error {
 // GetKeyBundle returns the client's key bundle for X3DH key agreement
 // This should be published to a key server or shared directly with contacts
 func (c *Client) GetKeyBundle() (*protocol.KeyBundle, error) {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+
 	if c.x3dhIdentity == nil || c.signedPreKey == nil {
-		return nil, errors.New("X3DH not initialized - call InitializeX3DH() first")
+		return nil, ErrX3DHNotInitialized
 	}
 
 	// Convert one-time prekeys to slice
@@ -72,62 +96,118 @@ func (c *Client) GetKeyBundle() (*protocol.KeyBundle, error) {
 		opks,
 		c.registrationID,
 	)
+	bundle.CipherSuites = c.cipherSuitesLocked()
 
 	return bundle, nil
 }
 
+// SetCipherSuites sets the ratchet cipher suites we accept, preferred first
+// Devices without AES hardware can put ChaCha20-Poly1305 first; by default the
+// order is chosen for this CPU. Applies to sessions set up afterwards.
+func (c *Client) SetCipherSuites(suites ...protocol.CipherSuite) error {
+	for _, suite := range suites {
+		if _, ok := protocol.LookupCipherSuite(suite); !ok {
+			return fmt.Errorf("%w: %d", protocol.ErrUnknownCipherSuite, suite)
+		}
+	}
+
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	if len(suites) == 0 {
+		c.cipherSuites = nil
+	} else {
+		c.cipherSuites = append([]protocol.CipherSuite(nil), suites...)
+	}
+	return nil
+}
+
+// CipherSuites returns the ratchet cipher suites we accept, preferred first
+func (c *Client) CipherSuites() []protocol.CipherSuite {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	return c.cipherSuitesLocked()
+}
+
+// cipherSuitesLocked returns our cipher suite preference (caller holds sessionMu)
+func (c *Client) cipherSuitesLocked() []protocol.CipherSuite {
+	if c.cipherSuites == nil {
+		return protocol.PreferredCipherSuites()
+	}
+	return append([]protocol.CipherSuite(nil), c.cipherSuites...)
+}
+
 // RefillOneTimePreKeys generates additional one-time prekeys if the pool is low
 func (c *Client) RefillOneTimePreKeys(threshold int) error {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+
 	if len(c.oneTimePreKeys) >= threshold {
 		return nil // Pool is sufficient
 	}
 
-	// Find highest existing key ID
-	maxID := uint32(0)
+	// Generate 50 new keys starting after the highest ID
+	if err := c.addOneTimePreKeysLocked(50); err != nil {
+		return err
+	}
+
+	log.Printf("✅ Refilled one-time prekeys: now have %d keys", len(c.oneTimePreKeys))
+
+	// Persist X3DH state if storage is attached
+	if err := c.saveX3DHState(); err != nil {
+		log.Printf("⚠️  Failed to persist X3DH state after refill: %v", err)
+	}
+
+	return nil
+}
+
+// addOneTimePreKeysLocked generates count one-time prekeys numbered after the highest we hold (caller holds sessionMu)
+// IDs only grow, even after every key has been used: relays refuse a key ID
+// they have already handed out.
+func (c *Client) addOneTimePreKeysLocked(count int) error {
+	maxID := c.lastPreKeyID
 	for id := range c.oneTimePreKeys {
 		if id > maxID {
 			maxID = id
 		}
 	}
 
-	// Generate 50 new keys starting after the highest ID
-	newKeys, err := protocol.GenerateOneTimePreKeys(maxID+1, 50)
+	newKeys, err := protocol.GenerateOneTimePreKeys(maxID+1, count)
 	if err != nil {
 		return fmt.Errorf("failed to generate one-time prekeys: %w", err)
 	}
-
-	// Add to map
 	for _, opk := range newKeys {
 		c.oneTimePreKeys[opk.KeyID] = opk
 	}
-
-	log.Printf("✅ Refilled one-time prekeys: now have %d keys", len(c.oneTimePreKeys))
-
-	// Persist X3DH state if storage is attached
-	if err := c.saveX3DHState(); err != nil {
-		log.Printf("⚠️  Failed to persist X3DH state after refill: %v", err)
-	}
-
+	c.lastPreKeyID = maxID + uint32(count)
 	return nil
 }
 
 // GetX3DHIdentity returns the client's X3DH identity
 func (c *Client) GetX3DHIdentity() *protocol.IdentityKeyPair {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
 	return c.x3dhIdentity
 }
 
 // GetSignedPreKey returns the client's signed prekey
 func (c *Client) GetSignedPreKey() *protocol.SignedPreKeyPrivate {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
 	return c.signedPreKey
 }
 
-// GetOneTimePreKeys returns the client's one-time prekeys map
+// GetOneTimePreKeys returns a copy of the client's one-time prekeys map
 func (c *Client) GetOneTimePreKeys() map[uint32]*protocol.OneTimePreKeyPrivate {
-	return c.oneTimePreKeys
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	return maps.Clone(c.oneTimePreKeys)
 }
 
 // CacheKeyBundle stores a key bundle for a user
 func (c *Client) CacheKeyBundle(addr protocol.Address, bundle *protocol.KeyBundle) {
+	c.keyBundleMu.Lock()
+	defer c.keyBundleMu.Unlock()
+
 	c.keyBundleCache[addr] = bundle
 	log.Printf("✅ Key bundle cached for %x (OPKs: %d)", addr[:8], len(bundle.OneTimePreKeys))
 
@@ -141,18 +221,25 @@ func (c *Client) CacheKeyBundle(addr protocol.Address, bundle *protocol.KeyBundl
 
 // GetCachedKeyBundle retrieves a cached key bundle
 func (c *Client) GetCachedKeyBundle(addr protocol.Address) (*protocol.KeyBundle, bool) {
+	c.keyBundleMu.RLock()
+	defer c.keyBundleMu.RUnlock()
 	bundle, exists := c.keyBundleCache[addr]
 	return bundle, exists
 }
 
 // ClearKeyBundleCache clears all cached key bundles
 func (c *Client) ClearKeyBundleCache() {
+	c.keyBundleMu.Lock()
+	defer c.keyBundleMu.Unlock()
 	c.keyBundleCache = make(map[protocol.Address]*protocol.KeyBundle)
 	log.Printf("Key bundle cache cleared")
 }
 
 // RemoveCachedKeyBundle removes a specific key bundle from cache
 func (c *Client) RemoveCachedKeyBundle(addr protocol.Address) {
+	c.keyBundleMu.Lock()
+	defer c.keyBundleMu.Unlock()
+
 	delete(c.keyBundleCache, addr)
 	log.Printf("Key bundle removed from cache: %x", addr[:8])
 
@@ -164,7 +251,7 @@ func (c *Client) RemoveCachedKeyBundle(addr protocol.Address) {
 	}
 }
 
-// saveX3DHState saves the current X3DH state to disk
+// saveX3DHState saves the current X3DH state to disk (caller holds sessionMu)
 func (c *Client) saveX3DHState() error {
 	if c.sessionStorage == nil {
 		return nil // No storage attached
@@ -181,6 +268,7 @@ func (c *Client) saveX3DHState() error {
 		SignedPreKey:    c.signedPreKey,
 		OneTimePreKeys:  opkMap,
 		RegistrationID:  c.registrationID,
+		LastPreKeyID:    c.lastPreKeyID,
 	}
 
 	return c.sessionStorage.SaveX3DHState(state)
@@ -202,9 +290,12 @@ func (c *Client) loadX3DHState() error {
 	}
 
 	// Restore X3DH state
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
 	c.x3dhIdentity = state.IdentityKeyPair
 	c.signedPreKey = state.SignedPreKey
 	c.registrationID = state.RegistrationID
+	c.lastPreKeyID = state.LastPreKeyID
 
 	// Convert string-keyed map back to uint32-keyed map
 	c.oneTimePreKeys = make(map[uint32]*protocol.OneTimePreKeyPrivate)
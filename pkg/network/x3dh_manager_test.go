@@ -0,0 +1,39 @@
+package network
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+func TestDisabledCipherSuiteRejected(t *testing.T) {
+	alice, _ := newTestClient(t, 0xa1)
+	bob, _ := newTestClient(t, 0xb0)
+
+	// Alice starts a session with a bundle Bob published while he still offered AES-GCM
+	bundle, err := bob.GetKeyBundle()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, _, initialMsg, err := protocol.X3DHInitiator(alice.Address, alice.x3dhIdentity, bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	initialMsg.CipherSuite = protocol.CipherSuiteAES256GCM
+
+	if err := bob.SetCipherSuites(protocol.CipherSuiteChaCha20Poly1305); err != nil {
+		t.Fatal(err)
+	}
+	if err := bob.InitializeRatchetSession(alice.Address, initialMsg); !errors.Is(err, protocol.ErrCipherSuiteRejected) {
+		t.Fatalf("InitializeRatchetSession() error = %v, want ErrCipherSuiteRejected", err)
+	}
+	if _, ok := bob.GetRatchetSession(alice.Address); ok {
+		t.Error("a session was set up with a disabled cipher suite")
+	}
+
+	initialMsg.CipherSuite = protocol.CipherSuiteChaCha20Poly1305
+	if err := bob.InitializeRatchetSession(alice.Address, initialMsg); err != nil {
+		t.Errorf("InitializeRatchetSession() with an enabled suite error = %v", err)
+	}
+}
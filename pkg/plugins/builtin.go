@@ -0,0 +1,162 @@
+package plugins
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/network"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+func init() {
+	Register("autoreply", func() Plugin { return &AutoReply{} })
+	Register("keywords", func() Plugin { return &KeywordFilter{} })
+	Register("archive", func() Plugin { return &Archiver{} })
+}
+
+// decodeConfig unmarshals a manifest's config, allowing it to be absent
+func decodeConfig(config json.RawMessage, v any) error {
+	if len(config) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(config, v); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	return nil
+}
+
+// AutoReply answers messages with a fixed text
+// Config: {"text": "..."}. Needs the "reply" capability; each sender gets at
+// most one reply per DefaultReplyInterval.
+type AutoReply struct {
+	host Host
+	text string
+}
+
+// Init implements Plugin
+func (p *AutoReply) Init(host Host, config json.RawMessage) error {
+	var c struct {
+		Text string `json:"text"`
+	}
+	if err := decodeConfig(config, &c); err != nil {
+		return err
+	}
+	if c.Text == "" {
+		return errors.New("config.text is required")
+	}
+	p.host = host
+	p.text = c.Text
+	return nil
+}
+
+// HandleMessage implements Plugin
+func (p *AutoReply) HandleMessage(msg *Message) error {
+	err := p.host.Reply(msg.From, p.text)
+	if errors.Is(err, ErrReplyRateLimited) {
+		return nil
+	}
+	return err
+}
+
+// KeywordFilter drops or flags text messages containing any of a list of words
+// Config: {"words": ["..."], "verdict": "drop" | "flag", "unknown_only": true}.
+// Matching ignores case. Needs the "filter" capability.
+type KeywordFilter struct {
+	words       []string
+	verdict     network.FilterVerdict
+	unknownOnly bool
+}
+
+// Init implements Plugin
+func (p *KeywordFilter) Init(host Host, config json.RawMessage) error {
+	var c struct {
+		Words       []string `json:"words"`
+		Verdict     string   `json:"verdict"`
+		UnknownOnly bool     `json:"unknown_only"`
+	}
+	if err := decodeConfig(config, &c); err != nil {
+		return err
+	}
+	if len(c.Words) == 0 {
+		return errors.New("config.words is required")
+	}
+
+	switch c.Verdict {
+	case "", "flag":
+		p.verdict = network.FilterFlag
+	case "drop":
+		p.verdict = network.FilterDrop
+	default:
+		return fmt.Errorf("config.verdict must be drop or flag, got %q", c.Verdict)
+	}
+	for _, w := range c.Words {
+		if w = strings.ToLower(strings.TrimSpace(w)); w != "" {
+			p.words = append(p.words, w)
+		}
+	}
+	p.unknownOnly = c.UnknownOnly
+	return nil
+}
+
+// FilterMessage implements Filter
+func (p *KeywordFilter) FilterMessage(msg *Message) network.FilterDecision {
+	if p.unknownOnly && msg.KnownSender {
+		return network.FilterDecision{Verdict: network.FilterAccept}
+	}
+
+	text := strings.ToLower(msg.Text())
+	for _, w := range p.words {
+		if strings.Contains(text, w) {
+			return network.FilterDecision{Verdict: p.verdict, Score: 0.8, Reason: fmt.Sprintf("contains %q", w)}
+		}
+	}
+	return network.FilterDecision{Verdict: network.FilterAccept}
+}
+
+// HandleMessage implements Plugin
+func (p *KeywordFilter) HandleMessage(msg *Message) error {
+	return nil
+}
+
+// Archiver appends each message to a JSON-lines file per sender
+// Needs the "archive" capability; files are named <sender hex>.jsonl.
+type Archiver struct {
+	host Host
+}
+
+// archiveEntry is one archived message
+type archiveEntry struct {
+	Time        time.Time `json:"time"`
+	ContentType string    `json:"content_type"`
+	Text        string    `json:"text,omitempty"`
+	Content     []byte    `json:"content,omitempty"` // Non-text content, base64
+}
+
+// Init implements Plugin
+func (p *Archiver) Init(host Host, config json.RawMessage) error {
+	p.host = host
+	return nil
+}
+
+// HandleMessage implements Plugin
+func (p *Archiver) HandleMessage(msg *Message) error {
+	entry := archiveEntry{
+		Time:        msg.Timestamp.UTC(),
+		ContentType: protocol.ContentTypeName(msg.ContentType),
+	}
+	if msg.ContentType == protocol.ContentTypeText {
+		entry.Text = msg.Text()
+	} else {
+		entry.Content = msg.Content
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return p.host.Archive(hex.EncodeToString(msg.From[:])+".jsonl", append(line, '\n'))
+}
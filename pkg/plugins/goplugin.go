@@ -0,0 +1,27 @@
+package plugins
+
+import (
+	"fmt"
+	"plugin"
+)
+
+// openGoPlugin loads a Go plugin and calls its New function
+// The plugin package only works where cgo is available (Linux, macOS, FreeBSD);
+// elsewhere Open returns an error and only builtin plugins can be used.
+func openGoPlugin(path string) (Plugin, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open plugin: %w", err)
+	}
+
+	sym, err := p.Lookup("New")
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", path, err)
+	}
+	newPlugin, ok := sym.(func() Plugin)
+	if !ok {
+		return nil, fmt.Errorf("plugin %s: New is %T, want func() plugins.Plugin", path, sym)
+	}
+
+	return newPlugin(), nil
+}
@@ -0,0 +1,172 @@
+package plugins
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/network"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+	"github.com/ZentaChain/zentalk-node/pkg/storage"
+)
+
+const (
+	// DefaultReplyInterval is the shortest time between two replies from one plugin to one sender
+	// It keeps two auto-responders from answering each other forever.
+	DefaultReplyInterval = 10 * time.Minute
+
+	// MaxArchiveFileSize caps each file a plugin archives to
+	MaxArchiveFileSize = 64 * 1024 * 1024
+)
+
+// Sender sends plugin replies
+type Sender interface {
+	SendText(to protocol.Address, text string) error
+}
+
+// pluginHost implements Host for one plugin
+type pluginHost struct {
+	name          string
+	caps          map[Capability]bool
+	sender        Sender
+	dataDir       string
+	replyInterval time.Duration
+
+	root      *os.Root // Opened on first Archive
+	lastReply map[protocol.Address]time.Time
+	mu        sync.Mutex
+}
+
+// newPluginHost creates the host for a manifest
+func newPluginHost(manifest *Manifest, sender Sender, dataDir string) *pluginHost {
+	h := &pluginHost{
+		name:          manifest.Name,
+		caps:          make(map[Capability]bool),
+		sender:        sender,
+		dataDir:       filepath.Join(dataDir, manifest.Name),
+		replyInterval: DefaultReplyInterval,
+		lastReply:     make(map[protocol.Address]time.Time),
+	}
+	for _, c := range manifest.Capabilities {
+		h.caps[c] = true
+	}
+	return h
+}
+
+// Name implements Host
+func (h *pluginHost) Name() string {
+	return h.name
+}
+
+// Reply implements Host
+func (h *pluginHost) Reply(to protocol.Address, text string) error {
+	if !h.caps[CapReply] {
+		return fmt.Errorf("%w: %s", ErrNotPermitted, CapReply)
+	}
+	if h.sender == nil {
+		return fmt.Errorf("replies are not available in this daemon")
+	}
+
+	h.mu.Lock()
+	now := time.Now()
+	if last, ok := h.lastReply[to]; ok && now.Sub(last) < h.replyInterval {
+		h.mu.Unlock()
+		return ErrReplyRateLimited
+	}
+	h.lastReply[to] = now
+	for addr, t := range h.lastReply {
+		if now.Sub(t) >= h.replyInterval {
+			delete(h.lastReply, addr)
+		}
+	}
+	h.mu.Unlock()
+
+	return h.sender.SendText(to, text)
+}
+
+// Archive implements Host
+func (h *pluginHost) Archive(name string, data []byte) error {
+	if !h.caps[CapArchive] {
+		return fmt.Errorf("%w: %s", ErrNotPermitted, CapArchive)
+	}
+	if name == "" || filepath.Base(name) != name {
+		return fmt.Errorf("invalid archive file name %q", name)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.root == nil {
+		if err := os.MkdirAll(h.dataDir, 0700); err != nil {
+			return fmt.Errorf("failed to create plugin data directory: %w", err)
+		}
+		root, err := os.OpenRoot(h.dataDir)
+		if err != nil {
+			return fmt.Errorf("failed to open plugin data directory: %w", err)
+		}
+		h.root = root
+	}
+
+	// os.Root refuses paths (and symlinks) leading outside the data directory
+	f, err := h.root.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if info, err := f.Stat(); err == nil && info.Size()+int64(len(data)) > MaxArchiveFileSize {
+		return fmt.Errorf("archive file %s would exceed %d bytes", name, MaxArchiveFileSize)
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+// Logf implements Host
+func (h *pluginHost) Logf(format string, args ...any) {
+	log.Printf("🧩 [%s] %s", h.name, fmt.Sprintf(format, args...))
+}
+
+// close releases the data directory
+func (h *pluginHost) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.root != nil {
+		h.root.Close()
+		h.root = nil
+	}
+}
+
+// ContactSender sends replies through a client to senders in its contact list
+// Replies to addresses without a stored public key fail.
+type ContactSender struct {
+	client *network.Client
+	db     *storage.MessageDB
+}
+
+// NewContactSender creates a sender that looks up recipients' keys in db
+func NewContactSender(client *network.Client, db *storage.MessageDB) *ContactSender {
+	return &ContactSender{client: client, db: db}
+}
+
+// SendText implements Sender
+func (s *ContactSender) SendText(to protocol.Address, text string) error {
+	contact, err := s.db.GetContact(hex.EncodeToString(to[:]))
+	if err != nil {
+		return fmt.Errorf("no contact for %x: %w", to[:8], err)
+	}
+	publicKey, err := crypto.ImportPublicKeyPEM(contact.PublicKey)
+	if err != nil {
+		return fmt.Errorf("contact %x has no usable public key: %w", to[:8], err)
+	}
+
+	path, err := s.client.BuildPolicyRelayPath()
+	if err != nil {
+		return err
+	}
+	return s.client.SendTextMessage(to, publicKey, text, path)
+}
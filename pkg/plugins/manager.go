@@ -0,0 +1,292 @@
+package plugins
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/network"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+const (
+	// FilterTimeout bounds one plugin's FilterMessage; slower filters count as accepting
+	FilterTimeout = 200 * time.Millisecond
+
+	// pluginQueueSize is the number of messages buffered per plugin
+	pluginQueueSize = 128
+)
+
+// Manager runs loaded plugins
+// Add it to the client with AddMessageFilter and call HandleDirectMessage from
+// OnMessageReceived.
+type Manager struct {
+	sender  Sender
+	dataDir string
+	plugins []*loadedPlugin
+
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+	running  bool
+	mu       sync.Mutex
+}
+
+// loadedPlugin is a plugin with its manifest's restrictions
+type loadedPlugin struct {
+	manifest     *Manifest
+	plugin       Plugin
+	filter       Filter // nil unless the plugin filters and holds CapFilter
+	host         *pluginHost
+	senders      map[protocol.Address]bool
+	contentTypes map[string]bool
+	queue        chan *Message
+}
+
+// NewManager creates a manager; replies go through sender (nil = no replies)
+// Each plugin's archive files live in dataDir/<plugin name>.
+func NewManager(sender Sender, dataDir string) *Manager {
+	return &Manager{
+		sender:   sender,
+		dataDir:  dataDir,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Load creates and initializes the plugin a manifest describes
+func (m *Manager) Load(manifest *Manifest) error {
+	if manifest.Name == "" || strings.ContainsAny(manifest.Name, `/\`) || manifest.Name == "." || manifest.Name == ".." {
+		return fmt.Errorf("invalid plugin name %q", manifest.Name)
+	}
+	for _, lp := range m.plugins {
+		if lp.manifest.Name == manifest.Name {
+			return fmt.Errorf("plugin %s is already loaded", manifest.Name)
+		}
+	}
+	for _, c := range manifest.Capabilities {
+		if c != CapReply && c != CapFilter && c != CapArchive {
+			return fmt.Errorf("plugin %s: unknown capability %q", manifest.Name, c)
+		}
+	}
+
+	lp := &loadedPlugin{
+		manifest:     manifest,
+		senders:      make(map[protocol.Address]bool),
+		contentTypes: make(map[string]bool),
+		queue:        make(chan *Message, pluginQueueSize),
+	}
+	for _, s := range manifest.Senders {
+		b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+		var addr protocol.Address
+		if err != nil || len(b) != len(addr) {
+			return fmt.Errorf("plugin %s: invalid sender %q", manifest.Name, s)
+		}
+		copy(addr[:], b)
+		lp.senders[addr] = true
+	}
+	for _, name := range manifest.ContentTypes {
+		lp.contentTypes[strings.ToLower(name)] = true
+	}
+
+	var err error
+	switch {
+	case manifest.Builtin != "" && manifest.Path != "":
+		return fmt.Errorf("plugin %s: set either builtin or path, not both", manifest.Name)
+	case manifest.Builtin != "":
+		lp.plugin, err = newBuiltin(manifest.Builtin)
+	case manifest.Path != "":
+		lp.plugin, err = openGoPlugin(manifest.Path)
+	default:
+		return fmt.Errorf("plugin %s: builtin or path is required", manifest.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("plugin %s: %w", manifest.Name, err)
+	}
+
+	lp.host = newPluginHost(manifest, m.sender, m.dataDir)
+	if err := lp.plugin.Init(lp.host, manifest.Config); err != nil {
+		return fmt.Errorf("plugin %s: init failed: %w", manifest.Name, err)
+	}
+	if f, ok := lp.plugin.(Filter); ok {
+		if lp.host.caps[CapFilter] {
+			lp.filter = f
+		} else {
+			log.Printf("⚠️  Plugin %s filters messages but lacks the %q capability; its verdicts are ignored", manifest.Name, CapFilter)
+		}
+	}
+
+	m.plugins = append(m.plugins, lp)
+	log.Printf("🧩 Loaded plugin %s (capabilities: %v)", manifest.Name, manifest.Capabilities)
+	return nil
+}
+
+// LoadDir loads every plugin manifest in dir
+func (m *Manager) LoadDir(dir string) error {
+	manifests, err := LoadManifests(dir)
+	if err != nil {
+		return err
+	}
+	for _, manifest := range manifests {
+		if err := m.Load(manifest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Count returns the number of loaded plugins
+func (m *Manager) Count() int {
+	return len(m.plugins)
+}
+
+// Start starts one worker per plugin
+func (m *Manager) Start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.running {
+		return
+	}
+	m.running = true
+
+	for _, lp := range m.plugins {
+		m.wg.Add(1)
+		go m.worker(lp)
+	}
+}
+
+// Stop stops the workers and closes plugins; queued messages are dropped
+func (m *Manager) Stop() {
+	m.mu.Lock()
+	if !m.running {
+		m.mu.Unlock()
+		return
+	}
+	m.running = false
+	close(m.stopChan)
+	m.mu.Unlock()
+
+	m.wg.Wait()
+
+	for _, lp := range m.plugins {
+		if c, ok := lp.plugin.(Closer); ok {
+			if err := c.Close(); err != nil {
+				log.Printf("⚠️  Plugin %s failed to close: %v", lp.manifest.Name, err)
+			}
+		}
+		lp.host.close()
+	}
+}
+
+// FilterMessage implements network.MessageFilter by asking filtering plugins
+// The strictest verdict wins, as with the client's other filters.
+func (m *Manager) FilterMessage(msg *protocol.DirectMessage, ctx network.FilterContext) network.FilterDecision {
+	decision := network.FilterDecision{Verdict: network.FilterAccept}
+
+	pm := newMessage(msg)
+	pm.KnownSender = ctx.KnownSender
+
+	for _, lp := range m.plugins {
+		if lp.filter == nil || !lp.matches(pm) {
+			continue
+		}
+
+		d := lp.runFilter(pm)
+		if d.Verdict > decision.Verdict {
+			decision = d
+			decision.Reason = lp.manifest.Name + ": " + d.Reason
+		}
+		if decision.Verdict == network.FilterDrop {
+			break
+		}
+	}
+
+	return decision
+}
+
+// HandleDirectMessage queues a delivered message for the plugins it matches
+func (m *Manager) HandleDirectMessage(msg *protocol.DirectMessage) {
+	pm := newMessage(msg)
+	for _, lp := range m.plugins {
+		if !lp.matches(pm) {
+			continue
+		}
+		select {
+		case lp.queue <- pm:
+		default:
+			log.Printf("⚠️  Plugin %s queue full, dropping message from %x", lp.manifest.Name, msg.From[:8])
+		}
+	}
+}
+
+// worker hands queued messages to one plugin
+func (m *Manager) worker(lp *loadedPlugin) {
+	defer m.wg.Done()
+
+	for {
+		select {
+		case <-m.stopChan:
+			return
+		case msg := <-lp.queue:
+			lp.handle(msg)
+		}
+	}
+}
+
+// matches reports whether the manifest's sender and content type lists allow msg
+func (lp *loadedPlugin) matches(msg *Message) bool {
+	if len(lp.senders) > 0 && !lp.senders[msg.From] {
+		return false
+	}
+	if len(lp.contentTypes) > 0 && !lp.contentTypes[protocol.ContentTypeName(msg.ContentType)] {
+		return false
+	}
+	return true
+}
+
+// handle runs HandleMessage, containing panics
+func (lp *loadedPlugin) handle(msg *Message) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("❌ Plugin %s panicked: %v", lp.manifest.Name, r)
+		}
+	}()
+
+	if err := lp.plugin.HandleMessage(msg); err != nil {
+		log.Printf("⚠️  Plugin %s failed to handle message from %x: %v", lp.manifest.Name, msg.From[:8], err)
+	}
+}
+
+// runFilter runs FilterMessage with a time limit, containing panics
+func (lp *loadedPlugin) runFilter(msg *Message) network.FilterDecision {
+	result := make(chan network.FilterDecision, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("❌ Plugin %s panicked in filter: %v", lp.manifest.Name, r)
+				result <- network.FilterDecision{Verdict: network.FilterAccept}
+			}
+		}()
+		result <- lp.filter.FilterMessage(msg)
+	}()
+
+	select {
+	case d := <-result:
+		return d
+	case <-time.After(FilterTimeout):
+		log.Printf("⚠️  Plugin %s filter timed out, accepting message", lp.manifest.Name)
+		return network.FilterDecision{Verdict: network.FilterAccept}
+	}
+}
+
+// newMessage copies a direct message for plugins
+func newMessage(msg *protocol.DirectMessage) *Message {
+	return &Message{
+		From:        msg.From,
+		ContentType: msg.ContentType,
+		Content:     append([]byte(nil), msg.Content...),
+		Timestamp:   time.UnixMilli(int64(msg.Timestamp)),
+	}
+}
@@ -0,0 +1,169 @@
+// Package plugins runs custom message handlers inside a client daemon
+//
+// Each plugin is described by a JSON manifest in the plugins directory:
+//
+//	{"name": "away", "builtin": "autoreply",
+//	 "content_types": ["text"], "capabilities": ["reply"],
+//	 "config": {"text": "Out of office until Monday"}}
+//
+// "builtin" names a plugin compiled into the daemon (see Register); "path"
+// instead loads a Go plugin (go build -buildmode=plugin) that exports
+//
+//	func New() plugins.Plugin
+//
+// and was built against the same version of this module. "senders" and
+// "content_types" limit which messages reach the plugin.
+//
+// Plugins never get the client itself. They act through a Host, and each Host
+// method needs a capability granted in the manifest: "reply" to answer the
+// sender, "filter" for a Filter's verdict to count, and "archive" to write
+// files, which can only be created inside the plugin's own data directory.
+// Go plugins share the daemon's process, so this limits what the host lets
+// them do, not what their code could do; only install plugins you trust.
+package plugins
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ZentaChain/zentalk-node/pkg/network"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+var (
+	ErrNotPermitted     = errors.New("capability not granted to plugin")
+	ErrReplyRateLimited = errors.New("already replied to this sender recently")
+	ErrUnknownPlugin    = errors.New("unknown plugin")
+)
+
+// Capability is a permission a manifest grants its plugin
+type Capability string
+
+const (
+	CapReply   Capability = "reply"   // Send text messages back to a message's sender
+	CapFilter  Capability = "filter"  // Drop or flag messages before they are delivered
+	CapArchive Capability = "archive" // Write files in the plugin's data directory
+)
+
+// Message is an incoming direct message as seen by plugins
+type Message struct {
+	From        protocol.Address
+	ContentType uint8
+	Content     []byte
+	Timestamp   time.Time
+	KnownSender bool // Sender is a contact (only set for filters)
+}
+
+// Text returns the content of a text message ("" for other content types)
+func (m *Message) Text() string {
+	if m.ContentType != protocol.ContentTypeText || !utf8.Valid(m.Content) {
+		return ""
+	}
+	return string(m.Content)
+}
+
+// Plugin handles messages delivered to the daemon
+// HandleMessage runs on the plugin's own goroutine after the message has been
+// accepted, so it may block briefly without holding up the client.
+type Plugin interface {
+	Init(host Host, config json.RawMessage) error
+	HandleMessage(msg *Message) error
+}
+
+// Filter is implemented by plugins that inspect messages before delivery
+// FilterMessage runs inline on the client's receive path and must be quick.
+type Filter interface {
+	FilterMessage(msg *Message) network.FilterDecision
+}
+
+// Closer is implemented by plugins that hold resources
+type Closer interface {
+	Close() error
+}
+
+// Host is a plugin's view of the daemon
+type Host interface {
+	// Name returns the plugin's name from its manifest
+	Name() string
+	// Reply sends a text message to the sender of a message (needs "reply")
+	Reply(to protocol.Address, text string) error
+	// Archive appends data to a file in the plugin's data directory (needs "archive")
+	Archive(name string, data []byte) error
+	// Logf writes to the daemon's log, prefixed with the plugin name
+	Logf(format string, args ...any)
+}
+
+// Manifest configures one plugin
+type Manifest struct {
+	Name         string          `json:"name"`
+	Builtin      string          `json:"builtin"`       // Registered plugin to run
+	Path         string          `json:"path"`          // Go plugin file, relative to the manifest
+	Senders      []string        `json:"senders"`       // Hex addresses (empty = everyone)
+	ContentTypes []string        `json:"content_types"` // Content type names (empty = all)
+	Capabilities []Capability    `json:"capabilities"`
+	Config       json.RawMessage `json:"config"` // Passed to Init
+}
+
+// LoadManifests reads every *.json manifest in dir, sorted by file name
+// Relative plugin paths are resolved against dir.
+func LoadManifests(dir string) ([]*Manifest, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	manifests := make([]*Manifest, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read plugin manifest: %w", err)
+		}
+
+		var m Manifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, fmt.Errorf("failed to parse plugin manifest %s: %w", filepath.Base(path), err)
+		}
+		if m.Name == "" {
+			m.Name = strings.TrimSuffix(filepath.Base(path), ".json")
+		}
+		if m.Path != "" && !filepath.IsAbs(m.Path) {
+			m.Path = filepath.Join(dir, m.Path)
+		}
+		manifests = append(manifests, &m)
+	}
+
+	return manifests, nil
+}
+
+var (
+	registry   = make(map[string]func() Plugin)
+	registryMu sync.RWMutex
+)
+
+// Register makes a compiled-in plugin available to manifests under name
+func Register(name string, factory func() Plugin) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = factory
+}
+
+// newBuiltin creates a registered plugin
+func newBuiltin(name string) (Plugin, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownPlugin, name)
+	}
+	return factory(), nil
+}
@@ -0,0 +1,202 @@
+package plugins
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/network"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// recordingSender records replies
+type recordingSender struct {
+	replies chan string
+}
+
+func (s *recordingSender) SendText(to protocol.Address, text string) error {
+	s.replies <- text
+	return nil
+}
+
+// probePlugin tries every host capability and reports the errors
+type probePlugin struct {
+	host   Host
+	errors chan error
+}
+
+func (p *probePlugin) Init(host Host, config json.RawMessage) error {
+	p.host = host
+	return nil
+}
+
+func (p *probePlugin) HandleMessage(msg *Message) error {
+	p.errors <- p.host.Reply(msg.From, "hi")
+	p.errors <- p.host.Archive("../escape", []byte("x"))
+	return nil
+}
+
+func init() {
+	Register("probe", func() Plugin { return &probePlugin{errors: make(chan error, 2)} })
+}
+
+func newTestManager(t *testing.T, manifests ...*Manifest) (*Manager, *recordingSender) {
+	t.Helper()
+
+	sender := &recordingSender{replies: make(chan string, 4)}
+	m := NewManager(sender, t.TempDir())
+	for _, manifest := range manifests {
+		if err := m.Load(manifest); err != nil {
+			t.Fatalf("Load(%s) error = %v", manifest.Name, err)
+		}
+	}
+	m.Start()
+	t.Cleanup(m.Stop)
+	return m, sender
+}
+
+func textMessage(from byte, text string) *protocol.DirectMessage {
+	msg := &protocol.DirectMessage{
+		ContentType: protocol.ContentTypeText,
+		Content:     []byte(text),
+		Timestamp:   uint64(time.Now().UnixMilli()),
+	}
+	msg.From[0] = from
+	return msg
+}
+
+func TestCapabilitiesAreEnforced(t *testing.T) {
+	m, _ := newTestManager(t, &Manifest{Name: "probe", Builtin: "probe"})
+	probe := m.plugins[0].plugin.(*probePlugin)
+
+	m.HandleDirectMessage(textMessage(1, "hello"))
+
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-probe.errors:
+			if !errors.Is(err, ErrNotPermitted) {
+				t.Errorf("call %d error = %v, want ErrNotPermitted", i, err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("plugin did not run")
+		}
+	}
+}
+
+func TestArchiveStaysInDataDirectory(t *testing.T) {
+	dataDir := t.TempDir()
+	h := newPluginHost(&Manifest{Name: "log", Capabilities: []Capability{CapArchive}}, nil, dataDir)
+	defer h.close()
+
+	if err := h.Archive("a.jsonl", []byte("one\n")); err != nil {
+		t.Fatalf("Archive() error = %v", err)
+	}
+	if err := h.Archive("../a.jsonl", []byte("two\n")); err == nil {
+		t.Error("Archive(../a.jsonl) succeeded")
+	}
+
+	// A symlink planted in the data directory must not lead out of it
+	outside := filepath.Join(t.TempDir(), "target")
+	if err := os.Symlink(outside, filepath.Join(dataDir, "log", "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Archive("link", []byte("three\n")); err == nil {
+		t.Error("Archive(link) followed a symlink out of the data directory")
+	}
+
+	data, _ := os.ReadFile(filepath.Join(dataDir, "log", "a.jsonl"))
+	if string(data) != "one\n" {
+		t.Errorf("archive = %q", data)
+	}
+}
+
+func TestAutoReplyRateLimit(t *testing.T) {
+	m, sender := newTestManager(t, &Manifest{
+		Name:         "away",
+		Builtin:      "autoreply",
+		ContentTypes: []string{"text"},
+		Capabilities: []Capability{CapReply},
+		Config:       json.RawMessage(`{"text": "back on Monday"}`),
+	})
+
+	m.HandleDirectMessage(textMessage(1, "ping"))
+	m.HandleDirectMessage(textMessage(1, "ping again"))
+	m.HandleDirectMessage(textMessage(2, "ping"))
+	image := textMessage(3, "")
+	image.ContentType = protocol.ContentTypeImage
+	m.HandleDirectMessage(image)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case text := <-sender.replies:
+			if text != "back on Monday" {
+				t.Errorf("reply = %q", text)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("got %d replies, want 2", i)
+		}
+	}
+	select {
+	case text := <-sender.replies:
+		t.Errorf("unexpected extra reply %q", text)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestKeywordFilter(t *testing.T) {
+	spam := &Manifest{
+		Name:         "spam",
+		Builtin:      "keywords",
+		Capabilities: []Capability{CapFilter},
+		Config:       json.RawMessage(`{"words": ["Free Crypto"], "verdict": "drop", "unknown_only": true}`),
+	}
+	unprivileged := &Manifest{
+		Name:    "noisy",
+		Builtin: "keywords",
+		Config:  json.RawMessage(`{"words": ["hello"], "verdict": "drop"}`),
+	}
+	m, _ := newTestManager(t, spam, unprivileged)
+
+	d := m.FilterMessage(textMessage(1, "get FREE crypto now"), network.FilterContext{})
+	if d.Verdict != network.FilterDrop || !strings.HasPrefix(d.Reason, "spam: ") {
+		t.Errorf("unknown sender decision = %+v, want drop by spam", d)
+	}
+	d = m.FilterMessage(textMessage(1, "get free crypto now"), network.FilterContext{KnownSender: true})
+	if d.Verdict != network.FilterAccept {
+		t.Errorf("contact decision = %+v, want accept", d)
+	}
+	// Without the filter capability the plugin's verdict doesn't count
+	d = m.FilterMessage(textMessage(1, "hello"), network.FilterContext{})
+	if d.Verdict != network.FilterAccept {
+		t.Errorf("unprivileged decision = %+v, want accept", d)
+	}
+}
+
+func TestLoadManifests(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "10-away.json"), []byte(`{"builtin": "autoreply", "senders": ["0x`+strings.Repeat("ab", 20)+`"]}`), 0600)
+	os.WriteFile(filepath.Join(dir, "20-custom.json"), []byte(`{"name": "custom", "path": "custom.so"}`), 0600)
+
+	manifests, err := LoadManifests(dir)
+	if err != nil {
+		t.Fatalf("LoadManifests() error = %v", err)
+	}
+	if len(manifests) != 2 || manifests[0].Name != "10-away" || manifests[1].Path != filepath.Join(dir, "custom.so") {
+		t.Fatalf("manifests = %+v", manifests)
+	}
+
+	m := NewManager(nil, t.TempDir())
+	if err := m.Load(&Manifest{Name: "x", Builtin: "nope"}); !errors.Is(err, ErrUnknownPlugin) {
+		t.Errorf("Load(unknown builtin) error = %v", err)
+	}
+	if err := m.Load(&Manifest{Name: "x", Builtin: "archive", Capabilities: []Capability{"network"}}); err == nil {
+		t.Error("Load(unknown capability) succeeded")
+	}
+	if err := m.Load(&Manifest{Name: "../x", Builtin: "archive"}); err == nil {
+		t.Error("Load(../x) succeeded")
+	}
+}
@@ -0,0 +1,53 @@
+package protocol
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// ===== BOT ACCOUNTS =====
+// Bots are accounts run by software (bridges, integrations). They announce
+// themselves with ProfileFlagBot and prove to relays that they hold the API
+// key the operator registered for them, so relays can apply bot limits.
+
+// BotNonceSize is the length of the random nonce that starts a bot's proof
+const BotNonceSize = 16
+
+// BotProofSize is the length of a bot's handshake proof (nonce + HMAC)
+const BotProofSize = BotNonceSize + sha256.Size
+
+// BotProof returns the proof a bot sends in its handshake
+// It binds the bot's API key to its address, the handshake timestamp and a
+// fresh nonce, which relays remember so a captured proof can't be replayed.
+func BotProof(apiKey []byte, addr Address, timestamp uint64) []byte {
+	proof := make([]byte, BotNonceSize, BotProofSize)
+	rand.Read(proof)
+	return append(proof, botProofMAC(apiKey, addr, timestamp, proof)...)
+}
+
+// VerifyBotProof returns true if proof was made with apiKey for this address and timestamp
+func VerifyBotProof(apiKey []byte, addr Address, timestamp uint64, proof []byte) bool {
+	if len(proof) != BotProofSize {
+		return false
+	}
+	nonce := proof[:BotNonceSize]
+	return hmac.Equal(proof[BotNonceSize:], botProofMAC(apiKey, addr, timestamp, nonce))
+}
+
+// BotProofNonce returns the nonce a proof was made with
+func BotProofNonce(proof []byte) (nonce [BotNonceSize]byte) {
+	copy(nonce[:], proof)
+	return nonce
+}
+
+// botProofMAC computes the HMAC over a proof's address, timestamp and nonce
+func botProofMAC(apiKey []byte, addr Address, timestamp uint64, nonce []byte) []byte {
+	mac := hmac.New(sha256.New, apiKey)
+	mac.Write([]byte("zentalk-bot-auth"))
+	mac.Write(addr[:])
+	binary.Write(mac, binary.BigEndian, timestamp)
+	mac.Write(nonce)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,65 @@
+package protocol
+
+import (
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// ===== CBOR PAYLOADS =====
+// A payload sent with FlagCBOR is a CBOR map keyed by the integers in each
+// struct's cbor tags instead of the fixed binary layout. Decoders skip keys
+// they don't know, so a message type can gain optional fields without breaking
+// older peers. Keys are part of the protocol: never renumber or reuse one, and
+// give new fields the next free key.
+
+var (
+	cborEnc cbor.EncMode
+	cborDec cbor.DecMode
+)
+
+func init() {
+	var err error
+
+	// Core deterministic encoding, so the same message always has the same bytes (and signature)
+	if cborEnc, err = cbor.CoreDetEncOptions().EncMode(); err != nil {
+		panic(err)
+	}
+	if cborDec, err = (cbor.DecOptions{
+		DupMapKey:   cbor.DupMapKeyEnforcedAPF,
+		IndefLength: cbor.IndefLengthForbidden,
+	}).DecMode(); err != nil {
+		panic(err)
+	}
+}
+
+// Payload is a message body with a fixed binary layout and CBOR keys
+type Payload interface {
+	Encode() []byte
+	Decode(buf []byte) error
+}
+
+// EncodePayload encodes msg as CBOR if flags has FlagCBOR, otherwise in its binary layout
+func EncodePayload(msg Payload, flags uint16) ([]byte, error) {
+	if flags&FlagCBOR == 0 {
+		return msg.Encode(), nil
+	}
+	return cborEnc.Marshal(msg)
+}
+
+// DecodePayload decodes a payload sent with the given header flags into msg
+func DecodePayload(payload []byte, flags uint16, msg Payload) error {
+	if flags&FlagCBOR == 0 {
+		return msg.Decode(payload)
+	}
+
+	if err := cborDec.Unmarshal(payload, msg); err != nil {
+		return fmt.Errorf("invalid CBOR payload: %w", err)
+	}
+
+	// The binary decoders validate as they go; CBOR needs the same checks
+	if v, ok := msg.(interface{ Validate() error }); ok {
+		return v.Validate()
+	}
+	return nil
+}
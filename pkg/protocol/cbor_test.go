@@ -0,0 +1,93 @@
+package protocol
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestCBORPayloadRoundTrip(t *testing.T) {
+	msg := &DirectMessage{
+		From:        Address{0xAB},
+		To:          Address{0x01},
+		Timestamp:   1700000000000,
+		ContentType: ContentTypeText,
+		Content:     []byte("hello"),
+		Extensions:  []MessageExtension{{Type: 3, Data: []byte{0xFF}}},
+	}
+
+	data, err := EncodePayload(msg, FlagCBOR)
+	if err != nil {
+		t.Fatalf("EncodePayload() error = %v", err)
+	}
+	if bytes.Equal(data, msg.Encode()) {
+		t.Fatal("EncodePayload() with FlagCBOR returned the binary layout")
+	}
+
+	var got DirectMessage
+	if err := DecodePayload(data, FlagCBOR, &got); err != nil {
+		t.Fatalf("DecodePayload() error = %v", err)
+	}
+	if !bytes.Equal(got.Encode(), msg.Encode()) {
+		t.Errorf("round trip changed the message: %+v", got)
+	}
+}
+
+func TestCBORPayloadUnknownKeys(t *testing.T) {
+	// A newer peer may send fields this version doesn't know about
+	data, err := cborEnc.Marshal(map[int]interface{}{
+		1:  bytes.Repeat([]byte{0x11}, 20),
+		3:  bytes.Repeat([]byte{0x10}, 16),
+		5:  uint64(42),
+		99: "from the future",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ack AckMessage
+	if err := DecodePayload(data, FlagCBOR, &ack); err != nil {
+		t.Fatalf("DecodePayload() error = %v", err)
+	}
+	if ack.From[0] != 0x11 || ack.MessageID[15] != 0x10 || ack.Timestamp != 42 {
+		t.Errorf("DecodePayload() = %+v", ack)
+	}
+}
+
+func TestCBORPayloadValidates(t *testing.T) {
+	data, err := EncodePayload(&RelayMovedNotice{NewRelay: Address{1}}, FlagCBOR)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var notice RelayMovedNotice
+	if err := DecodePayload(data, FlagCBOR, &notice); err == nil {
+		t.Error("DecodePayload() accepted a notice without an endpoint")
+	}
+
+	// {4: 1, 4: 2}: the timestamp key twice
+	dup := []byte{0xA2, 0x04, 0x01, 0x04, 0x02}
+	if err := DecodePayload(dup, FlagCBOR, &notice); err == nil {
+		t.Error("DecodePayload() accepted duplicate map keys")
+	}
+}
+
+func TestBinaryPayloadUnchanged(t *testing.T) {
+	ack := &AckMessage{From: Address{7}, SequenceNumber: 9, Timestamp: 1}
+
+	data, err := EncodePayload(ack, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, ack.Encode()) {
+		t.Error("EncodePayload() without FlagCBOR changed the binary layout")
+	}
+
+	var got AckMessage
+	if err := DecodePayload(data, 0, &got); err != nil {
+		t.Fatalf("DecodePayload() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, *ack) {
+		t.Errorf("DecodePayload() = %+v, want %+v", got, *ack)
+	}
+}
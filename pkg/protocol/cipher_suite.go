@@ -0,0 +1,217 @@
+package protocol
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"sync"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/sys/cpu"
+)
+
+var (
+	ErrUnknownCipherSuite  = errors.New("unknown cipher suite")
+	ErrCipherSuiteExists   = errors.New("cipher suite already registered")
+	ErrCipherSuiteRejected = errors.New("cipher suite not accepted")
+)
+
+// CipherSuite identifies the AEAD that encrypts a ratchet session's messages
+// The suite is chosen when the session is set up with X3DH and recorded in
+// the RatchetState, so both sides keep using it for the session's lifetime.
+type CipherSuite uint8
+
+const (
+	CipherSuiteAES256GCM         CipherSuite = 0 // Sessions that predate negotiation use this
+	CipherSuiteChaCha20Poly1305  CipherSuite = 1 // Fast in software, for devices without AES instructions
+	CipherSuiteXChaCha20Poly1305 CipherSuite = 2 // ChaCha20-Poly1305 with 192-bit nonces
+)
+
+// CipherSuiteInfo describes a registered AEAD
+type CipherSuiteInfo struct {
+	Suite   CipherSuite
+	Name    string                                // Human-readable name (e.g., "chacha20-poly1305")
+	NewAEAD func(key []byte) (cipher.AEAD, error) // Called with a 32-byte message key
+}
+
+var (
+	cipherSuites   = make(map[CipherSuite]*CipherSuiteInfo)
+	cipherSuitesMu sync.RWMutex
+)
+
+func init() {
+	for _, info := range []*CipherSuiteInfo{
+		{Suite: CipherSuiteAES256GCM, Name: "aes-256-gcm", NewAEAD: newAESGCM},
+		{Suite: CipherSuiteChaCha20Poly1305, Name: "chacha20-poly1305", NewAEAD: chacha20poly1305.New},
+		{Suite: CipherSuiteXChaCha20Poly1305, Name: "xchacha20-poly1305", NewAEAD: chacha20poly1305.NewX},
+	} {
+		cipherSuites[info.Suite] = info
+	}
+}
+
+// newAESGCM creates an AES-256-GCM AEAD
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// RegisterCipherSuite makes an additional AEAD available for negotiation
+func RegisterCipherSuite(info *CipherSuiteInfo) error {
+	if info.NewAEAD == nil {
+		return fmt.Errorf("cipher suite %d has no AEAD constructor", info.Suite)
+	}
+
+	cipherSuitesMu.Lock()
+	defer cipherSuitesMu.Unlock()
+
+	if _, exists := cipherSuites[info.Suite]; exists {
+		return fmt.Errorf("%w: %d", ErrCipherSuiteExists, info.Suite)
+	}
+	cipherSuites[info.Suite] = info
+	return nil
+}
+
+// LookupCipherSuite returns a registered suite's description
+func LookupCipherSuite(suite CipherSuite) (*CipherSuiteInfo, bool) {
+	cipherSuitesMu.RLock()
+	defer cipherSuitesMu.RUnlock()
+	info, ok := cipherSuites[suite]
+	return info, ok
+}
+
+// PreferredCipherSuites lists the registered suites, fastest on this machine first
+// AES-GCM leads where the CPU has AES instructions; elsewhere the ChaCha20
+// suites do, being several times faster than AES implemented in software.
+func PreferredCipherSuites() []CipherSuite {
+	cipherSuitesMu.RLock()
+	suites := make([]CipherSuite, 0, len(cipherSuites))
+	for suite := range cipherSuites {
+		suites = append(suites, suite)
+	}
+	cipherSuitesMu.RUnlock()
+
+	sort.Slice(suites, func(i, j int) bool { return suites[i] < suites[j] })
+
+	if !hasAESHardware() {
+		sort.SliceStable(suites, func(i, j int) bool {
+			return suites[i] == CipherSuiteChaCha20Poly1305 && suites[j] != CipherSuiteChaCha20Poly1305
+		})
+	}
+	return suites
+}
+
+// hasAESHardware reports whether AES-GCM runs in constant time with hardware support
+func hasAESHardware() bool {
+	return (cpu.X86.HasAES && cpu.X86.HasPCLMULQDQ) ||
+		(cpu.ARM64.HasAES && cpu.ARM64.HasPMULL) ||
+		(cpu.S390X.HasAES && cpu.S390X.HasGHASH)
+}
+
+// NegotiateCipherSuite picks the suite for a session from both sides' preferences
+// A side leading with a suite other than AES-256-GCM is taken to lack AES
+// hardware, so its first choice wins when both support it (ours, then theirs).
+// Otherwise our first choice that they support is used. A peer offering
+// nothing predates negotiation and gets AES-256-GCM.
+func NegotiateCipherSuite(ours, theirs []CipherSuite) CipherSuite {
+	mutual := func(suite CipherSuite) bool {
+		_, ok := LookupCipherSuite(suite)
+		return ok && slices.Contains(ours, suite) && slices.Contains(theirs, suite)
+	}
+
+	for _, prefs := range [][]CipherSuite{ours, theirs} {
+		if len(prefs) > 0 && prefs[0] != CipherSuiteAES256GCM && mutual(prefs[0]) {
+			return prefs[0]
+		}
+	}
+	for _, suite := range ours {
+		if mutual(suite) {
+			return suite
+		}
+	}
+	return CipherSuiteAES256GCM
+}
+
+// AgreeCipherSuite negotiates like NegotiateCipherSuite, but fails when the
+// sides have no suite in common instead of falling back to AES-256-GCM
+// A peer offering nothing predates negotiation and supports only AES-256-GCM.
+func AgreeCipherSuite(ours, theirs []CipherSuite) (CipherSuite, error) {
+	suite := NegotiateCipherSuite(ours, theirs)
+	if len(theirs) == 0 {
+		theirs = []CipherSuite{CipherSuiteAES256GCM}
+	}
+	if !slices.Contains(ours, suite) || !slices.Contains(theirs, suite) {
+		return suite, fmt.Errorf("%w: none in common", ErrCipherSuiteRejected)
+	}
+	return suite, nil
+}
+
+// AcceptCipherSuite checks a session's suite is registered and one we accept
+// accepted nil accepts every registered suite.
+func AcceptCipherSuite(suite CipherSuite, accepted []CipherSuite) error {
+	if _, ok := LookupCipherSuite(suite); !ok {
+		return fmt.Errorf("%w: %d", ErrUnknownCipherSuite, suite)
+	}
+	if accepted != nil && !slices.Contains(accepted, suite) {
+		return fmt.Errorf("%w: %s", ErrCipherSuiteRejected, suite)
+	}
+	return nil
+}
+
+// String returns the suite's registered name
+func (s CipherSuite) String() string {
+	if info, ok := LookupCipherSuite(s); ok {
+		return info.Name
+	}
+	return fmt.Sprintf("cipher-suite-%d", s)
+}
+
+// aead creates the suite's AEAD for a key
+func (s CipherSuite) aead(key []byte) (cipher.AEAD, error) {
+	info, ok := LookupCipherSuite(s)
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownCipherSuite, s)
+	}
+	return info.NewAEAD(key)
+}
+
+// Encrypt seals plaintext under key with a random nonce
+// Output: [nonce][ciphertext + tag], the layout RatchetEncrypt expects.
+func (s CipherSuite) Encrypt(plaintext []byte, key []byte) ([]byte, error) {
+	return s.EncryptFrom(rand.Reader, plaintext, key)
+}
+
+// EncryptFrom seals plaintext under key with a nonce read from r
+func (s CipherSuite) EncryptFrom(r io.Reader, plaintext []byte, key []byte) ([]byte, error) {
+	aead, err := s.aead(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := io.ReadFull(r, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Decrypt opens a ciphertext produced by Encrypt
+func (s CipherSuite) Decrypt(ciphertext []byte, key []byte) ([]byte, error) {
+	aead, err := s.aead(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, fmt.Errorf("%w for %s nonce", ErrShortBuffer, s)
+	}
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, nil)
+}
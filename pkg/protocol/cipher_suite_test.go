@@ -0,0 +1,188 @@
+package protocol
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCipherSuiteRoundTrip(t *testing.T) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	plaintext := []byte("forward secret")
+
+	for _, suite := range []CipherSuite{CipherSuiteAES256GCM, CipherSuiteChaCha20Poly1305, CipherSuiteXChaCha20Poly1305} {
+		ciphertext, err := suite.Encrypt(plaintext, key)
+		if err != nil {
+			t.Fatalf("%s: Encrypt() error = %v", suite, err)
+		}
+		got, err := suite.Decrypt(ciphertext, key)
+		if err != nil {
+			t.Fatalf("%s: Decrypt() error = %v", suite, err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("%s: Decrypt() = %q, want %q", suite, got, plaintext)
+		}
+	}
+
+	// AES-256-GCM stays compatible with sessions encrypted before negotiation
+	legacy, err := gcmEncrypt(plaintext, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := CipherSuiteAES256GCM.Decrypt(legacy, key); err != nil || !bytes.Equal(got, plaintext) {
+		t.Errorf("AES-256-GCM Decrypt(legacy) = %q, %v", got, err)
+	}
+
+	if _, err := CipherSuite(200).Encrypt(plaintext, key); !errors.Is(err, ErrUnknownCipherSuite) {
+		t.Errorf("Encrypt() with unregistered suite error = %v, want ErrUnknownCipherSuite", err)
+	}
+}
+
+func TestNegotiateCipherSuite(t *testing.T) {
+	fast := []CipherSuite{CipherSuiteAES256GCM, CipherSuiteChaCha20Poly1305, CipherSuiteXChaCha20Poly1305}
+	constrained := []CipherSuite{CipherSuiteChaCha20Poly1305, CipherSuiteXChaCha20Poly1305, CipherSuiteAES256GCM}
+
+	tests := []struct {
+		name         string
+		ours, theirs []CipherSuite
+		want         CipherSuite
+	}{
+		{"both fast", fast, fast, CipherSuiteAES256GCM},
+		{"constrained peer", fast, constrained, CipherSuiteChaCha20Poly1305},
+		{"constrained us", constrained, fast, CipherSuiteChaCha20Poly1305},
+		{"legacy peer", constrained, nil, CipherSuiteAES256GCM},
+		{"no overlap", []CipherSuite{CipherSuiteXChaCha20Poly1305}, []CipherSuite{CipherSuiteChaCha20Poly1305}, CipherSuiteAES256GCM},
+		{"unregistered suite ignored", []CipherSuite{200, CipherSuiteXChaCha20Poly1305}, []CipherSuite{200, CipherSuiteXChaCha20Poly1305}, CipherSuiteXChaCha20Poly1305},
+	}
+
+	for _, tt := range tests {
+		if got := NegotiateCipherSuite(tt.ours, tt.theirs); got != tt.want {
+			t.Errorf("%s: NegotiateCipherSuite() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestKeyBundleCipherSuites(t *testing.T) {
+	identity, err := GenerateIdentityKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	spk, err := GenerateSignedPreKey(1, identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundle := CreateKeyBundle(Address{1}, identity, spk, nil, 7)
+	bundle.CipherSuites = []CipherSuite{CipherSuiteChaCha20Poly1305, CipherSuiteAES256GCM}
+
+	decoded, err := DecodeKeyBundle(bundle.Encode())
+	if err != nil {
+		t.Fatalf("DecodeKeyBundle() error = %v", err)
+	}
+	if !reflect.DeepEqual(decoded.CipherSuites, bundle.CipherSuites) {
+		t.Errorf("CipherSuites = %v, want %v", decoded.CipherSuites, bundle.CipherSuites)
+	}
+
+	// Bundles from before negotiation end after the one-time prekeys
+	bundle.CipherSuites = nil
+	decoded, err = DecodeKeyBundle(bundle.Encode())
+	if err != nil {
+		t.Fatalf("DecodeKeyBundle(legacy) error = %v", err)
+	}
+	if decoded.CipherSuites != nil {
+		t.Errorf("legacy CipherSuites = %v, want none", decoded.CipherSuites)
+	}
+}
+
+func TestInitialMessageCipherSuite(t *testing.T) {
+	msg := &InitialMessage{SenderAddress: Address{1}, Ciphertext: []byte{1, 2, 3}, CipherSuite: CipherSuiteXChaCha20Poly1305}
+
+	encoded := msg.Encode()
+	var decoded InitialMessage
+	if err := decoded.Decode(encoded); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if decoded.CipherSuite != CipherSuiteXChaCha20Poly1305 {
+		t.Errorf("CipherSuite = %s, want %s", decoded.CipherSuite, CipherSuiteXChaCha20Poly1305)
+	}
+
+	// Messages from before negotiation have no suite byte
+	if err := decoded.Decode(encoded[:len(encoded)-1]); err != nil {
+		t.Fatalf("Decode(legacy) error = %v", err)
+	}
+	if decoded.CipherSuite != CipherSuiteAES256GCM {
+		t.Errorf("legacy CipherSuite = %s, want %s", decoded.CipherSuite, CipherSuiteAES256GCM)
+	}
+}
+
+func TestRatchetSessionCipherSuite(t *testing.T) {
+	alice, bob := newRatchetPair(t)
+	alice.CipherSuite = CipherSuiteChaCha20Poly1305
+	bob.CipherSuite = CipherSuiteChaCha20Poly1305
+
+	header, ciphertext, err := alice.Seal([]byte("hi"))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+
+	// A peer on another suite cannot read the message, and its state is untouched
+	mismatched := bob.clone()
+	mismatched.CipherSuite = CipherSuiteAES256GCM
+	if _, err := mismatched.Open(header, ciphertext); err == nil {
+		t.Error("Open() with a different suite succeeded")
+	}
+
+	got, err := bob.Open(header, ciphertext)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if string(got) != "hi" {
+		t.Errorf("Open() = %q, want %q", got, "hi")
+	}
+}
+
+func TestAcceptCipherSuite(t *testing.T) {
+	tests := []struct {
+		name     string
+		suite    CipherSuite
+		accepted []CipherSuite
+		want     error
+	}{
+		{"any registered", CipherSuiteXChaCha20Poly1305, nil, nil},
+		{"configured", CipherSuiteChaCha20Poly1305, []CipherSuite{CipherSuiteChaCha20Poly1305}, nil},
+		{"turned off", CipherSuiteAES256GCM, []CipherSuite{CipherSuiteChaCha20Poly1305}, ErrCipherSuiteRejected},
+		{"unregistered", CipherSuite(200), nil, ErrUnknownCipherSuite},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := AcceptCipherSuite(tt.suite, tt.accepted); !errors.Is(err, tt.want) {
+				t.Errorf("AcceptCipherSuite() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAgreeCipherSuite(t *testing.T) {
+	chacha := []CipherSuite{CipherSuiteChaCha20Poly1305}
+	aes := []CipherSuite{CipherSuiteAES256GCM}
+
+	if got, err := AgreeCipherSuite(aes, []CipherSuite{CipherSuiteChaCha20Poly1305, CipherSuiteAES256GCM}); err != nil || got != CipherSuiteAES256GCM {
+		t.Errorf("AgreeCipherSuite() = %s, %v; want %s", got, err, CipherSuiteAES256GCM)
+	}
+	if _, err := AgreeCipherSuite(aes, chacha); !errors.Is(err, ErrCipherSuiteRejected) {
+		t.Errorf("AgreeCipherSuite() with nothing in common error = %v, want ErrCipherSuiteRejected", err)
+	}
+
+	// A peer from before negotiation only speaks AES-GCM
+	if _, err := AgreeCipherSuite(chacha, nil); !errors.Is(err, ErrCipherSuiteRejected) {
+		t.Errorf("AgreeCipherSuite() with a legacy peer error = %v, want ErrCipherSuiteRejected", err)
+	}
+	if got, err := AgreeCipherSuite(aes, nil); err != nil || got != CipherSuiteAES256GCM {
+		t.Errorf("AgreeCipherSuite() with a legacy peer = %s, %v", got, err)
+	}
+}
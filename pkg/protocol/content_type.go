@@ -0,0 +1,353 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+var (
+	ErrUnknownContentType  = errors.New("unknown content type")
+	ErrContentTooLarge     = errors.New("content exceeds size limit")
+	ErrContentTypeExists   = errors.New("content type already registered")
+	ErrReservedContentType = errors.New("content type is reserved for built-in types")
+	ErrInvalidContent      = errors.New("invalid content")
+)
+
+// ContentTypeCustomMin is the first content type available to applications
+// Values below it are reserved for protocol-defined types
+const ContentTypeCustomMin uint8 = 0x80
+
+// ContentValidator checks a message body for a content type
+type ContentValidator func(content []byte) error
+
+// ContentTypeInfo describes a content type
+type ContentTypeInfo struct {
+	Type       uint8              // Wire value carried in DirectMessage/GroupMessage
+	Name       string             // Human-readable name (e.g., "sticker")
+	MIMETypes  []string           // Accepted MIME types, first is canonical
+	MaxSize    int                // Maximum content size in bytes (0 = unlimited)
+	Validators []ContentValidator // Additional checks run after the size limit
+}
+
+// MIMEType returns the canonical MIME type
+func (i *ContentTypeInfo) MIMEType() string {
+	if len(i.MIMETypes) == 0 {
+		return "application/octet-stream"
+	}
+	return i.MIMETypes[0]
+}
+
+// ContentTypeRegistry maps content types to MIME types, size limits and validators
+type ContentTypeRegistry struct {
+	types  map[uint8]*ContentTypeInfo
+	byMIME map[string]uint8
+	mu     sync.RWMutex
+}
+
+// DefaultContentTypes is the registry used when no custom registry is supplied
+var DefaultContentTypes = NewContentTypeRegistry()
+
+// NewContentTypeRegistry creates a registry pre-populated with the built-in content types
+func NewContentTypeRegistry() *ContentTypeRegistry {
+	r := &ContentTypeRegistry{
+		types:  make(map[uint8]*ContentTypeInfo),
+		byMIME: make(map[string]uint8),
+	}
+
+	for _, info := range builtinContentTypes() {
+		r.addLocked(info)
+	}
+
+	return r
+}
+
+// builtinContentTypes returns the protocol-defined content types
+func builtinContentTypes() []*ContentTypeInfo {
+	return []*ContentTypeInfo{
+		{
+			Type:       ContentTypeText,
+			Name:       "text",
+			MIMETypes:  []string{"text/plain"},
+			MaxSize:    64 * 1024,
+			Validators: []ContentValidator{validateUTF8},
+		},
+		{
+			Type:      ContentTypeImage,
+			Name:      "image",
+			MIMETypes: []string{"image/jpeg", "image/png", "image/webp", "image/heic"},
+			MaxSize:   10 * 1024 * 1024,
+		},
+		{
+			Type:      ContentTypeVideo,
+			Name:      "video",
+			MIMETypes: []string{"video/mp4", "video/webm", "video/quicktime"},
+			MaxSize:   100 * 1024 * 1024,
+		},
+		{
+			Type:      ContentTypeAudio,
+			Name:      "audio",
+			MIMETypes: []string{"audio/ogg", "audio/mpeg", "audio/mp4", "audio/aac"},
+			MaxSize:   25 * 1024 * 1024,
+		},
+		{
+			Type:      ContentTypeFile,
+			Name:      "file",
+			MIMETypes: []string{"application/octet-stream"},
+			MaxSize:   100 * 1024 * 1024,
+		},
+		{
+			Type:      ContentTypeLocation,
+			Name:      "location",
+			MIMETypes: []string{"application/geo+json"},
+			MaxSize:   1024,
+		},
+		{
+			Type:       ContentTypeContact,
+			Name:       "contact",
+			MIMETypes:  []string{"text/vcard"},
+			MaxSize:    16 * 1024,
+			Validators: []ContentValidator{validateUTF8},
+		},
+		{
+			Type:      ContentTypeSticker,
+			Name:      "sticker",
+			MIMETypes: []string{"image/webp", "image/png"},
+			MaxSize:   512 * 1024,
+		},
+		{
+			Type:      ContentTypeGIF,
+			Name:      "gif",
+			MIMETypes: []string{"image/gif"},
+			MaxSize:   8 * 1024 * 1024,
+		},
+		{
+			Type:       ContentTypeStickerPack,
+			Name:       "sticker-pack",
+			MIMETypes:  []string{"application/vnd.zentalk.sticker-pack"},
+			MaxSize:    1024,
+			Validators: []ContentValidator{validateStickerPackReference},
+		},
+		{
+			Type:       ContentTypeVoiceNote,
+			Name:       "voice-note",
+			MIMETypes:  []string{"application/vnd.zentalk.voice-note"},
+			MaxSize:    64 * 1024,
+			Validators: []ContentValidator{validateVoiceNote},
+		},
+		{
+			Type:       ContentTypeRecoveryShare,
+			Name:       "recovery-share",
+			MIMETypes:  []string{"application/vnd.zentalk.recovery-share"},
+			MaxSize:    recoveryShareHeaderSize + MaxRecoveryShareSize,
+			Validators: []ContentValidator{validateRecoveryShare},
+		},
+		{
+			Type:       ContentTypeRecoveryRequest,
+			Name:       "recovery-request",
+			MIMETypes:  []string{"application/vnd.zentalk.recovery-request"},
+			MaxSize:    recoveryRequestSize,
+			Validators: []ContentValidator{validateRecoveryRequest},
+		},
+		{
+			Type:       ContentTypeReadStateSync,
+			Name:       "read-state-sync",
+			MIMETypes:  []string{"application/vnd.zentalk.read-state-sync"},
+			MaxSize:    readStateSyncHeaderSize + MaxReadStateEntries*readStateEntrySize,
+			Validators: []ContentValidator{validateReadStateSync},
+		},
+		{
+			Type:       ContentTypeGroupFile,
+			Name:       "group-file",
+			MIMETypes:  []string{"application/vnd.zentalk.group-file"},
+			MaxSize:    groupFileShareFixedSize + 255 + 255,
+			Validators: []ContentValidator{validateGroupFileShare},
+		},
+		{
+			Type:      ContentTypePoll,
+			Name:      "poll",
+			MIMETypes: []string{"application/vnd.zentalk.poll+json"},
+			MaxSize:   16 * 1024,
+		},
+	}
+}
+
+// addLocked stores a content type and indexes its MIME types (first registration wins)
+func (r *ContentTypeRegistry) addLocked(info *ContentTypeInfo) {
+	r.types[info.Type] = info
+	for _, m := range info.MIMETypes {
+		key := normalizeMIME(m)
+		if _, exists := r.byMIME[key]; !exists {
+			r.byMIME[key] = info.Type
+		}
+	}
+}
+
+// Register adds an application-defined content type
+// Custom types must be >= ContentTypeCustomMin and not already registered
+func (r *ContentTypeRegistry) Register(info ContentTypeInfo) error {
+	if info.Type < ContentTypeCustomMin {
+		return fmt.Errorf("%w: 0x%02x", ErrReservedContentType, info.Type)
+	}
+	if info.Name == "" {
+		return fmt.Errorf("content type 0x%02x must have a name", info.Type)
+	}
+	if info.MaxSize < 0 {
+		return fmt.Errorf("content type 0x%02x has negative size limit", info.Type)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.types[info.Type]; exists {
+		return fmt.Errorf("%w: 0x%02x", ErrContentTypeExists, info.Type)
+	}
+
+	stored := info
+	stored.MIMETypes = append([]string(nil), info.MIMETypes...)
+	stored.Validators = append([]ContentValidator(nil), info.Validators...)
+	r.addLocked(&stored)
+
+	return nil
+}
+
+// AddValidator attaches an extra validation hook to a registered content type
+func (r *ContentTypeRegistry) AddValidator(contentType uint8, validator ContentValidator) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info, exists := r.types[contentType]
+	if !exists {
+		return fmt.Errorf("%w: 0x%02x", ErrUnknownContentType, contentType)
+	}
+
+	info.Validators = append(info.Validators, validator)
+	return nil
+}
+
+// SetMaxSize changes the size limit of a registered content type (0 = unlimited)
+func (r *ContentTypeRegistry) SetMaxSize(contentType uint8, maxSize int) error {
+	if maxSize < 0 {
+		return fmt.Errorf("size limit must not be negative")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info, exists := r.types[contentType]
+	if !exists {
+		return fmt.Errorf("%w: 0x%02x", ErrUnknownContentType, contentType)
+	}
+
+	info.MaxSize = maxSize
+	return nil
+}
+
+// Lookup returns a copy of the content type's description
+func (r *ContentTypeRegistry) Lookup(contentType uint8) (ContentTypeInfo, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	info, exists := r.types[contentType]
+	if !exists {
+		return ContentTypeInfo{}, false
+	}
+	return *info, true
+}
+
+// MIMEType returns the canonical MIME type for a content type
+// Unknown types map to application/octet-stream
+func (r *ContentTypeRegistry) MIMEType(contentType uint8) string {
+	info, exists := r.Lookup(contentType)
+	if !exists {
+		return "application/octet-stream"
+	}
+	return info.MIMEType()
+}
+
+// ContentTypeForMIME returns the content type registered for a MIME type
+// Parameters such as "; charset=utf-8" are ignored
+func (r *ContentTypeRegistry) ContentTypeForMIME(mimeType string) (uint8, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if t, exists := r.byMIME[normalizeMIME(mimeType)]; exists {
+		return t, nil
+	}
+	return 0, fmt.Errorf("%w: no content type for MIME %q", ErrUnknownContentType, mimeType)
+}
+
+// Validate checks content against the type's size limit and validators
+func (r *ContentTypeRegistry) Validate(contentType uint8, content []byte) error {
+	r.mu.RLock()
+	info, exists := r.types[contentType]
+	var maxSize int
+	var validators []ContentValidator
+	if exists {
+		maxSize = info.MaxSize
+		validators = append(validators, info.Validators...)
+	}
+	r.mu.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("%w: 0x%02x", ErrUnknownContentType, contentType)
+	}
+
+	if maxSize > 0 && len(content) > maxSize {
+		return fmt.Errorf("%w: %s content is %d bytes (max %d)", ErrContentTooLarge, info.Name, len(content), maxSize)
+	}
+
+	for _, validate := range validators {
+		if err := validate(content); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidContent, info.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Types returns all registered content types in ascending order
+func (r *ContentTypeRegistry) Types() []uint8 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	types := make([]uint8, 0, len(r.types))
+	for t := range r.types {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// ContentTypeName returns the registered name of a content type in the default registry
+func ContentTypeName(contentType uint8) string {
+	if info, exists := DefaultContentTypes.Lookup(contentType); exists {
+		return info.Name
+	}
+	return fmt.Sprintf("unknown(0x%02x)", contentType)
+}
+
+// ValidateContent validates content against the default registry
+func ValidateContent(contentType uint8, content []byte) error {
+	return DefaultContentTypes.Validate(contentType, content)
+}
+
+// normalizeMIME lowercases a MIME type and strips parameters
+func normalizeMIME(mimeType string) string {
+	if mediaType, _, err := mime.ParseMediaType(mimeType); err == nil {
+		return mediaType
+	}
+	return strings.ToLower(strings.TrimSpace(mimeType))
+}
+
+// validateUTF8 rejects content that is not valid UTF-8
+func validateUTF8(content []byte) error {
+	if !utf8.Valid(content) {
+		return errors.New("not valid UTF-8")
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestContentTypeRegistryBuiltins(t *testing.T) {
+	r := NewContentTypeRegistry()
+
+	builtins := []uint8{
+		ContentTypeText, ContentTypeImage, ContentTypeVideo, ContentTypeAudio, ContentTypeFile,
+		ContentTypeLocation, ContentTypeContact, ContentTypeSticker, ContentTypePoll,
+		ContentTypeGIF, ContentTypeStickerPack, ContentTypeVoiceNote, ContentTypeRecoveryShare,
+		ContentTypeRecoveryRequest, ContentTypeReadStateSync, ContentTypeGroupFile,
+	}
+
+	for _, ct := range builtins {
+		if _, ok := r.Lookup(ct); !ok {
+			t.Errorf("built-in content type 0x%02x not registered", ct)
+		}
+	}
+
+	if got := r.MIMEType(ContentTypeText); got != "text/plain" {
+		t.Errorf("MIMEType(text) = %q, want text/plain", got)
+	}
+
+	if got := r.MIMEType(0xFF); got != "application/octet-stream" {
+		t.Errorf("MIMEType(unknown) = %q, want application/octet-stream", got)
+	}
+}
+
+func TestContentTypeForMIME(t *testing.T) {
+	r := NewContentTypeRegistry()
+
+	tests := []struct {
+		mime string
+		want uint8
+	}{
+		{"text/plain", ContentTypeText},
+		{"Text/Plain; charset=utf-8", ContentTypeText},
+		{"image/png", ContentTypeImage},
+		{"image/gif", ContentTypeGIF},
+		{"image/webp", ContentTypeImage},
+		{"video/mp4", ContentTypeVideo},
+	}
+
+	for _, tt := range tests {
+		got, err := r.ContentTypeForMIME(tt.mime)
+		if err != nil {
+			t.Errorf("ContentTypeForMIME(%q) error = %v", tt.mime, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ContentTypeForMIME(%q) = 0x%02x, want 0x%02x", tt.mime, got, tt.want)
+		}
+	}
+
+	if _, err := r.ContentTypeForMIME("application/x-unknown"); !errors.Is(err, ErrUnknownContentType) {
+		t.Errorf("ContentTypeForMIME(unknown) error = %v, want ErrUnknownContentType", err)
+	}
+}
+
+func TestContentTypeValidate(t *testing.T) {
+	r := NewContentTypeRegistry()
+
+	if err := r.Validate(ContentTypeText, []byte("hello")); err != nil {
+		t.Errorf("Validate(text) error = %v", err)
+	}
+
+	if err := r.Validate(ContentTypeText, []byte{0xff, 0xfe}); !errors.Is(err, ErrInvalidContent) {
+		t.Errorf("Validate(invalid UTF-8) error = %v, want ErrInvalidContent", err)
+	}
+
+	sticker := bytes.Repeat([]byte{0x01}, 512*1024+1)
+	if err := r.Validate(ContentTypeSticker, sticker); !errors.Is(err, ErrContentTooLarge) {
+		t.Errorf("Validate(oversized sticker) error = %v, want ErrContentTooLarge", err)
+	}
+
+	if err := r.Validate(0xFE, []byte("x")); !errors.Is(err, ErrUnknownContentType) {
+		t.Errorf("Validate(unknown) error = %v, want ErrUnknownContentType", err)
+	}
+}
+
+func TestContentTypeRegisterCustom(t *testing.T) {
+	r := NewContentTypeRegistry()
+
+	custom := ContentTypeInfo{
+		Type:      ContentTypeCustomMin,
+		Name:      "game-move",
+		MIMETypes: []string{"application/vnd.example.move+json"},
+		MaxSize:   128,
+	}
+
+	if err := r.Register(custom); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	if err := r.Register(custom); !errors.Is(err, ErrContentTypeExists) {
+		t.Errorf("Register(duplicate) error = %v, want ErrContentTypeExists", err)
+	}
+
+	reserved := custom
+	reserved.Type = ContentTypeSticker
+	if err := r.Register(reserved); !errors.Is(err, ErrReservedContentType) {
+		t.Errorf("Register(reserved) error = %v, want ErrReservedContentType", err)
+	}
+
+	got, err := r.ContentTypeForMIME("application/vnd.example.move+json")
+	if err != nil || got != ContentTypeCustomMin {
+		t.Errorf("ContentTypeForMIME(custom) = 0x%02x, %v", got, err)
+	}
+
+	// Validation hooks run after the size check
+	errOdd := errors.New("odd length")
+	if err := r.AddValidator(ContentTypeCustomMin, func(content []byte) error {
+		if len(content)%2 != 0 {
+			return errOdd
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("AddValidator() error = %v", err)
+	}
+
+	if err := r.Validate(ContentTypeCustomMin, []byte("ab")); err != nil {
+		t.Errorf("Validate(even) error = %v", err)
+	}
+	if err := r.Validate(ContentTypeCustomMin, []byte("abc")); !errors.Is(err, ErrInvalidContent) {
+		t.Errorf("Validate(odd) error = %v, want ErrInvalidContent", err)
+	}
+}
+
+func TestContentTypeSetMaxSize(t *testing.T) {
+	r := NewContentTypeRegistry()
+
+	if err := r.SetMaxSize(ContentTypeSticker, 10); err != nil {
+		t.Fatalf("SetMaxSize() error = %v", err)
+	}
+	if err := r.Validate(ContentTypeSticker, make([]byte, 11)); !errors.Is(err, ErrContentTooLarge) {
+		t.Errorf("Validate() after SetMaxSize error = %v, want ErrContentTooLarge", err)
+	}
+
+	// Registries are independent
+	if err := DefaultContentTypes.Validate(ContentTypeSticker, make([]byte, 11)); err != nil {
+		t.Errorf("default registry affected by SetMaxSize: %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// DeviceID identifies one of a user's devices
+// Every device has its own address, relay connection and ratchet sessions; the
+// identity is the address contacts know the user by.
+type DeviceID uint32
+
+// PrimaryDeviceID is the device whose address is the user's identity
+const PrimaryDeviceID DeviceID = 1
+
+// FanoutID identifies one message sent to all of a user's devices (16 bytes)
+type FanoutID [16]byte
+
+// deviceFanoutHeaderSize is the encoded size of a DeviceFanout before its payload
+const deviceFanoutHeaderSize = 16 + 20 + 4
+
+// Device is one of a user's devices
+type Device struct {
+	ID      DeviceID `cbor:"1,keyasint,omitempty"`
+	Address Address  `cbor:"2,keyasint,omitempty"` // Where the device connects to relays
+}
+
+// DeviceFanout is the end-to-end payload (MsgTypeDeviceFanout) of one copy of a
+// message sent to every device of a user
+// Each device gets its own copy under its own ratchet session. All copies share
+// FanoutID, so a device that receives one twice delivers it once.
+type DeviceFanout struct {
+	FanoutID     FanoutID `cbor:"1,keyasint,omitempty"`
+	Identity     Address  `cbor:"2,keyasint,omitempty"` // The user the message is for
+	SenderDevice DeviceID `cbor:"3,keyasint,omitempty"` // Which of the sender's devices sent it
+	Payload      []byte   `cbor:"4,keyasint,omitempty"` // Tagged end-to-end payload (see TagPayload)
+}
+
+// Encode encodes the fan-out copy to bytes
+// Format: [FanoutID 16][Identity 20][SenderDevice 4][Payload]
+func (f *DeviceFanout) Encode() []byte {
+	buf := make([]byte, deviceFanoutHeaderSize+len(f.Payload))
+	offset := 0
+
+	copy(buf[offset:], f.FanoutID[:])
+	offset += 16
+
+	copy(buf[offset:], f.Identity[:])
+	offset += 20
+
+	binary.BigEndian.PutUint32(buf[offset:], uint32(f.SenderDevice))
+	offset += 4
+
+	copy(buf[offset:], f.Payload)
+
+	return buf
+}
+
+// Decode decodes the fan-out copy from bytes
+func (f *DeviceFanout) Decode(buf []byte) error {
+	if len(buf) < deviceFanoutHeaderSize {
+		return fmt.Errorf("%w for device fan-out", ErrShortBuffer)
+	}
+
+	offset := 0
+
+	copy(f.FanoutID[:], buf[offset:offset+16])
+	offset += 16
+
+	copy(f.Identity[:], buf[offset:offset+20])
+	offset += 20
+
+	f.SenderDevice = DeviceID(binary.BigEndian.Uint32(buf[offset:]))
+	offset += 4
+
+	f.Payload = append([]byte(nil), buf[offset:]...)
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestDeviceFanoutEncodeDecode(t *testing.T) {
+	fanout := &DeviceFanout{
+		FanoutID:     FanoutID{1, 2, 3},
+		Identity:     Address{7},
+		SenderDevice: 3,
+		Payload:      TagPayload(MsgTypeDirectMessage, []byte("hello")),
+	}
+
+	var decoded DeviceFanout
+	if err := decoded.Decode(fanout.Encode()); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if decoded.FanoutID != fanout.FanoutID || decoded.Identity != fanout.Identity || decoded.SenderDevice != fanout.SenderDevice {
+		t.Errorf("Decode() = %+v, want %+v", decoded, fanout)
+	}
+	if !bytes.Equal(decoded.Payload, fanout.Payload) {
+		t.Errorf("Decode() payload = %x, want %x", decoded.Payload, fanout.Payload)
+	}
+
+	if err := decoded.Decode(fanout.Encode()[:deviceFanoutHeaderSize-1]); !errors.Is(err, ErrShortBuffer) {
+		t.Errorf("Decode(truncated) error = %v, want ErrShortBuffer", err)
+	}
+}
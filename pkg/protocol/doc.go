@@ -17,7 +17,11 @@
 // The protocol supports several categories of messages:
 //
 // Connection Management (0x00xx):
+//   - StartTLS/StartTLSAck: Switch the connection to TLS before the handshake
 //   - Handshake/HandshakeAck: Initial connection setup
+//   - Resume/ResumeAck/Ticket: Session resumption after a brief disconnect
+//   - QueueProgress/QueueWindow: Offline queue delivered in windows the client grants
+//   - OfflineSync/OfflineSyncResponse: Offline queue pulled by the client in acknowledged pages
 //   - Ping/Pong: Keep-alive messages
 //   - Disconnect: Clean connection termination
 //
@@ -32,6 +36,7 @@
 //   - Typing: Typing indicators
 //   - ReadReceipt: Message read confirmations
 //   - Presence: User online/offline status
+//   - DeviceFanout: One device's copy of a message sent to all of a user's devices
 //
 // Profile & Groups (0x03xx):
 //   - ProfileUpdate: User profile changes
@@ -40,11 +45,16 @@
 //
 // Media (0x04xx):
 //   - MediaUpload/MediaDownload: File transfer operations
+//   - MediaDirectoryRequest/MediaDirectory: Storage endpoints and media limits a relay advertises
 //
 // System (0x05xx):
 //   - Ack/Nack: Message acknowledgments
 //   - Error: Protocol errors
 //
+// Key Distribution (0x06xx):
+//   - KeyBundlePublish/KeyBundleRequest/KeyBundleResponse: X3DH key bundles held
+//     by relays for users who are offline
+//
 // # Header Format
 //
 // Every message starts with a 32-byte header:
@@ -63,15 +73,34 @@
 //   - Variable-length fields are prefixed with their length (4 bytes)
 //   - Arrays use fixed sizes defined in the protocol
 //
+// A payload sent with FlagCBOR is instead a CBOR map keyed by the integers in
+// each struct's cbor tags (see EncodePayload and DecodePayload). Unknown keys
+// are skipped, so message types can gain optional fields without breaking
+// older decoders.
+//
+// Message structs also implement json.Marshaler and json.Unmarshaler for
+// debugging tools and fixtures: Go field names, byte fields as hex strings.
+// JSON is never sent on the wire.
+//
+// End-to-end payloads (the plaintext inside RSA or ratchet encryption) are
+// tagged with their message type by TagPayload, so a recipient knows whether
+// it holds a DirectMessage, GroupMessage, ProfileUpdate, ... before decoding.
+//
 // # Cryptographic Primitives
 //
 // The protocol uses:
 //   - X3DH (Extended Triple Diffie-Hellman) for key agreement
 //   - Double Ratchet for forward secrecy
 //   - RSA-4096 for signatures and key encryption
-//   - AES-256-GCM for message encryption
+//   - AES-256-GCM for message encryption, or ChaCha20-Poly1305 / XChaCha20-Poly1305
+//     when negotiated for a ratchet session (see CipherSuite)
 //   - BLAKE2b-256 for hashing
 //
+// Key generation and ratchet sessions read crypto/rand by default. The *From
+// variants (GenerateIdentityKeyPairFrom, X3DHInitiatorFrom, ...) and
+// RatchetState.SetRandom take any reader instead; the testkeys subpackage
+// uses them to derive reproducible identities and transcripts from seeds.
+//
 // # Usage Example
 //
 //	// Create a direct message
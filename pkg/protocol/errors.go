@@ -0,0 +1,12 @@
+package protocol
+
+import "errors"
+
+// Errors shared by the protocol's decoders and by packages storing versioned formats
+// Decode errors wrap these with the structure that failed, so callers can
+// branch with errors.Is without matching message text.
+var (
+	ErrShortBuffer        = errors.New("buffer too short")
+	ErrUnsupportedVersion = errors.New("unsupported format version")
+	ErrQuotaExceeded      = errors.New("quota exceeded")
+)
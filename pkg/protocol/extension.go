@@ -0,0 +1,99 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Message extension types
+const (
+	ExtensionLinkPreview  uint8 = 0x01
+	ExtensionMessageID    uint8 = 0x02 // Group message identifier (16 bytes)
+	ExtensionThreadParent uint8 = 0x03 // Thread parent message ID (16 bytes)
+	ExtensionMentions     uint8 = 0x04 // @mention ranges
+	ExtensionReturnRelay  uint8 = 0x05 // Sender's relay address, for routing the ACK back (20 bytes)
+	ExtensionForwarded    uint8 = 0x06 // Original sender, timestamp and hop count of a forwarded message
+)
+
+// MessageExtension is an optional typed attachment carried after a message's signature
+// Unknown extension types are preserved so clients can ignore what they don't understand
+type MessageExtension struct {
+	Type uint8  `cbor:"1,keyasint,omitempty"`
+	Data []byte `cbor:"2,keyasint,omitempty"`
+}
+
+// extensionsSize returns the encoded size of extensions
+// Format per extension: [Type 1][Length 4][Data]
+func extensionsSize(extensions []MessageExtension) int {
+	size := 0
+	for _, ext := range extensions {
+		size += 1 + 4 + len(ext.Data)
+	}
+	return size
+}
+
+// encodeExtensions writes extensions into buf (must be extensionsSize bytes)
+func encodeExtensions(buf []byte, extensions []MessageExtension) {
+	offset := 0
+	for _, ext := range extensions {
+		buf[offset] = ext.Type
+		offset++
+
+		binary.BigEndian.PutUint32(buf[offset:], uint32(len(ext.Data)))
+		offset += 4
+
+		copy(buf[offset:], ext.Data)
+		offset += len(ext.Data)
+	}
+}
+
+// decodeExtensions parses trailing extensions (empty input means none)
+func decodeExtensions(buf []byte) ([]MessageExtension, error) {
+	var extensions []MessageExtension
+	offset := 0
+
+	for offset < len(buf) {
+		if len(buf)-offset < 5 {
+			return nil, fmt.Errorf("%w for message extension", ErrShortBuffer)
+		}
+
+		extType := buf[offset]
+		offset++
+
+		extLen := int(binary.BigEndian.Uint32(buf[offset:]))
+		offset += 4
+
+		if extLen > len(buf)-offset {
+			return nil, fmt.Errorf("message extension 0x%02x truncated", extType)
+		}
+
+		data := make([]byte, extLen)
+		copy(data, buf[offset:offset+extLen])
+		offset += extLen
+
+		extensions = append(extensions, MessageExtension{Type: extType, Data: data})
+	}
+
+	return extensions, nil
+}
+
+// Extension returns the data of the first extension of the given type
+func (m *DirectMessage) Extension(extType uint8) ([]byte, bool) {
+	for _, ext := range m.Extensions {
+		if ext.Type == extType {
+			return ext.Data, true
+		}
+	}
+	return nil, false
+}
+
+// SetExtension adds or replaces the extension of the given type
+func (m *DirectMessage) SetExtension(extType uint8, data []byte) {
+	for i := range m.Extensions {
+		if m.Extensions[i].Type == extType {
+			m.Extensions[i].Data = data
+			return
+		}
+	}
+	m.Extensions = append(m.Extensions, MessageExtension{Type: extType, Data: data})
+}
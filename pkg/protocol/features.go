@@ -0,0 +1,107 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	// MaxFeatures caps the features one list can carry
+	MaxFeatures = 64
+
+	// MaxFeatureNameLength is the longest feature name a list can carry
+	MaxFeatureNameLength = 32
+)
+
+// Feature names an optional capability and the version of it a node implements
+type Feature struct {
+	Name    string `cbor:"1,keyasint,omitempty" json:"name"`
+	Version uint16 `cbor:"2,keyasint,omitempty" json:"version"`
+}
+
+// FeatureList is the set of features a node has switched on
+type FeatureList []Feature
+
+// Version returns the version of a feature (false if it is not in the list)
+func (l FeatureList) Version(name string) (uint16, bool) {
+	for _, f := range l {
+		if f.Name == name {
+			return f.Version, true
+		}
+	}
+	return 0, false
+}
+
+// String lists the features as name/version pairs
+func (l FeatureList) String() string {
+	if len(l) == 0 {
+		return "none"
+	}
+	names := make([]string, len(l))
+	for i, f := range l {
+		names[i] = fmt.Sprintf("%s/%d", f.Name, f.Version)
+	}
+	return strings.Join(names, ", ")
+}
+
+// Encode encodes the list to bytes, sorted by name
+// Format: [Count 1]([NameLen 1][Name][Version 2])...
+func (l FeatureList) Encode() []byte {
+	features := slices.Clone(l)
+	slices.SortFunc(features, func(a, b Feature) int { return strings.Compare(a.Name, b.Name) })
+
+	buf := []byte{0}
+	count := 0
+	for _, f := range features {
+		if count == MaxFeatures {
+			break
+		}
+		if f.Name == "" || len(f.Name) > MaxFeatureNameLength {
+			continue
+		}
+		buf = append(buf, uint8(len(f.Name)))
+		buf = append(buf, f.Name...)
+		buf = binary.BigEndian.AppendUint16(buf, f.Version)
+		count++
+	}
+	buf[0] = uint8(count)
+
+	return buf
+}
+
+// Decode decodes the list from bytes and returns the bytes consumed
+func (l *FeatureList) Decode(buf []byte) (int, error) {
+	if len(buf) < 1 {
+		return 0, fmt.Errorf("%w for feature list", ErrShortBuffer)
+	}
+
+	count := int(buf[0])
+	if count > MaxFeatures {
+		return 0, fmt.Errorf("feature list has %d entries (max %d)", count, MaxFeatures)
+	}
+
+	features := make(FeatureList, 0, count)
+	offset := 1
+	for i := 0; i < count; i++ {
+		if offset >= len(buf) {
+			return 0, fmt.Errorf("feature list truncated")
+		}
+		nameLen := int(buf[offset])
+		offset++
+		if nameLen == 0 || nameLen > MaxFeatureNameLength {
+			return 0, fmt.Errorf("feature name is %d bytes (max %d)", nameLen, MaxFeatureNameLength)
+		}
+		if offset+nameLen+2 > len(buf) {
+			return 0, fmt.Errorf("feature list truncated")
+		}
+		name := string(buf[offset : offset+nameLen])
+		offset += nameLen
+		features = append(features, Feature{Name: name, Version: binary.BigEndian.Uint16(buf[offset:])})
+		offset += 2
+	}
+
+	*l = features
+	return offset, nil
+}
@@ -0,0 +1,61 @@
+package protocol
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHandshakeFeatures(t *testing.T) {
+	ack := &HandshakeMessage{
+		ProtocolVersion: ProtocolVersion,
+		Address:         Address{4},
+		PublicKey:       []byte("-----BEGIN PUBLIC KEY-----"),
+		ClientType:      ClientTypeRelay,
+		Timestamp:       1700000000,
+		Features:        FeatureList{{Name: "mesh-routing", Version: 1}, {Name: "bots", Version: 2}},
+	}
+
+	// Features follow an empty policy, and come back sorted by name
+	var decoded HandshakeMessage
+	if err := decoded.Decode(ack.Encode()); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	want := FeatureList{{Name: "bots", Version: 2}, {Name: "mesh-routing", Version: 1}}
+	if !reflect.DeepEqual(decoded.Features, want) {
+		t.Errorf("Decode() Features = %+v, want %+v", decoded.Features, want)
+	}
+	if decoded.Policy == nil || !decoded.Policy.Allows(MsgTypeRelayForward) || decoded.Policy.DeliveryCeiling(false) != 0 {
+		t.Errorf("Decode() Policy = %+v, want an empty policy", decoded.Policy)
+	}
+	if v, ok := decoded.Features.Version("bots"); !ok || v != 2 {
+		t.Errorf("Version(bots) = %d, %v", v, ok)
+	}
+	if _, ok := decoded.Features.Version("tenants"); ok {
+		t.Error("Version(tenants) reported a feature that was not sent")
+	}
+
+	// Acks from older relays end at the policy
+	ack.Features = nil
+	ack.Policy = &RelayPolicy{MaxDelivered: 1 << 20}
+	if err := decoded.Decode(ack.Encode()); err != nil {
+		t.Fatalf("Decode(no features) error = %v", err)
+	}
+	if decoded.Features != nil || decoded.Policy.MaxDelivered != 1<<20 {
+		t.Errorf("Decode(no features) Features = %+v, Policy = %+v", decoded.Features, decoded.Policy)
+	}
+
+	encoded := FeatureList{{Name: "bots", Version: 2}}.Encode()
+	var truncated FeatureList
+	if _, err := truncated.Decode(encoded[:len(encoded)-1]); err == nil {
+		t.Error("Decode(truncated features) expected error, got nil")
+	}
+}
+
+func TestFeatureListString(t *testing.T) {
+	if got := (FeatureList{}).String(); got != "none" {
+		t.Errorf("String() = %q, want none", got)
+	}
+	if got := (FeatureList{{Name: "bots", Version: 2}, {Name: "tenants", Version: 1}}).String(); got != "bots/2, tenants/1" {
+		t.Errorf("String() = %q", got)
+	}
+}
@@ -0,0 +1,70 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// forwardedSize is the encoded size of a Forwarded extension
+const forwardedSize = 20 + 8 + 1
+
+// Forwarded attributes a forwarded message to the user who first sent it
+// Forwarding a forwarded message keeps the original sender and timestamp and
+// only counts the hop, so recipients see who wrote it however far it traveled.
+type Forwarded struct {
+	OriginalSender    Address `cbor:"1,keyasint,omitempty"` // Who first sent the message
+	OriginalTimestamp uint64  `cbor:"2,keyasint,omitempty"` // When they sent it (Unix timestamp ms)
+	HopCount          uint8   `cbor:"3,keyasint,omitempty"` // Times forwarded (1 = forwarded once), saturates at 255
+}
+
+// Next returns the attribution for forwarding the message once more
+func (f *Forwarded) Next() *Forwarded {
+	next := *f
+	if next.HopCount < 255 {
+		next.HopCount++
+	}
+	return &next
+}
+
+// Encode encodes the attribution to bytes
+// Format: [OriginalSender 20][OriginalTimestamp 8][HopCount 1]
+func (f *Forwarded) Encode() []byte {
+	buf := make([]byte, forwardedSize)
+	copy(buf[0:20], f.OriginalSender[:])
+	binary.BigEndian.PutUint64(buf[20:28], f.OriginalTimestamp)
+	buf[28] = f.HopCount
+	return buf
+}
+
+// Decode decodes the attribution from bytes
+func (f *Forwarded) Decode(buf []byte) error {
+	if len(buf) < forwardedSize {
+		return fmt.Errorf("%w for forwarded attribution", ErrShortBuffer)
+	}
+	copy(f.OriginalSender[:], buf[0:20])
+	f.OriginalTimestamp = binary.BigEndian.Uint64(buf[20:28])
+	f.HopCount = buf[28]
+	if f.HopCount == 0 {
+		return fmt.Errorf("forwarded attribution has no hops")
+	}
+	return nil
+}
+
+// SetForwarded marks the message as forwarded
+func (m *DirectMessage) SetForwarded(f *Forwarded) {
+	m.SetExtension(ExtensionForwarded, f.Encode())
+}
+
+// Forwarded returns the message's forward attribution, or nil if it was not forwarded
+func (m *DirectMessage) Forwarded() (*Forwarded, error) {
+	data, exists := m.Extension(ExtensionForwarded)
+	if !exists {
+		return nil, nil
+	}
+
+	var f Forwarded
+	if err := f.Decode(data); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
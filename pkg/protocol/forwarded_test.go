@@ -0,0 +1,64 @@
+package protocol
+
+import "testing"
+
+func TestForwardedDirectMessage(t *testing.T) {
+	msg := &DirectMessage{From: Address{1}, To: Address{2}, Content: []byte("hi")}
+	if f, err := msg.Forwarded(); f != nil || err != nil {
+		t.Fatalf("Forwarded() = %+v, %v on a message never forwarded", f, err)
+	}
+
+	attribution := &Forwarded{OriginalSender: Address{9}, OriginalTimestamp: 1700000000000, HopCount: 1}
+	msg.SetForwarded(attribution)
+
+	decoded := &DirectMessage{}
+	if err := decoded.Decode(msg.Encode()); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	got, err := decoded.Forwarded()
+	if err != nil || got == nil || *got != *attribution {
+		t.Errorf("Forwarded() = %+v, %v; want %+v", got, err, attribution)
+	}
+
+	msg.SetExtension(ExtensionForwarded, []byte{1, 2, 3})
+	if _, err := msg.Forwarded(); err == nil {
+		t.Error("Forwarded() accepted a truncated attribution")
+	}
+}
+
+func TestForwardedGroupMessage(t *testing.T) {
+	msg := &GroupMessage{
+		From:      Address{1},
+		GroupID:   GroupID{2},
+		Content:   []byte("hi"),
+		MessageID: MessageID{3},
+		Forwarded: &Forwarded{OriginalSender: Address{9}, OriginalTimestamp: 42, HopCount: 3},
+	}
+
+	decoded := &GroupMessage{}
+	if err := decoded.Decode(msg.Encode()); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if decoded.Forwarded == nil || *decoded.Forwarded != *msg.Forwarded {
+		t.Errorf("Forwarded = %+v, want %+v", decoded.Forwarded, msg.Forwarded)
+	}
+	if len(decoded.Extensions) != 0 {
+		t.Errorf("forward attribution also kept as an unknown extension: %+v", decoded.Extensions)
+	}
+}
+
+func TestForwardedNext(t *testing.T) {
+	f := &Forwarded{OriginalSender: Address{9}, OriginalTimestamp: 42, HopCount: 1}
+	next := f.Next()
+	if next.HopCount != 2 || next.OriginalSender != f.OriginalSender || next.OriginalTimestamp != 42 {
+		t.Errorf("Next() = %+v", next)
+	}
+	if f.HopCount != 1 {
+		t.Error("Next() modified the original attribution")
+	}
+
+	f.HopCount = 255
+	if f.Next().HopCount != 255 {
+		t.Error("Next() overflowed the hop count")
+	}
+}
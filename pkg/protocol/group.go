@@ -6,11 +6,11 @@ import "encoding/binary"
 
 // GroupCreateMessage represents a request to create a new group
 type GroupCreateMessage struct {
-	GroupID     GroupID   // Unique group identifier
-	GroupName   string    // Group name
-	CreatorAddr Address   // Creator's address
-	Timestamp   uint64    // Unix timestamp (ms)
-	Members     []Address // Initial member addresses
+	GroupID     GroupID   `cbor:"1,keyasint,omitempty"` // Unique group identifier
+	GroupName   string    `cbor:"2,keyasint,omitempty"` // Group name
+	CreatorAddr Address   `cbor:"3,keyasint,omitempty"` // Creator's address
+	Timestamp   uint64    `cbor:"4,keyasint,omitempty"` // Unix timestamp (ms)
+	Members     []Address `cbor:"5,keyasint,omitempty"` // Initial member addresses
 }
 
 // Encode encodes group create message to bytes
@@ -81,10 +81,10 @@ func (m *GroupCreateMessage) Decode(buf []byte) error {
 
 // GroupJoinMessage represents a request to join a group
 type GroupJoinMessage struct {
-	GroupID    GroupID // Group identifier
-	MemberAddr Address // Member requesting to join
-	Timestamp  uint64  // Unix timestamp (ms)
-	Signature  []byte  // Signature from member
+	GroupID    GroupID `cbor:"1,keyasint,omitempty"` // Group identifier
+	MemberAddr Address `cbor:"2,keyasint,omitempty"` // Member requesting to join
+	Timestamp  uint64  `cbor:"3,keyasint,omitempty"` // Unix timestamp (ms)
+	Signature  []byte  `cbor:"4,keyasint,omitempty"` // Signature from member
 }
 
 // EncodeForSigning encodes group join message without signature (for signing)
@@ -153,10 +153,10 @@ func (m *GroupJoinMessage) Decode(buf []byte) error {
 
 // GroupLeaveMessage represents a request to leave a group
 type GroupLeaveMessage struct {
-	GroupID    GroupID // Group identifier
-	MemberAddr Address // Member leaving
-	Timestamp  uint64  // Unix timestamp (ms)
-	Signature  []byte  // Signature from member
+	GroupID    GroupID `cbor:"1,keyasint,omitempty"` // Group identifier
+	MemberAddr Address `cbor:"2,keyasint,omitempty"` // Member leaving
+	Timestamp  uint64  `cbor:"3,keyasint,omitempty"` // Unix timestamp (ms)
+	Signature  []byte  `cbor:"4,keyasint,omitempty"` // Signature from member
 }
 
 // EncodeForSigning encodes group leave message without signature (for signing)
@@ -225,13 +225,13 @@ func (m *GroupLeaveMessage) Decode(buf []byte) error {
 
 // GroupUpdateMessage represents a group update (name change, add/remove members, etc.)
 type GroupUpdateMessage struct {
-	GroupID      GroupID // Group identifier
-	UpdateType   uint8   // Update type (1=name, 2=add member, 3=remove member, 4=admin change)
-	UpdatedBy    Address // Who made the update
-	Timestamp    uint64  // Unix timestamp (ms)
-	NewGroupName string  // New group name (if UpdateType=1)
-	MemberAddr   Address // Member address (if UpdateType=2 or 3)
-	Signature    []byte  // Signature
+	GroupID      GroupID `cbor:"1,keyasint,omitempty"` // Group identifier
+	UpdateType   uint8   `cbor:"2,keyasint,omitempty"` // Update type (1=name, 2=add member, 3=remove member, 4=admin change)
+	UpdatedBy    Address `cbor:"3,keyasint,omitempty"` // Who made the update
+	Timestamp    uint64  `cbor:"4,keyasint,omitempty"` // Unix timestamp (ms)
+	NewGroupName string  `cbor:"5,keyasint,omitempty"` // New group name (if UpdateType=1)
+	MemberAddr   Address `cbor:"6,keyasint,omitempty"` // Member address (if UpdateType=2 or 3)
+	Signature    []byte  `cbor:"7,keyasint,omitempty"` // Signature
 }
 
 // Update types
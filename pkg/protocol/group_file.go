@@ -0,0 +1,196 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	// GroupFileManifestVersion is the current group file manifest format version
+	GroupFileManifestVersion = 1
+
+	// MaxGroupFileChunks limits how many MeshStorage chunks one shared file may span
+	MaxGroupFileChunks = 4096
+
+	// groupFileShareFixedSize is the encoded size of a GroupFileShare without its strings
+	groupFileShareFixedSize = 16 + 8 + 32 + 8 + 1 + 1
+)
+
+// GroupFileID uniquely identifies a file shared in a group (16 bytes)
+type GroupFileID [16]byte
+
+// ===== GROUP FILE MANIFEST =====
+
+// GroupFileChunk is one encrypted MeshStorage chunk of a shared file
+type GroupFileChunk struct {
+	ChunkID       uint64 `json:"chunk_id"`       // MeshStorage chunk ID
+	EncryptionKey []byte `json:"encryption_key"` // AES-256 key for the chunk
+	Size          int    `json:"size"`           // Plaintext size in bytes
+}
+
+// GroupFileManifest lists the chunks of a file shared in a group
+// The manifest is JSON-encoded and stored encrypted in MeshStorage; only the
+// GroupFileShare pointing at it is sent to the group.
+type GroupFileManifest struct {
+	Version   int              `json:"version"`
+	FileID    GroupFileID      `json:"file_id"`
+	GroupID   GroupID          `json:"group_id"`
+	Name      string           `json:"name"`
+	MIMEType  string           `json:"mime_type"`
+	Size      int64            `json:"size"`   // Plaintext size in bytes
+	SHA256    []byte           `json:"sha256"` // Digest of the whole plaintext
+	Owner     Address          `json:"owner"`
+	CreatedAt int64            `json:"created_at"` // Unix timestamp (ms)
+	Chunks    []GroupFileChunk `json:"chunks"`     // In file order
+}
+
+// Encode serializes the manifest to JSON
+func (m *GroupFileManifest) Encode() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// DecodeGroupFileManifest deserializes and validates a manifest
+func DecodeGroupFileManifest(data []byte) (*GroupFileManifest, error) {
+	var m GroupFileManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to decode group file manifest: %w", err)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Validate checks the manifest for structural problems
+func (m *GroupFileManifest) Validate() error {
+	if m.Version != GroupFileManifestVersion {
+		return fmt.Errorf("%w of group file manifest: %d", ErrUnsupportedVersion, m.Version)
+	}
+	if m.Name == "" {
+		return errors.New("group file has no name")
+	}
+	if len(m.Chunks) == 0 {
+		return errors.New("group file has no chunks")
+	}
+	if len(m.Chunks) > MaxGroupFileChunks {
+		return fmt.Errorf("group file has %d chunks (max %d)", len(m.Chunks), MaxGroupFileChunks)
+	}
+	if len(m.SHA256) != 32 {
+		return errors.New("group file has invalid digest length")
+	}
+
+	var total int64
+	for i, chunk := range m.Chunks {
+		if len(chunk.EncryptionKey) != 32 {
+			return fmt.Errorf("group file chunk %d has invalid encryption key length", i)
+		}
+		if chunk.Size <= 0 {
+			return fmt.Errorf("group file chunk %d is empty", i)
+		}
+		total += int64(chunk.Size)
+	}
+	if total != m.Size {
+		return fmt.Errorf("group file chunks hold %d bytes, manifest says %d", total, m.Size)
+	}
+
+	return nil
+}
+
+// ===== GROUP FILE SHARE =====
+
+// GroupFileShare is the content of a ContentTypeGroupFile group message
+// Group messages are encrypted to each member separately, so the manifest key
+// reaches only the members the message was sent to.
+type GroupFileShare struct {
+	FileID        GroupFileID `cbor:"1,keyasint,omitempty"` // File identifier
+	ManifestChunk uint64      `cbor:"2,keyasint,omitempty"` // MeshStorage chunk ID of the encrypted manifest
+	ManifestKey   [32]byte    `cbor:"3,keyasint,omitempty"` // AES-256 key for the manifest
+	Size          uint64      `cbor:"4,keyasint,omitempty"` // File size in bytes (for display before download)
+	Name          string      `cbor:"5,keyasint,omitempty"` // File name, max 255 bytes
+	MIMEType      string      `cbor:"6,keyasint,omitempty"` // Max 255 bytes
+}
+
+// Encode encodes the share to bytes
+// Format: [FileID 16][ManifestChunk 8][Key 32][Size 8][NameLen 1][Name][MIMELen 1][MIME]
+func (s *GroupFileShare) Encode() []byte {
+	name := []byte(s.Name)
+	if len(name) > 255 {
+		name = name[:255]
+	}
+	mimeType := []byte(s.MIMEType)
+	if len(mimeType) > 255 {
+		mimeType = mimeType[:255]
+	}
+
+	buf := make([]byte, groupFileShareFixedSize+len(name)+len(mimeType))
+	offset := 0
+
+	copy(buf[offset:], s.FileID[:])
+	offset += 16
+
+	binary.BigEndian.PutUint64(buf[offset:], s.ManifestChunk)
+	offset += 8
+
+	copy(buf[offset:], s.ManifestKey[:])
+	offset += 32
+
+	binary.BigEndian.PutUint64(buf[offset:], s.Size)
+	offset += 8
+
+	buf[offset] = uint8(len(name))
+	offset++
+	copy(buf[offset:], name)
+	offset += len(name)
+
+	buf[offset] = uint8(len(mimeType))
+	offset++
+	copy(buf[offset:], mimeType)
+
+	return buf
+}
+
+// Decode decodes the share from bytes
+func (s *GroupFileShare) Decode(buf []byte) error {
+	if len(buf) < groupFileShareFixedSize {
+		return fmt.Errorf("%w for group file share", ErrShortBuffer)
+	}
+
+	offset := 0
+
+	copy(s.FileID[:], buf[offset:offset+16])
+	offset += 16
+
+	s.ManifestChunk = binary.BigEndian.Uint64(buf[offset:])
+	offset += 8
+
+	copy(s.ManifestKey[:], buf[offset:offset+32])
+	offset += 32
+
+	s.Size = binary.BigEndian.Uint64(buf[offset:])
+	offset += 8
+
+	nameLen := int(buf[offset])
+	offset++
+	if len(buf) < offset+nameLen+1 {
+		return fmt.Errorf("%w for group file name", ErrShortBuffer)
+	}
+	s.Name = string(buf[offset : offset+nameLen])
+	offset += nameLen
+
+	mimeLen := int(buf[offset])
+	offset++
+	if len(buf) < offset+mimeLen {
+		return fmt.Errorf("%w for group file MIME type", ErrShortBuffer)
+	}
+	s.MIMEType = string(buf[offset : offset+mimeLen])
+
+	return nil
+}
+
+// validateGroupFileShare is the content validator for ContentTypeGroupFile
+func validateGroupFileShare(content []byte) error {
+	var share GroupFileShare
+	return share.Decode(content)
+}
@@ -0,0 +1,104 @@
+package protocol
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func testGroupFileManifest() *GroupFileManifest {
+	return &GroupFileManifest{
+		Version:   GroupFileManifestVersion,
+		FileID:    GroupFileID{1, 2, 3},
+		GroupID:   GroupID{4, 5, 6},
+		Name:      "minutes.pdf",
+		MIMEType:  "application/pdf",
+		Size:      150,
+		SHA256:    bytes.Repeat([]byte{9}, 32),
+		CreatedAt: 1700000000000,
+		Chunks: []GroupFileChunk{
+			{ChunkID: 42, EncryptionKey: bytes.Repeat([]byte{7}, 32), Size: 100},
+			{ChunkID: 43, EncryptionKey: bytes.Repeat([]byte{8}, 32), Size: 50},
+		},
+	}
+}
+
+func TestGroupFileManifestRoundTrip(t *testing.T) {
+	manifest := testGroupFileManifest()
+
+	data, err := manifest.Encode()
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	decoded, err := DecodeGroupFileManifest(data)
+	if err != nil {
+		t.Fatalf("DecodeGroupFileManifest() error = %v", err)
+	}
+	if decoded.FileID != manifest.FileID || decoded.GroupID != manifest.GroupID || len(decoded.Chunks) != 2 {
+		t.Errorf("decoded manifest = %+v, want %+v", decoded, manifest)
+	}
+}
+
+func TestGroupFileManifestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(m *GroupFileManifest)
+	}{
+		{"wrong version", func(m *GroupFileManifest) { m.Version = 99 }},
+		{"no name", func(m *GroupFileManifest) { m.Name = "" }},
+		{"no chunks", func(m *GroupFileManifest) { m.Chunks = nil }},
+		{"bad digest", func(m *GroupFileManifest) { m.SHA256 = nil }},
+		{"bad key", func(m *GroupFileManifest) { m.Chunks[0].EncryptionKey = []byte{1} }},
+		{"empty chunk", func(m *GroupFileManifest) { m.Chunks[1].Size = 0 }},
+		{"size mismatch", func(m *GroupFileManifest) { m.Size = 151 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := testGroupFileManifest()
+			tt.mutate(m)
+			if err := m.Validate(); err == nil {
+				t.Error("Validate() should fail")
+			}
+		})
+	}
+}
+
+func TestGroupFileShareEncodeDecode(t *testing.T) {
+	share := &GroupFileShare{
+		FileID:        GroupFileID{9, 9, 9},
+		ManifestChunk: 12345,
+		ManifestKey:   [32]byte{1, 2, 3},
+		Size:          5 << 20,
+		Name:          "minutes.pdf",
+		MIMEType:      "application/pdf",
+	}
+
+	var decoded GroupFileShare
+	if err := decoded.Decode(share.Encode()); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if decoded != *share {
+		t.Errorf("decoded = %+v, want %+v", decoded, *share)
+	}
+
+	if err := ValidateContent(ContentTypeGroupFile, share.Encode()); err != nil {
+		t.Errorf("ValidateContent(group file) error = %v", err)
+	}
+	if err := ValidateContent(ContentTypeGroupFile, share.Encode()[:70]); err == nil {
+		t.Error("ValidateContent(truncated group file) should fail")
+	}
+
+	// Over-long strings are cut to what the format can carry and still validate
+	long := &GroupFileShare{Name: strings.Repeat("n", 300), MIMEType: strings.Repeat("m", 300)}
+	if err := decoded.Decode(long.Encode()); err != nil {
+		t.Fatalf("Decode(long) error = %v", err)
+	}
+	if len(decoded.Name) != 255 || len(decoded.MIMEType) != 255 {
+		t.Errorf("long strings decoded as %d and %d bytes, want 255", len(decoded.Name), len(decoded.MIMEType))
+	}
+	if err := ValidateContent(ContentTypeGroupFile, long.Encode()); err != nil {
+		t.Errorf("ValidateContent(long group file) error = %v", err)
+	}
+}
@@ -0,0 +1,472 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ===== JSON ENCODING =====
+// Message structs also marshal to JSON, for debugging tools, test fixtures and
+// web dashboards. Fields keep their Go names; byte fields ([]byte and fixed-size
+// arrays such as Address and MessageID) are hex strings. The binary Encode/Decode
+// formats remain the only wire format.
+//
+// KeyBundle is deliberately left out: its default JSON is the record published
+// in the DHT, which deployed nodes parse.
+
+var (
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// marshalJSON encodes a struct with hex byte fields
+func marshalJSON(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encodeJSONStruct(&buf, reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// unmarshalJSON decodes JSON produced by marshalJSON into the struct v points to
+func unmarshalJSON(data []byte, v any) error {
+	return decodeJSONStruct(data, reflect.ValueOf(v).Elem())
+}
+
+// encodeJSONValue writes v, hex-encoding byte slices and arrays
+func encodeJSONValue(buf *bytes.Buffer, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		return encodeJSONValue(buf, v.Elem())
+
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return writeJSON(buf, hex.EncodeToString(v.Bytes()))
+		}
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		return encodeJSONArray(buf, v)
+
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			return writeJSON(buf, hex.EncodeToString(b))
+		}
+		return encodeJSONArray(buf, v)
+
+	case reflect.Struct:
+		if v.Type().Implements(jsonMarshalerType) {
+			return writeJSON(buf, v.Interface())
+		}
+		return encodeJSONStruct(buf, v)
+	}
+
+	return writeJSON(buf, v.Interface())
+}
+
+// encodeJSONStruct writes the exported fields of a struct in declaration order
+func encodeJSONStruct(buf *bytes.Buffer, v reflect.Value) error {
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+
+	buf.WriteByte('{')
+	first := true
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+
+		if err := writeJSON(buf, field.Name); err != nil {
+			return err
+		}
+		buf.WriteByte(':')
+		if err := encodeJSONValue(buf, v.Field(i)); err != nil {
+			return fmt.Errorf("%s: %w", field.Name, err)
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// encodeJSONArray writes a slice or array element by element
+func encodeJSONArray(buf *bytes.Buffer, v reflect.Value) error {
+	buf.WriteByte('[')
+	for i := 0; i < v.Len(); i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := encodeJSONValue(buf, v.Index(i)); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte(']')
+	return nil
+}
+
+// writeJSON writes v with the standard encoder
+func writeJSON(buf *bytes.Buffer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	buf.Write(data)
+	return nil
+}
+
+// decodeJSONValue decodes data into v, which must be settable
+func decodeJSONValue(data []byte, v reflect.Value) error {
+	isNull := bytes.Equal(bytes.TrimSpace(data), []byte("null"))
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if isNull {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return decodeJSONValue(data, v.Elem())
+
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			if isNull {
+				v.SetBytes(nil)
+				return nil
+			}
+			b, err := decodeJSONHex(data)
+			if err != nil {
+				return err
+			}
+			v.SetBytes(b)
+			return nil
+		}
+		if isNull {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return err
+		}
+		v.Set(reflect.MakeSlice(v.Type(), len(items), len(items)))
+		for i, item := range items {
+			if err := decodeJSONValue(item, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b, err := decodeJSONHex(data)
+			if err != nil {
+				return err
+			}
+			if len(b) != v.Len() {
+				return fmt.Errorf("want %d hex-encoded bytes, got %d", v.Len(), len(b))
+			}
+			reflect.Copy(v, reflect.ValueOf(b))
+			return nil
+		}
+
+	case reflect.Struct:
+		if !reflect.PointerTo(v.Type()).Implements(jsonUnmarshalerType) {
+			return decodeJSONStruct(data, v)
+		}
+	}
+
+	return json.Unmarshal(data, v.Addr().Interface())
+}
+
+// decodeJSONStruct decodes a JSON object into the exported fields of a struct
+// Field names match case-insensitively and unknown names are ignored, as with encoding/json.
+func decodeJSONStruct(data []byte, v reflect.Value) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	for name, value := range fields {
+		field, ok := v.Type().FieldByNameFunc(func(f string) bool { return strings.EqualFold(f, name) })
+		if !ok || !field.IsExported() || len(field.Index) != 1 {
+			continue
+		}
+		if err := decodeJSONValue(value, v.FieldByIndex(field.Index)); err != nil {
+			return fmt.Errorf("%s: %w", field.Name, err)
+		}
+	}
+	return nil
+}
+
+// decodeJSONHex decodes a JSON string of hex digits
+func decodeJSONHex(data []byte) ([]byte, error) {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("want hex string: %w", err)
+	}
+	return hex.DecodeString(s)
+}
+
+// MarshalJSON implements json.Marshaler
+func (h Header) MarshalJSON() ([]byte, error) { return marshalJSON(h) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (h *Header) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, h) }
+
+// MarshalJSON implements json.Marshaler
+func (m DirectMessage) MarshalJSON() ([]byte, error) { return marshalJSON(m) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (m *DirectMessage) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, m) }
+
+// MarshalJSON implements json.Marshaler
+func (m GroupMessage) MarshalJSON() ([]byte, error) { return marshalJSON(m) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (m *GroupMessage) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, m) }
+
+// MarshalJSON implements json.Marshaler
+func (a AckMessage) MarshalJSON() ([]byte, error) { return marshalJSON(a) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (a *AckMessage) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, a) }
+
+// MarshalJSON implements json.Marshaler
+func (n NackMessage) MarshalJSON() ([]byte, error) { return marshalJSON(n) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (n *NackMessage) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, n) }
+
+// MarshalJSON implements json.Marshaler
+func (r ReadReceipt) MarshalJSON() ([]byte, error) { return marshalJSON(r) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (r *ReadReceipt) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, r) }
+
+// MarshalJSON implements json.Marshaler
+func (t TypingIndicator) MarshalJSON() ([]byte, error) { return marshalJSON(t) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (t *TypingIndicator) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, t) }
+
+// MarshalJSON implements json.Marshaler
+func (p PresenceUpdate) MarshalJSON() ([]byte, error) { return marshalJSON(p) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *PresenceUpdate) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, p) }
+
+// MarshalJSON implements json.Marshaler
+func (m ProfileUpdate) MarshalJSON() ([]byte, error) { return marshalJSON(m) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (m *ProfileUpdate) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, m) }
+
+// MarshalJSON implements json.Marshaler
+func (m GroupCreateMessage) MarshalJSON() ([]byte, error) { return marshalJSON(m) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (m *GroupCreateMessage) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, m) }
+
+// MarshalJSON implements json.Marshaler
+func (m GroupJoinMessage) MarshalJSON() ([]byte, error) { return marshalJSON(m) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (m *GroupJoinMessage) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, m) }
+
+// MarshalJSON implements json.Marshaler
+func (m GroupLeaveMessage) MarshalJSON() ([]byte, error) { return marshalJSON(m) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (m *GroupLeaveMessage) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, m) }
+
+// MarshalJSON implements json.Marshaler
+func (m GroupUpdateMessage) MarshalJSON() ([]byte, error) { return marshalJSON(m) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (m *GroupUpdateMessage) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, m) }
+
+// MarshalJSON implements json.Marshaler
+func (m HandshakeMessage) MarshalJSON() ([]byte, error) { return marshalJSON(m) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (m *HandshakeMessage) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, m) }
+
+// MarshalJSON implements json.Marshaler
+func (m RelayForward) MarshalJSON() ([]byte, error) { return marshalJSON(m) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (m *RelayForward) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, m) }
+
+// MarshalJSON implements json.Marshaler
+func (e RelayErrorMessage) MarshalJSON() ([]byte, error) { return marshalJSON(e) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (e *RelayErrorMessage) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, e) }
+
+// MarshalJSON implements json.Marshaler
+func (n RelayMovedNotice) MarshalJSON() ([]byte, error) { return marshalJSON(n) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (n *RelayMovedNotice) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, n) }
+
+// MarshalJSON implements json.Marshaler
+func (r KeyBundleRequest) MarshalJSON() ([]byte, error) { return marshalJSON(r) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (r *KeyBundleRequest) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, r) }
+
+// MarshalJSON implements json.Marshaler
+func (r KeyBundleResponse) MarshalJSON() ([]byte, error) { return marshalJSON(r) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (r *KeyBundleResponse) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, r) }
+
+// MarshalJSON implements json.Marshaler
+func (p QueueProgress) MarshalJSON() ([]byte, error) { return marshalJSON(p) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *QueueProgress) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, p) }
+
+// MarshalJSON implements json.Marshaler
+func (w QueueWindow) MarshalJSON() ([]byte, error) { return marshalJSON(w) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (w *QueueWindow) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, w) }
+
+// MarshalJSON implements json.Marshaler
+func (t SessionTicket) MarshalJSON() ([]byte, error) { return marshalJSON(t) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (t *SessionTicket) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, t) }
+
+// MarshalJSON implements json.Marshaler
+func (r ResumeRequest) MarshalJSON() ([]byte, error) { return marshalJSON(r) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (r *ResumeRequest) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, r) }
+
+// MarshalJSON implements json.Marshaler
+func (a ResumeAck) MarshalJSON() ([]byte, error) { return marshalJSON(a) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (a *ResumeAck) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, a) }
+
+// MarshalJSON implements json.Marshaler
+func (im InitialMessage) MarshalJSON() ([]byte, error) { return marshalJSON(im) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (im *InitialMessage) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, im) }
+
+// MarshalJSON implements json.Marshaler
+func (h MessageHeader) MarshalJSON() ([]byte, error) { return marshalJSON(h) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (h *MessageHeader) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, h) }
+
+// MarshalJSON implements json.Marshaler
+func (s RecoveryShare) MarshalJSON() ([]byte, error) { return marshalJSON(s) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (s *RecoveryShare) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, s) }
+
+// MarshalJSON implements json.Marshaler
+func (r RecoveryRequest) MarshalJSON() ([]byte, error) { return marshalJSON(r) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (r *RecoveryRequest) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, r) }
+
+// MarshalJSON implements json.Marshaler
+func (m VoiceNoteMessage) MarshalJSON() ([]byte, error) { return marshalJSON(m) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (m *VoiceNoteMessage) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, m) }
+
+// MarshalJSON implements json.Marshaler
+func (m StickerMessage) MarshalJSON() ([]byte, error) { return marshalJSON(m) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (m *StickerMessage) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, m) }
+
+// MarshalJSON implements json.Marshaler
+func (r StickerPackReference) MarshalJSON() ([]byte, error) { return marshalJSON(r) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (r *StickerPackReference) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, r) }
+
+// MarshalJSON implements json.Marshaler
+func (s GroupFileShare) MarshalJSON() ([]byte, error) { return marshalJSON(s) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (s *GroupFileShare) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, s) }
+
+// MarshalJSON implements json.Marshaler
+func (f Forwarded) MarshalJSON() ([]byte, error) { return marshalJSON(f) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (f *Forwarded) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, f) }
+
+// MarshalJSON implements json.Marshaler
+func (p LinkPreview) MarshalJSON() ([]byte, error) { return marshalJSON(p) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *LinkPreview) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, p) }
+
+// MarshalJSON implements json.Marshaler
+func (s ReadState) MarshalJSON() ([]byte, error) { return marshalJSON(s) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (s *ReadState) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, s) }
+
+// MarshalJSON implements json.Marshaler
+func (s ReadStateSync) MarshalJSON() ([]byte, error) { return marshalJSON(s) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (s *ReadStateSync) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, s) }
+
+// MarshalJSON implements json.Marshaler
+func (d Device) MarshalJSON() ([]byte, error) { return marshalJSON(d) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *Device) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, d) }
+
+// MarshalJSON implements json.Marshaler
+func (f DeviceFanout) MarshalJSON() ([]byte, error) { return marshalJSON(f) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (f *DeviceFanout) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, f) }
+
+// MarshalJSON implements json.Marshaler
+func (d MediaDirectory) MarshalJSON() ([]byte, error) { return marshalJSON(d) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *MediaDirectory) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, d) }
+
+// MarshalJSON implements json.Marshaler
+func (r OfflineSyncRequest) MarshalJSON() ([]byte, error) { return marshalJSON(r) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (r *OfflineSyncRequest) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, r) }
+
+// MarshalJSON implements json.Marshaler
+func (r OfflineSyncResponse) MarshalJSON() ([]byte, error) { return marshalJSON(r) }
+
+// UnmarshalJSON implements json.Unmarshaler
+func (r *OfflineSyncResponse) UnmarshalJSON(data []byte) error { return unmarshalJSON(data, r) }
@@ -0,0 +1,92 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDirectMessageJSON(t *testing.T) {
+	msg := &DirectMessage{
+		From:        Address{0xAB, 0xCD},
+		To:          Address{0x01},
+		Timestamp:   1700000000000,
+		ContentType: ContentTypeText,
+		Content:     []byte("hi"),
+		Extensions:  []MessageExtension{{Type: 7, Data: []byte{0xFF}}},
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	for _, want := range []string{
+		`"From":"abcd000000000000000000000000000000000000"`,
+		`"Content":"6869"`,
+		`"Signature":""`,
+		`"Extensions":[{"Type":7,"Data":"ff"}]`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Marshal() = %s, missing %s", data, want)
+		}
+	}
+
+	var got DirectMessage
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !bytes.Equal(got.Encode(), msg.Encode()) {
+		t.Errorf("round trip changed the message: %s", data)
+	}
+}
+
+func TestHandshakeJSON(t *testing.T) {
+	hs := HandshakeMessage{
+		ProtocolVersion: ProtocolVersion,
+		Address:         Address{9},
+		PublicKey:       []byte("-----BEGIN PUBLIC KEY-----"),
+		Limits:          DefaultPayloadLimits(),
+	}
+
+	data, err := json.Marshal(hs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got HandshakeMessage
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if got.Address != hs.Address || !bytes.Equal(got.PublicKey, hs.PublicKey) {
+		t.Errorf("Unmarshal() = %+v", got)
+	}
+	if !reflect.DeepEqual(got.Limits, hs.Limits) {
+		t.Errorf("Limits = %+v, want %+v", got.Limits, hs.Limits)
+	}
+}
+
+func TestUnmarshalJSONFixture(t *testing.T) {
+	// Hand-written fixtures may use any case for field names and hex digits
+	fixture := `{"messageid":"0102030405060708090A0B0C0D0E0F10","from":"` + strings.Repeat("11", 20) + `","Timestamp":5,"Unknown":true}`
+
+	var ack AckMessage
+	if err := json.Unmarshal([]byte(fixture), &ack); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if ack.MessageID[0] != 0x01 || ack.MessageID[15] != 0x10 || ack.From[19] != 0x11 || ack.Timestamp != 5 {
+		t.Errorf("Unmarshal() = %+v", ack)
+	}
+
+	for _, bad := range []string{
+		`{"From":"abcd"}`,      // Wrong length for an Address
+		`{"From":"zz"}`,        // Not hex
+		`{"From":[1,2,3]}`,     // Not a string
+		`{"Timestamp":"soon"}`, // Wrong type for a scalar
+	} {
+		if err := json.Unmarshal([]byte(bad), &ack); err == nil {
+			t.Errorf("Unmarshal(%s) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package protocol
+
+import (
+	"crypto/ed25519"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ===== KEY BUNDLE DISTRIBUTION =====
+// Relays hold their users' key bundles so others can start X3DH sessions while
+// the user is offline. A user publishes its bundle signed with its Ed25519
+// identity key (MsgTypeKeyBundlePublish, payload is KeyBundlePublish); anyone
+// may fetch it with the signature (MsgTypeKeyBundleRequest). The relay pins
+// the first signing key it sees for an address and refuses bundles signed by
+// any other.
+// Each fetch hands out at most one one-time prekey, which the relay then
+// deletes, and the owner is warned (KeyBundleStatusLow) when few remain so it
+// can publish more. Responses carry the request's MessageID.
+
+// Key bundle response statuses
+const (
+	KeyBundleStatusOK       uint8 = 0x00
+	KeyBundleStatusNotFound uint8 = 0x01 // No bundle published for the address
+	KeyBundleStatusRejected uint8 = 0x02 // Publish refused (not the owner, malformed, relay has no registry)
+	KeyBundleStatusLow      uint8 = 0x03 // Unsolicited: the relay is running out of the owner's one-time prekeys
+)
+
+var ErrKeyBundleSignature = errors.New("invalid key bundle signature")
+
+// KeyBundleSignature is an owner's signature over their key bundle
+// It covers the bundle without its one-time prekeys, which the relay hands
+// out one at a time, and the signed prekey must be signed by the same key.
+type KeyBundleSignature struct {
+	SigningKey [32]byte `cbor:"1,keyasint,omitempty"` // Owner's Ed25519 identity key
+	Signature  [64]byte `cbor:"2,keyasint,omitempty"`
+}
+
+// keyBundleSigningPayload returns the bytes of a bundle covered by its owner's signature
+func keyBundleSigningPayload(bundle *KeyBundle) []byte {
+	base := *bundle
+	base.OneTimePreKeys = nil
+	return append([]byte("zentalk-key-bundle|"), base.Encode()...)
+}
+
+// SignKeyBundle signs a bundle with its owner's identity key
+func SignKeyBundle(bundle *KeyBundle, identity *IdentityKeyPair) KeyBundleSignature {
+	sig := KeyBundleSignature{SigningKey: identity.PublicKey}
+	copy(sig.Signature[:], ed25519.Sign(identity.PrivateKey[:], keyBundleSigningPayload(bundle)))
+	return sig
+}
+
+// Verify checks that the bundle and its signed prekey were signed with SigningKey
+func (s KeyBundleSignature) Verify(bundle *KeyBundle) error {
+	if !ed25519.Verify(s.SigningKey[:], keyBundleSigningPayload(bundle), s.Signature[:]) {
+		return ErrKeyBundleSignature
+	}
+	if !VerifySignedPreKey(s.SigningKey, &bundle.SignedPreKey) {
+		return fmt.Errorf("%w: signed prekey not signed by the bundle's owner", ErrKeyBundleSignature)
+	}
+	return nil
+}
+
+// KeyBundlePublish is a key bundle published by its owner
+type KeyBundlePublish struct {
+	Signature KeyBundleSignature
+	Bundle    []byte // Encoded KeyBundle
+}
+
+// Encode encodes the publish to bytes
+// Format: [SigningKey 32][Signature 64][Bundle]
+func (p *KeyBundlePublish) Encode() []byte {
+	buf := make([]byte, 0, 32+64+len(p.Bundle))
+	buf = append(buf, p.Signature.SigningKey[:]...)
+	buf = append(buf, p.Signature.Signature[:]...)
+	return append(buf, p.Bundle...)
+}
+
+// Decode decodes the publish from bytes
+func (p *KeyBundlePublish) Decode(buf []byte) error {
+	if len(buf) < 32+64 {
+		return fmt.Errorf("%w for key bundle publish", ErrShortBuffer)
+	}
+	copy(p.Signature.SigningKey[:], buf[:32])
+	copy(p.Signature.Signature[:], buf[32:96])
+	p.Bundle = append([]byte(nil), buf[96:]...)
+	return nil
+}
+
+// KeyBundle decodes the published bundle and checks its owner's signature
+func (p *KeyBundlePublish) KeyBundle() (*KeyBundle, error) {
+	bundle, err := DecodeKeyBundle(p.Bundle)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.Signature.Verify(bundle); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+// KeyBundleRequest asks a relay for a user's key bundle
+type KeyBundleRequest struct {
+	Address Address `cbor:"1,keyasint,omitempty"` // Whose bundle
+}
+
+// Encode encodes the request to bytes
+// Format: [Address 20]
+func (r *KeyBundleRequest) Encode() []byte {
+	return append([]byte(nil), r.Address[:]...)
+}
+
+// Decode decodes the request from bytes
+func (r *KeyBundleRequest) Decode(buf []byte) error {
+	if len(buf) < 20 {
+		return fmt.Errorf("%w for key bundle request", ErrShortBuffer)
+	}
+	copy(r.Address[:], buf[:20])
+	return nil
+}
+
+// KeyBundleResponse answers a publish or request, or warns an owner that prekeys are low
+type KeyBundleResponse struct {
+	Status         uint8  `cbor:"1,keyasint,omitempty"`
+	OneTimePreKeys uint32 `cbor:"2,keyasint,omitempty"` // One-time prekeys the relay still holds for the owner
+	Bundle         []byte `cbor:"3,keyasint,omitempty"` // Encoded KeyBundle with at most one one-time prekey (requests only)
+
+	// Owner's signature over Bundle (requests only)
+	Signature KeyBundleSignature `cbor:"4,keyasint,omitempty"`
+}
+
+// Encode encodes the response to bytes
+// Format: [Status 1][OneTimePreKeys 4][BundleLen 4][Bundle][SigningKey 32][Signature 64]
+// The signing key and signature follow only a bundle.
+func (r *KeyBundleResponse) Encode() []byte {
+	size := 1 + 4 + 4 + len(r.Bundle)
+	if len(r.Bundle) > 0 {
+		size += 32 + 64
+	}
+	buf := make([]byte, size)
+	buf[0] = r.Status
+	binary.BigEndian.PutUint32(buf[1:5], r.OneTimePreKeys)
+	binary.BigEndian.PutUint32(buf[5:9], uint32(len(r.Bundle)))
+	copy(buf[9:], r.Bundle)
+	if len(r.Bundle) > 0 {
+		offset := 9 + len(r.Bundle)
+		copy(buf[offset:], r.Signature.SigningKey[:])
+		copy(buf[offset+32:], r.Signature.Signature[:])
+	}
+	return buf
+}
+
+// Decode decodes the response from bytes
+func (r *KeyBundleResponse) Decode(buf []byte) error {
+	if len(buf) < 9 {
+		return fmt.Errorf("%w for key bundle response", ErrShortBuffer)
+	}
+	r.Status = buf[0]
+	r.OneTimePreKeys = binary.BigEndian.Uint32(buf[1:5])
+
+	bundleLen := binary.BigEndian.Uint32(buf[5:9])
+	if uint64(len(buf)-9) < uint64(bundleLen) {
+		return fmt.Errorf("%w for key bundle (%d bytes)", ErrShortBuffer, bundleLen)
+	}
+	r.Bundle = nil
+	r.Signature = KeyBundleSignature{}
+	if bundleLen > 0 {
+		end := 9 + int(bundleLen)
+		if len(buf)-end < 32+64 {
+			return fmt.Errorf("%w for key bundle signature", ErrShortBuffer)
+		}
+		r.Bundle = append([]byte(nil), buf[9:end]...)
+		copy(r.Signature.SigningKey[:], buf[end:end+32])
+		copy(r.Signature.Signature[:], buf[end+32:end+96])
+	}
+	return nil
+}
+
+// KeyBundle decodes the bundle carried by an OK response to a request and checks its owner's signature
+func (r *KeyBundleResponse) KeyBundle() (*KeyBundle, error) {
+	if r.Status != KeyBundleStatusOK || len(r.Bundle) == 0 {
+		return nil, fmt.Errorf("key bundle response has no bundle (status %d)", r.Status)
+	}
+	bundle, err := DecodeKeyBundle(r.Bundle)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Signature.Verify(bundle); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
@@ -0,0 +1,114 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// signedTestBundle returns a bundle whose signed prekey and signature are made with a new identity
+func signedTestBundle(t *testing.T) (*KeyBundle, *IdentityKeyPair) {
+	t.Helper()
+	identity, err := GenerateIdentityKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	spk, err := GenerateSignedPreKey(1, identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return CreateKeyBundle(Address{3}, identity, spk, nil, 9), identity
+}
+
+func TestKeyBundleResponseRoundTrip(t *testing.T) {
+	bundle, identity := signedTestBundle(t)
+	sig := SignKeyBundle(bundle, identity)
+	bundle.OneTimePreKeys = []OneTimePreKey{{KeyID: 101}}
+
+	resp := &KeyBundleResponse{Status: KeyBundleStatusOK, OneTimePreKeys: 17, Bundle: bundle.Encode(), Signature: sig}
+	var decoded KeyBundleResponse
+	if err := decoded.Decode(resp.Encode()); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if decoded.Status != resp.Status || decoded.OneTimePreKeys != 17 || !bytes.Equal(decoded.Bundle, resp.Bundle) || decoded.Signature != sig {
+		t.Fatalf("Decode() = %+v, want %+v", decoded, resp)
+	}
+
+	got, err := decoded.KeyBundle()
+	if err != nil {
+		t.Fatalf("KeyBundle() error = %v", err)
+	}
+	if got.Address != bundle.Address || len(got.OneTimePreKeys) != 1 || got.OneTimePreKeys[0].KeyID != 101 {
+		t.Errorf("KeyBundle() = %+v", got)
+	}
+
+	notFound := &KeyBundleResponse{Status: KeyBundleStatusNotFound}
+	if _, err := notFound.KeyBundle(); err == nil {
+		t.Error("KeyBundle() on a not-found response returned a bundle")
+	}
+
+	decoded.Signature.Signature[0] ^= 1
+	if _, err := decoded.KeyBundle(); !errors.Is(err, ErrKeyBundleSignature) {
+		t.Errorf("KeyBundle() with a bad signature error = %v, want ErrKeyBundleSignature", err)
+	}
+}
+
+func TestKeyBundlePublishSignature(t *testing.T) {
+	bundle, identity := signedTestBundle(t)
+	publish := &KeyBundlePublish{Signature: SignKeyBundle(bundle, identity), Bundle: bundle.Encode()}
+
+	var decoded KeyBundlePublish
+	if err := decoded.Decode(publish.Encode()); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	got, err := decoded.KeyBundle()
+	if err != nil {
+		t.Fatalf("KeyBundle() error = %v", err)
+	}
+	if got.IdentityKey != bundle.IdentityKey {
+		t.Errorf("KeyBundle() identity = %x, want %x", got.IdentityKey, bundle.IdentityKey)
+	}
+
+	// The signature covers the bundle, and the signed prekey must be the signer's
+	_, stranger := signedTestBundle(t)
+	tampered := *bundle
+	tampered.RegistrationID++
+	forgedPreKey := *bundle
+	forgedPreKey.SignedPreKey.Signature[0] ^= 1
+	for name, p := range map[string]*KeyBundlePublish{
+		"changed after signing":        {Signature: publish.Signature, Bundle: tampered.Encode()},
+		"signed by another identity":   {Signature: SignKeyBundle(bundle, stranger), Bundle: bundle.Encode()},
+		"signed prekey of another key": {Signature: SignKeyBundle(&forgedPreKey, identity), Bundle: forgedPreKey.Encode()},
+	} {
+		if _, err := p.KeyBundle(); !errors.Is(err, ErrKeyBundleSignature) {
+			t.Errorf("%s: KeyBundle() error = %v, want ErrKeyBundleSignature", name, err)
+		}
+	}
+}
+
+func TestKeyBundleRPCShortBuffers(t *testing.T) {
+	var req KeyBundleRequest
+	if err := req.Decode(make([]byte, 19)); !errors.Is(err, ErrShortBuffer) {
+		t.Errorf("KeyBundleRequest.Decode() error = %v, want ErrShortBuffer", err)
+	}
+	var publish KeyBundlePublish
+	if err := publish.Decode(make([]byte, 95)); !errors.Is(err, ErrShortBuffer) {
+		t.Errorf("KeyBundlePublish.Decode() error = %v, want ErrShortBuffer", err)
+	}
+	var resp KeyBundleResponse
+
+	// A bundle length running past the end of the payload, or a bundle without its signature
+	truncated := (&KeyBundleResponse{Bundle: make([]byte, 10)}).Encode()[:12]
+	if err := resp.Decode((&KeyBundleResponse{Bundle: make([]byte, 10)}).Encode()[:19]); !errors.Is(err, ErrShortBuffer) {
+		t.Errorf("KeyBundleResponse.Decode() without signature error = %v, want ErrShortBuffer", err)
+	}
+	if err := resp.Decode(truncated); !errors.Is(err, ErrShortBuffer) {
+		t.Errorf("KeyBundleResponse.Decode() error = %v, want ErrShortBuffer", err)
+	}
+
+	// A bundle announcing more one-time prekeys than it carries
+	encoded := (&KeyBundle{OneTimePreKeys: []OneTimePreKey{{KeyID: 1}}}).Encode()
+	if _, err := DecodeKeyBundle(encoded[:len(encoded)-1]); !errors.Is(err, ErrShortBuffer) {
+		t.Errorf("DecodeKeyBundle() error = %v, want ErrShortBuffer", err)
+	}
+}
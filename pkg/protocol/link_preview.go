@@ -0,0 +1,135 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Link preview field limits (bytes)
+const (
+	MaxLinkPreviewURL         = 2048
+	MaxLinkPreviewTitle       = 256
+	MaxLinkPreviewDescription = 1024
+	MaxLinkPreviewSiteName    = 128
+)
+
+// LinkPreview is a sender-generated preview of a URL in a text message
+// It travels inside the end-to-end encrypted message, so recipients render it
+// without contacting the linked site (which would reveal their IP address)
+type LinkPreview struct {
+	URL          string   `cbor:"1,keyasint,omitempty"` // The previewed URL (as it appears in the text)
+	Title        string   `cbor:"2,keyasint,omitempty"` // Page title
+	Description  string   `cbor:"3,keyasint,omitempty"` // Page description
+	SiteName     string   `cbor:"4,keyasint,omitempty"` // Site name (e.g., og:site_name)
+	ThumbnailID  uint64   `cbor:"5,keyasint,omitempty"` // MeshStorage chunk ID of the thumbnail (0 = none)
+	ThumbnailKey [32]byte `cbor:"6,keyasint,omitempty"` // AES-256 key for the thumbnail
+}
+
+// HasThumbnail returns true if the preview references a thumbnail image
+func (p *LinkPreview) HasThumbnail() bool {
+	return p.ThumbnailID != 0
+}
+
+// Validate checks field limits
+func (p *LinkPreview) Validate() error {
+	if p.URL == "" {
+		return fmt.Errorf("link preview has no URL")
+	}
+	if len(p.URL) > MaxLinkPreviewURL {
+		return fmt.Errorf("link preview URL too long: %d bytes", len(p.URL))
+	}
+	if len(p.Title) > MaxLinkPreviewTitle {
+		return fmt.Errorf("link preview title too long: %d bytes", len(p.Title))
+	}
+	if len(p.Description) > MaxLinkPreviewDescription {
+		return fmt.Errorf("link preview description too long: %d bytes", len(p.Description))
+	}
+	if len(p.SiteName) > MaxLinkPreviewSiteName {
+		return fmt.Errorf("link preview site name too long: %d bytes", len(p.SiteName))
+	}
+	return nil
+}
+
+// Encode encodes the link preview to bytes
+// Format: [URLLen 2][URL][TitleLen 2][Title][DescLen 2][Desc][SiteLen 2][Site][ThumbID 8][ThumbKey 32]
+func (p *LinkPreview) Encode() []byte {
+	fields := []string{p.URL, p.Title, p.Description, p.SiteName}
+
+	size := 8 + 32
+	for _, f := range fields {
+		size += 2 + len(f)
+	}
+
+	buf := make([]byte, size)
+	offset := 0
+
+	for _, f := range fields {
+		binary.BigEndian.PutUint16(buf[offset:], uint16(len(f)))
+		offset += 2
+		copy(buf[offset:], f)
+		offset += len(f)
+	}
+
+	binary.BigEndian.PutUint64(buf[offset:], p.ThumbnailID)
+	offset += 8
+
+	copy(buf[offset:], p.ThumbnailKey[:])
+
+	return buf
+}
+
+// Decode decodes the link preview from bytes
+func (p *LinkPreview) Decode(buf []byte) error {
+	offset := 0
+	fields := make([]string, 4)
+
+	for i := range fields {
+		if len(buf) < offset+2 {
+			return fmt.Errorf("%w for link preview", ErrShortBuffer)
+		}
+		fieldLen := int(binary.BigEndian.Uint16(buf[offset:]))
+		offset += 2
+
+		if len(buf) < offset+fieldLen {
+			return fmt.Errorf("%w for link preview", ErrShortBuffer)
+		}
+		fields[i] = string(buf[offset : offset+fieldLen])
+		offset += fieldLen
+	}
+
+	if len(buf) < offset+8+32 {
+		return fmt.Errorf("%w for link preview thumbnail", ErrShortBuffer)
+	}
+
+	p.URL, p.Title, p.Description, p.SiteName = fields[0], fields[1], fields[2], fields[3]
+
+	p.ThumbnailID = binary.BigEndian.Uint64(buf[offset:])
+	offset += 8
+
+	copy(p.ThumbnailKey[:], buf[offset:offset+32])
+
+	return p.Validate()
+}
+
+// SetLinkPreview attaches a link preview to the message
+func (m *DirectMessage) SetLinkPreview(preview *LinkPreview) error {
+	if err := preview.Validate(); err != nil {
+		return err
+	}
+	m.SetExtension(ExtensionLinkPreview, preview.Encode())
+	return nil
+}
+
+// LinkPreview returns the message's link preview, if any
+func (m *DirectMessage) LinkPreview() (*LinkPreview, error) {
+	data, exists := m.Extension(ExtensionLinkPreview)
+	if !exists {
+		return nil, nil
+	}
+
+	var preview LinkPreview
+	if err := preview.Decode(data); err != nil {
+		return nil, err
+	}
+	return &preview, nil
+}
@@ -0,0 +1,113 @@
+package protocol
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLinkPreviewEncodeDecode(t *testing.T) {
+	preview := &LinkPreview{
+		URL:          "https://example.com/article",
+		Title:        "An Article",
+		Description:  "Something worth reading",
+		SiteName:     "Example",
+		ThumbnailID:  777,
+		ThumbnailKey: [32]byte{1, 2, 3},
+	}
+
+	var decoded LinkPreview
+	if err := decoded.Decode(preview.Encode()); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	if decoded != *preview {
+		t.Errorf("decoded = %+v, want %+v", decoded, *preview)
+	}
+	if !decoded.HasThumbnail() {
+		t.Error("HasThumbnail() = false, want true")
+	}
+}
+
+func TestLinkPreviewValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		preview LinkPreview
+	}{
+		{"no url", LinkPreview{Title: "x"}},
+		{"long url", LinkPreview{URL: strings.Repeat("a", MaxLinkPreviewURL+1)}},
+		{"long title", LinkPreview{URL: "https://a", Title: strings.Repeat("a", MaxLinkPreviewTitle+1)}},
+		{"long description", LinkPreview{URL: "https://a", Description: strings.Repeat("a", MaxLinkPreviewDescription+1)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.preview.Validate(); err == nil {
+				t.Error("Validate() should fail")
+			}
+		})
+	}
+}
+
+func TestDirectMessageLinkPreviewExtension(t *testing.T) {
+	msg := &DirectMessage{
+		From:        Address{1},
+		To:          Address{2},
+		ContentType: ContentTypeText,
+		Content:     []byte("look at https://example.com"),
+		Signature:   []byte("sig"),
+	}
+
+	if err := msg.SetLinkPreview(&LinkPreview{URL: "https://example.com", Title: "Example"}); err != nil {
+		t.Fatalf("SetLinkPreview() error = %v", err)
+	}
+
+	var decoded DirectMessage
+	if err := decoded.Decode(msg.Encode()); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	if string(decoded.Content) != string(msg.Content) || string(decoded.Signature) != "sig" {
+		t.Errorf("decoded body = %q/%q", decoded.Content, decoded.Signature)
+	}
+
+	preview, err := decoded.LinkPreview()
+	if err != nil || preview == nil {
+		t.Fatalf("LinkPreview() = %v, %v", preview, err)
+	}
+	if preview.Title != "Example" {
+		t.Errorf("preview.Title = %q, want Example", preview.Title)
+	}
+}
+
+func TestDirectMessageWithoutExtensions(t *testing.T) {
+	msg := &DirectMessage{From: Address{1}, To: Address{2}, Content: []byte("hi")}
+
+	var decoded DirectMessage
+	if err := decoded.Decode(msg.Encode()); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	if len(decoded.Extensions) != 0 {
+		t.Errorf("Extensions = %v, want none", decoded.Extensions)
+	}
+
+	preview, err := decoded.LinkPreview()
+	if preview != nil || err != nil {
+		t.Errorf("LinkPreview() = %v, %v, want nil, nil", preview, err)
+	}
+
+	// Unknown extensions survive a round trip
+	msg.SetExtension(0x7F, []byte{9, 9})
+	if err := decoded.Decode(msg.Encode()); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if data, ok := decoded.Extension(0x7F); !ok || len(data) != 2 {
+		t.Errorf("Extension(0x7F) = %v, %v", data, ok)
+	}
+
+	// Truncated extension is rejected
+	encoded := msg.Encode()
+	if err := decoded.Decode(encoded[:len(encoded)-1]); err == nil {
+		t.Error("Decode() should fail on truncated extension")
+	}
+}
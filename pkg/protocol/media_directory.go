@@ -0,0 +1,135 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+)
+
+const (
+	// MaxMediaEndpoints caps the storage endpoints in a MediaDirectory
+	MaxMediaEndpoints = 16
+
+	// MaxMediaEndpointLength caps the length of one endpoint URL
+	MaxMediaEndpointLength = 255
+
+	// MaxMediaLimitEntries caps the per-content-type size limits in a MediaDirectory
+	MaxMediaLimitEntries = 255
+)
+
+// MediaDirectory is where a relay's users upload the media their messages reference
+// Relays answer MsgTypeMediaDirectoryRequest with the mesh storage API endpoints
+// they are affiliated with and the largest media they expect per content type,
+// so clients need no separate storage configuration.
+type MediaDirectory struct {
+	Endpoints []string         `cbor:"1,keyasint,omitempty"` // Mesh storage API base URLs, preferred first
+	MaxSize   map[uint8]uint32 `cbor:"2,keyasint,omitempty"` // Largest media per content type in bytes (absent = no limit)
+}
+
+// CheckSize returns ErrPayloadTooLarge if media of size bytes exceeds the content type's limit
+// A nil directory sets no limits.
+func (d *MediaDirectory) CheckSize(contentType uint8, size int) error {
+	if d == nil {
+		return nil
+	}
+	if max, ok := d.MaxSize[contentType]; ok && size > int(max) {
+		return fmt.Errorf("%w: %d-byte %s, relay's storage accepts %d bytes", ErrPayloadTooLarge, size, ContentTypeName(contentType), max)
+	}
+	return nil
+}
+
+// Validate checks the directory fits the encoding
+func (d *MediaDirectory) Validate() error {
+	if len(d.Endpoints) > MaxMediaEndpoints {
+		return fmt.Errorf("media directory has too many endpoints: %d", len(d.Endpoints))
+	}
+	for _, endpoint := range d.Endpoints {
+		if len(endpoint) > MaxMediaEndpointLength {
+			return fmt.Errorf("media endpoint too long: %d bytes", len(endpoint))
+		}
+	}
+	if len(d.MaxSize) > MaxMediaLimitEntries {
+		return fmt.Errorf("media directory has too many size limits: %d", len(d.MaxSize))
+	}
+	return nil
+}
+
+// Encode encodes the directory to bytes (at most MaxMediaEndpoints endpoints)
+// Format: [Count 1]([Len 1][URL])... [Count 1]([ContentType 1][Max 4])...
+func (d *MediaDirectory) Encode() []byte {
+	endpoints := d.Endpoints
+	if len(endpoints) > MaxMediaEndpoints {
+		endpoints = endpoints[:MaxMediaEndpoints]
+	}
+
+	types := make([]uint8, 0, len(d.MaxSize))
+	for t := range d.MaxSize {
+		types = append(types, t)
+	}
+	slices.Sort(types)
+	if len(types) > MaxMediaLimitEntries {
+		types = types[:MaxMediaLimitEntries]
+	}
+
+	buf := make([]byte, 0, 1+len(endpoints)*(1+MaxMediaEndpointLength)+1+5*len(types))
+	buf = append(buf, uint8(len(endpoints)))
+	for _, endpoint := range endpoints {
+		if len(endpoint) > MaxMediaEndpointLength {
+			endpoint = endpoint[:MaxMediaEndpointLength]
+		}
+		buf = append(buf, uint8(len(endpoint)))
+		buf = append(buf, endpoint...)
+	}
+	buf = append(buf, uint8(len(types)))
+	for _, t := range types {
+		buf = append(buf, t)
+		buf = binary.BigEndian.AppendUint32(buf, d.MaxSize[t])
+	}
+
+	return buf
+}
+
+// Decode decodes the directory from bytes
+func (d *MediaDirectory) Decode(buf []byte) error {
+	if len(buf) < 2 {
+		return fmt.Errorf("%w for media directory", ErrShortBuffer)
+	}
+
+	count := int(buf[0])
+	offset := 1
+	if count > MaxMediaEndpoints {
+		return fmt.Errorf("media directory has too many endpoints: %d", count)
+	}
+	d.Endpoints = nil
+	for i := 0; i < count; i++ {
+		if len(buf) < offset+1 {
+			return fmt.Errorf("%w for media endpoint %d", ErrShortBuffer, i)
+		}
+		n := int(buf[offset])
+		offset++
+		if len(buf) < offset+n {
+			return fmt.Errorf("%w for media endpoint %d", ErrShortBuffer, i)
+		}
+		d.Endpoints = append(d.Endpoints, string(buf[offset:offset+n]))
+		offset += n
+	}
+
+	if len(buf) < offset+1 {
+		return fmt.Errorf("%w for media size limits", ErrShortBuffer)
+	}
+	count = int(buf[offset])
+	offset++
+	if len(buf) != offset+5*count {
+		return fmt.Errorf("media directory length mismatch")
+	}
+	d.MaxSize = nil
+	if count > 0 {
+		d.MaxSize = make(map[uint8]uint32, count)
+	}
+	for i := 0; i < count; i++ {
+		d.MaxSize[buf[offset]] = binary.BigEndian.Uint32(buf[offset+1:])
+		offset += 5
+	}
+
+	return nil
+}
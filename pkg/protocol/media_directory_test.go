@@ -0,0 +1,78 @@
+package protocol
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMediaDirectoryEncodeDecode(t *testing.T) {
+	dir := &MediaDirectory{
+		Endpoints: []string{"https://storage1.example.org", "https://storage2.example.org:8443"},
+		MaxSize: map[uint8]uint32{
+			ContentTypeImage: 10 << 20,
+			ContentTypeVideo: 50 << 20,
+		},
+	}
+
+	decoded := &MediaDirectory{}
+	if err := decoded.Decode(dir.Encode()); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !reflect.DeepEqual(decoded, dir) {
+		t.Errorf("Decoded directory = %+v, want %+v", decoded, dir)
+	}
+
+	empty := &MediaDirectory{}
+	if err := empty.Decode((&MediaDirectory{}).Encode()); err != nil {
+		t.Fatalf("Decode(empty) error = %v", err)
+	}
+	if empty.Endpoints != nil || empty.MaxSize != nil {
+		t.Errorf("Decoded empty directory = %+v", empty)
+	}
+}
+
+func TestMediaDirectoryDecodeInvalid(t *testing.T) {
+	valid := (&MediaDirectory{
+		Endpoints: []string{"https://storage.example.org"},
+		MaxSize:   map[uint8]uint32{ContentTypeImage: 1024},
+	}).Encode()
+
+	tests := []struct {
+		name string
+		buf  []byte
+	}{
+		{"Too short", []byte{0}},
+		{"Truncated endpoint", valid[:10]},
+		{"Truncated limits", valid[:len(valid)-1]},
+		{"Trailing bytes", append(append([]byte{}, valid...), 0)},
+		{"Too many endpoints", []byte{MaxMediaEndpoints + 1, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := (&MediaDirectory{}).Decode(tt.buf); err == nil {
+				t.Error("Decode() should fail")
+			}
+		})
+	}
+}
+
+func TestMediaDirectoryCheckSize(t *testing.T) {
+	dir := &MediaDirectory{MaxSize: map[uint8]uint32{ContentTypeImage: 1000}}
+
+	if err := dir.CheckSize(ContentTypeImage, 1000); err != nil {
+		t.Errorf("CheckSize(at limit) error = %v", err)
+	}
+	if err := dir.CheckSize(ContentTypeImage, 1001); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("CheckSize(over limit) error = %v, want ErrPayloadTooLarge", err)
+	}
+	if err := dir.CheckSize(ContentTypeVideo, 1<<30); err != nil {
+		t.Errorf("CheckSize(no limit) error = %v", err)
+	}
+
+	var none *MediaDirectory
+	if err := none.CheckSize(ContentTypeImage, 1<<30); err != nil {
+		t.Errorf("nil CheckSize() error = %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// MaxMentionsPerMessage limits how many mentions a single message may carry
+const MaxMentionsPerMessage = 64
+
+// mentionSize is the encoded size of one Mention
+const mentionSize = 20 + 2 + 2
+
+// Mention marks a range of message content that refers to a group member
+type Mention struct {
+	Address Address `cbor:"1,keyasint,omitempty"` // Mentioned member
+	Offset  uint16  `cbor:"2,keyasint,omitempty"` // Byte offset of the mention in Content
+	Length  uint16  `cbor:"3,keyasint,omitempty"` // Byte length of the mention text (e.g., "@alice")
+}
+
+// encodeMentions encodes mentions as [Count 2][Address 20][Offset 2][Length 2]...
+func encodeMentions(mentions []Mention) []byte {
+	buf := make([]byte, 2+len(mentions)*mentionSize)
+	offset := 0
+
+	binary.BigEndian.PutUint16(buf[offset:], uint16(len(mentions)))
+	offset += 2
+
+	for _, mention := range mentions {
+		copy(buf[offset:], mention.Address[:])
+		offset += 20
+
+		binary.BigEndian.PutUint16(buf[offset:], mention.Offset)
+		offset += 2
+
+		binary.BigEndian.PutUint16(buf[offset:], mention.Length)
+		offset += 2
+	}
+
+	return buf
+}
+
+// decodeMentions decodes a mention list
+func decodeMentions(buf []byte) ([]Mention, error) {
+	if len(buf) < 2 {
+		return nil, fmt.Errorf("%w for mentions", ErrShortBuffer)
+	}
+
+	count := int(binary.BigEndian.Uint16(buf))
+	if count > MaxMentionsPerMessage {
+		return nil, fmt.Errorf("too many mentions: %d", count)
+	}
+	if len(buf) < 2+count*mentionSize {
+		return nil, fmt.Errorf("%w for %d mentions", ErrShortBuffer, count)
+	}
+
+	mentions := make([]Mention, count)
+	offset := 2
+	for i := range mentions {
+		copy(mentions[i].Address[:], buf[offset:offset+20])
+		offset += 20
+
+		mentions[i].Offset = binary.BigEndian.Uint16(buf[offset:])
+		offset += 2
+
+		mentions[i].Length = binary.BigEndian.Uint16(buf[offset:])
+		offset += 2
+	}
+
+	return mentions, nil
+}
+
+// metadataExtensions builds the trailing extensions for a group message
+func (m *GroupMessage) metadataExtensions() []MessageExtension {
+	extensions := make([]MessageExtension, 0, 4+len(m.Extensions))
+
+	if m.MessageID != (MessageID{}) {
+		extensions = append(extensions, MessageExtension{Type: ExtensionMessageID, Data: append([]byte(nil), m.MessageID[:]...)})
+	}
+	if m.ThreadParent != (MessageID{}) {
+		extensions = append(extensions, MessageExtension{Type: ExtensionThreadParent, Data: append([]byte(nil), m.ThreadParent[:]...)})
+	}
+	if len(m.Mentions) > 0 {
+		extensions = append(extensions, MessageExtension{Type: ExtensionMentions, Data: encodeMentions(m.Mentions)})
+	}
+	if m.Forwarded != nil {
+		extensions = append(extensions, MessageExtension{Type: ExtensionForwarded, Data: m.Forwarded.Encode()})
+	}
+
+	return append(extensions, m.Extensions...)
+}
+
+// applyExtensions fills threading/mention/forward fields from decoded extensions
+func (m *GroupMessage) applyExtensions(extensions []MessageExtension) error {
+	m.Extensions = nil
+
+	for _, ext := range extensions {
+		switch ext.Type {
+		case ExtensionMessageID:
+			if len(ext.Data) != 16 {
+				return fmt.Errorf("invalid group message ID length: %d", len(ext.Data))
+			}
+			copy(m.MessageID[:], ext.Data)
+
+		case ExtensionThreadParent:
+			if len(ext.Data) != 16 {
+				return fmt.Errorf("invalid thread parent length: %d", len(ext.Data))
+			}
+			copy(m.ThreadParent[:], ext.Data)
+
+		case ExtensionMentions:
+			mentions, err := decodeMentions(ext.Data)
+			if err != nil {
+				return err
+			}
+			m.Mentions = mentions
+
+		case ExtensionForwarded:
+			var f Forwarded
+			if err := f.Decode(ext.Data); err != nil {
+				return err
+			}
+			m.Forwarded = &f
+
+		default:
+			m.Extensions = append(m.Extensions, ext)
+		}
+	}
+
+	return nil
+}
+
+// IsThreadReply returns true if the message replies to another message in a thread
+func (m *GroupMessage) IsThreadReply() bool {
+	return m.ThreadParent != (MessageID{})
+}
+
+// Mentioned returns true if addr is mentioned in the message
+func (m *GroupMessage) Mentioned(addr Address) bool {
+	for _, mention := range m.Mentions {
+		if mention.Address == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateMentions checks that every mention range lies within Content
+func (m *GroupMessage) ValidateMentions() error {
+	if len(m.Mentions) > MaxMentionsPerMessage {
+		return fmt.Errorf("too many mentions: %d (max %d)", len(m.Mentions), MaxMentionsPerMessage)
+	}
+	for i, mention := range m.Mentions {
+		if int(mention.Offset)+int(mention.Length) > len(m.Content) {
+			return fmt.Errorf("mention %d is outside message content", i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package protocol
+
+import (
+	"testing"
+)
+
+func TestGroupMessageThreadingRoundTrip(t *testing.T) {
+	alice := Address{0xA1}
+	bob := Address{0xB0}
+
+	msg := &GroupMessage{
+		From:         alice,
+		GroupID:      GroupID{7},
+		Timestamp:    uint64(NowUnixMilli()),
+		ContentType:  ContentTypeText,
+		Content:      []byte("@bob look at this"),
+		Signature:    []byte("sig"),
+		MessageID:    GenerateMessageID(),
+		ThreadParent: GenerateMessageID(),
+		Mentions:     []Mention{{Address: bob, Offset: 0, Length: 4}},
+	}
+
+	var decoded GroupMessage
+	if err := decoded.Decode(msg.Encode()); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	if decoded.MessageID != msg.MessageID {
+		t.Errorf("MessageID = %x, want %x", decoded.MessageID, msg.MessageID)
+	}
+	if decoded.ThreadParent != msg.ThreadParent || !decoded.IsThreadReply() {
+		t.Errorf("ThreadParent = %x, want %x", decoded.ThreadParent, msg.ThreadParent)
+	}
+	if len(decoded.Mentions) != 1 || decoded.Mentions[0] != msg.Mentions[0] {
+		t.Errorf("Mentions = %+v, want %+v", decoded.Mentions, msg.Mentions)
+	}
+	if !decoded.Mentioned(bob) || decoded.Mentioned(alice) {
+		t.Error("Mentioned() returned wrong result")
+	}
+	if string(decoded.Content) != string(msg.Content) || string(decoded.Signature) != "sig" {
+		t.Errorf("decoded body = %q/%q", decoded.Content, decoded.Signature)
+	}
+}
+
+func TestGroupMessageWithoutThreading(t *testing.T) {
+	msg := &GroupMessage{From: Address{1}, GroupID: GroupID{2}, Content: []byte("hi")}
+
+	var decoded GroupMessage
+	if err := decoded.Decode(msg.Encode()); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	if decoded.IsThreadReply() || len(decoded.Mentions) != 0 || decoded.MessageID != (MessageID{}) {
+		t.Errorf("plain group message decoded with metadata: %+v", decoded)
+	}
+}
+
+func TestGroupMessageValidateMentions(t *testing.T) {
+	msg := &GroupMessage{
+		Content:  []byte("@bob"),
+		Mentions: []Mention{{Address: Address{1}, Offset: 0, Length: 4}},
+	}
+	if err := msg.ValidateMentions(); err != nil {
+		t.Errorf("ValidateMentions() error = %v", err)
+	}
+
+	msg.Mentions[0].Offset = 1
+	if err := msg.ValidateMentions(); err == nil {
+		t.Error("ValidateMentions() should reject out-of-range mention")
+	}
+}
@@ -0,0 +1,108 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	// MaxRouteEntries caps the addresses in one RouteUpdate
+	MaxRouteEntries = 4096
+
+	// routeEntrySize is the encoded size of a RouteEntry
+	routeEntrySize = 20 + 1
+)
+
+// RouteEntry is one address a relay can deliver to
+type RouteEntry struct {
+	Address Address `cbor:"1,keyasint,omitempty"`
+	Hops    uint8   `cbor:"2,keyasint,omitempty"` // Relays between the announcing relay and the user (0 = connected to it)
+}
+
+// RouteUpdate is a relay's announcement of the users it can reach
+// Each update replaces the previous one from the same relay, so addresses it
+// leaves out are withdrawn.
+type RouteUpdate struct {
+	Entries []RouteEntry `cbor:"1,keyasint,omitempty"`
+}
+
+// Encode encodes the update to bytes
+// Format: [Count 2][Address 20, Hops 1]...
+func (u *RouteUpdate) Encode() []byte {
+	entries := u.Entries
+	if len(entries) > MaxRouteEntries {
+		entries = entries[:MaxRouteEntries]
+	}
+
+	buf := make([]byte, 2+routeEntrySize*len(entries))
+	binary.BigEndian.PutUint16(buf, uint16(len(entries)))
+
+	offset := 2
+	for _, entry := range entries {
+		copy(buf[offset:], entry.Address[:])
+		buf[offset+20] = entry.Hops
+		offset += routeEntrySize
+	}
+
+	return buf
+}
+
+// Decode decodes the update from bytes
+func (u *RouteUpdate) Decode(buf []byte) error {
+	if len(buf) < 2 {
+		return fmt.Errorf("%w for route update", ErrShortBuffer)
+	}
+
+	count := int(binary.BigEndian.Uint16(buf))
+	if count > MaxRouteEntries {
+		return fmt.Errorf("route update has %d entries (max %d)", count, MaxRouteEntries)
+	}
+	if len(buf) != 2+routeEntrySize*count {
+		return fmt.Errorf("route update length mismatch")
+	}
+
+	u.Entries = make([]RouteEntry, count)
+	offset := 2
+	for i := range u.Entries {
+		copy(u.Entries[i].Address[:], buf[offset:offset+20])
+		u.Entries[i].Hops = buf[offset+20]
+		offset += routeEntrySize
+	}
+
+	return nil
+}
+
+// RoutedMessage carries a message for final delivery from one relay to another
+// The payload is what the recipient would have been sent directly; it is
+// end-to-end encrypted, so relays along the way learn only the recipient.
+type RoutedMessage struct {
+	Recipient Address `cbor:"1,keyasint,omitempty"`
+	HopLimit  uint8   `cbor:"2,keyasint,omitempty"` // Further relays the message may be passed to
+	Payload   []byte  `cbor:"3,keyasint,omitempty"`
+}
+
+// Encode encodes the message to bytes
+// Format: [Recipient 20][HopLimit 1][Payload]
+func (m *RoutedMessage) Encode() []byte {
+	buf := make([]byte, 21+len(m.Payload))
+	copy(buf, m.Recipient[:])
+	buf[20] = m.HopLimit
+	copy(buf[21:], m.Payload)
+	return buf
+}
+
+// Decode decodes the message from bytes
+func (m *RoutedMessage) Decode(buf []byte) error {
+	if len(buf) < 21 {
+		return fmt.Errorf("%w for routed message", ErrShortBuffer)
+	}
+
+	copy(m.Recipient[:], buf[:20])
+	m.HopLimit = buf[20]
+	m.Payload = append([]byte(nil), buf[21:]...)
+
+	if IsZeroAddress(m.Recipient) {
+		return fmt.Errorf("routed message has no recipient")
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package protocol
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRouteUpdateEncodeDecode(t *testing.T) {
+	update := &RouteUpdate{Entries: []RouteEntry{
+		{Address: Address{1}, Hops: 0},
+		{Address: Address{2}, Hops: 3},
+	}}
+
+	var decoded RouteUpdate
+	if err := decoded.Decode(update.Encode()); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if len(decoded.Entries) != 2 || decoded.Entries[0] != update.Entries[0] || decoded.Entries[1] != update.Entries[1] {
+		t.Errorf("Decode() = %+v, want %+v", decoded.Entries, update.Entries)
+	}
+
+	var empty RouteUpdate
+	if err := empty.Decode((&RouteUpdate{}).Encode()); err != nil || len(empty.Entries) != 0 {
+		t.Errorf("Decode(empty) = %+v, %v", empty.Entries, err)
+	}
+
+	encoded := update.Encode()
+	if err := decoded.Decode(encoded[:len(encoded)-1]); err == nil {
+		t.Error("Decode(truncated) expected error, got nil")
+	}
+}
+
+func TestRoutedMessageEncodeDecode(t *testing.T) {
+	msg := &RoutedMessage{Recipient: Address{7}, HopLimit: 2, Payload: []byte("sealed")}
+
+	var decoded RoutedMessage
+	if err := decoded.Decode(msg.Encode()); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if decoded.Recipient != msg.Recipient || decoded.HopLimit != 2 || !bytes.Equal(decoded.Payload, msg.Payload) {
+		t.Errorf("Decode() = %+v, want %+v", decoded, msg)
+	}
+
+	if err := decoded.Decode((&RoutedMessage{HopLimit: 1}).Encode()); err == nil {
+		t.Error("Decode(zero recipient) expected error, got nil")
+	}
+}
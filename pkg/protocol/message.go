@@ -1,6 +1,7 @@
 package protocol
 
 import (
+	"crypto/sha256"
 	"encoding/binary"
 	"fmt"
 )
@@ -31,19 +32,23 @@ func NewMessage(msgType uint16, payload []byte) *Message {
 
 // DirectMessage represents a 1-to-1 message
 type DirectMessage struct {
-	From           Address   // Sender address
-	To             Address   // Recipient address
-	Timestamp      uint64    // Unix timestamp (ms)
-	SequenceNumber uint64    // Message sequence number (for ordering)
-	ContentType    uint8     // Content type
-	ReplyTo        MessageID // Optional: message being replied to
-	Content        []byte    // Encrypted content
-	Signature      []byte    // Signature
+	From           Address   `cbor:"1,keyasint,omitempty"` // Sender address
+	To             Address   `cbor:"2,keyasint,omitempty"` // Recipient address
+	Timestamp      uint64    `cbor:"3,keyasint,omitempty"` // Unix timestamp (ms)
+	SequenceNumber uint64    `cbor:"4,keyasint,omitempty"` // Message sequence number (for ordering)
+	ContentType    uint8     `cbor:"5,keyasint,omitempty"` // Content type
+	ReplyTo        MessageID `cbor:"6,keyasint,omitempty"` // Optional: message being replied to
+	Content        []byte    `cbor:"7,keyasint,omitempty"` // Encrypted content
+	Signature      []byte    `cbor:"8,keyasint,omitempty"` // Signature
+
+	// Optional attachments appended after the signature (link previews, ...)
+	// Older decoders ignore the trailing bytes
+	Extensions []MessageExtension `cbor:"9,keyasint,omitempty"`
 }
 
 // Encode encodes direct message to bytes
 func (m *DirectMessage) Encode() []byte {
-	size := 20 + 20 + 8 + 8 + 1 + 16 + 4 + len(m.Content) + 4 + len(m.Signature)
+	size := 20 + 20 + 8 + 8 + 1 + 16 + 4 + len(m.Content) + 4 + len(m.Signature) + extensionsSize(m.Extensions)
 	buf := make([]byte, size)
 	offset := 0
 
@@ -75,6 +80,9 @@ func (m *DirectMessage) Encode() []byte {
 	offset += 4
 
 	copy(buf[offset:], m.Signature)
+	offset += len(m.Signature)
+
+	encodeExtensions(buf[offset:], m.Extensions)
 
 	return buf
 }
@@ -113,6 +121,13 @@ func (m *DirectMessage) Decode(buf []byte) error {
 
 	m.Signature = make([]byte, sigLen)
 	copy(m.Signature, buf[offset:offset+int(sigLen)])
+	offset += int(sigLen)
+
+	extensions, err := decodeExtensions(buf[offset:])
+	if err != nil {
+		return err
+	}
+	m.Extensions = extensions
 
 	return nil
 }
@@ -121,16 +136,24 @@ func (m *DirectMessage) Decode(buf []byte) error {
 
 // AckMessage represents a message acknowledgment
 type AckMessage struct {
-	From           Address   // Sender of the ACK
-	To             Address   // Recipient of the ACK
-	MessageID      MessageID // Message being acknowledged
-	SequenceNumber uint64    // Sequence number being acknowledged
-	Timestamp      uint64    // Unix timestamp (ms)
+	From           Address   `cbor:"1,keyasint,omitempty"` // Sender of the ACK
+	To             Address   `cbor:"2,keyasint,omitempty"` // Recipient of the ACK
+	MessageID      MessageID `cbor:"3,keyasint,omitempty"` // Message being acknowledged
+	SequenceNumber uint64    `cbor:"4,keyasint,omitempty"` // Sequence number being acknowledged
+	Timestamp      uint64    `cbor:"5,keyasint,omitempty"` // Unix timestamp (ms)
+	ReturnRelay    Address   `cbor:"6,keyasint,omitempty"` // Relay to route the ACK to (zero = the acknowledging user's relay only)
+	Signature      []byte    `cbor:"7,keyasint,omitempty"` // Acknowledging user's RSA signature over Digest; relays keep it as proof of delivery
 }
 
 // Encode encodes ACK message to bytes
+// The return relay is appended only when set or followed by a signature, so
+// older peers see the same 72 bytes.
 func (a *AckMessage) Encode() []byte {
-	buf := make([]byte, 20+20+16+8+8)
+	size := 20 + 20 + 16 + 8 + 8
+	if a.ReturnRelay != (Address{}) || len(a.Signature) > 0 {
+		size += 20 + len(a.Signature)
+	}
+	buf := make([]byte, size)
 	offset := 0
 
 	copy(buf[offset:], a.From[:])
@@ -146,14 +169,28 @@ func (a *AckMessage) Encode() []byte {
 	offset += 8
 
 	binary.BigEndian.PutUint64(buf[offset:], a.Timestamp)
+	offset += 8
+
+	if len(buf) > offset {
+		copy(buf[offset:], a.ReturnRelay[:])
+		copy(buf[offset+20:], a.Signature)
+	}
 
 	return buf
 }
 
+// Digest returns the hash of the ack without its signature, which the acknowledging user signs
+func (a *AckMessage) Digest() []byte {
+	unsigned := *a
+	unsigned.Signature = nil
+	digest := sha256.Sum256(append([]byte("zentalk-ack|"), unsigned.Encode()...))
+	return digest[:]
+}
+
 // Decode decodes ACK message from bytes
 func (a *AckMessage) Decode(buf []byte) error {
 	if len(buf) < 72 {
-		return fmt.Errorf("buffer too short for ACK message")
+		return fmt.Errorf("%w for ACK message", ErrShortBuffer)
 	}
 
 	offset := 0
@@ -171,19 +208,30 @@ func (a *AckMessage) Decode(buf []byte) error {
 	offset += 8
 
 	a.Timestamp = binary.BigEndian.Uint64(buf[offset:])
+	offset += 8
+
+	a.ReturnRelay = Address{}
+	a.Signature = nil
+	if len(buf) >= offset+20 {
+		copy(a.ReturnRelay[:], buf[offset:offset+20])
+		offset += 20
+	}
+	if len(buf) > offset {
+		a.Signature = append([]byte(nil), buf[offset:]...)
+	}
 
 	return nil
 }
 
 // NackMessage represents a negative acknowledgment (message error)
 type NackMessage struct {
-	From           Address   // Sender of the NACK
-	To             Address   // Recipient of the NACK
-	MessageID      MessageID // Message that failed
-	SequenceNumber uint64    // Sequence number that failed
-	Timestamp      uint64    // Unix timestamp (ms)
-	ErrorCode      uint8     // Error code
-	ErrorMessage   []byte    // Optional error description
+	From           Address   `cbor:"1,keyasint,omitempty"` // Sender of the NACK
+	To             Address   `cbor:"2,keyasint,omitempty"` // Recipient of the NACK
+	MessageID      MessageID `cbor:"3,keyasint,omitempty"` // Message that failed
+	SequenceNumber uint64    `cbor:"4,keyasint,omitempty"` // Sequence number that failed
+	Timestamp      uint64    `cbor:"5,keyasint,omitempty"` // Unix timestamp (ms)
+	ErrorCode      uint8     `cbor:"6,keyasint,omitempty"` // Error code
+	ErrorMessage   []byte    `cbor:"7,keyasint,omitempty"` // Optional error description
 }
 
 // Error codes for NACK
@@ -230,7 +278,7 @@ func (n *NackMessage) Encode() []byte {
 // Decode decodes NACK message from bytes
 func (n *NackMessage) Decode(buf []byte) error {
 	if len(buf) < 75 {
-		return fmt.Errorf("buffer too short for NACK message")
+		return fmt.Errorf("%w for NACK message", ErrShortBuffer)
 	}
 
 	offset := 0
@@ -257,7 +305,7 @@ func (n *NackMessage) Decode(buf []byte) error {
 	offset += 2
 
 	if len(buf) < offset+int(errorMsgLen) {
-		return fmt.Errorf("buffer too short for error message")
+		return fmt.Errorf("%w for error message", ErrShortBuffer)
 	}
 
 	n.ErrorMessage = make([]byte, errorMsgLen)
@@ -270,17 +318,25 @@ func (n *NackMessage) Decode(buf []byte) error {
 
 // GroupMessage represents a group chat message
 type GroupMessage struct {
-	From        Address // Sender address
-	GroupID     GroupID // Group identifier
-	Timestamp   uint64  // Unix timestamp (ms)
-	ContentType uint8   // Content type
-	Content     []byte  // Encrypted with group key
-	Signature   []byte  // Signature
+	From        Address `cbor:"1,keyasint,omitempty"` // Sender address
+	GroupID     GroupID `cbor:"2,keyasint,omitempty"` // Group identifier
+	Timestamp   uint64  `cbor:"3,keyasint,omitempty"` // Unix timestamp (ms)
+	ContentType uint8   `cbor:"4,keyasint,omitempty"` // Content type
+	Content     []byte  `cbor:"5,keyasint,omitempty"` // Encrypted with group key
+	Signature   []byte  `cbor:"6,keyasint,omitempty"` // Signature
+
+	// Optional threading/mention/forward metadata, carried as trailing extensions
+	MessageID    MessageID          `cbor:"7,keyasint,omitempty"`  // Identifies this message so replies can reference it
+	ThreadParent MessageID          `cbor:"8,keyasint,omitempty"`  // Message this one replies to in a thread (zero = top level)
+	Mentions     []Mention          `cbor:"9,keyasint,omitempty"`  // @mentions within Content
+	Extensions   []MessageExtension `cbor:"10,keyasint,omitempty"` // Other (unknown) extensions, preserved as-is
+	Forwarded    *Forwarded         `cbor:"11,keyasint,omitempty"` // Original sender if forwarded (nil = not forwarded)
 }
 
 // Encode encodes group message to bytes
 func (m *GroupMessage) Encode() []byte {
-	size := 20 + 32 + 8 + 1 + 4 + len(m.Content) + 4 + len(m.Signature)
+	extensions := m.metadataExtensions()
+	size := 20 + 32 + 8 + 1 + 4 + len(m.Content) + 4 + len(m.Signature) + extensionsSize(extensions)
 	buf := make([]byte, size)
 	offset := 0
 
@@ -306,6 +362,9 @@ func (m *GroupMessage) Encode() []byte {
 	offset += 4
 
 	copy(buf[offset:], m.Signature)
+	offset += len(m.Signature)
+
+	encodeExtensions(buf[offset:], extensions)
 
 	return buf
 }
@@ -338,19 +397,25 @@ func (m *GroupMessage) Decode(buf []byte) error {
 
 	m.Signature = make([]byte, sigLen)
 	copy(m.Signature, buf[offset:offset+int(sigLen)])
+	offset += int(sigLen)
 
-	return nil
+	extensions, err := decodeExtensions(buf[offset:])
+	if err != nil {
+		return err
+	}
+
+	return m.applyExtensions(extensions)
 }
 
 // ===== READ RECEIPT =====
 
 // ReadReceipt represents a message read acknowledgment
 type ReadReceipt struct {
-	From       Address   // Sender of the receipt (who read the message)
-	To         Address   // Recipient of the receipt (original sender)
-	MessageID  MessageID // Message that was read
-	Timestamp  uint64    // When message was read (Unix timestamp ms)
-	ReadStatus uint8     // 0=delivered, 1=read, 2=seen
+	From       Address   `cbor:"1,keyasint,omitempty"` // Sender of the receipt (who read the message)
+	To         Address   `cbor:"2,keyasint,omitempty"` // Recipient of the receipt (original sender)
+	MessageID  MessageID `cbor:"3,keyasint,omitempty"` // Message that was read
+	Timestamp  uint64    `cbor:"4,keyasint,omitempty"` // When message was read (Unix timestamp ms)
+	ReadStatus uint8     `cbor:"5,keyasint,omitempty"` // 0=delivered, 1=read, 2=seen
 }
 
 // Read status constants
@@ -389,7 +454,7 @@ func (r *ReadReceipt) Encode() []byte {
 // Decode decodes read receipt from bytes
 func (r *ReadReceipt) Decode(buf []byte) error {
 	if len(buf) < 66 {
-		return fmt.Errorf("buffer too short for read receipt")
+		return fmt.Errorf("%w for read receipt", ErrShortBuffer)
 	}
 
 	offset := 0
@@ -471,6 +471,40 @@ func TestReadReceiptDecodeInvalidType(t *testing.T) {
 	}
 }
 
+func TestPresenceUpdateEncodeDecode(t *testing.T) {
+	update := &PresenceUpdate{
+		Address:   Address{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20},
+		Status:    PresenceAway,
+		LastSeen:  uint64(NowUnixMilli()) - 60000,
+		Timestamp: uint64(NowUnixMilli()),
+	}
+
+	encoded := update.Encode()
+
+	// Verify size (1 + 20 + 1 + 8 + 8 = 38 bytes)
+	if len(encoded) != 38 {
+		t.Errorf("Encode() length = %d, want 38", len(encoded))
+	}
+
+	decoded := &PresenceUpdate{}
+	if err := decoded.Decode(encoded); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if *decoded != *update {
+		t.Errorf("Decoded presence = %+v, want %+v", decoded, update)
+	}
+
+	if err := decoded.Decode(encoded[:37]); err == nil {
+		t.Error("Decode() expected error for short buffer, got nil")
+	}
+
+	// A typing indicator must not decode as presence
+	typing := (&TypingIndicator{IsTyping: true}).Encode()
+	if err := decoded.Decode(append(typing, make([]byte, 38)...)); err == nil {
+		t.Error("Decode() expected error for invalid message type, got nil")
+	}
+}
+
 func TestMessageEncodeDecodeConsistency(t *testing.T) {
 	// Test that encode/decode is deterministic and consistent
 	fromAddr := Address{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20}
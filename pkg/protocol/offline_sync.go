@@ -0,0 +1,155 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// ===== OFFLINE SYNC =====
+// A user who sets FlagOfflineSync on its handshake or resume is not pushed its
+// offline queue. It pulls the queue instead: each OfflineSyncRequest asks for
+// the next page of queued messages after a queue sequence and acknowledges
+// everything up to that sequence, which the relay then prunes. The client
+// repeats with the sequence of the last message it got until a page comes back
+// empty; that last request acknowledges the final page.
+
+// Offline sync page sizes, in messages
+const (
+	DefaultOfflineSyncPage = 50  // Page size when a request sets no limit
+	MaxOfflineSyncPage     = 500 // Larger limits are clamped
+)
+
+// offlineMessageOverhead is an OfflineMessage's encoded size without its payload
+const offlineMessageOverhead = 8 + 8 + 4
+
+// OfflineSyncRequest asks a relay for the next page of the client's offline queue
+type OfflineSyncRequest struct {
+	After uint64 `cbor:"1,keyasint,omitempty"` // Queue sequence of the last message received; it and all before it are acknowledged
+	Since uint64 `cbor:"2,keyasint,omitempty"` // Skip messages queued before this (unix seconds, 0 = all)
+	Limit uint16 `cbor:"3,keyasint,omitempty"` // Most messages wanted (0 = DefaultOfflineSyncPage)
+}
+
+// Encode encodes the request to bytes
+// Format: [After 8][Since 8][Limit 2]
+func (r *OfflineSyncRequest) Encode() []byte {
+	buf := make([]byte, 18)
+	binary.BigEndian.PutUint64(buf[0:8], r.After)
+	binary.BigEndian.PutUint64(buf[8:16], r.Since)
+	binary.BigEndian.PutUint16(buf[16:18], r.Limit)
+	return buf
+}
+
+// Decode decodes the request from bytes
+func (r *OfflineSyncRequest) Decode(buf []byte) error {
+	if len(buf) < 18 {
+		return fmt.Errorf("%w for offline sync request", ErrShortBuffer)
+	}
+	r.After = binary.BigEndian.Uint64(buf[0:8])
+	r.Since = binary.BigEndian.Uint64(buf[8:16])
+	r.Limit = binary.BigEndian.Uint16(buf[16:18])
+	return nil
+}
+
+// PageSize returns the limit with the default applied and clamped to MaxOfflineSyncPage
+func (r *OfflineSyncRequest) PageSize() int {
+	if r.Limit == 0 {
+		return DefaultOfflineSyncPage
+	}
+	return int(min(r.Limit, MaxOfflineSyncPage))
+}
+
+// OfflineMessage is one queued message in an offline sync page
+type OfflineMessage struct {
+	Seq      uint64 `cbor:"1,keyasint,omitempty"` // Queue sequence (see QueuedMessageID)
+	QueuedAt uint64 `cbor:"2,keyasint,omitempty"` // When it was queued (unix seconds, bucketed to the hour)
+	Payload  []byte `cbor:"3,keyasint,omitempty"` // Encrypted payload as a DirectMessage would carry it
+}
+
+// EncodedSize returns the message's length in an encoded OfflineSyncResponse
+func (m *OfflineMessage) EncodedSize() int {
+	return offlineMessageOverhead + len(m.Payload)
+}
+
+// OfflineSyncResponse is a page of a user's offline queue, oldest first
+type OfflineSyncResponse struct {
+	Messages  []OfflineMessage `cbor:"1,keyasint,omitempty"`
+	Remaining uint32           `cbor:"2,keyasint,omitempty"` // Still queued after this page
+}
+
+// Cursor returns the sequence to acknowledge in the next request (0 for an empty page)
+func (r *OfflineSyncResponse) Cursor() uint64 {
+	if len(r.Messages) == 0 {
+		return 0
+	}
+	return r.Messages[len(r.Messages)-1].Seq
+}
+
+// Validate checks the page fits the encoding and is in queue order
+func (r *OfflineSyncResponse) Validate() error {
+	if len(r.Messages) > MaxOfflineSyncPage {
+		return fmt.Errorf("offline sync page has too many messages: %d", len(r.Messages))
+	}
+	for i := 1; i < len(r.Messages); i++ {
+		if r.Messages[i].Seq <= r.Messages[i-1].Seq {
+			return fmt.Errorf("offline sync page out of order at message %d", i)
+		}
+	}
+	return nil
+}
+
+// EncodedSize returns the length of Encode's output
+func (r *OfflineSyncResponse) EncodedSize() int {
+	size := 2 + 4
+	for i := range r.Messages {
+		size += r.Messages[i].EncodedSize()
+	}
+	return size
+}
+
+// Encode encodes the page to bytes
+// Format: [Remaining 4][Count 2]([Seq 8][QueuedAt 8][Len 4][Payload])...
+func (r *OfflineSyncResponse) Encode() []byte {
+	buf := make([]byte, 6, r.EncodedSize())
+	binary.BigEndian.PutUint32(buf[0:4], r.Remaining)
+	binary.BigEndian.PutUint16(buf[4:6], uint16(len(r.Messages)))
+	for _, msg := range r.Messages {
+		buf = binary.BigEndian.AppendUint64(buf, msg.Seq)
+		buf = binary.BigEndian.AppendUint64(buf, msg.QueuedAt)
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(msg.Payload)))
+		buf = append(buf, msg.Payload...)
+	}
+	return buf
+}
+
+// Decode decodes the page from bytes
+func (r *OfflineSyncResponse) Decode(buf []byte) error {
+	if len(buf) < 6 {
+		return fmt.Errorf("%w for offline sync response", ErrShortBuffer)
+	}
+	r.Remaining = binary.BigEndian.Uint32(buf[0:4])
+	count := int(binary.BigEndian.Uint16(buf[4:6]))
+	if count > MaxOfflineSyncPage {
+		return fmt.Errorf("offline sync page has too many messages: %d", count)
+	}
+
+	offset := 6
+	r.Messages = make([]OfflineMessage, 0, count)
+	for i := 0; i < count; i++ {
+		if len(buf) < offset+offlineMessageOverhead {
+			return fmt.Errorf("%w for offline message %d", ErrShortBuffer, i)
+		}
+		msg := OfflineMessage{
+			Seq:      binary.BigEndian.Uint64(buf[offset : offset+8]),
+			QueuedAt: binary.BigEndian.Uint64(buf[offset+8 : offset+16]),
+		}
+		length := int(binary.BigEndian.Uint32(buf[offset+16 : offset+20]))
+		offset += offlineMessageOverhead
+		if len(buf)-offset < length {
+			return fmt.Errorf("%w for offline message %d payload", ErrShortBuffer, i)
+		}
+		msg.Payload = append([]byte(nil), buf[offset:offset+length]...)
+		offset += length
+		r.Messages = append(r.Messages, msg)
+	}
+	return r.Validate()
+}
@@ -0,0 +1,86 @@
+package protocol
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOfflineSyncRequestEncodeDecode(t *testing.T) {
+	req := &OfflineSyncRequest{After: 42, Since: 1700000000, Limit: 20}
+
+	decoded := &OfflineSyncRequest{}
+	if err := decoded.Decode(req.Encode()); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if *decoded != *req {
+		t.Errorf("Decoded request = %+v, want %+v", decoded, req)
+	}
+
+	for limit, want := range map[uint16]int{0: DefaultOfflineSyncPage, 7: 7, MaxOfflineSyncPage + 1: MaxOfflineSyncPage} {
+		if got := (&OfflineSyncRequest{Limit: limit}).PageSize(); got != want {
+			t.Errorf("PageSize() with limit %d = %d, want %d", limit, got, want)
+		}
+	}
+}
+
+func TestOfflineSyncResponseEncodeDecode(t *testing.T) {
+	page := &OfflineSyncResponse{
+		Messages: []OfflineMessage{
+			{Seq: 3, QueuedAt: 1700000000, Payload: []byte("first")},
+			{Seq: 5, QueuedAt: 1700003600, Payload: []byte("second")},
+		},
+		Remaining: 12,
+	}
+
+	encoded := page.Encode()
+	if len(encoded) != page.EncodedSize() {
+		t.Errorf("Encode() = %d bytes, EncodedSize() = %d", len(encoded), page.EncodedSize())
+	}
+
+	decoded := &OfflineSyncResponse{}
+	if err := decoded.Decode(encoded); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !reflect.DeepEqual(decoded, page) {
+		t.Errorf("Decoded page = %+v, want %+v", decoded, page)
+	}
+	if decoded.Cursor() != 5 {
+		t.Errorf("Cursor() = %d, want 5", decoded.Cursor())
+	}
+
+	empty := &OfflineSyncResponse{}
+	if err := empty.Decode((&OfflineSyncResponse{}).Encode()); err != nil {
+		t.Fatalf("Decode(empty) error = %v", err)
+	}
+	if len(empty.Messages) != 0 || empty.Cursor() != 0 {
+		t.Errorf("Decoded empty page = %+v", empty)
+	}
+}
+
+func TestOfflineSyncResponseDecodeInvalid(t *testing.T) {
+	valid := (&OfflineSyncResponse{
+		Messages: []OfflineMessage{{Seq: 1, Payload: []byte("a")}, {Seq: 2, Payload: []byte("b")}},
+	}).Encode()
+	unordered := (&OfflineSyncResponse{
+		Messages: []OfflineMessage{{Seq: 2}, {Seq: 1}},
+	}).Encode()
+
+	tests := []struct {
+		name string
+		buf  []byte
+	}{
+		{"Too short", []byte{0, 0, 0}},
+		{"Truncated message", valid[:10]},
+		{"Truncated payload", valid[:len(valid)-1]},
+		{"Too many messages", []byte{0, 0, 0, 0, 0xFF, 0xFF}},
+		{"Out of order", unordered},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := (&OfflineSyncResponse{}).Decode(tt.buf); err == nil {
+				t.Error("Decode() should fail")
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrPayloadTooLarge is returned when a header announces more payload than allowed
+var ErrPayloadTooLarge = errors.New("payload exceeds negotiated limit")
+
+// MaxPayloadLimitEntries caps the per-type entries in an encoded PayloadLimits
+const MaxPayloadLimitEntries = 255
+
+// PayloadLimits bounds the payload length a peer accepts for each message type
+// Peers advertise their limits in the handshake and both sides enforce the
+// negotiated (smaller) value. A nil *PayloadLimits applies the defaults.
+type PayloadLimits struct {
+	Default uint32            `cbor:"1,keyasint,omitempty"` // Limit for types without their own entry
+	PerType map[uint16]uint32 `cbor:"2,keyasint,omitempty"` // Tighter (or looser) limits per message type
+}
+
+// defaultPayloadLimits backs DefaultPayloadLimits and nil receivers
+var defaultPayloadLimits = PayloadLimits{
+	Default: MaxRelayPayloadSize,
+	PerType: map[uint16]uint32{
+		// Connection management: keys, padding and nothing else
+		MsgTypeHandshake:     64 * 1024,
+		MsgTypeHandshakeAck:  64 * 1024,
+		MsgTypePing:          4 * 1024,
+		MsgTypePong:          4 * 1024,
+		MsgTypeDisconnect:    4 * 1024,
+		MsgTypeProbe:         1024 * 1024,
+		MsgTypeProbeAck:      4 * 1024,
+		MsgTypeResume:        4 * 1024,
+		MsgTypeResumeAck:     4 * 1024,
+		MsgTypeTicket:        4 * 1024,
+		MsgTypeQueueProgress: 4 * 1024,
+		MsgTypeQueueWindow:   4 * 1024,
+		MsgTypeOfflineSync:   4 * 1024,
+		MsgTypeStartTLS:      0,
+		MsgTypeStartTLSAck:   0,
+
+		// Relay control
+		MsgTypeRelayAck:    4 * 1024,
+		MsgTypeRelayError:  8 + MaxRelayErrorDetailLength,
+		MsgTypeRelayMoved:  4 * 1024,
+		MsgTypeRouteUpdate: 2 + routeEntrySize*MaxRouteEntries,
+
+		// Key distribution: a bundle and its one-time prekeys
+		MsgTypeKeyBundlePublish:  64 * 1024,
+		MsgTypeKeyBundleRequest:  4 * 1024,
+		MsgTypeKeyBundleResponse: 64 * 1024,
+
+		// Media directory: endpoint URLs and a size per content type
+		MsgTypeMediaDirectoryRequest: 4 * 1024,
+		MsgTypeMediaDirectory:        8 * 1024,
+
+		// Acknowledgments
+		MsgTypeAck:  16 * 1024,
+		MsgTypeNack: 16 * 1024,
+	},
+}
+
+// DefaultPayloadLimits returns the limits a node advertises unless configured otherwise
+func DefaultPayloadLimits() *PayloadLimits {
+	return defaultPayloadLimits.clone()
+}
+
+// clone returns a deep copy
+func (l *PayloadLimits) clone() *PayloadLimits {
+	c := &PayloadLimits{Default: l.Default, PerType: make(map[uint16]uint32, len(l.PerType))}
+	for t, max := range l.PerType {
+		c.PerType[t] = max
+	}
+	return c
+}
+
+// Limit returns the largest payload accepted for a message type
+func (l *PayloadLimits) Limit(msgType uint16) uint32 {
+	if l == nil {
+		l = &defaultPayloadLimits
+	}
+	if max, ok := l.PerType[msgType]; ok {
+		return max
+	}
+	return l.Default
+}
+
+// Check returns ErrPayloadTooLarge if the header's payload length exceeds the limit
+func (l *PayloadLimits) Check(h *Header) error {
+	if max := l.Limit(h.Type); h.Length > max {
+		return fmt.Errorf("%w: type 0x%04x announces %d bytes, limit is %d", ErrPayloadTooLarge, h.Type, h.Length, max)
+	}
+	return nil
+}
+
+// NegotiatePayloadLimits combines our limits with those a peer advertised
+// Each type gets the smaller of the two, so neither side sends what the other
+// would refuse. Peers that advertise nothing leave our limits unchanged.
+func NegotiatePayloadLimits(local, remote *PayloadLimits) *PayloadLimits {
+	if local == nil {
+		local = &defaultPayloadLimits
+	}
+	if remote == nil {
+		return local.clone()
+	}
+
+	negotiated := &PayloadLimits{
+		Default: min(local.Default, remote.Default),
+		PerType: make(map[uint16]uint32),
+	}
+	for t := range local.PerType {
+		negotiated.PerType[t] = min(local.Limit(t), remote.Limit(t))
+	}
+	for t := range remote.PerType {
+		negotiated.PerType[t] = min(local.Limit(t), remote.Limit(t))
+	}
+	return negotiated
+}
+
+// Encode encodes the limits to bytes
+// Format: [Default 4][Count 1][Type 2, Max 4]... sorted by type
+func (l *PayloadLimits) Encode() []byte {
+	types := make([]uint16, 0, len(l.PerType))
+	for t := range l.PerType {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	if len(types) > MaxPayloadLimitEntries {
+		types = types[:MaxPayloadLimitEntries]
+	}
+
+	buf := make([]byte, 5+6*len(types))
+	binary.BigEndian.PutUint32(buf[0:4], l.Default)
+	buf[4] = uint8(len(types))
+
+	offset := 5
+	for _, t := range types {
+		binary.BigEndian.PutUint16(buf[offset:], t)
+		binary.BigEndian.PutUint32(buf[offset+2:], l.PerType[t])
+		offset += 6
+	}
+
+	return buf
+}
+
+// Decode decodes the limits from bytes and returns how many bytes were consumed
+func (l *PayloadLimits) Decode(buf []byte) (int, error) {
+	if len(buf) < 5 {
+		return 0, fmt.Errorf("%w for payload limits", ErrShortBuffer)
+	}
+
+	l.Default = binary.BigEndian.Uint32(buf[0:4])
+	count := int(buf[4])
+
+	size := 5 + 6*count
+	if len(buf) < size {
+		return 0, fmt.Errorf("%w for %d payload limit entries", ErrShortBuffer, count)
+	}
+
+	l.PerType = make(map[uint16]uint32, count)
+	for offset := 5; offset < size; offset += 6 {
+		l.PerType[binary.BigEndian.Uint16(buf[offset:])] = binary.BigEndian.Uint32(buf[offset+2:])
+	}
+
+	return size, nil
+}
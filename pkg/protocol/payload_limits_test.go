@@ -0,0 +1,178 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestPayloadLimitsCheckBounds(t *testing.T) {
+	limits := &PayloadLimits{
+		Default: 1000,
+		PerType: map[uint16]uint32{MsgTypePing: 0, MsgTypeHandshake: 200},
+	}
+
+	tests := []struct {
+		name    string
+		msgType uint16
+		length  uint32
+		wantErr bool
+	}{
+		{"Default at limit", MsgTypeRelayForward, 1000, false},
+		{"Default over limit", MsgTypeRelayForward, 1001, true},
+		{"Per-type at limit", MsgTypeHandshake, 200, false},
+		{"Per-type over limit", MsgTypeHandshake, 201, true},
+		{"Zero limit empty", MsgTypePing, 0, false},
+		{"Zero limit one byte", MsgTypePing, 1, true},
+		{"Claims 4GB", MsgTypeDirectMessage, 0xFFFFFFFF, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := limits.Check(&Header{Type: tt.msgType, Length: tt.length})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrPayloadTooLarge) {
+				t.Errorf("Check() error = %v, want ErrPayloadTooLarge", err)
+			}
+		})
+	}
+}
+
+func TestPayloadLimitsDefaults(t *testing.T) {
+	var nilLimits *PayloadLimits
+
+	if got := nilLimits.Limit(MsgTypeRelayForward); got != MaxRelayPayloadSize {
+		t.Errorf("nil Limit(RelayForward) = %d, want %d", got, MaxRelayPayloadSize)
+	}
+	if got, want := nilLimits.Limit(MsgTypeHandshake), DefaultPayloadLimits().Limit(MsgTypeHandshake); got != want {
+		t.Errorf("nil Limit(Handshake) = %d, want %d", got, want)
+	}
+	if err := nilLimits.Check(&Header{Type: MsgTypeRelayError, Length: 8 + MaxRelayErrorDetailLength}); err != nil {
+		t.Errorf("largest relay error refused by defaults: %v", err)
+	}
+
+	// Callers may modify the defaults without affecting other connections
+	limits := DefaultPayloadLimits()
+	limits.PerType[MsgTypeHandshake] = 1
+	if DefaultPayloadLimits().Limit(MsgTypeHandshake) == 1 {
+		t.Error("DefaultPayloadLimits() shares its map between calls")
+	}
+}
+
+func TestNegotiatePayloadLimits(t *testing.T) {
+	local := &PayloadLimits{
+		Default: 1 << 20,
+		PerType: map[uint16]uint32{MsgTypeHandshake: 4096, MsgTypePing: 64},
+	}
+	remote := &PayloadLimits{
+		Default: 1 << 16,
+		PerType: map[uint16]uint32{MsgTypeHandshake: 8192, MsgTypeAck: 1 << 24},
+	}
+
+	negotiated := NegotiatePayloadLimits(local, remote)
+
+	tests := []struct {
+		msgType uint16
+		want    uint32
+	}{
+		{MsgTypeRelayForward, 1 << 16}, // Remote default is smaller
+		{MsgTypeHandshake, 4096},       // Local entry is smaller
+		{MsgTypePing, 64},              // Only local has an entry
+		{MsgTypeAck, 1 << 20},          // Remote entry is looser than our default
+	}
+	for _, tt := range tests {
+		if got := negotiated.Limit(tt.msgType); got != tt.want {
+			t.Errorf("Limit(0x%04x) = %d, want %d", tt.msgType, got, tt.want)
+		}
+	}
+
+	// Symmetric, so both ends of a connection enforce the same limits
+	reverse := NegotiatePayloadLimits(remote, local)
+	for _, tt := range tests {
+		if got := reverse.Limit(tt.msgType); got != tt.want {
+			t.Errorf("reverse Limit(0x%04x) = %d, want %d", tt.msgType, got, tt.want)
+		}
+	}
+
+	// Peers that advertise nothing keep our limits
+	if got := NegotiatePayloadLimits(local, nil).Limit(MsgTypeHandshake); got != 4096 {
+		t.Errorf("Negotiate(local, nil) Limit(Handshake) = %d, want 4096", got)
+	}
+}
+
+func TestPayloadLimitsEncodeDecode(t *testing.T) {
+	limits := DefaultPayloadLimits()
+	encoded := limits.Encode()
+
+	// 4 + 1 + 6 per entry
+	if want := 5 + 6*len(limits.PerType); len(encoded) != want {
+		t.Errorf("Encode() length = %d, want %d", len(encoded), want)
+	}
+	if !bytes.Equal(encoded, limits.Encode()) {
+		t.Error("Encode() is not deterministic")
+	}
+
+	var decoded PayloadLimits
+	n, err := decoded.Decode(append(encoded, 0xAA))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if n != len(encoded) {
+		t.Errorf("Decode() consumed %d bytes, want %d", n, len(encoded))
+	}
+	if decoded.Default != limits.Default || len(decoded.PerType) != len(limits.PerType) {
+		t.Fatalf("Decoded limits = %+v, want %+v", decoded, limits)
+	}
+	for msgType, max := range limits.PerType {
+		if decoded.PerType[msgType] != max {
+			t.Errorf("Decoded limit for 0x%04x = %d, want %d", msgType, decoded.PerType[msgType], max)
+		}
+	}
+
+	if _, err := decoded.Decode(encoded[:4]); err == nil {
+		t.Error("Decode(too short) expected error, got nil")
+	}
+	if _, err := decoded.Decode(encoded[:len(encoded)-1]); err == nil {
+		t.Error("Decode(truncated entry) expected error, got nil")
+	}
+}
+
+func TestHandshakePayloadLimits(t *testing.T) {
+	hs := &HandshakeMessage{
+		ProtocolVersion: ProtocolVersion,
+		Address:         Address{1, 2, 3},
+		PublicKey:       []byte("-----BEGIN PUBLIC KEY-----"),
+		ClientType:      ClientTypeUser,
+		Timestamp:       1700000000,
+		Limits:          &PayloadLimits{Default: 4096, PerType: map[uint16]uint32{MsgTypePing: 0}},
+	}
+
+	var decoded HandshakeMessage
+	if err := decoded.Decode(hs.Encode()); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if decoded.Limits == nil {
+		t.Fatal("Decode() dropped payload limits")
+	}
+	if decoded.Limits.Limit(MsgTypePing) != 0 || decoded.Limits.Limit(MsgTypeAck) != 4096 {
+		t.Errorf("Decoded limits = %+v, want %+v", decoded.Limits, hs.Limits)
+	}
+
+	// Handshakes from peers predating limits end after the signature
+	hs.Limits = nil
+	if err := decoded.Decode(hs.Encode()); err != nil {
+		t.Fatalf("Decode(no limits) error = %v", err)
+	}
+	if decoded.Limits != nil {
+		t.Errorf("Decode(no limits) Limits = %+v, want nil", decoded.Limits)
+	}
+
+	// A truncated trailer is an error, not silently ignored
+	hs.Limits = DefaultPayloadLimits()
+	encoded := hs.Encode()
+	if err := decoded.Decode(encoded[:len(encoded)-2]); err == nil {
+		t.Error("Decode(truncated limits) expected error, got nil")
+	}
+}
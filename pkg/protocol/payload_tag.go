@@ -0,0 +1,35 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// PayloadTagMagic starts an end-to-end payload tagged with its message type
+// Neither a ratchet header length nor a hybrid key length can begin with
+// these bytes, so tagged payloads are never mistaken for either.
+var PayloadTagMagic = [4]byte{'Z', 'T', 'P', 'L'}
+
+// PayloadTagSize is the bytes a tag adds in front of an encoded message
+const PayloadTagSize = 4 + 2
+
+// TagPayload prefixes an encoded message with its type (MsgTypeDirectMessage, MsgTypeGroupMessage, ...)
+// Senders tag the plaintext before encrypting it, so the recipient dispatches
+// on the type instead of trying each decoder in turn.
+// Format: ["ZTPL"][Type 2][Encoded message]
+func TagPayload(msgType uint16, encoded []byte) []byte {
+	buf := make([]byte, PayloadTagSize+len(encoded))
+	copy(buf, PayloadTagMagic[:])
+	binary.BigEndian.PutUint16(buf[4:6], msgType)
+	copy(buf[PayloadTagSize:], encoded)
+	return buf
+}
+
+// UntagPayload splits a tagged payload into its message type and encoded message
+// ok is false for payloads from clients that predate tagging.
+func UntagPayload(payload []byte) (msgType uint16, encoded []byte, ok bool) {
+	if len(payload) < PayloadTagSize || !bytes.Equal(payload[:4], PayloadTagMagic[:]) {
+		return 0, nil, false
+	}
+	return binary.BigEndian.Uint16(payload[4:6]), payload[PayloadTagSize:], true
+}
@@ -0,0 +1,33 @@
+package protocol
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPayloadTagRoundTrip(t *testing.T) {
+	receipt := &ReadReceipt{From: Address{1}, To: Address{2}, ReadStatus: ReadStatusRead}
+	encoded := receipt.Encode()
+
+	msgType, body, ok := UntagPayload(TagPayload(MsgTypeReadReceipt, encoded))
+	if !ok {
+		t.Fatal("UntagPayload() did not recognize a tagged payload")
+	}
+	if msgType != MsgTypeReadReceipt {
+		t.Errorf("type = %#x, want %#x", msgType, MsgTypeReadReceipt)
+	}
+	if !bytes.Equal(body, encoded) {
+		t.Error("body differs from the encoded message")
+	}
+}
+
+func TestUntagPayloadLegacy(t *testing.T) {
+	// A bare encoding from an older client, whose From address happens to be short
+	msg := &DirectMessage{From: Address{'Z', 'T', 'P'}, To: Address{2}, Content: []byte("hi")}
+
+	for _, payload := range [][]byte{msg.Encode(), nil, []byte("ZTPL")} {
+		if _, _, ok := UntagPayload(payload); ok {
+			t.Errorf("UntagPayload(%x) recognized an untagged payload", payload)
+		}
+	}
+}
@@ -9,10 +9,10 @@ import (
 
 // TypingIndicator represents typing status
 type TypingIndicator struct {
-	From      Address // Sender
-	To        Address // Recipient (or group ID)
-	Timestamp uint64  // Timestamp
-	IsTyping  bool    // Currently typing
+	From      Address `cbor:"1,keyasint,omitempty"` // Sender
+	To        Address `cbor:"2,keyasint,omitempty"` // Recipient (or group ID)
+	Timestamp uint64  `cbor:"3,keyasint,omitempty"` // Timestamp
+	IsTyping  bool    `cbor:"4,keyasint,omitempty"` // Currently typing
 }
 
 // Encode encodes typing indicator to bytes
@@ -45,7 +45,7 @@ func (t *TypingIndicator) Encode() []byte {
 // Decode decodes typing indicator from bytes
 func (t *TypingIndicator) Decode(buf []byte) error {
 	if len(buf) < 50 {
-		return fmt.Errorf("buffer too short for typing indicator")
+		return fmt.Errorf("%w for typing indicator", ErrShortBuffer)
 	}
 
 	offset := 0
@@ -72,12 +72,70 @@ func (t *TypingIndicator) Decode(buf []byte) error {
 
 // ===== PRESENCE =====
 
+// Presence statuses
+const (
+	PresenceOffline uint8 = 0
+	PresenceOnline  uint8 = 1
+	PresenceAway    uint8 = 2
+	PresenceBusy    uint8 = 3
+)
+
 // PresenceUpdate represents online/offline status
 type PresenceUpdate struct {
-	Address   Address // User address
-	Status    uint8   // 0=offline, 1=online, 2=away, 3=busy
-	LastSeen  uint64  // Last seen timestamp
-	Timestamp uint64  // Update timestamp
+	Address   Address `cbor:"1,keyasint,omitempty"` // User address
+	Status    uint8   `cbor:"2,keyasint,omitempty"` // 0=offline, 1=online, 2=away, 3=busy
+	LastSeen  uint64  `cbor:"3,keyasint,omitempty"` // Last seen timestamp
+	Timestamp uint64  `cbor:"4,keyasint,omitempty"` // Update timestamp
+}
+
+// Encode encodes presence update to bytes
+// Format: [Type 1][Address 20][Status 1][LastSeen 8][Timestamp 8]
+func (p *PresenceUpdate) Encode() []byte {
+	buf := make([]byte, 1+20+1+8+8)
+	offset := 0
+
+	// Message type identifier
+	buf[offset] = 0x03 // Type: Presence Update
+	offset++
+
+	copy(buf[offset:], p.Address[:])
+	offset += 20
+
+	buf[offset] = p.Status
+	offset++
+
+	binary.BigEndian.PutUint64(buf[offset:], p.LastSeen)
+	offset += 8
+
+	binary.BigEndian.PutUint64(buf[offset:], p.Timestamp)
+
+	return buf
 }
 
-// TODO: Add Encode/Decode methods when implementing presence updates
+// Decode decodes presence update from bytes
+func (p *PresenceUpdate) Decode(buf []byte) error {
+	if len(buf) < 38 {
+		return fmt.Errorf("%w for presence update", ErrShortBuffer)
+	}
+
+	offset := 0
+
+	// Check message type
+	if buf[offset] != 0x03 {
+		return fmt.Errorf("invalid message type for presence update")
+	}
+	offset++
+
+	copy(p.Address[:], buf[offset:offset+20])
+	offset += 20
+
+	p.Status = buf[offset]
+	offset++
+
+	p.LastSeen = binary.BigEndian.Uint64(buf[offset:])
+	offset += 8
+
+	p.Timestamp = binary.BigEndian.Uint64(buf[offset:])
+
+	return nil
+}
@@ -4,21 +4,49 @@ import "encoding/binary"
 
 // ===== PROFILE UPDATE =====
 
+// Profile flags announce client modes that affect the people talking to this user
+const (
+	ProfileFlagAuditLog uint16 = 0x0001 // User consented to a signed audit log of message events (no content)
+	ProfileFlagBot      uint16 = 0x0002 // Account is a bot (bridge or integration), not a person
+)
+
 // ProfileUpdate represents a profile update
 type ProfileUpdate struct {
-	Address        Address   // User address
-	Username       [32]byte  // Username (UTF-8, max 32 bytes)
-	AvatarChunkID  uint64    // MeshStorage chunk ID for encrypted avatar
-	AvatarKey      [32]byte  // AES-256 key to decrypt avatar from MeshStorage
-	Bio            [256]byte // Bio text
-	PublicKey      []byte    // RSA public key
-	Timestamp      uint64    // Update timestamp
-	Signature      []byte    // Signature
+	Address       Address   `cbor:"1,keyasint,omitempty"` // User address
+	Username      [32]byte  `cbor:"2,keyasint,omitempty"` // Username (UTF-8, max 32 bytes)
+	AvatarChunkID uint64    `cbor:"3,keyasint,omitempty"` // MeshStorage chunk ID for encrypted avatar
+	AvatarKey     [32]byte  `cbor:"4,keyasint,omitempty"` // AES-256 key to decrypt avatar from MeshStorage
+	Bio           [256]byte `cbor:"5,keyasint,omitempty"` // Bio text
+	PublicKey     []byte    `cbor:"6,keyasint,omitempty"` // RSA public key
+	Timestamp     uint64    `cbor:"7,keyasint,omitempty"` // Update timestamp
+	Signature     []byte    `cbor:"8,keyasint,omitempty"` // Signature
+
+	// Optional: profile flags, appended after the signature when non-zero so
+	// older decoders still read the fixed fields
+	Flags uint16 `cbor:"9,keyasint,omitempty"`
+}
+
+// AuditLogEnabled returns true if the user announced audit mode
+func (m *ProfileUpdate) AuditLogEnabled() bool {
+	return m.Flags&ProfileFlagAuditLog != 0
+}
+
+// IsBot returns true if the profile belongs to a bot account
+func (m *ProfileUpdate) IsBot() bool {
+	return m.Flags&ProfileFlagBot != 0
+}
+
+// flagsSize returns the encoded size of the optional flags
+func (m *ProfileUpdate) flagsSize() int {
+	if m.Flags == 0 {
+		return 0
+	}
+	return 2
 }
 
 // EncodeForSigning encodes profile update without signature (for signing)
 func (m *ProfileUpdate) EncodeForSigning() []byte {
-	size := 20 + 32 + 8 + 32 + 256 + 4 + len(m.PublicKey) + 8
+	size := 20 + 32 + 8 + 32 + 256 + 4 + len(m.PublicKey) + 8 + m.flagsSize()
 	buf := make([]byte, size)
 	offset := 0
 
@@ -44,13 +72,19 @@ func (m *ProfileUpdate) EncodeForSigning() []byte {
 	offset += len(m.PublicKey)
 
 	binary.BigEndian.PutUint64(buf[offset:], m.Timestamp)
+	offset += 8
+
+	// Flags are signed: peers rely on them to know whether audit mode is on
+	if m.Flags != 0 {
+		binary.BigEndian.PutUint16(buf[offset:], m.Flags)
+	}
 
 	return buf
 }
 
 // Encode encodes profile update to bytes
 func (m *ProfileUpdate) Encode() []byte {
-	size := 20 + 32 + 8 + 32 + 256 + 4 + len(m.PublicKey) + 8 + 4 + len(m.Signature)
+	size := 20 + 32 + 8 + 32 + 256 + 4 + len(m.PublicKey) + 8 + 4 + len(m.Signature) + m.flagsSize()
 	buf := make([]byte, size)
 	offset := 0
 
@@ -82,6 +116,11 @@ func (m *ProfileUpdate) Encode() []byte {
 	offset += 4
 
 	copy(buf[offset:], m.Signature)
+	offset += len(m.Signature)
+
+	if m.Flags != 0 {
+		binary.BigEndian.PutUint16(buf[offset:], m.Flags)
+	}
 
 	return buf
 }
@@ -120,6 +159,12 @@ func (m *ProfileUpdate) Decode(buf []byte) error {
 
 	m.Signature = make([]byte, sigLen)
 	copy(m.Signature, buf[offset:offset+int(sigLen)])
+	offset += int(sigLen)
+
+	m.Flags = 0
+	if len(buf) >= offset+2 {
+		m.Flags = binary.BigEndian.Uint16(buf[offset:])
+	}
 
 	return nil
 }
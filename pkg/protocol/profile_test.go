@@ -0,0 +1,43 @@
+package protocol
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestProfileUpdateFlags(t *testing.T) {
+	profile := &ProfileUpdate{
+		Address:   Address{1},
+		PublicKey: []byte("pem"),
+		Timestamp: 1700000000000,
+		Signature: []byte("sig"),
+	}
+	plain := profile.Encode()
+	plainSigning := profile.EncodeForSigning()
+
+	profile.Flags = ProfileFlagAuditLog
+	flagged := profile.Encode()
+
+	// Older decoders read the fixed fields and ignore the trailing flags
+	if !bytes.Equal(flagged[:len(plain)], plain) || len(flagged) != len(plain)+2 {
+		t.Fatalf("flags changed the fixed layout")
+	}
+	if bytes.Equal(profile.EncodeForSigning(), plainSigning) {
+		t.Error("flags are not covered by the signature")
+	}
+
+	var got ProfileUpdate
+	if err := got.Decode(flagged); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !got.AuditLogEnabled() || got.IsBot() || !bytes.Equal(got.Signature, profile.Signature) {
+		t.Errorf("Decode() = %+v", got)
+	}
+
+	if err := got.Decode(plain); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if got.Flags != 0 {
+		t.Errorf("Flags = %#x for a profile without flags", got.Flags)
+	}
+}
@@ -0,0 +1,77 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// ===== QUEUE FLOW CONTROL =====
+// A user who sets FlagQueueFlow on its handshake or resume receives its
+// offline queue in windows. The relay sends up to the granted window of
+// queued messages, then a QueueProgress; the client answers with a
+// QueueWindow saying how far it got and how many more it will take. A flush
+// cut short by a dropped connection leaves the rest queued for the next one.
+
+// Queue flush windows, in messages
+const (
+	DefaultQueueWindow = 32  // Window a relay starts with and clients grant by default
+	MaxQueueWindow     = 256 // Larger grants are clamped
+)
+
+// QueueProgress reports how far a relay has got flushing a user's offline queue
+type QueueProgress struct {
+	Delivered uint32 `cbor:"1,keyasint,omitempty"` // Queued messages sent in this flush so far
+	Remaining uint32 `cbor:"2,keyasint,omitempty"` // Still queued; 0 ends the flush
+	Cursor    uint64 `cbor:"3,keyasint,omitempty"` // Queue sequence of the last message sent (see QueuedMessageID)
+}
+
+// Encode encodes the progress report to bytes
+// Format: [Delivered 4][Remaining 4][Cursor 8]
+func (p *QueueProgress) Encode() []byte {
+	buf := make([]byte, 16)
+	binary.BigEndian.PutUint32(buf[0:4], p.Delivered)
+	binary.BigEndian.PutUint32(buf[4:8], p.Remaining)
+	binary.BigEndian.PutUint64(buf[8:16], p.Cursor)
+	return buf
+}
+
+// Decode decodes the progress report from bytes
+func (p *QueueProgress) Decode(buf []byte) error {
+	if len(buf) < 16 {
+		return fmt.Errorf("%w for queue progress", ErrShortBuffer)
+	}
+	p.Delivered = binary.BigEndian.Uint32(buf[0:4])
+	p.Remaining = binary.BigEndian.Uint32(buf[4:8])
+	p.Cursor = binary.BigEndian.Uint64(buf[8:16])
+	return nil
+}
+
+// QueueWindow grants a relay more of the client's offline queue
+type QueueWindow struct {
+	Cursor uint64 `cbor:"1,keyasint,omitempty"` // Queue sequence of the last queued message received
+	Window uint32 `cbor:"2,keyasint,omitempty"` // Messages the relay may have in flight past Cursor
+}
+
+// Encode encodes the grant to bytes
+// Format: [Cursor 8][Window 4]
+func (w *QueueWindow) Encode() []byte {
+	buf := make([]byte, 12)
+	binary.BigEndian.PutUint64(buf[0:8], w.Cursor)
+	binary.BigEndian.PutUint32(buf[8:12], w.Window)
+	return buf
+}
+
+// Decode decodes the grant from bytes
+func (w *QueueWindow) Decode(buf []byte) error {
+	if len(buf) < 12 {
+		return fmt.Errorf("%w for queue window", ErrShortBuffer)
+	}
+	w.Cursor = binary.BigEndian.Uint64(buf[0:8])
+	w.Window = binary.BigEndian.Uint32(buf[8:12])
+	return nil
+}
+
+// Clamped returns the window limited to 1..MaxQueueWindow
+func (w *QueueWindow) Clamped() int {
+	return int(min(max(w.Window, 1), MaxQueueWindow))
+}
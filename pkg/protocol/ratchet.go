@@ -4,7 +4,11 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/binary"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"strconv"
+	"strings"
 
 	"golang.org/x/crypto/curve25519"
 	"golang.org/x/crypto/hkdf"
@@ -25,6 +29,9 @@ const (
 	// KDF info strings for HKDF
 	KDFRootInfo  = "ZenTalk Double Ratchet Root"
 	KDFChainInfo = "ZenTalk Double Ratchet Chain"
+
+	// MaxSkip limits how many message keys a single message may skip ahead (DoS protection)
+	MaxSkip = 1000
 )
 
 // RootKey represents the root key in the ratchet
@@ -69,6 +76,12 @@ type RatchetState struct {
 	// Identity (for debugging)
 	LocalAddress  Address // Our address
 	RemoteAddress Address // Their address
+
+	// AEAD for message keys, agreed during X3DH (zero = AES-256-GCM)
+	CipherSuite CipherSuite
+
+	// Source of new DH keys and nonces; nil means crypto/rand (see SetRandom)
+	random io.Reader
 }
 
 // MessageKeyID uniquely identifies a message key for out-of-order delivery
@@ -77,6 +90,31 @@ type MessageKeyID struct {
 	MessageNum  uint32      // The message number
 }
 
+// MarshalText encodes the ID as "<hex DH public key>:<message number>"
+// JSON only accepts text map keys, so this is what lets SkippedMessageKeys be persisted
+func (id MessageKeyID) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%s:%d", hex.EncodeToString(id.DHPublicKey[:]), id.MessageNum)), nil
+}
+
+// UnmarshalText decodes an ID produced by MarshalText
+func (id *MessageKeyID) UnmarshalText(text []byte) error {
+	keyHex, numStr, ok := strings.Cut(string(text), ":")
+	if !ok {
+		return fmt.Errorf("invalid message key ID %q", text)
+	}
+	key, err := hex.DecodeString(keyHex)
+	if err != nil || len(key) != DHKeyLen {
+		return fmt.Errorf("invalid DH public key in message key ID %q", text)
+	}
+	num, err := strconv.ParseUint(numStr, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid message number in message key ID %q", text)
+	}
+	copy(id.DHPublicKey[:], key)
+	id.MessageNum = uint32(num)
+	return nil
+}
+
 // MessageHeader is sent with each encrypted message
 type MessageHeader struct {
 	DHPublicKey      DHPublicKey // Current DH public key
@@ -96,7 +134,7 @@ func (h *MessageHeader) Encode() []byte {
 // DecodeMessageHeader decodes a message header from bytes
 func (h *MessageHeader) Decode(buf []byte) error {
 	if len(buf) < 40 {
-		return fmt.Errorf("buffer too short for message header")
+		return fmt.Errorf("%w for message header", ErrShortBuffer)
 	}
 
 	copy(h.DHPublicKey[:], buf[0:32])
@@ -226,15 +264,61 @@ func NewRatchetStateReceiver(
 	return state
 }
 
+// NewRatchetStateResponder initializes the receiver's ratchet state for an X3DH initial message
+// The initiator ratchets from our signed prekey to its ephemeral key (see NewRatchetState),
+// so we start from the signed prekey and derive the matching receiving chain.
+// The initiator's cipher suite must be one of accepted (nil = any registered).
+func NewRatchetStateResponder(
+	sharedSecret []byte,
+	signedPreKey *SignedPreKeyPrivate,
+	initialMsg *InitialMessage,
+	localAddr Address,
+	accepted []CipherSuite,
+) (*RatchetState, error) {
+	if err := AcceptCipherSuite(initialMsg.CipherSuite, accepted); err != nil {
+		return nil, err
+	}
+
+	state := NewRatchetStateReceiver(
+		sharedSecret,
+		signedPreKey.PrivateKey,
+		signedPreKey.PublicKey,
+		localAddr,
+		initialMsg.SenderAddress,
+	)
+	state.DHReceivingPublic = initialMsg.EphemeralKey
+	state.CipherSuite = initialMsg.CipherSuite
+
+	dhOutput, err := DH(state.DHSendingPrivate, state.DHReceivingPublic)
+	if err != nil {
+		return nil, fmt.Errorf("initial DH failed: %w", err)
+	}
+
+	newRootKey, receivingChainKey, err := KDF_RK(state.RootKey, dhOutput)
+	if err != nil {
+		return nil, fmt.Errorf("initial KDF failed: %w", err)
+	}
+
+	state.RootKey = newRootKey
+	state.ReceivingChainKey = receivingChainKey
+
+	return state, nil
+}
+
 // ===== DIFFIE-HELLMAN OPERATIONS =====
 
 // GenerateDHKeyPair generates a new X25519 key pair
 func GenerateDHKeyPair() (DHPrivateKey, DHPublicKey, error) {
+	return GenerateDHKeyPairFrom(rand.Reader)
+}
+
+// GenerateDHKeyPairFrom generates an X25519 key pair from the bytes of r
+func GenerateDHKeyPairFrom(r io.Reader) (DHPrivateKey, DHPublicKey, error) {
 	var private DHPrivateKey
 	var public DHPublicKey
 
 	// Generate random private key
-	if _, err := rand.Read(private[:]); err != nil {
+	if _, err := io.ReadFull(r, private[:]); err != nil {
 		return private, public, err
 	}
 
@@ -283,7 +367,7 @@ func (s *RatchetState) DHRatchet(remoteDHPublic DHPublicKey) error {
 	s.ReceivingChainKey = newReceivingChainKey
 
 	// Generate new DH key pair for sending
-	newPrivate, newPublic, err := GenerateDHKeyPair()
+	newPrivate, newPublic, err := GenerateDHKeyPairFrom(s.randomReader())
 	if err != nil {
 		return err
 	}
@@ -336,6 +420,8 @@ func (s *RatchetState) RatchetEncrypt(plaintext []byte, aesEncrypt func([]byte,
 }
 
 // RatchetDecrypt decrypts a ciphertext message
+// The state only changes if decryption succeeds: a forged, replayed or corrupted
+// message returns an error and leaves the session able to read later messages.
 // Returns: (plaintext, error)
 func (s *RatchetState) RatchetDecrypt(headerBytes []byte, ciphertext []byte, aesDecrypt func([]byte, []byte) ([]byte, error)) ([]byte, error) {
 	// Decode header
@@ -344,58 +430,92 @@ func (s *RatchetState) RatchetDecrypt(headerBytes []byte, ciphertext []byte, aes
 		return nil, err
 	}
 
+	// Late and out-of-order messages use a key stored when they were skipped.
+	// Checked first so a message from an earlier chain does not trigger a DH ratchet.
+	keyID := MessageKeyID{
+		DHPublicKey: header.DHPublicKey,
+		MessageNum:  header.MessageNum,
+	}
+	if messageKey, ok := s.SkippedMessageKeys[keyID]; ok {
+		plaintext, err := aesDecrypt(ciphertext, messageKey[:])
+		if err != nil {
+			return nil, err
+		}
+		// Each key decrypts exactly one message
+		delete(s.SkippedMessageKeys, keyID)
+		return plaintext, nil
+	}
+
+	// Advance a copy, committed only once the message decrypts
+	next := s.clone()
+
 	// Check if we need to perform a DH ratchet step
 	// (if the DH public key in the header is different from our receiving key)
-	if header.DHPublicKey != s.DHReceivingPublic {
+	if header.DHPublicKey != next.DHReceivingPublic {
 		// Skip message keys from the current receiving chain
-		if err := s.SkipMessageKeys(s.DHReceivingPublic, s.ReceivingMsgNum, header.PreviousChainLen); err != nil {
+		if err := next.SkipMessageKeys(next.DHReceivingPublic, next.ReceivingMsgNum, header.PreviousChainLen); err != nil {
 			return nil, err
 		}
 
 		// Perform DH ratchet
-		if err := s.DHRatchet(header.DHPublicKey); err != nil {
+		if err := next.DHRatchet(header.DHPublicKey); err != nil {
 			return nil, err
 		}
+	} else if header.MessageNum < next.ReceivingMsgNum {
+		// Its key was already used (or skipped and then consumed): a replay
+		return nil, fmt.Errorf("message %d already received", header.MessageNum)
 	}
 
 	// Skip message keys if needed (for out-of-order messages)
-	if header.MessageNum > s.ReceivingMsgNum {
-		if err := s.SkipMessageKeys(header.DHPublicKey, s.ReceivingMsgNum, header.MessageNum); err != nil {
-			return nil, err
-		}
+	if err := next.SkipMessageKeys(header.DHPublicKey, next.ReceivingMsgNum, header.MessageNum); err != nil {
+		return nil, err
 	}
 
-	// Try to use a skipped message key first
-	keyID := MessageKeyID{
-		DHPublicKey: header.DHPublicKey,
-		MessageNum:  header.MessageNum,
-	}
+	// Derive message key from receiving chain
+	newChainKey, messageKey := KDF_CK(next.ReceivingChainKey)
+	next.ReceivingChainKey = newChainKey
+	next.ReceivingMsgNum++
 
-	if messageKey, ok := s.SkippedMessageKeys[keyID]; ok {
-		// Use skipped key
-		delete(s.SkippedMessageKeys, keyID)
-		return aesDecrypt(ciphertext, messageKey[:])
+	// Decrypt ciphertext with message key
+	plaintext, err := aesDecrypt(ciphertext, messageKey[:])
+	if err != nil {
+		return nil, err
 	}
 
-	// Derive message key from receiving chain
-	newChainKey, messageKey := KDF_CK(s.ReceivingChainKey)
-	s.ReceivingChainKey = newChainKey
-	s.ReceivingMsgNum++
+	*s = *next
+	return plaintext, nil
+}
 
-	// Decrypt ciphertext with message key
-	return aesDecrypt(ciphertext, messageKey[:])
+// Seal encrypts plaintext with the session's cipher suite (see RatchetEncrypt)
+func (s *RatchetState) Seal(plaintext []byte) ([]byte, []byte, error) {
+	random := s.randomReader()
+	return s.RatchetEncrypt(plaintext, func(plaintext, key []byte) ([]byte, error) {
+		return s.CipherSuite.EncryptFrom(random, plaintext, key)
+	})
+}
+
+// Open decrypts a message with the session's cipher suite (see RatchetDecrypt)
+func (s *RatchetState) Open(headerBytes []byte, ciphertext []byte) ([]byte, error) {
+	return s.RatchetDecrypt(headerBytes, ciphertext, s.CipherSuite.Decrypt)
 }
 
 // SkipMessageKeys stores message keys for skipped messages
 // This handles out-of-order message delivery
 func (s *RatchetState) SkipMessageKeys(dhPublicKey DHPublicKey, fromMsgNum uint32, toMsgNum uint32) error {
-	// Limit the number of skipped message keys to prevent DoS
-	const MaxSkip = 1000
+	if toMsgNum <= fromMsgNum {
+		return nil
+	}
 
+	// Limit the number of skipped message keys to prevent DoS
 	if toMsgNum-fromMsgNum > MaxSkip {
 		return fmt.Errorf("skipping too many message keys (%d)", toMsgNum-fromMsgNum)
 	}
 
+	// No receiving chain yet, so there are no keys to skip
+	if s.ReceivingChainKey == (ChainKey{}) {
+		return nil
+	}
+
 	// Derive and store message keys for all skipped messages
 	chainKey := s.ReceivingChainKey
 	for i := fromMsgNum; i < toMsgNum; i++ {
@@ -409,8 +529,34 @@ func (s *RatchetState) SkipMessageKeys(dhPublicKey DHPublicKey, fromMsgNum uint3
 		s.SkippedMessageKeys[keyID] = messageKey
 	}
 
-	// Update receiving chain key
+	// Update receiving chain key and position
 	s.ReceivingChainKey = chainKey
+	s.ReceivingMsgNum = toMsgNum
 
 	return nil
 }
+
+// SetRandom makes the session draw its DH ratchet keys and Seal nonces from r
+// Tests use it to replay a session byte for byte; nil restores crypto/rand.
+// The reader is not persisted, so a reloaded session is random again.
+func (s *RatchetState) SetRandom(r io.Reader) {
+	s.random = r
+}
+
+// randomReader returns the session's source of randomness
+func (s *RatchetState) randomReader() io.Reader {
+	if s.random != nil {
+		return s.random
+	}
+	return rand.Reader
+}
+
+// clone returns a deep copy of the state
+func (s *RatchetState) clone() *RatchetState {
+	c := *s
+	c.SkippedMessageKeys = make(map[MessageKeyID]MessageKey, len(s.SkippedMessageKeys))
+	for id, key := range s.SkippedMessageKeys {
+		c.SkippedMessageKeys[id] = key
+	}
+	return &c
+}
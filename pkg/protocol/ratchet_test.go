@@ -0,0 +1,387 @@
+package protocol
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"pgregory.net/rapid"
+)
+
+// gcmEncrypt is AES-256-GCM with the nonce prepended, as the network layer uses
+func gcmEncrypt(plaintext, key []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func gcmDecrypt(ciphertext, key []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, sealed, nil)
+}
+
+// newRatchetPair sets up Alice (initiator) and Bob (receiver) sharing a secret
+func newRatchetPair(t interface{ Fatal(...any) }) (alice, bob *RatchetState) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		t.Fatal(err)
+	}
+	bobPriv, bobPub, err := GenerateDHKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	alicePriv, alicePub, err := GenerateDHKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var aliceAddr, bobAddr Address
+	aliceAddr[0], bobAddr[0] = 0xA1, 0xB0
+
+	alice, err = NewRatchetState(secret, bobPub, alicePriv, alicePub, aliceAddr, bobAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob = NewRatchetStateReceiver(secret, bobPriv, bobPub, bobAddr, aliceAddr)
+	return alice, bob
+}
+
+// sentMessage is a ratchet message on the wire
+type sentMessage struct {
+	header     []byte
+	ciphertext []byte
+	plaintext  []byte
+	key        string // Message key used to encrypt it
+}
+
+// party is one side of a conversation driven by the property tests
+type party struct {
+	name     string
+	state    *RatchetState
+	inbox    []sentMessage // Sent to this party, not yet delivered
+	received []sentMessage // Delivered to this party
+}
+
+// conversation drives two parties through random sends, deliveries and restarts
+type conversation struct {
+	alice, bob *party
+	sent       int
+	usedKeys   map[string]bool // Every message key used to encrypt
+}
+
+func newConversation(t *rapid.T) *conversation {
+	alice, bob := newRatchetPair(t)
+	return &conversation{
+		alice:    &party{name: "alice", state: alice},
+		bob:      &party{name: "bob", state: bob},
+		usedKeys: make(map[string]bool),
+	}
+}
+
+// peer returns the other party
+func (c *conversation) peer(p *party) *party {
+	if p == c.alice {
+		return c.bob
+	}
+	return c.alice
+}
+
+// canSend reports whether p has a sending chain
+// Bob's first sending chain is created by the DH ratchet on Alice's first message.
+func (c *conversation) canSend(p *party) bool {
+	return p == c.alice || len(p.received) > 0
+}
+
+// send encrypts a message from p and queues it for the peer, checking its key is fresh
+func (c *conversation) send(t *rapid.T, p *party) {
+	plaintext := []byte(fmt.Sprintf("%s message %d", p.name, c.sent))
+	c.sent++
+
+	var key []byte
+	header, ciphertext, err := p.state.RatchetEncrypt(plaintext, func(pt, k []byte) ([]byte, error) {
+		key = append([]byte(nil), k...)
+		return gcmEncrypt(pt, k)
+	})
+	if err != nil {
+		t.Fatalf("%s encrypt: %v", p.name, err)
+	}
+
+	if c.usedKeys[string(key)] {
+		t.Fatalf("%s reused a message key for %q", p.name, plaintext)
+	}
+	c.usedKeys[string(key)] = true
+
+	to := c.peer(p)
+	to.inbox = append(to.inbox, sentMessage{header, ciphertext, plaintext, string(key)})
+}
+
+// deliver hands p the i-th message in its inbox, which must decrypt to what was sent
+func (c *conversation) deliver(t *rapid.T, p *party, i int) {
+	msg := p.inbox[i]
+	p.inbox = append(p.inbox[:i], p.inbox[i+1:]...)
+
+	var key []byte
+	plaintext, err := p.state.RatchetDecrypt(msg.header, msg.ciphertext, func(ct, k []byte) ([]byte, error) {
+		key = append([]byte(nil), k...)
+		return gcmDecrypt(ct, k)
+	})
+	if err != nil {
+		t.Fatalf("%s decrypt %q: %v", p.name, msg.plaintext, err)
+	}
+	if !bytes.Equal(plaintext, msg.plaintext) {
+		t.Fatalf("%s decrypted %q, want %q", p.name, plaintext, msg.plaintext)
+	}
+	if string(key) != msg.key {
+		t.Fatalf("%s decrypted %q with a different key than it was sent with", p.name, msg.plaintext)
+	}
+	p.received = append(p.received, msg)
+}
+
+// replay hands p a message it already decrypted, which must be rejected
+func (c *conversation) replay(t *rapid.T, p *party, i int) {
+	msg := p.received[i]
+	before, err := json.Marshal(p.state)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.state.RatchetDecrypt(msg.header, msg.ciphertext, gcmDecrypt); err == nil {
+		t.Fatalf("%s accepted replay of %q", p.name, msg.plaintext)
+	}
+
+	after, err := json.Marshal(p.state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Fatalf("%s state changed by rejected replay of %q", p.name, msg.plaintext)
+	}
+}
+
+// restart round-trips p's state through JSON, as session storage does
+func (c *conversation) restart(t *rapid.T, p *party) {
+	data, err := json.Marshal(p.state)
+	if err != nil {
+		t.Fatalf("marshal %s state: %v", p.name, err)
+	}
+	var restored RatchetState
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Fatalf("unmarshal %s state: %v", p.name, err)
+	}
+	if !reflect.DeepEqual(p.state, &restored) {
+		t.Fatalf("%s state did not round-trip through JSON", p.name)
+	}
+	p.state = &restored
+}
+
+// drawParty picks Alice or Bob
+func (c *conversation) drawParty(t *rapid.T, label string) *party {
+	if rapid.Bool().Draw(t, label) {
+		return c.alice
+	}
+	return c.bob
+}
+
+// actions returns the state machine run by the conversation properties
+// Conversations stay well below MaxSkip messages, so every message must decrypt.
+func (c *conversation) actions(replays, restarts bool) map[string]func(*rapid.T) {
+	actions := map[string]func(*rapid.T){
+		"send": func(t *rapid.T) {
+			p := c.drawParty(t, "sender")
+			if !c.canSend(p) {
+				t.Skip("no sending chain yet")
+			}
+			if c.sent >= MaxSkip/4 {
+				t.Skip("conversation long enough")
+			}
+			n := rapid.IntRange(1, 5).Draw(t, "count")
+			for i := 0; i < n; i++ {
+				c.send(t, p)
+			}
+		},
+		"deliver": func(t *rapid.T) {
+			p := c.drawParty(t, "recipient")
+			if len(p.inbox) == 0 {
+				t.Skip("nothing in flight")
+			}
+			c.deliver(t, p, rapid.IntRange(0, len(p.inbox)-1).Draw(t, "message"))
+		},
+	}
+	if replays {
+		actions["replay"] = func(t *rapid.T) {
+			p := c.drawParty(t, "recipient")
+			if len(p.received) == 0 {
+				t.Skip("nothing delivered")
+			}
+			c.replay(t, p, rapid.IntRange(0, len(p.received)-1).Draw(t, "message"))
+		}
+	}
+	if restarts {
+		actions["restart"] = func(t *rapid.T) {
+			c.restart(t, c.drawParty(t, "party"))
+		}
+	}
+	return actions
+}
+
+// flush delivers everything still in flight in a random order
+func (c *conversation) flush(t *rapid.T) {
+	for len(c.alice.inbox)+len(c.bob.inbox) > 0 {
+		p := c.drawParty(t, "flush recipient")
+		if len(p.inbox) == 0 {
+			p = c.peer(p)
+		}
+		c.deliver(t, p, rapid.IntRange(0, len(p.inbox)-1).Draw(t, "flush message"))
+	}
+}
+
+func TestRatchetRoundTrip(t *testing.T) {
+	alice, bob := newRatchetPair(t)
+
+	exchange := func(from, to *RatchetState, text string) {
+		t.Helper()
+		header, ciphertext, err := from.RatchetEncrypt([]byte(text), gcmEncrypt)
+		if err != nil {
+			t.Fatalf("encrypt %q: %v", text, err)
+		}
+		plaintext, err := to.RatchetDecrypt(header, ciphertext, gcmDecrypt)
+		if err != nil {
+			t.Fatalf("decrypt %q: %v", text, err)
+		}
+		if string(plaintext) != text {
+			t.Fatalf("decrypted %q, want %q", plaintext, text)
+		}
+	}
+
+	exchange(alice, bob, "hello bob")
+	exchange(alice, bob, "are you there?")
+	exchange(bob, alice, "hi alice")
+	exchange(alice, bob, "great")
+	exchange(bob, alice, "bye")
+}
+
+func TestRatchetRejectsTampering(t *testing.T) {
+	alice, bob := newRatchetPair(t)
+
+	header, ciphertext, err := alice.RatchetEncrypt([]byte("first"), gcmEncrypt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := append([]byte(nil), ciphertext...)
+	tampered[len(tampered)-1] ^= 0x01
+	if _, err := bob.RatchetDecrypt(header, tampered, gcmDecrypt); err == nil {
+		t.Fatal("tampered ciphertext decrypted")
+	}
+
+	// The failed attempt must not have advanced Bob's state
+	plaintext, err := bob.RatchetDecrypt(header, ciphertext, gcmDecrypt)
+	if err != nil {
+		t.Fatalf("decrypt after rejected tampering: %v", err)
+	}
+	if string(plaintext) != "first" {
+		t.Fatalf("decrypted %q, want %q", plaintext, "first")
+	}
+}
+
+func TestRatchetMaxSkip(t *testing.T) {
+	alice, bob := newRatchetPair(t)
+
+	var header, ciphertext []byte
+	var err error
+	for i := 0; i <= MaxSkip+1; i++ {
+		header, ciphertext, err = alice.RatchetEncrypt([]byte("spam"), gcmEncrypt)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := bob.RatchetDecrypt(header, ciphertext, gcmDecrypt); err == nil {
+		t.Fatalf("message %d decrypted despite skipping more than %d keys", MaxSkip+1, MaxSkip)
+	}
+}
+
+func TestMessageKeyIDText(t *testing.T) {
+	var id MessageKeyID
+	id.DHPublicKey[0], id.DHPublicKey[31] = 0xAB, 0xCD
+	id.MessageNum = 42
+
+	text, err := id.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded MessageKeyID
+	if err := decoded.UnmarshalText(text); err != nil {
+		t.Fatalf("UnmarshalText(%q): %v", text, err)
+	}
+	if decoded != id {
+		t.Errorf("round trip = %+v, want %+v", decoded, id)
+	}
+
+	for _, bad := range []string{"", "abcd", "abcd:1", string(text[:64]) + ":x", string(text[:64]) + ":4294967296"} {
+		if err := decoded.UnmarshalText([]byte(bad)); err == nil {
+			t.Errorf("UnmarshalText(%q) succeeded", bad)
+		}
+	}
+}
+
+// Any interleaving of sends and out-of-order deliveries decrypts every message
+func TestRatchetPropertyOutOfOrder(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		c := newConversation(t)
+		t.Repeat(c.actions(false, false))
+		c.flush(t)
+	})
+}
+
+// No message key encrypts two messages, and a delivered message cannot be replayed
+func TestRatchetPropertyNoKeyReuse(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		c := newConversation(t)
+		t.Repeat(c.actions(true, false))
+		c.flush(t)
+
+		for _, p := range []*party{c.alice, c.bob} {
+			for i := range p.received {
+				c.replay(t, p, i)
+			}
+		}
+	})
+}
+
+// State survives a JSON round trip at any point, including with skipped keys pending
+func TestRatchetPropertySerialization(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		c := newConversation(t)
+		t.Repeat(c.actions(true, true))
+		c.restart(t, c.alice)
+		c.restart(t, c.bob)
+		c.flush(t)
+	})
+}
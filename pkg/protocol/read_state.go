@@ -0,0 +1,131 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	// MaxReadStateEntries limits how many conversations one sync message carries
+	MaxReadStateEntries = 256
+
+	// readStateSyncHeaderSize is the encoded size of a ReadStateSync before its entries
+	readStateSyncHeaderSize = 2
+
+	// readStateEntrySize is the encoded size of one ReadState
+	readStateEntrySize = 20 + 8 + 8 + 1
+)
+
+// ReadState is how far a user has read a conversation, and whether it is muted
+// Devices merge states instead of replacing them, so syncs arriving out of
+// order or twice settle on the same result.
+type ReadState struct {
+	Conversation Address `cbor:"1,keyasint,omitempty"` // The other party
+	LastRead     uint64  `cbor:"2,keyasint,omitempty"` // Unix timestamp (ms) of the newest message read
+	MutedAt      uint64  `cbor:"3,keyasint,omitempty"` // Unix timestamp (ms) of the last mute change, 0 if never changed
+	Muted        bool    `cbor:"4,keyasint,omitempty"`
+}
+
+// Merge folds another device's state for the same conversation into s
+// LastRead only moves forward and the later mute change wins. Returns true if s changed.
+func (s *ReadState) Merge(other *ReadState) bool {
+	changed := false
+	if other.LastRead > s.LastRead {
+		s.LastRead = other.LastRead
+		changed = true
+	}
+	if other.MutedAt > s.MutedAt {
+		changed = changed || other.Muted != s.Muted
+		s.MutedAt = other.MutedAt
+		s.Muted = other.Muted
+	}
+	return changed
+}
+
+// ReadStateSync is the content of a ContentTypeReadStateSync message
+// A device sends it to the user's other devices after reading or (un)muting a
+// conversation, so unread badges and mutes match everywhere.
+type ReadStateSync struct {
+	States []ReadState `cbor:"1,keyasint,omitempty"`
+}
+
+// Encode encodes the sync to bytes (at most MaxReadStateEntries states)
+// Format: [Count 2] then per state [Conversation 20][LastRead 8][MutedAt 8][Muted 1]
+func (s *ReadStateSync) Encode() []byte {
+	states := s.States
+	if len(states) > MaxReadStateEntries {
+		states = states[:MaxReadStateEntries]
+	}
+
+	buf := make([]byte, readStateSyncHeaderSize+len(states)*readStateEntrySize)
+	offset := 0
+
+	binary.BigEndian.PutUint16(buf[offset:], uint16(len(states)))
+	offset += 2
+
+	for _, state := range states {
+		copy(buf[offset:], state.Conversation[:])
+		offset += 20
+
+		binary.BigEndian.PutUint64(buf[offset:], state.LastRead)
+		offset += 8
+
+		binary.BigEndian.PutUint64(buf[offset:], state.MutedAt)
+		offset += 8
+
+		if state.Muted {
+			buf[offset] = 1
+		}
+		offset++
+	}
+
+	return buf
+}
+
+// Decode decodes the sync from bytes
+func (s *ReadStateSync) Decode(buf []byte) error {
+	if len(buf) < readStateSyncHeaderSize {
+		return fmt.Errorf("%w for read state sync", ErrShortBuffer)
+	}
+
+	count := int(binary.BigEndian.Uint16(buf))
+	if count == 0 {
+		return fmt.Errorf("read state sync has no conversations")
+	}
+	if count > MaxReadStateEntries {
+		return fmt.Errorf("read state sync has too many conversations: %d", count)
+	}
+	if len(buf) != readStateSyncHeaderSize+count*readStateEntrySize {
+		return fmt.Errorf("read state sync length mismatch")
+	}
+
+	offset := readStateSyncHeaderSize
+	s.States = make([]ReadState, count)
+	for i := range s.States {
+		copy(s.States[i].Conversation[:], buf[offset:offset+20])
+		offset += 20
+
+		s.States[i].LastRead = binary.BigEndian.Uint64(buf[offset:])
+		offset += 8
+
+		s.States[i].MutedAt = binary.BigEndian.Uint64(buf[offset:])
+		offset += 8
+
+		switch buf[offset] {
+		case 0:
+		case 1:
+			s.States[i].Muted = true
+		default:
+			return fmt.Errorf("invalid mute flag %d", buf[offset])
+		}
+		offset++
+	}
+
+	return nil
+}
+
+// validateReadStateSync is the content validator for ContentTypeReadStateSync
+func validateReadStateSync(content []byte) error {
+	var s ReadStateSync
+	return s.Decode(content)
+}
@@ -0,0 +1,52 @@
+package protocol
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReadStateSyncEncodeDecode(t *testing.T) {
+	sync := &ReadStateSync{States: []ReadState{
+		{Conversation: Address{1}, LastRead: 1700000000000},
+		{Conversation: Address{2}, LastRead: 1700000005000, MutedAt: 1700000001000, Muted: true},
+	}}
+
+	var decoded ReadStateSync
+	if err := decoded.Decode(sync.Encode()); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !reflect.DeepEqual(decoded, *sync) {
+		t.Errorf("Decode() = %+v, want %+v", decoded, sync)
+	}
+
+	if err := ValidateContent(ContentTypeReadStateSync, sync.Encode()[:40]); err == nil {
+		t.Error("ValidateContent(truncated) expected error, got nil")
+	}
+	if err := ValidateContent(ContentTypeReadStateSync, (&ReadStateSync{}).Encode()); err == nil {
+		t.Error("ValidateContent(empty) expected error, got nil")
+	}
+}
+
+func TestReadStateMerge(t *testing.T) {
+	state := ReadState{LastRead: 200, MutedAt: 100, Muted: true}
+
+	// An older read and an older mute change are both ignored
+	if state.Merge(&ReadState{LastRead: 150, MutedAt: 50}) {
+		t.Error("Merge(older) reported a change")
+	}
+	if state.LastRead != 200 || !state.Muted {
+		t.Errorf("Merge(older) = %+v, want it unchanged", state)
+	}
+
+	// A later unmute wins even without a newer read
+	if !state.Merge(&ReadState{LastRead: 100, MutedAt: 300}) {
+		t.Error("Merge(unmute) reported no change")
+	}
+	if state.Muted || state.MutedAt != 300 || state.LastRead != 200 {
+		t.Errorf("Merge(unmute) = %+v, want unmuted at 300, read to 200", state)
+	}
+
+	if !state.Merge(&ReadState{LastRead: 400}) || state.LastRead != 400 {
+		t.Errorf("Merge(newer read) = %+v, want read to 400", state)
+	}
+}
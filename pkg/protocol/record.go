@@ -0,0 +1,109 @@
+package protocol
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrInvalidRecord is returned for a record with an unknown size class or data length
+var ErrInvalidRecord = errors.New("invalid uniform record")
+
+// RecordSizes are the only record sizes on a uniform-records link, smallest first
+// Each frame is padded up to the smallest record that fits it (or split into
+// several of the largest), so observers see sizes from this set rather than
+// message-type-specific frame lengths.
+var RecordSizes = [...]int{512, 2048, 8192, 32768}
+
+// RecordOverhead is the per-record header: [Class 1][DataLen 2]
+const RecordOverhead = 3
+
+// RecordClass returns the index in RecordSizes of the smallest record holding n data bytes
+// Data larger than the largest record returns the largest class.
+func RecordClass(n int) int {
+	for i, size := range RecordSizes {
+		if n <= size-RecordOverhead {
+			return i
+		}
+	}
+	return len(RecordSizes) - 1
+}
+
+// EncodeRecords splits data into uniform records padded with random bytes
+// Format per record: [Class 1][DataLen 2][Data][Padding] = RecordSizes[Class] bytes
+func EncodeRecords(data []byte) ([]byte, error) {
+	var out []byte
+	for {
+		class := RecordClass(len(data))
+		size := RecordSizes[class]
+		n := min(len(data), size-RecordOverhead)
+
+		record := make([]byte, size)
+		record[0] = uint8(class)
+		binary.BigEndian.PutUint16(record[1:3], uint16(n))
+		copy(record[RecordOverhead:], data[:n])
+		if _, err := rand.Read(record[RecordOverhead+n:]); err != nil {
+			return nil, fmt.Errorf("failed to pad record: %w", err)
+		}
+
+		out = append(out, record...)
+		data = data[n:]
+		if len(data) == 0 {
+			return out, nil
+		}
+	}
+}
+
+// RecordReader reads the data carried by a stream of uniform records
+type RecordReader struct {
+	r      io.Reader
+	record []byte
+	data   []byte // Unread data of the current record
+}
+
+// NewRecordReader creates a reader that strips record framing and padding from r
+func NewRecordReader(r io.Reader) *RecordReader {
+	return &RecordReader{r: r, record: make([]byte, RecordSizes[len(RecordSizes)-1])}
+}
+
+// Read reads data, consuming whole records from the underlying reader as needed
+func (rr *RecordReader) Read(p []byte) (int, error) {
+	for len(rr.data) == 0 {
+		if err := rr.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, rr.data)
+	rr.data = rr.data[n:]
+	return n, nil
+}
+
+// next reads one record and exposes its data
+func (rr *RecordReader) next() error {
+	if _, err := io.ReadFull(rr.r, rr.record[:RecordOverhead]); err != nil {
+		return err
+	}
+
+	class := int(rr.record[0])
+	if class >= len(RecordSizes) {
+		return fmt.Errorf("%w: size class %d", ErrInvalidRecord, class)
+	}
+	size := RecordSizes[class]
+	n := int(binary.BigEndian.Uint16(rr.record[1:3]))
+	if n > size-RecordOverhead {
+		return fmt.Errorf("%w: %d data bytes in a %d-byte record", ErrInvalidRecord, n, size)
+	}
+
+	if _, err := io.ReadFull(rr.r, rr.record[RecordOverhead:size]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+
+	rr.data = rr.record[RecordOverhead : RecordOverhead+n]
+	return nil
+}
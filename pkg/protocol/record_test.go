@@ -0,0 +1,126 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestRecordClass(t *testing.T) {
+	tests := []struct {
+		n    int
+		want int
+	}{
+		{0, 0},
+		{512 - RecordOverhead, 0},
+		{512 - RecordOverhead + 1, 1},
+		{8192 - RecordOverhead, 2},
+		{32768 - RecordOverhead, 3},
+		{1 << 20, 3},
+	}
+
+	for _, tt := range tests {
+		if got := RecordClass(tt.n); got != tt.want {
+			t.Errorf("RecordClass(%d) = %d, want %d", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeRecordsSizes(t *testing.T) {
+	largest := RecordSizes[len(RecordSizes)-1]
+
+	tests := []struct {
+		name  string
+		n     int
+		sizes []int
+	}{
+		{"Header only", HeaderSize, []int{512}},
+		{"Small message", 600, []int{2048}},
+		{"Exactly one large record", largest - RecordOverhead, []int{largest}},
+		{"Split with small tail", largest - RecordOverhead + 100, []int{largest, 512}},
+		{"Split across three", 2*(largest-RecordOverhead) + 5000, []int{largest, largest, 8192}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := EncodeRecords(make([]byte, tt.n))
+			if err != nil {
+				t.Fatalf("EncodeRecords() error = %v", err)
+			}
+
+			// Walk the records by their class bytes
+			var sizes []int
+			for rest := encoded; len(rest) > 0; {
+				size := RecordSizes[rest[0]]
+				sizes = append(sizes, size)
+				rest = rest[size:]
+			}
+			if len(sizes) != len(tt.sizes) {
+				t.Fatalf("record sizes = %v, want %v", sizes, tt.sizes)
+			}
+			for i := range sizes {
+				if sizes[i] != tt.sizes[i] {
+					t.Errorf("record sizes = %v, want %v", sizes, tt.sizes)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestRecordReaderRoundTrip(t *testing.T) {
+	var stream bytes.Buffer
+	var want []byte
+
+	for i, n := range []int{HeaderSize, 0, 1, 509, 510, 40000, 100000} {
+		data := bytes.Repeat([]byte{byte(i + 1)}, n)
+		want = append(want, data...)
+
+		encoded, err := EncodeRecords(data)
+		if err != nil {
+			t.Fatalf("EncodeRecords(%d bytes) error = %v", n, err)
+		}
+		stream.Write(encoded)
+	}
+
+	got, err := io.ReadAll(NewRecordReader(&stream))
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("read data differs from what was written (%d bytes, want %d)", len(got), len(want))
+	}
+}
+
+func TestRecordReaderInvalid(t *testing.T) {
+	valid, err := EncodeRecords([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	badClass := append([]byte(nil), valid...)
+	badClass[0] = uint8(len(RecordSizes))
+
+	badLength := append([]byte(nil), valid...)
+	badLength[1], badLength[2] = 0xFF, 0xFF
+
+	tests := []struct {
+		name string
+		buf  []byte
+		want error
+	}{
+		{"Unknown class", badClass, ErrInvalidRecord},
+		{"Data longer than record", badLength, ErrInvalidRecord},
+		{"Truncated record", valid[:100], io.ErrUnexpectedEOF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewRecordReader(bytes.NewReader(tt.buf)).Read(make([]byte, 16))
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Read() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	// MaxRecoveryShareSize caps one contact's share of the recovery key
+	MaxRecoveryShareSize = 64
+
+	// recoveryShareHeaderSize is the encoded size of a RecoveryShare before the share bytes
+	recoveryShareHeaderSize = 20 + 16 + 1 + 1 + 8 + 8 + 1
+
+	// recoveryRequestSize is the encoded size of a RecoveryRequest
+	recoveryRequestSize = 20 + 20 + 8 + 32
+)
+
+// RecoverySetID identifies one split of a user's identity (16 bytes)
+// Splitting again for a new set of contacts gives a new ID, and holders drop older sets.
+type RecoverySetID [16]byte
+
+// RecoveryShare is the content of a ContentTypeRecoveryShare message
+// The owner's identity is stored encrypted in MeshStorage (ChunkID) and the
+// chunk's key is split so that any Threshold of the Total contacts can rebuild
+// it. Owners send shares to their contacts; contacts send them back to the
+// owner's new device.
+type RecoveryShare struct {
+	Owner     Address       `cbor:"1,keyasint,omitempty"` // Whose identity this recovers
+	SetID     RecoverySetID `cbor:"2,keyasint,omitempty"`
+	Threshold uint8         `cbor:"3,keyasint,omitempty"` // Shares needed to recover
+	Total     uint8         `cbor:"4,keyasint,omitempty"` // Shares in the set
+	CreatedAt uint64        `cbor:"5,keyasint,omitempty"` // Unix timestamp (ms) of the split
+	ChunkID   uint64        `cbor:"6,keyasint,omitempty"` // MeshStorage chunk holding the encrypted identity
+	Share     []byte        `cbor:"7,keyasint,omitempty"` // Share of the chunk key: index byte, then the share
+}
+
+// Validate checks the share is well-formed
+func (s *RecoveryShare) Validate() error {
+	if s.Threshold < 2 || s.Total < s.Threshold {
+		return fmt.Errorf("invalid recovery threshold %d of %d", s.Threshold, s.Total)
+	}
+	if len(s.Share) < 2 || len(s.Share) > MaxRecoveryShareSize {
+		return fmt.Errorf("recovery share is %d bytes (max %d)", len(s.Share), MaxRecoveryShareSize)
+	}
+	if s.Share[0] == 0 || s.Share[0] > s.Total {
+		return fmt.Errorf("recovery share index %d out of range 1-%d", s.Share[0], s.Total)
+	}
+	return nil
+}
+
+// Encode encodes the share to bytes
+// Format: [Owner 20][SetID 16][Threshold 1][Total 1][CreatedAt 8][ChunkID 8][ShareLen 1][Share]
+func (s *RecoveryShare) Encode() []byte {
+	share := s.Share
+	if len(share) > MaxRecoveryShareSize {
+		share = share[:MaxRecoveryShareSize]
+	}
+
+	buf := make([]byte, recoveryShareHeaderSize+len(share))
+	offset := 0
+
+	copy(buf[offset:], s.Owner[:])
+	offset += 20
+
+	copy(buf[offset:], s.SetID[:])
+	offset += 16
+
+	buf[offset] = s.Threshold
+	buf[offset+1] = s.Total
+	offset += 2
+
+	binary.BigEndian.PutUint64(buf[offset:], s.CreatedAt)
+	offset += 8
+
+	binary.BigEndian.PutUint64(buf[offset:], s.ChunkID)
+	offset += 8
+
+	buf[offset] = uint8(len(share))
+	offset++
+	copy(buf[offset:], share)
+
+	return buf
+}
+
+// Decode decodes the share from bytes
+func (s *RecoveryShare) Decode(buf []byte) error {
+	if len(buf) < recoveryShareHeaderSize {
+		return fmt.Errorf("%w for recovery share", ErrShortBuffer)
+	}
+
+	offset := 0
+
+	copy(s.Owner[:], buf[offset:offset+20])
+	offset += 20
+
+	copy(s.SetID[:], buf[offset:offset+16])
+	offset += 16
+
+	s.Threshold = buf[offset]
+	s.Total = buf[offset+1]
+	offset += 2
+
+	s.CreatedAt = binary.BigEndian.Uint64(buf[offset:])
+	offset += 8
+
+	s.ChunkID = binary.BigEndian.Uint64(buf[offset:])
+	offset += 8
+
+	shareLen := int(buf[offset])
+	offset++
+	if len(buf) != offset+shareLen {
+		return fmt.Errorf("recovery share length mismatch")
+	}
+	s.Share = append([]byte(nil), buf[offset:]...)
+
+	return s.Validate()
+}
+
+// RecoveryRequest is the content of a ContentTypeRecoveryRequest message
+// A user's new device asks the contacts holding shares to send them to it.
+// Contacts should confirm out of band that the request really comes from the
+// owner, and that KeyHash matches the key they are about to send the share to.
+type RecoveryRequest struct {
+	Owner      Address  `cbor:"1,keyasint,omitempty"` // Identity being recovered
+	NewAddress Address  `cbor:"2,keyasint,omitempty"` // Temporary address of the new device
+	Timestamp  uint64   `cbor:"3,keyasint,omitempty"` // Unix timestamp (ms)
+	KeyHash    [32]byte `cbor:"4,keyasint,omitempty"` // SHA-256 of the new device's public key (PEM)
+}
+
+// Encode encodes the request to bytes
+// Format: [Owner 20][NewAddress 20][Timestamp 8][KeyHash 32]
+func (r *RecoveryRequest) Encode() []byte {
+	buf := make([]byte, recoveryRequestSize)
+	offset := 0
+
+	copy(buf[offset:], r.Owner[:])
+	offset += 20
+
+	copy(buf[offset:], r.NewAddress[:])
+	offset += 20
+
+	binary.BigEndian.PutUint64(buf[offset:], r.Timestamp)
+	offset += 8
+
+	copy(buf[offset:], r.KeyHash[:])
+
+	return buf
+}
+
+// Decode decodes the request from bytes
+func (r *RecoveryRequest) Decode(buf []byte) error {
+	if len(buf) != recoveryRequestSize {
+		return fmt.Errorf("recovery request is %d bytes, want %d", len(buf), recoveryRequestSize)
+	}
+
+	offset := 0
+
+	copy(r.Owner[:], buf[offset:offset+20])
+	offset += 20
+
+	copy(r.NewAddress[:], buf[offset:offset+20])
+	offset += 20
+
+	r.Timestamp = binary.BigEndian.Uint64(buf[offset:])
+	offset += 8
+
+	copy(r.KeyHash[:], buf[offset:])
+
+	return nil
+}
+
+// validateRecoveryShare is the content validator for ContentTypeRecoveryShare
+func validateRecoveryShare(content []byte) error {
+	var s RecoveryShare
+	return s.Decode(content)
+}
+
+// validateRecoveryRequest is the content validator for ContentTypeRecoveryRequest
+func validateRecoveryRequest(content []byte) error {
+	var r RecoveryRequest
+	return r.Decode(content)
+}
@@ -0,0 +1,82 @@
+package protocol
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRecoveryShareEncodeDecode(t *testing.T) {
+	share := &RecoveryShare{
+		Owner:     Address{1, 2, 3},
+		SetID:     RecoverySetID{9},
+		Threshold: 2,
+		Total:     3,
+		CreatedAt: 1700000000000,
+		ChunkID:   42,
+		Share:     []byte{2, 0xAA, 0xBB},
+	}
+
+	var decoded RecoveryShare
+	if err := decoded.Decode(share.Encode()); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if decoded.Owner != share.Owner || decoded.SetID != share.SetID || decoded.Threshold != 2 || decoded.Total != 3 ||
+		decoded.CreatedAt != share.CreatedAt || decoded.ChunkID != 42 || !bytes.Equal(decoded.Share, share.Share) {
+		t.Errorf("Decode() = %+v, want %+v", decoded, share)
+	}
+
+	if err := ValidateContent(ContentTypeRecoveryShare, share.Encode()); err != nil {
+		t.Errorf("ValidateContent() error = %v", err)
+	}
+}
+
+func TestRecoveryShareDecodeInvalid(t *testing.T) {
+	valid := RecoveryShare{Threshold: 2, Total: 3, Share: []byte{1, 7}}
+
+	tests := []struct {
+		name  string
+		share RecoveryShare
+	}{
+		{"Threshold 1", RecoveryShare{Threshold: 1, Total: 3, Share: valid.Share}},
+		{"Threshold above total", RecoveryShare{Threshold: 4, Total: 3, Share: valid.Share}},
+		{"Index 0", RecoveryShare{Threshold: 2, Total: 3, Share: []byte{0, 7}}},
+		{"Index above total", RecoveryShare{Threshold: 2, Total: 3, Share: []byte{4, 7}}},
+		{"Empty share", RecoveryShare{Threshold: 2, Total: 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s RecoveryShare
+			if err := s.Decode(tt.share.Encode()); err == nil {
+				t.Error("Decode() expected error, got nil")
+			}
+		})
+	}
+
+	encoded := valid.Encode()
+	var s RecoveryShare
+	if err := s.Decode(encoded[:len(encoded)-1]); err == nil {
+		t.Error("Decode(truncated) expected error, got nil")
+	}
+}
+
+func TestRecoveryRequestEncodeDecode(t *testing.T) {
+	req := &RecoveryRequest{
+		Owner:      Address{1},
+		NewAddress: Address{2},
+		Timestamp:  1700000000000,
+		KeyHash:    [32]byte{7},
+	}
+
+	var decoded RecoveryRequest
+	if err := decoded.Decode(req.Encode()); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if decoded != *req {
+		t.Errorf("Decode() = %+v, want %+v", decoded, req)
+	}
+
+	if err := ValidateContent(ContentTypeRecoveryRequest, req.Encode()[:79]); err == nil {
+		t.Error("ValidateContent(truncated) expected error, got nil")
+	}
+}
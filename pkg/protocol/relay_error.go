@@ -0,0 +1,186 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// MaxRelayPayloadSize is the default payload limit for message types without a tighter one
+// Frames over the negotiated limit are refused with RelayErrPayloadTooLarge and the connection closed.
+const MaxRelayPayloadSize = 8 * 1024 * 1024
+
+// MaxRelayErrorDetailLength caps the human-readable detail in a RelayErrorMessage
+const MaxRelayErrorDetailLength = 1024
+
+// RelayErrorCode identifies why a relay refused or could not handle a message
+// Codes are grouped by the high byte: routing (0x01xx), capacity (0x02xx),
+// protocol (0x03xx) and relay-internal (0x04xx). Unknown codes must be treated
+// as permanent failures.
+type RelayErrorCode uint16
+
+// Relay error codes
+const (
+	RelayErrUnknown RelayErrorCode = 0x0000 // Unspecified (also sent by relays predating error codes)
+
+	// Routing (0x01xx)
+	RelayErrRecipientUnknown   RelayErrorCode = 0x0101 // Recipient not connected and no offline queue
+	RelayErrNextHopUnreachable RelayErrorCode = 0x0102 // Next relay in the route could not be reached
+	RelayErrExitPolicy         RelayErrorCode = 0x0103 // Relay's exit policy forbids this hop
+	RelayErrRoutingLoop        RelayErrorCode = 0x0104 // Route points back at this relay
+	RelayErrTenantDenied       RelayErrorCode = 0x0105 // Tenant unknown, user not on its allowlist, or recipient in another tenant
+
+	// Capacity (0x02xx)
+	RelayErrQueueFull       RelayErrorCode = 0x0201 // Recipient's offline queue cannot take more messages
+	RelayErrRateLimited     RelayErrorCode = 0x0202 // Too many messages; retry after RetryAfter
+	RelayErrPayloadTooLarge RelayErrorCode = 0x0203 // Payload exceeds the relay's limit
+
+	// Protocol (0x03xx)
+	RelayErrVersionUnsupported RelayErrorCode = 0x0301 // Header version not spoken by this relay
+	RelayErrMalformed          RelayErrorCode = 0x0302 // Payload or onion layer could not be decoded
+	RelayErrUnsupportedType    RelayErrorCode = 0x0303 // Message type not handled by relays
+	RelayErrTypeNotAllowed     RelayErrorCode = 0x0304 // Message type refused by the relay's policy
+
+	// Relay-internal (0x04xx)
+	RelayErrInternal RelayErrorCode = 0x0401 // Relay failed (e.g. storage error); not the sender's fault
+
+	// Access (0x05xx)
+	RelayErrBotUnauthorized RelayErrorCode = 0x0501 // Bot not registered, or its API key proof is invalid or stale
+	RelayErrTLSRequired     RelayErrorCode = 0x0502 // Relay only accepts users that switched to TLS
+)
+
+// relayErrorInfo describes one registered error code
+type relayErrorInfo struct {
+	name      string
+	retryable bool // The same message may succeed if sent again later
+}
+
+// relayErrors is the registry of known codes
+var relayErrors = map[RelayErrorCode]relayErrorInfo{
+	RelayErrUnknown:            {"unknown", false},
+	RelayErrRecipientUnknown:   {"recipient-unknown", true},
+	RelayErrNextHopUnreachable: {"next-hop-unreachable", true},
+	RelayErrExitPolicy:         {"exit-policy", false},
+	RelayErrRoutingLoop:        {"routing-loop", false},
+	RelayErrTenantDenied:       {"tenant-denied", false},
+	RelayErrQueueFull:          {"queue-full", true},
+	RelayErrRateLimited:        {"rate-limited", true},
+	RelayErrPayloadTooLarge:    {"payload-too-large", false},
+	RelayErrVersionUnsupported: {"version-unsupported", false},
+	RelayErrMalformed:          {"malformed", false},
+	RelayErrUnsupportedType:    {"unsupported-type", false},
+	RelayErrTypeNotAllowed:     {"type-not-allowed", false},
+	RelayErrInternal:           {"internal", true},
+	RelayErrBotUnauthorized:    {"bot-unauthorized", false},
+	RelayErrTLSRequired:        {"tls-required", false},
+}
+
+// String returns the code's registered name, or its hex value if unknown
+func (c RelayErrorCode) String() string {
+	if info, ok := relayErrors[c]; ok {
+		return info.name
+	}
+	return fmt.Sprintf("0x%04x", uint16(c))
+}
+
+// Known reports whether the code is in the registry
+func (c RelayErrorCode) Known() bool {
+	_, ok := relayErrors[c]
+	return ok
+}
+
+// Retryable reports whether resending the same message later may succeed
+// Permanent errors (and unknown codes) mean the message or route must change.
+func (c RelayErrorCode) Retryable() bool {
+	return relayErrors[c].retryable
+}
+
+// RelayErrorMessage is the payload of MsgTypeRelayError
+// The header's MessageID is that of the refused message. It implements error so
+// clients can hand it to callers and inspect it with errors.As.
+type RelayErrorMessage struct {
+	Code       RelayErrorCode `cbor:"1,keyasint,omitempty"`
+	RetryAfter time.Duration  `cbor:"2,keyasint,omitempty"` // Suggested wait before retrying (0 = no hint)
+	Detail     string         `cbor:"3,keyasint,omitempty"` // Human-readable explanation for logs; not for matching
+}
+
+// NewRelayError creates a relay error with a formatted detail
+func NewRelayError(code RelayErrorCode, format string, args ...interface{}) *RelayErrorMessage {
+	return &RelayErrorMessage{Code: code, Detail: fmt.Sprintf(format, args...)}
+}
+
+// Error formats the error as "relay error rate-limited (0x0202): detail"
+func (e *RelayErrorMessage) Error() string {
+	msg := fmt.Sprintf("relay error %s (0x%04x)", e.Code, uint16(e.Code))
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	return msg
+}
+
+// Unwrap maps the code to the matching sentinel, so callers can use errors.Is
+// Codes without a sentinel unwrap to nil; inspect Code for those.
+func (e *RelayErrorMessage) Unwrap() error {
+	switch e.Code {
+	case RelayErrQueueFull, RelayErrRateLimited:
+		return ErrQuotaExceeded
+	case RelayErrPayloadTooLarge:
+		return ErrPayloadTooLarge
+	case RelayErrVersionUnsupported:
+		return ErrInvalidVersion
+	case RelayErrTypeNotAllowed:
+		return ErrTypeNotAllowed
+	}
+	return nil
+}
+
+// Encode encodes the error to bytes
+// Format: [Code 2][RetryAfterMs 4][DetailLen 2][Detail]
+func (e *RelayErrorMessage) Encode() []byte {
+	detail := e.Detail
+	if len(detail) > MaxRelayErrorDetailLength {
+		detail = detail[:MaxRelayErrorDetailLength]
+	}
+
+	retryMs := e.RetryAfter.Milliseconds()
+	if retryMs < 0 {
+		retryMs = 0
+	}
+	if retryMs > 0xFFFFFFFF {
+		retryMs = 0xFFFFFFFF
+	}
+
+	buf := make([]byte, 8+len(detail))
+	binary.BigEndian.PutUint16(buf[0:2], uint16(e.Code))
+	binary.BigEndian.PutUint32(buf[2:6], uint32(retryMs))
+	binary.BigEndian.PutUint16(buf[6:8], uint16(len(detail)))
+	copy(buf[8:], detail)
+
+	return buf
+}
+
+// Decode decodes the error from bytes
+// An empty payload, as sent by relays predating error codes, decodes as RelayErrUnknown.
+func (e *RelayErrorMessage) Decode(buf []byte) error {
+	*e = RelayErrorMessage{}
+	if len(buf) == 0 {
+		return nil
+	}
+	if len(buf) < 8 {
+		return fmt.Errorf("%w for relay error", ErrShortBuffer)
+	}
+
+	e.Code = RelayErrorCode(binary.BigEndian.Uint16(buf[0:2]))
+	e.RetryAfter = time.Duration(binary.BigEndian.Uint32(buf[2:6])) * time.Millisecond
+
+	detailLen := int(binary.BigEndian.Uint16(buf[6:8]))
+	if detailLen > MaxRelayErrorDetailLength {
+		return fmt.Errorf("relay error detail too long: %d bytes", detailLen)
+	}
+	if len(buf) < 8+detailLen {
+		return fmt.Errorf("%w for relay error detail", ErrShortBuffer)
+	}
+	e.Detail = string(buf[8 : 8+detailLen])
+
+	return nil
+}
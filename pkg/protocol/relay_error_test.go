@@ -0,0 +1,164 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRelayErrorEncodeDecode(t *testing.T) {
+	relayErr := NewRelayError(RelayErrRateLimited, "%d messages in %s", 120, time.Minute)
+	relayErr.RetryAfter = 30 * time.Second
+
+	encoded := relayErr.Encode()
+
+	// 2 + 4 + 2 + detail
+	if want := 8 + len(relayErr.Detail); len(encoded) != want {
+		t.Errorf("Encode() length = %d, want %d", len(encoded), want)
+	}
+
+	decoded := &RelayErrorMessage{}
+	if err := decoded.Decode(encoded); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	if *decoded != *relayErr {
+		t.Errorf("Decoded error = %+v, want %+v", decoded, relayErr)
+	}
+}
+
+func TestRelayErrorDecodeEmpty(t *testing.T) {
+	relayErr := &RelayErrorMessage{Code: RelayErrQueueFull, Detail: "stale"}
+	if err := relayErr.Decode(nil); err != nil {
+		t.Fatalf("Decode(empty) error = %v", err)
+	}
+	if relayErr.Code != RelayErrUnknown || relayErr.Detail != "" {
+		t.Errorf("Decode(empty) = %+v, want zero value", relayErr)
+	}
+}
+
+func TestRelayErrorDecodeInvalid(t *testing.T) {
+	valid := NewRelayError(RelayErrMalformed, "bad onion layer").Encode()
+
+	oversized := make([]byte, 8)
+	binary.BigEndian.PutUint16(oversized[6:8], MaxRelayErrorDetailLength+1)
+
+	tests := []struct {
+		name string
+		buf  []byte
+	}{
+		{"Too short", valid[:5]},
+		{"Truncated detail", valid[:len(valid)-3]},
+		{"Oversized detail", oversized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var relayErr RelayErrorMessage
+			if err := relayErr.Decode(tt.buf); err == nil {
+				t.Error("Decode() expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestRelayErrorEncodeClamps(t *testing.T) {
+	relayErr := &RelayErrorMessage{
+		Code:       RelayErrInternal,
+		RetryAfter: 2000 * time.Hour,
+		Detail:     strings.Repeat("x", MaxRelayErrorDetailLength+100),
+	}
+
+	var decoded RelayErrorMessage
+	if err := decoded.Decode(relayErr.Encode()); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if len(decoded.Detail) != MaxRelayErrorDetailLength {
+		t.Errorf("Detail length = %d, want %d", len(decoded.Detail), MaxRelayErrorDetailLength)
+	}
+	if want := time.Duration(0xFFFFFFFF) * time.Millisecond; decoded.RetryAfter != want {
+		t.Errorf("RetryAfter = %s, want %s", decoded.RetryAfter, want)
+	}
+}
+
+func TestRelayErrorCode(t *testing.T) {
+	tests := []struct {
+		code      RelayErrorCode
+		name      string
+		known     bool
+		retryable bool
+	}{
+		{RelayErrRecipientUnknown, "recipient-unknown", true, true},
+		{RelayErrQueueFull, "queue-full", true, true},
+		{RelayErrRateLimited, "rate-limited", true, true},
+		{RelayErrPayloadTooLarge, "payload-too-large", true, false},
+		{RelayErrVersionUnsupported, "version-unsupported", true, false},
+		{RelayErrUnknown, "unknown", true, false},
+		{RelayErrorCode(0x7777), "0x7777", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.code.String(); got != tt.name {
+				t.Errorf("String() = %q, want %q", got, tt.name)
+			}
+			if got := tt.code.Known(); got != tt.known {
+				t.Errorf("Known() = %v, want %v", got, tt.known)
+			}
+			if got := tt.code.Retryable(); got != tt.retryable {
+				t.Errorf("Retryable() = %v, want %v", got, tt.retryable)
+			}
+		})
+	}
+}
+
+func TestRelayErrorAs(t *testing.T) {
+	err := fmt.Errorf("deliver: %w", NewRelayError(RelayErrRecipientUnknown, "no queue"))
+
+	var relayErr *RelayErrorMessage
+	if !errors.As(err, &relayErr) {
+		t.Fatal("errors.As() did not find RelayErrorMessage")
+	}
+	if relayErr.Code != RelayErrRecipientUnknown {
+		t.Errorf("Code = %s, want %s", relayErr.Code, RelayErrRecipientUnknown)
+	}
+	if want := "relay error recipient-unknown (0x0101): no queue"; relayErr.Error() != want {
+		t.Errorf("Error() = %q, want %q", relayErr.Error(), want)
+	}
+}
+
+func TestRelayErrorIs(t *testing.T) {
+	tests := []struct {
+		code RelayErrorCode
+		want error
+	}{
+		{RelayErrQueueFull, ErrQuotaExceeded},
+		{RelayErrRateLimited, ErrQuotaExceeded},
+		{RelayErrPayloadTooLarge, ErrPayloadTooLarge},
+		{RelayErrVersionUnsupported, ErrInvalidVersion},
+		{RelayErrTypeNotAllowed, ErrTypeNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code.String(), func(t *testing.T) {
+			err := fmt.Errorf("send: %w", NewRelayError(tt.code, "refused"))
+			if !errors.Is(err, tt.want) {
+				t.Errorf("errors.Is(%v, %v) = false", err, tt.want)
+			}
+		})
+	}
+
+	if err := NewRelayError(RelayErrRecipientUnknown, "no queue"); errors.Is(err, ErrQuotaExceeded) {
+		t.Error("recipient-unknown matched ErrQuotaExceeded")
+	}
+}
+
+func TestRelayErrorDecodeShortBuffer(t *testing.T) {
+	var relayErr RelayErrorMessage
+	if err := relayErr.Decode([]byte{0x01}); !errors.Is(err, ErrShortBuffer) {
+		t.Errorf("Decode() error = %v, want ErrShortBuffer", err)
+	}
+}
@@ -0,0 +1,81 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// MaxRelayEndpointLength caps the network address in a RelayMovedNotice
+const MaxRelayEndpointLength = 255
+
+// RelayMovedNotice tells a client that its relay has migrated to new hardware
+// Queued offline messages were transferred, so the client should reconnect to
+// the new endpoint to receive them
+type RelayMovedNotice struct {
+	NewRelay        Address `cbor:"1,keyasint,omitempty"` // Address of the replacement relay
+	NewEndpoint     string  `cbor:"2,keyasint,omitempty"` // Network address of the replacement relay (host:port)
+	PendingMessages uint32  `cbor:"3,keyasint,omitempty"` // Messages queued for this client at migration time
+	Timestamp       uint64  `cbor:"4,keyasint,omitempty"` // Unix timestamp (ms) of the migration
+}
+
+// Validate checks the endpoint is present and fits the encoding
+func (n *RelayMovedNotice) Validate() error {
+	if n.NewEndpoint == "" {
+		return fmt.Errorf("relay moved notice has no endpoint")
+	}
+	if len(n.NewEndpoint) > MaxRelayEndpointLength {
+		return fmt.Errorf("relay endpoint too long: %d bytes", len(n.NewEndpoint))
+	}
+	return nil
+}
+
+// Encode encodes the notice to bytes
+// Format: [NewRelay 20][Pending 4][Timestamp 8][EndpointLen 1][Endpoint]
+func (n *RelayMovedNotice) Encode() []byte {
+	buf := make([]byte, 20+4+8+1+len(n.NewEndpoint))
+	offset := 0
+
+	copy(buf[offset:], n.NewRelay[:])
+	offset += 20
+
+	binary.BigEndian.PutUint32(buf[offset:], n.PendingMessages)
+	offset += 4
+
+	binary.BigEndian.PutUint64(buf[offset:], n.Timestamp)
+	offset += 8
+
+	buf[offset] = uint8(len(n.NewEndpoint))
+	offset++
+
+	copy(buf[offset:], n.NewEndpoint)
+
+	return buf
+}
+
+// Decode decodes the notice from bytes
+func (n *RelayMovedNotice) Decode(buf []byte) error {
+	if len(buf) < 33 {
+		return fmt.Errorf("%w for relay moved notice", ErrShortBuffer)
+	}
+
+	offset := 0
+
+	copy(n.NewRelay[:], buf[offset:offset+20])
+	offset += 20
+
+	n.PendingMessages = binary.BigEndian.Uint32(buf[offset:])
+	offset += 4
+
+	n.Timestamp = binary.BigEndian.Uint64(buf[offset:])
+	offset += 8
+
+	endpointLen := int(buf[offset])
+	offset++
+
+	if len(buf) < offset+endpointLen {
+		return fmt.Errorf("%w for relay endpoint", ErrShortBuffer)
+	}
+	n.NewEndpoint = string(buf[offset : offset+endpointLen])
+
+	return n.Validate()
+}
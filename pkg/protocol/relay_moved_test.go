@@ -0,0 +1,60 @@
+package protocol
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRelayMovedNoticeEncodeDecode(t *testing.T) {
+	notice := &RelayMovedNotice{
+		NewRelay:        Address{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20},
+		NewEndpoint:     "relay2.example.com:9001",
+		PendingMessages: 17,
+		Timestamp:       uint64(NowUnixMilli()),
+	}
+
+	encoded := notice.Encode()
+
+	// 20 + 4 + 8 + 1 + endpoint
+	if want := 33 + len(notice.NewEndpoint); len(encoded) != want {
+		t.Errorf("Encode() length = %d, want %d", len(encoded), want)
+	}
+
+	decoded := &RelayMovedNotice{}
+	if err := decoded.Decode(encoded); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	if *decoded != *notice {
+		t.Errorf("Decoded notice = %+v, want %+v", decoded, notice)
+	}
+}
+
+func TestRelayMovedNoticeDecodeInvalid(t *testing.T) {
+	valid := (&RelayMovedNotice{NewEndpoint: "10.0.0.1:8080"}).Encode()
+
+	tests := []struct {
+		name string
+		buf  []byte
+	}{
+		{"Too short", make([]byte, 20)},
+		{"Truncated endpoint", valid[:len(valid)-3]},
+		{"Empty endpoint", (&RelayMovedNotice{}).Encode()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var notice RelayMovedNotice
+			if err := notice.Decode(tt.buf); err == nil {
+				t.Error("Decode() expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestRelayMovedNoticeValidate(t *testing.T) {
+	notice := &RelayMovedNotice{NewEndpoint: strings.Repeat("a", MaxRelayEndpointLength+1)}
+	if err := notice.Validate(); err == nil {
+		t.Error("Validate() expected error for oversized endpoint, got nil")
+	}
+}
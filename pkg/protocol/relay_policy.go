@@ -0,0 +1,228 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// ErrTypeNotAllowed is returned for a message type a relay's policy refuses
+var ErrTypeNotAllowed = errors.New("message type not allowed by relay policy")
+
+// MaxRelayPolicyEntries caps the allowed types and per-type ceilings in an encoded RelayPolicy
+const MaxRelayPolicyEntries = 255
+
+const (
+	// MaxPaddingBuckets caps the padding buckets a relay policy advertises
+	MaxPaddingBuckets = 16
+	// MinPaddingBucket is the smallest padding bucket, large enough for one onion layer's overhead
+	MinPaddingBucket = CellSize512
+	// MaxPaddingBucket is the largest padding bucket; bigger layers round up to a multiple of the largest
+	MaxPaddingBucket = 1 << 20
+)
+
+// RelayPolicy is what a relay accepts from users, beyond the payload limits
+// Relays only see frame types and sizes, so that is all a policy can name.
+// Unlike PayloadLimits, which bound framing and close the connection, a policy
+// refuses single messages with a RelayError. Relays advertise their policy in
+// the handshake ack so clients can check a message before sending it.
+type RelayPolicy struct {
+	AllowedTypes []uint16          `cbor:"1,keyasint,omitempty"` // Frame types users may send once connected (empty = all)
+	MaxPayload   map[uint16]uint32 `cbor:"2,keyasint,omitempty"` // Largest payload per frame type (absent = payload limit)
+	MaxDelivered uint32            `cbor:"3,keyasint,omitempty"` // Largest message delivered to a recipient (0 = no ceiling)
+	MaxQueued    uint32            `cbor:"4,keyasint,omitempty"` // Largest message queued for an offline recipient (0 = MaxDelivered)
+
+	// Sizes onion layers arriving at the relay are padded to, ascending (empty = unpadded)
+	// Every layer the relay sees is one of a few sizes, so an odd-sized message
+	// doesn't stand out among its traffic. See crypto.BuildOnionLayers.
+	PaddingBuckets []uint32 `cbor:"5,keyasint,omitempty"`
+}
+
+// Allows reports whether users may send a frame type
+// Handshakes and resumptions come before a user is known and are always allowed.
+// A nil policy allows everything.
+func (p *RelayPolicy) Allows(msgType uint16) bool {
+	if p == nil || len(p.AllowedTypes) == 0 {
+		return true
+	}
+	if msgType == MsgTypeHandshake || msgType == MsgTypeResume {
+		return true
+	}
+	return slices.Contains(p.AllowedTypes, msgType)
+}
+
+// CheckFrame checks a frame a user sends to the relay
+// Returns ErrTypeNotAllowed or ErrPayloadTooLarge.
+func (p *RelayPolicy) CheckFrame(h *Header) error {
+	if !p.Allows(h.Type) {
+		return fmt.Errorf("%w: type 0x%04x", ErrTypeNotAllowed, h.Type)
+	}
+	if p == nil {
+		return nil
+	}
+	if max, ok := p.MaxPayload[h.Type]; ok && h.Length > max {
+		return fmt.Errorf("%w: type 0x%04x announces %d bytes, relay policy allows %d", ErrPayloadTooLarge, h.Type, h.Length, max)
+	}
+	return nil
+}
+
+// DeliveryCeiling returns the largest message the relay delivers (0 = none)
+// queued selects the ceiling for recipients who are offline.
+func (p *RelayPolicy) DeliveryCeiling(queued bool) uint32 {
+	if p == nil {
+		return 0
+	}
+	if queued && p.MaxQueued > 0 && (p.MaxDelivered == 0 || p.MaxQueued < p.MaxDelivered) {
+		return p.MaxQueued
+	}
+	return p.MaxDelivered
+}
+
+// CheckDelivery returns ErrPayloadTooLarge if a message of size bytes won't be delivered
+func (p *RelayPolicy) CheckDelivery(size int, queued bool) error {
+	if max := p.DeliveryCeiling(queued); max > 0 && size > int(max) {
+		what := "delivered"
+		if queued {
+			what = "queued"
+		}
+		return fmt.Errorf("%w: %d-byte message, relay policy allows %d bytes %s", ErrPayloadTooLarge, size, max, what)
+	}
+	return nil
+}
+
+// Buckets returns the padding buckets the relay advertises (nil for a nil policy)
+func (p *RelayPolicy) Buckets() []uint32 {
+	if p == nil {
+		return nil
+	}
+	return p.PaddingBuckets
+}
+
+// ValidatePaddingBuckets checks there are at most MaxPaddingBuckets, ascending from MinPaddingBucket to MaxPaddingBucket
+func ValidatePaddingBuckets(buckets []uint32) error {
+	if len(buckets) > MaxPaddingBuckets {
+		return fmt.Errorf("%d padding buckets, max %d", len(buckets), MaxPaddingBuckets)
+	}
+	for i, size := range buckets {
+		if size < MinPaddingBucket || size > MaxPaddingBucket {
+			return fmt.Errorf("padding bucket of %d bytes is outside %d-%d", size, MinPaddingBucket, MaxPaddingBucket)
+		}
+		if i > 0 && size <= buckets[i-1] {
+			return fmt.Errorf("padding buckets must be ascending, %d follows %d", size, buckets[i-1])
+		}
+	}
+	return nil
+}
+
+// EncodePaddingBuckets encodes padding buckets to bytes
+// Format: [Count 1][Size 4]... Handshakes carry them after the feature list
+// rather than in the policy itself, so relays' acks stay readable by older clients.
+func EncodePaddingBuckets(buckets []uint32) []byte {
+	if len(buckets) > MaxPaddingBuckets {
+		buckets = buckets[:MaxPaddingBuckets]
+	}
+
+	buf := make([]byte, 0, 1+4*len(buckets))
+	buf = append(buf, uint8(len(buckets)))
+	for _, size := range buckets {
+		buf = binary.BigEndian.AppendUint32(buf, size)
+	}
+	return buf
+}
+
+// DecodePaddingBuckets decodes padding buckets from bytes and returns how many bytes were consumed
+func DecodePaddingBuckets(buf []byte) ([]uint32, int, error) {
+	if len(buf) < 1 {
+		return nil, 0, fmt.Errorf("%w for padding buckets", ErrShortBuffer)
+	}
+
+	count := int(buf[0])
+	if len(buf) < 1+4*count {
+		return nil, 0, fmt.Errorf("%w for %d padding buckets", ErrShortBuffer, count)
+	}
+
+	var buckets []uint32
+	for i := 0; i < count; i++ {
+		buckets = append(buckets, binary.BigEndian.Uint32(buf[1+4*i:]))
+	}
+	if err := ValidatePaddingBuckets(buckets); err != nil {
+		return nil, 0, err
+	}
+	return buckets, 1 + 4*count, nil
+}
+
+// Encode encodes the policy to bytes
+// PaddingBuckets are not included (see EncodePaddingBuckets).
+// Format: [Count 1][Type 2]... [Count 1][Type 2, Max 4]... [MaxDelivered 4][MaxQueued 4]
+func (p *RelayPolicy) Encode() []byte {
+	allowed := slices.Clone(p.AllowedTypes)
+	slices.Sort(allowed)
+	allowed = slices.Compact(allowed)
+	if len(allowed) > MaxRelayPolicyEntries {
+		allowed = allowed[:MaxRelayPolicyEntries]
+	}
+
+	types := make([]uint16, 0, len(p.MaxPayload))
+	for t := range p.MaxPayload {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	if len(types) > MaxRelayPolicyEntries {
+		types = types[:MaxRelayPolicyEntries]
+	}
+
+	buf := make([]byte, 0, 1+2*len(allowed)+1+6*len(types)+8)
+	buf = append(buf, uint8(len(allowed)))
+	for _, t := range allowed {
+		buf = binary.BigEndian.AppendUint16(buf, t)
+	}
+	buf = append(buf, uint8(len(types)))
+	for _, t := range types {
+		buf = binary.BigEndian.AppendUint16(buf, t)
+		buf = binary.BigEndian.AppendUint32(buf, p.MaxPayload[t])
+	}
+	buf = binary.BigEndian.AppendUint32(buf, p.MaxDelivered)
+	buf = binary.BigEndian.AppendUint32(buf, p.MaxQueued)
+
+	return buf
+}
+
+// Decode decodes the policy from bytes and returns how many bytes were consumed
+func (p *RelayPolicy) Decode(buf []byte) (int, error) {
+	if len(buf) < 1 {
+		return 0, fmt.Errorf("%w for relay policy", ErrShortBuffer)
+	}
+
+	count := int(buf[0])
+	offset := 1
+	if len(buf) < offset+2*count+1 {
+		return 0, fmt.Errorf("%w for %d allowed types", ErrShortBuffer, count)
+	}
+	p.AllowedTypes = nil
+	for i := 0; i < count; i++ {
+		p.AllowedTypes = append(p.AllowedTypes, binary.BigEndian.Uint16(buf[offset:]))
+		offset += 2
+	}
+
+	count = int(buf[offset])
+	offset++
+	if len(buf) < offset+6*count+8 {
+		return 0, fmt.Errorf("%w for %d relay policy entries", ErrShortBuffer, count)
+	}
+	p.MaxPayload = nil
+	if count > 0 {
+		p.MaxPayload = make(map[uint16]uint32, count)
+	}
+	for i := 0; i < count; i++ {
+		p.MaxPayload[binary.BigEndian.Uint16(buf[offset:])] = binary.BigEndian.Uint32(buf[offset+2:])
+		offset += 6
+	}
+
+	p.MaxDelivered = binary.BigEndian.Uint32(buf[offset:])
+	p.MaxQueued = binary.BigEndian.Uint32(buf[offset+4:])
+	offset += 8
+
+	return offset, nil
+}
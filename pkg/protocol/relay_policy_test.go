@@ -0,0 +1,171 @@
+package protocol
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRelayPolicyCheckFrame(t *testing.T) {
+	policy := &RelayPolicy{
+		AllowedTypes: []uint16{MsgTypeRelayForward, MsgTypePing},
+		MaxPayload:   map[uint16]uint32{MsgTypeRelayForward: 1000},
+	}
+
+	tests := []struct {
+		name    string
+		msgType uint16
+		length  uint32
+		wantErr error
+	}{
+		{"Allowed at ceiling", MsgTypeRelayForward, 1000, nil},
+		{"Allowed over ceiling", MsgTypeRelayForward, 1001, ErrPayloadTooLarge},
+		{"Allowed without ceiling", MsgTypePing, 1 << 20, nil},
+		{"Not allowed", MsgTypeProbe, 10, ErrTypeNotAllowed},
+		{"Handshake always allowed", MsgTypeHandshake, 10, nil},
+		{"Resume always allowed", MsgTypeResume, 10, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.CheckFrame(&Header{Type: tt.msgType, Length: tt.length})
+			if !errors.Is(err, tt.wantErr) || (err != nil) != (tt.wantErr != nil) {
+				t.Errorf("CheckFrame() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	var nilPolicy *RelayPolicy
+	if err := nilPolicy.CheckFrame(&Header{Type: MsgTypeProbe, Length: 1 << 30}); err != nil {
+		t.Errorf("nil CheckFrame() error = %v", err)
+	}
+}
+
+func TestRelayPolicyDeliveryCeiling(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    *RelayPolicy
+		delivered uint32
+		queued    uint32
+	}{
+		{"Nil", nil, 0, 0},
+		{"Delivery only", &RelayPolicy{MaxDelivered: 1000}, 1000, 1000},
+		{"Smaller queue", &RelayPolicy{MaxDelivered: 1000, MaxQueued: 100}, 1000, 100},
+		{"Queue can't exceed delivery", &RelayPolicy{MaxDelivered: 100, MaxQueued: 1000}, 100, 100},
+		{"Queue only", &RelayPolicy{MaxQueued: 100}, 0, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.DeliveryCeiling(false); got != tt.delivered {
+				t.Errorf("DeliveryCeiling(false) = %d, want %d", got, tt.delivered)
+			}
+			if got := tt.policy.DeliveryCeiling(true); got != tt.queued {
+				t.Errorf("DeliveryCeiling(true) = %d, want %d", got, tt.queued)
+			}
+		})
+	}
+
+	policy := &RelayPolicy{MaxDelivered: 1000, MaxQueued: 100}
+	if err := policy.CheckDelivery(1000, false); err != nil {
+		t.Errorf("CheckDelivery(1000, false) error = %v", err)
+	}
+	if err := policy.CheckDelivery(101, true); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("CheckDelivery(101, true) error = %v, want ErrPayloadTooLarge", err)
+	}
+}
+
+func TestHandshakeRelayPolicy(t *testing.T) {
+	policy := &RelayPolicy{
+		AllowedTypes: []uint16{MsgTypePing, MsgTypeRelayForward},
+		MaxPayload:   map[uint16]uint32{MsgTypeRelayForward: 4 << 20},
+		MaxDelivered: 2 << 20,
+		MaxQueued:    1 << 20,
+	}
+	ack := &HandshakeMessage{
+		ProtocolVersion: ProtocolVersion,
+		Address:         Address{3},
+		PublicKey:       []byte("-----BEGIN PUBLIC KEY-----"),
+		ClientType:      ClientTypeRelay,
+		Timestamp:       1700000000,
+		Policy:          policy,
+	}
+
+	// The policy follows placeholder limits, tenant and bot proof
+	var decoded HandshakeMessage
+	if err := decoded.Decode(ack.Encode()); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if decoded.Limits == nil || decoded.Tenant != "" || decoded.BotProof != nil {
+		t.Errorf("Decode() Limits = %v, Tenant = %q, BotProof = %x", decoded.Limits, decoded.Tenant, decoded.BotProof)
+	}
+	if !reflect.DeepEqual(decoded.Policy, policy) {
+		t.Errorf("Decode() Policy = %+v, want %+v", decoded.Policy, policy)
+	}
+
+	// Acks from older relays carry no policy
+	ack.Policy = nil
+	if err := decoded.Decode(ack.Encode()); err != nil {
+		t.Fatalf("Decode(no policy) error = %v", err)
+	}
+	if decoded.Policy != nil {
+		t.Errorf("Decode(no policy) Policy = %+v", decoded.Policy)
+	}
+
+	var truncated RelayPolicy
+	if _, err := truncated.Decode(policy.Encode()[:5]); err == nil {
+		t.Error("Decode(truncated policy) expected error, got nil")
+	}
+}
+
+func TestHandshakePaddingBuckets(t *testing.T) {
+	policy := &RelayPolicy{MaxDelivered: 1 << 20, PaddingBuckets: []uint32{1024, 4096, 16384}}
+	ack := &HandshakeMessage{
+		ProtocolVersion: ProtocolVersion,
+		Address:         Address{3},
+		PublicKey:       []byte("-----BEGIN PUBLIC KEY-----"),
+		ClientType:      ClientTypeRelay,
+		Timestamp:       1700000000,
+		Policy:          policy,
+	}
+
+	// Buckets follow an empty feature list
+	encoded := ack.Encode()
+	var decoded HandshakeMessage
+	if err := decoded.Decode(encoded); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if len(decoded.Features) != 0 || !reflect.DeepEqual(decoded.Policy, policy) {
+		t.Errorf("Decode() Features = %v, Policy = %+v, want none and %+v", decoded.Features, decoded.Policy, policy)
+	}
+
+	for _, flags := range []uint16{0, FlagCBOR} {
+		data, err := EncodePayload(ack, flags)
+		if err != nil {
+			t.Fatalf("EncodePayload(flags %#x) error = %v", flags, err)
+		}
+		var got HandshakeMessage
+		if err := DecodePayload(data, flags, &got); err != nil {
+			t.Fatalf("DecodePayload(flags %#x) error = %v", flags, err)
+		}
+		if !reflect.DeepEqual(got.Policy.Buckets(), policy.PaddingBuckets) {
+			t.Errorf("DecodePayload(flags %#x) buckets = %v, want %v", flags, got.Policy.Buckets(), policy.PaddingBuckets)
+		}
+	}
+
+	// Acks from relays that predate buckets end after the features
+	legacy := encoded[:len(encoded)-len(EncodePaddingBuckets(policy.PaddingBuckets))]
+	if err := decoded.Decode(legacy); err != nil {
+		t.Fatalf("Decode(legacy) error = %v", err)
+	}
+	if decoded.Policy == nil || decoded.Policy.Buckets() != nil {
+		t.Errorf("Decode(legacy) Policy = %+v, want one without buckets", decoded.Policy)
+	}
+
+	for _, buckets := range [][]uint32{{4096, 1024}, {1024, 1024}, {100}, {MaxPaddingBucket + 1}} {
+		ack.Policy = &RelayPolicy{PaddingBuckets: buckets}
+		if err := decoded.Decode(ack.Encode()); err == nil {
+			t.Errorf("Decode(buckets %v) expected error, got nil", buckets)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package protocol
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+)
+
+// ===== SESSION RESUMPTION =====
+
+// TicketSize is the length of a session resumption ticket
+const TicketSize = 32
+
+// Ticket is an opaque, single-use token that lets a user resume a relay session
+type Ticket [TicketSize]byte
+
+// Resume statuses
+const (
+	ResumeAccepted uint8 = 0x00 // Session restored; the relay sends a fresh ticket next
+	ResumeRejected uint8 = 0x01 // Unknown, used or expired ticket; do a full handshake
+)
+
+// SessionTicket is sent by a relay after a handshake or resume that asked for FlagResumable
+type SessionTicket struct {
+	Ticket   Ticket `cbor:"1,keyasint,omitempty"`
+	Lifetime uint32 `cbor:"2,keyasint,omitempty"` // Seconds after a disconnect during which the ticket can be redeemed
+}
+
+// Encode encodes the ticket to bytes
+// Format: [Ticket 32][Lifetime 4]
+func (t *SessionTicket) Encode() []byte {
+	buf := make([]byte, TicketSize+4)
+	copy(buf, t.Ticket[:])
+	binary.BigEndian.PutUint32(buf[TicketSize:], t.Lifetime)
+	return buf
+}
+
+// Decode decodes the ticket from bytes
+func (t *SessionTicket) Decode(buf []byte) error {
+	if len(buf) < TicketSize+4 {
+		return fmt.Errorf("%w for session ticket", ErrShortBuffer)
+	}
+	copy(t.Ticket[:], buf[:TicketSize])
+	t.Lifetime = binary.BigEndian.Uint32(buf[TicketSize:])
+	return nil
+}
+
+// ResumeRequest asks a relay to restore a session instead of handshaking again
+type ResumeRequest struct {
+	Ticket Ticket `cbor:"1,keyasint,omitempty"`
+	Cursor uint64 `cbor:"2,keyasint,omitempty"` // Sequence of the last queued message received (see QueuedMessageID)
+}
+
+// Encode encodes the request to bytes
+// Format: [Ticket 32][Cursor 8]
+func (r *ResumeRequest) Encode() []byte {
+	buf := make([]byte, TicketSize+8)
+	copy(buf, r.Ticket[:])
+	binary.BigEndian.PutUint64(buf[TicketSize:], r.Cursor)
+	return buf
+}
+
+// Decode decodes the request from bytes
+func (r *ResumeRequest) Decode(buf []byte) error {
+	if len(buf) < TicketSize+8 {
+		return fmt.Errorf("%w for resume request", ErrShortBuffer)
+	}
+	copy(r.Ticket[:], buf[:TicketSize])
+	r.Cursor = binary.BigEndian.Uint64(buf[TicketSize:])
+	return nil
+}
+
+// ResumeAck answers a ResumeRequest
+type ResumeAck struct {
+	Status uint8 `cbor:"1,keyasint,omitempty"`
+}
+
+// Encode encodes the ack to bytes
+// Format: [Status 1]
+func (a *ResumeAck) Encode() []byte {
+	return []byte{a.Status}
+}
+
+// Decode decodes the ack from bytes
+func (a *ResumeAck) Decode(buf []byte) error {
+	if len(buf) < 1 {
+		return fmt.Errorf("%w for resume ack", ErrShortBuffer)
+	}
+	a.Status = buf[0]
+	return nil
+}
+
+// NewTicket generates a random ticket
+func NewTicket() (Ticket, error) {
+	var t Ticket
+	if _, err := rand.Read(t[:]); err != nil {
+		return Ticket{}, fmt.Errorf("failed to generate ticket: %w", err)
+	}
+	return t, nil
+}
+
+// QueuedMessageID builds the header MessageID of a queued delivery
+// The first 8 bytes carry the queue sequence so the client can report how far
+// it got; the rest is random.
+func QueuedMessageID(seq uint64) MessageID {
+	id := GenerateMessageID()
+	binary.BigEndian.PutUint64(id[0:8], seq)
+	return id
+}
+
+// QueueSeq returns the queue sequence of a header with FlagQueued (0 otherwise)
+func (h *Header) QueueSeq() uint64 {
+	if !h.HasFlag(FlagQueued) {
+		return 0
+	}
+	return binary.BigEndian.Uint64(h.MessageID[0:8])
+}
@@ -0,0 +1,20 @@
+package protocol
+
+// SetReturnRelay names the relay the sender is connected to, so the recipient's ACK can reach it
+// The recipient's relay forwards the ACK to this relay rather than only to
+// users connected to itself. The address travels inside the end-to-end
+// encrypted message, so relays on the forward path don't learn it.
+func (m *DirectMessage) SetReturnRelay(relay Address) {
+	m.SetExtension(ExtensionReturnRelay, append([]byte(nil), relay[:]...))
+}
+
+// ReturnRelay returns the sender's relay named by the message, if any
+func (m *DirectMessage) ReturnRelay() (Address, bool) {
+	var relay Address
+	data, exists := m.Extension(ExtensionReturnRelay)
+	if !exists || len(data) != len(relay) {
+		return relay, false
+	}
+	copy(relay[:], data)
+	return relay, relay != Address{}
+}
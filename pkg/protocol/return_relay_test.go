@@ -0,0 +1,83 @@
+package protocol
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestReturnRelayRoundTrip(t *testing.T) {
+	msg := &DirectMessage{From: Address{1}, To: Address{2}, Content: []byte("hi")}
+	if _, ok := msg.ReturnRelay(); ok {
+		t.Fatal("ReturnRelay() set on a message without one")
+	}
+
+	relay := Address{0xAA, 0xBB}
+	msg.SetReturnRelay(relay)
+
+	decoded := &DirectMessage{}
+	if err := decoded.Decode(msg.Encode()); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if got, ok := decoded.ReturnRelay(); !ok || got != relay {
+		t.Errorf("ReturnRelay() = %x, %v; want %x", got, ok, relay)
+	}
+
+	msg.SetExtension(ExtensionReturnRelay, []byte{1, 2, 3})
+	if _, ok := msg.ReturnRelay(); ok {
+		t.Error("ReturnRelay() accepted a truncated address")
+	}
+}
+
+func TestAckMessageReturnRelay(t *testing.T) {
+	ack := &AckMessage{From: Address{1}, To: Address{2}, SequenceNumber: 7, ReturnRelay: Address{3}}
+
+	encoded := ack.Encode()
+	if len(encoded) != 92 {
+		t.Fatalf("Encode() length = %d, want 92", len(encoded))
+	}
+
+	decoded := &AckMessage{}
+	if err := decoded.Decode(encoded); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !reflect.DeepEqual(decoded, ack) {
+		t.Errorf("Decoded ACK = %+v, want %+v", decoded, ack)
+	}
+
+	// Older peers send the 72-byte form, which names no return relay
+	if err := decoded.Decode(encoded[:72]); err != nil {
+		t.Fatalf("Decode(72 bytes) error = %v", err)
+	}
+	if decoded.ReturnRelay != (Address{}) {
+		t.Errorf("ReturnRelay = %x from a 72-byte ACK", decoded.ReturnRelay)
+	}
+}
+
+func TestAckMessageSignature(t *testing.T) {
+	ack := &AckMessage{From: Address{1}, To: Address{2}, SequenceNumber: 7}
+	digest := ack.Digest()
+	ack.Signature = []byte("signature")
+
+	// A signature is carried after a return relay, zero if none was named
+	encoded := ack.Encode()
+	if len(encoded) != 92+len(ack.Signature) {
+		t.Fatalf("Encode() length = %d, want %d", len(encoded), 92+len(ack.Signature))
+	}
+	decoded := &AckMessage{}
+	if err := decoded.Decode(encoded); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !reflect.DeepEqual(decoded, ack) {
+		t.Errorf("Decoded ACK = %+v, want %+v", decoded, ack)
+	}
+
+	// The digest leaves the signature out but covers every other field
+	if !bytes.Equal(decoded.Digest(), digest) {
+		t.Error("Digest() changed with the signature")
+	}
+	decoded.MessageID[0] = 1
+	if bytes.Equal(decoded.Digest(), digest) {
+		t.Error("Digest() did not change with the message ID")
+	}
+}
@@ -1,22 +1,87 @@
 package protocol
 
-import "encoding/binary"
+import (
+	"encoding/binary"
+	"fmt"
+)
 
 // ===== HANDSHAKE =====
 
+// MaxTenantIDLength is the longest tenant ID a handshake can carry
+const MaxTenantIDLength = 64
+
 // HandshakeMessage represents a connection handshake
 type HandshakeMessage struct {
-	ProtocolVersion uint16  // Protocol version
-	Address         Address // ETH address
-	PublicKey       []byte  // RSA public key
-	ClientType      uint8   // User or relay
-	Timestamp       uint64  // Unix timestamp (ms)
-	Signature       []byte  // Signature
+	ProtocolVersion uint16  `cbor:"1,keyasint,omitempty"` // Protocol version
+	Address         Address `cbor:"2,keyasint,omitempty"` // ETH address
+	PublicKey       []byte  `cbor:"3,keyasint,omitempty"` // RSA public key
+	ClientType      uint8   `cbor:"4,keyasint,omitempty"` // User or relay
+	Timestamp       uint64  `cbor:"5,keyasint,omitempty"` // Unix timestamp (seconds)
+	Signature       []byte  `cbor:"6,keyasint,omitempty"` // Signature
+
+	// Payload limits the sender accepts (optional trailer; nil from older peers)
+	Limits *PayloadLimits `cbor:"7,keyasint,omitempty"`
+
+	// Organization the user belongs to on a multi-tenant relay (optional trailer
+	// after Limits; "" = none)
+	Tenant string `cbor:"8,keyasint,omitempty"`
+
+	// BotProof authenticates a bot account (optional trailer after Tenant; see BotProof)
+	BotProof []byte `cbor:"9,keyasint,omitempty"`
+
+	// What the relay accepts from users (optional trailer after BotProof; sent
+	// in relays' handshake acks, nil from older relays)
+	Policy *RelayPolicy `cbor:"10,keyasint,omitempty"`
+
+	// Features the sender has switched on (optional trailer after Policy; sent
+	// in relays' handshake acks, nil from older relays)
+	Features FeatureList `cbor:"11,keyasint,omitempty"`
+
+	// Policy.PaddingBuckets travel in a last optional trailer after Features
 }
 
 // Encode encodes handshake to bytes
 func (m *HandshakeMessage) Encode() []byte {
-	size := 2 + 20 + 4 + len(m.PublicKey) + 1 + 8 + 4 + len(m.Signature)
+	// Optional trailers in order: limits, tenant, bot proof, policy, features,
+	// padding buckets. A later trailer needs the earlier ones, so missing limits
+	// are sent as the defaults and a missing tenant, bot proof, policy or
+	// feature list as an empty one.
+	var trailer []byte
+	hasBuckets := len(m.Policy.Buckets()) > 0
+	hasFeatures := len(m.Features) > 0 || hasBuckets
+	hasPolicy := m.Policy != nil || hasFeatures
+	hasProof := len(m.BotProof) > 0 || hasPolicy
+	hasTenant := m.Tenant != "" || hasProof
+	if m.Limits != nil || hasTenant {
+		limits := m.Limits
+		if limits == nil {
+			limits = DefaultPayloadLimits()
+		}
+		trailer = limits.Encode()
+	}
+	if hasTenant {
+		trailer = append(trailer, uint8(len(m.Tenant)))
+		trailer = append(trailer, m.Tenant...)
+	}
+	if hasProof {
+		trailer = append(trailer, uint8(len(m.BotProof)))
+		trailer = append(trailer, m.BotProof...)
+	}
+	if hasPolicy {
+		policy := m.Policy
+		if policy == nil {
+			policy = &RelayPolicy{}
+		}
+		trailer = append(trailer, policy.Encode()...)
+	}
+	if hasFeatures {
+		trailer = append(trailer, m.Features.Encode()...)
+	}
+	if hasBuckets {
+		trailer = append(trailer, EncodePaddingBuckets(m.Policy.PaddingBuckets)...)
+	}
+
+	size := 2 + 20 + 4 + len(m.PublicKey) + 1 + 8 + 4 + len(m.Signature) + len(trailer)
 	buf := make([]byte, size)
 	offset := 0
 
@@ -42,6 +107,9 @@ func (m *HandshakeMessage) Encode() []byte {
 	offset += 4
 
 	copy(buf[offset:], m.Signature)
+	offset += len(m.Signature)
+
+	copy(buf[offset:], trailer)
 
 	return buf
 }
@@ -74,7 +142,85 @@ func (m *HandshakeMessage) Decode(buf []byte) error {
 
 	m.Signature = make([]byte, sigLen)
 	copy(m.Signature, buf[offset:offset+int(sigLen)])
+	offset += int(sigLen)
+
+	// Older peers end the handshake here
+	m.Limits = nil
+	m.Tenant = ""
+	m.BotProof = nil
+	m.Policy = nil
+	m.Features = nil
+	if offset < len(buf) {
+		m.Limits = &PayloadLimits{}
+		n, err := m.Limits.Decode(buf[offset:])
+		if err != nil {
+			return fmt.Errorf("handshake payload limits: %w", err)
+		}
+		offset += n
+	}
+
+	if offset < len(buf) {
+		tenantLen := int(buf[offset])
+		offset++
+		if offset+tenantLen > len(buf) {
+			return fmt.Errorf("handshake tenant ID truncated")
+		}
+		m.Tenant = string(buf[offset : offset+tenantLen])
+		offset += tenantLen
+	}
+
+	if offset < len(buf) {
+		proofLen := int(buf[offset])
+		offset++
+		if offset+proofLen > len(buf) {
+			return fmt.Errorf("handshake bot proof truncated")
+		}
+		if proofLen > 0 {
+			m.BotProof = make([]byte, proofLen)
+			copy(m.BotProof, buf[offset:offset+proofLen])
+		}
+		offset += proofLen
+	}
+
+	if offset < len(buf) {
+		m.Policy = &RelayPolicy{}
+		n, err := m.Policy.Decode(buf[offset:])
+		if err != nil {
+			return fmt.Errorf("handshake relay policy: %w", err)
+		}
+		offset += n
+	}
+
+	if offset < len(buf) {
+		n, err := m.Features.Decode(buf[offset:])
+		if err != nil {
+			return fmt.Errorf("handshake features: %w", err)
+		}
+		offset += n
+	}
+
+	if offset < len(buf) && m.Policy != nil {
+		buckets, _, err := DecodePaddingBuckets(buf[offset:])
+		if err != nil {
+			return fmt.Errorf("handshake padding buckets: %w", err)
+		}
+		m.Policy.PaddingBuckets = buckets
+	}
+
+	return m.Validate()
+}
 
+// Validate checks the optional trailers fit their length prefixes
+func (m *HandshakeMessage) Validate() error {
+	if len(m.Tenant) > MaxTenantIDLength {
+		return fmt.Errorf("tenant ID is %d bytes, max %d", len(m.Tenant), MaxTenantIDLength)
+	}
+	if len(m.BotProof) != 0 && len(m.BotProof) != BotProofSize {
+		return fmt.Errorf("bot proof is %d bytes, want %d", len(m.BotProof), BotProofSize)
+	}
+	if err := ValidatePaddingBuckets(m.Policy.Buckets()); err != nil {
+		return fmt.Errorf("relay policy: %w", err)
+	}
 	return nil
 }
 
@@ -82,10 +228,10 @@ func (m *HandshakeMessage) Decode(buf []byte) error {
 
 // RelayForward represents a message being forwarded through relays
 type RelayForward struct {
-	NextHop     Address // Next relay address (or zero for final delivery)
-	TTL         uint8   // Time to live (hops remaining)
-	Payload     []byte  // Encrypted next layer
-	PayloadHash Hash    // BLAKE2b hash for integrity
+	NextHop     Address `cbor:"1,keyasint,omitempty"` // Next relay address (or zero for final delivery)
+	TTL         uint8   `cbor:"2,keyasint,omitempty"` // Time to live (hops remaining)
+	Payload     []byte  `cbor:"3,keyasint,omitempty"` // Encrypted next layer
+	PayloadHash Hash    `cbor:"4,keyasint,omitempty"` // BLAKE2b hash for integrity
 }
 
 // Encode encodes relay forward to bytes
@@ -0,0 +1,92 @@
+package protocol
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandshakeTenant(t *testing.T) {
+	hs := &HandshakeMessage{
+		ProtocolVersion: ProtocolVersion,
+		Address:         Address{1},
+		PublicKey:       []byte("-----BEGIN PUBLIC KEY-----"),
+		ClientType:      ClientTypeUser,
+		Tenant:          "acme",
+	}
+
+	// A tenant without limits still decodes: the default limits fill the slot
+	var decoded HandshakeMessage
+	if err := decoded.Decode(hs.Encode()); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if decoded.Tenant != "acme" || decoded.Limits == nil {
+		t.Errorf("Decode() Tenant = %q, Limits = %v", decoded.Tenant, decoded.Limits)
+	}
+
+	// Relays predating tenancy stop after the limits
+	withoutTenant := *hs
+	withoutTenant.Tenant = ""
+	withoutTenant.Limits = DefaultPayloadLimits()
+	if err := decoded.Decode(withoutTenant.Encode()); err != nil {
+		t.Fatalf("Decode(no tenant) error = %v", err)
+	}
+	if decoded.Tenant != "" {
+		t.Errorf("Decode(no tenant) Tenant = %q", decoded.Tenant)
+	}
+
+	encoded := hs.Encode()
+	if err := decoded.Decode(encoded[:len(encoded)-1]); err == nil {
+		t.Error("Decode(truncated tenant) expected error, got nil")
+	}
+
+	hs.Tenant = strings.Repeat("x", MaxTenantIDLength+1)
+	if err := decoded.Decode(hs.Encode()); err == nil {
+		t.Error("Decode(long tenant) expected error, got nil")
+	}
+}
+
+func TestHandshakeBotProof(t *testing.T) {
+	apiKey := []byte("0123456789abcdef0123456789abcdef")
+	hs := &HandshakeMessage{
+		ProtocolVersion: ProtocolVersion,
+		Address:         Address{2},
+		PublicKey:       []byte("-----BEGIN PUBLIC KEY-----"),
+		ClientType:      ClientTypeUser,
+		Timestamp:       1700000000,
+	}
+	hs.BotProof = BotProof(apiKey, hs.Address, hs.Timestamp)
+
+	// A proof without a tenant or limits sends both as placeholders
+	var decoded HandshakeMessage
+	if err := decoded.Decode(hs.Encode()); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if decoded.Tenant != "" || decoded.Limits == nil {
+		t.Errorf("Decode() Tenant = %q, Limits = %v", decoded.Tenant, decoded.Limits)
+	}
+	if !VerifyBotProof(apiKey, decoded.Address, decoded.Timestamp, decoded.BotProof) {
+		t.Error("VerifyBotProof() = false for a valid proof")
+	}
+
+	if VerifyBotProof(apiKey, decoded.Address, decoded.Timestamp+1, decoded.BotProof) {
+		t.Error("VerifyBotProof() = true for another timestamp")
+	}
+	if VerifyBotProof([]byte("other key"), decoded.Address, decoded.Timestamp, decoded.BotProof) {
+		t.Error("VerifyBotProof() = true for another key")
+	}
+
+	// Every proof carries a fresh nonce, and the MAC covers it
+	if again := BotProof(apiKey, hs.Address, hs.Timestamp); BotProofNonce(again) == BotProofNonce(hs.BotProof) {
+		t.Error("BotProof() reused a nonce")
+	}
+	tampered := append([]byte(nil), hs.BotProof...)
+	tampered[0] ^= 1
+	if VerifyBotProof(apiKey, hs.Address, hs.Timestamp, tampered) {
+		t.Error("VerifyBotProof() = true for another nonce")
+	}
+
+	hs.BotProof = hs.BotProof[:16]
+	if err := decoded.Decode(hs.Encode()); err == nil {
+		t.Error("Decode(short proof) expected error, got nil")
+	}
+}
@@ -0,0 +1,230 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// StickerPackManifestVersion is the current manifest format version
+const StickerPackManifestVersion = 1
+
+// MaxStickersPerPack limits the size of a sticker pack
+const MaxStickersPerPack = 120
+
+// StickerPackID uniquely identifies a sticker pack (16 bytes)
+type StickerPackID [16]byte
+
+// ===== STICKER PACK MANIFEST =====
+
+// StickerEntry describes one sticker inside a pack
+// The image itself is stored encrypted in MeshStorage
+type StickerEntry struct {
+	ID            uint16 `json:"id"`             // Index within the pack
+	Emoji         string `json:"emoji"`          // Associated emoji (for suggestions)
+	ContentType   uint8  `json:"content_type"`   // ContentTypeSticker or ContentTypeGIF
+	MIMEType      string `json:"mime_type"`      // e.g., image/webp
+	ChunkID       uint64 `json:"chunk_id"`       // MeshStorage chunk ID
+	EncryptionKey []byte `json:"encryption_key"` // AES-256 key for the chunk
+	Size          int    `json:"size"`           // Plaintext size in bytes
+}
+
+// StickerPackManifest lists the stickers in a pack
+// The manifest is JSON-encoded and stored encrypted in MeshStorage
+type StickerPackManifest struct {
+	Version   int            `json:"version"`
+	PackID    StickerPackID  `json:"pack_id"`
+	Name      string         `json:"name"`
+	Author    Address        `json:"author"`
+	CreatedAt int64          `json:"created_at"` // Unix timestamp (ms)
+	Stickers  []StickerEntry `json:"stickers"`
+}
+
+// Encode serializes the manifest to JSON
+func (m *StickerPackManifest) Encode() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// DecodeStickerPackManifest deserializes and validates a manifest
+func DecodeStickerPackManifest(data []byte) (*StickerPackManifest, error) {
+	var m StickerPackManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to decode sticker pack manifest: %w", err)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Validate checks the manifest for structural problems
+func (m *StickerPackManifest) Validate() error {
+	if m.Version != StickerPackManifestVersion {
+		return fmt.Errorf("%w of sticker pack manifest: %d", ErrUnsupportedVersion, m.Version)
+	}
+	if m.Name == "" {
+		return errors.New("sticker pack has no name")
+	}
+	if len(m.Stickers) == 0 {
+		return errors.New("sticker pack is empty")
+	}
+	if len(m.Stickers) > MaxStickersPerPack {
+		return fmt.Errorf("sticker pack has %d stickers (max %d)", len(m.Stickers), MaxStickersPerPack)
+	}
+
+	seen := make(map[uint16]bool, len(m.Stickers))
+	for _, s := range m.Stickers {
+		if seen[s.ID] {
+			return fmt.Errorf("duplicate sticker id %d", s.ID)
+		}
+		seen[s.ID] = true
+
+		if s.ContentType != ContentTypeSticker && s.ContentType != ContentTypeGIF {
+			return fmt.Errorf("sticker %d has unsupported content type 0x%02x", s.ID, s.ContentType)
+		}
+		if len(s.EncryptionKey) != 32 {
+			return fmt.Errorf("sticker %d has invalid encryption key length", s.ID)
+		}
+	}
+
+	return nil
+}
+
+// Sticker returns the entry with the given ID
+func (m *StickerPackManifest) Sticker(id uint16) (*StickerEntry, bool) {
+	for i := range m.Stickers {
+		if m.Stickers[i].ID == id {
+			return &m.Stickers[i], true
+		}
+	}
+	return nil, false
+}
+
+// ===== STICKER PACK REFERENCE =====
+
+// StickerPackReference is the content of a ContentTypeStickerPack message
+// It carries everything needed to fetch and decrypt the manifest from MeshStorage
+type StickerPackReference struct {
+	PackID        StickerPackID `cbor:"1,keyasint,omitempty"` // Pack identifier
+	ManifestChunk uint64        `cbor:"2,keyasint,omitempty"` // MeshStorage chunk ID of the encrypted manifest
+	ManifestKey   [32]byte      `cbor:"3,keyasint,omitempty"` // AES-256 key for the manifest
+	Name          string        `cbor:"4,keyasint,omitempty"` // Pack name (max 255 bytes, for display before install)
+}
+
+// Encode encodes the reference to bytes
+// Format: [PackID 16][ChunkID 8][Key 32][NameLen 1][Name]
+func (r *StickerPackReference) Encode() []byte {
+	name := []byte(r.Name)
+	if len(name) > 255 {
+		name = name[:255]
+	}
+
+	buf := make([]byte, 16+8+32+1+len(name))
+	offset := 0
+
+	copy(buf[offset:], r.PackID[:])
+	offset += 16
+
+	binary.BigEndian.PutUint64(buf[offset:], r.ManifestChunk)
+	offset += 8
+
+	copy(buf[offset:], r.ManifestKey[:])
+	offset += 32
+
+	buf[offset] = uint8(len(name))
+	offset++
+
+	copy(buf[offset:], name)
+
+	return buf
+}
+
+// Decode decodes the reference from bytes
+func (r *StickerPackReference) Decode(buf []byte) error {
+	if len(buf) < 16+8+32+1 {
+		return fmt.Errorf("%w for sticker pack reference", ErrShortBuffer)
+	}
+
+	offset := 0
+
+	copy(r.PackID[:], buf[offset:offset+16])
+	offset += 16
+
+	r.ManifestChunk = binary.BigEndian.Uint64(buf[offset:])
+	offset += 8
+
+	copy(r.ManifestKey[:], buf[offset:offset+32])
+	offset += 32
+
+	nameLen := int(buf[offset])
+	offset++
+
+	if len(buf) < offset+nameLen {
+		return fmt.Errorf("%w for sticker pack name", ErrShortBuffer)
+	}
+	r.Name = string(buf[offset : offset+nameLen])
+
+	return nil
+}
+
+// validateStickerPackReference is the content validator for ContentTypeStickerPack
+func validateStickerPackReference(content []byte) error {
+	var ref StickerPackReference
+	return ref.Decode(content)
+}
+
+// ===== STICKER MESSAGE =====
+
+// StickerMessage is the content of a ContentTypeSticker/ContentTypeGIF message sent from a pack
+type StickerMessage struct {
+	PackID        StickerPackID `cbor:"1,keyasint,omitempty"` // Pack the sticker belongs to
+	StickerID     uint16        `cbor:"2,keyasint,omitempty"` // Sticker index within the pack
+	ChunkID       uint64        `cbor:"3,keyasint,omitempty"` // MeshStorage chunk ID of the image
+	EncryptionKey [32]byte      `cbor:"4,keyasint,omitempty"` // AES-256 key for the image
+}
+
+// stickerMessageSize is the encoded size of a StickerMessage
+const stickerMessageSize = 16 + 2 + 8 + 32
+
+// Encode encodes the sticker message to bytes
+// Format: [PackID 16][StickerID 2][ChunkID 8][Key 32]
+func (m *StickerMessage) Encode() []byte {
+	buf := make([]byte, stickerMessageSize)
+	offset := 0
+
+	copy(buf[offset:], m.PackID[:])
+	offset += 16
+
+	binary.BigEndian.PutUint16(buf[offset:], m.StickerID)
+	offset += 2
+
+	binary.BigEndian.PutUint64(buf[offset:], m.ChunkID)
+	offset += 8
+
+	copy(buf[offset:], m.EncryptionKey[:])
+
+	return buf
+}
+
+// Decode decodes the sticker message from bytes
+func (m *StickerMessage) Decode(buf []byte) error {
+	if len(buf) < stickerMessageSize {
+		return fmt.Errorf("%w for sticker message", ErrShortBuffer)
+	}
+
+	offset := 0
+
+	copy(m.PackID[:], buf[offset:offset+16])
+	offset += 16
+
+	m.StickerID = binary.BigEndian.Uint16(buf[offset:])
+	offset += 2
+
+	m.ChunkID = binary.BigEndian.Uint64(buf[offset:])
+	offset += 8
+
+	copy(m.EncryptionKey[:], buf[offset:offset+32])
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package protocol
+
+import (
+	"bytes"
+	"testing"
+)
+
+func testStickerManifest() *StickerPackManifest {
+	return &StickerPackManifest{
+		Version:   StickerPackManifestVersion,
+		PackID:    StickerPackID{1, 2, 3},
+		Name:      "Cats",
+		CreatedAt: 1700000000000,
+		Stickers: []StickerEntry{
+			{ID: 0, Emoji: "😺", ContentType: ContentTypeSticker, MIMEType: "image/webp", ChunkID: 42, EncryptionKey: bytes.Repeat([]byte{7}, 32)},
+			{ID: 1, Emoji: "😹", ContentType: ContentTypeGIF, MIMEType: "image/gif", ChunkID: 43, EncryptionKey: bytes.Repeat([]byte{8}, 32)},
+		},
+	}
+}
+
+func TestStickerPackManifestRoundTrip(t *testing.T) {
+	manifest := testStickerManifest()
+
+	data, err := manifest.Encode()
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	decoded, err := DecodeStickerPackManifest(data)
+	if err != nil {
+		t.Fatalf("DecodeStickerPackManifest() error = %v", err)
+	}
+
+	if decoded.PackID != manifest.PackID || decoded.Name != manifest.Name {
+		t.Errorf("decoded manifest = %+v, want %+v", decoded, manifest)
+	}
+
+	entry, ok := decoded.Sticker(1)
+	if !ok || entry.ChunkID != 43 || entry.ContentType != ContentTypeGIF {
+		t.Errorf("Sticker(1) = %+v, %v", entry, ok)
+	}
+}
+
+func TestStickerPackManifestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(m *StickerPackManifest)
+	}{
+		{"wrong version", func(m *StickerPackManifest) { m.Version = 99 }},
+		{"no name", func(m *StickerPackManifest) { m.Name = "" }},
+		{"empty", func(m *StickerPackManifest) { m.Stickers = nil }},
+		{"duplicate id", func(m *StickerPackManifest) { m.Stickers[1].ID = 0 }},
+		{"bad content type", func(m *StickerPackManifest) { m.Stickers[0].ContentType = ContentTypeText }},
+		{"bad key", func(m *StickerPackManifest) { m.Stickers[0].EncryptionKey = []byte{1} }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := testStickerManifest()
+			tt.mutate(m)
+			if err := m.Validate(); err == nil {
+				t.Error("Validate() should fail")
+			}
+		})
+	}
+}
+
+func TestStickerPackReferenceEncodeDecode(t *testing.T) {
+	ref := &StickerPackReference{
+		PackID:        StickerPackID{9, 9, 9},
+		ManifestChunk: 12345,
+		ManifestKey:   [32]byte{1, 2, 3},
+		Name:          "Cats",
+	}
+
+	var decoded StickerPackReference
+	if err := decoded.Decode(ref.Encode()); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	if decoded != *ref {
+		t.Errorf("decoded = %+v, want %+v", decoded, *ref)
+	}
+
+	if err := ValidateContent(ContentTypeStickerPack, ref.Encode()); err != nil {
+		t.Errorf("ValidateContent(sticker pack) error = %v", err)
+	}
+	if err := ValidateContent(ContentTypeStickerPack, []byte{1, 2, 3}); err == nil {
+		t.Error("ValidateContent(truncated sticker pack) should fail")
+	}
+}
+
+func TestStickerMessageEncodeDecode(t *testing.T) {
+	msg := &StickerMessage{
+		PackID:        StickerPackID{4, 5, 6},
+		StickerID:     7,
+		ChunkID:       99,
+		EncryptionKey: [32]byte{0xAA},
+	}
+
+	var decoded StickerMessage
+	if err := decoded.Decode(msg.Encode()); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	if decoded != *msg {
+		t.Errorf("decoded = %+v, want %+v", decoded, *msg)
+	}
+
+	if err := decoded.Decode([]byte{1}); err == nil {
+		t.Error("Decode() should fail on short buffer")
+	}
+}
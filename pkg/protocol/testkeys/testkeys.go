@@ -0,0 +1,181 @@
+// Package testkeys derives ZenTalk identities, key bundles and ratchet
+// sessions from seed strings.
+//
+// Every key and nonce is read from a keystream keyed by the seed, so a seed
+// produces the same keys, bundles and ciphertexts on every run. Integration
+// tests can compare exact transcripts, and other implementations can follow
+// the derivation below to check their output against ours.
+//
+// Keys made here are for tests only: anyone who knows the seed knows them.
+//
+// # Derivation
+//
+// Reader(seed) is the ChaCha20 keystream (all-zero nonce) under the key
+// SHA-256("zentalk-testkeys-v1" || 0x00 || seed). Each purpose reads its own
+// stream, seeded with "<seed>/<purpose>", in the order the protocol package's
+// *From functions consume bytes:
+//
+//	<user>/address           20-byte address, then a 4-byte big-endian registration ID
+//	<user>/identity          Ed25519 seed (32 bytes), then X25519 private key (32)
+//	<user>/signed-prekey     X25519 private key, key ID 1, stamped with Timestamp
+//	<user>/one-time-prekeys  X25519 private keys, key IDs 1..n
+//	<session>/x3dh           the initiator's ephemeral key
+//	<session>/initiator      the initiator's DH ratchet keys and nonces, as used
+//	<session>/responder      the same for the responder
+package testkeys
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+	"golang.org/x/crypto/chacha20"
+)
+
+// domain separates testkeys streams from any other use of the seed
+const domain = "zentalk-testkeys-v1"
+
+// Timestamp is the signing time of every signed prekey (2023-11-14T22:13:20Z)
+const Timestamp uint64 = 1700000000000
+
+// CipherSuites is the suite list advertised in every bundle
+// PreferredCipherSuites depends on the CPU, which would make bundles differ between machines.
+var CipherSuites = []protocol.CipherSuite{
+	protocol.CipherSuiteAES256GCM,
+	protocol.CipherSuiteChaCha20Poly1305,
+	protocol.CipherSuiteXChaCha20Poly1305,
+}
+
+// keystream reads a ChaCha20 keystream
+type keystream struct {
+	cipher *chacha20.Cipher
+}
+
+// Read fills p with the next len(p) keystream bytes
+func (k *keystream) Read(p []byte) (int, error) {
+	clear(p)
+	k.cipher.XORKeyStream(p, p)
+	return len(p), nil
+}
+
+// Reader returns the deterministic byte stream for seed
+// It is not safe for concurrent use.
+func Reader(seed string) io.Reader {
+	h := sha256.New()
+	h.Write([]byte(domain))
+	h.Write([]byte{0})
+	h.Write([]byte(seed))
+
+	c, err := chacha20.NewUnauthenticatedCipher(h.Sum(nil), make([]byte, chacha20.NonceSize))
+	if err != nil {
+		panic(err) // Key and nonce sizes are fixed
+	}
+	return &keystream{cipher: c}
+}
+
+// User is a deterministic identity with the keys it publishes
+type User struct {
+	Seed           string
+	Address        protocol.Address
+	RegistrationID uint32
+	Identity       *protocol.IdentityKeyPair
+	SignedPreKey   *protocol.SignedPreKeyPrivate
+	OneTimePreKeys []*protocol.OneTimePreKeyPrivate
+}
+
+// NewUser derives a user and oneTimePreKeys one-time prekeys from seed
+func NewUser(seed string, oneTimePreKeys int) (*User, error) {
+	u := &User{Seed: seed}
+
+	r := Reader(seed + "/address")
+	var regID [4]byte
+	if _, err := io.ReadFull(r, u.Address[:]); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(r, regID[:]); err != nil {
+		return nil, err
+	}
+	u.RegistrationID = binary.BigEndian.Uint32(regID[:])
+
+	var err error
+	if u.Identity, err = protocol.GenerateIdentityKeyPairFrom(Reader(seed + "/identity")); err != nil {
+		return nil, fmt.Errorf("identity key: %w", err)
+	}
+	if u.SignedPreKey, err = protocol.GenerateSignedPreKeyFrom(Reader(seed+"/signed-prekey"), 1, u.Identity, Timestamp); err != nil {
+		return nil, fmt.Errorf("signed prekey: %w", err)
+	}
+	if u.OneTimePreKeys, err = protocol.GenerateOneTimePreKeysFrom(Reader(seed+"/one-time-prekeys"), 1, oneTimePreKeys); err != nil {
+		return nil, fmt.Errorf("one-time prekeys: %w", err)
+	}
+	return u, nil
+}
+
+// Bundle returns the user's published key bundle
+func (u *User) Bundle() *protocol.KeyBundle {
+	bundle := protocol.CreateKeyBundle(u.Address, u.Identity, u.SignedPreKey, u.OneTimePreKeys, u.RegistrationID)
+	bundle.CipherSuites = append([]protocol.CipherSuite(nil), CipherSuites...)
+	return bundle
+}
+
+// oneTimePreKeyMap indexes the user's one-time prekeys by ID, as X3DHResponder expects
+func (u *User) oneTimePreKeyMap() map[uint32]*protocol.OneTimePreKeyPrivate {
+	keys := make(map[uint32]*protocol.OneTimePreKeyPrivate, len(u.OneTimePreKeys))
+	for _, opk := range u.OneTimePreKeys {
+		keys[opk.KeyID] = opk
+	}
+	return keys
+}
+
+// Session is both ends of a ratchet session set up with X3DH
+type Session struct {
+	Initiator      *protocol.RatchetState
+	Responder      *protocol.RatchetState
+	InitialMessage *protocol.InitialMessage
+}
+
+// NewSession runs X3DH from initiator to responder's bundle and sets up both ratchets
+// The session uses suite rather than negotiating one, and both states keep
+// drawing from seeded streams, so every message they Seal is reproducible.
+// Neither user is modified; the responder's one-time prekey stays available.
+func NewSession(seed string, initiator, responder *User, suite protocol.CipherSuite) (*Session, error) {
+	bundle := responder.Bundle()
+
+	sharedSecret, ephemeralPrivate, ephemeralPublic, initialMsg, err := protocol.X3DHInitiatorFrom(
+		Reader(seed+"/x3dh"), initiator.Address, initiator.Identity, bundle)
+	if err != nil {
+		return nil, fmt.Errorf("X3DH initiator: %w", err)
+	}
+	initialMsg.CipherSuite = suite
+
+	initiatorState, err := protocol.NewRatchetState(
+		sharedSecret,
+		bundle.SignedPreKey.PublicKey,
+		ephemeralPrivate,
+		ephemeralPublic,
+		initiator.Address,
+		responder.Address,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("initiator ratchet: %w", err)
+	}
+	initiatorState.CipherSuite = suite
+	initiatorState.SetRandom(Reader(seed + "/initiator"))
+
+	responderSecret, err := protocol.X3DHResponder(responder.Identity, responder.SignedPreKey, responder.oneTimePreKeyMap(), initialMsg)
+	if err != nil {
+		return nil, fmt.Errorf("X3DH responder: %w", err)
+	}
+	responderState, err := protocol.NewRatchetStateResponder(responderSecret, responder.SignedPreKey, initialMsg, responder.Address, nil)
+	if err != nil {
+		return nil, fmt.Errorf("responder ratchet: %w", err)
+	}
+	responderState.SetRandom(Reader(seed + "/responder"))
+
+	return &Session{
+		Initiator:      initiatorState,
+		Responder:      responderState,
+		InitialMessage: initialMsg,
+	}, nil
+}
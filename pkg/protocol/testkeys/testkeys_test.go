@@ -0,0 +1,152 @@
+package testkeys
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// transcript exchanges messages in both directions and returns every header and ciphertext
+func transcript(t *testing.T, s *Session) [][]byte {
+	t.Helper()
+
+	var out [][]byte
+	for i, step := range []struct {
+		from, to *protocol.RatchetState
+	}{
+		{s.Initiator, s.Responder},
+		{s.Initiator, s.Responder},
+		{s.Responder, s.Initiator}, // DH ratchet step on each side
+		{s.Initiator, s.Responder},
+	} {
+		plaintext := []byte(fmt.Sprintf("message %d", i))
+		header, ciphertext, err := step.from.Seal(plaintext)
+		if err != nil {
+			t.Fatalf("message %d: Seal() error = %v", i, err)
+		}
+		got, err := step.to.Open(header, ciphertext)
+		if err != nil {
+			t.Fatalf("message %d: Open() error = %v", i, err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Fatalf("message %d: Open() = %q, want %q", i, got, plaintext)
+		}
+		out = append(out, header, ciphertext)
+	}
+	return out
+}
+
+// newTestSession derives alice and bob and opens a session between them
+func newTestSession(t *testing.T, seed string, suite protocol.CipherSuite) (*User, *User, *Session) {
+	t.Helper()
+
+	alice, err := NewUser("alice", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := NewUser("bob", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := NewSession(seed, alice, bob, suite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return alice, bob, session
+}
+
+func TestUserDeterministic(t *testing.T) {
+	a, err := NewUser("alice", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewUser("alice", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(a.Bundle().Encode(), b.Bundle().Encode()) {
+		t.Error("same seed produced different bundles")
+	}
+	if a.Identity.PrivateKey != b.Identity.PrivateKey || a.Identity.DHPrivate != b.Identity.DHPrivate {
+		t.Error("same seed produced different identity keys")
+	}
+
+	// More one-time prekeys leave the other keys alone
+	c, err := NewUser("alice", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Address != a.Address || c.SignedPreKey.PublicKey != a.SignedPreKey.PublicKey ||
+		c.OneTimePreKeys[2].PublicKey != a.OneTimePreKeys[2].PublicKey {
+		t.Error("one-time prekey count changed other keys")
+	}
+
+	other, err := NewUser("bob", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Address == a.Address || other.Identity.DHPublic == a.Identity.DHPublic {
+		t.Error("different seeds produced the same keys")
+	}
+
+	if !protocol.VerifySignedPreKey(a.Identity.PublicKey, &a.Bundle().SignedPreKey) {
+		t.Error("signed prekey signature does not verify")
+	}
+}
+
+// TestVectors pins the derivation so changes to it, or to the protocol's key
+// generation, show up as a failure rather than silently new transcripts
+func TestVectors(t *testing.T) {
+	alice, bob, session := newTestSession(t, "alice->bob", protocol.CipherSuiteChaCha20Poly1305)
+
+	header, ciphertext, err := session.Initiator.Seal([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		got  []byte
+		want string
+	}{
+		{"alice address", alice.Address[:], "a8beb7b16f8df0453ed195f53a72ca6b6fd41efd"},
+		{"alice identity", alice.Identity.DHPublic[:], "d8a21a4fa4505731e858322b2a508a3d3cdd105cca6475475f73143fe8f1263a"},
+		{"bob signed prekey", bob.SignedPreKey.PublicKey[:], "49c46270a53abc7c57f83a6ad804ba97ba1ea06e6d3f2aa5e4426613b45afa17"},
+		{"ephemeral key", session.InitialMessage.EphemeralKey[:], "e7dcea0e22c5ef3b56ef76094a10380ac7f10a33124d032745cf42b519875621"},
+		{"first header", header, "e7dcea0e22c5ef3b56ef76094a10380ac7f10a33124d032745cf42b5198756210000000000000000"},
+		{"first ciphertext", ciphertext, "ecde0c3c3cc4a251036f4452bba4b909d839e3db445c7497ec2c09d7710f5814da"},
+	} {
+		if got := hex.EncodeToString(tt.got); got != tt.want {
+			t.Errorf("%s = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSessionTranscript(t *testing.T) {
+	for _, suite := range []protocol.CipherSuite{
+		protocol.CipherSuiteAES256GCM,
+		protocol.CipherSuiteChaCha20Poly1305,
+		protocol.CipherSuiteXChaCha20Poly1305,
+	} {
+		_, _, first := newTestSession(t, "transcript", suite)
+		_, _, second := newTestSession(t, "transcript", suite)
+		if first.Responder.CipherSuite != suite {
+			t.Errorf("%s: responder suite = %s", suite, first.Responder.CipherSuite)
+		}
+
+		a, b := transcript(t, first), transcript(t, second)
+		for i := range a {
+			if !bytes.Equal(a[i], b[i]) {
+				t.Fatalf("%s: transcript differs at part %d", suite, i)
+			}
+		}
+
+		_, _, other := newTestSession(t, "another transcript", suite)
+		if c := transcript(t, other); bytes.Equal(a[1], c[1]) {
+			t.Errorf("%s: different session seeds produced the same ciphertext", suite)
+		}
+	}
+}
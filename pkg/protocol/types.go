@@ -21,16 +21,30 @@ const (
 // Message types
 const (
 	// Connection Management (0x00xx)
-	MsgTypeHandshake    uint16 = 0x0001
-	MsgTypeHandshakeAck uint16 = 0x0002
-	MsgTypePing         uint16 = 0x0003
-	MsgTypePong         uint16 = 0x0004
-	MsgTypeDisconnect   uint16 = 0x0005
+	MsgTypeHandshake           uint16 = 0x0001
+	MsgTypeHandshakeAck        uint16 = 0x0002
+	MsgTypePing                uint16 = 0x0003
+	MsgTypePong                uint16 = 0x0004
+	MsgTypeDisconnect          uint16 = 0x0005
+	MsgTypeProbe               uint16 = 0x0006 // Bandwidth probe (relay self-measurement)
+	MsgTypeProbeAck            uint16 = 0x0007
+	MsgTypeResume              uint16 = 0x0008 // Resume a session with a ticket instead of handshaking
+	MsgTypeResumeAck           uint16 = 0x0009
+	MsgTypeTicket              uint16 = 0x000A // Session resumption ticket from the relay
+	MsgTypeQueueProgress       uint16 = 0x000B // Offline queue flush progress from the relay; payload is QueueProgress
+	MsgTypeQueueWindow         uint16 = 0x000C // Client takes more of its offline queue; payload is QueueWindow
+	MsgTypeOfflineSync         uint16 = 0x000D // Client pulls a page of its offline queue; payload is OfflineSyncRequest
+	MsgTypeOfflineSyncResponse uint16 = 0x000E // Page of the offline queue; payload is OfflineSyncResponse
+	MsgTypeStartTLS            uint16 = 0x000F // Client asks to switch the connection to TLS before handshaking; no payload
+	MsgTypeStartTLSAck         uint16 = 0x0010 // Relay agrees; TLS starts right after this header; no payload
 
 	// Relay Operations (0x01xx)
-	MsgTypeRelayForward uint16 = 0x0100
-	MsgTypeRelayAck     uint16 = 0x0101
-	MsgTypeRelayError   uint16 = 0x0102
+	MsgTypeRelayForward  uint16 = 0x0100
+	MsgTypeRelayAck      uint16 = 0x0101
+	MsgTypeRelayError    uint16 = 0x0102
+	MsgTypeRelayMoved    uint16 = 0x0103 // Relay migrated; payload is RelayMovedNotice
+	MsgTypeRouteUpdate   uint16 = 0x0104 // Users a relay can reach; payload is RouteUpdate
+	MsgTypeRoutedMessage uint16 = 0x0105 // Final delivery passed between relays; payload is RoutedMessage
 
 	// User Messages (0x02xx)
 	MsgTypeDirectMessage uint16 = 0x0200
@@ -38,6 +52,7 @@ const (
 	MsgTypeTyping        uint16 = 0x0202
 	MsgTypeReadReceipt   uint16 = 0x0203
 	MsgTypePresence      uint16 = 0x0204
+	MsgTypeDeviceFanout  uint16 = 0x0205 // One device's copy of a message to all of a user's devices; payload is DeviceFanout
 
 	// Profile & Groups (0x03xx)
 	MsgTypeProfileUpdate  uint16 = 0x0300
@@ -48,8 +63,15 @@ const (
 	MsgTypeGroupUpdate    uint16 = 0x0305
 
 	// Media (0x04xx)
-	MsgTypeMediaUpload   uint16 = 0x0400
-	MsgTypeMediaDownload uint16 = 0x0401
+	MsgTypeMediaUpload           uint16 = 0x0400
+	MsgTypeMediaDownload         uint16 = 0x0401
+	MsgTypeMediaDirectoryRequest uint16 = 0x0402 // Ask the relay where to upload media; empty payload
+	MsgTypeMediaDirectory        uint16 = 0x0403 // Relay's storage endpoints and media limits; payload is MediaDirectory
+
+	// Key Distribution (0x06xx)
+	MsgTypeKeyBundlePublish  uint16 = 0x0600 // Store our key bundle on the relay; payload is KeyBundlePublish
+	MsgTypeKeyBundleRequest  uint16 = 0x0601 // Fetch a user's key bundle; payload is KeyBundleRequest
+	MsgTypeKeyBundleResponse uint16 = 0x0602 // Relay's answer to either; payload is KeyBundleResponse
 
 	// System (0x05xx)
 	MsgTypeError uint16 = 0x0500
@@ -59,25 +81,41 @@ const (
 
 // Flags
 const (
-	FlagEncrypted   uint16 = 0x0001 // Payload is encrypted
-	FlagCompressed  uint16 = 0x0002 // Payload is compressed
-	FlagFragmented  uint16 = 0x0004 // Message is fragmented
-	FlagUrgent      uint16 = 0x0008 // High priority message
-	FlagRequiresAck uint16 = 0x0010 // Requires acknowledgment
-	FlagPadded      uint16 = 0x0020 // Message has padding (for traffic analysis resistance)
+	FlagEncrypted      uint16 = 0x0001 // Payload is encrypted
+	FlagCompressed     uint16 = 0x0002 // Payload is compressed
+	FlagFragmented     uint16 = 0x0004 // Message is fragmented
+	FlagUrgent         uint16 = 0x0008 // High priority message
+	FlagRequiresAck    uint16 = 0x0010 // Requires acknowledgment
+	FlagPadded         uint16 = 0x0020 // Message has padding (for traffic analysis resistance)
+	FlagUniformRecords uint16 = 0x0040 // Handshake/HandshakeAck: switch to uniform records after the ACK
+	FlagResumable      uint16 = 0x0080 // Handshake: client wants session resumption tickets
+	FlagQueued         uint16 = 0x0100 // DirectMessage: delivered from the offline queue; MessageID carries the queue sequence
+	FlagCBOR           uint16 = 0x0200 // Payload is CBOR with integer field keys instead of the fixed binary layout
+	FlagQueueFlow      uint16 = 0x0400 // Handshake/Resume: flush the offline queue in windows granted with QueueWindow
+	FlagOfflineSync    uint16 = 0x0800 // Handshake/Resume: don't flush the offline queue; the client pulls it with OfflineSync
 )
 
 // Content types
 const (
-	ContentTypeText     uint8 = 0x00
-	ContentTypeImage    uint8 = 0x01
-	ContentTypeVideo    uint8 = 0x02
-	ContentTypeAudio    uint8 = 0x03
-	ContentTypeFile     uint8 = 0x04
-	ContentTypeLocation uint8 = 0x05
-	ContentTypeContact  uint8 = 0x06
-	ContentTypeSticker  uint8 = 0x07
-	ContentTypePoll     uint8 = 0x08
+	ContentTypeText        uint8 = 0x00
+	ContentTypeImage       uint8 = 0x01
+	ContentTypeVideo       uint8 = 0x02
+	ContentTypeAudio       uint8 = 0x03
+	ContentTypeFile        uint8 = 0x04
+	ContentTypeLocation    uint8 = 0x05
+	ContentTypeContact     uint8 = 0x06
+	ContentTypeSticker     uint8 = 0x07
+	ContentTypePoll        uint8 = 0x08
+	ContentTypeGIF         uint8 = 0x09 // Animated GIF (sticker-style reference or inline)
+	ContentTypeStickerPack uint8 = 0x0A // Sticker pack reference (install link)
+	ContentTypeVoiceNote   uint8 = 0x0B // Voice note descriptor (chunks stored in MeshStorage)
+
+	ContentTypeRecoveryShare   uint8 = 0x0C // Social recovery share (to a contact, or back to the owner's new device)
+	ContentTypeRecoveryRequest uint8 = 0x0D // New device asking a contact for its recovery share
+
+	ContentTypeReadStateSync uint8 = 0x0E // Conversation read/mute state, sent between a user's own devices
+
+	ContentTypeGroupFile uint8 = 0x0F // File shared in a group (manifest stored in MeshStorage)
 )
 
 // Client types
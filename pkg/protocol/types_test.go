@@ -260,6 +260,10 @@ func TestFlagConstants(t *testing.T) {
 		FlagFragmented,
 		FlagUrgent,
 		FlagRequiresAck,
+		FlagPadded,
+		FlagUniformRecords,
+		FlagResumable,
+		FlagQueued,
 	}
 
 	for i, flag := range flags {
@@ -0,0 +1,152 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	// MaxVoiceNoteWaveform is the maximum number of waveform samples carried in a voice note
+	MaxVoiceNoteWaveform = 128
+
+	// MaxVoiceNoteChunks limits how many MeshStorage chunks a single voice note may span
+	MaxVoiceNoteChunks = 512
+
+	// voiceNoteChunkRefSize is the encoded size of one VoiceNoteChunk
+	voiceNoteChunkRefSize = 8 + 32 + 4
+)
+
+// VoiceNoteChunk references one segment of a voice note stored in MeshStorage
+type VoiceNoteChunk struct {
+	ChunkID       uint64   `cbor:"1,keyasint,omitempty"` // MeshStorage chunk ID
+	EncryptionKey [32]byte `cbor:"2,keyasint,omitempty"` // AES-256 key for the chunk
+	Size          uint32   `cbor:"3,keyasint,omitempty"` // Plaintext size in bytes
+}
+
+// VoiceNoteMessage is the content of a ContentTypeVoiceNote message
+// The audio is split into chunks so playback can start after the first chunk arrives
+type VoiceNoteMessage struct {
+	DurationMs uint32           `cbor:"1,keyasint,omitempty"` // Total duration in milliseconds
+	MIMEType   string           `cbor:"2,keyasint,omitempty"` // Audio encoding (e.g., "audio/ogg; codecs=opus"), max 255 bytes
+	Waveform   []byte           `cbor:"3,keyasint,omitempty"` // Amplitude samples (0-255) for rendering, max MaxVoiceNoteWaveform
+	Chunks     []VoiceNoteChunk `cbor:"4,keyasint,omitempty"` // Audio segments in playback order
+}
+
+// TotalSize returns the size of the full audio in bytes
+func (m *VoiceNoteMessage) TotalSize() uint64 {
+	var total uint64
+	for _, chunk := range m.Chunks {
+		total += uint64(chunk.Size)
+	}
+	return total
+}
+
+// Encode encodes the voice note to bytes
+// Format: [Duration 4][MIMELen 1][MIME][WaveformLen 1][Waveform][ChunkCount 2][Chunks...]
+func (m *VoiceNoteMessage) Encode() []byte {
+	mimeType := []byte(m.MIMEType)
+	if len(mimeType) > 255 {
+		mimeType = mimeType[:255]
+	}
+	waveform := m.Waveform
+	if len(waveform) > MaxVoiceNoteWaveform {
+		waveform = waveform[:MaxVoiceNoteWaveform]
+	}
+
+	size := 4 + 1 + len(mimeType) + 1 + len(waveform) + 2 + len(m.Chunks)*voiceNoteChunkRefSize
+	buf := make([]byte, size)
+	offset := 0
+
+	binary.BigEndian.PutUint32(buf[offset:], m.DurationMs)
+	offset += 4
+
+	buf[offset] = uint8(len(mimeType))
+	offset++
+	copy(buf[offset:], mimeType)
+	offset += len(mimeType)
+
+	buf[offset] = uint8(len(waveform))
+	offset++
+	copy(buf[offset:], waveform)
+	offset += len(waveform)
+
+	binary.BigEndian.PutUint16(buf[offset:], uint16(len(m.Chunks)))
+	offset += 2
+
+	for _, chunk := range m.Chunks {
+		binary.BigEndian.PutUint64(buf[offset:], chunk.ChunkID)
+		offset += 8
+
+		copy(buf[offset:], chunk.EncryptionKey[:])
+		offset += 32
+
+		binary.BigEndian.PutUint32(buf[offset:], chunk.Size)
+		offset += 4
+	}
+
+	return buf
+}
+
+// Decode decodes the voice note from bytes
+func (m *VoiceNoteMessage) Decode(buf []byte) error {
+	if len(buf) < 4+1 {
+		return fmt.Errorf("%w for voice note", ErrShortBuffer)
+	}
+
+	offset := 0
+
+	m.DurationMs = binary.BigEndian.Uint32(buf[offset:])
+	offset += 4
+
+	mimeLen := int(buf[offset])
+	offset++
+	if len(buf) < offset+mimeLen+1 {
+		return fmt.Errorf("%w for voice note MIME type", ErrShortBuffer)
+	}
+	m.MIMEType = string(buf[offset : offset+mimeLen])
+	offset += mimeLen
+
+	waveformLen := int(buf[offset])
+	offset++
+	if waveformLen > MaxVoiceNoteWaveform {
+		return fmt.Errorf("voice note waveform too long: %d samples", waveformLen)
+	}
+	if len(buf) < offset+waveformLen+2 {
+		return fmt.Errorf("%w for voice note waveform", ErrShortBuffer)
+	}
+	m.Waveform = make([]byte, waveformLen)
+	copy(m.Waveform, buf[offset:offset+waveformLen])
+	offset += waveformLen
+
+	chunkCount := int(binary.BigEndian.Uint16(buf[offset:]))
+	offset += 2
+	if chunkCount == 0 {
+		return fmt.Errorf("voice note has no chunks")
+	}
+	if chunkCount > MaxVoiceNoteChunks {
+		return fmt.Errorf("voice note has too many chunks: %d", chunkCount)
+	}
+	if len(buf) < offset+chunkCount*voiceNoteChunkRefSize {
+		return fmt.Errorf("%w for voice note chunks", ErrShortBuffer)
+	}
+
+	m.Chunks = make([]VoiceNoteChunk, chunkCount)
+	for i := range m.Chunks {
+		m.Chunks[i].ChunkID = binary.BigEndian.Uint64(buf[offset:])
+		offset += 8
+
+		copy(m.Chunks[i].EncryptionKey[:], buf[offset:offset+32])
+		offset += 32
+
+		m.Chunks[i].Size = binary.BigEndian.Uint32(buf[offset:])
+		offset += 4
+	}
+
+	return nil
+}
+
+// validateVoiceNote is the content validator for ContentTypeVoiceNote
+func validateVoiceNote(content []byte) error {
+	var m VoiceNoteMessage
+	return m.Decode(content)
+}
@@ -0,0 +1,61 @@
+package protocol
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestVoiceNoteEncodeDecode(t *testing.T) {
+	msg := &VoiceNoteMessage{
+		DurationMs: 12500,
+		MIMEType:   "audio/ogg; codecs=opus",
+		Waveform:   []byte{0, 64, 128, 255, 32},
+		Chunks: []VoiceNoteChunk{
+			{ChunkID: 1, EncryptionKey: [32]byte{1}, Size: 262144},
+			{ChunkID: 2, EncryptionKey: [32]byte{2}, Size: 1000},
+		},
+	}
+
+	var decoded VoiceNoteMessage
+	if err := decoded.Decode(msg.Encode()); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	if decoded.DurationMs != msg.DurationMs || decoded.MIMEType != msg.MIMEType {
+		t.Errorf("decoded header = %d/%q, want %d/%q", decoded.DurationMs, decoded.MIMEType, msg.DurationMs, msg.MIMEType)
+	}
+	if !bytes.Equal(decoded.Waveform, msg.Waveform) {
+		t.Errorf("decoded waveform = %v, want %v", decoded.Waveform, msg.Waveform)
+	}
+	if len(decoded.Chunks) != 2 || decoded.Chunks[1] != msg.Chunks[1] {
+		t.Errorf("decoded chunks = %+v, want %+v", decoded.Chunks, msg.Chunks)
+	}
+	if decoded.TotalSize() != 263144 {
+		t.Errorf("TotalSize() = %d, want 263144", decoded.TotalSize())
+	}
+}
+
+func TestVoiceNoteDecodeInvalid(t *testing.T) {
+	valid := (&VoiceNoteMessage{
+		DurationMs: 1000,
+		MIMEType:   "audio/ogg",
+		Chunks:     []VoiceNoteChunk{{ChunkID: 1, Size: 10}},
+	}).Encode()
+
+	// Every truncation must be rejected
+	for i := 0; i < len(valid); i++ {
+		var m VoiceNoteMessage
+		if err := m.Decode(valid[:i]); err == nil {
+			t.Errorf("Decode() of %d/%d bytes should fail", i, len(valid))
+		}
+	}
+
+	noChunks := (&VoiceNoteMessage{DurationMs: 1000, MIMEType: "audio/ogg"}).Encode()
+	if err := ValidateContent(ContentTypeVoiceNote, noChunks); err == nil {
+		t.Error("ValidateContent() should reject voice note without chunks")
+	}
+
+	if err := ValidateContent(ContentTypeVoiceNote, valid); err != nil {
+		t.Errorf("ValidateContent() error = %v", err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"crypto/sha256"
 	"encoding/binary"
 	"fmt"
+	"io"
 
 	"golang.org/x/crypto/curve25519"
 	"golang.org/x/crypto/hkdf"
@@ -71,38 +72,48 @@ type KeyBundle struct {
 	SignedPreKey   SignedPreKey    // Signed prekey
 	OneTimePreKeys []OneTimePreKey // Available one-time prekeys
 	RegistrationID uint32          // Unique registration ID
+	CipherSuites   []CipherSuite   `json:",omitempty"` // Supported cipher suites, preferred first (none = AES-256-GCM only)
 }
 
 // InitialMessage is sent by Alice to Bob to establish a session
 type InitialMessage struct {
 	// Sender information
-	SenderAddress Address // Alice's address (20 bytes)
+	SenderAddress Address `cbor:"1,keyasint,omitempty"` // Alice's address (20 bytes)
 
 	// Alice's keys
-	IdentityKey  [32]byte // Alice's identity key (X25519)
-	EphemeralKey [32]byte // Alice's ephemeral key
+	IdentityKey  [32]byte `cbor:"2,keyasint,omitempty"` // Alice's identity key (X25519)
+	EphemeralKey [32]byte `cbor:"3,keyasint,omitempty"` // Alice's ephemeral key
 
 	// Bob's key IDs (which keys Alice used)
-	UsedSignedPreKeyID  uint32
-	UsedOneTimePreKeyID uint32 // 0 if no OPK was used
+	UsedSignedPreKeyID  uint32 `cbor:"4,keyasint,omitempty"`
+	UsedOneTimePreKeyID uint32 `cbor:"5,keyasint,omitempty"` // 0 if no OPK was used
 
 	// Initial message encrypted with derived key
-	Ciphertext []byte
+	Ciphertext []byte `cbor:"6,keyasint,omitempty"`
+
+	// Cipher suite chosen for the session from the key bundle's list
+	CipherSuite CipherSuite `cbor:"7,keyasint,omitempty"`
 }
 
 // ===== KEY GENERATION =====
 
 // GenerateIdentityKeyPair generates a long-term identity key pair
 func GenerateIdentityKeyPair() (*IdentityKeyPair, error) {
+	return GenerateIdentityKeyPairFrom(rand.Reader)
+}
+
+// GenerateIdentityKeyPairFrom generates an identity key pair from the bytes of r
+// A deterministic r reproduces the same keys (see the testkeys package).
+func GenerateIdentityKeyPairFrom(r io.Reader) (*IdentityKeyPair, error) {
 	// Generate Ed25519 key pair for signatures
-	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	edPublic, edPrivate, err := ed25519.GenerateKey(r)
 	if err != nil {
 		return nil, err
 	}
 
 	// Generate X25519 key pair for DH
 	var dhPrivate [32]byte
-	if _, err := rand.Read(dhPrivate[:]); err != nil {
+	if _, err := io.ReadFull(r, dhPrivate[:]); err != nil {
 		return nil, err
 	}
 
@@ -121,9 +132,14 @@ func GenerateIdentityKeyPair() (*IdentityKeyPair, error) {
 
 // GenerateSignedPreKey generates a signed prekey
 func GenerateSignedPreKey(keyID uint32, identityKey *IdentityKeyPair) (*SignedPreKeyPrivate, error) {
+	return GenerateSignedPreKeyFrom(rand.Reader, keyID, identityKey, uint64(NowUnixMilli()))
+}
+
+// GenerateSignedPreKeyFrom generates a signed prekey from the bytes of r, stamped with timestamp
+func GenerateSignedPreKeyFrom(r io.Reader, keyID uint32, identityKey *IdentityKeyPair, timestamp uint64) (*SignedPreKeyPrivate, error) {
 	// Generate X25519 key pair
 	var private [32]byte
-	if _, err := rand.Read(private[:]); err != nil {
+	if _, err := io.ReadFull(r, private[:]); err != nil {
 		return nil, err
 	}
 
@@ -131,7 +147,6 @@ func GenerateSignedPreKey(keyID uint32, identityKey *IdentityKeyPair) (*SignedPr
 	curve25519.ScalarBaseMult(&public, &private)
 
 	// Create signature data: keyID + public key + timestamp
-	timestamp := uint64(NowUnixMilli())
 	sigData := make([]byte, 4+32+8)
 	binary.BigEndian.PutUint32(sigData[0:4], keyID)
 	copy(sigData[4:36], public[:])
@@ -153,11 +168,16 @@ func GenerateSignedPreKey(keyID uint32, identityKey *IdentityKeyPair) (*SignedPr
 
 // GenerateOneTimePreKeys generates multiple one-time prekeys
 func GenerateOneTimePreKeys(startID uint32, count int) ([]*OneTimePreKeyPrivate, error) {
+	return GenerateOneTimePreKeysFrom(rand.Reader, startID, count)
+}
+
+// GenerateOneTimePreKeysFrom generates one-time prekeys from the bytes of r
+func GenerateOneTimePreKeysFrom(r io.Reader, startID uint32, count int) ([]*OneTimePreKeyPrivate, error) {
 	keys := make([]*OneTimePreKeyPrivate, count)
 
 	for i := 0; i < count; i++ {
 		var private [32]byte
-		if _, err := rand.Read(private[:]); err != nil {
+		if _, err := io.ReadFull(r, private[:]); err != nil {
 			return nil, err
 		}
 
@@ -195,6 +215,7 @@ func CreateKeyBundle(
 			Timestamp: signedPreKey.Timestamp,
 		},
 		OneTimePreKeys: make([]OneTimePreKey, len(oneTimePreKeys)),
+		CipherSuites:   PreferredCipherSuites(),
 	}
 
 	for i, opk := range oneTimePreKeys {
@@ -229,6 +250,16 @@ func X3DHInitiator(
 	senderAddress Address,
 	aliceIdentity *IdentityKeyPair,
 	bobBundle *KeyBundle,
+) ([]byte, [32]byte, [32]byte, *InitialMessage, error) {
+	return X3DHInitiatorFrom(rand.Reader, senderAddress, aliceIdentity, bobBundle)
+}
+
+// X3DHInitiatorFrom performs X3DH as the initiator, drawing the ephemeral key from r
+func X3DHInitiatorFrom(
+	r io.Reader,
+	senderAddress Address,
+	aliceIdentity *IdentityKeyPair,
+	bobBundle *KeyBundle,
 ) ([]byte, [32]byte, [32]byte, *InitialMessage, error) {
 	// 1. Verify Bob's signed prekey
 	// Note: Skipping verification for now as we'd need Bob's Ed25519 key
@@ -236,7 +267,7 @@ func X3DHInitiator(
 
 	// 2. Generate ephemeral key
 	var ephemeralPrivate [32]byte
-	if _, err := rand.Read(ephemeralPrivate[:]); err != nil {
+	if _, err := io.ReadFull(r, ephemeralPrivate[:]); err != nil {
 		var empty [32]byte
 		return nil, empty, empty, nil, err
 	}
@@ -300,6 +331,7 @@ func X3DHInitiator(
 		EphemeralKey:        ephemeralPublic,
 		UsedSignedPreKeyID:  bobBundle.SignedPreKey.KeyID,
 		UsedOneTimePreKeyID: usedOPKID,
+		CipherSuite:         NegotiateCipherSuite(PreferredCipherSuites(), bobBundle.CipherSuites),
 	}
 
 	return sharedSecret, ephemeralPrivate, ephemeralPublic, initialMsg, nil
@@ -379,7 +411,11 @@ func X3DHResponder(
 // EncodeKeyBundle encodes a key bundle to bytes
 func (kb *KeyBundle) Encode() []byte {
 	// Calculate size: Address(20) + IdentityKey(32) + RegID(4) + SignedPreKey(4+32+64+8) + OPKCount(4) + OPKs(N*36)
+	// + [SuiteCount(1) + Suites(N)] when cipher suites are listed
 	size := 20 + 32 + 4 + 108 + 4 + len(kb.OneTimePreKeys)*36
+	if len(kb.CipherSuites) > 0 {
+		size += 1 + len(kb.CipherSuites)
+	}
 	buf := make([]byte, size)
 	offset := 0
 
@@ -417,13 +453,23 @@ func (kb *KeyBundle) Encode() []byte {
 		offset += 32
 	}
 
+	// Cipher suites (optional; older decoders ignore trailing bytes)
+	if len(kb.CipherSuites) > 0 {
+		buf[offset] = byte(len(kb.CipherSuites))
+		offset++
+		for _, suite := range kb.CipherSuites {
+			buf[offset] = byte(suite)
+			offset++
+		}
+	}
+
 	return buf
 }
 
 // DecodeKeyBundle decodes a key bundle from bytes
 func DecodeKeyBundle(buf []byte) (*KeyBundle, error) {
 	if len(buf) < 20+32+4+108+4 {
-		return nil, fmt.Errorf("buffer too short for key bundle")
+		return nil, fmt.Errorf("%w for key bundle", ErrShortBuffer)
 	}
 
 	kb := &KeyBundle{}
@@ -454,6 +500,9 @@ func DecodeKeyBundle(buf []byte) (*KeyBundle, error) {
 	// One-time prekeys count
 	opkCount := binary.BigEndian.Uint32(buf[offset:])
 	offset += 4
+	if uint64(len(buf)-offset) < uint64(opkCount)*36 {
+		return nil, fmt.Errorf("%w for %d one-time prekeys", ErrShortBuffer, opkCount)
+	}
 
 	kb.OneTimePreKeys = make([]OneTimePreKey, opkCount)
 	for i := uint32(0); i < opkCount; i++ {
@@ -463,13 +512,26 @@ func DecodeKeyBundle(buf []byte) (*KeyBundle, error) {
 		offset += 32
 	}
 
+	// Cipher suites (absent from bundles that predate negotiation)
+	if len(buf) > offset {
+		suiteCount := int(buf[offset])
+		offset++
+		if len(buf) < offset+suiteCount {
+			return nil, fmt.Errorf("%w for cipher suites", ErrShortBuffer)
+		}
+		kb.CipherSuites = make([]CipherSuite, suiteCount)
+		for i := range kb.CipherSuites {
+			kb.CipherSuites[i] = CipherSuite(buf[offset+i])
+		}
+	}
+
 	return kb, nil
 }
 
 // Encode encodes an InitialMessage to bytes
 func (im *InitialMessage) Encode() []byte {
-	// Calculate size: SenderAddress(20) + IdentityKey(32) + EphemeralKey(32) + SignedPreKeyID(4) + OneTimePreKeyID(4) + CiphertextLen(4) + Ciphertext
-	size := 20 + 32 + 32 + 4 + 4 + 4 + len(im.Ciphertext)
+	// Calculate size: SenderAddress(20) + IdentityKey(32) + EphemeralKey(32) + SignedPreKeyID(4) + OneTimePreKeyID(4) + CiphertextLen(4) + Ciphertext + CipherSuite(1)
+	size := 20 + 32 + 32 + 4 + 4 + 4 + len(im.Ciphertext) + 1
 	buf := make([]byte, size)
 	offset := 0
 
@@ -499,6 +561,10 @@ func (im *InitialMessage) Encode() []byte {
 
 	// Ciphertext (variable length)
 	copy(buf[offset:], im.Ciphertext)
+	offset += len(im.Ciphertext)
+
+	// Cipher suite (1 byte; older decoders ignore trailing bytes)
+	buf[offset] = byte(im.CipherSuite)
 
 	return buf
 }
@@ -506,7 +572,7 @@ func (im *InitialMessage) Encode() []byte {
 // Decode decodes an InitialMessage from bytes
 func (im *InitialMessage) Decode(buf []byte) error {
 	if len(buf) < 20+32+32+4+4+4 {
-		return fmt.Errorf("buffer too short for initial message")
+		return fmt.Errorf("%w for initial message", ErrShortBuffer)
 	}
 
 	offset := 0
@@ -537,12 +603,19 @@ func (im *InitialMessage) Decode(buf []byte) error {
 
 	// Validate ciphertext length
 	if len(buf) < offset+int(ciphertextLen) {
-		return fmt.Errorf("buffer too short for ciphertext")
+		return fmt.Errorf("%w for ciphertext", ErrShortBuffer)
 	}
 
 	// Ciphertext (variable length)
 	im.Ciphertext = make([]byte, ciphertextLen)
 	copy(im.Ciphertext, buf[offset:offset+int(ciphertextLen)])
+	offset += int(ciphertextLen)
+
+	// Cipher suite (absent from messages that predate negotiation)
+	im.CipherSuite = CipherSuiteAES256GCM
+	if len(buf) > offset {
+		im.CipherSuite = CipherSuite(buf[offset])
+	}
 
 	return nil
 }
@@ -0,0 +1,175 @@
+// Package release signs and verifies ZenTalk release manifests
+//
+// A manifest names the latest version and, per platform, where to download its
+// binary and the binary's SHA-256. Maintainers sign it with an Ed25519 release
+// key; relays pin the public key and ignore manifests it did not sign, so a
+// compromised download host can at worst withhold updates.
+package release
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// signatureTag separates manifest signatures from anything else signed with the key
+const signatureTag = "zentalk-release-manifest\n"
+
+var (
+	ErrBadSignature = errors.New("release manifest signature mismatch")
+	ErrNoBinary     = errors.New("release has no binary for this platform")
+)
+
+// Manifest describes a release
+type Manifest struct {
+	Version    string            `json:"version"`               // Semantic version, e.g. 1.4.2
+	MinVersion string            `json:"min_version,omitempty"` // Versions below this have known vulnerabilities
+	ReleasedAt int64             `json:"released_at"`           // Unix seconds
+	Notes      string            `json:"notes,omitempty"`
+	Binaries   map[string]Binary `json:"binaries"` // Keyed by "GOOS/GOARCH"
+}
+
+// Binary is one platform's build of a release
+type Binary struct {
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"` // Hex
+}
+
+// SignedManifest is a manifest as published: its bytes and their signature
+// The signature covers the compact JSON encoding of the manifest, so
+// re-indenting the published document doesn't invalidate it.
+type SignedManifest struct {
+	Manifest  json.RawMessage `json:"manifest"`
+	Signature []byte          `json:"signature"` // Ed25519, base64 in JSON
+}
+
+// Sign encodes and signs a manifest with the release key
+func Sign(m *Manifest, key ed25519.PrivateKey) (*SignedManifest, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid release key size: %d", len(key))
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	return &SignedManifest{
+		Manifest:  data,
+		Signature: ed25519.Sign(key, append([]byte(signatureTag), data...)),
+	}, nil
+}
+
+// Verify checks the signature against the pinned release key and returns the manifest
+func (s *SignedManifest) Verify(key ed25519.PublicKey) (*Manifest, error) {
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid release key size: %d", len(key))
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, s.Manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if !ed25519.Verify(key, append([]byte(signatureTag), compact.Bytes()...), s.Signature) {
+		return nil, ErrBadSignature
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(s.Manifest, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// Validate checks the manifest's versions and hashes are well-formed
+func (m *Manifest) Validate() error {
+	if _, err := parseVersion(m.Version); err != nil {
+		return err
+	}
+	if m.MinVersion != "" {
+		if _, err := parseVersion(m.MinVersion); err != nil {
+			return err
+		}
+	}
+	for platform, bin := range m.Binaries {
+		if sum, err := hex.DecodeString(bin.SHA256); err != nil || len(sum) != sha256.Size {
+			return fmt.Errorf("invalid sha256 for %s", platform)
+		}
+		if bin.URL == "" {
+			return fmt.Errorf("missing url for %s", platform)
+		}
+	}
+	return nil
+}
+
+// Binary returns the build for a platform
+func (m *Manifest) Binary(goos, goarch string) (Binary, error) {
+	bin, ok := m.Binaries[goos+"/"+goarch]
+	if !ok {
+		return Binary{}, fmt.Errorf("%w: %s/%s", ErrNoBinary, goos, goarch)
+	}
+	return bin, nil
+}
+
+// Check verifies downloaded bytes against the binary's hash
+func (b Binary) Check(data []byte) error {
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != strings.ToLower(b.SHA256) {
+		return fmt.Errorf("binary hash mismatch: got %x", sum)
+	}
+	return nil
+}
+
+// CompareVersions returns -1, 0 or 1 as a is older than, the same as, or newer than b
+// Versions are MAJOR.MINOR.PATCH with an optional "v" prefix; pre-release
+// suffixes are not supported.
+func CompareVersions(a, b string) (int, error) {
+	va, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range va {
+		if va[i] < vb[i] {
+			return -1, nil
+		}
+		if va[i] > vb[i] {
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// parseVersion splits a version into its three numbers
+func parseVersion(v string) ([3]int, error) {
+	var parsed [3]int
+	parts := strings.Split(strings.TrimPrefix(v, "v"), ".")
+	if len(parts) != 3 {
+		return parsed, fmt.Errorf("invalid version %q (want MAJOR.MINOR.PATCH)", v)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return parsed, fmt.Errorf("invalid version %q (want MAJOR.MINOR.PATCH)", v)
+		}
+		parsed[i] = n
+	}
+	return parsed, nil
+}
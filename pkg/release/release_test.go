@@ -0,0 +1,96 @@
+package release
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestSignVerify(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	binary := []byte("relay binary")
+	sum := sha256.Sum256(binary)
+
+	signed, err := Sign(&Manifest{
+		Version:    "1.2.0",
+		MinVersion: "1.1.3",
+		Binaries: map[string]Binary{
+			"linux/amd64": {URL: "https://example.com/relay", SHA256: hex.EncodeToString(sum[:])},
+		},
+	}, priv)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	// Round trip through the published (indented) JSON
+	data, _ := json.MarshalIndent(signed, "", "  ")
+	var published SignedManifest
+	if err := json.Unmarshal(data, &published); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	m, err := published.Verify(pub)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if m.Version != "1.2.0" || m.MinVersion != "1.1.3" {
+		t.Errorf("Verify() = %+v", m)
+	}
+
+	bin, err := m.Binary("linux", "amd64")
+	if err != nil {
+		t.Fatalf("Binary() error = %v", err)
+	}
+	if err := bin.Check(binary); err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+	if err := bin.Check([]byte("tampered")); err == nil {
+		t.Error("Check(tampered) expected error, got nil")
+	}
+	if _, err := m.Binary("windows", "arm64"); !errors.Is(err, ErrNoBinary) {
+		t.Errorf("Binary(missing) error = %v, want ErrNoBinary", err)
+	}
+
+	otherPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	if _, err := published.Verify(otherPub); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Verify(other key) error = %v, want ErrBadSignature", err)
+	}
+
+	published.Manifest = []byte(`{"version":"9.9.9","binaries":{}}`)
+	if _, err := published.Verify(pub); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Verify(modified manifest) error = %v, want ErrBadSignature", err)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"v1.2.3", "1.2.3", 0},
+		{"1.0.0", "1.0.1", -1},
+		{"1.10.0", "1.9.9", 1},
+		{"2.0.0", "1.99.99", 1},
+	}
+
+	for _, tt := range tests {
+		got, err := CompareVersions(tt.a, tt.b)
+		if err != nil {
+			t.Fatalf("CompareVersions(%q, %q) error = %v", tt.a, tt.b, err)
+		}
+		if got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"1.0", "1.0.0-rc1", "one.two.three", "1.-1.0"} {
+		if _, err := CompareVersions(bad, "1.0.0"); err == nil {
+			t.Errorf("CompareVersions(%q) expected error, got nil", bad)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package smtpgw
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/network"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// Config maps mail aliases to ZenTalk addresses and lists who may submit mail
+type Config struct {
+	Domain     string            `json:"domain"` // Mail domain the gateway receives for, e.g. zentalk.example.org
+	Users      []UserConfig      `json:"users"`
+	Recipients []RecipientConfig `json:"recipients"`
+}
+
+// UserConfig is an account that may submit mail
+type UserConfig struct {
+	Username     string   `json:"username"`
+	PasswordHash string   `json:"password_hash"` // bcrypt
+	Recipients   []string `json:"recipients"`    // Aliases this user may mail (empty = all)
+}
+
+// RecipientConfig pairs a mail alias with a ZenTalk address
+type RecipientConfig struct {
+	Alias     string `json:"alias"`      // Mail to alias@domain is sent to Address
+	Address   string `json:"address"`    // Hex ZenTalk address
+	PublicKey string `json:"public_key"` // PEM file with the address's public key
+	Email     string `json:"email"`      // Messages Address sends to the gateway are emailed here ("" = none)
+}
+
+// LoadConfig reads a gateway config from a JSON file
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gateway config: %w", err)
+	}
+
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse gateway config: %w", err)
+	}
+	return &config, nil
+}
+
+// Sender sends text messages into ZenTalk
+type Sender interface {
+	SendText(to protocol.Address, publicKey *rsa.PublicKey, text string) error
+}
+
+// Mailer sends outbound email
+type Mailer interface {
+	SendMail(from string, to []string, msg []byte) error
+}
+
+// Gateway converts between email and ZenTalk messages
+// It is the Backend of the SMTP server; its HandleDirectMessage is the
+// gateway client's OnMessageReceived callback.
+type Gateway struct {
+	domain  string
+	users   map[string]*UserConfig
+	aliases map[string]*recipient
+	byAddr  map[protocol.Address]*recipient
+
+	sender Sender
+	mailer Mailer // nil = no outbound email
+}
+
+// recipient is a parsed RecipientConfig
+type recipient struct {
+	alias     string
+	address   protocol.Address
+	publicKey *rsa.PublicKey
+	email     string
+}
+
+// NewGateway loads the recipients' public keys and creates a gateway
+func NewGateway(config *Config, sender Sender, mailer Mailer) (*Gateway, error) {
+	if config.Domain == "" {
+		return nil, fmt.Errorf("gateway config has no domain")
+	}
+
+	g := &Gateway{
+		domain:  strings.ToLower(config.Domain),
+		users:   make(map[string]*UserConfig),
+		aliases: make(map[string]*recipient),
+		byAddr:  make(map[protocol.Address]*recipient),
+		sender:  sender,
+		mailer:  mailer,
+	}
+
+	for i := range config.Users {
+		user := &config.Users[i]
+		if user.Username == "" || user.PasswordHash == "" {
+			return nil, fmt.Errorf("user %q: username and password_hash are required", user.Username)
+		}
+		g.users[user.Username] = user
+	}
+
+	for _, rc := range config.Recipients {
+		r := &recipient{alias: strings.ToLower(rc.Alias), email: rc.Email}
+		if r.alias == "" {
+			return nil, fmt.Errorf("recipient %s: alias is required", rc.Address)
+		}
+
+		addrBytes, err := hex.DecodeString(strings.TrimPrefix(rc.Address, "0x"))
+		if err != nil || len(addrBytes) != len(r.address) {
+			return nil, fmt.Errorf("recipient %s: invalid address %q", rc.Alias, rc.Address)
+		}
+		copy(r.address[:], addrBytes)
+
+		pemData, err := crypto.LoadKeyFromFile(rc.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("recipient %s: %w", rc.Alias, err)
+		}
+		if r.publicKey, err = crypto.ImportPublicKeyPEM(pemData); err != nil {
+			return nil, fmt.Errorf("recipient %s: %w", rc.Alias, err)
+		}
+
+		g.aliases[r.alias] = r
+		g.byAddr[r.address] = r
+	}
+
+	return g, nil
+}
+
+// Authenticate checks a submitter's password
+func (g *Gateway) Authenticate(username, password string) bool {
+	user, ok := g.users[username]
+	if !ok {
+		// Spend the same time as a real check so usernames can't be probed
+		bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) == nil
+}
+
+// CheckRecipient accepts mapped aliases the submitter is allowed to mail
+func (g *Gateway) CheckRecipient(username, rcpt string) error {
+	r, ok := g.resolve(rcpt)
+	if !ok {
+		return &SMTPError{Code: 550, Message: "No such recipient"}
+	}
+
+	user := g.users[username]
+	if user == nil || len(user.Recipients) == 0 {
+		return nil
+	}
+	for _, alias := range user.Recipients {
+		if strings.EqualFold(alias, r.alias) {
+			return nil
+		}
+	}
+	return &SMTPError{Code: 550, Message: "Not allowed to mail this recipient"}
+}
+
+// Deliver sends an accepted email to each recipient's ZenTalk address
+func (g *Gateway) Deliver(env *Envelope) error {
+	m, err := parseMail(env.Data)
+	if err != nil {
+		return &SMTPError{Code: 554, Message: err.Error()}
+	}
+
+	for _, rcpt := range env.To {
+		r, ok := g.resolve(rcpt)
+		if !ok {
+			continue
+		}
+
+		text := formatInbound(m, env.From, maxTextBytes(r.publicKey))
+		if err := g.sender.SendText(r.address, r.publicKey, text); err != nil {
+			return fmt.Errorf("send to %s: %w", r.alias, err)
+		}
+		log.Printf("📮 Mail from %s (%s) sent to %s (%x)", env.From, env.Username, r.alias, r.address[:8])
+	}
+	return nil
+}
+
+// HandleDirectMessage emails a text message from a mapped address to its mailbox
+// Sending happens in the background so a slow mail server can't stall the client.
+func (g *Gateway) HandleDirectMessage(msg *protocol.DirectMessage) {
+	r, ok := g.byAddr[msg.From]
+	if !ok || r.email == "" || g.mailer == nil {
+		return
+	}
+	if msg.ContentType != protocol.ContentTypeText {
+		return
+	}
+
+	from := r.alias + "@" + g.domain
+	text := string(msg.Content)
+	subject, _, _ := strings.Cut(text, "\n")
+	subject = truncateUTF8(subject, 78)
+	email := buildOutbound(from, r.email, subject, text, time.UnixMilli(int64(msg.Timestamp)))
+
+	go func() {
+		if err := g.mailer.SendMail(from, []string{r.email}, email); err != nil {
+			log.Printf("⚠️  Failed to email message from %s to %s: %v", r.alias, r.email, err)
+			return
+		}
+		log.Printf("📮 Message from %s emailed to %s", r.alias, r.email)
+	}()
+}
+
+// resolve maps "alias@domain" to a recipient
+func (g *Gateway) resolve(rcpt string) (*recipient, bool) {
+	local, domain, ok := strings.Cut(strings.ToLower(rcpt), "@")
+	if !ok || domain != g.domain {
+		return nil, false
+	}
+	r, ok := g.aliases[local]
+	return r, ok
+}
+
+// maxTextBytes is the longest text that fits a direct message to the key
+// Direct messages are RSA-OAEP encrypted in one block, so long emails are cut.
+func maxTextBytes(publicKey *rsa.PublicKey) int {
+	overhead := len((&protocol.DirectMessage{}).Encode())
+	return publicKey.Size() - 2*sha256.Size - 2 - overhead
+}
+
+var (
+	dummyHashOnce  sync.Once
+	dummyHashValue []byte
+)
+
+// dummyHash is a bcrypt hash compared against for unknown usernames
+func dummyHash() []byte {
+	dummyHashOnce.Do(func() {
+		dummyHashValue, _ = bcrypt.GenerateFromPassword([]byte("unknown user"), bcrypt.DefaultCost)
+	})
+	return dummyHashValue
+}
+
+// ClientSender sends through a connected ZenTalk client
+type ClientSender struct {
+	client *network.Client
+	relays []*crypto.RelayInfo // Fixed onion path; empty = the client's route policy
+}
+
+// NewClientSender wraps a client; relays may be nil to use its route policy
+func NewClientSender(client *network.Client, relays []*crypto.RelayInfo) *ClientSender {
+	return &ClientSender{client: client, relays: relays}
+}
+
+// SendText sends a text message
+func (s *ClientSender) SendText(to protocol.Address, publicKey *rsa.PublicKey, text string) error {
+	path := s.relays
+	if len(path) == 0 {
+		var err error
+		if path, err = s.client.BuildPolicyRelayPath(); err != nil {
+			return err
+		}
+	}
+	return s.client.SendTextMessage(to, publicKey, text, path)
+}
+
+// SMTPMailer sends email through an SMTP relay
+type SMTPMailer struct {
+	Addr string    // host:port
+	Auth smtp.Auth // nil = no authentication
+}
+
+// SendMail sends msg with net/smtp, upgrading to TLS when the server offers it
+func (m *SMTPMailer) SendMail(from string, to []string, msg []byte) error {
+	return smtp.SendMail(m.Addr, m.Auth, from, to, msg)
+}
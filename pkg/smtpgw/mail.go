@@ -0,0 +1,160 @@
+package smtpgw
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var ErrNoTextPart = errors.New("message has no text/plain part")
+
+// maxMultipartDepth bounds nesting of multipart bodies
+const maxMultipartDepth = 5
+
+// inboundMail is the part of an email forwarded to ZenTalk
+type inboundMail struct {
+	From    string
+	Subject string
+	Body    string
+}
+
+// parseMail extracts the sender, subject and plain-text body of a message
+func parseMail(data []byte) (*inboundMail, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid message: %w", err)
+	}
+
+	body, err := textPart(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &inboundMail{
+		From:    decodeHeader(msg.Header.Get("From")),
+		Subject: decodeHeader(msg.Header.Get("Subject")),
+		Body:    body,
+	}, nil
+}
+
+// textPart returns the first text/plain body in a (possibly multipart) entity
+func textPart(contentType, encoding string, r io.Reader, depth int) (string, error) {
+	mediaType := "text/plain"
+	var params map[string]string
+	if contentType != "" {
+		var err error
+		if mediaType, params, err = mime.ParseMediaType(contentType); err != nil {
+			return "", fmt.Errorf("invalid Content-Type: %w", err)
+		}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		if depth >= maxMultipartDepth || params["boundary"] == "" {
+			return "", ErrNoTextPart
+		}
+		mr := multipart.NewReader(r, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return "", ErrNoTextPart
+			}
+			if err != nil {
+				return "", fmt.Errorf("invalid multipart body: %w", err)
+			}
+			// multipart decodes quoted-printable parts itself and drops the header
+			text, err := textPart(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part, depth+1)
+			if err == nil {
+				return text, nil
+			}
+		}
+	}
+
+	if mediaType != "text/plain" {
+		return "", ErrNoTextPart
+	}
+
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		r = quotedprintable.NewReader(r)
+	case "base64":
+		r = base64.NewDecoder(base64.StdEncoding, r) // Skips line breaks
+	}
+
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("invalid body encoding: %w", err)
+	}
+	text := strings.ReplaceAll(string(body), "\r\n", "\n")
+	return strings.TrimSpace(strings.ToValidUTF8(text, "�")), nil
+}
+
+// decodeHeader decodes RFC 2047 encoded words, falling back to the raw value
+func decodeHeader(value string) string {
+	decoded, err := new(mime.WordDecoder).DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+// formatInbound renders an email as a ZenTalk text message of at most maxBytes
+func formatInbound(m *inboundMail, envelopeFrom string, maxBytes int) string {
+	from := m.From
+	if from == "" {
+		from = envelopeFrom
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "📧 %s\n", from)
+	if m.Subject != "" {
+		fmt.Fprintf(&b, "%s\n", m.Subject)
+	}
+	if m.Body != "" {
+		fmt.Fprintf(&b, "\n%s", m.Body)
+	}
+	return truncateUTF8(strings.TrimRight(b.String(), "\n"), maxBytes)
+}
+
+// buildOutbound renders a ZenTalk message as a plain-text email
+func buildOutbound(from, to, subject, text string, now time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&b, "Content-Type: text/plain; charset=utf-8\r\n")
+	fmt.Fprintf(&b, "Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	qp := quotedprintable.NewWriter(&b)
+	qp.Write([]byte(strings.ReplaceAll(text, "\n", "\r\n")))
+	qp.Close()
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
+
+// truncateUTF8 shortens s to at most maxBytes without splitting a character
+func truncateUTF8(s string, maxBytes int) string {
+	if len(s) <= maxBytes {
+		return s
+	}
+
+	const ellipsis = "…"
+	cut := maxBytes - len(ellipsis)
+	if cut <= 0 {
+		return ""
+	}
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + ellipsis
+}
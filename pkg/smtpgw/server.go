@@ -0,0 +1,535 @@
+// Package smtpgw bridges email and ZenTalk for alerting and notifications
+//
+// Inbound, an SMTP submission server accepts mail from authenticated systems
+// (monitoring, CI, ...) and sends it as a DirectMessage to the ZenTalk address
+// mapped to each recipient. Outbound, messages that mapped users send to the
+// gateway's own address are emailed to their configured mailbox.
+//
+// The server implements the subset of SMTP that submission clients use: EHLO,
+// STARTTLS, AUTH PLAIN/LOGIN, MAIL, RCPT, DATA, RSET, NOOP and QUIT.
+package smtpgw
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// commandTimeout is how long a client may take to send one command
+	commandTimeout = 5 * time.Minute
+
+	// maxLineLength bounds a command line (RFC 5321 allows 512 bytes)
+	maxLineLength = 4096
+
+	// maxAuthFailures closes a connection after this many failed AUTH attempts
+	maxAuthFailures = 3
+)
+
+// ServerConfig controls an SMTP server's limits and TLS
+type ServerConfig struct {
+	Hostname          string      // Announced in the greeting and EHLO reply
+	TLSConfig         *tls.Config // Enables STARTTLS (nil = plain connections only)
+	AllowInsecureAuth bool        // Accept AUTH before STARTTLS (loopback or tests only)
+	MaxMessageBytes   int         // Largest accepted message
+	MaxRecipients     int         // Recipients per message
+}
+
+// DefaultServerConfig returns limits suited to alert and notification mail
+func DefaultServerConfig() ServerConfig {
+	return ServerConfig{
+		Hostname:        "localhost",
+		MaxMessageBytes: 1 << 20,
+		MaxRecipients:   50,
+	}
+}
+
+// Envelope is one accepted message
+type Envelope struct {
+	Username string   // Authenticated submitter
+	From     string   // MAIL FROM address
+	To       []string // RCPT TO addresses
+	Data     []byte   // Message as received (headers and body)
+}
+
+// Backend authenticates submitters and delivers their messages
+type Backend interface {
+	Authenticate(username, password string) bool
+	CheckRecipient(username, rcpt string) error
+	Deliver(env *Envelope) error
+}
+
+// SMTPError is a reply a Backend returns to send a specific code to the client
+// Other errors are reported as 550 from CheckRecipient and 451 from Deliver.
+type SMTPError struct {
+	Code    int
+	Message string
+}
+
+func (e *SMTPError) Error() string {
+	return fmt.Sprintf("%d %s", e.Code, e.Message)
+}
+
+// Server is an SMTP submission server
+type Server struct {
+	backend Backend
+	config  ServerConfig
+
+	listeners map[net.Listener]struct{}
+	closed    bool
+	wg        sync.WaitGroup
+	mu        sync.Mutex
+}
+
+// NewServer creates a server that hands accepted mail to backend
+func NewServer(backend Backend, config ServerConfig) *Server {
+	return &Server{
+		backend:   backend,
+		config:    config,
+		listeners: make(map[net.Listener]struct{}),
+	}
+}
+
+// ListenAndServe listens on addr and serves until Close
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l until Close
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return net.ErrClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+
+	log.Printf("📮 SMTP gateway listening on %s", l.Addr())
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.serveConn(conn)
+		}()
+	}
+}
+
+// Close stops accepting connections and waits for open sessions to finish
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
+
+// session is one client connection
+type session struct {
+	server *Server
+	conn   net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+
+	tls          bool
+	helo         bool
+	username     string // Set once authenticated
+	authFailures int
+
+	from string
+	to   []string
+}
+
+// serveConn runs the SMTP dialogue on one connection
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	sess := &session{server: s, conn: conn}
+	sess.setConn(conn)
+	_, sess.tls = conn.(*tls.Conn)
+
+	sess.reply(220, s.config.Hostname+" ZenTalk SMTP gateway ready")
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(commandTimeout))
+		line, err := sess.readLine()
+		if err != nil {
+			if errors.Is(err, bufio.ErrBufferFull) {
+				sess.reply(500, "Line too long")
+			}
+			return
+		}
+
+		verb, arg, _ := strings.Cut(line, " ")
+		if !sess.handle(strings.ToUpper(verb), strings.TrimSpace(arg)) {
+			return
+		}
+	}
+}
+
+// setConn (re)creates the buffered reader and writer, e.g. after STARTTLS
+func (sess *session) setConn(conn net.Conn) {
+	sess.conn = conn
+	sess.reader = bufio.NewReaderSize(conn, maxLineLength)
+	sess.writer = bufio.NewWriter(conn)
+}
+
+// readLine reads one CRLF-terminated line without the line ending
+func (sess *session) readLine() (string, error) {
+	line, err := sess.reader.ReadSlice('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+// reply sends a single-line reply
+func (sess *session) reply(code int, text string) {
+	fmt.Fprintf(sess.writer, "%d %s\r\n", code, text)
+	sess.writer.Flush()
+}
+
+// replyLines sends a multi-line reply
+func (sess *session) replyLines(code int, lines []string) {
+	for i, line := range lines {
+		sep := "-"
+		if i == len(lines)-1 {
+			sep = " "
+		}
+		fmt.Fprintf(sess.writer, "%d%s%s\r\n", code, sep, line)
+	}
+	sess.writer.Flush()
+}
+
+// replyError sends a backend error, using its code if it is an *SMTPError
+func (sess *session) replyError(err error, defaultCode int, defaultText string) {
+	var smtpErr *SMTPError
+	if errors.As(err, &smtpErr) {
+		sess.reply(smtpErr.Code, smtpErr.Message)
+		return
+	}
+	sess.reply(defaultCode, defaultText)
+}
+
+// handle runs one command; false closes the connection
+func (sess *session) handle(verb, arg string) bool {
+	switch verb {
+	case "EHLO", "HELO":
+		sess.helo = true
+		sess.resetTransaction()
+		if verb == "HELO" {
+			sess.reply(250, sess.server.config.Hostname)
+			return true
+		}
+		sess.replyLines(250, sess.extensions())
+
+	case "STARTTLS":
+		return sess.startTLS()
+
+	case "AUTH":
+		return sess.auth(arg)
+
+	case "MAIL":
+		sess.mail(arg)
+
+	case "RCPT":
+		sess.rcpt(arg)
+
+	case "DATA":
+		return sess.data()
+
+	case "RSET":
+		sess.resetTransaction()
+		sess.reply(250, "OK")
+
+	case "NOOP":
+		sess.reply(250, "OK")
+
+	case "QUIT":
+		sess.reply(221, "Bye")
+		return false
+
+	default:
+		sess.reply(502, "Command not implemented")
+	}
+	return true
+}
+
+// extensions lists the EHLO keywords available in the current state
+func (sess *session) extensions() []string {
+	config := sess.server.config
+	lines := []string{config.Hostname, "8BITMIME", "PIPELINING", fmt.Sprintf("SIZE %d", config.MaxMessageBytes)}
+	if config.TLSConfig != nil && !sess.tls {
+		lines = append(lines, "STARTTLS")
+	}
+	if sess.tls || config.AllowInsecureAuth {
+		lines = append(lines, "AUTH PLAIN LOGIN")
+	}
+	return lines
+}
+
+// startTLS upgrades the connection; state from before the upgrade is discarded
+func (sess *session) startTLS() bool {
+	config := sess.server.config.TLSConfig
+	if config == nil || sess.tls {
+		sess.reply(502, "STARTTLS not available")
+		return true
+	}
+
+	sess.reply(220, "Ready to start TLS")
+	tlsConn := tls.Server(sess.conn, config)
+	if err := tlsConn.Handshake(); err != nil {
+		log.Printf("⚠️  SMTP TLS handshake with %s failed: %v", sess.conn.RemoteAddr(), err)
+		return false
+	}
+
+	sess.setConn(tlsConn)
+	sess.tls = true
+	sess.helo = false
+	sess.username = ""
+	sess.resetTransaction()
+	return true
+}
+
+// auth handles AUTH PLAIN and AUTH LOGIN
+func (sess *session) auth(arg string) bool {
+	if !sess.helo {
+		sess.reply(503, "Send EHLO first")
+		return true
+	}
+	if sess.username != "" {
+		sess.reply(503, "Already authenticated")
+		return true
+	}
+	if !sess.tls && !sess.server.config.AllowInsecureAuth {
+		sess.reply(538, "Encryption required for requested authentication mechanism")
+		return true
+	}
+
+	mechanism, initial, _ := strings.Cut(arg, " ")
+	var username, password string
+	var ok bool
+
+	switch strings.ToUpper(mechanism) {
+	case "PLAIN":
+		// PLAIN: base64("authzid\x00username\x00password")
+		if initial == "" {
+			if initial, ok = sess.challenge(""); !ok {
+				return false
+			}
+		}
+		decoded, err := base64.StdEncoding.DecodeString(initial)
+		parts := strings.Split(string(decoded), "\x00")
+		if err != nil || len(parts) != 3 {
+			sess.reply(501, "Malformed AUTH PLAIN response")
+			return true
+		}
+		username, password = parts[1], parts[2]
+
+	case "LOGIN":
+		if username, ok = sess.challengeDecoded("VXNlcm5hbWU6"); !ok { // "Username:"
+			return false
+		}
+		if password, ok = sess.challengeDecoded("UGFzc3dvcmQ6"); !ok { // "Password:"
+			return false
+		}
+
+	default:
+		sess.reply(504, "Unrecognized authentication mechanism")
+		return true
+	}
+
+	if !sess.server.backend.Authenticate(username, password) {
+		sess.authFailures++
+		log.Printf("🚫 SMTP authentication failed for %q from %s", username, sess.conn.RemoteAddr())
+		sess.reply(535, "Authentication credentials invalid")
+		return sess.authFailures < maxAuthFailures
+	}
+
+	sess.username = username
+	sess.reply(235, "Authentication succeeded")
+	return true
+}
+
+// challenge sends a 334 prompt and returns the client's raw response
+func (sess *session) challenge(prompt string) (string, bool) {
+	sess.reply(334, prompt)
+	line, err := sess.readLine()
+	if err != nil {
+		return "", false
+	}
+	if line == "*" {
+		sess.reply(501, "Authentication cancelled")
+		return "", false
+	}
+	return line, true
+}
+
+// challengeDecoded sends a 334 prompt and base64-decodes the response
+func (sess *session) challengeDecoded(prompt string) (string, bool) {
+	line, ok := sess.challenge(prompt)
+	if !ok {
+		return "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(line)
+	if err != nil {
+		sess.reply(501, "Malformed base64 response")
+		return "", false
+	}
+	return string(decoded), true
+}
+
+// mail starts a transaction
+func (sess *session) mail(arg string) {
+	if sess.username == "" {
+		sess.reply(530, "Authentication required")
+		return
+	}
+	if sess.from != "" {
+		sess.reply(503, "Nested MAIL command")
+		return
+	}
+
+	from, params, ok := parsePath(arg, "FROM:")
+	if !ok {
+		sess.reply(501, "Syntax: MAIL FROM:<address>")
+		return
+	}
+
+	// Refuse oversized messages up front when the client declares the size
+	for _, param := range params {
+		if key, value, _ := strings.Cut(param, "="); strings.EqualFold(key, "SIZE") {
+			var size int
+			if _, err := fmt.Sscan(value, &size); err == nil && size > sess.server.config.MaxMessageBytes {
+				sess.reply(552, "Message size exceeds limit")
+				return
+			}
+		}
+	}
+
+	sess.from = from
+	if sess.from == "" {
+		sess.from = "<>" // Null sender (bounces); still marks the transaction open
+	}
+	sess.reply(250, "OK")
+}
+
+// rcpt adds a recipient the backend accepts for this submitter
+func (sess *session) rcpt(arg string) {
+	if sess.from == "" {
+		sess.reply(503, "Send MAIL first")
+		return
+	}
+	if len(sess.to) >= sess.server.config.MaxRecipients {
+		sess.reply(452, "Too many recipients")
+		return
+	}
+
+	to, _, ok := parsePath(arg, "TO:")
+	if !ok || to == "" {
+		sess.reply(501, "Syntax: RCPT TO:<address>")
+		return
+	}
+
+	if err := sess.server.backend.CheckRecipient(sess.username, to); err != nil {
+		sess.replyError(err, 550, "Recipient not accepted")
+		return
+	}
+
+	sess.to = append(sess.to, to)
+	sess.reply(250, "OK")
+}
+
+// data reads the message and hands it to the backend
+func (sess *session) data() bool {
+	if len(sess.to) == 0 {
+		sess.reply(503, "Send RCPT first")
+		return true
+	}
+	sess.reply(354, "End data with <CR><LF>.<CR><LF>")
+
+	limit := sess.server.config.MaxMessageBytes
+	dot := textproto.NewReader(sess.reader).DotReader()
+	data, err := io.ReadAll(io.LimitReader(dot, int64(limit)+1))
+	if err != nil {
+		return false
+	}
+	if len(data) > limit {
+		// Read the rest so the next command is in sync
+		if _, err := io.Copy(io.Discard, dot); err != nil {
+			return false
+		}
+		sess.resetTransaction()
+		sess.reply(552, "Message size exceeds limit")
+		return true
+	}
+
+	env := &Envelope{
+		Username: sess.username,
+		From:     strings.Trim(sess.from, "<>"),
+		To:       sess.to,
+		Data:     data,
+	}
+	sess.resetTransaction()
+
+	if err := sess.server.backend.Deliver(env); err != nil {
+		log.Printf("⚠️  SMTP delivery for %q failed: %v", env.Username, err)
+		sess.replyError(err, 451, "Requested action aborted: local error in processing")
+		return true
+	}
+
+	sess.reply(250, "OK: queued")
+	return true
+}
+
+// resetTransaction clears the sender and recipients
+func (sess *session) resetTransaction() {
+	sess.from = ""
+	sess.to = nil
+}
+
+// parsePath parses "FROM:<addr> PARAMS..." or "TO:<addr>"
+func parsePath(arg, prefix string) (addr string, params []string, ok bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", nil, false
+	}
+	rest := strings.TrimSpace(arg[len(prefix):])
+	if !strings.HasPrefix(rest, "<") {
+		return "", nil, false
+	}
+	end := strings.IndexByte(rest, '>')
+	if end < 0 {
+		return "", nil, false
+	}
+	return rest[1:end], strings.Fields(rest[end+1:]), true
+}
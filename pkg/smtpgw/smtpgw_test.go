@@ -0,0 +1,176 @@
+package smtpgw
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// recordingSender records sent texts
+type recordingSender struct {
+	texts map[protocol.Address][]string
+	mu    sync.Mutex
+}
+
+func (s *recordingSender) SendText(to protocol.Address, _ *rsa.PublicKey, text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.texts[to] = append(s.texts[to], text)
+	return nil
+}
+
+// recordingMailer records sent emails
+type recordingMailer struct {
+	sent chan []byte
+}
+
+func (m *recordingMailer) SendMail(_ string, _ []string, msg []byte) error {
+	m.sent <- msg
+	return nil
+}
+
+func newTestGateway(t *testing.T) (*Gateway, *recordingSender, *recordingMailer) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubPEM, err := crypto.ExportPublicKeyPEM(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubPath := filepath.Join(t.TempDir(), "oncall.pub")
+	if err := os.WriteFile(pubPath, pubPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &Config{
+		Domain: "zentalk.example.org",
+		Users: []UserConfig{
+			{Username: "alerts", PasswordHash: string(hash), Recipients: []string{"oncall"}},
+		},
+		Recipients: []RecipientConfig{
+			{Alias: "oncall", Address: strings.Repeat("01", 20), PublicKey: pubPath, Email: "ack@example.org"},
+			{Alias: "ceo", Address: strings.Repeat("02", 20), PublicKey: pubPath},
+		},
+	}
+
+	sender := &recordingSender{texts: make(map[protocol.Address][]string)}
+	mailer := &recordingMailer{sent: make(chan []byte, 1)}
+	g, err := NewGateway(config, sender, mailer)
+	if err != nil {
+		t.Fatalf("NewGateway() error = %v", err)
+	}
+	return g, sender, mailer
+}
+
+func TestSMTPSubmission(t *testing.T) {
+	g, sender, _ := newTestGateway(t)
+
+	config := DefaultServerConfig()
+	config.AllowInsecureAuth = true
+	server := NewServer(g, config)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(l)
+	defer server.Close()
+
+	// net/smtp only sends PLAIN credentials in the clear to "localhost"
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	addr := "localhost:" + port
+
+	msg := "From: Alertmanager <am@example.org>\r\n" +
+		"Subject: =?utf-8?q?Disk_full_=E2=9A=A0?=\r\n" +
+		"Content-Type: multipart/alternative; boundary=b1\r\n\r\n" +
+		"--b1\r\nContent-Type: text/html\r\n\r\n<p>html</p>\r\n" +
+		"--b1\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n" +
+		"/var is 99% full on db-1=\r\n.\r\n" +
+		"--b1--\r\n"
+
+	auth := smtp.PlainAuth("", "alerts", "hunter2", "localhost")
+	if err := smtp.SendMail(addr, auth, "am@example.org", []string{"OnCall@zentalk.example.org"}, []byte(msg)); err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+
+	texts := sender.texts[protocol.Address{0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01}]
+	want := "📧 Alertmanager <am@example.org>\nDisk full ⚠\n\n/var is 99% full on db-1."
+	if len(texts) != 1 || texts[0] != want {
+		t.Errorf("sent texts = %q, want %q", texts, want)
+	}
+
+	// Not on the user's recipient list, unknown alias, wrong password
+	err = smtp.SendMail(addr, auth, "am@example.org", []string{"ceo@zentalk.example.org"}, []byte(msg))
+	if err == nil || !strings.Contains(err.Error(), "550") {
+		t.Errorf("SendMail(disallowed recipient) error = %v, want 550", err)
+	}
+	err = smtp.SendMail(addr, auth, "am@example.org", []string{"nobody@zentalk.example.org"}, []byte(msg))
+	if err == nil || !strings.Contains(err.Error(), "550") {
+		t.Errorf("SendMail(unknown recipient) error = %v, want 550", err)
+	}
+	badAuth := smtp.PlainAuth("", "alerts", "wrong", "localhost")
+	err = smtp.SendMail(addr, badAuth, "am@example.org", []string{"oncall@zentalk.example.org"}, []byte(msg))
+	if err == nil || !strings.Contains(err.Error(), "535") {
+		t.Errorf("SendMail(wrong password) error = %v, want 535", err)
+	}
+}
+
+func TestInboundTruncation(t *testing.T) {
+	m := &inboundMail{Subject: "s", Body: strings.Repeat("é", 400)}
+	text := formatInbound(m, "a@example.org", 100)
+	if len(text) > 100 || !strings.HasSuffix(text, "…") || !strings.HasPrefix(text, "📧 a@example.org\ns\n\n") {
+		t.Errorf("formatInbound() = %q (%d bytes)", text, len(text))
+	}
+}
+
+func TestOutboundEmail(t *testing.T) {
+	g, _, mailer := newTestGateway(t)
+
+	var from protocol.Address
+	copy(from[:], []byte(strings.Repeat("\x01", 20)))
+	g.HandleDirectMessage(&protocol.DirectMessage{
+		From:        from,
+		Timestamp:   uint64(time.Now().UnixMilli()),
+		ContentType: protocol.ContentTypeText,
+		Content:     []byte("ack\nlooking into it"),
+	})
+
+	select {
+	case email := <-mailer.sent:
+		s := string(email)
+		if !strings.Contains(s, "From: oncall@zentalk.example.org\r\n") || !strings.Contains(s, "To: ack@example.org\r\n") ||
+			!strings.Contains(s, "Subject: ack\r\n") || !strings.Contains(s, "looking into it") {
+			t.Errorf("email = %q", s)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no email sent")
+	}
+
+	// Addresses without a mailbox are not emailed
+	copy(from[:], []byte(strings.Repeat("\x02", 20)))
+	g.HandleDirectMessage(&protocol.DirectMessage{From: from, ContentType: protocol.ContentTypeText, Content: []byte("hi")})
+	select {
+	case email := <-mailer.sent:
+		t.Errorf("unexpected email %q", email)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
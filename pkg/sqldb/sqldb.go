@@ -0,0 +1,180 @@
+// Package sqldb selects and adapts SQL backends (SQLite or PostgreSQL) for ZenTalk storage
+//
+// Schemas and queries are written once in SQLite syntax with ? placeholders;
+// Translate and Rebind adapt them for PostgreSQL, so migrations are shared
+// across backends instead of maintained twice.
+package sqldb
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Dialect identifies a SQL backend
+type Dialect int
+
+const (
+	// SQLite is the default embedded backend
+	SQLite Dialect = iota
+	// Postgres is for high-volume deployments that outgrow SQLite's single writer
+	Postgres
+)
+
+// String returns the dialect name
+func (d Dialect) String() string {
+	if d == Postgres {
+		return "postgres"
+	}
+	return "sqlite"
+}
+
+// DriverName returns the database/sql driver name for the dialect
+func (d Dialect) DriverName() string {
+	if d == Postgres {
+		return "postgres"
+	}
+	return "sqlite3"
+}
+
+// ParseDSN determines the dialect of a DSN and returns the driver-specific data source
+// postgres:// and postgresql:// URLs select PostgreSQL; sqlite:// URLs and plain
+// file paths select SQLite
+func ParseDSN(dsn string) (Dialect, string) {
+	lower := strings.ToLower(dsn)
+	switch {
+	case strings.HasPrefix(lower, "postgres://"), strings.HasPrefix(lower, "postgresql://"):
+		return Postgres, dsn
+	case strings.HasPrefix(lower, "sqlite://"):
+		return SQLite, dsn[len("sqlite://"):]
+	default:
+		return SQLite, dsn
+	}
+}
+
+// IsPostgresDSN returns true if the DSN selects PostgreSQL
+func IsPostgresDSN(dsn string) bool {
+	d, _ := ParseDSN(dsn)
+	return d == Postgres
+}
+
+// Open opens a database by DSN
+// PostgreSQL connections are verified immediately so a bad DSN fails at startup
+func Open(dsn string) (*sql.DB, Dialect, error) {
+	dialect, source := ParseDSN(dsn)
+
+	db, err := sql.Open(dialect.DriverName(), source)
+	if err != nil {
+		return nil, dialect, fmt.Errorf("failed to open %s database: %w", dialect, err)
+	}
+
+	if dialect == Postgres {
+		if err := db.Ping(); err != nil {
+			db.Close()
+			return nil, dialect, fmt.Errorf("failed to connect to %s database: %w", dialect, err)
+		}
+	}
+
+	return db, dialect, nil
+}
+
+// DialectOf reports the dialect of an open database from its driver
+func DialectOf(db *sql.DB) Dialect {
+	if strings.Contains(fmt.Sprintf("%T", db.Driver()), "pq.") {
+		return Postgres
+	}
+	return SQLite
+}
+
+// Rebind converts ? placeholders to the dialect's placeholder style
+// Question marks inside single-quoted string literals are left alone
+func (d Dialect) Rebind(query string) string {
+	if d != Postgres {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+
+	n := 0
+	inString := false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			inString = !inString
+		case r == '?' && !inString:
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+var (
+	autoIncrementPattern = regexp.MustCompile(`(?i)\bINTEGER\s+PRIMARY\s+KEY\s+AUTOINCREMENT\b`)
+	integerPattern       = regexp.MustCompile(`(?i)\bINTEGER\b`)
+	blobPattern          = regexp.MustCompile(`(?i)\bBLOB\b`)
+	strftimePattern      = regexp.MustCompile(`(?i)\(strftime\('%s',\s*'now'\)\)`)
+)
+
+// Translate adapts SQLite DDL to the dialect
+// Only the constructs used by ZenTalk schemas are handled: AUTOINCREMENT keys,
+// INTEGER (widened to BIGINT for Unix timestamps), BLOB and strftime('%s','now')
+func (d Dialect) Translate(schema string) string {
+	if d != Postgres {
+		return schema
+	}
+
+	schema = autoIncrementPattern.ReplaceAllString(schema, "BIGSERIAL PRIMARY KEY")
+	schema = integerPattern.ReplaceAllString(schema, "BIGINT")
+	schema = blobPattern.ReplaceAllString(schema, "BYTEA")
+	schema = strftimePattern.ReplaceAllString(schema, "(EXTRACT(EPOCH FROM NOW())::BIGINT)")
+	return schema
+}
+
+// TableExists returns true if a table exists
+func TableExists(db *sql.DB, table string) (bool, error) {
+	var query string
+	if DialectOf(db) == Postgres {
+		query = `SELECT table_name FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1`
+	} else {
+		query = `SELECT name FROM sqlite_master WHERE type='table' AND name=?`
+	}
+
+	var name string
+	err := db.QueryRow(query, table).Scan(&name)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ColumnExists returns true if a table has a column
+// Used by migrations that add columns to tables created by older versions.
+func ColumnExists(db *sql.DB, table, column string) (bool, error) {
+	var query string
+	if DialectOf(db) == Postgres {
+		query = `SELECT column_name FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = $1 AND column_name = $2`
+	} else {
+		query = `SELECT name FROM pragma_table_info(?) WHERE name = ?`
+	}
+
+	var name string
+	err := db.QueryRow(query, table, column).Scan(&name)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
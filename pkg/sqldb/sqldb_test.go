@@ -0,0 +1,95 @@
+package sqldb
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseDSN(t *testing.T) {
+	tests := []struct {
+		dsn     string
+		dialect Dialect
+		source  string
+	}{
+		{"postgres://user:pw@db:5432/zentalk", Postgres, "postgres://user:pw@db:5432/zentalk"},
+		{"postgresql://db/zentalk?sslmode=disable", Postgres, "postgresql://db/zentalk?sslmode=disable"},
+		{"sqlite://./data/queue.db", SQLite, "./data/queue.db"},
+		{"./data/chunks.db", SQLite, "./data/chunks.db"},
+	}
+
+	for _, tt := range tests {
+		dialect, source := ParseDSN(tt.dsn)
+		if dialect != tt.dialect || source != tt.source {
+			t.Errorf("ParseDSN(%q) = (%v, %q), want (%v, %q)", tt.dsn, dialect, source, tt.dialect, tt.source)
+		}
+	}
+}
+
+func TestRebind(t *testing.T) {
+	query := `SELECT * FROM t WHERE a = ? AND b = '?' AND c > ?`
+
+	if got := SQLite.Rebind(query); got != query {
+		t.Errorf("SQLite.Rebind() changed query: %q", got)
+	}
+
+	want := `SELECT * FROM t WHERE a = $1 AND b = '?' AND c > $2`
+	if got := Postgres.Rebind(query); got != want {
+		t.Errorf("Postgres.Rebind() = %q, want %q", got, want)
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	schema := `CREATE TABLE t (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		data BLOB NOT NULL,
+		stored_at INTEGER NOT NULL,
+		created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
+	)`
+
+	if got := SQLite.Translate(schema); got != schema {
+		t.Errorf("SQLite.Translate() changed schema: %q", got)
+	}
+
+	got := Postgres.Translate(schema)
+	for _, want := range []string{"BIGSERIAL PRIMARY KEY", "data BYTEA", "stored_at BIGINT", "EXTRACT(EPOCH FROM NOW())::BIGINT"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Postgres.Translate() missing %q in:\n%s", want, got)
+		}
+	}
+	for _, unwanted := range []string{"AUTOINCREMENT", "BLOB", "strftime", "INTEGER"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("Postgres.Translate() left %q in:\n%s", unwanted, got)
+		}
+	}
+}
+
+func TestOpenSQLite(t *testing.T) {
+	db, dialect, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer db.Close()
+
+	if dialect != SQLite || DialectOf(db) != SQLite {
+		t.Errorf("Open() dialect = %v, DialectOf() = %v, want sqlite", dialect, DialectOf(db))
+	}
+
+	if exists, err := TableExists(db, "missing"); err != nil || exists {
+		t.Errorf("TableExists(missing) = %v, %v; want false, nil", exists, err)
+	}
+
+	if _, err := db.Exec(`CREATE TABLE present (id INTEGER)`); err != nil {
+		t.Fatalf("CREATE TABLE error = %v", err)
+	}
+	if exists, err := TableExists(db, "present"); err != nil || !exists {
+		t.Errorf("TableExists(present) = %v, %v; want true, nil", exists, err)
+	}
+
+	if exists, err := ColumnExists(db, "present", "id"); err != nil || !exists {
+		t.Errorf("ColumnExists(present, id) = %v, %v; want true, nil", exists, err)
+	}
+	if exists, err := ColumnExists(db, "present", "missing"); err != nil || exists {
+		t.Errorf("ColumnExists(present, missing) = %v, %v; want false, nil", exists, err)
+	}
+}
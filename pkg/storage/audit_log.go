@@ -0,0 +1,271 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// ===== AUDIT LOG OPERATIONS =====
+// The audit log records that a message was sent or received, never what it
+// said. Each record carries the hash of the one before it and the owner's
+// signature, so an export shows whether records were removed, reordered or
+// edited after the fact. The owner's wallet signs for the signing key, so an
+// export cannot be passed off as someone else's.
+
+// auditExportVersion is bumped whenever the export format or record hash changes
+const auditExportVersion = 2
+
+var (
+	ErrAuditChainBroken = errors.New("audit log chain broken")
+	ErrAuditOwner       = errors.New("audit log key not vouched for by its owner")
+)
+
+// AuditEvent is the kind of event an audit record describes
+type AuditEvent string
+
+const (
+	AuditEventSent     AuditEvent = "sent"
+	AuditEventReceived AuditEvent = "received"
+)
+
+// AuditRecord is one entry in the hash-chained audit log
+type AuditRecord struct {
+	Seq         int64      `json:"seq"`
+	Event       AuditEvent `json:"event"`
+	Peer        string     `json:"peer"`       // Hex address of the other party
+	MessageID   string     `json:"message_id"` // Same ID as the stored message
+	ContentType uint8      `json:"content_type"`
+	Timestamp   int64      `json:"timestamp"` // Unix timestamp (ms) of the event
+	PrevHash    string     `json:"prev_hash"` // Hex hash of the previous record ("" for the first)
+	Hash        string     `json:"hash"`
+	Signature   []byte     `json:"signature"` // Owner's signature over Hash
+}
+
+// AuditExport is an audit log export handed to a compliance system
+type AuditExport struct {
+	Version    int            `json:"version"`
+	ExportedAt int64          `json:"exported_at"`
+	Address    string         `json:"address"`    // Hex address of the log owner
+	PublicKey  []byte         `json:"public_key"` // Owner's RSA public key (PEM)
+	Binding    []byte         `json:"binding"`    // Owner wallet's signature over AuditBindingMessage
+	Records    []*AuditRecord `json:"records"`
+}
+
+// AuditBindingMessage returns the text the wallet of address signs to vouch for
+// the RSA key that signs its audit records
+func AuditBindingMessage(address string, publicKeyPEM []byte) []byte {
+	return []byte(fmt.Sprintf("zentalk-audit-owner|%s|%s", strings.ToLower(address), publicKeyPEM))
+}
+
+// VerifyAuditBinding checks binding is the personal-message (EIP-191) signature
+// of address's wallet over AuditBindingMessage
+func VerifyAuditBinding(address string, publicKeyPEM, binding []byte) error {
+	if len(binding) != ethcrypto.SignatureLength {
+		return fmt.Errorf("%w: no wallet signature", ErrAuditOwner)
+	}
+
+	// Wallets report the recovery ID as 27/28
+	binding = append([]byte(nil), binding...)
+	if binding[ethcrypto.RecoveryIDOffset] >= 27 {
+		binding[ethcrypto.RecoveryIDOffset] -= 27
+	}
+
+	publicKey, err := ethcrypto.SigToPub(accounts.TextHash(AuditBindingMessage(address, publicKeyPEM)), binding)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrAuditOwner, err)
+	}
+	signer := ethcrypto.PubkeyToAddress(*publicKey)
+	if !strings.EqualFold(hex.EncodeToString(signer[:]), strings.TrimPrefix(address, "0x")) {
+		return fmt.Errorf("%w: signed by %s, not %s", ErrAuditOwner, signer.Hex(), address)
+	}
+	return nil
+}
+
+// initAuditLogSchema creates the audit log table
+func (db *MessageDB) initAuditLogSchema() error {
+	schema := `
+	CREATE TABLE IF NOT EXISTS audit_log (
+		seq INTEGER PRIMARY KEY,
+		event TEXT NOT NULL,
+		peer TEXT NOT NULL,
+		message_id TEXT NOT NULL,
+		content_type INTEGER NOT NULL,
+		timestamp INTEGER NOT NULL,
+		prev_hash TEXT NOT NULL,
+		hash TEXT NOT NULL,
+		signature BLOB NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_audit_log_timestamp ON audit_log(timestamp);
+	`
+
+	if _, err := db.db.Exec(schema); err != nil {
+		return fmt.Errorf("failed to create audit log schema: %v", err)
+	}
+	return nil
+}
+
+// AppendAuditRecord chains rec onto the log and signs it with key
+// Seq, PrevHash, Hash and Signature are filled in.
+func (db *MessageDB) AppendAuditRecord(rec *AuditRecord, key *rsa.PrivateKey) error {
+	db.auditMu.Lock()
+	defer db.auditMu.Unlock()
+
+	var lastSeq int64
+	var lastHash string
+	err := db.db.QueryRow(`SELECT seq, hash FROM audit_log ORDER BY seq DESC LIMIT 1`).Scan(&lastSeq, &lastHash)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to read audit log head: %v", err)
+	}
+
+	rec.Seq = lastSeq + 1
+	rec.PrevHash = lastHash
+	hash := rec.computeHash()
+	rec.Hash = hex.EncodeToString(hash)
+
+	rec.Signature, err = crypto.SignData(hash, key)
+	if err != nil {
+		return fmt.Errorf("failed to sign audit record: %v", err)
+	}
+
+	query := `
+		INSERT INTO audit_log (
+			seq, event, peer, message_id, content_type, timestamp, prev_hash, hash, signature
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err = db.db.Exec(
+		query,
+		rec.Seq,
+		string(rec.Event),
+		rec.Peer,
+		rec.MessageID,
+		rec.ContentType,
+		rec.Timestamp,
+		rec.PrevHash,
+		rec.Hash,
+		rec.Signature,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save audit record: %v", err)
+	}
+
+	return nil
+}
+
+// GetAuditRecords returns records with since <= timestamp < until in log order
+// until = 0 means no upper bound
+func (db *MessageDB) GetAuditRecords(since, until int64) ([]*AuditRecord, error) {
+	query := `
+		SELECT seq, event, peer, message_id, content_type, timestamp, prev_hash, hash, signature
+		FROM audit_log
+		WHERE timestamp >= ? AND (? = 0 OR timestamp < ?)
+		ORDER BY seq ASC
+	`
+
+	rows, err := db.db.Query(query, since, until, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %v", err)
+	}
+	defer rows.Close()
+
+	var records []*AuditRecord
+	for rows.Next() {
+		rec := &AuditRecord{}
+		var event string
+		if err := rows.Scan(&rec.Seq, &event, &rec.Peer, &rec.MessageID, &rec.ContentType, &rec.Timestamp, &rec.PrevHash, &rec.Hash, &rec.Signature); err != nil {
+			return nil, fmt.Errorf("failed to scan audit record: %v", err)
+		}
+		rec.Event = AuditEvent(event)
+		records = append(records, rec)
+	}
+
+	return records, rows.Err()
+}
+
+// NewAuditExport wraps records for export
+// binding is the owner's wallet signature from VerifyAuditBinding.
+func NewAuditExport(address string, publicKeyPEM, binding []byte, exportedAt int64, records []*AuditRecord) *AuditExport {
+	return &AuditExport{
+		Version:    auditExportVersion,
+		ExportedAt: exportedAt,
+		Address:    address,
+		PublicKey:  publicKeyPEM,
+		Binding:    binding,
+		Records:    records,
+	}
+}
+
+// Verify checks the key belongs to Address, then every record's hash, signature
+// and link to the record before it
+// An export of a time range starts mid-chain, so the first record's PrevHash is
+// taken as given; a gap or edit anywhere after it is reported.
+func (e *AuditExport) Verify() error {
+	if e.Version != auditExportVersion {
+		return fmt.Errorf("%w of audit export: %d", protocol.ErrUnsupportedVersion, e.Version)
+	}
+
+	if err := VerifyAuditBinding(e.Address, e.PublicKey, e.Binding); err != nil {
+		return err
+	}
+
+	pubKey, err := crypto.ImportPublicKeyPEM(e.PublicKey)
+	if err != nil {
+		return fmt.Errorf("invalid public key in audit export: %v", err)
+	}
+
+	for i, rec := range e.Records {
+		if i > 0 {
+			prev := e.Records[i-1]
+			if rec.Seq != prev.Seq+1 || rec.PrevHash != prev.Hash {
+				return fmt.Errorf("%w: record %d does not follow record %d", ErrAuditChainBroken, rec.Seq, prev.Seq)
+			}
+		}
+
+		hash := rec.computeHash()
+		stored, err := hex.DecodeString(rec.Hash)
+		if err != nil || !bytes.Equal(hash, stored) {
+			return fmt.Errorf("%w: record %d was modified", ErrAuditChainBroken, rec.Seq)
+		}
+
+		if err := crypto.VerifySignature(hash, rec.Signature, pubKey); err != nil {
+			return fmt.Errorf("%w: bad signature on record %d", ErrAuditChainBroken, rec.Seq)
+		}
+	}
+
+	return nil
+}
+
+// computeHash hashes the record's fields and the previous hash
+// Strings are length-prefixed so no two records encode to the same bytes.
+func (rec *AuditRecord) computeHash() []byte {
+	var buf bytes.Buffer
+	writeString := func(s string) {
+		binary.Write(&buf, binary.BigEndian, uint32(len(s)))
+		buf.WriteString(s)
+	}
+
+	binary.Write(&buf, binary.BigEndian, rec.Seq)
+	writeString(string(rec.Event))
+	writeString(rec.Peer)
+	writeString(rec.MessageID)
+	buf.WriteByte(rec.ContentType)
+	binary.Write(&buf, binary.BigEndian, rec.Timestamp)
+	writeString(rec.PrevHash)
+
+	hash := sha256.Sum256(buf.Bytes())
+	return hash[:]
+}
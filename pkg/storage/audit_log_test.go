@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+)
+
+func newAuditTestDB(t *testing.T) (*MessageDB, *rsa.PrivateKey) {
+	t.Helper()
+
+	db, err := NewMessageDB(filepath.Join(t.TempDir(), "audit.db"), "password")
+	if err != nil {
+		t.Fatalf("NewMessageDB() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, key
+}
+
+// auditOwner returns the hex address of wallet and its binding to key
+func auditOwner(t *testing.T, wallet *ecdsa.PrivateKey, key *rsa.PrivateKey) (string, []byte, []byte) {
+	t.Helper()
+
+	pubKeyPEM, err := crypto.ExportPublicKeyPEM(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ethcrypto.PubkeyToAddress(wallet.PublicKey)
+	address := hex.EncodeToString(addr[:])
+	binding, err := ethcrypto.Sign(accounts.TextHash(AuditBindingMessage(address, pubKeyPEM)), wallet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return address, pubKeyPEM, binding
+}
+
+func newAuditWallet(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	wallet, err := ethcrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wallet
+}
+
+func TestAuditLogChain(t *testing.T) {
+	db, key := newAuditTestDB(t)
+
+	for i, event := range []AuditEvent{AuditEventSent, AuditEventReceived, AuditEventSent} {
+		rec := &AuditRecord{Event: event, Peer: "abcd", MessageID: "m", Timestamp: int64(1000 + i)}
+		if err := db.AppendAuditRecord(rec, key); err != nil {
+			t.Fatalf("AppendAuditRecord() error = %v", err)
+		}
+	}
+
+	records, err := db.GetAuditRecords(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[0].PrevHash != "" || records[2].PrevHash != records[1].Hash {
+		t.Fatalf("GetAuditRecords() = %+v", records)
+	}
+
+	address, pubKeyPEM, binding := auditOwner(t, newAuditWallet(t), key)
+	if err := NewAuditExport(address, pubKeyPEM, binding, 0, records).Verify(); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+
+	// A time range starts mid-chain and still verifies
+	if err := NewAuditExport(address, pubKeyPEM, binding, 0, records[1:]).Verify(); err != nil {
+		t.Errorf("Verify() on a range error = %v", err)
+	}
+}
+
+func TestAuditExportOwner(t *testing.T) {
+	db, key := newAuditTestDB(t)
+	rec := &AuditRecord{Event: AuditEventSent, Peer: "abcd", MessageID: "m", Timestamp: 1}
+	if err := db.AppendAuditRecord(rec, key); err != nil {
+		t.Fatal(err)
+	}
+	records, err := db.GetAuditRecords(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	address, pubKeyPEM, binding := auditOwner(t, newAuditWallet(t), key)
+	other, _, otherBinding := auditOwner(t, newAuditWallet(t), key)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherPEM, _ := auditOwner(t, newAuditWallet(t), otherKey)
+
+	tests := []struct {
+		name      string
+		address   string
+		publicKey []byte
+		binding   []byte
+	}{
+		{"no binding", address, pubKeyPEM, nil},
+		{"claims another address", other, pubKeyPEM, binding},
+		{"another wallet's binding", address, pubKeyPEM, otherBinding},
+		{"binding for another key", address, otherPEM, binding},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewAuditExport(tt.address, tt.publicKey, tt.binding, 0, records).Verify()
+			if !errors.Is(err, ErrAuditOwner) {
+				t.Errorf("Verify() error = %v, want ErrAuditOwner", err)
+			}
+		})
+	}
+}
+
+func TestAuditLogTamperDetected(t *testing.T) {
+	db, key := newAuditTestDB(t)
+
+	for i := 0; i < 3; i++ {
+		rec := &AuditRecord{Event: AuditEventReceived, Peer: "abcd", MessageID: "m", Timestamp: int64(i)}
+		if err := db.AppendAuditRecord(rec, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	address, pubKeyPEM, binding := auditOwner(t, newAuditWallet(t), key)
+
+	tests := []struct {
+		name   string
+		tamper func([]*AuditRecord) []*AuditRecord
+	}{
+		{"edited", func(r []*AuditRecord) []*AuditRecord { r[1].Peer = "ffff"; return r }},
+		{"removed", func(r []*AuditRecord) []*AuditRecord { return []*AuditRecord{r[0], r[2]} }},
+		{"reordered", func(r []*AuditRecord) []*AuditRecord { return []*AuditRecord{r[1], r[0], r[2]} }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := db.GetAuditRecords(0, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = NewAuditExport(address, pubKeyPEM, binding, 0, tt.tamper(records)).Verify()
+			if !errors.Is(err, ErrAuditChainBroken) {
+				t.Errorf("Verify() error = %v, want ErrAuditChainBroken", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"fmt"
+)
+
+// ===== BANDWIDTH USAGE OPERATIONS =====
+// Clients count the bytes they send and receive so users on metered
+// connections can see where their data goes. Counts are kept per UTC day,
+// conversation and category; the client adds to them in batches.
+
+// UsageDayFormat is the layout of UsageRecord.Day
+const UsageDayFormat = "2006-01-02"
+
+// UsageCategory groups traffic for bandwidth accounting
+type UsageCategory string
+
+const (
+	UsageMessages UsageCategory = "messages" // Direct and group messages, including retransmissions
+	UsageMedia    UsageCategory = "media"    // Media messages and MeshStorage uploads and downloads
+	UsageControl  UsageCategory = "control"  // Typing, receipts, presence, ACKs, pings, key bundles and other protocol traffic
+)
+
+// UsageRecord is the traffic of one category in one conversation on one day
+type UsageRecord struct {
+	Day           string // UTC day (UsageDayFormat)
+	Conversation  string // Conversation ID (empty = traffic not tied to a conversation)
+	Category      UsageCategory
+	BytesSent     int64
+	BytesReceived int64
+}
+
+// initBandwidthUsageSchema creates the bandwidth usage table
+func (db *MessageDB) initBandwidthUsageSchema() error {
+	schema := `
+	CREATE TABLE IF NOT EXISTS bandwidth_usage (
+		day TEXT NOT NULL,
+		conversation_id TEXT NOT NULL,
+		category TEXT NOT NULL,
+		bytes_sent INTEGER NOT NULL DEFAULT 0,
+		bytes_received INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (day, conversation_id, category)
+	);
+	`
+
+	if _, err := db.db.Exec(schema); err != nil {
+		return fmt.Errorf("failed to create bandwidth usage schema: %v", err)
+	}
+	return nil
+}
+
+// AddBandwidthUsage adds byte counts to the stored totals in one transaction
+func (db *MessageDB) AddBandwidthUsage(records []*UsageRecord) error {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO bandwidth_usage (day, conversation_id, category, bytes_sent, bytes_received)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (day, conversation_id, category) DO UPDATE SET
+			bytes_sent = bytes_sent + excluded.bytes_sent,
+			bytes_received = bytes_received + excluded.bytes_received
+	`
+	for _, r := range records {
+		if _, err := tx.Exec(query, r.Day, r.Conversation, string(r.Category), r.BytesSent, r.BytesReceived); err != nil {
+			return fmt.Errorf("failed to add bandwidth usage: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit bandwidth usage: %v", err)
+	}
+	return nil
+}
+
+// GetBandwidthUsage returns the usage recorded from one day to another (inclusive), oldest first
+func (db *MessageDB) GetBandwidthUsage(fromDay, toDay string) ([]*UsageRecord, error) {
+	query := `
+		SELECT day, conversation_id, category, bytes_sent, bytes_received
+		FROM bandwidth_usage
+		WHERE day >= ? AND day <= ?
+		ORDER BY day ASC, conversation_id ASC, category ASC
+	`
+
+	rows, err := db.db.Query(query, fromDay, toDay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bandwidth usage: %v", err)
+	}
+	defer rows.Close()
+
+	var records []*UsageRecord
+	for rows.Next() {
+		r := &UsageRecord{}
+		var category string
+		if err := rows.Scan(&r.Day, &r.Conversation, &category, &r.BytesSent, &r.BytesReceived); err != nil {
+			return nil, fmt.Errorf("failed to scan bandwidth usage: %v", err)
+		}
+		r.Category = UsageCategory(category)
+		records = append(records, r)
+	}
+
+	return records, rows.Err()
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestBandwidthUsage(t *testing.T) {
+	db, err := NewMessageDB(filepath.Join(t.TempDir(), "usage.db"), "password")
+	if err != nil {
+		t.Fatalf("NewMessageDB() error = %v", err)
+	}
+	defer db.Close()
+
+	batches := [][]*UsageRecord{
+		{
+			{Day: "2026-03-01", Conversation: "a-b", Category: UsageMessages, BytesSent: 100, BytesReceived: 10},
+			{Day: "2026-03-02", Conversation: "", Category: UsageControl, BytesSent: 5},
+		},
+		{
+			{Day: "2026-03-01", Conversation: "a-b", Category: UsageMessages, BytesSent: 50, BytesReceived: 1},
+			{Day: "2026-03-03", Conversation: "group-ff", Category: UsageMedia, BytesReceived: 4096},
+		},
+	}
+	for _, batch := range batches {
+		if err := db.AddBandwidthUsage(batch); err != nil {
+			t.Fatalf("AddBandwidthUsage() error = %v", err)
+		}
+	}
+
+	records, err := db.GetBandwidthUsage("2026-03-01", "2026-03-02")
+	if err != nil {
+		t.Fatalf("GetBandwidthUsage() error = %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("GetBandwidthUsage() = %d records, want 2", len(records))
+	}
+	want := UsageRecord{Day: "2026-03-01", Conversation: "a-b", Category: UsageMessages, BytesSent: 150, BytesReceived: 11}
+	if *records[0] != want {
+		t.Errorf("first record = %+v, want %+v", *records[0], want)
+	}
+	if records[1].Day != "2026-03-02" || records[1].Category != UsageControl {
+		t.Errorf("second record = %+v", *records[1])
+	}
+}
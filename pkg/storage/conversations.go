@@ -91,3 +91,24 @@ func (db *MessageDB) MarkConversationRead(conversationID string) error {
 	_, err := db.db.Exec(query, conversationID)
 	return err
 }
+
+// MarkConversationReadUntil marks messages up to a timestamp (ms) as read
+// Incoming messages newer than until stay unread.
+func (db *MessageDB) MarkConversationReadUntil(conversationID string, until int64) error {
+	query := `
+		UPDATE conversations SET unread_count = (
+			SELECT COUNT(*) FROM messages
+			WHERE conversation_id = ? AND is_outgoing = 0 AND timestamp > ?
+		)
+		WHERE id = ?
+	`
+	_, err := db.db.Exec(query, conversationID, until, conversationID)
+	return err
+}
+
+// SetConversationMuted mutes or unmutes a conversation
+func (db *MessageDB) SetConversationMuted(conversationID string, muted bool) error {
+	query := `UPDATE conversations SET is_muted = ? WHERE id = ?`
+	_, err := db.db.Exec(query, boolToInt(muted), conversationID)
+	return err
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"sync"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -31,6 +32,8 @@ const (
 type MessageDB struct {
 	db            *sql.DB
 	encryptionKey []byte // Derived from user password
+
+	auditMu sync.Mutex // Serializes audit log appends so the chain never forks
 }
 
 // StoredMessage represents a message in the database
@@ -48,6 +51,11 @@ type StoredMessage struct {
 	MeshChunkID    uint64
 	EncryptionKey  []byte
 	ReplyToID      string
+	ThreadParentID string   // Group thread parent message ID ("" = top level)
+	Mentions       []string // Hex addresses mentioned in the message
+	ForwardedFrom  string   // Hex address of the original sender ("" = not forwarded)
+	ForwardedAt    int64    // Original send time (Unix ms) of a forwarded message
+	ForwardHops    int      // Times a forwarded message has been forwarded
 }
 
 // Contact represents a contact in the database
@@ -131,6 +139,11 @@ func (db *MessageDB) initSchema() error {
 		mesh_chunk_id INTEGER DEFAULT 0,
 		encryption_key BLOB,
 		reply_to_id TEXT,
+		thread_parent_id TEXT NOT NULL DEFAULT '',
+		mentions TEXT NOT NULL DEFAULT '',
+		forwarded_from TEXT NOT NULL DEFAULT '',
+		forwarded_at INTEGER NOT NULL DEFAULT 0,
+		forward_hops INTEGER NOT NULL DEFAULT 0,
 		created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
 	);
 
@@ -173,9 +186,90 @@ func (db *MessageDB) initSchema() error {
 		return fmt.Errorf("failed to create schema: %v", err)
 	}
 
+	// Add columns introduced after the initial schema to existing databases
+	if err := db.addColumnIfMissing("messages", "thread_parent_id", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := db.addColumnIfMissing("messages", "mentions", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := db.addColumnIfMissing("messages", "forwarded_from", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := db.addColumnIfMissing("messages", "forwarded_at", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := db.addColumnIfMissing("messages", "forward_hops", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+
+	if _, err := db.db.Exec(`CREATE INDEX IF NOT EXISTS idx_messages_thread_parent ON messages(thread_parent_id, timestamp)`); err != nil {
+		return fmt.Errorf("failed to create thread index: %v", err)
+	}
+
+	if err := db.initMessageRequestSchema(); err != nil {
+		return err
+	}
+
+	if err := db.initAuditLogSchema(); err != nil {
+		return err
+	}
+
+	if err := db.initMessageArchiveSchema(); err != nil {
+		return err
+	}
+
+	if err := db.initOutboundQueueSchema(); err != nil {
+		return err
+	}
+
+	if err := db.initBandwidthUsageSchema(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// addColumnIfMissing adds a column to a table if it does not exist yet
+func (db *MessageDB) addColumnIfMissing(table, column, definition string) error {
+	rows, err := db.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to inspect %s: %v", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return fmt.Errorf("failed to inspect %s: %v", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	rows.Close()
+
+	if _, err := db.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add %s.%s: %v", table, column, err)
+	}
+
 	return nil
 }
 
+// Size returns the database's size in bytes, not counting the write-ahead log
+func (db *MessageDB) Size() (int64, error) {
+	var pages, pageSize int64
+	if err := db.db.QueryRow("PRAGMA page_count").Scan(&pages); err != nil {
+		return 0, fmt.Errorf("failed to read page count: %v", err)
+	}
+	if err := db.db.QueryRow("PRAGMA page_size").Scan(&pageSize); err != nil {
+		return 0, fmt.Errorf("failed to read page size: %v", err)
+	}
+	return pages * pageSize, nil
+}
+
 // Close closes the database connection
 func (db *MessageDB) Close() error {
 	return db.db.Close()
@@ -0,0 +1,356 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+	"github.com/ZentaChain/zentalk-node/pkg/sqldb"
+)
+
+// ===== DELIVERY PROOFS =====
+// A relay keeps a signed proof for each acknowledgment a recipient sends it,
+// so the relay counts it reports can be backed up if the reward contract or
+// other relays dispute them. Each proof carries the recipient's own signature
+// over the ack, so a relay cannot make up deliveries, and a recipient's ack of
+// a message is only counted once. Proofs hold the digest of the ack, never the
+// ack itself, and are kept for a rolling window.
+
+// deliveryProofExportVersion is bumped whenever the export format or proof digest changes
+const deliveryProofExportVersion = 2
+
+var (
+	ErrInvalidDeliveryProof   = errors.New("invalid delivery proof")
+	ErrDuplicateDeliveryProof = errors.New("delivery already proven")
+)
+
+// DeliveryProof is a relay's signed record that a recipient acknowledged a message
+type DeliveryProof struct {
+	Seq          int64  `json:"seq"`
+	Recipient    string `json:"recipient"`     // Hex address of the acknowledging user
+	MessageID    string `json:"message_id"`    // Hex ID of the acknowledged message
+	AckHash      string `json:"ack_hash"`      // Hex AckMessage.Digest of the ack
+	RecipientKey []byte `json:"recipient_key"` // Recipient's RSA public key (PEM)
+	AckSignature []byte `json:"ack_signature"` // Recipient's signature over the ack digest
+	Timestamp    int64  `json:"timestamp"`     // Unix timestamp (ms) the ack arrived
+	Signature    []byte `json:"signature"`     // Relay's signature over the proof digest
+}
+
+// DeliveryProofExport is a set of proofs handed to whoever disputes a relay's counts
+type DeliveryProofExport struct {
+	Version    int              `json:"version"`
+	ExportedAt int64            `json:"exported_at"`
+	Relay      string           `json:"relay"`      // Hex address of the relay
+	PublicKey  []byte           `json:"public_key"` // Relay's RSA public key (PEM)
+	Proofs     []*DeliveryProof `json:"proofs"`
+}
+
+// DeliveryProofStore keeps a relay's delivery proofs
+type DeliveryProofStore struct {
+	db      *sql.DB
+	dialect sqldb.Dialect
+	relay   string // Hex address of the relay, bound into every proof
+	key     *rsa.PrivateKey
+	window  time.Duration // How long proofs are kept
+}
+
+// OpenDeliveryProofStore opens a proof store, choosing the backend from the DSN
+// window: How long proofs are kept (default: 30 days)
+func OpenDeliveryProofStore(dsn string, relay protocol.Address, key *rsa.PrivateKey, window time.Duration) (*DeliveryProofStore, error) {
+	if window == 0 {
+		window = 30 * 24 * time.Hour // 30 days default
+	}
+
+	db, dialect, err := sqldb.Open(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open proof database: %v", err)
+	}
+
+	if dialect == sqldb.SQLite {
+		if _, err := db.Exec("PRAGMA journal_mode=WAL"); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to enable WAL: %v", err)
+		}
+	}
+
+	store := &DeliveryProofStore{
+		db:      db,
+		dialect: dialect,
+		relay:   hex.EncodeToString(relay[:]),
+		key:     key,
+		window:  window,
+	}
+
+	schema := `
+	CREATE TABLE IF NOT EXISTS delivery_proofs (
+		seq INTEGER PRIMARY KEY AUTOINCREMENT,
+		recipient TEXT NOT NULL,
+		message_id TEXT NOT NULL DEFAULT '',
+		ack_hash TEXT NOT NULL,
+		recipient_key BLOB,
+		ack_signature BLOB,
+		timestamp INTEGER NOT NULL,
+		signature BLOB NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_delivery_proofs_timestamp ON delivery_proofs(timestamp);
+	`
+
+	if _, err := db.Exec(dialect.Translate(schema)); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create proof schema: %v", err)
+	}
+
+	if err := store.migrateRecipientSignatures(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if _, err := db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_delivery_proofs_ack ON delivery_proofs(recipient, message_id)`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create proof index: %v", err)
+	}
+
+	// Start background pruning goroutine
+	go store.pruneExpiredProofs()
+
+	return store, nil
+}
+
+// migrateRecipientSignatures adds the recipient's signature to stores created before it
+// Proofs already stored hold only the relay's word and cannot be verified,
+// so they are dropped.
+func (s *DeliveryProofStore) migrateRecipientSignatures() error {
+	hasMessageID, err := sqldb.ColumnExists(s.db, "delivery_proofs", "message_id")
+	if err != nil {
+		return fmt.Errorf("failed to inspect proof schema: %v", err)
+	}
+	if hasMessageID {
+		return nil
+	}
+
+	for _, column := range []string{"message_id TEXT NOT NULL DEFAULT ''", "recipient_key BLOB", "ack_signature BLOB"} {
+		if _, err := s.db.Exec(s.dialect.Translate(`ALTER TABLE delivery_proofs ADD COLUMN ` + column)); err != nil {
+			return fmt.Errorf("failed to add proof column: %v", err)
+		}
+	}
+	result, err := s.db.Exec(`DELETE FROM delivery_proofs`)
+	if err != nil {
+		return fmt.Errorf("failed to drop unsigned delivery proofs: %v", err)
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		log.Printf("🧾 Dropped %d delivery proofs without recipient signatures", n)
+	}
+	return nil
+}
+
+// Record stores a proof for an ack its recipient signed with recipientKey
+// Returns ErrInvalidDeliveryProof if the ack is not the recipient's or its
+// signature does not verify, and ErrDuplicateDeliveryProof if the recipient
+// already acknowledged the message.
+func (s *DeliveryProofStore) Record(recipient protocol.Address, recipientKey *rsa.PublicKey, ack *protocol.AckMessage) (*DeliveryProof, error) {
+	if ack.From != recipient {
+		return nil, fmt.Errorf("%w: ack from %x recorded for %x", ErrInvalidDeliveryProof, ack.From[:8], recipient[:8])
+	}
+	digest := ack.Digest()
+	if recipientKey == nil || len(ack.Signature) == 0 || crypto.VerifySignature(digest, ack.Signature, recipientKey) != nil {
+		return nil, fmt.Errorf("%w: ack not signed by %x", ErrInvalidDeliveryProof, recipient[:8])
+	}
+	keyPEM, err := crypto.ExportPublicKeyPEM(recipientKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export recipient key: %v", err)
+	}
+
+	proof := &DeliveryProof{
+		Recipient:    hex.EncodeToString(recipient[:]),
+		MessageID:    hex.EncodeToString(ack.MessageID[:]),
+		AckHash:      hex.EncodeToString(digest),
+		RecipientKey: keyPEM,
+		AckSignature: ack.Signature,
+		Timestamp:    time.Now().UnixMilli(),
+	}
+
+	proof.Signature, err = crypto.SignData(proof.digest(s.relay), s.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign delivery proof: %v", err)
+	}
+
+	query := `
+		INSERT INTO delivery_proofs (recipient, message_id, ack_hash, recipient_key, ack_signature, timestamp, signature)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (recipient, message_id) DO NOTHING
+	`
+	result, err := s.db.Exec(s.dialect.Rebind(query), proof.Recipient, proof.MessageID, proof.AckHash,
+		proof.RecipientKey, proof.AckSignature, proof.Timestamp, proof.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save delivery proof: %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("failed to save delivery proof: %v", err)
+	} else if n == 0 {
+		return nil, fmt.Errorf("%w: %x acknowledged %s before", ErrDuplicateDeliveryProof, recipient[:8], proof.MessageID)
+	}
+
+	return proof, nil
+}
+
+// GetDeliveryProofs returns proofs with since <= timestamp < until, oldest first
+// until = 0 means no upper bound
+func (s *DeliveryProofStore) GetDeliveryProofs(since, until int64) ([]*DeliveryProof, error) {
+	query := `
+		SELECT seq, recipient, message_id, ack_hash, recipient_key, ack_signature, timestamp, signature
+		FROM delivery_proofs
+		WHERE timestamp >= ? AND (? = 0 OR timestamp < ?)
+		ORDER BY seq ASC
+	`
+
+	rows, err := s.db.Query(s.dialect.Rebind(query), since, until, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read delivery proofs: %v", err)
+	}
+	defer rows.Close()
+
+	var proofs []*DeliveryProof
+	for rows.Next() {
+		proof := &DeliveryProof{}
+		if err := rows.Scan(&proof.Seq, &proof.Recipient, &proof.MessageID, &proof.AckHash,
+			&proof.RecipientKey, &proof.AckSignature, &proof.Timestamp, &proof.Signature); err != nil {
+			return nil, fmt.Errorf("failed to scan delivery proof: %v", err)
+		}
+		proofs = append(proofs, proof)
+	}
+
+	return proofs, rows.Err()
+}
+
+// CountDeliveryProofs returns how many proofs have since <= timestamp < until
+// until = 0 means no upper bound
+func (s *DeliveryProofStore) CountDeliveryProofs(since, until int64) (int, error) {
+	query := `SELECT COUNT(*) FROM delivery_proofs WHERE timestamp >= ? AND (? = 0 OR timestamp < ?)`
+
+	var count int
+	if err := s.db.QueryRow(s.dialect.Rebind(query), since, until, until).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count delivery proofs: %v", err)
+	}
+	return count, nil
+}
+
+// Export returns the proofs in [since, until) ready to hand to a disputing party
+func (s *DeliveryProofStore) Export(since, until int64) (*DeliveryProofExport, error) {
+	proofs, err := s.GetDeliveryProofs(since, until)
+	if err != nil {
+		return nil, err
+	}
+
+	pubKeyPEM, err := crypto.ExportPublicKeyPEM(&s.key.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export public key: %v", err)
+	}
+
+	return &DeliveryProofExport{
+		Version:    deliveryProofExportVersion,
+		ExportedAt: time.Now().UnixMilli(),
+		Relay:      s.relay,
+		PublicKey:  pubKeyPEM,
+		Proofs:     proofs,
+	}, nil
+}
+
+// Prune deletes proofs older than the window
+func (s *DeliveryProofStore) Prune() (int64, error) {
+	cutoff := time.Now().Add(-s.window).UnixMilli()
+
+	result, err := s.db.Exec(s.dialect.Rebind(`DELETE FROM delivery_proofs WHERE timestamp < ?`), cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune delivery proofs: %v", err)
+	}
+	return result.RowsAffected()
+}
+
+// pruneExpiredProofs periodically removes proofs that have left the window
+func (s *DeliveryProofStore) pruneExpiredProofs() {
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		count, err := s.Prune()
+		if err != nil {
+			log.Printf("Failed to prune delivery proofs: %v", err)
+			continue
+		}
+		if count > 0 {
+			log.Printf("🧹 Pruned %d expired delivery proofs", count)
+		}
+	}
+}
+
+// Close closes the database connection
+func (s *DeliveryProofStore) Close() error {
+	return s.db.Close()
+}
+
+// Verify checks every proof's signatures: the relay's, and the recipient's over the ack
+// No ack may be proven twice. Returns the number of proofs checked.
+func (e *DeliveryProofExport) Verify() (int, error) {
+	if e.Version != deliveryProofExportVersion {
+		return 0, fmt.Errorf("%w of delivery proof export: %d", protocol.ErrUnsupportedVersion, e.Version)
+	}
+
+	pubKey, err := crypto.ImportPublicKeyPEM(e.PublicKey)
+	if err != nil {
+		return 0, fmt.Errorf("invalid public key in proof export: %v", err)
+	}
+
+	seen := make(map[string]bool, len(e.Proofs))
+	for _, proof := range e.Proofs {
+		if err := crypto.VerifySignature(proof.digest(e.Relay), proof.Signature, pubKey); err != nil {
+			return 0, fmt.Errorf("%w: bad signature on proof %d", ErrInvalidDeliveryProof, proof.Seq)
+		}
+
+		recipientKey, err := crypto.ImportPublicKeyPEM(proof.RecipientKey)
+		if err != nil {
+			return 0, fmt.Errorf("%w: bad recipient key on proof %d", ErrInvalidDeliveryProof, proof.Seq)
+		}
+		digest, err := hex.DecodeString(proof.AckHash)
+		if err != nil || crypto.VerifySignature(digest, proof.AckSignature, recipientKey) != nil {
+			return 0, fmt.Errorf("%w: ack on proof %d not signed by its recipient", ErrInvalidDeliveryProof, proof.Seq)
+		}
+
+		ack := proof.Recipient + "|" + proof.MessageID
+		if seen[ack] || seen[proof.AckHash] {
+			return 0, fmt.Errorf("%w: proof %d repeats an ack", ErrDuplicateDeliveryProof, proof.Seq)
+		}
+		seen[ack], seen[proof.AckHash] = true, true
+	}
+
+	return len(e.Proofs), nil
+}
+
+// digest is what the relay signs: its own address and the proof's fields
+// Seq is left out, it only orders proofs within one store.
+func (proof *DeliveryProof) digest(relay string) []byte {
+	var buf bytes.Buffer
+	writeString := func(s string) {
+		binary.Write(&buf, binary.BigEndian, uint32(len(s)))
+		buf.WriteString(s)
+	}
+
+	writeString("zentalk-delivery-proof")
+	writeString(relay)
+	writeString(proof.Recipient)
+	writeString(proof.MessageID)
+	writeString(proof.AckHash)
+	writeString(string(proof.RecipientKey))
+	writeString(string(proof.AckSignature))
+	binary.Write(&buf, binary.BigEndian, proof.Timestamp)
+
+	return buf.Bytes()
+}
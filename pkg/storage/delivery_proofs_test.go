@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// signedAck returns recipient's ack of message id, signed with key
+func signedAck(t *testing.T, recipient protocol.Address, id byte, key *rsa.PrivateKey) *protocol.AckMessage {
+	t.Helper()
+	ack := &protocol.AckMessage{From: recipient, To: protocol.Address{0xAA}, MessageID: protocol.MessageID{id}, Timestamp: 1}
+	signature, err := crypto.SignData(ack.Digest(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ack.Signature = signature
+	return ack
+}
+
+func TestDeliveryProofs(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipientKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := OpenDeliveryProofStore(filepath.Join(t.TempDir(), "proofs.db"), protocol.Address{9}, key, time.Hour)
+	if err != nil {
+		t.Fatalf("OpenDeliveryProofStore() error = %v", err)
+	}
+	defer store.Close()
+
+	for i := byte(0); i < 3; i++ {
+		if _, err := store.Record(protocol.Address{i}, &recipientKey.PublicKey, signedAck(t, protocol.Address{i}, 1, recipientKey)); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+
+	// Only the recipient's signed ack proves a delivery, and only once
+	if _, err := store.Record(protocol.Address{0}, &recipientKey.PublicKey, signedAck(t, protocol.Address{0}, 1, recipientKey)); !errors.Is(err, ErrDuplicateDeliveryProof) {
+		t.Errorf("Record(duplicate ack) error = %v, want ErrDuplicateDeliveryProof", err)
+	}
+	unsigned := signedAck(t, protocol.Address{0}, 2, recipientKey)
+	unsigned.Signature = nil
+	if _, err := store.Record(protocol.Address{0}, &recipientKey.PublicKey, unsigned); !errors.Is(err, ErrInvalidDeliveryProof) {
+		t.Errorf("Record(unsigned ack) error = %v, want ErrInvalidDeliveryProof", err)
+	}
+	if _, err := store.Record(protocol.Address{0}, &recipientKey.PublicKey, signedAck(t, protocol.Address{0}, 2, key)); !errors.Is(err, ErrInvalidDeliveryProof) {
+		t.Errorf("Record(ack signed by another key) error = %v, want ErrInvalidDeliveryProof", err)
+	}
+	if _, err := store.Record(protocol.Address{0}, &recipientKey.PublicKey, signedAck(t, protocol.Address{1}, 2, recipientKey)); !errors.Is(err, ErrInvalidDeliveryProof) {
+		t.Errorf("Record(another user's ack) error = %v, want ErrInvalidDeliveryProof", err)
+	}
+
+	if n, err := store.CountDeliveryProofs(0, 0); err != nil || n != 3 {
+		t.Errorf("CountDeliveryProofs() = %d, %v; want 3", n, err)
+	}
+
+	export, err := store.Export(0, 0)
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if n, err := export.Verify(); err != nil || n != 3 {
+		t.Fatalf("Verify() = %d, %v; want 3", n, err)
+	}
+
+	// A proof can't be moved to another recipient or claimed by another relay
+	export.Proofs[1].Recipient = export.Proofs[0].Recipient
+	if _, err := export.Verify(); !errors.Is(err, ErrInvalidDeliveryProof) {
+		t.Errorf("Verify(edited recipient) error = %v, want ErrInvalidDeliveryProof", err)
+	}
+
+	export, _ = store.Export(0, 0)
+	export.Relay = "00"
+	if _, err := export.Verify(); !errors.Is(err, ErrInvalidDeliveryProof) {
+		t.Errorf("Verify(other relay) error = %v, want ErrInvalidDeliveryProof", err)
+	}
+
+	// Nor can a relay vouch for an ack the recipient did not sign, or count one twice
+	export, _ = store.Export(0, 0)
+	export.Proofs[0].AckSignature = export.Proofs[1].AckSignature
+	export.Proofs[0].Signature, _ = crypto.SignData(export.Proofs[0].digest(export.Relay), key)
+	if _, err := export.Verify(); !errors.Is(err, ErrInvalidDeliveryProof) {
+		t.Errorf("Verify(forged ack signature) error = %v, want ErrInvalidDeliveryProof", err)
+	}
+
+	export, _ = store.Export(0, 0)
+	export.Proofs = append(export.Proofs, export.Proofs[0])
+	if _, err := export.Verify(); !errors.Is(err, ErrDuplicateDeliveryProof) {
+		t.Errorf("Verify(repeated proof) error = %v, want ErrDuplicateDeliveryProof", err)
+	}
+
+	// Proofs older than the window are pruned
+	if _, err := store.db.Exec(`UPDATE delivery_proofs SET timestamp = ? WHERE seq = 1`, time.Now().Add(-2*time.Hour).UnixMilli()); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := store.Prune(); err != nil || n != 1 {
+		t.Errorf("Prune() = %d, %v; want 1", n, err)
+	}
+	if n, _ := store.CountDeliveryProofs(0, 0); n != 2 {
+		t.Errorf("CountDeliveryProofs() after Prune = %d, want 2", n)
+	}
+}
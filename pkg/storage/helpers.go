@@ -3,6 +3,7 @@ package storage
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 )
 
 // ===== HELPER FUNCTIONS =====
@@ -68,3 +69,16 @@ func (db *MessageDB) ExportData() ([]byte, error) {
 
 	return json.Marshal(data)
 }
+
+// joinMentions serializes mentioned addresses for the mentions column
+func joinMentions(mentions []string) string {
+	return strings.Join(mentions, ",")
+}
+
+// splitMentions parses the mentions column
+func splitMentions(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
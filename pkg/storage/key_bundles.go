@@ -0,0 +1,261 @@
+package storage
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+	"github.com/ZentaChain/zentalk-node/pkg/sqldb"
+)
+
+// ===== KEY BUNDLES =====
+// A relay keeps its users' X3DH key bundles so contacts can start sessions
+// with them while they are offline. Every fetch hands out the lowest-numbered
+// one-time prekey and deletes it, and the highest ID handed out is remembered:
+// a republished bundle only adds prekeys above it, so a key already given to
+// one sender is never given to another. The first key to sign a user's bundle
+// is pinned: a later bundle must be signed by it, even one with a new identity.
+
+// MaxStoredOneTimePreKeys caps the one-time prekeys held for one user
+const MaxStoredOneTimePreKeys = 200
+
+var (
+	ErrKeyBundleNotFound = errors.New("key bundle not found")
+	ErrKeyBundleSigner   = errors.New("key bundle signed by another key than the owner's")
+)
+
+// KeyBundleStore keeps the key bundles published to a relay
+type KeyBundleStore struct {
+	db      *sql.DB
+	dialect sqldb.Dialect
+}
+
+// OpenKeyBundleStore opens a key bundle store, choosing the backend from the DSN
+func OpenKeyBundleStore(dsn string) (*KeyBundleStore, error) {
+	db, dialect, err := sqldb.Open(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open key bundle database: %v", err)
+	}
+
+	if dialect == sqldb.SQLite {
+		if _, err := db.Exec("PRAGMA journal_mode=WAL"); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to enable WAL: %v", err)
+		}
+	}
+
+	// bundle holds the encoded KeyBundle without its one-time prekeys
+	schema := `
+	CREATE TABLE IF NOT EXISTS key_bundles (
+		address TEXT PRIMARY KEY,
+		identity_key BLOB NOT NULL,
+		signing_key BLOB,
+		signature BLOB,
+		bundle BLOB NOT NULL,
+		last_issued INTEGER NOT NULL DEFAULT 0,
+		updated_at INTEGER NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS one_time_prekeys (
+		address TEXT NOT NULL,
+		key_id INTEGER NOT NULL,
+		public_key BLOB NOT NULL,
+		PRIMARY KEY (address, key_id)
+	);
+	`
+
+	if _, err := db.Exec(dialect.Translate(schema)); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create key bundle schema: %v", err)
+	}
+
+	// Stores created before bundles were signed have no signing key columns
+	hasSigningKey, err := sqldb.ColumnExists(db, "key_bundles", "signing_key")
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to inspect key bundle schema: %v", err)
+	}
+	if !hasSigningKey {
+		for _, column := range []string{"signing_key", "signature"} {
+			if _, err := db.Exec(`ALTER TABLE key_bundles ADD COLUMN ` + column + ` BLOB`); err != nil {
+				db.Close()
+				return nil, fmt.Errorf("failed to add %s column: %v", column, err)
+			}
+		}
+	}
+
+	return &KeyBundleStore{db: db, dialect: dialect}, nil
+}
+
+// Publish stores a user's bundle and adds its new one-time prekeys
+// The caller checks sig; it must be made with the key pinned for the user, if
+// any, or ErrKeyBundleSigner is returned. A bundle with a different identity
+// key replaces the old one and its prekeys outright. Returns how many one-time
+// prekeys are now held for the user.
+func (s *KeyBundleStore) Publish(bundle *protocol.KeyBundle, sig protocol.KeyBundleSignature) (int, error) {
+	addr := hex.EncodeToString(bundle.Address[:])
+
+	base := *bundle
+	base.OneTimePreKeys = nil
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin key bundle publish: %v", err)
+	}
+	defer tx.Rollback()
+
+	var identityKey, signingKey []byte
+	var lastIssued int64
+	err = tx.QueryRow(s.dialect.Rebind(`SELECT identity_key, signing_key, last_issued FROM key_bundles WHERE address = ?`), addr).
+		Scan(&identityKey, &signingKey, &lastIssued)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return 0, fmt.Errorf("failed to read key bundle: %v", err)
+	case len(signingKey) > 0 && !bytes.Equal(signingKey, sig.SigningKey[:]):
+		return 0, fmt.Errorf("%w for %x", ErrKeyBundleSigner, bundle.Address[:8])
+	case !bytes.Equal(identityKey, bundle.IdentityKey[:]):
+		// A new identity: prekeys signed for the old one are useless
+		if _, err := tx.Exec(s.dialect.Rebind(`DELETE FROM one_time_prekeys WHERE address = ?`), addr); err != nil {
+			return 0, fmt.Errorf("failed to drop old one-time prekeys: %v", err)
+		}
+		lastIssued = 0
+	}
+
+	upsert := `
+		INSERT INTO key_bundles (address, identity_key, signing_key, signature, bundle, last_issued, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (address) DO UPDATE SET
+			identity_key = excluded.identity_key,
+			signing_key = excluded.signing_key,
+			signature = excluded.signature,
+			bundle = excluded.bundle,
+			last_issued = excluded.last_issued,
+			updated_at = excluded.updated_at
+	`
+	if _, err := tx.Exec(s.dialect.Rebind(upsert), addr, bundle.IdentityKey[:], sig.SigningKey[:], sig.Signature[:], base.Encode(), lastIssued, time.Now().Unix()); err != nil {
+		return 0, fmt.Errorf("failed to save key bundle: %v", err)
+	}
+
+	count, err := countOneTimePreKeys(tx, s.dialect, addr)
+	if err != nil {
+		return 0, err
+	}
+	insert := s.dialect.Rebind(`INSERT INTO one_time_prekeys (address, key_id, public_key) VALUES (?, ?, ?) ON CONFLICT (address, key_id) DO NOTHING`)
+	for _, opk := range bundle.OneTimePreKeys {
+		if count >= MaxStoredOneTimePreKeys {
+			break
+		}
+		if int64(opk.KeyID) <= lastIssued {
+			continue // Already handed out once
+		}
+		result, err := tx.Exec(insert, addr, int64(opk.KeyID), opk.PublicKey[:])
+		if err != nil {
+			return 0, fmt.Errorf("failed to save one-time prekey: %v", err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			count++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit key bundle: %v", err)
+	}
+	return count, nil
+}
+
+// Fetch returns a user's bundle with one one-time prekey, which is removed from the store
+// The bundle has no one-time prekey once they run out. Also returns the owner's
+// signature and how many prekeys remain.
+func (s *KeyBundleStore) Fetch(addr protocol.Address) (*protocol.KeyBundle, protocol.KeyBundleSignature, int, error) {
+	key := hex.EncodeToString(addr[:])
+	var sig protocol.KeyBundleSignature
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, sig, 0, fmt.Errorf("failed to begin key bundle fetch: %v", err)
+	}
+	defer tx.Rollback()
+
+	var encoded, signingKey, signature []byte
+	err = tx.QueryRow(s.dialect.Rebind(`SELECT bundle, signing_key, signature FROM key_bundles WHERE address = ?`), key).
+		Scan(&encoded, &signingKey, &signature)
+	if err == sql.ErrNoRows {
+		return nil, sig, 0, fmt.Errorf("%w for %x", ErrKeyBundleNotFound, addr[:8])
+	}
+	if err != nil {
+		return nil, sig, 0, fmt.Errorf("failed to read key bundle: %v", err)
+	}
+	bundle, err := protocol.DecodeKeyBundle(encoded)
+	if err != nil {
+		return nil, sig, 0, fmt.Errorf("stored key bundle for %x is corrupt: %w", addr[:8], err)
+	}
+	copy(sig.SigningKey[:], signingKey)
+	copy(sig.Signature[:], signature)
+
+	// Another relay process sharing the database may take the same key first; try the next
+	for attempt := 0; attempt < 3; attempt++ {
+		var keyID int64
+		var publicKey []byte
+		err := tx.QueryRow(s.dialect.Rebind(`SELECT key_id, public_key FROM one_time_prekeys WHERE address = ? ORDER BY key_id LIMIT 1`), key).
+			Scan(&keyID, &publicKey)
+		if err == sql.ErrNoRows {
+			break
+		}
+		if err != nil {
+			return nil, sig, 0, fmt.Errorf("failed to read one-time prekey: %v", err)
+		}
+
+		result, err := tx.Exec(s.dialect.Rebind(`DELETE FROM one_time_prekeys WHERE address = ? AND key_id = ?`), key, keyID)
+		if err != nil {
+			return nil, sig, 0, fmt.Errorf("failed to remove one-time prekey: %v", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			continue
+		}
+
+		update := `UPDATE key_bundles SET last_issued = ? WHERE address = ? AND last_issued < ?`
+		if _, err := tx.Exec(s.dialect.Rebind(update), keyID, key, keyID); err != nil {
+			return nil, sig, 0, fmt.Errorf("failed to record issued prekey: %v", err)
+		}
+
+		opk := protocol.OneTimePreKey{KeyID: uint32(keyID)}
+		copy(opk.PublicKey[:], publicKey)
+		bundle.OneTimePreKeys = []protocol.OneTimePreKey{opk}
+		break
+	}
+
+	remaining, err := countOneTimePreKeys(tx, s.dialect, key)
+	if err != nil {
+		return nil, sig, 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, sig, 0, fmt.Errorf("failed to commit key bundle fetch: %v", err)
+	}
+	return bundle, sig, remaining, nil
+}
+
+// CountOneTimePreKeys returns how many one-time prekeys are held for a user
+func (s *KeyBundleStore) CountOneTimePreKeys(addr protocol.Address) (int, error) {
+	return countOneTimePreKeys(s.db, s.dialect, hex.EncodeToString(addr[:]))
+}
+
+// countOneTimePreKeys counts a user's one-time prekeys through db or a transaction
+func countOneTimePreKeys(q interface {
+	QueryRow(string, ...any) *sql.Row
+}, dialect sqldb.Dialect, addr string) (int, error) {
+	var count int
+	if err := q.QueryRow(dialect.Rebind(`SELECT COUNT(*) FROM one_time_prekeys WHERE address = ?`), addr).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count one-time prekeys: %v", err)
+	}
+	return count, nil
+}
+
+// Close closes the database
+func (s *KeyBundleStore) Close() error {
+	return s.db.Close()
+}
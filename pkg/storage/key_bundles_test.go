@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// testBundle returns a bundle with one-time prekeys numbered from..to
+func testBundle(identity byte, from, to uint32) *protocol.KeyBundle {
+	bundle := &protocol.KeyBundle{Address: protocol.Address{7}, RegistrationID: 42}
+	bundle.IdentityKey[0] = identity
+	for id := from; id <= to; id++ {
+		opk := protocol.OneTimePreKey{KeyID: id}
+		opk.PublicKey[0] = byte(id)
+		bundle.OneTimePreKeys = append(bundle.OneTimePreKeys, opk)
+	}
+	return bundle
+}
+
+// testSigner is the owner's signature the tests publish with; the store does not check it
+var testSigner = protocol.KeyBundleSignature{SigningKey: [32]byte{1}, Signature: [64]byte{2}}
+
+func TestKeyBundleStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bundles.db")
+	store, err := OpenKeyBundleStore(path)
+	if err != nil {
+		t.Fatalf("OpenKeyBundleStore() error = %v", err)
+	}
+
+	if _, _, _, err := store.Fetch(protocol.Address{7}); !errors.Is(err, ErrKeyBundleNotFound) {
+		t.Fatalf("Fetch() before publish error = %v, want ErrKeyBundleNotFound", err)
+	}
+
+	if count, err := store.Publish(testBundle(1, 1, 3), testSigner); err != nil || count != 3 {
+		t.Fatalf("Publish() = %d, %v; want 3", count, err)
+	}
+
+	// Prekeys are handed out lowest first, once each
+	bundle, sig, remaining, err := store.Fetch(protocol.Address{7})
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if sig != testSigner {
+		t.Errorf("Fetch() signature = %+v, want the published one", sig)
+	}
+	if len(bundle.OneTimePreKeys) != 1 || bundle.OneTimePreKeys[0].KeyID != 1 || remaining != 2 {
+		t.Fatalf("Fetch() prekeys = %v, remaining %d; want key 1 and 2 left", bundle.OneTimePreKeys, remaining)
+	}
+	if bundle.RegistrationID != 42 || bundle.OneTimePreKeys[0].PublicKey[0] != 1 {
+		t.Errorf("Fetch() returned bundle %+v", bundle)
+	}
+	store.Close()
+
+	// Consumption survives a restart, and republishing never revives an issued key
+	store, err = OpenKeyBundleStore(path)
+	if err != nil {
+		t.Fatalf("OpenKeyBundleStore() reopen error = %v", err)
+	}
+	defer store.Close()
+
+	if count, err := store.Publish(testBundle(1, 1, 5), testSigner); err != nil || count != 4 {
+		t.Fatalf("republish Publish() = %d, %v; want 4", count, err)
+	}
+	for _, want := range []uint32{2, 3, 4, 5} {
+		bundle, _, _, err := store.Fetch(protocol.Address{7})
+		if err != nil || len(bundle.OneTimePreKeys) != 1 || bundle.OneTimePreKeys[0].KeyID != want {
+			t.Fatalf("Fetch() = %v, %v; want key %d", bundle, err, want)
+		}
+	}
+
+	// Once they run out, the bundle still comes back without a one-time prekey
+	bundle, _, remaining, err = store.Fetch(protocol.Address{7})
+	if err != nil || len(bundle.OneTimePreKeys) != 0 || remaining != 0 {
+		t.Fatalf("Fetch() when exhausted = %v, %d, %v", bundle, remaining, err)
+	}
+
+	// A new identity starts over
+	if count, err := store.Publish(testBundle(2, 1, 2), testSigner); err != nil || count != 2 {
+		t.Fatalf("Publish() with new identity = %d, %v; want 2", count, err)
+	}
+
+	// Only the pinned key may replace the bundle, even with a new identity
+	hijacker := protocol.KeyBundleSignature{SigningKey: [32]byte{9}}
+	if _, err := store.Publish(testBundle(3, 10, 12), hijacker); !errors.Is(err, ErrKeyBundleSigner) {
+		t.Fatalf("Publish() signed by another key error = %v, want ErrKeyBundleSigner", err)
+	}
+	bundle, _, _, err = store.Fetch(protocol.Address{7})
+	if err != nil || bundle.IdentityKey[0] != 2 || bundle.OneTimePreKeys[0].KeyID != 1 {
+		t.Fatalf("Fetch() after refused publish = %v, %v; want the owner's bundle", bundle, err)
+	}
+}
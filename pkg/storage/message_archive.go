@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+)
+
+// ===== MESSAGE ARCHIVE OPERATIONS =====
+// Old messages can be moved out of the database into encrypted MeshStorage
+// chunks. Each chunk holds messages of one conversation; the archive index
+// keeps the chunk ID, its key and the time range covered so the messages can
+// be fetched again on demand.
+
+// MessageArchive is one MeshStorage chunk of archived messages
+type MessageArchive struct {
+	ID             int64
+	ConversationID string
+	ChunkID        uint64
+	ChunkKey       []byte // Key the chunk was encrypted with
+	FirstTimestamp int64  // Oldest message in the chunk (ms)
+	LastTimestamp  int64  // Newest message in the chunk (ms)
+	MessageCount   int
+	ArchivedAt     int64 // Unix timestamp (ms)
+}
+
+// initMessageArchiveSchema creates the archive index table
+func (db *MessageDB) initMessageArchiveSchema() error {
+	schema := `
+	CREATE TABLE IF NOT EXISTS message_archives (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		conversation_id TEXT NOT NULL,
+		chunk_id INTEGER NOT NULL,
+		chunk_key BLOB NOT NULL,
+		first_timestamp INTEGER NOT NULL,
+		last_timestamp INTEGER NOT NULL,
+		message_count INTEGER NOT NULL,
+		archived_at INTEGER NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_message_archives_conversation ON message_archives(conversation_id, last_timestamp DESC);
+	`
+
+	if _, err := db.db.Exec(schema); err != nil {
+		return fmt.Errorf("failed to create message archive schema: %v", err)
+	}
+	return nil
+}
+
+// GetArchivableConversations lists conversations holding messages older than before (ms)
+func (db *MessageDB) GetArchivableConversations(before int64) ([]string, error) {
+	rows, err := db.db.Query(`SELECT DISTINCT conversation_id FROM messages WHERE timestamp < ?`, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var conversationIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		conversationIDs = append(conversationIDs, id)
+	}
+	return conversationIDs, rows.Err()
+}
+
+// GetMessagesBefore retrieves a conversation's messages older than before (ms), oldest first
+func (db *MessageDB) GetMessagesBefore(conversationID string, before int64, limit int) ([]*StoredMessage, error) {
+	query := `
+		SELECT id, conversation_id, message_id, from_address, to_address,
+		       content, content_type, timestamp, status, is_outgoing,
+		       mesh_chunk_id, encryption_key, reply_to_id,
+		       thread_parent_id, mentions,
+		       forwarded_from, forwarded_at, forward_hops
+		FROM messages
+		WHERE conversation_id = ? AND timestamp < ?
+		ORDER BY timestamp ASC, id ASC
+		LIMIT ?
+	`
+
+	rows, err := db.db.Query(query, conversationID, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return db.scanMessages(rows)
+}
+
+// SaveMessageArchive records an archived chunk and deletes the messages it holds
+// Both happen in one transaction, so a message is never lost or kept twice.
+func (db *MessageDB) SaveMessageArchive(archive *MessageArchive, messageIDs []string) error {
+	if len(messageIDs) == 0 {
+		return fmt.Errorf("archive holds no messages")
+	}
+
+	encryptedKey, err := crypto.AESEncrypt(archive.ChunkKey, db.encryptionKey)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt chunk key: %v", err)
+	}
+	if archive.ArchivedAt == 0 {
+		archive.ArchivedAt = time.Now().UnixMilli()
+	}
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO message_archives (
+			conversation_id, chunk_id, chunk_key, first_timestamp,
+			last_timestamp, message_count, archived_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?)
+	`,
+		archive.ConversationID,
+		int64(archive.ChunkID),
+		encryptedKey,
+		archive.FirstTimestamp,
+		archive.LastTimestamp,
+		archive.MessageCount,
+		archive.ArchivedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save message archive: %v", err)
+	}
+	if archive.ID, err = result.LastInsertId(); err != nil {
+		return err
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(messageIDs)), ",")
+	args := make([]any, len(messageIDs))
+	for i, id := range messageIDs {
+		args[i] = id
+	}
+	if _, err := tx.Exec(`DELETE FROM messages WHERE message_id IN (`+placeholders+`)`, args...); err != nil {
+		return fmt.Errorf("failed to delete archived messages: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+// GetMessageArchives lists a conversation's archived chunks, newest first
+func (db *MessageDB) GetMessageArchives(conversationID string) ([]*MessageArchive, error) {
+	query := `
+		SELECT id, conversation_id, chunk_id, chunk_key, first_timestamp,
+		       last_timestamp, message_count, archived_at
+		FROM message_archives
+		WHERE conversation_id = ?
+		ORDER BY last_timestamp DESC
+	`
+
+	rows, err := db.db.Query(query, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var archives []*MessageArchive
+	for rows.Next() {
+		var archive MessageArchive
+		var chunkID int64
+		var encryptedKey []byte
+
+		if err := rows.Scan(
+			&archive.ID,
+			&archive.ConversationID,
+			&chunkID,
+			&encryptedKey,
+			&archive.FirstTimestamp,
+			&archive.LastTimestamp,
+			&archive.MessageCount,
+			&archive.ArchivedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		archive.ChunkID = uint64(chunkID)
+		archive.ChunkKey, err = crypto.AESDecrypt(encryptedKey, db.encryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt chunk key: %v", err)
+		}
+		archives = append(archives, &archive)
+	}
+	return archives, rows.Err()
+}
+
+// Compact rebuilds the database file so space freed by deleted messages is returned to the OS
+func (db *MessageDB) Compact() error {
+	if _, err := db.db.Exec(`VACUUM`); err != nil {
+		return fmt.Errorf("failed to compact database: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestMessageArchive(t *testing.T) {
+	db, err := NewMessageDB(filepath.Join(t.TempDir(), "archive.db"), "password")
+	if err != nil {
+		t.Fatalf("NewMessageDB() error = %v", err)
+	}
+	defer db.Close()
+
+	conversationID := GetConversationID("aa", "bb")
+	for i := 0; i < 5; i++ {
+		msg := &StoredMessage{
+			ConversationID: conversationID,
+			MessageID:      fmt.Sprintf("m%d", i),
+			FromAddress:    "bb",
+			ToAddress:      "aa",
+			Content:        fmt.Appendf(nil, "message %d", i),
+			Timestamp:      int64(1000 * (i + 1)),
+			Status:         MessageStatusDelivered,
+		}
+		if err := db.SaveMessage(msg); err != nil {
+			t.Fatalf("SaveMessage() error = %v", err)
+		}
+	}
+
+	conversations, err := db.GetArchivableConversations(3500)
+	if err != nil || len(conversations) != 1 || conversations[0] != conversationID {
+		t.Fatalf("GetArchivableConversations() = %v, %v; want [%s]", conversations, err, conversationID)
+	}
+
+	old, err := db.GetMessagesBefore(conversationID, 3500, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(old) != 3 || old[0].MessageID != "m0" || string(old[2].Content) != "message 2" {
+		t.Fatalf("GetMessagesBefore() returned %d messages, want m0-m2 oldest first", len(old))
+	}
+
+	archive := &MessageArchive{
+		ConversationID: conversationID,
+		ChunkID:        42,
+		ChunkKey:       []byte("chunk key"),
+		FirstTimestamp: old[0].Timestamp,
+		LastTimestamp:  old[2].Timestamp,
+		MessageCount:   3,
+	}
+	if err := db.SaveMessageArchive(archive, []string{"m0", "m1", "m2"}); err != nil {
+		t.Fatalf("SaveMessageArchive() error = %v", err)
+	}
+
+	if _, err := db.GetMessage("m1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetMessage(archived) error = %v, want ErrNotFound", err)
+	}
+	remaining, err := db.GetConversationMessages(conversationID, 10, 0)
+	if err != nil || len(remaining) != 2 {
+		t.Errorf("GetConversationMessages() = %d messages, %v; want 2", len(remaining), err)
+	}
+
+	archives, err := db.GetMessageArchives(conversationID)
+	if err != nil || len(archives) != 1 {
+		t.Fatalf("GetMessageArchives() = %v, %v; want one archive", archives, err)
+	}
+	got := archives[0]
+	if got.ChunkID != 42 || !bytes.Equal(got.ChunkKey, archive.ChunkKey) || got.MessageCount != 3 || got.LastTimestamp != 3000 {
+		t.Errorf("GetMessageArchives()[0] = %+v, want %+v", got, archive)
+	}
+
+	if err := db.Compact(); err != nil {
+		t.Errorf("Compact() error = %v", err)
+	}
+}
@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+)
+
+// ===== MESSAGE REQUEST OPERATIONS =====
+
+// MessageRequest summarizes pending messages from a sender who is not a contact
+type MessageRequest struct {
+	SenderAddress string
+	MessageCount  int
+	FirstSeen     int64
+	LastSeen      int64
+	Preview       string // Latest message text (truncated)
+}
+
+// initMessageRequestSchema creates the message request table
+// Requests are kept apart from messages so unknown senders never appear in the inbox
+func (db *MessageDB) initMessageRequestSchema() error {
+	schema := `
+	CREATE TABLE IF NOT EXISTS message_requests (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		message_id TEXT UNIQUE NOT NULL,
+		from_address TEXT NOT NULL,
+		to_address TEXT NOT NULL,
+		content BLOB NOT NULL,
+		content_type INTEGER NOT NULL,
+		timestamp INTEGER NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_message_requests_from ON message_requests(from_address, timestamp);
+	`
+
+	if _, err := db.db.Exec(schema); err != nil {
+		return fmt.Errorf("failed to create message request schema: %v", err)
+	}
+	return nil
+}
+
+// SaveMessageRequest stores a message from an unknown sender
+func (db *MessageDB) SaveMessageRequest(msg *StoredMessage) error {
+	encryptedContent, err := crypto.AESEncrypt(msg.Content, db.encryptionKey)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt content: %v", err)
+	}
+
+	query := `
+		INSERT OR IGNORE INTO message_requests (
+			message_id, from_address, to_address, content, content_type, timestamp
+		) VALUES (?, ?, ?, ?, ?, ?)
+	`
+
+	result, err := db.db.Exec(
+		query,
+		msg.MessageID,
+		msg.FromAddress,
+		msg.ToAddress,
+		encryptedContent,
+		msg.ContentType,
+		msg.Timestamp,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save message request: %v", err)
+	}
+
+	msg.ID, _ = result.LastInsertId()
+	return nil
+}
+
+// GetMessageRequests lists pending requests grouped by sender, newest first
+func (db *MessageDB) GetMessageRequests() ([]*MessageRequest, error) {
+	query := `
+		SELECT from_address, COUNT(*), MIN(timestamp), MAX(timestamp)
+		FROM message_requests
+		GROUP BY from_address
+		ORDER BY MAX(timestamp) DESC
+	`
+
+	rows, err := db.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []*MessageRequest
+	for rows.Next() {
+		var req MessageRequest
+		if err := rows.Scan(&req.SenderAddress, &req.MessageCount, &req.FirstSeen, &req.LastSeen); err != nil {
+			return nil, err
+		}
+		requests = append(requests, &req)
+	}
+	rows.Close()
+
+	// Fill previews from each sender's latest message
+	for _, req := range requests {
+		msgs, err := db.GetMessageRequestMessages(req.SenderAddress)
+		if err != nil || len(msgs) == 0 {
+			continue
+		}
+		preview := string(msgs[len(msgs)-1].Content)
+		if len(preview) > 100 {
+			preview = preview[:100] + "..."
+		}
+		req.Preview = preview
+	}
+
+	return requests, nil
+}
+
+// GetMessageRequestMessages retrieves pending messages from a sender, oldest first
+func (db *MessageDB) GetMessageRequestMessages(senderAddress string) ([]*StoredMessage, error) {
+	query := `
+		SELECT id, message_id, from_address, to_address, content, content_type, timestamp
+		FROM message_requests
+		WHERE from_address = ?
+		ORDER BY timestamp ASC
+	`
+
+	rows, err := db.db.Query(query, senderAddress)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []*StoredMessage
+	for rows.Next() {
+		var msg StoredMessage
+		var encryptedContent []byte
+
+		err := rows.Scan(
+			&msg.ID,
+			&msg.MessageID,
+			&msg.FromAddress,
+			&msg.ToAddress,
+			&encryptedContent,
+			&msg.ContentType,
+			&msg.Timestamp,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		msg.Content, err = crypto.AESDecrypt(encryptedContent, db.encryptionKey)
+		if err != nil {
+			continue // Skip messages that can't be decrypted
+		}
+
+		msg.ConversationID = GetConversationID(msg.FromAddress, msg.ToAddress)
+		msg.Status = MessageStatusDelivered
+		messages = append(messages, &msg)
+	}
+
+	return messages, nil
+}
+
+// HasMessageRequest returns true if a sender has pending request messages
+func (db *MessageDB) HasMessageRequest(senderAddress string) (bool, error) {
+	var count int
+	err := db.db.QueryRow(`SELECT COUNT(*) FROM message_requests WHERE from_address = ?`, senderAddress).Scan(&count)
+	return count > 0, err
+}
+
+// AcceptMessageRequest moves a sender's pending messages into their conversation
+func (db *MessageDB) AcceptMessageRequest(senderAddress string) ([]*StoredMessage, error) {
+	messages, err := db.GetMessageRequestMessages(senderAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, msg := range messages {
+		if err := db.SaveMessage(msg); err != nil {
+			return nil, fmt.Errorf("failed to move request message %s: %v", msg.MessageID, err)
+		}
+	}
+
+	if err := db.DeleteMessageRequests(senderAddress); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// DeleteMessageRequests discards all pending messages from a sender
+func (db *MessageDB) DeleteMessageRequests(senderAddress string) error {
+	query := `DELETE FROM message_requests WHERE from_address = ?`
+	_, err := db.db.Exec(query, senderAddress)
+	return err
+}
+
+// HasOutgoingMessages returns true if we've sent anything in a conversation
+// Conversations we started are never treated as message requests
+func (db *MessageDB) HasOutgoingMessages(conversationID string) (bool, error) {
+	var count int
+	err := db.db.QueryRow(
+		`SELECT COUNT(*) FROM messages WHERE conversation_id = ? AND is_outgoing = 1`,
+		conversationID,
+	).Scan(&count)
+	return count > 0, err
+}
@@ -31,8 +31,10 @@ func (db *MessageDB) SaveMessage(msg *StoredMessage) error {
 		INSERT INTO messages (
 			conversation_id, message_id, from_address, to_address,
 			content, content_type, timestamp, status, is_outgoing,
-			mesh_chunk_id, encryption_key, reply_to_id
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			mesh_chunk_id, encryption_key, reply_to_id,
+			thread_parent_id, mentions,
+			forwarded_from, forwarded_at, forward_hops
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	result, err := db.db.Exec(
@@ -49,6 +51,11 @@ func (db *MessageDB) SaveMessage(msg *StoredMessage) error {
 		msg.MeshChunkID,
 		encryptedMeshKey,
 		msg.ReplyToID,
+		msg.ThreadParentID,
+		joinMentions(msg.Mentions),
+		msg.ForwardedFrom,
+		msg.ForwardedAt,
+		msg.ForwardHops,
 	)
 
 	if err != nil {
@@ -75,7 +82,9 @@ func (db *MessageDB) GetMessage(messageID string) (*StoredMessage, error) {
 	query := `
 		SELECT id, conversation_id, message_id, from_address, to_address,
 		       content, content_type, timestamp, status, is_outgoing,
-		       mesh_chunk_id, encryption_key, reply_to_id
+		       mesh_chunk_id, encryption_key, reply_to_id,
+		       thread_parent_id, mentions,
+		       forwarded_from, forwarded_at, forward_hops
 		FROM messages WHERE message_id = ?
 	`
 
@@ -85,6 +94,7 @@ func (db *MessageDB) GetMessage(messageID string) (*StoredMessage, error) {
 	var encryptedContent []byte
 	var encryptedMeshKey []byte
 	var isOutgoing int
+	var mentions string
 
 	err := row.Scan(
 		&msg.ID,
@@ -100,6 +110,11 @@ func (db *MessageDB) GetMessage(messageID string) (*StoredMessage, error) {
 		&msg.MeshChunkID,
 		&encryptedMeshKey,
 		&msg.ReplyToID,
+		&msg.ThreadParentID,
+		&mentions,
+		&msg.ForwardedFrom,
+		&msg.ForwardedAt,
+		&msg.ForwardHops,
 	)
 
 	if err == sql.ErrNoRows {
@@ -110,6 +125,7 @@ func (db *MessageDB) GetMessage(messageID string) (*StoredMessage, error) {
 	}
 
 	msg.IsOutgoing = intToBool(isOutgoing)
+	msg.Mentions = splitMentions(mentions)
 
 	// Decrypt content
 	msg.Content, err = crypto.AESDecrypt(encryptedContent, db.encryptionKey)
@@ -133,7 +149,9 @@ func (db *MessageDB) GetConversationMessages(conversationID string, limit, offse
 	query := `
 		SELECT id, conversation_id, message_id, from_address, to_address,
 		       content, content_type, timestamp, status, is_outgoing,
-		       mesh_chunk_id, encryption_key, reply_to_id
+		       mesh_chunk_id, encryption_key, reply_to_id,
+		       thread_parent_id, mentions,
+		       forwarded_from, forwarded_at, forward_hops
 		FROM messages
 		WHERE conversation_id = ?
 		ORDER BY timestamp DESC
@@ -153,6 +171,7 @@ func (db *MessageDB) GetConversationMessages(conversationID string, limit, offse
 		var encryptedContent []byte
 		var encryptedMeshKey []byte
 		var isOutgoing int
+		var mentions string
 
 		err := rows.Scan(
 			&msg.ID,
@@ -168,12 +187,18 @@ func (db *MessageDB) GetConversationMessages(conversationID string, limit, offse
 			&msg.MeshChunkID,
 			&encryptedMeshKey,
 			&msg.ReplyToID,
+			&msg.ThreadParentID,
+			&mentions,
+			&msg.ForwardedFrom,
+			&msg.ForwardedAt,
+			&msg.ForwardHops,
 		)
 		if err != nil {
 			return nil, err
 		}
 
 		msg.IsOutgoing = intToBool(isOutgoing)
+		msg.Mentions = splitMentions(mentions)
 
 		// Decrypt content
 		msg.Content, err = crypto.AESDecrypt(encryptedContent, db.encryptionKey)
@@ -216,7 +241,9 @@ func (db *MessageDB) SearchMessages(searchText string, limit int) ([]*StoredMess
 	query := `
 		SELECT id, conversation_id, message_id, from_address, to_address,
 		       content, content_type, timestamp, status, is_outgoing,
-		       mesh_chunk_id, encryption_key, reply_to_id
+		       mesh_chunk_id, encryption_key, reply_to_id,
+		       thread_parent_id, mentions,
+		       forwarded_from, forwarded_at, forward_hops
 		FROM messages
 		WHERE content_type = ?
 		ORDER BY timestamp DESC
@@ -236,6 +263,7 @@ func (db *MessageDB) SearchMessages(searchText string, limit int) ([]*StoredMess
 		var encryptedContent []byte
 		var encryptedMeshKey []byte
 		var isOutgoing int
+		var mentions string
 
 		err := rows.Scan(
 			&msg.ID,
@@ -251,12 +279,18 @@ func (db *MessageDB) SearchMessages(searchText string, limit int) ([]*StoredMess
 			&msg.MeshChunkID,
 			&encryptedMeshKey,
 			&msg.ReplyToID,
+			&msg.ThreadParentID,
+			&mentions,
+			&msg.ForwardedFrom,
+			&msg.ForwardedAt,
+			&msg.ForwardHops,
 		)
 		if err != nil {
 			return nil, err
 		}
 
 		msg.IsOutgoing = intToBool(isOutgoing)
+		msg.Mentions = splitMentions(mentions)
 
 		// Decrypt and search
 		msg.Content, err = crypto.AESDecrypt(encryptedContent, db.encryptionKey)
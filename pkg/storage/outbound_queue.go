@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"fmt"
+)
+
+// ===== OUTBOUND QUEUE OPERATIONS =====
+// Sent messages stay queued until the recipient ACKs them, so a client can keep
+// retransmitting them across restarts. The queue holds the onion-wrapped frame
+// exactly as it was written; its content is already encrypted for the
+// recipient and the relays on the path.
+
+// OutboundMessage is a sent message awaiting the recipient's ACK
+type OutboundMessage struct {
+	MessageID   string // Stored message ID (hex relay message ID)
+	Peer        string // Hex address of the recipient
+	Seq         uint64 // Sequence number the ACK will carry
+	Frame       []byte // Onion-wrapped payload, resent as is
+	Attempts    int    // Times the frame has been sent (or tried)
+	NextAttempt int64  // Unix timestamp (ms) of the next retransmission
+}
+
+// initOutboundQueueSchema creates the outbound queue table
+func (db *MessageDB) initOutboundQueueSchema() error {
+	schema := `
+	CREATE TABLE IF NOT EXISTS outbound_queue (
+		message_id TEXT PRIMARY KEY,
+		peer TEXT NOT NULL,
+		seq INTEGER NOT NULL,
+		frame BLOB NOT NULL,
+		attempts INTEGER NOT NULL,
+		next_attempt INTEGER NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_outbound_queue_peer ON outbound_queue(peer, seq);
+	`
+
+	if _, err := db.db.Exec(schema); err != nil {
+		return fmt.Errorf("failed to create outbound queue schema: %v", err)
+	}
+	return nil
+}
+
+// SaveOutboundMessage queues a sent message, replacing any entry with the same message ID
+func (db *MessageDB) SaveOutboundMessage(msg *OutboundMessage) error {
+	query := `
+		INSERT OR REPLACE INTO outbound_queue (
+			message_id, peer, seq, frame, attempts, next_attempt
+		) VALUES (?, ?, ?, ?, ?, ?)
+	`
+
+	if _, err := db.db.Exec(query, msg.MessageID, msg.Peer, int64(msg.Seq), msg.Frame, msg.Attempts, msg.NextAttempt); err != nil {
+		return fmt.Errorf("failed to save outbound message: %v", err)
+	}
+	return nil
+}
+
+// UpdateOutboundAttempt records another send of a queued message
+func (db *MessageDB) UpdateOutboundAttempt(messageID string, attempts int, nextAttempt int64) error {
+	query := `UPDATE outbound_queue SET attempts = ?, next_attempt = ? WHERE message_id = ?`
+	if _, err := db.db.Exec(query, attempts, nextAttempt, messageID); err != nil {
+		return fmt.Errorf("failed to update outbound message: %v", err)
+	}
+	return nil
+}
+
+// DeleteOutboundMessage removes a message from the queue once it is ACKed or given up on
+func (db *MessageDB) DeleteOutboundMessage(messageID string) error {
+	if _, err := db.db.Exec(`DELETE FROM outbound_queue WHERE message_id = ?`, messageID); err != nil {
+		return fmt.Errorf("failed to delete outbound message: %v", err)
+	}
+	return nil
+}
+
+// GetOutboundMessages returns every queued message, next retransmission first
+func (db *MessageDB) GetOutboundMessages() ([]*OutboundMessage, error) {
+	query := `
+		SELECT message_id, peer, seq, frame, attempts, next_attempt
+		FROM outbound_queue
+		ORDER BY next_attempt ASC
+	`
+
+	rows, err := db.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outbound queue: %v", err)
+	}
+	defer rows.Close()
+
+	var messages []*OutboundMessage
+	for rows.Next() {
+		msg := &OutboundMessage{}
+		var seq int64
+		if err := rows.Scan(&msg.MessageID, &msg.Peer, &seq, &msg.Frame, &msg.Attempts, &msg.NextAttempt); err != nil {
+			return nil, fmt.Errorf("failed to scan outbound message: %v", err)
+		}
+		msg.Seq = uint64(seq)
+		messages = append(messages, msg)
+	}
+
+	return messages, rows.Err()
+}
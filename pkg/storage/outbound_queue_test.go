@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestOutboundQueue(t *testing.T) {
+	db, err := NewMessageDB(filepath.Join(t.TempDir(), "outbound.db"), "password")
+	if err != nil {
+		t.Fatalf("NewMessageDB() error = %v", err)
+	}
+	defer db.Close()
+
+	later := &OutboundMessage{MessageID: "m1", Peer: "bb", Seq: 1 << 63, Frame: []byte("onion 1"), Attempts: 1, NextAttempt: 5000}
+	sooner := &OutboundMessage{MessageID: "m2", Peer: "bb", Seq: 2, Frame: []byte("onion 2"), Attempts: 1, NextAttempt: 3000}
+	for _, msg := range []*OutboundMessage{later, sooner} {
+		if err := db.SaveOutboundMessage(msg); err != nil {
+			t.Fatalf("SaveOutboundMessage() error = %v", err)
+		}
+	}
+
+	if err := db.UpdateOutboundAttempt("m2", 2, 9000); err != nil {
+		t.Fatalf("UpdateOutboundAttempt() error = %v", err)
+	}
+
+	queued, err := db.GetOutboundMessages()
+	if err != nil {
+		t.Fatalf("GetOutboundMessages() error = %v", err)
+	}
+	if len(queued) != 2 || queued[0].MessageID != "m1" || queued[1].MessageID != "m2" {
+		t.Fatalf("GetOutboundMessages() = %+v, want m1 then m2", queued)
+	}
+	if queued[0].Seq != 1<<63 || string(queued[0].Frame) != "onion 1" {
+		t.Errorf("m1 = %+v, want it unchanged", queued[0])
+	}
+	if queued[1].Attempts != 2 || queued[1].NextAttempt != 9000 {
+		t.Errorf("m2 = %+v, want 2 attempts, next at 9000", queued[1])
+	}
+
+	if err := db.DeleteOutboundMessage("m1"); err != nil {
+		t.Fatalf("DeleteOutboundMessage() error = %v", err)
+	}
+	if queued, _ := db.GetOutboundMessages(); len(queued) != 1 || queued[0].MessageID != "m2" {
+		t.Errorf("after delete = %+v, want only m2", queued)
+	}
+}
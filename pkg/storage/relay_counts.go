@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/sqldb"
+)
+
+// ===== RELAY COUNTS =====
+// A relay's rewards depend on the message counts it records on-chain. Counts
+// are checkpointed here between reports, so a restart or crash loses at most
+// the messages relayed since the last checkpoint rather than a whole interval.
+
+// RelayCountStore persists a relay's message counts between on-chain reports
+type RelayCountStore struct {
+	db      *sql.DB
+	dialect sqldb.Dialect
+}
+
+// OpenRelayCountStore opens a count store, choosing the backend from the DSN
+func OpenRelayCountStore(dsn string) (*RelayCountStore, error) {
+	db, dialect, err := sqldb.Open(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open relay count database: %v", err)
+	}
+
+	if dialect == sqldb.SQLite {
+		if _, err := db.Exec("PRAGMA journal_mode=WAL"); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to enable WAL: %v", err)
+		}
+	}
+
+	// A single row: pending counts are not yet on-chain, reported ones are
+	schema := `
+	CREATE TABLE IF NOT EXISTS relay_counts (
+		id INTEGER PRIMARY KEY,
+		pending INTEGER NOT NULL DEFAULT 0,
+		reported INTEGER NOT NULL DEFAULT 0,
+		updated_at INTEGER NOT NULL DEFAULT 0
+	);
+	`
+
+	if _, err := db.Exec(dialect.Translate(schema)); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create relay count schema: %v", err)
+	}
+
+	if _, err := db.Exec(`INSERT INTO relay_counts (id) VALUES (1) ON CONFLICT (id) DO NOTHING`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize relay counts: %v", err)
+	}
+
+	return &RelayCountStore{db: db, dialect: dialect}, nil
+}
+
+// AddPending adds relayed messages that still need to be reported
+func (s *RelayCountStore) AddPending(count uint64) error {
+	query := `UPDATE relay_counts SET pending = pending + ?, updated_at = ? WHERE id = 1`
+	if _, err := s.db.Exec(s.dialect.Rebind(query), int64(count), time.Now().Unix()); err != nil {
+		return fmt.Errorf("failed to save relay count: %v", err)
+	}
+	return nil
+}
+
+// MarkReported moves count messages from pending to reported once they are on-chain
+func (s *RelayCountStore) MarkReported(count uint64) error {
+	query := `
+		UPDATE relay_counts
+		SET pending = CASE WHEN pending > ? THEN pending - ? ELSE 0 END,
+			reported = reported + ?,
+			updated_at = ?
+		WHERE id = 1
+	`
+	n := int64(count)
+	if _, err := s.db.Exec(s.dialect.Rebind(query), n, n, n, time.Now().Unix()); err != nil {
+		return fmt.Errorf("failed to mark relay count reported: %v", err)
+	}
+	return nil
+}
+
+// Pending returns the messages saved but not yet reported
+func (s *RelayCountStore) Pending() (uint64, error) {
+	pending, _, err := s.counts()
+	return pending, err
+}
+
+// Reported returns the messages reported on-chain since the store was created
+func (s *RelayCountStore) Reported() (uint64, error) {
+	_, reported, err := s.counts()
+	return reported, err
+}
+
+// counts reads the pending and reported totals
+func (s *RelayCountStore) counts() (uint64, uint64, error) {
+	var pending, reported int64
+	if err := s.db.QueryRow(`SELECT pending, reported FROM relay_counts WHERE id = 1`).Scan(&pending, &reported); err != nil {
+		return 0, 0, fmt.Errorf("failed to read relay counts: %v", err)
+	}
+	return uint64(pending), uint64(reported), nil
+}
+
+// Close closes the database
+func (s *RelayCountStore) Close() error {
+	return s.db.Close()
+}
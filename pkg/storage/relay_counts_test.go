@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestRelayCountStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counts.db")
+
+	store, err := OpenRelayCountStore(path)
+	if err != nil {
+		t.Fatalf("OpenRelayCountStore() error = %v", err)
+	}
+	if err := store.AddPending(7); err != nil {
+		t.Fatalf("AddPending() error = %v", err)
+	}
+	if err := store.AddPending(5); err != nil {
+		t.Fatalf("AddPending() error = %v", err)
+	}
+	if err := store.MarkReported(10); err != nil {
+		t.Fatalf("MarkReported() error = %v", err)
+	}
+	store.Close()
+
+	// Counts survive reopening
+	store, err = OpenRelayCountStore(path)
+	if err != nil {
+		t.Fatalf("OpenRelayCountStore() reopen error = %v", err)
+	}
+	defer store.Close()
+
+	if pending, err := store.Pending(); err != nil || pending != 2 {
+		t.Errorf("Pending() = %d, %v; want 2", pending, err)
+	}
+	if reported, err := store.Reported(); err != nil || reported != 10 {
+		t.Errorf("Reported() = %d, %v; want 10", reported, err)
+	}
+
+	// Pending never goes below zero
+	if err := store.MarkReported(5); err != nil {
+		t.Fatalf("MarkReported() error = %v", err)
+	}
+	if pending, _ := store.Pending(); pending != 0 {
+		t.Errorf("Pending() = %d after over-reporting, want 0", pending)
+	}
+}
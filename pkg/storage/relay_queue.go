@@ -7,8 +7,8 @@ import (
 	"log"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
 	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+	"github.com/ZentaChain/zentalk-node/pkg/sqldb"
 )
 
 // QueuedMessage represents a message waiting for delivery
@@ -20,6 +20,8 @@ type QueuedMessage struct {
 	Timestamp       int64  // When message was queued (bucketed to 1-hour intervals for privacy)
 	ExpiresAt       int64  // When message expires (TTL)
 	Attempts        int    // Delivery attempt count
+	Tenant          string // Organization the recipient belongs to ("" = default)
+	Priority        int    // Higher priority messages are evicted last when the queue is full
 }
 
 // bucketTimestamp rounds a timestamp to the nearest hour (privacy protection)
@@ -31,33 +33,54 @@ func bucketTimestamp(timestamp int64) int64 {
 
 // RelayMessageQueue manages offline message storage for a relay
 type RelayMessageQueue struct {
-	db  *sql.DB
-	ttl time.Duration // Message time-to-live
+	db      *sql.DB
+	dialect sqldb.Dialect // SQLite by default, PostgreSQL for shared/high-volume queues
+	ttl     time.Duration // Message time-to-live
 }
 
 // NewRelayMessageQueue creates a new relay message queue
 // ttl: Time-to-live for queued messages (default: 30 days)
 func NewRelayMessageQueue(dbPath string, ttl time.Duration) (*RelayMessageQueue, error) {
+	return OpenRelayMessageQueue(dbPath, ttl)
+}
+
+// NewPostgresRelayMessageQueue creates a relay message queue backed by PostgreSQL
+// Several relay processes can share one PostgreSQL queue to form a cluster
+func NewPostgresRelayMessageQueue(dsn string, ttl time.Duration) (*RelayMessageQueue, error) {
+	if !sqldb.IsPostgresDSN(dsn) {
+		return nil, fmt.Errorf("not a PostgreSQL DSN (expected postgres://...)")
+	}
+	return OpenRelayMessageQueue(dsn, ttl)
+}
+
+// OpenRelayMessageQueue opens a relay message queue, choosing the backend from the DSN
+// (postgres:// URLs use PostgreSQL, anything else is a SQLite file path)
+func OpenRelayMessageQueue(dsn string, ttl time.Duration) (*RelayMessageQueue, error) {
 	if ttl == 0 {
 		ttl = 30 * 24 * time.Hour // 30 days default
 	}
 
-	db, err := sql.Open("sqlite3", dbPath)
+	db, dialect, err := sqldb.Open(dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open queue database: %v", err)
 	}
 
-	// Enable WAL mode for better concurrency
-	if _, err := db.Exec("PRAGMA journal_mode=WAL"); err != nil {
-		return nil, fmt.Errorf("failed to enable WAL: %v", err)
+	if dialect == sqldb.SQLite {
+		// Enable WAL mode for better concurrency
+		if _, err := db.Exec("PRAGMA journal_mode=WAL"); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to enable WAL: %v", err)
+		}
 	}
 
 	queue := &RelayMessageQueue{
-		db:  db,
-		ttl: ttl,
+		db:      db,
+		dialect: dialect,
+		ttl:     ttl,
 	}
 
 	if err := queue.initSchema(); err != nil {
+		db.Close()
 		return nil, err
 	}
 
@@ -78,6 +101,8 @@ func (q *RelayMessageQueue) initSchema() error {
 		timestamp INTEGER NOT NULL,
 		expires_at INTEGER NOT NULL,
 		attempts INTEGER NOT NULL DEFAULT 0,
+		tenant TEXT NOT NULL DEFAULT '',
+		priority INTEGER NOT NULL DEFAULT 0,
 		created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
 	);
 
@@ -91,50 +116,150 @@ func (q *RelayMessageQueue) initSchema() error {
 	CREATE INDEX IF NOT EXISTS idx_message_id ON queued_messages(message_id);
 	`
 
-	if _, err := q.db.Exec(schema); err != nil {
+	if _, err := q.db.Exec(q.dialect.Translate(schema)); err != nil {
 		return fmt.Errorf("failed to create schema: %v", err)
 	}
 
+	// Queues created before tenancy have no tenant column
+	hasTenant, err := sqldb.ColumnExists(q.db, "queued_messages", "tenant")
+	if err != nil {
+		return fmt.Errorf("failed to inspect schema: %v", err)
+	}
+	if !hasTenant {
+		if _, err := q.db.Exec(`ALTER TABLE queued_messages ADD COLUMN tenant TEXT NOT NULL DEFAULT ''`); err != nil {
+			return fmt.Errorf("failed to add tenant column: %v", err)
+		}
+	}
+
+	if _, err := q.db.Exec(`CREATE INDEX IF NOT EXISTS idx_tenant ON queued_messages(tenant)`); err != nil {
+		return fmt.Errorf("failed to create tenant index: %v", err)
+	}
+
+	// Nor do queues created before priorities
+	hasPriority, err := sqldb.ColumnExists(q.db, "queued_messages", "priority")
+	if err != nil {
+		return fmt.Errorf("failed to inspect schema: %v", err)
+	}
+	if !hasPriority {
+		if _, err := q.db.Exec(`ALTER TABLE queued_messages ADD COLUMN priority INTEGER NOT NULL DEFAULT 0`); err != nil {
+			return fmt.Errorf("failed to add priority column: %v", err)
+		}
+	}
+
+	if _, err := q.db.Exec(`CREATE INDEX IF NOT EXISTS idx_priority ON queued_messages(priority, timestamp)`); err != nil {
+		return fmt.Errorf("failed to create priority index: %v", err)
+	}
+
 	return nil
 }
 
+// TTL returns how long messages stay queued unless given their own TTL
+func (q *RelayMessageQueue) TTL() time.Duration {
+	return q.ttl
+}
+
+// rebind converts ? placeholders for the queue's dialect
+func (q *RelayMessageQueue) rebind(query string) string {
+	return q.dialect.Rebind(query)
+}
+
+// exec runs a statement with driver-specific placeholders
+func (q *RelayMessageQueue) exec(query string, args ...interface{}) (sql.Result, error) {
+	return q.db.Exec(q.rebind(query), args...)
+}
+
+// query runs a query with driver-specific placeholders
+func (q *RelayMessageQueue) query(query string, args ...interface{}) (*sql.Rows, error) {
+	return q.db.Query(q.rebind(query), args...)
+}
+
+// queryRow runs a single-row query with driver-specific placeholders
+func (q *RelayMessageQueue) queryRow(query string, args ...interface{}) *sql.Row {
+	return q.db.QueryRow(q.rebind(query), args...)
+}
+
 // QueueMessage adds a message to the queue for an offline recipient
 func (q *RelayMessageQueue) QueueMessage(recipientAddr protocol.Address, messageID [16]byte, encryptedPayload []byte) error {
+	return q.QueueTenantMessage("", recipientAddr, messageID, encryptedPayload)
+}
+
+// QueueTenantMessage adds a message to a tenant's queue for an offline recipient
+func (q *RelayMessageQueue) QueueTenantMessage(tenant string, recipientAddr protocol.Address, messageID [16]byte, encryptedPayload []byte) error {
+	return q.QueuePriorityMessage(tenant, recipientAddr, messageID, encryptedPayload, 0, 0)
+}
+
+// QueuePriorityMessage adds a message with a priority and its own time-to-live
+// ttl 0 uses the queue's TTL.
+func (q *RelayMessageQueue) QueuePriorityMessage(tenant string, recipientAddr protocol.Address, messageID [16]byte, encryptedPayload []byte, priority int, ttl time.Duration) error {
 	recipientHex := hex.EncodeToString(recipientAddr[:])
 	messageIDHex := hex.EncodeToString(messageID[:])
 	now := time.Now().Unix()
 
+	if ttl <= 0 {
+		ttl = q.ttl
+	}
+
 	// Bucket timestamp to nearest hour for privacy (prevents precise online/offline tracking)
 	bucketedTimestamp := bucketTimestamp(now)
-	expiresAt := now + int64(q.ttl.Seconds())
+	expiresAt := now + int64(ttl.Seconds())
 
 	query := `
-		INSERT INTO queued_messages (recipient_addr, message_id, encrypted_payload, timestamp, expires_at)
-		VALUES (?, ?, ?, ?, ?)
+		INSERT INTO queued_messages (recipient_addr, message_id, encrypted_payload, timestamp, expires_at, tenant, priority)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
 	`
 
-	_, err := q.db.Exec(query, recipientHex, messageIDHex, encryptedPayload, bucketedTimestamp, expiresAt)
+	_, err := q.exec(query, recipientHex, messageIDHex, encryptedPayload, bucketedTimestamp, expiresAt, tenant, priority)
 	if err != nil {
 		return fmt.Errorf("failed to queue message: %v", err)
 	}
 
-	log.Printf("📬 Queued message %s for offline user %x (expires in %v)", messageIDHex[:8], recipientAddr[:8], q.ttl)
+	log.Printf("📬 Queued message %s for offline user %x (priority %d, expires in %v)", messageIDHex[:8], recipientAddr[:8], priority, ttl)
 	return nil
 }
 
+// EvictLowerPriority deletes the oldest of the lowest-priority messages below priority
+// Returns false if every queued message has at least that priority.
+func (q *RelayMessageQueue) EvictLowerPriority(priority int) (bool, error) {
+	return q.evictLowerPriority(`priority < ?`, priority)
+}
+
+// EvictTenantLowerPriority is EvictLowerPriority within one tenant's messages
+func (q *RelayMessageQueue) EvictTenantLowerPriority(tenant string, priority int) (bool, error) {
+	return q.evictLowerPriority(`tenant = ? AND priority < ?`, tenant, priority)
+}
+
+// evictLowerPriority deletes the first message matching where, lowest priority and oldest first
+func (q *RelayMessageQueue) evictLowerPriority(where string, args ...interface{}) (bool, error) {
+	query := `
+		DELETE FROM queued_messages WHERE id = (
+			SELECT id FROM queued_messages
+			WHERE ` + where + `
+			ORDER BY priority ASC, timestamp ASC, id ASC
+			LIMIT 1
+		)
+	`
+
+	result, err := q.exec(query, args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to evict message: %v", err)
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
 // GetQueuedMessages retrieves all queued messages for a recipient
 func (q *RelayMessageQueue) GetQueuedMessages(recipientAddr protocol.Address) ([]*QueuedMessage, error) {
 	recipientHex := hex.EncodeToString(recipientAddr[:])
 
 	query := `
-		SELECT id, recipient_addr, message_id, encrypted_payload, timestamp, expires_at, attempts
+		SELECT id, recipient_addr, message_id, encrypted_payload, timestamp, expires_at, attempts, tenant, priority
 		FROM queued_messages
 		WHERE recipient_addr = ? AND expires_at > ?
-		ORDER BY timestamp ASC
+		ORDER BY timestamp ASC, id ASC
 	`
 
 	now := time.Now().Unix()
-	rows, err := q.db.Query(query, recipientHex, now)
+	rows, err := q.query(query, recipientHex, now)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get queued messages: %v", err)
 	}
@@ -143,7 +268,7 @@ func (q *RelayMessageQueue) GetQueuedMessages(recipientAddr protocol.Address) ([
 	var messages []*QueuedMessage
 	for rows.Next() {
 		msg := &QueuedMessage{}
-		if err := rows.Scan(&msg.ID, &msg.RecipientAddr, &msg.MessageID, &msg.EncryptedPayload, &msg.Timestamp, &msg.ExpiresAt, &msg.Attempts); err != nil {
+		if err := rows.Scan(&msg.ID, &msg.RecipientAddr, &msg.MessageID, &msg.EncryptedPayload, &msg.Timestamp, &msg.ExpiresAt, &msg.Attempts, &msg.Tenant, &msg.Priority); err != nil {
 			return nil, fmt.Errorf("failed to scan message: %v", err)
 		}
 		messages = append(messages, msg)
@@ -152,10 +277,72 @@ func (q *RelayMessageQueue) GetQueuedMessages(recipientAddr protocol.Address) ([
 	return messages, nil
 }
 
+// GetQueuedMessagesAfter retrieves up to limit queued messages for a recipient in queue order
+// Only messages with an ID above afterID and queued at or after since (unix
+// seconds) are returned; this is how clients page through their queue.
+func (q *RelayMessageQueue) GetQueuedMessagesAfter(recipientAddr protocol.Address, afterID int64, since int64, limit int) ([]*QueuedMessage, error) {
+	recipientHex := hex.EncodeToString(recipientAddr[:])
+
+	query := `
+		SELECT id, recipient_addr, message_id, encrypted_payload, timestamp, expires_at, attempts, tenant, priority
+		FROM queued_messages
+		WHERE recipient_addr = ? AND id > ? AND timestamp >= ? AND expires_at > ?
+		ORDER BY id ASC
+		LIMIT ?
+	`
+
+	now := time.Now().Unix()
+	rows, err := q.query(query, recipientHex, afterID, since, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get queued messages: %v", err)
+	}
+	defer rows.Close()
+
+	var messages []*QueuedMessage
+	for rows.Next() {
+		msg := &QueuedMessage{}
+		if err := rows.Scan(&msg.ID, &msg.RecipientAddr, &msg.MessageID, &msg.EncryptedPayload, &msg.Timestamp, &msg.ExpiresAt, &msg.Attempts, &msg.Tenant, &msg.Priority); err != nil {
+			return nil, fmt.Errorf("failed to scan message: %v", err)
+		}
+		messages = append(messages, msg)
+	}
+
+	return messages, rows.Err()
+}
+
+// CountQueuedMessagesAfter returns how many queued messages GetQueuedMessagesAfter could still return
+func (q *RelayMessageQueue) CountQueuedMessagesAfter(recipientAddr protocol.Address, afterID int64, since int64) (int, error) {
+	recipientHex := hex.EncodeToString(recipientAddr[:])
+	now := time.Now().Unix()
+
+	query := `SELECT COUNT(*) FROM queued_messages WHERE recipient_addr = ? AND id > ? AND timestamp >= ? AND expires_at > ?`
+
+	var count int
+	if err := q.queryRow(query, recipientHex, afterID, since, now).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to get message count: %v", err)
+	}
+	return count, nil
+}
+
+// DeleteMessagesThrough removes a recipient's queued messages with IDs up to and including throughID
+// Clients acknowledge a synced page this way. Returns the number removed.
+func (q *RelayMessageQueue) DeleteMessagesThrough(recipientAddr protocol.Address, throughID int64) (int, error) {
+	recipientHex := hex.EncodeToString(recipientAddr[:])
+	query := `DELETE FROM queued_messages WHERE recipient_addr = ? AND id <= ?`
+
+	result, err := q.exec(query, recipientHex, throughID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete messages: %v", err)
+	}
+
+	count, _ := result.RowsAffected()
+	return int(count), nil
+}
+
 // DeleteMessage removes a message from the queue (after successful delivery)
 func (q *RelayMessageQueue) DeleteMessage(messageID string) error {
 	query := `DELETE FROM queued_messages WHERE message_id = ?`
-	_, err := q.db.Exec(query, messageID)
+	_, err := q.exec(query, messageID)
 	if err != nil {
 		return fmt.Errorf("failed to delete message: %v", err)
 	}
@@ -167,7 +354,7 @@ func (q *RelayMessageQueue) DeleteMessagesForRecipient(recipientAddr protocol.Ad
 	recipientHex := hex.EncodeToString(recipientAddr[:])
 	query := `DELETE FROM queued_messages WHERE recipient_addr = ?`
 
-	result, err := q.db.Exec(query, recipientHex)
+	result, err := q.exec(query, recipientHex)
 	if err != nil {
 		return fmt.Errorf("failed to delete messages: %v", err)
 	}
@@ -180,7 +367,7 @@ func (q *RelayMessageQueue) DeleteMessagesForRecipient(recipientAddr protocol.Ad
 // IncrementAttempts increments the delivery attempt counter
 func (q *RelayMessageQueue) IncrementAttempts(messageID string) error {
 	query := `UPDATE queued_messages SET attempts = attempts + 1 WHERE message_id = ?`
-	_, err := q.db.Exec(query, messageID)
+	_, err := q.exec(query, messageID)
 	return err
 }
 
@@ -192,7 +379,7 @@ func (q *RelayMessageQueue) GetQueuedMessageCount(recipientAddr protocol.Address
 	query := `SELECT COUNT(*) FROM queued_messages WHERE recipient_addr = ? AND expires_at > ?`
 
 	var count int
-	err := q.db.QueryRow(query, recipientHex, now).Scan(&count)
+	err := q.queryRow(query, recipientHex, now).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get message count: %v", err)
 	}
@@ -206,7 +393,7 @@ func (q *RelayMessageQueue) GetTotalQueueSize() (int, error) {
 	query := `SELECT COUNT(*) FROM queued_messages WHERE expires_at > ?`
 
 	var count int
-	err := q.db.QueryRow(query, now).Scan(&count)
+	err := q.queryRow(query, now).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get queue size: %v", err)
 	}
@@ -214,6 +401,20 @@ func (q *RelayMessageQueue) GetTotalQueueSize() (int, error) {
 	return count, nil
 }
 
+// GetTenantQueueSize returns the number of queued messages for a tenant
+func (q *RelayMessageQueue) GetTenantQueueSize(tenant string) (int, error) {
+	now := time.Now().Unix()
+	query := `SELECT COUNT(*) FROM queued_messages WHERE tenant = ? AND expires_at > ?`
+
+	var count int
+	err := q.queryRow(query, tenant, now).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get tenant queue size: %v", err)
+	}
+
+	return count, nil
+}
+
 // cleanupExpiredMessages periodically removes expired messages
 func (q *RelayMessageQueue) cleanupExpiredMessages() {
 	ticker := time.NewTicker(1 * time.Hour)
@@ -223,7 +424,7 @@ func (q *RelayMessageQueue) cleanupExpiredMessages() {
 		now := time.Now().Unix()
 		query := `DELETE FROM queued_messages WHERE expires_at <= ?`
 
-		result, err := q.db.Exec(query, now)
+		result, err := q.exec(query, now)
 		if err != nil {
 			log.Printf("Failed to cleanup expired messages: %v", err)
 			continue
@@ -244,7 +445,7 @@ func (q *RelayMessageQueue) GetOldestMessageTime(recipientAddr protocol.Address)
 	query := `SELECT MIN(timestamp) FROM queued_messages WHERE recipient_addr = ? AND expires_at > ?`
 
 	var oldest sql.NullInt64
-	err := q.db.QueryRow(query, recipientHex, now).Scan(&oldest)
+	err := q.queryRow(query, recipientHex, now).Scan(&oldest)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get oldest message time: %v", err)
 	}
@@ -276,7 +477,7 @@ func (q *RelayMessageQueue) GetQueueStats() (map[string]interface{}, error) {
 	`
 
 	now := time.Now().Unix()
-	rows, err := q.db.Query(query, now)
+	rows, err := q.query(query, now)
 	if err != nil {
 		return nil, err
 	}
@@ -292,6 +493,32 @@ func (q *RelayMessageQueue) GetQueueStats() (map[string]interface{}, error) {
 		recipientCounts[addr] = count
 	}
 	stats["by_recipient"] = recipientCounts
+	rows.Close()
+
+	// Messages by tenant
+	query = `
+		SELECT tenant, COUNT(*) as count
+		FROM queued_messages
+		WHERE expires_at > ?
+		GROUP BY tenant
+	`
+
+	rows, err = q.query(query, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tenantCounts := make(map[string]int)
+	for rows.Next() {
+		var tenant string
+		var count int
+		if err := rows.Scan(&tenant, &count); err != nil {
+			return nil, err
+		}
+		tenantCounts[tenant] = count
+	}
+	stats["by_tenant"] = tenantCounts
 
 	return stats, nil
 }
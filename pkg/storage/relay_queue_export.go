@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// queueExportVersion is bumped whenever the export format changes
+const queueExportVersion = 1
+
+// QueueExport is the decrypted contents of a relay queue export file
+type QueueExport struct {
+	Version    int              `json:"version"`
+	ExportedAt int64            `json:"exported_at"`
+	Messages   []*QueuedMessage `json:"messages"`
+}
+
+// ExportQueue serializes all unexpired queued messages and encrypts them with passphrase
+// Payloads are already end-to-end encrypted; the outer encryption protects the
+// recipient addresses and queue timing while the file is in transit
+func (q *RelayMessageQueue) ExportQueue(passphrase string) ([]byte, int, error) {
+	if passphrase == "" {
+		return nil, 0, fmt.Errorf("export passphrase is required")
+	}
+
+	query := `
+		SELECT id, recipient_addr, message_id, encrypted_payload, timestamp, expires_at, attempts, tenant, priority
+		FROM queued_messages
+		WHERE expires_at > ?
+		ORDER BY timestamp ASC
+	`
+
+	rows, err := q.query(query, time.Now().Unix())
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read queue: %v", err)
+	}
+	defer rows.Close()
+
+	export := QueueExport{
+		Version:    queueExportVersion,
+		ExportedAt: time.Now().Unix(),
+	}
+
+	for rows.Next() {
+		msg := &QueuedMessage{}
+		if err := rows.Scan(&msg.ID, &msg.RecipientAddr, &msg.MessageID, &msg.EncryptedPayload, &msg.Timestamp, &msg.ExpiresAt, &msg.Attempts, &msg.Tenant, &msg.Priority); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan message: %v", err)
+		}
+		export.Messages = append(export.Messages, msg)
+	}
+
+	data, err := json.Marshal(&export)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to encode export: %v", err)
+	}
+
+	encrypted, err := crypto.AESEncrypt(data, deriveKey(passphrase))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to encrypt export: %v", err)
+	}
+
+	log.Printf("📦 Exported %d queued messages", len(export.Messages))
+	return encrypted, len(export.Messages), nil
+}
+
+// ImportQueue decrypts an export and adds its messages to this queue
+// Messages already present (same message ID) are skipped, and original expiry
+// times are kept so migration does not extend a message's lifetime
+func (q *RelayMessageQueue) ImportQueue(data []byte, passphrase string) (int, error) {
+	decrypted, err := crypto.AESDecrypt(data, deriveKey(passphrase))
+	if err != nil {
+		return 0, fmt.Errorf("failed to decrypt export (wrong passphrase?): %v", err)
+	}
+
+	var export QueueExport
+	if err := json.Unmarshal(decrypted, &export); err != nil {
+		return 0, fmt.Errorf("failed to decode export: %v", err)
+	}
+
+	if export.Version != queueExportVersion {
+		return 0, fmt.Errorf("%w of queue export: %d", protocol.ErrUnsupportedVersion, export.Version)
+	}
+
+	tx, err := q.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin import: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO queued_messages (recipient_addr, message_id, encrypted_payload, timestamp, expires_at, attempts, tenant, priority)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (message_id) DO NOTHING
+	`
+
+	now := time.Now().Unix()
+	imported := 0
+	for _, msg := range export.Messages {
+		if msg.ExpiresAt <= now {
+			continue
+		}
+
+		result, err := tx.Exec(q.rebind(query), msg.RecipientAddr, msg.MessageID, msg.EncryptedPayload, msg.Timestamp, msg.ExpiresAt, msg.Attempts, msg.Tenant, msg.Priority)
+		if err != nil {
+			return 0, fmt.Errorf("failed to import message %s: %v", msg.MessageID, err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			imported++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit import: %v", err)
+	}
+
+	log.Printf("📥 Imported %d/%d queued messages", imported, len(export.Messages))
+	return imported, nil
+}
+
+// GetQueuedRecipients returns each recipient with pending messages and their message count
+func (q *RelayMessageQueue) GetQueuedRecipients() (map[protocol.Address]int, error) {
+	query := `
+		SELECT recipient_addr, COUNT(*)
+		FROM queued_messages
+		WHERE expires_at > ?
+		GROUP BY recipient_addr
+	`
+
+	rows, err := q.query(query, time.Now().Unix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get queued recipients: %v", err)
+	}
+	defer rows.Close()
+
+	recipients := make(map[protocol.Address]int)
+	for rows.Next() {
+		var recipientHex string
+		var count int
+		if err := rows.Scan(&recipientHex, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan recipient: %v", err)
+		}
+
+		addrBytes, err := hex.DecodeString(recipientHex)
+		if err != nil || len(addrBytes) != len(protocol.Address{}) {
+			continue
+		}
+
+		var addr protocol.Address
+		copy(addr[:], addrBytes)
+		recipients[addr] = count
+	}
+
+	return recipients, nil
+}
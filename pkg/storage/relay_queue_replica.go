@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// replicaBatchSize bounds the message IDs in one IN (...) query
+const replicaBatchSize = 500
+
+// QueueReplica brings a standby relay's copy of the queue up to date with the primary's
+type QueueReplica struct {
+	Messages []*QueuedMessage `json:"messages"` // Queued on the primary but missing on the standby
+	Removed  []string         `json:"removed"`  // On the standby but delivered or expired on the primary
+}
+
+// QueuedMessageIDs returns the IDs of all unexpired queued messages
+func (q *RelayMessageQueue) QueuedMessageIDs() ([]string, error) {
+	rows, err := q.query(`SELECT message_id FROM queued_messages WHERE expires_at > ?`, time.Now().Unix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message IDs: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan message ID: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// DiffQueue returns what a copy of the queue holding the messages have needs to match it
+func (q *RelayMessageQueue) DiffQueue(have []string) (*QueueReplica, error) {
+	live, err := q.QueuedMessageIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	held := make(map[string]bool, len(have))
+	for _, id := range have {
+		held[id] = true
+	}
+
+	replica := &QueueReplica{}
+	var missing []string
+	for _, id := range live {
+		if held[id] {
+			delete(held, id)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	for id := range held {
+		replica.Removed = append(replica.Removed, id)
+	}
+
+	for start := 0; start < len(missing); start += replicaBatchSize {
+		end := min(start+replicaBatchSize, len(missing))
+		messages, err := q.messagesByID(missing[start:end])
+		if err != nil {
+			return nil, err
+		}
+		replica.Messages = append(replica.Messages, messages...)
+	}
+
+	return replica, nil
+}
+
+// messagesByID reads the queued messages with the given message IDs
+func (q *RelayMessageQueue) messagesByID(ids []string) ([]*QueuedMessage, error) {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	query := `
+		SELECT id, recipient_addr, message_id, encrypted_payload, timestamp, expires_at, attempts, tenant, priority
+		FROM queued_messages
+		WHERE message_id IN (?` + strings.Repeat(", ?", len(ids)-1) + `)
+		ORDER BY id ASC
+	`
+
+	rows, err := q.query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queued messages: %v", err)
+	}
+	defer rows.Close()
+
+	var messages []*QueuedMessage
+	for rows.Next() {
+		msg := &QueuedMessage{}
+		if err := rows.Scan(&msg.ID, &msg.RecipientAddr, &msg.MessageID, &msg.EncryptedPayload, &msg.Timestamp, &msg.ExpiresAt, &msg.Attempts, &msg.Tenant, &msg.Priority); err != nil {
+			return nil, fmt.Errorf("failed to scan message: %v", err)
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
+
+// ApplyReplica adds the replica's new messages and deletes its removed ones in one transaction
+// Messages keep their original expiry, as with ImportQueue.
+func (q *RelayMessageQueue) ApplyReplica(replica *QueueReplica) (added, removed int, err error) {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin replica update: %v", err)
+	}
+	defer tx.Rollback()
+
+	insert := q.rebind(`
+		INSERT INTO queued_messages (recipient_addr, message_id, encrypted_payload, timestamp, expires_at, attempts, tenant, priority)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (message_id) DO NOTHING
+	`)
+	for _, msg := range replica.Messages {
+		result, err := tx.Exec(insert, msg.RecipientAddr, msg.MessageID, msg.EncryptedPayload, msg.Timestamp, msg.ExpiresAt, msg.Attempts, msg.Tenant, msg.Priority)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to add message %s: %v", msg.MessageID, err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			added++
+		}
+	}
+
+	remove := q.rebind(`DELETE FROM queued_messages WHERE message_id = ?`)
+	for _, id := range replica.Removed {
+		result, err := tx.Exec(remove, id)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to remove message %s: %v", id, err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			removed++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit replica update: %v", err)
+	}
+
+	if added > 0 || removed > 0 {
+		log.Printf("🪞 Replicated queue: %d messages added, %d removed", added, removed)
+	}
+	return added, removed, nil
+}
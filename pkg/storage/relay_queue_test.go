@@ -1,8 +1,12 @@
 package storage
 
 import (
+	"database/sql"
+	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
 )
 
 func TestBucketTimestamp(t *testing.T) {
@@ -158,6 +162,285 @@ func TestBucketTimestampEdgeCases(t *testing.T) {
 	}
 }
 
+func TestQueueExportImport(t *testing.T) {
+	dir := t.TempDir()
+
+	oldQueue, err := NewRelayMessageQueue(filepath.Join(dir, "old.db"), time.Hour)
+	if err != nil {
+		t.Fatalf("NewRelayMessageQueue() error = %v", err)
+	}
+	defer oldQueue.Close()
+
+	recipient := protocol.Address{1, 2, 3}
+	for i := byte(0); i < 3; i++ {
+		if err := oldQueue.QueueMessage(recipient, [16]byte{i}, []byte{0xAA, i}); err != nil {
+			t.Fatalf("QueueMessage() error = %v", err)
+		}
+	}
+
+	data, count, err := oldQueue.ExportQueue("migration-secret")
+	if err != nil {
+		t.Fatalf("ExportQueue() error = %v", err)
+	}
+	if count != 3 {
+		t.Errorf("ExportQueue() count = %d, want 3", count)
+	}
+
+	newQueue, err := NewRelayMessageQueue(filepath.Join(dir, "new.db"), time.Hour)
+	if err != nil {
+		t.Fatalf("NewRelayMessageQueue() error = %v", err)
+	}
+	defer newQueue.Close()
+
+	if _, err := newQueue.ImportQueue(data, "wrong-secret"); err == nil {
+		t.Error("ImportQueue() with wrong passphrase expected error, got nil")
+	}
+
+	imported, err := newQueue.ImportQueue(data, "migration-secret")
+	if err != nil {
+		t.Fatalf("ImportQueue() error = %v", err)
+	}
+	if imported != 3 {
+		t.Errorf("ImportQueue() imported = %d, want 3", imported)
+	}
+
+	// Importing twice must not duplicate messages
+	if imported, _ := newQueue.ImportQueue(data, "migration-secret"); imported != 0 {
+		t.Errorf("Second ImportQueue() imported = %d, want 0", imported)
+	}
+
+	recipients, err := newQueue.GetQueuedRecipients()
+	if err != nil {
+		t.Fatalf("GetQueuedRecipients() error = %v", err)
+	}
+	if recipients[recipient] != 3 {
+		t.Errorf("GetQueuedRecipients()[recipient] = %d, want 3", recipients[recipient])
+	}
+}
+
+func TestQueueReplica(t *testing.T) {
+	dir := t.TempDir()
+
+	primary, err := NewRelayMessageQueue(filepath.Join(dir, "primary.db"), time.Hour)
+	if err != nil {
+		t.Fatalf("NewRelayMessageQueue() error = %v", err)
+	}
+	defer primary.Close()
+
+	standby, err := NewRelayMessageQueue(filepath.Join(dir, "standby.db"), time.Hour)
+	if err != nil {
+		t.Fatalf("NewRelayMessageQueue() error = %v", err)
+	}
+	defer standby.Close()
+
+	recipient := protocol.Address{1, 2, 3}
+	for i := byte(0); i < 3; i++ {
+		if err := primary.QueueMessage(recipient, [16]byte{i}, []byte{0xAA, i}); err != nil {
+			t.Fatalf("QueueMessage() error = %v", err)
+		}
+	}
+
+	// sync brings the standby up to date and returns the IDs it then holds
+	sync := func() []string {
+		t.Helper()
+		have, err := standby.QueuedMessageIDs()
+		if err != nil {
+			t.Fatalf("QueuedMessageIDs() error = %v", err)
+		}
+		replica, err := primary.DiffQueue(have)
+		if err != nil {
+			t.Fatalf("DiffQueue() error = %v", err)
+		}
+		if _, _, err := standby.ApplyReplica(replica); err != nil {
+			t.Fatalf("ApplyReplica() error = %v", err)
+		}
+		ids, err := standby.QueuedMessageIDs()
+		if err != nil {
+			t.Fatalf("QueuedMessageIDs() error = %v", err)
+		}
+		return ids
+	}
+
+	if ids := sync(); len(ids) != 3 {
+		t.Fatalf("standby holds %d messages, want 3", len(ids))
+	}
+
+	// Delivered on the primary, then one more queued
+	messages, err := primary.GetQueuedMessages(recipient)
+	if err != nil {
+		t.Fatalf("GetQueuedMessages() error = %v", err)
+	}
+	if err := primary.DeleteMessage(messages[0].MessageID); err != nil {
+		t.Fatalf("DeleteMessage() error = %v", err)
+	}
+	if err := primary.QueueMessage(recipient, [16]byte{9}, []byte{0xBB}); err != nil {
+		t.Fatalf("QueueMessage() error = %v", err)
+	}
+
+	have, _ := standby.QueuedMessageIDs()
+	replica, err := primary.DiffQueue(have)
+	if err != nil {
+		t.Fatalf("DiffQueue() error = %v", err)
+	}
+	if len(replica.Messages) != 1 || len(replica.Removed) != 1 {
+		t.Errorf("DiffQueue() = %d messages, %d removed, want 1 and 1", len(replica.Messages), len(replica.Removed))
+	}
+
+	ids := sync()
+	want, _ := primary.QueuedMessageIDs()
+	if len(ids) != len(want) {
+		t.Fatalf("standby holds %d messages, want %d", len(ids), len(want))
+	}
+	held := make(map[string]bool)
+	for _, id := range ids {
+		held[id] = true
+	}
+	for _, id := range want {
+		if !held[id] {
+			t.Errorf("standby is missing message %s", id)
+		}
+	}
+}
+
+func TestQueueTenants(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.db")
+
+	// A queue created before tenancy, without the tenant column
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TABLE queued_messages (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		recipient_addr TEXT NOT NULL,
+		message_id TEXT UNIQUE NOT NULL,
+		encrypted_payload BLOB NOT NULL,
+		timestamp INTEGER NOT NULL,
+		expires_at INTEGER NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0
+	)`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	queue, err := NewRelayMessageQueue(path, time.Hour)
+	if err != nil {
+		t.Fatalf("NewRelayMessageQueue() error = %v", err)
+	}
+	defer queue.Close()
+
+	recipient := protocol.Address{4}
+	if err := queue.QueueMessage(recipient, [16]byte{1}, []byte{1}); err != nil {
+		t.Fatalf("QueueMessage() error = %v", err)
+	}
+	if err := queue.QueueTenantMessage("acme", recipient, [16]byte{2}, []byte{2}); err != nil {
+		t.Fatalf("QueueTenantMessage() error = %v", err)
+	}
+
+	if n, err := queue.GetTenantQueueSize("acme"); err != nil || n != 1 {
+		t.Errorf("GetTenantQueueSize(acme) = %d, %v; want 1", n, err)
+	}
+	if n, err := queue.GetTenantQueueSize(""); err != nil || n != 1 {
+		t.Errorf("GetTenantQueueSize(\"\") = %d, %v; want 1", n, err)
+	}
+
+	messages, err := queue.GetQueuedMessages(recipient)
+	if err != nil || len(messages) != 2 {
+		t.Fatalf("GetQueuedMessages() = %d messages, %v", len(messages), err)
+	}
+	if messages[0].Tenant != "" || messages[1].Tenant != "acme" {
+		t.Errorf("tenants = %q, %q", messages[0].Tenant, messages[1].Tenant)
+	}
+}
+
+func TestQueuePriority(t *testing.T) {
+	queue, err := NewRelayMessageQueue(filepath.Join(t.TempDir(), "queue.db"), time.Hour)
+	if err != nil {
+		t.Fatalf("NewRelayMessageQueue() error = %v", err)
+	}
+	defer queue.Close()
+
+	recipient := protocol.Address{5}
+	for i, priority := range []int{5, 0, 0, 9} {
+		if err := queue.QueuePriorityMessage("", recipient, [16]byte{byte(i)}, []byte{byte(i)}, priority, 48*time.Hour); err != nil {
+			t.Fatalf("QueuePriorityMessage() error = %v", err)
+		}
+	}
+
+	// No message is below priority 0
+	if evicted, err := queue.EvictLowerPriority(0); err != nil || evicted {
+		t.Errorf("EvictLowerPriority(0) = %v, %v; want false", evicted, err)
+	}
+
+	// The oldest of the lowest-priority messages goes first
+	if evicted, err := queue.EvictLowerPriority(5); err != nil || !evicted {
+		t.Fatalf("EvictLowerPriority(5) = %v, %v; want true", evicted, err)
+	}
+
+	messages, err := queue.GetQueuedMessages(recipient)
+	if err != nil || len(messages) != 3 {
+		t.Fatalf("GetQueuedMessages() = %d messages, %v", len(messages), err)
+	}
+	for i, want := range []struct {
+		payload  byte
+		priority int
+	}{{0, 5}, {2, 0}, {3, 9}} {
+		if messages[i].EncryptedPayload[0] != want.payload || messages[i].Priority != want.priority {
+			t.Errorf("messages[%d] = payload %d, priority %d; want %d, %d",
+				i, messages[i].EncryptedPayload[0], messages[i].Priority, want.payload, want.priority)
+		}
+	}
+
+	// The longer TTL outlives the queue's default
+	if ttl := time.Until(time.Unix(messages[0].ExpiresAt, 0)); ttl < 47*time.Hour {
+		t.Errorf("ExpiresAt is %s away, want about 48h", ttl)
+	}
+}
+
+func TestQueuePaging(t *testing.T) {
+	queue, err := NewRelayMessageQueue(filepath.Join(t.TempDir(), "queue.db"), time.Hour)
+	if err != nil {
+		t.Fatalf("NewRelayMessageQueue() error = %v", err)
+	}
+	defer queue.Close()
+
+	recipient, other := protocol.Address{6}, protocol.Address{7}
+	for i := 0; i < 5; i++ {
+		if err := queue.QueueMessage(recipient, [16]byte{byte(i)}, []byte{byte(i)}); err != nil {
+			t.Fatalf("QueueMessage() error = %v", err)
+		}
+	}
+	if err := queue.QueueMessage(other, [16]byte{9}, []byte{9}); err != nil {
+		t.Fatalf("QueueMessage() error = %v", err)
+	}
+
+	page, err := queue.GetQueuedMessagesAfter(recipient, 0, 0, 2)
+	if err != nil || len(page) != 2 || page[0].EncryptedPayload[0] != 0 || page[1].EncryptedPayload[0] != 1 {
+		t.Fatalf("first page = %d messages, %v", len(page), err)
+	}
+	if n, err := queue.CountQueuedMessagesAfter(recipient, page[1].ID, 0); err != nil || n != 3 {
+		t.Errorf("CountQueuedMessagesAfter() = %d, %v; want 3", n, err)
+	}
+
+	// Acknowledging the first page removes it, and only it
+	if n, err := queue.DeleteMessagesThrough(recipient, page[1].ID); err != nil || n != 2 {
+		t.Fatalf("DeleteMessagesThrough() = %d, %v; want 2", n, err)
+	}
+	page, err = queue.GetQueuedMessagesAfter(recipient, page[1].ID, 0, 10)
+	if err != nil || len(page) != 3 || page[0].EncryptedPayload[0] != 2 {
+		t.Fatalf("second page = %d messages, %v", len(page), err)
+	}
+	if n, err := queue.GetQueuedMessageCount(other); err != nil || n != 1 {
+		t.Errorf("other recipient has %d messages, %v; want 1", n, err)
+	}
+
+	// Everything was queued this hour
+	future := time.Now().Add(2 * time.Hour).Unix()
+	if page, err := queue.GetQueuedMessagesAfter(recipient, 0, future, 10); err != nil || len(page) != 0 {
+		t.Errorf("GetQueuedMessagesAfter(since future) = %d messages, %v; want none", len(page), err)
+	}
+}
+
 func BenchmarkBucketTimestamp(b *testing.B) {
 	now := time.Now().Unix()
 
@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+)
+
+// ===== THREAD & MENTION OPERATIONS =====
+
+// GetGroupConversationID returns the conversation ID used for a group's messages
+func GetGroupConversationID(groupIDHex string) string {
+	return "group-" + groupIDHex
+}
+
+// GetThreadReplies retrieves replies to a message, oldest first
+func (db *MessageDB) GetThreadReplies(parentMessageID string, limit, offset int) ([]*StoredMessage, error) {
+	query := `
+		SELECT id, conversation_id, message_id, from_address, to_address,
+		       content, content_type, timestamp, status, is_outgoing,
+		       mesh_chunk_id, encryption_key, reply_to_id,
+		       thread_parent_id, mentions,
+		       forwarded_from, forwarded_at, forward_hops
+		FROM messages
+		WHERE thread_parent_id = ?
+		ORDER BY timestamp ASC
+		LIMIT ? OFFSET ?
+	`
+
+	rows, err := db.db.Query(query, parentMessageID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return db.scanMessages(rows)
+}
+
+// CountThreadReplies returns the number of replies to a message
+func (db *MessageDB) CountThreadReplies(parentMessageID string) (int, error) {
+	var count int
+	err := db.db.QueryRow(`SELECT COUNT(*) FROM messages WHERE thread_parent_id = ?`, parentMessageID).Scan(&count)
+	return count, err
+}
+
+// GetMentions retrieves messages that mention an address, newest first
+func (db *MessageDB) GetMentions(address string, limit int) ([]*StoredMessage, error) {
+	query := `
+		SELECT id, conversation_id, message_id, from_address, to_address,
+		       content, content_type, timestamp, status, is_outgoing,
+		       mesh_chunk_id, encryption_key, reply_to_id,
+		       thread_parent_id, mentions,
+		       forwarded_from, forwarded_at, forward_hops
+		FROM messages
+		WHERE ',' || mentions || ',' LIKE ?
+		ORDER BY timestamp DESC
+		LIMIT ?
+	`
+
+	rows, err := db.db.Query(query, "%,"+strings.ToLower(address)+",%", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return db.scanMessages(rows)
+}
+
+// GetMessagesByContentType retrieves a conversation's messages of one content type, newest first
+func (db *MessageDB) GetMessagesByContentType(conversationID string, contentType uint8, limit, offset int) ([]*StoredMessage, error) {
+	query := `
+		SELECT id, conversation_id, message_id, from_address, to_address,
+		       content, content_type, timestamp, status, is_outgoing,
+		       mesh_chunk_id, encryption_key, reply_to_id,
+		       thread_parent_id, mentions,
+		       forwarded_from, forwarded_at, forward_hops
+		FROM messages
+		WHERE conversation_id = ? AND content_type = ?
+		ORDER BY timestamp DESC
+		LIMIT ? OFFSET ?
+	`
+
+	rows, err := db.db.Query(query, conversationID, contentType, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return db.scanMessages(rows)
+}
+
+// scanMessages reads and decrypts message rows selected with thread columns
+func (db *MessageDB) scanMessages(rows *sql.Rows) ([]*StoredMessage, error) {
+	var messages []*StoredMessage
+
+	for rows.Next() {
+		var msg StoredMessage
+		var encryptedContent []byte
+		var encryptedMeshKey []byte
+		var isOutgoing int
+		var mentions string
+
+		err := rows.Scan(
+			&msg.ID,
+			&msg.ConversationID,
+			&msg.MessageID,
+			&msg.FromAddress,
+			&msg.ToAddress,
+			&encryptedContent,
+			&msg.ContentType,
+			&msg.Timestamp,
+			&msg.Status,
+			&isOutgoing,
+			&msg.MeshChunkID,
+			&encryptedMeshKey,
+			&msg.ReplyToID,
+			&msg.ThreadParentID,
+			&mentions,
+			&msg.ForwardedFrom,
+			&msg.ForwardedAt,
+			&msg.ForwardHops,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		msg.IsOutgoing = intToBool(isOutgoing)
+		msg.Mentions = splitMentions(mentions)
+
+		msg.Content, err = crypto.AESDecrypt(encryptedContent, db.encryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt content: %v", err)
+		}
+
+		if len(encryptedMeshKey) > 0 {
+			msg.EncryptionKey, err = crypto.AESDecrypt(encryptedMeshKey, db.encryptionKey)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt mesh key: %v", err)
+			}
+		}
+
+		messages = append(messages, &msg)
+	}
+
+	return messages, rows.Err()
+}
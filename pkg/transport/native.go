@@ -0,0 +1,206 @@
+//go:build !js
+
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// upgradeTimeout bounds how long a client may take to send the upgrade request
+const upgradeTimeout = 10 * time.Second
+
+// upgrader accepts WebSocket connections from any origin
+// Relay connections carry no cookies or ambient credentials, so there is nothing
+// for a cross-origin page to abuse; browser clients are served from anywhere.
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  32 * 1024,
+	WriteBufferSize: 32 * 1024,
+	CheckOrigin:     func(*http.Request) bool { return true },
+}
+
+// dialTCP connects to host:port
+func dialTCP(ctx context.Context, address string) (net.Conn, error) {
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", address)
+}
+
+// DialWebSocket connects to a relay's WebSocket endpoint (ws:// or wss:// URL)
+func DialWebSocket(ctx context.Context, url string) (net.Conn, error) {
+	ws, _, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("websocket dial %s: %w", url, err)
+	}
+	return newWSConn(ws), nil
+}
+
+// wsConn presents a WebSocket as a byte stream
+type wsConn struct {
+	ws     *websocket.Conn
+	reader io.Reader // Unread rest of the current message
+
+	readMu  sync.Mutex
+	writeMu sync.Mutex
+}
+
+// newWSConn wraps a WebSocket
+func newWSConn(ws *websocket.Conn) *wsConn {
+	return &wsConn{ws: ws}
+}
+
+// Read reads message bytes, moving on to the next message when one is exhausted
+func (c *wsConn) Read(p []byte) (int, error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+
+	for {
+		if c.reader == nil {
+			_, reader, err := c.ws.NextReader()
+			if err != nil {
+				if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					return 0, io.EOF
+				}
+				return 0, err
+			}
+			c.reader = reader
+		}
+
+		n, err := c.reader.Read(p)
+		if errors.Is(err, io.EOF) {
+			c.reader = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+// Write sends p as one binary message
+func (c *wsConn) Write(p []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err := c.ws.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close closes the underlying connection without waiting for the close handshake
+func (c *wsConn) Close() error {
+	return c.ws.Close()
+}
+
+// LocalAddr returns the local network address
+func (c *wsConn) LocalAddr() net.Addr {
+	return c.ws.LocalAddr()
+}
+
+// RemoteAddr returns the remote network address (the peer's IP, not its origin)
+func (c *wsConn) RemoteAddr() net.Addr {
+	return c.ws.RemoteAddr()
+}
+
+// SetDeadline sets the read and write deadlines
+func (c *wsConn) SetDeadline(t time.Time) error {
+	if err := c.ws.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.ws.SetWriteDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline
+// As with TCP, a connection whose read timed out should be closed.
+func (c *wsConn) SetReadDeadline(t time.Time) error {
+	return c.ws.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the write deadline
+func (c *wsConn) SetWriteDeadline(t time.Time) error {
+	return c.ws.SetWriteDeadline(t)
+}
+
+// wsListener accepts WebSocket connections as net.Conns
+type wsListener struct {
+	listener net.Listener
+	server   *http.Server
+	conns    chan net.Conn
+	closed   chan struct{}
+	once     sync.Once
+}
+
+// ListenWebSocket listens for WebSocket connections at WebSocketPath
+// network and address are passed to net.Listen. Accepted connections are
+// returned by Accept like those of a TCP listener.
+func ListenWebSocket(network, address string) (net.Listener, error) {
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	wl := &wsListener{
+		listener: listener,
+		conns:    make(chan net.Conn),
+		closed:   make(chan struct{}),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(WebSocketPath, wl.upgrade)
+	wl.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: upgradeTimeout,
+	}
+
+	go wl.server.Serve(listener)
+
+	return wl, nil
+}
+
+// upgrade upgrades an HTTP request and hands the connection to Accept
+func (l *wsListener) upgrade(w http.ResponseWriter, r *http.Request) {
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // Upgrade already replied with an HTTP error
+	}
+
+	select {
+	case l.conns <- newWSConn(ws):
+	case <-l.closed:
+		ws.Close()
+	}
+}
+
+// Accept waits for the next WebSocket connection
+func (l *wsListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close stops accepting connections; established connections stay open
+func (l *wsListener) Close() error {
+	var err error
+	l.once.Do(func() {
+		close(l.closed)
+		err = l.server.Close()
+	})
+	return err
+}
+
+// Addr returns the listener's network address
+func (l *wsListener) Addr() net.Addr {
+	return l.listener.Addr()
+}
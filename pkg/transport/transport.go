@@ -0,0 +1,38 @@
+// Package transport dials and accepts relay connections over TCP or WebSocket
+//
+// Relay connections are byte streams of protocol frames. Browsers can only open
+// WebSockets, so relays may also accept WebSocket connections, and this package
+// presents both kinds as net.Conn: every Write is sent as one binary message and
+// Read returns message bytes in order, ignoring message boundaries.
+//
+// The WebSocket client uses the browser's WebSocket API when built with
+// GOOS=js GOARCH=wasm and gorilla/websocket everywhere else. Plain TCP and the
+// WebSocket listener are not available in the browser.
+package transport
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+)
+
+// WebSocketPath is the HTTP path relays accept WebSocket connections on
+const WebSocketPath = "/ws"
+
+// ErrTCPUnsupported is returned when dialing a host:port from the browser
+var ErrTCPUnsupported = errors.New("plain TCP is not available in the browser; use a ws:// or wss:// relay URL")
+
+// IsWebSocketURL reports whether address is a ws:// or wss:// URL
+func IsWebSocketURL(address string) bool {
+	return strings.HasPrefix(address, "ws://") || strings.HasPrefix(address, "wss://")
+}
+
+// Dial connects to a relay
+// ws:// and wss:// URLs are dialed over WebSocket, anything else (host:port) over TCP.
+func Dial(ctx context.Context, address string) (net.Conn, error) {
+	if IsWebSocketURL(address) {
+		return DialWebSocket(ctx, address)
+	}
+	return dialTCP(ctx, address)
+}
@@ -0,0 +1,241 @@
+//go:build js && wasm
+
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"syscall/js"
+	"time"
+)
+
+// WebSocket readyState values
+const (
+	wsOpen = 1
+)
+
+// wsAddr is the address of a WebSocket endpoint
+type wsAddr string
+
+// Network implements net.Addr
+func (a wsAddr) Network() string { return "websocket" }
+
+// String implements net.Addr
+func (a wsAddr) String() string { return string(a) }
+
+// dialTCP fails: browsers cannot open raw sockets
+func dialTCP(context.Context, string) (net.Conn, error) {
+	return nil, ErrTCPUnsupported
+}
+
+// DialWebSocket connects to a relay's WebSocket endpoint with the browser's WebSocket API
+func DialWebSocket(ctx context.Context, url string) (conn net.Conn, err error) {
+	// The constructor throws on malformed URLs or blocked ports
+	defer func() {
+		if r := recover(); r != nil {
+			conn, err = nil, fmt.Errorf("websocket dial %s: %v", url, r)
+		}
+	}()
+
+	ws := js.Global().Get("WebSocket").New(url)
+	ws.Set("binaryType", "arraybuffer")
+
+	c := &jsConn{
+		ws:     ws,
+		url:    url,
+		notify: make(chan struct{}, 1),
+	}
+
+	opened := make(chan struct{})
+	var openOnce sync.Once
+
+	c.onOpen = js.FuncOf(func(js.Value, []js.Value) any {
+		openOnce.Do(func() { close(opened) })
+		return nil
+	})
+	c.onMessage = js.FuncOf(func(_ js.Value, args []js.Value) any {
+		data := js.Global().Get("Uint8Array").New(args[0].Get("data"))
+		buf := make([]byte, data.Get("length").Int())
+		js.CopyBytesToGo(buf, data)
+		c.push(buf)
+		return nil
+	})
+	c.onClose = js.FuncOf(func(js.Value, []js.Value) any {
+		c.fail(io.EOF)
+		openOnce.Do(func() { close(opened) })
+		return nil
+	})
+
+	ws.Set("onopen", c.onOpen)
+	ws.Set("onmessage", c.onMessage)
+	ws.Set("onclose", c.onClose)
+
+	// Callbacks run on the JS event loop, which only turns while Go blocks
+	select {
+	case <-opened:
+	case <-ctx.Done():
+		c.Close()
+		return nil, ctx.Err()
+	}
+
+	if ws.Get("readyState").Int() != wsOpen {
+		c.Close()
+		return nil, fmt.Errorf("websocket dial %s: connection refused", url)
+	}
+	return c, nil
+}
+
+// jsConn presents a browser WebSocket as a byte stream
+// JS callbacks must not block, so incoming messages are queued under a mutex
+// and readers are woken through a one-slot channel.
+type jsConn struct {
+	ws  js.Value
+	url string
+
+	onOpen    js.Func
+	onMessage js.Func
+	onClose   js.Func
+
+	queue        [][]byte
+	err          error // Set once the socket closed
+	readDeadline time.Time
+	notify       chan struct{}
+
+	mu        sync.Mutex
+	closeOnce sync.Once
+}
+
+// push queues a received message
+func (c *jsConn) push(data []byte) {
+	c.mu.Lock()
+	c.queue = append(c.queue, data)
+	c.mu.Unlock()
+	c.wake()
+}
+
+// fail records the error returned once the queue is drained
+func (c *jsConn) fail(err error) {
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = err
+	}
+	c.mu.Unlock()
+	c.wake()
+}
+
+// wake wakes a blocked reader without blocking the caller
+func (c *jsConn) wake() {
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Read reads queued message bytes, waiting for the next message if none are queued
+func (c *jsConn) Read(p []byte) (int, error) {
+	for {
+		c.mu.Lock()
+		if len(c.queue) > 0 {
+			n := copy(p, c.queue[0])
+			if n == len(c.queue[0]) {
+				c.queue = c.queue[1:]
+			} else {
+				c.queue[0] = c.queue[0][n:]
+			}
+			c.mu.Unlock()
+			return n, nil
+		}
+		err, deadline := c.err, c.readDeadline
+		c.mu.Unlock()
+
+		if err != nil {
+			return 0, err
+		}
+
+		var timeout <-chan time.Time
+		if !deadline.IsZero() {
+			wait := time.Until(deadline)
+			if wait <= 0 {
+				return 0, os.ErrDeadlineExceeded
+			}
+			timer := time.NewTimer(wait)
+			timeout = timer.C
+			defer timer.Stop()
+		}
+
+		select {
+		case <-c.notify:
+		case <-timeout:
+			return 0, os.ErrDeadlineExceeded
+		}
+	}
+}
+
+// Write sends p as one binary message
+func (c *jsConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	err := c.err
+	c.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	if c.ws.Get("readyState").Int() != wsOpen {
+		return 0, errors.New("websocket is not open")
+	}
+
+	data := js.Global().Get("Uint8Array").New(len(p))
+	js.CopyBytesToJS(data, p)
+	c.ws.Call("send", data)
+
+	return len(p), nil
+}
+
+// Close closes the socket and releases the JS callbacks
+func (c *jsConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.fail(net.ErrClosed)
+		c.ws.Call("close")
+		c.ws.Set("onopen", js.Null())
+		c.ws.Set("onmessage", js.Null())
+		c.ws.Set("onclose", js.Null())
+		c.onOpen.Release()
+		c.onMessage.Release()
+		c.onClose.Release()
+	})
+	return nil
+}
+
+// LocalAddr returns a placeholder; the browser does not expose it
+func (c *jsConn) LocalAddr() net.Addr {
+	return wsAddr("browser")
+}
+
+// RemoteAddr returns the relay URL
+func (c *jsConn) RemoteAddr() net.Addr {
+	return wsAddr(c.url)
+}
+
+// SetDeadline sets the read deadline; writes never block in the browser
+func (c *jsConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline
+func (c *jsConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.readDeadline = t
+	c.mu.Unlock()
+	c.wake()
+	return nil
+}
+
+// SetWriteDeadline is a no-op: the browser buffers sends
+func (c *jsConn) SetWriteDeadline(time.Time) error {
+	return nil
+}
@@ -0,0 +1,399 @@
+// Package webclient is a lightweight ZenTalk client that also runs in the browser
+//
+// It depends only on pkg/protocol, pkg/crypto and pkg/transport, which all
+// compile to WebAssembly, and talks to relays over WebSocket (relays started
+// with -ws-port). See cmd/webclient for the JavaScript bindings:
+//
+//	GOOS=js GOARCH=wasm go build -o zentalk.wasm ./cmd/webclient
+//
+// Messages use the same wire format as network.Client: RSA end-to-end
+// encryption inside onion layers. Ratchet sessions, groups management and
+// message history are left to the full client; the host page keeps its own.
+package webclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+	"github.com/ZentaChain/zentalk-node/pkg/transport"
+)
+
+// pingInterval keeps the connection under the relay's idle timeout
+const pingInterval = 30 * time.Second
+
+var (
+	ErrNotConnected    = errors.New("not connected")
+	ErrHandshakeFailed = errors.New("handshake failed")
+)
+
+// Client is a ZenTalk client connected to one relay
+type Client struct {
+	Address    protocol.Address
+	PrivateKey *rsa.PrivateKey
+
+	conn   net.Conn
+	relay  *crypto.RelayInfo       // Entry relay, learned in the handshake
+	limits *protocol.PayloadLimits // Negotiated in the handshake
+	done   chan struct{}
+
+	store     Storage
+	sequences map[protocol.Address]uint64 // Next send sequence number per recipient
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+
+	// Callbacks, run on the receive goroutine
+	OnMessage      func(*protocol.DirectMessage)
+	OnGroupMessage func(*protocol.GroupMessage)
+	OnDisconnect   func(error)
+}
+
+// New creates a client
+// store keeps send sequence numbers across page reloads, so recipients don't
+// discard new messages as replays; nil keeps them in memory only.
+func New(address protocol.Address, privateKey *rsa.PrivateKey, store Storage) (*Client, error) {
+	sequences, err := loadSequences(store)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		Address:    address,
+		PrivateKey: privateKey,
+		store:      store,
+		sequences:  sequences,
+	}, nil
+}
+
+// Connect dials a relay (ws:// or wss:// URL in the browser), handshakes and starts receiving
+func (c *Client) Connect(ctx context.Context, relayURL string) error {
+	conn, err := transport.Dial(ctx, relayURL)
+	if err != nil {
+		return err
+	}
+
+	if err := c.handshake(conn); err != nil {
+		conn.Close()
+		return err
+	}
+
+	c.mu.Lock()
+	c.conn = conn
+	c.done = make(chan struct{})
+	done := c.done
+	c.mu.Unlock()
+
+	log.Printf("Connected to relay %s", relayURL)
+
+	go c.receiveLoop(conn, done)
+	go c.keepaliveLoop(done)
+
+	return nil
+}
+
+// Close disconnects from the relay
+func (c *Client) Close() error {
+	c.mu.Lock()
+	conn, done := c.conn, c.done
+	c.conn, c.done = nil, nil
+	c.mu.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+	close(done)
+	return conn.Close()
+}
+
+// IsConnected reports whether the client is connected
+func (c *Client) IsConnected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn != nil
+}
+
+// Relay returns the entry relay (nil before Connect)
+// It is the default one-hop path for SendMessage.
+func (c *Client) Relay() *crypto.RelayInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.relay
+}
+
+// SendText sends a text message
+func (c *Client) SendText(to protocol.Address, recipientPubKey *rsa.PublicKey, text string, relayPath []*crypto.RelayInfo) error {
+	return c.SendMessage(to, recipientPubKey, []byte(text), protocol.ContentTypeText, relayPath)
+}
+
+// SendMessage sends a message through relayPath (nil = the entry relay only)
+func (c *Client) SendMessage(to protocol.Address, recipientPubKey *rsa.PublicKey, content []byte, contentType uint8, relayPath []*crypto.RelayInfo) error {
+	if !c.IsConnected() {
+		return ErrNotConnected
+	}
+
+	if err := protocol.DefaultContentTypes.Validate(contentType, content); err != nil {
+		return err
+	}
+
+	if len(relayPath) == 0 {
+		relayPath = []*crypto.RelayInfo{c.Relay()}
+	}
+
+	msg := &protocol.DirectMessage{
+		From:           c.Address,
+		To:             to,
+		Timestamp:      uint64(time.Now().UnixMilli()),
+		SequenceNumber: c.nextSequence(to),
+		ContentType:    contentType,
+		Content:        content,
+	}
+
+	// Tag with the message type, encrypt with recipient's public key, then wrap in onion layers
+	payload := protocol.TagPayload(protocol.MsgTypeDirectMessage, msg.Encode())
+	encryptedMsg, err := crypto.RSAEncrypt(payload, recipientPubKey)
+	if err != nil {
+		return err
+	}
+
+	onion, err := crypto.BuildOnionLayers(relayPath, to, encryptedMsg)
+	if err != nil {
+		return err
+	}
+
+	return c.writeFrame(protocol.MsgTypeRelayForward, protocol.FlagEncrypted, onion)
+}
+
+// nextSequence returns and persists the next sequence number for a recipient
+func (c *Client) nextSequence(to protocol.Address) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	seq := c.sequences[to]
+	c.sequences[to] = seq + 1
+
+	if err := saveSequences(c.store, c.sequences); err != nil {
+		log.Printf("⚠️  Failed to persist sequence numbers: %v", err)
+	}
+	return seq
+}
+
+// handshake identifies us to the relay and records its key and payload limits
+func (c *Client) handshake(conn net.Conn) error {
+	pubKeyPEM, err := crypto.ExportPublicKeyPEM(&c.PrivateKey.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	hs := &protocol.HandshakeMessage{
+		ProtocolVersion: protocol.ProtocolVersion,
+		Address:         c.Address,
+		PublicKey:       pubKeyPEM,
+		ClientType:      protocol.ClientTypeUser,
+		Timestamp:       uint64(time.Now().Unix()),
+		Limits:          protocol.DefaultPayloadLimits(),
+	}
+
+	if _, err := conn.Write(encodeFrame(protocol.MsgTypeHandshake, 0, hs.Encode())); err != nil {
+		return err
+	}
+
+	ackHeader, err := protocol.ReadHeader(conn)
+	if err != nil {
+		return err
+	}
+	if ackHeader.Type != protocol.MsgTypeHandshakeAck {
+		return ErrHandshakeFailed
+	}
+	if err := hs.Limits.Check(ackHeader); err != nil {
+		return err
+	}
+
+	payload := make([]byte, ackHeader.Length)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return err
+	}
+
+	var ack protocol.HandshakeMessage
+	if err := protocol.DecodePayload(payload, ackHeader.Flags, &ack); err != nil {
+		return fmt.Errorf("%w: %v", ErrHandshakeFailed, err)
+	}
+
+	relayKey, err := crypto.ImportPublicKeyPEM(ack.PublicKey)
+	if err != nil {
+		return fmt.Errorf("%w: relay public key: %v", ErrHandshakeFailed, err)
+	}
+
+	c.mu.Lock()
+	c.relay = &crypto.RelayInfo{Address: ack.Address, PublicKey: relayKey, PaddingBuckets: ack.Policy.Buckets()}
+	c.limits = protocol.NegotiatePayloadLimits(hs.Limits, ack.Limits)
+	c.mu.Unlock()
+
+	return nil
+}
+
+// receiveLoop reads frames until the connection closes
+func (c *Client) receiveLoop(conn net.Conn, done chan struct{}) {
+	err := c.receive(conn)
+
+	select {
+	case <-done:
+		return // Closed by us
+	default:
+	}
+
+	log.Printf("Relay connection lost: %v", err)
+	c.Close()
+	if c.OnDisconnect != nil {
+		c.OnDisconnect(err)
+	}
+}
+
+// receive handles frames until a read fails
+func (c *Client) receive(conn net.Conn) error {
+	c.mu.Lock()
+	limits := c.limits
+	c.mu.Unlock()
+
+	for {
+		header, err := protocol.ReadHeader(conn)
+		if err != nil {
+			return err
+		}
+
+		// A relay announcing more than we negotiated is broken or hostile; don't read it
+		if err := limits.Check(header); err != nil {
+			return err
+		}
+
+		payload := make([]byte, header.Length)
+		if _, err := io.ReadFull(conn, payload); err != nil {
+			return err
+		}
+
+		// Anything else (pongs, acks, relay errors) needs no handling here
+		if header.Type == protocol.MsgTypeDirectMessage {
+			c.handleDirectMessage(payload)
+		}
+	}
+}
+
+// handleDirectMessage decrypts a delivery and dispatches it as a direct or group message
+func (c *Client) handleDirectMessage(payload []byte) {
+	plaintext, err := crypto.RSADecrypt(payload, c.PrivateKey)
+	if err != nil {
+		log.Printf("Decrypt message error: %v", err)
+		return
+	}
+
+	msgType, body, ok := protocol.UntagPayload(plaintext)
+	if !ok {
+		// Untagged payloads come from clients that predate tagging
+		body = plaintext
+		msgType = c.legacyPayloadType(plaintext)
+	}
+
+	switch msgType {
+	case protocol.MsgTypeDirectMessage:
+		var msg protocol.DirectMessage
+		if err := msg.Decode(body); err != nil || msg.To != c.Address {
+			log.Printf("Dropping direct message not meant for us (%d bytes)", len(body))
+			return
+		}
+		if c.OnMessage != nil {
+			c.OnMessage(&msg)
+		}
+
+	case protocol.MsgTypeGroupMessage:
+		var groupMsg protocol.GroupMessage
+		if err := groupMsg.Decode(body); err != nil {
+			log.Printf("Dropping undecodable group message: %v", err)
+			return
+		}
+		if c.OnGroupMessage != nil {
+			c.OnGroupMessage(&groupMsg)
+		}
+
+	default:
+		log.Printf("Dropping message of unhandled type %#04x (%d bytes)", msgType, len(body))
+	}
+}
+
+// legacyPayloadType guesses whether an untagged payload is a direct or group message
+func (c *Client) legacyPayloadType(plaintext []byte) uint16 {
+	var msg protocol.DirectMessage
+	if err := msg.Decode(plaintext); err == nil && msg.To == c.Address {
+		return protocol.MsgTypeDirectMessage
+	}
+	var groupMsg protocol.GroupMessage
+	if err := groupMsg.Decode(plaintext); err == nil {
+		return protocol.MsgTypeGroupMessage
+	}
+	return 0
+}
+
+// keepaliveLoop pings the relay until the connection is closed
+func (c *Client) keepaliveLoop(done chan struct{}) {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := c.writeFrame(protocol.MsgTypePing, 0, nil); err != nil {
+				log.Printf("⚠️  Keepalive ping failed: %v", err)
+			}
+		}
+	}
+}
+
+// writeFrame sends a header and payload in one write
+// Over WebSocket each write is one message, so frames are never split.
+func (c *Client) writeFrame(msgType uint16, flags uint16, payload []byte) error {
+	c.mu.Lock()
+	conn, limits := c.conn, c.limits
+	c.mu.Unlock()
+
+	if conn == nil {
+		return ErrNotConnected
+	}
+
+	frame := encodeFrame(msgType, flags, payload)
+	if err := limits.Check(&protocol.Header{Type: msgType, Length: uint32(len(payload))}); err != nil {
+		return err
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	_, err := conn.Write(frame)
+	return err
+}
+
+// encodeFrame encodes a header followed by payload
+func encodeFrame(msgType uint16, flags uint16, payload []byte) []byte {
+	header := &protocol.Header{
+		Magic:     protocol.ProtocolMagic,
+		Version:   protocol.ProtocolVersion,
+		Type:      msgType,
+		Length:    uint32(len(payload)),
+		Flags:     flags,
+		MessageID: protocol.GenerateMessageID(),
+	}
+
+	var buf bytes.Buffer
+	protocol.WriteHeader(&buf, header)
+	buf.Write(payload)
+	return buf.Bytes()
+}
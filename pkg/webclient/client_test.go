@@ -0,0 +1,153 @@
+//go:build !js
+
+package webclient
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/crypto"
+	"github.com/ZentaChain/zentalk-node/pkg/network"
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+func newKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// freePort returns a TCP port that was free a moment ago
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// startRelay starts a relay accepting WebSocket connections and returns its URL
+func startRelay(t *testing.T) string {
+	t.Helper()
+
+	relay := network.NewRelayServer(0, newKey(t))
+	relay.SetListenConfig(network.ListenConfig{
+		Hosts:         []string{"127.0.0.1"},
+		WebSocketPort: freePort(t),
+	})
+	if err := relay.Start(); err != nil {
+		t.Fatalf("relay.Start() error = %v", err)
+	}
+	t.Cleanup(func() { relay.Stop() })
+
+	return fmt.Sprintf("ws://127.0.0.1:%d/ws", relay.GetListenConfig().WebSocketPort)
+}
+
+func TestSendOverWebSocket(t *testing.T) {
+	relayURL := startRelay(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	alice, err := New(protocol.Address{1}, newKey(t), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobKey := newKey(t)
+	bob, err := New(protocol.Address{2}, bobKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan *protocol.DirectMessage, 1)
+	bob.OnMessage = func(msg *protocol.DirectMessage) { received <- msg }
+
+	for _, c := range []*Client{alice, bob} {
+		if err := c.Connect(ctx, relayURL); err != nil {
+			t.Fatalf("Connect() error = %v", err)
+		}
+		defer c.Close()
+	}
+
+	if alice.Relay() == nil {
+		t.Fatal("Relay() = nil after handshake")
+	}
+
+	if err := alice.SendText(bob.Address, &bobKey.PublicKey, "hello from the browser", nil); err != nil {
+		t.Fatalf("SendText() error = %v", err)
+	}
+
+	select {
+	case msg := <-received:
+		if string(msg.Content) != "hello from the browser" || msg.From != alice.Address {
+			t.Errorf("received %q from %x", msg.Content, msg.From)
+		}
+	case <-ctx.Done():
+		t.Fatal("message not delivered")
+	}
+}
+
+func TestSequencesPersist(t *testing.T) {
+	store, err := NewStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := newKey(t)
+	to := protocol.Address{9}
+
+	c, err := New(protocol.Address{1}, key, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.nextSequence(to)
+	c.nextSequence(to)
+
+	// A reloaded page continues where it left off
+	reloaded, err := New(protocol.Address{1}, key, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq := reloaded.nextSequence(to); seq != 2 {
+		t.Errorf("nextSequence() after reload = %d, want 2", seq)
+	}
+}
+
+func TestHandleTaggedAndLegacyPayloads(t *testing.T) {
+	key := newKey(t)
+	c, err := New(protocol.Address{2}, key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var direct, group int
+	c.OnMessage = func(*protocol.DirectMessage) { direct++ }
+	c.OnGroupMessage = func(*protocol.GroupMessage) { group++ }
+
+	deliver := func(plaintext []byte) {
+		payload, err := crypto.RSAEncrypt(plaintext, &key.PublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.handleDirectMessage(payload)
+	}
+
+	msg := &protocol.DirectMessage{From: protocol.Address{1}, To: c.Address, Content: []byte("hi")}
+	groupMsg := &protocol.GroupMessage{From: protocol.Address{1}, Content: []byte("hi all")}
+
+	deliver(protocol.TagPayload(protocol.MsgTypeDirectMessage, msg.Encode()))
+	deliver(protocol.TagPayload(protocol.MsgTypeGroupMessage, groupMsg.Encode()))
+	deliver(msg.Encode()) // From a client that predates tagging
+
+	if direct != 2 || group != 1 {
+		t.Errorf("delivered %d direct and %d group messages, want 2 and 1", direct, group)
+	}
+}
@@ -0,0 +1,69 @@
+package webclient
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// sequencesKey stores the next send sequence number per recipient
+const sequencesKey = "sequences"
+
+// Storage persists small values between sessions
+// NewStorage returns localStorage in the browser and a directory elsewhere.
+type Storage interface {
+	// Load returns the value stored under key, or nil if there is none
+	Load(key string) ([]byte, error)
+
+	// Save stores value under key
+	Save(key string, value []byte) error
+}
+
+// loadSequences reads the per-recipient send sequence numbers
+func loadSequences(store Storage) (map[protocol.Address]uint64, error) {
+	sequences := make(map[protocol.Address]uint64)
+	if store == nil {
+		return sequences, nil
+	}
+
+	data, err := store.Load(sequencesKey)
+	if err != nil || data == nil {
+		return sequences, err
+	}
+
+	var stored map[string]uint64
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to decode sequence numbers: %w", err)
+	}
+
+	for addrHex, seq := range stored {
+		b, err := hex.DecodeString(addrHex)
+		if err != nil || len(b) != 20 {
+			continue
+		}
+		var addr protocol.Address
+		copy(addr[:], b)
+		sequences[addr] = seq
+	}
+	return sequences, nil
+}
+
+// saveSequences writes the per-recipient send sequence numbers
+func saveSequences(store Storage, sequences map[protocol.Address]uint64) error {
+	if store == nil {
+		return nil
+	}
+
+	stored := make(map[string]uint64, len(sequences))
+	for addr, seq := range sequences {
+		stored[hex.EncodeToString(addr[:])] = seq
+	}
+
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	return store.Save(sequencesKey, data)
+}
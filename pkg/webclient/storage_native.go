@@ -0,0 +1,36 @@
+//go:build !js
+
+package webclient
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// dirStorage stores each key as a file in a directory
+type dirStorage struct {
+	dir string
+}
+
+// NewStorage returns storage backed by the directory namespace
+func NewStorage(namespace string) (Storage, error) {
+	if err := os.MkdirAll(namespace, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	return &dirStorage{dir: namespace}, nil
+}
+
+// Load implements Storage
+func (s *dirStorage) Load(key string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// Save implements Storage
+func (s *dirStorage) Save(key string, value []byte) error {
+	return os.WriteFile(filepath.Join(s.dir, key), value, 0600)
+}
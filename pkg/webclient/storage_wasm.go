@@ -0,0 +1,52 @@
+//go:build js && wasm
+
+package webclient
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"syscall/js"
+)
+
+// localStorage stores values base64-encoded in window.localStorage
+type localStorage struct {
+	prefix string
+	store  js.Value
+}
+
+// NewStorage returns storage backed by localStorage, with keys prefixed by namespace
+func NewStorage(namespace string) (Storage, error) {
+	store := js.Global().Get("localStorage")
+	if store.IsUndefined() || store.IsNull() {
+		return nil, errors.New("localStorage is not available")
+	}
+	return &localStorage{prefix: namespace + "/", store: store}, nil
+}
+
+// Load implements Storage
+func (s *localStorage) Load(key string) ([]byte, error) {
+	value := s.store.Call("getItem", s.prefix+key)
+	if value.IsNull() {
+		return nil, nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(value.String())
+	if err != nil {
+		return nil, fmt.Errorf("corrupt localStorage value for %s: %w", key, err)
+	}
+	return data, nil
+}
+
+// Save implements Storage
+// setItem throws when the origin's quota is exhausted.
+func (s *localStorage) Save(key string, value []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("localStorage write failed: %v", r)
+		}
+	}()
+
+	s.store.Call("setItem", s.prefix+key, base64.StdEncoding.EncodeToString(value))
+	return nil
+}
@@ -0,0 +1,204 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxAttempts is how often a delivery is tried before it is dropped
+	DefaultMaxAttempts = 5
+
+	// DefaultRetryDelay is the wait before the first retry; it doubles after each failure
+	DefaultRetryDelay = 2 * time.Second
+
+	// endpointQueueSize is the number of events buffered per endpoint
+	endpointQueueSize = 256
+
+	// requestTimeout bounds one POST
+	requestTimeout = 10 * time.Second
+)
+
+// Dispatcher delivers events to endpoints in the background
+// Each endpoint has its own queue and worker, so a slow or failing endpoint
+// doesn't hold up the others. Events for one endpoint arrive in order.
+type Dispatcher struct {
+	targets     []*target
+	http        *http.Client
+	maxAttempts int
+	retryDelay  time.Duration
+
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+	running  bool
+	mu       sync.Mutex
+}
+
+// target is an endpoint and its pending events
+type target struct {
+	endpoint Endpoint
+	queue    chan *Event
+}
+
+// NewDispatcher creates a dispatcher for the endpoints with default retries
+func NewDispatcher(endpoints []Endpoint) *Dispatcher {
+	d := &Dispatcher{
+		http:        &http.Client{Timeout: requestTimeout},
+		maxAttempts: DefaultMaxAttempts,
+		retryDelay:  DefaultRetryDelay,
+		stopChan:    make(chan struct{}),
+	}
+	for _, endpoint := range endpoints {
+		d.targets = append(d.targets, &target{endpoint: endpoint, queue: make(chan *Event, endpointQueueSize)})
+	}
+	return d
+}
+
+// SetRetryPolicy sets how often and how soon failed deliveries are retried
+func (d *Dispatcher) SetRetryPolicy(maxAttempts int, retryDelay time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if maxAttempts > 0 {
+		d.maxAttempts = maxAttempts
+	}
+	if retryDelay > 0 {
+		d.retryDelay = retryDelay
+	}
+}
+
+// Start starts one delivery worker per endpoint
+func (d *Dispatcher) Start() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.running {
+		return fmt.Errorf("webhook dispatcher already running")
+	}
+	d.running = true
+
+	for _, t := range d.targets {
+		d.wg.Add(1)
+		go d.worker(t, d.maxAttempts, d.retryDelay)
+	}
+
+	log.Printf("🪝 Delivering webhooks to %d endpoints", len(d.targets))
+	return nil
+}
+
+// Stop stops the workers; queued events are dropped
+func (d *Dispatcher) Stop() {
+	d.mu.Lock()
+	if !d.running {
+		d.mu.Unlock()
+		return
+	}
+	d.running = false
+	close(d.stopChan)
+	d.mu.Unlock()
+
+	d.wg.Wait()
+	log.Println("🛑 Stopped webhook delivery")
+}
+
+// Deliver queues an event for every endpoint subscribed to its type
+// Never blocks: if an endpoint's queue is full the event is dropped for it.
+func (d *Dispatcher) Deliver(event *Event) {
+	for _, t := range d.targets {
+		if !t.endpoint.wants(event.Type) {
+			continue
+		}
+		select {
+		case t.queue <- event:
+		default:
+			log.Printf("⚠️  Webhook queue for %s full, dropping event %s", t.endpoint.URL, event.ID)
+		}
+	}
+}
+
+// worker posts one endpoint's events until the dispatcher stops
+func (d *Dispatcher) worker(t *target, maxAttempts int, retryDelay time.Duration) {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case event := <-t.queue:
+			d.deliverWithRetry(t.endpoint, event, maxAttempts, retryDelay)
+		case <-d.stopChan:
+			return
+		}
+	}
+}
+
+// deliverWithRetry posts an event, backing off between failed attempts
+func (d *Dispatcher) deliverWithRetry(endpoint Endpoint, event *Event, maxAttempts int, retryDelay time.Duration) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("⚠️  Failed to encode webhook event %s: %v", event.ID, err)
+		return
+	}
+
+	delay := retryDelay
+	for attempt := 1; ; attempt++ {
+		retry, err := d.post(endpoint, event, body)
+		if err == nil {
+			return
+		}
+		if !retry || attempt >= maxAttempts {
+			log.Printf("❌ Webhook %s failed for event %s after %d attempts: %v", endpoint.URL, event.ID, attempt, err)
+			return
+		}
+
+		log.Printf("⚠️  Webhook %s failed (attempt %d/%d), retrying in %v: %v", endpoint.URL, attempt, maxAttempts, delay, err)
+		select {
+		case <-time.After(delay):
+			delay *= 2
+		case <-d.stopChan:
+			return
+		}
+	}
+}
+
+// post sends one delivery; retry reports whether a failure may succeed later
+func (d *Dispatcher) post(endpoint Endpoint, event *Event, body []byte) (retry bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	// Signed with the time of this attempt, so retries aren't refused as stale
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, event.Type)
+	req.Header.Set(HeaderDelivery, event.ID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	if len(endpoint.Secret) > 0 {
+		req.Header.Set(HeaderSignature, Sign(endpoint.Secret, timestamp, body))
+	}
+
+	resp, err := d.http.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("HTTP %d", resp.StatusCode)
+	default:
+		// The endpoint rejected the event itself; sending it again won't help
+		return false, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+}
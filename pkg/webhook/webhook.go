@@ -0,0 +1,182 @@
+// Package webhook posts incoming ZenTalk messages to HTTP endpoints
+//
+// Each delivery is a JSON Event. Endpoints with a secret get an HMAC-SHA256
+// signature over the timestamp and body, so a receiver can check a request
+// came from its own daemon and is not a replay:
+//
+//	X-Zentalk-Event:     message
+//	X-Zentalk-Delivery:  <event ID, the same on every retry>
+//	X-Zentalk-Timestamp: <unix seconds>
+//	X-Zentalk-Signature: sha256=<hex HMAC of "<timestamp>.<body>">
+//
+// Receivers written in Go can check requests with Verify.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+// Event types
+const (
+	EventMessage        = "message"         // Direct message from a contact
+	EventMessageRequest = "message_request" // First message from an unknown sender
+	EventGroupMessage   = "group_message"
+)
+
+// Request headers
+const (
+	HeaderEvent     = "X-Zentalk-Event"
+	HeaderDelivery  = "X-Zentalk-Delivery"
+	HeaderTimestamp = "X-Zentalk-Timestamp"
+	HeaderSignature = "X-Zentalk-Signature"
+)
+
+var ErrBadSignature = errors.New("webhook signature mismatch")
+
+// Event is the JSON body of a webhook delivery
+type Event struct {
+	ID          string `json:"id"` // Unique per event; receivers can drop repeats
+	Type        string `json:"type"`
+	From        string `json:"from"`                 // Hex sender address
+	To          string `json:"to,omitempty"`         // Hex recipient address (direct messages)
+	GroupID     string `json:"group_id,omitempty"`   // Hex group ID (group messages)
+	MessageID   string `json:"message_id,omitempty"` // Hex message ID (group messages)
+	Timestamp   int64  `json:"timestamp"`            // Unix milliseconds, as sent
+	ContentType uint8  `json:"content_type"`
+	Text        string `json:"text,omitempty"`    // Text messages
+	Content     []byte `json:"content,omitempty"` // Other content types, raw (base64 in JSON)
+}
+
+// Endpoint is a URL that receives events
+type Endpoint struct {
+	URL    string
+	Secret []byte   // HMAC key (empty = unsigned)
+	Events []string // Event types to send (empty = all)
+}
+
+// NewMessageEvent creates an event for a direct message
+// eventType is EventMessage or EventMessageRequest.
+func NewMessageEvent(eventType string, msg *protocol.DirectMessage) *Event {
+	e := newEvent(eventType, msg.From, int64(msg.Timestamp), msg.ContentType, msg.Content)
+	e.To = hex.EncodeToString(msg.To[:])
+	return e
+}
+
+// NewGroupMessageEvent creates an event for a group message
+func NewGroupMessageEvent(msg *protocol.GroupMessage) *Event {
+	e := newEvent(EventGroupMessage, msg.From, int64(msg.Timestamp), msg.ContentType, msg.Content)
+	e.GroupID = hex.EncodeToString(msg.GroupID[:])
+	if msg.MessageID != (protocol.MessageID{}) {
+		e.MessageID = hex.EncodeToString(msg.MessageID[:])
+	}
+	return e
+}
+
+// newEvent fills in the fields every event has
+func newEvent(eventType string, from protocol.Address, timestamp int64, contentType uint8, content []byte) *Event {
+	id := protocol.GenerateMessageID()
+	e := &Event{
+		ID:          hex.EncodeToString(id[:]),
+		Type:        eventType,
+		From:        hex.EncodeToString(from[:]),
+		Timestamp:   timestamp,
+		ContentType: contentType,
+	}
+	if contentType == protocol.ContentTypeText {
+		e.Text = string(content)
+	} else {
+		e.Content = content
+	}
+	return e
+}
+
+// wants returns true if the endpoint subscribed to the event type
+func (ep *Endpoint) wants(eventType string) bool {
+	if len(ep.Events) == 0 {
+		return true
+	}
+	for _, t := range ep.Events {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign returns the signature header value for a body sent at timestamp (Unix seconds)
+func Sign(secret []byte, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a delivery's timestamp and signature headers against its body
+// Requests older or newer than maxAge are refused as possible replays.
+func Verify(secret []byte, timestampHeader, signatureHeader string, body []byte, maxAge time.Duration) error {
+	timestamp, err := strconv.ParseInt(timestampHeader, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid webhook timestamp %q", timestampHeader)
+	}
+	if age := time.Since(time.Unix(timestamp, 0)); age > maxAge || age < -maxAge {
+		return fmt.Errorf("webhook timestamp outside the allowed %v", maxAge)
+	}
+
+	if !hmac.Equal([]byte(signatureHeader), []byte(Sign(secret, timestamp, body))) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// endpointFileEntry is one endpoint in a hooks file
+type endpointFileEntry struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+}
+
+// LoadEndpoints reads endpoints from a JSON file
+// Format: [{"url": "https://example.org/hook", "secret": "...", "events": ["message", "group_message"]}, ...]
+func LoadEndpoints(path string) ([]Endpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hooks file: %w", err)
+	}
+
+	var entries []endpointFileEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse hooks file: %w", err)
+	}
+
+	endpoints := make([]Endpoint, 0, len(entries))
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.URL, "http://") && !strings.HasPrefix(entry.URL, "https://") {
+			return nil, fmt.Errorf("invalid hook URL %q", entry.URL)
+		}
+		for _, t := range entry.Events {
+			if t != EventMessage && t != EventMessageRequest && t != EventGroupMessage {
+				return nil, fmt.Errorf("hook %s: unknown event type %q", entry.URL, t)
+			}
+		}
+
+		endpoint := Endpoint{URL: entry.URL, Events: entry.Events}
+		if entry.Secret != "" {
+			endpoint.Secret = []byte(entry.Secret)
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+
+	return endpoints, nil
+}
@@ -0,0 +1,119 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ZentaChain/zentalk-node/pkg/protocol"
+)
+
+func TestSignVerify(t *testing.T) {
+	secret := []byte("s3cret")
+	body := []byte(`{"type":"message"}`)
+	now := time.Now().Unix()
+	sig := Sign(secret, now, body)
+	ts := strconv.FormatInt(now, 10)
+
+	if err := Verify(secret, ts, sig, body, time.Minute); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+	if err := Verify(secret, ts, sig, []byte(`{"type":"other"}`), time.Minute); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Verify(modified body) error = %v, want ErrBadSignature", err)
+	}
+	if err := Verify([]byte("wrong"), ts, sig, body, time.Minute); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Verify(wrong secret) error = %v, want ErrBadSignature", err)
+	}
+
+	old := now - 3600
+	if err := Verify(secret, strconv.FormatInt(old, 10), Sign(secret, old, body), body, time.Minute); err == nil {
+		t.Error("Verify(stale timestamp) expected error, got nil")
+	}
+}
+
+func TestDispatcherDelivers(t *testing.T) {
+	secret := []byte("s3cret")
+	var attempts atomic.Int32
+	received := make(chan *Event, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Fail once to exercise the retry
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		if err := Verify(secret, r.Header.Get(HeaderTimestamp), r.Header.Get(HeaderSignature), body, time.Minute); err != nil {
+			t.Errorf("Verify() error = %v", err)
+		}
+		var event Event
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Errorf("invalid event body: %v", err)
+		}
+		if r.Header.Get(HeaderDelivery) != event.ID || r.Header.Get(HeaderEvent) != event.Type {
+			t.Errorf("headers don't match event %+v", event)
+		}
+		received <- &event
+	}))
+	defer server.Close()
+
+	d := NewDispatcher([]Endpoint{
+		{URL: server.URL, Secret: secret, Events: []string{EventMessage}},
+	})
+	d.SetRetryPolicy(3, 10*time.Millisecond)
+	if err := d.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Stop()
+
+	// Not subscribed: never sent
+	d.Deliver(NewGroupMessageEvent(&protocol.GroupMessage{From: protocol.Address{1}, ContentType: protocol.ContentTypeText}))
+
+	d.Deliver(NewMessageEvent(EventMessage, &protocol.DirectMessage{
+		From:        protocol.Address{1},
+		To:          protocol.Address{2},
+		Timestamp:   1700000000000,
+		ContentType: protocol.ContentTypeText,
+		Content:     []byte("deploy prod"),
+	}))
+
+	select {
+	case event := <-received:
+		if event.Type != EventMessage || event.Text != "deploy prod" || event.Timestamp != 1700000000000 || len(event.Content) != 0 {
+			t.Errorf("received %+v", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("event not delivered")
+	}
+	if got := attempts.Load(); got != 2 {
+		t.Errorf("attempts = %d, want 2", got)
+	}
+}
+
+func TestLoadEndpoints(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "hooks.json")
+	os.WriteFile(path, []byte(`[{"url": "https://example.org/hook", "secret": "abc", "events": ["group_message"]}]`), 0600)
+	endpoints, err := LoadEndpoints(path)
+	if err != nil {
+		t.Fatalf("LoadEndpoints() error = %v", err)
+	}
+	if len(endpoints) != 1 || string(endpoints[0].Secret) != "abc" || !endpoints[0].wants(EventGroupMessage) || endpoints[0].wants(EventMessage) {
+		t.Errorf("LoadEndpoints() = %+v", endpoints)
+	}
+
+	os.WriteFile(path, []byte(`[{"url": "https://example.org/hook", "events": ["typing"]}]`), 0600)
+	if _, err := LoadEndpoints(path); err == nil {
+		t.Error("LoadEndpoints(unknown event) expected error, got nil")
+	}
+}